@@ -148,6 +148,15 @@ func (al *ActivityLogger) LogWarn(activity string, message string, details map[s
 	al.LogActivityWithLevel("warn", activity, details)
 }
 
+// Notify records an alert as a warning in the activity log, so the agent can
+// be used as a core.Notifier for background maintenance.
+func (al *ActivityLogger) Notify(ctx context.Context, title, message string) error {
+	al.LogWarn("alert", message, map[string]interface{}{
+		"title": title,
+	})
+	return nil
+}
+
 // LogDebug is a convenience method for logging debug information.
 func (al *ActivityLogger) LogDebug(activity string, details map[string]interface{}) {
 	al.LogActivityWithLevel("debug", activity, details)
@@ -166,7 +175,7 @@ func (al *ActivityLogger) GetActivityLogs(ctx context.Context, filter ActivityLo
 	}
 
 	// Execute query
-	nodes, err := query.All()
+	nodes, err := query.AllContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query activity logs: %w", err)
 	}
@@ -8,8 +8,11 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -18,9 +21,15 @@ import (
 	"github.com/Solifugus/ai-work-studio/pkg/core"
 	"github.com/Solifugus/ai-work-studio/pkg/llm"
 	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/metrics"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/memwatch"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
 )
 
+// performanceSaveInterval is how often the agent persists model performance.
+const performanceSaveInterval = 5 * time.Minute
+
 // Agent represents the background daemon with all its dependencies.
 type Agent struct {
 	config            *config.Config
@@ -33,6 +42,9 @@ type Agent struct {
 	ethicalFramework  *core.EthicalFramework
 	learningLoop      *core.LearningLoop
 	scheduler         *Scheduler
+	watchdog          *memwatch.Watchdog
+	replication       *storage.ReplicationServer
+	metrics           *metrics.Server
 	llmRouter         *llm.Router
 	logger            *ActivityLogger
 	ctx               context.Context
@@ -191,11 +203,13 @@ func (m *MockLLMService) Execute(ctx context.Context, params mcp.ServiceParams)
 				strings.Contains(strings.ToLower(prompt), "impact")
 
 			if isEthical {
-				response := `Freedom Impact: 0.8
-Well-Being Impact: 0.7
-Sustainability Impact: 0.6
-Confidence: 0.9
-Reasoning: Automated execution appears to have positive impact across all dimensions.`
+				response := `{
+  "freedom_impact": 0.8,
+  "well_being_impact": 0.7,
+  "sustainability_impact": 0.6,
+  "confidence": 0.9,
+  "reasoning": "Automated execution appears to have positive impact across all dimensions."
+}`
 
 				return mcp.ServiceResult{
 					Success: true,
@@ -309,20 +323,59 @@ func main() {
 
 // NewAgent creates a new background agent instance with all dependencies.
 func NewAgent(cfg *config.Config, configPath string, checkInterval int, dryRun bool) (*Agent, error) {
-	// Initialize storage
-	store, err := storage.NewStore(cfg.DataDir)
+	// The agent makes changes, so it runs only where the store is primary
+	if cfg.Replication.IsReplica() {
+		addr, _, _ := storage.ParsePrimary(cfg.Replication.Primary)
+		return nil, fmt.Errorf("this machine is a read replica of %s; run the agent there", addr)
+	}
+
+	// Initialize storage, sized by the operating profile
+	store, err := storage.NewStore(cfg.DataDir, cfg.Profile.StoreOptions()...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
+	// Record outbound requests and apply the network allowlist. Failing to
+	// set this up is fatal, so an enforced allowlist is never silently dropped.
+	auditor, err := netaudit.NewAuditor(cfg.Network.AuditorConfig(cfg.DataDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize network auditing: %w", err)
+	}
+	netaudit.SetDefault(auditor)
+
 	// Initialize managers
 	goalManager := core.NewGoalManager(store)
 	objectiveManager := core.NewObjectiveManager(store)
+	objectiveManager.SetWIPLimits(core.WIPLimits{
+		MaxInProgress:        cfg.Preferences.MaxInProgressObjectives,
+		MaxInProgressPerGoal: cfg.Preferences.MaxInProgressPerGoal,
+		Goals:                cfg.Preferences.GoalWIPLimits,
+	})
 	methodManager := core.NewMethodManager(store)
 	contextManager := core.NewUserContextManager(store)
 
-	// Initialize LLM router
-	llmRouter := llm.NewRouter(&MockLLMService{})
+	// Initialize LLM router, counting tokens exactly where vocabularies are installed
+	routerConfig := llm.DefaultRouterConfig()
+	routerConfig.TokenEstimator = llm.NewTokenEstimator(llm.TokenizerConfig{
+		VocabularyDir: cfg.API.Tokenizer.VocabularyDir,
+		Models:        cfg.API.Tokenizer.Models,
+	})
+	routerConfig.Credentials = llm.NewCredentialMonitor(llm.CredentialMonitorConfig{
+		Sources: llm.EnvironmentCredentialSources(),
+	})
+	routerConfig.PerformanceStore = llm.NewStoragePerformanceStore(store)
+	routerConfig.RoutingStore = llm.NewStorageRoutingStore(store, 0)
+	routerConfig.Policy = llm.RoutingPolicy(cfg.Routing.Policy)
+	llmRouter := llm.NewRouter(&MockLLMService{}, routerConfig)
+	if err := llmRouter.LoadPerformance(context.Background()); err != nil {
+		fmt.Printf("Warning: failed to load model performance: %v\n", err)
+	}
+	exchangeLogger, err := llm.NewExchangeLogger(filepath.Join(cfg.DataDir, "exchanges"), llm.DefaultExchangeLogConfig(), nil)
+	if err != nil {
+		fmt.Printf("Warning: failed to initialize exchange logging: %v\n", err)
+	} else {
+		llmRouter.SetExchangeLogger(exchangeLogger)
+	}
 
 	// Initialize ethical framework
 	ethicalFramework := core.NewEthicalFramework(store, llmRouter, contextManager)
@@ -338,12 +391,18 @@ func NewAgent(cfg *config.Config, configPath string, checkInterval int, dryRun b
 		return nil, fmt.Errorf("failed to initialize activity logger: %w", err)
 	}
 
+	// Report providers that start rejecting their keys
+	llmRouter.Credentials().SetOnAlert(func(alert llm.CredentialAlert) {
+		logger.Notify(context.Background(), alert.Title(), alert.Message())
+	})
+
 	// Create context for cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Create agent configuration
 	agentConfig := DefaultAgentConfig()
 	agentConfig.CheckInterval = checkInterval
+	agentConfig.MaxConcurrentObjectives = cfg.Profile.WorkerLimit(agentConfig.MaxConcurrentObjectives)
 
 	// Initialize scheduler
 	scheduler, err := NewScheduler(SchedulerConfig{
@@ -356,6 +415,33 @@ func NewAgent(cfg *config.Config, configPath string, checkInterval int, dryRun b
 		return nil, fmt.Errorf("failed to initialize scheduler: %w", err)
 	}
 
+	// Shed load near the memory budget instead of being killed: pause new
+	// work first, then drop caches and hand free memory back to the OS
+	watchdog := memwatch.New(memwatch.Config{
+		Ceiling:  cfg.Profile.MemoryCeiling(),
+		SetLimit: true,
+		OnShed: func(event memwatch.Event) {
+			logger.LogWarn("memory_shed", event.String(), map[string]interface{}{
+				"used_bytes":    event.Used,
+				"ceiling_bytes": event.Ceiling,
+			})
+		},
+		OnResume: func(event memwatch.Event) {
+			logger.LogActivity("memory_resumed", map[string]interface{}{
+				"used_bytes":    event.Used,
+				"ceiling_bytes": event.Ceiling,
+			})
+		},
+	})
+	watchdog.Add(memwatch.Shedder{Name: "scheduler", Shed: scheduler.Pause, Resume: scheduler.Resume})
+	watchdog.Add(memwatch.Shedder{Name: "archive cache", Shed: func() string {
+		if dropped := store.DropCaches(); dropped > 0 {
+			return fmt.Sprintf("dropped %d cached segments", dropped)
+		}
+		return ""
+	}})
+	watchdog.Add(memwatch.FreeOSMemory())
+
 	return &Agent{
 		config:           cfg,
 		configPath:       configPath,
@@ -367,6 +453,7 @@ func NewAgent(cfg *config.Config, configPath string, checkInterval int, dryRun b
 		ethicalFramework: ethicalFramework,
 		learningLoop:     learningLoop,
 		scheduler:        scheduler,
+		watchdog:         watchdog,
 		llmRouter:        llmRouter,
 		logger:           logger,
 		ctx:              ctx,
@@ -382,8 +469,78 @@ func (a *Agent) Start() error {
 		"data_directory": a.config.DataDir,
 		"auto_approve":   a.config.Preferences.AutoApprove,
 		"check_interval": a.scheduler.config.CheckInterval,
+		"profile":        a.config.Profile.Name,
 	})
 
+	// Scheduled backups need a configured backup directory
+	var backupManager *core.BackupManager
+	if a.config.Storage.BackupEnabled && a.config.Storage.BackupDir != "" {
+		backupManager = core.NewBackupManager(a.store, core.BackupConfig{
+			TargetDir:  a.config.Storage.BackupDir,
+			KeepDaily:  a.config.Storage.BackupKeepDaily,
+			KeepWeekly: a.config.Storage.BackupKeepWeekly,
+			Notifier:   a.logger,
+		})
+	}
+
+	// Drift detection reads the request history kept by budget tracking
+	var driftDetector *core.DriftDetector
+	var budget *llm.BudgetManager
+	if a.config.BudgetLimits.TrackingEnabled {
+		location, err := a.config.BudgetLimits.Location()
+		if err != nil {
+			location = time.Local
+		}
+		enforcement, err := a.config.BudgetLimits.EnforcementMode()
+		if err != nil {
+			enforcement = mcp.BudgetEnforcementHard
+		}
+		tracked, err := llm.NewBudgetManager(filepath.Join(a.config.DataDir, "budget"), llm.BudgetConfig{
+			DailyLimit:      a.config.BudgetLimits.DailyLimit,
+			WeeklyLimit:     a.config.BudgetLimits.WeeklyLimit,
+			MonthlyLimit:    a.config.BudgetLimits.MonthlyLimit,
+			TrackingEnabled: true,
+			Location:        location,
+			Enforcement:     enforcement,
+			GracePercent:    a.config.BudgetLimits.GracePercent,
+		}, log.Default())
+		if err != nil {
+			log.Printf("Warning: model drift will not be checked: %v", err)
+		} else {
+			budget = tracked
+			driftConfig := core.DefaultDriftConfig()
+			driftConfig.Notifier = a.logger
+			driftDetector = core.NewDriftDetector(budget, a.llmRouter, driftConfig)
+		}
+	}
+
+	// Preference mining is opt-in; what it finds waits for confirmation
+	var preferenceMiner *core.PreferenceMiner
+	if a.config.Preferences.MinePreferences {
+		miningConfig := core.DefaultPreferenceMiningConfig()
+		miningConfig.Router = a.llmRouter
+		miningConfig.Budget = budget
+		miningConfig.WeeklyBudget = a.config.Preferences.PreferenceMiningWeeklyLimit
+		preferenceMiner = core.NewPreferenceMiner(a.store, a.contextManager, miningConfig)
+	}
+
+	// Serve read replicas, such as a desktop GUI following this host
+	if a.config.Replication.Listen != "" {
+		if err := a.startReplication(); err != nil {
+			return err
+		}
+	}
+
+	// Serve metrics for scraping; nothing is recorded otherwise
+	if a.config.Metrics.Enabled() {
+		if err := a.startMetrics(); err != nil {
+			return err
+		}
+	}
+
+	go a.watchdog.Start(a.ctx)
+	go a.savePerformancePeriodically(a.ctx)
+
 	// Start the scheduler
 	go a.scheduler.Start(a.ctx, &SchedulerDependencies{
 		ObjectiveManager: a.objectiveManager,
@@ -392,6 +549,10 @@ func (a *Agent) Start() error {
 		ContextManager:   a.contextManager,
 		Config:           a.config,
 		Logger:           a.logger,
+		BackupManager:    backupManager,
+		ArchiveManager:   core.NewArchiveManager(a.store),
+		DriftDetector:    driftDetector,
+		PreferenceMiner:  preferenceMiner,
 	})
 
 	return nil
@@ -411,8 +572,99 @@ func (a *Agent) Stop() {
 	})
 }
 
+// startReplication starts serving replicas of the store and records the
+// listening endpoint in the network audit log.
+func (a *Agent) startReplication() error {
+	server, err := storage.NewReplicationServer(a.store, a.config.Replication.ServerConfig(a.config.DataDir))
+	if err != nil {
+		return fmt.Errorf("failed to configure replication: %w", err)
+	}
+	if err := server.Start(); err != nil {
+		return err
+	}
+	a.replication = server
+
+	// The audit log holds outbound requests; the listener is recorded as a
+	// LISTEN entry so every endpoint the studio exposes shows up there too
+	host, portText, _ := net.SplitHostPort(server.Addr())
+	port, _ := strconv.Atoi(portText)
+	auditor := netaudit.Default()
+	auditor.Record(netaudit.Record{
+		Component:   "replication",
+		Method:      "LISTEN",
+		Host:        host,
+		Port:        port,
+		Allowlisted: auditor.Allowed(host, port),
+	})
+
+	a.logger.LogActivity("replication_started", map[string]interface{}{
+		"address":   server.Addr(),
+		"loopback":  storage.IsLoopbackAddr(server.Addr()),
+		"sequence":  a.store.Sequence(),
+		"has_token": a.config.Replication.Token != "",
+	})
+	log.Printf("Serving read replicas on %s", server.Addr())
+	return nil
+}
+
+// startMetrics starts serving metrics and records the listening endpoint in
+// the network audit log.
+func (a *Agent) startMetrics() error {
+	server := metrics.NewServer(metrics.Default, a.config.Metrics.Listen)
+	if err := server.Start(); err != nil {
+		return err
+	}
+	a.metrics = server
+
+	host, portText, _ := net.SplitHostPort(server.Addr())
+	port, _ := strconv.Atoi(portText)
+	auditor := netaudit.Default()
+	auditor.Record(netaudit.Record{
+		Component:   "metrics",
+		Method:      "LISTEN",
+		Host:        host,
+		Port:        port,
+		Allowlisted: auditor.Allowed(host, port),
+	})
+
+	a.logger.LogActivity("metrics_started", map[string]interface{}{
+		"address":  server.Addr(),
+		"loopback": storage.IsLoopbackAddr(server.Addr()),
+	})
+	log.Printf("Serving metrics on http://%s%s", server.Addr(), metrics.Path)
+	return nil
+}
+
+// savePerformancePeriodically persists what routing learned every
+// performanceSaveInterval, so a crash loses little of it.
+func (a *Agent) savePerformancePeriodically(ctx context.Context) {
+	ticker := time.NewTicker(performanceSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.llmRouter.SavePerformance(ctx); err != nil {
+				log.Printf("Warning: failed to save model performance: %v", err)
+			}
+		}
+	}
+}
+
 // Close cleans up agent resources.
 func (a *Agent) Close() {
+	if a.replication != nil {
+		a.replication.Close()
+	}
+	if a.metrics != nil {
+		a.metrics.Close()
+	}
+	if a.llmRouter != nil {
+		if err := a.llmRouter.SavePerformance(context.Background()); err != nil {
+			log.Printf("Warning: failed to save model performance: %v", err)
+		}
+	}
 	if a.store != nil {
 		a.store.Close()
 	}
@@ -5,10 +5,32 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Solifugus/ai-work-studio/internal/config"
 	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+const (
+	// backupInterval is how often scheduled backups are taken
+	backupInterval = 24 * time.Hour
+
+	// backupRetryDelay is how long to wait after a failed backup before retrying
+	backupRetryDelay = time.Hour
+
+	// archiveInterval is how often settled work is moved to the archive
+	archiveInterval = 24 * time.Hour
+
+	// driftCheckInterval is how often model results are checked for drift
+	driftCheckInterval = 6 * time.Hour
+
+	// preferenceMiningInterval is how often ratings and edits are mined for preferences
+	preferenceMiningInterval = time.Hour
+
+	// contextConsolidationInterval is how often duplicate user context is merged
+	contextConsolidationInterval = 7 * 24 * time.Hour
 )
 
 // Scheduler manages background monitoring and execution of objectives.
@@ -17,6 +39,11 @@ type Scheduler struct {
 	runningObjectives sync.Map // map[string]context.CancelFunc for tracking running objectives
 	mutex            sync.RWMutex
 	executionCount   int
+	lastArchive      time.Time
+	lastDriftCheck   time.Time
+	lastMining       time.Time
+	lastConsolidation time.Time
+	paused           atomic.Bool // Set while the memory watchdog sheds load
 }
 
 // SchedulerConfig defines configuration for the scheduler.
@@ -29,6 +56,9 @@ type SchedulerConfig struct {
 
 	// DryRun mode simulates execution without making changes
 	DryRun bool
+
+	// Clock drives the check loop and maintenance timing (default: the system clock)
+	Clock utils.Clock
 }
 
 // SchedulerDependencies contains all external dependencies the scheduler needs.
@@ -39,6 +69,10 @@ type SchedulerDependencies struct {
 	ContextManager   *core.UserContextManager
 	Config           *config.Config
 	Logger           *ActivityLogger
+	BackupManager    *core.BackupManager // nil when no backup directory is configured
+	ArchiveManager   *core.ArchiveManager
+	DriftDetector    *core.DriftDetector // nil when usage tracking is unavailable
+	PreferenceMiner  *core.PreferenceMiner // nil unless preference mining is enabled
 }
 
 // ExecutionContext tracks the context of a running objective.
@@ -57,6 +91,7 @@ func NewScheduler(config SchedulerConfig) (*Scheduler, error) {
 	if config.MaxConcurrentObjectives <= 0 {
 		config.MaxConcurrentObjectives = 3 // Default to 3
 	}
+	config.Clock = utils.ClockOrReal(config.Clock)
 
 	return &Scheduler{
 		config: config,
@@ -65,7 +100,7 @@ func NewScheduler(config SchedulerConfig) (*Scheduler, error) {
 
 // Start begins the scheduler's monitoring loop.
 func (s *Scheduler) Start(ctx context.Context, deps *SchedulerDependencies) {
-	ticker := time.NewTicker(s.config.CheckInterval)
+	ticker := s.config.Clock.NewTicker(s.config.CheckInterval)
 	defer ticker.Stop()
 
 	log.Printf("Scheduler started with %d max concurrent objectives", s.config.MaxConcurrentObjectives)
@@ -76,12 +111,35 @@ func (s *Scheduler) Start(ctx context.Context, deps *SchedulerDependencies) {
 			log.Println("Scheduler stopping due to context cancellation")
 			s.stopAllRunningObjectives()
 			return
-		case <-ticker.C:
+		case <-ticker.C():
+			if s.paused.Load() {
+				continue
+			}
+			s.checkAndRunBackup(ctx, deps)
+			s.checkAndRunArchive(ctx, deps)
+			s.checkForModelDrift(ctx, deps)
+			s.checkAndMinePreferences(ctx, deps)
+			s.checkAndConsolidateContext(ctx, deps)
 			s.checkAndExecuteObjectives(ctx, deps)
 		}
 	}
 }
 
+// Pause stops starting background jobs and new objectives until Resume.
+// Objectives already running continue. It describes what was paused, or
+// returns "" if the scheduler was already paused.
+func (s *Scheduler) Pause() string {
+	if s.paused.Swap(true) {
+		return ""
+	}
+	return fmt.Sprintf("paused backups, archiving, drift checks, preference mining, context consolidation and new objectives (%d running)", s.getRunningObjectiveCount())
+}
+
+// Resume restarts the checks stopped by Pause.
+func (s *Scheduler) Resume() {
+	s.paused.Store(false)
+}
+
 // checkAndExecuteObjectives checks for pending objectives and executes them if appropriate.
 func (s *Scheduler) checkAndExecuteObjectives(ctx context.Context, deps *SchedulerDependencies) {
 	s.mutex.Lock()
@@ -116,6 +174,22 @@ func (s *Scheduler) checkAndExecuteObjectives(ctx context.Context, deps *Schedul
 		return
 	}
 
+	// Objectives over the work-in-progress limits wait for a later check
+	objectives, deferred, err := deps.ObjectiveManager.SelectStartable(ctx, objectives)
+	if err != nil {
+		log.Printf("Error checking work-in-progress limits: %v", err)
+		return
+	}
+	for _, objective := range deferred {
+		if deps.Config.Preferences.VerboseOutput {
+			log.Printf("Objective %s deferred: work-in-progress limit reached", objective.ID)
+		}
+		deps.Logger.LogActivity("wip_deferred", map[string]interface{}{
+			"objective_id": objective.ID,
+			"goal_id":      objective.GoalID,
+		})
+	}
+
 	// Process each pending objective
 	for _, objective := range objectives {
 		if s.shouldExecuteObjective(ctx, objective, deps) {
@@ -130,6 +204,184 @@ func (s *Scheduler) checkAndExecuteObjectives(ctx context.Context, deps *Schedul
 	}
 }
 
+// checkAndRunBackup takes a backup when one is due. If quiet hours are
+// configured, backups only run inside them.
+func (s *Scheduler) checkAndRunBackup(ctx context.Context, deps *SchedulerDependencies) {
+	if deps.BackupManager == nil || s.config.DryRun {
+		return
+	}
+
+	now := s.config.Clock.Now()
+	prefs := deps.Config.Preferences
+	if prefs.HasQuietHours() && !prefs.InQuietHours(now) {
+		return
+	}
+
+	health, err := deps.BackupManager.Health(ctx)
+	if err != nil {
+		deps.Logger.LogError("backup", err, map[string]interface{}{
+			"context": "checking_backup_health",
+		})
+		return
+	}
+	if !health.BackupDue(now, backupInterval, backupRetryDelay) {
+		return
+	}
+
+	// Failures are already reported through the backup manager's notifier
+	manifest, err := deps.BackupManager.Backup(ctx)
+	if err != nil {
+		log.Printf("Scheduled backup failed: %v", err)
+		return
+	}
+
+	deps.Logger.LogActivity("backup_completed", map[string]interface{}{
+		"backup_id":    manifest.ID,
+		"files":        len(manifest.Files),
+		"files_copied": manifest.FilesCopied,
+		"bytes_copied": manifest.BytesCopied,
+	})
+}
+
+// checkAndRunArchive moves settled work to the archive once a day, keeping
+// startup time and memory bounded as history grows. Like backups, it waits
+// for quiet hours when they are configured.
+func (s *Scheduler) checkAndRunArchive(ctx context.Context, deps *SchedulerDependencies) {
+	if deps.ArchiveManager == nil || s.config.DryRun {
+		return
+	}
+
+	now := s.config.Clock.Now()
+	if now.Sub(s.lastArchive) < archiveInterval {
+		return
+	}
+	prefs := deps.Config.Preferences
+	if prefs.HasQuietHours() && !prefs.InQuietHours(now) {
+		return
+	}
+	s.lastArchive = now
+
+	report, err := deps.ArchiveManager.ArchiveSettled(ctx, core.DefaultArchivePolicy(), false)
+	if err != nil {
+		deps.Logger.LogError("archive", err, map[string]interface{}{
+			"context": "scheduled_archive",
+		})
+		return
+	}
+	if report.TotalNodes() == 0 {
+		return
+	}
+
+	deps.Logger.LogActivity("archive_completed", map[string]interface{}{
+		"segments": len(report.Segments),
+		"nodes":    report.TotalNodes(),
+		"edges":    report.Edges,
+	})
+}
+
+// checkForModelDrift compares recent model results against their baselines
+// every few hours. New drift is reported through the detector's notifier.
+func (s *Scheduler) checkForModelDrift(ctx context.Context, deps *SchedulerDependencies) {
+	if deps.DriftDetector == nil {
+		return
+	}
+
+	now := s.config.Clock.Now()
+	if now.Sub(s.lastDriftCheck) < driftCheckInterval {
+		return
+	}
+	s.lastDriftCheck = now
+
+	report, err := deps.DriftDetector.Check(ctx)
+	if err != nil {
+		deps.Logger.LogError("drift", err, map[string]interface{}{
+			"context": "scheduled_drift_check",
+		})
+		return
+	}
+	if len(report.Detected) == 0 && len(report.Recovered) == 0 {
+		return
+	}
+
+	deps.Logger.LogActivity("drift_checked", map[string]interface{}{
+		"checked":   report.Checked,
+		"detected":  len(report.Detected),
+		"recovered": len(report.Recovered),
+	})
+}
+
+// checkAndMinePreferences looks for edited outputs and mines queued ratings
+// and edits for candidate preferences, within the weekly mining budget.
+// Candidates are left pending for the user to confirm.
+func (s *Scheduler) checkAndMinePreferences(ctx context.Context, deps *SchedulerDependencies) {
+	if deps.PreferenceMiner == nil || s.config.DryRun {
+		return
+	}
+
+	now := s.config.Clock.Now()
+	if now.Sub(s.lastMining) < preferenceMiningInterval {
+		return
+	}
+	s.lastMining = now
+
+	if _, err := deps.PreferenceMiner.DetectEdits(ctx, deps.Config.Session.UserID); err != nil {
+		deps.Logger.LogError("preference_mining", err, map[string]interface{}{
+			"context": "detect_edits",
+		})
+		return
+	}
+	report, err := deps.PreferenceMiner.MinePending(ctx)
+	if err != nil {
+		deps.Logger.LogError("preference_mining", err, map[string]interface{}{
+			"context": "scheduled_mining",
+		})
+		return
+	}
+	if report.Mined == 0 && !report.BudgetExhausted {
+		return
+	}
+
+	deps.Logger.LogActivity("preferences_mined", map[string]interface{}{
+		"signals":          report.Mined,
+		"proposed":         report.Proposed,
+		"reinforced":       report.Reinforced,
+		"spent":            report.Spent,
+		"budget_exhausted": report.BudgetExhausted,
+	})
+}
+
+// checkAndConsolidateContext merges duplicate user context once a week.
+// Exact duplicates are merged; other merges wait for the user to confirm them.
+func (s *Scheduler) checkAndConsolidateContext(ctx context.Context, deps *SchedulerDependencies) {
+	if deps.ContextManager == nil || s.config.DryRun {
+		return
+	}
+
+	now := s.config.Clock.Now()
+	if now.Sub(s.lastConsolidation) < contextConsolidationInterval {
+		return
+	}
+	s.lastConsolidation = now
+
+	report, err := deps.ContextManager.ConsolidateContext(ctx, deps.Config.Session.UserID, core.DefaultContextConsolidationOptions())
+	if err != nil {
+		deps.Logger.LogError("context_consolidation", err, map[string]interface{}{
+			"context": "scheduled_consolidation",
+		})
+		return
+	}
+	if len(report.Merged) == 0 && len(report.Proposed) == 0 {
+		return
+	}
+
+	deps.Logger.LogActivity("context_consolidated", map[string]interface{}{
+		"merged":        len(report.Merged),
+		"proposed":      len(report.Proposed),
+		"saved_chars":   report.SavedChars,
+		"pending_chars": report.PendingChars,
+	})
+}
+
 // shouldExecuteObjective determines if an objective should be executed based on
 // ethical framework, user context, and system state.
 func (s *Scheduler) shouldExecuteObjective(ctx context.Context, objective *core.Objective, deps *SchedulerDependencies) bool {
@@ -225,7 +477,7 @@ func (s *Scheduler) startObjectiveExecution(ctx context.Context, objective *core
 	// Track the running objective
 	execContext := &ExecutionContext{
 		ObjectiveID: objective.ID,
-		StartTime:   time.Now(),
+		StartTime:   s.config.Clock.Now(),
 		Cancel:      cancel,
 		DryRun:      s.config.DryRun,
 	}
@@ -261,7 +513,7 @@ func (s *Scheduler) executeObjective(ctx context.Context, objective *core.Object
 		log.Printf("Execution #%d of objective %s completed", execNumber, objective.ID)
 	}()
 
-	startTime := time.Now()
+	startTime := s.config.Clock.Now()
 
 	// In dry-run mode, simulate execution
 	if s.config.DryRun {
@@ -278,7 +530,7 @@ func (s *Scheduler) executeObjective(ctx context.Context, objective *core.Object
 	}
 	result, err := deps.LearningLoop.ExecuteObjective(ctx, objective.ID)
 
-	executionTime := time.Since(startTime)
+	executionTime := s.config.Clock.Since(startTime)
 
 	if err != nil {
 		log.Printf("Execution #%d of objective %s failed: %v", execNumber, objective.ID, err)
@@ -307,10 +559,12 @@ func (s *Scheduler) simulateExecution(ctx context.Context, objective *core.Objec
 	log.Printf("DRY-RUN: Simulating execution #%d of objective %s", execNumber, objective.ID)
 
 	// Simulate some processing time
+	timer := s.config.Clock.NewTimer(time.Duration(2+execNumber%8) * time.Second)
+	defer timer.Stop()
 	select {
 	case <-ctx.Done():
 		return
-	case <-time.After(time.Duration(2+execNumber%8) * time.Second):
+	case <-timer.C():
 		// Simulate variable execution time
 	}
 
@@ -362,7 +616,7 @@ func (s *Scheduler) GetStatus() map[string]interface{} {
 			runningObjectives = append(runningObjectives, map[string]interface{}{
 				"objective_id": execContext.ObjectiveID,
 				"start_time":   execContext.StartTime,
-				"duration":     time.Since(execContext.StartTime).String(),
+				"duration":     s.config.Clock.Since(execContext.StartTime).String(),
 				"dry_run":      execContext.DryRun,
 			})
 		}
@@ -3,21 +3,35 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/internal/completion"
 	"github.com/Solifugus/ai-work-studio/internal/config"
+	"github.com/Solifugus/ai-work-studio/internal/selftest"
 	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
 )
 
 // createGoal creates a new goal with the given parameters.
 func (cli *CLI) createGoal(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("usage: create-goal <title> [description] [priority]")
+		return errs.New(errs.Validation, "usage: create-goal <title> [description] [priority]")
 	}
 
 	parsed := parseArgs(args, 3)
@@ -66,12 +80,61 @@ func (cli *CLI) createGoal(args []string) error {
 
 // createObjective creates a new objective for a goal.
 func (cli *CLI) createObjective(args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: create-objective <goal-id> <title> [description] [priority]")
+	usage := errs.New(errs.Validation, "usage: create-objective <goal-id> <title> [description] [priority] [--time-box duration] [--due date] [--recur rule] [--dry-run [--task-type type] [--quality level]]")
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		positional, args = append(positional, args[0]), args[1:]
+	}
+	if len(positional) < 2 {
+		return usage
+	}
+
+	flags := flag.NewFlagSet("create-objective", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Show the estimated cost without creating the objective")
+	taskType := flags.String("task-type", "analysis", "Task type the estimate assumes")
+	qualityName := flags.String("quality", llm.QualityStandard.String(), "Quality level the estimate assumes")
+	timeBox := flags.Duration("time-box", 0, "Pause the objective at a checkpoint after this much execution (e.g. 2h)")
+	due := flags.String("due", "", "Due date (YYYY-MM-DD or RFC 3339), which raises the objective in next suggestions as it nears")
+	recur := flags.String("recur", "", "Repeat after finishing (daily, weekly, monthly, or e.g. FREQ=WEEKLY;INTERVAL=2;TZID=Europe/Berlin)")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 {
+		return usage
+	}
+	if *timeBox < 0 {
+		return errs.Newf(errs.Validation, "time box cannot be negative, got %s", *timeBox)
+	}
+	schedule := core.ObjectiveUpdates{}
+	if *timeBox > 0 {
+		schedule.MaxExecutionTime = timeBox
+	}
+	location := time.Local
+	if *recur != "" {
+		recurrence, err := core.ParseRecurrence(*recur)
+		if err != nil {
+			return err
+		}
+		if location, err = recurrence.Location(); err != nil {
+			return err
+		}
+		schedule.Recurrence = recurrence
+	}
+	if *due != "" {
+		dueAt, ok := core.ParseDueDateIn(*due, location)
+		if !ok {
+			return errs.Newf(errs.Validation, "invalid due date %q (expected YYYY-MM-DD)", *due)
+		}
+		schedule.DueAt = &dueAt
+	} else if schedule.Recurrence != nil {
+		return errs.New(errs.Validation, "a recurring objective needs a due date (--due)")
 	}
 
-	parsed := parseArgs(args, 4)
-	goalID := parsed[0]
+	parsed := parseArgs(positional, 4)
+	goalID, err := cli.resolveID(completion.ArgGoal, parsed[0])
+	if err != nil {
+		return err
+	}
 	title := parsed[1]
 	description := parsed[2]
 	priority := parseInt(parsed[3], cli.config.Preferences.DefaultPriority)
@@ -88,6 +151,18 @@ func (cli *CLI) createObjective(args []string) error {
 		return fmt.Errorf("goal not found: %w", err)
 	}
 
+	if *dryRun {
+		quality, err := llm.ParseQualityRequirement(*qualityName)
+		if err != nil {
+			return err
+		}
+		return cli.previewObjectiveCost(llm.TaskRequest{
+			Prompt:          strings.TrimSpace(title + "\n\n" + description),
+			TaskType:        *taskType,
+			QualityRequired: quality,
+		})
+	}
+
 	// For now, use a placeholder method ID
 	// TODO: Integrate with method selection when method system is ready
 	methodID := "placeholder-method"
@@ -97,6 +172,12 @@ func (cli *CLI) createObjective(args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create objective: %w", err)
 	}
+	if schedule.MaxExecutionTime != nil || schedule.DueAt != nil {
+		objective, err = cli.objectiveManager.UpdateObjective(ctx, objective.ID, schedule)
+		if err != nil {
+			return fmt.Errorf("failed to schedule objective: %w", err)
+		}
+	}
 
 	if cli.config.Preferences.VerboseOutput {
 		fmt.Printf("✓ Created objective: %s\n", objective.ID)
@@ -106,6 +187,15 @@ func (cli *CLI) createObjective(args []string) error {
 		}
 		fmt.Printf("  Goal: %s (%s)\n", goal.Title, goalID)
 		fmt.Printf("  Priority: %d\n", objective.Priority)
+		if limit := cli.objectiveManager.TimeBoxes().For(objective); limit > 0 {
+			fmt.Printf("  Time box: %s\n", limit)
+		}
+		if objective.DueAt != nil {
+			fmt.Printf("  Due: %s\n", objective.DueAt.Local().Format("2006-01-02 15:04"))
+		}
+		if objective.Recurrence != nil {
+			fmt.Printf("  Recurs: %s\n", objective.Recurrence)
+		}
 		fmt.Printf("  Status: %s\n", objective.Status)
 		fmt.Printf("  Created: %s\n", formatTime(objective.CreatedAt))
 	} else {
@@ -115,19 +205,27 @@ func (cli *CLI) createObjective(args []string) error {
 	return nil
 }
 
-// listGoals lists all goals, optionally filtered by status.
+// listGoals lists all goals, optionally filtered by status. Archived goals
+// are listed with --all or when asked for by status.
 func (cli *CLI) listGoals(args []string) error {
 	var statusFilter *core.GoalStatus
 
-	if len(args) > 0 {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
 		status := core.GoalStatus(args[0])
 		statusFilter = &status
+		args = args[1:]
+	}
+
+	flags := flag.NewFlagSet("list-goals", flag.ContinueOnError)
+	all := flags.Bool("all", false, "Also list archived goals")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
 	}
 
 	ctx := context.Background()
 
 	// Build filter
-	filter := core.GoalFilter{}
+	filter := core.GoalFilter{IncludeArchived: *all}
 	if statusFilter != nil {
 		filter.Status = statusFilter
 	}
@@ -183,7 +281,11 @@ func (cli *CLI) listObjectives(args []string) error {
 	var statusFilter *core.ObjectiveStatus
 
 	if len(args) > 0 {
-		goalIDFilter = args[0]
+		goalID, err := cli.resolveID(completion.ArgGoal, args[0])
+		if err != nil {
+			return err
+		}
+		goalIDFilter = goalID
 	}
 	if len(args) > 1 {
 		status := core.ObjectiveStatus(args[1])
@@ -235,7 +337,7 @@ func (cli *CLI) listObjectives(args []string) error {
 			}
 			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
 				objective.ID[:8], objective.Title, objective.GoalID[:8],
-				objective.Status, objective.Priority, formatTime(objective.CreatedAt), description)
+				objectiveStatusLabel(objective), objective.Priority, formatTime(objective.CreatedAt), description)
 		}
 	} else {
 		fmt.Fprintln(w, "Title\tGoal ID\tStatus\tPriority\tCreated")
@@ -243,7 +345,7 @@ func (cli *CLI) listObjectives(args []string) error {
 
 		for _, objective := range objectives {
 			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
-				objective.Title, objective.GoalID[:8], objective.Status,
+				objective.Title, objective.GoalID[:8], objectiveStatusLabel(objective),
 				objective.Priority, formatTime(objective.CreatedAt))
 		}
 	}
@@ -251,383 +353,4047 @@ func (cli *CLI) listObjectives(args []string) error {
 	return nil
 }
 
-// showStatus displays current system status and progress.
-func (cli *CLI) showStatus(args []string) error {
-	ctx := context.Background()
-
-	fmt.Println("🎯 AI Work Studio Status")
-	fmt.Println()
+// objectiveStatusLabel shows an objective's status, noting who it was
+// delegated to and whether it is overdue.
+func objectiveStatusLabel(objective *core.Objective) string {
+	label := string(objective.Status)
+	if delegation := objective.Delegation(); delegation != nil {
+		label = fmt.Sprintf("%s (delegated to %s)", objective.Status, delegation.To)
+	}
+	if objective.IsOverdue(time.Now()) {
+		label += " ⚠️ overdue"
+	}
+	return label
+}
 
-	// Show current goal if set
-	if cli.config.Session.CurrentGoalID != "" {
-		goal, err := cli.goalManager.GetGoal(ctx, cli.config.Session.CurrentGoalID)
+// showGoalTree prints the goal hierarchy as an outline, with progress and
+// spend rolled up from each goal's sub-goals.
+func (cli *CLI) showGoalTree(args []string) error {
+	var rootID string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		goalID, err := cli.resolveID(completion.ArgGoal, args[0])
 		if err != nil {
-			fmt.Printf("⚠️  Current goal (%s) not found\n", cli.config.Session.CurrentGoalID)
-		} else {
-			fmt.Printf("📋 Current Goal: %s\n", goal.Title)
-			fmt.Printf("   Status: %s | Priority: %d\n", goal.Status, goal.Priority)
-			if goal.Description != "" {
-				fmt.Printf("   %s\n", goal.Description)
-			}
+			return err
 		}
-		fmt.Println()
+		rootID = goalID
+		args = args[1:]
 	}
 
-	// Show active goals summary
-	activeFilter := core.GoalFilter{Status: &[]core.GoalStatus{core.GoalStatusActive}[0]}
-	activeGoals, err := cli.goalManager.ListGoals(ctx, activeFilter)
-	if err != nil {
-		return fmt.Errorf("failed to get active goals: %w", err)
+	flags := flag.NewFlagSet("tree", flag.ContinueOnError)
+	depth := flags.Int("depth", 0, "Show at most this many levels of sub-goals (0 for all)")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if *depth < 0 {
+		return fmt.Errorf("--depth cannot be negative")
 	}
 
-	fmt.Printf("📊 Active Goals: %d\n", len(activeGoals))
-
-	// Show in-progress objectives summary
-	inProgressFilter := core.ObjectiveFilter{Status: &[]core.ObjectiveStatus{core.ObjectiveStatusInProgress}[0]}
-	inProgressObjectives, err := cli.objectiveManager.ListObjectives(ctx, inProgressFilter)
+	tree, err := cli.goalManager.GetGoalTreeWithRollups(context.Background(), rootID, core.GoalTreeOptions{MaxDepth: *depth})
 	if err != nil {
-		return fmt.Errorf("failed to get in-progress objectives: %w", err)
+		return fmt.Errorf("failed to build goal tree: %w", err)
+	}
+	if len(tree.Roots) == 0 {
+		fmt.Printf("No goals found. Use 'create-goal' to create your first goal.\n")
+		return nil
 	}
 
-	fmt.Printf("⚡ In Progress: %d objectives\n", len(inProgressObjectives))
+	for _, root := range tree.Roots {
+		cli.printGoalTreeNode(root, "", "")
+	}
+	if len(tree.Roots) > 1 {
+		fmt.Printf("\nTotal: %s\n", formatGoalProgress(tree.Total))
+	}
+	return nil
+}
 
-	// Show recent completions
-	completedFilter := core.ObjectiveFilter{Status: &[]core.ObjectiveStatus{core.ObjectiveStatusCompleted}[0]}
-	completedObjectives, err := cli.objectiveManager.ListObjectives(ctx, completedFilter)
-	if err != nil {
-		return fmt.Errorf("failed to get completed objectives: %w", err)
+// printGoalTreeNode prints a goal and, below it, its sub-goals with
+// box-drawing branches. Prefix is the indentation of the goal's own line and
+// childPrefix the indentation continuing below it.
+func (cli *CLI) printGoalTreeNode(node *core.GoalTreeNode, prefix, childPrefix string) {
+	label := node.Goal.Title
+	if cli.config.Preferences.VerboseOutput {
+		label = fmt.Sprintf("%s (%s)", label, node.Goal.ID[:8])
 	}
 
-	// Count recent completions (last 24 hours)
-	recentCompletions := 0
-	for _, obj := range completedObjectives {
-		if obj.CompletedAt != nil && time.Since(*obj.CompletedAt) < 24*time.Hour {
-			recentCompletions++
+	var notes []string
+	if node.Shared {
+		notes = append(notes, "shared")
+	}
+	if node.Cycle {
+		notes = append(notes, "cycle, not expanded")
+	}
+	if node.Truncated {
+		if node.Descendants == 1 {
+			notes = append(notes, "1 sub-goal hidden")
+		} else {
+			notes = append(notes, fmt.Sprintf("%d sub-goals hidden", node.Descendants))
 		}
 	}
+	if len(notes) > 0 {
+		label += " (" + strings.Join(notes, ", ") + ")"
+	}
 
-	fmt.Printf("✅ Recent Completions: %d (last 24h)\n", recentCompletions)
+	fmt.Printf("%s%s [%s]  %s\n", prefix, label, node.Goal.Status, formatGoalProgress(node.Rollup))
 
-	// Show budget status if configured
-	if cli.config.BudgetLimits.DailyLimit > 0 {
-		fmt.Println()
-		fmt.Printf("💰 Budget Limits:\n")
-		fmt.Printf("   Daily: $%.2f | Monthly: $%.2f | Per Request: $%.2f\n",
-			cli.config.BudgetLimits.DailyLimit, cli.config.BudgetLimits.MonthlyLimit,
-			cli.config.BudgetLimits.PerRequestLimit)
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			cli.printGoalTreeNode(child, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			cli.printGoalTreeNode(child, childPrefix+"├── ", childPrefix+"│   ")
+		}
 	}
+}
 
-	// Show data directory info
-	if cli.config.Preferences.VerboseOutput {
-		fmt.Println()
-		fmt.Printf("📁 Data Directory: %s\n", cli.config.DataDir)
-		fmt.Printf("👤 User ID: %s\n", cli.config.Session.UserID)
+// formatGoalProgress summarizes progress as "40% · 2/5 objectives (1 in progress) · $0.50",
+// where the percentage is weighted by objective priority.
+func formatGoalProgress(progress core.GoalProgress) string {
+	if progress.Objectives == 0 {
+		return "no objectives"
 	}
 
-	return nil
+	summary := fmt.Sprintf("%.0f%% · %d/%d objectives", progress.WeightedPercent(), progress.Completed(), progress.Objectives)
+	var details []string
+	if count := progress.InProgress(); count > 0 {
+		details = append(details, fmt.Sprintf("%d in progress", count))
+	}
+	if count := progress.Failed(); count > 0 {
+		details = append(details, fmt.Sprintf("%d failed", count))
+	}
+	if len(details) > 0 {
+		summary += " (" + strings.Join(details, ", ") + ")"
+	}
+	if progress.Spend > 0 {
+		summary += fmt.Sprintf(" · $%.2f", progress.Spend)
+	}
+	return summary
 }
 
-// provideFeedback handles user feedback on decisions or outcomes.
-func (cli *CLI) provideFeedback(args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: feedback <decision-id> <approve|reject> [message]")
+// manageMethods handles method listing, playbook generation, and sharing
+// method definitions.
+func (cli *CLI) manageMethods(args []string) error {
+	if len(args) == 0 {
+		return cli.listMethods()
 	}
 
-	decisionID := args[0]
-	action := strings.ToLower(args[1])
-	var message string
-	if len(args) > 2 {
-		message = strings.Join(args[2:], " ")
+	action := args[0]
+	switch action {
+	case "list":
+		return cli.listMethods()
+	case "playbook":
+		return cli.methodPlaybook(args[1:])
+	case "import":
+		return cli.importMethods(args[1:])
+	case "export":
+		return cli.exportMethods(args[1:])
+	case "starter":
+		return cli.installStarterMethods()
+	default:
+		return fmt.Errorf("unknown methods action: %s. Use 'list', 'playbook', 'import', 'export' or 'starter'", action)
 	}
+}
 
-	ctx := context.Background()
-
-	// Validate action
-	if action != "approve" && action != "reject" {
-		return fmt.Errorf("action must be 'approve' or 'reject', got '%s'", action)
+// importMethods imports a method definition file.
+func (cli *CLI) importMethods(args []string) error {
+	const usage = "usage: methods import <file> [--on-duplicate skip|replace|successor]"
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, usage)
 	}
+	path := args[0]
 
-	// Get the decision
-	decision, err := cli.ethicalFramework.GetDecision(ctx, decisionID)
+	flags := flag.NewFlagSet("methods import", flag.ContinueOnError)
+	onDuplicate := flags.String("on-duplicate", string(core.DuplicateSkip), "What to do with a method whose name is taken: skip, replace or successor")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	policy, err := core.ParseDuplicatePolicy(*onDuplicate)
 	if err != nil {
-		return fmt.Errorf("decision not found: %w", err)
+		return err
+	}
+	opts := core.ImportOptions{OnDuplicate: policy}
+	if format, err := core.ParseMethodFormat(strings.TrimPrefix(filepath.Ext(path), ".")); err == nil {
+		opts.Format = format
 	}
 
-	// Provide feedback
-	if action == "approve" {
-		if message == "" {
-			message = "Approved via CLI"
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open method file: %w", err)
+	}
+	defer file.Close()
+	results, err := cli.methodManager.ImportMethods(context.Background(), file, opts)
+	printMethodImport(results)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Action == core.MethodImportSkipped {
+			fmt.Println("Run with --on-duplicate replace or successor to take the file's version of skipped methods.")
+			break
 		}
-		err = cli.ethicalFramework.ApproveDecision(ctx, decisionID, message)
+	}
+	return nil
+}
+
+// printMethodImport lists what an import did with each method.
+func printMethodImport(results []core.MethodImportResult) {
+	if len(results) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tACTION\tVERSION\tID")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Name, result.Action, result.Version, result.MethodID)
+	}
+	w.Flush()
+}
+
+// exportMethods writes one method's definition, or every current method's,
+// to stdout or to the --out file.
+func (cli *CLI) exportMethods(args []string) error {
+	var methodIDs []string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		methodID, err := cli.resolveID(completion.ArgMethod, args[0])
 		if err != nil {
-			return fmt.Errorf("failed to approve decision: %w", err)
+			return err
 		}
-		fmt.Printf("✓ Approved decision: %s\n", decision.DecisionContext)
-	} else {
-		if message == "" {
-			message = "Rejected via CLI"
-		}
-		err = cli.ethicalFramework.RejectDecision(ctx, decisionID, message)
+		methodIDs, args = []string{methodID}, args[1:]
+	}
+
+	flags := flag.NewFlagSet("methods export", flag.ContinueOnError)
+	outPath := flags.String("out", "", "Write the definitions to this file instead of stdout")
+	formatName := flags.String("format", "", "json or yaml (default: from the --out file's extension, else yaml)")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 {
+		return errs.New(errs.Validation, "usage: methods export [method-id] [--out file] [--format json|yaml]")
+	}
+
+	format := core.MethodFormatYAML
+	switch {
+	case *formatName != "":
+		parsed, err := core.ParseMethodFormat(*formatName)
 		if err != nil {
-			return fmt.Errorf("failed to reject decision: %w", err)
+			return err
+		}
+		format = parsed
+	case *outPath != "":
+		if parsed, err := core.ParseMethodFormat(strings.TrimPrefix(filepath.Ext(*outPath), ".")); err == nil {
+			format = parsed
 		}
-		fmt.Printf("✗ Rejected decision: %s\n", decision.DecisionContext)
 	}
 
-	if cli.config.Preferences.VerboseOutput {
-		fmt.Printf("  Feedback: %s\n", message)
-		fmt.Printf("  Impact scores: Freedom=%.1f, Well-being=%.1f, Sustainability=%.1f\n",
-			decision.Impact.FreedomImpact, decision.Impact.WellBeingImpact, decision.Impact.SustainabilityImpact)
+	ctx := context.Background()
+	if *outPath == "" {
+		return cli.methodManager.ExportMethods(ctx, os.Stdout, methodIDs, format)
 	}
 
+	file, err := os.Create(*outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create method file: %w", err)
+	}
+	defer file.Close()
+	if err := cli.methodManager.ExportMethods(ctx, file, methodIDs, format); err != nil {
+		return fmt.Errorf("failed to export methods: %w", err)
+	}
+	fmt.Printf("✅ Methods written to %s\n", *outPath)
 	return nil
 }
 
-// manageConfig handles configuration management commands.
-func (cli *CLI) manageConfig(args []string) error {
+// installStarterMethods installs the starter method library.
+func (cli *CLI) installStarterMethods() error {
+	results, err := cli.methodManager.InstallStarterMethods(context.Background())
+	printMethodImport(results)
+	if err != nil {
+		return fmt.Errorf("failed to install the starter methods: %w", err)
+	}
+	return nil
+}
+
+// offerStarterMethods offers to install the starter method library when
+// there are no methods yet, as on a fresh installation, reading the answer
+// from input.
+func (cli *CLI) offerStarterMethods(input *bufio.Reader) {
+	methods, err := cli.methodManager.ListMethods(context.Background(), core.MethodFilter{})
+	if err != nil || len(methods) > 0 {
+		return
+	}
+	names, err := core.StarterMethods()
+	if err != nil {
+		return
+	}
+	fmt.Printf("No methods yet. Install the starter methods (%s)? [y/N]: ", strings.Join(names, ", "))
+	answer, err := input.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if err != nil || !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+		fmt.Println("Run 'methods starter' to install them later.")
+		fmt.Println()
+		return
+	}
+	if err := cli.installStarterMethods(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	fmt.Println()
+}
+
+// listMethods displays all methods with their version and success rate.
+func (cli *CLI) listMethods() error {
+	methods, err := cli.methodManager.ListMethods(context.Background(), core.MethodFilter{})
+	if err != nil {
+		return fmt.Errorf("failed to list methods: %w", err)
+	}
+
+	if len(methods) == 0 {
+		fmt.Println("No methods found. Methods are created as objectives are worked on,")
+		fmt.Println("or run 'methods starter' to install a starter set, or 'methods import <file>'.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tName\tVersion\tStatus\tRuns\tSuccess")
+	fmt.Fprintln(w, "---\t----\t-------\t------\t----\t-------")
+	for _, method := range methods {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%.0f%%\n",
+			method.ID, method.Name, method.Version, method.Status,
+			method.Metrics.ExecutionCount, method.Metrics.SuccessRate())
+	}
+
+	return nil
+}
+
+// methodPlaybook writes a method's playbook to stdout or to the --out file.
+func (cli *CLI) methodPlaybook(args []string) error {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, "usage: methods playbook <method-id> [--out file] [--no-history] [--no-stats] [--narrative]")
+	}
+	methodID, err := cli.resolveID(completion.ArgMethod, args[0])
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("methods playbook", flag.ContinueOnError)
+	outPath := flags.String("out", "", "Write the playbook to this file instead of stdout")
+	noHistory := flags.Bool("no-history", false, "Leave out the evolution lineage and recent executions")
+	noStats := flags.Bool("no-stats", false, "Leave out performance statistics")
+	narrative := flags.Bool("narrative", false, "Add an LLM-written narrative summary")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+
+	opts := core.DefaultPlaybookOptions()
+	opts.IncludeHistory = !*noHistory
+	opts.IncludeStats = !*noStats
+	opts.UseLLMNarrative = *narrative
+	opts.Router = cli.llmRouter
+
+	playbook, err := cli.methodManager.GeneratePlaybook(context.Background(), methodID, opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate playbook: %w", err)
+	}
+
+	if playbook.NarrativeError != nil {
+		fmt.Fprintf(os.Stderr, "Warning: narrative left out: %v\n", playbook.NarrativeError)
+	}
+
+	if *outPath == "" {
+		_, err := playbook.WriteTo(os.Stdout)
+		return err
+	}
+
+	file, err := os.Create(*outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create playbook file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := playbook.WriteTo(file); err != nil {
+		return fmt.Errorf("failed to write playbook: %w", err)
+	}
+
+	fmt.Printf("✅ Playbook written to %s\n", *outPath)
+	if playbook.Narrative != "" {
+		fmt.Printf("   Narrative cost: $%.4f\n", playbook.NarrativeCost)
+	}
+	return nil
+}
+
+// manageBackups handles backup subcommands; without one it takes a backup.
+func (cli *CLI) manageBackups(args []string) error {
+	backups, err := cli.backupManager()
+	if err != nil {
+		return err
+	}
+
 	if len(args) == 0 {
-		return cli.showConfig()
+		return cli.backupNow(backups)
 	}
 
 	action := args[0]
 	switch action {
-	case "get":
+	case "now":
+		return cli.backupNow(backups)
+	case "list":
+		return cli.listBackups(backups)
+	case "verify":
 		if len(args) < 2 {
-			return fmt.Errorf("usage: config get <key>")
+			return errs.New(errs.Validation, "usage: backup verify <backup-id>")
 		}
-		return cli.getConfigValue(args[1])
-	case "set":
-		if len(args) < 3 {
-			return fmt.Errorf("usage: config set <key> <value>")
-		}
-		return cli.setConfigValue(args[1], args[2])
+		return cli.verifyBackup(backups, args[1])
+	case "restore":
+		return cli.restoreBackup(backups, args[1:])
 	default:
-		return fmt.Errorf("unknown config action: %s. Use 'get' or 'set'", action)
+		return fmt.Errorf("unknown backup action: %s. Use 'now', 'list', 'verify' or 'restore'", action)
 	}
 }
 
-// showConfig displays current configuration.
-func (cli *CLI) showConfig() error {
-	fmt.Println("🔧 Configuration Settings")
-	fmt.Println()
+// replicationStatusMaxAge is how old the agent's replication status can be
+// before it is reported as possibly stale; the agent rewrites it every few seconds.
+const replicationStatusMaxAge = 30 * time.Second
 
-	fmt.Printf("Data Directory: %s\n", cli.config.DataDir)
-	fmt.Println()
+// replicationSummary describes replication health: on a replica, how far it
+// is behind its primary; on a primary, the replicas the agent is serving.
+// It returns "" when replication is not configured.
+func (cli *CLI) replicationSummary() string {
+	now := time.Now()
+	if cli.replica != nil {
+		return cli.replica.Status().Summary(now)
+	}
+	if cli.config.Replication.Listen == "" {
+		return ""
+	}
 
-	fmt.Printf("Budget Limits:\n")
-	fmt.Printf("  daily-limit: $%.2f\n", cli.config.BudgetLimits.DailyLimit)
-	fmt.Printf("  monthly-limit: $%.2f\n", cli.config.BudgetLimits.MonthlyLimit)
-	fmt.Printf("  per-request-limit: $%.2f\n", cli.config.BudgetLimits.PerRequestLimit)
-	fmt.Println()
+	status, err := storage.ReadReplicationStatus(config.ReplicationStatusFile(cli.config.DataDir))
+	switch {
+	case err != nil:
+		return err.Error()
+	case status == nil:
+		return fmt.Sprintf("configured on %s, but the agent is not serving replicas", cli.config.Replication.Listen)
+	case now.Sub(status.UpdatedAt) > replicationStatusMaxAge:
+		return fmt.Sprintf("%s (as of %s ago, the agent may have stopped)", status.Summary(), now.Sub(status.UpdatedAt).Round(time.Second))
+	}
+	return status.Summary()
+}
 
-	fmt.Printf("Preferences:\n")
-	fmt.Printf("  auto-approve: %t\n", cli.config.Preferences.AutoApprove)
-	fmt.Printf("  verbose-output: %t\n", cli.config.Preferences.VerboseOutput)
-	fmt.Printf("  default-priority: %d\n", cli.config.Preferences.DefaultPriority)
-	fmt.Printf("  interactive-mode: %t\n", cli.config.Preferences.InteractiveMode)
-	fmt.Println()
+// backupManager creates a backup manager for the configured backup directory.
+// Alerts are printed to stderr, since the CLI has no other channel.
+func (cli *CLI) backupManager() (*core.BackupManager, error) {
+	if cli.config.Storage.BackupDir == "" {
+		return nil, fmt.Errorf("no backup directory configured. Use 'config set backup-dir <path>'")
+	}
 
-	fmt.Printf("Session:\n")
-	fmt.Printf("  current-goal-id: %s\n", cli.config.Session.CurrentGoalID)
-	fmt.Printf("  user-id: %s\n", cli.config.Session.UserID)
+	return core.NewBackupManager(cli.store, core.BackupConfig{
+		TargetDir:  cli.config.Storage.BackupDir,
+		KeepDaily:  cli.config.Storage.BackupKeepDaily,
+		KeepWeekly: cli.config.Storage.BackupKeepWeekly,
+		Notifier: core.NotifierFunc(func(ctx context.Context, title, message string) error {
+			fmt.Fprintf(os.Stderr, "⚠️  %s: %s\n", title, message)
+			return nil
+		}),
+	}), nil
+}
+
+// backupNow takes an incremental backup and reports what was copied.
+func (cli *CLI) backupNow(backups *core.BackupManager) error {
+	manifest, err := backups.Backup(context.Background())
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
 
+	fmt.Printf("✅ Backup %s verified (%d files, %d copied, %d bytes written)\n",
+		manifest.ID, len(manifest.Files), manifest.FilesCopied, manifest.BytesCopied)
 	return nil
 }
 
-// getConfigValue retrieves and displays a specific configuration value.
-func (cli *CLI) getConfigValue(key string) error {
-	switch key {
-	case "data-dir":
-		fmt.Println(cli.config.DataDir)
-	case "daily-limit":
-		fmt.Printf("%.2f\n", cli.config.BudgetLimits.DailyLimit)
-	case "monthly-limit":
-		fmt.Printf("%.2f\n", cli.config.BudgetLimits.MonthlyLimit)
-	case "per-request-limit":
-		fmt.Printf("%.2f\n", cli.config.BudgetLimits.PerRequestLimit)
-	case "auto-approve":
-		fmt.Printf("%t\n", cli.config.Preferences.AutoApprove)
-	case "verbose-output":
+// listBackups displays the backups in the backup directory, newest first.
+func (cli *CLI) listBackups(backups *core.BackupManager) error {
+	manifests, err := backups.List()
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	if len(manifests) == 0 {
+		fmt.Printf("No backups found in %s\n", backups.TargetDir())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tCreated\tFiles\tSize\tCopied")
+	fmt.Fprintln(w, "---\t-------\t-----\t----\t------")
+	for _, manifest := range manifests {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n",
+			manifest.ID, formatTime(manifest.CreatedAt), len(manifest.Files),
+			manifest.TotalSize(), manifest.FilesCopied)
+	}
+
+	return nil
+}
+
+// verifyBackup re-checks a backup against its recorded checksums.
+func (cli *CLI) verifyBackup(backups *core.BackupManager, backupID string) error {
+	verification, err := backups.Verify(context.Background(), backupID)
+	if err != nil {
+		return fmt.Errorf("failed to verify backup: %w", err)
+	}
+
+	if !verification.OK() {
+		for _, problem := range verification.Problems {
+			fmt.Printf("❌ %s\n", problem)
+		}
+		return fmt.Errorf("backup %s failed verification: %d of %d files damaged",
+			backupID, len(verification.Problems), verification.FilesChecked)
+	}
+
+	fmt.Printf("✅ Backup %s verified (%d files)\n", backupID, verification.FilesChecked)
+	return nil
+}
+
+// restoreBackup reconstructs a backup into a data directory.
+func (cli *CLI) restoreBackup(backups *core.BackupManager, args []string) error {
+	flags := flag.NewFlagSet("backup restore", flag.ContinueOnError)
+	force := flags.Bool("force", false, "Overwrite a non-empty target directory")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() != 2 {
+		return errs.New(errs.Validation, "usage: backup restore <backup-id> <dir> [--force]")
+	}
+	backupID, targetDir := flags.Arg(0), flags.Arg(1)
+
+	if err := backups.Restore(context.Background(), backupID, targetDir, *force); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	fmt.Printf("✅ Backup %s restored to %s\n", backupID, targetDir)
+	fmt.Printf("   Use it with: AI_WORK_STUDIO_DATA_DIR=%s\n", targetDir)
+	return nil
+}
+
+// showStatus displays current system status and progress.
+func (cli *CLI) showStatus(args []string) error {
+	ctx := context.Background()
+
+	fmt.Println("🎯 AI Work Studio Status")
+	fmt.Println()
+
+	// Show current goal if set
+	if cli.config.Session.CurrentGoalID != "" {
+		goal, err := cli.goalManager.GetGoal(ctx, cli.config.Session.CurrentGoalID)
+		if err != nil {
+			fmt.Printf("⚠️  Current goal (%s) not found\n", cli.config.Session.CurrentGoalID)
+		} else {
+			fmt.Printf("📋 Current Goal: %s\n", goal.Title)
+			fmt.Printf("   Status: %s | Priority: %d\n", goal.Status, goal.Priority)
+			if goal.Description != "" {
+				fmt.Printf("   %s\n", goal.Description)
+			}
+			if progress, err := cli.goalManager.GetGoalProgress(ctx, goal.ID); err == nil {
+				fmt.Printf("   Progress: %s\n", formatGoalProgress(*progress))
+				if !progress.LastActivity.IsZero() {
+					fmt.Printf("   Last Activity: %s\n", progress.LastActivity.Local().Format("2006-01-02 15:04"))
+				}
+			}
+		}
+		fmt.Println()
+	}
+
+	// Show active goals summary
+	goalCounts, err := cli.rollupManager.GoalStatusCounts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get active goals: %w", err)
+	}
+
+	fmt.Printf("📊 Active Goals: %d\n", goalCounts[core.GoalStatusActive])
+
+	// Show in-progress objectives summary
+	objectiveCounts, err := cli.rollupManager.ObjectiveStatusCounts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get in-progress objectives: %w", err)
+	}
+
+	fmt.Printf("⚡ In Progress: %d objectives\n", objectiveCounts[core.ObjectiveStatusInProgress])
+	if err := cli.showWIPStatus(ctx); err != nil {
+		return err
+	}
+	if stopped, err := cli.objectiveManager.TimeBoxedObjectives(ctx); err == nil && len(stopped) > 0 {
+		fmt.Printf("⏱️  Time box exceeded: %d objectives waiting (see time-box list)\n", len(stopped))
+	}
+
+	// Show what the unattended work loop is doing
+	if agent, err := core.LatestWorkLoopStatus(cli.config.DataDir); err == nil && agent != nil {
+		fmt.Printf("🤖 Agent: %s\n", agent.Summary(time.Now()))
+	}
+
+	// Show what to work on next
+	if next, err := cli.objectiveManager.GetNextActionable(ctx); err != nil {
+		fmt.Printf("⚠️  Next objective unavailable: %v\n", err)
+	} else if next != nil {
+		fmt.Printf("👉 Next: %s (%s) — %s\n", next.Objective.Title, next.Objective.ID[:8], next.Explain())
+	}
+
+	// Show today's completions
+	recentCompletions, err := cli.rollupManager.CompletionsOnDay(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get completed objectives: %w", err)
+	}
+
+	fmt.Printf("✅ Completed Today: %d\n", recentCompletions)
+
+	// Show decisions waiting for feedback
+	pendingDecisions, err := cli.rollupManager.PendingDecisionCount(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get pending decisions: %w", err)
+	}
+	if pendingDecisions > 0 {
+		fmt.Printf("🤔 Pending Decisions: %d\n", pendingDecisions)
+	}
+
+	// Warn when the ethical framework keeps failing to evaluate decisions
+	if failures, err := cli.rollupManager.EvaluationFailuresOnDay(ctx, time.Now()); err == nil {
+		if warning := core.EvaluationFailureWarning(failures); warning != "" {
+			fmt.Printf("⚠️  %s\n", warning)
+		}
+	}
+
+	// Point to the latest decision audit
+	if latest, err := cli.ethicalFramework.LatestAuditExport(ctx); err == nil && latest != nil {
+		fmt.Printf("🧾 Latest Audit: %s (%s to %s)\n", latest.Path,
+			latest.From.Format("2006-01-02"), latest.To.Add(-time.Nanosecond).Format("2006-01-02"))
+	}
+
+	// Show budget status if configured
+	if cli.config.BudgetLimits.DailyLimit > 0 {
+		fmt.Println()
+		_ = cli.showBudget()
+	}
+
+	// Show what the completion cache saved
+	if cli.config.CompletionCache.Enabled {
+		if cache, err := mcp.NewCompletionCache(cli.config.CompletionCache.CacheConfig(cli.config.DataDir)); err == nil {
+			stats := cache.Stats()
+			fmt.Printf("🗃️  Completion Cache: %d hits of %d requests (%.0f%%), $%.2f saved\n",
+				stats.Hits, stats.Hits+stats.Misses, stats.HitRate*100, stats.CostSaved)
+		}
+	}
+
+	// Show which goals spent the most this month
+	if cli.config.BudgetLimits.TrackingEnabled {
+		cli.showGoalSpending(ctx, 5)
+	}
+
+	// Show backup health if backups are configured
+	if backups, err := cli.backupManager(); err == nil {
+		if health, err := backups.Health(ctx); err == nil {
+			fmt.Println()
+			fmt.Printf("💾 Backups: %s\n", health.Summary(time.Now()))
+		}
+	}
+
+	// Show replication health, from whichever side this machine is on
+	if summary := cli.replicationSummary(); summary != "" {
+		fmt.Println()
+		fmt.Printf("🔁 Replication: %s\n", summary)
+	}
+
+	// Show what the operating profile turns down
+	if profile := cli.config.Profile; profile.Name != config.ProfileStandard {
+		fmt.Println()
+		fmt.Printf("🪫 Profile: %s\n", profile.Name)
+		for _, feature := range profile.Features() {
+			if feature.Degraded != "" {
+				fmt.Printf("   %s: %s\n", feature.Name, feature.Degraded)
+			}
+		}
+	}
+
+	// Show data directory info
+	if cli.config.Preferences.VerboseOutput {
+		fmt.Println()
+		fmt.Printf("📁 Data Directory: %s\n", cli.config.DataDir)
+		fmt.Printf("👤 User ID: %s\n", cli.config.Session.UserID)
+	}
+
+	return nil
+}
+
+// provideFeedback handles user feedback on decisions or outcomes.
+// With --always, an approval is also generalized into a standing approval rule.
+func (cli *CLI) provideFeedback(args []string) error {
+	always := false
+	expiresDays := 0
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--always":
+			always = true
+		case "--expires-days":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--expires-days needs a number of days")
+			}
+			i++
+			days, err := strconv.Atoi(args[i])
+			if err != nil || days <= 0 {
+				return fmt.Errorf("invalid --expires-days value: %s", args[i])
+			}
+			expiresDays = days
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	args = positional
+
+	if len(args) < 2 {
+		return errs.New(errs.Validation, "usage: feedback <decision-id> <approve|reject> [message] [--always [--expires-days N]]")
+	}
+
+	decisionID, err := cli.resolveID(completion.ArgDecision, args[0])
+	if err != nil {
+		return err
+	}
+	action := strings.ToLower(args[1])
+	var message string
+	if len(args) > 2 {
+		message = strings.Join(args[2:], " ")
+	}
+
+	ctx := context.Background()
+
+	// Validate action
+	if action != "approve" && action != "reject" {
+		return fmt.Errorf("action must be 'approve' or 'reject', got '%s'", action)
+	}
+	if always && action != "approve" {
+		return fmt.Errorf("--always can only be used when approving")
+	}
+
+	// Get the decision
+	decision, err := cli.ethicalFramework.GetDecision(ctx, decisionID)
+	if err != nil {
+		return fmt.Errorf("decision not found: %w", err)
+	}
+
+	// Provide feedback
+	if action == "approve" {
+		if message == "" {
+			message = "Approved via CLI"
+		}
+		err = cli.ethicalFramework.ApproveDecision(ctx, decisionID, message)
+		if err != nil {
+			return fmt.Errorf("failed to approve decision: %w", err)
+		}
+		fmt.Printf("✓ Approved decision: %s\n", decision.DecisionContext)
+		cli.showPolicyProposal(ctx, decisionID)
+	} else {
+		if message == "" {
+			message = "Rejected via CLI"
+		}
+		err = cli.ethicalFramework.RejectDecision(ctx, decisionID, message)
+		if err != nil {
+			return fmt.Errorf("failed to reject decision: %w", err)
+		}
+		fmt.Printf("✗ Rejected decision: %s\n", decision.DecisionContext)
+	}
+
+	if cli.config.Preferences.VerboseOutput {
+		fmt.Printf("  Feedback: %s\n", message)
+		fmt.Printf("  Impact scores: Freedom=%.1f, Well-being=%.1f, Sustainability=%.1f\n",
+			decision.Impact.FreedomImpact, decision.Impact.WellBeingImpact, decision.Impact.SustainabilityImpact)
+	}
+
+	if always {
+		return cli.createApprovalRule(ctx, decision, expiresDays)
+	}
+	return nil
+}
+
+// showPolicyProposal tells the user when approving a decision led to a
+// policy rule being proposed.
+func (cli *CLI) showPolicyProposal(ctx context.Context, decisionID string) {
+	rules, err := cli.ethicalFramework.ListRules(ctx)
+	if err != nil {
+		return
+	}
+	for _, rule := range rules {
+		if rule.Status != core.PolicyRuleProposed {
+			continue
+		}
+		for _, learnedFrom := range rule.LearnedFrom {
+			if learnedFrom == decisionID {
+				fmt.Printf("💡 You approved this action %d times. Proposed policy rule %s: %s\n", len(rule.LearnedFrom), rule.ID, rule)
+				fmt.Printf("   'policy accept %s' to approve it without asking from now on\n", rule.ID)
+			}
+		}
+	}
+}
+
+// createApprovalRule generalizes an approved decision into a rule, shows it
+// for editing, and stores it once confirmed.
+func (cli *CLI) createApprovalRule(ctx context.Context, decision *core.EthicalDecision, expiresDays int) error {
+	rules := cli.ethicalFramework.Rules()
+	rule, err := rules.DeriveRule(decision)
+	if err != nil {
+		return fmt.Errorf("cannot create an approval rule: %w", err)
+	}
+	if expiresDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, expiresDays)
+		rule.ExpiresAt = &expiresAt
+	}
+
+	fmt.Println()
+	fmt.Printf("📏 Proposed rule: %s\n", rule)
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("   Edit %s (Enter keeps %q): ", strings.ReplaceAll(string(rule.ScopeKind), "_", " "), rule.Scope)
+	line, _, err := reader.ReadLine()
+	if err != nil {
+		return fmt.Errorf("failed to read rule scope: %w", err)
+	}
+	if scope := strings.TrimSpace(string(line)); scope != "" {
+		rule.Scope = scope
+		fmt.Printf("   Rule is now: %s\n", rule)
+	}
+
+	confirmed, err := readConfirmation(reader, "   Create this rule? [y/N] ")
+	if err != nil {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	if !confirmed {
+		fmt.Println("No rule created.")
+		return nil
+	}
+
+	rule, err = rules.CreateRule(ctx, rule)
+	if err != nil {
+		return fmt.Errorf("failed to create approval rule: %w", err)
+	}
+	fmt.Printf("✓ Created rule %s: %s\n", rule.ID, rule)
+	return nil
+}
+
+// manageRules lists, revokes and resumes approval rules.
+func (cli *CLI) manageRules(args []string) error {
+	rules := cli.ethicalFramework.Rules()
+	ctx := context.Background()
+
+	if len(args) == 0 || args[0] == "list" {
+		return cli.listRules(ctx, rules)
+	}
+
+	action := args[0]
+	if len(args) < 2 {
+		return errs.Newf(errs.Validation, "usage: rules %s <rule-id>", action)
+	}
+	ruleID, err := cli.resolveID(completion.ArgRule, args[1])
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "revoke":
+		if err := rules.RevokeRule(ctx, ruleID); err != nil {
+			return fmt.Errorf("failed to revoke rule: %w", err)
+		}
+		fmt.Printf("✓ Revoked rule %s\n", ruleID)
+	case "resume":
+		if err := rules.ResumeRule(ctx, ruleID); err != nil {
+			return fmt.Errorf("failed to resume rule: %w", err)
+		}
+		fmt.Printf("✓ Resumed rule %s\n", ruleID)
+	default:
+		return fmt.Errorf("unknown rules action: %s. Use 'list', 'revoke' or 'resume'", action)
+	}
+	return nil
+}
+
+// listRules displays all approval rules with their state and usage.
+func (cli *CLI) listRules(ctx context.Context, rules *core.ApprovalRuleManager) error {
+	list, err := rules.ListRules(ctx)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println("No approval rules. Create one with: feedback <decision-id> approve --always")
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tState\tUses\tRule")
+	fmt.Fprintln(w, "---\t-----\t----\t----")
+	for _, rule := range list {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", rule.ID, rule.State(now), rule.UsageCount, rule)
+	}
+	w.Flush()
+
+	for _, rule := range list {
+		if rule.Status == core.ApprovalRuleSuspended {
+			fmt.Printf("\n⚠️  Rule %s is suspended: %s\n", rule.ID, rule.SuspendedReason)
+			fmt.Printf("   Review it, then 'rules resume %s' or 'rules revoke %s'\n", rule.ID, rule.ID)
+		}
+	}
+	return nil
+}
+
+// managePolicy lists, adds, accepts and removes policy rules.
+func (cli *CLI) managePolicy(args []string) error {
+	ctx := context.Background()
+	if len(args) == 0 || args[0] == "list" {
+		return cli.listPolicyRules(ctx)
+	}
+
+	action := args[0]
+	if action == "add" {
+		return cli.addPolicyRule(ctx, args[1:])
+	}
+	if len(args) < 2 {
+		return errs.Newf(errs.Validation, "usage: policy %s <rule-id>", action)
+	}
+	ruleID, err := cli.resolveID(completion.ArgPolicyRule, args[1])
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "accept":
+		if err := cli.ethicalFramework.AcceptRule(ctx, ruleID); err != nil {
+			return fmt.Errorf("failed to accept policy rule: %w", err)
+		}
+		fmt.Printf("✓ Accepted policy rule %s\n", ruleID)
+	case "remove":
+		if err := cli.ethicalFramework.RemoveRule(ctx, ruleID); err != nil {
+			return fmt.Errorf("failed to remove policy rule: %w", err)
+		}
+		fmt.Printf("✓ Removed policy rule %s\n", ruleID)
+	default:
+		return fmt.Errorf("unknown policy action: %s. Use 'list', 'add', 'accept' or 'remove'", action)
+	}
+	return nil
+}
+
+// addPolicyRule adds a policy rule from an effect and condition flags.
+func (cli *CLI) addPolicyRule(ctx context.Context, args []string) error {
+	usage := errs.New(errs.Validation, "usage: policy add <approve|reject|escalate> [--action text] [--keyword k1,k2] [--context k1,k2] [--verb v] [--service s] [--path prefix] [--host h] [--objective id]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	flags := flag.NewFlagSet("policy add", flag.ContinueOnError)
+	action := flags.String("action", "", "Match this exact action, ignoring case and spacing")
+	keywords := flags.String("keyword", "", "Comma-separated words the action must mention")
+	contextKeywords := flags.String("context", "", "Comma-separated words the decision context must mention")
+	verb := flags.String("verb", "", "Match actions with this leading verb")
+	service := flags.String("service", "", "Match actions on filesystem, web or general targets")
+	pathPrefix := flags.String("path", "", "Match actions on paths at or below this directory")
+	host := flags.String("host", "", "Match actions against this host")
+	objective := flags.String("objective", "", "Match decisions of this objective")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 {
+		return usage
+	}
+
+	rule := &core.PolicyRule{
+		Effect: core.PolicyEffect(strings.ToLower(args[0])),
+		Condition: core.PolicyCondition{
+			Action:          *action,
+			ActionKeywords:  strings.Split(*keywords, ","),
+			ContextKeywords: strings.Split(*contextKeywords, ","),
+			Verb:            *verb,
+			Service:         *service,
+			PathPrefix:      *pathPrefix,
+			Host:            *host,
+		},
+		UserID: cli.config.Session.UserID,
+	}
+	if *objective != "" {
+		objectiveID, err := cli.resolveID(completion.ArgObjective, *objective)
+		if err != nil {
+			return err
+		}
+		rule.Condition.ObjectiveID = objectiveID
+	}
+
+	rule, err := cli.ethicalFramework.AddRule(ctx, rule)
+	if err != nil {
+		return fmt.Errorf("failed to add policy rule: %w", err)
+	}
+	fmt.Printf("✓ Added policy rule %s: %s\n", rule.ID, rule)
+	return nil
+}
+
+// listPolicyRules displays the active and proposed policy rules with their usage.
+func (cli *CLI) listPolicyRules(ctx context.Context) error {
+	rules, err := cli.ethicalFramework.ListRules(ctx)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		fmt.Println("No policy rules. Add one with: policy add <approve|reject|escalate> [conditions]")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tStatus\tUses\tRule")
+	fmt.Fprintln(w, "---\t------\t----\t----")
+	for _, rule := range rules {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", rule.ID, rule.Status, rule.UsageCount, rule)
+	}
+	w.Flush()
+
+	for _, rule := range rules {
+		if rule.Status == core.PolicyRuleProposed {
+			fmt.Printf("\n💡 Rule %s was learned from %d approvals of the same action.\n", rule.ID, len(rule.LearnedFrom))
+			fmt.Printf("   'policy accept %s' to approve it without asking, or 'policy remove %s'\n", rule.ID, rule.ID)
+		}
+	}
+	return nil
+}
+
+// rateObjective records a rating of a finished objective's output, for
+// preference mining. Routing IDs are rated with rateRouting.
+func (cli *CLI) rateObjective(args []string) error {
+	if len(args) < 2 {
+		return errs.New(errs.Validation, "usage: rate <objective-id|routing-id> <1-10> [comment]")
+	}
+	if strings.HasPrefix(args[0], llm.RoutingIDPrefix) {
+		return cli.rateRouting(args)
+	}
+
+	objectiveID, err := cli.resolveID(completion.ArgObjective, args[0])
+	if err != nil {
+		return err
+	}
+	rating, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid rating: %s", args[1])
+	}
+	comment := strings.Join(args[2:], " ")
+
+	miningConfig := core.DefaultPreferenceMiningConfig()
+	miningConfig.Router = cli.llmRouter
+	miner := core.NewPreferenceMiner(cli.store, cli.contextManager, miningConfig)
+	if _, err := miner.RecordRating(context.Background(), objectiveID, rating, comment, cli.config.Session.UserID); err != nil {
+		return fmt.Errorf("failed to record rating: %w", err)
+	}
+
+	fmt.Printf("✓ Rated objective %s %d/10\n", objectiveID, rating)
+	if !cli.config.Preferences.MinePreferences {
+		fmt.Println("   Preference mining is off; set mine_preferences in [preferences] to learn from ratings")
+	}
+	return nil
+}
+
+// rateRouting records feedback on an LLM routing, which routing learns the
+// model's quality from. The completion's budget transaction, when the router
+// recorded one, gets the rating as its quality.
+func (cli *CLI) rateRouting(args []string) error {
+	rating, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return errs.Newf(errs.Validation, "invalid rating: %s", args[1])
+	}
+
+	ctx := context.Background()
+	record, err := cli.llmRouter.SubmitFeedback(ctx, args[0], rating, strings.Join(args[2:], " "))
+	if err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+	if record.TransactionID != "" {
+		if budget, err := cli.budgetManager(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		} else if err := budget.RecordQuality(ctx, record.TransactionID, rating); err != nil {
+			fmt.Printf("Warning: failed to record the completion's quality: %v\n", err)
+		}
+	}
+
+	fmt.Printf("✓ Rated routing %s (%s/%s) %g/10\n", record.ID, record.Provider, record.Model, rating)
+	return nil
+}
+
+// managePreferences lists the preferences waiting for confirmation,
+// confirms or dismisses one, or consolidates duplicate context.
+func (cli *CLI) managePreferences(args []string) error {
+	ctx := context.Background()
+	if len(args) == 0 || args[0] == "list" {
+		return cli.listPendingPreferences(ctx)
+	}
+	if args[0] == "consolidate" {
+		return cli.consolidateContext(ctx, args[1:])
+	}
+
+	action := args[0]
+	if len(args) < 2 {
+		return errs.Newf(errs.Validation, "usage: preferences %s <preference-id>", action)
+	}
+	contextID, err := cli.resolveID(completion.ArgPreference, args[1])
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "confirm":
+		confirmed, err := cli.contextManager.ConfirmContext(ctx, contextID)
+		if err != nil {
+			return fmt.Errorf("failed to confirm preference: %w", err)
+		}
+		fmt.Printf("✓ Confirmed: %s\n", confirmed.Content)
+	case "dismiss":
+		dismissed, err := cli.contextManager.DismissContext(ctx, contextID)
+		if err != nil {
+			return fmt.Errorf("failed to dismiss preference: %w", err)
+		}
+		fmt.Printf("✓ Dismissed: %s\n", dismissed.Content)
+	default:
+		return fmt.Errorf("unknown preferences action: %s. Use 'list', 'confirm', 'dismiss' or 'consolidate'", action)
+	}
+	return nil
+}
+
+// listPendingPreferences displays the preferences learned from ratings and
+// edits that are waiting for confirmation.
+func (cli *CLI) listPendingPreferences(ctx context.Context) error {
+	pending, err := cli.contextManager.GetPendingContext(ctx, cli.config.Session.UserID)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Println("No preferences waiting for confirmation.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tConfidence\tSeen\tPreference")
+	fmt.Fprintln(w, "---\t----------\t----\t----------")
+	for _, preference := range pending {
+		content := preference.Content
+		if len(preference.MergedFrom) > 0 {
+			content = fmt.Sprintf("%s (merges %d %s entries)", content, len(preference.MergedFrom), preference.Category)
+		}
+		fmt.Fprintf(w, "%s\t%.0f%%\t%d\t%s\n", preference.ID, preference.Confidence*100, preference.Reinforcements+1, content)
+	}
+	w.Flush()
+
+	fmt.Println("\nUnconfirmed preferences are never used. Run 'preferences confirm <id>' or 'preferences dismiss <id>'.")
+	return nil
+}
+
+// consolidateContext merges duplicate user context: exact duplicates
+// straight away, and similar entries once the merge is confirmed.
+func (cli *CLI) consolidateContext(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("preferences consolidate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Show the merges without making them")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+
+	opts := core.DefaultContextConsolidationOptions()
+	opts.DryRun = *dryRun
+	report, err := cli.contextManager.ConsolidateContext(ctx, cli.config.Session.UserID, opts)
+	if err != nil {
+		return fmt.Errorf("failed to consolidate context: %w", err)
+	}
+
+	fmt.Printf("🧹 %s\n", report)
+	for _, merge := range report.Merged {
+		fmt.Printf("  merged %d into: %s\n", len(merge.MergedFrom), merge.Statement)
+	}
+	for _, merge := range report.Proposed {
+		fmt.Printf("  merge %d: %s\n", len(merge.MergedFrom), merge.Statement)
+	}
+	if len(report.Proposed) > 0 && !report.DryRun {
+		fmt.Println("\nMerges take effect once confirmed. Run 'preferences' to review them.")
+	}
+	return nil
+}
+
+// manageContext lists or exports what has been learned about the user, or
+// forgets an entry that was learned wrongly.
+func (cli *CLI) manageContext(args []string) error {
+	ctx := context.Background()
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "list", "export":
+		return cli.reviewContext(ctx, action, args)
+	case "forget":
+		if len(args) < 1 {
+			return errs.New(errs.Validation, "usage: context forget <context-id> [reason]")
+		}
+		contextID, err := cli.resolveID(completion.ArgContext, args[0])
+		if err != nil {
+			return err
+		}
+		forgotten, err := cli.contextManager.ForgetContext(ctx, contextID, strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Forgotten: %s\n", forgotten.Content)
+		fmt.Println("  It will no longer be used for planning or decisions.")
+		return nil
+	default:
+		return fmt.Errorf("unknown context action: %s. Use 'list', 'export' or 'forget'", action)
+	}
+}
+
+// reviewContext lists the user's context as a table, or exports it as JSON.
+func (cli *CLI) reviewContext(ctx context.Context, action string, args []string) error {
+	flags := flag.NewFlagSet("context "+action, flag.ContinueOnError)
+	category := flags.String("category", "", "Only entries in this category")
+	source := flags.String("source", "", "Only entries learned this way: explicit, inferred or feedback")
+	tag := flags.String("tag", "", "Only entries with this tag")
+	all := flags.Bool("all", false, "Include forgotten entries")
+	outPath := flags.String("out", "", "Write the export to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+
+	filter := core.ContextFilter{
+		Category:       core.ContextCategory(*category),
+		Source:         core.ContextSource(*source),
+		Tag:            *tag,
+		IncludeRevoked: *all,
+	}
+	userID := cli.config.Session.UserID
+
+	if action == "export" {
+		data, err := cli.contextManager.ExportContexts(ctx, userID, filter)
+		if err != nil {
+			return err
+		}
+		if *outPath == "" {
+			_, err := os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(*outPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to write context export: %w", err)
+		}
+		fmt.Printf("✓ Exported context to %s\n", *outPath)
+		return nil
+	}
+
+	contexts, err := cli.contextManager.ListContexts(ctx, userID, filter)
+	if err != nil {
+		return err
+	}
+	if len(contexts) == 0 {
+		fmt.Println("No learned context found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCategory\tSource\tStatus\tConfidence\tContext")
+	fmt.Fprintln(w, "---\t--------\t------\t------\t----------\t-------")
+	for _, entry := range contexts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.0f%%\t%s\n", entry.ID, entry.Category, entry.Source, entry.Status, entry.Confidence*100, entry.Content)
+	}
+	w.Flush()
+
+	fmt.Println("\nRun 'context forget <id> [reason]' to stop an entry from being used.")
+	return nil
+}
+
+// manageDecisions lists the decisions still open, aborts one whose
+// implementation went wrong, or exports an audit of past decisions.
+func (cli *CLI) manageDecisions(args []string) error {
+	ctx := context.Background()
+	if len(args) == 0 {
+		return cli.listOpenDecisions(ctx)
+	}
+	if args[0] == "audit" {
+		return cli.auditDecisions(ctx, args[1:])
+	}
+	if args[0] != "abort" {
+		return fmt.Errorf("unknown decisions action: %s. Use 'abort' or 'audit'", args[0])
+	}
+	if len(args) < 3 {
+		return errs.New(errs.Validation, "usage: decisions abort <decision-id> <reason>")
+	}
+
+	decisionID, err := cli.resolveID(completion.ArgImplementedDecision, args[1])
+	if err != nil {
+		return err
+	}
+	decision, err := cli.ethicalFramework.AbortImplementation(ctx, decisionID, strings.Join(args[2:], " "))
+	if err != nil {
+		return fmt.Errorf("failed to abort decision: %w", err)
+	}
+	fmt.Printf("✗ Aborted decision: %s\n", decision.ProposedAction)
+	fmt.Println("  Recorded a negative outcome and a constraint for future decisions.")
+
+	plan := decision.RemediationPlan
+	if plan == nil {
+		return nil
+	}
+	fmt.Printf("\n🩹 Remediation plan: %s\n", plan.Description)
+	if plan.ObjectiveID == "" {
+		confirmed, err := readConfirmation(bufio.NewReader(os.Stdin), "   Create an objective for it? [y/N] ")
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if !confirmed {
+			fmt.Println("No remediation objective created.")
+			return nil
+		}
+	}
+	objective, err := cli.ethicalFramework.CreateRemediationObjective(ctx, decision.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create remediation objective: %w", err)
+	}
+	fmt.Printf("✓ Remediation objective: %s (%s)\n", objective.Title, objective.ID)
+	return nil
+}
+
+// auditDecisions writes an audit report of the decisions made in a period to
+// stdout or to the --out file. The format follows the file extension unless
+// --format is given.
+func (cli *CLI) auditDecisions(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("decisions audit", flag.ContinueOnError)
+	fromDate := flags.String("from", "", "First day of the period, YYYY-MM-DD (default: 7 days ago)")
+	toDate := flags.String("to", "", "Last day of the period, YYYY-MM-DD (default: today)")
+	minUrgency := flags.String("min-urgency", "low", "Leave out decisions below this urgency")
+	formatName := flags.String("format", "", "markdown, html or json")
+	outPath := flags.String("out", "", "Write the audit to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from, to := today.AddDate(0, 0, -7), today.AddDate(0, 0, 1)
+	if *fromDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", *fromDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("--from must be a date like 2026-03-01: %w", err)
+		}
+		from = parsed
+	}
+	if *toDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", *toDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("--to must be a date like 2026-03-08: %w", err)
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	opts := core.DefaultAuditOptions()
+	urgency, err := core.ParseDecisionUrgency(*minUrgency)
+	if err != nil {
+		return err
+	}
+	opts.MinUrgency = urgency
+	if *formatName == "" && *outPath != "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(*outPath), ".")
+	}
+	if *formatName != "" {
+		format, err := core.ParseAuditFormat(*formatName)
+		if err != nil {
+			return err
+		}
+		opts.Format = format
+	}
+
+	report, err := cli.ethicalFramework.GenerateAuditReport(ctx, from, to, opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate audit: %w", err)
+	}
+
+	if *outPath == "" {
+		_, err := report.WriteTo(os.Stdout)
+		return err
+	}
+
+	file, err := os.Create(*outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create audit file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := report.WriteTo(file); err != nil {
+		return fmt.Errorf("failed to write audit: %w", err)
+	}
+	if absPath, err := filepath.Abs(*outPath); err == nil {
+		*outPath = absPath
+	}
+	if err := cli.ethicalFramework.RecordAuditExport(ctx, *outPath, report); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	fmt.Printf("✅ Audit of %d decisions written to %s\n", report.Summary.Decisions, *outPath)
+	return nil
+}
+
+// listOpenDecisions shows the decisions awaiting approval or implemented
+// without an outcome, with what can be done to each.
+func (cli *CLI) listOpenDecisions(ctx context.Context) error {
+	decisions, err := cli.ethicalFramework.ListDecisions(ctx, core.DecisionStateAwaitingApproval, core.DecisionStateImplemented)
+	if err != nil {
+		return err
+	}
+	if len(decisions) == 0 {
+		fmt.Println("No open decisions.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tState\tUrgency\tNext\tAction")
+	fmt.Fprintln(w, "---\t-----\t-------\t----\t------")
+	for _, decision := range decisions {
+		next := fmt.Sprintf("feedback %s approve|reject", decision.ID)
+		if decision.CanAbort() {
+			next = fmt.Sprintf("decisions abort %s <reason>", decision.ID)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", decision.ID, decision.State(), decision.Urgency, next, decision.ProposedAction)
+	}
+	return w.Flush()
+}
+
+// pendingDecision is how pending-decisions --json reports a decision.
+type pendingDecision struct {
+	ID             string    `json:"id"`
+	Urgency        string    `json:"urgency"`
+	ProposedAction string    `json:"proposed_action"`
+	CreatedAt      time.Time `json:"created_at"`
+	AgeSeconds     int64     `json:"age_seconds"`
+}
+
+// listPendingDecisions prints the session user's approval queue, most urgent
+// first. --urgency keeps only one urgency and --json prints the queue for
+// scripts.
+func (cli *CLI) listPendingDecisions(args []string) error {
+	flags := flag.NewFlagSet("pending-decisions", flag.ContinueOnError)
+	urgencyName := flags.String("urgency", "", "Only list decisions of this urgency")
+	jsonOutput := flags.Bool("json", false, "Print the decisions as JSON")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 {
+		return errs.New(errs.Validation, "usage: pending-decisions [--urgency low|medium|high|critical] [--json]")
+	}
+
+	var only *core.DecisionUrgency
+	if *urgencyName != "" {
+		urgency, err := core.ParseDecisionUrgency(*urgencyName)
+		if err != nil {
+			return errs.Wrap(err, errs.Validation, nil)
+		}
+		only = &urgency
+	}
+
+	decisions, err := cli.ethicalFramework.ListPendingDecisions(context.Background(), cli.config.Session.UserID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pending := []pendingDecision{}
+	for _, decision := range decisions {
+		if only != nil && decision.Urgency != *only {
+			continue
+		}
+		pending = append(pending, pendingDecision{
+			ID:             decision.ID,
+			Urgency:        decision.Urgency.String(),
+			ProposedAction: decision.ProposedAction,
+			CreatedAt:      decision.CreatedAt,
+			AgeSeconds:     int64(now.Sub(decision.CreatedAt).Seconds()),
+		})
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(pending)
+	}
+	if len(pending) == 0 {
+		fmt.Println("No decisions are waiting for approval.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUrgency\tAge\tAction")
+	fmt.Fprintln(w, "---\t-------\t---\t------")
+	for _, decision := range pending {
+		age := formatDuration(time.Duration(decision.AgeSeconds) * time.Second)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", decision.ID, decision.Urgency, age, decision.ProposedAction)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("\nApprove or reject one with: feedback <decision-id> approve|reject [message]")
+	return nil
+}
+
+// manageConfig handles configuration management commands.
+func (cli *CLI) manageConfig(args []string) error {
+	if len(args) == 0 {
+		return cli.showConfig()
+	}
+
+	action := args[0]
+	switch action {
+	case "get":
+		if len(args) < 2 {
+			return errs.New(errs.Validation, "usage: config get <key>")
+		}
+		return cli.getConfigValue(args[1])
+	case "set":
+		if len(args) < 3 {
+			return errs.New(errs.Validation, "usage: config set <key> <value>")
+		}
+		return cli.setConfigValue(args[1], args[2])
+	case "set-credential":
+		if len(args) < 2 {
+			return errs.New(errs.Validation, "usage: config set-credential <provider>")
+		}
+		return cli.setCredential(args[1])
+	default:
+		return fmt.Errorf("unknown config action: %s. Use 'get', 'set' or 'set-credential'", action)
+	}
+}
+
+// setCredential reads a provider's API key without echoing it and stores it
+// in the OS keyring, where it takes precedence over the configuration file
+// and the environment.
+func (cli *CLI) setCredential(name string) error {
+	provider, err := credentialProvider(name)
+	if err != nil {
+		return err
+	}
+	key, err := readSecretInput(fmt.Sprintf("New %s API key: ", provider))
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	if key == "" {
+		return errs.Newf(errs.Validation, "the %s API key cannot be empty", provider)
+	}
+	if err := mcp.SystemKeyring().Set(mcp.KeyringService, provider, key); err != nil {
+		if errors.Is(err, mcp.ErrKeyringUnavailable) {
+			return fmt.Errorf("%w; use 'providers set-key %s' to save it to the configuration file instead", err, provider)
+		}
+		return fmt.Errorf("failed to save key: %w", err)
+	}
+	fmt.Printf("✓ Saved the %s key to the OS keyring\n", provider)
+
+	// Re-probe so a rejected key is caught now rather than mid-task
+	cli.reportProbe(cli.providerRouter(), provider)
+	return nil
+}
+
+// showConfig displays current configuration.
+func (cli *CLI) showConfig() error {
+	fmt.Println("🔧 Configuration Settings")
+	fmt.Println()
+
+	fmt.Printf("Data Directory: %s\n", cli.config.DataDir)
+	fmt.Printf("Backup Directory: %s\n", cli.config.Storage.BackupDir)
+	fmt.Println()
+
+	fmt.Printf("Budget Limits:\n")
+	fmt.Printf("  daily-limit: $%.2f\n", cli.config.BudgetLimits.DailyLimit)
+	fmt.Printf("  weekly-limit: $%.2f\n", cli.config.BudgetLimits.WeeklyLimit)
+	fmt.Printf("  monthly-limit: $%.2f\n", cli.config.BudgetLimits.MonthlyLimit)
+	fmt.Printf("  per-request-limit: $%.2f\n", cli.config.BudgetLimits.PerRequestLimit)
+	fmt.Printf("  budget-timezone: %s\n", formatBudgetTimezone(cli.config.BudgetLimits.Timezone))
+	fmt.Printf("  budget.enforcement: %s\n", budgetEnforcement(cli.config.BudgetLimits))
+	fmt.Printf("  budget.grace-percent: %g\n", budgetGracePercent(cli.config.BudgetLimits))
+	fmt.Println()
+
+	fmt.Printf("Routing:\n")
+	fmt.Printf("  routing.policy: %s\n", cli.llmRouter.Policy())
+	fmt.Println()
+
+	fmt.Printf("Preferences:\n")
+	fmt.Printf("  auto-approve: %t\n", cli.config.Preferences.AutoApprove)
+	fmt.Printf("  verbose-output: %t\n", cli.config.Preferences.VerboseOutput)
+	fmt.Printf("  log-level: %s\n", strings.ToLower(string(cli.config.Preferences.Verbosity())))
+	fmt.Printf("  default-priority: %d\n", cli.config.Preferences.DefaultPriority)
+	fmt.Printf("  interactive-mode: %t\n", cli.config.Preferences.InteractiveMode)
+	fmt.Printf("  quiet-hours: %s\n", formatQuietHours(cli.config.Preferences))
+	fmt.Printf("  max-in-progress: %d\n", cli.config.Preferences.MaxInProgressObjectives)
+	fmt.Printf("  max-in-progress-per-goal: %d\n", cli.config.Preferences.MaxInProgressPerGoal)
+	fmt.Printf("  suggestion-weights: %s\n", formatSuggestionWeights(cli.objectiveManager.SuggestionWeights()))
+	fmt.Println()
+
+	fmt.Printf("Session:\n")
+	fmt.Printf("  current-goal-id: %s\n", cli.config.Session.CurrentGoalID)
+	fmt.Printf("  user-id: %s\n", cli.config.Session.UserID)
+
+	return nil
+}
+
+// getConfigValue retrieves and displays a specific configuration value.
+func (cli *CLI) getConfigValue(key string) error {
+	switch key {
+	case "data-dir":
+		fmt.Println(cli.config.DataDir)
+	case "backup-dir":
+		fmt.Println(cli.config.Storage.BackupDir)
+	case "quiet-hours":
+		fmt.Println(formatQuietHours(cli.config.Preferences))
+	case "max-in-progress":
+		fmt.Printf("%d\n", cli.config.Preferences.MaxInProgressObjectives)
+	case "max-in-progress-per-goal":
+		fmt.Printf("%d\n", cli.config.Preferences.MaxInProgressPerGoal)
+	case "suggestion-weights":
+		fmt.Println(formatSuggestionWeights(cli.objectiveManager.SuggestionWeights()))
+	case "daily-limit":
+		fmt.Printf("%.2f\n", cli.config.BudgetLimits.DailyLimit)
+	case "weekly-limit":
+		fmt.Printf("%.2f\n", cli.config.BudgetLimits.WeeklyLimit)
+	case "monthly-limit":
+		fmt.Printf("%.2f\n", cli.config.BudgetLimits.MonthlyLimit)
+	case "per-request-limit":
+		fmt.Printf("%.2f\n", cli.config.BudgetLimits.PerRequestLimit)
+	case "budget-timezone":
+		fmt.Println(formatBudgetTimezone(cli.config.BudgetLimits.Timezone))
+	case "budget.enforcement":
+		fmt.Println(budgetEnforcement(cli.config.BudgetLimits))
+	case "budget.grace-percent":
+		fmt.Printf("%g\n", budgetGracePercent(cli.config.BudgetLimits))
+	case "routing.policy":
+		fmt.Println(cli.llmRouter.Policy())
+	case "auto-approve":
+		fmt.Printf("%t\n", cli.config.Preferences.AutoApprove)
+	case "verbose-output":
 		fmt.Printf("%t\n", cli.config.Preferences.VerboseOutput)
+	case "log-level":
+		fmt.Println(strings.ToLower(string(cli.config.Preferences.Verbosity())))
+	case "default-priority":
+		fmt.Printf("%d\n", cli.config.Preferences.DefaultPriority)
+	case "interactive-mode":
+		fmt.Printf("%t\n", cli.config.Preferences.InteractiveMode)
+	case "current-goal-id":
+		fmt.Println(cli.config.Session.CurrentGoalID)
+	case "user-id":
+		fmt.Println(cli.config.Session.UserID)
+	default:
+		return fmt.Errorf("unknown config key: %s", key)
+	}
+	return nil
+}
+
+// setConfigValue updates a configuration value.
+func (cli *CLI) setConfigValue(key, value string) error {
+	switch key {
+	case "daily-limit":
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid daily limit: %s", value)
+		}
+		updates := config.BudgetUpdates{DailyLimit: &limit}
+		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+
+	case "weekly-limit":
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid weekly limit: %s", value)
+		}
+		updates := config.BudgetUpdates{WeeklyLimit: &limit}
+		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+
+	case "routing.policy":
+		policy, err := llm.ParseRoutingPolicy(value)
+		if err != nil {
+			return err
+		}
+		updates := config.RoutingUpdates{Policy: &value}
+		if err := cli.config.UpdateRouting(cli.configPath, updates); err != nil {
+			return err
+		}
+		cli.llmRouter.SetPolicy(policy)
+		return nil
+
+	case "budget-timezone":
+		if value == "local" {
+			value = ""
+		}
+		updates := config.BudgetUpdates{Timezone: &value}
+		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+
+	case "budget.enforcement":
+		updates := config.BudgetUpdates{Enforcement: &value}
+		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+
+	case "budget.grace-percent":
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid grace percent: %s", value)
+		}
+		updates := config.BudgetUpdates{GracePercent: &percent}
+		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+
+	case "monthly-limit":
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid monthly limit: %s", value)
+		}
+		updates := config.BudgetUpdates{MonthlyLimit: &limit}
+		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+
+	case "per-request-limit":
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid per-request limit: %s", value)
+		}
+		updates := config.BudgetUpdates{PerRequestLimit: &limit}
+		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+
+	case "auto-approve":
+		autoApprove, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean value: %s", value)
+		}
+		updates := config.PreferenceUpdates{AutoApprove: &autoApprove}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
+	case "verbose-output":
+		verboseOutput, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean value: %s", value)
+		}
+		updates := config.PreferenceUpdates{VerboseOutput: &verboseOutput}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
+	case "log-level":
+		// Accepts debug, info, warning or error, or "default" to follow verbose-output
+		if value == "default" {
+			value = ""
+		}
+		updates := config.PreferenceUpdates{LogLevel: &value}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
 	case "default-priority":
-		fmt.Printf("%d\n", cli.config.Preferences.DefaultPriority)
+		priority, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid priority: %s", value)
+		}
+		updates := config.PreferenceUpdates{DefaultPriority: &priority}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
 	case "interactive-mode":
-		fmt.Printf("%t\n", cli.config.Preferences.InteractiveMode)
+		interactiveMode, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean value: %s", value)
+		}
+		updates := config.PreferenceUpdates{InteractiveMode: &interactiveMode}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
+	case "max-in-progress":
+		// Lowering the limit only prevents new starts; nothing is paused
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid limit: %s", value)
+		}
+		updates := config.PreferenceUpdates{MaxInProgressObjectives: &limit}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
+	case "max-in-progress-per-goal":
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid limit: %s", value)
+		}
+		updates := config.PreferenceUpdates{MaxInProgressPerGoal: &limit}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
+	case "suggestion-weights":
+		// Accepts factor=weight pairs, or "default" to clear the overrides
+		if value == "default" {
+			updates := config.PreferenceUpdates{SuggestionWeights: map[string]float64{}}
+			return cli.config.UpdatePreferences(cli.configPath, updates)
+		}
+		weights, err := core.ParseSuggestionWeights(cli.objectiveManager.SuggestionWeights(), value)
+		if err != nil {
+			return err
+		}
+		updates := config.PreferenceUpdates{SuggestionWeights: weights.Map()}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
+	case "backup-dir":
+		updates := config.StorageUpdates{BackupDir: &value}
+		return cli.config.UpdateStorage(cli.configPath, updates)
+
+	case "quiet-hours":
+		// Accepts "HH:MM-HH:MM", or "off" to clear the window
+		start, end := "", ""
+		if value != "off" {
+			var found bool
+			start, end, found = strings.Cut(value, "-")
+			if !found {
+				return fmt.Errorf("invalid quiet hours: %s (expected HH:MM-HH:MM or off)", value)
+			}
+		}
+		updates := config.PreferenceUpdates{QuietHoursStart: &start, QuietHoursEnd: &end}
+		return cli.config.UpdatePreferences(cli.configPath, updates)
+
 	case "current-goal-id":
-		fmt.Println(cli.config.Session.CurrentGoalID)
+		updates := config.SessionUpdates{CurrentGoalID: &value}
+		return cli.config.UpdateSession(cli.configPath, updates)
+
 	case "user-id":
-		fmt.Println(cli.config.Session.UserID)
+		updates := config.SessionUpdates{UserID: &value}
+		return cli.config.UpdateSession(cli.configPath, updates)
+
+	default:
+		return fmt.Errorf("unknown or read-only config key: %s", key)
+	}
+}
+
+// formatQuietHours describes the quiet-hours window for display.
+func formatQuietHours(prefs config.PreferenceConfig) string {
+	if !prefs.HasQuietHours() {
+		return "off"
+	}
+	return prefs.QuietHoursStart + "-" + prefs.QuietHoursEnd
+}
+
+// formatBudgetTimezone shows the budget time zone, "local" when unset.
+func formatBudgetTimezone(timezone string) string {
+	if timezone == "" {
+		return "local"
+	}
+	return timezone
+}
+
+// budgetEnforcement names the budget's enforcement mode, "hard" when unset.
+func budgetEnforcement(budget *config.BudgetConfig) string {
+	if budget.Enforcement == "" {
+		return string(mcp.BudgetEnforcementHard)
+	}
+	return budget.Enforcement
+}
+
+// budgetGracePercent returns the grace soft enforcement gives running plans,
+// as a percentage of the limits.
+func budgetGracePercent(budget *config.BudgetConfig) float64 {
+	if budget.GracePercent <= 0 {
+		return mcp.DefaultGracePercent
+	}
+	return budget.GracePercent
+}
+
+// archiveSettled moves settled work into archive segments.
+func (cli *CLI) archiveSettled(args []string) error {
+	dryRun := false
+	for _, arg := range args {
+		switch arg {
+		case "--dry-run":
+			dryRun = true
+		default:
+			return fmt.Errorf("unknown archive option: %s", arg)
+		}
+	}
+
+	report, err := core.NewArchiveManager(cli.store).ArchiveSettled(context.Background(), core.DefaultArchivePolicy(), dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to archive: %w", err)
+	}
+
+	fmt.Printf("🗄️  %s\n", report)
+	if len(report.Segments) > 0 && cli.config.Preferences.VerboseOutput {
+		fmt.Printf("Segments: %s\n", strings.Join(report.Segments, ", "))
+	}
+	return nil
+}
+
+// archiveGoal archives a goal and cancels its pending and paused objectives.
+// Its sub-goals are archived too with --recursive; without it they are named
+// so the user can decide.
+func (cli *CLI) archiveGoal(args []string) error {
+	const usage = "usage: archive-goal <goal-id> [--reason text] [--recursive]"
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, usage)
+	}
+	goalID, err := cli.resolveID(completion.ArgGoal, args[0])
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("archive-goal", flag.ContinueOnError)
+	reason := flags.String("reason", "", "Why the goal is archived")
+	recursive := flags.Bool("recursive", false, "Archive the goal's sub-goals too")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 {
+		return errs.New(errs.Validation, usage)
+	}
+
+	result, err := cli.goalManager.ArchiveGoal(context.Background(), goalID, core.ArchiveOptions{Reason: *reason, Recursive: *recursive})
+	if err != nil {
+		return err
+	}
+
+	for _, goal := range result.Goals {
+		fmt.Printf("✓ Archived goal: %s\n", goal.Title)
+		if goal.ID == cli.config.Session.CurrentGoalID {
+			none := ""
+			if err := cli.config.UpdateSession(cli.configPath, config.SessionUpdates{CurrentGoalID: &none}); err != nil {
+				fmt.Printf("Warning: failed to update session: %v\n", err)
+			}
+		}
+	}
+	if len(result.Objectives) > 0 {
+		fmt.Printf("  Cancelled %d pending or paused objectives\n", len(result.Objectives))
+	}
+	for _, objective := range result.Running {
+		fmt.Printf("  Still in progress: %s (%s)\n", objective.Title, objective.ID[:8])
+	}
+	if len(result.SubGoals) > 0 {
+		if *recursive {
+			fmt.Println("  Kept sub-goals that still serve other goals:")
+		} else {
+			fmt.Println("  Sub-goals left active (archive them too with --recursive):")
+		}
+		for _, goal := range result.SubGoals {
+			fmt.Printf("    %s (%s)\n", goal.Title, goal.ID[:8])
+		}
+	}
+	return nil
+}
+
+// unarchiveGoal restores an archived goal, the sub-goals archived with it
+// and the objectives the archive cancelled.
+func (cli *CLI) unarchiveGoal(args []string) error {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, "usage: unarchive-goal <goal-id>")
+	}
+	goalID, err := cli.resolveID(completion.ArgArchivedGoal, args[0])
+	if err != nil {
+		return err
+	}
+
+	result, err := cli.goalManager.UnarchiveGoal(context.Background(), goalID)
+	if err != nil {
+		return err
+	}
+
+	for _, goal := range result.Goals {
+		fmt.Printf("✓ Restored goal: %s (%s)\n", goal.Title, goal.Status)
+	}
+	if len(result.Objectives) > 0 {
+		fmt.Printf("  %d objectives back to pending\n", len(result.Objectives))
+	}
+	return nil
+}
+
+// checkData lists the live nodes whose data does not match the schema of
+// their type, field by field. Such nodes are skipped when listed; fix them
+// with -skip-validation if the fix needs several steps.
+func (cli *CLI) checkData(args []string) error {
+	if len(args) > 0 {
+		return errs.New(errs.Validation, "usage: check-data")
+	}
+	invalid, err := cli.store.ValidateAll(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to check data: %w", err)
+	}
+	if len(invalid) == 0 {
+		fmt.Println("✓ All stored nodes match their schemas")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Type\tID\tField\tIssue")
+	fmt.Fprintln(w, "----\t--\t-----\t-----")
+	for _, node := range invalid {
+		for _, violation := range node.Violations {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", node.NodeType, node.NodeID, violation.Field, violation.Issue)
+		}
+	}
+	w.Flush()
+	return errs.Newf(errs.Validation, "%d nodes do not match their schemas", len(invalid)).With("nodes", len(invalid))
+}
+
+// compactStore removes intermediate node versions older than the retention
+// window, optionally exporting them first, and the task output blobs no
+// execution refers to any more.
+func (cli *CLI) compactStore(args []string) error {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	days := flags.Int("days", int(storage.DefaultCompactRetention/(24*time.Hour)), "Keep every version from the last n days")
+	nodeType := flags.String("type", "", "Only compact nodes of this type")
+	export := flags.String("export", "", "Write the removed versions to this file first")
+	dryRun := flags.Bool("dry-run", false, "Show what would be removed without removing it")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 || *days < 1 {
+		return errs.New(errs.Validation, "usage: compact [--days n] [--type t] [--export file] [--dry-run]")
+	}
+
+	opts := storage.CompactOptions{
+		Retention:  time.Duration(*days) * 24 * time.Hour,
+		ExportPath: *export,
+		DryRun:     *dryRun,
+	}
+	if *nodeType != "" {
+		opts.Types = []string{*nodeType}
+	}
+
+	ctx := context.Background()
+	result, err := cli.store.Compact(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to compact: %w", err)
+	}
+
+	// Task outputs stored as blobs go once no execution refers to them
+	blobs, err := core.CollectOutputBlobs(ctx, cli.store, storage.BlobGCOptions{DryRun: *dryRun})
+	if err != nil {
+		return fmt.Errorf("failed to collect output blobs: %w", err)
+	}
+
+	fmt.Printf("🗜️  %s\n", result)
+	fmt.Printf("🗑️  %s\n", blobs)
+	if result.ExportPath != "" {
+		fmt.Printf("Removed versions saved to %s\n", result.ExportPath)
+	}
+	if result.DryRun && (result.VersionsRemoved > 0 || blobs.Removed > 0) {
+		fmt.Println("Run without --dry-run to remove them.")
+	}
+	return nil
+}
+
+// exportStore writes the whole store to an archive file.
+func (cli *CLI) exportStore(args []string) error {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, "usage: export <file>")
+	}
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	manifest, err := cli.store.Export(context.Background(), file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(args[0])
+		return fmt.Errorf("failed to export: %w", err)
+	}
+
+	fmt.Printf("📦 Exported %s to %s\n", manifest, args[0])
+	return nil
+}
+
+// importStore loads an archive written by exportStore, merging it into the
+// store unless --replace is given.
+func (cli *CLI) importStore(args []string) error {
+	var path string
+	opts := storage.ImportOptions{Mode: storage.ImportMerge}
+	for _, arg := range args {
+		switch {
+		case arg == "--replace":
+			opts.Mode = storage.ImportReplace
+		case strings.HasPrefix(arg, "-"):
+			return errs.Newf(errs.Validation, "unknown import option: %s", arg)
+		case path == "":
+			path = arg
+		default:
+			return errs.New(errs.Validation, "usage: import <file> [--replace]")
+		}
+	}
+	if path == "" {
+		return errs.New(errs.Validation, "usage: import <file> [--replace]")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open export file: %w", err)
+	}
+	defer file.Close()
+	result, err := cli.store.Import(context.Background(), file, opts)
+	if err != nil {
+		return fmt.Errorf("failed to import: %w", err)
+	}
+
+	fmt.Printf("📥 %s\n", result)
+	if len(result.Conflicts) > 0 {
+		fmt.Println("Kept the store's own version of:")
+		for _, conflict := range result.Conflicts {
+			fmt.Printf("  %s %s (%s)\n", conflict.Kind, conflict.ID, conflict.Type)
+		}
+		fmt.Println("Run with --replace to take the archive's contents instead.")
+	}
+	return nil
+}
+
+// undoOperation undoes a journaled operation, the most recent undoable one
+// by default, after showing what it changed and asking for confirmation.
+// Undoing an undo redoes the operation.
+func (cli *CLI) undoOperation(args []string) error {
+	var operationID string
+	opts := core.UndoOptions{}
+	confirmed := false
+	for _, arg := range args {
+		switch {
+		case arg == "--partial":
+			opts.Partial = true
+		case arg == "--yes":
+			confirmed = true
+		case strings.HasPrefix(arg, "-"):
+			return errs.Newf(errs.Validation, "unknown undo option: %s", arg)
+		case operationID == "":
+			operationID = arg
+		default:
+			return errs.New(errs.Validation, "usage: undo [operation-id] [--partial] [--yes]")
+		}
+	}
+
+	ctx := context.Background()
+	journal := core.NewOperationJournal(cli.store)
+	var operation *core.OperationRecord
+	var err error
+	if operationID == "" {
+		operation, err = journal.LastUndoable(ctx)
+	} else {
+		if operationID, err = cli.resolveID(completion.ArgOperation, operationID); err != nil {
+			return err
+		}
+		operation, err = journal.GetOperation(ctx, operationID)
+	}
+	if err != nil {
+		return err
+	}
+
+	dryRun := opts
+	dryRun.DryRun = true
+	preview, err := journal.UndoOperation(ctx, operation.ID, dryRun)
+	printUndoConflicts(preview)
+	if err != nil {
+		if errs.CodeOf(err) == errs.Conflict && preview != nil && len(preview.Conflicts) > 0 {
+			fmt.Println("   Run with --partial to undo the rest.")
+		}
+		return err
+	}
+
+	fmt.Printf("↩️  %s (%s)\n", operation.Summary, operation.CreatedAt.Local().Format("2006-01-02 15:04"))
+	fmt.Printf("   Reverts %d of %d changes: %s\n", len(preview.Reverted), len(operation.Changes), operation.Describe())
+	if len(preview.Reverted) == 0 {
+		fmt.Println("Nothing left to undo.")
+		return nil
+	}
+	if !confirmed {
+		answer, err := readConfirmation(bufio.NewReader(os.Stdin), "Undo it? [y/N] ")
+		if err != nil || !answer {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	result, err := journal.UndoOperation(ctx, operation.ID, opts)
+	if err != nil {
+		printUndoConflicts(result)
+		return fmt.Errorf("failed to undo operation: %w", err)
+	}
+	fmt.Printf("✓ Undone; %d changes reverted\n", len(result.Reverted))
+	if result.Undo != nil {
+		fmt.Printf("   Run 'undo %s' to redo it.\n", result.Undo.ID)
+	}
+	return nil
+}
+
+// printUndoConflicts lists the entities that changed since an operation.
+func printUndoConflicts(result *core.UndoResult) {
+	if result == nil || len(result.Conflicts) == 0 {
+		return
+	}
+	fmt.Printf("⚠️  %d entities changed since the operation:\n", len(result.Conflicts))
+	for _, conflict := range result.Conflicts {
+		fmt.Printf("   %s %s (%s): %s\n", conflict.Entity, conflict.ID, conflict.Type, conflict.Reason)
+	}
+}
+
+// listOperations shows the operation journal, most recent first.
+func (cli *CLI) listOperations(args []string) error {
+	if len(args) > 0 && args[0] != "list" {
+		return fmt.Errorf("unknown operations action: %s. Use 'list'", args[0])
+	}
+
+	operations, err := core.NewOperationJournal(cli.store).ListOperations(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(operations) == 0 {
+		fmt.Println("No operations journaled yet.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tWhen\tState\tChanges\tOperation")
+	fmt.Fprintln(w, "---\t----\t-----\t-------\t---------")
+	for _, operation := range operations {
+		state := "undoable"
+		if operation.UndoneBy != "" {
+			state = "undone"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", operation.ID, operation.CreatedAt.Local().Format("2006-01-02 15:04"),
+			state, len(operation.Changes), operation.Summary)
+	}
+	w.Flush()
+	return nil
+}
+
+// previewReplan shows the context diff since an objective's last execution
+// and the re-planning strategy that running it now would use.
+func (cli *CLI) previewReplan(args []string) error {
+	if len(args) != 1 {
+		return errs.New(errs.Validation, "usage: preview <objective-id>")
+	}
+	ctx := context.Background()
+
+	objectiveID, err := cli.resolveID(completion.ArgObjective, args[0])
+	if err != nil {
+		return err
+	}
+	objective, err := cli.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return fmt.Errorf("failed to get objective: %w", err)
+	}
+	decision, err := core.NewReplanAdvisor(cli.store, nil).Preview(ctx, objective.ID)
+	if err != nil {
+		return fmt.Errorf("failed to preview re-planning: %w", err)
+	}
+
+	fmt.Printf("Objective: %s (%s)\n", objective.Title, objective.ID)
+	if decision.PreviousPlan != nil {
+		fmt.Printf("Last run:  plan %s, execution %s\n", decision.PreviousPlanID, decision.PreviousExecutionID)
+		if plan := decision.PreviousPlan; plan.MethodID != "" && plan.RawEstimatedTokens() > 0 {
+			calibration, err := cli.methodManager.GetEstimateCalibration(ctx, plan.MethodID)
+			if err != nil {
+				return fmt.Errorf("failed to calibrate estimate: %w", err)
+			}
+			fmt.Printf("Estimate:  %d tokens (%s)\n", calibration.Adjust(plan.RawEstimatedTokens()), calibration.Description())
+		}
+	}
+
+	if decision.Diff != nil {
+		fmt.Printf("\nContext changes: %s\n", decision.Diff)
+		for _, line := range decision.Diff.Lines() {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	fmt.Printf("\nStrategy: %s\n", decision.Strategy.Description())
+	fmt.Printf("Reason:   %s\n", decision.Reason)
+	if len(decision.AffectedTasks) > 0 && decision.PreviousPlan != nil {
+		fmt.Println("Tasks to re-plan:")
+		for _, task := range decision.PreviousPlan.Tasks {
+			for _, id := range decision.AffectedTasks {
+				if task.ID == id {
+					fmt.Printf("  %s: %s\n", task.ID, task.Description)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// decomposeGoal asks an LLM to propose objectives for a goal, lets the user
+// pick which ones to keep, and creates those.
+func (cli *CLI) decomposeGoal(args []string) error {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, "usage: decompose <goal-id> [--max n] [--no-methods]")
+	}
+	goalID, err := cli.resolveID(completion.ArgGoal, args[0])
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("decompose", flag.ContinueOnError)
+	maxObjectives := flags.Int("max", core.DefaultDecompositionOptions().MaxObjectives, "Propose at most this many objectives")
+	noMethods := flags.Bool("no-methods", false, "Do not offer proven methods for reuse")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+
+	budget, err := cli.budgetManager()
+	if err != nil {
+		return err
+	}
+
+	opts := core.DefaultDecompositionOptions()
+	opts.MaxObjectives = *maxObjectives
+	opts.UseMethodCache = !*noMethods
+	opts.Router = cli.llmRouter
+	opts.Budget = budget
+	opts.ContextManager = cli.contextManager
+	opts.UserID = cli.config.Session.UserID
+
+	ctx := context.Background()
+	fmt.Println("🧩 Proposing objectives...")
+	proposal, err := cli.goalManager.ProposeObjectives(ctx, goalID, opts)
+	if err != nil {
+		return fmt.Errorf("failed to propose objectives: %w", err)
+	}
+
+	if len(proposal.Issues) > 0 {
+		fmt.Println("\nIssues with the response:")
+		for _, issue := range proposal.Issues {
+			fmt.Printf("  ⚠️  %s\n", issue)
+		}
+	}
+	if len(proposal.Items) == 0 {
+		fmt.Println("\nNo usable objectives were proposed.")
+		return nil
+	}
+
+	fmt.Printf("\nProposed objectives (cost $%.4f):\n", proposal.Cost)
+	for _, item := range proposal.Items {
+		method := item.MethodName
+		if item.NewMethodNeeded {
+			method = core.NewMethodNeeded
+		}
+		fmt.Printf("  [%s] %s (priority %d, method: %s)\n", item.Key, item.Title, item.Priority, method)
+		if item.Description != "" {
+			fmt.Printf("      %s\n", item.Description)
+		}
+		if len(item.DependsOn) > 0 {
+			fmt.Printf("      after: %s\n", strings.Join(item.DependsOn, ", "))
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var keys []string
+	for {
+		fmt.Print("\nAccept which objectives? [all|none|1,3] ")
+		line, _, err := reader.ReadLine()
+		if err != nil {
+			return fmt.Errorf("failed to read selection: %w", err)
+		}
+		keys, err = parseProposalSelection(string(line), proposal)
+		if err == nil {
+			break
+		}
+		fmt.Printf("  %v\n", err)
+	}
+	if len(keys) == 0 {
+		fmt.Println("No objectives created.")
+		return nil
+	}
+
+	accepted, err := cli.goalManager.AcceptProposals(ctx, proposal, keys)
+	if err != nil {
+		return fmt.Errorf("failed to create objectives: %w", err)
+	}
+
+	for _, objective := range accepted.Objectives {
+		fmt.Printf("✓ Created objective %s: %s\n", objective.ID, objective.Title)
+	}
+	if len(accepted.MethodSuggestions) > 0 {
+		fmt.Println("\nDraft methods were created for objectives that needed one; refine them before running:")
+		for _, method := range accepted.MethodSuggestions {
+			fmt.Printf("  %s: %s\n", method.ID, method.Name)
+		}
+	}
+	return nil
+}
+
+// parseProposalSelection reads "all", "none" (or nothing) or a comma
+// separated list of proposal keys.
+func parseProposalSelection(input string, proposal *core.DecompositionProposal) ([]string, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	switch input {
+	case "", "none", "n":
+		return nil, nil
+	case "all", "a":
+		keys := make([]string, len(proposal.Items))
+		for i, item := range proposal.Items {
+			keys[i] = item.Key
+		}
+		return keys, nil
+	}
+
+	var keys []string
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if proposal.Item(field) == nil {
+			return nil, fmt.Errorf("no proposed objective %q", field)
+		}
+		keys = append(keys, field)
+	}
+	return keys, nil
+}
+
+// showAudit lists the LLM requests in the audit log, most recent last, with
+// their prompts and responses when --full is given.
+func (cli *CLI) showAudit(args []string) error {
+	const usage = "usage: audit [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--provider name] [--objective id] [--limit n] [--full]"
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fromDate := flags.String("from", "", "First day to show, YYYY-MM-DD")
+	toDate := flags.String("to", "", "Last day to show, YYYY-MM-DD")
+	provider := flags.String("provider", "", "Show only requests to this provider")
+	objectiveID := flags.String("objective", "", "Show only requests for this objective")
+	limit := flags.Int("limit", 20, "Show at most this many requests (0: all)")
+	full := flags.Bool("full", false, "Show each request's prompt and response")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 || *limit < 0 {
+		return errs.New(errs.Validation, usage)
+	}
+
+	filter := mcp.AuditFilter{Provider: *provider, ObjectiveID: *objectiveID, Limit: *limit}
+	if *fromDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", *fromDate, time.Local)
+		if err != nil {
+			return errs.Wrap(fmt.Errorf("--from must be a date like 2026-03-01: %w", err), errs.Validation, nil)
+		}
+		filter.From = parsed
+	}
+	if *toDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", *toDate, time.Local)
+		if err != nil {
+			return errs.Wrap(fmt.Errorf("--to must be a date like 2026-03-31: %w", err), errs.Validation, nil)
+		}
+		filter.To = parsed.AddDate(0, 0, 1)
+	}
+
+	auditLog, err := mcp.NewAuditLog(cli.config.Audit.AuditLogConfig(cli.config.DataDir))
+	if err != nil {
+		return fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	defer auditLog.Close()
+	entries, err := auditLog.Query(filter)
+	if err != nil {
+		return fmt.Errorf("failed to read the audit log: %w", err)
+	}
+	if len(entries) == 0 {
+		if !cli.config.Audit.Enabled {
+			fmt.Println("No requests audited. Set enabled = true under [audit] in the configuration to start.")
+		} else {
+			fmt.Println("No requests audited.")
+		}
+		return nil
+	}
+
+	if !*full {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer w.Flush()
+		fmt.Fprintln(w, "TIME\tOPERATION\tMODEL\tOBJECTIVE\tTOKENS\tCOST\tLATENCY\tSTATUS")
+		for _, entry := range entries {
+			status := "ok"
+			if entry.Error != "" {
+				status = "failed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s/%s\t%s\t%d\t$%.4f\t%dms\t%s\n",
+				entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.Operation,
+				entry.Provider, entry.Model, entry.ObjectiveID,
+				entry.TokensUsed, entry.Cost, entry.LatencyMs, status)
+		}
+		return nil
+	}
+
+	for i, entry := range entries {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s %s %s/%s\n", entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.Operation, entry.Provider, entry.Model)
+		if entry.ObjectiveID != "" || entry.Component != "" {
+			fmt.Printf("  Objective: %s  Component: %s\n", entry.ObjectiveID, entry.Component)
+		}
+		fmt.Printf("  Usage:     %d tokens, $%.4f, %dms\n", entry.TokensUsed, entry.Cost, entry.LatencyMs)
+		if entry.Error != "" {
+			fmt.Printf("  Error:     %s\n", entry.Error)
+		}
+		if entry.Redacted {
+			fmt.Printf("  Redacted:  prompt %d bytes (sha256 %s), response %d bytes (sha256 %s)\n",
+				entry.PromptBytes, entry.PromptHash, entry.ResponseBytes, entry.ResponseHash)
+			continue
+		}
+		fmt.Printf("\n--- Prompt (%d bytes) ---\n%s\n", entry.PromptBytes, entry.Prompt)
+		if entry.Response != "" {
+			fmt.Printf("--- Response (%d bytes) ---\n%s\n", entry.ResponseBytes, entry.Response)
+		}
+	}
+	return nil
+}
+
+// inspectExchanges lists recently logged LLM exchanges or shows every
+// attempt at one request.
+func (cli *CLI) inspectExchanges(args []string) error {
+	logger, err := llm.NewExchangeLogger(filepath.Join(cli.config.DataDir, "exchanges"), llm.DefaultExchangeLogConfig(), log.New(os.Stderr, "", 0))
+	if err != nil {
+		return fmt.Errorf("failed to open exchange log: %w", err)
+	}
+
+	action := "tail"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "tail":
+		limit := 20
+		if len(args) > 1 {
+			if limit, err = strconv.Atoi(args[1]); err != nil || limit <= 0 {
+				return fmt.Errorf("invalid count: %s", args[1])
+			}
+		}
+		records, err := logger.Tail(limit)
+		if err != nil {
+			return fmt.Errorf("failed to read exchanges: %w", err)
+		}
+		if len(records) == 0 {
+			fmt.Println("No exchanges logged.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer w.Flush()
+		fmt.Fprintln(w, "TIME\tFINGERPRINT\tMODEL\tTASK\tTOKENS\tCOST\tLATENCY\tSTATUS")
+		for _, record := range records {
+			status := "ok"
+			if !record.Success {
+				status = "failed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s/%s\t%s\t%d\t$%.4f\t%dms\t%s\n",
+				record.Timestamp.Format("2006-01-02 15:04:05"), record.Fingerprint,
+				record.Provider, record.Model, record.TaskType,
+				record.TokensUsed, record.Cost, record.LatencyMs, status)
+		}
+		return nil
+
+	case "show":
+		if len(args) != 2 {
+			return errs.New(errs.Validation, "usage: exchanges show <fingerprint>")
+		}
+		records, err := logger.Find(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to find exchanges: %w", err)
+		}
+		if len(records) == 0 {
+			return fmt.Errorf("no exchanges logged for %s", args[1])
+		}
+
+		for i, record := range records {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("Exchange %s (%s)\n", record.ID, record.Fingerprint)
+			fmt.Printf("  Time:     %s\n", record.Timestamp.Format("2006-01-02 15:04:05"))
+			fmt.Printf("  Model:    %s/%s\n", record.Provider, record.Model)
+			if record.TaskType != "" {
+				fmt.Printf("  Task:     %s\n", record.TaskType)
+			}
+			fmt.Printf("  Usage:    %d tokens, $%.4f, %dms\n", record.TokensUsed, record.Cost, record.LatencyMs)
+			fmt.Printf("  Logged:   %s\n", record.LoggedBecause)
+			if record.Error != "" {
+				fmt.Printf("  Error:    %s\n", record.Error)
+			}
+			if record.TextOmitted != "" {
+				fmt.Printf("  Text omitted: %s (prompt %d bytes, response %d bytes)\n",
+					record.TextOmitted, record.PromptBytes, record.ResponseBytes)
+				continue
+			}
+			fmt.Printf("\n--- Prompt (%d bytes) ---\n%s\n", record.PromptBytes, record.Prompt)
+			if record.Response != "" || record.Success {
+				fmt.Printf("--- Response (%d bytes) ---\n%s\n", record.ResponseBytes, record.Response)
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown exchanges action: %s (use tail or show)", action)
+	}
+}
+
+// search finds nodes whose data contains all the given words.
+func (cli *CLI) search(args []string) error {
+	opts := storage.SearchOptions{Limit: 50}
+	var words []string
+	for _, arg := range args {
+		if arg == "--archived" {
+			opts.IncludeArchived = true
+			continue
+		}
+		words = append(words, arg)
+	}
+	if len(words) == 0 {
+		return errs.New(errs.Validation, "usage: search <words...> [--archived]")
+	}
+
+	nodes, err := cli.store.Search(context.Background(), strings.Join(words, " "), opts)
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+
+	if len(nodes) == 0 {
+		fmt.Printf("No matches for: %s\n", strings.Join(words, " "))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tType\tTitle\tChanged")
+	fmt.Fprintln(w, "---\t----\t-----\t-------")
+	for _, node := range nodes {
+		title, _ := node.Data["title"].(string)
+		if title == "" {
+			title, _ = node.Data["name"].(string)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", node.ID[:8], node.Type, title, formatTime(node.ValidFrom))
+	}
+	return nil
+}
+
+// previewObjectiveCost prints what working on an objective is estimated to
+// cost, as the GUI shows while it is written. Nothing is created or sent.
+func (cli *CLI) previewObjectiveCost(req llm.TaskRequest) error {
+	budget, err := cli.budgetManager()
+	if err != nil {
+		return err
+	}
+	preview := cli.llmRouter.PreviewCost(req, llm.BudgetCostLimits(budget, cli.config.BudgetLimits.PerRequestLimit))
+	if preview == nil {
+		fmt.Println("No cost estimate: no LLM provider is configured.")
+		return nil
+	}
+
+	fmt.Printf("💲 %s\n", preview)
+	if preview.OverBudget() {
+		fmt.Printf("⚠️  Expected cost with %s is %s\n", preview.Expected.Model, preview.Warning)
+	}
+	fmt.Println("Dry run: the objective was not created.")
+	return nil
+}
+
+// budgetReport writes the recorded LLM usage of a period, by day, provider
+// and model, to stdout or to the --out file. The period defaults to the
+// current month, and the format follows the file extension unless --format
+// is given.
+func (cli *CLI) budgetReport(args []string) error {
+	flags := flag.NewFlagSet("budget-report", flag.ContinueOnError)
+	fromDate := flags.String("from", "", "First day of the period, YYYY-MM-DD (default: first of this month)")
+	toDate := flags.String("to", "", "Last day of the period, YYYY-MM-DD (default: today)")
+	formatName := flags.String("format", "", "csv or json (default: csv)")
+	outPath := flags.String("out", "", "Write the report to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 {
+		return errs.New(errs.Validation, "usage: budget-report [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--format csv|json] [--out file]")
+	}
+
+	now := time.Now()
+	opts := llm.ReportOptions{From: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)}
+	if *fromDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", *fromDate, time.Local)
+		if err != nil {
+			return errs.Wrap(fmt.Errorf("--from must be a date like 2026-03-01: %w", err), errs.Validation, nil)
+		}
+		opts.From = parsed
+	}
+	if *toDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", *toDate, time.Local)
+		if err != nil {
+			return errs.Wrap(fmt.Errorf("--to must be a date like 2026-03-31: %w", err), errs.Validation, nil)
+		}
+		opts.To = parsed.AddDate(0, 0, 1)
+	}
+	if *formatName == "" && *outPath != "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(*outPath), ".")
+		if *formatName != "json" {
+			*formatName = "csv"
+		}
+	}
+	if *formatName != "" {
+		format, err := llm.ParseReportFormat(*formatName)
+		if err != nil {
+			return err
+		}
+		opts.Format = format
+	}
+
+	budget, err := cli.budgetManager()
+	if err != nil {
+		return err
+	}
+	report, err := budget.ExportReport(context.Background(), opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate budget report: %w", err)
+	}
+
+	if *outPath == "" {
+		_, err := report.WriteTo(os.Stdout)
+		return err
+	}
+
+	file, err := os.Create(*outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer file.Close()
+	if _, err := report.WriteTo(file); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	fmt.Printf("📄 Budget report for %s to %s written to %s: %d calls costing $%.2f\n",
+		report.From.Format("2006-01-02"), report.LastDay().Format("2006-01-02"), *outPath,
+		report.Totals.Calls, report.Totals.Cost)
+	if gaps := len(report.GapDays); gaps == 1 {
+		fmt.Println("   1 day without recorded usage")
+	} else if gaps > 1 {
+		fmt.Printf("   %d days without recorded usage\n", gaps)
+	}
+	return nil
+}
+
+// showBudget prints the budget limits and what has been spent against them.
+func (cli *CLI) showBudget() error {
+	fmt.Printf("💰 Budget Limits:\n")
+	fmt.Printf("   Daily: $%.2f | Weekly: $%.2f | Monthly: $%.2f | Per Request: $%.2f\n",
+		cli.config.BudgetLimits.DailyLimit, cli.config.BudgetLimits.WeeklyLimit,
+		cli.config.BudgetLimits.MonthlyLimit, cli.config.BudgetLimits.PerRequestLimit)
+	budget, err := cli.budgetManager()
+	if err != nil {
+		return err
+	}
+	status := budget.GetBudgetStatus()
+	for _, period := range []struct{ key, label string }{
+		{"daily", "today"}, {"weekly", "this week"}, {"monthly", "this month"},
+	} {
+		if spent := status.Periods[period.key]; spent != nil {
+			fmt.Printf("   Spent %s: $%.2f (%.0f%%, $%.2f left)\n", period.label, spent.Usage, spent.Percentage, spent.Remaining)
+		}
+	}
+	if alert := status.LatestAlert; alert != nil {
+		fmt.Printf("   ⚠️  %s (%s)\n", alert.Message, alert.Timestamp.Format("Jan 2 15:04"))
+	}
+	return nil
+}
+
+// budgetManager opens the budget that LLM spending is recorded against.
+func (cli *CLI) budgetManager() (*llm.BudgetManager, error) {
+	config, err := cli.config.BudgetLimits.ManagerConfig()
+	if err != nil {
+		return nil, err
+	}
+	budget, err := llm.NewBudgetManager(filepath.Join(cli.config.DataDir, "budget"), config, log.New(os.Stderr, "[Budget] ", 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open budget: %w", err)
+	}
+	return budget, nil
+}
+
+// showGoalSpending prints the goals that spent the most this month, at most
+// limit of them, with spending not attributed to a goal listed as such.
+func (cli *CLI) showGoalSpending(ctx context.Context, limit int) {
+	budget, err := cli.budgetManager()
+	if err != nil {
+		return
+	}
+	spend, err := budget.GetSpendByGoal(ctx, llm.PeriodMonthly)
+	if err != nil || len(spend) == 0 {
+		return
+	}
+
+	goalIDs := make([]string, 0, len(spend))
+	for goalID := range spend {
+		goalIDs = append(goalIDs, goalID)
+	}
+	sort.Slice(goalIDs, func(i, j int) bool {
+		a, b := spend[goalIDs[i]], spend[goalIDs[j]]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		return goalIDs[i] < goalIDs[j]
+	})
+	if len(goalIDs) > limit {
+		goalIDs = goalIDs[:limit]
+	}
+
+	fmt.Println()
+	fmt.Printf("🎯 Top Goals by Spend (this month):\n")
+	for _, goalID := range goalIDs {
+		name := "(unattributed)"
+		if goalID != llm.UnattributedGoal {
+			name = goalID
+			if goal, err := cli.goalManager.GetGoal(ctx, goalID); err == nil {
+				name = goal.Title
+			}
+		}
+		total := spend[goalID]
+		fmt.Printf("   %-40s $%.2f | %d calls | %d tokens\n", name, total.Cost, total.Calls, total.Tokens)
+	}
+}
+
+// newIntentDispatcher sets up intent classification for interactive mode.
+func (cli *CLI) newIntentDispatcher() (*core.IntentDispatcher, error) {
+	budget, err := cli.budgetManager()
+	if err != nil {
+		return nil, err
+	}
+
+	registry := core.NewIntentRegistry()
+	if err := core.RegisterBuiltinIntents(registry, core.IntentServices{
+		Goals:           cli.goalManager,
+		Objectives:      cli.objectiveManager,
+		Methods:         cli.methodManager,
+		Budget:          budget,
+		DefaultPriority: cli.config.Preferences.DefaultPriority,
+	}); err != nil {
+		return nil, err
+	}
+
+	classifier := core.NewIntentClassifier(registry, core.IntentClassifierConfig{
+		Router: cli.llmRouter,
+		Budget: budget,
+	})
+
+	// Chat keeps its history for the session so replies can build on it
+	conversation := llm.NewConversation(cli.llmRouter, llm.TaskRequest{
+		MaxTokens:   1000,
+		Temperature: 0.7,
+		TaskType:    "chat",
+	}, 0)
+	chat := func(ctx context.Context, message string) (string, error) {
+		// Talk is about the current goal, whichever it is by now
+		goalID := cli.config.Session.CurrentGoalID
+		conversation.SetSystemPrompt(cli.goalChatPrompt(ctx, goalID))
+		result, err := conversation.Send(ctx, message)
+		if err != nil {
+			return "", err
+		}
+
+		completion := result.ExecutionResult
+		if err := budget.RecordUsage(ctx, llm.Transaction{
+			Provider:   result.SelectedModel.Provider,
+			Model:      result.SelectedModel.Model,
+			TaskType:   "chat",
+			TokensUsed: completion.TokensUsed,
+			Cost:       completion.Cost,
+			Success:    true,
+			GoalID:     goalID,
+		}); err != nil {
+			fmt.Printf("Warning: failed to record chat cost: %v\n", err)
+		}
+		return strings.TrimSpace(completion.Text), nil
+	}
+
+	return core.NewIntentDispatcher(registry, classifier, chat), nil
+}
+
+// goalChatPrompt tells chat about the current goal and what is known about
+// the user that bears on it. Without a goal there is nothing to tell.
+func (cli *CLI) goalChatPrompt(ctx context.Context, goalID string) string {
+	if goalID == "" {
+		return ""
+	}
+	goal, err := cli.goalManager.GetGoal(ctx, goalID)
+	if err != nil {
+		return ""
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "The user is working on the goal %q.", goal.Title)
+	if goal.Description != "" {
+		fmt.Fprintf(&prompt, " %s", goal.Description)
+	}
+	if cli.contextManager != nil {
+		contexts, err := cli.contextManager.GetRelevantContext(ctx, goal.Title+"\n"+goal.Description, cli.config.Session.UserID, 5)
+		if err == nil && len(contexts) > 0 {
+			prompt.WriteString("\n\nWhat you know about the user:")
+			for _, entry := range contexts {
+				fmt.Fprintf(&prompt, "\n- %s", entry.Content)
+			}
+		}
+	}
+	return prompt.String()
+}
+
+// handleIntentTurn handles a free-form line, asking before anything changes.
+func (cli *CLI) handleIntentTurn(dispatcher *core.IntentDispatcher, reader *bufio.Reader, input string) error {
+	confirm := func(operation *core.IntentOperation) bool {
+		fmt.Printf("📝 %s\n", operation.Summary)
+		answer, err := readConfirmation(reader, "Proceed? [y/N] ")
+		return err == nil && answer
+	}
+
+	turn, err := dispatcher.HandleTurn(cli.turnContext(), input, confirm)
+	if err != nil {
+		return err
+	}
+
+	if cli.config.Preferences.VerboseOutput && turn.FallbackReason != "" {
+		fmt.Printf("(answering as chat: %s)\n", turn.FallbackReason)
+	}
+	fmt.Println(turn.Response)
+	return nil
+}
+
+// readConfirmation reads a yes/no answer from the interactive reader.
+func readConfirmation(reader *bufio.Reader, prompt string) (bool, error) {
+	fmt.Print(prompt)
+	line, _, err := reader.ReadLine()
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(line))) {
+	case "y", "yes":
+		return true, nil
 	default:
-		return fmt.Errorf("unknown config key: %s", key)
+		return false, nil
+	}
+}
+
+// runSelfTest runs the offline self-test scenario and prints a checklist.
+// It uses a temporary data directory, never the configured store.
+func (cli *CLI) runSelfTest(args []string) error {
+	fmt.Println("🧪 AI Work Studio Self-Test")
+	fmt.Println()
+
+	report, err := selftest.Run(context.Background(), selftest.DefaultOptions())
+	if err != nil {
+		return fmt.Errorf("failed to run self-test: %w", err)
+	}
+
+	report.Print(os.Stdout)
+
+	if !report.Passed() {
+		return fmt.Errorf("self-test failed: %d of %d stages failed", len(report.Failures()), len(report.Stages))
+	}
+	return nil
+}
+
+// showHelp displays help information.
+func (cli *CLI) showHelp(args []string) error {
+	if len(args) > 0 {
+		// Show help for specific command
+		commandName := args[0]
+		command, exists := getCommands()[commandName]
+		if !exists {
+			return fmt.Errorf("unknown command: %s", commandName)
+		}
+
+		fmt.Printf("Command: %s\n", command.Name)
+		fmt.Printf("Description: %s\n", command.Description)
+		fmt.Printf("Usage: %s\n", command.Usage)
+		return nil
+	}
+
+	// Show general help
+	fmt.Println("🎯 AI Work Studio - Command Line Interface")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  ai-work-studio [global-options] <command> [command-options]")
+	fmt.Println()
+	fmt.Println("GLOBAL OPTIONS:")
+	fmt.Println("  -config <path>    Configuration file path")
+	fmt.Println("  -data <path>      Data directory path")
+	fmt.Println("  -verbose          Enable verbose output")
+	fmt.Println("  -json             Report errors as JSON on stdout")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+
+	// Display commands in a table
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "  Command\tDescription")
+	fmt.Fprintln(w, "  -------\t-----------")
+
+	for _, command := range getCommands() {
+		fmt.Fprintf(w, "  %s\t%s\n", command.Name, command.Description)
+	}
+
+	fmt.Println()
+	fmt.Println("Use 'help <command>' for detailed information about a specific command.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  ai-work-studio create-goal \"Learn Go programming\" \"Master Go for backend development\" 8")
+	fmt.Println("  ai-work-studio list-goals active")
+	fmt.Println("  ai-work-studio status")
+	fmt.Println("  ai-work-studio interactive")
+
+	return nil
+}
+// printCompletion prints the completion script for a shell.
+func (cli *CLI) printCompletion(args []string) error {
+	return printCompletionScript(args)
+}
+
+// printCompletionScript prints the completion script for the shell in args.
+// It needs no CLI, so main runs it before setting up storage.
+func printCompletionScript(args []string) error {
+	if len(args) != 1 {
+		return errs.Newf(errs.Validation, "usage: completion <%s>", strings.Join(completion.Shells, "|"))
+	}
+	script, err := completion.Script(args[0], filepath.Base(os.Args[0]))
+	if err != nil {
+		return err
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// resolveID expands an ID prefix typed by the user to the full ID of an
+// entity of the given kind. A prefix matching several entities is an error
+// listing them; an argument matching none is returned unchanged.
+func (cli *CLI) resolveID(kind completion.ArgKind, arg string) (string, error) {
+	candidates, err := completion.NewStoreSource(cli.store).Candidates(context.Background(), kind)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s IDs: %w", kind, err)
+	}
+	return completion.Resolve(candidates, arg)
+}
+
+// countTokens counts the tokens in a file as the router would for a model.
+func (cli *CLI) countTokens(args []string) error {
+	const usage = "usage: tokens count --model <model> <file>"
+	if len(args) < 1 || args[0] != "count" {
+		return errs.New(errs.Validation, usage)
+	}
+
+	flags := flag.NewFlagSet("tokens count", flag.ContinueOnError)
+	model := flags.String("model", "", "Model whose tokenizer to use")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if *model == "" || flags.NArg() != 1 {
+		return errs.New(errs.Validation, usage)
+	}
+
+	text, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", flags.Arg(0), err)
+	}
+
+	estimator := llm.NewTokenEstimator(tokenizerConfig(cli.config))
+	if _, _, err := estimator.Tokenizer(*model); err != nil && !errors.Is(err, llm.ErrNoVocabulary) {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	estimate := estimator.Estimate(*model, string(text))
+
+	accuracy := "approximate"
+	if estimate.Exact {
+		accuracy = "exact"
+	}
+	fmt.Printf("%d tokens (%s, %s)\n", estimate.Tokens, estimate.Tokenizer, accuracy)
+	return nil
+}
+
+// previewRoute prints the routing decision for a prompt: the task
+// assessment, the three best-scoring models with their scores broken down,
+// and the request the selected model would be sent. Nothing is sent.
+func (cli *CLI) previewRoute(args []string) error {
+	const usage = "usage: route-preview [--task-type type] [--policy name] <prompt>"
+	flags := flag.NewFlagSet("route-preview", flag.ContinueOnError)
+	taskType := flags.String("task-type", "", "Type of task, e.g. analysis, generation or qa")
+	policyName := flags.String("policy", "", "Routing policy: cost-saver, balanced or quality-first")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() == 0 {
+		return errs.New(errs.Validation, usage)
+	}
+	policy, err := llm.ParseRoutingPolicy(*policyName)
+	if err != nil {
+		return err
+	}
+
+	plan, err := cli.llmRouter.RoutePlan(context.Background(), llm.TaskRequest{
+		Prompt:   strings.Join(flags.Args(), " "),
+		TaskType: *taskType,
+		Policy:   policy,
+	})
+	if err != nil {
+		return err
+	}
+
+	if policy == llm.PolicyDefault {
+		policy = cli.llmRouter.Policy()
+	}
+	fmt.Printf("Policy: %s\n", policy)
+	fmt.Printf("Assessment: %s\n", plan.Assessment.Reasoning)
+	fmt.Println()
+	fmt.Println("Top models:")
+	ranked := append([]llm.ModelRecommendation{plan.SelectedModel}, plan.AlternativeModels...)
+	if len(ranked) > 3 {
+		ranked = ranked[:3]
+	}
+	for i, model := range ranked {
+		parts := model.Components
+		fmt.Printf("  %d. %s/%s  score %.3f = quality %.3f + cost %.3f + speed %.3f",
+			i+1, model.Provider, model.Model, model.OverallScore, parts.Quality, parts.Cost, parts.Speed)
+		if parts.Penalty > 0 {
+			fmt.Printf(" - penalty %.3f", parts.Penalty)
+		}
+		fmt.Printf("  ($%.4f)\n", model.EstimatedCost)
+	}
+	for _, excluded := range plan.ExcludedModels {
+		fmt.Printf("  skipped %s/%s: %s\n", excluded.Model.Provider, excluded.Model.Model, excluded.Reason)
+	}
+
+	fmt.Println()
+	fmt.Println("Would send:")
+	keys := make([]string, 0, len(plan.PlannedParams))
+	for key := range plan.PlannedParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %s: %v\n", key, plan.PlannedParams[key])
+	}
+	fmt.Println("Dry run: nothing was sent.")
+	return nil
+}
+
+// startObjective starts a pending objective, or resumes a paused one, within
+// the work-in-progress limits unless --override-wip is given.
+func (cli *CLI) startObjective(args []string) error {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, "usage: start-objective <objective-id> [--override-wip] [--reason text]")
+	}
+	objectiveID, err := cli.resolveID(completion.ArgObjective, args[0])
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("start-objective", flag.ContinueOnError)
+	override := flags.Bool("override-wip", false, "Start even if a work-in-progress limit is reached")
+	reason := flags.String("reason", "", "Why the limit is overridden, recorded with the objective")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+
+	ctx := context.Background()
+	objective, err := cli.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return fmt.Errorf("objective not found: %w", err)
+	}
+
+	opts := core.StartOptions{OverrideWIPLimit: *override, Reason: *reason}
+	if objective.Status == core.ObjectiveStatusPaused {
+		objective, err = cli.objectiveManager.ResumeObjective(ctx, objectiveID, opts)
+	} else {
+		objective, err = cli.objectiveManager.StartObjective(ctx, objectiveID, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("▶️  Started objective: %s\n", objective.Title)
+	if _, overridden := objective.Context["wip_override"]; overridden {
+		fmt.Println("⚠️  Work-in-progress limit overridden; the exception is recorded with the objective")
+	}
+	return nil
+}
+
+// runObjective plans and executes an objective through the learning loop,
+// using the configured LLM providers. Progress is printed as tasks run, and
+// tasks with side effects are put to the user for approval where the ethical
+// framework asks for it. The objective is completed with the outcome.
+func (cli *CLI) runObjective(args []string) error {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, "usage: run-objective <objective-id>")
+	}
+	objectiveID, err := cli.resolveID(completion.ArgObjective, args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx := cli.turnContext()
+	objective, err := cli.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return fmt.Errorf("objective not found: %w", err)
+	}
+
+	// Without a provider nothing can run; say so before starting anything
+	router := cli.providerRouter()
+	if err := router.Ready(); err != nil {
+		return err
+	}
+
+	switch objective.Status {
+	case core.ObjectiveStatusCompleted, core.ObjectiveStatusFailed, core.ObjectiveStatusCancelled:
+		return fmt.Errorf("objective %s is already %s", objective.ID, objective.Status)
+	case core.ObjectiveStatusPaused:
+		return fmt.Errorf("objective %s is paused; resume it with start-objective or time-box first", objective.ID)
+	case core.ObjectiveStatusPending:
+		// The learning loop itself continues an objective stopped by its time box
+		if objective.TimeBoxStop() == nil {
+			if objective, err = cli.objectiveManager.StartObjective(ctx, objectiveID); err != nil {
+				return err
+			}
+		}
+	}
+
+	ethics := core.NewEthicalFramework(cli.store, router, cli.contextManager)
+	executor, loop, err := cli.newLearningLoop(router)
+	if err != nil {
+		return err
+	}
+	executor.SetEthicalReview(ethics, cli.config.Session.UserID, promptApproval)
+
+	fmt.Printf("🚀 Running objective: %s\n", objective.Title)
+	result, err := loop.ExecuteObjective(ctx, objectiveID)
+	if err != nil {
+		return err
+	}
+
+	switch result.FinalOutcome {
+	case core.OutcomeDeferred:
+		fmt.Println("⏸️  Not started: a work-in-progress limit is reached. See: start-objective --override-wip")
+		return nil
+	case core.OutcomeTimeBoxed:
+		fmt.Println("⏱️  The objective ran out of its time box. See: time-box " + objectiveID)
+		return nil
+	}
+
+	if objective, err = cli.objectiveManager.RecordLearningResult(ctx, result); err != nil {
+		return err
+	}
+
+	if result.WasSuccessful {
+		fmt.Printf("\n✅ Objective completed: %s\n", objective.Title)
+	} else {
+		fmt.Printf("\n❌ Objective failed: %s\n", objective.Title)
+	}
+	fmt.Printf("  Outcome:  %s\n", result.Summary())
+	fmt.Printf("  Tokens:   %d\n", result.GetTotalTokensUsed())
+	fmt.Printf("  Duration: %s\n", formatDuration(result.TotalDuration))
+	for _, attempt := range result.ExecutionAttempts {
+		line := fmt.Sprintf("  Attempt %d: %d/%d tasks succeeded", attempt.AttemptNumber,
+			attempt.ExecutionResult.SuccessfulTasks, attempt.ExecutionResult.SuccessfulTasks+attempt.ExecutionResult.FailedTasks)
+		if attempt.MethodRating > 0 {
+			line += fmt.Sprintf(", method %s rated %.1f/10", attempt.MethodID, attempt.MethodRating)
+		}
+		if attempt.RefinementApplied {
+			line += ", method refined"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// newLearningLoop assembles the learning loop objectives run through, with
+// the web tools, plan validation and the configured limits. The executor's
+// ethical review is left for the caller to set.
+func (cli *CLI) newLearningLoop(router *llm.Router) (*core.RouterTaskExecutor, *core.LearningLoop, error) {
+	executor := core.NewRouterTaskExecutor(router)
+	tools := mcp.NewServiceRegistry(log.New(io.Discard, "", 0))
+	tools.RegisterMiddleware(mcp.RecoveryMiddleware(nil))
+	if err := tools.RegisterService(mcp.NewWebService(mcp.WebConfig{Search: mcp.SearchBackendFromEnv()}, nil)); err != nil {
+		return nil, nil, fmt.Errorf("failed to register the web service: %w", err)
+	}
+	executor.SetTools(tools)
+	loader := core.NewStoreContextLoader(cli.store)
+
+	rtc := core.NewRealTimeCursor(cli.store, &progressExecutor{TaskExecutor: executor, verbose: cli.config.Preferences.VerboseOutput}, loader)
+	rtc.SetPlanValidator(core.NewPlanValidator(cli.store, executor))
+	cc := core.NewContemplativeCursor(cli.store, core.NewRouterReasoner(router))
+	cc.SetContextLoader(loader)
+	loop := core.NewLearningLoop(cli.store, cc, rtc, core.NewRouterLearningAgent(router))
+	loop.SetWIPLimits(wipLimits(cli.config))
+	loop.SetTimeBoxes(timeBoxes(cli.config))
+	return executor, loop, nil
+}
+
+// progressExecutor prints each task as it starts and finishes, with its
+// full output when verbose.
+type progressExecutor struct {
+	core.TaskExecutor
+	verbose bool
+}
+
+// ExecuteTask implements core.TaskExecutor.
+func (e *progressExecutor) ExecuteTask(ctx context.Context, task *core.ExecutionTask, fullContext map[string]interface{}) (*core.TaskResult, error) {
+	fmt.Printf("  ▶ %s: %s\n", task.ID, task.Description)
+	result, err := e.TaskExecutor.ExecuteTask(ctx, task, fullContext)
+	if err != nil {
+		fmt.Printf("  ✗ %s failed: %v\n", task.ID, err)
+		return result, err
+	}
+	if result.RoutingID != "" {
+		fmt.Printf("  ✓ %s completed (%d tokens) · rate with: rate %s <1-10>\n", task.ID, result.TokensUsed, result.RoutingID)
+	} else {
+		fmt.Printf("  ✓ %s completed (%d tokens)\n", task.ID, result.TokensUsed)
+	}
+	if e.verbose {
+		fmt.Printf("%v\n\n", result.Output)
+	}
+	return result, nil
+}
+
+// promptApproval shows a decision awaiting approval and asks the user to
+// approve or reject it.
+func promptApproval(ctx context.Context, decision *core.EthicalDecision) (bool, string, error) {
+	fmt.Printf("\n⚖️  Approval needed (%s urgency): %s\n", decision.Urgency, decision.DecisionContext)
+	fmt.Printf("  Action: %s\n", decision.ProposedAction)
+	fmt.Printf("  Impact scores: Freedom=%.1f, Well-being=%.1f, Sustainability=%.1f\n",
+		decision.Impact.FreedomImpact, decision.Impact.WellBeingImpact, decision.Impact.SustainabilityImpact)
+	if decision.Impact.Reasoning != "" {
+		fmt.Printf("  Reasoning: %s\n", decision.Impact.Reasoning)
+	}
+
+	for {
+		answer, err := readUserInput("Approve or reject? [approve/reject]: ")
+		if err != nil {
+			return false, "", err
+		}
+		switch strings.ToLower(answer) {
+		case "approve", "a", "yes", "y":
+			feedback, err := readUserInput("Feedback (optional): ")
+			if err != nil {
+				return false, "", err
+			}
+			if feedback == "" {
+				feedback = "Approved via CLI"
+			}
+			return true, feedback, nil
+		case "reject", "r", "no", "n":
+			feedback, err := readUserInput("Reason (optional): ")
+			if err != nil {
+				return false, "", err
+			}
+			if feedback == "" {
+				feedback = "Rejected via CLI"
+			}
+			return false, feedback, nil
+		}
+		fmt.Println("Please answer approve or reject.")
+	}
+}
+
+// manageTimeBox shows or changes an objective's time box, lists the
+// objectives paused by their time box, and extends, resumes or abandons them.
+func (cli *CLI) manageTimeBox(args []string) error {
+	usage := "usage: time-box [list | <objective-id> [set <duration>|clear|extend <duration>|resume|abandon]]"
+	ctx := context.Background()
+	if len(args) == 0 || args[0] == "list" {
+		return cli.listTimeBoxed(ctx)
+	}
+
+	objectiveID, err := cli.resolveID(completion.ArgObjective, args[0])
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		return cli.showTimeBox(ctx, objectiveID)
+	}
+
+	action := args[1]
+	var duration time.Duration
+	if action == "set" || action == "extend" {
+		if len(args) < 3 {
+			return errs.New(errs.Validation, usage)
+		}
+		duration, err = time.ParseDuration(args[2])
+		if err != nil || duration <= 0 {
+			return errs.Newf(errs.Validation, "invalid duration %q, e.g. 90m or 2h", args[2])
+		}
+	}
+
+	switch action {
+	case "set", "clear":
+		objective, err := cli.objectiveManager.UpdateObjective(ctx, objectiveID, core.ObjectiveUpdates{MaxExecutionTime: &duration})
+		if err != nil {
+			return fmt.Errorf("failed to set time box: %w", err)
+		}
+		if limit := cli.objectiveManager.TimeBoxes().For(objective); limit > 0 {
+			fmt.Printf("✓ Time box for %s: %s\n", objective.Title, limit)
+		} else {
+			fmt.Printf("✓ No time box for %s\n", objective.Title)
+		}
+	case "extend", "resume", "abandon":
+		resolution, err := core.ParseTimeBoxResolution(action)
+		if err != nil {
+			return err
+		}
+		objective, err := cli.objectiveManager.ResolveTimeBox(ctx, objectiveID, resolution, duration)
+		if err != nil {
+			return err
+		}
+		switch resolution {
+		case core.TimeBoxExtend:
+			fmt.Printf("✓ Extended %s to %s; it continues from its checkpoint\n", objective.Title, objective.MaxExecutionTime)
+		case core.TimeBoxResume:
+			fmt.Printf("✓ Resuming %s from its checkpoint with a fresh time box\n", objective.Title)
+		default:
+			fmt.Printf("✓ Abandoned %s\n", objective.Title)
+		}
+	default:
+		return errs.New(errs.Validation, usage)
+	}
+	return nil
+}
+
+// showTimeBox prints an objective's time box and the time it has used.
+func (cli *CLI) showTimeBox(ctx context.Context, objectiveID string) error {
+	objective, err := cli.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return fmt.Errorf("objective not found: %w", err)
+	}
+
+	fmt.Printf("⏱️  %s (%s)\n", objective.Title, objective.Status)
+	switch limit := cli.objectiveManager.TimeBoxes().For(objective); {
+	case objective.MaxExecutionTime > 0:
+		fmt.Printf("   Time box:      %s\n", limit)
+	case limit > 0:
+		fmt.Printf("   Time box:      %s (default for priority %d)\n", limit, objective.Priority)
+	default:
+		fmt.Println("   Time box:      none")
+	}
+	fmt.Printf("   Active:        %s\n", formatDuration(objective.ActiveExecutionTime))
+	fmt.Printf("   Approval wait: %s\n", formatDuration(objective.ApprovalWaitTime))
+	if stop := objective.TimeBoxStop(); stop != nil && objective.IsPaused() {
+		fmt.Printf("   Paused %s after %s", formatTime(stop.At), formatDuration(stop.Elapsed))
+		if stop.TaskID != "" {
+			fmt.Printf(" at task %s", stop.TaskID)
+		}
+		fmt.Printf("\n   Continue with: time-box %s extend <duration> | resume | abandon\n", objective.ID)
+	}
+	return nil
+}
+
+// listTimeBoxed lists the objectives paused by their time box.
+func (cli *CLI) listTimeBoxed(ctx context.Context) error {
+	stopped, err := cli.objectiveManager.TimeBoxedObjectives(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list time-boxed objectives: %w", err)
+	}
+	if len(stopped) == 0 {
+		fmt.Println("No objectives are waiting after exceeding their time box.")
+		return nil
+	}
+
+	fmt.Printf("⏱️  Paused by their time box (%d):\n", len(stopped))
+	for _, objective := range stopped {
+		stop := objective.TimeBoxStop()
+		fmt.Printf("  %s  %s — %s of %s, %s\n", objective.ID, objective.Title,
+			formatDuration(stop.Elapsed), stop.Limit, formatTime(stop.At))
+	}
+	fmt.Println("\nContinue one with: time-box <objective-id> extend <duration> | resume | abandon")
+	return nil
+}
+
+// writeBrief writes an objective's delegation brief to stdout or to the --out
+// file, exporting its file attachments alongside, and optionally records who
+// the objective was delegated to.
+func (cli *CLI) writeBrief(args []string) error {
+	usage := "usage: brief <objective-id> [--out file] [--format markdown|json] [--audience text] [--no-context] [--polish] [--delegate-to name [--note text]] [--undelegate]"
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return errs.New(errs.Validation, usage)
+	}
+	objectiveID, err := cli.resolveID(completion.ArgObjective, args[0])
+	if err != nil {
+		return err
+	}
+
+	opts := core.DefaultBriefOptions()
+	flags := flag.NewFlagSet("brief", flag.ContinueOnError)
+	outPath := flags.String("out", "", "Write the brief to this file instead of stdout")
+	format := flags.String("format", string(core.BriefFormatMarkdown), "Output format: markdown or json")
+	audience := flags.String("audience", opts.Audience, "Who the brief is written for")
+	noContext := flags.Bool("no-context", false, "Leave out the objective's resolved context")
+	polish := flags.Bool("polish", false, "Open the brief with an LLM-written summary")
+	delegateTo := flags.String("delegate-to", "", "Mark the objective as delegated to this person or tool")
+	note := flags.String("note", "", "A note recorded with the delegation")
+	undelegate := flags.Bool("undelegate", false, "Take the objective back so autonomous sessions may select it again")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if *delegateTo != "" && *undelegate {
+		return fmt.Errorf("--delegate-to and --undelegate cannot be combined")
+	}
+
+	opts.Format = core.BriefFormat(*format)
+	opts.Audience = *audience
+	opts.IncludeContext = !*noContext
+	opts.UserID = cli.config.Session.UserID
+	opts.Polish = *polish
+	opts.Router = cli.llmRouter
+	if *polish {
+		if opts.Budget, err = cli.budgetManager(); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	brief, err := cli.objectiveManager.GenerateBrief(ctx, objectiveID, opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate brief: %w", err)
+	}
+
+	// Only record the hand-over once the brief exists
+	if *delegateTo != "" {
+		objective, err := cli.objectiveManager.MarkDelegated(ctx, objectiveID, *delegateTo, *note)
+		if err != nil {
+			return fmt.Errorf("failed to mark objective as delegated: %w", err)
+		}
+		brief.Delegation = objective.Delegation()
+		brief.Delegation.Note = llm.RedactSecrets(brief.Delegation.Note)
+	}
+	if *undelegate {
+		if _, err := cli.objectiveManager.ClearDelegation(ctx, objectiveID); err != nil {
+			return fmt.Errorf("failed to clear delegation: %w", err)
+		}
+		brief.Delegation = nil
+	}
+
+	if brief.PolishError != nil {
+		fmt.Fprintf(os.Stderr, "Warning: summary left out: %v\n", brief.PolishError)
+	}
+	if *undelegate {
+		fmt.Fprintln(os.Stderr, "↩️  Delegation cleared; autonomous sessions may select the objective again")
+	}
+	if *delegateTo != "" {
+		fmt.Fprintf(os.Stderr, "🤝 Delegated to %s; autonomous sessions will skip this objective\n", brief.Delegation.To)
+	}
+
+	if *outPath == "" {
+		if len(brief.Attachments) > 0 {
+			fmt.Fprintln(os.Stderr, "Note: attachments are only exported with --out")
+		}
+		_, err := brief.WriteTo(os.Stdout)
+		return err
+	}
+
+	file, err := os.Create(*outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create brief file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := brief.WriteTo(file); err != nil {
+		return fmt.Errorf("failed to write brief: %w", err)
+	}
+
+	attachmentDir := strings.TrimSuffix(*outPath, filepath.Ext(*outPath)) + "-attachments"
+	exported, err := brief.ExportAttachments(attachmentDir)
+	if err != nil {
+		return fmt.Errorf("failed to export attachments: %w", err)
+	}
+
+	fmt.Printf("✅ Brief written to %s\n", *outPath)
+	if len(exported) > 0 {
+		fmt.Printf("   %d attachment(s) exported to %s\n", len(exported), attachmentDir)
+	}
+	if brief.Summary != "" {
+		fmt.Printf("   Summary cost: $%.4f\n", brief.PolishCost)
 	}
 	return nil
 }
 
-// setConfigValue updates a configuration value.
-func (cli *CLI) setConfigValue(key, value string) error {
-	switch key {
-	case "daily-limit":
-		limit, err := strconv.ParseFloat(value, 64)
+// suggestNext ranks the objectives that could be started now. Weights given
+// with --weights preview a change for this run only unless --save-weights is set.
+func (cli *CLI) suggestNext(args []string) error {
+	usage := "usage: next [--time duration] [--energy low|normal|high] [--max n] [--weights factor=weight,...] [--save-weights] [--explain] [--tie-break]"
+	flags := flag.NewFlagSet("next", flag.ContinueOnError)
+	available := flags.Duration("time", 0, "How long you have (e.g. 30m); favors objectives expected to fit")
+	energy := flags.String("energy", string(core.EnergyNormal), "Energy level: low favors short work, high favors important work")
+	maxSuggestions := flags.Int("max", core.DefaultSuggestOptions().MaxSuggestions, "Show at most this many suggestions")
+	weightSpec := flags.String("weights", "", "Override factor weights, e.g. priority=0.4,due=0.1")
+	saveWeights := flags.Bool("save-weights", false, "Save the --weights overrides to the configuration")
+	explain := flags.Bool("explain", false, "Show each suggestion's score breakdown")
+	tieBreak := flags.Bool("tie-break", false, "Ask an LLM to order the top three (capped cost)")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 || *available < 0 || *maxSuggestions < 0 {
+		return errs.New(errs.Validation, usage)
+	}
+	if *saveWeights && *weightSpec == "" {
+		return errs.New(errs.Validation, "--save-weights needs --weights")
+	}
+
+	opts := core.DefaultSuggestOptions()
+	opts.AvailableTime = *available
+	opts.MaxSuggestions = *maxSuggestions
+	level, err := core.ParseEnergyLevel(*energy)
+	if err != nil {
+		return err
+	}
+	opts.EnergyLevel = level
+	if *weightSpec != "" {
+		weights, err := core.ParseSuggestionWeights(cli.objectiveManager.SuggestionWeights(), *weightSpec)
 		if err != nil {
-			return fmt.Errorf("invalid daily limit: %s", value)
+			return err
 		}
-		updates := config.BudgetUpdates{DailyLimit: &limit}
-		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+		opts.Weights = &weights
+	}
+	if *tieBreak {
+		opts.TieBreak = true
+		opts.Router = cli.llmRouter
+		if opts.Budget, err = cli.budgetManager(); err != nil {
+			return err
+		}
+	}
 
-	case "monthly-limit":
-		limit, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return fmt.Errorf("invalid monthly limit: %s", value)
+	report, err := cli.objectiveManager.SuggestNext(context.Background(), opts)
+	if err != nil {
+		return fmt.Errorf("failed to suggest objectives: %w", err)
+	}
+
+	if *saveWeights {
+		updates := config.PreferenceUpdates{SuggestionWeights: opts.Weights.Map()}
+		if err := cli.config.UpdatePreferences(cli.configPath, updates); err != nil {
+			return fmt.Errorf("failed to save suggestion weights: %w", err)
 		}
-		updates := config.BudgetUpdates{MonthlyLimit: &limit}
-		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+		cli.objectiveManager.SetSuggestionWeights(*opts.Weights)
+		fmt.Printf("✅ Saved suggestion weights: %s\n\n", formatSuggestionWeights(*opts.Weights))
+	}
 
-	case "per-request-limit":
-		limit, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return fmt.Errorf("invalid per-request limit: %s", value)
+	printSuggestions(report, opts, *explain || *weightSpec != "")
+	return nil
+}
+
+// printSuggestions prints a suggestion report, with the score breakdowns when explain is set.
+func printSuggestions(report *core.SuggestionReport, opts core.SuggestOptions, explain bool) {
+	situation := fmt.Sprintf("%s energy", opts.EnergyLevel)
+	if opts.AvailableTime > 0 {
+		situation = fmt.Sprintf("%s available, %s", formatDuration(opts.AvailableTime), situation)
+	}
+	fmt.Printf("🧭 Suggested next (%s)\n", situation)
+	if explain {
+		fmt.Printf("   Weights: %s\n", formatSuggestionWeights(report.Weights))
+	}
+	fmt.Println()
+
+	if len(report.Suggestions) == 0 {
+		fmt.Println("Nothing can be started right now.")
+	}
+	for i, suggestion := range report.Suggestions {
+		fmt.Printf("%d. %s  [%.2f]\n", i+1, suggestion.Objective.Title, suggestion.Score)
+		goal := "unknown goal"
+		if suggestion.Goal != nil {
+			goal = suggestion.Goal.Title
 		}
-		updates := config.BudgetUpdates{PerRequestLimit: &limit}
-		return cli.config.UpdateBudgetLimits(cli.configPath, updates)
+		fmt.Printf("   %s · goal %s", suggestion.Objective.ID, goal)
+		if suggestion.Estimate > 0 {
+			fmt.Printf(" · about %s", formatDuration(suggestion.Estimate))
+		}
+		fmt.Println()
+		fmt.Printf("   Why: %s\n", suggestion.Explain())
+		if explain {
+			for _, component := range suggestion.Components {
+				known := ""
+				if !component.Known {
+					known = " (no data)"
+				}
+				fmt.Printf("     %-13s %.2f × %.2f = %.3f  %s%s\n", component.Factor, component.Weight, component.Value,
+					component.Contribution, component.Detail, known)
+			}
+		}
+	}
 
-	case "auto-approve":
-		autoApprove, err := strconv.ParseBool(value)
-		if err != nil {
-			return fmt.Errorf("invalid boolean value: %s", value)
+	if report.Blocked > 0 || report.Deferred > 0 {
+		fmt.Printf("\nNot shown: %d blocked by dependencies or an inactive goal, %d held back by work-in-progress limits\n", report.Blocked, report.Deferred)
+	}
+	switch {
+	case report.TieBroken:
+		fmt.Printf("\nTop suggestions ordered by LLM tie-break (cost $%.4f)\n", report.TieBreakCost)
+	case report.TieBreakError != "":
+		fmt.Fprintf(os.Stderr, "Warning: tie-break not applied: %s\n", report.TieBreakError)
+	}
+}
+
+// formatSuggestionWeights lists the weights as factor=weight pairs.
+func formatSuggestionWeights(weights core.SuggestionWeights) string {
+	pairs := make([]string, 0, len(core.SuggestionFactors))
+	for _, factor := range core.SuggestionFactors {
+		weight, _ := weights.Get(factor)
+		pairs = append(pairs, fmt.Sprintf("%s=%g", factor, weight))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// showWIPStatus prints work in progress against the limits, warning near the cap.
+func (cli *CLI) showWIPStatus(ctx context.Context) error {
+	wip, err := cli.objectiveManager.WIPStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get work in progress: %w", err)
+	}
+
+	if wip.Total.Limit > 0 {
+		fmt.Printf("   WIP: %d/%d%s\n", wip.Total.InProgress, wip.Total.Limit, wipWarning(wip.Total))
+	}
+
+	goalIDs := make([]string, 0, len(wip.Goals))
+	for goalID, usage := range wip.Goals {
+		if usage.NearLimit() {
+			goalIDs = append(goalIDs, goalID)
 		}
-		updates := config.PreferenceUpdates{AutoApprove: &autoApprove}
-		return cli.config.UpdatePreferences(cli.configPath, updates)
+	}
+	sort.Strings(goalIDs)
+	for _, goalID := range goalIDs {
+		title := goalID
+		if goal, err := cli.goalManager.GetGoal(ctx, goalID); err == nil {
+			title = goal.Title
+		}
+		usage := wip.Goals[goalID]
+		fmt.Printf("   %s: %d/%d%s\n", title, usage.InProgress, usage.Limit, wipWarning(usage))
+	}
+	return nil
+}
 
-	case "verbose-output":
-		verboseOutput, err := strconv.ParseBool(value)
-		if err != nil {
-			return fmt.Errorf("invalid boolean value: %s", value)
+// wipWarning marks usage at or one short of its limit.
+func wipWarning(usage core.WIPUsage) string {
+	switch {
+	case usage.AtLimit():
+		return "  🛑 at limit, pause an objective before starting another"
+	case usage.NearLimit():
+		return "  ⚠️  near limit"
+	default:
+		return ""
+	}
+}
+
+// manageProviders shows where each provider's API key comes from, checks the
+// keys against the providers, or replaces a key stored in the configuration.
+func (cli *CLI) manageProviders(args []string) error {
+	const usage = "usage: providers [list|check [provider]|set-key <provider>|diagnose]"
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "list":
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "PROVIDER\tKEY SOURCE")
+		for _, provider := range llm.CredentialProviders {
+			fmt.Fprintf(writer, "%s\t%s\n", provider, cli.keySource(provider))
 		}
-		updates := config.PreferenceUpdates{VerboseOutput: &verboseOutput}
-		return cli.config.UpdatePreferences(cli.configPath, updates)
+		return writer.Flush()
 
-	case "default-priority":
-		priority, err := strconv.Atoi(value)
+	case "check":
+		providers := llm.CredentialProviders
+		if len(args) > 1 {
+			provider, err := credentialProvider(args[1])
+			if err != nil {
+				return err
+			}
+			providers = []string{provider}
+		}
+		router := cli.providerRouter()
+		for _, provider := range providers {
+			if cli.keySource(provider) == "not configured" {
+				fmt.Printf("%s: no API key configured\n", provider)
+				continue
+			}
+			cli.reportProbe(router, provider)
+		}
+		return nil
+
+	case "diagnose":
+		diagnosis, err := cli.providerRouter().Diagnose(context.Background())
 		if err != nil {
-			return fmt.Errorf("invalid priority: %s", value)
+			return err
 		}
-		updates := config.PreferenceUpdates{DefaultPriority: &priority}
-		return cli.config.UpdatePreferences(cli.configPath, updates)
+		printProviderDiagnosis(diagnosis)
+		return nil
 
-	case "interactive-mode":
-		interactiveMode, err := strconv.ParseBool(value)
+	case "set-key":
+		if len(args) < 2 {
+			return errs.New(errs.Validation, usage)
+		}
+		provider, err := credentialProvider(args[1])
 		if err != nil {
-			return fmt.Errorf("invalid boolean value: %s", value)
+			return err
+		}
+		if cli.credentials().Resolve(provider).Source == mcp.CredentialSourceKeyring {
+			return fmt.Errorf("the %s key comes from the OS keyring, which overrides the configuration; use 'config set-credential %s' instead",
+				provider, provider)
 		}
-		updates := config.PreferenceUpdates{InteractiveMode: &interactiveMode}
-		return cli.config.UpdatePreferences(cli.configPath, updates)
 
-	case "current-goal-id":
-		updates := config.SessionUpdates{CurrentGoalID: &value}
-		return cli.config.UpdateSession(cli.configPath, updates)
+		key, err := readUserInput(fmt.Sprintf("New %s API key: ", provider))
+		if err != nil {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+		updates := config.APIKeyUpdates{}
+		if provider == "anthropic" {
+			updates.Anthropic = &key
+		} else {
+			updates.OpenAI = &key
+		}
+		if err := cli.config.UpdateAPIKeys(cli.configPath, updates); err != nil {
+			return fmt.Errorf("failed to save key: %w", err)
+		}
+		fmt.Printf("Saved the %s key to %s\n", provider, cli.configPath)
 
-	case "user-id":
-		updates := config.SessionUpdates{UserID: &value}
-		return cli.config.UpdateSession(cli.configPath, updates)
+		// Re-probe so a rejected key is caught now rather than mid-task
+		cli.reportProbe(cli.providerRouter(), provider)
+		return nil
 
 	default:
-		return fmt.Errorf("unknown or read-only config key: %s", key)
+		return errs.New(errs.Validation, usage)
 	}
 }
 
-// interactiveMode enters conversation-like interactive mode.
-func (cli *CLI) interactiveMode(args []string) error {
-	fmt.Println("🤖 AI Work Studio - Interactive Mode")
-	fmt.Println("Type 'help' for commands, 'exit' to quit")
-	fmt.Println()
+// printProviderDiagnosis shows which providers were set up, from what, and
+// why the others were skipped.
+func printProviderDiagnosis(diagnosis *mcp.ProviderDiagnosis) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PROVIDER\tSTATUS\tSOURCE\tCHECKED\tNOTE")
+	for _, status := range diagnosis.Providers {
+		state, source := "skipped", "-"
+		if status.Available {
+			state, source = "available", status.Source
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", status.Provider, state, source, strings.Join(status.Checked, ", "), status.Reason)
+	}
+	writer.Flush()
 
-	reader := bufio.NewReader(os.Stdin)
+	if !diagnosis.Ready {
+		fmt.Printf("\n⚠️  No LLM providers are configured. To add one, %s.\n", diagnosis.Remediation)
+	}
+}
 
-	for {
-		fmt.Print("ai-work-studio> ")
-		line, _, err := reader.ReadLine()
-		if err != nil {
-			break
-		}
+// warnIfNoProviders prints a prominent warning when no LLM provider is
+// configured, so the user learns of it before a task fails for it.
+func (cli *CLI) warnIfNoProviders() {
+	service := mcp.NewLLMServiceWithCredentialsProvider(log.New(io.Discard, "", 0), cli.credentials())
+	if service.Ready() == nil {
+		return
+	}
+	fmt.Println("⚠️  No LLM providers are configured: goals, plans and cost estimates work, but nothing can run.")
+	fmt.Printf("   To add one, %s.\n", mcp.NoProvidersRemediation)
+	fmt.Println("   'providers diagnose' shows what was checked.")
+	fmt.Println()
+}
 
-		input := strings.TrimSpace(string(line))
-		if input == "" {
-			continue
+// credentialProvider validates a provider name given on the command line.
+func credentialProvider(name string) (string, error) {
+	for _, provider := range llm.CredentialProviders {
+		if provider == name {
+			return provider, nil
 		}
+	}
+	return "", fmt.Errorf("unknown provider %q, must be one of: %s", name, strings.Join(llm.CredentialProviders, ", "))
+}
 
-		if input == "exit" || input == "quit" {
-			fmt.Println("👋 Goodbye!")
-			break
-		}
+// credentials resolves provider API keys from the OS keyring, then the
+// configuration file, then the environment.
+func (cli *CLI) credentials() *mcp.Credentials {
+	return mcp.NewCredentials(mcp.SystemKeyring(), cli.config.CredentialKeys())
+}
 
-		// Parse command and arguments
-		parts := strings.Fields(input)
-		if len(parts) == 0 {
-			continue
+// keySource describes where the provider's API key comes from.
+func (cli *CLI) keySource(provider string) string {
+	credential := cli.credentials().Resolve(provider)
+	switch {
+	case credential.Key == "":
+		return "not configured"
+	case credential.Source == mcp.CredentialSourceKeyring:
+		return "OS keyring"
+	case credential.Source == llm.ProviderEnvVar(provider):
+		return "environment (" + credential.Source + ")"
+	default:
+		return "config"
+	}
+}
+
+// providerRouter returns a router over the real providers, using the keys in
+// the OS keyring, the configuration and the environment.
+func (cli *CLI) providerRouter() *llm.Router {
+	service := mcp.NewLLMServiceWithCredentialsProvider(log.New(io.Discard, "", 0), cli.credentials())
+	routerConfig := llm.DefaultRouterConfig()
+	routerConfig.Credentials = llm.NewCredentialMonitor(llm.CredentialMonitorConfig{
+		Sources: llm.DiagnosedCredentialSources(service.Diagnose()),
+	})
+	routerConfig.Policy = llm.RoutingPolicy(cli.config.Routing.Policy)
+	routerConfig.RoutingStore = llm.NewStorageRoutingStore(cli.store, 0)
+	routerConfig.TokenEstimator = llm.NewTokenEstimator(tokenizerConfig(cli.config))
+	service.SetTokenCounter(routerConfig.TokenEstimator.CountTokens)
+	if cli.configPath != "" {
+		if err := service.LoadModelCatalog(filepath.Join(filepath.Dir(cli.configPath), mcp.ModelCatalogFile)); err != nil {
+			fmt.Printf("Warning: ignoring the model catalog: %v\n", err)
+		}
+	}
+	for provider, limits := range cli.config.API.Limits {
+		service.SetProviderLimits(provider, mcp.ProviderLimits(limits))
+	}
+	if enforcement, err := cli.config.BudgetLimits.EnforcementMode(); err == nil {
+		service.SetBudgetEnforcement(enforcement, cli.config.BudgetLimits.GracePercent)
+	}
+	if cli.config.Audit.Enabled {
+		if auditLog, err := mcp.NewAuditLog(cli.config.Audit.AuditLogConfig(cli.config.DataDir)); err != nil {
+			fmt.Printf("Warning: failed to open the LLM audit log: %v\n", err)
+		} else {
+			service.SetAuditLog(auditLog)
+		}
+	}
+	if cli.config.CompletionCache.Enabled {
+		if cache, err := mcp.NewCompletionCache(cli.config.CompletionCache.CacheConfig(cli.config.DataDir)); err != nil {
+			fmt.Printf("Warning: failed to open the completion cache: %v\n", err)
+		} else {
+			service.SetCompletionCache(cache)
 		}
+	}
+	return llm.NewRouter(service, routerConfig)
+}
 
-		commandName := parts[0]
-		commandArgs := parts[1:]
+// reportProbe probes the provider's credentials and prints the outcome.
+func (cli *CLI) reportProbe(router *llm.Router, provider string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-		// Execute command
-		if err := cli.executeCommand(commandName, commandArgs); err != nil {
-			fmt.Printf("Error: %v\n", err)
-		}
+	var alert *llm.CredentialAlert
+	router.Credentials().SetOnAlert(func(raised llm.CredentialAlert) { alert = &raised })
 
-		fmt.Println()
+	err := router.ProbeProvider(ctx, provider)
+	switch {
+	case err == nil:
+		fmt.Printf("%s: key accepted\n", provider)
+	case alert != nil:
+		fmt.Printf("%s: key rejected. %s\n", provider, alert.Message())
+	default:
+		fmt.Printf("%s: could not check the key: %v\n", provider, err)
 	}
-
-	return nil
 }
 
-// showHelp displays help information.
-func (cli *CLI) showHelp(args []string) error {
+// manageNetwork summarizes the outbound network destinations in the audit
+// log, and shows or edits the allowlist and the enforcement mode.
+func (cli *CLI) manageNetwork(args []string) error {
+	const usage = "usage: network [audit [--days n]|list|allow <host>|disallow <host>|mode <audit|enforce>]"
+	action := "audit"
 	if len(args) > 0 {
-		// Show help for specific command
-		commandName := args[0]
-		command, exists := getCommands()[commandName]
-		if !exists {
-			return fmt.Errorf("unknown command: %s", commandName)
+		action = args[0]
+		args = args[1:]
+	}
+
+	switch action {
+	case "audit":
+		flags := flag.NewFlagSet("network audit", flag.ContinueOnError)
+		days := flags.Int("days", 7, "Summarize this many days of requests")
+		if err := flags.Parse(args); err != nil {
+			return errs.Wrap(err, errs.Validation, nil)
+		}
+		if *days < 1 {
+			return fmt.Errorf("--days must be at least 1")
 		}
+		return cli.showNetworkAudit(*days)
 
-		fmt.Printf("Command: %s\n", command.Name)
-		fmt.Printf("Description: %s\n", command.Description)
-		fmt.Printf("Usage: %s\n", command.Usage)
+	case "list":
+		fmt.Printf("Mode: %s\n", netaudit.Default().Mode())
+		allowlist := netaudit.Default().Allowlist()
+		if len(allowlist) == 0 {
+			fmt.Println("The allowlist is empty.")
+			return nil
+		}
+		fmt.Println("Allowlist:")
+		for _, pattern := range allowlist {
+			fmt.Printf("  %s\n", pattern)
+		}
 		return nil
-	}
 
-	// Show general help
-	fmt.Println("🎯 AI Work Studio - Command Line Interface")
-	fmt.Println()
-	fmt.Println("USAGE:")
-	fmt.Println("  ai-work-studio [global-options] <command> [command-options]")
-	fmt.Println()
-	fmt.Println("GLOBAL OPTIONS:")
-	fmt.Println("  -config <path>    Configuration file path")
-	fmt.Println("  -data <path>      Data directory path")
-	fmt.Println("  -verbose          Enable verbose output")
-	fmt.Println()
-	fmt.Println("COMMANDS:")
+	case "allow", "disallow":
+		if len(args) < 1 {
+			return errs.New(errs.Validation, usage)
+		}
+		pattern, err := netaudit.NormalizePattern(args[0])
+		if err != nil {
+			return err
+		}
+		updates := config.NetworkUpdates{}
+		if action == "allow" {
+			updates.Allow = []string{pattern}
+		} else {
+			updates.Disallow = []string{pattern}
+		}
+		if err := cli.config.UpdateNetwork(cli.configPath, updates); err != nil {
+			return fmt.Errorf("failed to save allowlist: %w", err)
+		}
+		if action == "allow" {
+			netaudit.Default().Allow(pattern)
+			fmt.Printf("Added %s to the allowlist\n", pattern)
+		} else {
+			netaudit.Default().Disallow(pattern)
+			fmt.Printf("Removed %s from the allowlist\n", pattern)
+		}
+		return nil
 
-	// Display commands in a table
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	defer w.Flush()
+	case "mode":
+		if len(args) < 1 {
+			return errs.New(errs.Validation, usage)
+		}
+		mode, err := netaudit.ParseMode(args[0])
+		if err != nil {
+			return err
+		}
+		modeName := string(mode)
+		if err := cli.config.UpdateNetwork(cli.configPath, config.NetworkUpdates{Mode: &modeName}); err != nil {
+			return fmt.Errorf("failed to save mode: %w", err)
+		}
+		netaudit.Default().SetMode(mode)
+		if mode == netaudit.ModeEnforce {
+			fmt.Println("Enforcing the allowlist: requests to other hosts are now blocked.")
+		} else {
+			fmt.Println("Auditing only: requests are recorded but none are blocked.")
+		}
+		return nil
 
-	fmt.Fprintln(w, "  Command\tDescription")
-	fmt.Fprintln(w, "  -------\t-----------")
+	default:
+		return errs.New(errs.Validation, usage)
+	}
+}
 
-	for _, command := range getCommands() {
-		fmt.Fprintf(w, "  %s\t%s\n", command.Name, command.Description)
+// showNetworkAudit prints the destinations contacted over the last days.
+func (cli *CLI) showNetworkAudit(days int) error {
+	since := time.Now().AddDate(0, 0, -days)
+	auditor := netaudit.Default()
+	destinations, err := auditor.Summary(since)
+	if err != nil {
+		return fmt.Errorf("failed to read the network audit log: %w", err)
 	}
 
-	fmt.Println()
-	fmt.Println("Use 'help <command>' for detailed information about a specific command.")
-	fmt.Println()
-	fmt.Println("EXAMPLES:")
-	fmt.Println("  ai-work-studio create-goal \"Learn Go programming\" \"Master Go for backend development\" 8")
-	fmt.Println("  ai-work-studio list-goals active")
-	fmt.Println("  ai-work-studio status")
-	fmt.Println("  ai-work-studio interactive")
+	fmt.Printf("Outbound destinations over the last %d days (mode: %s)\n\n", days, auditor.Mode())
+	if len(destinations) == 0 {
+		fmt.Println("No outbound requests recorded.")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "DESTINATION\tCOMPONENTS\tREQUESTS\tBLOCKED\tSENT\tRECEIVED\tTLS\tALLOWLISTED\tLAST SEEN")
+	unlisted := 0
+	for _, destination := range destinations {
+		allowlisted := "yes"
+		if !auditor.Allowed(destination.Host, destination.Port) {
+			allowlisted = "no"
+			unlisted++
+		}
+		tls := "no"
+		switch {
+		case destination.TLS:
+			tls = "yes"
+		case destination.Blocked+destination.Failed == destination.Requests:
+			tls = "-" // never connected
+		}
+		fmt.Fprintf(writer, "%s:%d\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			destination.Host, destination.Port,
+			strings.Join(destination.Components, ", "),
+			destination.Requests, destination.Blocked,
+			formatBytes(destination.BytesSent), formatBytes(destination.BytesReceived),
+			tls, allowlisted,
+			destination.LastSeen.Local().Format("Jan 2 15:04"))
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
 
+	if unlisted > 0 && auditor.Mode() == netaudit.ModeAudit {
+		fmt.Printf("\n%d destination(s) are not on the allowlist and would be blocked in enforce mode.\n", unlisted)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// formatBytes formats a byte count for display.
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
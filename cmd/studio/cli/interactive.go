@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Solifugus/ai-work-studio/internal/completion"
+	"github.com/Solifugus/ai-work-studio/internal/config"
+	"github.com/Solifugus/ai-work-studio/internal/lineinput"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// historyLimit is how many entries interactive mode remembers across sessions.
+const historyLimit = 1000
+
+// slashCommand is a command of interactive mode, typed with a leading slash.
+type slashCommand struct {
+	Usage       string
+	Description string
+	Handler     func(s *interactiveSession, args []string) error
+}
+
+// slashCommands returns interactive mode's commands by name. core's
+// ChatOverridePrefix is not among them: it goes to the intent dispatcher.
+func slashCommands() map[string]slashCommand {
+	return map[string]slashCommand{
+		"goals": {
+			Usage:       "/goals [status]",
+			Description: "List goals",
+			Handler:     func(s *interactiveSession, args []string) error { return s.cli.listGoals(args) },
+		},
+		"objectives": {
+			Usage:       "/objectives [goal-id] [status]",
+			Description: "List a goal's objectives, making it the current goal",
+			Handler:     (*interactiveSession).objectives,
+		},
+		"goal": {
+			Usage:       "/goal [goal-id|none]",
+			Description: "Show or set the goal the conversation is about",
+			Handler:     (*interactiveSession).goal,
+		},
+		"run": {
+			Usage:       "/run <objective-id>",
+			Description: "Plan and execute an objective (Ctrl-C cancels)",
+			Handler:     func(s *interactiveSession, args []string) error { return s.cli.runObjective(args) },
+		},
+		"budget": {
+			Usage:       "/budget",
+			Description: "Show what has been spent and what is left",
+			Handler:     func(s *interactiveSession, args []string) error { return s.cli.showBudget() },
+		},
+		"history": {
+			Usage:       "/history",
+			Description: "List earlier input; !n repeats entry n, !! the last",
+			Handler:     (*interactiveSession).showHistory,
+		},
+		"help": {
+			Usage:       "/help",
+			Description: "List these commands",
+			Handler:     (*interactiveSession).help,
+		},
+	}
+}
+
+// interactiveSession is one run of interactive mode: its input, and the
+// turn in flight that Ctrl-C cancels.
+type interactiveSession struct {
+	cli        *CLI
+	reader     *bufio.Reader
+	input      *lineinput.Reader
+	history    *lineinput.History
+	dispatcher *core.IntentDispatcher
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// interactiveMode enters conversation-like interactive mode.
+// Lines starting with a slash or a command name run that command; anything
+// else is classified and either answered, with the current goal in mind, or
+// turned into a confirmed operation.
+func (cli *CLI) interactiveMode(args []string) error {
+	fmt.Println("🤖 AI Work Studio - Interactive Mode")
+	fmt.Println("Type a command, or just say what you need ('/chat ...' to only talk)")
+	fmt.Println("Type '/help' for commands, '/quit' to leave; end a line with \\ to continue it")
+	fmt.Println()
+	cli.warnIfNoProviders()
+
+	history, err := lineinput.LoadHistory(filepath.Join(cli.config.DataDir, "cli_history"), historyLimit)
+	if err != nil {
+		fmt.Printf("Warning: input history unavailable: %v\n\n", err)
+		history = nil
+	}
+	session := &interactiveSession{cli: cli, reader: bufio.NewReader(os.Stdin), history: history}
+	session.input = lineinput.NewReader(session.reader, os.Stdout, history)
+	cli.offerStarterMethods(session.reader)
+
+	session.dispatcher, err = cli.newIntentDispatcher()
+	if err != nil {
+		fmt.Printf("Warning: intent detection unavailable, only commands will work: %v\n\n", err)
+	}
+
+	// Ctrl-C stops the turn in flight, not the session
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer func() {
+		signal.Stop(interrupts)
+		close(interrupts)
+	}()
+	go func() {
+		for range interrupts {
+			if !session.cancelTurn() {
+				fmt.Println("\n(Type /quit to leave)")
+			}
+		}
+	}()
+
+	for {
+		input, err := session.input.Read(session.prompt())
+		if errs.CodeOf(err) == errs.NotFound {
+			fmt.Printf("Error: %v\n\n", err)
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if input == "" {
+			continue
+		}
+		if !session.handle(input) {
+			fmt.Println("👋 Goodbye!")
+			break
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// prompt shows what is left of today's budget, when there is a daily limit.
+func (s *interactiveSession) prompt() string {
+	if s.cli.config.BudgetLimits.DailyLimit <= 0 {
+		return "ai-work-studio> "
+	}
+	budget, err := s.cli.budgetManager()
+	if err != nil {
+		return "ai-work-studio> "
+	}
+	daily := budget.GetBudgetStatus().Periods["daily"]
+	if daily == nil {
+		return "ai-work-studio> "
+	}
+	return fmt.Sprintf("ai-work-studio [$%.2f left today]> ", daily.Remaining)
+}
+
+// handle runs one entry, reporting whether the session goes on.
+func (s *interactiveSession) handle(input string) bool {
+	fields := strings.Fields(input)
+	name, args := fields[0], fields[1:]
+
+	var turn func() error
+	switch {
+	case name == "exit" || name == "quit" || name == "/exit" || name == "/quit":
+		return false
+
+	case name == core.ChatOverridePrefix || !strings.HasPrefix(name, "/"):
+		if _, isCommand := getCommands()[name]; isCommand || s.dispatcher == nil {
+			turn = func() error { return s.cli.executeCommand(name, args) }
+		} else {
+			turn = func() error { return s.cli.handleIntentTurn(s.dispatcher, s.reader, input) }
+		}
+
+	default:
+		command, ok := slashCommands()[strings.TrimPrefix(name, "/")]
+		if !ok {
+			fmt.Printf("Error: %v\n", errs.Newf(errs.Validation, "unknown command %s (type /help)", name).With("command", name))
+			return true
+		}
+		turn = func() error { return command.Handler(s, args) }
+	}
+
+	if cancelled, err := s.runTurn(turn); cancelled {
+		fmt.Println("⏹  Cancelled")
+	} else if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	return true
+}
+
+// runTurn runs a turn under a context Ctrl-C cancels, reporting whether it was.
+func (s *interactiveSession) runTurn(turn func() error) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+	s.cli.turn = ctx
+
+	err := turn()
+
+	s.mu.Lock()
+	s.cancel = nil
+	s.mu.Unlock()
+	s.cli.turn = nil
+	cancelled := ctx.Err() != nil
+	cancel()
+	return cancelled, err
+}
+
+// cancelTurn cancels the turn in flight, reporting whether there was one.
+func (s *interactiveSession) cancelTurn() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+// objectives lists a goal's objectives, the current goal's by default, and
+// makes the goal listed the current one.
+func (s *interactiveSession) objectives(args []string) error {
+	if len(args) == 0 {
+		if s.cli.config.Session.CurrentGoalID == "" {
+			return errs.New(errs.Validation, "usage: /objectives <goal-id> [status] (no current goal)")
+		}
+		return s.cli.listObjectives([]string{s.cli.config.Session.CurrentGoalID})
+	}
+	goalID, err := s.cli.resolveID(completion.ArgGoal, args[0])
+	if err != nil {
+		return err
+	}
+	goal, err := s.cli.goalManager.GetGoal(s.cli.turnContext(), goalID)
+	if err != nil {
+		return fmt.Errorf("goal not found: %w", err)
+	}
+	if err := s.setGoal(goal.ID); err != nil {
+		return err
+	}
+	return s.cli.listObjectives(append([]string{goal.ID}, args[1:]...))
+}
+
+// goal shows the current goal, or sets it ("none" clears it).
+func (s *interactiveSession) goal(args []string) error {
+	switch {
+	case len(args) > 1:
+		return errs.New(errs.Validation, "usage: /goal [goal-id|none]")
+
+	case len(args) == 0:
+		goalID := s.cli.config.Session.CurrentGoalID
+		if goalID == "" {
+			fmt.Println("No current goal; the conversation is about nothing in particular.")
+			return nil
+		}
+		goal, err := s.cli.goalManager.GetGoal(s.cli.turnContext(), goalID)
+		if err != nil {
+			return fmt.Errorf("current goal %s not found: %w", goalID, err)
+		}
+		fmt.Printf("📋 Current Goal: %s (%s)\n", goal.Title, goal.ID[:8])
+		return nil
+
+	case args[0] == "none":
+		if err := s.setGoal(""); err != nil {
+			return err
+		}
+		fmt.Println("✓ Cleared the current goal")
+		return nil
+	}
+
+	goalID, err := s.cli.resolveID(completion.ArgGoal, args[0])
+	if err != nil {
+		return err
+	}
+	goal, err := s.cli.goalManager.GetGoal(s.cli.turnContext(), goalID)
+	if err != nil {
+		return fmt.Errorf("goal not found: %w", err)
+	}
+	if err := s.setGoal(goal.ID); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Current goal: %s\n", goal.Title)
+	return nil
+}
+
+// setGoal saves the current goal to the session.
+func (s *interactiveSession) setGoal(goalID string) error {
+	if goalID == s.cli.config.Session.CurrentGoalID {
+		return nil
+	}
+	updates := config.SessionUpdates{CurrentGoalID: &goalID}
+	if err := s.cli.config.UpdateSession(s.cli.configPath, updates); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+// showHistory lists the remembered input, numbered for !n.
+func (s *interactiveSession) showHistory(args []string) error {
+	if s.history == nil {
+		fmt.Println("Input history is unavailable.")
+		return nil
+	}
+	for i, entry := range s.history.Entries() {
+		fmt.Printf("%5d  %s\n", i+1, strings.ReplaceAll(entry, "\n", "\n       "))
+	}
+	return nil
+}
+
+// help lists interactive mode's commands.
+func (s *interactiveSession) help(args []string) error {
+	commands := slashCommands()
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Interactive commands:")
+	for _, name := range names {
+		fmt.Printf("  %-32s %s\n", commands[name].Usage, commands[name].Description)
+	}
+	fmt.Printf("  %-32s %s\n", core.ChatOverridePrefix+" <message>", "Only talk, never change anything")
+	fmt.Printf("  %-32s %s\n", "/quit", "Leave interactive mode")
+	fmt.Println()
+	fmt.Println("Every CLI command also works by name ('help' lists them). Anything else is")
+	fmt.Println("answered in conversation, about the current goal if there is one.")
+	return nil
+}
@@ -5,17 +5,24 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/internal/completion"
 	"github.com/Solifugus/ai-work-studio/internal/config"
 	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/llm"
 	"github.com/Solifugus/ai-work-studio/pkg/mcp"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
 )
 
 // CLI represents the command-line interface with its dependencies.
@@ -23,12 +30,27 @@ type CLI struct {
 	config           *config.Config
 	configPath       string
 	store            *storage.Store
+	replica          *storage.ReplicaStore
 	goalManager      *core.GoalManager
 	objectiveManager *core.ObjectiveManager
 	methodManager    *core.MethodManager
 	contextManager   *core.UserContextManager
 	ethicalFramework *core.EthicalFramework
+	rollupManager    *core.RollupManager
 	llmRouter        *llm.Router
+
+	// turn is the context of the interactive turn in progress, which Ctrl-C
+	// cancels (nil outside interactive mode)
+	turn context.Context
+}
+
+// turnContext returns the context commands run under: the interactive
+// turn's, or the background context.
+func (cli *CLI) turnContext() context.Context {
+	if cli.turn != nil {
+		return cli.turn
+	}
+	return context.Background()
 }
 
 // Command represents a CLI command with its handler function.
@@ -37,8 +59,25 @@ type Command struct {
 	Description string
 	Usage       string
 	Handler     func(*CLI, []string) error
+
+	// Positional arguments and flags, for shell completion
+	Args  []completion.Arg
+	Flags []completion.Flag
 }
 
+// Status values offered when completing list filters
+var (
+	goalStatuses = []string{
+		string(core.GoalStatusActive), string(core.GoalStatusPaused),
+		string(core.GoalStatusCompleted), string(core.GoalStatusArchived),
+	}
+	objectiveStatuses = []string{
+		string(core.ObjectiveStatusPending), string(core.ObjectiveStatusInProgress),
+		string(core.ObjectiveStatusCompleted), string(core.ObjectiveStatusFailed),
+		string(core.ObjectiveStatusPaused), string(core.ObjectiveStatusCancelled),
+	}
+)
+
 // getCommands returns the available commands map
 func getCommands() map[string]Command {
 	return map[string]Command{
@@ -51,20 +90,209 @@ func getCommands() map[string]Command {
 	"create-objective": {
 		Name:        "create-objective",
 		Description: "Create a new objective for a goal",
-		Usage:       "create-objective <goal-id> <title> [description] [priority]",
+		Usage:       "create-objective <goal-id> <title> [description] [priority] [--time-box duration] [--due date] [--recur rule] [--dry-run [--task-type type] [--quality level]]",
 		Handler:     (*CLI).createObjective,
+		Args:        []completion.Arg{{Kind: completion.ArgGoal}},
+		Flags:       []completion.Flag{{Name: "--time-box", TakesValue: true}, {Name: "--due", TakesValue: true}, {Name: "--dry-run"}, {Name: "--task-type", TakesValue: true}, {Name: "--quality", TakesValue: true}},
+	},
+	"time-box": {
+		Name:        "time-box",
+		Description: "Show or set an objective's time box, or extend, resume or abandon one that ran out",
+		Usage:       "time-box [list | <objective-id> [set <duration>|clear|extend <duration>|resume|abandon]]",
+		Handler:     (*CLI).manageTimeBox,
+		Args:        []completion.Arg{{Kind: completion.ArgObjective}, {Kind: completion.ArgChoice, Words: []string{"set", "clear", "extend", "resume", "abandon"}}},
+	},
+	"start-objective": {
+		Name:        "start-objective",
+		Description: "Start or resume an objective within the work-in-progress limits",
+		Usage:       "start-objective <objective-id> [--override-wip] [--reason text]",
+		Handler:     (*CLI).startObjective,
+		Args:        []completion.Arg{{Kind: completion.ArgObjective}},
+		Flags:       []completion.Flag{{Name: "--override-wip"}, {Name: "--reason", TakesValue: true}},
+	},
+	"run-objective": {
+		Name:        "run-objective",
+		Description: "Plan and execute an objective with the configured LLM providers",
+		Usage:       "run-objective <objective-id>",
+		Handler:     (*CLI).runObjective,
+		Args:        []completion.Arg{{Kind: completion.ArgObjective}},
+	},
+	"work": {
+		Name:        "work",
+		Description: "Work through actionable objectives unattended, within hourly, spending and failure limits",
+		Usage:       "work [--max-per-hour n] [--max-spend dollars] [--max-failures n] [--idle duration]",
+		Handler:     (*CLI).work,
+		Flags: []completion.Flag{
+			{Name: "--max-per-hour", TakesValue: true}, {Name: "--max-spend", TakesValue: true},
+			{Name: "--max-failures", TakesValue: true}, {Name: "--idle", TakesValue: true},
+		},
+	},
+	"next": {
+		Name:        "next",
+		Description: "Suggest which objective to start next, and why",
+		Usage:       "next [--time duration] [--energy low|normal|high] [--max n] [--weights factor=weight,...] [--save-weights] [--explain] [--tie-break]",
+		Handler:     (*CLI).suggestNext,
+		Flags: []completion.Flag{
+			{Name: "--time", TakesValue: true}, {Name: "--energy", TakesValue: true}, {Name: "--max", TakesValue: true},
+			{Name: "--weights", TakesValue: true}, {Name: "--save-weights"}, {Name: "--explain"}, {Name: "--tie-break"},
+		},
+	},
+	"brief": {
+		Name:        "brief",
+		Description: "Write a brief for handing an objective to someone outside the studio",
+		Usage:       "brief <objective-id> [--out file] [--format markdown|json] [--audience text] [--no-context] [--polish] [--delegate-to name [--note text]] [--undelegate]",
+		Handler:     (*CLI).writeBrief,
+		Args:        []completion.Arg{{Kind: completion.ArgObjective}},
+		Flags: []completion.Flag{
+			{Name: "--out", TakesValue: true}, {Name: "--format", TakesValue: true}, {Name: "--audience", TakesValue: true},
+			{Name: "--no-context"}, {Name: "--polish"}, {Name: "--delegate-to", TakesValue: true}, {Name: "--note", TakesValue: true},
+			{Name: "--undelegate"},
+		},
+	},
+	"decompose": {
+		Name:        "decompose",
+		Description: "Propose objectives for a goal and create the accepted ones",
+		Usage:       "decompose <goal-id> [--max n] [--no-methods]",
+		Handler:     (*CLI).decomposeGoal,
+		Args:        []completion.Arg{{Kind: completion.ArgGoal}},
+		Flags:       []completion.Flag{{Name: "--max", TakesValue: true}, {Name: "--no-methods"}},
 	},
 	"list-goals": {
 		Name:        "list-goals",
 		Description: "List all goals",
-		Usage:       "list-goals [status]",
+		Usage:       "list-goals [status] [--all]",
 		Handler:     (*CLI).listGoals,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: goalStatuses}},
+		Flags:       []completion.Flag{{Name: "--all"}},
+	},
+	"archive-goal": {
+		Name:        "archive-goal",
+		Description: "Archive a goal, cancelling its pending and paused objectives",
+		Usage:       "archive-goal <goal-id> [--reason text] [--recursive]",
+		Handler:     (*CLI).archiveGoal,
+		Args:        []completion.Arg{{Kind: completion.ArgGoal}},
+		Flags:       []completion.Flag{{Name: "--reason", TakesValue: true}, {Name: "--recursive"}},
+	},
+	"unarchive-goal": {
+		Name:        "unarchive-goal",
+		Description: "Restore an archived goal and the objectives its archiving cancelled",
+		Usage:       "unarchive-goal <goal-id>",
+		Handler:     (*CLI).unarchiveGoal,
+		Args:        []completion.Arg{{Kind: completion.ArgArchivedGoal}},
+	},
+	"tree": {
+		Name:        "tree",
+		Description: "Show the goal hierarchy with progress and spend rolled up from sub-goals",
+		Usage:       "tree [root-id] [--depth n]",
+		Handler:     (*CLI).showGoalTree,
+		Args:        []completion.Arg{{Kind: completion.ArgGoal}},
+		Flags:       []completion.Flag{{Name: "--depth", TakesValue: true}},
 	},
 	"list-objectives": {
 		Name:        "list-objectives",
 		Description: "List objectives for a goal",
 		Usage:       "list-objectives [goal-id] [status]",
 		Handler:     (*CLI).listObjectives,
+		Args:        []completion.Arg{{Kind: completion.ArgGoal}, {Kind: completion.ArgChoice, Words: objectiveStatuses}},
+	},
+	"methods": {
+		Name:        "methods",
+		Description: "List methods, generate a method playbook, or import and export method definitions",
+		Usage:       "methods [list|playbook <method-id> [--out file] [--no-history] [--no-stats] [--narrative]|import <file> [--on-duplicate skip|replace|successor]|export [method-id] [--out file] [--format json|yaml]|starter]",
+		Handler:     (*CLI).manageMethods,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list", "playbook", "import", "export", "starter"}}, {Kind: completion.ArgMethod}},
+		Flags:       []completion.Flag{{Name: "--out", TakesValue: true}, {Name: "--no-history"}, {Name: "--no-stats"}, {Name: "--narrative"}, {Name: "--on-duplicate", TakesValue: true}, {Name: "--format", TakesValue: true}},
+	},
+	"backup": {
+		Name:        "backup",
+		Description: "Back up, verify or restore the data directory",
+		Usage:       "backup [now|list|verify <backup-id>|restore <backup-id> <dir> [--force]]",
+		Handler:     (*CLI).manageBackups,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"now", "list", "verify", "restore"}}},
+		Flags:       []completion.Flag{{Name: "--force"}},
+	},
+	"archive": {
+		Name:        "archive",
+		Description: "Move settled work of archived goals out of the live store",
+		Usage:       "archive [--dry-run]",
+		Handler:     (*CLI).archiveSettled,
+		Flags:       []completion.Flag{{Name: "--dry-run"}},
+	},
+	"compact": {
+		Name:        "compact",
+		Description: "Thin out old node versions, keeping the first and last of each day, and remove unreferenced output blobs",
+		Usage:       "compact [--days n] [--type t] [--export file] [--dry-run]",
+		Handler:     (*CLI).compactStore,
+		Flags:       []completion.Flag{{Name: "--days", TakesValue: true}, {Name: "--type", TakesValue: true}, {Name: "--export", TakesValue: true}, {Name: "--dry-run"}},
+	},
+	"check-data": {
+		Name:        "check-data",
+		Description: "Report stored goals, objectives, methods, decisions and executions whose data does not match their schema",
+		Usage:       "check-data",
+		Handler:     (*CLI).checkData,
+	},
+	"export": {
+		Name:        "export",
+		Description: "Write every node, edge and blob in the store to an archive for backup or another machine",
+		Usage:       "export <file>",
+		Handler:     (*CLI).exportStore,
+	},
+	"import": {
+		Name:        "import",
+		Description: "Load a store archive written by export, merging it into the store or replacing its contents",
+		Usage:       "import <file> [--replace]",
+		Handler:     (*CLI).importStore,
+		Flags:       []completion.Flag{{Name: "--replace"}},
+	},
+	"undo": {
+		Name:        "undo",
+		Description: "Undo the last bulk operation, such as a context merge or an archive pass",
+		Usage:       "undo [operation-id] [--partial] [--yes]",
+		Handler:     (*CLI).undoOperation,
+		Args:        []completion.Arg{{Kind: completion.ArgOperation}},
+		Flags:       []completion.Flag{{Name: "--partial"}, {Name: "--yes"}},
+	},
+	"operations": {
+		Name:        "operations",
+		Description: "List the journal of bulk operations that can be undone",
+		Usage:       "operations [list]",
+		Handler:     (*CLI).listOperations,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list"}}},
+	},
+	"preview": {
+		Name:        "preview",
+		Description: "Show how an objective's context changed since its last run and how it would be re-planned",
+		Usage:       "preview <objective-id>",
+		Handler:     (*CLI).previewReplan,
+		Args:        []completion.Arg{{Kind: completion.ArgObjective}},
+	},
+	"exchanges": {
+		Name:        "exchanges",
+		Description: "Inspect logged LLM exchanges",
+		Usage:       "exchanges [tail [n]|show <fingerprint>]",
+		Handler:     (*CLI).inspectExchanges,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"tail", "show"}}},
+	},
+	"audit": {
+		Name:        "audit",
+		Description: "Show the LLM requests in the audit log",
+		Usage:       "audit [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--provider name] [--objective id] [--limit n] [--full]",
+		Handler:     (*CLI).showAudit,
+		Flags:       []completion.Flag{{Name: "--from", TakesValue: true}, {Name: "--to", TakesValue: true}, {Name: "--provider", TakesValue: true}, {Name: "--objective", TakesValue: true}, {Name: "--limit", TakesValue: true}, {Name: "--full"}},
+	},
+	"budget-report": {
+		Name:        "budget-report",
+		Description: "Report LLM usage and spending by day, provider and model",
+		Usage:       "budget-report [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--format csv|json] [--out file]",
+		Handler:     (*CLI).budgetReport,
+		Flags:       []completion.Flag{{Name: "--from", TakesValue: true}, {Name: "--to", TakesValue: true}, {Name: "--format", TakesValue: true}, {Name: "--out", TakesValue: true}},
+	},
+	"search": {
+		Name:        "search",
+		Description: "Search goals, objectives and other records by words",
+		Usage:       "search <words...> [--archived]",
+		Handler:     (*CLI).search,
+		Flags:       []completion.Flag{{Name: "--archived"}},
 	},
 	"status": {
 		Name:        "status",
@@ -75,14 +303,79 @@ func getCommands() map[string]Command {
 	"feedback": {
 		Name:        "feedback",
 		Description: "Provide feedback on decisions or outcomes",
-		Usage:       "feedback <decision-id> <approve|reject> [message]",
+		Usage:       "feedback <decision-id> <approve|reject> [message] [--always [--expires-days N]]",
 		Handler:     (*CLI).provideFeedback,
+		Args:        []completion.Arg{{Kind: completion.ArgDecision}, {Kind: completion.ArgChoice, Words: []string{"approve", "reject"}}},
+		Flags:       []completion.Flag{{Name: "--always"}, {Name: "--expires-days", TakesValue: true}},
+	},
+	"decisions": {
+		Name:        "decisions",
+		Description: "List open decisions, abort one whose implementation went wrong, or export an audit",
+		Usage:       "decisions [abort <decision-id> <reason>|audit [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--min-urgency U] [--format F] [--out file]]",
+		Handler:     (*CLI).manageDecisions,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"abort", "audit"}}, {Kind: completion.ArgImplementedDecision}},
+		Flags:       []completion.Flag{{Name: "--from", TakesValue: true}, {Name: "--to", TakesValue: true}, {Name: "--min-urgency", TakesValue: true}, {Name: "--format", TakesValue: true}, {Name: "--out", TakesValue: true}},
+	},
+	"pending-decisions": {
+		Name:        "pending-decisions",
+		Description: "List the decisions waiting for your approval, most urgent first",
+		Usage:       "pending-decisions [--urgency low|medium|high|critical] [--json]",
+		Handler:     (*CLI).listPendingDecisions,
+		Flags:       []completion.Flag{{Name: "--urgency", TakesValue: true}, {Name: "--json"}},
+	},
+	"rules": {
+		Name:        "rules",
+		Description: "List, revoke or resume standing approval rules",
+		Usage:       "rules [list|revoke <rule-id>|resume <rule-id>]",
+		Handler:     (*CLI).manageRules,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list", "revoke", "resume"}}, {Kind: completion.ArgRule}},
+	},
+	"policy": {
+		Name:        "policy",
+		Description: "List, add, accept or remove the policy rules decided before ethical reasoning",
+		Usage:       "policy [list|add <approve|reject|escalate> [conditions]|accept <rule-id>|remove <rule-id>]",
+		Handler:     (*CLI).managePolicy,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list", "add", "accept", "remove"}}, {Kind: completion.ArgPolicyRule}},
+		Flags: []completion.Flag{
+			{Name: "--action", TakesValue: true}, {Name: "--keyword", TakesValue: true}, {Name: "--context", TakesValue: true},
+			{Name: "--verb", TakesValue: true}, {Name: "--service", TakesValue: true}, {Name: "--path", TakesValue: true},
+			{Name: "--host", TakesValue: true}, {Name: "--objective", TakesValue: true},
+		},
+	},
+	"rate": {
+		Name:        "rate",
+		Description: "Rate a finished objective's output so preferences can be learned from it, or an LLM routing so routing can",
+		Usage:       "rate <objective-id|routing-id> <1-10> [comment]",
+		Handler:     (*CLI).rateObjective,
+		Args:        []completion.Arg{{Kind: completion.ArgObjective}},
+	},
+	"preferences": {
+		Name:        "preferences",
+		Description: "Confirm or dismiss learned preferences and context merges, or merge duplicate context",
+		Usage:       "preferences [list|confirm <preference-id>|dismiss <preference-id>|consolidate [--dry-run]]",
+		Handler:     (*CLI).managePreferences,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list", "confirm", "dismiss", "consolidate"}}, {Kind: completion.ArgPreference}},
+	},
+	"context": {
+		Name:        "context",
+		Description: "Review what has been learned about you, export it, or forget an entry",
+		Usage:       "context [list [--category C] [--source S] [--tag T] [--all]|export [--out file]|forget <context-id> [reason]]",
+		Handler:     (*CLI).manageContext,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list", "export", "forget"}}, {Kind: completion.ArgContext}},
+		Flags:       []completion.Flag{{Name: "--category", TakesValue: true}, {Name: "--source", TakesValue: true}, {Name: "--tag", TakesValue: true}, {Name: "--all"}, {Name: "--out", TakesValue: true}},
 	},
 	"config": {
 		Name:        "config",
 		Description: "Manage configuration settings",
-		Usage:       "config [get|set] [key] [value]",
+		Usage:       "config [get|set] [key] [value] | config set-credential <provider>",
 		Handler:     (*CLI).manageConfig,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"get", "set", "set-credential"}}},
+	},
+	"selftest": {
+		Name:        "selftest",
+		Description: "Check the whole pipeline offline with a fake LLM provider",
+		Usage:       "selftest",
+		Handler:     (*CLI).runSelfTest,
 	},
 	"interactive": {
 		Name:        "interactive",
@@ -90,11 +383,49 @@ func getCommands() map[string]Command {
 		Usage:       "interactive",
 		Handler:     (*CLI).interactiveMode,
 	},
+	"tokens": {
+		Name:        "tokens",
+		Description: "Count the tokens a model would see in a file",
+		Usage:       "tokens count --model <model> <file>",
+		Handler:     (*CLI).countTokens,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"count"}}},
+		Flags:       []completion.Flag{{Name: "--model", TakesValue: true}},
+	},
+	"route-preview": {
+		Name:        "route-preview",
+		Description: "Show which model would handle a prompt, and why, without sending it",
+		Usage:       "route-preview [--task-type type] [--policy name] <prompt>",
+		Handler:     (*CLI).previewRoute,
+		Flags:       []completion.Flag{{Name: "--task-type", TakesValue: true}, {Name: "--policy", TakesValue: true}},
+	},
+	"providers": {
+		Name:        "providers",
+		Description: "Show, check or replace LLM provider API keys, or diagnose why none is available",
+		Usage:       "providers [list|check [provider]|set-key <provider>|diagnose]",
+		Handler:     (*CLI).manageProviders,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list", "check", "set-key", "diagnose"}}, {Kind: completion.ArgChoice, Words: llm.CredentialProviders}},
+	},
+	"network": {
+		Name:        "network",
+		Description: "Summarize outbound network destinations and manage the allowlist",
+		Usage:       "network [audit [--days n]|list|allow <host>|disallow <host>|mode <audit|enforce>]",
+		Handler:     (*CLI).manageNetwork,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"audit", "list", "allow", "disallow", "mode"}}},
+		Flags:       []completion.Flag{{Name: "--days", TakesValue: true}},
+	},
+	"completion": {
+		Name:        "completion",
+		Description: "Print a shell completion script",
+		Usage:       "completion <bash|zsh|fish>",
+		Handler:     (*CLI).printCompletion,
+		Args:        []completion.Arg{{Kind: completion.ArgChoice, Words: completion.Shells}},
+	},
 	"help": {
 		Name:        "help",
 		Description: "Show help information",
 		Usage:       "help [command]",
 		Handler:     (*CLI).showHelp,
+		Args:        []completion.Arg{{Kind: completion.ArgCommand}},
 	},
 	}
 }
@@ -104,27 +435,47 @@ func main() {
 	var configPath string
 	var verbose bool
 	var dataDir string
+	var jsonOutput bool
+	var skipValidation bool
 
 	flag.StringVar(&configPath, "config", "", "Configuration file path (default: ~/.ai-work-studio/config.json)")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose output")
 	flag.StringVar(&dataDir, "data", "", "Data directory path (overrides config)")
+	flag.BoolVar(&jsonOutput, "json", false, "Report errors as JSON on stdout")
+	flag.BoolVar(&skipValidation, "skip-validation", false, "Store data without checking it against node schemas, for migrations")
 	flag.Parse()
 
+	// Shell completion runs before any setup: it is called on every TAB
+	// press, so it must be fast and must not create or write anything
+	if args := flag.Args(); len(args) > 0 && (args[0] == "completion" || args[0] == completeCommand) {
+		var err error
+		if args[0] == "completion" {
+			err = printCompletionScript(args[1:])
+		} else {
+			err = complete(configPath, dataDir, args[1:])
+		}
+		if err != nil {
+			exitWithError("Error", err, jsonOutput)
+		}
+		return
+	}
+
 	// Get default config path if not specified
 	if configPath == "" {
 		var err error
 		configPath, err = config.GetConfigPath()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError("Error", err, jsonOutput)
 		}
 	}
 
 	// Load configuration
 	cfg, err := config.Load(configPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
-		os.Exit(1)
+		exitWithError("Error loading configuration", err, jsonOutput)
+	}
+	if warning := config.CredentialsFileWarning(configPath, cfg); warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
 	}
 
 	// Override data directory if specified
@@ -139,17 +490,18 @@ func main() {
 
 	// Ensure data directory exists
 	if err := cfg.EnsureDataDir(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error setting up data directory: %v\n", err)
-		os.Exit(1)
+		exitWithError("Error setting up data directory", err, jsonOutput)
 	}
 
 	// Initialize CLI
-	cli, err := NewCLI(cfg, configPath)
+	var storeOpts []storage.StoreOption
+	if skipValidation {
+		storeOpts = append(storeOpts, storage.SkipSchemaValidation())
+	}
+	cli, err := NewCLI(cfg, configPath, storeOpts...)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing CLI: %v\n", err)
-		os.Exit(1)
+		exitWithError("Error initializing CLI", err, jsonOutput)
 	}
-	defer cli.Close()
 
 	// Get command arguments
 	args := flag.Args()
@@ -158,12 +510,13 @@ func main() {
 	if len(args) == 0 {
 		if cfg.Preferences.InteractiveMode {
 			if err := cli.interactiveMode([]string{}); err != nil {
-				fmt.Fprintf(os.Stderr, "Error in interactive mode: %v\n", err)
-				os.Exit(1)
+				cli.Close()
+				exitWithError("Error in interactive mode", err, jsonOutput)
 			}
 		} else {
 			cli.showHelp([]string{})
 		}
+		cli.Close()
 		return
 	}
 
@@ -171,16 +524,138 @@ func main() {
 	commandName := args[0]
 	commandArgs := args[1:]
 
-	if err := cli.executeCommand(commandName, commandArgs); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	err = cli.executeCommand(commandName, commandArgs)
+	cli.Close()
+	if err != nil {
+		exitWithError("Error", err, jsonOutput)
+	}
+}
+
+// exitWithError reports err and exits with the status of its error code, so
+// scripts can tell failures apart. With --json the error is printed to
+// stdout as {"error": {"code": ..., "message": ..., "details": ...}}.
+func exitWithError(prefix string, err error, jsonOutput bool) {
+	code := errs.CodeOf(err)
+	if jsonOutput {
+		details := errs.DetailsOf(err)
+		if details == nil {
+			details = map[string]interface{}{}
+		}
+		var report struct {
+			Error struct {
+				Code    errs.Code              `json:"code"`
+				Message string                 `json:"message"`
+				Details map[string]interface{} `json:"details"`
+			} `json:"error"`
+		}
+		report.Error.Code = code
+		report.Error.Message = err.Error()
+		report.Error.Details = details
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(report); encodeErr == nil {
+			os.Exit(code.ExitCode())
+		}
 	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", prefix, err)
+	os.Exit(code.ExitCode())
 }
 
-// NewCLI creates a new CLI instance with initialized dependencies.
-func NewCLI(cfg *config.Config, configPath string) (*CLI, error) {
+// completeCommand is the hidden command the completion scripts call.
+const completeCommand = "__complete"
+
+// completionSpec describes the commands for shell completion.
+func completionSpec() completion.Spec {
+	spec := completion.Spec{
+		Globals: []completion.Flag{
+			{Name: "-config", TakesValue: true},
+			{Name: "-data", TakesValue: true},
+			{Name: "-json"},
+			{Name: "-verbose"},
+		},
+	}
+	for _, command := range getCommands() {
+		spec.Commands = append(spec.Commands, completion.Command{
+			Name:        command.Name,
+			Description: command.Description,
+			Args:        command.Args,
+			Flags:       command.Flags,
+		})
+	}
+	return spec
+}
+
+// complete prints the completion candidates for the words typed so far, one
+// "id<TAB>title" per line. Global flags among the words choose the config and
+// data directory; the store is opened read-only.
+func complete(configPath, dataDir string, words []string) error {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	globals := flag.NewFlagSet(completeCommand, flag.ContinueOnError)
+	globals.SetOutput(io.Discard)
+	globals.StringVar(&configPath, "config", configPath, "")
+	globals.StringVar(&dataDir, "data", dataDir, "")
+	globals.Bool("verbose", false, "")
+	globals.Bool("json", false, "")
+	if err := globals.Parse(words[:len(words)-1]); err != nil {
+		return nil // Completing a global flag value: leave it to the shell
+	}
+	words = append(globals.Args(), current)
+
+	spec := completionSpec()
+	var source completion.Source
+	if len(words) > 1 {
+		if configPath == "" {
+			var err error
+			if configPath, err = config.GetConfigPath(); err != nil {
+				return err
+			}
+		}
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+		if dataDir != "" {
+			cfg.DataDir = dataDir
+		}
+		storeDir := cfg.DataDir
+		if cfg.Replication.IsReplica() {
+			storeDir = config.ReplicaDir(cfg.DataDir)
+		}
+		store, err := storage.NewStore(storeDir, storage.ReadOnly())
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		source = completion.NewStoreSource(store)
+	}
+
+	candidates, err := spec.Complete(context.Background(), source, words)
+	if err != nil {
+		return err
+	}
+	for _, candidate := range candidates {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// NewCLI creates a new CLI instance with initialized dependencies. The
+// store options apply to a local store, after the profile's.
+func NewCLI(cfg *config.Config, configPath string, storeOpts ...storage.StoreOption) (*CLI, error) {
+	// Record outbound requests and apply the network allowlist. Failing to
+	// set this up is fatal, so an enforced allowlist is never silently dropped.
+	auditor, err := netaudit.NewAuditor(cfg.Network.AuditorConfig(cfg.DataDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize network auditing: %w", err)
+	}
+	netaudit.SetDefault(auditor)
+
 	// Initialize storage
-	store, err := storage.NewStore(cfg.DataDir)
+	store, replica, err := openStore(cfg, storeOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
@@ -188,31 +663,130 @@ func NewCLI(cfg *config.Config, configPath string) (*CLI, error) {
 	// Initialize managers
 	goalManager := core.NewGoalManager(store)
 	objectiveManager := core.NewObjectiveManager(store)
+	objectiveManager.SetWIPLimits(wipLimits(cfg))
+	objectiveManager.SetTimeBoxes(timeBoxes(cfg))
+	objectiveManager.SetSuggestionWeights(suggestionWeights(cfg))
 	methodManager := core.NewMethodManager(store)
 	contextManager := core.NewUserContextManager(store)
 
-	// Initialize LLM router (with mock service for now)
-	llmRouter := llm.NewRouter(&MockLLMService{})
+	// Initialize LLM router (with mock service for now), resuming what it
+	// learned about model performance in earlier runs
+	routerConfig := llm.DefaultRouterConfig()
+	routerConfig.TokenEstimator = llm.NewTokenEstimator(tokenizerConfig(cfg))
+	routerConfig.PerformanceStore = llm.NewStoragePerformanceStore(store)
+	routerConfig.RoutingStore = llm.NewStorageRoutingStore(store, 0)
+	routerConfig.Policy = llm.RoutingPolicy(cfg.Routing.Policy)
+	llmRouter := llm.NewRouter(&MockLLMService{}, routerConfig)
+	if err := llmRouter.LoadPerformance(context.Background()); err != nil {
+		fmt.Printf("Warning: failed to load model performance: %v\n", err)
+	}
+	exchangeLogger, err := llm.NewExchangeLogger(filepath.Join(cfg.DataDir, "exchanges"), llm.DefaultExchangeLogConfig(), nil)
+	if err != nil {
+		fmt.Printf("Warning: failed to initialize exchange logging: %v\n", err)
+	} else {
+		llmRouter.SetExchangeLogger(exchangeLogger)
+	}
 
 	// Initialize ethical framework
 	ethicalFramework := core.NewEthicalFramework(store, llmRouter, contextManager)
 
+	// Initialize dashboard rollups; reads fall back to scanning if this fails
+	rollupManager := core.NewRollupManager(store)
+	if err := rollupManager.Initialize(context.Background()); err != nil {
+		fmt.Printf("Warning: failed to initialize rollups: %v\n", err)
+	}
+
 	return &CLI{
 		config:           cfg,
 		configPath:       configPath,
 		store:            store,
+		replica:          replica,
 		goalManager:      goalManager,
 		objectiveManager: objectiveManager,
 		methodManager:    methodManager,
 		contextManager:   contextManager,
 		ethicalFramework: ethicalFramework,
+		rollupManager:    rollupManager,
 		llmRouter:        llmRouter,
 	}, nil
 }
 
+// replicaSyncTimeout bounds how long startup waits for a replica to catch up.
+const replicaSyncTimeout = 5 * time.Second
+
+// openStore opens the local store or, when a primary is configured, a
+// read-only replica of it. A replica that cannot catch up in time still
+// serves the data it has.
+func openStore(cfg *config.Config, opts ...storage.StoreOption) (*storage.Store, *storage.ReplicaStore, error) {
+	if !cfg.Replication.IsReplica() {
+		store, err := storage.NewStore(cfg.DataDir, append(cfg.Profile.StoreOptions(), opts...)...)
+		return store, nil, err
+	}
+
+	replica, err := storage.OpenReplica(config.ReplicaDir(cfg.DataDir), cfg.Replication.ReplicaConfig(cfg.Profile))
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), replicaSyncTimeout)
+	defer cancel()
+	if err := replica.WaitForSync(ctx); err != nil {
+		fmt.Printf("Warning: %v; showing data as of sequence %d\n", err, replica.Sequence())
+	}
+	return replica.Store, replica, nil
+}
+
+// wipLimits returns the work-in-progress limits from cfg.
+func wipLimits(cfg *config.Config) core.WIPLimits {
+	return core.WIPLimits{
+		MaxInProgress:        cfg.Preferences.MaxInProgressObjectives,
+		MaxInProgressPerGoal: cfg.Preferences.MaxInProgressPerGoal,
+		Goals:                cfg.Preferences.GoalWIPLimits,
+	}
+}
+
+// timeBoxes returns the default time boxes from cfg.
+func timeBoxes(cfg *config.Config) core.TimeBoxes {
+	return core.TimeBoxes{
+		High:   time.Duration(cfg.Preferences.TimeBoxHighMinutes) * time.Minute,
+		Normal: time.Duration(cfg.Preferences.TimeBoxNormalMinutes) * time.Minute,
+		Low:    time.Duration(cfg.Preferences.TimeBoxLowMinutes) * time.Minute,
+	}
+}
+
+// suggestionWeights returns the suggestion weights from cfg, or the defaults
+// if its overrides name an unknown factor.
+func suggestionWeights(cfg *config.Config) core.SuggestionWeights {
+	weights, err := core.DefaultSuggestionWeights().WithOverrides(cfg.Preferences.SuggestionWeights)
+	if err != nil {
+		fmt.Printf("Warning: ignoring suggestion weights: %v\n", err)
+		return core.DefaultSuggestionWeights()
+	}
+	return weights
+}
+
+// tokenizerConfig returns the token counting settings from cfg.
+func tokenizerConfig(cfg *config.Config) llm.TokenizerConfig {
+	return llm.TokenizerConfig{
+		VocabularyDir: cfg.API.Tokenizer.VocabularyDir,
+		Models:        cfg.API.Tokenizer.Models,
+	}
+}
+
 // Close cleans up CLI resources.
 func (cli *CLI) Close() {
-	if cli.store != nil {
+	if cli.rollupManager != nil {
+		if err := cli.rollupManager.Close(context.Background()); err != nil {
+			fmt.Printf("Warning: failed to persist rollups: %v\n", err)
+		}
+	}
+	if cli.llmRouter != nil {
+		if err := cli.llmRouter.SavePerformance(context.Background()); err != nil {
+			fmt.Printf("Warning: failed to save model performance: %v\n", err)
+		}
+	}
+	if cli.replica != nil {
+		cli.replica.Close()
+	} else if cli.store != nil {
 		cli.store.Close()
 	}
 }
@@ -221,7 +795,7 @@ func (cli *CLI) Close() {
 func (cli *CLI) executeCommand(commandName string, args []string) error {
 	command, exists := getCommands()[commandName]
 	if !exists {
-		return fmt.Errorf("unknown command: %s. Use 'help' to see available commands", commandName)
+		return errs.Newf(errs.Validation, "unknown command: %s. Use 'help' to see available commands", commandName).With("command", commandName)
 	}
 
 	return command.Handler(cli, args)
@@ -286,6 +860,36 @@ func readUserInput(prompt string) (string, error) {
 	return strings.TrimSpace(string(line)), nil
 }
 
+// readSecretInput reads a line of input from the user without echoing it,
+// for API keys. When the terminal's echo cannot be turned off, such as when
+// input is piped, the line is read as it is.
+func readSecretInput(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if setTerminalEcho(false) == nil {
+		defer func() {
+			setTerminalEcho(true)
+			fmt.Println()
+		}()
+	}
+	reader := bufio.NewReader(os.Stdin)
+	line, _, err := reader.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(line)), nil
+}
+
+// setTerminalEcho turns the echo of the terminal on standard input on or off.
+func setTerminalEcho(on bool) error {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
 // formatDuration formats a duration in a human-readable way.
 func formatDuration(d time.Duration) string {
 	if d < time.Minute {
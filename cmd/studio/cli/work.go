@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// workLockFile is the lock file, under the data directory, that keeps two
+// work loops from running on the same data.
+const workLockFile = "work.lock"
+
+// work runs actionable objectives unattended until a limit stops it. The
+// first Ctrl-C stops once the objective in progress is done; a second stops
+// at once, leaving its plan at the last checkpoint. Decisions awaiting
+// approval pause the loop until settled with feedback, here or in the GUI.
+func (cli *CLI) work(args []string) error {
+	defaults := core.DefaultWorkLoopLimits()
+	flags := flag.NewFlagSet("work", flag.ContinueOnError)
+	perHour := flags.Int("max-per-hour", defaults.MaxObjectivesPerHour, "Run at most this many objectives in any hour (0 for no limit)")
+	maxSpend := flags.Float64("max-spend", defaults.MaxSessionSpend, "Stop once this session has spent this many dollars (0 for no limit)")
+	maxFailures := flags.Int("max-failures", defaults.MaxConsecutiveFailures, "Stop after this many objectives in a row fail (0 for no limit)")
+	idle := flags.Duration("idle", defaults.IdleInterval, "How long to sleep when there is nothing to do")
+	if err := flags.Parse(args); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if flags.NArg() > 0 {
+		return errs.New(errs.Validation, "usage: work [--max-per-hour n] [--max-spend dollars] [--max-failures n] [--idle duration]")
+	}
+	if *perHour < 0 || *maxSpend < 0 || *maxFailures < 0 || *idle <= 0 {
+		return errs.New(errs.Validation, "limits cannot be negative, and --idle must be positive")
+	}
+
+	router := cli.providerRouter()
+	if err := router.Ready(); err != nil {
+		return err
+	}
+	budget, err := cli.budgetManager()
+	if err != nil {
+		return err
+	}
+	router.SetBudgetManager(budget)
+
+	ethics := core.NewEthicalFramework(cli.store, router, cli.contextManager)
+	executor, loop, err := cli.newLearningLoop(router)
+	if err != nil {
+		return err
+	}
+	limits := core.WorkLoopLimits{
+		MaxObjectivesPerHour:   *perHour,
+		MaxSessionSpend:        *maxSpend,
+		MaxConsecutiveFailures: *maxFailures,
+		IdleInterval:           *idle,
+	}
+	workLoop := core.NewWorkLoop(cli.store, cli.objectiveManager, loop, core.WorkLoopConfig{
+		Limits:   limits,
+		Budget:   budget,
+		Ethics:   ethics,
+		UserID:   cli.config.Session.UserID,
+		LockFile: filepath.Join(cli.config.DataDir, workLockFile),
+		OnEvent: func(event core.WorkLoopEvent) {
+			fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), event.Message)
+		},
+	})
+	// Nobody is at the prompt: decisions wait for feedback given elsewhere
+	executor.SetEthicalReview(ethics, cli.config.Session.UserID, workLoop.AwaitApproval)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	defer func() {
+		signal.Stop(interrupts)
+		close(interrupts)
+	}()
+	go func() {
+		if _, ok := <-interrupts; !ok {
+			return
+		}
+		fmt.Println("\nStopping after the current objective (Ctrl-C again to stop now)")
+		workLoop.Stop()
+		if _, ok := <-interrupts; ok {
+			fmt.Println("\nStopping now; the plan resumes from its checkpoint next time")
+			cancel()
+		}
+	}()
+
+	fmt.Printf("🤖 Working through objectives. Limits: %s\n", formatWorkLimits(limits))
+	status, err := workLoop.Run(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%s\n", status.Summary(time.Now()))
+	return nil
+}
+
+// formatWorkLimits lists the limits a work loop runs under.
+func formatWorkLimits(limits core.WorkLoopLimits) string {
+	var parts []string
+	if limits.MaxObjectivesPerHour > 0 {
+		parts = append(parts, fmt.Sprintf("%d objectives an hour", limits.MaxObjectivesPerHour))
+	}
+	if limits.MaxSessionSpend > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f this session", limits.MaxSessionSpend))
+	}
+	if limits.MaxConsecutiveFailures > 0 {
+		parts = append(parts, fmt.Sprintf("stop after %d failures in a row", limits.MaxConsecutiveFailures))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,401 @@
+// Package completion provides shell completion for the AI Work Studio CLI.
+// The CLI describes its commands with a Spec; Complete turns the words typed
+// so far into candidates, which the shell scripts generated by Script request
+// through the hidden __complete command. Entity IDs are completed from a
+// read-only store, so completing never writes data or waits on a writer.
+//
+// Resolve implements ID prefix matching, letting every ID-taking command
+// accept an unambiguous prefix in place of the full ID.
+package completion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// ArgKind identifies what a positional argument holds.
+type ArgKind string
+
+const (
+	// ArgGoal is a goal ID
+	ArgGoal ArgKind = "goal"
+	// ArgArchivedGoal is the ID of an archived goal
+	ArgArchivedGoal ArgKind = "archived-goal"
+	// ArgObjective is an objective ID
+	ArgObjective ArgKind = "objective"
+	// ArgMethod is a method ID
+	ArgMethod ArgKind = "method"
+	// ArgDecision is the ID of a decision awaiting approval
+	ArgDecision ArgKind = "decision"
+	// ArgImplementedDecision is the ID of an implemented decision that can still be aborted
+	ArgImplementedDecision ArgKind = "implemented-decision"
+	// ArgRule is an approval rule ID
+	ArgRule ArgKind = "rule"
+	// ArgPolicyRule is an active or proposed policy rule ID
+	ArgPolicyRule ArgKind = "policy-rule"
+	// ArgPreference is the ID of a learned preference waiting for confirmation
+	ArgPreference ArgKind = "preference"
+	// ArgContext is the ID of user context that is in use or waiting for confirmation
+	ArgContext ArgKind = "context"
+	// ArgOperation is the ID of a journaled operation that can still be undone
+	ArgOperation ArgKind = "operation"
+	// ArgChoice is one of a fixed set of words, e.g. a subcommand
+	ArgChoice ArgKind = "choice"
+	// ArgCommand is the name of a command
+	ArgCommand ArgKind = "command"
+)
+
+// Arg describes a positional argument.
+type Arg struct {
+	Kind ArgKind
+
+	// Words are the accepted values of an ArgChoice argument
+	Words []string
+}
+
+// Flag describes a command-line flag.
+type Flag struct {
+	Name string // Including the dashes, e.g. "--max"
+
+	// TakesValue is set for flags followed by a value, which is not completed
+	TakesValue bool
+}
+
+// Command describes a CLI command for completion.
+type Command struct {
+	Name        string
+	Description string
+	Args        []Arg
+	Flags       []Flag
+}
+
+// Spec describes the whole command line.
+type Spec struct {
+	Commands []Command
+
+	// Globals are the flags accepted before the command
+	Globals []Flag
+}
+
+// Candidate is a possible completion. Title is shown next to the ID by shells
+// that support descriptions.
+type Candidate struct {
+	ID    string
+	Title string
+}
+
+// String formats the candidate as a line of __complete output: "id<TAB>title".
+func (c Candidate) String() string {
+	if c.Title == "" {
+		return c.ID
+	}
+	return c.ID + "\t" + c.Title
+}
+
+// Source provides the entity IDs that arguments of a kind may take.
+type Source interface {
+	Candidates(ctx context.Context, kind ArgKind) ([]Candidate, error)
+}
+
+// Complete returns the candidates for the last of words, the arguments typed
+// so far after the program name. The last word is the one being completed and
+// may be empty. Global flags before the command are expected to have been
+// removed already.
+func (s Spec) Complete(ctx context.Context, source Source, words []string) ([]Candidate, error) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	// Completing the command itself
+	if len(words) == 1 {
+		if strings.HasPrefix(current, "-") {
+			return matchFlags(s.Globals, current), nil
+		}
+		return matchPrefix(s.commandCandidates(), current), nil
+	}
+
+	cmd, ok := s.command(words[0])
+	if !ok {
+		return nil, nil
+	}
+	if strings.HasPrefix(current, "-") {
+		return matchFlags(cmd.Flags, current), nil
+	}
+
+	// Count the positional arguments before the current word, skipping flags and their values
+	position := 0
+	for i := 1; i < len(words)-1; i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "-") {
+			position++
+			continue
+		}
+		if flag, known := findFlag(cmd.Flags, word); known && flag.TakesValue {
+			if i == len(words)-2 {
+				return nil, nil // Completing a flag value
+			}
+			i++
+		}
+	}
+	if position >= len(cmd.Args) {
+		return nil, nil
+	}
+
+	arg := cmd.Args[position]
+	var candidates []Candidate
+	switch arg.Kind {
+	case ArgChoice:
+		for _, word := range arg.Words {
+			candidates = append(candidates, Candidate{ID: word})
+		}
+	case ArgCommand:
+		candidates = s.commandCandidates()
+	default:
+		var err error
+		if candidates, err = source.Candidates(ctx, arg.Kind); err != nil {
+			return nil, err
+		}
+	}
+	return matchPrefix(candidates, current), nil
+}
+
+// commandCandidates returns the commands, sorted by name.
+func (s Spec) commandCandidates() []Candidate {
+	var candidates []Candidate
+	for _, cmd := range s.Commands {
+		candidates = append(candidates, Candidate{ID: cmd.Name, Title: cmd.Description})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+	return candidates
+}
+
+// command looks up a command by name.
+func (s Spec) command(name string) (Command, bool) {
+	for _, cmd := range s.Commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// findFlag looks up a flag by name.
+func findFlag(flags []Flag, name string) (Flag, bool) {
+	for _, flag := range flags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// matchFlags returns the flags starting with prefix.
+func matchFlags(flags []Flag, prefix string) []Candidate {
+	var candidates []Candidate
+	for _, flag := range flags {
+		if strings.HasPrefix(flag.Name, prefix) {
+			candidates = append(candidates, Candidate{ID: flag.Name})
+		}
+	}
+	return candidates
+}
+
+// matchPrefix returns the candidates whose ID starts with prefix.
+func matchPrefix(candidates []Candidate, prefix string) []Candidate {
+	var matched []Candidate
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate.ID, prefix) {
+			matched = append(matched, candidate)
+		}
+	}
+	return matched
+}
+
+// AmbiguousError is returned by Resolve when a prefix matches several IDs.
+type AmbiguousError struct {
+	Prefix  string
+	Matches []Candidate
+}
+
+func (e *AmbiguousError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ambiguous ID prefix %q matches %d entries:", e.Prefix, len(e.Matches))
+	for _, match := range e.Matches {
+		fmt.Fprintf(&b, "\n  %s", match.ID)
+		if match.Title != "" {
+			fmt.Fprintf(&b, "  %s", match.Title)
+		}
+	}
+	return b.String()
+}
+
+// ErrorCode returns errs.Conflict: the ID exists, but so do others like it.
+func (e *AmbiguousError) ErrorCode() errs.Code {
+	return errs.Conflict
+}
+
+// Resolve expands arg to the full ID it abbreviates. An exact ID match wins;
+// otherwise a prefix matching exactly one candidate resolves to it, and one
+// matching several returns an *AmbiguousError listing them. An arg matching
+// nothing is returned unchanged, so the caller reports it as not found.
+func Resolve(candidates []Candidate, arg string) (string, error) {
+	if arg == "" {
+		return arg, nil
+	}
+	for _, candidate := range candidates {
+		if candidate.ID == arg {
+			return arg, nil
+		}
+	}
+
+	matches := matchPrefix(candidates, arg)
+	switch len(matches) {
+	case 0:
+		return arg, nil
+	case 1:
+		return matches[0].ID, nil
+	default:
+		sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+		return "", &AmbiguousError{Prefix: arg, Matches: matches}
+	}
+}
+
+// StoreSource provides candidates from a store. Open the store with
+// storage.ReadOnly when completing, since completion must never write.
+type StoreSource struct {
+	goals      *core.GoalManager
+	objectives *core.ObjectiveManager
+	methods    *core.MethodManager
+	ethical    *core.EthicalFramework
+	contexts   *core.UserContextManager
+	journal    *core.OperationJournal
+}
+
+// NewStoreSource creates a candidate source backed by store.
+func NewStoreSource(store *storage.Store) *StoreSource {
+	return &StoreSource{
+		goals:      core.NewGoalManager(store),
+		objectives: core.NewObjectiveManager(store),
+		methods:    core.NewMethodManager(store),
+		ethical:    core.NewEthicalFramework(store, nil, nil),
+		contexts:   core.NewUserContextManager(store),
+		journal:    core.NewOperationJournal(store),
+	}
+}
+
+// Candidates returns the IDs and titles of the entities of the given kind.
+func (ss *StoreSource) Candidates(ctx context.Context, kind ArgKind) ([]Candidate, error) {
+	var candidates []Candidate
+	switch kind {
+	case ArgGoal:
+		goals, err := ss.goals.ListGoals(ctx, core.GoalFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, goal := range goals {
+			candidates = append(candidates, Candidate{ID: goal.ID, Title: goal.Title})
+		}
+	case ArgArchivedGoal:
+		archived := core.GoalStatusArchived
+		goals, err := ss.goals.ListGoals(ctx, core.GoalFilter{Status: &archived})
+		if err != nil {
+			return nil, err
+		}
+		for _, goal := range goals {
+			candidates = append(candidates, Candidate{ID: goal.ID, Title: goal.Title})
+		}
+	case ArgObjective:
+		objectives, err := ss.objectives.ListObjectives(ctx, core.ObjectiveFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, objective := range objectives {
+			candidates = append(candidates, Candidate{ID: objective.ID, Title: objective.Title})
+		}
+	case ArgMethod:
+		methods, err := ss.methods.ListMethods(ctx, core.MethodFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, method := range methods {
+			candidates = append(candidates, Candidate{ID: method.ID, Title: method.Name})
+		}
+	case ArgDecision:
+		decisions, err := ss.ethical.ListPendingDecisions(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, decision := range decisions {
+			candidates = append(candidates, Candidate{ID: decision.ID, Title: decision.DecisionContext})
+		}
+	case ArgImplementedDecision:
+		decisions, err := ss.ethical.ListDecisions(ctx, core.DecisionStateImplemented)
+		if err != nil {
+			return nil, err
+		}
+		for _, decision := range decisions {
+			candidates = append(candidates, Candidate{ID: decision.ID, Title: decision.ProposedAction})
+		}
+	case ArgRule:
+		rules, err := ss.ethical.Rules().ListRules(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			candidates = append(candidates, Candidate{ID: rule.ID, Title: rule.String()})
+		}
+	case ArgPolicyRule:
+		rules, err := ss.ethical.ListRules(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			candidates = append(candidates, Candidate{ID: rule.ID, Title: rule.String()})
+		}
+	case ArgPreference:
+		pending, err := ss.contexts.GetPendingContext(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, preference := range pending {
+			candidates = append(candidates, Candidate{ID: preference.ID, Title: preference.Content})
+		}
+	case ArgContext:
+		contexts, err := ss.contexts.ListContexts(ctx, "", core.ContextFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range contexts {
+			candidates = append(candidates, Candidate{ID: entry.ID, Title: entry.Content})
+		}
+	case ArgOperation:
+		operations, err := ss.journal.ListOperations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, operation := range operations {
+			if operation.Undoable() {
+				candidates = append(candidates, Candidate{ID: operation.ID, Title: operation.Summary})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("no candidates for argument kind %q", kind)
+	}
+
+	for i := range candidates {
+		candidates[i].Title = oneLine(candidates[i].Title)
+	}
+	return candidates, nil
+}
+
+// oneLine flattens a title so it fits the line-based __complete output.
+func oneLine(title string) string {
+	return strings.Join(strings.Fields(title), " ")
+}
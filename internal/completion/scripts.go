@@ -0,0 +1,74 @@
+package completion
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Shells lists the shells Script supports.
+var Shells = []string{"bash", "zsh", "fish"}
+
+// nonIdentifier matches characters not allowed in shell function names.
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Script returns the completion script for shell. The script completes
+// program by calling "program __complete <words...>", which prints one
+// "id<TAB>title" candidate per line.
+func Script(shell, program string) (string, error) {
+	var template string
+	switch shell {
+	case "bash":
+		template = bashScript
+	case "zsh":
+		template = zshScript
+	case "fish":
+		template = fishScript
+	default:
+		return "", fmt.Errorf("unsupported shell %q, expected one of: %s", shell, strings.Join(Shells, ", "))
+	}
+
+	function := "_" + nonIdentifier.ReplaceAllString(program, "_") + "_complete"
+	return strings.NewReplacer("PROGRAM", program, "FUNCTION", function).Replace(template), nil
+}
+
+// bashScript drops the titles, since bash cannot show descriptions.
+const bashScript = `# bash completion for PROGRAM
+# Load with: source <(PROGRAM completion bash)
+FUNCTION() {
+    local IFS=$'\n'
+    local candidates
+    candidates=$(PROGRAM __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null) || return
+    COMPREPLY=($(printf '%s\n' "$candidates" | cut -f1))
+}
+complete -o default -F FUNCTION PROGRAM
+`
+
+const zshScript = `#compdef PROGRAM
+# zsh completion for PROGRAM
+# Load with: source <(PROGRAM completion zsh)
+FUNCTION() {
+    local -a candidates
+    local line
+    for line in "${(@f)$(PROGRAM __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        [[ -z $line ]] && continue
+        if [[ $line == *$'\t'* ]]; then
+            candidates+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+        else
+            candidates+=("${line//:/\\:}")
+        fi
+    done
+    _describe 'values' candidates
+}
+compdef FUNCTION PROGRAM
+`
+
+const fishScript = `# fish completion for PROGRAM
+# Load with: PROGRAM completion fish | source
+function FUNCTION
+    set -l words (commandline -opc)
+    set -e words[1]
+    PROGRAM __complete $words (commandline -ct) 2>/dev/null
+end
+complete -c PROGRAM -f -a '(FUNCTION)'
+`
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
 )
 
 // Manager handles configuration file operations with TOML format.
@@ -40,11 +43,13 @@ func NewManagerWithPath(configPath string) *Manager {
 // Environment variables override configuration values.
 func (m *Manager) Load() (*Config, error) {
 	var config *Config
+	firstRun := false
 
 	// Check if config file exists
 	if _, err := os.Stat(m.configPath); os.IsNotExist(err) {
 		// File doesn't exist, use defaults
 		config = DefaultConfig()
+		firstRun = true
 	} else {
 		// File exists, read it
 		var err error
@@ -57,6 +62,27 @@ func (m *Manager) Load() (*Config, error) {
 	// Apply environment variable overrides
 	config.ApplyEnvironmentOverrides()
 
+	// Seed the network allowlist on first run, and for files written before
+	// the network section existed
+	if firstRun || config.Network.Mode == "" {
+		config.SeedAllowlist()
+		if config.Network.Mode == "" {
+			config.Network.Mode = string(netaudit.ModeAudit)
+		}
+		if config.Network.AuditRetentionDays == 0 {
+			config.Network.AuditRetentionDays = 30
+		}
+	}
+
+	// A replica always reaches its primary, which is usually configured by
+	// hand after the allowlist was seeded
+	config.seedReplicationAllowlist()
+
+	// Files written before profiles existed run the standard profile
+	if config.Profile.Name == "" {
+		config.Profile.Name = ProfileStandard
+	}
+
 	// Validate the final configuration
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
@@ -68,6 +94,16 @@ func (m *Manager) Load() (*Config, error) {
 
 // Save writes configuration to file with proper TOML formatting.
 func (m *Manager) Save(config *Config) error {
+	if err := m.write(config); err != nil {
+		return err
+	}
+
+	m.config = config
+	return nil
+}
+
+// write validates configuration and writes it to the file atomically.
+func (m *Manager) write(config *Config) error {
 	// Validate configuration before saving
 	if err := config.Validate(); err != nil {
 		return fmt.Errorf("cannot save invalid configuration: %w", err)
@@ -90,7 +126,28 @@ func (m *Manager) Save(config *Config) error {
 		return fmt.Errorf("failed to save config file: %w", err)
 	}
 
-	m.config = config
+	return nil
+}
+
+// update applies changes to a copy of the loaded configuration, then
+// validates and saves the copy. The loaded configuration takes the changes
+// only once they are saved, so a rejected update leaves it as it was.
+func (m *Manager) update(apply func(c *Config) error) error {
+	if m.config == nil {
+		return fmt.Errorf("configuration not loaded")
+	}
+
+	next := *m.config
+	next.SyncConvenienceFields()
+	if err := apply(&next); err != nil {
+		return err
+	}
+	if err := m.write(&next); err != nil {
+		return err
+	}
+
+	*m.config = next
+	m.config.SyncConvenienceFields()
 	return nil
 }
 
@@ -126,153 +183,286 @@ func (m *Manager) EnsureDataDir() error {
 
 // UpdateStorage updates storage configuration and saves.
 func (m *Manager) UpdateStorage(updates StorageUpdates) error {
-	if m.config == nil {
-		return fmt.Errorf("configuration not loaded")
-	}
-
-	// Apply updates
-	if updates.DataDir != nil {
-		m.config.Storage.DataDir = *updates.DataDir
-	}
-	if updates.BackupEnabled != nil {
-		m.config.Storage.BackupEnabled = *updates.BackupEnabled
-	}
-	if updates.BackupRetention != nil {
-		if *updates.BackupRetention < 1 {
-			return fmt.Errorf("backup retention must be at least 1 day")
+	return m.update(func(c *Config) error {
+		// Apply updates
+		if updates.DataDir != nil {
+			c.Storage.DataDir = *updates.DataDir
+		}
+		if updates.BackupEnabled != nil {
+			c.Storage.BackupEnabled = *updates.BackupEnabled
+		}
+		if updates.BackupRetention != nil {
+			if *updates.BackupRetention < 1 {
+				return fmt.Errorf("backup retention must be at least 1 day")
+			}
+			c.Storage.BackupRetention = *updates.BackupRetention
+		}
+		if updates.BackupDir != nil {
+			c.Storage.BackupDir = *updates.BackupDir
 		}
-		m.config.Storage.BackupRetention = *updates.BackupRetention
-	}
 
-	return m.Save(m.config)
+		return nil
+	})
 }
 
 // UpdateBudget updates budget configuration and saves.
 func (m *Manager) UpdateBudget(updates BudgetUpdates) error {
-	if m.config == nil {
-		return fmt.Errorf("configuration not loaded")
-	}
-
-	// Apply updates with validation
-	if updates.DailyLimit != nil {
-		if *updates.DailyLimit < 0 {
-			return fmt.Errorf("daily limit cannot be negative")
+	return m.update(func(c *Config) error {
+		// Apply updates with validation
+		if updates.DailyLimit != nil {
+			if *updates.DailyLimit < 0 {
+				return fmt.Errorf("daily limit cannot be negative")
+			}
+			c.Budget.DailyLimit = *updates.DailyLimit
 		}
-		m.config.Budget.DailyLimit = *updates.DailyLimit
-	}
-	if updates.MonthlyLimit != nil {
-		if *updates.MonthlyLimit < 0 {
-			return fmt.Errorf("monthly limit cannot be negative")
+		if updates.WeeklyLimit != nil {
+			if *updates.WeeklyLimit < 0 {
+				return fmt.Errorf("weekly limit cannot be negative")
+			}
+			c.Budget.WeeklyLimit = *updates.WeeklyLimit
 		}
-		m.config.Budget.MonthlyLimit = *updates.MonthlyLimit
-	}
-	if updates.PerRequestLimit != nil {
-		if *updates.PerRequestLimit < 0 {
-			return fmt.Errorf("per-request limit cannot be negative")
+		if updates.MonthlyLimit != nil {
+			if *updates.MonthlyLimit < 0 {
+				return fmt.Errorf("monthly limit cannot be negative")
+			}
+			c.Budget.MonthlyLimit = *updates.MonthlyLimit
+		}
+		if updates.PerRequestLimit != nil {
+			if *updates.PerRequestLimit < 0 {
+				return fmt.Errorf("per-request limit cannot be negative")
+			}
+			c.Budget.PerRequestLimit = *updates.PerRequestLimit
+		}
+		if updates.TrackingEnabled != nil {
+			c.Budget.TrackingEnabled = *updates.TrackingEnabled
+		}
+		if updates.Timezone != nil {
+			if _, err := (BudgetConfig{Timezone: *updates.Timezone}).Location(); err != nil {
+				return err
+			}
+			c.Budget.Timezone = *updates.Timezone
+		}
+		if updates.Enforcement != nil {
+			if _, err := (BudgetConfig{Enforcement: *updates.Enforcement}).EnforcementMode(); err != nil {
+				return err
+			}
+			c.Budget.Enforcement = *updates.Enforcement
+		}
+		if updates.GracePercent != nil {
+			if *updates.GracePercent < 0 {
+				return fmt.Errorf("grace percent cannot be negative")
+			}
+			c.Budget.GracePercent = *updates.GracePercent
 		}
-		m.config.Budget.PerRequestLimit = *updates.PerRequestLimit
-	}
-	if updates.TrackingEnabled != nil {
-		m.config.Budget.TrackingEnabled = *updates.TrackingEnabled
-	}
 
-	return m.Save(m.config)
+		return nil
+	})
 }
 
 // UpdatePreferences updates user preferences and saves.
 func (m *Manager) UpdatePreferences(updates PreferenceUpdates) error {
-	if m.config == nil {
-		return fmt.Errorf("configuration not loaded")
-	}
+	return m.update(func(c *Config) error {
+		// Apply updates
+		if updates.AutoApprove != nil {
+			c.Preferences.AutoApprove = *updates.AutoApprove
+		}
+		if updates.VerboseOutput != nil {
+			c.Preferences.VerboseOutput = *updates.VerboseOutput
+		}
+		if updates.LogLevel != nil {
+			c.Preferences.LogLevel = strings.ToLower(*updates.LogLevel)
+		}
+		if updates.DefaultPriority != nil {
+			if *updates.DefaultPriority < 1 || *updates.DefaultPriority > 10 {
+				return fmt.Errorf("default priority must be between 1 and 10")
+			}
+			c.Preferences.DefaultPriority = *updates.DefaultPriority
+		}
+		if updates.InteractiveMode != nil {
+			c.Preferences.InteractiveMode = *updates.InteractiveMode
+		}
+		if updates.ConfirmDestructive != nil {
+			c.Preferences.ConfirmDestructive = *updates.ConfirmDestructive
+		}
+		if updates.MaxInProgressObjectives != nil {
+			if *updates.MaxInProgressObjectives < 0 {
+				return fmt.Errorf("work-in-progress limit cannot be negative")
+			}
+			c.Preferences.MaxInProgressObjectives = *updates.MaxInProgressObjectives
+		}
+		if updates.MaxInProgressPerGoal != nil {
+			if *updates.MaxInProgressPerGoal < 0 {
+				return fmt.Errorf("work-in-progress limit cannot be negative")
+			}
+			c.Preferences.MaxInProgressPerGoal = *updates.MaxInProgressPerGoal
+		}
+		if updates.SuggestionWeights != nil {
+			for factor, weight := range updates.SuggestionWeights {
+				if weight < 0 {
+					return fmt.Errorf("suggestion weight for %s cannot be negative", factor)
+				}
+			}
+			c.Preferences.SuggestionWeights = updates.SuggestionWeights
+		}
+		if updates.QuietHoursStart != nil || updates.QuietHoursEnd != nil {
+			prefs := c.Preferences
+			if updates.QuietHoursStart != nil {
+				prefs.QuietHoursStart = *updates.QuietHoursStart
+			}
+			if updates.QuietHoursEnd != nil {
+				prefs.QuietHoursEnd = *updates.QuietHoursEnd
+			}
+			check := Config{Preferences: prefs}
+			if err := check.validatePreferences(); err != nil {
+				return err
+			}
+			c.Preferences = prefs
+		}
 
-	// Apply updates
-	if updates.AutoApprove != nil {
-		m.config.Preferences.AutoApprove = *updates.AutoApprove
-	}
-	if updates.VerboseOutput != nil {
-		m.config.Preferences.VerboseOutput = *updates.VerboseOutput
-	}
-	if updates.DefaultPriority != nil {
-		if *updates.DefaultPriority < 1 || *updates.DefaultPriority > 10 {
-			return fmt.Errorf("default priority must be between 1 and 10")
+		return nil
+	})
+}
+
+// UpdateRouting updates routing configuration and saves.
+func (m *Manager) UpdateRouting(updates RoutingUpdates) error {
+	return m.update(func(c *Config) error {
+		if updates.Policy != nil {
+			check := Config{Routing: RoutingConfig{Policy: *updates.Policy}}
+			if err := check.validateRouting(); err != nil {
+				return err
+			}
+			c.Routing.Policy = *updates.Policy
 		}
-		m.config.Preferences.DefaultPriority = *updates.DefaultPriority
-	}
-	if updates.InteractiveMode != nil {
-		m.config.Preferences.InteractiveMode = *updates.InteractiveMode
-	}
-	if updates.ConfirmDestructive != nil {
-		m.config.Preferences.ConfirmDestructive = *updates.ConfirmDestructive
-	}
 
-	return m.Save(m.config)
+		return nil
+	})
+}
+
+// UpdateAPIKeys replaces provider API keys and saves.
+func (m *Manager) UpdateAPIKeys(updates APIKeyUpdates) error {
+	return m.update(func(c *Config) error {
+		// Apply updates with validation
+		if updates.Anthropic != nil {
+			if strings.TrimSpace(*updates.Anthropic) == "" {
+				return fmt.Errorf("Anthropic API key cannot be empty")
+			}
+			c.API.Anthropic.APIKey = strings.TrimSpace(*updates.Anthropic)
+		}
+		if updates.OpenAI != nil {
+			if strings.TrimSpace(*updates.OpenAI) == "" {
+				return fmt.Errorf("OpenAI API key cannot be empty")
+			}
+			c.API.OpenAI.APIKey = strings.TrimSpace(*updates.OpenAI)
+		}
+
+		return nil
+	})
+}
+
+// UpdateNetwork updates the network mode and allowlist and saves.
+func (m *Manager) UpdateNetwork(updates NetworkUpdates) error {
+	return m.update(func(c *Config) error {
+		network := c.Network
+		if updates.Mode != nil {
+			network.Mode = *updates.Mode
+		}
+		for _, pattern := range updates.Allow {
+			normalized, err := netaudit.NormalizePattern(pattern)
+			if err != nil {
+				return err
+			}
+			if !contains(network.Allowlist, normalized) {
+				network.Allowlist = append(network.Allowlist, normalized)
+			}
+		}
+		for _, pattern := range updates.Disallow {
+			normalized, err := netaudit.NormalizePattern(pattern)
+			if err != nil {
+				return err
+			}
+			kept := network.Allowlist[:0:0]
+			for _, existing := range network.Allowlist {
+				if existing != normalized {
+					kept = append(kept, existing)
+				}
+			}
+			network.Allowlist = kept
+		}
+		if updates.AuditRetentionDays != nil {
+			network.AuditRetentionDays = *updates.AuditRetentionDays
+		}
+
+		check := Config{Network: network}
+		if err := check.validateNetwork(); err != nil {
+			return err
+		}
+		c.Network = network
+
+		return nil
+	})
 }
 
 // UpdateSession updates session state and saves.
 func (m *Manager) UpdateSession(updates SessionUpdates) error {
-	if m.config == nil {
-		return fmt.Errorf("configuration not loaded")
-	}
-
-	// Apply updates
-	if updates.CurrentGoalID != nil {
-		m.config.Session.CurrentGoalID = *updates.CurrentGoalID
-	}
-	if updates.LastUsedDataDir != nil {
-		m.config.Session.LastUsedDataDir = *updates.LastUsedDataDir
-		// Also update the main data directory
-		m.config.Storage.DataDir = *updates.LastUsedDataDir
-	}
-	if updates.UserID != nil {
-		m.config.Session.UserID = *updates.UserID
-	}
+	return m.update(func(c *Config) error {
+		// Apply updates
+		if updates.CurrentGoalID != nil {
+			c.Session.CurrentGoalID = *updates.CurrentGoalID
+		}
+		if updates.LastUsedDataDir != nil {
+			c.Session.LastUsedDataDir = *updates.LastUsedDataDir
+			// Also update the main data directory
+			c.Storage.DataDir = *updates.LastUsedDataDir
+		}
+		if updates.UserID != nil {
+			c.Session.UserID = *updates.UserID
+		}
 
-	return m.Save(m.config)
+		return nil
+	})
 }
 
 // UpdateWindow updates window preferences and saves.
 func (m *Manager) UpdateWindow(updates WindowUpdates) error {
-	if m.config == nil {
-		return fmt.Errorf("configuration not loaded")
-	}
-
-	// Apply updates with validation
-	if updates.Width != nil {
-		if *updates.Width < 400 {
-			return fmt.Errorf("window width must be at least 400 pixels")
+	return m.update(func(c *Config) error {
+		// Apply updates with validation
+		if updates.Width != nil {
+			if *updates.Width < 400 {
+				return fmt.Errorf("window width must be at least 400 pixels")
+			}
+			c.Window.Width = *updates.Width
 		}
-		m.config.Window.Width = *updates.Width
-	}
-	if updates.Height != nil {
-		if *updates.Height < 300 {
-			return fmt.Errorf("window height must be at least 300 pixels")
+		if updates.Height != nil {
+			if *updates.Height < 300 {
+				return fmt.Errorf("window height must be at least 300 pixels")
+			}
+			c.Window.Height = *updates.Height
 		}
-		m.config.Window.Height = *updates.Height
-	}
-	if updates.X != nil {
-		m.config.Window.X = *updates.X
-	}
-	if updates.Y != nil {
-		m.config.Window.Y = *updates.Y
-	}
-	if updates.Maximized != nil {
-		m.config.Window.Maximized = *updates.Maximized
-	}
-	if updates.ActiveTab != nil {
-		if *updates.ActiveTab < 0 || *updates.ActiveTab > 4 {
-			return fmt.Errorf("active tab must be between 0 and 4")
+		if updates.X != nil {
+			c.Window.X = *updates.X
 		}
-		m.config.Window.ActiveTab = *updates.ActiveTab
-	}
-	if updates.Theme != nil {
-		validThemes := []string{"light", "dark", "auto"}
-		if !contains(validThemes, *updates.Theme) {
-			return fmt.Errorf("invalid theme %q, must be one of: %v", *updates.Theme, validThemes)
+		if updates.Y != nil {
+			c.Window.Y = *updates.Y
+		}
+		if updates.Maximized != nil {
+			c.Window.Maximized = *updates.Maximized
+		}
+		if updates.ActiveTab != nil {
+			if *updates.ActiveTab < 0 || *updates.ActiveTab > 4 {
+				return fmt.Errorf("active tab must be between 0 and 4")
+			}
+			c.Window.ActiveTab = *updates.ActiveTab
+		}
+		if updates.Theme != nil {
+			validThemes := []string{"light", "dark", "auto"}
+			if !contains(validThemes, *updates.Theme) {
+				return fmt.Errorf("invalid theme %q, must be one of: %v", *updates.Theme, validThemes)
+			}
+			c.Window.Theme = *updates.Theme
 		}
-		m.config.Window.Theme = *updates.Theme
-	}
 
-	return m.Save(m.config)
+		return nil
+	})
 }
 
 // GetConfig returns the currently loaded configuration.
@@ -286,18 +476,34 @@ func (m *Manager) readConfigFile() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	return parseConfig(data)
+}
 
+// parseConfig parses a TOML configuration. Keys the schema does not know,
+// such as a misspelled setting, are refused rather than ignored, and so are
+// values of the wrong type.
+func parseConfig(data []byte) (*Config, error) {
 	var config Config
-	if err := toml.Unmarshal(data, &config); err != nil {
+	meta, err := toml.Decode(string(data), &config)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse TOML config: %w", err)
 	}
 
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return nil, fmt.Errorf("unknown configuration keys: %s", strings.Join(keys, ", "))
+	}
+
 	return &config, nil
 }
 
 // writeConfigFile writes configuration to TOML file with proper formatting.
 func (m *Manager) writeConfigFile(config *Config, path string) error {
-	file, err := os.Create(path)
+	// The file may hold API keys, so only its owner may read a new one
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
@@ -321,23 +527,57 @@ type StorageUpdates struct {
 	DataDir         *string
 	BackupEnabled   *bool
 	BackupRetention *int
+	BackupDir       *string
 }
 
 // BudgetUpdates contains optional budget configuration updates.
 type BudgetUpdates struct {
 	DailyLimit      *float64
+	WeeklyLimit     *float64
 	MonthlyLimit    *float64
 	PerRequestLimit *float64
 	TrackingEnabled *bool
+	Timezone        *string
+	Enforcement     *string
+	GracePercent    *float64
+}
+
+// RoutingUpdates contains optional routing configuration updates.
+type RoutingUpdates struct {
+	Policy *string
 }
 
 // PreferenceUpdates contains optional preference updates.
 type PreferenceUpdates struct {
 	AutoApprove        *bool
 	VerboseOutput      *bool
+	LogLevel           *string
 	DefaultPriority    *int
 	InteractiveMode    *bool
 	ConfirmDestructive *bool
+	QuietHoursStart    *string
+	QuietHoursEnd      *string
+
+	MaxInProgressObjectives *int
+	MaxInProgressPerGoal    *int
+
+	// SuggestionWeights replaces the suggestion weight overrides when not nil
+	SuggestionWeights map[string]float64
+}
+
+// APIKeyUpdates contains optional provider API key updates.
+type APIKeyUpdates struct {
+	Anthropic *string
+	OpenAI    *string
+}
+
+// NetworkUpdates contains optional network updates. Allow and Disallow add
+// and remove allowlist patterns.
+type NetworkUpdates struct {
+	Mode               *string
+	Allow              []string
+	Disallow           []string
+	AuditRetentionDays *int
 }
 
 // SessionUpdates contains optional session updates.
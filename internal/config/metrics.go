@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// MetricsConfig sets up the endpoint the agent serves metrics on for
+// scraping by Prometheus.
+type MetricsConfig struct {
+	// Listen is the address the agent serves /metrics on ("" to not
+	// serve, and to record no metrics), e.g. "127.0.0.1:9464"
+	Listen string `toml:"listen"`
+}
+
+// Enabled reports whether the agent serves metrics.
+func (m MetricsConfig) Enabled() bool {
+	return m.Listen != ""
+}
+
+// validateMetrics validates metrics configuration.
+func (c *Config) validateMetrics() error {
+	if c.Metrics.Listen == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(c.Metrics.Listen); err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", c.Metrics.Listen, err)
+	}
+	return nil
+}
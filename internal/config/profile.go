@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// Operating profiles.
+const (
+	// ProfileStandard keeps indexes and caches in memory for speed
+	ProfileStandard = "standard"
+
+	// ProfileLowMemory fits an always-on host with little memory, such as a
+	// Raspberry Pi, trading speed for a small footprint
+	ProfileLowMemory = "low_memory"
+)
+
+// Dashboard refresh intervals; each refresh reads every rollup.
+const (
+	standardRefreshInterval  = 30 * time.Second
+	lowMemoryRefreshInterval = 5 * time.Minute
+)
+
+// defaultLowMemoryLimitMB is the memory budget of the low-memory profile
+// when none is configured.
+const defaultLowMemoryLimitMB = 256
+
+// ProfileConfig selects an operating profile. Every setting a profile
+// changes is derived from it, rather than configured one knob at a time.
+type ProfileConfig struct {
+	// Name is "standard" or "low_memory"
+	Name string `toml:"name"`
+
+	// MemoryLimitMB is the memory budget the watchdog sheds load to stay
+	// under (0: none for standard, 256 for low_memory)
+	MemoryLimitMB int `toml:"memory_limit_mb"`
+}
+
+// LowMemory reports whether the low-memory profile is selected.
+func (p ProfileConfig) LowMemory() bool {
+	return p.Name == ProfileLowMemory
+}
+
+// StoreOptions returns the options stores are opened with.
+func (p ProfileConfig) StoreOptions() []storage.StoreOption {
+	if p.LowMemory() {
+		return []storage.StoreOption{storage.LowMemory()}
+	}
+	return nil
+}
+
+// WorkerLimit caps a worker pool size for the profile.
+func (p ProfileConfig) WorkerLimit(size int) int {
+	if p.LowMemory() && size > 1 {
+		return 1
+	}
+	return size
+}
+
+// RefreshInterval returns how often dashboards refresh their rollups.
+func (p ProfileConfig) RefreshInterval() time.Duration {
+	if p.LowMemory() {
+		return lowMemoryRefreshInterval
+	}
+	return standardRefreshInterval
+}
+
+// MemoryCeiling returns the watchdog's memory budget in bytes (0: no watchdog).
+func (p ProfileConfig) MemoryCeiling() uint64 {
+	limitMB := p.MemoryLimitMB
+	if limitMB == 0 && p.LowMemory() {
+		limitMB = defaultLowMemoryLimitMB
+	}
+	return uint64(limitMB) << 20
+}
+
+// Feature describes something a profile can turn down.
+type Feature struct {
+	Name        string
+	Description string
+	Enabled     bool
+
+	// Degraded says what is lost when the feature is turned down ("" if it is not)
+	Degraded string
+}
+
+// Features lists the features the profile controls, with what each loses
+// under it, for the status command.
+func (p ProfileConfig) Features() []Feature {
+	low := p.LowMemory()
+	ceiling := p.MemoryCeiling()
+
+	features := []Feature{
+		{
+			Name:        "search_index",
+			Description: "In-memory word index for search",
+			Enabled:     !low,
+		},
+		{
+			Name:        "archive_cache",
+			Description: "Archive segments kept in memory once read",
+			Enabled:     !low,
+		},
+		{
+			Name:        "parallel_objectives",
+			Description: "Up to 3 objectives run by the agent at once",
+			Enabled:     !low,
+		},
+		{
+			Name:        "dashboard_refresh",
+			Description: fmt.Sprintf("Dashboard auto-refresh every %s", p.RefreshInterval()),
+			Enabled:     true,
+		},
+		{
+			Name:        "memory_watchdog",
+			Description: "Sheds load near the memory budget",
+			Enabled:     ceiling > 0,
+		},
+	}
+	if low {
+		features[0].Degraded = "search scans every node, so it slows as the store grows"
+		features[1].Degraded = "only the last segment read is cached; archived queries reread segments from disk"
+		features[2].Degraded = "the agent runs one objective at a time"
+		features[3].Degraded = "dashboard counts can be up to 5 minutes old"
+	}
+	if ceiling > 0 {
+		features[4].Description = fmt.Sprintf("Sheds load near the %d MB memory budget", ceiling>>20)
+		features[4].Degraded = "background jobs pause and caches are dropped while memory is high"
+	}
+	return features
+}
+
+// validateProfile validates profile configuration.
+func (c *Config) validateProfile() error {
+	if !contains([]string{ProfileStandard, ProfileLowMemory}, c.Profile.Name) {
+		return fmt.Errorf("unknown profile %q, must be %q or %q", c.Profile.Name, ProfileStandard, ProfileLowMemory)
+	}
+	if c.Profile.MemoryLimitMB < 0 {
+		return fmt.Errorf("memory limit cannot be negative, got %d", c.Profile.MemoryLimitMB)
+	}
+	return nil
+}
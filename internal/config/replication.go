@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
+)
+
+// ReplicationConfig sets up a hot-standby read replica of the store: the
+// always-on host listens, and desktop machines follow it.
+type ReplicationConfig struct {
+	// Listen is the address the agent serves replicas on ("" to not
+	// serve), e.g. "127.0.0.1:7420"
+	Listen string `toml:"listen"`
+
+	// Token authenticates replicas; required unless Listen is a loopback address
+	Token string `toml:"token"`
+
+	// Primary is the connection string of the store to follow, e.g.
+	// "studio://TOKEN@pi.local:7420". When set, the CLI and GUI open a
+	// read-only replica instead of the local store.
+	Primary string `toml:"primary"`
+}
+
+// IsReplica reports whether this machine follows a primary store.
+func (r ReplicationConfig) IsReplica() bool {
+	return r.Primary != ""
+}
+
+// ServerConfig returns the replication listener settings, with the status
+// file kept under dataDir for the status command.
+func (r ReplicationConfig) ServerConfig(dataDir string) storage.ReplicationConfig {
+	config := storage.DefaultReplicationConfig()
+	config.Addr = r.Listen
+	config.Token = r.Token
+	config.StatusFile = ReplicationStatusFile(dataDir)
+	return config
+}
+
+// ReplicaConfig returns the settings a replica follows the primary with.
+func (r ReplicationConfig) ReplicaConfig(profile ProfileConfig) storage.ReplicaConfig {
+	return storage.ReplicaConfig{
+		Primary:      r.Primary,
+		StoreOptions: profile.StoreOptions(),
+	}
+}
+
+// ReplicaDir returns where the replica copy of the primary's store is kept,
+// apart from any local store in dataDir.
+func ReplicaDir(dataDir string) string {
+	return filepath.Join(dataDir, "replica")
+}
+
+// ReplicationStatusFile returns where the agent reports replication health.
+func ReplicationStatusFile(dataDir string) string {
+	return filepath.Join(dataDir, "replication", "status.json")
+}
+
+// validateReplication validates replication configuration.
+func (c *Config) validateReplication() error {
+	r := c.Replication
+	if r.Listen != "" && r.Primary != "" {
+		return fmt.Errorf("a store cannot both serve replicas and follow a primary")
+	}
+	if r.Listen != "" && r.Token == "" && !storage.IsLoopbackAddr(r.Listen) {
+		return fmt.Errorf("a token is required to listen on %s, which is not a loopback address", r.Listen)
+	}
+	if r.Primary != "" {
+		if _, _, err := storage.ParsePrimary(r.Primary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedReplicationAllowlist adds the primary's host and port to the
+// allowlist, so a replica keeps working in enforce mode.
+func (c *Config) seedReplicationAllowlist() {
+	addr, _, err := storage.ParsePrimary(c.Replication.Primary)
+	if err != nil {
+		return
+	}
+	pattern, err := netaudit.NormalizePattern(addr)
+	if err != nil || contains(c.Network.Allowlist, pattern) {
+		return
+	}
+	c.Network.Allowlist = append(c.Network.Allowlist, pattern)
+}
@@ -7,6 +7,12 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
 )
 
 // Config represents the complete application configuration.
@@ -20,9 +26,21 @@ type Config struct {
 	// Budget limits for cost management
 	Budget BudgetConfig `toml:"budget"`
 
+	// Model routing preferences
+	Routing RoutingConfig `toml:"routing"`
+
 	// Permission settings for security
 	Permissions PermissionConfig `toml:"permissions"`
 
+	// Outbound network auditing and allowlist
+	Network NetworkConfig `toml:"network"`
+
+	// Audit log of LLM prompts and responses
+	Audit AuditConfig `toml:"audit"`
+
+	// Cache of LLM completions for repeated requests
+	CompletionCache CompletionCacheConfig `toml:"completion_cache"`
+
 	// User preferences for behavior customization
 	Preferences PreferenceConfig `toml:"preferences"`
 
@@ -32,6 +50,18 @@ type Config struct {
 	// Current session state
 	Session SessionConfig `toml:"session"`
 
+	// Operating profile, e.g. low memory for a Raspberry Pi
+	Profile ProfileConfig `toml:"profile"`
+
+	// Read replica of the store over the local network
+	Replication ReplicationConfig `toml:"replication"`
+
+	// Metrics endpoint for Prometheus
+	Metrics MetricsConfig `toml:"metrics"`
+
+	// Provider API keys by provider name; the OS keyring is preferred
+	Credentials map[string]string `toml:"credentials,omitempty"`
+
 	// Convenience fields for CLI/UI/Agent compatibility (not serialized)
 	DataDir      string        `toml:"-"`
 	BudgetLimits *BudgetConfig `toml:"-"`
@@ -98,12 +128,36 @@ func (c *Config) UpdateSession(path string, updates SessionUpdates) error {
 	return manager.UpdateSession(updates)
 }
 
+// UpdateAPIKeys replaces provider API keys and saves the configuration.
+func (c *Config) UpdateAPIKeys(path string, updates APIKeyUpdates) error {
+	manager := &Manager{configPath: path, config: c}
+	return manager.UpdateAPIKeys(updates)
+}
+
+// UpdateNetwork updates the network mode and allowlist and saves to file
+func (c *Config) UpdateNetwork(path string, updates NetworkUpdates) error {
+	manager := &Manager{configPath: path, config: c}
+	return manager.UpdateNetwork(updates)
+}
+
 // UpdateBudgetLimits updates budget limits and saves to file
 func (c *Config) UpdateBudgetLimits(path string, updates BudgetUpdates) error {
 	manager := &Manager{configPath: path, config: c}
 	return manager.UpdateBudget(updates)
 }
 
+// UpdateRouting updates routing settings and saves to file
+func (c *Config) UpdateRouting(path string, updates RoutingUpdates) error {
+	manager := &Manager{configPath: path, config: c}
+	return manager.UpdateRouting(updates)
+}
+
+// UpdateStorage updates storage settings and saves to file
+func (c *Config) UpdateStorage(path string, updates StorageUpdates) error {
+	manager := &Manager{configPath: path, config: c}
+	return manager.UpdateStorage(updates)
+}
+
 // UpdatePreferences updates user preferences and saves to file
 func (c *Config) UpdatePreferences(path string, updates PreferenceUpdates) error {
 	manager := &Manager{configPath: path, config: c}
@@ -144,6 +198,16 @@ type StorageConfig struct {
 
 	// BackupRetention is the number of days to keep backups
 	BackupRetention int `toml:"backup_retention_days"`
+
+	// BackupDir is where incremental backups are written, e.g. an external
+	// drive or synced folder. Scheduled backups are off while it is empty.
+	BackupDir string `toml:"backup_dir"`
+
+	// BackupKeepDaily is how many daily backups rotation keeps (0 uses the default)
+	BackupKeepDaily int `toml:"backup_keep_daily"`
+
+	// BackupKeepWeekly is how many weekly backups rotation keeps (0 uses the default)
+	BackupKeepWeekly int `toml:"backup_keep_weekly"`
 }
 
 // APIConfig contains settings for LLM service APIs.
@@ -159,6 +223,59 @@ type APIConfig struct {
 
 	// DefaultProvider specifies which provider to use by default
 	DefaultProvider string `toml:"default_provider"`
+
+	// Tokenizer configures exact token counting for model routing
+	Tokenizer TokenizerConfig `toml:"tokenizer"`
+
+	// Limits bounds the traffic sent to each provider, by provider name
+	// ("anthropic", "openai", "local"); providers without an entry are unlimited
+	Limits map[string]ProviderLimitsConfig `toml:"limits"`
+}
+
+// ProviderLimitsConfig bounds the traffic sent to one provider. Requests over
+// a limit wait for room. Zero fields are unlimited.
+type ProviderLimitsConfig struct {
+	RequestsPerMinute int `toml:"requests_per_minute"`
+	TokensPerMinute   int `toml:"tokens_per_minute"`
+	MaxConcurrent     int `toml:"max_concurrent"`
+}
+
+// Keys returns the configured API keys by provider name, omitting providers without one.
+func (a APIConfig) Keys() map[string]string {
+	keys := make(map[string]string)
+	if a.Anthropic.APIKey != "" {
+		keys["anthropic"] = a.Anthropic.APIKey
+	}
+	if a.OpenAI.APIKey != "" {
+		keys["openai"] = a.OpenAI.APIKey
+	}
+	return keys
+}
+
+// CredentialKeys returns the provider API keys the configuration file holds:
+// the credentials section, then any api.<provider>.api_key it does not
+// override.
+func (c *Config) CredentialKeys() map[string]string {
+	keys := c.API.Keys()
+	for provider, key := range c.Credentials {
+		if strings.TrimSpace(key) != "" {
+			keys[provider] = strings.TrimSpace(key)
+		}
+	}
+	return keys
+}
+
+// CredentialsFileWarning returns a warning when the configuration file at
+// path holds API keys and other users can read it, or "" when it does not.
+func CredentialsFileWarning(path string, cfg *Config) string {
+	if len(cfg.CredentialKeys()) == 0 {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0o004 == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s holds API keys and is readable by every user; run 'chmod 600 %s' or move the keys to the OS keyring", path, path)
 }
 
 // AnthropicConfig contains Anthropic Claude API settings.
@@ -197,11 +314,28 @@ type LocalConfig struct {
 	ServerURL string `toml:"server_url"`
 }
 
+// TokenizerConfig contains token counting settings. Vocabularies are read
+// from local files only and never downloaded.
+type TokenizerConfig struct {
+	// VocabularyDir holds BPE vocabulary files named <vocabulary>.tiktoken,
+	// e.g. cl100k_base.tiktoken; models without one use a heuristic count
+	// calibrated for their family
+	VocabularyDir string `toml:"vocabulary_dir"`
+
+	// Models maps model names to vocabulary names, e.g. to approximate
+	// Anthropic models with an OpenAI vocabulary. A trailing "*" matches by prefix.
+	Models map[string]string `toml:"models"`
+}
+
 // BudgetConfig defines spending limits for LLM usage.
 type BudgetConfig struct {
 	// DailyLimit is the maximum daily spend (in USD)
 	DailyLimit float64 `toml:"daily_limit"`
 
+	// WeeklyLimit is the maximum spend in a Monday-to-Sunday week (in USD,
+	// 0 for no weekly limit)
+	WeeklyLimit float64 `toml:"weekly_limit"`
+
 	// MonthlyLimit is the maximum monthly spend (in USD)
 	MonthlyLimit float64 `toml:"monthly_limit"`
 
@@ -210,6 +344,131 @@ type BudgetConfig struct {
 
 	// TrackingEnabled determines if usage tracking is active
 	TrackingEnabled bool `toml:"tracking_enabled"`
+
+	// Timezone is the IANA time zone whose midnights start budget days,
+	// weeks and months, such as "Europe/Paris" (empty for the local zone)
+	Timezone string `toml:"timezone"`
+
+	// Enforcement is how spending past a limit is treated: "hard" refuses
+	// it, "soft" lets running execution plans spend into GracePercent past
+	// it, and "warn" only warns (empty for "hard")
+	Enforcement string `toml:"enforcement"`
+
+	// GracePercent is how far past a limit, as a percentage of it, running
+	// plans may spend under "soft" enforcement (0 for the default, 10%)
+	GracePercent float64 `toml:"grace_percent"`
+}
+
+// EnforcementMode returns the enforcement mode named by Enforcement.
+func (b BudgetConfig) EnforcementMode() (mcp.BudgetEnforcement, error) {
+	return mcp.ParseBudgetEnforcement(b.Enforcement)
+}
+
+// ManagerConfig returns the budget manager settings for these limits.
+func (b BudgetConfig) ManagerConfig() (llm.BudgetConfig, error) {
+	location, err := b.Location()
+	if err != nil {
+		return llm.BudgetConfig{}, err
+	}
+	enforcement, err := b.EnforcementMode()
+	if err != nil {
+		return llm.BudgetConfig{}, err
+	}
+	return llm.BudgetConfig{
+		DailyLimit:      b.DailyLimit,
+		WeeklyLimit:     b.WeeklyLimit,
+		MonthlyLimit:    b.MonthlyLimit,
+		TrackingEnabled: b.TrackingEnabled,
+		Location:        location,
+		Enforcement:     enforcement,
+		GracePercent:    b.GracePercent,
+	}, nil
+}
+
+// Location returns the time zone named by Timezone.
+func (b BudgetConfig) Location() (*time.Location, error) {
+	if b.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(b.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid budget timezone %q: %w", b.Timezone, err)
+	}
+	return loc, nil
+}
+
+// RoutingPolicies are the names RoutingConfig.Policy accepts, cheapest first.
+var RoutingPolicies = []string{"cost-saver", "balanced", "quality-first"}
+
+// RoutingConfig defines how requests are routed to models.
+type RoutingConfig struct {
+	// Policy is the preset models are chosen by: "cost-saver" for the
+	// cheapest, local models first, "balanced", or "quality-first" for the
+	// best available regardless of cost
+	Policy string `toml:"policy"`
+}
+
+// AuditConfig defines the audit log of LLM requests, kept under the data
+// directory and rotated by size like the application log.
+type AuditConfig struct {
+	// Enabled records every completion and embedding with its full prompt
+	// and response
+	Enabled bool `toml:"enabled"`
+
+	// Redact stores SHA-256 hashes in place of prompts and responses,
+	// keeping their lengths and the rest of each entry
+	Redact bool `toml:"redact"`
+
+	// RedactComponents overrides Redact for requests from the named
+	// components, e.g. ethics = true
+	RedactComponents map[string]bool `toml:"redact_components"`
+
+	// MaxSizeMB is the size in megabytes at which the log is rotated
+	MaxSizeMB int `toml:"max_size_mb"`
+
+	// MaxBackups is how many rotated files are kept (0: all of them)
+	MaxBackups int `toml:"max_backups"`
+
+	// MaxAgeDays removes rotated files older than this (0: kept regardless of age)
+	MaxAgeDays int `toml:"max_age_days"`
+}
+
+// AuditLogConfig returns the LLM audit log settings, with the log kept
+// under dataDir.
+func (a AuditConfig) AuditLogConfig(dataDir string) mcp.AuditConfig {
+	return mcp.AuditConfig{
+		Path:             filepath.Join(dataDir, "audit", "llm-audit.jsonl"),
+		MaxSizeMB:        a.MaxSizeMB,
+		MaxBackups:       a.MaxBackups,
+		MaxAgeDays:       a.MaxAgeDays,
+		Redact:           a.Redact,
+		RedactComponents: a.RedactComponents,
+	}
+}
+
+// CompletionCacheConfig defines the cache of LLM completions, kept under the
+// data directory, which answers a request made before without calling the
+// provider again.
+type CompletionCacheConfig struct {
+	// Enabled serves repeated completion requests from the cache
+	Enabled bool `toml:"enabled"`
+
+	// MaxEntries is how many completions are kept, the least recently used
+	// being evicted first
+	MaxEntries int `toml:"max_entries"`
+
+	// MaxTemperature is the highest temperature whose completions are cached
+	MaxTemperature float64 `toml:"max_temperature"`
+}
+
+// CacheConfig returns the completion cache settings, with the cache kept
+// under dataDir.
+func (c CompletionCacheConfig) CacheConfig(dataDir string) mcp.CompletionCacheConfig {
+	return mcp.CompletionCacheConfig{
+		Path:           filepath.Join(dataDir, "cache", "completions.json"),
+		MaxEntries:     c.MaxEntries,
+		MaxTemperature: c.MaxTemperature,
+	}
 }
 
 // PermissionConfig defines security and access control settings.
@@ -230,6 +489,51 @@ type PermissionConfig struct {
 	RequireConfirmation []string `toml:"require_confirmation"`
 }
 
+// NetworkConfig controls the audit log of outbound network requests and the
+// allowlist of hosts they may reach.
+type NetworkConfig struct {
+	// Mode is "audit" to record every destination, or "enforce" to also
+	// block hosts outside the allowlist
+	Mode string `toml:"mode"`
+
+	// Allowlist holds permitted hosts ("api.openai.com"), subdomain
+	// wildcards ("*.example.com") or hosts with a port ("localhost:8080")
+	Allowlist []string `toml:"allowlist"`
+
+	// AuditRetentionDays is how many days of the audit log are kept
+	AuditRetentionDays int `toml:"audit_retention_days"`
+}
+
+// AuditorConfig returns the network auditor settings, with the audit log
+// kept under dataDir.
+func (n NetworkConfig) AuditorConfig(dataDir string) netaudit.Config {
+	return netaudit.Config{
+		Mode:          netaudit.Mode(n.Mode),
+		Allowlist:     n.Allowlist,
+		Dir:           filepath.Join(dataDir, "network"),
+		RetentionDays: n.AuditRetentionDays,
+	}
+}
+
+// SeedAllowlist adds the hosts of the enabled providers to the allowlist,
+// so switching to enforce mode does not cut off the configured providers.
+func (c *Config) SeedAllowlist() {
+	urls := []string{c.API.Anthropic.BaseURL, c.API.OpenAI.BaseURL}
+	if c.API.Local.Enabled {
+		urls = append(urls, c.API.Local.ServerURL)
+	}
+	for _, rawURL := range urls {
+		if rawURL == "" {
+			continue
+		}
+		pattern, err := netaudit.NormalizePattern(rawURL)
+		if err != nil || contains(c.Network.Allowlist, pattern) {
+			continue
+		}
+		c.Network.Allowlist = append(c.Network.Allowlist, pattern)
+	}
+}
+
 // PreferenceConfig contains user behavior preferences.
 type PreferenceConfig struct {
 	// AutoApprove determines if low-risk operations are auto-approved
@@ -238,6 +542,10 @@ type PreferenceConfig struct {
 	// VerboseOutput enables detailed logging and status information
 	VerboseOutput bool `toml:"verbose_output"`
 
+	// LogLevel is the least severe level logged: "debug", "info", "warning"
+	// or "error" (empty for "info", or "debug" with VerboseOutput)
+	LogLevel string `toml:"log_level"`
+
 	// DefaultPriority is the default priority for new goals (1-10)
 	DefaultPriority int `toml:"default_priority"`
 
@@ -246,6 +554,83 @@ type PreferenceConfig struct {
 
 	// ConfirmDestructive requires confirmation for destructive operations
 	ConfirmDestructive bool `toml:"confirm_destructive"`
+
+	// QuietHoursStart begins the daily window ("HH:MM") for background
+	// maintenance such as backups; empty means no quiet hours
+	QuietHoursStart string `toml:"quiet_hours_start"`
+
+	// QuietHoursEnd ends the quiet-hours window ("HH:MM"); it may be earlier
+	// than the start for a window that spans midnight
+	QuietHoursEnd string `toml:"quiet_hours_end"`
+
+	// MaxInProgressObjectives caps objectives in progress at once across all
+	// goals; 0 means no limit
+	MaxInProgressObjectives int `toml:"max_in_progress_objectives"`
+
+	// MaxInProgressPerGoal caps objectives in progress within each goal; 0 means no limit
+	MaxInProgressPerGoal int `toml:"max_in_progress_per_goal"`
+
+	// GoalWIPLimits overrides MaxInProgressPerGoal for individual goals, by goal ID
+	GoalWIPLimits map[string]int `toml:"goal_wip_limits"`
+
+	// MinePreferences lets the agent infer candidate preferences from how
+	// objective outputs are rated and edited; candidates wait for confirmation
+	MinePreferences bool `toml:"mine_preferences"`
+
+	// PreferenceMiningWeeklyLimit caps preference mining spending per week, in dollars
+	PreferenceMiningWeeklyLimit float64 `toml:"preference_mining_weekly_limit"`
+
+	// TimeBoxHighMinutes is the default time box for objectives of priority
+	// 8-10, in minutes of active execution; 0 means no limit
+	TimeBoxHighMinutes int `toml:"time_box_high_minutes"`
+
+	// TimeBoxNormalMinutes is the default time box for priority 4-7; 0 means no limit
+	TimeBoxNormalMinutes int `toml:"time_box_normal_minutes"`
+
+	// TimeBoxLowMinutes is the default time box for priority 1-3; 0 means no limit
+	TimeBoxLowMinutes int `toml:"time_box_low_minutes"`
+
+	// SuggestionWeights overrides the weights of the next-objective
+	// suggestion factors, by factor name (e.g. "priority", "due")
+	SuggestionWeights map[string]float64 `toml:"suggestion_weights"`
+}
+
+// Verbosity returns the least severe level logged, from LogLevel or else
+// VerboseOutput.
+func (p PreferenceConfig) Verbosity() utils.LogLevel {
+	if p.LogLevel != "" {
+		if level, err := utils.ParseLogLevel(p.LogLevel); err == nil {
+			return level
+		}
+	}
+	if p.VerboseOutput {
+		return utils.LogLevelDebug
+	}
+	return utils.LogLevelInfo
+}
+
+// HasQuietHours reports whether a quiet-hours window is configured.
+func (p PreferenceConfig) HasQuietHours() bool {
+	return p.QuietHoursStart != "" && p.QuietHoursEnd != ""
+}
+
+// InQuietHours reports whether t falls within the quiet-hours window.
+// It returns false when no valid window is configured.
+func (p PreferenceConfig) InQuietHours(t time.Time) bool {
+	start, err := parseClock(p.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(p.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
 }
 
 // WindowConfig contains GUI window settings.
@@ -312,6 +697,9 @@ func DefaultConfig() *Config {
 				ServerURL: "http://localhost:8080",
 			},
 			DefaultProvider: "anthropic",
+			Tokenizer: TokenizerConfig{
+				VocabularyDir: filepath.Join(homeDir, ".ai-work-studio", "vocabularies"),
+			},
 		},
 		Budget: BudgetConfig{
 			DailyLimit:      5.00,
@@ -334,12 +722,26 @@ func DefaultConfig() *Config {
 			AllowFileWrites:     true,
 			RequireConfirmation: []string{"delete", "move", "rename"},
 		},
+		Network: NetworkConfig{
+			Mode:               string(netaudit.ModeAudit),
+			AuditRetentionDays: 30,
+		},
+		Audit: AuditConfig{
+			MaxSizeMB:  50,
+			MaxBackups: 10,
+		},
+		CompletionCache: CompletionCacheConfig{
+			MaxEntries:     mcp.DefaultCacheEntries,
+			MaxTemperature: mcp.DefaultCacheMaxTemperature,
+		},
 		Preferences: PreferenceConfig{
-			AutoApprove:        false,
-			VerboseOutput:      false,
-			DefaultPriority:    5,
-			InteractiveMode:    true,
-			ConfirmDestructive: true,
+			AutoApprove:                 false,
+			VerboseOutput:               false,
+			DefaultPriority:             5,
+			InteractiveMode:             true,
+			ConfirmDestructive:          true,
+			MaxInProgressObjectives:     5,
+			PreferenceMiningWeeklyLimit: 0.10,
 		},
 		Window: WindowConfig{
 			Width:     1200,
@@ -350,11 +752,17 @@ func DefaultConfig() *Config {
 			ActiveTab: 0,
 			Theme:     "auto",
 		},
+		Routing: RoutingConfig{
+			Policy: "balanced",
+		},
 		Session: SessionConfig{
 			CurrentGoalID:   "",
 			LastUsedDataDir: defaultDataDir,
 			UserID:          "default-user",
 		},
+		Profile: ProfileConfig{
+			Name: ProfileStandard,
+		},
 	}
 
 	// Initialize convenience fields
@@ -376,10 +784,26 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("budget validation failed: %w", err)
 	}
 
+	if err := c.validateRouting(); err != nil {
+		return fmt.Errorf("routing validation failed: %w", err)
+	}
+
 	if err := c.validatePermissions(); err != nil {
 		return fmt.Errorf("permissions validation failed: %w", err)
 	}
 
+	if err := c.validateNetwork(); err != nil {
+		return fmt.Errorf("network validation failed: %w", err)
+	}
+
+	if err := c.validateAudit(); err != nil {
+		return fmt.Errorf("audit validation failed: %w", err)
+	}
+
+	if err := c.validateCompletionCache(); err != nil {
+		return fmt.Errorf("completion cache validation failed: %w", err)
+	}
+
 	if err := c.validatePreferences(); err != nil {
 		return fmt.Errorf("preferences validation failed: %w", err)
 	}
@@ -392,6 +816,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("session validation failed: %w", err)
 	}
 
+	if err := c.validateProfile(); err != nil {
+		return fmt.Errorf("profile validation failed: %w", err)
+	}
+
+	if err := c.validateReplication(); err != nil {
+		return fmt.Errorf("replication validation failed: %w", err)
+	}
+
+	if err := c.validateMetrics(); err != nil {
+		return fmt.Errorf("metrics validation failed: %w", err)
+	}
+
 	return nil
 }
 
@@ -405,6 +841,11 @@ func (c *Config) validateStorage() error {
 		return fmt.Errorf("backup retention must be at least 1 day, got %d", c.Storage.BackupRetention)
 	}
 
+	if c.Storage.BackupKeepDaily < 0 || c.Storage.BackupKeepWeekly < 0 {
+		return fmt.Errorf("backup rotation counts cannot be negative, got daily %d and weekly %d",
+			c.Storage.BackupKeepDaily, c.Storage.BackupKeepWeekly)
+	}
+
 	return nil
 }
 
@@ -441,6 +882,15 @@ func (c *Config) validateAPI() error {
 		}
 	}
 
+	for provider, limits := range c.API.Limits {
+		if !contains(validProviders, provider) {
+			return fmt.Errorf("limits for unknown provider %q, must be one of: %v", provider, validProviders)
+		}
+		if limits.RequestsPerMinute < 0 || limits.TokensPerMinute < 0 || limits.MaxConcurrent < 0 {
+			return fmt.Errorf("limits for provider %q cannot be negative", provider)
+		}
+	}
+
 	return nil
 }
 
@@ -450,6 +900,10 @@ func (c *Config) validateBudget() error {
 		return fmt.Errorf("daily budget limit cannot be negative")
 	}
 
+	if c.Budget.WeeklyLimit < 0 {
+		return fmt.Errorf("weekly budget limit cannot be negative")
+	}
+
 	if c.Budget.MonthlyLimit < 0 {
 		return fmt.Errorf("monthly budget limit cannot be negative")
 	}
@@ -464,6 +918,27 @@ func (c *Config) validateBudget() error {
 			c.Budget.DailyLimit*30, c.Budget.MonthlyLimit)
 	}
 
+	if _, err := c.Budget.Location(); err != nil {
+		return err
+	}
+
+	if _, err := c.Budget.EnforcementMode(); err != nil {
+		return err
+	}
+
+	if c.Budget.GracePercent < 0 {
+		return fmt.Errorf("budget grace percent cannot be negative")
+	}
+
+	return nil
+}
+
+// validateRouting validates routing configuration. An empty policy, as in
+// files written before it was configurable, routes with the default weights.
+func (c *Config) validateRouting() error {
+	if c.Routing.Policy != "" && !contains(RoutingPolicies, c.Routing.Policy) {
+		return fmt.Errorf("invalid routing policy %q, must be one of: %v", c.Routing.Policy, RoutingPolicies)
+	}
 	return nil
 }
 
@@ -493,12 +968,96 @@ func (c *Config) validatePermissions() error {
 	return nil
 }
 
+// validateNetwork validates network configuration.
+func (c *Config) validateNetwork() error {
+	if _, err := netaudit.ParseMode(c.Network.Mode); err != nil {
+		return err
+	}
+
+	if c.Network.AuditRetentionDays < 0 {
+		return fmt.Errorf("audit retention cannot be negative, got %d", c.Network.AuditRetentionDays)
+	}
+
+	for _, pattern := range c.Network.Allowlist {
+		if _, err := netaudit.NormalizePattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateAudit validates LLM audit log configuration. A zero size, as in
+// files written before the audit log existed, rotates at 100 MB.
+func (c *Config) validateAudit() error {
+	if c.Audit.MaxSizeMB < 0 {
+		return fmt.Errorf("audit log max size cannot be negative, got %d", c.Audit.MaxSizeMB)
+	}
+	if c.Audit.MaxBackups < 0 {
+		return fmt.Errorf("audit log max backups cannot be negative, got %d", c.Audit.MaxBackups)
+	}
+	if c.Audit.MaxAgeDays < 0 {
+		return fmt.Errorf("audit log max age cannot be negative, got %d", c.Audit.MaxAgeDays)
+	}
+	return nil
+}
+
+// validateCompletionCache validates completion cache configuration. Zero
+// values, as in files written before the cache existed, use the defaults.
+func (c *Config) validateCompletionCache() error {
+	if c.CompletionCache.MaxEntries < 0 {
+		return fmt.Errorf("completion cache max entries cannot be negative, got %d", c.CompletionCache.MaxEntries)
+	}
+	if c.CompletionCache.MaxTemperature < 0 || c.CompletionCache.MaxTemperature > 2 {
+		return fmt.Errorf("completion cache max temperature must be between 0 and 2, got %g", c.CompletionCache.MaxTemperature)
+	}
+	return nil
+}
+
 // validatePreferences validates preference configuration.
 func (c *Config) validatePreferences() error {
 	if c.Preferences.DefaultPriority < 1 || c.Preferences.DefaultPriority > 10 {
 		return fmt.Errorf("default priority must be between 1 and 10, got %d", c.Preferences.DefaultPriority)
 	}
 
+	if (c.Preferences.QuietHoursStart == "") != (c.Preferences.QuietHoursEnd == "") {
+		return fmt.Errorf("quiet hours need both a start and an end")
+	}
+	for _, clock := range []string{c.Preferences.QuietHoursStart, c.Preferences.QuietHoursEnd} {
+		if _, err := parseClock(clock); clock != "" && err != nil {
+			return err
+		}
+	}
+
+	if c.Preferences.MaxInProgressObjectives < 0 || c.Preferences.MaxInProgressPerGoal < 0 {
+		return fmt.Errorf("work-in-progress limits cannot be negative")
+	}
+	for goalID, limit := range c.Preferences.GoalWIPLimits {
+		if limit < 0 {
+			return fmt.Errorf("work-in-progress limit for goal %s cannot be negative", goalID)
+		}
+	}
+
+	if c.Preferences.TimeBoxHighMinutes < 0 || c.Preferences.TimeBoxNormalMinutes < 0 || c.Preferences.TimeBoxLowMinutes < 0 {
+		return fmt.Errorf("time boxes cannot be negative")
+	}
+
+	for factor, weight := range c.Preferences.SuggestionWeights {
+		if weight < 0 {
+			return fmt.Errorf("suggestion weight for %s cannot be negative", factor)
+		}
+	}
+
+	if c.Preferences.LogLevel != "" {
+		if _, err := utils.ParseLogLevel(c.Preferences.LogLevel); err != nil {
+			return fmt.Errorf("invalid log level %q, must be one of: debug, info, warning, error", c.Preferences.LogLevel)
+		}
+	}
+
+	if c.Preferences.PreferenceMiningWeeklyLimit < 0 {
+		return fmt.Errorf("preference mining weekly limit cannot be negative, got %.2f", c.Preferences.PreferenceMiningWeeklyLimit)
+	}
+
 	return nil
 }
 
@@ -541,25 +1100,30 @@ func (c *Config) validateSession() error {
 
 // ApplyEnvironmentOverrides applies environment variable overrides to the configuration.
 func (c *Config) ApplyEnvironmentOverrides() {
-	// API key overrides (most important for security)
-	if apiKey := os.Getenv("ANTHROPIC_API_KEY"); apiKey != "" {
-		c.API.Anthropic.APIKey = apiKey
-	}
-	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
-		c.API.OpenAI.APIKey = apiKey
-	}
+	// API keys in the environment are read where they are used, never
+	// copied here, so saving the configuration cannot write them to disk
 
 	// Data directory override
 	if dataDir := os.Getenv("AI_WORK_STUDIO_DATA_DIR"); dataDir != "" {
 		c.Storage.DataDir = dataDir
 	}
 
+	// Backup directory override
+	if backupDir := os.Getenv("AI_WORK_STUDIO_BACKUP_DIR"); backupDir != "" {
+		c.Storage.BackupDir = backupDir
+	}
+
 	// Budget overrides
 	if dailyLimit := os.Getenv("AI_WORK_STUDIO_DAILY_LIMIT"); dailyLimit != "" {
 		if limit, err := parseFloat(dailyLimit); err == nil && limit >= 0 {
 			c.Budget.DailyLimit = limit
 		}
 	}
+	if weeklyLimit := os.Getenv("AI_WORK_STUDIO_WEEKLY_LIMIT"); weeklyLimit != "" {
+		if limit, err := parseFloat(weeklyLimit); err == nil && limit >= 0 {
+			c.Budget.WeeklyLimit = limit
+		}
+	}
 	if monthlyLimit := os.Getenv("AI_WORK_STUDIO_MONTHLY_LIMIT"); monthlyLimit != "" {
 		if limit, err := parseFloat(monthlyLimit); err == nil && limit >= 0 {
 			c.Budget.MonthlyLimit = limit
@@ -573,11 +1137,21 @@ func (c *Config) ApplyEnvironmentOverrides() {
 		}
 	}
 
+	// Vocabulary directory override
+	if vocabularyDir := os.Getenv("AI_WORK_STUDIO_VOCABULARY_DIR"); vocabularyDir != "" {
+		c.API.Tokenizer.VocabularyDir = vocabularyDir
+	}
+
 	// Verbose output override
 	if verbose := os.Getenv("AI_WORK_STUDIO_VERBOSE"); verbose != "" {
 		c.Preferences.VerboseOutput = strings.ToLower(verbose) == "true"
 	}
 
+	// Profile override
+	if profile := os.Getenv("AI_WORK_STUDIO_PROFILE"); profile != "" {
+		c.Profile.Name = profile
+	}
+
 	// User ID override
 	if userID := os.Getenv("AI_WORK_STUDIO_USER_ID"); userID != "" {
 		c.Session.UserID = userID
@@ -616,6 +1190,15 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
+// parseClock parses an "HH:MM" time of day into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
 // parseFloat safely parses a float64 from a string.
 func parseFloat(s string) (float64, error) {
 	var f float64
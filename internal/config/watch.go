@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often Watch checks the configuration file for
+// changes.
+const DefaultWatchInterval = 2 * time.Second
+
+// Watcher reloads a configuration file when it changes, so settings take
+// effect without restarting. Each new configuration is loaded as Load loads
+// it, environment overrides and validation included, and delivered to the
+// components registered with OnChange. A file that cannot be read, parsed or
+// validated is rejected, and the configuration before it stays in effect.
+type Watcher struct {
+	path string
+
+	mu       sync.Mutex
+	current  *Config
+	data     []byte // The file as it was last checked
+	onChange []func(*Config)
+	onReject []func(error)
+
+	// checkMu serializes checks, so changes are delivered in order
+	checkMu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWatcher loads the configuration file at path and returns a watcher for
+// it. The file is only checked again when Check is called; Watch checks it
+// periodically.
+func NewWatcher(path string) (*Watcher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	current, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{path: path, current: current, data: data}, nil
+}
+
+// Watch loads the configuration file at path and checks it every
+// DefaultWatchInterval until Close, calling onChange with each valid new
+// configuration. Rejected files are logged unless an OnReject handler is
+// registered.
+func Watch(path string, onChange func(*Config)) (*Watcher, error) {
+	w, err := NewWatcher(path)
+	if err != nil {
+		return nil, err
+	}
+	if onChange != nil {
+		w.OnChange(onChange)
+	}
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.poll(DefaultWatchInterval)
+	return w, nil
+}
+
+// OnChange registers fn to receive each new configuration the watcher
+// accepts. Components are called in the order they registered, outside the
+// watcher's lock, and must not modify the configuration.
+func (w *Watcher) OnChange(fn func(*Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, fn)
+}
+
+// OnReject registers fn to receive the reason each rejected file was
+// rejected.
+func (w *Watcher) OnReject(fn func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onReject = append(w.onReject, fn)
+}
+
+// Config returns the configuration in effect.
+func (w *Watcher) Config() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Check reloads the configuration file if it changed since it was last
+// loaded, and reports whether a new configuration was delivered. A file
+// that is rejected is returned as an error, which is also passed to the
+// OnReject handlers; it is not reported again until it changes.
+func (w *Watcher) Check() (bool, error) {
+	w.checkMu.Lock()
+	defer w.checkMu.Unlock()
+
+	// A file that cannot be read reads as empty, so it is rejected once
+	data, err := os.ReadFile(w.path)
+	w.mu.Lock()
+	unchanged := bytes.Equal(data, w.data)
+	w.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	var next *Config
+	if err == nil {
+		next, err = Load(w.path)
+	} else {
+		err = fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	w.mu.Lock()
+	w.data = data
+	if err != nil {
+		rejected := fmt.Errorf("rejected configuration change in %s, keeping the previous configuration: %w", w.path, err)
+		handlers := append([]func(error){}, w.onReject...)
+		w.mu.Unlock()
+		if len(handlers) == 0 {
+			log.Printf("Warning: %v", rejected)
+		}
+		for _, handler := range handlers {
+			handler(rejected)
+		}
+		return false, rejected
+	}
+	w.current = next
+	components := append([]func(*Config){}, w.onChange...)
+	w.mu.Unlock()
+
+	for _, component := range components {
+		component(next)
+	}
+	return true, nil
+}
+
+// Close stops the periodic checks Watch started.
+func (w *Watcher) Close() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+// poll checks the file every interval until Close.
+func (w *Watcher) poll(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Package lineinput reads the input of the CLI's interactive mode. A line
+// ending in a backslash continues on the next one, for multi-line input.
+// Entries are kept in a history file across sessions, and "!!" or "!n"
+// recalls the last or the n-th entry.
+package lineinput
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// DefaultHistoryLimit is how many entries a history keeps when no limit is given.
+const DefaultHistoryLimit = 1000
+
+// ContinuationPrompt is shown while a multi-line entry continues.
+const ContinuationPrompt = "... "
+
+// History is the list of entries read, oldest first, saved to a file as they
+// are added. Each entry is one quoted line, so multi-line entries survive.
+type History struct {
+	path    string
+	limit   int
+	entries []string
+
+	// lines counts the lines in the file, which is rewritten with only the
+	// kept entries once it holds twice the limit
+	lines int
+}
+
+// LoadHistory reads the history saved at path, keeping the last limit
+// entries (0: DefaultHistoryLimit). A missing file is an empty history;
+// unreadable lines are skipped.
+func LoadHistory(path string, limit int) (*History, error) {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	h := &History{path: path, limit: limit}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		h.lines++
+		if entry, err := strconv.Unquote(scanner.Text()); err == nil && strings.TrimSpace(entry) != "" {
+			h.entries = append(h.entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	if excess := len(h.entries) - limit; excess > 0 {
+		h.entries = h.entries[excess:]
+	}
+	return h, nil
+}
+
+// Entries returns the entries kept, oldest first.
+func (h *History) Entries() []string {
+	return append([]string(nil), h.entries...)
+}
+
+// Get returns the n-th entry kept, counting from 1.
+func (h *History) Get(n int) (string, bool) {
+	if n < 1 || n > len(h.entries) {
+		return "", false
+	}
+	return h.entries[n-1], true
+}
+
+// Last returns the most recent entry.
+func (h *History) Last() (string, bool) {
+	return h.Get(len(h.entries))
+}
+
+// Add records an entry and saves it. Blank entries and repeats of the last
+// entry are not recorded.
+func (h *History) Add(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+	if last, ok := h.Last(); ok && last == entry {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+	if excess := len(h.entries) - h.limit; excess > 0 {
+		h.entries = h.entries[excess:]
+	}
+
+	if h.lines+1 > 2*h.limit {
+		return h.rewrite()
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintln(file, strconv.Quote(entry)); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	h.lines++
+	return nil
+}
+
+// rewrite replaces the history file with the entries kept.
+func (h *History) rewrite() error {
+	var b strings.Builder
+	for _, entry := range h.entries {
+		b.WriteString(strconv.Quote(entry))
+		b.WriteByte('\n')
+	}
+	temp := h.path + ".tmp"
+	if err := os.WriteFile(temp, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	if err := os.Rename(temp, h.path); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	h.lines = len(h.entries)
+	return nil
+}
+
+// Reader reads entries, recording them in a history.
+type Reader struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *History
+}
+
+// NewReader creates a reader of in that shows prompts on out. History may
+// be nil, in which case nothing is recorded or recalled.
+func NewReader(in *bufio.Reader, out io.Writer, history *History) *Reader {
+	return &Reader{in: in, out: out, history: history}
+}
+
+// Read shows prompt and returns the next entry, trimmed, with continued
+// lines joined by newlines. A recalled entry is shown before it is
+// returned. At the end of the input it returns io.EOF.
+func (r *Reader) Read(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	var lines []string
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			if len(lines) == 0 {
+				return "", err
+			}
+			break
+		}
+		if continued, ok := strings.CutSuffix(line, `\`); ok {
+			lines = append(lines, continued)
+			fmt.Fprint(r.out, ContinuationPrompt)
+			continue
+		}
+		lines = append(lines, line)
+		break
+	}
+
+	entry := strings.TrimSpace(strings.Join(lines, "\n"))
+	if recalled, ok, err := r.recall(entry); err != nil {
+		return "", err
+	} else if ok {
+		fmt.Fprintln(r.out, recalled)
+		entry = recalled
+	}
+
+	if r.history != nil {
+		if err := r.history.Add(entry); err != nil {
+			fmt.Fprintf(r.out, "Warning: %v\n", err)
+		}
+	}
+	return entry, nil
+}
+
+// recall expands "!!" and "!n" to the entries they name.
+func (r *Reader) recall(entry string) (string, bool, error) {
+	ref, ok := strings.CutPrefix(entry, "!")
+	if !ok || ref == "" || r.history == nil {
+		return "", false, nil
+	}
+	if ref == "!" {
+		last, found := r.history.Last()
+		if !found {
+			return "", false, errs.New(errs.NotFound, "no history yet")
+		}
+		return last, true, nil
+	}
+	n, err := strconv.Atoi(ref)
+	if err != nil {
+		return "", false, nil
+	}
+	recalled, found := r.history.Get(n)
+	if !found {
+		return "", false, errs.Newf(errs.NotFound, "no history entry %d", n).With("entry", n)
+	}
+	return recalled, true, nil
+}
+
+// ReadLine shows prompt and returns the next line as typed, without
+// continuation or history, as for answers to questions.
+func (r *Reader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	return r.readLine()
+}
+
+// readLine returns the next line without its line ending.
+func (r *Reader) readLine() (string, error) {
+	line, err := r.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,188 @@
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+const (
+	// fakeProviderName is reported as the provider of every fake response
+	fakeProviderName = "fake"
+
+	// fakeCostPerMillionTokens keeps fake spending small but non-zero
+	fakeCostPerMillionTokens = 0.50
+
+	// fakeEmbeddingDimensions is the length of fake embedding vectors
+	fakeEmbeddingDimensions = 16
+)
+
+// FakeProvider is a deterministic in-process LLM provider used by the self-test.
+// The same request always produces the same response, and failures can be
+// scripted to exercise retry and fallback paths without any network access.
+type FakeProvider struct {
+	mu          sync.Mutex
+	failures    []error  // Returned by the next calls, in order
+	replies     []string // Returned by the next completions, in order
+	completions int
+	embeddings  int
+}
+
+// NewFakeProvider creates a fake provider with no scripted failures.
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+// Name returns the provider name.
+func (fp *FakeProvider) Name() string {
+	return "Self-test fake provider"
+}
+
+// FailNext makes the next call to the provider return err.
+// Calling it several times queues several failures.
+func (fp *FakeProvider) FailNext(err error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.failures = append(fp.failures, err)
+}
+
+// ReplyNext makes the next completion return text instead of the scripted
+// response. Calling it several times queues several replies.
+func (fp *FakeProvider) ReplyNext(text string) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.replies = append(fp.replies, text)
+}
+
+// Calls returns the number of completion and embedding calls received,
+// including calls that returned a scripted failure.
+func (fp *FakeProvider) Calls() (completions, embeddings int) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return fp.completions, fp.embeddings
+}
+
+// Complete returns a scripted completion for the request.
+// Prompts asking for the ethical evaluation format get parseable scores,
+// prompts mentioning JSON get a JSON object, and anything else gets plain text.
+// Replies queued with ReplyNext take precedence.
+func (fp *FakeProvider) Complete(ctx context.Context, request mcp.CompletionRequest) (*mcp.CompletionResponse, error) {
+	fp.mu.Lock()
+	fp.completions++
+	err := fp.nextFailure()
+	var reply *string
+	if err == nil && len(fp.replies) > 0 {
+		reply = &fp.replies[0]
+		fp.replies = fp.replies[1:]
+	}
+	fp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var text string
+	if reply != nil {
+		text = *reply
+	} else if text, err = scriptedCompletion(request); err != nil {
+		return nil, err
+	}
+
+	inputTokens := len(strings.Fields(request.Prompt))
+	outputTokens := len(strings.Fields(text))
+	return &mcp.CompletionResponse{
+		Text:         text,
+		TokensUsed:   inputTokens + outputTokens,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Model:        request.Model,
+		Provider:     fakeProviderName,
+		Cost:         fp.CalculateCost(request.Model, inputTokens, outputTokens),
+	}, nil
+}
+
+// Embed returns a deterministic vector derived from the words of the text.
+func (fp *FakeProvider) Embed(ctx context.Context, request mcp.EmbeddingRequest) (*mcp.EmbeddingResponse, error) {
+	fp.mu.Lock()
+	fp.embeddings++
+	err := fp.nextFailure()
+	fp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	embedding := make([]float64, fakeEmbeddingDimensions)
+	words := strings.Fields(strings.ToLower(request.Text))
+	for _, word := range words {
+		hash := fnv.New32a()
+		hash.Write([]byte(word))
+		sum := hash.Sum32()
+		embedding[sum%fakeEmbeddingDimensions] += float64(sum%1000) / 1000
+	}
+
+	tokens := len(words)
+	return &mcp.EmbeddingResponse{
+		Embedding:  embedding,
+		TokensUsed: tokens,
+		Model:      request.Model,
+		Provider:   fakeProviderName,
+		Cost:       fp.CalculateCost(request.Model, tokens, 0),
+	}, nil
+}
+
+// CalculateCost returns the fake cost of a request, the same for every model
+// and for input and output tokens alike.
+func (fp *FakeProvider) CalculateCost(model string, inputTokens, outputTokens int) float64 {
+	return float64(inputTokens+outputTokens) * fakeCostPerMillionTokens / 1000000
+}
+
+// HealthCheck reports the fake provider healthy; it is always reachable.
+func (fp *FakeProvider) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// nextFailure pops the next scripted failure. Callers must hold fp.mu.
+func (fp *FakeProvider) nextFailure() error {
+	if len(fp.failures) == 0 {
+		return nil
+	}
+	err := fp.failures[0]
+	fp.failures = fp.failures[1:]
+	return err
+}
+
+// scriptedCompletion produces the response text for a completion request.
+func scriptedCompletion(request mcp.CompletionRequest) (string, error) {
+	switch {
+	case strings.Contains(request.Prompt, `"freedom_impact"`):
+		return `{"freedom_impact": 0.6, "well_being_impact": 0.5, "sustainability_impact": 0.4, "confidence": 0.9, ` +
+			`"reasoning": "The action is reversible and keeps the user in control."}`, nil
+
+	case strings.Contains(request.Prompt, "JSON"):
+		data, err := json.Marshal(map[string]interface{}{
+			"status":  "ok",
+			"model":   request.Model,
+			"summary": summarizePrompt(request.Prompt),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to encode fake JSON response: %w", err)
+		}
+		return string(data), nil
+
+	default:
+		return "Fake completion: " + summarizePrompt(request.Prompt), nil
+	}
+}
+
+// summarizePrompt returns the first few words of a prompt.
+func summarizePrompt(prompt string) string {
+	words := strings.Fields(prompt)
+	if len(words) > 8 {
+		words = words[:8]
+	}
+	return strings.Join(words, " ")
+}
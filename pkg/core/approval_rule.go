@@ -0,0 +1,492 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// ApprovalScopeKind is what part of a proposed action an approval rule constrains.
+type ApprovalScopeKind string
+
+const (
+	// ApprovalScopePathPrefix matches actions on a path at or below a directory
+	ApprovalScopePathPrefix ApprovalScopeKind = "path_prefix"
+	// ApprovalScopeHost matches actions against one host
+	ApprovalScopeHost ApprovalScopeKind = "host"
+	// ApprovalScopeAction matches one exact action, ignoring case and spacing
+	ApprovalScopeAction ApprovalScopeKind = "action"
+)
+
+// ApprovalRuleStatus tracks whether a rule may auto-approve decisions.
+type ApprovalRuleStatus string
+
+const (
+	// ApprovalRuleActive rules auto-approve matching decisions until they expire
+	ApprovalRuleActive ApprovalRuleStatus = "active"
+	// ApprovalRuleSuspended rules are on hold pending review, after a negative outcome
+	ApprovalRuleSuspended ApprovalRuleStatus = "suspended"
+	// ApprovalRuleRevoked rules were withdrawn by the user and never match again
+	ApprovalRuleRevoked ApprovalRuleStatus = "revoked"
+)
+
+// dangerousActionWords are words that keep a decision out of reach of approval
+// rules. They mirror the commands the command service refuses without explicit
+// approval, plus actions whose damage is hard to undo.
+var dangerousActionWords = []string{
+	"rm", "rmdir", "del", "delete",
+	"sudo", "su", "chmod", "chown",
+	"format", "fdisk", "mkfs",
+	"shutdown", "reboot", "halt",
+	"kill", "killall", "pkill",
+	"dd", "shred", "wipe",
+	"drop", "truncate", "overwrite",
+	"password", "passwords", "credential", "credentials",
+	"payment", "purchase", "transfer",
+}
+
+// hostSuffixes are the top-level domains recognized in bare host names such as
+// "docs.python.org"; other dotted words are taken to be file names.
+var hostSuffixes = []string{"com", "org", "net", "io", "dev", "edu", "gov", "co", "ai", "app", "info"}
+
+// ApprovalRule is a standing approval the user generalized from a decision.
+// A rule matches decisions with the same action verb whose target falls in its scope.
+type ApprovalRule struct {
+	ID string
+
+	// Verb is the leading action word, such as "write" or "fetch"
+	Verb string
+
+	// Service is the kind of target: "filesystem", "web" or "general"
+	Service string
+
+	// ScopeKind and Scope constrain the target, e.g. a path prefix or a host
+	ScopeKind ApprovalScopeKind
+	Scope     string
+
+	Status          ApprovalRuleStatus
+	SuspendedReason string
+
+	// SuspendedAt is when the rule was last suspended
+	SuspendedAt *time.Time
+
+	// SourceDecisionID is the decision the rule was generalized from
+	SourceDecisionID string
+
+	// UsageCount counts the decisions the rule auto-approved
+	UsageCount int
+	LastUsedAt *time.Time
+
+	// ExpiresAt, if set, is when the rule stops matching
+	ExpiresAt *time.Time
+
+	CreatedAt time.Time
+	UserID    string
+}
+
+// actionTarget is what a proposed action acts on.
+type actionTarget struct {
+	verb    string
+	service string
+	kind    ApprovalScopeKind
+	value   string
+	isDir   bool // The path was written with a trailing slash
+}
+
+// ApprovalRuleManager stores approval rules and matches decisions against them.
+type ApprovalRuleManager struct {
+	store *storage.Store
+
+	// clock stamps rule creation, use and suspension
+	clock utils.Clock
+}
+
+// NewApprovalRuleManager creates a new approval rule manager.
+func NewApprovalRuleManager(store *storage.Store) *ApprovalRuleManager {
+	return &ApprovalRuleManager{store: store, clock: utils.ClockOrReal(nil)}
+}
+
+// DeriveRule generalizes a decision into an unsaved rule: same verb, and the
+// directory of a path, the host of a URL, or otherwise the exact action.
+// Critical and dangerous decisions cannot be generalized.
+func (arm *ApprovalRuleManager) DeriveRule(decision *EthicalDecision) (*ApprovalRule, error) {
+	if decision == nil {
+		return nil, fmt.Errorf("decision cannot be nil")
+	}
+	if decision.Urgency == DecisionUrgencyCritical {
+		return nil, fmt.Errorf("decision %s is critical and cannot be generalized into a rule", decision.ID)
+	}
+	if IsDangerousAction(decision.ProposedAction) {
+		return nil, fmt.Errorf("decision %s matches a dangerous pattern and cannot be generalized into a rule", decision.ID)
+	}
+
+	target := parseActionTarget(decision.ProposedAction)
+	if target.verb == "" {
+		return nil, fmt.Errorf("decision %s has no action verb to generalize", decision.ID)
+	}
+
+	scope := target.value
+	if target.kind == ApprovalScopePathPrefix && !target.isDir {
+		// A file path generalizes to its directory
+		scope = path.Dir(target.value)
+	}
+
+	return &ApprovalRule{
+		Verb:             target.verb,
+		Service:          target.service,
+		ScopeKind:        target.kind,
+		Scope:            scope,
+		Status:           ApprovalRuleActive,
+		SourceDecisionID: decision.ID,
+		UserID:           decision.UserID,
+	}, nil
+}
+
+// CreateRule validates and stores a rule, usually one from DeriveRule after the user edited it.
+func (arm *ApprovalRuleManager) CreateRule(ctx context.Context, rule *ApprovalRule) (*ApprovalRule, error) {
+	if rule == nil {
+		return nil, fmt.Errorf("rule cannot be nil")
+	}
+	rule.Verb = strings.ToLower(strings.TrimSpace(rule.Verb))
+	if rule.Verb == "" {
+		return nil, fmt.Errorf("rule verb cannot be empty")
+	}
+	if IsDangerousAction(rule.Verb) {
+		return nil, fmt.Errorf("rule verb %q matches a dangerous pattern", rule.Verb)
+	}
+
+	switch rule.ScopeKind {
+	case ApprovalScopePathPrefix:
+		rule.Scope = path.Clean(strings.TrimSpace(rule.Scope))
+		if !strings.HasPrefix(rule.Scope, "/") && !strings.HasPrefix(rule.Scope, "~") {
+			return nil, fmt.Errorf("path prefix %q must be absolute", rule.Scope)
+		}
+		if rule.Scope == "/" || rule.Scope == "~" {
+			return nil, fmt.Errorf("path prefix %q would cover every file", rule.Scope)
+		}
+	case ApprovalScopeHost:
+		rule.Scope = strings.ToLower(strings.TrimSpace(rule.Scope))
+		if rule.Scope == "" || strings.ContainsAny(rule.Scope, "/*") {
+			return nil, fmt.Errorf("host %q must be a single host name", rule.Scope)
+		}
+	case ApprovalScopeAction:
+		rule.Scope = normalizeAction(rule.Scope)
+		if rule.Scope == "" {
+			return nil, fmt.Errorf("action scope cannot be empty")
+		}
+		if IsDangerousAction(rule.Scope) {
+			return nil, fmt.Errorf("action %q matches a dangerous pattern", rule.Scope)
+		}
+	default:
+		return nil, fmt.Errorf("unknown rule scope kind: %s", rule.ScopeKind)
+	}
+
+	rule.Status = ApprovalRuleActive
+	rule.UsageCount = 0
+	rule.LastUsedAt = nil
+	rule.CreatedAt = arm.clock.Now()
+
+	node := storage.NewNode("approval_rule", rule.toData())
+	if err := arm.store.AddNode(ctx, node); err != nil {
+		return nil, fmt.Errorf("failed to store approval rule: %w", err)
+	}
+	rule.ID = node.ID
+
+	return rule, nil
+}
+
+// GetRule retrieves an approval rule by ID.
+func (arm *ApprovalRuleManager) GetRule(ctx context.Context, ruleID string) (*ApprovalRule, error) {
+	node, err := arm.store.GetNode(ctx, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve approval rule %s: %w", ruleID, err)
+	}
+	if node.Type != "approval_rule" {
+		return nil, errs.Newf(errs.NotFound, "node %s is not an approval rule (type: %s)", ruleID, node.Type).With("id", ruleID)
+	}
+	return nodeToApprovalRule(node), nil
+}
+
+// ListRules returns all rules, including suspended, revoked and expired ones, newest first.
+func (arm *ApprovalRuleManager) ListRules(ctx context.Context) ([]*ApprovalRule, error) {
+	nodes, err := arm.store.GetNodesByType(ctx, "approval_rule")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list approval rules: %w", err)
+	}
+
+	rules := make([]*ApprovalRule, 0, len(nodes))
+	for _, node := range nodes {
+		rules = append(rules, nodeToApprovalRule(node))
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.After(rules[j].CreatedAt)
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, nil
+}
+
+// RevokeRule withdraws a rule for good.
+func (arm *ApprovalRuleManager) RevokeRule(ctx context.Context, ruleID string) error {
+	return arm.setStatus(ctx, ruleID, ApprovalRuleRevoked, "")
+}
+
+// SuspendRule puts a rule on hold until the user reviews and resumes it.
+func (arm *ApprovalRuleManager) SuspendRule(ctx context.Context, ruleID, reason string) error {
+	return arm.setStatus(ctx, ruleID, ApprovalRuleSuspended, reason)
+}
+
+// ResumeRule reactivates a suspended rule after review.
+func (arm *ApprovalRuleManager) ResumeRule(ctx context.Context, ruleID string) error {
+	rule, err := arm.GetRule(ctx, ruleID)
+	if err != nil {
+		return err
+	}
+	if rule.Status != ApprovalRuleSuspended {
+		return fmt.Errorf("approval rule %s is not suspended (current status: %s)", ruleID, rule.Status)
+	}
+	return arm.setStatus(ctx, ruleID, ApprovalRuleActive, "")
+}
+
+// MatchRule returns the active rule that covers a proposed action, or nil.
+// Critical decisions and dangerous actions never match.
+func (arm *ApprovalRuleManager) MatchRule(ctx context.Context, proposedAction string, urgency DecisionUrgency) (*ApprovalRule, error) {
+	if urgency == DecisionUrgencyCritical || IsDangerousAction(proposedAction) {
+		return nil, nil
+	}
+
+	rules, err := arm.ListRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target := parseActionTarget(proposedAction)
+	now := arm.clock.Now()
+	for _, rule := range rules {
+		if rule.IsActive(now) && rule.matches(target) {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordUse counts an auto-approval against a rule.
+func (arm *ApprovalRuleManager) recordUse(ctx context.Context, rule *ApprovalRule) error {
+	now := arm.clock.Now()
+	rule.UsageCount++
+	rule.LastUsedAt = &now
+	if err := arm.store.UpdateNode(ctx, rule.ID, rule.toData()); err != nil {
+		return fmt.Errorf("failed to record use of approval rule %s: %w", rule.ID, err)
+	}
+	return nil
+}
+
+// setStatus changes the status of a stored rule.
+func (arm *ApprovalRuleManager) setStatus(ctx context.Context, ruleID string, status ApprovalRuleStatus, reason string) error {
+	rule, err := arm.GetRule(ctx, ruleID)
+	if err != nil {
+		return err
+	}
+	if rule.Status == ApprovalRuleRevoked {
+		return fmt.Errorf("approval rule %s has been revoked", ruleID)
+	}
+
+	rule.Status = status
+	rule.SuspendedReason = reason
+	if status == ApprovalRuleSuspended {
+		now := arm.clock.Now()
+		rule.SuspendedAt = &now
+	}
+	if err := arm.store.UpdateNode(ctx, ruleID, rule.toData()); err != nil {
+		return fmt.Errorf("failed to update approval rule %s: %w", ruleID, err)
+	}
+	return nil
+}
+
+// IsActive reports whether the rule may auto-approve decisions at the given time.
+func (r *ApprovalRule) IsActive(now time.Time) bool {
+	return r.Status == ApprovalRuleActive && (r.ExpiresAt == nil || now.Before(*r.ExpiresAt))
+}
+
+// State describes the rule for listings: its status, or "expired".
+func (r *ApprovalRule) State(now time.Time) string {
+	if r.Status == ApprovalRuleActive && !r.IsActive(now) {
+		return "expired"
+	}
+	return string(r.Status)
+}
+
+// String describes what the rule allows, e.g. "allow write on filesystem under /home/me/workspace".
+func (r *ApprovalRule) String() string {
+	var description string
+	switch r.ScopeKind {
+	case ApprovalScopePathPrefix:
+		description = fmt.Sprintf("allow %s on %s under %s", r.Verb, r.Service, r.Scope)
+	case ApprovalScopeHost:
+		description = fmt.Sprintf("allow %s on host %s", r.Verb, r.Scope)
+	default:
+		description = fmt.Sprintf("allow exactly %q", r.Scope)
+	}
+	if r.ExpiresAt != nil {
+		description += fmt.Sprintf(" until %s", r.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	return description
+}
+
+// matches reports whether the rule's verb and scope cover the target.
+func (r *ApprovalRule) matches(target actionTarget) bool {
+	if r.ScopeKind == ApprovalScopeAction {
+		return target.kind == ApprovalScopeAction && target.value == r.Scope
+	}
+	if target.verb != r.Verb || target.kind != r.ScopeKind {
+		return false
+	}
+
+	switch r.ScopeKind {
+	case ApprovalScopePathPrefix:
+		// Match whole path components, so /home/me/work does not cover /home/me/workspace
+		return target.value == r.Scope || strings.HasPrefix(target.value, r.Scope+"/")
+	case ApprovalScopeHost:
+		return target.value == r.Scope
+	}
+	return false
+}
+
+// toData converts the rule to node data.
+func (r *ApprovalRule) toData() map[string]interface{} {
+	data := map[string]interface{}{
+		"verb":               r.Verb,
+		"service":            r.Service,
+		"scope_kind":         string(r.ScopeKind),
+		"scope":              r.Scope,
+		"status":             string(r.Status),
+		"suspended_reason":   r.SuspendedReason,
+		"source_decision_id": r.SourceDecisionID,
+		"usage_count":        r.UsageCount,
+		"created_at":         r.CreatedAt.Format(time.RFC3339),
+		"user_id":            r.UserID,
+	}
+	if r.LastUsedAt != nil {
+		data["last_used_at"] = r.LastUsedAt.Format(time.RFC3339)
+	}
+	if r.ExpiresAt != nil {
+		data["expires_at"] = r.ExpiresAt.Format(time.RFC3339)
+	}
+	if r.SuspendedAt != nil {
+		data["suspended_at"] = r.SuspendedAt.Format(time.RFC3339)
+	}
+	return data
+}
+
+// nodeToApprovalRule converts a storage node to an ApprovalRule.
+func nodeToApprovalRule(node *storage.Node) *ApprovalRule {
+	createdAt, _ := time.Parse(time.RFC3339, getString(node.Data, "created_at"))
+	return &ApprovalRule{
+		ID:               node.ID,
+		Verb:             getString(node.Data, "verb"),
+		Service:          getString(node.Data, "service"),
+		ScopeKind:        ApprovalScopeKind(getString(node.Data, "scope_kind")),
+		Scope:            getString(node.Data, "scope"),
+		Status:           ApprovalRuleStatus(getString(node.Data, "status")),
+		SuspendedReason:  getString(node.Data, "suspended_reason"),
+		SuspendedAt:      parseOptionalTime(node.Data["suspended_at"]),
+		SourceDecisionID: getString(node.Data, "source_decision_id"),
+		UsageCount:       int(getFloat64(node.Data, "usage_count")),
+		LastUsedAt:       parseOptionalTime(node.Data["last_used_at"]),
+		ExpiresAt:        parseOptionalTime(node.Data["expires_at"]),
+		CreatedAt:        createdAt,
+		UserID:           getString(node.Data, "user_id"),
+	}
+}
+
+// IsDangerousAction reports whether an action contains a word from the
+// dangerous-pattern list. Such actions always need explicit approval.
+func IsDangerousAction(action string) bool {
+	for _, word := range actionWords(action) {
+		for _, dangerous := range dangerousActionWords {
+			if word == dangerous {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// actionWords splits text into its lower-case words of letters and digits.
+func actionWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+}
+
+// parseActionTarget splits a proposed action such as "write file /home/me/notes.md"
+// or "fetch from https://docs.python.org/3/" into its verb and target.
+func parseActionTarget(action string) actionTarget {
+	fields := strings.Fields(action)
+	target := actionTarget{
+		service: "general",
+		kind:    ApprovalScopeAction,
+		value:   normalizeAction(action),
+	}
+	if len(fields) == 0 {
+		return target
+	}
+	target.verb = strings.ToLower(strings.Trim(fields[0], ".,:;"))
+
+	for _, field := range fields[1:] {
+		field = strings.Trim(field, "\"'`,;()")
+		if parsed, err := url.Parse(field); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Hostname() != "" {
+			target.service, target.kind, target.value = "web", ApprovalScopeHost, strings.ToLower(parsed.Hostname())
+			return target
+		}
+		if strings.HasPrefix(field, "/") || strings.HasPrefix(field, "~/") {
+			target.service, target.kind, target.value = "filesystem", ApprovalScopePathPrefix, path.Clean(field)
+			target.isDir = strings.HasSuffix(field, "/")
+			return target
+		}
+		if isHostName(field) {
+			target.service, target.kind, target.value = "web", ApprovalScopeHost, strings.ToLower(strings.TrimSuffix(field, "."))
+			return target
+		}
+	}
+	return target
+}
+
+// isHostName reports whether a word is a bare host name with a known top-level domain.
+func isHostName(word string) bool {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(word, ".")), ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	suffix := labels[len(labels)-1]
+	for _, known := range hostSuffixes {
+		if suffix == known {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAction lower-cases an action and collapses its spacing.
+func normalizeAction(action string) string {
+	return strings.Join(strings.Fields(strings.ToLower(action)), " ")
+}
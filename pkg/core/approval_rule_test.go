@@ -0,0 +1,222 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestApprovalRuleManager_DeriveRule(t *testing.T) {
+	arm := NewApprovalRuleManager(setupTestStore(t))
+
+	tests := []struct {
+		action    string
+		verb      string
+		service   string
+		kind      ApprovalScopeKind
+		scope     string
+		wantError bool
+	}{
+		{action: "Write file /home/me/workspace/notes.md", verb: "write", service: "filesystem", kind: ApprovalScopePathPrefix, scope: "/home/me/workspace"},
+		{action: "write into /home/me/workspace/", verb: "write", service: "filesystem", kind: ApprovalScopePathPrefix, scope: "/home/me/workspace"},
+		{action: "fetch https://docs.python.org/3/library/json.html", verb: "fetch", service: "web", kind: ApprovalScopeHost, scope: "docs.python.org"},
+		{action: "fetch from docs.python.org", verb: "fetch", service: "web", kind: ApprovalScopeHost, scope: "docs.python.org"},
+		{action: "Summarize  notes.md", verb: "summarize", service: "general", kind: ApprovalScopeAction, scope: "summarize notes.md"},
+		{action: "delete /home/me/workspace/notes.md", wantError: true},
+		{action: "sudo apt upgrade", wantError: true},
+	}
+
+	for _, tt := range tests {
+		rule, err := arm.DeriveRule(&EthicalDecision{ID: "d1", ProposedAction: tt.action, Urgency: DecisionUrgencyMedium})
+		if tt.wantError {
+			if err == nil {
+				t.Errorf("%q: expected an error for a dangerous action, got rule %s", tt.action, rule)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.action, err)
+			continue
+		}
+		if rule.Verb != tt.verb || rule.Service != tt.service || rule.ScopeKind != tt.kind || rule.Scope != tt.scope {
+			t.Errorf("%q: expected %s/%s/%s/%s, got %s/%s/%s/%s", tt.action,
+				tt.verb, tt.service, tt.kind, tt.scope, rule.Verb, rule.Service, rule.ScopeKind, rule.Scope)
+		}
+		if rule.SourceDecisionID != "d1" {
+			t.Errorf("%q: expected the source decision to be recorded", tt.action)
+		}
+	}
+
+	if _, err := arm.DeriveRule(&EthicalDecision{ProposedAction: "write /tmp/x", Urgency: DecisionUrgencyCritical}); err == nil {
+		t.Error("Expected critical decisions not to be generalized")
+	}
+}
+
+func TestApprovalRuleManager_ScopeMatching(t *testing.T) {
+	arm := NewApprovalRuleManager(setupTestStore(t))
+	ctx := context.Background()
+
+	pathRule, err := arm.CreateRule(ctx, &ApprovalRule{Verb: "write", Service: "filesystem", ScopeKind: ApprovalScopePathPrefix, Scope: "/home/me/work/"})
+	if err != nil {
+		t.Fatalf("Failed to create path rule: %v", err)
+	}
+	if pathRule.Scope != "/home/me/work" {
+		t.Errorf("Expected the scope to be cleaned, got %q", pathRule.Scope)
+	}
+	hostRule, err := arm.CreateRule(ctx, &ApprovalRule{Verb: "fetch", Service: "web", ScopeKind: ApprovalScopeHost, Scope: "docs.python.org"})
+	if err != nil {
+		t.Fatalf("Failed to create host rule: %v", err)
+	}
+
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{"write file /home/me/work/notes.md", pathRule.ID},
+		{"write file /home/me/work", pathRule.ID},
+		{"write file /home/me/work/sub/dir/notes.md", pathRule.ID},
+		{"write file /home/me/workspace/notes.md", ""}, // shares the prefix but not the directory
+		{"write file /home/me/work/../secrets.txt", ""},
+		{"write file /home/me", ""},
+		{"read file /home/me/work/notes.md", ""}, // different verb
+		{"fetch https://docs.python.org/3/", hostRule.ID},
+		{"fetch from DOCS.python.org", hostRule.ID},
+		{"fetch https://evil.docs.python.org.example.com/", ""},
+		{"fetch https://python.org/", ""},
+	}
+	for _, tt := range tests {
+		rule, err := arm.MatchRule(ctx, tt.action, DecisionUrgencyMedium)
+		if err != nil {
+			t.Fatalf("%q: MatchRule failed: %v", tt.action, err)
+		}
+		got := ""
+		if rule != nil {
+			got = rule.ID
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected rule %q, got %q", tt.action, tt.want, got)
+		}
+	}
+
+	// Rules covering everything are refused
+	if _, err := arm.CreateRule(ctx, &ApprovalRule{Verb: "write", ScopeKind: ApprovalScopePathPrefix, Scope: "/"}); err == nil {
+		t.Error("Expected a root path prefix to be refused")
+	}
+}
+
+func TestApprovalRuleManager_Exclusions(t *testing.T) {
+	arm := NewApprovalRuleManager(setupTestStore(t))
+	ctx := context.Background()
+
+	if _, err := arm.CreateRule(ctx, &ApprovalRule{Verb: "write", Service: "filesystem", ScopeKind: ApprovalScopePathPrefix, Scope: "/home/me/work"}); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	if rule, _ := arm.MatchRule(ctx, "write file /home/me/work/notes.md", DecisionUrgencyCritical); rule != nil {
+		t.Error("Expected critical decisions never to be auto-approved")
+	}
+	if rule, _ := arm.MatchRule(ctx, "write file /home/me/work/notes.md and then rm it", DecisionUrgencyLow); rule != nil {
+		t.Error("Expected dangerous actions never to be auto-approved")
+	}
+	if rule, _ := arm.MatchRule(ctx, "write file /home/me/work/notes.md", DecisionUrgencyHigh); rule == nil {
+		t.Error("Expected high urgency decisions to be covered by rules")
+	}
+
+	// Expired and revoked rules stop matching
+	expired := time.Now().Add(-time.Hour)
+	if _, err := arm.CreateRule(ctx, &ApprovalRule{Verb: "fetch", Service: "web", ScopeKind: ApprovalScopeHost, Scope: "example.com", ExpiresAt: &expired}); err != nil {
+		t.Fatalf("Failed to create expiring rule: %v", err)
+	}
+	if rule, _ := arm.MatchRule(ctx, "fetch https://example.com/", DecisionUrgencyLow); rule != nil {
+		t.Error("Expected an expired rule not to match")
+	}
+
+	rules, _ := arm.ListRules(ctx)
+	for _, rule := range rules {
+		if err := arm.RevokeRule(ctx, rule.ID); err != nil {
+			t.Fatalf("Failed to revoke rule: %v", err)
+		}
+	}
+	if rule, _ := arm.MatchRule(ctx, "write file /home/me/work/notes.md", DecisionUrgencyLow); rule != nil {
+		t.Error("Expected a revoked rule not to match")
+	}
+	if err := arm.ResumeRule(ctx, rules[0].ID); err == nil {
+		t.Error("Expected a revoked rule not to be resumable")
+	}
+}
+
+func TestEthicalFramework_AutoApprovalAndSuspension(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	ef := NewEthicalFramework(store, nil, NewUserContextManager(store))
+
+	rule, err := ef.Rules().CreateRule(ctx, &ApprovalRule{Verb: "write", Service: "filesystem", ScopeKind: ApprovalScopePathPrefix, Scope: "/home/me/work"})
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	// Below the approval threshold, so approval would otherwise be required
+	impact := &EthicalImpact{FreedomImpact: 0.2, WellBeingImpact: 0.2, SustainabilityImpact: 0.2, ConfidenceScore: 0.9}
+	status, matched, err := ef.determineApprovalNeeded(ctx, "write file /home/me/work/notes.md", impact, DecisionUrgencyLow)
+	if err != nil {
+		t.Fatalf("determineApprovalNeeded failed: %v", err)
+	}
+	if status != DecisionApprovalApproved || matched == nil || matched.ID != rule.ID {
+		t.Fatalf("Expected auto-approval by the rule, got %s", status)
+	}
+	status, _, _ = ef.determineApprovalNeeded(ctx, "write file /etc/hosts", impact, DecisionUrgencyLow)
+	if status != DecisionApprovalPending {
+		t.Errorf("Expected approval to be required outside the rule's scope, got %s", status)
+	}
+
+	// Record an auto-approved decision the way EvaluateDecision does
+	now := time.Now()
+	decision := &EthicalDecision{
+		ProposedAction: "write file /home/me/work/notes.md",
+		Impact:         *impact,
+		ApprovalStatus: DecisionApprovalApproved,
+		ApprovedAt:     &now,
+		ApprovedByRule: rule.ID,
+		Outcome:        DecisionOutcomeUnknown,
+		CreatedAt:      now,
+	}
+	if err := ef.storeDecision(ctx, decision); err != nil {
+		t.Fatalf("Failed to store decision: %v", err)
+	}
+	if err := ef.rules.recordUse(ctx, matched); err != nil {
+		t.Fatalf("Failed to record rule use: %v", err)
+	}
+
+	stored, err := ef.GetDecision(ctx, decision.ID)
+	if err != nil || stored.ApprovedByRule != rule.ID {
+		t.Errorf("Expected the rule ID on the stored decision, got %v, %v", stored, err)
+	}
+	if used, _ := ef.Rules().GetRule(ctx, rule.ID); used.UsageCount != 1 || used.LastUsedAt == nil {
+		t.Errorf("Expected the usage to be counted, got %d", used.UsageCount)
+	}
+
+	// A negative outcome suspends the rule
+	if err := ef.ImplementDecision(ctx, decision.ID); err != nil {
+		t.Fatalf("ImplementDecision failed: %v", err)
+	}
+	if err := ef.RecordOutcome(ctx, decision.ID, DecisionOutcomeNegative, ""); err != nil {
+		t.Fatalf("RecordOutcome failed: %v", err)
+	}
+	suspended, _ := ef.Rules().GetRule(ctx, rule.ID)
+	if suspended.Status != ApprovalRuleSuspended || suspended.SuspendedReason == "" {
+		t.Errorf("Expected the rule to be suspended with a reason, got %s", suspended.Status)
+	}
+	status, _, _ = ef.determineApprovalNeeded(ctx, "write file /home/me/work/notes.md", impact, DecisionUrgencyLow)
+	if status != DecisionApprovalPending {
+		t.Errorf("Expected a suspended rule not to auto-approve, got %s", status)
+	}
+
+	// Resuming after review brings it back
+	if err := ef.Rules().ResumeRule(ctx, rule.ID); err != nil {
+		t.Fatalf("ResumeRule failed: %v", err)
+	}
+	status, _, _ = ef.determineApprovalNeeded(ctx, "write file /home/me/work/notes.md", impact, DecisionUrgencyLow)
+	if status != DecisionApprovalApproved {
+		t.Errorf("Expected the resumed rule to auto-approve again, got %s", status)
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// ArchivePolicy decides which settled work ArchiveSettled moves out of the live store.
+type ArchivePolicy struct {
+	// MinAge is how long a goal must have been archived before its work is moved,
+	// so recently archived goals stay cheap to look at and to unarchive.
+	MinAge time.Duration
+}
+
+// DefaultArchivePolicy returns the policy used by scheduled archiving.
+func DefaultArchivePolicy() ArchivePolicy {
+	return ArchivePolicy{MinAge: 7 * 24 * time.Hour}
+}
+
+// ArchiveReport summarizes an archiving pass.
+type ArchiveReport struct {
+	Segments []string       // Archive segments written; empty for dry runs or when nothing was due
+	Nodes    map[string]int // Archived nodes by type
+	Edges    int            // Archived edges; not known for dry runs
+	DryRun   bool
+
+	// OperationID is the journal entry for the pass, which undoes it
+	OperationID string
+}
+
+// TotalNodes returns the number of archived nodes across all types.
+func (r *ArchiveReport) TotalNodes() int {
+	total := 0
+	for _, count := range r.Nodes {
+		total += count
+	}
+	return total
+}
+
+// String summarizes the report in one line, e.g. "archived 120 nodes (80 objective, 40 goal) and 200 edges".
+func (r *ArchiveReport) String() string {
+	if r.TotalNodes() == 0 {
+		return "nothing to archive"
+	}
+
+	types := make([]string, 0, len(r.Nodes))
+	for nodeType := range r.Nodes {
+		types = append(types, nodeType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if r.Nodes[types[i]] != r.Nodes[types[j]] {
+			return r.Nodes[types[i]] > r.Nodes[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	parts := make([]string, 0, len(types))
+	for _, nodeType := range types {
+		parts = append(parts, fmt.Sprintf("%d %s", r.Nodes[nodeType], nodeType))
+	}
+
+	if r.DryRun {
+		return fmt.Sprintf("would archive %d nodes (%s)", r.TotalNodes(), strings.Join(parts, ", "))
+	}
+	return fmt.Sprintf("archived %d nodes (%s) and %d edges", r.TotalNodes(), strings.Join(parts, ", "), r.Edges)
+}
+
+// ArchiveManager moves settled work into archive segments, which the store
+// keeps on disk and only reads on demand. Archived nodes stay reachable by ID
+// and through queries that include archived data, but no longer cost startup
+// time or memory, and drop out of default listings and searches.
+type ArchiveManager struct {
+	store *storage.Store
+}
+
+// NewArchiveManager creates a new archive manager.
+func NewArchiveManager(store *storage.Store) *ArchiveManager {
+	return &ArchiveManager{store: store}
+}
+
+// ArchiveSettled archives what the policy considers settled: archived goals,
+// their completed, failed and cancelled objectives, and the execution results and ethical
+// decisions recorded for those objectives. Unfinished objectives stay live.
+// With dryRun set, the report lists what would be archived without changing
+// anything. The pass is journaled, so it can be undone.
+func (am *ArchiveManager) ArchiveSettled(ctx context.Context, policy ArchivePolicy, dryRun bool) (*ArchiveReport, error) {
+	nodeIDs, report, err := am.selectSettled(ctx, policy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = dryRun
+	if dryRun || len(nodeIDs) == 0 {
+		return report, nil
+	}
+
+	operation, err := NewOperationJournal(am.store).Record(ctx, OperationArchive, func(ctx context.Context) (string, error) {
+		result, err := am.store.ArchiveNodes(ctx, nodeIDs)
+		if err != nil {
+			return "", fmt.Errorf("failed to archive settled work: %w", err)
+		}
+		report.Segments = result.Segments
+		report.Edges = result.Edges
+		return "Archive settled work: " + report.String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if operation != nil {
+		report.OperationID = operation.ID
+	}
+	return report, nil
+}
+
+// selectSettled returns the IDs of live nodes the policy archives, with their counts by type.
+func (am *ArchiveManager) selectSettled(ctx context.Context, policy ArchivePolicy, now time.Time) ([]string, *ArchiveReport, error) {
+	report := &ArchiveReport{Nodes: make(map[string]int)}
+	var nodeIDs []string
+	add := func(node *storage.Node) {
+		nodeIDs = append(nodeIDs, node.ID)
+		report.Nodes[node.Type]++
+	}
+
+	goals, err := am.store.GetNodesByType(ctx, "goal")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	cutoff := now.Add(-policy.MinAge)
+	archivedGoals := make(map[string]bool)
+	for _, goal := range goals {
+		if getString(goal.Data, "status") == string(GoalStatusArchived) && !goal.ValidFrom.After(cutoff) {
+			archivedGoals[goal.ID] = true
+			add(goal)
+		}
+	}
+
+	objectives, err := am.store.GetNodesByType(ctx, "objective")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list objectives: %w", err)
+	}
+	settledObjectives := make(map[string]bool)
+	for _, objective := range objectives {
+		goalID := getString(objective.Data, "goal_id")
+		if !archivedGoals[goalID] && !am.store.IsArchived(ctx, goalID) {
+			continue
+		}
+		switch ObjectiveStatus(getString(objective.Data, "status")) {
+		case ObjectiveStatusCompleted, ObjectiveStatusFailed, ObjectiveStatusCancelled:
+			settledObjectives[objective.ID] = true
+			add(objective)
+		}
+	}
+
+	// Records follow their objective, including objectives archived by earlier passes
+	for _, recordType := range []string{"execution_result", "ethical_decision"} {
+		records, err := am.store.GetNodesByType(ctx, recordType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list %s nodes: %w", recordType, err)
+		}
+		for _, record := range records {
+			objectiveID := getString(record.Data, "objective_id")
+			if objectiveID != "" && (settledObjectives[objectiveID] || am.store.IsArchived(ctx, objectiveID)) {
+				add(record)
+			}
+		}
+	}
+
+	return nodeIDs, report, nil
+}
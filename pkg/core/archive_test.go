@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// archiveTestWorkspace holds an archived goal and an active goal, each with a
+// completed and a pending objective, and an execution result for every completed one.
+type archiveTestWorkspace struct {
+	store *storage.Store
+	gm    *GoalManager
+	om    *ObjectiveManager
+
+	archivedGoal, archivedDone, archivedOpen, archivedResult string
+	activeGoal, activeDone                                   string
+}
+
+func setupArchiveTestWorkspace(t *testing.T) *archiveTestWorkspace {
+	t.Helper()
+	ctx := context.Background()
+	store := setupTestStore(t)
+	ws := &archiveTestWorkspace{store: store, gm: NewGoalManager(store), om: NewObjectiveManager(store)}
+	methodID := addRollupTestMethod(t, store)
+
+	createGoal := func(title string) string {
+		goal, err := ws.gm.CreateGoal(ctx, title, "", 5, nil)
+		if err != nil {
+			t.Fatalf("Failed to create goal: %v", err)
+		}
+		return goal.ID
+	}
+	createObjective := func(goalID, title string, complete bool) string {
+		objective, err := ws.om.CreateObjective(ctx, goalID, methodID, title, "", nil, 5)
+		if err != nil {
+			t.Fatalf("Failed to create objective: %v", err)
+		}
+		if complete {
+			status := ObjectiveStatusCompleted
+			if _, err := ws.om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{Status: &status}); err != nil {
+				t.Fatalf("Failed to complete objective: %v", err)
+			}
+		}
+		return objective.ID
+	}
+	addResult := func(objectiveID string) string {
+		result := storage.NewNode("execution_result", map[string]interface{}{"objective_id": objectiveID, "status": "completed"})
+		if err := store.AddNode(ctx, result); err != nil {
+			t.Fatalf("Failed to add execution result: %v", err)
+		}
+		return result.ID
+	}
+
+	ws.archivedGoal = createGoal("Old project")
+	ws.archivedDone = createObjective(ws.archivedGoal, "Finished step", true)
+	ws.archivedOpen = createObjective(ws.archivedGoal, "Abandoned step", false)
+	ws.archivedResult = addResult(ws.archivedDone)
+
+	ws.activeGoal = createGoal("Current project")
+	ws.activeDone = createObjective(ws.activeGoal, "Finished current step", true)
+	addResult(ws.activeDone)
+
+	status := GoalStatusArchived
+	if _, err := ws.gm.UpdateGoal(ctx, ws.archivedGoal, GoalUpdates{Status: &status}); err != nil {
+		t.Fatalf("Failed to archive goal: %v", err)
+	}
+	return ws
+}
+
+func TestArchiveManager_ArchiveSettled(t *testing.T) {
+	ws := setupArchiveTestWorkspace(t)
+	ctx := context.Background()
+	am := NewArchiveManager(ws.store)
+
+	report, err := am.ArchiveSettled(ctx, ArchivePolicy{}, false)
+	if err != nil {
+		t.Fatalf("ArchiveSettled failed: %v", err)
+	}
+	if report.Nodes["goal"] != 1 || report.Nodes["objective"] != 1 || report.Nodes["execution_result"] != 1 {
+		t.Errorf("Expected 1 goal, 1 objective and 1 result archived, got %v", report.Nodes)
+	}
+	if len(report.Segments) == 0 {
+		t.Error("Expected the report to name the segments written")
+	}
+
+	for _, id := range []string{ws.archivedGoal, ws.archivedDone, ws.archivedResult} {
+		if !ws.store.IsArchived(ctx, id) {
+			t.Errorf("Expected %s to be archived", id)
+		}
+	}
+	for _, id := range []string{ws.archivedOpen, ws.activeGoal, ws.activeDone} {
+		if ws.store.IsArchived(ctx, id) {
+			t.Errorf("Expected %s to stay live", id)
+		}
+	}
+
+	// Archived goals and objectives are still listed on request
+	goals, _ := ws.gm.ListGoals(ctx, GoalFilter{})
+	if len(goals) != 1 {
+		t.Errorf("Expected 1 live goal, got %d", len(goals))
+	}
+	status := GoalStatusArchived
+	goals, _ = ws.gm.ListGoals(ctx, GoalFilter{Status: &status})
+	if len(goals) != 1 || goals[0].ID != ws.archivedGoal {
+		t.Errorf("Expected filtering on archived status to find the archived goal, got %d", len(goals))
+	}
+	objectives, _ := ws.om.ListObjectives(ctx, ObjectiveFilter{GoalID: &ws.archivedGoal, IncludeArchived: true})
+	if len(objectives) != 2 {
+		t.Errorf("Expected 2 objectives for the archived goal, got %d", len(objectives))
+	}
+
+	// A second pass picks up work settled since
+	status2 := ObjectiveStatusFailed
+	if _, err := ws.om.UpdateObjective(ctx, ws.archivedOpen, ObjectiveUpdates{Status: &status2}); err != nil {
+		t.Fatalf("Failed to fail objective: %v", err)
+	}
+	report, err = am.ArchiveSettled(ctx, ArchivePolicy{}, false)
+	if err != nil {
+		t.Fatalf("Second ArchiveSettled failed: %v", err)
+	}
+	if report.TotalNodes() != 1 || !ws.store.IsArchived(ctx, ws.archivedOpen) {
+		t.Errorf("Expected the newly failed objective to be archived, got %s", report)
+	}
+}
+
+func TestArchiveManager_DryRunAndMinAge(t *testing.T) {
+	ws := setupArchiveTestWorkspace(t)
+	ctx := context.Background()
+	am := NewArchiveManager(ws.store)
+
+	report, err := am.ArchiveSettled(ctx, ArchivePolicy{}, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if report.TotalNodes() != 3 || !report.DryRun {
+		t.Errorf("Expected a dry run listing 3 nodes, got %s", report)
+	}
+	if ws.store.IsArchived(ctx, ws.archivedGoal) {
+		t.Error("Expected a dry run to leave the store unchanged")
+	}
+
+	// The goal was archived just now, so the default policy leaves it alone
+	report, err = am.ArchiveSettled(ctx, DefaultArchivePolicy(), false)
+	if err != nil {
+		t.Fatalf("ArchiveSettled failed: %v", err)
+	}
+	if report.TotalNodes() != 0 || report.String() != "nothing to archive" {
+		t.Errorf("Expected nothing to archive under the default policy, got %s", report)
+	}
+}
@@ -0,0 +1,920 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+const (
+	// backupRunNodeType is the node type recording each backup, verify or restore run
+	backupRunNodeType = "backup_run"
+
+	// backupIDLayout formats backup IDs; they sort chronologically as strings
+	backupIDLayout = "20060102-150405.000000"
+
+	// backupManifestFile is the manifest file name inside each backup directory
+	backupManifestFile = "manifest.json"
+
+	// backupObjectsDir holds file contents addressed by SHA-256, shared by all backups
+	backupObjectsDir = "objects"
+
+	// backupManifestsDir holds one directory per backup with its manifest
+	backupManifestsDir = "backups"
+
+	// backupTempPrefix marks partially written files in the target directory
+	backupTempPrefix = ".tmp-"
+
+	// DefaultBackupKeepDaily is how many daily backups rotation keeps by default
+	DefaultBackupKeepDaily = 7
+
+	// DefaultBackupKeepWeekly is how many weekly backups rotation keeps by default
+	DefaultBackupKeepWeekly = 4
+)
+
+// backupSourceDirs are the data directory subdirectories that make up a store.
+var backupSourceDirs = []string{"nodes", "edges", "archive"}
+
+// BackupRunStatus is the outcome of a backup, verify or restore run.
+type BackupRunStatus string
+
+const (
+	// BackupRunSucceeded indicates the run completed and, for backups, verified
+	BackupRunSucceeded BackupRunStatus = "succeeded"
+
+	// BackupRunFailed indicates the run could not complete
+	BackupRunFailed BackupRunStatus = "failed"
+)
+
+// BackupConfig configures where backups go and how many are kept.
+type BackupConfig struct {
+	// TargetDir is the backup destination, e.g. an external drive or synced folder
+	TargetDir string
+
+	// KeepDaily is how many most recent days keep their newest backup
+	KeepDaily int
+
+	// KeepWeekly is how many most recent weeks keep their newest backup
+	KeepWeekly int
+
+	// Notifier receives alerts when the target is unreachable or a backup fails verification
+	Notifier Notifier
+}
+
+// BackupFile describes one store file captured by a backup.
+type BackupFile struct {
+	// Path is the file path relative to the data directory, slash-separated
+	Path string `json:"path"`
+
+	// Size is the file size in bytes
+	Size int64 `json:"size"`
+
+	// ModTime is the file modification time when it was captured
+	ModTime time.Time `json:"mod_time"`
+
+	// SHA256 is the hex checksum of the content, which is also its object name
+	SHA256 string `json:"sha256"`
+}
+
+// BackupManifest lists every file of the store at the time of a backup.
+// File contents live in the shared object directory, so a backup only
+// copies files that changed since the previous one.
+type BackupManifest struct {
+	// ID uniquely identifies the backup and sorts chronologically
+	ID string `json:"id"`
+
+	// CreatedAt is when the backup was taken
+	CreatedAt time.Time `json:"created_at"`
+
+	// Sequence is the store's logical sequence number at the time of the backup
+	Sequence uint64 `json:"sequence"`
+
+	// Parent is the previous backup this one was taken incrementally from
+	Parent string `json:"parent,omitempty"`
+
+	// Files lists every store file, sorted by path
+	Files []BackupFile `json:"files"`
+
+	// FilesCopied counts the files whose content was new to the target
+	FilesCopied int `json:"files_copied"`
+
+	// BytesCopied counts the bytes written to the target for this backup
+	BytesCopied int64 `json:"bytes_copied"`
+}
+
+// TotalSize returns the size of the store captured by the backup.
+func (m *BackupManifest) TotalSize() int64 {
+	var total int64
+	for _, file := range m.Files {
+		total += file.Size
+	}
+	return total
+}
+
+// BackupVerification is the result of re-hashing a backup's files.
+type BackupVerification struct {
+	// BackupID identifies the verified backup
+	BackupID string
+
+	// FilesChecked counts the files that were re-hashed
+	FilesChecked int
+
+	// Problems describes every missing or corrupted file
+	Problems []string
+
+	// VerifiedAt is when the verification ran
+	VerifiedAt time.Time
+}
+
+// OK reports whether every file matched its recorded checksum.
+func (v *BackupVerification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// BackupRun is a recorded backup, verify or restore run.
+type BackupRun struct {
+	ID          string
+	Operation   string // "backup", "verify" or "restore"
+	BackupID    string
+	Status      BackupRunStatus
+	Verified    bool
+	Sequence    uint64
+	FilesTotal  int
+	FilesCopied int
+	BytesCopied int64
+	Error       string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+}
+
+// BackupHealth summarizes recorded runs for status displays and the digest.
+type BackupHealth struct {
+	// LastSuccess is the most recent successful backup (nil if none)
+	LastSuccess *BackupRun
+
+	// LastVerified is when a backup was last verified successfully
+	LastVerified time.Time
+
+	// LastFailure is the most recent failed backup or verification (nil if none)
+	LastFailure *BackupRun
+
+	// LastAttempt is when a backup was last attempted, successful or not
+	LastAttempt time.Time
+}
+
+// Summary describes backup health in one line, e.g. "last verified backup: 2 days ago".
+func (h *BackupHealth) Summary(now time.Time) string {
+	summary := "no verified backup yet"
+	if !h.LastVerified.IsZero() {
+		summary = "last verified backup: " + formatBackupAge(now.Sub(h.LastVerified))
+	}
+	if h.LastFailure != nil && h.LastFailure.FinishedAt.After(h.LastVerified) {
+		summary += fmt.Sprintf(" (last %s failed %s: %s)", h.LastFailure.Operation,
+			formatBackupAge(now.Sub(h.LastFailure.FinishedAt)), h.LastFailure.Error)
+	}
+	return summary
+}
+
+// BackupDue reports whether a new backup should be taken. A backup is due
+// when the last success is older than interval; after a failure the next
+// attempt waits retryDelay so an unreachable target is not retried constantly.
+func (h *BackupHealth) BackupDue(now time.Time, interval, retryDelay time.Duration) bool {
+	if h.LastSuccess != nil && now.Sub(h.LastSuccess.FinishedAt) < interval {
+		return false
+	}
+	if h.LastFailure != nil && h.LastFailure.Operation == "backup" &&
+		h.LastAttempt.Equal(h.LastFailure.FinishedAt) && now.Sub(h.LastFailure.FinishedAt) < retryDelay {
+		return false
+	}
+	return true
+}
+
+// BackupManager takes incremental, verified backups of a store to a target
+// directory and restores them. The target layout is:
+//
+//	target/objects/{sha256}           - File contents, shared by all backups
+//	target/backups/{id}/manifest.json - Files and checksums for each backup
+type BackupManager struct {
+	store  *storage.Store
+	config BackupConfig
+}
+
+// NewBackupManager creates a backup manager for the store.
+// Zero retention counts fall back to the defaults.
+func NewBackupManager(store *storage.Store, config BackupConfig) *BackupManager {
+	if config.KeepDaily <= 0 {
+		config.KeepDaily = DefaultBackupKeepDaily
+	}
+	if config.KeepWeekly <= 0 {
+		config.KeepWeekly = DefaultBackupKeepWeekly
+	}
+
+	return &BackupManager{
+		store:  store,
+		config: config,
+	}
+}
+
+// TargetDir returns the backup destination directory.
+func (bm *BackupManager) TargetDir() string {
+	return bm.config.TargetDir
+}
+
+// Backup takes an incremental backup, verifies it and rotates old backups.
+// An unreachable target or failed verification is reported to the notifier
+// and recorded as a failed run.
+func (bm *BackupManager) Backup(ctx context.Context) (*BackupManifest, error) {
+	run := &BackupRun{Operation: "backup", StartedAt: time.Now()}
+
+	manifest, verification, err := bm.backup(ctx)
+	if manifest != nil {
+		run.BackupID = manifest.ID
+		run.Sequence = manifest.Sequence
+		run.FilesTotal = len(manifest.Files)
+		run.FilesCopied = manifest.FilesCopied
+		run.BytesCopied = manifest.BytesCopied
+	}
+	if err == nil && !verification.OK() {
+		err = fmt.Errorf("backup %s failed verification: %s", manifest.ID, strings.Join(verification.Problems, "; "))
+	}
+
+	if err != nil {
+		run.Status = BackupRunFailed
+		run.Error = err.Error()
+		run.FinishedAt = time.Now()
+		bm.recordRun(ctx, run)
+		bm.alert(ctx, "Backup failed", fmt.Sprintf("Backup to %s failed: %v", bm.config.TargetDir, err))
+		return nil, err
+	}
+
+	run.Status = BackupRunSucceeded
+	run.Verified = true
+	run.FinishedAt = time.Now()
+	bm.recordRun(ctx, run)
+
+	if _, err := bm.Rotate(ctx); err != nil {
+		fmt.Printf("Warning: failed to rotate backups: %v\n", err)
+	}
+
+	return manifest, nil
+}
+
+// backup copies changed files and writes the manifest, then verifies it.
+func (bm *BackupManager) backup(ctx context.Context) (*BackupManifest, *BackupVerification, error) {
+	if err := bm.checkTarget(); err != nil {
+		return nil, nil, err
+	}
+
+	previous, err := bm.latestManifest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read previous backup: %w", err)
+	}
+	previousFiles := make(map[string]BackupFile)
+	if previous != nil {
+		for _, file := range previous.Files {
+			previousFiles[file.Path] = file
+		}
+	}
+
+	now := time.Now()
+	manifest := &BackupManifest{
+		ID:        now.UTC().Format(backupIDLayout),
+		CreatedAt: now,
+	}
+	if previous != nil {
+		manifest.Parent = previous.ID
+	}
+
+	err = bm.store.WithFileSnapshot(func(sequence uint64) error {
+		manifest.Sequence = sequence
+		return bm.captureFiles(ctx, manifest, previousFiles)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to copy store files: %w", err)
+	}
+
+	if err := bm.writeManifest(manifest); err != nil {
+		return nil, nil, err
+	}
+
+	verification, err := bm.verifyManifest(ctx, manifest)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("failed to verify backup: %w", err)
+	}
+
+	return manifest, verification, nil
+}
+
+// checkTarget makes sure the target directory exists and is writable.
+func (bm *BackupManager) checkTarget() error {
+	if bm.config.TargetDir == "" {
+		return fmt.Errorf("no backup target directory is configured")
+	}
+
+	for _, dir := range []string{backupObjectsDir, backupManifestsDir} {
+		if err := os.MkdirAll(filepath.Join(bm.config.TargetDir, dir), 0755); err != nil {
+			return fmt.Errorf("backup target %s is unreachable: %w", bm.config.TargetDir, err)
+		}
+	}
+
+	probe, err := os.CreateTemp(bm.config.TargetDir, backupTempPrefix+"probe-")
+	if err != nil {
+		return fmt.Errorf("backup target %s is not writable: %w", bm.config.TargetDir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return nil
+}
+
+// captureFiles adds every store file to the manifest, copying only content
+// the target does not already hold. Files whose size and modification time
+// match the previous backup reuse its checksum without being read.
+func (bm *BackupManager) captureFiles(ctx context.Context, manifest *BackupManifest, previousFiles map[string]BackupFile) error {
+	dataDir := bm.store.DataDir()
+
+	for _, dir := range backupSourceDirs {
+		root := filepath.Join(dataDir, dir)
+		err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return nil
+				}
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", path, err)
+			}
+			relPath, err := filepath.Rel(dataDir, path)
+			if err != nil {
+				return fmt.Errorf("failed to get relative path: %w", err)
+			}
+
+			file := BackupFile{
+				Path:    filepath.ToSlash(relPath),
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			}
+
+			if prev, ok := previousFiles[file.Path]; ok && prev.Size == file.Size && prev.ModTime.Equal(file.ModTime) &&
+				bm.objectExists(prev.SHA256) {
+				file.SHA256 = prev.SHA256
+			} else {
+				checksum, copied, err := bm.storeObject(path)
+				if err != nil {
+					return err
+				}
+				file.SHA256 = checksum
+				if copied {
+					manifest.FilesCopied++
+					manifest.BytesCopied += file.Size
+				}
+			}
+
+			manifest.Files = append(manifest.Files, file)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	return nil
+}
+
+// storeObject hashes a file while copying it into the object directory.
+// It returns the checksum and whether the content was new to the target.
+func (bm *BackupManager) storeObject(path string) (string, bool, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer src.Close()
+
+	objectsDir := filepath.Join(bm.config.TargetDir, backupObjectsDir)
+	tmp, err := os.CreateTemp(objectsDir, backupTempPrefix)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create object in backup target: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), src); err != nil {
+		tmp.Close()
+		return "", false, fmt.Errorf("failed to copy %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", false, fmt.Errorf("failed to write object for %s: %w", path, err)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if bm.objectExists(checksum) {
+		return checksum, false, nil
+	}
+	if err := os.Rename(tmp.Name(), bm.objectPath(checksum)); err != nil {
+		return "", false, fmt.Errorf("failed to store object for %s: %w", path, err)
+	}
+	return checksum, true, nil
+}
+
+// objectPath returns where the content with the given checksum is stored.
+func (bm *BackupManager) objectPath(checksum string) string {
+	return filepath.Join(bm.config.TargetDir, backupObjectsDir, checksum)
+}
+
+// objectExists reports whether the target holds the content with the given checksum.
+func (bm *BackupManager) objectExists(checksum string) bool {
+	if checksum == "" {
+		return false
+	}
+	_, err := os.Stat(bm.objectPath(checksum))
+	return err == nil
+}
+
+// writeManifest atomically writes a backup manifest.
+func (bm *BackupManager) writeManifest(manifest *BackupManifest) error {
+	dir := filepath.Join(bm.config.TargetDir, backupManifestsDir, manifest.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+
+	tmpPath := filepath.Join(dir, backupTempPrefix+backupManifestFile)
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, backupManifestFile)); err != nil {
+		return fmt.Errorf("failed to finalize backup manifest: %w", err)
+	}
+	return nil
+}
+
+// LoadManifest reads the manifest of a backup.
+func (bm *BackupManager) LoadManifest(backupID string) (*BackupManifest, error) {
+	if backupID == "" || !filepath.IsLocal(backupID) || strings.ContainsAny(backupID, `/\`) {
+		return nil, fmt.Errorf("invalid backup ID: %q", backupID)
+	}
+
+	path := filepath.Join(bm.config.TargetDir, backupManifestsDir, backupID, backupManifestFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest for backup %s: %w", backupID, err)
+	}
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest for backup %s: %w", backupID, err)
+	}
+	return &manifest, nil
+}
+
+// List returns all backups in the target directory, newest first.
+func (bm *BackupManager) List() ([]*BackupManifest, error) {
+	entries, err := os.ReadDir(filepath.Join(bm.config.TargetDir, backupManifestsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var manifests []*BackupManifest
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := bm.LoadManifest(entry.Name())
+		if err != nil {
+			continue // Skip incomplete backups
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].ID > manifests[j].ID
+	})
+	return manifests, nil
+}
+
+// latestManifest returns the newest backup, or nil if there is none.
+func (bm *BackupManager) latestManifest() (*BackupManifest, error) {
+	manifests, err := bm.List()
+	if err != nil || len(manifests) == 0 {
+		return nil, err
+	}
+	return manifests[0], nil
+}
+
+// Verify re-hashes every file of a backup and compares it with the manifest.
+// The run is recorded so backup health reflects the latest verification.
+func (bm *BackupManager) Verify(ctx context.Context, backupID string) (*BackupVerification, error) {
+	run := &BackupRun{Operation: "verify", BackupID: backupID, StartedAt: time.Now()}
+
+	manifest, err := bm.LoadManifest(backupID)
+	var verification *BackupVerification
+	if err == nil {
+		run.Sequence = manifest.Sequence
+		run.FilesTotal = len(manifest.Files)
+		verification, err = bm.verifyManifest(ctx, manifest)
+	}
+	if err == nil && !verification.OK() {
+		run.Error = fmt.Sprintf("%d problems: %s", len(verification.Problems), strings.Join(verification.Problems, "; "))
+		bm.alert(ctx, "Backup verification failed", fmt.Sprintf("Backup %s in %s is damaged: %s", backupID, bm.config.TargetDir, run.Error))
+	}
+
+	run.FinishedAt = time.Now()
+	switch {
+	case err != nil:
+		run.Status = BackupRunFailed
+		run.Error = err.Error()
+	case verification.OK():
+		run.Status = BackupRunSucceeded
+		run.Verified = true
+	default:
+		run.Status = BackupRunFailed
+	}
+	bm.recordRun(ctx, run)
+
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
+// verifyManifest re-hashes the objects referenced by a manifest.
+func (bm *BackupManager) verifyManifest(ctx context.Context, manifest *BackupManifest) (*BackupVerification, error) {
+	verification := &BackupVerification{
+		BackupID:   manifest.ID,
+		VerifiedAt: time.Now(),
+	}
+
+	for _, file := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		verification.FilesChecked++
+		checksum, err := hashFile(bm.objectPath(file.SHA256))
+		if err != nil {
+			if os.IsNotExist(err) {
+				verification.Problems = append(verification.Problems, fmt.Sprintf("%s: content is missing", file.Path))
+				continue
+			}
+			return nil, fmt.Errorf("failed to read backup of %s: %w", file.Path, err)
+		}
+		if checksum != file.SHA256 {
+			verification.Problems = append(verification.Problems, fmt.Sprintf("%s: checksum mismatch", file.Path))
+		}
+	}
+
+	return verification, nil
+}
+
+// Restore reconstructs the store captured by a backup in targetDataDir.
+// It refuses to write into a non-empty directory unless force is set, and
+// never restores over the directory of the store being backed up.
+func (bm *BackupManager) Restore(ctx context.Context, backupID, targetDataDir string, force bool) error {
+	run := &BackupRun{Operation: "restore", BackupID: backupID, StartedAt: time.Now()}
+
+	err := bm.restore(ctx, backupID, targetDataDir, force, run)
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Status = BackupRunFailed
+		run.Error = err.Error()
+	} else {
+		run.Status = BackupRunSucceeded
+		run.Verified = true
+	}
+	bm.recordRun(ctx, run)
+
+	return err
+}
+
+// restore does the work of Restore, filling in run details as it goes.
+func (bm *BackupManager) restore(ctx context.Context, backupID, targetDataDir string, force bool, run *BackupRun) error {
+	manifest, err := bm.LoadManifest(backupID)
+	if err != nil {
+		return err
+	}
+	run.Sequence = manifest.Sequence
+	run.FilesTotal = len(manifest.Files)
+
+	target, err := filepath.Abs(targetDataDir)
+	if err != nil {
+		return fmt.Errorf("invalid restore directory: %w", err)
+	}
+	if live, err := filepath.Abs(bm.store.DataDir()); err == nil && live == target {
+		return fmt.Errorf("cannot restore into %s while its store is open", target)
+	}
+
+	entries, err := os.ReadDir(target)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read restore directory: %w", err)
+	}
+	if len(entries) > 0 {
+		if !force {
+			return fmt.Errorf("restore directory %s is not empty; use force to overwrite it", target)
+		}
+		for _, dir := range backupSourceDirs {
+			if err := os.RemoveAll(filepath.Join(target, dir)); err != nil {
+				return fmt.Errorf("failed to clear %s: %w", dir, err)
+			}
+		}
+	}
+
+	for _, file := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return fmt.Errorf("backup %s contains an unsafe path: %q", backupID, file.Path)
+		}
+		if err := bm.restoreFile(file, filepath.Join(target, filepath.FromSlash(file.Path))); err != nil {
+			return err
+		}
+	}
+
+	// Opening the restored store proves it loads and is consistent with the manifest
+	restored, err := storage.NewStore(target)
+	if err != nil {
+		return fmt.Errorf("restored store does not load: %w", err)
+	}
+	defer restored.Close()
+	if restored.Sequence() != manifest.Sequence {
+		return fmt.Errorf("restored store is at sequence %d, backup recorded %d", restored.Sequence(), manifest.Sequence)
+	}
+
+	return nil
+}
+
+// restoreFile copies one object to its place in the data directory,
+// checking its checksum on the way.
+func (bm *BackupManager) restoreFile(file BackupFile, dst string) error {
+	src, err := os.Open(bm.objectPath(file.SHA256))
+	if err != nil {
+		return fmt.Errorf("failed to open backup of %s: %w", file.Path, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), src); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to restore %s: %w", file.Path, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != file.SHA256 {
+		return fmt.Errorf("backup of %s is corrupted: checksum mismatch", file.Path)
+	}
+	if !file.ModTime.IsZero() {
+		os.Chtimes(dst, file.ModTime, file.ModTime)
+	}
+	return nil
+}
+
+// Rotate deletes backups outside the retention policy and any content no
+// remaining backup refers to. The newest backup of each of the last KeepDaily
+// days and KeepWeekly weeks is kept, as is the newest backup overall.
+// It returns the IDs of the removed backups.
+func (bm *BackupManager) Rotate(ctx context.Context) ([]string, error) {
+	manifests, err := bm.List()
+	if err != nil {
+		return nil, err
+	}
+
+	keep := selectBackupsToKeep(manifests, bm.config.KeepDaily, bm.config.KeepWeekly)
+
+	var removed []string
+	referenced := make(map[string]bool)
+	for _, manifest := range manifests {
+		if keep[manifest.ID] {
+			for _, file := range manifest.Files {
+				referenced[file.SHA256] = true
+			}
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(bm.config.TargetDir, backupManifestsDir, manifest.ID)); err != nil {
+			return removed, fmt.Errorf("failed to remove backup %s: %w", manifest.ID, err)
+		}
+		removed = append(removed, manifest.ID)
+	}
+
+	// Collect content that no remaining backup refers to
+	objectsDir := filepath.Join(bm.config.TargetDir, backupObjectsDir)
+	entries, err := os.ReadDir(objectsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return removed, fmt.Errorf("failed to read backup objects: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || referenced[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(objectsDir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to remove unreferenced backup object: %w", err)
+		}
+	}
+
+	return removed, nil
+}
+
+// selectBackupsToKeep applies the daily/weekly retention policy to backups
+// sorted newest first.
+func selectBackupsToKeep(manifests []*BackupManifest, keepDaily, keepWeekly int) map[string]bool {
+	keep := make(map[string]bool)
+	if len(manifests) == 0 {
+		return keep
+	}
+	keep[manifests[0].ID] = true
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for _, manifest := range manifests {
+		created := manifest.CreatedAt.Local()
+
+		day := created.Format("2006-01-02")
+		if !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep[manifest.ID] = true
+		}
+
+		year, week := created.ISOWeek()
+		weekKey := fmt.Sprintf("%d-W%02d", year, week)
+		if !weeks[weekKey] && len(weeks) < keepWeekly {
+			weeks[weekKey] = true
+			keep[manifest.ID] = true
+		}
+	}
+	return keep
+}
+
+// Health summarizes recorded backup runs.
+func (bm *BackupManager) Health(ctx context.Context) (*BackupHealth, error) {
+	runs, err := bm.Runs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &BackupHealth{}
+	for _, run := range runs {
+		if run.Verified && run.FinishedAt.After(health.LastVerified) && run.Operation != "restore" {
+			health.LastVerified = run.FinishedAt
+		}
+		if run.Operation == "backup" {
+			if run.FinishedAt.After(health.LastAttempt) {
+				health.LastAttempt = run.FinishedAt
+			}
+			if run.Status == BackupRunSucceeded && (health.LastSuccess == nil || run.FinishedAt.After(health.LastSuccess.FinishedAt)) {
+				health.LastSuccess = run
+			}
+		}
+		// A refused restore says nothing about the backups themselves
+		if run.Status == BackupRunFailed && run.Operation != "restore" && (health.LastFailure == nil || run.FinishedAt.After(health.LastFailure.FinishedAt)) {
+			health.LastFailure = run
+		}
+	}
+	return health, nil
+}
+
+// Runs returns all recorded backup runs, most recent first.
+func (bm *BackupManager) Runs(ctx context.Context) ([]*BackupRun, error) {
+	nodes, err := bm.store.GetNodesByType(ctx, backupRunNodeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query backup runs: %w", err)
+	}
+
+	runs := make([]*BackupRun, 0, len(nodes))
+	for _, node := range nodes {
+		runs = append(runs, nodeToBackupRun(node))
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].FinishedAt.After(runs[j].FinishedAt)
+	})
+	return runs, nil
+}
+
+// recordRun stores a run as a node. Failing to record never fails the run itself.
+func (bm *BackupManager) recordRun(ctx context.Context, run *BackupRun) {
+	node := storage.NewNode(backupRunNodeType, map[string]interface{}{
+		"operation":    run.Operation,
+		"backup_id":    run.BackupID,
+		"status":       string(run.Status),
+		"verified":     run.Verified,
+		"sequence":     run.Sequence,
+		"files_total":  run.FilesTotal,
+		"files_copied": run.FilesCopied,
+		"bytes_copied": run.BytesCopied,
+		"error":        run.Error,
+		"target_dir":   bm.config.TargetDir,
+		"started_at":   run.StartedAt.Format(time.RFC3339Nano),
+		"finished_at":  run.FinishedAt.Format(time.RFC3339Nano),
+	})
+	run.ID = node.ID
+
+	if err := bm.store.AddNode(ctx, node); err != nil {
+		fmt.Printf("Warning: failed to record backup run: %v\n", err)
+	}
+}
+
+// nodeToBackupRun converts a backup_run node to a BackupRun.
+func nodeToBackupRun(node *storage.Node) *BackupRun {
+	run := &BackupRun{
+		ID:          node.ID,
+		Operation:   getString(node.Data, "operation"),
+		BackupID:    getString(node.Data, "backup_id"),
+		Status:      BackupRunStatus(getString(node.Data, "status")),
+		Sequence:    uint64(getFloat64(node.Data, "sequence")),
+		FilesTotal:  int(getFloat64(node.Data, "files_total")),
+		FilesCopied: int(getFloat64(node.Data, "files_copied")),
+		BytesCopied: int64(getFloat64(node.Data, "bytes_copied")),
+		Error:       getString(node.Data, "error"),
+	}
+	run.Verified, _ = node.Data["verified"].(bool)
+	run.StartedAt, _ = time.Parse(time.RFC3339Nano, getString(node.Data, "started_at"))
+	run.FinishedAt, _ = time.Parse(time.RFC3339Nano, getString(node.Data, "finished_at"))
+	return run
+}
+
+// alert reports a backup problem through the notifier, falling back to
+// stderr so a problem is never silent.
+func (bm *BackupManager) alert(ctx context.Context, title, message string) {
+	if bm.config.Notifier != nil {
+		if err := bm.config.Notifier.Notify(ctx, title, message); err == nil {
+			return
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: failed to deliver backup alert: %v\n", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", title, message)
+}
+
+// hashFile returns the hex SHA-256 checksum of a file.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// formatBackupAge describes how long ago something happened.
+func formatBackupAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return pluralize(int(age/time.Minute), "minute") + " ago"
+	case age < 24*time.Hour:
+		return pluralize(int(age/time.Hour), "hour") + " ago"
+	default:
+		return pluralize(int(age/(24*time.Hour)), "day") + " ago"
+	}
+}
+
+// pluralize formats a count with a singular or plural unit.
+func pluralize(count int, unit string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", count, unit)
+}
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// storeContents returns the current version of every node of the given types.
+func storeContents(t *testing.T, store *storage.Store, nodeTypes ...string) map[string]map[string]interface{} {
+	t.Helper()
+	contents := make(map[string]map[string]interface{})
+	for _, nodeType := range nodeTypes {
+		nodes, err := store.GetNodesByType(context.Background(), nodeType)
+		if err != nil {
+			t.Fatalf("Failed to get %s nodes: %v", nodeType, err)
+		}
+		for _, node := range nodes {
+			contents[node.ID] = node.Data
+		}
+	}
+	return contents
+}
+
+func TestBackupManager_IncrementalBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+
+	store, err := storage.NewStore(filepath.Join(tempDir, "data"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	bm := NewBackupManager(store, BackupConfig{TargetDir: filepath.Join(tempDir, "backup")})
+
+	addNotes := func(prefix string, count int) {
+		for i := 0; i < count; i++ {
+			node := storage.NewNode("note", map[string]interface{}{"title": prefix, "index": i})
+			if err := store.AddNode(ctx, node); err != nil {
+				t.Fatalf("Failed to add node: %v", err)
+			}
+		}
+	}
+
+	addNotes("first", 3)
+	first, err := bm.Backup(ctx)
+	if err != nil {
+		t.Fatalf("First backup failed: %v", err)
+	}
+	if first.FilesCopied != 3 {
+		t.Errorf("Expected 3 files copied by the first backup, got %d", first.FilesCopied)
+	}
+
+	// The run recorded by the first backup is the only new file
+	second, err := bm.Backup(ctx)
+	if err != nil {
+		t.Fatalf("Second backup failed: %v", err)
+	}
+	if second.Parent != first.ID {
+		t.Errorf("Expected second backup to build on %s, got %q", first.ID, second.Parent)
+	}
+	if second.FilesCopied != 1 {
+		t.Errorf("Expected 1 file copied by the second backup, got %d", second.FilesCopied)
+	}
+
+	addNotes("third", 2)
+	third, err := bm.Backup(ctx)
+	if err != nil {
+		t.Fatalf("Third backup failed: %v", err)
+	}
+	if third.FilesCopied != 3 {
+		t.Errorf("Expected 3 files copied by the third backup, got %d", third.FilesCopied)
+	}
+	if len(third.Files) != 7 {
+		t.Errorf("Expected 7 files in the third backup, got %d", len(third.Files))
+	}
+
+	restoreDir := filepath.Join(tempDir, "restored")
+	if err := bm.Restore(ctx, third.ID, restoreDir, false); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored, err := storage.NewStore(restoreDir)
+	if err != nil {
+		t.Fatalf("Failed to open restored store: %v", err)
+	}
+	defer restored.Close()
+
+	if restored.Sequence() != third.Sequence {
+		t.Errorf("Expected restored sequence %d, got %d", third.Sequence, restored.Sequence())
+	}
+
+	// Compare through a reload, since the live store still holds unmarshalled Go types
+	live, err := storage.NewStore(store.DataDir())
+	if err != nil {
+		t.Fatalf("Failed to reload live store: %v", err)
+	}
+	defer live.Close()
+	want := storeContents(t, live, "note")
+	got := storeContents(t, restored, "note")
+	if len(want) != 5 || !reflect.DeepEqual(got, want) {
+		t.Errorf("Restored notes do not match the live store:\n got %v\nwant %v", got, want)
+	}
+
+	// Rotation keeps only the newest backup of the day
+	backups, err := bm.List()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 1 || backups[0].ID != third.ID {
+		t.Errorf("Expected only %s after rotation, got %d backups", third.ID, len(backups))
+	}
+	if _, err := os.Stat(bm.objectPath(third.Files[0].SHA256)); err != nil {
+		t.Errorf("Expected objects of the kept backup to survive rotation: %v", err)
+	}
+
+	// A non-empty directory needs force
+	if err := bm.Restore(ctx, third.ID, restoreDir, false); err == nil {
+		t.Error("Expected restore into a non-empty directory to fail without force")
+	}
+	if err := os.WriteFile(filepath.Join(restoreDir, "nodes", "note", "stale.json"), []byte("not json"), 0644); err != nil {
+		t.Fatalf("Failed to write stale file: %v", err)
+	}
+	if err := bm.Restore(ctx, third.ID, restoreDir, true); err != nil {
+		t.Fatalf("Forced restore failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(restoreDir, "nodes", "note", "stale.json")); !os.IsNotExist(err) {
+		t.Error("Expected forced restore to remove files that are not in the backup")
+	}
+
+	if err := bm.Restore(ctx, third.ID, store.DataDir(), true); err == nil {
+		t.Error("Expected restore over the live store to fail")
+	}
+}
+
+func TestBackupManager_VerifyDetectsCorruption(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	store := setupTestStore(t)
+
+	var alerts []string
+	bm := NewBackupManager(store, BackupConfig{
+		TargetDir: filepath.Join(tempDir, "backup"),
+		Notifier: NotifierFunc(func(ctx context.Context, title, message string) error {
+			alerts = append(alerts, title)
+			return nil
+		}),
+	})
+
+	if err := store.AddNode(ctx, storage.NewNode("note", map[string]interface{}{"title": "keep me"})); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	manifest, err := bm.Backup(ctx)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	verification, err := bm.Verify(ctx, manifest.ID)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !verification.OK() || verification.FilesChecked != len(manifest.Files) {
+		t.Fatalf("Expected a clean verification of %d files, got %+v", len(manifest.Files), verification)
+	}
+
+	// Flip the content of one backed-up file
+	objectPath := bm.objectPath(manifest.Files[0].SHA256)
+	if err := os.WriteFile(objectPath, []byte(`{"corrupted": true}`), 0644); err != nil {
+		t.Fatalf("Failed to corrupt object: %v", err)
+	}
+
+	verification, err = bm.Verify(ctx, manifest.ID)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if verification.OK() {
+		t.Fatal("Expected verification to detect the corrupted file")
+	}
+	if !strings.Contains(verification.Problems[0], manifest.Files[0].Path) {
+		t.Errorf("Expected problem to name %s, got %v", manifest.Files[0].Path, verification.Problems)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("Expected one alert for the corrupted backup, got %v", alerts)
+	}
+
+	if err := bm.Restore(ctx, manifest.ID, filepath.Join(tempDir, "restored"), false); err == nil {
+		t.Error("Expected restore of a corrupted backup to fail")
+	}
+
+	health, err := bm.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health failed: %v", err)
+	}
+	if health.LastSuccess == nil || health.LastSuccess.BackupID != manifest.ID {
+		t.Errorf("Expected last success to be %s, got %+v", manifest.ID, health.LastSuccess)
+	}
+	if health.LastFailure == nil || health.LastFailure.Operation != "verify" {
+		t.Errorf("Expected the failed verification as last failure, got %+v", health.LastFailure)
+	}
+}
+
+func TestBackupManager_UnreachableTargetAlerts(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+
+	// A regular file where the target directory should be
+	blocker := filepath.Join(t.TempDir(), "not-a-directory")
+	if err := os.WriteFile(blocker, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create blocker file: %v", err)
+	}
+
+	var alerts []string
+	bm := NewBackupManager(store, BackupConfig{
+		TargetDir: filepath.Join(blocker, "backup"),
+		Notifier: NotifierFunc(func(ctx context.Context, title, message string) error {
+			alerts = append(alerts, message)
+			return nil
+		}),
+	})
+
+	if _, err := bm.Backup(ctx); err == nil {
+		t.Fatal("Expected backup to an unreachable target to fail")
+	}
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "unreachable") {
+		t.Errorf("Expected an unreachable-target alert, got %v", alerts)
+	}
+
+	health, err := bm.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health failed: %v", err)
+	}
+	if health.LastFailure == nil || health.LastSuccess != nil {
+		t.Fatalf("Expected one failed run and no success, got %+v", health)
+	}
+
+	now := time.Now()
+	if health.BackupDue(now, 24*time.Hour, time.Hour) {
+		t.Error("Expected no retry within the retry delay")
+	}
+	if !health.BackupDue(now.Add(2*time.Hour), 24*time.Hour, time.Hour) {
+		t.Error("Expected a retry once the retry delay has passed")
+	}
+	if summary := health.Summary(now); !strings.HasPrefix(summary, "no verified backup yet") {
+		t.Errorf("Unexpected summary: %q", summary)
+	}
+}
+
+func TestSelectBackupsToKeep(t *testing.T) {
+	// One backup every 12 hours for 6 weeks, newest first
+	base := time.Date(2024, time.June, 30, 18, 0, 0, 0, time.Local)
+	var manifests []*BackupManifest
+	for i := 0; i < 84; i++ {
+		created := base.Add(-time.Duration(i) * 12 * time.Hour)
+		manifests = append(manifests, &BackupManifest{ID: created.UTC().Format(backupIDLayout), CreatedAt: created})
+	}
+
+	keep := selectBackupsToKeep(manifests, 7, 4)
+
+	// 7 daily backups, plus the newest of each of the 3 weeks before the current one
+	if len(keep) != 10 {
+		t.Errorf("Expected 10 backups kept, got %d", len(keep))
+	}
+	if !keep[manifests[0].ID] {
+		t.Error("Expected the newest backup to be kept")
+	}
+	if keep[manifests[1].ID] {
+		t.Error("Expected the older backup of the newest day to be rotated out")
+	}
+	if keep[manifests[len(manifests)-1].ID] {
+		t.Error("Expected the oldest backup to be rotated out")
+	}
+}
+
+func TestBackupHealthSummary(t *testing.T) {
+	now := time.Now()
+	health := &BackupHealth{LastVerified: now.Add(-50 * time.Hour)}
+	if got := health.Summary(now); got != "last verified backup: 2 days ago" {
+		t.Errorf("Unexpected summary: %q", got)
+	}
+
+	health.LastFailure = &BackupRun{Operation: "backup", Error: "disk full", FinishedAt: now.Add(-3 * time.Hour)}
+	if got := health.Summary(now); got != "last verified backup: 2 days ago (last backup failed 3 hours ago: disk full)" {
+		t.Errorf("Unexpected summary: %q", got)
+	}
+}
@@ -37,6 +37,10 @@ type TaskContext struct {
 
 	// Priority indicates task priority within the plan (1-10)
 	Priority int `json:"priority,omitempty"`
+
+	// Consumes lists the objective context keys this task reads, so changes to
+	// other keys need not re-plan it. Empty means the task's inputs are undeclared.
+	Consumes []string `json:"consumes,omitempty"`
 }
 
 // ExecutionTask represents a single task in an execution plan.
@@ -83,14 +87,35 @@ type ExecutionPlan struct {
 	// Dependencies specify prerequisite relationships between tasks
 	Dependencies []TaskDependency
 
-	// TotalEstimatedTokens is the sum of all task token estimates
+	// TotalEstimatedTokens is the sum of all task token estimates, scaled by
+	// Calibration when the method's history corrects its estimates
 	TotalEstimatedTokens int
 
+	// Calibration records how TotalEstimatedTokens was adjusted from the task
+	// estimates (nil for plans built outside the CC or without a method)
+	Calibration *EstimateCalibration
+
 	// CreatedBy indicates what created this plan (e.g., "contemplative_cursor")
 	CreatedBy string
 
 	// CreatedAt is when this plan was generated
 	CreatedAt time.Time
+
+	// ContextFingerprint records the objective context the plan was made for
+	// (nil for plans built outside the CC)
+	ContextFingerprint *ContextFingerprint
+
+	// Replan records how the plan was derived from the previous execution's plan
+	// (nil unless made by PlanExecution)
+	Replan *ReplanDecision
+
+	// Comparison identifies the comparison branch the plan runs in
+	// (nil unless run by ExecuteComparison)
+	Comparison *ComparisonBranch
+
+	// Validation is what the RTC's plan validator found before running the
+	// plan (nil when no validator is set)
+	Validation *PlanValidationReport
 }
 
 // LLMReasoner defines the interface for LLM-based reasoning operations.
@@ -168,6 +193,12 @@ type ContemplativeCursor struct {
 
 	// reasoner provides LLM-based analysis and planning capabilities
 	reasoner LLMReasoner
+
+	// replanAdvisor compares objective contexts with those of previous plans
+	replanAdvisor *ReplanAdvisor
+
+	// calibration configures how estimates are corrected from method history
+	calibration EstimateCalibrationConfig
 }
 
 // NewContemplativeCursor creates a new CC instance with the given dependencies.
@@ -178,7 +209,65 @@ func NewContemplativeCursor(store *storage.Store, reasoner LLMReasoner) *Contemp
 		methodManager:    NewMethodManager(store),
 		objectiveManager: NewObjectiveManager(store),
 		reasoner:         reasoner,
+		replanAdvisor:    NewReplanAdvisor(store, nil),
+		calibration:      DefaultEstimateCalibrationConfig(),
+	}
+}
+
+// SetEstimateCalibration configures how plan estimates are corrected from
+// the history of their method. A MaxFactor of 1 turns calibration off.
+func (cc *ContemplativeCursor) SetEstimateCalibration(config EstimateCalibrationConfig) {
+	cc.calibration = config.withDefaults()
+}
+
+// SetContextLoader sets the loader used to resolve references in objective
+// contexts, so that changes behind references are noticed when re-planning.
+func (cc *ContemplativeCursor) SetContextLoader(loader ContextLoader) {
+	cc.replanAdvisor.loader = loader
+}
+
+// PlanExecution plans an objective, keeping as much of its previous
+// execution's plan as the changes to the objective's context allow: the plan
+// is reused when no task is affected, only the affected tasks are re-planned
+// when the tasks declare what they consume, and otherwise the objective is
+// planned from scratch. The decision and context diff are attached to the plan
+// so they are recorded with its execution.
+func (cc *ContemplativeCursor) PlanExecution(ctx context.Context, objectiveID string) (*ExecutionPlan, error) {
+	decision, err := cc.replanAdvisor.Preview(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare objective context: %w", err)
+	}
+
+	var plan *ExecutionPlan
+	switch decision.Strategy {
+	case ReplanReuse:
+		plan = decision.PreviousPlan.reissue()
+	case ReplanPartial:
+		fresh, err := cc.CreateExecutionPlan(ctx, objectiveID)
+		if err != nil {
+			return nil, err
+		}
+		merged, replaced, err := mergePartialPlan(decision.PreviousPlan, fresh, decision.AffectedTasks)
+		if err != nil {
+			decision.Strategy = ReplanFull
+			decision.Reason = fmt.Sprintf("%s, but a partial re-plan was not possible: %v", decision.Reason, err)
+			decision.AffectedTasks = nil
+			plan = fresh
+		} else {
+			decision.AffectedTasks = replaced
+			plan = merged
+		}
+	default:
+		plan, err = cc.CreateExecutionPlan(ctx, objectiveID)
+		if err != nil {
+			return nil, err
+		}
 	}
+
+	plan.ContextFingerprint = decision.Fingerprint
+	plan.Replan = decision
+	cc.calibrateEstimate(ctx, plan)
+	return plan, nil
 }
 
 // CreateExecutionPlan generates an execution plan for the given objective.
@@ -228,29 +317,77 @@ func (cc *ContemplativeCursor) CreateExecutionPlan(ctx context.Context, objectiv
 		}
 	}
 
-	// Create the execution plan
-	plan, err := cc.reasoner.DecomposePlan(ctx, objective, selectedMethod)
+	return cc.decomposeWithMethod(ctx, objective, selectedMethod)
+}
+
+// CreateExecutionPlanForMethod creates an execution plan that follows the
+// given method rather than the one the method cache would select, as when
+// comparing candidate methods on the same objective.
+func (cc *ContemplativeCursor) CreateExecutionPlanForMethod(ctx context.Context, objectiveID, methodID string) (*ExecutionPlan, error) {
+	objective, err := cc.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve objective: %w", err)
+	}
+
+	method, err := cc.methodManager.GetMethod(ctx, methodID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve method: %w", err)
+	}
+
+	return cc.decomposeWithMethod(ctx, objective, method)
+}
+
+// decomposeWithMethod breaks the objective into an execution plan following method.
+func (cc *ContemplativeCursor) decomposeWithMethod(ctx context.Context, objective *Objective, method *Method) (*ExecutionPlan, error) {
+	plan, err := cc.reasoner.DecomposePlan(ctx, objective, method)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decompose execution plan: %w", err)
 	}
 
 	// Set plan metadata
 	plan.ID = generatePlanID()
-	plan.ObjectiveID = objectiveID
-	plan.MethodID = selectedMethod.ID
+	plan.ObjectiveID = objective.ID
+	plan.MethodID = method.ID
 	plan.CreatedBy = "contemplative_cursor"
 	plan.CreatedAt = time.Now()
+	plan.ContextFingerprint = cc.replanAdvisor.Fingerprint(ctx, objective)
 
-	// Calculate total estimated tokens
-	totalTokens := 0
-	for _, task := range plan.Tasks {
-		totalTokens += task.EstimatedTokens
-	}
-	plan.TotalEstimatedTokens = totalTokens
+	// Calculate total estimated tokens, corrected by the method's history
+	cc.calibrateEstimate(ctx, plan)
 
 	return plan, nil
 }
 
+// calibrateEstimate sets the plan's total estimate from its tasks, scaled by
+// the calibration of its method. The total is always recomputed from the task
+// estimates, so calibrating a plan twice does not compound the adjustment.
+func (cc *ContemplativeCursor) calibrateEstimate(ctx context.Context, plan *ExecutionPlan) {
+	rawTokens := plan.RawEstimatedTokens()
+	plan.TotalEstimatedTokens = rawTokens
+	plan.Calibration = nil
+	if plan.MethodID == "" || rawTokens == 0 {
+		return
+	}
+
+	calibration, err := cc.methodManager.GetEstimateCalibration(ctx, plan.MethodID, cc.calibration)
+	if err != nil {
+		fmt.Printf("Warning: failed to calibrate estimate: %v\n", err)
+		return
+	}
+	plan.Calibration = &calibration
+	plan.TotalEstimatedTokens = calibration.Adjust(rawTokens)
+}
+
+// RawEstimatedTokens returns the sum of the task estimates, before any
+// calibration.
+func (p *ExecutionPlan) RawEstimatedTokens() int {
+	total := 0
+	for _, task := range p.Tasks {
+		total += task.EstimatedTokens
+	}
+	return total
+}
+
 // findBestMethod queries the method cache for the most suitable method.
 // Returns empty string if no suitable cached method is found.
 func (cc *ContemplativeCursor) findBestMethod(ctx context.Context, analysis *ObjectiveAnalysis) (string, error) {
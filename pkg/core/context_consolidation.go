@@ -0,0 +1,446 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// supersededByEdgeType links a merged context entry to its canonical entry
+const supersededByEdgeType = "superseded_by"
+
+// ContextConsolidationOptions configures ConsolidateContext.
+type ContextConsolidationOptions struct {
+	// DryRun reports the merges without making them
+	DryRun bool
+
+	// Similarity is the word overlap (0-1) at which two entries of a
+	// category are proposed for merging
+	Similarity float64
+
+	// Embed returns a vector embedding of a statement, for matching entries
+	// worded differently (optional: word overlap alone is used without it)
+	Embed func(ctx context.Context, text string) ([]float64, error)
+
+	// EmbeddingSimilarity is the cosine similarity (0-1) of embeddings at
+	// which two entries are proposed for merging
+	EmbeddingSimilarity float64
+}
+
+// DefaultContextConsolidationOptions returns the default consolidation options.
+func DefaultContextConsolidationOptions() ContextConsolidationOptions {
+	return ContextConsolidationOptions{
+		Similarity:          0.5,
+		EmbeddingSimilarity: 0.85,
+	}
+}
+
+// ContextMerge is a set of context entries consolidated into one canonical
+// statement.
+type ContextMerge struct {
+	Category   ContextCategory
+	Statement  string
+	Confidence float64
+
+	// CanonicalID is the entry the merge keeps: an existing entry for exact
+	// duplicates, or the pending proposal for similar ones ("" in a dry run)
+	CanonicalID string
+
+	// MergedFrom are the entries superseded by the merge
+	MergedFrom []string
+
+	// Exact is set for exact duplicates, which merge without confirmation
+	Exact bool
+
+	// Saved is the characters of prompt context the merge removes
+	Saved int
+}
+
+// ContextConsolidationReport summarizes a consolidation pass.
+type ContextConsolidationReport struct {
+	Merged   []ContextMerge // Exact duplicates merged
+	Proposed []ContextMerge // Similar entries waiting for confirmation
+
+	// SavedChars is the prompt context removed by the merges made, and
+	// PendingChars what the proposed merges would remove once confirmed
+	SavedChars   int
+	PendingChars int
+
+	DryRun bool
+
+	// OperationID is the journal entry for the merges made, which undoes
+	// them; empty on a dry run or when nothing was merged
+	OperationID string
+}
+
+// String summarizes the report in one line.
+func (r *ContextConsolidationReport) String() string {
+	verb, proposed := "merged", "proposed"
+	if r.DryRun {
+		verb, proposed = "would merge", "would propose"
+	}
+	return fmt.Sprintf("%s %d exact duplicate sets, %s %d merges; %d characters of prompt context saved, %d more on confirmation",
+		verb, len(r.Merged), proposed, len(r.Proposed), r.SavedChars, r.PendingChars)
+}
+
+// ConsolidateContext merges the duplicate context entries accumulated by
+// learning. Active entries are clustered within each category by similarity.
+// Exact duplicates merge into the best of them straight away; other clusters
+// are proposed as a pending canonical entry, as mined preferences are, and
+// the entries are only superseded once the user confirms it. Superseded
+// entries keep their history and an edge to the canonical entry. The changes
+// are journaled, so the whole pass can be undone.
+func (ucm *UserContextManager) ConsolidateContext(ctx context.Context, userID string, opts ContextConsolidationOptions) (*ContextConsolidationReport, error) {
+	if opts.DryRun {
+		return ucm.consolidateContext(ctx, userID, opts)
+	}
+
+	var report *ContextConsolidationReport
+	operation, err := NewOperationJournal(ucm.store).Record(ctx, OperationContextConsolidation, func(ctx context.Context) (string, error) {
+		var err error
+		if report, err = ucm.consolidateContext(ctx, userID, opts); err != nil {
+			return "", err
+		}
+		return "Consolidate context: " + report.String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if operation != nil {
+		report.OperationID = operation.ID
+	}
+	return report, nil
+}
+
+func (ucm *UserContextManager) consolidateContext(ctx context.Context, userID string, opts ContextConsolidationOptions) (*ContextConsolidationReport, error) {
+	defaults := DefaultContextConsolidationOptions()
+	if opts.Similarity <= 0 {
+		opts.Similarity = defaults.Similarity
+	}
+	if opts.EmbeddingSimilarity <= 0 {
+		opts.EmbeddingSimilarity = defaults.EmbeddingSimilarity
+	}
+
+	all, err := ucm.listContexts(ctx, "", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries awaiting a merge decision are left alone, and a merge the user
+	// dismissed is not proposed again
+	awaiting := make(map[string]bool)
+	dismissed := make(map[string]bool)
+	byCategory := make(map[ContextCategory][]*UserContext)
+	for _, entry := range all {
+		switch {
+		case entry.Status == ContextStatusPending && len(entry.MergedFrom) > 0:
+			for _, id := range entry.MergedFrom {
+				awaiting[id] = true
+			}
+		case entry.Status == ContextStatusDismissed && len(entry.MergedFrom) > 0:
+			dismissed[mergeKey(entry.MergedFrom)] = true
+		}
+	}
+	for _, entry := range all {
+		if entry.Status == ContextStatusActive && !awaiting[entry.ID] {
+			byCategory[entry.Category] = append(byCategory[entry.Category], entry)
+		}
+	}
+
+	categories := make([]string, 0, len(byCategory))
+	for category := range byCategory {
+		categories = append(categories, string(category))
+	}
+	sort.Strings(categories)
+
+	report := &ContextConsolidationReport{DryRun: opts.DryRun}
+	similar := ucm.newContextSimilarity(opts)
+	for _, category := range categories {
+		entries := byCategory[ContextCategory(category)]
+		sortByStrength(entries)
+
+		entries, err = ucm.mergeExactDuplicates(ctx, entries, opts.DryRun, report)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range clusterContexts(ctx, entries, similar) {
+			ids := contextIDs(cluster)
+			if len(cluster) < 2 || dismissed[mergeKey(ids)] {
+				continue
+			}
+			merge, err := ucm.proposeMerge(ctx, cluster, opts.DryRun)
+			if err != nil {
+				return nil, err
+			}
+			report.Proposed = append(report.Proposed, *merge)
+			report.PendingChars += merge.Saved
+		}
+	}
+	return report, nil
+}
+
+// mergeExactDuplicates merges entries with the same statement into the
+// strongest of them, and returns the entries left. Entries are in strength order.
+func (ucm *UserContextManager) mergeExactDuplicates(ctx context.Context, entries []*UserContext, dryRun bool, report *ContextConsolidationReport) ([]*UserContext, error) {
+	groups := make(map[string][]*UserContext)
+	var remaining []*UserContext
+	for _, entry := range entries {
+		key := normalizeStatement(entry.Content)
+		if len(groups[key]) == 0 {
+			remaining = append(remaining, entry)
+		}
+		groups[key] = append(groups[key], entry)
+	}
+
+	for i, keeper := range remaining {
+		group := groups[normalizeStatement(keeper.Content)]
+		if len(group) < 2 {
+			continue
+		}
+
+		merge := ContextMerge{
+			Category:    keeper.Category,
+			Statement:   keeper.Content,
+			Confidence:  combinedConfidence(group),
+			CanonicalID: keeper.ID,
+			MergedFrom:  contextIDs(group[1:]),
+			Exact:       true,
+		}
+		reinforcements := keeper.Reinforcements
+		tags := keeper.RelevanceTags
+		for _, duplicate := range group[1:] {
+			merge.Saved += len(duplicate.Content)
+			reinforcements += duplicate.Reinforcements + 1
+			tags = mergeTags(tags, duplicate.RelevanceTags)
+		}
+
+		merged := *keeper
+		merged.Confidence = merge.Confidence
+		merged.Reinforcements = reinforcements
+		merged.RelevanceTags = tags
+		merged.MergedFrom = append(append([]string(nil), keeper.MergedFrom...), merge.MergedFrom...)
+		if !dryRun {
+			updated, err := ucm.UpdateContext(ctx, keeper.ID, UserContextUpdates{
+				Confidence:     &merged.Confidence,
+				Reinforcements: &merged.Reinforcements,
+				RelevanceTags:  merged.RelevanceTags,
+				MergedFrom:     merged.MergedFrom,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to merge duplicate context: %w", err)
+			}
+			if err := ucm.supersede(ctx, merge.MergedFrom, keeper.ID); err != nil {
+				return nil, err
+			}
+			merged = *updated
+		}
+
+		remaining[i] = &merged
+		report.Merged = append(report.Merged, merge)
+		report.SavedChars += merge.Saved
+	}
+	return remaining, nil
+}
+
+// proposeMerge stores a cluster's canonical statement as a pending entry
+// that supersedes the cluster when confirmed. The statement is that of the
+// strongest entry, the first in the cluster.
+func (ucm *UserContextManager) proposeMerge(ctx context.Context, cluster []*UserContext, dryRun bool) (*ContextMerge, error) {
+	canonical := cluster[0]
+	merge := &ContextMerge{
+		Category:   canonical.Category,
+		Statement:  canonical.Content,
+		Confidence: combinedConfidence(cluster),
+		MergedFrom: contextIDs(cluster),
+		Saved:      -len(canonical.Content),
+	}
+	source := canonical.Source
+	reinforcements := len(cluster) - 1
+	var tags []string
+	for _, entry := range cluster {
+		merge.Saved += len(entry.Content)
+		reinforcements += entry.Reinforcements
+		tags = mergeTags(tags, entry.RelevanceTags)
+		if sourceRank(entry.Source) > sourceRank(source) {
+			source = entry.Source
+		}
+	}
+	if dryRun {
+		return merge, nil
+	}
+
+	proposed, err := ucm.storeContext(ctx, canonical.Category, canonical.Content, source, tags, canonical.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propose context merge: %w", err)
+	}
+	status := ContextStatusPending
+	if _, err := ucm.UpdateContext(ctx, proposed.ID, UserContextUpdates{
+		Status:         &status,
+		Confidence:     &merge.Confidence,
+		Reinforcements: &reinforcements,
+		MergedFrom:     merge.MergedFrom,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to propose context merge: %w", err)
+	}
+	merge.CanonicalID = proposed.ID
+	return merge, nil
+}
+
+// supersede retires merged entries in favour of the canonical entry, with a
+// new version marking each superseded and an edge to the canonical entry.
+// Entries no longer active are left as they are.
+func (ucm *UserContextManager) supersede(ctx context.Context, mergedIDs []string, canonicalID string) error {
+	status := ContextStatusSuperseded
+	for _, id := range mergedIDs {
+		entry, err := ucm.GetContext(ctx, id)
+		if err != nil {
+			return err
+		}
+		if entry.Status != ContextStatusActive {
+			continue
+		}
+		if _, err := ucm.UpdateContext(ctx, id, UserContextUpdates{Status: &status}); err != nil {
+			return fmt.Errorf("failed to supersede context %s: %w", id, err)
+		}
+
+		edge := storage.NewEdge(id, canonicalID, supersededByEdgeType, map[string]interface{}{
+			"created_at": time.Now().Format(time.RFC3339),
+		})
+		if err := ucm.store.AddEdge(ctx, edge); err != nil {
+			return fmt.Errorf("failed to link context %s to %s: %w", id, canonicalID, err)
+		}
+	}
+	return nil
+}
+
+// contextSimilarity reports whether two context entries say the same thing.
+type contextSimilarity func(ctx context.Context, a, b *UserContext) bool
+
+// newContextSimilarity matches entries by word overlap or, when embeddings
+// are available, by the cosine similarity of their embeddings. If embedding
+// fails, word overlap alone is used for the rest of the pass.
+func (ucm *UserContextManager) newContextSimilarity(opts ContextConsolidationOptions) contextSimilarity {
+	embeddings := make(map[string][]float64)
+	embed := opts.Embed
+	embedding := func(ctx context.Context, entry *UserContext) []float64 {
+		if vector, ok := embeddings[entry.ID]; ok || embed == nil {
+			return vector
+		}
+		vector, err := embed(ctx, entry.Content)
+		if err != nil {
+			embed = nil
+			return nil
+		}
+		embeddings[entry.ID] = vector
+		return vector
+	}
+
+	return func(ctx context.Context, a, b *UserContext) bool {
+		if statementSimilarity(a.Content, b.Content) >= opts.Similarity {
+			return true
+		}
+		vectorA, vectorB := embedding(ctx, a), embedding(ctx, b)
+		return len(vectorA) > 0 && len(vectorA) == len(vectorB) &&
+			cosineSimilarity(vectorA, vectorB) >= opts.EmbeddingSimilarity
+	}
+}
+
+// clusterContexts groups entries that match any entry already in the
+// group, so the clusters do not depend on the order entries are compared
+// in. Each cluster starts with its strongest entry.
+func clusterContexts(ctx context.Context, entries []*UserContext, similar contextSimilarity) [][]*UserContext {
+	var clusters [][]*UserContext
+	grouped := make([]bool, len(entries))
+	for i, seed := range entries {
+		if grouped[i] {
+			continue
+		}
+		grouped[i] = true
+		cluster := []*UserContext{seed}
+		for next := 0; next < len(cluster); next++ {
+			for j := i + 1; j < len(entries); j++ {
+				if !grouped[j] && similar(ctx, cluster[next], entries[j]) {
+					cluster = append(cluster, entries[j])
+					grouped[j] = true
+				}
+			}
+		}
+		sortByStrength(cluster)
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// sortByStrength orders entries most confident first, then most reinforced,
+// then oldest, then shortest, so the strongest entry gives a cluster its
+// statement and, among equals, the one taking the least prompt context.
+// Confidence is compared in whole percents, as decay is applied to each
+// entry at a slightly different moment.
+func sortByStrength(entries []*UserContext) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if confidenceA, confidenceB := math.Round(a.Confidence*100), math.Round(b.Confidence*100); confidenceA != confidenceB {
+			return confidenceA > confidenceB
+		}
+		if a.Reinforcements != b.Reinforcements {
+			return a.Reinforcements > b.Reinforcements
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if len(a.Content) != len(b.Content) {
+			return len(a.Content) < len(b.Content)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// combinedConfidence treats each entry as independent evidence for the
+// statement: it is wrong only if every entry is.
+func combinedConfidence(entries []*UserContext) float64 {
+	doubt := 1.0
+	for _, entry := range entries {
+		doubt *= 1 - entry.Confidence
+	}
+	return math.Min(1-doubt, 1.0)
+}
+
+// sourceRank orders sources by reliability, as their initial confidence does.
+func sourceRank(source ContextSource) int {
+	switch source {
+	case ContextSourceExplicit:
+		return 2
+	case ContextSourceFeedback:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// normalizeStatement reduces a statement to its lower-case words, so
+// statements differing only in case, spacing or punctuation are equal.
+func normalizeStatement(statement string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(statement), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}), " ")
+}
+
+// mergeKey identifies a set of merged entries regardless of order.
+func mergeKey(ids []string) string {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+func contextIDs(entries []*UserContext) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
@@ -0,0 +1,283 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// learnContexts stores entries of a category for the test user. Duplicates
+// are stored as they are, as they were before LearnContext reinforced them.
+func learnContexts(t *testing.T, ucm *UserContextManager, category ContextCategory, source ContextSource, statements ...string) []*UserContext {
+	t.Helper()
+	var learned []*UserContext
+	for _, statement := range statements {
+		entry, err := ucm.storeContext(context.Background(), category, statement, source, []string{strings.Fields(statement)[0]}, "user1")
+		if err != nil {
+			t.Fatalf("Failed to learn context: %v", err)
+		}
+		learned = append(learned, entry)
+	}
+	return learned
+}
+
+// assertSuperseded checks that an entry was superseded by canonicalID.
+func assertSuperseded(t *testing.T, ucm *UserContextManager, id, canonicalID string) {
+	t.Helper()
+	entry, err := ucm.GetContext(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get context: %v", err)
+	}
+	if entry.Status != ContextStatusSuperseded {
+		t.Errorf("Expected %s to be superseded, got %s", id, entry.Status)
+	}
+
+	edges, err := ucm.store.Edges().FromNode(id).OfType(supersededByEdgeType).All()
+	if err != nil {
+		t.Fatalf("Failed to query edges: %v", err)
+	}
+	if len(edges) != 1 || edges[0].TargetID != canonicalID {
+		t.Errorf("Expected %s to link to %s, got %v", id, canonicalID, edges)
+	}
+}
+
+func TestConsolidateContextClusters(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	// "responses" and "direct answers" only match through "answers"
+	short := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceInferred,
+		"Prefers short direct answers", "Prefers short responses", "Prefers short answers")
+	concise := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceInferred,
+		"Likes concise responses", "Wants brevity")
+	other := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceExplicit, "Prefers markdown tables")
+	learnContexts(t, ucm, ContextCategoryPatterns, ContextSourceInferred, "Prefers short meetings")
+
+	report, err := ucm.ConsolidateContext(ctx, "user1", ContextConsolidationOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Consolidation failed: %v", err)
+	}
+	if len(report.Merged) != 0 || len(report.Proposed) != 1 {
+		t.Fatalf("Expected one proposed merge, got %+v", report)
+	}
+	merge := report.Proposed[0]
+	if mergeKey(merge.MergedFrom) != mergeKey(contextIDs(short)) {
+		t.Errorf("Expected the short-answer entries to cluster, got %v", merge.MergedFrom)
+	}
+	if merge.Category != ContextCategoryPreferences || merge.Statement != "Prefers short answers" {
+		t.Errorf("Expected the shortest of equally strong statements, got %+v", merge)
+	}
+	if merge.Confidence <= short[0].Confidence || merge.Confidence > 1 {
+		t.Errorf("Expected combined confidence above %.2f, got %.2f", short[0].Confidence, merge.Confidence)
+	}
+	if want := len("Prefers short responses") + len("Prefers short direct answers"); report.PendingChars != want {
+		t.Errorf("Expected %d characters pending, got %d", want, report.PendingChars)
+	}
+
+	// A dry run changes nothing
+	pending, _ := ucm.GetPendingContext(ctx, "user1")
+	active, _ := ucm.GetContextByCategory(ctx, ContextCategoryPreferences, "user1")
+	if len(pending) != 0 || len(active) != 6 {
+		t.Errorf("Expected a dry run to leave 6 active entries, got %d active and %d pending", len(active), len(pending))
+	}
+
+	// Embeddings match entries worded differently
+	vectors := map[string][]float64{
+		"Likes concise responses": {1, 0.1},
+		"Wants brevity":           {0.95, 0.15},
+		"Prefers markdown tables": {0, 1},
+	}
+	report, err = ucm.ConsolidateContext(ctx, "user1", ContextConsolidationOptions{
+		DryRun: true,
+		Embed: func(ctx context.Context, text string) ([]float64, error) {
+			if vector, ok := vectors[text]; ok {
+				return vector, nil
+			}
+			return []float64{0.7, 0.7}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Consolidation failed: %v", err)
+	}
+	clustered := false
+	for _, proposed := range report.Proposed {
+		clustered = clustered || mergeKey(proposed.MergedFrom) == mergeKey(contextIDs(concise))
+		if containsString(proposed.MergedFrom, other[0].ID) {
+			t.Errorf("Expected the unrelated entry to stay apart, got %v", proposed.MergedFrom)
+		}
+	}
+	if len(report.Proposed) != 2 || !clustered {
+		t.Errorf("Expected the concise entries to cluster by embedding, got %+v", report.Proposed)
+	}
+
+	// Without embeddings, word overlap alone is used
+	report, err = ucm.ConsolidateContext(ctx, "user1", ContextConsolidationOptions{
+		DryRun: true,
+		Embed: func(ctx context.Context, text string) ([]float64, error) {
+			return nil, errors.New("no embedding provider")
+		},
+	})
+	if err != nil || len(report.Proposed) != 1 {
+		t.Errorf("Expected word overlap after embedding failed, got %+v, %v", report, err)
+	}
+}
+
+func TestConsolidateContextMergesExactDuplicates(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	explicit := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceExplicit, "Prefers short answers")[0]
+	duplicates := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceInferred, "prefers short answers.", "Prefers  SHORT answers")
+	other := learnContexts(t, ucm, ContextCategoryConstraints, ContextSourceExplicit, "Prefers short answers")[0]
+	before := time.Now()
+
+	report, err := ucm.ConsolidateContext(ctx, "user1", DefaultContextConsolidationOptions())
+	if err != nil {
+		t.Fatalf("Consolidation failed: %v", err)
+	}
+	if len(report.Merged) != 1 || len(report.Proposed) != 0 {
+		t.Fatalf("Expected one automatic merge and no proposals, got %+v", report)
+	}
+	merge := report.Merged[0]
+	if !merge.Exact || merge.CanonicalID != explicit.ID || mergeKey(merge.MergedFrom) != mergeKey(contextIDs(duplicates)) {
+		t.Errorf("Expected the duplicates to merge into the explicit entry, got %+v", merge)
+	}
+	if report.SavedChars != len(duplicates[0].Content)+len(duplicates[1].Content) {
+		t.Errorf("Expected the duplicates' characters to be saved, got %d", report.SavedChars)
+	}
+
+	canonical, err := ucm.GetContext(ctx, explicit.ID)
+	if err != nil {
+		t.Fatalf("Failed to get canonical context: %v", err)
+	}
+	if canonical.Status != ContextStatusActive || canonical.Reinforcements != 2 || canonical.Confidence <= explicit.Confidence {
+		t.Errorf("Expected a reinforced, more confident canonical entry, got %+v", canonical)
+	}
+	if mergeKey(canonical.MergedFrom) != mergeKey(contextIDs(duplicates)) {
+		t.Errorf("Expected the canonical entry to record its provenance, got %v", canonical.MergedFrom)
+	}
+	for _, duplicate := range duplicates {
+		assertSuperseded(t, ucm, duplicate.ID, explicit.ID)
+	}
+
+	// History is kept: the superseded entry's earlier version was active
+	earlier, err := ucm.store.GetNodeAtTime(ctx, duplicates[0].ID, before)
+	if err != nil || earlier.Data["status"] != string(ContextStatusActive) {
+		t.Errorf("Expected the superseded entry's history to be kept, got %v, %v", earlier, err)
+	}
+
+	// Other categories are consolidated separately
+	if entry, _ := ucm.GetContext(ctx, other.ID); entry.Status != ContextStatusActive {
+		t.Errorf("Expected the constraint to stay active, got %s", entry.Status)
+	}
+
+	// A second pass finds nothing left to merge
+	report, err = ucm.ConsolidateContext(ctx, "user1", DefaultContextConsolidationOptions())
+	if err != nil || len(report.Merged)+len(report.Proposed) != 0 {
+		t.Errorf("Expected nothing left to consolidate, got %+v, %v", report, err)
+	}
+}
+
+func TestConsolidateContextConfirmation(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	short := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceInferred, "Prefers short answers", "Prefers short responses")
+	tables := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceInferred, "Likes markdown tables", "Likes tables in markdown form")
+
+	report, err := ucm.ConsolidateContext(ctx, "user1", DefaultContextConsolidationOptions())
+	if err != nil {
+		t.Fatalf("Consolidation failed: %v", err)
+	}
+	if len(report.Proposed) != 2 || report.SavedChars != 0 {
+		t.Fatalf("Expected two proposals and nothing merged yet, got %+v", report)
+	}
+
+	// Proposals wait in the pending list; nothing changes until then
+	pending, err := ucm.GetPendingContext(ctx, "user1")
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("Expected two pending merges, got %d (%v)", len(pending), err)
+	}
+	for _, entry := range append(append([]*UserContext{}, short...), tables...) {
+		if current, _ := ucm.GetContext(ctx, entry.ID); current.Status != ContextStatusActive {
+			t.Errorf("Expected %s to stay active until confirmed, got %s", entry.ID, current.Status)
+		}
+	}
+
+	// Proposals are not made twice
+	report, err = ucm.ConsolidateContext(ctx, "user1", DefaultContextConsolidationOptions())
+	if err != nil || len(report.Proposed) != 0 {
+		t.Errorf("Expected pending merges not to be proposed again, got %+v, %v", report, err)
+	}
+
+	byMembers := make(map[string]*UserContext)
+	for _, proposal := range pending {
+		byMembers[mergeKey(proposal.MergedFrom)] = proposal
+	}
+	confirmed, err := ucm.ConfirmContext(ctx, byMembers[mergeKey(contextIDs(short))].ID)
+	if err != nil {
+		t.Fatalf("Failed to confirm merge: %v", err)
+	}
+	if confirmed.Status != ContextStatusActive || confirmed.Content != "Prefers short answers" {
+		t.Errorf("Expected the canonical entry to be active, got %+v", confirmed)
+	}
+	for _, entry := range short {
+		assertSuperseded(t, ucm, entry.ID, confirmed.ID)
+	}
+
+	// A dismissed merge leaves its entries active and is not proposed again
+	if _, err := ucm.DismissContext(ctx, byMembers[mergeKey(contextIDs(tables))].ID); err != nil {
+		t.Fatalf("Failed to dismiss merge: %v", err)
+	}
+	for _, entry := range tables {
+		if current, _ := ucm.GetContext(ctx, entry.ID); current.Status != ContextStatusActive {
+			t.Errorf("Expected %s to stay active after dismissal, got %s", entry.ID, current.Status)
+		}
+	}
+	report, err = ucm.ConsolidateContext(ctx, "user1", DefaultContextConsolidationOptions())
+	if err != nil || len(report.Proposed) != 0 {
+		t.Errorf("Expected a dismissed merge not to be proposed again, got %+v, %v", report, err)
+	}
+}
+
+func TestRetrievalSkipsSupersededContext(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	entries := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceExplicit,
+		"Prefers short answers", "Prefers short answers!", "Prefers short responses")
+	if _, err := ucm.ConsolidateContext(ctx, "user1", DefaultContextConsolidationOptions()); err != nil {
+		t.Fatalf("Consolidation failed: %v", err)
+	}
+	pending, _ := ucm.GetPendingContext(ctx, "user1")
+	if len(pending) != 1 {
+		t.Fatalf("Expected one pending merge, got %d", len(pending))
+	}
+	canonical, err := ucm.ConfirmContext(ctx, pending[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to confirm merge: %v", err)
+	}
+
+	relevant, err := ucm.GetRelevantContext(ctx, "write short answers to the support questions", "user1", 10)
+	if err != nil {
+		t.Fatalf("Failed to retrieve context: %v", err)
+	}
+	byCategory, err := ucm.GetContextByCategory(ctx, ContextCategoryPreferences, "user1")
+	if err != nil {
+		t.Fatalf("Failed to retrieve context: %v", err)
+	}
+	for name, retrieved := range map[string][]*UserContext{"relevant": relevant, "by category": byCategory} {
+		if len(retrieved) != 1 || retrieved[0].ID != canonical.ID {
+			t.Errorf("Expected only the canonical entry in %s context, got %d entries", name, len(retrieved))
+		}
+		for _, entry := range retrieved {
+			for _, original := range entries {
+				if entry.ID == original.ID {
+					t.Errorf("Expected superseded entry %s not to be retrieved", original.ID)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// maxReferenceBytes bounds how much of a referenced file is loaded into a
+// task's context.
+const maxReferenceBytes = 64 * 1024
+
+// DefaultMaxContextBytes bounds how much loaded content a task's context
+// holds, unless set otherwise with SetMaxContextBytes.
+const DefaultMaxContextBytes = 256 * 1024
+
+// StoreContextLoader loads task and objective context from the store. It
+// resolves references of the forms:
+//
+//	node://<id>              the data of the node
+//	node://<id>#field.path   one field of the node's data; path segments key
+//	                         into maps and, as numbers, index into lists
+//	blob://<hash>            the text of the blob, such as a task output
+//	                         stored by the RTC
+//	objective://<id>/result  the result of a finished objective
+//	file://<path>            the file's text, up to 64 KiB
+//
+// What the store references load is cached for the plan being executed, as
+// told by the plan ID in the context, so references repeated across a plan's
+// tasks are read once. Only one plan is cached at a time. Files are read
+// every time, as a plan's tasks may write them.
+type StoreContextLoader struct {
+	store            *storage.Store
+	goalManager      *GoalManager
+	objectiveManager *ObjectiveManager
+	maxContextBytes  int
+
+	mu    sync.Mutex
+	cache referenceCache
+}
+
+// referenceCache holds what the store references of one plan loaded.
+type referenceCache struct {
+	planID string
+	loaded map[string]interface{}
+}
+
+// NewStoreContextLoader creates a context loader reading from store.
+func NewStoreContextLoader(store *storage.Store) *StoreContextLoader {
+	return &StoreContextLoader{
+		store:            store,
+		goalManager:      NewGoalManager(store),
+		objectiveManager: NewObjectiveManager(store),
+		maxContextBytes:  DefaultMaxContextBytes,
+	}
+}
+
+// SetMaxContextBytes bounds how much loaded content a task's context holds;
+// zero or less restores DefaultMaxContextBytes.
+func (l *StoreContextLoader) SetMaxContextBytes(maxBytes int) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxContextBytes
+	}
+	l.maxContextBytes = maxBytes
+}
+
+// LoadTaskContext implements ContextLoader. The objective is the one the
+// executing plan serves.
+//
+// The task's parameters and inputs take precedence over the objective's
+// context, which takes precedence over its goal's: a context key the task
+// has a parameter for is left out, and tasks that declare what they consume
+// only see those context keys. Loaded content is capped at the loader's
+// byte budget, with the inputs given room first, in the order the task
+// lists them, and then the context in key order. The value that crosses the
+// budget is shortened, and those after it reduced, to a marker of how much
+// was omitted.
+func (l *StoreContextLoader) LoadTaskContext(ctx context.Context, task *ExecutionTask) (map[string]interface{}, error) {
+	fullContext := map[string]interface{}{
+		"parameters": task.Context.Parameters,
+	}
+	remaining := l.maxContextBytes
+
+	if len(task.Context.InputRefs) > 0 {
+		inputs := make(map[string]interface{}, len(task.Context.InputRefs))
+		for _, ref := range task.Context.InputRefs {
+			content, err := l.ResolveReference(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			inputs[ref], remaining = capContextValue(content, remaining)
+		}
+		fullContext["inputs"] = inputs
+	}
+
+	if objectiveID := utils.LogContextFrom(ctx).ObjectiveID; objectiveID != "" {
+		objectiveContext, err := l.LoadObjectiveContext(ctx, objectiveID)
+		if err != nil {
+			return nil, err
+		}
+		context, _ := objectiveContext["objective_context"].(map[string]interface{})
+		if len(task.Context.Consumes) > 0 {
+			consumed := make(map[string]interface{}, len(task.Context.Consumes))
+			for _, key := range task.Context.Consumes {
+				if value, ok := context[key]; ok {
+					consumed[key] = value
+				}
+			}
+			context = consumed
+		}
+		for key := range task.Context.Parameters {
+			delete(context, key)
+		}
+		for _, key := range sortedKeys(context) {
+			context[key], remaining = capContextValue(context[key], remaining)
+		}
+		objectiveContext["objective_context"] = context
+
+		for key, value := range objectiveContext {
+			fullContext[key] = value
+		}
+	}
+	return fullContext, nil
+}
+
+// LoadObjectiveContext implements ContextLoader. It returns the objective's
+// title and description, its goal's ID, title, description and priority, and
+// its context with references resolved where they can be. The goal's user
+// context fills in keys the objective's context lacks. Bookkeeping kept in
+// the context is left out.
+func (l *StoreContextLoader) LoadObjectiveContext(ctx context.Context, objectiveID string) (map[string]interface{}, error) {
+	objective, err := l.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load objective context: %w", err)
+	}
+
+	context := copyObjectiveContext(objective.Context)
+	loaded := map[string]interface{}{
+		"objective_id":          objective.ID,
+		"objective_title":       objective.Title,
+		"objective_description": objective.Description,
+	}
+	if goal, err := l.goalManager.GetGoal(ctx, objective.GoalID); err == nil {
+		loaded["goal_id"] = goal.ID
+		loaded["goal_title"] = goal.Title
+		loaded["goal_description"] = goal.Description
+		loaded["goal_priority"] = goal.Priority
+		for key, value := range goal.UserContext {
+			if _, exists := context[key]; !exists {
+				context[key] = value
+			}
+		}
+	}
+
+	for _, key := range []string{delegationContextKey, attachmentsContextKey, "wip_override", timeBoxContextKey} {
+		delete(context, key)
+	}
+	for key, value := range context {
+		if ref, ok := value.(string); ok && isContextReference(ref) {
+			if content, err := l.ResolveReference(ctx, ref); err == nil {
+				context[key] = content
+			}
+		}
+	}
+	loaded["objective_context"] = context
+	return loaded, nil
+}
+
+// ResolveReference implements ContextLoader.
+func (l *StoreContextLoader) ResolveReference(ctx context.Context, ref string) (interface{}, error) {
+	scheme, target, _ := strings.Cut(ref, "://")
+	var content interface{}
+	var err error
+	switch scheme {
+	case "node":
+		id, path, _ := strings.Cut(target, "#")
+		content, err = l.cached(ctx, "node://"+id, func() (interface{}, error) {
+			node, err := l.store.GetNode(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return node.Data, nil
+		})
+		if err == nil && path != "" {
+			content, err = fieldAt(content, path)
+		}
+	case "blob":
+		content, err = l.cached(ctx, ref, func() (interface{}, error) {
+			data, err := l.store.GetBlob(ctx, target)
+			if err != nil {
+				return nil, err
+			}
+			return string(data), nil
+		})
+	case "objective":
+		id, part, _ := strings.Cut(target, "/")
+		if part != "result" {
+			return nil, errs.Newf(errs.Validation, "reference %s should name an objective's result, as objective://<id>/result", ref).
+				With("reference", ref)
+		}
+		content, err = l.cached(ctx, ref, func() (interface{}, error) {
+			objective, err := l.objectiveManager.GetObjective(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if objective.Result == nil {
+				return nil, errs.Newf(errs.NotFound, "objective %s has no result yet", id).With("objective_id", id)
+			}
+			return objective.Result, nil
+		})
+	case "file":
+		content, err = readReferencedFile(target)
+	default:
+		return nil, errs.Newf(errs.Validation, "reference %s has an unsupported scheme", ref).With("reference", ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	return content, nil
+}
+
+// cached returns what key loaded earlier in the plan ctx executes, or loads
+// and remembers it. Nothing is cached outside a plan.
+func (l *StoreContextLoader) cached(ctx context.Context, key string, load func() (interface{}, error)) (interface{}, error) {
+	planID := utils.LogContextFrom(ctx).PlanID
+	if planID == "" {
+		return load()
+	}
+
+	l.mu.Lock()
+	if l.cache.planID != planID {
+		l.cache = referenceCache{planID: planID, loaded: make(map[string]interface{})}
+	}
+	content, exists := l.cache.loaded[key]
+	l.mu.Unlock()
+	if exists {
+		return content, nil
+	}
+
+	content, err := load()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	if l.cache.planID == planID {
+		l.cache.loaded[key] = content
+	}
+	l.mu.Unlock()
+	return content, nil
+}
+
+// readReferencedFile returns the text of a referenced file, up to
+// maxReferenceBytes.
+func readReferencedFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxReferenceBytes))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// fieldAt returns the value at a dot-separated path into data.
+func fieldAt(data interface{}, path string) (interface{}, error) {
+	value := data
+	for _, segment := range strings.Split(path, ".") {
+		found := false
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			value, found = typed[segment]
+		case []interface{}:
+			if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(typed) {
+				value, found = typed[index], true
+			}
+		}
+		if !found {
+			return nil, errs.Newf(errs.NotFound, "field %s not found", path).With("field", path)
+		}
+	}
+	return value, nil
+}
+
+// capContextValue returns value and the bytes of budget remaining after it,
+// or, when its text does not fit in remaining, the text shortened to fit and
+// marked with how much was omitted. Values other than text count as JSON.
+func capContextValue(value interface{}, remaining int) (interface{}, int) {
+	text := promptValue(value)
+	if len(text) <= remaining {
+		return value, remaining - len(text)
+	}
+	cut := remaining
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s…[%d bytes omitted]", text[:cut], len(text)-cut), 0
+}
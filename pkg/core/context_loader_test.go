@@ -0,0 +1,212 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// loaderObjective creates an objective under a goal with the given user
+// contexts, as the plans the loader serves have.
+func loaderObjective(t *testing.T, store *storage.Store, goalContext, objectiveContext map[string]interface{}) *Objective {
+	t.Helper()
+	ctx := context.Background()
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Quarterly report", "Report to the board each quarter", 7, goalContext)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Report writing", "Gather, draft, review", []ApproachStep{}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	objective, err := NewObjectiveManager(store).CreateObjective(ctx, goal.ID, method.ID, "Q3 report", "Write the Q3 report", objectiveContext, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	return objective
+}
+
+func TestStoreContextLoader_ResolvesReferences(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	loader := NewStoreContextLoader(store)
+
+	node := storage.NewNode("dataset", map[string]interface{}{
+		"name":    "sales",
+		"summary": map[string]interface{}{"total": 1200.0, "regions": []interface{}{"north", "south"}},
+	})
+	if err := store.AddNode(ctx, node); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	hash, err := store.PutBlob(ctx, []byte("draft text"))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("meeting notes"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	objectives := NewObjectiveManager(store)
+	finished := loaderObjective(t, store, nil, nil)
+	if _, err := objectives.StartObjective(ctx, finished.ID); err != nil {
+		t.Fatalf("Failed to start objective: %v", err)
+	}
+	if _, err := objectives.CompleteObjective(ctx, finished.ID, ObjectiveResult{Success: true, Message: "Report sent"}); err != nil {
+		t.Fatalf("Failed to complete objective: %v", err)
+	}
+	pending := loaderObjective(t, store, nil, nil)
+
+	resolved := map[string]interface{}{
+		"node://" + node.ID:                        node.Data,
+		"node://" + node.ID + "#name":              "sales",
+		"node://" + node.ID + "#summary.total":     1200.0,
+		"node://" + node.ID + "#summary.regions.1": "south",
+		storage.BlobScheme + hash:                  "draft text",
+		"file://" + path:                           "meeting notes",
+	}
+	for ref, want := range resolved {
+		got, err := loader.ResolveReference(ctx, ref)
+		if err != nil {
+			t.Errorf("Failed to resolve %s: %v", ref, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %s to resolve to %v, got %v", ref, want, got)
+		}
+	}
+
+	result, err := loader.ResolveReference(ctx, "objective://"+finished.ID+"/result")
+	if err != nil {
+		t.Fatalf("Failed to resolve the objective's result: %v", err)
+	}
+	if objectiveResult, ok := result.(*ObjectiveResult); !ok || objectiveResult.Message != "Report sent" {
+		t.Errorf("Expected the objective's result, got %v", result)
+	}
+
+	failures := map[string]errs.Code{
+		"node://" + node.ID + "#summary.missing":   errs.NotFound,
+		"node://" + node.ID + "#summary.regions.2": errs.NotFound,
+		"node://" + node.ID + "#name.first":        errs.NotFound,
+		"objective://" + pending.ID + "/result":    errs.NotFound,
+		"objective://" + finished.ID + "/context":  errs.Validation,
+		"ftp://example.com/report":                 errs.Validation,
+	}
+	for ref, code := range failures {
+		if _, err := loader.ResolveReference(ctx, ref); errs.CodeOf(err) != code {
+			t.Errorf("Expected %s to fail with %s, got %v", ref, code, err)
+		}
+	}
+}
+
+func TestStoreContextLoader_CachesPerPlan(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	loader := NewStoreContextLoader(store)
+
+	node := storage.NewNode("dataset", map[string]interface{}{"version": "first"})
+	if err := store.AddNode(ctx, node); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	ref := "node://" + node.ID + "#version"
+	planCtx := utils.WithLogContext(ctx, utils.LogContext{PlanID: "plan_1"})
+	if got, _ := loader.ResolveReference(planCtx, ref); got != "first" {
+		t.Fatalf("Expected the first version, got %v", got)
+	}
+
+	if err := store.AddNode(ctx, storage.NewNodeWithID(node.ID, "dataset", map[string]interface{}{"version": "second"})); err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	// The plan reads the node once; outside it, and in the next plan, it is read again
+	if got, _ := loader.ResolveReference(planCtx, "node://"+node.ID); !reflect.DeepEqual(got, map[string]interface{}{"version": "first"}) {
+		t.Errorf("Expected the plan's cached node, got %v", got)
+	}
+	if got, _ := loader.ResolveReference(ctx, ref); got != "second" {
+		t.Errorf("Expected the node read again outside a plan, got %v", got)
+	}
+	nextCtx := utils.WithLogContext(ctx, utils.LogContext{PlanID: "plan_2"})
+	if got, _ := loader.ResolveReference(nextCtx, ref); got != "second" {
+		t.Errorf("Expected the node read again for the next plan, got %v", got)
+	}
+}
+
+func TestStoreContextLoader_TaskContextPrecedence(t *testing.T) {
+	store := setupTestStore(t)
+	loader := NewStoreContextLoader(store)
+
+	objective := loaderObjective(t, store,
+		map[string]interface{}{"tone": "formal", "audience": "board"},
+		map[string]interface{}{"tone": "plain", "length": "two pages", "quarter": "Q3"})
+	ctx := utils.WithLogContext(context.Background(), utils.LogContext{ObjectiveID: objective.ID, PlanID: "plan_1"})
+
+	task := &ExecutionTask{ID: "draft", Type: "generate", Context: TaskContext{
+		Parameters: map[string]interface{}{"length": "one page"},
+	}}
+	fullContext, err := loader.LoadTaskContext(ctx, task)
+	if err != nil {
+		t.Fatalf("LoadTaskContext failed: %v", err)
+	}
+
+	// The objective's tone wins over the goal's, and the task's length over both
+	want := map[string]interface{}{"tone": "plain", "audience": "board", "quarter": "Q3"}
+	if got := fullContext["objective_context"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected context %v, got %v", want, got)
+	}
+	if fullContext["goal_id"] != objective.GoalID || fullContext["goal_title"] != "Quarterly report" ||
+		fullContext["goal_description"] != "Report to the board each quarter" || fullContext["goal_priority"] != 7 {
+		t.Errorf("Expected the goal's metadata, got %v", fullContext)
+	}
+
+	// A task declaring what it consumes sees only that
+	task.Context.Consumes = []string{"audience", "length"}
+	fullContext, err = loader.LoadTaskContext(ctx, task)
+	if err != nil {
+		t.Fatalf("LoadTaskContext failed: %v", err)
+	}
+	if got := fullContext["objective_context"]; !reflect.DeepEqual(got, map[string]interface{}{"audience": "board"}) {
+		t.Errorf("Expected only the consumed context, got %v", got)
+	}
+}
+
+func TestStoreContextLoader_TruncatesToBudget(t *testing.T) {
+	store := setupTestStore(t)
+	loader := NewStoreContextLoader(store)
+	loader.SetMaxContextBytes(100)
+
+	objective := loaderObjective(t, store, nil, map[string]interface{}{"background": strings.Repeat("b", 50)})
+	ctx := utils.WithLogContext(context.Background(), utils.LogContext{ObjectiveID: objective.ID})
+
+	var refs []string
+	for _, text := range []string{strings.Repeat("a", 60), strings.Repeat("é", 40)} {
+		hash, err := store.PutBlob(ctx, []byte(text))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		refs = append(refs, storage.BlobScheme+hash)
+	}
+
+	fullContext, err := loader.LoadTaskContext(ctx, &ExecutionTask{ID: "draft", Context: TaskContext{InputRefs: refs}})
+	if err != nil {
+		t.Fatalf("Expected the context shortened rather than refused, got %v", err)
+	}
+
+	// The first input fits, the second is cut on a character boundary, and
+	// the context has no room left
+	inputs := fullContext["inputs"].(map[string]interface{})
+	if inputs[refs[0]] != strings.Repeat("a", 60) {
+		t.Errorf("Expected the first input whole, got %v", inputs[refs[0]])
+	}
+	if want := strings.Repeat("é", 20) + "…[40 bytes omitted]"; inputs[refs[1]] != want {
+		t.Errorf("Expected %q, got %q", want, inputs[refs[1]])
+	}
+	background := fullContext["objective_context"].(map[string]interface{})["background"]
+	if background != "…[50 bytes omitted]" {
+		t.Errorf("Expected the context reduced to a marker, got %q", background)
+	}
+}
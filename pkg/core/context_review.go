@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// ContextFilter selects the entries ListContexts returns. Empty fields
+// match every entry.
+type ContextFilter struct {
+	Category ContextCategory
+	Source   ContextSource
+	Status   ContextStatus
+
+	// Tag matches entries with this relevance tag, ignoring case
+	Tag string
+
+	// IncludeRevoked lists forgotten entries too; without it they are only
+	// listed when Status asks for them
+	IncludeRevoked bool
+}
+
+// matches reports whether an entry passes the filter.
+func (f ContextFilter) matches(entry *UserContext) bool {
+	if f.Category != "" && entry.Category != f.Category {
+		return false
+	}
+	if f.Source != "" && entry.Source != f.Source {
+		return false
+	}
+	if f.Status != "" && entry.Status != f.Status {
+		return false
+	}
+	if entry.Status == ContextStatusRevoked && f.Status != ContextStatusRevoked && !f.IncludeRevoked {
+		return false
+	}
+	if f.Tag != "" {
+		for _, tag := range entry.RelevanceTags {
+			if strings.EqualFold(tag, f.Tag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ListContexts returns what the system has learned about the user, in any
+// status the filter allows, ordered by category and then most confident
+// first. An empty userID lists every user's entries.
+func (ucm *UserContextManager) ListContexts(ctx context.Context, userID string, filter ContextFilter) ([]*UserContext, error) {
+	if filter.Category != "" && !isValidCategory(filter.Category) {
+		return nil, errs.Newf(errs.Validation, "invalid context category: %s", filter.Category)
+	}
+	if filter.Source != "" && !isValidSource(filter.Source) {
+		return nil, errs.Newf(errs.Validation, "invalid context source: %s", filter.Source)
+	}
+
+	all, err := ucm.listContexts(ctx, filter.Category, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var contexts []*UserContext
+	for _, entry := range all {
+		if filter.matches(entry) {
+			contexts = append(contexts, entry)
+		}
+	}
+	sort.Slice(contexts, func(i, j int) bool {
+		if contexts[i].Category != contexts[j].Category {
+			return contexts[i].Category < contexts[j].Category
+		}
+		return contexts[i].Confidence > contexts[j].Confidence
+	})
+	return contexts, nil
+}
+
+// ContextExport is a readable record of what the system has learned about
+// a user.
+type ContextExport struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	UserID     string            `json:"user_id,omitempty"`
+	Contexts   []ExportedContext `json:"contexts"`
+}
+
+// ExportedContext is one learned entry in a ContextExport.
+type ExportedContext struct {
+	ID             string     `json:"id"`
+	Category       string     `json:"category"`
+	Statement      string     `json:"statement"`
+	Source         string     `json:"source"`
+	Status         string     `json:"status"`
+	Confidence     float64    `json:"confidence"`
+	Tags           []string   `json:"tags,omitempty"`
+	LearnedAt      time.Time  `json:"learned_at"`
+	LastValidated  time.Time  `json:"last_validated"`
+	Reinforcements int        `json:"reinforcements,omitempty"`
+	MergedFrom     []string   `json:"merged_from,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason   string     `json:"revoke_reason,omitempty"`
+}
+
+// ExportContexts returns the entries ListContexts would list as indented
+// JSON, for the user to read or keep.
+func (ucm *UserContextManager) ExportContexts(ctx context.Context, userID string, filter ContextFilter) ([]byte, error) {
+	contexts, err := ucm.ListContexts(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	export := ContextExport{
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		UserID:     userID,
+		Contexts:   make([]ExportedContext, 0, len(contexts)),
+	}
+	for _, entry := range contexts {
+		exported := ExportedContext{
+			ID:             entry.ID,
+			Category:       string(entry.Category),
+			Statement:      entry.Content,
+			Source:         string(entry.Source),
+			Status:         string(entry.Status),
+			Confidence:     entry.Confidence,
+			Tags:           entry.RelevanceTags,
+			LearnedAt:      entry.CreatedAt,
+			LastValidated:  entry.LastValidated,
+			Reinforcements: entry.Reinforcements,
+			MergedFrom:     entry.MergedFrom,
+			RevokeReason:   entry.RevokeReason,
+		}
+		if !entry.RevokedAt.IsZero() {
+			revokedAt := entry.RevokedAt
+			exported.RevokedAt = &revokedAt
+		}
+		export.Contexts = append(export.Contexts, exported)
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode context export: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ForgetContext revokes an entry the system learned wrongly, with a new
+// version marking it revoked. It is never retrieved for planning or ethical
+// reasoning, reinforced or proposed again, while its earlier versions stay
+// in the store's history. Decisions already reasoned with it keep their
+// reasoning as it was stored.
+func (ucm *UserContextManager) ForgetContext(ctx context.Context, contextID, reason string) (*UserContext, error) {
+	status := ContextStatusRevoked
+	reason = strings.TrimSpace(reason)
+	forgotten, err := ucm.UpdateContext(ctx, contextID, UserContextUpdates{Status: &status, RevokeReason: &reason})
+	if err != nil {
+		return nil, fmt.Errorf("failed to forget context: %w", err)
+	}
+	return forgotten, nil
+}
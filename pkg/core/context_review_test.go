@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+func TestListContextsFilters(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceExplicit, "Prefers short answers")
+	inferred := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceInferred, "Likes bullet summaries")[0]
+	constraint := learnContexts(t, ucm, ContextCategoryConstraints, ContextSourceExplicit, "Never email clients on weekends")[0]
+	learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceExplicit, "Prefers tea")
+	if _, err := ucm.ForgetContext(ctx, inferred.ID, ""); err != nil {
+		t.Fatalf("ForgetContext failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter ContextFilter
+		want   int
+	}{
+		{"everything in use", ContextFilter{}, 3},
+		{"including forgotten", ContextFilter{IncludeRevoked: true}, 4},
+		{"category", ContextFilter{Category: ContextCategoryConstraints}, 1},
+		{"source", ContextFilter{Source: ContextSourceInferred, IncludeRevoked: true}, 1},
+		{"tag", ContextFilter{Tag: "NEVER"}, 1},
+		{"forgotten only", ContextFilter{Status: ContextStatusRevoked}, 1},
+	}
+	for _, tt := range tests {
+		contexts, err := ucm.ListContexts(ctx, "user1", tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ListContexts failed: %v", tt.name, err)
+		}
+		if len(contexts) != tt.want {
+			t.Errorf("%s: expected %d entries, got %d", tt.name, tt.want, len(contexts))
+		}
+	}
+
+	contexts, _ := ucm.ListContexts(ctx, "user1", ContextFilter{})
+	if contexts[0].ID != constraint.ID || contexts[1].Category != ContextCategoryPreferences {
+		t.Errorf("Expected entries ordered by category, got %v", contextIDs(contexts))
+	}
+
+	if _, err := ucm.ListContexts(ctx, "user1", ContextFilter{Category: "moods"}); errs.CodeOf(err) != errs.Validation {
+		t.Errorf("Expected an unknown category to be rejected, got %v", err)
+	}
+}
+
+func TestExportContexts(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	kept := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceExplicit, "Prefers short answers")[0]
+	forgotten := learnContexts(t, ucm, ContextCategoryValues, ContextSourceInferred, "Values speed over accuracy")[0]
+	if _, err := ucm.ForgetContext(ctx, forgotten.ID, "  Not true  "); err != nil {
+		t.Fatalf("ForgetContext failed: %v", err)
+	}
+
+	data, err := ucm.ExportContexts(ctx, "user1", ContextFilter{IncludeRevoked: true})
+	if err != nil {
+		t.Fatalf("ExportContexts failed: %v", err)
+	}
+	var export ContextExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatalf("Expected the export to be JSON: %v\n%s", err, data)
+	}
+	if export.UserID != "user1" || len(export.Contexts) != 2 {
+		t.Fatalf("Expected both entries exported for user1, got %+v", export)
+	}
+
+	for _, entry := range export.Contexts {
+		switch entry.ID {
+		case kept.ID:
+			if entry.Statement != kept.Content || entry.Status != string(ContextStatusActive) || entry.RevokedAt != nil {
+				t.Errorf("Unexpected exported entry: %+v", entry)
+			}
+		case forgotten.ID:
+			if entry.Status != string(ContextStatusRevoked) || entry.RevokedAt == nil || entry.RevokeReason != "Not true" {
+				t.Errorf("Expected the forgotten entry exported with its reason, got %+v", entry)
+			}
+		default:
+			t.Errorf("Unexpected entry %s exported", entry.ID)
+		}
+	}
+}
+
+func TestForgetContext(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	ucm := NewUserContextManager(store)
+	ef := NewEthicalFramework(store, nil, ucm)
+
+	entry := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceInferred, "Prefers deleting old drafts")[0]
+
+	// A decision waiting for approval was reasoned with the entry
+	reasoning := "The user prefers deleting old drafts"
+	pending := &EthicalDecision{
+		ObjectiveID:     "obj-1",
+		DecisionContext: "Clean up the drafts folder",
+		ProposedAction:  "delete old drafts",
+		Impact:          EthicalImpact{FreedomImpact: 0.5, WellBeingImpact: 0.5, SustainabilityImpact: 0.5, ConfidenceScore: 0.6, Reasoning: reasoning},
+		ApprovalStatus:  DecisionApprovalPending,
+		Outcome:         DecisionOutcomeUnknown,
+		CreatedAt:       time.Now(),
+		UserID:          "user1",
+	}
+	if err := ef.storeDecision(ctx, pending); err != nil {
+		t.Fatalf("Failed to store decision: %v", err)
+	}
+	before := time.Now()
+
+	forgotten, err := ucm.ForgetContext(ctx, entry.ID, "I never said that")
+	if err != nil {
+		t.Fatalf("ForgetContext failed: %v", err)
+	}
+	if forgotten.Status != ContextStatusRevoked || forgotten.RevokedAt.IsZero() || forgotten.RevokeReason != "I never said that" {
+		t.Errorf("Expected a revoked entry with its reason, got %+v", forgotten)
+	}
+
+	// It is no longer retrieved for planning or decisions
+	relevant, err := ucm.GetRelevantContext(ctx, "clean up old drafts", "user1", 10)
+	if err != nil {
+		t.Fatalf("GetRelevantContext failed: %v", err)
+	}
+	byCategory, _ := ucm.GetContextByCategory(ctx, ContextCategoryPreferences, "user1")
+	if len(relevant) != 0 || len(byCategory) != 0 {
+		t.Errorf("Expected a forgotten entry not to be retrieved, got %d relevant and %d by category", len(relevant), len(byCategory))
+	}
+
+	// Learning it again does not bring it back
+	relearned, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "Prefers deleting old drafts", ContextSourceInferred, nil, "user1")
+	if err != nil {
+		t.Fatalf("LearnContext failed: %v", err)
+	}
+	if relearned.ID == entry.ID {
+		t.Error("Expected a forgotten entry not to be reinforced")
+	}
+
+	// Its history is kept for audit, and it cannot be changed again
+	earlier, err := store.GetNodeAtTime(ctx, entry.ID, before)
+	if err != nil || earlier.Data["status"] != string(ContextStatusActive) {
+		t.Errorf("Expected the forgotten entry's history to be kept, got %v, %v", earlier, err)
+	}
+	if _, err := ucm.ForgetContext(ctx, entry.ID, "again"); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected forgetting twice to conflict, got %v", err)
+	}
+
+	// The pending decision keeps the reasoning it was made with
+	stored, err := ef.GetDecision(ctx, pending.ID)
+	if err != nil {
+		t.Fatalf("GetDecision failed: %v", err)
+	}
+	if stored.Impact.Reasoning != reasoning || stored.ApprovalStatus != DecisionApprovalPending {
+		t.Errorf("Expected the pending decision unchanged, got %q (%s)", stored.Impact.Reasoning, stored.ApprovalStatus)
+	}
+}
@@ -0,0 +1,736 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+const (
+	// auditExportNodeType records where an audit report was written
+	auditExportNodeType = "decision_audit_export"
+
+	// auditTimeLayout is the time format used throughout the audit
+	auditTimeLayout = "2006-01-02 15:04"
+
+	// auditDateLayout is the date format of the audit period
+	auditDateLayout = "2006-01-02"
+)
+
+// AuditFormat is the output format of an audit report.
+type AuditFormat string
+
+const (
+	// AuditFormatMarkdown renders the audit as a markdown document
+	AuditFormatMarkdown AuditFormat = "markdown"
+	// AuditFormatHTML renders the audit as a single self-contained HTML page
+	AuditFormatHTML AuditFormat = "html"
+	// AuditFormatJSON renders the audit as indented JSON
+	AuditFormatJSON AuditFormat = "json"
+)
+
+// ParseAuditFormat parses a format name, accepting "md" for markdown.
+func ParseAuditFormat(name string) (AuditFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "markdown", "md":
+		return AuditFormatMarkdown, nil
+	case "html":
+		return AuditFormatHTML, nil
+	case "json":
+		return AuditFormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown audit format %q, must be markdown, html or json", name)
+	}
+}
+
+// How a decision in an audit came to be approved, or not.
+const (
+	// AuditApprovalAutomatic decisions scored well enough to need no approval
+	AuditApprovalAutomatic = "automatic"
+	// AuditApprovalRule decisions were approved by a standing approval rule
+	AuditApprovalRule = "rule"
+	// AuditApprovalPolicy decisions were approved by a policy rule, without
+	// ethical reasoning
+	AuditApprovalPolicy = "policy"
+	// AuditApprovalUser decisions were approved by the user
+	AuditApprovalUser = "user"
+	// AuditApprovalRejected decisions were turned down by the user
+	AuditApprovalRejected = "rejected"
+	// AuditApprovalPending decisions are still waiting for the user
+	AuditApprovalPending = "pending"
+)
+
+// AuditOptions controls what GenerateAuditReport includes and how it renders.
+type AuditOptions struct {
+	// MinUrgency leaves out decisions below this urgency
+	MinUrgency DecisionUrgency
+
+	// Format is the rendering of the report's Content (default: markdown)
+	Format AuditFormat
+}
+
+// DefaultAuditOptions returns options for a markdown audit of every decision.
+func DefaultAuditOptions() AuditOptions {
+	return AuditOptions{
+		MinUrgency: DecisionUrgencyLow,
+		Format:     AuditFormatMarkdown,
+	}
+}
+
+// AuditReport is a chronological account of the decisions made in a period,
+// grouped by goal and objective. Quoted text is redacted.
+type AuditReport struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	GeneratedAt time.Time `json:"generated_at"`
+	MinUrgency  string    `json:"min_urgency"`
+
+	Summary AuditSummary `json:"summary"`
+	Goals   []AuditGoal  `json:"goals"`
+
+	// Format and Content are the rendered report
+	Format  AuditFormat `json:"-"`
+	Content string      `json:"-"`
+}
+
+// WriteTo writes the rendered report to w.
+func (r *AuditReport) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, r.Content)
+	return int64(n), err
+}
+
+// LastDay returns the last day the report covers; To itself is excluded.
+func (r *AuditReport) LastDay() time.Time {
+	return r.To.Add(-time.Nanosecond)
+}
+
+// AuditGoal groups the audited decisions made for one goal.
+type AuditGoal struct {
+	GoalID     string           `json:"goal_id,omitempty"`
+	Title      string           `json:"title"`
+	Objectives []AuditObjective `json:"objectives"`
+}
+
+// AuditObjective groups the audited decisions made for one objective.
+type AuditObjective struct {
+	ObjectiveID string          `json:"objective_id,omitempty"`
+	Title       string          `json:"title"`
+	Decisions   []AuditDecision `json:"decisions"`
+}
+
+// AuditDecision is one decision as it appears in an audit.
+type AuditDecision struct {
+	DecisionID     string      `json:"decision_id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	Urgency        string      `json:"urgency"`
+	Context        string      `json:"context"`
+	ProposedAction string      `json:"proposed_action"`
+	Impact         AuditImpact `json:"impact"`
+
+	// Approval is one of the AuditApproval values
+	Approval   string     `json:"approval"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	Rule       *AuditRule `json:"rule,omitempty"`
+
+	// PolicyRuleID is the policy rule that approved or rejected the decision
+	PolicyRuleID string `json:"policy_rule_id,omitempty"`
+
+	// EvaluationFailure explains a decision made without an ethical evaluation
+	EvaluationFailure string `json:"evaluation_failure,omitempty"`
+
+	State         string     `json:"state"`
+	ImplementedAt *time.Time `json:"implemented_at,omitempty"`
+	Outcome       string     `json:"outcome"`
+	Feedback      string     `json:"feedback,omitempty"`
+
+	AbortedAt              *time.Time `json:"aborted_at,omitempty"`
+	AbortReason            string     `json:"abort_reason,omitempty"`
+	Remediation            string     `json:"remediation,omitempty"`
+	RemediationObjectiveID string     `json:"remediation_objective_id,omitempty"`
+}
+
+// AuditImpact is a decision's assessed impact scores.
+type AuditImpact struct {
+	Freedom        float64 `json:"freedom"`
+	WellBeing      float64 `json:"well_being"`
+	Sustainability float64 `json:"sustainability"`
+	Confidence     float64 `json:"confidence"`
+}
+
+// AuditRule describes an approval rule in an audit.
+type AuditRule struct {
+	RuleID      string     `json:"rule_id"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+}
+
+// AuditSummary holds the statistics of an audit period.
+type AuditSummary struct {
+	Decisions int `json:"decisions"`
+	Aborted   int `json:"aborted"`
+
+	// ByUrgency breaks approvals down per urgency, lowest first
+	ByUrgency []AuditUrgencyStats `json:"by_urgency"`
+
+	// Outcomes counts decisions by recorded outcome
+	Outcomes map[string]int `json:"outcomes"`
+
+	RulesCreated   []AuditRule `json:"rules_created"`
+	RulesSuspended []AuditRule `json:"rules_suspended"`
+}
+
+// AuditUrgencyStats counts how decisions of one urgency were approved.
+type AuditUrgencyStats struct {
+	Urgency      string `json:"urgency"`
+	Decisions    int    `json:"decisions"`
+	Automatic    int    `json:"automatic"`
+	ByRule       int    `json:"by_rule"`
+	UserApproved int    `json:"user_approved"`
+	Rejected     int    `json:"rejected"`
+	Pending      int    `json:"pending"`
+}
+
+// AutoApprovalRate is the fraction of decisions approved without the user (0.0-1.0).
+func (s AuditUrgencyStats) AutoApprovalRate() float64 {
+	if s.Decisions == 0 {
+		return 0.0
+	}
+	return float64(s.Automatic+s.ByRule) / float64(s.Decisions)
+}
+
+// GenerateAuditReport renders every decision made from from up to to, at or
+// above the minimum urgency: what was proposed, its impact, how it was
+// approved and by which rule, what became of it, and any abort. Summary
+// statistics cover approvals by urgency, outcomes, and the approval rules
+// created or suspended in the period.
+func (ef *EthicalFramework) GenerateAuditReport(ctx context.Context, from, to time.Time, opts AuditOptions) (*AuditReport, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("audit period must end after it starts")
+	}
+	if opts.Format == "" {
+		opts.Format = AuditFormatMarkdown
+	}
+	format, err := ParseAuditFormat(string(opts.Format))
+	if err != nil {
+		return nil, err
+	}
+	opts.Format = format
+
+	decisions, err := ef.ListDecisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var audited []*EthicalDecision
+	for _, decision := range decisions {
+		if inPeriod(decision.CreatedAt, from, to) && decision.Urgency >= opts.MinUrgency {
+			audited = append(audited, decision)
+		}
+	}
+	sort.SliceStable(audited, func(i, j int) bool {
+		return audited[i].CreatedAt.Before(audited[j].CreatedAt)
+	})
+
+	rules, err := ef.rules.ListRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list approval rules: %w", err)
+	}
+	rulesByID := make(map[string]*ApprovalRule, len(rules))
+	for _, rule := range rules {
+		rulesByID[rule.ID] = rule
+	}
+
+	report := &AuditReport{
+		From:        from,
+		To:          to,
+		GeneratedAt: ef.clock.Now(),
+		MinUrgency:  opts.MinUrgency.String(),
+		Format:      opts.Format,
+	}
+	report.Goals = ef.groupAuditDecisions(ctx, audited, rulesByID)
+	report.Summary = summarizeAudit(audited, rules, from, to)
+
+	switch opts.Format {
+	case AuditFormatJSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode audit: %w", err)
+		}
+		report.Content = string(data) + "\n"
+	case AuditFormatHTML:
+		var b bytes.Buffer
+		if err := auditHTMLTemplate.Execute(&b, report); err != nil {
+			return nil, fmt.Errorf("failed to render audit: %w", err)
+		}
+		report.Content = b.String()
+	default:
+		report.Content = renderAuditMarkdown(report)
+	}
+	return report, nil
+}
+
+// groupAuditDecisions groups chronologically ordered decisions by goal and
+// objective. Groups are ordered by their first decision.
+func (ef *EthicalFramework) groupAuditDecisions(ctx context.Context, decisions []*EthicalDecision, rules map[string]*ApprovalRule) []AuditGoal {
+	objectives := NewObjectiveManager(ef.store)
+	goals := NewGoalManager(ef.store)
+
+	var groups []AuditGoal
+	goalIndex := make(map[string]int)
+	objectiveIndex := make(map[string][2]int)
+
+	for _, decision := range decisions {
+		key, found := objectiveIndex[decision.ObjectiveID]
+		if !found {
+			goalID, goalTitle := "", "No goal"
+			objectiveTitle := "No objective"
+			if decision.ObjectiveID != "" {
+				objectiveTitle = decision.ObjectiveID
+				if objective, err := objectives.GetObjective(ctx, decision.ObjectiveID); err == nil {
+					objectiveTitle = objective.Title
+					goalID = objective.GoalID
+					goalTitle = objective.GoalID
+					if goal, err := goals.GetGoal(ctx, objective.GoalID); err == nil {
+						goalTitle = goal.Title
+					}
+				}
+			}
+
+			g, ok := goalIndex[goalID]
+			if !ok {
+				g = len(groups)
+				goalIndex[goalID] = g
+				groups = append(groups, AuditGoal{GoalID: goalID, Title: goalTitle})
+			}
+			groups[g].Objectives = append(groups[g].Objectives, AuditObjective{
+				ObjectiveID: decision.ObjectiveID,
+				Title:       objectiveTitle,
+			})
+			key = [2]int{g, len(groups[g].Objectives) - 1}
+			objectiveIndex[decision.ObjectiveID] = key
+		}
+
+		group := &groups[key[0]].Objectives[key[1]]
+		group.Decisions = append(group.Decisions, auditDecision(decision, rules))
+	}
+	return groups
+}
+
+// auditDecision converts a decision for the audit, redacting quoted text.
+func auditDecision(decision *EthicalDecision, rules map[string]*ApprovalRule) AuditDecision {
+	entry := AuditDecision{
+		DecisionID:     decision.ID,
+		CreatedAt:      decision.CreatedAt,
+		Urgency:        decision.Urgency.String(),
+		Context:        llm.RedactSecrets(decision.DecisionContext),
+		ProposedAction: llm.RedactSecrets(decision.ProposedAction),
+		Impact: AuditImpact{
+			Freedom:        decision.Impact.FreedomImpact,
+			WellBeing:      decision.Impact.WellBeingImpact,
+			Sustainability: decision.Impact.SustainabilityImpact,
+			Confidence:     decision.Impact.ConfidenceScore,
+		},
+		Approval:      auditApproval(decision),
+		ApprovedAt:    decision.ApprovedAt,
+		State:         decision.State().String(),
+		ImplementedAt: decision.ImplementedAt,
+		Outcome:       string(decision.Outcome),
+		Feedback:      llm.RedactSecrets(decision.UserFeedback),
+		AbortedAt:     decision.AbortedAt,
+		AbortReason:   llm.RedactSecrets(decision.AbortReason),
+		PolicyRuleID:  decision.PolicyRuleID,
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = string(DecisionOutcomeUnknown)
+	}
+
+	if decision.ApprovedByRule != "" {
+		if rule, ok := rules[decision.ApprovedByRule]; ok {
+			entry.Rule = auditRule(rule)
+		} else {
+			entry.Rule = &AuditRule{RuleID: decision.ApprovedByRule, Description: "rule no longer exists", Status: "deleted"}
+		}
+	}
+	if failure := decision.EvaluationFailure; failure != nil {
+		entry.EvaluationFailure = llm.RedactSecrets(fmt.Sprintf("%s (%s): %s", failure.Mode, failure.Cause, failure.Reason))
+	}
+	if plan := decision.RemediationPlan; plan != nil {
+		entry.Remediation = llm.RedactSecrets(plan.Description)
+		entry.RemediationObjectiveID = plan.ObjectiveID
+	}
+	return entry
+}
+
+// auditApproval classifies how a decision was approved.
+func auditApproval(decision *EthicalDecision) string {
+	switch {
+	case decision.ApprovedByRule != "":
+		return AuditApprovalRule
+	case decision.PolicyRuleID != "" && decision.ApprovalStatus == DecisionApprovalApproved:
+		return AuditApprovalPolicy
+	case decision.ApprovalStatus == DecisionApprovalNotRequired:
+		return AuditApprovalAutomatic
+	case decision.ApprovalStatus == DecisionApprovalRejected:
+		return AuditApprovalRejected
+	case decision.ApprovalStatus == DecisionApprovalPending:
+		return AuditApprovalPending
+	default:
+		return AuditApprovalUser
+	}
+}
+
+// auditRule converts an approval rule for the audit.
+func auditRule(rule *ApprovalRule) *AuditRule {
+	createdAt := rule.CreatedAt
+	return &AuditRule{
+		RuleID:      rule.ID,
+		Description: llm.RedactSecrets(rule.String()),
+		Status:      string(rule.Status),
+		CreatedAt:   &createdAt,
+		SuspendedAt: rule.SuspendedAt,
+		Reason:      llm.RedactSecrets(rule.SuspendedReason),
+	}
+}
+
+// summarizeAudit computes the statistics of the audited decisions and of the
+// rules created or suspended in the period.
+func summarizeAudit(decisions []*EthicalDecision, rules []*ApprovalRule, from, to time.Time) AuditSummary {
+	summary := AuditSummary{
+		Decisions:      len(decisions),
+		Outcomes:       make(map[string]int),
+		RulesCreated:   []AuditRule{},
+		RulesSuspended: []AuditRule{},
+	}
+
+	byUrgency := make(map[DecisionUrgency]*AuditUrgencyStats)
+	for _, decision := range decisions {
+		stats, ok := byUrgency[decision.Urgency]
+		if !ok {
+			stats = &AuditUrgencyStats{Urgency: decision.Urgency.String()}
+			byUrgency[decision.Urgency] = stats
+		}
+		stats.Decisions++
+		switch auditApproval(decision) {
+		case AuditApprovalAutomatic:
+			stats.Automatic++
+		case AuditApprovalRule, AuditApprovalPolicy:
+			stats.ByRule++
+		case AuditApprovalUser:
+			stats.UserApproved++
+		case AuditApprovalRejected:
+			stats.Rejected++
+		case AuditApprovalPending:
+			stats.Pending++
+		}
+
+		outcome := string(decision.Outcome)
+		if outcome == "" {
+			outcome = string(DecisionOutcomeUnknown)
+		}
+		summary.Outcomes[outcome]++
+		if decision.IsAborted() {
+			summary.Aborted++
+		}
+	}
+	for urgency := DecisionUrgencyLow; urgency <= DecisionUrgencyCritical; urgency++ {
+		if stats, ok := byUrgency[urgency]; ok {
+			summary.ByUrgency = append(summary.ByUrgency, *stats)
+		}
+	}
+
+	sorted := append([]*ApprovalRule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+	for _, rule := range sorted {
+		if inPeriod(rule.CreatedAt, from, to) {
+			summary.RulesCreated = append(summary.RulesCreated, *auditRule(rule))
+		}
+		if rule.SuspendedAt != nil && inPeriod(*rule.SuspendedAt, from, to) {
+			summary.RulesSuspended = append(summary.RulesSuspended, *auditRule(rule))
+		}
+	}
+	return summary
+}
+
+// inPeriod reports whether t falls in [from, to).
+func inPeriod(t, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
+
+// ApprovalText describes how the decision was approved, naming its rule.
+func (d AuditDecision) ApprovalText() string {
+	switch d.Approval {
+	case AuditApprovalRule:
+		return fmt.Sprintf("auto-approved by rule %s (%s)", d.Rule.RuleID, d.Rule.Description)
+	case AuditApprovalPolicy:
+		return fmt.Sprintf("approved by policy rule %s", d.PolicyRuleID)
+	case AuditApprovalAutomatic:
+		return "auto-approved (no approval required)"
+	case AuditApprovalUser:
+		if d.ApprovedAt != nil {
+			return "approved by the user on " + d.ApprovedAt.Format(auditTimeLayout)
+		}
+		return "approved by the user"
+	case AuditApprovalRejected:
+		if d.PolicyRuleID != "" {
+			return fmt.Sprintf("rejected by policy rule %s", d.PolicyRuleID)
+		}
+		return "rejected by the user"
+	default:
+		return "awaiting the user's approval"
+	}
+}
+
+// ImpactText summarizes the impact scores on one line.
+func (d AuditDecision) ImpactText() string {
+	return fmt.Sprintf("freedom %+.2f, well-being %+.2f, sustainability %+.2f (confidence %.2f)",
+		d.Impact.Freedom, d.Impact.WellBeing, d.Impact.Sustainability, d.Impact.Confidence)
+}
+
+// StatusText describes where the decision ended up.
+func (d AuditDecision) StatusText() string {
+	if d.ImplementedAt != nil {
+		return fmt.Sprintf("%s (implemented %s)", d.State, d.ImplementedAt.Format(auditTimeLayout))
+	}
+	return d.State
+}
+
+// OutcomeCounts lists the outcome counts in a fixed order, for rendering.
+func (s AuditSummary) OutcomeCounts() string {
+	var parts []string
+	for _, outcome := range []DecisionOutcome{DecisionOutcomePositive, DecisionOutcomeNeutral, DecisionOutcomeNegative, DecisionOutcomeUnknown} {
+		if count := s.Outcomes[string(outcome)]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", outcome, count))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renderAuditMarkdown renders the report as markdown.
+func renderAuditMarkdown(report *AuditReport) string {
+	var b strings.Builder
+	b.WriteString("# Decision Audit\n\n")
+	fmt.Fprintf(&b, "**Period:** %s to %s  \n", report.From.Format(auditDateLayout), report.LastDay().Format(auditDateLayout))
+	fmt.Fprintf(&b, "**Generated:** %s  \n", report.GeneratedAt.Format(auditTimeLayout))
+	fmt.Fprintf(&b, "**Minimum urgency:** %s\n\n", report.MinUrgency)
+
+	summary := report.Summary
+	b.WriteString("## Summary\n\n")
+	fmt.Fprintf(&b, "- **Decisions:** %d (%d aborted)\n", summary.Decisions, summary.Aborted)
+	fmt.Fprintf(&b, "- **Outcomes:** %s\n\n", summary.OutcomeCounts())
+
+	if len(summary.ByUrgency) > 0 {
+		b.WriteString("| Urgency | Decisions | Automatic | By rule | User-approved | Rejected | Pending | Auto-approval rate |\n")
+		b.WriteString("|---------|-----------|-----------|---------|---------------|----------|---------|--------------------|\n")
+		for _, stats := range summary.ByUrgency {
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %d | %d | %.0f%% |\n", stats.Urgency, stats.Decisions,
+				stats.Automatic, stats.ByRule, stats.UserApproved, stats.Rejected, stats.Pending, stats.AutoApprovalRate()*100)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("### Approval Rules\n\n")
+	if len(summary.RulesCreated) == 0 && len(summary.RulesSuspended) == 0 {
+		b.WriteString("_No rules were created or suspended in this period._\n\n")
+	}
+	for _, rule := range summary.RulesCreated {
+		fmt.Fprintf(&b, "- Created %s: rule `%s`, %s (now %s)\n", rule.CreatedAt.Format(auditTimeLayout), rule.RuleID, rule.Description, rule.Status)
+	}
+	for _, rule := range summary.RulesSuspended {
+		fmt.Fprintf(&b, "- Suspended %s: rule `%s`, %s: %s\n", rule.SuspendedAt.Format(auditTimeLayout), rule.RuleID, rule.Description, rule.Reason)
+	}
+	if len(summary.RulesCreated) > 0 || len(summary.RulesSuspended) > 0 {
+		b.WriteString("\n")
+	}
+
+	if len(report.Goals) == 0 {
+		b.WriteString("_No decisions were made in this period._\n")
+		return b.String()
+	}
+
+	for _, goal := range report.Goals {
+		fmt.Fprintf(&b, "## Goal: %s\n\n", goal.Title)
+		for _, objective := range goal.Objectives {
+			fmt.Fprintf(&b, "### Objective: %s\n\n", objective.Title)
+			for _, decision := range objective.Decisions {
+				fmt.Fprintf(&b, "#### %s · %s · %s\n\n", decision.CreatedAt.Format(auditTimeLayout), decision.Urgency, decision.ProposedAction)
+				fmt.Fprintf(&b, "- **Decision:** `%s`\n", decision.DecisionID)
+				fmt.Fprintf(&b, "- **Context:** %s\n", decision.Context)
+				fmt.Fprintf(&b, "- **Impact:** %s\n", decision.ImpactText())
+				fmt.Fprintf(&b, "- **Approval:** %s\n", decision.ApprovalText())
+				if decision.EvaluationFailure != "" {
+					fmt.Fprintf(&b, "- **Made without evaluation:** %s\n", decision.EvaluationFailure)
+				}
+				fmt.Fprintf(&b, "- **Status:** %s\n", decision.StatusText())
+				fmt.Fprintf(&b, "- **Outcome:** %s\n", decision.Outcome)
+				if decision.Feedback != "" {
+					fmt.Fprintf(&b, "- **Feedback:** %s\n", decision.Feedback)
+				}
+				if decision.AbortedAt != nil {
+					fmt.Fprintf(&b, "- **Aborted:** %s: %s\n", decision.AbortedAt.Format(auditTimeLayout), decision.AbortReason)
+				}
+				if decision.Remediation != "" {
+					remediation := decision.Remediation
+					if decision.RemediationObjectiveID != "" {
+						remediation += fmt.Sprintf(" (objective `%s`)", decision.RemediationObjectiveID)
+					}
+					fmt.Fprintf(&b, "- **Rollback plan:** %s\n", remediation)
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// auditHTMLTemplate renders the report as one HTML page with inline styles
+// and no external assets.
+var auditHTMLTemplate = template.Must(template.New("audit").Funcs(template.FuncMap{
+	"time": func(t *time.Time) string { return t.Format(auditTimeLayout) },
+	"at":   func(t time.Time) string { return t.Format(auditTimeLayout) },
+	"date": func(t time.Time) string { return t.Format(auditDateLayout) },
+	"pct":  func(rate float64) string { return fmt.Sprintf("%.0f%%", rate*100) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Decision Audit {{date .From}} to {{date .LastDay}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; color: #222; }
+h1, h2, h3 { color: #123; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.decision { border-left: 4px solid #89a; padding: 0.2em 1em; margin: 1em 0; }
+.decision.aborted { border-color: #c44; }
+.meta { color: #666; }
+dt { font-weight: bold; }
+dd { margin: 0 0 0.4em 1em; }
+code { background: #eee; padding: 0 0.2em; }
+</style>
+</head>
+<body>
+<h1>Decision Audit</h1>
+<p class="meta">Period: {{date .From}} to {{date .LastDay}} &middot; Generated: {{at .GeneratedAt}} &middot; Minimum urgency: {{.MinUrgency}}</p>
+<h2>Summary</h2>
+<p>Decisions: {{.Summary.Decisions}} ({{.Summary.Aborted}} aborted) &middot; Outcomes: {{.Summary.OutcomeCounts}}</p>
+{{- if .Summary.ByUrgency}}
+<table>
+<tr><th>Urgency</th><th>Decisions</th><th>Automatic</th><th>By rule</th><th>User-approved</th><th>Rejected</th><th>Pending</th><th>Auto-approval rate</th></tr>
+{{- range .Summary.ByUrgency}}
+<tr><td>{{.Urgency}}</td><td>{{.Decisions}}</td><td>{{.Automatic}}</td><td>{{.ByRule}}</td><td>{{.UserApproved}}</td><td>{{.Rejected}}</td><td>{{.Pending}}</td><td>{{pct .AutoApprovalRate}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<h3>Approval Rules</h3>
+{{- if or .Summary.RulesCreated .Summary.RulesSuspended}}
+<ul>
+{{- range .Summary.RulesCreated}}
+<li>Created {{time .CreatedAt}}: rule <code>{{.RuleID}}</code>, {{.Description}} (now {{.Status}})</li>
+{{- end}}
+{{- range .Summary.RulesSuspended}}
+<li>Suspended {{time .SuspendedAt}}: rule <code>{{.RuleID}}</code>, {{.Description}}: {{.Reason}}</li>
+{{- end}}
+</ul>
+{{- else}}
+<p><em>No rules were created or suspended in this period.</em></p>
+{{- end}}
+{{- range .Goals}}
+<h2>Goal: {{.Title}}</h2>
+{{- range .Objectives}}
+<h3>Objective: {{.Title}}</h3>
+{{- range .Decisions}}
+<div class="decision{{if .AbortedAt}} aborted{{end}}">
+<h4>{{at .CreatedAt}} &middot; {{.Urgency}} &middot; {{.ProposedAction}}</h4>
+<dl>
+<dt>Decision</dt><dd><code>{{.DecisionID}}</code></dd>
+<dt>Context</dt><dd>{{.Context}}</dd>
+<dt>Impact</dt><dd>{{.ImpactText}}</dd>
+<dt>Approval</dt><dd>{{.ApprovalText}}</dd>
+{{- if .EvaluationFailure}}
+<dt>Made without evaluation</dt><dd>{{.EvaluationFailure}}</dd>
+{{- end}}
+<dt>Status</dt><dd>{{.StatusText}}</dd>
+<dt>Outcome</dt><dd>{{.Outcome}}</dd>
+{{- if .Feedback}}
+<dt>Feedback</dt><dd>{{.Feedback}}</dd>
+{{- end}}
+{{- if .AbortedAt}}
+<dt>Aborted</dt><dd>{{time .AbortedAt}}: {{.AbortReason}}</dd>
+{{- end}}
+{{- if .Remediation}}
+<dt>Rollback plan</dt><dd>{{.Remediation}}{{if .RemediationObjectiveID}} (objective <code>{{.RemediationObjectiveID}}</code>){{end}}</dd>
+{{- end}}
+</dl>
+</div>
+{{- end}}
+{{- end}}
+{{- else}}
+<p><em>No decisions were made in this period.</em></p>
+{{- end}}
+</body>
+</html>
+`))
+
+// AuditExport records an audit report written to a file.
+type AuditExport struct {
+	Path        string
+	Format      AuditFormat
+	From        time.Time
+	To          time.Time
+	GeneratedAt time.Time
+}
+
+// RecordAuditExport remembers where an audit report was written, so status
+// displays can point to the latest one.
+func (ef *EthicalFramework) RecordAuditExport(ctx context.Context, path string, report *AuditReport) error {
+	node := storage.NewNode(auditExportNodeType, map[string]interface{}{
+		"path":         path,
+		"format":       string(report.Format),
+		"from":         report.From.Format(time.RFC3339),
+		"to":           report.To.Format(time.RFC3339),
+		"generated_at": report.GeneratedAt.Format(time.RFC3339),
+	})
+	if err := ef.store.AddNode(ctx, node); err != nil {
+		return fmt.Errorf("failed to record audit export: %w", err)
+	}
+	return nil
+}
+
+// LatestAuditExport returns the most recently generated audit export, or nil
+// if no audit has been written.
+func (ef *EthicalFramework) LatestAuditExport(ctx context.Context) (*AuditExport, error) {
+	nodes, err := ef.store.GetNodesByType(ctx, auditExportNodeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit exports: %w", err)
+	}
+
+	var latest *AuditExport
+	for _, node := range nodes {
+		export := &AuditExport{
+			Path:   getString(node.Data, "path"),
+			Format: AuditFormat(getString(node.Data, "format")),
+		}
+		export.From, _ = time.Parse(time.RFC3339, getString(node.Data, "from"))
+		export.To, _ = time.Parse(time.RFC3339, getString(node.Data, "to"))
+		export.GeneratedAt, _ = time.Parse(time.RFC3339, getString(node.Data, "generated_at"))
+		if latest == nil || export.GeneratedAt.After(latest.GeneratedAt) {
+			latest = export
+		}
+	}
+	return latest, nil
+}
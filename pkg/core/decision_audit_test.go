@@ -0,0 +1,309 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+var updateAuditGolden = flag.Bool("update-audit", false, "rewrite the decision audit golden files")
+
+// auditFixture is a week of decisions covering every approval path.
+type auditFixture struct {
+	ef       *EthicalFramework
+	clock    *utils.FakeClock
+	from, to time.Time
+	ids      map[string]string // ID -> placeholder
+}
+
+func setupAuditFixture(t *testing.T) *auditFixture {
+	t.Helper()
+	ctx := context.Background()
+	store := setupTestStore(t)
+
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewFakeClock(from.Add(9 * time.Hour))
+	config := DefaultEthicalConfig()
+	config.Clock = clock
+	f := &auditFixture{
+		ef:    NewEthicalFramework(store, nil, NewUserContextManager(store), config),
+		clock: clock,
+		from:  from,
+		to:    from.AddDate(0, 0, 7),
+		ids:   make(map[string]string),
+	}
+
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Tidy the home server", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Cleanup", "", []ApproachStep{{Description: "Clean"}}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	objectives := NewObjectiveManager(store)
+	logs, err := objectives.CreateObjective(ctx, goal.ID, method.ID, "Rotate the logs", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	config2, err := objectives.CreateObjective(ctx, goal.ID, method.ID, "Update the proxy config", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	f.ids[goal.ID] = "<goal>"
+	f.ids[logs.ID] = "<logs-objective>"
+	f.ids[config2.ID] = "<config-objective>"
+
+	store1 := func(placeholder string, decision *EthicalDecision) *EthicalDecision {
+		t.Helper()
+		if decision.CreatedAt.IsZero() {
+			decision.CreatedAt = clock.Now()
+		}
+		if decision.Outcome == "" {
+			decision.Outcome = DecisionOutcomeUnknown
+		}
+		if err := f.ef.storeDecision(ctx, decision); err != nil {
+			t.Fatalf("Failed to store decision: %v", err)
+		}
+		f.ids[decision.ID] = placeholder
+		return decision
+	}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Lifecycle call failed: %v", err)
+		}
+	}
+
+	// Before the period: left out
+	store1("<old>", &EthicalDecision{
+		ObjectiveID: logs.ID, DecisionContext: "Last week", ProposedAction: "list old logs",
+		Urgency: DecisionUrgencyLow, ApprovalStatus: DecisionApprovalNotRequired, CreatedAt: from.Add(-time.Hour),
+	})
+
+	// Needs no approval, goes well
+	automatic := store1("<automatic>", &EthicalDecision{
+		ObjectiveID: logs.ID, DecisionContext: "Logs fill the disk", ProposedAction: "compress logs older than a week",
+		Impact:  EthicalImpact{FreedomImpact: 0.6, WellBeingImpact: 0.7, SustainabilityImpact: 0.8, ConfidenceScore: 0.9},
+		Urgency: DecisionUrgencyLow, ApprovalStatus: DecisionApprovalNotRequired,
+	})
+	clock.Advance(30 * time.Minute)
+	must(f.ef.ImplementDecision(ctx, automatic.ID))
+	must(f.ef.RecordOutcome(ctx, automatic.ID, DecisionOutcomePositive, "Freed 4 GB"))
+
+	// Approved by a rule, goes badly, suspending the rule
+	clock.Advance(24 * time.Hour)
+	rule, err := f.ef.Rules().CreateRule(ctx, &ApprovalRule{Verb: "write", Service: "filesystem", ScopeKind: ApprovalScopePathPrefix, Scope: "/srv/proxy"})
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	f.ids[rule.ID] = "<rule>"
+	clock.Advance(time.Hour)
+	now := clock.Now()
+	byRule := store1("<by-rule>", &EthicalDecision{
+		ObjectiveID: config2.ID, DecisionContext: "Proxy needs a new upstream, api_key=abc123",
+		ProposedAction: "write file /srv/proxy/upstream.conf",
+		Impact:         EthicalImpact{FreedomImpact: 0.2, WellBeingImpact: 0.3, SustainabilityImpact: 0.1, ConfidenceScore: 0.7},
+		Urgency:        DecisionUrgencyMedium, ApprovalStatus: DecisionApprovalApproved, ApprovedAt: &now, ApprovedByRule: rule.ID,
+	})
+	clock.Advance(10 * time.Minute)
+	must(f.ef.ImplementDecision(ctx, byRule.ID))
+	must(f.ef.RecordOutcome(ctx, byRule.ID, DecisionOutcomeNegative, "The proxy stopped serving"))
+
+	// Approved by the user, aborted partway
+	clock.Advance(24 * time.Hour)
+	userApproved := store1("<user-approved>", &EthicalDecision{
+		ObjectiveID: config2.ID, DecisionContext: "Move the proxy to a new port",
+		ProposedAction: "restart the proxy on port 8443",
+		Impact:         EthicalImpact{FreedomImpact: -0.3, WellBeingImpact: 0.1, SustainabilityImpact: 0.0, ConfidenceScore: 0.6},
+		Urgency:        DecisionUrgencyHigh, ApprovalStatus: DecisionApprovalPending,
+		RemediationPlan: &RemediationPlan{Description: "Restart the proxy on port 443"},
+	})
+	clock.Advance(2 * time.Hour)
+	must(f.ef.ApproveDecision(ctx, userApproved.ID, "Go ahead"))
+	clock.Advance(time.Hour)
+	must(f.ef.ImplementDecision(ctx, userApproved.ID))
+	clock.Advance(15 * time.Minute)
+	if _, err := f.ef.AbortImplementation(ctx, userApproved.ID, "clients still use 443, token sk-abcdefghijklmnop1234 leaked in logs"); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+
+	// Rejected, with no objective
+	clock.Advance(24 * time.Hour)
+	rejected := store1("<rejected>", &EthicalDecision{
+		DecisionContext: "Disk almost full", ProposedAction: "empty the backup folder",
+		Impact:  EthicalImpact{FreedomImpact: -0.8, WellBeingImpact: -0.6, SustainabilityImpact: -0.5, ConfidenceScore: 0.8},
+		Urgency: DecisionUrgencyCritical, ApprovalStatus: DecisionApprovalPending,
+	})
+	clock.Advance(time.Hour)
+	must(f.ef.RejectDecision(ctx, rejected.ID, "Never touch backups"))
+
+	// Still waiting
+	clock.Advance(time.Hour)
+	store1("<pending>", &EthicalDecision{
+		ObjectiveID: logs.ID, DecisionContext: "Old logs remain", ProposedAction: "archive logs to /mnt/cold",
+		Impact:  EthicalImpact{FreedomImpact: 0.1, WellBeingImpact: 0.2, SustainabilityImpact: 0.3, ConfidenceScore: 0.5},
+		Urgency: DecisionUrgencyLow, ApprovalStatus: DecisionApprovalPending,
+	})
+
+	// After the period: left out
+	store1("<late>", &EthicalDecision{
+		ObjectiveID: logs.ID, DecisionContext: "Next week", ProposedAction: "list new logs",
+		Urgency: DecisionUrgencyLow, ApprovalStatus: DecisionApprovalNotRequired, CreatedAt: f.to,
+	})
+	return f
+}
+
+// normalize replaces generated IDs with stable placeholders.
+func (f *auditFixture) normalize(content string) string {
+	var pairs []string
+	for id, placeholder := range f.ids {
+		pairs = append(pairs, id, placeholder)
+	}
+	return strings.NewReplacer(pairs...).Replace(content)
+}
+
+func TestEthicalFramework_GenerateAuditReportGolden(t *testing.T) {
+	f := setupAuditFixture(t)
+
+	for _, tc := range []struct {
+		format AuditFormat
+		golden string
+	}{
+		{AuditFormatMarkdown, "decision_audit.golden.md"},
+		{AuditFormatHTML, "decision_audit.golden.html"},
+		{AuditFormatJSON, "decision_audit.golden.json"},
+	} {
+		t.Run(string(tc.format), func(t *testing.T) {
+			report, err := f.ef.GenerateAuditReport(context.Background(), f.from, f.to, AuditOptions{Format: tc.format})
+			if err != nil {
+				t.Fatalf("Failed to generate audit: %v", err)
+			}
+			got := f.normalize(report.Content)
+
+			goldenPath := filepath.Join("testdata", tc.golden)
+			if *updateAuditGolden {
+				if err := os.WriteFile(goldenPath, []byte(got), 0644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("Audit does not match %s (run with -update-audit to refresh)\n--- got ---\n%s", goldenPath, got)
+			}
+		})
+	}
+}
+
+func TestEthicalFramework_GenerateAuditReport(t *testing.T) {
+	f := setupAuditFixture(t)
+	ctx := context.Background()
+
+	report, err := f.ef.GenerateAuditReport(ctx, f.from, f.to, DefaultAuditOptions())
+	if err != nil {
+		t.Fatalf("Failed to generate audit: %v", err)
+	}
+	if report.Summary.Decisions != 5 || report.Summary.Aborted != 1 {
+		t.Errorf("Expected 5 decisions in the period with 1 aborted, got %+v", report.Summary)
+	}
+	if len(report.Summary.RulesCreated) != 1 || len(report.Summary.RulesSuspended) != 1 {
+		t.Errorf("Expected the rule to be created and suspended in the period, got %+v", report.Summary)
+	}
+
+	// Groups follow the first decision of each goal and objective
+	if len(report.Goals) != 2 || report.Goals[0].Title != "Tidy the home server" || report.Goals[1].Title != "No goal" {
+		t.Fatalf("Expected the goal followed by the unassigned group, got %+v", report.Goals)
+	}
+	if objectives := report.Goals[0].Objectives; len(objectives) != 2 || objectives[0].Title != "Rotate the logs" || len(objectives[0].Decisions) != 2 {
+		t.Errorf("Expected both log decisions under the first objective, got %+v", objectives)
+	}
+
+	// Quoted text is redacted
+	for _, leaked := range []string{"abc123", "sk-abcdefghijklmnop1234"} {
+		if strings.Contains(report.Content, leaked) {
+			t.Errorf("Expected %q to be redacted from the audit", leaked)
+		}
+	}
+
+	// Rule attribution survives in every format
+	for _, format := range []AuditFormat{AuditFormatMarkdown, AuditFormatHTML} {
+		report, err := f.ef.GenerateAuditReport(ctx, f.from, f.to, AuditOptions{Format: format})
+		if err != nil {
+			t.Fatalf("Failed to generate audit: %v", err)
+		}
+		if !strings.Contains(f.normalize(report.Content), "auto-approved by rule <rule> (allow write on filesystem under /srv/proxy)") {
+			t.Errorf("Expected the %s audit to attribute the approval to its rule", format)
+		}
+	}
+	report, err = f.ef.GenerateAuditReport(ctx, f.from, f.to, AuditOptions{Format: AuditFormatJSON})
+	if err != nil {
+		t.Fatalf("Failed to generate audit: %v", err)
+	}
+	var decoded AuditReport
+	if err := json.Unmarshal([]byte(report.Content), &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON audit: %v", err)
+	}
+	byRule := decoded.Goals[0].Objectives[1].Decisions[0]
+	if byRule.Approval != AuditApprovalRule || byRule.Rule == nil || byRule.Rule.Status != string(ApprovalRuleSuspended) {
+		t.Errorf("Expected the JSON audit to name the suspended rule, got %+v", byRule)
+	}
+
+	// The HTML page loads nothing from elsewhere
+	report, _ = f.ef.GenerateAuditReport(ctx, f.from, f.to, AuditOptions{Format: AuditFormatHTML})
+	for _, external := range []string{"<link", "<script", "src=", "http://", "https://"} {
+		if strings.Contains(report.Content, external) {
+			t.Errorf("Expected a self-contained HTML audit, found %q", external)
+		}
+	}
+
+	// The urgency filter drops lower-urgency decisions
+	report, err = f.ef.GenerateAuditReport(ctx, f.from, f.to, AuditOptions{MinUrgency: DecisionUrgencyHigh})
+	if err != nil {
+		t.Fatalf("Failed to generate audit: %v", err)
+	}
+	if report.Summary.Decisions != 2 {
+		t.Errorf("Expected 2 high or critical decisions, got %d", report.Summary.Decisions)
+	}
+
+	if _, err := f.ef.GenerateAuditReport(ctx, f.to, f.from, DefaultAuditOptions()); err == nil {
+		t.Error("Expected an error for a period that ends before it starts")
+	}
+	if _, err := f.ef.GenerateAuditReport(ctx, f.from, f.to, AuditOptions{Format: "pdf"}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestEthicalFramework_LatestAuditExport(t *testing.T) {
+	f := setupAuditFixture(t)
+	ctx := context.Background()
+
+	if latest, err := f.ef.LatestAuditExport(ctx); err != nil || latest != nil {
+		t.Fatalf("Expected no audit export yet, got %+v, %v", latest, err)
+	}
+
+	for _, path := range []string{"/tmp/first.html", "/tmp/second.md"} {
+		report, err := f.ef.GenerateAuditReport(ctx, f.from, f.to, DefaultAuditOptions())
+		if err != nil {
+			t.Fatalf("Failed to generate audit: %v", err)
+		}
+		if err := f.ef.RecordAuditExport(ctx, path, report); err != nil {
+			t.Fatalf("Failed to record export: %v", err)
+		}
+		f.clock.Advance(time.Hour)
+	}
+
+	latest, err := f.ef.LatestAuditExport(ctx)
+	if err != nil || latest == nil || latest.Path != "/tmp/second.md" || !latest.From.Equal(f.from) {
+		t.Errorf("Expected the second export to be the latest, got %+v, %v", latest, err)
+	}
+}
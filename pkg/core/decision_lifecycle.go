@@ -0,0 +1,338 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// DecisionState is where an ethical decision is in its lifecycle. It is
+// derived from the decision's approval status and timestamps:
+//
+//	awaiting_approval ──approve──▶ approved ──implement──▶ implemented ──outcome──▶ closed
+//	        │                                                   │
+//	        └──reject──▶ rejected                               └──abort──▶ aborted
+//
+// Decisions that need no approval, or are approved by a standing rule, start
+// out approved.
+type DecisionState string
+
+const (
+	// DecisionStateAwaitingApproval for decisions waiting on the user
+	DecisionStateAwaitingApproval DecisionState = "awaiting_approval"
+	// DecisionStateApproved for decisions cleared to be implemented
+	DecisionStateApproved DecisionState = "approved"
+	// DecisionStateRejected for decisions the user turned down
+	DecisionStateRejected DecisionState = "rejected"
+	// DecisionStateImplemented for decisions being carried out, whose outcome is not known yet
+	DecisionStateImplemented DecisionState = "implemented"
+	// DecisionStateAborted for decisions stopped partway because the action turned out harmful
+	DecisionStateAborted DecisionState = "aborted"
+	// DecisionStateClosed for implemented decisions whose outcome was recorded
+	DecisionStateClosed DecisionState = "closed"
+)
+
+// decisionTransitions lists the states each state can move to. Rejected,
+// aborted and closed decisions are final.
+var decisionTransitions = map[DecisionState][]DecisionState{
+	DecisionStateAwaitingApproval: {DecisionStateApproved, DecisionStateRejected},
+	DecisionStateApproved:         {DecisionStateImplemented},
+	DecisionStateImplemented:      {DecisionStateClosed, DecisionStateAborted},
+}
+
+// ErrInvalidDecisionTransition is returned when a lifecycle call comes out of
+// order, e.g. recording the outcome of a decision that was never implemented.
+var ErrInvalidDecisionTransition = errs.New(errs.Conflict, "invalid decision transition")
+
+// abortConfidenceBoost is how much more confidence the constraint learned from
+// an abort carries than feedback from a negative outcome: an abort means the
+// harm was seen while it happened, not judged afterwards.
+const abortConfidenceBoost = 0.15
+
+// RemediationPlan says what to do if a decision's implementation has to be
+// aborted. It is proposed at evaluation time for high-urgency actions.
+type RemediationPlan struct {
+	// Description explains how to undo or contain the action
+	Description string
+
+	// ObjectiveID links the objective that carries out the remediation, once
+	// one is created or if one was linked up front
+	ObjectiveID string
+}
+
+// CanTransitionTo reports whether a decision in this state may move to next.
+func (s DecisionState) CanTransitionTo(next DecisionState) bool {
+	for _, allowed := range decisionTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStates returns the states a decision in this state may move to.
+func (s DecisionState) NextStates() []DecisionState {
+	return decisionTransitions[s]
+}
+
+func (s DecisionState) String() string {
+	return string(s)
+}
+
+// State returns where the decision is in its lifecycle.
+func (ed *EthicalDecision) State() DecisionState {
+	switch {
+	case ed.AbortedAt != nil:
+		return DecisionStateAborted
+	case ed.Outcome != "" && ed.Outcome != DecisionOutcomeUnknown:
+		return DecisionStateClosed
+	case ed.ImplementedAt != nil:
+		return DecisionStateImplemented
+	}
+
+	switch ed.ApprovalStatus {
+	case DecisionApprovalPending:
+		return DecisionStateAwaitingApproval
+	case DecisionApprovalRejected:
+		return DecisionStateRejected
+	default:
+		return DecisionStateApproved
+	}
+}
+
+// IsAborted returns true if the decision's implementation was aborted.
+func (ed *EthicalDecision) IsAborted() bool {
+	return ed.AbortedAt != nil
+}
+
+// CanAbort returns true while the decision is implemented and no outcome has
+// been recorded, the only window in which it can be aborted.
+func (ed *EthicalDecision) CanAbort() bool {
+	return ed.State().CanTransitionTo(DecisionStateAborted)
+}
+
+// checkTransition returns an error matching ErrInvalidDecisionTransition
+// unless the decision may move to next.
+func (ed *EthicalDecision) checkTransition(next DecisionState) error {
+	current := ed.State()
+	if !current.CanTransitionTo(next) {
+		return fmt.Errorf("%w: decision %s is %s and cannot become %s", ErrInvalidDecisionTransition, ed.ID, current, next)
+	}
+	return nil
+}
+
+// AbortImplementation stops a decision whose implementation went wrong
+// partway. It is valid only after the decision was implemented and before an
+// outcome was recorded. The abort records a negative outcome and teaches the
+// user context a constraint weighted above ordinary negative feedback. If the
+// decision carries a remediation plan, CreateRemediationObjective turns it
+// into an objective.
+func (ef *EthicalFramework) AbortImplementation(ctx context.Context, decisionID, reason string) (*EthicalDecision, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("an abort needs a reason")
+	}
+
+	decision, err := ef.GetDecision(ctx, decisionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get decision for abort: %w", err)
+	}
+	if err := decision.checkTransition(DecisionStateAborted); err != nil {
+		return nil, err
+	}
+
+	now := ef.clock.Now()
+	decision.AbortedAt = &now
+	decision.AbortReason = reason
+	decision.Outcome = DecisionOutcomeNegative
+	decision.UserFeedback = reason
+
+	if err := ef.learnFromAbort(ctx, decision); err != nil {
+		// Log error but don't fail - the abort itself matters more
+		fmt.Printf("Warning: failed to learn from abort: %v\n", err)
+	}
+
+	if err := ef.closeDecision(ctx, decision); err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+// SetRemediationPlan attaches a remediation plan to a decision that has not
+// been implemented yet, replacing any plan proposed at evaluation time.
+func (ef *EthicalFramework) SetRemediationPlan(ctx context.Context, decisionID string, plan RemediationPlan) error {
+	if plan.Description == "" && plan.ObjectiveID == "" {
+		return fmt.Errorf("a remediation plan needs a description or an objective")
+	}
+
+	decision, err := ef.GetDecision(ctx, decisionID)
+	if err != nil {
+		return fmt.Errorf("failed to get decision for remediation plan: %w", err)
+	}
+	if state := decision.State(); state != DecisionStateAwaitingApproval && state != DecisionStateApproved {
+		return fmt.Errorf("%w: decision %s is %s, remediation must be planned before implementation", ErrInvalidDecisionTransition, decisionID, state)
+	}
+
+	decision.RemediationPlan = &plan
+	return ef.updateDecisionInStorage(ctx, decision)
+}
+
+// CreateRemediationObjective turns an aborted decision's remediation plan
+// into an objective under the same goal as the decision's objective, with a
+// draft one-step method the learning loop can refine. A plan that already
+// links an objective returns that objective.
+func (ef *EthicalFramework) CreateRemediationObjective(ctx context.Context, decisionID string) (*Objective, error) {
+	decision, err := ef.GetDecision(ctx, decisionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get decision for remediation: %w", err)
+	}
+	if !decision.IsAborted() {
+		return nil, fmt.Errorf("decision %s was not aborted (state: %s)", decisionID, decision.State())
+	}
+	plan := decision.RemediationPlan
+	if plan == nil {
+		return nil, fmt.Errorf("decision %s has no remediation plan", decisionID)
+	}
+
+	om := NewObjectiveManager(ef.store)
+	if plan.ObjectiveID != "" {
+		return om.GetObjective(ctx, plan.ObjectiveID)
+	}
+
+	source, err := om.GetObjective(ctx, decision.ObjectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get objective %s for remediation: %w", decision.ObjectiveID, err)
+	}
+
+	title := "Remediate: " + decision.ProposedAction
+	method, err := NewMethodManager(ef.store).CreateMethod(ctx, title, "Draft method for: "+title,
+		[]ApproachStep{{Description: plan.Description}},
+		MethodDomainUser, map[string]interface{}{"draft": true, "source": "decision_remediation"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remediation method: %w", err)
+	}
+
+	description := fmt.Sprintf("%s\n\nAborted because: %s", plan.Description, decision.AbortReason)
+	objective, err := om.CreateObjective(ctx, source.GoalID, method.ID, title, description,
+		map[string]interface{}{"source": "decision_remediation", "decision_id": decision.ID}, 10)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remediation objective: %w", err)
+	}
+
+	plan.ObjectiveID = objective.ID
+	if err := ef.updateDecisionInStorage(ctx, decision); err != nil {
+		return nil, fmt.Errorf("failed to link remediation objective: %w", err)
+	}
+	return objective, nil
+}
+
+// ListDecisions returns the decisions in any of the given states, newest
+// first. Without states it returns every decision.
+func (ef *EthicalFramework) ListDecisions(ctx context.Context, states ...DecisionState) ([]*EthicalDecision, error) {
+	nodes, err := ef.store.GetNodesByType(ctx, "ethical_decision")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query decisions: %w", err)
+	}
+
+	var decisions []*EthicalDecision
+	for _, node := range nodes {
+		decision, err := ef.nodeToEthicalDecision(node)
+		if err != nil {
+			continue // Skip malformed decisions
+		}
+		if len(states) == 0 || decisionStateIn(decision.State(), states) {
+			decisions = append(decisions, decision)
+		}
+	}
+
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].CreatedAt.After(decisions[j].CreatedAt)
+	})
+	return decisions, nil
+}
+
+func decisionStateIn(state DecisionState, states []DecisionState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// closeDecision stores a decision whose outcome was just settled. A rule that
+// let a harmful decision through needs the user to look at it again.
+func (ef *EthicalFramework) closeDecision(ctx context.Context, decision *EthicalDecision) error {
+	if err := ef.updateDecisionInStorage(ctx, decision); err != nil {
+		return err
+	}
+
+	if decision.Outcome == DecisionOutcomeNegative && decision.ApprovedByRule != "" {
+		reason := fmt.Sprintf("auto-approved decision %s had a negative outcome", decision.ID)
+		if decision.IsAborted() {
+			reason = fmt.Sprintf("auto-approved decision %s was aborted: %s", decision.ID, decision.AbortReason)
+		}
+		if err := ef.rules.SuspendRule(ctx, decision.ApprovedByRule, reason); err != nil {
+			return fmt.Errorf("failed to suspend approval rule %s: %w", decision.ApprovedByRule, err)
+		}
+	}
+	return nil
+}
+
+// learnFromAbort records the abort as a user constraint, with more
+// confidence than learnFromOutcome gives a negative outcome.
+func (ef *EthicalFramework) learnFromAbort(ctx context.Context, decision *EthicalDecision) error {
+	if ef.contextManager == nil {
+		return nil
+	}
+
+	content := fmt.Sprintf("Aborted action: %s. Reason: %s. Context: %s",
+		decision.ProposedAction, decision.AbortReason, decision.DecisionContext)
+	learned, err := ef.contextManager.LearnContext(ctx, ContextCategoryConstraints, content,
+		ContextSourceFeedback, []string{"ethical_decision", "abort", "constraint"}, decision.UserID)
+	if err != nil {
+		return err
+	}
+	return ef.contextManager.ValidateContext(ctx, learned.ID, abortConfidenceBoost)
+}
+
+// proposeRemediation keeps the remediation the evaluation proposed, for
+// high-urgency actions only.
+func proposeRemediation(impact *EthicalImpact, urgency DecisionUrgency) *RemediationPlan {
+	description := strings.TrimSpace(impact.Remediation)
+	if urgency < DecisionUrgencyHigh || description == "" || strings.EqualFold(strings.Trim(description, "[]. "), "none") {
+		return nil
+	}
+	return &RemediationPlan{Description: description}
+}
+
+// lifecycleToData adds a decision's abort and remediation plan to its node data.
+func lifecycleToData(decision *EthicalDecision, data map[string]interface{}) {
+	if decision.AbortedAt != nil {
+		data["aborted_at"] = decision.AbortedAt.Format(time.RFC3339)
+		data["abort_reason"] = decision.AbortReason
+	}
+	if plan := decision.RemediationPlan; plan != nil {
+		data["remediation_description"] = plan.Description
+		data["remediation_objective_id"] = plan.ObjectiveID
+	}
+}
+
+// lifecycleFromData reads a decision's abort and remediation plan, if any.
+func lifecycleFromData(decision *EthicalDecision, data map[string]interface{}) {
+	if abortedAtStr := getString(data, "aborted_at"); abortedAtStr != "" {
+		if t, err := time.Parse(time.RFC3339, abortedAtStr); err == nil {
+			decision.AbortedAt = &t
+		}
+		decision.AbortReason = getString(data, "abort_reason")
+	}
+
+	description := getString(data, "remediation_description")
+	objectiveID := getString(data, "remediation_objective_id")
+	if description != "" || objectiveID != "" {
+		decision.RemediationPlan = &RemediationPlan{Description: description, ObjectiveID: objectiveID}
+	}
+}
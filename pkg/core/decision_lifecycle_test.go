@@ -0,0 +1,295 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+// storeApprovedDecision records a decision that needs no approval, the way
+// EvaluateDecision does.
+func storeApprovedDecision(t *testing.T, ef *EthicalFramework, objectiveID, action string) *EthicalDecision {
+	t.Helper()
+	decision := &EthicalDecision{
+		ObjectiveID:     objectiveID,
+		DecisionContext: "Clean up the shared drive",
+		ProposedAction:  action,
+		Impact:          EthicalImpact{FreedomImpact: 0.5, WellBeingImpact: 0.5, SustainabilityImpact: 0.5, ConfidenceScore: 0.9},
+		ApprovalStatus:  DecisionApprovalNotRequired,
+		Outcome:         DecisionOutcomeUnknown,
+		CreatedAt:       time.Now(),
+		UserID:          "user-1",
+	}
+	if err := ef.storeDecision(context.Background(), decision); err != nil {
+		t.Fatalf("Failed to store decision: %v", err)
+	}
+	return decision
+}
+
+func TestDecisionLifecycle_RejectsOutOfOrderCalls(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+	ef := NewEthicalFramework(store, nil, NewUserContextManager(store))
+
+	decision := storeApprovedDecision(t, ef, "obj-1", "move old files to the archive folder")
+	if state := decision.State(); state != DecisionStateApproved {
+		t.Fatalf("Expected a decision needing no approval to start approved, got %s", state)
+	}
+
+	outOfOrder := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, ErrInvalidDecisionTransition) {
+			t.Errorf("%s: expected ErrInvalidDecisionTransition, got %v", name, err)
+		}
+	}
+
+	// Nothing can be settled before the decision is implemented
+	outOfOrder("outcome before implementation", ef.RecordOutcome(ctx, decision.ID, DecisionOutcomePositive, ""))
+	_, err := ef.AbortImplementation(ctx, decision.ID, "too early")
+	outOfOrder("abort before implementation", err)
+	outOfOrder("approve without pending approval", ef.ApproveDecision(ctx, decision.ID, ""))
+
+	if err := ef.ImplementDecision(ctx, decision.ID); err != nil {
+		t.Fatalf("ImplementDecision failed: %v", err)
+	}
+	outOfOrder("implement twice", ef.ImplementDecision(ctx, decision.ID))
+	outOfOrder("plan remediation after implementation", ef.SetRemediationPlan(ctx, decision.ID, RemediationPlan{Description: "restore"}))
+	if _, err := ef.AbortImplementation(ctx, decision.ID, "  "); err == nil {
+		t.Error("Expected an abort without a reason to be rejected")
+	}
+
+	implemented, _ := ef.GetDecision(ctx, decision.ID)
+	if implemented.State() != DecisionStateImplemented || !implemented.CanAbort() {
+		t.Fatalf("Expected an abortable implemented decision, got %s", implemented.State())
+	}
+
+	aborted, err := ef.AbortImplementation(ctx, decision.ID, "it was moving files still in use")
+	if err != nil {
+		t.Fatalf("AbortImplementation failed: %v", err)
+	}
+	if aborted.State() != DecisionStateAborted || aborted.Outcome != DecisionOutcomeNegative || aborted.CanAbort() {
+		t.Errorf("Expected an aborted decision with a negative outcome, got %s/%s", aborted.State(), aborted.Outcome)
+	}
+
+	stored, err := ef.GetDecision(ctx, decision.ID)
+	if err != nil {
+		t.Fatalf("GetDecision failed: %v", err)
+	}
+	if stored.AbortedAt == nil || stored.AbortReason != "it was moving files still in use" || stored.Outcome != DecisionOutcomeNegative {
+		t.Errorf("Expected the abort to be persisted, got %+v", stored)
+	}
+
+	// Aborted decisions are final
+	outOfOrder("outcome after abort", ef.RecordOutcome(ctx, decision.ID, DecisionOutcomePositive, ""))
+	_, err = ef.AbortImplementation(ctx, decision.ID, "again")
+	outOfOrder("abort twice", err)
+
+	// Once an outcome is recorded the abort window has closed
+	closed := storeApprovedDecision(t, ef, "obj-1", "rename the archive folder")
+	if err := ef.ImplementDecision(ctx, closed.ID); err != nil {
+		t.Fatalf("ImplementDecision failed: %v", err)
+	}
+	if err := ef.RecordOutcome(ctx, closed.ID, DecisionOutcomeNeutral, ""); err != nil {
+		t.Fatalf("RecordOutcome failed: %v", err)
+	}
+	_, err = ef.AbortImplementation(ctx, closed.ID, "too late")
+	outOfOrder("abort after outcome", err)
+
+	implementedOnly, err := ef.ListDecisions(ctx, DecisionStateImplemented)
+	if err != nil || len(implementedOnly) != 0 {
+		t.Errorf("Expected no decisions left implemented, got %d, %v", len(implementedOnly), err)
+	}
+}
+
+func TestDecisionLifecycle_AbortOutweighsNegativeOutcome(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+	contexts := NewUserContextManager(store)
+	ef := NewEthicalFramework(store, nil, contexts)
+
+	negative := storeApprovedDecision(t, ef, "obj-1", "email the weekly report to the team")
+	if err := ef.ImplementDecision(ctx, negative.ID); err != nil {
+		t.Fatalf("ImplementDecision failed: %v", err)
+	}
+	if err := ef.RecordOutcome(ctx, negative.ID, DecisionOutcomeNegative, "don't email reports without review"); err != nil {
+		t.Fatalf("RecordOutcome failed: %v", err)
+	}
+
+	aborted := storeApprovedDecision(t, ef, "obj-1", "email the draft budget to the team")
+	if err := ef.ImplementDecision(ctx, aborted.ID); err != nil {
+		t.Fatalf("ImplementDecision failed: %v", err)
+	}
+	if _, err := ef.AbortImplementation(ctx, aborted.ID, "the draft had salary data in it"); err != nil {
+		t.Fatalf("AbortImplementation failed: %v", err)
+	}
+
+	constraints, err := contexts.GetContextByCategory(ctx, ContextCategoryConstraints, "user-1")
+	if err != nil {
+		t.Fatalf("GetContextByCategory failed: %v", err)
+	}
+	var fromOutcome, fromAbort *UserContext
+	for _, learned := range constraints {
+		switch {
+		case strings.Contains(learned.Content, "salary data"):
+			fromAbort = learned
+		case strings.Contains(learned.Content, "without review"):
+			fromOutcome = learned
+		}
+	}
+	if fromOutcome == nil || fromAbort == nil {
+		t.Fatalf("Expected a constraint from each decision, got %d constraints", len(constraints))
+	}
+	if fromAbort.Confidence <= fromOutcome.Confidence {
+		t.Errorf("Expected the abort to weigh more than the negative outcome, got %.2f vs %.2f",
+			fromAbort.Confidence, fromOutcome.Confidence)
+	}
+}
+
+func TestDecisionLifecycle_RemediationObjective(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Keep the shared drive tidy", "", 5, nil)
+	if err != nil {
+		t.Fatalf("CreateGoal failed: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Bulk cleanup", "Remove stale files",
+		[]ApproachStep{{Description: "Delete files older than a year"}}, MethodDomainUser, nil)
+	if err != nil {
+		t.Fatalf("CreateMethod failed: %v", err)
+	}
+	objective, err := NewObjectiveManager(store).CreateObjective(ctx, goal.ID, method.ID, "Clean up the shared drive", "", nil, 5)
+	if err != nil {
+		t.Fatalf("CreateObjective failed: %v", err)
+	}
+
+	service := &scriptedClassifierService{}
+	service.script(
+		`{"freedom_impact": -0.8, "well_being_impact": -0.5, "sustainability_impact": 0.2, "confidence": 0.9, `+
+			`"remediation": "Restore the deleted files from last night's backup", "reasoning": "Deleting shared files removes the team's control over them."}`,
+		`{"freedom_impact": 0.4, "well_being_impact": 0.4, "sustainability_impact": 0.4, "confidence": 0.9, `+
+			`"remediation": "Restore the old names", "reasoning": "Renaming is easy to undo."}`,
+	)
+	ef := NewEthicalFramework(store, llm.NewRouter(service), NewUserContextManager(store))
+
+	decision, err := ef.EvaluateDecision(ctx, objective.ID, "Clean up the shared drive", "delete files older than a year", nil, "user-1")
+	if err != nil {
+		t.Fatalf("EvaluateDecision failed: %v", err)
+	}
+	if decision.Urgency < DecisionUrgencyHigh || decision.RemediationPlan == nil ||
+		decision.RemediationPlan.Description != "Restore the deleted files from last night's backup" {
+		t.Fatalf("Expected a remediation plan on a high-urgency decision, got %s with %+v", decision.Urgency, decision.RemediationPlan)
+	}
+
+	// Low-urgency decisions do not keep a remediation plan
+	routine, err := ef.EvaluateDecision(ctx, objective.ID, "Clean up the shared drive", "rename the archive folder", nil, "user-1")
+	if err != nil {
+		t.Fatalf("EvaluateDecision failed: %v", err)
+	}
+	if routine.Urgency >= DecisionUrgencyHigh || routine.RemediationPlan != nil {
+		t.Errorf("Expected no remediation plan on a %s decision, got %+v", routine.Urgency, routine.RemediationPlan)
+	}
+
+	if _, err := ef.CreateRemediationObjective(ctx, decision.ID); err == nil {
+		t.Error("Expected remediation to need an aborted decision")
+	}
+
+	if decision.IsPendingApproval() {
+		if err := ef.ApproveDecision(ctx, decision.ID, "go ahead"); err != nil {
+			t.Fatalf("ApproveDecision failed: %v", err)
+		}
+	}
+	if err := ef.ImplementDecision(ctx, decision.ID); err != nil {
+		t.Fatalf("ImplementDecision failed: %v", err)
+	}
+	if _, err := ef.AbortImplementation(ctx, decision.ID, "it deleted files still in use"); err != nil {
+		t.Fatalf("AbortImplementation failed: %v", err)
+	}
+
+	remediation, err := ef.CreateRemediationObjective(ctx, decision.ID)
+	if err != nil {
+		t.Fatalf("CreateRemediationObjective failed: %v", err)
+	}
+	if remediation.GoalID != goal.ID || remediation.Priority != 10 || remediation.ID == objective.ID {
+		t.Errorf("Expected an urgent objective under the same goal, got %+v", remediation)
+	}
+	if !strings.Contains(remediation.Description, "last night's backup") || !strings.Contains(remediation.Description, "still in use") {
+		t.Errorf("Expected the plan and abort reason in the description, got %q", remediation.Description)
+	}
+
+	stored, _ := ef.GetDecision(ctx, decision.ID)
+	if stored.RemediationPlan == nil || stored.RemediationPlan.ObjectiveID != remediation.ID {
+		t.Errorf("Expected the remediation objective to be linked, got %+v", stored.RemediationPlan)
+	}
+
+	again, err := ef.CreateRemediationObjective(ctx, decision.ID)
+	if err != nil || again.ID != remediation.ID {
+		t.Errorf("Expected the linked objective to be reused, got %v, %v", again, err)
+	}
+}
+
+func TestListPendingDecisions_OrdersByUrgencyThenAge(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+	ef := NewEthicalFramework(store, nil, NewUserContextManager(store))
+
+	base := time.Now().Add(-time.Hour)
+	pending := func(action, userID string, urgency DecisionUrgency, age time.Duration) *EthicalDecision {
+		t.Helper()
+		decision := &EthicalDecision{
+			ObjectiveID:     "obj-1",
+			DecisionContext: "Tidy the project folder",
+			ProposedAction:  action,
+			Urgency:         urgency,
+			ApprovalStatus:  DecisionApprovalPending,
+			Outcome:         DecisionOutcomeUnknown,
+			CreatedAt:       base.Add(-age),
+			UserID:          userID,
+		}
+		if err := ef.storeDecision(ctx, decision); err != nil {
+			t.Fatalf("Failed to store decision: %v", err)
+		}
+		return decision
+	}
+
+	newerHigh := pending("delete build outputs", "user-1", DecisionUrgencyHigh, 0)
+	low := pending("rename the folder", "user-1", DecisionUrgencyLow, 30*time.Minute)
+	olderHigh := pending("delete old logs", "user-1", DecisionUrgencyHigh, 10*time.Minute)
+	critical := pending("stop the deploy", "user-1", DecisionUrgencyCritical, 0)
+	other := pending("email the team", "user-2", DecisionUrgencyCritical, 0)
+	storeApprovedDecision(t, ef, "obj-1", "move old files to the archive folder")
+
+	decisions, err := ef.ListPendingDecisions(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListPendingDecisions failed: %v", err)
+	}
+	want := []string{critical.ID, olderHigh.ID, newerHigh.ID, low.ID}
+	if len(decisions) != len(want) {
+		t.Fatalf("Expected %d pending decisions, got %d", len(want), len(decisions))
+	}
+	for i, id := range want {
+		if decisions[i].ID != id {
+			t.Errorf("Position %d: expected %s, got %s (%s)", i, id, decisions[i].ID, decisions[i].ProposedAction)
+		}
+	}
+
+	// Approved decisions leave the queue
+	if err := ef.ApproveDecision(ctx, critical.ID, "go ahead"); err != nil {
+		t.Fatalf("ApproveDecision failed: %v", err)
+	}
+	decisions, err = ef.ListPendingDecisions(ctx, "")
+	if err != nil {
+		t.Fatalf("ListPendingDecisions failed: %v", err)
+	}
+	if len(decisions) != 4 || decisions[0].ID != other.ID {
+		t.Errorf("Expected every user's remaining decisions with user-2's critical one first, got %d", len(decisions))
+	}
+}
@@ -0,0 +1,414 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// EstimateCalibrationConfig configures how a method's token estimates are
+// corrected from its execution history.
+type EstimateCalibrationConfig struct {
+	// MinSamples is how many recorded executions a method needs before its
+	// estimates are adjusted
+	MinSamples int
+
+	// MaxFactor bounds the adjustment: estimates are scaled up by at most this
+	// factor, or down by at most its inverse. A factor of 1 disables calibration.
+	MaxFactor float64
+
+	// RecentRuns is how many of the most recent executions the adjustment is
+	// drawn from, so it follows a method whose cost changes over time
+	RecentRuns int
+
+	// WindowSize is how many executions each accuracy window covers
+	WindowSize int
+}
+
+// DefaultEstimateCalibrationConfig returns the default calibration settings.
+func DefaultEstimateCalibrationConfig() EstimateCalibrationConfig {
+	return EstimateCalibrationConfig{
+		MinSamples: 5,
+		MaxFactor:  2.0,
+		RecentRuns: 20,
+		WindowSize: 5,
+	}
+}
+
+// withDefaults fills unset fields from the defaults.
+func (c EstimateCalibrationConfig) withDefaults() EstimateCalibrationConfig {
+	defaults := DefaultEstimateCalibrationConfig()
+	if c.MinSamples <= 0 {
+		c.MinSamples = defaults.MinSamples
+	}
+	if c.MaxFactor <= 0 {
+		c.MaxFactor = defaults.MaxFactor
+	}
+	if c.MaxFactor < 1 {
+		c.MaxFactor = 1 / c.MaxFactor
+	}
+	if c.RecentRuns <= 0 {
+		c.RecentRuns = defaults.RecentRuns
+	}
+	if c.WindowSize <= 0 {
+		c.WindowSize = defaults.WindowSize
+	}
+	return c
+}
+
+// estimateCalibrationConfig returns the first config given, with defaults filled in.
+func estimateCalibrationConfig(config []EstimateCalibrationConfig) EstimateCalibrationConfig {
+	if len(config) > 0 {
+		return config[0].withDefaults()
+	}
+	return DefaultEstimateCalibrationConfig()
+}
+
+// EstimateRecord is one execution's token and cost estimate next to what it
+// actually used.
+type EstimateRecord struct {
+	ID          string
+	MethodID    string
+	ObjectiveID string
+	PlanID      string
+	ExecutionID string
+
+	// RawTokens is the plan's estimate before calibration
+	RawTokens int
+
+	// EstimatedTokens is the estimate the plan went ahead with
+	EstimatedTokens int
+
+	ActualTokens int
+
+	// EstimatedCost and ActualCost price the estimate and the actual use
+	// (zero when no token price is known)
+	EstimatedCost float64
+	ActualCost    float64
+
+	// CalibrationFactor is the adjustment that turned RawTokens into
+	// EstimatedTokens (1 when none was applied)
+	CalibrationFactor float64
+
+	RecordedAt time.Time
+}
+
+// Ratio returns actual use over the uncalibrated estimate, the bias that
+// calibration corrects.
+func (r *EstimateRecord) Ratio() float64 {
+	if r.RawTokens <= 0 {
+		return 0
+	}
+	return float64(r.ActualTokens) / float64(r.RawTokens)
+}
+
+// PercentError returns how far the estimate the plan went ahead with was
+// from actual use, as a percentage of actual use.
+func (r *EstimateRecord) PercentError() float64 {
+	if r.ActualTokens <= 0 {
+		return 0
+	}
+	return math.Abs(float64(r.ActualTokens-r.EstimatedTokens)) / float64(r.ActualTokens) * 100
+}
+
+// EstimateWindow summarizes the estimate accuracy of consecutive executions.
+type EstimateWindow struct {
+	Start      time.Time
+	End        time.Time
+	Executions int
+
+	// MeanAbsolutePercentError is the average PercentError of the window
+	MeanAbsolutePercentError float64
+
+	// MeanRatio is the geometric mean of actual over uncalibrated estimates
+	MeanRatio float64
+}
+
+// EstimateCalibration is the adjustment applied to a method's next estimate.
+type EstimateCalibration struct {
+	// Factor scales the raw estimate (1 when not applied)
+	Factor float64
+
+	// Bias is the observed ratio of actual use to raw estimates, before bounding
+	Bias float64
+
+	// Samples is how many recent executions Bias is drawn from
+	Samples int
+
+	// MinSamples is how many executions calibration needs
+	MinSamples int
+
+	// Applied is set when the history was long enough to adjust estimates
+	Applied bool
+
+	// Capped is set when Bias was beyond the configured bound
+	Capped bool
+}
+
+// Adjust scales a raw token estimate by the calibration factor.
+func (c EstimateCalibration) Adjust(tokens int) int {
+	if !c.Applied {
+		return tokens
+	}
+	return int(math.Round(float64(tokens) * c.Factor))
+}
+
+// Description explains the calibration, e.g. "estimate adjusted +40% based
+// on 12 prior runs".
+func (c EstimateCalibration) Description() string {
+	if !c.Applied {
+		if c.Samples < c.MinSamples {
+			return fmt.Sprintf("estimate not adjusted: %d prior %s, %d needed", c.Samples, pluralRuns(c.Samples), c.MinSamples)
+		}
+		return "estimate not adjusted: calibration is disabled"
+	}
+
+	change := math.Round((c.Factor - 1) * 100)
+	if change == 0 {
+		return fmt.Sprintf("estimate unchanged; %d prior %s match it", c.Samples, pluralRuns(c.Samples))
+	}
+	description := fmt.Sprintf("estimate adjusted %+.0f%% based on %d prior %s", change, c.Samples, pluralRuns(c.Samples))
+	if c.Capped {
+		description += fmt.Sprintf(" (capped; runs used %.1fx the estimate)", c.Bias)
+	}
+	return description
+}
+
+func pluralRuns(n int) string {
+	if n == 1 {
+		return "run"
+	}
+	return "runs"
+}
+
+// EstimateAccuracy describes how well a method's estimates match its
+// executions over time, and the calibration its next estimate gets.
+type EstimateAccuracy struct {
+	MethodID string
+	Samples  int
+
+	// Windows groups the executions in order, oldest first
+	Windows []EstimateWindow
+
+	// MeanAbsolutePercentError covers every recorded execution
+	MeanAbsolutePercentError float64
+
+	Calibration EstimateCalibration
+}
+
+// Improving reports whether the latest window's error is below the first's.
+func (a *EstimateAccuracy) Improving() bool {
+	if len(a.Windows) < 2 {
+		return false
+	}
+	return a.Windows[len(a.Windows)-1].MeanAbsolutePercentError < a.Windows[0].MeanAbsolutePercentError
+}
+
+// RecordEstimate stores an execution's estimate next to its actual use.
+func (mm *MethodManager) RecordEstimate(ctx context.Context, record *EstimateRecord) error {
+	if record.MethodID == "" {
+		return fmt.Errorf("estimate record needs a method ID")
+	}
+	if record.RecordedAt.IsZero() {
+		record.RecordedAt = time.Now()
+	}
+	if record.CalibrationFactor == 0 {
+		record.CalibrationFactor = 1
+	}
+
+	node := storage.NewNode("estimate_record", map[string]interface{}{
+		"method_id":          record.MethodID,
+		"objective_id":       record.ObjectiveID,
+		"plan_id":            record.PlanID,
+		"execution_id":       record.ExecutionID,
+		"raw_tokens":         record.RawTokens,
+		"estimated_tokens":   record.EstimatedTokens,
+		"actual_tokens":      record.ActualTokens,
+		"estimated_cost":     record.EstimatedCost,
+		"actual_cost":        record.ActualCost,
+		"calibration_factor": record.CalibrationFactor,
+		"recorded_at":        record.RecordedAt.Format(time.RFC3339Nano),
+	})
+	if err := mm.store.AddNode(ctx, node); err != nil {
+		return fmt.Errorf("failed to store estimate record: %w", err)
+	}
+	record.ID = node.ID
+	return nil
+}
+
+// GetEstimateRecords returns a method's estimate records, oldest first.
+func (mm *MethodManager) GetEstimateRecords(ctx context.Context, methodID string) ([]*EstimateRecord, error) {
+	nodes, err := mm.store.Nodes().OfType("estimate_record").WithData("method_id", methodID).AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query estimate records: %w", err)
+	}
+	return estimateRecordsFromNodes(nodes), nil
+}
+
+// GetEstimateAccuracy returns the error distribution of a method's
+// estimates over time and the calibration for its next estimate.
+func (mm *MethodManager) GetEstimateAccuracy(ctx context.Context, methodID string, config ...EstimateCalibrationConfig) (*EstimateAccuracy, error) {
+	records, err := mm.GetEstimateRecords(ctx, methodID)
+	if err != nil {
+		return nil, err
+	}
+	return estimateAccuracy(methodID, records, estimateCalibrationConfig(config)), nil
+}
+
+// GetEstimateCalibration returns the adjustment for a method's next estimate.
+func (mm *MethodManager) GetEstimateCalibration(ctx context.Context, methodID string, config ...EstimateCalibrationConfig) (EstimateCalibration, error) {
+	accuracy, err := mm.GetEstimateAccuracy(ctx, methodID, config...)
+	if err != nil {
+		return EstimateCalibration{Factor: 1}, err
+	}
+	return accuracy.Calibration, nil
+}
+
+// GetCalibrationSummary returns the estimate accuracy of every method with
+// recorded executions, most executions first.
+func (mm *MethodManager) GetCalibrationSummary(ctx context.Context, config ...EstimateCalibrationConfig) ([]*EstimateAccuracy, error) {
+	nodes, err := mm.store.Nodes().OfType("estimate_record").AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query estimate records: %w", err)
+	}
+
+	byMethod := make(map[string][]*EstimateRecord)
+	for _, record := range estimateRecordsFromNodes(nodes) {
+		byMethod[record.MethodID] = append(byMethod[record.MethodID], record)
+	}
+
+	cfg := estimateCalibrationConfig(config)
+	summary := make([]*EstimateAccuracy, 0, len(byMethod))
+	for methodID, records := range byMethod {
+		summary = append(summary, estimateAccuracy(methodID, records, cfg))
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Samples != summary[j].Samples {
+			return summary[i].Samples > summary[j].Samples
+		}
+		return summary[i].MethodID < summary[j].MethodID
+	})
+	return summary, nil
+}
+
+// estimateRecordsFromNodes converts nodes to records, oldest first, skipping
+// records without a usable estimate.
+func estimateRecordsFromNodes(nodes []*storage.Node) []*EstimateRecord {
+	records := make([]*EstimateRecord, 0, len(nodes))
+	for _, node := range nodes {
+		record := &EstimateRecord{
+			ID:                node.ID,
+			MethodID:          getString(node.Data, "method_id"),
+			ObjectiveID:       getString(node.Data, "objective_id"),
+			PlanID:            getString(node.Data, "plan_id"),
+			ExecutionID:       getString(node.Data, "execution_id"),
+			RawTokens:         int(getFloat64(node.Data, "raw_tokens")),
+			EstimatedTokens:   int(getFloat64(node.Data, "estimated_tokens")),
+			ActualTokens:      int(getFloat64(node.Data, "actual_tokens")),
+			EstimatedCost:     getFloat64(node.Data, "estimated_cost"),
+			ActualCost:        getFloat64(node.Data, "actual_cost"),
+			CalibrationFactor: getFloat64(node.Data, "calibration_factor"),
+			RecordedAt:        node.CreatedAt,
+		}
+		if recordedAt, err := time.Parse(time.RFC3339Nano, getString(node.Data, "recorded_at")); err == nil {
+			record.RecordedAt = recordedAt
+		}
+		if record.RawTokens <= 0 || record.ActualTokens <= 0 {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].RecordedAt.Before(records[j].RecordedAt)
+	})
+	return records
+}
+
+// estimateAccuracy computes the windows and calibration for ordered records.
+func estimateAccuracy(methodID string, records []*EstimateRecord, config EstimateCalibrationConfig) *EstimateAccuracy {
+	accuracy := &EstimateAccuracy{
+		MethodID: methodID,
+		Samples:  len(records),
+	}
+
+	totalError := 0.0
+	for start := 0; start < len(records); start += config.WindowSize {
+		end := start + config.WindowSize
+		if end > len(records) {
+			end = len(records)
+		}
+		window := records[start:end]
+
+		windowError := 0.0
+		for _, record := range window {
+			windowError += record.PercentError()
+		}
+		totalError += windowError
+
+		accuracy.Windows = append(accuracy.Windows, EstimateWindow{
+			Start:                    window[0].RecordedAt,
+			End:                      window[len(window)-1].RecordedAt,
+			Executions:               len(window),
+			MeanAbsolutePercentError: windowError / float64(len(window)),
+			MeanRatio:                geometricMeanRatio(window),
+		})
+	}
+	if len(records) > 0 {
+		accuracy.MeanAbsolutePercentError = totalError / float64(len(records))
+	}
+
+	accuracy.Calibration = calibrate(records, config)
+	return accuracy
+}
+
+// calibrate derives the factor for the next estimate from the most recent
+// executions: the geometric mean of their actual-to-estimate ratios, bounded
+// by MaxFactor either way.
+func calibrate(records []*EstimateRecord, config EstimateCalibrationConfig) EstimateCalibration {
+	recent := records
+	if len(recent) > config.RecentRuns {
+		recent = recent[len(recent)-config.RecentRuns:]
+	}
+
+	calibration := EstimateCalibration{
+		Factor:     1,
+		Samples:    len(recent),
+		MinSamples: config.MinSamples,
+	}
+	if len(recent) == 0 {
+		return calibration
+	}
+	calibration.Bias = geometricMeanRatio(recent)
+	if len(recent) < config.MinSamples || config.MaxFactor == 1 {
+		return calibration
+	}
+
+	calibration.Applied = true
+	calibration.Factor = calibration.Bias
+	if calibration.Factor > config.MaxFactor {
+		calibration.Factor = config.MaxFactor
+		calibration.Capped = true
+	} else if calibration.Factor < 1/config.MaxFactor {
+		calibration.Factor = 1 / config.MaxFactor
+		calibration.Capped = true
+	}
+	return calibration
+}
+
+// geometricMeanRatio averages actual-to-estimate ratios so that running at
+// half and at double the estimate cancel out.
+func geometricMeanRatio(records []*EstimateRecord) float64 {
+	if len(records) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, record := range records {
+		sum += math.Log(record.Ratio())
+	}
+	return math.Exp(sum / float64(len(records)))
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCalibratedHistory simulates executions of a method whose actual use is
+// bias times the raw estimate, with multiplicative noise. Each run plans with
+// the calibration the history so far gives, as the CC does.
+func runCalibratedHistory(t *testing.T, mm *MethodManager, methodID string, runs int, bias, noise float64, config EstimateCalibrationConfig) {
+	t.Helper()
+	ctx := context.Background()
+	random := rand.New(rand.NewSource(7))
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	for i := 0; i < runs; i++ {
+		calibration, err := mm.GetEstimateCalibration(ctx, methodID, config)
+		if err != nil {
+			t.Fatalf("GetEstimateCalibration failed: %v", err)
+		}
+
+		raw := 1000
+		actual := int(float64(raw) * bias * (1 + noise*(2*random.Float64()-1)))
+		err = mm.RecordEstimate(ctx, &EstimateRecord{
+			MethodID:          methodID,
+			RawTokens:         raw,
+			EstimatedTokens:   calibration.Adjust(raw),
+			ActualTokens:      actual,
+			CalibrationFactor: calibration.Factor,
+			RecordedAt:        start.Add(time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("RecordEstimate failed: %v", err)
+		}
+	}
+}
+
+func TestEstimateCalibrationConverges(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	mm := NewMethodManager(store)
+	ctx := context.Background()
+
+	config := DefaultEstimateCalibrationConfig()
+	runCalibratedHistory(t, mm, "method-slow", 30, 1.4, 0.1, config)
+
+	accuracy, err := mm.GetEstimateAccuracy(ctx, "method-slow", config)
+	if err != nil {
+		t.Fatalf("GetEstimateAccuracy failed: %v", err)
+	}
+	if accuracy.Samples != 30 || len(accuracy.Windows) != 6 {
+		t.Fatalf("Expected 30 samples in 6 windows, got %d in %d", accuracy.Samples, len(accuracy.Windows))
+	}
+
+	calibration := accuracy.Calibration
+	if !calibration.Applied || calibration.Capped {
+		t.Fatalf("Expected an uncapped calibration to be applied: %+v", calibration)
+	}
+	if math.Abs(calibration.Factor-1.4) > 0.05 {
+		t.Errorf("Expected the factor to converge on 1.4, got %.3f", calibration.Factor)
+	}
+
+	// The first window ran on raw estimates, the last on calibrated ones
+	first, last := accuracy.Windows[0], accuracy.Windows[len(accuracy.Windows)-1]
+	if first.MeanAbsolutePercentError < 20 {
+		t.Errorf("Expected uncalibrated estimates to be far off, got %.1f%%", first.MeanAbsolutePercentError)
+	}
+	if last.MeanAbsolutePercentError > 10 {
+		t.Errorf("Expected calibrated estimates within 10%%, got %.1f%%", last.MeanAbsolutePercentError)
+	}
+	if !accuracy.Improving() {
+		t.Error("Expected the accuracy to be improving")
+	}
+	if math.Abs(last.MeanRatio-1.4) > 0.1 {
+		t.Errorf("Expected the window ratio to stay near 1.4, got %.3f", last.MeanRatio)
+	}
+
+	description := calibration.Description()
+	if !strings.Contains(description, "estimate adjusted +4") || !strings.Contains(description, "based on 20 prior runs") {
+		t.Errorf("Unexpected description %q", description)
+	}
+}
+
+func TestEstimateCalibrationRespectsBounds(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	mm := NewMethodManager(store)
+	ctx := context.Background()
+
+	config := EstimateCalibrationConfig{MaxFactor: 1.5}
+	runCalibratedHistory(t, mm, "method-expensive", 10, 3.0, 0, config)
+	runCalibratedHistory(t, mm, "method-cheap", 10, 0.2, 0, config)
+
+	expensive, err := mm.GetEstimateCalibration(ctx, "method-expensive", config)
+	if err != nil {
+		t.Fatalf("GetEstimateCalibration failed: %v", err)
+	}
+	if expensive.Factor != 1.5 || !expensive.Capped || math.Abs(expensive.Bias-3.0) > 0.01 {
+		t.Errorf("Expected the factor capped at 1.5 with a bias of 3, got %+v", expensive)
+	}
+	if got := expensive.Adjust(1000); got != 1500 {
+		t.Errorf("Expected 1000 tokens to become 1500, got %d", got)
+	}
+	if !strings.Contains(expensive.Description(), "capped") {
+		t.Errorf("Expected the description to mention the cap: %q", expensive.Description())
+	}
+
+	cheap, err := mm.GetEstimateCalibration(ctx, "method-cheap", config)
+	if err != nil {
+		t.Fatalf("GetEstimateCalibration failed: %v", err)
+	}
+	if math.Abs(cheap.Factor-1/1.5) > 1e-9 || !cheap.Capped {
+		t.Errorf("Expected the factor capped at 1/1.5, got %+v", cheap)
+	}
+
+	disabled, err := mm.GetEstimateCalibration(ctx, "method-expensive", EstimateCalibrationConfig{MaxFactor: 1})
+	if err != nil {
+		t.Fatalf("GetEstimateCalibration failed: %v", err)
+	}
+	if disabled.Applied || disabled.Adjust(1000) != 1000 {
+		t.Errorf("Expected a max factor of 1 to disable calibration, got %+v", disabled)
+	}
+}
+
+func TestEstimateCalibrationNeedsMinimumSamples(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	mm := NewMethodManager(store)
+	ctx := context.Background()
+
+	config := DefaultEstimateCalibrationConfig()
+	runCalibratedHistory(t, mm, "method-new", config.MinSamples-1, 1.4, 0, config)
+
+	calibration, err := mm.GetEstimateCalibration(ctx, "method-new", config)
+	if err != nil {
+		t.Fatalf("GetEstimateCalibration failed: %v", err)
+	}
+	if calibration.Applied || calibration.Factor != 1 || calibration.Adjust(1000) != 1000 {
+		t.Errorf("Expected no adjustment below the minimum sample count, got %+v", calibration)
+	}
+	if want := "estimate not adjusted: 4 prior runs, 5 needed"; calibration.Description() != want {
+		t.Errorf("Expected %q, got %q", want, calibration.Description())
+	}
+
+	summary, err := mm.GetCalibrationSummary(ctx)
+	if err != nil {
+		t.Fatalf("GetCalibrationSummary failed: %v", err)
+	}
+	if len(summary) != 1 || summary[0].MethodID != "method-new" || summary[0].Samples != 4 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+}
+
+func TestExecutePlanRecordsCalibratedEstimate(t *testing.T) {
+	rtc, store, _, _ := setupTestRTC(t)
+	ctx := context.Background()
+	mm := NewMethodManager(store)
+
+	// The mock executor uses 100 tokens per task against estimates of 100 and 150
+	plan := createTestPlan()
+	plan.Calibration = &EstimateCalibration{Factor: 0.8, Applied: true}
+	plan.TotalEstimatedTokens = 200
+	rtc.SetCostPerToken(0.001)
+
+	if _, err := rtc.ExecutePlan(ctx, plan); err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+
+	records, err := mm.GetEstimateRecords(ctx, plan.MethodID)
+	if err != nil {
+		t.Fatalf("GetEstimateRecords failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 estimate record, got %d", len(records))
+	}
+	record := records[0]
+	if record.RawTokens != 250 || record.EstimatedTokens != 200 || record.ActualTokens != 200 || record.CalibrationFactor != 0.8 {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if math.Abs(record.EstimatedCost-0.2) > 1e-9 || math.Abs(record.ActualCost-0.2) > 1e-9 {
+		t.Errorf("Expected both costs to be $0.20, got %.4f and %.4f", record.EstimatedCost, record.ActualCost)
+	}
+	if record.ExecutionID == "" || record.PercentError() != 0 {
+		t.Errorf("Expected a linked, exact record, got %+v", record)
+	}
+}
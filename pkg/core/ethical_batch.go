@@ -0,0 +1,397 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+const (
+	// DefaultDecisionBatchSize is the most decisions EvaluateDecisions puts
+	// to the LLM in one prompt by default
+	DefaultDecisionBatchSize = 5
+
+	// DefaultAssessmentReuseTTL is how long the assessment of a decision that
+	// turned out well is reused by default for the same decision
+	DefaultAssessmentReuseTTL = 24 * time.Hour
+
+	// reusedConfidenceFactor scales the confidence of a reused assessment,
+	// which was made for an earlier occurrence of the decision
+	reusedConfidenceFactor = 0.8
+)
+
+// DecisionInput is one decision to evaluate with EvaluateDecisions.
+type DecisionInput struct {
+	ObjectiveID     string
+	DecisionContext string
+	ProposedAction  string
+	Alternatives    []string
+	UserID          string
+}
+
+// validate checks the decision can be evaluated.
+func (in DecisionInput) validate() error {
+	if in.DecisionContext == "" {
+		return fmt.Errorf("decision context cannot be empty")
+	}
+	if in.ProposedAction == "" {
+		return fmt.Errorf("proposed action cannot be empty")
+	}
+	return nil
+}
+
+// decisionHash identifies a decision by its context and proposed action, so
+// a recurring decision can be recognized.
+func decisionHash(decisionContext, proposedAction string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(decisionContext) + "\x00" + strings.TrimSpace(proposedAction)))
+	return hex.EncodeToString(sum[:])
+}
+
+// EvaluationStats counts the ethical evaluations a framework made and the
+// LLM calls they took.
+type EvaluationStats struct {
+	// Decisions is the number of decisions evaluated
+	Decisions int
+
+	// LLMCalls is the number of ethical reasoning calls made, batched or not
+	LLMCalls int
+
+	// BatchCalls is the number of calls that assessed several decisions, and
+	// BatchedDecisions the number of decisions they assessed
+	BatchCalls       int
+	BatchedDecisions int
+
+	// BatchFallbacks is the number of decisions a batch did not assess
+	// validly, which were then evaluated on their own
+	BatchFallbacks int
+
+	// ReusedAssessments is the number of decisions that reused the assessment
+	// of an earlier one without calling the LLM
+	ReusedAssessments int
+
+	// PolicyDecisions is the number of decisions a policy rule made without
+	// calling the LLM
+	PolicyDecisions int
+}
+
+// CallsSaved returns the number of LLM calls saved compared to one call per
+// decision. Batches that had to fall back can make it negative.
+func (s EvaluationStats) CallsSaved() int {
+	return s.Decisions - s.LLMCalls
+}
+
+// Stats returns the evaluations the framework made so far.
+func (ef *EthicalFramework) Stats() EvaluationStats {
+	ef.statsMu.Lock()
+	defer ef.statsMu.Unlock()
+	return ef.stats
+}
+
+// countStats updates the framework's evaluation stats.
+func (ef *EthicalFramework) countStats(update func(stats *EvaluationStats)) {
+	ef.statsMu.Lock()
+	defer ef.statsMu.Unlock()
+	update(&ef.stats)
+}
+
+// EvaluateDecisions evaluates several decisions, putting up to the
+// configured batch size of them to the LLM in one prompt instead of one
+// call each. A decision the batch reply has no valid scores for is
+// evaluated on its own. The decisions are returned in the order of inputs;
+// one that could not be evaluated is nil, with its error joined into the
+// returned error.
+func (ef *EthicalFramework) EvaluateDecisions(ctx context.Context, inputs []DecisionInput) ([]*EthicalDecision, error) {
+	decisions := make([]*EthicalDecision, len(inputs))
+	failures := make([]error, len(inputs))
+	fail := func(i int, err error) {
+		failures[i] = fmt.Errorf("decision %d (%s): %w", i+1, inputs[i].ProposedAction, err)
+	}
+
+	// Decisions a policy rule decides skip reasoning, and decisions that
+	// recur reuse their assessment; the others are batched
+	var pending []pendingDecision
+	for i, input := range inputs {
+		if err := input.validate(); err != nil {
+			fail(i, err)
+			continue
+		}
+		decision, decided, err := ef.applyPolicy(ctx, input)
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		if decided {
+			decisions[i] = decision
+			continue
+		}
+		decision, reused, err := ef.reuseAssessment(ctx, input)
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		if reused {
+			decisions[i] = decision
+			continue
+		}
+		userContext, err := ef.contextManager.GetRelevantContext(ctx, input.DecisionContext+" "+input.ProposedAction, input.UserID, 5)
+		if err != nil {
+			fail(i, fmt.Errorf("failed to get user context: %w", err))
+			continue
+		}
+		pending = append(pending, pendingDecision{index: i, input: input, contextInfo: ef.buildContextInfo(userContext)})
+	}
+
+	for start := 0; start < len(pending); start += ef.batchSize {
+		end := start + ef.batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+
+		var impacts map[int]*EthicalImpact
+		var batchErr error
+		if len(batch) > 1 {
+			impacts, batchErr = ef.performBatchReasoning(ctx, batch)
+		}
+		for _, p := range batch {
+			var decision *EthicalDecision
+			var err error
+			switch impact, assessed := impacts[p.index]; {
+			case assessed:
+				decision, err = ef.recordDecision(ctx, p.input, impact, "")
+			case batchErr != nil && !isResponseFailure(batchErr):
+				// The provider failed the batch; asking again per decision
+				// would fail the same way
+				ef.countStats(func(stats *EvaluationStats) { stats.Decisions++ })
+				decision, err = ef.handleEvaluationFailure(ctx, p.input.ObjectiveID, p.input.DecisionContext, p.input.ProposedAction, p.input.Alternatives, p.input.UserID, batchErr)
+			default:
+				if len(batch) > 1 {
+					ef.countStats(func(stats *EvaluationStats) { stats.BatchFallbacks++ })
+				}
+				decision, err = ef.evaluateWithContext(ctx, p.input, p.contextInfo)
+			}
+			if err != nil {
+				fail(p.index, err)
+				continue
+			}
+			decisions[p.index] = decision
+		}
+	}
+
+	return decisions, errors.Join(failures...)
+}
+
+// pendingDecision is a decision awaiting ethical reasoning, with the summary
+// of the user's context relevant to it.
+type pendingDecision struct {
+	index       int
+	input       DecisionInput
+	contextInfo string
+}
+
+// isResponseFailure reports whether reasoning failed on the reply rather
+// than on the call.
+func isResponseFailure(err error) bool {
+	var evalErr *evaluationError
+	return errors.As(err, &evalErr) && evalErr.cause == EvaluationFailureResponse
+}
+
+// evaluateWithContext evaluates one decision with one LLM call and records
+// it, applying the failure policy if reasoning fails.
+func (ef *EthicalFramework) evaluateWithContext(ctx context.Context, input DecisionInput, contextInfo string) (*EthicalDecision, error) {
+	impact, err := ef.performEthicalReasoning(ctx, input.DecisionContext, input.ProposedAction, input.Alternatives, contextInfo)
+	if err != nil {
+		ef.countStats(func(stats *EvaluationStats) { stats.Decisions++ })
+		return ef.handleEvaluationFailure(ctx, input.ObjectiveID, input.DecisionContext, input.ProposedAction, input.Alternatives, input.UserID, err)
+	}
+	return ef.recordDecision(ctx, input, impact, "")
+}
+
+// reuseAssessment records the decision with the assessment of the latest
+// decision like it, the same context and action for the same user, that
+// was made within the reuse TTL and turned out positive. Its confidence is
+// reduced, as it was made for another occurrence of the decision.
+func (ef *EthicalFramework) reuseAssessment(ctx context.Context, input DecisionInput) (*EthicalDecision, bool, error) {
+	if ef.reuseTTL <= 0 {
+		return nil, false, nil
+	}
+
+	nodes, err := ef.store.Nodes().OfType("ethical_decision").
+		WithData("decision_hash", decisionHash(input.DecisionContext, input.ProposedAction)).
+		WithData("outcome", string(DecisionOutcomePositive)).
+		WithData("user_id", input.UserID).
+		AllContext(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query earlier decisions: %w", err)
+	}
+
+	cutoff := ef.clock.Now().Add(-ef.reuseTTL)
+	var prior *EthicalDecision
+	for _, node := range nodes {
+		decision, err := ef.nodeToEthicalDecision(node)
+		if err != nil || decision.EvaluationFailure != nil || decision.CreatedAt.Before(cutoff) {
+			continue
+		}
+		if prior == nil || decision.CreatedAt.After(prior.CreatedAt) {
+			prior = decision
+		}
+	}
+	if prior == nil {
+		return nil, false, nil
+	}
+
+	impact := prior.Impact
+	impact.ConfidenceScore *= reusedConfidenceFactor
+	impact.Reasoning = fmt.Sprintf("Reused from decision %s, which turned out positive: %s", prior.ID, prior.Impact.Reasoning)
+	ef.countStats(func(stats *EvaluationStats) { stats.ReusedAssessments++ })
+
+	decision, err := ef.recordDecision(ctx, input, &impact, prior.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	return decision, true, nil
+}
+
+// performBatchReasoning assesses several decisions with one LLM call. It
+// returns the assessments that passed validation, by decision index; a
+// decision with invalid scores is left out for evaluation on its own.
+func (ef *EthicalFramework) performBatchReasoning(ctx context.Context, batch []pendingDecision) (map[int]*EthicalImpact, error) {
+	if ef.llmRouter == nil {
+		return nil, &evaluationError{cause: EvaluationFailureNoRouter, err: fmt.Errorf("no LLM router configured")}
+	}
+
+	request := llm.TaskRequest{
+		Prompt:          ef.buildBatchEthicalPrompt(batch),
+		MaxTokens:       500 * len(batch),
+		Temperature:     0.3, // Lower temperature for consistent ethical reasoning
+		TaskType:        "ethical_analysis",
+		QualityRequired: llm.QualityPremium, // Ethical decisions require highest quality
+		ResponseSchema:  ethicalBatchSchema,
+	}
+
+	ef.countStats(func(stats *EvaluationStats) { stats.LLMCalls++ })
+	result, err := ef.llmRouter.Route(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("LLM routing failed: %w", err)
+	}
+	if result.ExecutionResult == nil {
+		return nil, &evaluationError{cause: EvaluationFailureResponse, err: fmt.Errorf("no result from LLM execution")}
+	}
+
+	_, value, err := ethicalBatchSchema.Parse(result.ExecutionResult.Text)
+	if err != nil {
+		return nil, &evaluationError{cause: EvaluationFailureResponse, err: fmt.Errorf("failed to parse batch ethical response: %w", err)}
+	}
+
+	// Each entry is checked on its own, so one bad score only costs that
+	// decision a call of its own
+	impacts := make(map[int]*EthicalImpact, len(batch))
+	entries, _ := value.(map[string]interface{})["decisions"].([]interface{})
+	for _, entry := range entries {
+		fields, _ := entry.(map[string]interface{})
+		number, _ := fields["decision"].(float64)
+		position := int(number) - 1
+		if position < 0 || position >= len(batch) || impacts[batch[position].index] != nil {
+			continue
+		}
+		text, err := json.Marshal(fields)
+		if err != nil {
+			continue
+		}
+		impact, err := ef.parseEthicalResponse(string(text))
+		if err != nil {
+			continue
+		}
+		impacts[batch[position].index] = impact
+	}
+
+	ef.countStats(func(stats *EvaluationStats) {
+		stats.BatchCalls++
+		stats.BatchedDecisions += len(impacts)
+	})
+	return impacts, nil
+}
+
+// buildBatchEthicalPrompt creates one prompt assessing several decisions,
+// numbered from 1.
+func (ef *EthicalFramework) buildBatchEthicalPrompt(batch []pendingDecision) string {
+	var prompt strings.Builder
+	prompt.WriteString(`You are an ethical reasoning system evaluating decisions based on the Prime Value of Mutual Freedom and Well-Being. Assess each of the numbered decisions below on its own, for its impact on:
+
+1. USER FREEDOM: The user's autonomy, choice, and control over their environment
+2. USER WELL-BEING: The user's health, happiness, productivity, and overall flourishing
+3. SYSTEM SUSTAINABILITY: The long-term viability and health of the AI system
+`)
+
+	for i, p := range batch {
+		fmt.Fprintf(&prompt, "\nDECISION %d\nContext: %s\nProposed action: %s\n", i+1, p.input.DecisionContext, p.input.ProposedAction)
+		if len(p.input.Alternatives) > 0 {
+			fmt.Fprintf(&prompt, "Alternatives considered: %s\n", strings.Join(p.input.Alternatives, "; "))
+		}
+		fmt.Fprintf(&prompt, "User context: %s\n", p.contextInfo)
+	}
+
+	prompt.WriteString(`
+EVALUATION INSTRUCTIONS:
+Score each dimension from -1.0 (restricts freedom, harms well-being, creates waste or debt) to +1.0 (increases choice and control, improves flourishing, healthy patterns), and your confidence from 0.0 to 1.0.
+
+CRITICAL PRINCIPLES:
+- Always choose freedom over convenience when they conflict
+- Prioritize user agency and informed choice
+- Consider both immediate and long-term impacts
+- Be especially cautious with actions that reduce user control
+
+REQUIRED OUTPUT FORMAT:
+Reply with a JSON object whose "decisions" array has one object per decision, with these fields:
+- "decision": the decision's number
+- "freedom_impact": score from -1.0 to +1.0
+- "well_being_impact": score from -1.0 to +1.0
+- "sustainability_impact": score from -1.0 to +1.0
+- "confidence": score from 0.0 to 1.0
+- "remediation": if the action could cause harm, how to undo or contain it should it go wrong partway; otherwise "none"
+- "reasoning": 2-3 sentence explanation of the assessment
+
+Please provide your ethical evaluations:`)
+
+	return prompt.String()
+}
+
+// ethicalBatchSchema is the JSON reply batch ethical reasoning asks for.
+// The scores of each decision are left to ethicalImpactSchema, so that an
+// invalid one fails only its decision rather than the whole reply.
+var ethicalBatchSchema = &mcp.ResponseSchema{
+	Name: "ethical_impacts",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"decisions": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"decision": map[string]interface{}{"type": "integer", "minimum": 1},
+					},
+					"required": []interface{}{"decision"},
+				},
+			},
+		},
+		"required": []interface{}{"decisions"},
+	},
+}
+
+// reuseToData stores what lets a decision's assessment be found for reuse,
+// and the decision it reused, if any.
+func reuseToData(decision *EthicalDecision, data map[string]interface{}) {
+	data["decision_hash"] = decisionHash(decision.DecisionContext, decision.ProposedAction)
+	if decision.ReusedFrom != "" {
+		data["reused_from"] = decision.ReusedFrom
+	}
+}
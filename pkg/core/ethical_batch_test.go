@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// impactReply is a valid ethical assessment reply.
+const impactReply = `{"freedom_impact": 0.6, "well_being_impact": 0.7, "sustainability_impact": 0.5, "confidence": 0.9, "reasoning": "Helps the user."}`
+
+func newScriptedEthicalFramework(t *testing.T, clock utils.Clock) (*EthicalFramework, *scriptedClassifierService) {
+	t.Helper()
+	store := setupTestStore(t)
+	service := &scriptedClassifierService{}
+	config := DefaultEthicalConfig()
+	config.Clock = clock
+	return NewEthicalFramework(store, llm.NewRouter(service), NewUserContextManager(store), config), service
+}
+
+func TestEthicalFramework_EvaluateDecisionsBatches(t *testing.T) {
+	ctx := context.Background()
+	ef, service := newScriptedEthicalFramework(t, nil)
+
+	// The batch scores decision 2 out of range, so it is asked about alone
+	service.script(`{"decisions": [
+		{"decision": 1, "freedom_impact": 0.5, "well_being_impact": 0.6, "sustainability_impact": 0.4, "confidence": 0.9, "reasoning": "Saves time."},
+		{"decision": 2, "freedom_impact": 1.5, "well_being_impact": 0.6, "sustainability_impact": 0.4, "confidence": 0.9, "reasoning": "Out of range."},
+		{"decision": 3, "freedom_impact": -0.8, "well_being_impact": -0.5, "sustainability_impact": 0.1, "confidence": 0.8, "reasoning": "Takes control away."}
+	]}`, impactReply)
+
+	inputs := []DecisionInput{
+		{ObjectiveID: "obj-1", DecisionContext: "Weekly report", ProposedAction: "summarize the inbox", UserID: "user-1"},
+		{ObjectiveID: "obj-1", DecisionContext: "Weekly report", ProposedAction: "draft the report", UserID: "user-1"},
+		{ObjectiveID: "obj-1", DecisionContext: "Weekly report", ProposedAction: "unsubscribe from every list", UserID: "user-1"},
+		{ObjectiveID: "obj-1", DecisionContext: "Weekly report", UserID: "user-1"},
+	}
+	decisions, err := ef.EvaluateDecisions(ctx, inputs)
+	if err == nil || !strings.Contains(err.Error(), "proposed action cannot be empty") {
+		t.Errorf("Expected the invalid input's error, got %v", err)
+	}
+	if decisions[3] != nil {
+		t.Errorf("Expected no decision for the invalid input, got %+v", decisions[3])
+	}
+	for i, decision := range decisions[:3] {
+		if decision == nil || decision.ProposedAction != inputs[i].ProposedAction {
+			t.Fatalf("Expected decision %d in input order, got %+v", i+1, decision)
+		}
+	}
+
+	if service.calls() != 2 || !strings.Contains(service.prompts[0], "DECISION 3") {
+		t.Fatalf("Expected one batch call and one fallback, got %d calls", service.calls())
+	}
+	if decisions[1].Impact.Reasoning != "Helps the user." {
+		t.Errorf("Expected the fallback's own assessment, got %q", decisions[1].Impact.Reasoning)
+	}
+	if decisions[2].ApprovalStatus != DecisionApprovalPending {
+		t.Errorf("Expected the harmful action to need approval, got %s", decisions[2].ApprovalStatus)
+	}
+
+	stats := ef.Stats()
+	want := EvaluationStats{Decisions: 3, LLMCalls: 2, BatchCalls: 1, BatchedDecisions: 2, BatchFallbacks: 1}
+	if stats != want || stats.CallsSaved() != 1 {
+		t.Errorf("Expected stats %+v, got %+v", want, stats)
+	}
+}
+
+func TestEthicalFramework_ReusesPositiveAssessment(t *testing.T) {
+	ctx := context.Background()
+	clock := utils.NewFakeClock(time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC))
+	ef, service := newScriptedEthicalFramework(t, clock)
+	service.script(impactReply)
+
+	first, err := ef.EvaluateDecision(ctx, "obj-1", "Weekly report", "summarize the inbox", nil, "user-1")
+	if err != nil {
+		t.Fatalf("EvaluateDecision failed: %v", err)
+	}
+
+	// Until its outcome is known, the assessment is not reused
+	service.script(impactReply)
+	if _, err := ef.EvaluateDecision(ctx, "obj-1", "Weekly report", "summarize the inbox", nil, "user-1"); err != nil || service.calls() != 2 {
+		t.Fatalf("Expected a second call, got %d calls, %v", service.calls(), err)
+	}
+
+	if err := ef.ImplementDecision(ctx, first.ID); err != nil {
+		t.Fatalf("ImplementDecision failed: %v", err)
+	}
+	if err := ef.RecordOutcome(ctx, first.ID, DecisionOutcomePositive, ""); err != nil {
+		t.Fatalf("RecordOutcome failed: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	reused, err := ef.EvaluateDecision(ctx, "obj-2", "Weekly report", "summarize the inbox", nil, "user-1")
+	if err != nil {
+		t.Fatalf("EvaluateDecision failed: %v", err)
+	}
+	if service.calls() != 2 || reused.ReusedFrom != first.ID {
+		t.Fatalf("Expected the positive assessment reused without a call, got %d calls, %+v", service.calls(), reused)
+	}
+	if reused.Impact.FreedomImpact != first.Impact.FreedomImpact || reused.Impact.ConfidenceScore >= first.Impact.ConfidenceScore {
+		t.Errorf("Expected the same scores with reduced confidence, got %+v", reused.Impact)
+	}
+	if stored, err := ef.GetDecision(ctx, reused.ID); err != nil || stored.ReusedFrom != first.ID {
+		t.Errorf("Expected the reuse to be stored, got %v", err)
+	}
+
+	// Another user's decision, or one past the TTL, is evaluated afresh
+	service.script(impactReply, impactReply)
+	if decision, err := ef.EvaluateDecision(ctx, "obj-2", "Weekly report", "summarize the inbox", nil, "user-2"); err != nil || decision.ReusedFrom != "" {
+		t.Errorf("Expected another user's decision evaluated, got %v", err)
+	}
+	clock.Advance(DefaultAssessmentReuseTTL)
+	if decision, err := ef.EvaluateDecision(ctx, "obj-3", "Weekly report", "summarize the inbox", nil, "user-1"); err != nil || decision.ReusedFrom != "" {
+		t.Errorf("Expected an expired assessment not reused, got %v", err)
+	}
+
+	stats := ef.Stats()
+	if stats.ReusedAssessments != 1 || stats.Decisions != 5 || stats.LLMCalls != 4 || stats.CallsSaved() != 1 {
+		t.Errorf("Expected one reuse saving one call, got %+v", stats)
+	}
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// EvaluationFailureMode is what the ethical framework does when it cannot
+// evaluate a decision, for instance because the LLM provider is down.
+type EvaluationFailureMode string
+
+const (
+	// EvaluationFailClosed blocks the decision and returns the error
+	EvaluationFailClosed EvaluationFailureMode = "fail_closed"
+	// EvaluationFailPending records the decision as critical and pending approval
+	EvaluationFailPending EvaluationFailureMode = "fail_pending"
+	// EvaluationFailOpenForLowRisk approves provably mundane actions and
+	// handles everything else like EvaluationFailPending
+	EvaluationFailOpenForLowRisk EvaluationFailureMode = "fail_open_for_low_risk"
+)
+
+// EvaluationFailureCause classifies why an ethical evaluation failed.
+type EvaluationFailureCause string
+
+const (
+	// EvaluationFailureBudget means the LLM budget was exhausted
+	EvaluationFailureBudget EvaluationFailureCause = "budget_exhausted"
+	// EvaluationFailureTimeout means the evaluation was cancelled or timed out
+	EvaluationFailureTimeout EvaluationFailureCause = "timeout"
+	// EvaluationFailureProvider means no model could complete the request
+	EvaluationFailureProvider EvaluationFailureCause = "provider_unavailable"
+	// EvaluationFailureResponse means the model's answer could not be parsed
+	EvaluationFailureResponse EvaluationFailureCause = "unparseable_response"
+	// EvaluationFailureNoRouter means the framework has no LLM router
+	EvaluationFailureNoRouter EvaluationFailureCause = "no_router"
+)
+
+// EvaluationFailureWarningThreshold is how many evaluation failures in a day
+// make them count as recurring, so that dashboards warn about them.
+const EvaluationFailureWarningThreshold = 3
+
+// evaluationFailureNodeType is the storage node type recording each failure.
+const evaluationFailureNodeType = "evaluation_failure"
+
+// EvaluationFailure records that a decision was made without an ethical
+// evaluation, under which mode and why.
+type EvaluationFailure struct {
+	Mode   EvaluationFailureMode
+	Cause  EvaluationFailureCause
+	Reason string
+}
+
+// evaluationError carries the cause of a failure the framework detected itself.
+type evaluationError struct {
+	cause EvaluationFailureCause
+	err   error
+}
+
+func (e *evaluationError) Error() string { return e.err.Error() }
+func (e *evaluationError) Unwrap() error { return e.err }
+
+// lowRiskVerbs are the read-only verbs the low-risk pre-screen accepts.
+var lowRiskVerbs = []string{
+	"read", "list", "view", "show", "search", "find",
+	"summarize", "count", "check", "inspect", "preview",
+}
+
+// sensitivePathWords keep file actions out of the low-risk pre-screen even
+// when they only read.
+var sensitivePathWords = []string{"ssh", "gnupg", "aws", "env", "shadow", "secret", "secrets", "rsa", "pem", "token", "tokens", "key", "keys"}
+
+// chainingWords mark compound actions, which the pre-screen cannot vouch for.
+var chainingWords = []string{"and", "then", "also", "after", "before", "while"}
+
+// classifyEvaluationFailure works out the cause of an evaluation error.
+func classifyEvaluationFailure(err error) EvaluationFailureCause {
+	var evalErr *evaluationError
+	switch {
+	case errors.As(err, &evalErr):
+		return evalErr.cause
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return EvaluationFailureTimeout
+	case errors.Is(err, mcp.ErrBudgetExceeded), errs.CodeOf(err) == errs.BudgetExceeded:
+		return EvaluationFailureBudget
+	default:
+		return EvaluationFailureProvider
+	}
+}
+
+// handleEvaluationFailure applies the configured failure mode after the
+// ethical evaluation of a proposed action failed. Every failure is recorded,
+// and dangerous actions always end up pending approval whatever the mode.
+func (ef *EthicalFramework) handleEvaluationFailure(ctx context.Context, objectiveID, decisionContext, proposedAction string, alternatives []string, userID string, evalErr error) (*EthicalDecision, error) {
+	failure := &EvaluationFailure{
+		Mode:   ef.onEvaluationFailure,
+		Cause:  classifyEvaluationFailure(evalErr),
+		Reason: evalErr.Error(),
+	}
+
+	dangerous := IsDangerousAction(proposedAction)
+	if failure.Mode == EvaluationFailClosed && !dangerous {
+		if err := ef.recordEvaluationFailure(ctx, failure, proposedAction, ""); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		return nil, fmt.Errorf("failed to perform ethical reasoning: %w", evalErr)
+	}
+
+	now := ef.clock.Now()
+	decision := &EthicalDecision{
+		ObjectiveID:        objectiveID,
+		DecisionContext:    decisionContext,
+		ProposedAction:     proposedAction,
+		AlternativeActions: alternatives,
+		Impact: EthicalImpact{
+			Reasoning: fmt.Sprintf("Ethical evaluation unavailable (%s): %s", failure.Cause, failure.Reason),
+		},
+		Urgency:           DecisionUrgencyCritical,
+		ApprovalStatus:    DecisionApprovalPending,
+		Outcome:           DecisionOutcomeUnknown,
+		CreatedAt:         now,
+		UserID:            userID,
+		EvaluationFailure: failure,
+		store:             ef.store,
+	}
+
+	switch {
+	case dangerous:
+		decision.Impact.Reasoning += "; the action matches a dangerous pattern and needs approval"
+	case failure.Mode == EvaluationFailOpenForLowRisk:
+		if lowRisk, why := prescreenLowRisk(decisionContext, proposedAction); lowRisk {
+			decision.Impact.Reasoning += "; approved by the low-risk pre-screen: " + why
+			decision.Urgency = DecisionUrgencyLow
+			decision.ApprovalStatus = DecisionApprovalApproved
+			decision.ApprovedAt = &now
+		} else {
+			decision.Impact.Reasoning += "; not approved by the low-risk pre-screen: " + why
+		}
+	}
+
+	if err := ef.storeDecision(ctx, decision); err != nil {
+		return nil, fmt.Errorf("failed to store decision: %w", err)
+	}
+	if err := ef.recordEvaluationFailure(ctx, failure, proposedAction, decision.ID); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	return decision, nil
+}
+
+// prescreenLowRisk reports whether an action is provably mundane: a single
+// read-only action on a local target, with nothing dangerous in the action
+// or its context. The reason explains the verdict either way.
+func prescreenLowRisk(decisionContext, proposedAction string) (bool, string) {
+	if IsDangerousAction(proposedAction) || IsDangerousAction(decisionContext) {
+		return false, "it matches a dangerous pattern"
+	}
+	if strings.ContainsAny(proposedAction, "|;&><`$") {
+		return false, "it contains shell control characters"
+	}
+
+	words := strings.Fields(strings.ToLower(proposedAction))
+	if len(words) == 0 || len(words) > 12 {
+		return false, "it is not a short, single action"
+	}
+	for _, word := range words[1:] {
+		if containsString(chainingWords, strings.Trim(word, ".,:;")) {
+			return false, "it combines several actions"
+		}
+	}
+
+	target := parseActionTarget(proposedAction)
+	if !containsString(lowRiskVerbs, target.verb) {
+		return false, fmt.Sprintf("%q is not a read-only verb", target.verb)
+	}
+	switch target.service {
+	case "web":
+		return false, "it reaches out to the network"
+	case "filesystem":
+		segments := strings.FieldsFunc(strings.ToLower(target.value), func(r rune) bool {
+			return r == '/' || r == '.' || r == '_' || r == '-'
+		})
+		for _, segment := range segments {
+			if containsString(sensitivePathWords, segment) {
+				return false, "it touches a sensitive path"
+			}
+		}
+	}
+
+	return true, fmt.Sprintf("read-only %s action", target.service)
+}
+
+// recordEvaluationFailure stores a failure so that metrics and dashboards
+// can count failures per cause across processes.
+func (ef *EthicalFramework) recordEvaluationFailure(ctx context.Context, failure *EvaluationFailure, proposedAction, decisionID string) error {
+	node := storage.NewNode(evaluationFailureNodeType, map[string]interface{}{
+		"mode":            string(failure.Mode),
+		"cause":           string(failure.Cause),
+		"reason":          failure.Reason,
+		"proposed_action": proposedAction,
+		"decision_id":     decisionID,
+	})
+	if err := ef.store.AddNode(ctx, node); err != nil {
+		return fmt.Errorf("failed to record evaluation failure: %w", err)
+	}
+	return nil
+}
+
+// EvaluationFailureWarning returns a warning for dashboards when a day's
+// evaluation failures are recurring, or "" when there is nothing to report.
+func EvaluationFailureWarning(counts map[EvaluationFailureCause]int) string {
+	total := 0
+	var causes []string
+	for cause, count := range counts {
+		total += count
+		causes = append(causes, fmt.Sprintf("%s %d", cause, count))
+	}
+	if total < EvaluationFailureWarningThreshold {
+		return ""
+	}
+	sort.Strings(causes)
+	return fmt.Sprintf("Ethical evaluation failed %d times today (%s); decisions are falling back to the failure policy",
+		total, strings.Join(causes, ", "))
+}
+
+// evaluationFailureToData adds a decision's evaluation failure to its node data.
+func evaluationFailureToData(failure *EvaluationFailure, data map[string]interface{}) {
+	if failure == nil {
+		return
+	}
+	data["evaluation_failure_mode"] = string(failure.Mode)
+	data["evaluation_failure_cause"] = string(failure.Cause)
+	data["evaluation_failure_reason"] = failure.Reason
+}
+
+// evaluationFailureFromData reads a decision's evaluation failure, if any.
+func evaluationFailureFromData(data map[string]interface{}) *EvaluationFailure {
+	mode := getString(data, "evaluation_failure_mode")
+	if mode == "" {
+		return nil
+	}
+	return &EvaluationFailure{
+		Mode:   EvaluationFailureMode(mode),
+		Cause:  EvaluationFailureCause(getString(data, "evaluation_failure_cause")),
+		Reason: getString(data, "evaluation_failure_reason"),
+	}
+}
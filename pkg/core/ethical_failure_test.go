@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// failingLLMService fails every request with the same error.
+type failingLLMService struct {
+	err error
+}
+
+func (s *failingLLMService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	return mcp.ServiceResult{Success: false, Error: s.err}
+}
+
+func newFailingEthicalFramework(t *testing.T, mode EvaluationFailureMode, err error) *EthicalFramework {
+	t.Helper()
+	store := setupTestStore(t)
+	config := DefaultEthicalConfig()
+	config.OnEvaluationFailure = mode
+	return NewEthicalFramework(store, llm.NewRouter(&failingLLMService{err: err}), NewUserContextManager(store), config)
+}
+
+func TestEthicalFramework_EvaluationFailureModes(t *testing.T) {
+	ctx := context.Background()
+	providerDown := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		mode       EvaluationFailureMode
+		action     string
+		wantError  bool
+		wantStatus DecisionApprovalStatus
+	}{
+		{name: "closed blocks", mode: EvaluationFailClosed, action: "read file /home/me/notes.md", wantError: true},
+		{name: "closed still records dangerous actions", mode: EvaluationFailClosed, action: "delete /home/me/notes.md", wantStatus: DecisionApprovalPending},
+		{name: "pending", mode: EvaluationFailPending, action: "read file /home/me/notes.md", wantStatus: DecisionApprovalPending},
+		{name: "open approves mundane actions", mode: EvaluationFailOpenForLowRisk, action: "read file /home/me/notes.md", wantStatus: DecisionApprovalApproved},
+		{name: "open defers other actions", mode: EvaluationFailOpenForLowRisk, action: "write file /home/me/notes.md", wantStatus: DecisionApprovalPending},
+		{name: "open never approves dangerous actions", mode: EvaluationFailOpenForLowRisk, action: "read file /home/me/notes.md then rm it", wantStatus: DecisionApprovalPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ef := newFailingEthicalFramework(t, tt.mode, providerDown)
+			decision, err := ef.EvaluateDecision(ctx, "obj-1", "Tidy up notes", tt.action, nil, "user-1")
+
+			if tt.wantError {
+				if err == nil || decision != nil {
+					t.Fatalf("Expected the failure to be surfaced, got %v, %v", decision, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("EvaluateDecision failed: %v", err)
+				}
+				if decision.ApprovalStatus != tt.wantStatus {
+					t.Errorf("Expected status %s, got %s", tt.wantStatus, decision.ApprovalStatus)
+				}
+				if tt.wantStatus == DecisionApprovalPending && decision.Urgency != DecisionUrgencyCritical {
+					t.Errorf("Expected a pending fallback decision to be critical, got %s", decision.Urgency)
+				}
+
+				stored, err := ef.GetDecision(ctx, decision.ID)
+				if err != nil {
+					t.Fatalf("GetDecision failed: %v", err)
+				}
+				failure := stored.EvaluationFailure
+				if failure == nil || failure.Mode != tt.mode || failure.Cause != EvaluationFailureProvider ||
+					!strings.Contains(failure.Reason, "connection refused") {
+					t.Errorf("Expected the mode and reason on the stored decision, got %+v", failure)
+				}
+			}
+
+			counts, err := NewRollupManager(ef.store).EvaluationFailuresOnDay(ctx, time.Now())
+			if err != nil || counts[EvaluationFailureProvider] != 1 {
+				t.Errorf("Expected the failure to be counted, got %v, %v", counts, err)
+			}
+		})
+	}
+}
+
+func TestEthicalFramework_EvaluationFailureCauses(t *testing.T) {
+	ctx := context.Background()
+
+	ef := newFailingEthicalFramework(t, EvaluationFailPending, fmt.Errorf("budget check failed: %w", mcp.ErrBudgetExceeded))
+	for i := 0; i < EvaluationFailureWarningThreshold; i++ {
+		decision, err := ef.EvaluateDecision(ctx, "obj-1", "Report", "summarize the weekly report", nil, "user-1")
+		if err != nil || decision.EvaluationFailure.Cause != EvaluationFailureBudget {
+			t.Fatalf("Expected a budget failure, got %v, %v", decision, err)
+		}
+	}
+
+	noRouter := NewEthicalFramework(ef.store, nil, ef.contextManager, DefaultEthicalConfig())
+	if _, err := noRouter.EvaluateDecision(ctx, "obj-1", "Report", "summarize the weekly report", nil, "user-1"); err == nil {
+		t.Error("Expected the default mode to fail closed")
+	}
+
+	counts, err := NewRollupManager(ef.store).EvaluationFailuresOnDay(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("EvaluationFailuresOnDay failed: %v", err)
+	}
+	if counts[EvaluationFailureBudget] != EvaluationFailureWarningThreshold || counts[EvaluationFailureNoRouter] != 1 {
+		t.Errorf("Expected failures to be counted per cause, got %v", counts)
+	}
+
+	warning := EvaluationFailureWarning(counts)
+	if !strings.Contains(warning, "failed 4 times") || !strings.Contains(warning, "budget_exhausted 3") {
+		t.Errorf("Expected a warning for recurring failures, got %q", warning)
+	}
+	if warning := EvaluationFailureWarning(map[EvaluationFailureCause]int{EvaluationFailureTimeout: 2}); warning != "" {
+		t.Errorf("Expected no warning below the threshold, got %q", warning)
+	}
+}
+
+func TestPrescreenLowRisk(t *testing.T) {
+	tests := []struct {
+		context string
+		action  string
+		want    bool
+	}{
+		{"Tidy up notes", "read file /home/me/notes.md", true},
+		{"Tidy up notes", "List /home/me/projects/", true},
+		{"Weekly review", "summarize the weekly report", true},
+		{"Tidy up notes", "write file /home/me/notes.md", false},     // not read-only
+		{"Tidy up notes", "readme /home/me/notes.md", false},         // verbs match whole words only
+		{"Delete stale notes", "read file /home/me/notes.md", false}, // dangerous context
+		{"Tidy up notes", "read file /home/me/notes.md and archive it", false},
+		{"Tidy up notes", "read file /home/me/notes.md > /tmp/copy", false},
+		{"Tidy up notes", "read file /home/me/.ssh/config", false},
+		{"Tidy up notes", "read file /home/me/api_key.txt", false},
+		{"Research", "search https://docs.python.org/3/", false},
+		{"Research", "fetch docs.python.org", false},
+		{"Tidy up notes", "read " + strings.Repeat("notes ", 12), false},
+		{"Tidy up notes", "", false},
+	}
+
+	for _, tt := range tests {
+		if got, why := prescreenLowRisk(tt.context, tt.action); got != tt.want {
+			t.Errorf("prescreenLowRisk(%q, %q) = %v (%s), want %v", tt.context, tt.action, got, why, tt.want)
+		}
+	}
+}
+
+func TestClassifyEvaluationFailure(t *testing.T) {
+	unaffordable := &llm.BudgetExceededError{Affordability: &llm.AffordabilityCheck{}}
+	overloaded := &mcp.APIError{Provider: "anthropic", Model: "claude-3-haiku", StatusCode: 503, Message: "overloaded"}
+
+	tests := []struct {
+		name string
+		err  error
+		want EvaluationFailureCause
+	}{
+		{"service budget", fmt.Errorf("task execution failed: %w", mcp.ErrBudgetExceeded), EvaluationFailureBudget},
+		{"routing budget", unaffordable, EvaluationFailureBudget},
+		{"provider down", fmt.Errorf("task execution failed: %w", overloaded), EvaluationFailureProvider},
+		{"message mentioning a budget", errors.New("budget service: connection refused"), EvaluationFailureProvider},
+		{"timeout", fmt.Errorf("completion failed: %w", context.DeadlineExceeded), EvaluationFailureTimeout},
+	}
+	for _, tt := range tests {
+		if got := classifyEvaluationFailure(tt.err); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
@@ -2,13 +2,19 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 // DecisionUrgency represents how urgent an ethical decision is.
@@ -72,6 +78,10 @@ type EthicalImpact struct {
 
 	// Reasoning explains the rationale behind these scores
 	Reasoning string
+
+	// Remediation proposes how to undo or contain the action should its
+	// implementation go wrong; only kept for high-urgency decisions
+	Remediation string
 }
 
 // EthicalDecision represents a decision point that requires ethical evaluation.
@@ -113,9 +123,33 @@ type EthicalDecision struct {
 	// ApprovedAt is when the user approved this decision (if applicable)
 	ApprovedAt *time.Time
 
+	// ApprovedByRule is the approval rule that auto-approved this decision, if any
+	ApprovedByRule string
+
+	// PolicyRuleID is the policy rule that decided this decision without
+	// ethical reasoning, if any
+	PolicyRuleID string
+
 	// ImplementedAt is when the decision was implemented
 	ImplementedAt *time.Time
 
+	// AbortedAt is when the implementation was aborted (if applicable)
+	AbortedAt *time.Time
+
+	// AbortReason explains why the implementation was aborted
+	AbortReason string
+
+	// RemediationPlan says what to do if the implementation is aborted
+	RemediationPlan *RemediationPlan
+
+	// EvaluationFailure is set when the decision was made without an ethical
+	// evaluation, recording the failure mode applied and why evaluation failed
+	EvaluationFailure *EvaluationFailure
+
+	// ReusedFrom is the earlier decision whose assessment this one reused
+	// instead of being evaluated, if any
+	ReusedFrom string
+
 	// UserID identifies which user this decision belongs to
 	UserID string
 
@@ -130,12 +164,31 @@ type EthicalFramework struct {
 	store           *storage.Store
 	llmRouter       *llm.Router
 	contextManager  *UserContextManager
+	rules           *ApprovalRuleManager
 
 	// Configuration for ethical reasoning
 	freedomWeight      float64 // Weight given to freedom considerations (0-1)
 	wellBeingWeight    float64 // Weight given to well-being considerations (0-1)
 	sustainabilityWeight float64 // Weight given to sustainability considerations (0-1)
 	approvalThreshold  float64 // Threshold below which user approval is required
+
+	// onEvaluationFailure is the policy applied when ethical reasoning fails
+	onEvaluationFailure EvaluationFailureMode
+
+	// clock stamps decisions, approvals and implementations
+	clock utils.Clock
+
+	// batchSize is the most decisions EvaluateDecisions puts in one prompt,
+	// and reuseTTL how long a positive decision's assessment is reused
+	batchSize int
+	reuseTTL  time.Duration
+
+	// policyProposalThreshold is how many approvals of the same action
+	// lead to proposing a policy rule approving it
+	policyProposalThreshold int
+
+	statsMu sync.Mutex
+	stats   EvaluationStats
 }
 
 // EthicalConfig contains configuration for the ethical framework.
@@ -144,6 +197,27 @@ type EthicalConfig struct {
 	WellBeingWeight      float64
 	SustainabilityWeight float64
 	ApprovalThreshold    float64
+
+	// OnEvaluationFailure decides what happens when ethical reasoning itself
+	// fails, e.g. because the provider is down or the budget is exhausted
+	OnEvaluationFailure EvaluationFailureMode
+
+	// Clock stamps decisions (default: the system clock)
+	Clock utils.Clock
+
+	// BatchSize is the most decisions EvaluateDecisions puts to the LLM in
+	// one prompt (default: DefaultDecisionBatchSize)
+	BatchSize int
+
+	// ReuseTTL is how long the assessment of a decision that turned out
+	// positive is reused for the same context and action, without calling
+	// the LLM (0: never reused)
+	ReuseTTL time.Duration
+
+	// PolicyProposalThreshold is how many times the user approves the same
+	// action before a policy rule approving it is proposed (default:
+	// DefaultPolicyProposalThreshold)
+	PolicyProposalThreshold int
 }
 
 // DefaultEthicalConfig returns sensible defaults for ethical framework configuration.
@@ -153,6 +227,10 @@ func DefaultEthicalConfig() EthicalConfig {
 		WellBeingWeight:      0.35, // Well-being is secondary
 		SustainabilityWeight: 0.25, // Sustainability ensures long-term viability
 		ApprovalThreshold:    0.6,  // Require approval if overall score < 0.6
+		OnEvaluationFailure:  EvaluationFailClosed,
+		BatchSize:            DefaultDecisionBatchSize,
+		ReuseTTL:             DefaultAssessmentReuseTTL,
+		PolicyProposalThreshold: DefaultPolicyProposalThreshold,
 	}
 }
 
@@ -162,27 +240,58 @@ func NewEthicalFramework(store *storage.Store, llmRouter *llm.Router, contextMan
 	if len(config) > 0 {
 		cfg = config[0]
 	}
+	if cfg.OnEvaluationFailure == "" {
+		cfg.OnEvaluationFailure = EvaluationFailClosed
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultDecisionBatchSize
+	}
+	if cfg.PolicyProposalThreshold <= 0 {
+		cfg.PolicyProposalThreshold = DefaultPolicyProposalThreshold
+	}
+
+	clock := utils.ClockOrReal(cfg.Clock)
+	rules := NewApprovalRuleManager(store)
+	rules.clock = clock
 
 	return &EthicalFramework{
 		store:               store,
 		llmRouter:           llmRouter,
 		contextManager:      contextManager,
+		rules:               rules,
 		freedomWeight:       cfg.FreedomWeight,
 		wellBeingWeight:     cfg.WellBeingWeight,
 		sustainabilityWeight: cfg.SustainabilityWeight,
 		approvalThreshold:   cfg.ApprovalThreshold,
+		onEvaluationFailure: cfg.OnEvaluationFailure,
+		clock:               clock,
+		batchSize:           cfg.BatchSize,
+		reuseTTL:            cfg.ReuseTTL,
+		policyProposalThreshold: cfg.PolicyProposalThreshold,
 	}
 }
 
 // EvaluateDecision performs ethical evaluation of a proposed decision.
-// It uses LLM-based reasoning to assess the decision against ethical principles.
+// It uses LLM-based reasoning to assess the decision against ethical principles,
+// unless a policy rule decides it, or a recent decision like it turned out
+// positive and its assessment can be reused.
 func (ef *EthicalFramework) EvaluateDecision(ctx context.Context, objectiveID, decisionContext, proposedAction string, alternatives []string, userID string) (*EthicalDecision, error) {
-	if decisionContext == "" {
-		return nil, fmt.Errorf("decision context cannot be empty")
+	input := DecisionInput{
+		ObjectiveID:     objectiveID,
+		DecisionContext: decisionContext,
+		ProposedAction:  proposedAction,
+		Alternatives:    alternatives,
+		UserID:          userID,
+	}
+	if err := input.validate(); err != nil {
+		return nil, err
 	}
 
-	if proposedAction == "" {
-		return nil, fmt.Errorf("proposed action cannot be empty")
+	if decision, decided, err := ef.applyPolicy(ctx, input); decided || err != nil {
+		return decision, err
+	}
+	if decision, reused, err := ef.reuseAssessment(ctx, input); reused || err != nil {
+		return decision, err
 	}
 
 	// Get relevant user context for ethical reasoning
@@ -191,48 +300,65 @@ func (ef *EthicalFramework) EvaluateDecision(ctx context.Context, objectiveID, d
 		return nil, fmt.Errorf("failed to get user context: %w", err)
 	}
 
-	// Perform ethical reasoning using LLM
-	impact, err := ef.performEthicalReasoning(ctx, decisionContext, proposedAction, alternatives, userContext)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform ethical reasoning: %w", err)
-	}
+	// Perform ethical reasoning using LLM, falling back to the failure policy
+	return ef.evaluateWithContext(ctx, input, ef.buildContextInfo(userContext))
+}
+
+// recordDecision creates and stores the decision with its assessed impact,
+// determining its urgency and whether it needs approval.
+func (ef *EthicalFramework) recordDecision(ctx context.Context, input DecisionInput, impact *EthicalImpact, reusedFrom string) (*EthicalDecision, error) {
+	ef.countStats(func(stats *EvaluationStats) { stats.Decisions++ })
 
 	// Determine urgency based on impact scores
 	urgency := ef.determineUrgency(impact)
 
-	// Determine if approval is needed
-	approvalStatus := ef.determineApprovalNeeded(impact, urgency)
+	// Determine if approval is needed, or already given by a standing rule
+	approvalStatus, rule, err := ef.determineApprovalNeeded(ctx, input.ProposedAction, impact, urgency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine approval: %w", err)
+	}
 
-	now := time.Now()
+	now := ef.clock.Now()
 
 	// Create decision record
 	decision := &EthicalDecision{
-		ObjectiveID:        objectiveID,
-		DecisionContext:    decisionContext,
-		ProposedAction:     proposedAction,
-		AlternativeActions: alternatives,
+		ObjectiveID:        input.ObjectiveID,
+		DecisionContext:    input.DecisionContext,
+		ProposedAction:     input.ProposedAction,
+		AlternativeActions: input.Alternatives,
 		Impact:             *impact,
 		Urgency:            urgency,
 		ApprovalStatus:     approvalStatus,
 		Outcome:            DecisionOutcomeUnknown,
 		CreatedAt:          now,
-		UserID:             userID,
+		RemediationPlan:    proposeRemediation(impact, urgency),
+		ReusedFrom:         reusedFrom,
+		UserID:             input.UserID,
 		store:              ef.store,
 	}
+	if rule != nil {
+		decision.ApprovedAt = &now
+		decision.ApprovedByRule = rule.ID
+	}
 
 	// Store the decision
 	if err := ef.storeDecision(ctx, decision); err != nil {
 		return nil, fmt.Errorf("failed to store decision: %w", err)
 	}
 
+	if rule != nil {
+		if err := ef.rules.recordUse(ctx, rule); err != nil {
+			// Log error but don't fail - the decision is already recorded
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
 	return decision, nil
 }
 
-// performEthicalReasoning uses LLM to assess ethical impact of a decision.
-func (ef *EthicalFramework) performEthicalReasoning(ctx context.Context, decisionContext, proposedAction string, alternatives []string, userContext []*UserContext) (*EthicalImpact, error) {
-	// Build context information from user context
-	contextInfo := ef.buildContextInfo(userContext)
-
+// performEthicalReasoning uses LLM to assess ethical impact of a decision,
+// given the summary of the user's context relevant to it.
+func (ef *EthicalFramework) performEthicalReasoning(ctx context.Context, decisionContext, proposedAction string, alternatives []string, contextInfo string) (*EthicalImpact, error) {
 	// Create structured prompt for ethical reasoning
 	prompt := ef.buildEthicalPrompt(decisionContext, proposedAction, alternatives, contextInfo)
 
@@ -243,21 +369,27 @@ func (ef *EthicalFramework) performEthicalReasoning(ctx context.Context, decisio
 		Temperature:      0.3, // Lower temperature for consistent ethical reasoning
 		TaskType:         "ethical_analysis",
 		QualityRequired:  llm.QualityPremium, // Ethical decisions require highest quality
+		ResponseSchema:   ethicalImpactSchema,
 	}
 
+	if ef.llmRouter == nil {
+		return nil, &evaluationError{cause: EvaluationFailureNoRouter, err: fmt.Errorf("no LLM router configured")}
+	}
+
+	ef.countStats(func(stats *EvaluationStats) { stats.LLMCalls++ })
 	result, err := ef.llmRouter.Route(ctx, request)
 	if err != nil {
 		return nil, fmt.Errorf("LLM routing failed: %w", err)
 	}
 
 	if result.ExecutionResult == nil {
-		return nil, fmt.Errorf("no result from LLM execution")
+		return nil, &evaluationError{cause: EvaluationFailureResponse, err: fmt.Errorf("no result from LLM execution")}
 	}
 
 	// Parse the LLM response to extract ethical impact scores
 	impact, err := ef.parseEthicalResponse(result.ExecutionResult.Text)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse ethical response: %w", err)
+		return nil, &evaluationError{cause: EvaluationFailureResponse, err: fmt.Errorf("failed to parse ethical response: %w", err)}
 	}
 
 	return impact, nil
@@ -353,86 +485,69 @@ CRITICAL PRINCIPLES:
 - Be especially cautious with actions that reduce user control
 
 REQUIRED OUTPUT FORMAT:
-Freedom Impact: [score from -1.0 to +1.0]
-Well-Being Impact: [score from -1.0 to +1.0]
-Sustainability Impact: [score from -1.0 to +1.0]
-Confidence: [score from 0.0 to 1.0]
-Reasoning: [2-3 sentence explanation of the assessment]
+Reply with a JSON object with these fields:
+- "freedom_impact": score from -1.0 to +1.0
+- "well_being_impact": score from -1.0 to +1.0
+- "sustainability_impact": score from -1.0 to +1.0
+- "confidence": score from 0.0 to 1.0
+- "remediation": if the action could cause harm, how to undo or contain it should it go wrong partway; otherwise "none"
+- "reasoning": 2-3 sentence explanation of the assessment
 
 Please provide your ethical evaluation:`
 
 	return prompt
 }
 
-// parseEthicalResponse extracts impact scores from LLM response.
-func (ef *EthicalFramework) parseEthicalResponse(response string) (*EthicalImpact, error) {
-	lines := strings.Split(response, "\n")
-
-	impact := &EthicalImpact{}
-	var err error
+// ethicalImpactSchema is the JSON reply ethical reasoning asks for.
+var ethicalImpactSchema = &mcp.ResponseSchema{
+	Name: "ethical_impact",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"freedom_impact":        map[string]interface{}{"type": "number", "minimum": -1.0, "maximum": 1.0},
+			"well_being_impact":     map[string]interface{}{"type": "number", "minimum": -1.0, "maximum": 1.0},
+			"sustainability_impact": map[string]interface{}{"type": "number", "minimum": -1.0, "maximum": 1.0},
+			"confidence":            map[string]interface{}{"type": "number", "minimum": 0.0, "maximum": 1.0},
+			"remediation":           map[string]interface{}{"type": "string"},
+			"reasoning":             map[string]interface{}{"type": "string"},
+		},
+		"required": []interface{}{"freedom_impact", "well_being_impact", "sustainability_impact", "confidence", "reasoning"},
+	},
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+// ethicalImpactReply is the decoded reply to ethical reasoning.
+type ethicalImpactReply struct {
+	FreedomImpact        float64 `json:"freedom_impact"`
+	WellBeingImpact      float64 `json:"well_being_impact"`
+	SustainabilityImpact float64 `json:"sustainability_impact"`
+	Confidence           float64 `json:"confidence"`
+	Remediation          string  `json:"remediation"`
+	Reasoning            string  `json:"reasoning"`
+}
 
-		if strings.HasPrefix(line, "Freedom Impact:") {
-			scoreStr := strings.TrimSpace(strings.TrimPrefix(line, "Freedom Impact:"))
-			impact.FreedomImpact, err = ef.parseScore(scoreStr)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse freedom impact: %w", err)
-			}
-		} else if strings.HasPrefix(line, "Well-Being Impact:") {
-			scoreStr := strings.TrimSpace(strings.TrimPrefix(line, "Well-Being Impact:"))
-			impact.WellBeingImpact, err = ef.parseScore(scoreStr)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse well-being impact: %w", err)
-			}
-		} else if strings.HasPrefix(line, "Sustainability Impact:") {
-			scoreStr := strings.TrimSpace(strings.TrimPrefix(line, "Sustainability Impact:"))
-			impact.SustainabilityImpact, err = ef.parseScore(scoreStr)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse sustainability impact: %w", err)
-			}
-		} else if strings.HasPrefix(line, "Confidence:") {
-			scoreStr := strings.TrimSpace(strings.TrimPrefix(line, "Confidence:"))
-			impact.ConfidenceScore, err = ef.parseScore(scoreStr)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse confidence: %w", err)
-			}
-		} else if strings.HasPrefix(line, "Reasoning:") {
-			impact.Reasoning = strings.TrimSpace(strings.TrimPrefix(line, "Reasoning:"))
-			// Continue reading additional reasoning lines
-			for i := len(lines) - 1; i > 0; i-- {
-				nextLine := strings.TrimSpace(lines[i])
-				if nextLine != "" && !strings.Contains(nextLine, ":") {
-					impact.Reasoning += " " + nextLine
-				}
-			}
-		}
+// parseEthicalResponse decodes the JSON impact assessment of an LLM reply.
+func (ef *EthicalFramework) parseEthicalResponse(response string) (*EthicalImpact, error) {
+	text, _, err := ethicalImpactSchema.Parse(response)
+	if err != nil {
+		return nil, err
 	}
 
-	// Validate that we got all required scores
-	if impact.Reasoning == "" {
-		return nil, fmt.Errorf("missing reasoning in LLM response")
+	var reply ethicalImpactReply
+	if err := json.Unmarshal([]byte(text), &reply); err != nil {
+		return nil, err
 	}
-
-	return impact, nil
-}
-
-// parseScore extracts a numeric score from a string, handling various formats.
-func (ef *EthicalFramework) parseScore(scoreStr string) (float64, error) {
-	// Remove common non-numeric prefixes/suffixes
-	scoreStr = strings.TrimSpace(scoreStr)
-	scoreStr = strings.TrimPrefix(scoreStr, "[")
-	scoreStr = strings.TrimSuffix(scoreStr, "]")
-
-	// Try to parse as float
-	var score float64
-	n, err := fmt.Sscanf(scoreStr, "%f", &score)
-	if err != nil || n != 1 {
-		return 0, fmt.Errorf("invalid score format: %s", scoreStr)
+	if strings.TrimSpace(reply.Reasoning) == "" {
+		return nil, fmt.Errorf("missing reasoning in LLM response")
 	}
 
-	return score, nil
+	return &EthicalImpact{
+		FreedomImpact:        reply.FreedomImpact,
+		WellBeingImpact:      reply.WellBeingImpact,
+		SustainabilityImpact: reply.SustainabilityImpact,
+		ConfidenceScore:      reply.Confidence,
+		Remediation:          strings.TrimSpace(reply.Remediation),
+		Reasoning:            strings.TrimSpace(reply.Reasoning),
+	}, nil
 }
 
 // determineUrgency assesses how urgent a decision is based on its ethical impact.
@@ -468,7 +583,25 @@ func (ef *EthicalFramework) determineUrgency(impact *EthicalImpact) DecisionUrge
 }
 
 // determineApprovalNeeded decides if user approval is required for a decision.
-func (ef *EthicalFramework) determineApprovalNeeded(impact *EthicalImpact, urgency DecisionUrgency) DecisionApprovalStatus {
+// Before requiring approval it consults the active approval rules; a matching
+// rule approves the decision and is returned.
+func (ef *EthicalFramework) determineApprovalNeeded(ctx context.Context, proposedAction string, impact *EthicalImpact, urgency DecisionUrgency) (DecisionApprovalStatus, *ApprovalRule, error) {
+	if !ef.approvalRequired(impact, urgency) {
+		return DecisionApprovalNotRequired, nil, nil
+	}
+
+	rule, err := ef.rules.MatchRule(ctx, proposedAction, urgency)
+	if err != nil {
+		return "", nil, err
+	}
+	if rule != nil {
+		return DecisionApprovalApproved, rule, nil
+	}
+	return DecisionApprovalPending, nil, nil
+}
+
+// approvalRequired applies the ethical thresholds that call for user approval.
+func (ef *EthicalFramework) approvalRequired(impact *EthicalImpact, urgency DecisionUrgency) bool {
 	// Calculate weighted overall score
 	overallScore := (impact.FreedomImpact * ef.freedomWeight) +
 		(impact.WellBeingImpact * ef.wellBeingWeight) +
@@ -476,25 +609,25 @@ func (ef *EthicalFramework) determineApprovalNeeded(impact *EthicalImpact, urgen
 
 	// Always require approval for critical decisions
 	if urgency == DecisionUrgencyCritical {
-		return DecisionApprovalPending
+		return true
 	}
 
 	// Require approval if overall score is below threshold
 	if overallScore < ef.approvalThreshold {
-		return DecisionApprovalPending
+		return true
 	}
 
 	// Require approval if any dimension has significant negative impact
 	if impact.FreedomImpact < -0.3 || impact.WellBeingImpact < -0.3 {
-		return DecisionApprovalPending
+		return true
 	}
 
 	// Require approval if confidence is low on high-impact decisions
 	if urgency >= DecisionUrgencyHigh && impact.ConfidenceScore < 0.6 {
-		return DecisionApprovalPending
+		return true
 	}
 
-	return DecisionApprovalNotRequired
+	return false
 }
 
 // storeDecision persists an ethical decision to storage.
@@ -521,9 +654,18 @@ func (ef *EthicalFramework) storeDecision(ctx context.Context, decision *Ethical
 	if decision.ApprovedAt != nil {
 		data["approved_at"] = decision.ApprovedAt.Format(time.RFC3339)
 	}
+	if decision.ApprovedByRule != "" {
+		data["approved_by_rule"] = decision.ApprovedByRule
+	}
+	if decision.PolicyRuleID != "" {
+		data["policy_rule_id"] = decision.PolicyRuleID
+	}
 	if decision.ImplementedAt != nil {
 		data["implemented_at"] = decision.ImplementedAt.Format(time.RFC3339)
 	}
+	evaluationFailureToData(decision.EvaluationFailure, data)
+	reuseToData(decision, data)
+	lifecycleToData(decision, data)
 
 	// Create storage node
 	node := storage.NewNode("ethical_decision", data)
@@ -545,29 +687,74 @@ func (ef *EthicalFramework) GetDecision(ctx context.Context, decisionID string)
 	}
 
 	if node.Type != "ethical_decision" {
-		return nil, fmt.Errorf("node %s is not an ethical decision (type: %s)", decisionID, node.Type)
+		return nil, errs.Newf(errs.NotFound, "node %s is not an ethical decision (type: %s)", decisionID, node.Type).With("id", decisionID)
 	}
 
 	return ef.nodeToEthicalDecision(node)
 }
 
-// ApproveDecision marks a decision as approved by the user.
+// ListPendingDecisions returns the decisions of a user awaiting approval, most
+// urgent first and the oldest first within an urgency. An empty userID lists
+// the decisions of every user.
+func (ef *EthicalFramework) ListPendingDecisions(ctx context.Context, userID string) ([]*EthicalDecision, error) {
+	query := ef.store.Nodes().OfType("ethical_decision").
+		WithData("approval_status", string(DecisionApprovalPending))
+	if userID != "" {
+		query = query.WithData("user_id", userID)
+	}
+
+	nodes, err := query.AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending decisions: %w", err)
+	}
+
+	var decisions []*EthicalDecision
+	for _, node := range nodes {
+		decision, err := ef.nodeToEthicalDecision(node)
+		if err != nil {
+			continue // Skip malformed decisions
+		}
+		if decision.State() == DecisionStateAwaitingApproval {
+			decisions = append(decisions, decision)
+		}
+	}
+
+	sort.Slice(decisions, func(i, j int) bool {
+		if decisions[i].Urgency != decisions[j].Urgency {
+			return decisions[i].Urgency > decisions[j].Urgency
+		}
+		return decisions[i].CreatedAt.Before(decisions[j].CreatedAt)
+	})
+	return decisions, nil
+}
+
+// ApproveDecision marks a decision as approved by the user. Once the user
+// approved the same action often enough, a policy rule approving it is
+// proposed; see ListRules and AcceptRule.
 func (ef *EthicalFramework) ApproveDecision(ctx context.Context, decisionID, userFeedback string) error {
 	decision, err := ef.GetDecision(ctx, decisionID)
 	if err != nil {
 		return fmt.Errorf("failed to get decision for approval: %w", err)
 	}
 
-	if decision.ApprovalStatus != DecisionApprovalPending {
-		return fmt.Errorf("decision %s is not pending approval (current status: %s)", decisionID, decision.ApprovalStatus)
+	if err := decision.checkTransition(DecisionStateApproved); err != nil {
+		return err
 	}
 
-	now := time.Now()
+	now := ef.clock.Now()
 	decision.ApprovalStatus = DecisionApprovalApproved
 	decision.ApprovedAt = &now
 	decision.UserFeedback = userFeedback
 
-	return ef.updateDecisionInStorage(ctx, decision)
+	if err := ef.updateDecisionInStorage(ctx, decision); err != nil {
+		return err
+	}
+
+	if _, err := ef.proposeRule(ctx, decision); err != nil {
+		// Log error but don't fail - the approval is already recorded
+		fmt.Printf("Warning: failed to propose a policy rule: %v\n", err)
+	}
+	return nil
 }
 
 // RejectDecision marks a decision as rejected by the user.
@@ -577,8 +764,8 @@ func (ef *EthicalFramework) RejectDecision(ctx context.Context, decisionID, user
 		return fmt.Errorf("failed to get decision for rejection: %w", err)
 	}
 
-	if decision.ApprovalStatus != DecisionApprovalPending {
-		return fmt.Errorf("decision %s is not pending approval (current status: %s)", decisionID, decision.ApprovalStatus)
+	if err := decision.checkTransition(DecisionStateRejected); err != nil {
+		return err
 	}
 
 	decision.ApprovalStatus = DecisionApprovalRejected
@@ -587,34 +774,39 @@ func (ef *EthicalFramework) RejectDecision(ctx context.Context, decisionID, user
 	return ef.updateDecisionInStorage(ctx, decision)
 }
 
-// ImplementDecision marks a decision as implemented and tracks outcomes.
+// ImplementDecision marks an approved decision as implemented. Until its
+// outcome is recorded it can still be aborted with AbortImplementation.
 func (ef *EthicalFramework) ImplementDecision(ctx context.Context, decisionID string) error {
 	decision, err := ef.GetDecision(ctx, decisionID)
 	if err != nil {
 		return fmt.Errorf("failed to get decision for implementation: %w", err)
 	}
 
-	if decision.ApprovalStatus == DecisionApprovalPending {
-		return fmt.Errorf("cannot implement decision %s: still pending approval", decisionID)
+	if err := decision.checkTransition(DecisionStateImplemented); err != nil {
+		return err
 	}
 
-	if decision.ApprovalStatus == DecisionApprovalRejected {
-		return fmt.Errorf("cannot implement decision %s: was rejected by user", decisionID)
-	}
-
-	now := time.Now()
+	now := ef.clock.Now()
 	decision.ImplementedAt = &now
 
 	return ef.updateDecisionInStorage(ctx, decision)
 }
 
 // RecordOutcome records the actual outcome of implementing a decision for learning.
+// The decision must have been implemented and not yet closed or aborted.
 func (ef *EthicalFramework) RecordOutcome(ctx context.Context, decisionID string, outcome DecisionOutcome, feedback string) error {
 	decision, err := ef.GetDecision(ctx, decisionID)
 	if err != nil {
 		return fmt.Errorf("failed to get decision for outcome recording: %w", err)
 	}
 
+	if outcome == "" || outcome == DecisionOutcomeUnknown {
+		return fmt.Errorf("cannot record an unknown outcome for decision %s", decisionID)
+	}
+	if err := decision.checkTransition(DecisionStateClosed); err != nil {
+		return err
+	}
+
 	decision.Outcome = outcome
 	if feedback != "" {
 		decision.UserFeedback = feedback
@@ -626,7 +818,12 @@ func (ef *EthicalFramework) RecordOutcome(ctx context.Context, decisionID string
 		fmt.Printf("Warning: failed to learn from outcome: %v\n", err)
 	}
 
-	return ef.updateDecisionInStorage(ctx, decision)
+	return ef.closeDecision(ctx, decision)
+}
+
+// Rules returns the manager for the approval rules this framework consults.
+func (ef *EthicalFramework) Rules() *ApprovalRuleManager {
+	return ef.rules
 }
 
 // learnFromOutcome updates user context based on decision outcomes.
@@ -678,9 +875,18 @@ func (ef *EthicalFramework) updateDecisionInStorage(ctx context.Context, decisio
 	if decision.ApprovedAt != nil {
 		data["approved_at"] = decision.ApprovedAt.Format(time.RFC3339)
 	}
+	if decision.ApprovedByRule != "" {
+		data["approved_by_rule"] = decision.ApprovedByRule
+	}
+	if decision.PolicyRuleID != "" {
+		data["policy_rule_id"] = decision.PolicyRuleID
+	}
 	if decision.ImplementedAt != nil {
 		data["implemented_at"] = decision.ImplementedAt.Format(time.RFC3339)
 	}
+	evaluationFailureToData(decision.EvaluationFailure, data)
+	reuseToData(decision, data)
+	lifecycleToData(decision, data)
 
 	return ef.store.UpdateNode(ctx, decision.ID, data)
 }
@@ -748,7 +954,7 @@ func (ef *EthicalFramework) nodeToEthicalDecision(node *storage.Node) (*EthicalD
 
 	userFeedback := getString(node.Data, "user_feedback")
 
-	return &EthicalDecision{
+	decision := &EthicalDecision{
 		ID:                 node.ID,
 		ObjectiveID:        objectiveID,
 		DecisionContext:    decisionContext,
@@ -761,10 +967,17 @@ func (ef *EthicalFramework) nodeToEthicalDecision(node *storage.Node) (*EthicalD
 		Outcome:            outcome,
 		CreatedAt:          createdAt,
 		ApprovedAt:         approvedAt,
+		ApprovedByRule:     getString(node.Data, "approved_by_rule"),
+		PolicyRuleID:       getString(node.Data, "policy_rule_id"),
 		ImplementedAt:      implementedAt,
+		EvaluationFailure:  evaluationFailureFromData(node.Data),
+		ReusedFrom:         getString(node.Data, "reused_from"),
 		UserID:             userID,
 		store:              ef.store,
-	}, nil
+	}
+	lifecycleFromData(decision, node.Data)
+
+	return decision, nil
 }
 
 // Helper functions for data extraction
@@ -776,9 +989,18 @@ func getString(data map[string]interface{}, key string) string {
 	return ""
 }
 
+// getFloat64 reads a numeric value, accepting the integer types that
+// in-memory nodes hold before a JSON round trip turns them into float64.
 func getFloat64(data map[string]interface{}, key string) float64 {
-	if val, ok := data[key].(float64); ok {
+	switch val := data[key].(type) {
+	case float64:
 		return val
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case uint64:
+		return float64(val)
 	}
 	return 0.0
 }
@@ -798,6 +1020,18 @@ func parseUrgency(urgencyStr string) DecisionUrgency {
 	}
 }
 
+// ParseDecisionUrgency parses a user-supplied urgency name, rejecting
+// anything other than low, medium, high or critical.
+func ParseDecisionUrgency(name string) (DecisionUrgency, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "low", "medium", "high", "critical":
+		return parseUrgency(name), nil
+	default:
+		return DecisionUrgencyLow, fmt.Errorf("unknown urgency %q, must be low, medium, high or critical", name)
+	}
+}
+
 // String methods for enums
 
 func (du DecisionUrgency) String() string {
@@ -0,0 +1,543 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// DefaultPolicyProposalThreshold is how many times by default the user must
+// approve the same action before a policy rule approving it is proposed.
+const DefaultPolicyProposalThreshold = 3
+
+// PolicyEffect is what a policy rule does with the decisions it matches.
+type PolicyEffect string
+
+const (
+	// PolicyApprove approves matching decisions without ethical reasoning
+	PolicyApprove PolicyEffect = "approve"
+	// PolicyReject rejects matching decisions without ethical reasoning
+	PolicyReject PolicyEffect = "reject"
+	// PolicyEscalate leaves matching decisions to ethical reasoning, even
+	// when an approve rule matches them too
+	PolicyEscalate PolicyEffect = "escalate"
+)
+
+// policyPrecedence orders the effects of rules matching the same decision:
+// the most cautious one decides.
+var policyPrecedence = map[PolicyEffect]int{
+	PolicyApprove:  1,
+	PolicyEscalate: 2,
+	PolicyReject:   3,
+}
+
+// PolicyRuleStatus tracks whether a policy rule takes part in evaluation.
+type PolicyRuleStatus string
+
+const (
+	// PolicyRuleActive rules decide the decisions they match
+	PolicyRuleActive PolicyRuleStatus = "active"
+	// PolicyRuleProposed rules were learned from repeated approvals and wait
+	// for the user to accept them
+	PolicyRuleProposed PolicyRuleStatus = "proposed"
+	// PolicyRuleRemoved rules were removed by the user and never match again.
+	// They are kept so the decisions they made can still be traced to them.
+	PolicyRuleRemoved PolicyRuleStatus = "removed"
+)
+
+// PolicyCondition is what a decision must look like for a policy rule to
+// match it. Every field set must hold; keywords are matched as whole words,
+// ignoring case.
+type PolicyCondition struct {
+	// Action matches one exact action, ignoring case and spacing
+	Action string
+
+	// ActionKeywords must all appear in the proposed action, and
+	// ContextKeywords in the decision context
+	ActionKeywords  []string
+	ContextKeywords []string
+
+	// Verb is the leading action word, and Service the kind of target:
+	// "filesystem", "web" or "general"
+	Verb    string
+	Service string
+
+	// PathPrefix matches actions on a path at or below a directory, and
+	// Host actions against one host
+	PathPrefix string
+	Host       string
+
+	// ObjectiveID matches the decisions of one objective
+	ObjectiveID string
+}
+
+// PolicyRule is a deterministic rule the ethical framework applies before
+// asking the LLM, so routine decisions are made quickly and the same way
+// every time.
+type PolicyRule struct {
+	ID        string
+	Condition PolicyCondition
+	Effect    PolicyEffect
+	Status    PolicyRuleStatus
+
+	// LearnedFrom are the approved decisions a proposed rule was learned from
+	LearnedFrom []string
+
+	// UsageCount counts the decisions the rule made
+	UsageCount int
+	LastUsedAt *time.Time
+
+	CreatedAt time.Time
+
+	// UserID limits the rule to one user's decisions; empty matches everyone's
+	UserID string
+}
+
+// policySubject is a decision as policy conditions see it.
+type policySubject struct {
+	input        DecisionInput
+	target       actionTarget
+	actionWords  string
+	contextWords string
+}
+
+// newPolicySubject parses a decision for matching.
+func newPolicySubject(input DecisionInput) policySubject {
+	return policySubject{
+		input:        input,
+		target:       parseActionTarget(input.ProposedAction),
+		actionWords:  " " + strings.Join(actionWords(input.ProposedAction), " ") + " ",
+		contextWords: " " + strings.Join(actionWords(input.DecisionContext), " ") + " ",
+	}
+}
+
+// AddRule validates and stores an active policy rule. Approve rules must
+// constrain the action itself and cannot name anything dangerous.
+func (ef *EthicalFramework) AddRule(ctx context.Context, rule *PolicyRule) (*PolicyRule, error) {
+	if rule == nil {
+		return nil, fmt.Errorf("rule cannot be nil")
+	}
+	if err := rule.normalize(); err != nil {
+		return nil, errs.Wrap(err, errs.Validation, nil)
+	}
+
+	rule.Status = PolicyRuleActive
+	return ef.storeRule(ctx, rule)
+}
+
+// GetRule retrieves a policy rule by ID.
+func (ef *EthicalFramework) GetRule(ctx context.Context, ruleID string) (*PolicyRule, error) {
+	node, err := ef.store.GetNode(ctx, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve policy rule %s: %w", ruleID, err)
+	}
+	if node.Type != "policy_rule" {
+		return nil, errs.Newf(errs.NotFound, "node %s is not a policy rule (type: %s)", ruleID, node.Type).With("id", ruleID)
+	}
+	return nodeToPolicyRule(node), nil
+}
+
+// ListRules returns the active and proposed policy rules, newest first.
+func (ef *EthicalFramework) ListRules(ctx context.Context) ([]*PolicyRule, error) {
+	nodes, err := ef.store.GetNodesByType(ctx, "policy_rule")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policy rules: %w", err)
+	}
+
+	rules := make([]*PolicyRule, 0, len(nodes))
+	for _, node := range nodes {
+		if rule := nodeToPolicyRule(node); rule.Status != PolicyRuleRemoved {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.After(rules[j].CreatedAt)
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, nil
+}
+
+// RemoveRule withdraws a policy rule, or declines a proposed one, for good.
+func (ef *EthicalFramework) RemoveRule(ctx context.Context, ruleID string) error {
+	return ef.setRuleStatus(ctx, ruleID, PolicyRuleRemoved)
+}
+
+// AcceptRule activates a rule proposed from repeated approvals.
+func (ef *EthicalFramework) AcceptRule(ctx context.Context, ruleID string) error {
+	rule, err := ef.GetRule(ctx, ruleID)
+	if err != nil {
+		return err
+	}
+	if rule.Status != PolicyRuleProposed {
+		return fmt.Errorf("policy rule %s is not proposed (current status: %s)", ruleID, rule.Status)
+	}
+	return ef.setRuleStatus(ctx, ruleID, PolicyRuleActive)
+}
+
+// applyPolicy records the decision as the policy rules decide it, without
+// ethical reasoning. It reports false when no rule decides it: none
+// matches, the deciding rule escalates, or an approve rule matches a
+// decision the urgency heuristics would treat as critical.
+func (ef *EthicalFramework) applyPolicy(ctx context.Context, input DecisionInput) (*EthicalDecision, bool, error) {
+	rules, err := ef.ListRules(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	subject := newPolicySubject(input)
+	var rule *PolicyRule
+	for _, candidate := range rules {
+		if candidate.Status != PolicyRuleActive || !candidate.matches(subject) {
+			continue
+		}
+		if rule == nil || policyPrecedence[candidate.Effect] > policyPrecedence[rule.Effect] {
+			rule = candidate
+		}
+	}
+	if rule == nil || rule.Effect == PolicyEscalate {
+		return nil, false, nil
+	}
+	if rule.Effect == PolicyApprove && heuristicallyCritical(input) {
+		return nil, false, nil
+	}
+
+	now := ef.clock.Now()
+	decision := &EthicalDecision{
+		ObjectiveID:        input.ObjectiveID,
+		DecisionContext:    input.DecisionContext,
+		ProposedAction:     input.ProposedAction,
+		AlternativeActions: input.Alternatives,
+		Impact: EthicalImpact{
+			ConfidenceScore: 1.0,
+			Reasoning:       fmt.Sprintf("Decided by policy rule %s: %s", rule.ID, rule),
+		},
+		Urgency:        DecisionUrgencyLow,
+		ApprovalStatus: DecisionApprovalRejected,
+		Outcome:        DecisionOutcomeUnknown,
+		CreatedAt:      now,
+		PolicyRuleID:   rule.ID,
+		UserID:         input.UserID,
+		store:          ef.store,
+	}
+	if rule.Effect == PolicyApprove {
+		decision.ApprovalStatus = DecisionApprovalApproved
+		decision.ApprovedAt = &now
+	}
+
+	if err := ef.storeDecision(ctx, decision); err != nil {
+		return nil, false, fmt.Errorf("failed to store decision: %w", err)
+	}
+	ef.countStats(func(stats *EvaluationStats) {
+		stats.Decisions++
+		stats.PolicyDecisions++
+	})
+
+	rule.UsageCount++
+	rule.LastUsedAt = &now
+	if err := ef.store.UpdateNode(ctx, rule.ID, rule.toData()); err != nil {
+		// Log error but don't fail - the decision is already recorded
+		fmt.Printf("Warning: failed to record use of policy rule %s: %v\n", rule.ID, err)
+	}
+
+	return decision, true, nil
+}
+
+// heuristicallyCritical reports whether a decision is one the urgency
+// heuristics treat as critical without an assessment: its action or
+// context matches a dangerous pattern.
+func heuristicallyCritical(input DecisionInput) bool {
+	return IsDangerousAction(input.ProposedAction) || IsDangerousAction(input.DecisionContext)
+}
+
+// proposeRule proposes a rule approving the action of a decision the user
+// just approved, once the user approved the same action the configured
+// number of times. Actions that were ever critical or dangerous, or that a
+// rule was already proposed for, are not proposed again.
+func (ef *EthicalFramework) proposeRule(ctx context.Context, decision *EthicalDecision) (*PolicyRule, error) {
+	action := normalizeAction(decision.ProposedAction)
+	if decision.Urgency == DecisionUrgencyCritical || IsDangerousAction(action) || IsDangerousAction(decision.DecisionContext) {
+		return nil, nil
+	}
+
+	nodes, err := ef.store.Nodes().OfType("ethical_decision").
+		WithData("approval_status", string(DecisionApprovalApproved)).
+		WithData("user_id", decision.UserID).
+		AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query approved decisions: %w", err)
+	}
+
+	var approvals []string
+	for _, node := range nodes {
+		approved, err := ef.nodeToEthicalDecision(node)
+		if err != nil || normalizeAction(approved.ProposedAction) != action {
+			continue
+		}
+		if approved.Urgency == DecisionUrgencyCritical {
+			return nil, nil
+		}
+		// Only approvals the user gave count
+		if approved.ApprovedByRule != "" || approved.PolicyRuleID != "" || approved.EvaluationFailure != nil {
+			continue
+		}
+		approvals = append(approvals, approved.ID)
+	}
+	if len(approvals) < ef.policyProposalThreshold {
+		return nil, nil
+	}
+
+	existing, err := ef.store.Nodes().OfType("policy_rule").
+		WithData("action", action).
+		WithData("user_id", decision.UserID).
+		AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy rules: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil, nil
+	}
+
+	sort.Strings(approvals)
+	return ef.storeRule(ctx, &PolicyRule{
+		Condition:   PolicyCondition{Action: action},
+		Effect:      PolicyApprove,
+		Status:      PolicyRuleProposed,
+		LearnedFrom: approvals,
+		UserID:      decision.UserID,
+	})
+}
+
+// storeRule stores a new rule with the status it has.
+func (ef *EthicalFramework) storeRule(ctx context.Context, rule *PolicyRule) (*PolicyRule, error) {
+	rule.UsageCount = 0
+	rule.LastUsedAt = nil
+	rule.CreatedAt = ef.clock.Now()
+
+	node := storage.NewNode("policy_rule", rule.toData())
+	if err := ef.store.AddNode(ctx, node); err != nil {
+		return nil, fmt.Errorf("failed to store policy rule: %w", err)
+	}
+	rule.ID = node.ID
+	return rule, nil
+}
+
+// setRuleStatus changes the status of a stored rule.
+func (ef *EthicalFramework) setRuleStatus(ctx context.Context, ruleID string, status PolicyRuleStatus) error {
+	rule, err := ef.GetRule(ctx, ruleID)
+	if err != nil {
+		return err
+	}
+	if rule.Status == PolicyRuleRemoved {
+		return fmt.Errorf("policy rule %s has been removed", ruleID)
+	}
+
+	rule.Status = status
+	if err := ef.store.UpdateNode(ctx, ruleID, rule.toData()); err != nil {
+		return fmt.Errorf("failed to update policy rule %s: %w", ruleID, err)
+	}
+	return nil
+}
+
+// normalize cleans up the rule's condition and checks it is a rule that
+// can be added.
+func (r *PolicyRule) normalize() error {
+	switch r.Effect {
+	case PolicyApprove, PolicyReject, PolicyEscalate:
+	default:
+		return fmt.Errorf("unknown policy effect: %q", r.Effect)
+	}
+
+	c := &r.Condition
+	c.Action = normalizeAction(c.Action)
+	c.ActionKeywords = normalizeKeywords(c.ActionKeywords)
+	c.ContextKeywords = normalizeKeywords(c.ContextKeywords)
+	c.Verb = strings.ToLower(strings.TrimSpace(c.Verb))
+	c.Service = strings.ToLower(strings.TrimSpace(c.Service))
+	c.Host = strings.ToLower(strings.TrimSpace(c.Host))
+	c.ObjectiveID = strings.TrimSpace(c.ObjectiveID)
+
+	switch c.Service {
+	case "", "filesystem", "web", "general":
+	default:
+		return fmt.Errorf("unknown service %q: use filesystem, web or general", c.Service)
+	}
+	if c.PathPrefix = strings.TrimSpace(c.PathPrefix); c.PathPrefix != "" {
+		c.PathPrefix = path.Clean(c.PathPrefix)
+		if !strings.HasPrefix(c.PathPrefix, "/") && !strings.HasPrefix(c.PathPrefix, "~") {
+			return fmt.Errorf("path prefix %q must be absolute", c.PathPrefix)
+		}
+	}
+	if strings.ContainsAny(c.Host, "/*") {
+		return fmt.Errorf("host %q must be a single host name", c.Host)
+	}
+
+	constrainsAction := c.Action != "" || len(c.ActionKeywords) > 0 || c.Verb != "" || c.PathPrefix != "" || c.Host != ""
+	if !constrainsAction && len(c.ContextKeywords) == 0 && c.Service == "" && c.ObjectiveID == "" {
+		return fmt.Errorf("rule has no condition and would match every decision")
+	}
+	if r.Effect != PolicyApprove {
+		return nil
+	}
+
+	// An approve rule must say which actions it approves, and never
+	// approve one that is dangerous
+	if !constrainsAction {
+		return fmt.Errorf("an approve rule must constrain the action, by action, keyword, verb, path or host")
+	}
+	if c.PathPrefix == "/" || c.PathPrefix == "~" {
+		return fmt.Errorf("path prefix %q would approve actions on every file", c.PathPrefix)
+	}
+	for _, words := range append([]string{c.Action, c.Verb}, c.ActionKeywords...) {
+		if IsDangerousAction(words) {
+			return fmt.Errorf("an approve rule cannot match %q, which matches a dangerous pattern", words)
+		}
+	}
+	return nil
+}
+
+// matches reports whether every condition of the rule holds for the decision.
+func (r *PolicyRule) matches(subject policySubject) bool {
+	c := r.Condition
+	switch {
+	case r.UserID != "" && r.UserID != subject.input.UserID:
+		return false
+	case c.ObjectiveID != "" && c.ObjectiveID != subject.input.ObjectiveID:
+		return false
+	case c.Action != "" && c.Action != normalizeAction(subject.input.ProposedAction):
+		return false
+	case c.Verb != "" && c.Verb != subject.target.verb:
+		return false
+	case c.Service != "" && c.Service != subject.target.service:
+		return false
+	case c.Host != "" && (subject.target.kind != ApprovalScopeHost || subject.target.value != c.Host):
+		return false
+	}
+	if c.PathPrefix != "" {
+		// Match whole path components, as approval rules do
+		value := subject.target.value
+		if subject.target.kind != ApprovalScopePathPrefix || value != c.PathPrefix && !strings.HasPrefix(value, c.PathPrefix+"/") {
+			return false
+		}
+	}
+	for _, keyword := range c.ActionKeywords {
+		if !strings.Contains(subject.actionWords, " "+keyword+" ") {
+			return false
+		}
+	}
+	for _, keyword := range c.ContextKeywords {
+		if !strings.Contains(subject.contextWords, " "+keyword+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the rule, e.g. `approve when verb is read and path under /home/me/notes`.
+func (r *PolicyRule) String() string {
+	c := r.Condition
+	var conditions []string
+	if c.Action != "" {
+		conditions = append(conditions, fmt.Sprintf("action is %q", c.Action))
+	}
+	if len(c.ActionKeywords) > 0 {
+		conditions = append(conditions, "action mentions "+quoteAll(c.ActionKeywords))
+	}
+	if len(c.ContextKeywords) > 0 {
+		conditions = append(conditions, "context mentions "+quoteAll(c.ContextKeywords))
+	}
+	if c.Verb != "" {
+		conditions = append(conditions, "verb is "+c.Verb)
+	}
+	if c.Service != "" {
+		conditions = append(conditions, "service is "+c.Service)
+	}
+	if c.PathPrefix != "" {
+		conditions = append(conditions, "path under "+c.PathPrefix)
+	}
+	if c.Host != "" {
+		conditions = append(conditions, "host is "+c.Host)
+	}
+	if c.ObjectiveID != "" {
+		conditions = append(conditions, "objective is "+c.ObjectiveID)
+	}
+	return fmt.Sprintf("%s when %s", r.Effect, strings.Join(conditions, " and "))
+}
+
+// toData converts the rule to node data.
+func (r *PolicyRule) toData() map[string]interface{} {
+	c := r.Condition
+	data := map[string]interface{}{
+		"action":           c.Action,
+		"action_keywords":  c.ActionKeywords,
+		"context_keywords": c.ContextKeywords,
+		"verb":             c.Verb,
+		"service":          c.Service,
+		"path_prefix":      c.PathPrefix,
+		"host":             c.Host,
+		"objective_id":     c.ObjectiveID,
+		"effect":           string(r.Effect),
+		"status":           string(r.Status),
+		"learned_from":     r.LearnedFrom,
+		"usage_count":      r.UsageCount,
+		"created_at":       r.CreatedAt.Format(time.RFC3339),
+		"user_id":          r.UserID,
+	}
+	if r.LastUsedAt != nil {
+		data["last_used_at"] = r.LastUsedAt.Format(time.RFC3339)
+	}
+	return data
+}
+
+// nodeToPolicyRule converts a storage node to a PolicyRule.
+func nodeToPolicyRule(node *storage.Node) *PolicyRule {
+	createdAt, _ := time.Parse(time.RFC3339, getString(node.Data, "created_at"))
+	return &PolicyRule{
+		ID: node.ID,
+		Condition: PolicyCondition{
+			Action:          getString(node.Data, "action"),
+			ActionKeywords:  toStringSlice(node.Data["action_keywords"]),
+			ContextKeywords: toStringSlice(node.Data["context_keywords"]),
+			Verb:            getString(node.Data, "verb"),
+			Service:         getString(node.Data, "service"),
+			PathPrefix:      getString(node.Data, "path_prefix"),
+			Host:            getString(node.Data, "host"),
+			ObjectiveID:     getString(node.Data, "objective_id"),
+		},
+		Effect:      PolicyEffect(getString(node.Data, "effect")),
+		Status:      PolicyRuleStatus(getString(node.Data, "status")),
+		LearnedFrom: toStringSlice(node.Data["learned_from"]),
+		UsageCount:  int(getFloat64(node.Data, "usage_count")),
+		LastUsedAt:  parseOptionalTime(node.Data["last_used_at"]),
+		CreatedAt:   createdAt,
+		UserID:      getString(node.Data, "user_id"),
+	}
+}
+
+// normalizeKeywords lower-cases keywords into the words they are matched
+// by, dropping empty ones.
+func normalizeKeywords(keywords []string) []string {
+	var normalized []string
+	for _, keyword := range keywords {
+		if words := actionWords(keyword); len(words) > 0 {
+			normalized = append(normalized, strings.Join(words, " "))
+		}
+	}
+	return normalized
+}
+
+// quoteAll quotes each word and joins them with commas.
+func quoteAll(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = fmt.Sprintf("%q", word)
+	}
+	return strings.Join(quoted, ", ")
+}
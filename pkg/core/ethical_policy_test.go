@@ -0,0 +1,194 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// pendingReply is an ethical assessment that needs the user's approval.
+const pendingReply = `{"freedom_impact": 0.2, "well_being_impact": 0.3, "sustainability_impact": 0.1, "confidence": 0.8, "reasoning": "Unclear benefit."}`
+
+func TestEthicalFramework_AddRuleValidation(t *testing.T) {
+	ctx := context.Background()
+	ef, _ := newScriptedEthicalFramework(t, nil)
+
+	invalid := []*PolicyRule{
+		{Effect: PolicyApprove},
+		{Effect: "allow", Condition: PolicyCondition{Verb: "read"}},
+		{Effect: PolicyApprove, Condition: PolicyCondition{ContextKeywords: []string{"notes"}}},
+		{Effect: PolicyApprove, Condition: PolicyCondition{ActionKeywords: []string{"Delete"}}},
+		{Effect: PolicyApprove, Condition: PolicyCondition{Verb: "write", PathPrefix: "/"}},
+		{Effect: PolicyReject, Condition: PolicyCondition{PathPrefix: "notes/"}},
+		{Effect: PolicyReject, Condition: PolicyCondition{Service: "email"}},
+	}
+	for _, rule := range invalid {
+		if _, err := ef.AddRule(ctx, rule); !errors.Is(err, errs.Validation) {
+			t.Errorf("Expected %s to be rejected, got %v", rule, err)
+		}
+	}
+
+	rule, err := ef.AddRule(ctx, &PolicyRule{Effect: PolicyApprove, Condition: PolicyCondition{
+		Verb:           " Read",
+		PathPrefix:     "/home/me/notes/",
+		ActionKeywords: []string{"Weekly  Report", " "},
+	}})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+	c := rule.Condition
+	if c.Verb != "read" || c.PathPrefix != "/home/me/notes" || len(c.ActionKeywords) != 1 || c.ActionKeywords[0] != "weekly report" {
+		t.Errorf("Expected the condition normalized, got %+v", c)
+	}
+	if want := `approve when action mentions "weekly report" and verb is read and path under /home/me/notes`; rule.String() != want {
+		t.Errorf("Expected %q, got %q", want, rule.String())
+	}
+
+	// A reject rule needs no constraint on the action
+	if _, err := ef.AddRule(ctx, &PolicyRule{Effect: PolicyReject, Condition: PolicyCondition{ContextKeywords: []string{"payroll"}}}); err != nil {
+		t.Errorf("Expected a reject rule on the context, got %v", err)
+	}
+}
+
+func TestEthicalFramework_PolicyRulesDecideBeforeReasoning(t *testing.T) {
+	ctx := context.Background()
+	ef, service := newScriptedEthicalFramework(t, nil)
+
+	approve, err := ef.AddRule(ctx, &PolicyRule{Effect: PolicyApprove, Condition: PolicyCondition{Verb: "read", PathPrefix: "/home/me/notes"}})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+	reject, err := ef.AddRule(ctx, &PolicyRule{Effect: PolicyReject, Condition: PolicyCondition{ActionKeywords: []string{"unsubscribe"}}})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+	if _, err := ef.AddRule(ctx, &PolicyRule{Effect: PolicyEscalate, Condition: PolicyCondition{ContextKeywords: []string{"finance"}}}); err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	approved, err := ef.EvaluateDecision(ctx, "obj-1", "Weekly report", "read /home/me/notes/todo.md", nil, "user-1")
+	if err != nil {
+		t.Fatalf("EvaluateDecision failed: %v", err)
+	}
+	if approved.ApprovalStatus != DecisionApprovalApproved || approved.PolicyRuleID != approve.ID || approved.ApprovedAt == nil {
+		t.Errorf("Expected the approve rule to decide, got %+v", approved)
+	}
+	if stored, err := ef.GetDecision(ctx, approved.ID); err != nil || stored.PolicyRuleID != approve.ID {
+		t.Errorf("Expected the deciding rule to be stored, got %v", err)
+	}
+
+	rejected, err := ef.EvaluateDecision(ctx, "obj-1", "Weekly report", "Unsubscribe from every list", nil, "user-1")
+	if err != nil {
+		t.Fatalf("EvaluateDecision failed: %v", err)
+	}
+	if !rejected.IsRejected() || rejected.PolicyRuleID != reject.ID {
+		t.Errorf("Expected the reject rule to decide, got %+v", rejected)
+	}
+	if service.calls() != 0 {
+		t.Fatalf("Expected no LLM calls for decisions the rules decide, got %d", service.calls())
+	}
+
+	// An escalate rule outweighs the approve rule, and an approve rule never
+	// decides a dangerous action; both are reasoned about
+	service.script(impactReply, pendingReply)
+	escalated, err := ef.EvaluateDecision(ctx, "obj-1", "Monthly finance review", "read /home/me/notes/budget.md", nil, "user-1")
+	if err != nil || escalated.PolicyRuleID != "" {
+		t.Errorf("Expected the escalated decision reasoned about, got %+v, %v", escalated, err)
+	}
+	dangerous, err := ef.EvaluateDecision(ctx, "obj-1", "Weekly report", "read /home/me/notes/passwords.txt", nil, "user-1")
+	if err != nil || dangerous.PolicyRuleID != "" || dangerous.ApprovalStatus != DecisionApprovalPending {
+		t.Errorf("Expected the dangerous action left to the user, got %+v, %v", dangerous, err)
+	}
+	if service.calls() != 2 {
+		t.Errorf("Expected two LLM calls, got %d", service.calls())
+	}
+
+	decisions, err := ef.EvaluateDecisions(ctx, []DecisionInput{
+		{ObjectiveID: "obj-2", DecisionContext: "Cleanup", ProposedAction: "unsubscribe from newsletters", UserID: "user-1"},
+	})
+	if err != nil || decisions[0].PolicyRuleID != reject.ID || service.calls() != 2 {
+		t.Errorf("Expected batched evaluation to apply the rules too, got %v", err)
+	}
+
+	stats := ef.Stats()
+	if stats.PolicyDecisions != 3 || stats.Decisions != 5 || stats.CallsSaved() != 3 {
+		t.Errorf("Expected three policy decisions, got %+v", stats)
+	}
+	if rule, err := ef.GetRule(ctx, reject.ID); err != nil || rule.UsageCount != 2 || rule.LastUsedAt == nil {
+		t.Errorf("Expected the reject rule's uses counted, got %+v, %v", rule, err)
+	}
+
+	if err := ef.RemoveRule(ctx, approve.ID); err != nil {
+		t.Fatalf("RemoveRule failed: %v", err)
+	}
+	rules, err := ef.ListRules(ctx)
+	if err != nil || len(rules) != 2 {
+		t.Errorf("Expected the removed rule left out of the list, got %d rules, %v", len(rules), err)
+	}
+	service.script(impactReply)
+	if decision, err := ef.EvaluateDecision(ctx, "obj-1", "Weekly report", "read /home/me/notes/todo.md", nil, "user-1"); err != nil || decision.PolicyRuleID != "" {
+		t.Errorf("Expected a removed rule not to decide, got %v", err)
+	}
+	if err := ef.RemoveRule(ctx, approve.ID); err == nil {
+		t.Error("Expected a removed rule not to be removed again")
+	}
+}
+
+func TestEthicalFramework_ProposesRuleAfterRepeatedApprovals(t *testing.T) {
+	ctx := context.Background()
+	ef, service := newScriptedEthicalFramework(t, nil)
+
+	approveAction := func(action string) *EthicalDecision {
+		t.Helper()
+		service.script(pendingReply)
+		decision, err := ef.EvaluateDecision(ctx, "obj-1", "Inbox cleanup", action, nil, "user-1")
+		if err != nil || !decision.IsPendingApproval() {
+			t.Fatalf("Expected %q to await approval, got %v", action, err)
+		}
+		if err := ef.ApproveDecision(ctx, decision.ID, "fine"); err != nil {
+			t.Fatalf("ApproveDecision failed: %v", err)
+		}
+		return decision
+	}
+
+	var approvals []string
+	for i := 0; i < DefaultPolicyProposalThreshold; i++ {
+		if rules, _ := ef.ListRules(ctx); len(rules) != 0 {
+			t.Fatalf("Expected no proposal after %d approvals, got %s", i, rules[0])
+		}
+		approvals = append(approvals, approveAction("Archive old  newsletters").ID)
+		approveAction("delete old newsletters")
+	}
+
+	rules, err := ef.ListRules(ctx)
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("Expected one proposed rule, and none for the dangerous action, got %d, %v", len(rules), err)
+	}
+	proposed := rules[0]
+	if proposed.Status != PolicyRuleProposed || proposed.Effect != PolicyApprove || proposed.Condition.Action != "archive old newsletters" {
+		t.Errorf("Expected a proposal approving the action, got %+v", proposed)
+	}
+	if len(proposed.LearnedFrom) != len(approvals) {
+		t.Errorf("Expected the proposal to name the approvals, got %v", proposed.LearnedFrom)
+	}
+
+	// A proposal does not decide until accepted, and is proposed only once
+	approveAction("archive old newsletters")
+	if rules, _ := ef.ListRules(ctx); len(rules) != 1 {
+		t.Errorf("Expected no second proposal, got %d rules", len(rules))
+	}
+
+	if err := ef.AcceptRule(ctx, proposed.ID); err != nil {
+		t.Fatalf("AcceptRule failed: %v", err)
+	}
+	if err := ef.AcceptRule(ctx, proposed.ID); err == nil {
+		t.Error("Expected an active rule not to be accepted again")
+	}
+	calls := service.calls()
+	decision, err := ef.EvaluateDecision(ctx, "obj-2", "Weekend cleanup", "archive old newsletters", nil, "user-1")
+	if err != nil || decision.PolicyRuleID != proposed.ID || !decision.IsApproved() || service.calls() != calls {
+		t.Errorf("Expected the accepted rule to approve without a call, got %+v, %v", decision, err)
+	}
+}
@@ -0,0 +1,338 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// ProgressKind identifies what happened in an execution to produce an
+// ExecutionProgress event.
+type ProgressKind string
+
+const (
+	// ProgressExecutionStarted is emitted once a plan starts executing
+	ProgressExecutionStarted ProgressKind = "execution_started"
+	// ProgressTaskStarted is emitted before a task's first attempt
+	ProgressTaskStarted ProgressKind = "task_started"
+	// ProgressToolUsed is emitted when the executor reports a tool call
+	ProgressToolUsed ProgressKind = "tool_used"
+	// ProgressTaskFinished is emitted once a task completed or failed
+	ProgressTaskFinished ProgressKind = "task_finished"
+	// ProgressPaused and ProgressResumed are emitted when the execution
+	// holds between tasks for its paused objective, and when it goes on
+	ProgressPaused  ProgressKind = "paused"
+	ProgressResumed ProgressKind = "resumed"
+	// ProgressExecutionFinished is emitted once the execution stopped, for
+	// whatever reason; Status says how
+	ProgressExecutionFinished ProgressKind = "execution_finished"
+)
+
+// TaskProgress is the state of one task of an execution in progress.
+type TaskProgress struct {
+	TaskID      string
+	Description string
+	Status      TaskStatus
+	TokensUsed  int
+	ToolsUsed   []string
+	Duration    time.Duration
+}
+
+// ExecutionProgress is a snapshot of an execution, taken when something in
+// it changed. Each event carries the whole state, so a subscriber that
+// missed events, or attached late, is brought up to date by the next one.
+type ExecutionProgress struct {
+	// Kind is what changed
+	Kind ProgressKind
+
+	ObjectiveID string
+	PlanID      string
+	Status      ExecutionStatus
+
+	// Paused is set while the execution holds for its paused objective
+	Paused bool
+
+	// Tasks holds every task of the plan in execution order
+	Tasks []TaskProgress
+
+	// CurrentTaskID is the task running, or that last ran
+	CurrentTaskID string
+
+	// Tool is the tool reported by a ProgressToolUsed event
+	Tool string
+
+	// TokensUsed is the sum of tokens used by the tasks finished so far
+	TokensUsed int
+
+	StartTime time.Time
+	Elapsed   time.Duration
+}
+
+// CurrentTask returns the state of the task running, or that last ran.
+func (p ExecutionProgress) CurrentTask() (TaskProgress, bool) {
+	for _, task := range p.Tasks {
+		if task.TaskID == p.CurrentTaskID {
+			return task, true
+		}
+	}
+	return TaskProgress{}, false
+}
+
+// ProgressHandler receives the progress events of executions.
+// Handlers are called synchronously from the executing goroutine, so they
+// should return quickly and must not wait on the execution.
+type ProgressHandler func(progress ExecutionProgress)
+
+// progressKey carries the tracker of the execution a task runs in.
+type progressKey struct{}
+
+// ReportToolUse tells the execution a task runs in that the task is calling
+// a tool, for its progress subscribers. Task executors call it as they go;
+// outside an execution it does nothing.
+func ReportToolUse(ctx context.Context, tool string) {
+	if tracker, ok := ctx.Value(progressKey{}).(*progressTracker); ok {
+		tracker.toolUsed(tool)
+	}
+}
+
+// Subscribe registers a handler that is notified as the cursor's executions
+// progress. It returns a function that removes the subscription.
+func (rtc *RealTimeCursor) Subscribe(handler ProgressHandler) func() {
+	rtc.progressMu.Lock()
+	defer rtc.progressMu.Unlock()
+
+	rtc.nextSubscriberID++
+	id := rtc.nextSubscriberID
+	rtc.subscribers[id] = handler
+
+	return func() {
+		rtc.progressMu.Lock()
+		defer rtc.progressMu.Unlock()
+		delete(rtc.subscribers, id)
+	}
+}
+
+// Progress returns the latest snapshot of the objective's execution in
+// progress, if one is running, so a subscriber attaching late can show it
+// before the next event.
+func (rtc *RealTimeCursor) Progress(objectiveID string) (ExecutionProgress, bool) {
+	rtc.progressMu.RLock()
+	defer rtc.progressMu.RUnlock()
+	tracker, ok := rtc.running[objectiveID]
+	if !ok {
+		return ExecutionProgress{}, false
+	}
+	return tracker.snapshot(""), true
+}
+
+// publishProgress delivers an event to all subscribers in subscription order.
+func (rtc *RealTimeCursor) publishProgress(progress ExecutionProgress) {
+	rtc.progressMu.RLock()
+	ids := make([]int, 0, len(rtc.subscribers))
+	for id := range rtc.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	handlers := make([]ProgressHandler, 0, len(ids))
+	for _, id := range ids {
+		handlers = append(handlers, rtc.subscribers[id])
+	}
+	rtc.progressMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(progress)
+	}
+}
+
+// progressTracker follows one execution for the cursor's subscribers.
+type progressTracker struct {
+	rtc    *RealTimeCursor
+	result *ExecutionResult
+
+	// The tracker keeps its own copy of what subscribers may read from
+	// other goroutines while the execution updates its result
+	mu      sync.Mutex
+	status  ExecutionStatus
+	tasks   []TaskProgress
+	index   map[string]int
+	current string
+	paused  bool
+	elapsed time.Duration // Set once the execution finished
+}
+
+// trackProgress starts following an execution of the ordered tasks and
+// announces it. The returned context lets executors report tool use.
+func (rtc *RealTimeCursor) trackProgress(ctx context.Context, result *ExecutionResult, taskOrder []*ExecutionTask) (context.Context, *progressTracker) {
+	tracker := &progressTracker{
+		rtc:    rtc,
+		result: result,
+		status: result.Status,
+		tasks:  make([]TaskProgress, len(taskOrder)),
+		index:  make(map[string]int, len(taskOrder)),
+	}
+	for i, task := range taskOrder {
+		tracker.tasks[i] = TaskProgress{TaskID: task.ID, Description: task.Description, Status: TaskStatusPending}
+		if done, ok := result.TaskResults[task.ID]; ok {
+			tracker.tasks[i] = taskProgressFrom(task, done)
+		}
+		tracker.index[task.ID] = i
+	}
+
+	rtc.progressMu.Lock()
+	rtc.running[result.ObjectiveID] = tracker
+	rtc.progressMu.Unlock()
+
+	tracker.publish(ProgressExecutionStarted, "")
+	return context.WithValue(ctx, progressKey{}, tracker), tracker
+}
+
+// taskProgressFrom returns the state of a task from its result.
+func taskProgressFrom(task *ExecutionTask, result *TaskResult) TaskProgress {
+	return TaskProgress{
+		TaskID:      task.ID,
+		Description: task.Description,
+		Status:      result.Status,
+		TokensUsed:  result.TokensUsed,
+		ToolsUsed:   append([]string(nil), result.ToolsUsed...),
+		Duration:    result.Duration,
+	}
+}
+
+func (t *progressTracker) taskStarted(task *ExecutionTask) {
+	t.mu.Lock()
+	t.current = task.ID
+	t.tasks[t.index[task.ID]].Status = TaskStatusRunning
+	t.mu.Unlock()
+	t.publish(ProgressTaskStarted, "")
+}
+
+func (t *progressTracker) toolUsed(tool string) {
+	t.mu.Lock()
+	if i, ok := t.index[t.current]; ok {
+		t.tasks[i].ToolsUsed = append(t.tasks[i].ToolsUsed, tool)
+	}
+	t.mu.Unlock()
+	t.publish(ProgressToolUsed, tool)
+}
+
+func (t *progressTracker) taskFinished(task *ExecutionTask, result *TaskResult) {
+	t.mu.Lock()
+	// Tools the executor reported as it went stand for those in its result
+	progress := taskProgressFrom(task, result)
+	if reported := t.tasks[t.index[task.ID]].ToolsUsed; len(reported) > 0 {
+		progress.ToolsUsed = reported
+	}
+	t.tasks[t.index[task.ID]] = progress
+	t.mu.Unlock()
+	t.publish(ProgressTaskFinished, "")
+}
+
+func (t *progressTracker) setPaused(paused bool) {
+	t.mu.Lock()
+	t.paused = paused
+	t.mu.Unlock()
+	if paused {
+		t.publish(ProgressPaused, "")
+	} else {
+		t.publish(ProgressResumed, "")
+	}
+}
+
+// finish announces the end of the execution, with the status it ended in,
+// and stops following it.
+func (t *progressTracker) finish() {
+	t.mu.Lock()
+	t.status = t.result.Status
+	t.elapsed = t.result.TotalDuration
+	if t.result.EndTime.IsZero() {
+		t.elapsed = t.result.elapsed()
+	}
+	t.mu.Unlock()
+
+	t.rtc.progressMu.Lock()
+	if t.rtc.running[t.result.ObjectiveID] == t {
+		delete(t.rtc.running, t.result.ObjectiveID)
+	}
+	t.rtc.progressMu.Unlock()
+	t.publish(ProgressExecutionFinished, "")
+}
+
+func (t *progressTracker) publish(kind ProgressKind, tool string) {
+	progress := t.snapshot(tool)
+	progress.Kind = kind
+	t.rtc.publishProgress(progress)
+}
+
+// snapshot returns the execution's current state.
+func (t *progressTracker) snapshot(tool string) ExecutionProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	progress := ExecutionProgress{
+		ObjectiveID:   t.result.ObjectiveID,
+		PlanID:        t.result.PlanID,
+		Status:        t.status,
+		Paused:        t.paused,
+		Tasks:         make([]TaskProgress, len(t.tasks)),
+		CurrentTaskID: t.current,
+		Tool:          tool,
+		StartTime:     t.result.StartTime,
+		Elapsed:       t.result.elapsed(),
+	}
+	for i, task := range t.tasks {
+		task.ToolsUsed = append([]string(nil), task.ToolsUsed...)
+		progress.Tasks[i] = task
+		progress.TokensUsed += task.TokensUsed
+	}
+	if t.elapsed > 0 {
+		progress.Elapsed = t.elapsed
+	}
+	return progress
+}
+
+// waitWhilePaused holds an execution between tasks while its objective is
+// paused, until the objective is resumed or ctx is done. Pausing the
+// objective through the ObjectiveManager is what pauses its execution.
+func (rtc *RealTimeCursor) waitWhilePaused(ctx context.Context, objectiveID string, tracker *progressTracker) error {
+	if rtc.store == nil || objectiveID == "" {
+		return nil
+	}
+
+	changed := make(chan struct{}, 1)
+	unsubscribe := rtc.store.Subscribe(func(event storage.ChangeEvent) {
+		if event.Node != nil && event.Node.ID != objectiveID {
+			return
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	paused := false
+	defer func() {
+		if paused {
+			tracker.setPaused(false)
+		}
+	}()
+	for {
+		objective, err := NewObjectiveManager(rtc.store).GetObjective(ctx, objectiveID)
+		if err != nil || objective.Status != ObjectiveStatusPaused {
+			return nil
+		}
+		if !paused {
+			paused = true
+			tracker.setPaused(true)
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
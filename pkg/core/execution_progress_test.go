@@ -0,0 +1,222 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// toolReportingExecutor reports a tool call for each task and records the
+// progress the cursor held for the objective while the task ran.
+type toolReportingExecutor struct {
+	MockTaskExecutor
+	rtc    *RealTimeCursor
+	during []ExecutionProgress
+	onTask func(task *ExecutionTask)
+}
+
+func (e *toolReportingExecutor) ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error) {
+	ReportToolUse(ctx, "search")
+	objectiveID, _ := fullContext[llm.MetadataObjectiveID].(string)
+	if progress, ok := e.rtc.Progress(objectiveID); ok {
+		e.during = append(e.during, progress)
+	}
+	if e.onTask != nil {
+		e.onTask(task)
+	}
+	return &TaskResult{Status: TaskStatusCompleted, TokensUsed: 100, ToolsUsed: []string{"llm"}}, nil
+}
+
+// progressRecorder collects the progress events delivered to it.
+type progressRecorder struct {
+	mu     sync.Mutex
+	events []ExecutionProgress
+}
+
+func (r *progressRecorder) handle(progress ExecutionProgress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, progress)
+}
+
+func (r *progressRecorder) kinds() []ProgressKind {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kinds := make([]ProgressKind, len(r.events))
+	for i, event := range r.events {
+		kinds[i] = event.Kind
+	}
+	return kinds
+}
+
+func (r *progressRecorder) last() ExecutionProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events[len(r.events)-1]
+}
+
+// startTestObjective creates an objective and starts it.
+func startTestObjective(t *testing.T, store *storage.Store) *Objective {
+	t.Helper()
+	ctx := context.Background()
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Ship", "Ship the release", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Write", "Write it down", []ApproachStep{}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	manager := NewObjectiveManager(store)
+	objective, err := manager.CreateObjective(ctx, goal.ID, method.ID, "Release notes", "Write the notes", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	if _, err := manager.StartObjective(ctx, objective.ID); err != nil {
+		t.Fatalf("Failed to start objective: %v", err)
+	}
+	return objective
+}
+
+func TestExecutionProgress_Events(t *testing.T) {
+	rtc, _, _, _ := setupTestRTC(t)
+	executor := &toolReportingExecutor{rtc: rtc}
+	rtc.executor = executor
+	recorder := &progressRecorder{}
+	unsubscribe := rtc.Subscribe(recorder.handle)
+
+	plan := createTestPlan()
+	if _, err := rtc.ExecutePlan(context.Background(), plan); err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+
+	expected := []ProgressKind{
+		ProgressExecutionStarted,
+		ProgressTaskStarted, ProgressToolUsed, ProgressTaskFinished,
+		ProgressTaskStarted, ProgressToolUsed, ProgressTaskFinished,
+		ProgressExecutionFinished,
+	}
+	kinds := recorder.kinds()
+	if len(kinds) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, kinds)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Errorf("Event %d: expected %s, got %s", i, expected[i], kinds[i])
+		}
+	}
+
+	final := recorder.last()
+	if final.Status != ExecutionStatusCompleted || final.TokensUsed != 200 || final.ObjectiveID != plan.ObjectiveID || final.Elapsed <= 0 {
+		t.Errorf("Expected the completed execution's totals, got %+v", final)
+	}
+	for _, task := range final.Tasks {
+		if task.Status != TaskStatusCompleted || len(task.ToolsUsed) != 1 || task.ToolsUsed[0] != "search" {
+			t.Errorf("Expected each task completed with its reported tool, got %+v", task)
+		}
+	}
+
+	// While a task ran, its progress could be looked up
+	if len(executor.during) != 2 {
+		t.Fatalf("Expected the execution's progress during each task, got %d", len(executor.during))
+	}
+	second := executor.during[1]
+	if second.CurrentTaskID != "task_2" || second.TokensUsed != 100 || second.Tasks[0].Status != TaskStatusCompleted {
+		t.Errorf("Expected the second task running after the first, got %+v", second)
+	}
+	if current, ok := second.CurrentTask(); !ok || current.Status != TaskStatusRunning || current.ToolsUsed[0] != "search" {
+		t.Errorf("Expected the running task's tool use, got %+v", current)
+	}
+	if _, ok := rtc.Progress(plan.ObjectiveID); ok {
+		t.Error("Expected no progress once the execution finished")
+	}
+
+	// Unsubscribed handlers hear nothing more
+	unsubscribe()
+	if _, err := rtc.ExecutePlan(context.Background(), createTestPlan()); err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+	if len(recorder.kinds()) != len(expected) {
+		t.Error("Expected no events after unsubscribing")
+	}
+}
+
+func TestExecutionProgress_PauseHoldsBetweenTasks(t *testing.T) {
+	rtc, store, _, _ := setupTestRTC(t)
+	ctx := context.Background()
+	manager := NewObjectiveManager(store)
+	objective := startTestObjective(t, store)
+
+	// The objective is paused during the first task, and resumed once the
+	// execution reports it holds
+	executor := &toolReportingExecutor{rtc: rtc}
+	executor.onTask = func(task *ExecutionTask) {
+		if task.ID == "task_1" {
+			if _, err := manager.PauseObjective(ctx, objective.ID); err != nil {
+				t.Errorf("Failed to pause: %v", err)
+			}
+		}
+	}
+	rtc.executor = executor
+	recorder := &progressRecorder{}
+	defer rtc.Subscribe(recorder.handle)()
+	defer rtc.Subscribe(func(progress ExecutionProgress) {
+		if progress.Kind == ProgressPaused {
+			go manager.ResumeObjective(ctx, objective.ID)
+		}
+	})()
+
+	plan := createTestPlan()
+	plan.ObjectiveID = objective.ID
+	if _, err := rtc.ExecutePlan(ctx, plan); err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+
+	kinds := recorder.kinds()
+	paused, resumed, secondTask := -1, -1, -1
+	for i, kind := range kinds {
+		switch {
+		case kind == ProgressPaused:
+			paused = i
+		case kind == ProgressResumed:
+			resumed = i
+		case kind == ProgressTaskStarted && paused >= 0:
+			secondTask = i
+		}
+	}
+	if paused < 0 || resumed < paused || secondTask < resumed {
+		t.Errorf("Expected the second task to start only after the pause was lifted, got %v", kinds)
+	}
+}
+
+func TestExecutionProgress_CancelWhilePaused(t *testing.T) {
+	rtc, store, _, _ := setupTestRTC(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	objective := startTestObjective(t, store)
+	if _, err := NewObjectiveManager(store).PauseObjective(ctx, objective.ID); err != nil {
+		t.Fatalf("Failed to pause: %v", err)
+	}
+
+	rtc.executor = &toolReportingExecutor{rtc: rtc}
+	defer rtc.Subscribe(func(progress ExecutionProgress) {
+		if progress.Kind == ProgressPaused {
+			time.AfterFunc(10*time.Millisecond, cancel)
+		}
+	})()
+
+	plan := createTestPlan()
+	plan.ObjectiveID = objective.ID
+	result, err := rtc.ExecutePlan(ctx, plan)
+	if !errors.Is(err, context.Canceled) || result.Status != ExecutionStatusCancelled {
+		t.Fatalf("Expected the paused execution to be cancelled, got %v, %v", result.Status, err)
+	}
+	if len(result.TaskResults) != 0 {
+		t.Errorf("Expected no task to run while paused, got %d", len(result.TaskResults))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
 )
 
@@ -53,6 +54,10 @@ type Goal struct {
 	// CreatedAt is when this goal was originally created
 	CreatedAt time.Time
 
+	// Archive records why and how the goal was archived; nil unless its
+	// status is archived and it was archived through ArchiveGoal
+	Archive *GoalArchive
+
 	// store reference for database operations
 	store *storage.Store
 }
@@ -72,10 +77,10 @@ func NewGoalManager(store *storage.Store) *GoalManager {
 // CreateGoal creates a new goal and stores it in the system.
 func (gm *GoalManager) CreateGoal(ctx context.Context, title, description string, priority int, userContext map[string]interface{}) (*Goal, error) {
 	if title == "" {
-		return nil, fmt.Errorf("goal title cannot be empty")
+		return nil, errs.New(errs.Validation, "goal title cannot be empty")
 	}
 	if priority < 1 || priority > 10 {
-		return nil, fmt.Errorf("priority must be between 1 and 10, got %d", priority)
+		return nil, errs.Newf(errs.Validation, "priority must be between 1 and 10, got %d", priority)
 	}
 
 	now := time.Now()
@@ -121,7 +126,7 @@ func (gm *GoalManager) GetGoal(ctx context.Context, goalID string) (*Goal, error
 	}
 
 	if node.Type != "goal" {
-		return nil, fmt.Errorf("node %s is not a goal (type: %s)", goalID, node.Type)
+		return nil, errs.Newf(errs.NotFound, "node %s is not a goal (type: %s)", goalID, node.Type).With("id", goalID)
 	}
 
 	return gm.nodeToGoal(node)
@@ -135,7 +140,7 @@ func (gm *GoalManager) GetGoalAtTime(ctx context.Context, goalID string, timesta
 	}
 
 	if node.Type != "goal" {
-		return nil, fmt.Errorf("node %s is not a goal (type: %s)", goalID, node.Type)
+		return nil, errs.Newf(errs.NotFound, "node %s is not a goal (type: %s)", goalID, node.Type).With("id", goalID)
 	}
 
 	return gm.nodeToGoal(node)
@@ -154,7 +159,7 @@ func (gm *GoalManager) UpdateGoal(ctx context.Context, goalID string, updates Go
 	if updates.Title != nil {
 		title = *updates.Title
 		if title == "" {
-			return nil, fmt.Errorf("goal title cannot be empty")
+			return nil, errs.New(errs.Validation, "goal title cannot be empty")
 		}
 	}
 
@@ -175,7 +180,7 @@ func (gm *GoalManager) UpdateGoal(ctx context.Context, goalID string, updates Go
 	if updates.Priority != nil {
 		priority = *updates.Priority
 		if priority < 1 || priority > 10 {
-			return nil, fmt.Errorf("priority must be between 1 and 10, got %d", priority)
+			return nil, errs.Newf(errs.Validation, "priority must be between 1 and 10, got %d", priority)
 		}
 	}
 
@@ -184,23 +189,13 @@ func (gm *GoalManager) UpdateGoal(ctx context.Context, goalID string, updates Go
 		userContext = updates.UserContext
 	}
 
-	// Prepare updated data
-	data := map[string]interface{}{
-		"title":        title,
-		"description":  description,
-		"status":       string(status),
-		"priority":     priority,
-		"user_context": userContext,
-		"created_at":   currentGoal.CreatedAt.Format(time.RFC3339),
-	}
-
-	// Update in storage
-	if err := gm.store.UpdateNode(ctx, goalID, data); err != nil {
-		return nil, fmt.Errorf("failed to update goal: %w", err)
+	// The archive record lasts as long as the goal stays archived
+	archive := currentGoal.Archive
+	if status != GoalStatusArchived {
+		archive = nil
 	}
 
-	// Return updated goal
-	return &Goal{
+	updated := &Goal{
 		ID:          goalID,
 		Title:       title,
 		Description: description,
@@ -208,8 +203,30 @@ func (gm *GoalManager) UpdateGoal(ctx context.Context, goalID string, updates Go
 		Priority:    priority,
 		UserContext: userContext,
 		CreatedAt:   currentGoal.CreatedAt,
+		Archive:     archive,
 		store:       gm.store,
-	}, nil
+	}
+
+	// Update in storage
+	if err := gm.store.UpdateNode(ctx, goalID, goalData(updated)); err != nil {
+		return nil, fmt.Errorf("failed to update goal: %w", err)
+	}
+
+	return updated, nil
+}
+
+// goalData returns the stored form of a goal, as read back by nodeToGoal.
+func goalData(goal *Goal) map[string]interface{} {
+	data := map[string]interface{}{
+		"title":        goal.Title,
+		"description":  goal.Description,
+		"status":       string(goal.Status),
+		"priority":     goal.Priority,
+		"user_context": goal.UserContext,
+		"created_at":   goal.CreatedAt.Format(time.RFC3339),
+	}
+	setGoalArchiveData(data, goal.Archive)
+	return data
 }
 
 // GoalUpdates defines the fields that can be updated in a goal.
@@ -222,10 +239,17 @@ type GoalUpdates struct {
 	UserContext map[string]interface{}
 }
 
-// ListGoals returns all goals with optional filtering.
+// ListGoals returns all goals with optional filtering. Archived goals are
+// left out unless the filter includes them or asks for the archived status.
 func (gm *GoalManager) ListGoals(ctx context.Context, filter GoalFilter) ([]*Goal, error) {
 	query := gm.store.Nodes().OfType("goal")
 
+	// Goals moved to the archive are listed on request, or when asking for archived goals
+	listArchived := filter.IncludeArchived || (filter.Status != nil && *filter.Status == GoalStatusArchived)
+	if listArchived {
+		query = query.IncludeArchived()
+	}
+
 	// Apply status filter if specified
 	if filter.Status != nil {
 		query = query.WithData("status", string(*filter.Status))
@@ -237,7 +261,7 @@ func (gm *GoalManager) ListGoals(ctx context.Context, filter GoalFilter) ([]*Goa
 		// For now, we'll get all and filter in memory
 	}
 
-	nodes, err := query.All()
+	nodes, err := query.AllContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query goals: %w", err)
 	}
@@ -249,6 +273,10 @@ func (gm *GoalManager) ListGoals(ctx context.Context, filter GoalFilter) ([]*Goa
 			continue // Skip invalid nodes
 		}
 
+		if goal.Status == GoalStatusArchived && !listArchived {
+			continue
+		}
+
 		// Apply priority filter in memory (custom filtering)
 		if filter.MinPriority != nil && goal.Priority < *filter.MinPriority {
 			continue
@@ -268,6 +296,10 @@ type GoalFilter struct {
 	Status      *GoalStatus
 	MinPriority *int
 	MaxPriority *int
+
+	// IncludeArchived also lists archived goals, including those moved to
+	// the archive (see ArchiveManager)
+	IncludeArchived bool
 }
 
 // AddSubGoal creates a hierarchical relationship where the subgoal serves the parent goal.
@@ -289,7 +321,10 @@ func (gm *GoalManager) AddSubGoal(ctx context.Context, parentGoalID, subGoalID s
 		"created_at":   time.Now().Format(time.RFC3339),
 	})
 
-	if err := gm.store.AddEdge(ctx, edge); err != nil {
+	err = gm.store.Batch(ctx, func(tx *storage.Tx) error {
+		return tx.AddEdge(edge)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to create goal hierarchy relationship: %w", err)
 	}
 
@@ -298,17 +333,21 @@ func (gm *GoalManager) AddSubGoal(ctx context.Context, parentGoalID, subGoalID s
 
 // GetSubGoals returns all goals that serve the given parent goal.
 func (gm *GoalManager) GetSubGoals(ctx context.Context, parentGoalID string) ([]*Goal, error) {
-	// Find all edges of type "serves" targeting the parent goal
-	edges, err := gm.store.Edges().OfType("serves").ToNode(parentGoalID).All()
+	// Sub-goals are the goals with "serves" edges to the parent
+	neighbors, err := gm.store.GetNeighbors(ctx, parentGoalID, storage.NeighborOptions{
+		Direction: storage.DirectionIncoming,
+		EdgeTypes: []string{"serves"},
+		NodeTypes: []string{"goal"},
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sub-goal relationships: %w", err)
 	}
 
 	var subGoals []*Goal
-	for _, edge := range edges {
-		subGoal, err := gm.GetGoal(ctx, edge.SourceID)
+	for _, node := range neighbors.Nodes {
+		subGoal, err := gm.nodeToGoal(node)
 		if err != nil {
-			continue // Skip if sub-goal no longer exists
+			continue // Skip invalid goals
 		}
 		subGoals = append(subGoals, subGoal)
 	}
@@ -316,19 +355,25 @@ func (gm *GoalManager) GetSubGoals(ctx context.Context, parentGoalID string) ([]
 	return subGoals, nil
 }
 
-// GetParentGoals returns all goals that this goal serves.
+// GetParentGoals returns all goals that this goal serves, archived ones
+// included.
 func (gm *GoalManager) GetParentGoals(ctx context.Context, subGoalID string) ([]*Goal, error) {
-	// Find all edges of type "serves" originating from the sub-goal
-	edges, err := gm.store.Edges().OfType("serves").FromNode(subGoalID).All()
+	// Parent goals are the goals the sub-goal has "serves" edges to
+	neighbors, err := gm.store.GetNeighbors(ctx, subGoalID, storage.NeighborOptions{
+		Direction:       storage.DirectionOutgoing,
+		EdgeTypes:       []string{"serves"},
+		NodeTypes:       []string{"goal"},
+		IncludeArchived: true,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to query parent-goal relationships: %w", err)
 	}
 
 	var parentGoals []*Goal
-	for _, edge := range edges {
-		parentGoal, err := gm.GetGoal(ctx, edge.TargetID)
+	for _, node := range neighbors.Nodes {
+		parentGoal, err := gm.nodeToGoal(node)
 		if err != nil {
-			continue // Skip if parent goal no longer exists
+			continue // Skip invalid goals
 		}
 		parentGoals = append(parentGoals, parentGoal)
 	}
@@ -339,7 +384,7 @@ func (gm *GoalManager) GetParentGoals(ctx context.Context, subGoalID string) ([]
 // RemoveSubGoal removes a hierarchical relationship between goals.
 func (gm *GoalManager) RemoveSubGoal(ctx context.Context, parentGoalID, subGoalID string) error {
 	// Find the edge representing this relationship
-	edges, err := gm.store.Edges().OfType("serves").FromNode(subGoalID).ToNode(parentGoalID).All()
+	edges, err := gm.store.Edges().OfType("serves").BetweenNodes(subGoalID, parentGoalID).AllContext(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to query goal relationship: %w", err)
 	}
@@ -406,6 +451,7 @@ func (gm *GoalManager) nodeToGoal(node *storage.Node) (*Goal, error) {
 		Priority:    int(priority),
 		UserContext: userContext,
 		CreatedAt:   createdAt,
+		Archive:     goalArchiveFromData(node.Data, status),
 		store:       gm.store,
 	}, nil
 }
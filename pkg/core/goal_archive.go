@@ -0,0 +1,314 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// archiveCascadeContextKey marks, in an objective's context, the goal whose
+// archiving cancelled it. Unarchiving that goal returns exactly these
+// objectives to pending, not ones the user cancelled themselves.
+const archiveCascadeContextKey = "cancelled_by_archive"
+
+// GoalArchive records how a goal was archived.
+type GoalArchive struct {
+	// Reason is why the goal was archived, stored as archived_reason
+	Reason string
+
+	// ArchivedAt is when the goal was archived
+	ArchivedAt time.Time
+
+	// PreviousStatus is the status unarchiving returns the goal to
+	PreviousStatus GoalStatus
+
+	// ByGoal is the parent whose archiving archived this sub-goal with it;
+	// empty when the goal was archived itself
+	ByGoal string
+}
+
+// ArchiveOptions configures GoalManager.ArchiveGoal.
+type ArchiveOptions struct {
+	// Reason is recorded with the goal and any sub-goals archived with it
+	Reason string
+
+	// Recursive archives the goal's sub-goals along with it, except those
+	// that still serve another goal that is not being archived
+	Recursive bool
+}
+
+// GoalArchiveResult reports what ArchiveGoal or UnarchiveGoal changed.
+type GoalArchiveResult struct {
+	// Goals are the goals archived or unarchived, the requested one first
+	Goals []*Goal
+
+	// Objectives are the objectives cancelled, or returned to pending
+	Objectives []*Objective
+
+	// Running are objectives of archived goals that were in progress; they
+	// are left to finish
+	Running []*Objective
+
+	// SubGoals are sub-goals of archived goals that were left as they were,
+	// because Recursive was not set or they still serve another goal
+	SubGoals []*Goal
+}
+
+// ArchiveGoal archives a goal, so it drops out of listings and progress.
+// Its pending and paused objectives are cancelled and marked as cancelled by
+// the archive; objectives in progress are left to finish. With Recursive set
+// its sub-goals are archived with it; otherwise the sub-goals that could have
+// been are reported in SubGoals.
+func (gm *GoalManager) ArchiveGoal(ctx context.Context, goalID string, opts ArchiveOptions) (*GoalArchiveResult, error) {
+	goal, err := gm.GetGoal(ctx, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if goal.Status == GoalStatusArchived {
+		return nil, errs.Newf(errs.Conflict, "goal %s is already archived", goalID).With("goal_id", goalID)
+	}
+
+	graph, err := gm.loadGoalGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	graph.goals[goalID] = goal
+	archiving := graph.archiveSet(goalID, opts.Recursive)
+
+	// Each goal reached from the requested one is archived by the parent it
+	// was first reached through
+	result := &GoalArchiveResult{}
+	order := []string{goalID}
+	archivedBy := map[string]string{goalID: ""}
+	kept := make(map[string]bool)
+	for i := 0; i < len(order); i++ {
+		for _, child := range graph.children[order[i]] {
+			if !archiving[child] {
+				if !kept[child] {
+					kept[child] = true
+					result.SubGoals = append(result.SubGoals, graph.goals[child])
+				}
+				continue
+			}
+			if _, seen := archivedBy[child]; !seen {
+				archivedBy[child] = order[i]
+				order = append(order, child)
+			}
+		}
+	}
+
+	// Sub-goals are archived before their parents, so an archive that stops
+	// part way can be run again
+	now := time.Now()
+	objectives := NewObjectiveManager(gm.store)
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		cancelled, running, err := objectives.cancelForArchive(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		result.Objectives = append(result.Objectives, cancelled...)
+		result.Running = append(result.Running, running...)
+
+		archived := *graph.goals[id]
+		archived.Status = GoalStatusArchived
+		archived.Archive = &GoalArchive{
+			Reason:         opts.Reason,
+			ArchivedAt:     now,
+			PreviousStatus: graph.goals[id].Status,
+			ByGoal:         archivedBy[id],
+		}
+		if err := gm.store.UpdateNode(ctx, id, goalData(&archived)); err != nil {
+			return nil, fmt.Errorf("failed to archive goal %s: %w", id, err)
+		}
+		result.Goals = append([]*Goal{&archived}, result.Goals...)
+	}
+
+	return result, nil
+}
+
+// UnarchiveGoal returns an archived goal to the status it had before, along
+// with the sub-goals archived with it. Only the objectives the archive
+// cancelled go back to pending; ones cancelled otherwise stay cancelled.
+func (gm *GoalManager) UnarchiveGoal(ctx context.Context, goalID string) (*GoalArchiveResult, error) {
+	goal, err := gm.GetGoal(ctx, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if goal.Status != GoalStatusArchived {
+		return nil, errs.Newf(errs.Conflict, "goal %s is not archived, current status: %s", goalID, goal.Status).With("goal_id", goalID)
+	}
+
+	// Sub-goals archived independently stay archived
+	order := []*Goal{goal}
+	seen := map[string]bool{goalID: true}
+	for i := 0; i < len(order); i++ {
+		children, err := gm.store.GetNeighbors(ctx, order[i].ID, storage.NeighborOptions{
+			Direction:       storage.DirectionIncoming,
+			EdgeTypes:       []string{"serves"},
+			NodeTypes:       []string{"goal"},
+			IncludeArchived: true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query sub-goal relationships: %w", err)
+		}
+		for _, node := range children.Nodes {
+			child, err := gm.nodeToGoal(node)
+			if err != nil || seen[child.ID] || child.Archive == nil || child.Archive.ByGoal != order[i].ID {
+				continue
+			}
+			seen[child.ID] = true
+			order = append(order, child)
+		}
+	}
+
+	result := &GoalArchiveResult{}
+	objectives := NewObjectiveManager(gm.store)
+	for i := len(order) - 1; i >= 0; i-- {
+		restored, err := objectives.restoreFromArchive(ctx, order[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Objectives = append(result.Objectives, restored...)
+
+		unarchived := *order[i]
+		unarchived.Status = GoalStatusActive
+		if unarchived.Archive != nil && unarchived.Archive.PreviousStatus != "" && unarchived.Archive.PreviousStatus != GoalStatusArchived {
+			unarchived.Status = unarchived.Archive.PreviousStatus
+		}
+		unarchived.Archive = nil
+		if err := gm.store.UpdateNode(ctx, unarchived.ID, goalData(&unarchived)); err != nil {
+			return nil, fmt.Errorf("failed to unarchive goal %s: %w", unarchived.ID, err)
+		}
+		result.Goals = append([]*Goal{&unarchived}, result.Goals...)
+	}
+
+	return result, nil
+}
+
+// archiveSet returns the goals archiving goalID archives: the goal itself
+// and, if recursive, every sub-goal below it whose parents are all archived
+// along with it.
+func (g *goalGraph) archiveSet(goalID string, recursive bool) map[string]bool {
+	if !recursive {
+		return map[string]bool{goalID: true}
+	}
+	set := make(map[string]bool)
+	g.walk(goalID, set)
+
+	// Leaving out a shared sub-goal can leave out the goals below it too
+	for changed := true; changed; {
+		changed = false
+		for id := range set {
+			if id == goalID {
+				continue
+			}
+			for _, parent := range g.parents[id] {
+				if !set[parent] {
+					delete(set, id)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return set
+}
+
+// cancelForArchive cancels the pending and paused objectives of a goal being
+// archived, marking them as cancelled by the archive. It returns them, and
+// the objectives left in progress.
+func (om *ObjectiveManager) cancelForArchive(ctx context.Context, goalID string) ([]*Objective, []*Objective, error) {
+	objectives, err := om.ListObjectives(ctx, ObjectiveFilter{GoalID: &goalID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cancelled, running []*Objective
+	for _, objective := range objectives {
+		switch objective.Status {
+		case ObjectiveStatusInProgress:
+			running = append(running, objective)
+			continue
+		case ObjectiveStatusPending, ObjectiveStatusPaused:
+		default:
+			continue
+		}
+
+		context := copyObjectiveContext(objective.Context)
+		context[archiveCascadeContextKey] = goalID
+		status := ObjectiveStatusCancelled
+		updated, err := om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{Status: &status, Context: context})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to cancel objective %s: %w", objective.ID, err)
+		}
+		cancelled = append(cancelled, updated)
+	}
+	return cancelled, running, nil
+}
+
+// restoreFromArchive returns the objectives the archiving of a goal cancelled
+// to pending.
+func (om *ObjectiveManager) restoreFromArchive(ctx context.Context, goalID string) ([]*Objective, error) {
+	cancelled := ObjectiveStatusCancelled
+	objectives, err := om.ListObjectives(ctx, ObjectiveFilter{GoalID: &goalID, Status: &cancelled, IncludeArchived: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []*Objective
+	for _, objective := range objectives {
+		if !objective.CancelledByArchive() {
+			continue
+		}
+		context := copyObjectiveContext(objective.Context)
+		delete(context, archiveCascadeContextKey)
+		status := ObjectiveStatusPending
+		updated, err := om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{Status: &status, Context: context})
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore objective %s: %w", objective.ID, err)
+		}
+		restored = append(restored, updated)
+	}
+	return restored, nil
+}
+
+// CancelledByArchive returns true if the objective was cancelled because its
+// goal was archived.
+func (o *Objective) CancelledByArchive() bool {
+	return o.Status == ObjectiveStatusCancelled && getString(o.Context, archiveCascadeContextKey) == o.GoalID
+}
+
+// setGoalArchiveData stores a goal's archive record in its node data.
+func setGoalArchiveData(data map[string]interface{}, archive *GoalArchive) {
+	if archive == nil {
+		return
+	}
+	data["archived_reason"] = archive.Reason
+	data["archived_at"] = archive.ArchivedAt.Format(time.RFC3339Nano)
+	data["archived_from"] = string(archive.PreviousStatus)
+	if archive.ByGoal != "" {
+		data["archived_by_goal"] = archive.ByGoal
+	}
+}
+
+// goalArchiveFromData reads a goal's archive record; nil unless the goal is
+// archived and has one.
+func goalArchiveFromData(data map[string]interface{}, status GoalStatus) *GoalArchive {
+	if status != GoalStatusArchived {
+		return nil
+	}
+	archivedAt, err := time.Parse(time.RFC3339Nano, getString(data, "archived_at"))
+	if err != nil {
+		return nil
+	}
+	return &GoalArchive{
+		Reason:         getString(data, "archived_reason"),
+		ArchivedAt:     archivedAt,
+		PreviousStatus: GoalStatus(getString(data, "archived_from")),
+		ByGoal:         getString(data, "archived_by_goal"),
+	}
+}
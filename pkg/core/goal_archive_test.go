@@ -0,0 +1,285 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// archiveObjective creates an objective of goal in the given status.
+func (f *goalTreeFixture) archiveObjective(goal *Goal, title string, status ObjectiveStatus) *Objective {
+	ctx := context.Background()
+	objective, err := f.om.CreateObjective(ctx, goal.ID, f.methodID, title, "", nil, 5)
+	if err != nil {
+		f.t.Fatalf("Failed to create objective: %v", err)
+	}
+
+	switch status {
+	case ObjectiveStatusInProgress:
+		objective, err = f.om.StartObjective(ctx, objective.ID, StartOptions{OverrideWIPLimit: true})
+	case ObjectiveStatusPaused:
+		if _, err = f.om.StartObjective(ctx, objective.ID, StartOptions{OverrideWIPLimit: true}); err == nil {
+			objective, err = f.om.PauseObjective(ctx, objective.ID)
+		}
+	case ObjectiveStatusCompleted:
+		if _, err = f.om.StartObjective(ctx, objective.ID, StartOptions{OverrideWIPLimit: true}); err == nil {
+			objective, err = f.om.CompleteObjective(ctx, objective.ID, ObjectiveResult{Success: true})
+		}
+	case ObjectiveStatusCancelled:
+		objective, err = f.om.CancelObjective(ctx, objective.ID)
+	}
+	if err != nil {
+		f.t.Fatalf("Failed to move objective %s to %s: %v", title, status, err)
+	}
+	return objective
+}
+
+// objectiveStatus returns an objective's current status.
+func (f *goalTreeFixture) objectiveStatus(objective *Objective) ObjectiveStatus {
+	current, err := f.om.GetObjective(context.Background(), objective.ID)
+	if err != nil {
+		f.t.Fatalf("Failed to get objective %s: %v", objective.Title, err)
+	}
+	return current.Status
+}
+
+// goalStatus returns a goal's current status.
+func (f *goalTreeFixture) goalStatus(goal *Goal) GoalStatus {
+	current, err := f.gm.GetGoal(context.Background(), goal.ID)
+	if err != nil {
+		f.t.Fatalf("Failed to get goal %s: %v", goal.Title, err)
+	}
+	return current.Status
+}
+
+func TestGoalManager_ArchiveGoal(t *testing.T) {
+	f := newGoalTreeFixture(t)
+	ctx := context.Background()
+
+	parent := f.goal("Parent")
+	goal := f.goal("Abandoned", parent)
+	pending := f.archiveObjective(goal, "Pending", ObjectiveStatusPending)
+	paused := f.archiveObjective(goal, "Paused", ObjectiveStatusPaused)
+	running := f.archiveObjective(goal, "Running", ObjectiveStatusInProgress)
+	done := f.archiveObjective(goal, "Done", ObjectiveStatusCompleted)
+	f.archiveObjective(parent, "Parent work", ObjectiveStatusPending)
+
+	result, err := f.gm.ArchiveGoal(ctx, goal.ID, ArchiveOptions{Reason: "changed plans"})
+	if err != nil {
+		t.Fatalf("ArchiveGoal failed: %v", err)
+	}
+	if len(result.Goals) != 1 || len(result.Objectives) != 2 || len(result.Running) != 1 || result.Running[0].ID != running.ID {
+		t.Errorf("Expected 1 goal archived, 2 objectives cancelled and 1 left running, got %d, %d and %d",
+			len(result.Goals), len(result.Objectives), len(result.Running))
+	}
+
+	for objective, expected := range map[*Objective]ObjectiveStatus{
+		pending: ObjectiveStatusCancelled,
+		paused:  ObjectiveStatusCancelled,
+		running: ObjectiveStatusInProgress,
+		done:    ObjectiveStatusCompleted,
+	} {
+		if status := f.objectiveStatus(objective); status != expected {
+			t.Errorf("Expected %s to be %s, got %s", objective.Title, expected, status)
+		}
+	}
+
+	archived, err := f.gm.GetGoal(ctx, goal.ID)
+	if err != nil {
+		t.Fatalf("Failed to get goal: %v", err)
+	}
+	if archived.Status != GoalStatusArchived || archived.Archive == nil || archived.Archive.Reason != "changed plans" ||
+		archived.Archive.PreviousStatus != GoalStatusActive {
+		t.Errorf("Expected the goal archived with its reason and earlier status, got %s %+v", archived.Status, archived.Archive)
+	}
+	node, _ := f.store.GetNode(ctx, goal.ID)
+	if node.Data["archived_reason"] != "changed plans" {
+		t.Errorf("Expected archived_reason in the node data, got %v", node.Data["archived_reason"])
+	}
+
+	// Listings and progress leave the archived goal out
+	goals, _ := f.gm.ListGoals(ctx, GoalFilter{})
+	if len(goals) != 1 || goals[0].ID != parent.ID {
+		t.Errorf("Expected only the parent listed, got %d goals", len(goals))
+	}
+	goals, _ = f.gm.ListGoals(ctx, GoalFilter{IncludeArchived: true})
+	if len(goals) != 2 {
+		t.Errorf("Expected both goals listed with IncludeArchived, got %d", len(goals))
+	}
+	progress, err := f.gm.GetGoalProgress(ctx, parent.ID)
+	if err != nil {
+		t.Fatalf("GetGoalProgress failed: %v", err)
+	}
+	assertProgress(t, "parent", *progress, 1, 0, 0)
+	tree, _ := f.gm.GetGoalTreeWithRollups(ctx, "", GoalTreeOptions{})
+	if len(tree.Roots) != 1 || len(tree.Roots[0].Children) != 0 {
+		t.Errorf("Expected the tree to leave out the archived sub-goal")
+	}
+
+	// Archiving twice is a conflict
+	if _, err := f.gm.ArchiveGoal(ctx, goal.ID, ArchiveOptions{}); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected a conflict archiving an archived goal, got %v", err)
+	}
+	if _, err := f.gm.UnarchiveGoal(ctx, parent.ID); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected a conflict unarchiving an active goal, got %v", err)
+	}
+}
+
+func TestGoalManager_UnarchiveGoal_RestoresOnlyArchiveCancellations(t *testing.T) {
+	f := newGoalTreeFixture(t)
+	ctx := context.Background()
+
+	goal := f.goal("On hold")
+	pending := f.archiveObjective(goal, "Pending", ObjectiveStatusPending)
+	paused := f.archiveObjective(goal, "Paused", ObjectiveStatusPaused)
+	userCancelled := f.archiveObjective(goal, "Dropped", ObjectiveStatusCancelled)
+
+	paused2 := GoalStatusPaused
+	if _, err := f.gm.UpdateGoal(ctx, goal.ID, GoalUpdates{Status: &paused2}); err != nil {
+		t.Fatalf("Failed to pause goal: %v", err)
+	}
+	if _, err := f.gm.ArchiveGoal(ctx, goal.ID, ArchiveOptions{}); err != nil {
+		t.Fatalf("ArchiveGoal failed: %v", err)
+	}
+	if status := f.objectiveStatus(userCancelled); status != ObjectiveStatusCancelled {
+		t.Fatalf("Expected the cancelled objective to stay cancelled, got %s", status)
+	}
+
+	result, err := f.gm.UnarchiveGoal(ctx, goal.ID)
+	if err != nil {
+		t.Fatalf("UnarchiveGoal failed: %v", err)
+	}
+	if len(result.Objectives) != 2 {
+		t.Errorf("Expected 2 objectives restored, got %d", len(result.Objectives))
+	}
+	for _, objective := range []*Objective{pending, paused} {
+		if status := f.objectiveStatus(objective); status != ObjectiveStatusPending {
+			t.Errorf("Expected %s back to pending, got %s", objective.Title, status)
+		}
+	}
+	if status := f.objectiveStatus(userCancelled); status != ObjectiveStatusCancelled {
+		t.Errorf("Expected the objective the user cancelled to stay cancelled, got %s", status)
+	}
+	restored, _ := f.gm.GetGoal(ctx, goal.ID)
+	if restored.Status != GoalStatusPaused || restored.Archive != nil {
+		t.Errorf("Expected the goal back to paused without an archive record, got %s %+v", restored.Status, restored.Archive)
+	}
+	node, _ := f.store.GetNode(ctx, goal.ID)
+	if _, exists := node.Data["archived_reason"]; exists {
+		t.Error("Expected archived_reason to be dropped when unarchived")
+	}
+
+	// The cascade marker is gone: cancelling by hand and archiving again
+	// does not bring the objective back
+	if _, err := f.om.CancelObjective(ctx, pending.ID); err != nil {
+		t.Fatalf("CancelObjective failed: %v", err)
+	}
+	if _, err := f.gm.ArchiveGoal(ctx, goal.ID, ArchiveOptions{}); err != nil {
+		t.Fatalf("ArchiveGoal failed: %v", err)
+	}
+	if _, err := f.gm.UnarchiveGoal(ctx, goal.ID); err != nil {
+		t.Fatalf("UnarchiveGoal failed: %v", err)
+	}
+	if f.objectiveStatus(pending) != ObjectiveStatusCancelled || f.objectiveStatus(paused) != ObjectiveStatusPending {
+		t.Errorf("Expected only the archive's cancellation undone, got %s and %s", f.objectiveStatus(pending), f.objectiveStatus(paused))
+	}
+}
+
+func TestGoalManager_ArchiveGoal_Recursive(t *testing.T) {
+	f := newGoalTreeFixture(t)
+	ctx := context.Background()
+
+	// root ─ a ─ leaf
+	//      └ b ─┘      (leaf serves both a and b)
+	// other ─ shared   (shared also serves root)
+	root := f.goal("Root")
+	a := f.goal("A", root)
+	b := f.goal("B", root)
+	leaf := f.goal("Leaf", a, b)
+	other := f.goal("Other")
+	shared := f.goal("Shared", root, other)
+	leafWork := f.archiveObjective(leaf, "Leaf work", ObjectiveStatusPending)
+	sharedWork := f.archiveObjective(shared, "Shared work", ObjectiveStatusPending)
+
+	// Without Recursive the sub-goals are only offered
+	result, err := f.gm.ArchiveGoal(ctx, root.ID, ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("ArchiveGoal failed: %v", err)
+	}
+	if len(result.Goals) != 1 || len(result.SubGoals) != 3 {
+		t.Errorf("Expected 1 goal archived and 3 sub-goals offered, got %d and %d", len(result.Goals), len(result.SubGoals))
+	}
+	if f.goalStatus(a) != GoalStatusActive || f.objectiveStatus(leafWork) != ObjectiveStatusPending {
+		t.Error("Expected the sub-goals untouched without Recursive")
+	}
+	if _, err := f.gm.UnarchiveGoal(ctx, root.ID); err != nil {
+		t.Fatalf("UnarchiveGoal failed: %v", err)
+	}
+
+	// An independently archived sub-goal stays archived through both
+	if _, err := f.gm.ArchiveGoal(ctx, b.ID, ArchiveOptions{Reason: "done with b"}); err != nil {
+		t.Fatalf("ArchiveGoal failed: %v", err)
+	}
+	result, err = f.gm.ArchiveGoal(ctx, root.ID, ArchiveOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("ArchiveGoal failed: %v", err)
+	}
+	if len(result.Goals) != 3 || result.Goals[0].ID != root.ID {
+		t.Errorf("Expected root, a and leaf archived, root first, got %d goals", len(result.Goals))
+	}
+	if len(result.SubGoals) != 1 || result.SubGoals[0].ID != shared.ID {
+		t.Errorf("Expected the sub-goal serving another goal kept, got %v", result.SubGoals)
+	}
+	if f.goalStatus(leaf) != GoalStatusArchived || f.objectiveStatus(leafWork) != ObjectiveStatusCancelled {
+		t.Error("Expected the leaf archived and its objective cancelled")
+	}
+	if f.goalStatus(shared) != GoalStatusActive || f.objectiveStatus(sharedWork) != ObjectiveStatusPending {
+		t.Error("Expected the shared sub-goal and its objective untouched")
+	}
+
+	result, err = f.gm.UnarchiveGoal(ctx, root.ID)
+	if err != nil {
+		t.Fatalf("UnarchiveGoal failed: %v", err)
+	}
+	if len(result.Goals) != 3 || len(result.Objectives) != 1 {
+		t.Errorf("Expected 3 goals and 1 objective restored, got %d and %d", len(result.Goals), len(result.Objectives))
+	}
+	for _, goal := range []*Goal{root, a, leaf} {
+		if status := f.goalStatus(goal); status != GoalStatusActive {
+			t.Errorf("Expected %s restored, got %s", goal.Title, status)
+		}
+	}
+	if f.objectiveStatus(leafWork) != ObjectiveStatusPending {
+		t.Error("Expected the leaf's objective back to pending")
+	}
+	archivedB, _ := f.gm.GetGoal(ctx, b.ID)
+	if archivedB.Status != GoalStatusArchived || archivedB.Archive.Reason != "done with b" {
+		t.Errorf("Expected b to stay archived on its own, got %s", archivedB.Status)
+	}
+}
+
+func TestGoalManager_ArchiveGoal_Cycle(t *testing.T) {
+	f := newGoalTreeFixture(t)
+	ctx := context.Background()
+
+	a := f.goal("A")
+	b := f.goal("B", a)
+	if err := f.gm.AddSubGoal(ctx, b.ID, a.ID); err != nil {
+		t.Fatalf("Failed to close the cycle: %v", err)
+	}
+
+	result, err := f.gm.ArchiveGoal(ctx, a.ID, ArchiveOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("ArchiveGoal failed: %v", err)
+	}
+	if len(result.Goals) != 2 || f.goalStatus(b) != GoalStatusArchived {
+		t.Errorf("Expected both goals of the cycle archived, got %d", len(result.Goals))
+	}
+	if _, err := f.gm.UnarchiveGoal(ctx, a.ID); err != nil {
+		t.Fatalf("UnarchiveGoal failed: %v", err)
+	}
+	if f.goalStatus(a) != GoalStatusActive || f.goalStatus(b) != GoalStatusActive {
+		t.Error("Expected both goals of the cycle restored")
+	}
+}
@@ -0,0 +1,695 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+const (
+	// PlanningTaskType is the task type decomposition requests are routed and billed under
+	PlanningTaskType = "planning"
+
+	// NewMethodNeeded is what a proposal names instead of a method when none of
+	// the offered methods fits the objective
+	NewMethodNeeded = "new method needed"
+
+	// dependsOnEdgeType links an objective to an objective it depends on
+	dependsOnEdgeType = "depends_on"
+)
+
+// DecompositionOptions controls how ProposeObjectives asks for objectives.
+type DecompositionOptions struct {
+	// MaxObjectives caps how many objectives are proposed
+	MaxObjectives int
+
+	// UseMethodCache offers proven methods to the LLM so that objectives can
+	// reuse them. Without it every proposal needs a new method.
+	UseMethodCache bool
+
+	// MethodCache matches methods semantically against the goal (optional).
+	// Without it methods are ranked by success rate and recency only.
+	MethodCache *MethodCache
+
+	// MaxMethods limits how many methods are offered
+	MaxMethods int
+
+	// Router routes the decomposition request (required)
+	Router *llm.Router
+
+	// Budget records spending under PlanningTaskType (optional)
+	Budget *llm.BudgetManager
+
+	// ContextManager supplies relevant user context (optional)
+	ContextManager *UserContextManager
+
+	// UserID selects whose context is included
+	UserID string
+
+	// MaxTokens caps the length of the response
+	MaxTokens int
+
+	// RequestBudget is the most the request may cost, in dollars
+	RequestBudget float64
+}
+
+// DefaultDecompositionOptions returns options for a typical decomposition.
+// Router must still be set.
+func DefaultDecompositionOptions() DecompositionOptions {
+	return DecompositionOptions{
+		MaxObjectives:  6,
+		UseMethodCache: true,
+		MaxMethods:     8,
+		MaxTokens:      1200,
+		RequestBudget:  0.05,
+	}
+}
+
+// ObjectiveProposal is one objective suggested for a goal. Nothing is stored
+// until the proposal is accepted.
+type ObjectiveProposal struct {
+	// Key identifies the proposal within its decomposition ("1", "2", ...)
+	Key string
+
+	Title       string
+	Description string
+
+	// MethodID and MethodName name the proven method to use, if any
+	MethodID   string
+	MethodName string
+
+	// NewMethodNeeded is set when none of the offered methods fits
+	NewMethodNeeded bool
+
+	// Priority is between 1 and 10
+	Priority int
+
+	// DependsOn holds the keys of proposals that must be done first
+	DependsOn []string
+}
+
+// DecompositionProposal is the set of objectives proposed for a goal.
+type DecompositionProposal struct {
+	GoalID string
+
+	// Items are the valid proposals, in the order the LLM gave them
+	Items []*ObjectiveProposal
+
+	// Methods are the methods that were offered to the LLM
+	Methods []*Method
+
+	// Issues reports what was wrong with the response and how it was handled
+	Issues []string
+
+	Cost       float64
+	TokensUsed int
+}
+
+// Item returns the proposal with the given key, or nil.
+func (dp *DecompositionProposal) Item(key string) *ObjectiveProposal {
+	for _, item := range dp.Items {
+		if item.Key == key {
+			return item
+		}
+	}
+	return nil
+}
+
+// AcceptedDecomposition is what AcceptProposals created.
+type AcceptedDecomposition struct {
+	// Objectives are the created objectives, dependencies first
+	Objectives []*Objective
+
+	// MethodSuggestions are draft methods created for objectives that needed
+	// a new method; they hold a single step and are meant to be fleshed out
+	MethodSuggestions []*Method
+
+	// OperationID is the journal entry for the acceptance, which undoes it
+	OperationID string
+}
+
+// rawObjectiveProposal is one objective as the LLM wrote it. Fields are
+// loosely typed because models are not consistent about them.
+type rawObjectiveProposal struct {
+	Title        string          `json:"title"`
+	Description  string          `json:"description"`
+	Method       string          `json:"method"`
+	Priority     json.RawMessage `json:"priority"`
+	Dependencies json.RawMessage `json:"dependencies"`
+}
+
+// ProposeObjectives asks an LLM to break a goal into objectives, drawing on
+// the user's context and proven methods. Malformed parts of the response are
+// dropped and reported in Issues rather than failing the whole proposal.
+func (gm *GoalManager) ProposeObjectives(ctx context.Context, goalID string, opts DecompositionOptions) (*DecompositionProposal, error) {
+	if opts.Router == nil {
+		return nil, fmt.Errorf("goal decomposition requires an LLM router")
+	}
+	defaults := DefaultDecompositionOptions()
+	if opts.MaxObjectives <= 0 {
+		opts.MaxObjectives = defaults.MaxObjectives
+	}
+	if opts.MaxMethods <= 0 {
+		opts.MaxMethods = defaults.MaxMethods
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = defaults.MaxTokens
+	}
+	if opts.RequestBudget <= 0 {
+		opts.RequestBudget = defaults.RequestBudget
+	}
+
+	goal, err := gm.GetGoal(ctx, goalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goal: %w", err)
+	}
+
+	proposal := &DecompositionProposal{GoalID: goalID}
+	goalText := goal.Title + "\n" + goal.Description
+
+	if opts.UseMethodCache {
+		proposal.Methods = gm.decompositionMethods(ctx, goalText, opts, proposal)
+	}
+
+	var contexts []*UserContext
+	if opts.ContextManager != nil {
+		contexts, err = opts.ContextManager.GetRelevantContext(ctx, goalText, opts.UserID, 5)
+		if err != nil {
+			proposal.Issues = append(proposal.Issues, fmt.Sprintf("user context unavailable: %v", err))
+		}
+	}
+
+	result, err := opts.Router.Route(ctx, llm.TaskRequest{
+		Prompt:           buildDecompositionPrompt(goal, contexts, proposal.Methods, opts.MaxObjectives),
+		MaxTokens:        opts.MaxTokens,
+		Temperature:      0.3,
+		TaskType:         PlanningTaskType,
+		QualityRequired:  llm.QualityStandard,
+		BudgetConstraint: &opts.RequestBudget,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("LLM routing failed: %w", err)
+	}
+	if result.ExecutionResult == nil {
+		return nil, fmt.Errorf("no result from LLM execution")
+	}
+
+	completion := result.ExecutionResult
+	proposal.Cost = completion.Cost
+	proposal.TokensUsed = completion.TokensUsed
+	if opts.Budget != nil {
+		if err := opts.Budget.RecordUsage(ctx, llm.Transaction{
+			Provider:   result.SelectedModel.Provider,
+			Model:      result.SelectedModel.Model,
+			TaskType:   PlanningTaskType,
+			TokensUsed: completion.TokensUsed,
+			Cost:       completion.Cost,
+			Success:    true,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to record decomposition cost: %w", err)
+		}
+	}
+
+	items, issues := parseObjectiveProposals(completion.Text, proposal.Methods, opts.MaxObjectives)
+	proposal.Items = items
+	proposal.Issues = append(proposal.Issues, issues...)
+
+	return proposal, nil
+}
+
+// decompositionMethods picks the proven methods offered to the LLM. Failures
+// only shrink the offer, so they are reported as issues.
+func (gm *GoalManager) decompositionMethods(ctx context.Context, goalText string, opts DecompositionOptions, proposal *DecompositionProposal) []*Method {
+	var matches []*MatchResult
+	var err error
+	if opts.MethodCache != nil {
+		matches, err = opts.MethodCache.Query().WithObjective(goalText).WithMaxResults(opts.MaxMethods).Execute(ctx)
+		if err != nil {
+			proposal.Issues = append(proposal.Issues, fmt.Sprintf("semantic method matching failed, ranking by success instead: %v", err))
+		}
+	}
+	if opts.MethodCache == nil || err != nil {
+		matches, err = NewMethodCache(gm.store, nil).Query().Execute(ctx)
+		if err != nil {
+			proposal.Issues = append(proposal.Issues, fmt.Sprintf("proven methods unavailable: %v", err))
+			return nil
+		}
+	}
+
+	if len(matches) > opts.MaxMethods {
+		matches = matches[:opts.MaxMethods]
+	}
+	methods := make([]*Method, len(matches))
+	for i, match := range matches {
+		methods[i] = match.Method
+	}
+	return methods
+}
+
+// buildDecompositionPrompt asks for the objectives as a JSON array.
+func buildDecompositionPrompt(goal *Goal, contexts []*UserContext, methods []*Method, maxObjectives int) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Break the goal below into at most %d concrete objectives that together achieve it.\n\n", maxObjectives)
+	fmt.Fprintf(&b, "Goal: %s\n", goal.Title)
+	if goal.Description != "" {
+		fmt.Fprintf(&b, "Description: %s\n", goal.Description)
+	}
+	fmt.Fprintf(&b, "Priority: %d/10\n", goal.Priority)
+
+	if len(contexts) > 0 {
+		b.WriteString("\nWhat we know about the user:\n")
+		for _, uc := range contexts {
+			fmt.Fprintf(&b, "- (%s) %s\n", uc.Category, uc.Content)
+		}
+	}
+
+	b.WriteString("\nProven methods:\n")
+	if len(methods) == 0 {
+		b.WriteString("- none\n")
+	}
+	for i, method := range methods {
+		fmt.Fprintf(&b, "- M%d: %s - %s (%d runs, %.0f%% success)\n", i+1, method.Name, method.Description,
+			method.Metrics.ExecutionCount, method.Metrics.SuccessRate())
+	}
+
+	fmt.Fprintf(&b, `
+Respond with only a JSON array, one object per objective, in the order they should be done:
+[{"title": "...", "description": "...", "method": "M1", "priority": 5, "dependencies": [1]}]
+- "method" is the label of a proven method above, or "%s" if none fits
+- "priority" is an integer from 1 (low) to 10 (high)
+- "dependencies" lists the 1-based positions of objectives in this array that must be done first
+`, NewMethodNeeded)
+
+	return b.String()
+}
+
+// parseObjectiveProposals extracts valid proposals from an LLM response.
+// Invalid items and fields are dropped or repaired, and each repair is
+// described in the returned issues.
+func parseObjectiveProposals(text string, methods []*Method, maxObjectives int) ([]*ObjectiveProposal, []string) {
+	var issues []string
+
+	raws, err := decodeRawProposals(text)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("response could not be read as JSON: %v", err)}
+	}
+
+	// Positions in the response, which dependencies refer to, map to keys of
+	// the items that survive validation
+	keyAt := make(map[int]string)
+	var items []*ObjectiveProposal
+	rawDependencies := make(map[string]json.RawMessage)
+
+	for i, raw := range raws {
+		position := i + 1
+		title := strings.TrimSpace(raw.Title)
+		if title == "" {
+			issues = append(issues, fmt.Sprintf("item %d dropped: it has no title", position))
+			continue
+		}
+		if len(items) == maxObjectives {
+			issues = append(issues, fmt.Sprintf("items from %d on dropped: at most %d objectives were requested", position, maxObjectives))
+			break
+		}
+
+		item := &ObjectiveProposal{
+			Key:         strconv.Itoa(len(items) + 1),
+			Title:       title,
+			Description: strings.TrimSpace(raw.Description),
+		}
+
+		if method := resolveProposalMethod(raw.Method, methods); method != nil {
+			item.MethodID = method.ID
+			item.MethodName = method.Name
+		} else {
+			item.NewMethodNeeded = true
+			name := strings.TrimSpace(raw.Method)
+			if name != "" && !strings.EqualFold(name, NewMethodNeeded) {
+				issues = append(issues, fmt.Sprintf("%q: unknown method %q, marked as needing a new method", title, name))
+			}
+		}
+
+		priority, ok := parseProposalPriority(raw.Priority)
+		if !ok {
+			issues = append(issues, fmt.Sprintf("%q: unreadable priority %s, using 5", title, string(raw.Priority)))
+		}
+		item.Priority = priority
+
+		keyAt[position] = item.Key
+		rawDependencies[item.Key] = raw.Dependencies
+		items = append(items, item)
+	}
+
+	byTitle := make(map[string]string, len(items))
+	for _, item := range items {
+		byTitle[strings.ToLower(item.Title)] = item.Key
+	}
+	for _, item := range items {
+		for _, ref := range decodeDependencyRefs(rawDependencies[item.Key]) {
+			key, found := "", false
+			if position, err := strconv.Atoi(ref); err == nil {
+				key, found = keyAt[position]
+			} else {
+				key, found = byTitle[strings.ToLower(ref)]
+			}
+			switch {
+			case !found:
+				issues = append(issues, fmt.Sprintf("%q: dependency %q does not match a proposed objective, ignored", item.Title, ref))
+			case key == item.Key:
+				issues = append(issues, fmt.Sprintf("%q: depends on itself, ignored", item.Title))
+			case !containsString(item.DependsOn, key):
+				item.DependsOn = append(item.DependsOn, key)
+			}
+		}
+	}
+
+	issues = append(issues, breakProposalCycles(items)...)
+	return items, issues
+}
+
+// decodeRawProposals finds the JSON in a response, tolerating code fences and
+// surrounding prose, and accepts either an array or an object holding one.
+func decodeRawProposals(text string) ([]rawObjectiveProposal, error) {
+	start := strings.IndexAny(text, "[{")
+	if start < 0 {
+		return nil, fmt.Errorf("no JSON found")
+	}
+	decoder := json.NewDecoder(strings.NewReader(text[start:]))
+
+	if text[start] == '[' {
+		var raws []rawObjectiveProposal
+		if err := decoder.Decode(&raws); err != nil {
+			return nil, err
+		}
+		return raws, nil
+	}
+
+	var wrapper map[string]json.RawMessage
+	if err := decoder.Decode(&wrapper); err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"objectives", "items", "proposals"} {
+		if list, ok := wrapper[key]; ok {
+			var raws []rawObjectiveProposal
+			if err := json.Unmarshal(list, &raws); err != nil {
+				return nil, fmt.Errorf("%q is not a list of objectives: %w", key, err)
+			}
+			return raws, nil
+		}
+	}
+	if _, ok := wrapper["title"]; ok {
+		var raw rawObjectiveProposal
+		if err := json.Unmarshal([]byte(text[start:start+int(decoder.InputOffset())]), &raw); err != nil {
+			return nil, err
+		}
+		return []rawObjectiveProposal{raw}, nil
+	}
+	return nil, fmt.Errorf("no list of objectives found")
+}
+
+// resolveProposalMethod finds the offered method a proposal names, by label
+// ("M2"), ID or name.
+func resolveProposalMethod(name string, methods []*Method) *Method {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	if strings.HasPrefix(strings.ToUpper(name), "M") {
+		if index, err := strconv.Atoi(name[1:]); err == nil && index >= 1 && index <= len(methods) {
+			return methods[index-1]
+		}
+	}
+	for _, method := range methods {
+		if method.ID == name || strings.EqualFold(method.Name, name) {
+			return method
+		}
+	}
+	return nil
+}
+
+// parseProposalPriority reads a priority given as a number, a numeric string
+// or a word, clamped to 1-10. Unreadable priorities default to 5.
+func parseProposalPriority(raw json.RawMessage) (int, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 5, true
+	}
+
+	var number float64
+	if err := json.Unmarshal(raw, &number); err != nil {
+		var word string
+		if err := json.Unmarshal(raw, &word); err != nil {
+			return 5, false
+		}
+		word = strings.ToLower(strings.TrimSpace(word))
+		switch word {
+		case "critical", "urgent":
+			return 9, true
+		case "high":
+			return 8, true
+		case "medium", "normal":
+			return 5, true
+		case "low":
+			return 3, true
+		}
+		if number, err = strconv.ParseFloat(word, 64); err != nil {
+			return 5, false
+		}
+	}
+
+	priority := int(number + 0.5)
+	if priority < 1 {
+		priority = 1
+	}
+	if priority > 10 {
+		priority = 10
+	}
+	return priority, true
+}
+
+// decodeDependencyRefs reads dependencies given as a list or a single value,
+// of positions or titles, as strings.
+func decodeDependencyRefs(raw json.RawMessage) []string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+
+	var values []interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		var single interface{}
+		if err := json.Unmarshal(raw, &single); err != nil {
+			return nil
+		}
+		values = []interface{}{single}
+	}
+
+	var refs []string
+	for _, value := range values {
+		switch v := value.(type) {
+		case float64:
+			refs = append(refs, strconv.Itoa(int(v)))
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				refs = append(refs, v)
+			}
+		}
+	}
+	return refs
+}
+
+// breakProposalCycles removes dependencies that would close a cycle, keeping
+// the earlier ones, and reports each removal.
+func breakProposalCycles(items []*ObjectiveProposal) []string {
+	byKey := make(map[string]*ObjectiveProposal, len(items))
+	for _, item := range items {
+		byKey[item.Key] = item
+	}
+
+	var issues []string
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(items))
+
+	var visit func(item *ObjectiveProposal)
+	visit = func(item *ObjectiveProposal) {
+		state[item.Key] = visiting
+		kept := item.DependsOn[:0]
+		for _, key := range item.DependsOn {
+			if state[key] == visiting {
+				issues = append(issues, fmt.Sprintf("%q: dependency on %q would form a cycle, ignored", item.Title, byKey[key].Title))
+				continue
+			}
+			if state[key] == unvisited {
+				visit(byKey[key])
+			}
+			kept = append(kept, key)
+		}
+		item.DependsOn = kept
+		state[item.Key] = done
+	}
+	for _, item := range items {
+		if state[item.Key] == unvisited {
+			visit(item)
+		}
+	}
+	return issues
+}
+
+// AcceptProposals creates the objectives with the given keys, in dependency
+// order, linked to their goal, their methods and the accepted objectives they
+// depend on. Dependencies on proposals that were not accepted are dropped.
+// Objectives needing a new method get a draft method, returned as a
+// suggestion to refine. Creation is all or nothing: if any step fails,
+// everything created so far is archived again.
+func (gm *GoalManager) AcceptProposals(ctx context.Context, proposal *DecompositionProposal, keys []string) (*AcceptedDecomposition, error) {
+	var result *AcceptedDecomposition
+	operation, err := NewOperationJournal(gm.store).Record(ctx, OperationAcceptProposals, func(ctx context.Context) (string, error) {
+		var err error
+		if result, err = gm.acceptProposals(ctx, proposal, keys); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Accept %d proposed objectives for goal %s", len(result.Objectives), proposal.GoalID), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if operation != nil {
+		result.OperationID = operation.ID
+	}
+	return result, nil
+}
+
+func (gm *GoalManager) acceptProposals(ctx context.Context, proposal *DecompositionProposal, keys []string) (*AcceptedDecomposition, error) {
+	if proposal == nil {
+		return nil, fmt.Errorf("proposal cannot be nil")
+	}
+	if _, err := gm.GetGoal(ctx, proposal.GoalID); err != nil {
+		return nil, fmt.Errorf("failed to get goal: %w", err)
+	}
+
+	accepted := make(map[string]*ObjectiveProposal, len(keys))
+	for _, key := range keys {
+		item := proposal.Item(key)
+		if item == nil {
+			return nil, fmt.Errorf("no proposed objective with key %q", key)
+		}
+		accepted[key] = item
+	}
+
+	ordered := orderProposals(proposal.Items, accepted)
+	om := NewObjectiveManager(gm.store)
+	mm := NewMethodManager(gm.store)
+
+	var created []string
+	rollback := func(cause error) error {
+		if _, err := gm.store.ArchiveNodes(ctx, created); err != nil {
+			return fmt.Errorf("%w (rolling back also failed: %v)", cause, err)
+		}
+		return cause
+	}
+
+	result := &AcceptedDecomposition{}
+	objectiveIDs := make(map[string]string, len(ordered))
+	for _, item := range ordered {
+		methodID := item.MethodID
+		if item.NewMethodNeeded {
+			step := item.Description
+			if step == "" {
+				step = item.Title
+			}
+			method, err := mm.CreateMethod(ctx, item.Title, "Draft method for: "+item.Title,
+				[]ApproachStep{{Description: step}},
+				MethodDomainUser, map[string]interface{}{"draft": true, "source": "goal_decomposition"})
+			if err != nil {
+				return nil, rollback(fmt.Errorf("failed to create method for %q: %w", item.Title, err))
+			}
+			created = append(created, method.ID)
+			result.MethodSuggestions = append(result.MethodSuggestions, method)
+			methodID = method.ID
+		}
+
+		objective, err := om.CreateObjective(ctx, proposal.GoalID, methodID, item.Title, item.Description,
+			map[string]interface{}{"source": "goal_decomposition"}, item.Priority)
+		if err != nil {
+			if objective == nil {
+				// CreateObjective may have stored the node before an edge failed
+				if orphan := gm.findProposedObjective(ctx, proposal.GoalID, item.Title); orphan != "" {
+					created = append(created, orphan)
+				}
+			}
+			return nil, rollback(fmt.Errorf("failed to create objective %q: %w", item.Title, err))
+		}
+		created = append(created, objective.ID)
+		objectiveIDs[item.Key] = objective.ID
+		result.Objectives = append(result.Objectives, objective)
+
+		for _, key := range item.DependsOn {
+			dependencyID, ok := objectiveIDs[key]
+			if !ok {
+				continue
+			}
+			edge := storage.NewEdge(objective.ID, dependencyID, dependsOnEdgeType, map[string]interface{}{
+				"relationship": "objective_depends_on_objective",
+				"created_at":   time.Now().Format(time.RFC3339),
+			})
+			if err := gm.store.AddEdge(ctx, edge); err != nil {
+				return nil, rollback(fmt.Errorf("failed to link %q to its dependency: %w", item.Title, err))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// orderProposals returns the accepted proposals with every dependency before
+// its dependents, otherwise keeping the proposal order.
+func orderProposals(items []*ObjectiveProposal, accepted map[string]*ObjectiveProposal) []*ObjectiveProposal {
+	var ordered []*ObjectiveProposal
+	placed := make(map[string]bool, len(accepted))
+
+	var place func(item *ObjectiveProposal)
+	place = func(item *ObjectiveProposal) {
+		if placed[item.Key] {
+			return
+		}
+		placed[item.Key] = true
+		for _, key := range item.DependsOn {
+			if dependency, ok := accepted[key]; ok {
+				place(dependency)
+			}
+		}
+		ordered = append(ordered, item)
+	}
+	for _, item := range items {
+		if _, ok := accepted[item.Key]; ok {
+			place(item)
+		}
+	}
+	return ordered
+}
+
+// findProposedObjective looks up a decomposition objective stored for a goal
+// by title, so that a partially created objective can be rolled back.
+func (gm *GoalManager) findProposedObjective(ctx context.Context, goalID, title string) string {
+	nodes, err := gm.store.GetNodesByType(ctx, "objective")
+	if err != nil {
+		return ""
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ValidFrom.After(nodes[j].ValidFrom) })
+	for _, node := range nodes {
+		if getString(node.Data, "goal_id") == goalID && getString(node.Data, "title") == title {
+			return node.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseObjectiveProposals_Clean(t *testing.T) {
+	methods := []*Method{{ID: "method-a", Name: "Inbox triage"}, {ID: "method-b", Name: "Weekly report"}}
+	response := `[
+		{"title": "Clear the backlog", "description": "Archive old mail", "method": "M1", "priority": 7, "dependencies": []},
+		{"title": "Report progress", "description": "Summarize the week", "method": "M2", "priority": 4, "dependencies": [1]},
+		{"title": "Automate filing", "description": "Write filters", "method": "new method needed", "priority": 5, "dependencies": [1, 2]}
+	]`
+
+	items, issues := parseObjectiveProposals(response, methods, 6)
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+	if len(items) != 3 {
+		t.Fatalf("Expected 3 proposals, got %d", len(items))
+	}
+
+	if items[0].MethodID != "method-a" || items[0].Priority != 7 || len(items[0].DependsOn) != 0 {
+		t.Errorf("Unexpected first proposal: %+v", items[0])
+	}
+	if items[1].MethodName != "Weekly report" || !reflect.DeepEqual(items[1].DependsOn, []string{"1"}) {
+		t.Errorf("Unexpected second proposal: %+v", items[1])
+	}
+	if !items[2].NewMethodNeeded || items[2].MethodID != "" || !reflect.DeepEqual(items[2].DependsOn, []string{"1", "2"}) {
+		t.Errorf("Unexpected third proposal: %+v", items[2])
+	}
+}
+
+func TestParseObjectiveProposals_Messy(t *testing.T) {
+	methods := []*Method{{ID: "method-a", Name: "Inbox triage"}}
+	response := "Sure! Here is the plan:\n```json\n" + `{"objectives": [
+		{"title": "Clear the backlog", "method": "inbox triage", "priority": "high"},
+		{"description": "No title here", "method": "M1"},
+		{"title": "Report progress", "method": "Carrier pigeon", "priority": 42, "dependencies": "Clear the backlog"},
+		{"title": "Loop A", "priority": "soon", "dependencies": [5]},
+		{"title": "Loop B", "method": "M9", "priority": "2", "dependencies": [4, 5, 99]},
+		{"title": "One too many"}
+	]}` + "\n```\nLet me know if you want changes."
+
+	items, issues := parseObjectiveProposals(response, methods, 4)
+	if len(items) != 4 {
+		t.Fatalf("Expected 4 proposals, got %d: %+v", len(items), items)
+	}
+
+	if items[0].MethodID != "method-a" || items[0].Priority != 8 {
+		t.Errorf("Expected the method matched by name and a word priority, got %+v", items[0])
+	}
+	if !items[1].NewMethodNeeded || items[1].Priority != 10 || !reflect.DeepEqual(items[1].DependsOn, []string{"1"}) {
+		t.Errorf("Expected an unknown method, clamped priority and title dependency, got %+v", items[1])
+	}
+	if items[2].Priority != 5 || items[3].Priority != 2 || !items[3].NewMethodNeeded {
+		t.Errorf("Expected default and numeric-string priorities, got %+v and %+v", items[2], items[3])
+	}
+
+	// Loop A (key 3) depends on Loop B (key 4), which depends on itself,
+	// Loop A and a missing item: only the first dependency can stay
+	if !reflect.DeepEqual(items[2].DependsOn, []string{"4"}) || len(items[3].DependsOn) != 0 {
+		t.Errorf("Expected the cycle to be broken, got %v and %v", items[2].DependsOn, items[3].DependsOn)
+	}
+
+	report := strings.Join(issues, "\n")
+	for _, want := range []string{
+		"item 2 dropped: it has no title",
+		`unknown method "Carrier pigeon"`,
+		`unknown method "M9"`,
+		"unreadable priority",
+		"depends on itself",
+		`dependency "99" does not match`,
+		"would form a cycle",
+		"items from 6 on dropped",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected the issues to mention %q, got:\n%s", want, report)
+		}
+	}
+}
+
+func TestParseObjectiveProposals_Unreadable(t *testing.T) {
+	for _, response := range []string{"I cannot help with that.", `[{"title": "Cut off`, `{"plan": "do it"}`} {
+		items, issues := parseObjectiveProposals(response, nil, 5)
+		if len(items) != 0 || len(issues) != 1 {
+			t.Errorf("Expected no proposals and one issue for %q, got %v, %v", response, items, issues)
+		}
+	}
+}
+
+func TestGoalManager_AcceptProposals(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+
+	goal, err := gm.CreateGoal(ctx, "Tame email", "Keep the inbox under control", 6, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Inbox triage", "Sort new email",
+		[]ApproachStep{{Description: "Read new messages"}}, MethodDomainUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+
+	proposal := &DecompositionProposal{
+		GoalID: goal.ID,
+		Items: []*ObjectiveProposal{
+			{Key: "1", Title: "Report progress", MethodID: method.ID, Priority: 4, DependsOn: []string{"2", "3"}},
+			{Key: "2", Title: "Clear the backlog", MethodID: method.ID, Priority: 7},
+			{Key: "3", Title: "Automate filing", Description: "Write filters", NewMethodNeeded: true, Priority: 5},
+		},
+	}
+
+	if _, err := gm.AcceptProposals(ctx, proposal, []string{"1", "7"}); err == nil {
+		t.Error("Expected an unknown key to be refused")
+	}
+	if objectives, _ := NewObjectiveManager(store).GetObjectivesForGoal(ctx, goal.ID); len(objectives) != 0 {
+		t.Fatalf("Expected nothing to be created for a refused acceptance, got %d objectives", len(objectives))
+	}
+
+	accepted, err := gm.AcceptProposals(ctx, proposal, []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("AcceptProposals failed: %v", err)
+	}
+	if len(accepted.Objectives) != 2 || accepted.Objectives[0].Title != "Clear the backlog" {
+		t.Fatalf("Expected the dependency to be created first, got %+v", accepted.Objectives)
+	}
+	if len(accepted.MethodSuggestions) != 0 {
+		t.Errorf("Expected no method suggestions, got %d", len(accepted.MethodSuggestions))
+	}
+
+	edges, err := store.GetEdgesByType(ctx, dependsOnEdgeType)
+	if err != nil {
+		t.Fatalf("Failed to get dependency edges: %v", err)
+	}
+	if len(edges) != 1 || edges[0].SourceID != accepted.Objectives[1].ID || edges[0].TargetID != accepted.Objectives[0].ID {
+		t.Errorf("Expected one dependency edge from the report to the backlog, got %+v", edges)
+	}
+
+	accepted, err = gm.AcceptProposals(ctx, proposal, []string{"3"})
+	if err != nil {
+		t.Fatalf("AcceptProposals failed: %v", err)
+	}
+	if len(accepted.MethodSuggestions) != 1 || accepted.Objectives[0].MethodID != accepted.MethodSuggestions[0].ID {
+		t.Fatalf("Expected a draft method for the objective, got %+v", accepted)
+	}
+	if step := accepted.MethodSuggestions[0].Approach[0].Description; step != "Write filters" {
+		t.Errorf("Expected the draft method to start from the description, got %q", step)
+	}
+}
+
+func TestGoalManager_AcceptProposalsRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+
+	goal, err := gm.CreateGoal(ctx, "Tame email", "", 6, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+
+	// The second objective names a method that does not exist, so linking it fails
+	proposal := &DecompositionProposal{
+		GoalID: goal.ID,
+		Items: []*ObjectiveProposal{
+			{Key: "1", Title: "Automate filing", NewMethodNeeded: true, Priority: 5},
+			{Key: "2", Title: "Report progress", MethodID: "missing-method", Priority: 4, DependsOn: []string{"1"}},
+		},
+	}
+	if _, err := gm.AcceptProposals(ctx, proposal, []string{"1", "2"}); err == nil {
+		t.Fatal("Expected acceptance to fail")
+	}
+
+	objectives, err := NewObjectiveManager(store).GetObjectivesForGoal(ctx, goal.ID)
+	if err != nil {
+		t.Fatalf("Failed to list objectives: %v", err)
+	}
+	if len(objectives) != 0 {
+		t.Errorf("Expected the created objectives to be rolled back, got %d", len(objectives))
+	}
+	methods, err := store.GetNodesByType(ctx, "method")
+	if err != nil {
+		t.Fatalf("Failed to list methods: %v", err)
+	}
+	if len(methods) != 0 {
+		t.Errorf("Expected the draft method to be rolled back, got %d", len(methods))
+	}
+}
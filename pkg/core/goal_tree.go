@@ -0,0 +1,450 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// GoalProgress summarizes the objectives of a goal, or of a goal and its
+// sub-goals. GetGoalProgress and GetGoalTreeWithRollups build it the same
+// way, so a goal's numbers agree between the flat and the tree views.
+type GoalProgress struct {
+	// Objectives is the number of objectives counted; cancelled ones are not
+	Objectives int
+
+	// ByStatus counts the objectives in each status
+	ByStatus map[ObjectiveStatus]int
+
+	// Weight sums the priorities of the objectives and CompletedWeight those
+	// of the completed ones
+	Weight          int
+	CompletedWeight int
+
+	// TokensUsed and Spend total the recorded executions of the objectives
+	TokensUsed int
+	Spend      float64
+
+	// LastActivity is when an objective last changed or an execution was
+	// last recorded; zero if there was none
+	LastActivity time.Time
+}
+
+// newGoalProgress returns empty progress.
+func newGoalProgress() GoalProgress {
+	return GoalProgress{ByStatus: make(map[ObjectiveStatus]int)}
+}
+
+// Completed returns the number of completed objectives.
+func (p GoalProgress) Completed() int {
+	return p.ByStatus[ObjectiveStatusCompleted]
+}
+
+// Failed returns the number of failed objectives.
+func (p GoalProgress) Failed() int {
+	return p.ByStatus[ObjectiveStatusFailed]
+}
+
+// InProgress returns the number of objectives being worked on.
+func (p GoalProgress) InProgress() int {
+	return p.ByStatus[ObjectiveStatusInProgress]
+}
+
+// Percent returns the share of objectives completed, from 0 to 100.
+// Progress without objectives is 0.
+func (p GoalProgress) Percent() float64 {
+	if p.Objectives == 0 {
+		return 0
+	}
+	return float64(p.Completed()) / float64(p.Objectives) * 100
+}
+
+// WeightedPercent returns the share of objectives completed weighted by
+// priority, from 0 to 100. Progress without objectives is 0.
+func (p GoalProgress) WeightedPercent() float64 {
+	if p.Weight == 0 {
+		return 0
+	}
+	return float64(p.CompletedWeight) / float64(p.Weight) * 100
+}
+
+// touch moves LastActivity forward to at.
+func (p *GoalProgress) touch(at time.Time) {
+	if at.After(p.LastActivity) {
+		p.LastActivity = at
+	}
+}
+
+// add accumulates other into p.
+func (p *GoalProgress) add(other GoalProgress) {
+	p.Objectives += other.Objectives
+	for status, count := range other.ByStatus {
+		p.ByStatus[status] += count
+	}
+	p.Weight += other.Weight
+	p.CompletedWeight += other.CompletedWeight
+	p.TokensUsed += other.TokensUsed
+	p.Spend += other.Spend
+	p.touch(other.LastActivity)
+}
+
+// GetGoalProgress returns the progress of a goal's objectives together with
+// those of all its sub-goals. Each sub-goal is counted once, even when it is
+// reached through several parents or a cycle. Spend is attributed through the
+// objectives' execution results.
+func (gm *GoalManager) GetGoalProgress(ctx context.Context, goalID string) (*GoalProgress, error) {
+	if _, err := gm.GetGoal(ctx, goalID); err != nil {
+		return nil, err
+	}
+
+	graph, err := gm.loadGoalGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := loadGoalProgress(ctx, gm.store, "")
+	if err != nil {
+		return nil, err
+	}
+
+	visited := make(map[string]bool)
+	graph.walk(goalID, visited)
+	rollup := newGoalProgress()
+	for id := range visited {
+		rollup.add(progressFor(progress, id))
+	}
+	return &rollup, nil
+}
+
+// loadGoalProgress reads objectives and their executions in one query each
+// and returns every goal's own progress by goal ID. If goalID is set only that
+// goal's objectives are read.
+func loadGoalProgress(ctx context.Context, store *storage.Store, goalID string) (map[string]GoalProgress, error) {
+	query := store.Nodes().OfType("objective")
+	if goalID != "" {
+		query = query.WithData("goal_id", goalID)
+	}
+	objectives, err := query.AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query objectives: %w", err)
+	}
+
+	objectiveGoals := make(map[string]string, len(objectives))
+	progress := make(map[string]GoalProgress)
+	for _, node := range objectives {
+		owner := getString(node.Data, "goal_id")
+		status := getString(node.Data, "status")
+		if owner == "" || status == "" {
+			continue
+		}
+		objectiveGoals[node.ID] = owner
+		if ObjectiveStatus(status) == ObjectiveStatusCancelled {
+			continue // Spend counts, but the objective no longer does
+		}
+
+		priority := int(getFloat64(node.Data, "priority"))
+		if priority <= 0 {
+			priority = 5
+		}
+
+		goalProgress := progressFor(progress, owner)
+		goalProgress.Objectives++
+		goalProgress.ByStatus[ObjectiveStatus(status)]++
+		goalProgress.Weight += priority
+		if ObjectiveStatus(status) == ObjectiveStatusCompleted {
+			goalProgress.CompletedWeight += priority
+		}
+		goalProgress.touch(node.CreatedAt)
+		progress[owner] = goalProgress
+	}
+
+	executions, err := store.GetNodesByType(ctx, "execution_result")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query execution results: %w", err)
+	}
+	for _, node := range executions {
+		owner, exists := objectiveGoals[getString(node.Data, "objective_id")]
+		if !exists {
+			continue
+		}
+		goalProgress := progress[owner]
+		goalProgress.TokensUsed += int(getFloat64(node.Data, "total_tokens_used"))
+		goalProgress.Spend += getFloat64(node.Data, "total_cost")
+		goalProgress.touch(node.CreatedAt)
+		progress[owner] = goalProgress
+	}
+
+	return progress, nil
+}
+
+// progressFor returns a goal's progress from a loaded set, or empty progress.
+func progressFor(progress map[string]GoalProgress, goalID string) GoalProgress {
+	if existing, exists := progress[goalID]; exists {
+		return existing
+	}
+	return newGoalProgress()
+}
+
+// GoalTreeOptions configures GetGoalTreeWithRollups.
+type GoalTreeOptions struct {
+	// MaxDepth is how many levels of sub-goals are returned below each root
+	// (0: all). Rollups always include every descendant.
+	MaxDepth int
+}
+
+// GoalTree is a goal hierarchy with progress rolled up at every node.
+type GoalTree struct {
+	Roots []*GoalTreeNode
+
+	// Total covers every goal in the tree, each counted once
+	Total GoalProgress
+}
+
+// GoalTreeNode is one goal in a GoalTree. A goal serving several parents
+// appears under each of them.
+type GoalTreeNode struct {
+	Goal  *Goal
+	Depth int
+
+	// Own covers the goal's own objectives
+	Own GoalProgress
+
+	// Rollup covers the goal and all of its descendants, each counted once
+	// even when it is reached through several parents, and matches
+	// GetGoalProgress
+	Rollup GoalProgress
+
+	// Descendants is the number of distinct sub-goals included in Rollup
+	Descendants int
+
+	Children []*GoalTreeNode
+
+	// Shared is set when the goal serves more than one parent in the tree
+	Shared bool
+
+	// Cycle is set when the goal is also one of its own ancestors; its
+	// sub-goals are not repeated below it
+	Cycle bool
+
+	// Truncated is set when MaxDepth hid the goal's sub-goals
+	Truncated bool
+}
+
+// GetGoalTreeWithRollups returns the hierarchy below rootID, or every
+// top-level goal if rootID is empty, with per-node and cumulative progress.
+// Goals, sub-goal relationships, objectives and executions are each loaded
+// with a single query.
+func (gm *GoalManager) GetGoalTreeWithRollups(ctx context.Context, rootID string, opts GoalTreeOptions) (*GoalTree, error) {
+	graph, err := gm.loadGoalGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []string
+	if rootID != "" {
+		if _, exists := graph.goals[rootID]; !exists {
+			root, err := gm.GetGoal(ctx, rootID)
+			if err != nil {
+				return nil, err
+			}
+			graph.goals[rootID] = root
+		}
+		roots = []string{rootID}
+	} else {
+		roots = graph.topLevel()
+	}
+
+	progress, err := loadGoalProgress(ctx, gm.store, "")
+	if err != nil {
+		return nil, err
+	}
+
+	builder := &goalTreeBuilder{
+		graph:       graph,
+		progress:    progress,
+		opts:        opts,
+		descendants: make(map[string][]string),
+	}
+
+	tree := &GoalTree{Total: newGoalProgress()}
+	counted := make(map[string]bool)
+	for _, id := range roots {
+		tree.Roots = append(tree.Roots, builder.build(id, 0, map[string]bool{}))
+		for _, goalID := range append([]string{id}, builder.descendantsOf(id)...) {
+			if !counted[goalID] {
+				counted[goalID] = true
+				tree.Total.add(progressFor(progress, goalID))
+			}
+		}
+	}
+
+	return tree, nil
+}
+
+// goalGraph is the sub-goal hierarchy held in memory.
+type goalGraph struct {
+	goals    map[string]*Goal
+	children map[string][]string
+	parents  map[string][]string
+}
+
+// loadGoalGraph reads every goal and "serves" relationship once. Archived
+// goals are left out, and with them their place in the hierarchy.
+func (gm *GoalManager) loadGoalGraph(ctx context.Context) (*goalGraph, error) {
+	nodes, err := gm.store.Nodes().OfType("goal").AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goals: %w", err)
+	}
+
+	graph := &goalGraph{
+		goals:    make(map[string]*Goal, len(nodes)),
+		children: make(map[string][]string),
+		parents:  make(map[string][]string),
+	}
+	for _, node := range nodes {
+		goal, err := gm.nodeToGoal(node)
+		if err != nil || goal.Status == GoalStatusArchived {
+			continue // Skip invalid and archived goals
+		}
+		graph.goals[goal.ID] = goal
+	}
+
+	edges, err := gm.store.Edges().OfType("serves").AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sub-goal relationships: %w", err)
+	}
+	linked := make(map[[2]string]bool)
+	for _, edge := range edges {
+		link := [2]string{edge.TargetID, edge.SourceID}
+		if linked[link] || graph.goals[edge.SourceID] == nil || graph.goals[edge.TargetID] == nil {
+			continue
+		}
+		linked[link] = true
+		graph.children[edge.TargetID] = append(graph.children[edge.TargetID], edge.SourceID)
+		graph.parents[edge.SourceID] = append(graph.parents[edge.SourceID], edge.TargetID)
+	}
+
+	for id := range graph.children {
+		graph.sortGoals(graph.children[id])
+	}
+	return graph, nil
+}
+
+// topLevel returns the goals that serve no other goal. Goals only reachable
+// through a cycle are added as roots too, so every goal appears somewhere.
+func (g *goalGraph) topLevel() []string {
+	ids := make([]string, 0, len(g.goals))
+	for id := range g.goals {
+		ids = append(ids, id)
+	}
+	g.sortGoals(ids)
+
+	var roots []string
+	reached := make(map[string]bool)
+	for _, id := range ids {
+		if len(g.parents[id]) == 0 {
+			roots = append(roots, id)
+			g.walk(id, reached)
+		}
+	}
+	for _, id := range ids {
+		if !reached[id] {
+			roots = append(roots, id)
+			g.walk(id, reached)
+		}
+	}
+	return roots
+}
+
+// walk marks id and everything below it as visited. It stops at goals that
+// were already visited, so cycles and shared sub-goals are handled.
+func (g *goalGraph) walk(id string, visited map[string]bool) {
+	stack := []string{id}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, g.children[current]...)
+	}
+}
+
+// sortGoals orders goal IDs by creation time, then title.
+func (g *goalGraph) sortGoals(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := g.goals[ids[i]], g.goals[ids[j]]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return a.ID < b.ID
+	})
+}
+
+// goalTreeBuilder turns a goal graph into nested nodes with rollups.
+type goalTreeBuilder struct {
+	graph       *goalGraph
+	progress    map[string]GoalProgress
+	opts        GoalTreeOptions
+	descendants map[string][]string
+}
+
+// build returns the node for id. Ancestors holds the goals on the path from
+// the root, which is how cycles are recognized.
+func (b *goalTreeBuilder) build(id string, depth int, ancestors map[string]bool) *GoalTreeNode {
+	descendants := b.descendantsOf(id)
+	node := &GoalTreeNode{
+		Goal:        b.graph.goals[id],
+		Depth:       depth,
+		Own:         progressFor(b.progress, id),
+		Rollup:      newGoalProgress(),
+		Descendants: len(descendants),
+		Shared:      len(b.graph.parents[id]) > 1,
+		Cycle:       ancestors[id],
+	}
+	node.Rollup.add(node.Own)
+	for _, descendant := range descendants {
+		node.Rollup.add(progressFor(b.progress, descendant))
+	}
+
+	children := b.graph.children[id]
+	if node.Cycle || len(children) == 0 {
+		return node
+	}
+	if b.opts.MaxDepth > 0 && depth >= b.opts.MaxDepth {
+		node.Truncated = true
+		return node
+	}
+
+	ancestors[id] = true
+	for _, child := range children {
+		node.Children = append(node.Children, b.build(child, depth+1, ancestors))
+	}
+	delete(ancestors, id)
+	return node
+}
+
+// descendantsOf returns the distinct goals below id, excluding id itself.
+func (b *goalTreeBuilder) descendantsOf(id string) []string {
+	if cached, exists := b.descendants[id]; exists {
+		return cached
+	}
+
+	visited := make(map[string]bool)
+	b.graph.walk(id, visited)
+	delete(visited, id)
+
+	descendants := make([]string, 0, len(visited))
+	for descendant := range visited {
+		descendants = append(descendants, descendant)
+	}
+	sort.Strings(descendants)
+	b.descendants[id] = descendants
+	return descendants
+}
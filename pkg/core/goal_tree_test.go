@@ -0,0 +1,288 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// goalTreeFixture creates goals and objectives for the goal tree tests.
+type goalTreeFixture struct {
+	t        *testing.T
+	store    *storage.Store
+	gm       *GoalManager
+	om       *ObjectiveManager
+	methodID string
+}
+
+func newGoalTreeFixture(t *testing.T) *goalTreeFixture {
+	store := setupTestStore(t)
+	t.Cleanup(func() { store.Close() })
+	return &goalTreeFixture{
+		t:        t,
+		store:    store,
+		gm:       NewGoalManager(store),
+		om:       NewObjectiveManager(store),
+		methodID: addRollupTestMethod(t, store),
+	}
+}
+
+func (f *goalTreeFixture) goal(title string, parents ...*Goal) *Goal {
+	ctx := context.Background()
+	goal, err := f.gm.CreateGoal(ctx, title, "", 5, nil)
+	if err != nil {
+		f.t.Fatalf("Failed to create goal %s: %v", title, err)
+	}
+	for _, parent := range parents {
+		if err := f.gm.AddSubGoal(ctx, parent.ID, goal.ID); err != nil {
+			f.t.Fatalf("Failed to add %s under %s: %v", title, parent.Title, err)
+		}
+	}
+	return goal
+}
+
+// objective creates an objective in the given status, recording an execution
+// with the given usage if cost is positive.
+func (f *goalTreeFixture) objective(goal *Goal, status ObjectiveStatus, tokens int, cost float64) {
+	ctx := context.Background()
+	objective, err := f.om.CreateObjective(ctx, goal.ID, f.methodID, goal.Title+" objective", "", nil, 5)
+	if err != nil {
+		f.t.Fatalf("Failed to create objective: %v", err)
+	}
+
+	if status != ObjectiveStatusPending {
+		if _, err := f.om.StartObjective(ctx, objective.ID); err != nil {
+			f.t.Fatalf("Failed to start objective: %v", err)
+		}
+	}
+	switch status {
+	case ObjectiveStatusCompleted:
+		_, err = f.om.CompleteObjective(ctx, objective.ID, ObjectiveResult{Success: true})
+	case ObjectiveStatusFailed:
+		_, err = f.om.FailObjective(ctx, objective.ID, "failed", 0)
+	}
+	if err != nil {
+		f.t.Fatalf("Failed to move objective to %s: %v", status, err)
+	}
+
+	if cost > 0 {
+		execution := storage.NewNode("execution_result", map[string]interface{}{
+			"objective_id":      objective.ID,
+			"total_tokens_used": tokens,
+			"total_cost":        cost,
+		})
+		if err := f.store.AddNode(ctx, execution); err != nil {
+			f.t.Fatalf("Failed to add execution result: %v", err)
+		}
+	}
+}
+
+func assertProgress(t *testing.T, label string, got GoalProgress, objectives, completed int, spend float64) {
+	t.Helper()
+	if got.Objectives != objectives || got.Completed() != completed || math.Abs(got.Spend-spend) > 1e-9 {
+		t.Errorf("%s: expected %d objectives, %d completed and $%.2f, got %d, %d and $%.2f",
+			label, objectives, completed, spend, got.Objectives, got.Completed(), got.Spend)
+	}
+}
+
+func TestGoalManager_GetGoalTreeWithRollups(t *testing.T) {
+	f := newGoalTreeFixture(t)
+	ctx := context.Background()
+
+	// root → {a, b}; a → {c, d}; b → d, so d is reached along two paths
+	root := f.goal("Root")
+	a := f.goal("A", root)
+	b := f.goal("B", root)
+	c := f.goal("C", a)
+	d := f.goal("D", a, b)
+
+	f.objective(root, ObjectiveStatusCompleted, 50, 0.25)
+	f.objective(a, ObjectiveStatusPending, 0, 0)
+	f.objective(a, ObjectiveStatusCompleted, 0, 0)
+	f.objective(b, ObjectiveStatusCompleted, 0, 0)
+	f.objective(c, ObjectiveStatusFailed, 0, 0)
+	f.objective(d, ObjectiveStatusCompleted, 100, 0.5)
+	f.objective(d, ObjectiveStatusInProgress, 0, 0)
+
+	tree, err := f.gm.GetGoalTreeWithRollups(ctx, root.ID, GoalTreeOptions{})
+	if err != nil {
+		t.Fatalf("Failed to build goal tree: %v", err)
+	}
+	if len(tree.Roots) != 1 || tree.Roots[0].Goal.ID != root.ID {
+		t.Fatalf("Expected the requested root, got %d roots", len(tree.Roots))
+	}
+
+	top := tree.Roots[0]
+	assertProgress(t, "root rollup", top.Rollup, 7, 4, 0.75)
+	if top.Rollup.TokensUsed != 150 || top.Descendants != 4 {
+		t.Errorf("Expected 150 tokens over 4 descendants, got %d over %d", top.Rollup.TokensUsed, top.Descendants)
+	}
+	if math.Abs(top.Rollup.Percent()-400.0/7) > 1e-9 {
+		t.Errorf("Expected %.2f%% complete, got %.2f%%", 400.0/7, top.Rollup.Percent())
+	}
+	assertProgress(t, "tree total", tree.Total, 7, 4, 0.75)
+
+	if len(top.Children) != 2 || top.Children[0].Goal.ID != a.ID || top.Children[1].Goal.ID != b.ID {
+		t.Fatalf("Expected A and B below the root in order")
+	}
+	nodeA, nodeB := top.Children[0], top.Children[1]
+	assertProgress(t, "A rollup", nodeA.Rollup, 5, 2, 0.5)
+	assertProgress(t, "B rollup", nodeB.Rollup, 3, 2, 0.5)
+	if nodeA.Rollup.ByStatus[ObjectiveStatusFailed] != 1 || nodeA.Rollup.ByStatus[ObjectiveStatusInProgress] != 1 {
+		t.Errorf("Expected A's rollup to count C's failure and D's work in progress, got %v", nodeA.Rollup.ByStatus)
+	}
+
+	// The shared sub-goal appears under both parents with the same numbers
+	if len(nodeA.Children) != 2 || len(nodeB.Children) != 1 {
+		t.Fatalf("Expected C and D below A and D below B")
+	}
+	for _, node := range []*GoalTreeNode{nodeA.Children[1], nodeB.Children[0]} {
+		if node.Goal.ID != d.ID || !node.Shared || node.Depth != 2 {
+			t.Errorf("Expected shared goal D at depth 2, got %s (shared %v, depth %d)", node.Goal.Title, node.Shared, node.Depth)
+		}
+		assertProgress(t, "D rollup", node.Rollup, 2, 1, 0.5)
+	}
+
+	// Each node's rollup matches the flat progress of its goal
+	var check func(node *GoalTreeNode)
+	check = func(node *GoalTreeNode) {
+		flat, err := f.gm.GetGoalProgress(ctx, node.Goal.ID)
+		if err != nil {
+			t.Fatalf("Failed to get progress of %s: %v", node.Goal.Title, err)
+		}
+		assertProgress(t, node.Goal.Title+" rollup", node.Rollup, flat.Objectives, flat.Completed(), flat.Spend)
+		if node.Rollup.TokensUsed != flat.TokensUsed {
+			t.Errorf("%s: tree shows %d tokens, flat progress %d", node.Goal.Title, node.Rollup.TokensUsed, flat.TokensUsed)
+		}
+		for _, child := range node.Children {
+			check(child)
+		}
+	}
+	check(top)
+}
+
+func TestGoalManager_GetGoalTreeWithRollups_Depth(t *testing.T) {
+	f := newGoalTreeFixture(t)
+	ctx := context.Background()
+
+	root := f.goal("A root")
+	child := f.goal("Child", root)
+	grandchild := f.goal("Grandchild", child)
+	other := f.goal("Other")
+	f.objective(grandchild, ObjectiveStatusCompleted, 10, 0.1)
+
+	tree, err := f.gm.GetGoalTreeWithRollups(ctx, "", GoalTreeOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("Failed to build goal tree: %v", err)
+	}
+	if len(tree.Roots) != 2 || tree.Roots[0].Goal.ID != root.ID || tree.Roots[1].Goal.ID != other.ID {
+		t.Fatalf("Expected both top-level goals as roots, got %d", len(tree.Roots))
+	}
+
+	childNode := tree.Roots[0].Children[0]
+	if !childNode.Truncated || len(childNode.Children) != 0 {
+		t.Errorf("Expected the child's sub-goals to be hidden at depth 1")
+	}
+	// Hidden goals still count towards the rollups
+	assertProgress(t, "root rollup", tree.Roots[0].Rollup, 1, 1, 0.1)
+	assertProgress(t, "child rollup", childNode.Rollup, 1, 1, 0.1)
+	assertProgress(t, "tree total", tree.Total, 1, 1, 0.1)
+}
+
+func TestGoalManager_GetGoalTreeWithRollups_Cycle(t *testing.T) {
+	f := newGoalTreeFixture(t)
+	ctx := context.Background()
+
+	first := f.goal("First")
+	second := f.goal("Second", first)
+	if err := f.gm.AddSubGoal(ctx, second.ID, first.ID); err != nil {
+		t.Fatalf("Failed to close the cycle: %v", err)
+	}
+	f.objective(first, ObjectiveStatusCompleted, 0, 0)
+	f.objective(second, ObjectiveStatusPending, 0, 0)
+
+	// Neither goal is top-level, but both still appear once as a root
+	tree, err := f.gm.GetGoalTreeWithRollups(ctx, "", GoalTreeOptions{})
+	if err != nil {
+		t.Fatalf("Failed to build goal tree: %v", err)
+	}
+	if len(tree.Roots) != 1 || tree.Roots[0].Goal.ID != first.ID {
+		t.Fatalf("Expected the first goal as the only root, got %d roots", len(tree.Roots))
+	}
+
+	top := tree.Roots[0]
+	assertProgress(t, "root rollup", top.Rollup, 2, 1, 0)
+	repeat := top.Children[0].Children[0]
+	if repeat.Goal.ID != first.ID || !repeat.Cycle || len(repeat.Children) != 0 {
+		t.Errorf("Expected the cycle to stop at the repeated goal")
+	}
+	assertProgress(t, "tree total", tree.Total, 2, 1, 0)
+}
+
+func TestGoalManager_GetGoalProgress(t *testing.T) {
+	f := newGoalTreeFixture(t)
+	ctx := context.Background()
+
+	// parent → child → parent, so the walk has to stop at the cycle
+	parent := f.goal("Parent")
+	child := f.goal("Child", parent)
+	if err := f.gm.AddSubGoal(ctx, child.ID, parent.ID); err != nil {
+		t.Fatalf("Failed to close the cycle: %v", err)
+	}
+	empty := f.goal("Empty")
+
+	prioritized := func(goal *Goal, priority int, complete bool) {
+		objective, err := f.om.CreateObjective(ctx, goal.ID, f.methodID, goal.Title+" objective", "", nil, priority)
+		if err != nil {
+			t.Fatalf("Failed to create objective: %v", err)
+		}
+		if !complete {
+			return
+		}
+		if _, err := f.om.StartObjective(ctx, objective.ID); err != nil {
+			t.Fatalf("Failed to start objective: %v", err)
+		}
+		if _, err := f.om.CompleteObjective(ctx, objective.ID, ObjectiveResult{Success: true}); err != nil {
+			t.Fatalf("Failed to complete objective: %v", err)
+		}
+	}
+	prioritized(parent, 9, true)
+	prioritized(parent, 1, false)
+	prioritized(child, 10, false)
+	f.objective(child, ObjectiveStatusFailed, 0, 0)
+	f.objective(child, ObjectiveStatusInProgress, 40, 0.2)
+
+	progress, err := f.gm.GetGoalProgress(ctx, parent.ID)
+	if err != nil {
+		t.Fatalf("Failed to get progress: %v", err)
+	}
+	assertProgress(t, "parent", *progress, 5, 1, 0.2)
+	if progress.Failed() != 1 || progress.InProgress() != 1 || progress.TokensUsed != 40 {
+		t.Errorf("Expected 1 failed, 1 in progress and 40 tokens, got %d, %d and %d",
+			progress.Failed(), progress.InProgress(), progress.TokensUsed)
+	}
+	if progress.Weight != 30 || math.Abs(progress.WeightedPercent()-30) > 1e-9 {
+		t.Errorf("Expected 30%% of a weight of 30, got %.2f%% of %d", progress.WeightedPercent(), progress.Weight)
+	}
+	if progress.LastActivity.IsZero() {
+		t.Error("Expected the most recent activity to be recorded")
+	}
+
+	// The cycle gives the child the same rollup
+	fromChild, err := f.gm.GetGoalProgress(ctx, child.ID)
+	if err != nil {
+		t.Fatalf("Failed to get progress: %v", err)
+	}
+	assertProgress(t, "child", *fromChild, 5, 1, 0.2)
+
+	none, err := f.gm.GetGoalProgress(ctx, empty.ID)
+	if err != nil {
+		t.Fatalf("Failed to get progress: %v", err)
+	}
+	if none.Percent() != 0 || none.WeightedPercent() != 0 || !none.LastActivity.IsZero() {
+		t.Errorf("Expected a goal without objectives to report 0%%, got %.2f%% (weighted %.2f%%)",
+			none.Percent(), none.WeightedPercent())
+	}
+}
@@ -0,0 +1,506 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+const (
+	// IntentTaskType attributes intent classification spending in the budget
+	IntentTaskType = "meta"
+
+	// IntentChat is the intent for turns that are answered as plain conversation
+	IntentChat = "chat"
+
+	// ChatOverridePrefix forces a turn to be answered as plain conversation,
+	// skipping classification entirely
+	ChatOverridePrefix = "/chat"
+)
+
+// IntentKind determines how a classified turn is handled.
+type IntentKind string
+
+const (
+	// IntentKindChat turns are answered by the LLM
+	IntentKindChat IntentKind = "chat"
+
+	// IntentKindCreate turns create goals or objectives after confirmation
+	IntentKindCreate IntentKind = "create"
+
+	// IntentKindQuery turns are answered from local data without calling the LLM
+	IntentKindQuery IntentKind = "query"
+
+	// IntentKindAction turns change objective state after confirmation
+	IntentKindAction IntentKind = "action"
+)
+
+// NeedsConfirmation reports whether operations of this kind must be confirmed
+// by the user before they run.
+func (k IntentKind) NeedsConfirmation() bool {
+	return k == IntentKindCreate || k == IntentKindAction
+}
+
+// IntentOperation is a parsed, structured operation ready to be confirmed and run.
+type IntentOperation struct {
+	// Summary describes exactly what Execute will do, for confirmation
+	Summary string
+
+	// Execute performs the operation and returns the reply for the user
+	Execute func(ctx context.Context) (string, error)
+}
+
+// IntentHandler turns the parameters extracted by the classifier into an
+// operation. It should resolve and validate everything up front so the
+// confirmation summary is accurate, and leave all changes to Execute.
+type IntentHandler func(ctx context.Context, params map[string]string) (*IntentOperation, error)
+
+// IntentDefinition describes an intent to the classifier and handles it.
+type IntentDefinition struct {
+	// Name identifies the intent in classifier output, e.g. "create_goal"
+	Name string
+
+	// Kind determines whether the intent is confirmed and whether it may use the LLM
+	Kind IntentKind
+
+	// Description tells the classifier when to choose this intent
+	Description string
+
+	// Parameters lists the values the classifier should extract
+	Parameters []string
+
+	// Examples are sample messages for this intent, included in the classifier prompt
+	Examples []string
+
+	// Handler prepares the operation (unused for chat intents)
+	Handler IntentHandler
+}
+
+// IntentRegistry holds the intents the classifier can choose from.
+// New intents are added with Register; the classifier prompt is built from
+// the registered definitions, so no prompt changes are needed.
+type IntentRegistry struct {
+	mu      sync.RWMutex
+	intents map[string]*IntentDefinition
+	order   []string
+}
+
+// NewIntentRegistry creates a registry containing only the chat intent.
+func NewIntentRegistry() *IntentRegistry {
+	registry := &IntentRegistry{
+		intents: make(map[string]*IntentDefinition),
+	}
+	registry.Register(IntentDefinition{
+		Name:        IntentChat,
+		Kind:        IntentKindChat,
+		Description: "General conversation, advice, or questions that are not about the user's own goals, objectives, or spending.",
+	})
+	return registry
+}
+
+// Register adds an intent, replacing any existing intent with the same name.
+func (r *IntentRegistry) Register(def IntentDefinition) error {
+	if def.Name == "" {
+		return fmt.Errorf("intent name cannot be empty")
+	}
+	if strings.ContainsAny(def.Name, " \t\n\"") {
+		return fmt.Errorf("intent name %q must be a single word", def.Name)
+	}
+	switch def.Kind {
+	case IntentKindChat:
+	case IntentKindCreate, IntentKindQuery, IntentKindAction:
+		if def.Handler == nil {
+			return fmt.Errorf("intent %s needs a handler", def.Name)
+		}
+	default:
+		return fmt.Errorf("intent %s has unknown kind %q", def.Name, def.Kind)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.intents[def.Name]; !exists {
+		r.order = append(r.order, def.Name)
+	}
+	r.intents[def.Name] = &def
+	return nil
+}
+
+// Get returns the intent with the given name.
+func (r *IntentRegistry) Get(name string) (*IntentDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, exists := r.intents[name]
+	return def, exists
+}
+
+// Definitions returns all intents in registration order.
+func (r *IntentRegistry) Definitions() []*IntentDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]*IntentDefinition, 0, len(r.order))
+	for _, name := range r.order {
+		defs = append(defs, r.intents[name])
+	}
+	return defs
+}
+
+// ClassifierPrompt builds the classification prompt for a message.
+func (r *IntentRegistry) ClassifierPrompt(message string) string {
+	var b strings.Builder
+	b.WriteString("Classify the user's message to a personal work assistant. Reply with only a JSON object:\n")
+	b.WriteString(`{"intent": "<intent name>", "confidence": <0.0 to 1.0>, "params": {"<param>": "<value>"}}`)
+	b.WriteString("\n\nIntents:\n")
+
+	for _, def := range r.Definitions() {
+		fmt.Fprintf(&b, "- %s: %s", def.Name, def.Description)
+		if len(def.Parameters) > 0 {
+			fmt.Fprintf(&b, " Params: %s.", strings.Join(def.Parameters, ", "))
+		}
+		for _, example := range def.Examples {
+			fmt.Fprintf(&b, " Example: %q.", example)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\nOnly include params stated in the message. Use \"chat\" with low confidence when unsure.\n\n")
+	fmt.Fprintf(&b, "Message: %s\n", message)
+	return b.String()
+}
+
+// IntentClassification is the classifier's reading of one message.
+type IntentClassification struct {
+	// Intent is the name of the chosen intent
+	Intent string `json:"intent"`
+
+	// Confidence is how sure the classifier is (0-1)
+	Confidence float64 `json:"confidence"`
+
+	// Params are the values extracted for the intent
+	Params map[string]string `json:"params,omitempty"`
+
+	// Cost is what the classification request cost
+	Cost float64 `json:"-"`
+}
+
+// IntentClassifierConfig configures intent classification.
+type IntentClassifierConfig struct {
+	// Router routes classification requests (required)
+	Router *llm.Router
+
+	// Budget records classification spending under IntentTaskType (optional)
+	Budget *llm.BudgetManager
+
+	// ConfidenceThreshold is the confidence below which turns fall back to chat
+	ConfidenceThreshold float64
+
+	// RequestBudget is the most a single classification may cost, in dollars
+	RequestBudget float64
+
+	// SessionBudget caps classification spending for the whole session, in
+	// dollars; once spent, turns fall back to chat
+	SessionBudget float64
+
+	// MaxTokens caps the classification response length
+	MaxTokens int
+}
+
+// DefaultIntentClassifierConfig returns defaults suited to a cheap model.
+func DefaultIntentClassifierConfig() IntentClassifierConfig {
+	return IntentClassifierConfig{
+		ConfidenceThreshold: 0.7,
+		RequestBudget:       0.002,
+		SessionBudget:       0.05,
+		MaxTokens:           200,
+	}
+}
+
+// IntentClassifier classifies messages with a cheap LLM call.
+type IntentClassifier struct {
+	registry *IntentRegistry
+	config   IntentClassifierConfig
+
+	mu    sync.Mutex
+	spent float64
+}
+
+// NewIntentClassifier creates a classifier for the registry's intents.
+// Zero config values fall back to the defaults.
+func NewIntentClassifier(registry *IntentRegistry, config IntentClassifierConfig) *IntentClassifier {
+	defaults := DefaultIntentClassifierConfig()
+	if config.ConfidenceThreshold <= 0 {
+		config.ConfidenceThreshold = defaults.ConfidenceThreshold
+	}
+	if config.RequestBudget <= 0 {
+		config.RequestBudget = defaults.RequestBudget
+	}
+	if config.SessionBudget <= 0 {
+		config.SessionBudget = defaults.SessionBudget
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = defaults.MaxTokens
+	}
+
+	return &IntentClassifier{
+		registry: registry,
+		config:   config,
+	}
+}
+
+// ConfidenceThreshold returns the confidence below which turns fall back to chat.
+func (c *IntentClassifier) ConfidenceThreshold() float64 {
+	return c.config.ConfidenceThreshold
+}
+
+// SessionSpent returns how much classification has cost this session.
+func (c *IntentClassifier) SessionSpent() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spent
+}
+
+// Classify asks the LLM which registered intent a message expresses.
+func (c *IntentClassifier) Classify(ctx context.Context, message string) (*IntentClassification, error) {
+	if c.config.Router == nil {
+		return nil, fmt.Errorf("intent classifier has no router")
+	}
+
+	c.mu.Lock()
+	spent := c.spent
+	c.mu.Unlock()
+	if spent+c.config.RequestBudget > c.config.SessionBudget {
+		return nil, errs.Newf(errs.BudgetExceeded, "classification budget for this session is spent ($%.4f of $%.4f)",
+			spent, c.config.SessionBudget)
+	}
+
+	budget := c.config.RequestBudget
+	result, err := c.config.Router.Route(ctx, llm.TaskRequest{
+		Prompt:           c.registry.ClassifierPrompt(message),
+		MaxTokens:        c.config.MaxTokens,
+		Temperature:      0.0,
+		TaskType:         IntentTaskType,
+		QualityRequired:  llm.QualityBasic,
+		BudgetConstraint: &budget,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("LLM routing failed: %w", err)
+	}
+	if result.ExecutionResult == nil {
+		return nil, fmt.Errorf("no result from LLM execution")
+	}
+
+	completion := result.ExecutionResult
+	c.mu.Lock()
+	c.spent += completion.Cost
+	c.mu.Unlock()
+
+	if c.config.Budget != nil {
+		if err := c.config.Budget.RecordUsage(ctx, llm.Transaction{
+			Provider:   result.SelectedModel.Provider,
+			Model:      result.SelectedModel.Model,
+			TaskType:   IntentTaskType,
+			TokensUsed: completion.TokensUsed,
+			Cost:       completion.Cost,
+			Success:    true,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to record classification cost: %w", err)
+		}
+	}
+
+	classification, err := parseIntentClassification(completion.Text)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := c.registry.Get(classification.Intent); !exists {
+		return nil, fmt.Errorf("classifier chose unknown intent %q", classification.Intent)
+	}
+	classification.Cost = completion.Cost
+
+	return classification, nil
+}
+
+// parseIntentClassification extracts the JSON object from a classifier reply.
+// Parameter values of any JSON type are converted to strings.
+func parseIntentClassification(text string) (*IntentClassification, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("classifier reply contains no JSON object")
+	}
+
+	var raw struct {
+		Intent     string                 `json:"intent"`
+		Confidence float64                `json:"confidence"`
+		Params     map[string]interface{} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse classifier reply: %w", err)
+	}
+	if raw.Intent == "" {
+		return nil, fmt.Errorf("classifier reply has no intent")
+	}
+
+	classification := &IntentClassification{
+		Intent:     raw.Intent,
+		Confidence: clampFloat(raw.Confidence, 0, 1),
+		Params:     make(map[string]string),
+	}
+	for key, value := range raw.Params {
+		if value == nil {
+			continue
+		}
+		if s := strings.TrimSpace(fmt.Sprint(value)); s != "" {
+			classification.Params[key] = s
+		}
+	}
+	return classification, nil
+}
+
+// IntentChatFunc answers a turn as plain conversation.
+type IntentChatFunc func(ctx context.Context, message string) (string, error)
+
+// IntentConfirmFunc asks the user to confirm an operation before it runs.
+type IntentConfirmFunc func(operation *IntentOperation) bool
+
+// IntentTurn records how one interactive turn was handled.
+type IntentTurn struct {
+	// Message is the user's input, without any override prefix
+	Message string
+
+	// Classification is the classifier's reading (nil if classification was skipped or failed)
+	Classification *IntentClassification
+
+	// Intent is the intent the turn was handled as
+	Intent string
+
+	// FallbackReason explains why the turn was handled as chat instead of the classified intent
+	FallbackReason string
+
+	// Operation is the prepared operation for create, query and action intents
+	Operation *IntentOperation
+
+	// Confirmed reports whether the operation was confirmed (or needed no confirmation)
+	Confirmed bool
+
+	// Response is the reply for the user
+	Response string
+}
+
+// IntentDispatcher handles interactive turns: it classifies each message,
+// falls back to chat when unsure, and confirms create and action operations
+// before executing them.
+type IntentDispatcher struct {
+	registry   *IntentRegistry
+	classifier *IntentClassifier
+	chat       IntentChatFunc
+}
+
+// NewIntentDispatcher creates a dispatcher. A nil classifier handles every turn as chat.
+func NewIntentDispatcher(registry *IntentRegistry, classifier *IntentClassifier, chat IntentChatFunc) *IntentDispatcher {
+	return &IntentDispatcher{
+		registry:   registry,
+		classifier: classifier,
+		chat:       chat,
+	}
+}
+
+// HandleTurn classifies and handles one message. confirm is asked before
+// create and action operations run; declining leaves everything unchanged.
+// Problems preparing an operation are reported in the response rather than
+// as errors, so the session can continue.
+func (d *IntentDispatcher) HandleTurn(ctx context.Context, message string, confirm IntentConfirmFunc) (*IntentTurn, error) {
+	message = strings.TrimSpace(message)
+	turn := &IntentTurn{Message: message}
+
+	if rest, forced := cutChatOverride(message); forced {
+		turn.Message = rest
+		turn.FallbackReason = "forced with " + ChatOverridePrefix
+		return d.handleChat(ctx, turn)
+	}
+
+	if d.classifier == nil {
+		turn.FallbackReason = "no classifier configured"
+		return d.handleChat(ctx, turn)
+	}
+
+	classification, err := d.classifier.Classify(ctx, message)
+	if err != nil {
+		turn.FallbackReason = err.Error()
+		return d.handleChat(ctx, turn)
+	}
+	turn.Classification = classification
+
+	def, _ := d.registry.Get(classification.Intent)
+	if def.Kind == IntentKindChat {
+		return d.handleChat(ctx, turn)
+	}
+	if classification.Confidence < d.classifier.ConfidenceThreshold() {
+		turn.FallbackReason = fmt.Sprintf("low confidence (%.2f) for %s", classification.Confidence, def.Name)
+		return d.handleChat(ctx, turn)
+	}
+
+	turn.Intent = def.Name
+	operation, err := def.Handler(ctx, classification.Params)
+	if err != nil {
+		turn.Response = fmt.Sprintf("I read this as %s, but %v. Prefix with %s to just chat.", def.Name, err, ChatOverridePrefix)
+		return turn, nil
+	}
+	turn.Operation = operation
+
+	if def.Kind.NeedsConfirmation() && (confirm == nil || !confirm(operation)) {
+		turn.Response = "Cancelled; nothing was changed."
+		return turn, nil
+	}
+	turn.Confirmed = true
+
+	response, err := operation.Execute(ctx)
+	if err != nil {
+		return turn, fmt.Errorf("%s failed: %w", def.Name, err)
+	}
+	turn.Response = response
+	return turn, nil
+}
+
+// handleChat answers the turn as plain conversation.
+func (d *IntentDispatcher) handleChat(ctx context.Context, turn *IntentTurn) (*IntentTurn, error) {
+	turn.Intent = IntentChat
+	if d.chat == nil {
+		return turn, fmt.Errorf("no chat handler configured")
+	}
+
+	response, err := d.chat(ctx, turn.Message)
+	if err != nil {
+		return turn, fmt.Errorf("chat failed: %w", err)
+	}
+	turn.Response = response
+	return turn, nil
+}
+
+// cutChatOverride strips a leading chat override prefix.
+func cutChatOverride(message string) (string, bool) {
+	if message == ChatOverridePrefix {
+		return "", true
+	}
+	if rest, found := strings.CutPrefix(message, ChatOverridePrefix+" "); found {
+		return strings.TrimSpace(rest), true
+	}
+	return message, false
+}
+
+// clampFloat limits value to the range [min, max].
+func clampFloat(value, min, max float64) float64 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,423 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+// IntentServices are the managers the built-in intents work through.
+type IntentServices struct {
+	Goals      *GoalManager
+	Objectives *ObjectiveManager
+	Methods    *MethodManager
+
+	// Budget answers spending queries (optional)
+	Budget *llm.BudgetManager
+
+	// DefaultPriority is used when a message does not state a priority
+	DefaultPriority int
+}
+
+// RegisterBuiltinIntents registers the goal, objective, status and budget intents.
+func RegisterBuiltinIntents(registry *IntentRegistry, services IntentServices) error {
+	if services.Goals == nil || services.Objectives == nil || services.Methods == nil {
+		return fmt.Errorf("built-in intents need goal, objective and method managers")
+	}
+	if services.DefaultPriority < 1 || services.DefaultPriority > 10 {
+		services.DefaultPriority = 5
+	}
+
+	h := &builtinIntents{services: services}
+	defs := []IntentDefinition{
+		{
+			Name:        "create_goal",
+			Kind:        IntentKindCreate,
+			Description: "Create a new goal.",
+			Parameters:  []string{"title", "description", "priority (1-10)"},
+			Examples:    []string{"new goal: learn Spanish this year"},
+			Handler:     h.createGoal,
+		},
+		{
+			Name:        "create_objective",
+			Kind:        IntentKindCreate,
+			Description: "Add an objective to an existing goal.",
+			Parameters:  []string{"goal (title or ID)", "title", "description", "priority (1-10)"},
+			Examples:    []string{"add an objective to the migration goal to test the rollback script"},
+			Handler:     h.createObjective,
+		},
+		{
+			Name:        "query_status",
+			Kind:        IntentKindQuery,
+			Description: "Overall status: active goals and objectives in progress.",
+			Examples:    []string{"what's going on right now?"},
+			Handler:     h.queryStatus,
+		},
+		{
+			Name:        "query_goal",
+			Kind:        IntentKindQuery,
+			Description: "Details and objectives of one goal.",
+			Parameters:  []string{"goal (title or ID)"},
+			Examples:    []string{"how is the migration goal doing?"},
+			Handler:     h.queryGoal,
+		},
+		{
+			Name:        "query_budget",
+			Kind:        IntentKindQuery,
+			Description: "How much has been spent on LLM usage.",
+			Parameters:  []string{"period (day, week or month)"},
+			Examples:    []string{"how much have I spent this week?"},
+			Handler:     h.queryBudget,
+		},
+		{
+			Name:        "start_objective",
+			Kind:        IntentKindAction,
+			Description: "Start work on a pending objective.",
+			Parameters:  []string{"objective (title or ID)"},
+			Examples:    []string{"start the rollback test objective"},
+			Handler:     h.startObjective,
+		},
+		{
+			Name:        "complete_objective",
+			Kind:        IntentKindAction,
+			Description: "Mark an objective in progress as completed.",
+			Parameters:  []string{"objective (title or ID)", "message (outcome)"},
+			Examples:    []string{"the rollback script test is done, it worked"},
+			Handler:     h.completeObjective,
+		},
+	}
+
+	for _, def := range defs {
+		if err := registry.Register(def); err != nil {
+			return fmt.Errorf("failed to register intent %s: %w", def.Name, err)
+		}
+	}
+	return nil
+}
+
+// builtinIntents implements the built-in intent handlers.
+type builtinIntents struct {
+	services IntentServices
+}
+
+func (h *builtinIntents) createGoal(ctx context.Context, params map[string]string) (*IntentOperation, error) {
+	title := params["title"]
+	if title == "" {
+		return nil, fmt.Errorf("no goal title was given")
+	}
+	priority, err := h.priority(params)
+	if err != nil {
+		return nil, err
+	}
+	description := params["description"]
+
+	summary := fmt.Sprintf("Create goal %q (priority %d)", title, priority)
+	if description != "" {
+		summary += "\n  " + description
+	}
+
+	return &IntentOperation{
+		Summary: summary,
+		Execute: func(ctx context.Context) (string, error) {
+			goal, err := h.services.Goals.CreateGoal(ctx, title, description, priority, nil)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Created goal %q (%s).", goal.Title, goal.ID), nil
+		},
+	}, nil
+}
+
+func (h *builtinIntents) createObjective(ctx context.Context, params map[string]string) (*IntentOperation, error) {
+	title := params["title"]
+	if title == "" {
+		return nil, fmt.Errorf("no objective title was given")
+	}
+	goal, err := h.findGoal(ctx, params["goal"])
+	if err != nil {
+		return nil, err
+	}
+	priority, err := h.priority(params)
+	if err != nil {
+		return nil, err
+	}
+	description := params["description"]
+
+	summary := fmt.Sprintf("Create objective %q under goal %q (priority %d), with a new one-step method", title, goal.Title, priority)
+	if description != "" {
+		summary += "\n  " + description
+	}
+
+	return &IntentOperation{
+		Summary: summary,
+		Execute: func(ctx context.Context) (string, error) {
+			// Objectives need a method; start with a single step the learning loop can refine
+			method, err := h.services.Methods.CreateMethod(ctx, title, description,
+				[]ApproachStep{{Description: title}}, MethodDomainUser, nil)
+			if err != nil {
+				return "", fmt.Errorf("failed to create method: %w", err)
+			}
+
+			objective, err := h.services.Objectives.CreateObjective(ctx, goal.ID, method.ID, title, description, nil, priority)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Created objective %q (%s) for goal %q.", objective.Title, objective.ID, goal.Title), nil
+		},
+	}, nil
+}
+
+func (h *builtinIntents) queryStatus(ctx context.Context, params map[string]string) (*IntentOperation, error) {
+	return &IntentOperation{
+		Summary: "Show overall status",
+		Execute: func(ctx context.Context) (string, error) {
+			active := GoalStatusActive
+			goals, err := h.services.Goals.ListGoals(ctx, GoalFilter{Status: &active})
+			if err != nil {
+				return "", fmt.Errorf("failed to list goals: %w", err)
+			}
+			objectives, err := h.services.Objectives.ListObjectives(ctx, ObjectiveFilter{})
+			if err != nil {
+				return "", fmt.Errorf("failed to list objectives: %w", err)
+			}
+
+			counts := make(map[ObjectiveStatus]int)
+			completedToday := 0
+			today := time.Now().Format("2006-01-02")
+			for _, objective := range objectives {
+				counts[objective.Status]++
+				if objective.CompletedAt != nil && objective.CompletedAt.Format("2006-01-02") == today {
+					completedToday++
+				}
+			}
+
+			return fmt.Sprintf("Active goals: %d\nObjectives in progress: %d, pending: %d\nCompleted today: %d",
+				len(goals), counts[ObjectiveStatusInProgress], counts[ObjectiveStatusPending], completedToday), nil
+		},
+	}, nil
+}
+
+func (h *builtinIntents) queryGoal(ctx context.Context, params map[string]string) (*IntentOperation, error) {
+	goal, err := h.findGoal(ctx, params["goal"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &IntentOperation{
+		Summary: fmt.Sprintf("Show goal %q", goal.Title),
+		Execute: func(ctx context.Context) (string, error) {
+			objectives, err := h.services.Objectives.GetObjectivesForGoal(ctx, goal.ID)
+			if err != nil {
+				return "", fmt.Errorf("failed to get objectives: %w", err)
+			}
+
+			var b strings.Builder
+			fmt.Fprintf(&b, "%s (%s, priority %d)", goal.Title, goal.Status, goal.Priority)
+			if goal.Description != "" {
+				fmt.Fprintf(&b, "\n%s", goal.Description)
+			}
+			if len(objectives) == 0 {
+				b.WriteString("\nNo objectives yet.")
+			}
+			for _, objective := range objectives {
+				fmt.Fprintf(&b, "\n- %s [%s]", objective.Title, objective.Status)
+			}
+			return b.String(), nil
+		},
+	}, nil
+}
+
+func (h *builtinIntents) queryBudget(ctx context.Context, params map[string]string) (*IntentOperation, error) {
+	period, label, err := parseBudgetPeriod(params["period"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &IntentOperation{
+		Summary: "Show spending " + label,
+		Execute: func(ctx context.Context) (string, error) {
+			if h.services.Budget == nil {
+				return "Spending is not being tracked.", nil
+			}
+
+			spent := h.services.Budget.GetSpending(period, time.Now())
+			response := fmt.Sprintf("Spent %s: $%.4f", label, spent)
+			if status := h.services.Budget.GetBudgetStatus().Periods[period.String()]; status != nil {
+				response += fmt.Sprintf(" of $%.2f ($%.2f left)", status.Limit, status.Remaining)
+			}
+			return response, nil
+		},
+	}, nil
+}
+
+func (h *builtinIntents) startObjective(ctx context.Context, params map[string]string) (*IntentOperation, error) {
+	objective, err := h.findObjective(ctx, params["objective"], ObjectiveStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	if objective.Status != ObjectiveStatusPending {
+		return nil, fmt.Errorf("objective %q is %s, not pending", objective.Title, objective.Status)
+	}
+
+	return &IntentOperation{
+		Summary: fmt.Sprintf("Start objective %q", objective.Title),
+		Execute: func(ctx context.Context) (string, error) {
+			if _, err := h.services.Objectives.StartObjective(ctx, objective.ID); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Started objective %q.", objective.Title), nil
+		},
+	}, nil
+}
+
+func (h *builtinIntents) completeObjective(ctx context.Context, params map[string]string) (*IntentOperation, error) {
+	objective, err := h.findObjective(ctx, params["objective"], ObjectiveStatusInProgress)
+	if err != nil {
+		return nil, err
+	}
+	if objective.Status != ObjectiveStatusInProgress {
+		return nil, fmt.Errorf("objective %q is %s, not in progress", objective.Title, objective.Status)
+	}
+	message := params["message"]
+	if message == "" {
+		message = "Completed from interactive mode"
+	}
+
+	return &IntentOperation{
+		Summary: fmt.Sprintf("Mark objective %q completed: %s", objective.Title, message),
+		Execute: func(ctx context.Context) (string, error) {
+			result := ObjectiveResult{Success: true, Message: message}
+			if _, err := h.services.Objectives.CompleteObjective(ctx, objective.ID, result); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Completed objective %q.", objective.Title), nil
+		},
+	}, nil
+}
+
+// priority parses the priority parameter, defaulting when it is missing.
+func (h *builtinIntents) priority(params map[string]string) (int, error) {
+	value := params["priority"]
+	if value == "" {
+		return h.services.DefaultPriority, nil
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil || priority < 1 || priority > 10 {
+		return 0, fmt.Errorf("priority must be a number from 1 to 10, got %q", value)
+	}
+	return priority, nil
+}
+
+// findGoal resolves a goal by ID, exact title, or a unique partial title match.
+func (h *builtinIntents) findGoal(ctx context.Context, ref string) (*Goal, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("no goal was named")
+	}
+	if goal, err := h.services.Goals.GetGoal(ctx, ref); err == nil {
+		return goal, nil
+	}
+
+	goals, err := h.services.Goals.ListGoals(ctx, GoalFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	titles := make([]string, len(goals))
+	for i, goal := range goals {
+		titles[i] = goal.Title
+	}
+
+	index, err := matchTitle(titles, ref, "goal")
+	if err != nil {
+		return nil, err
+	}
+	return goals[index], nil
+}
+
+// findObjective resolves an objective by ID, exact title, or a unique partial
+// title match. Ambiguous matches are narrowed to objectives with the preferred status.
+func (h *builtinIntents) findObjective(ctx context.Context, ref string, preferred ObjectiveStatus) (*Objective, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("no objective was named")
+	}
+	if objective, err := h.services.Objectives.GetObjective(ctx, ref); err == nil {
+		return objective, nil
+	}
+
+	objectives, err := h.services.Objectives.ListObjectives(ctx, ObjectiveFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	titles := make([]string, len(objectives))
+	for i, objective := range objectives {
+		titles[i] = objective.Title
+	}
+	index, err := matchTitle(titles, ref, "objective")
+	if err == nil {
+		return objectives[index], nil
+	}
+
+	var candidates []*Objective
+	for _, objective := range objectives {
+		if objective.Status == preferred {
+			candidates = append(candidates, objective)
+		}
+	}
+	titles = titles[:0]
+	for _, objective := range candidates {
+		titles = append(titles, objective.Title)
+	}
+	if index, narrowed := matchTitle(titles, ref, "objective"); narrowed == nil {
+		return candidates[index], nil
+	}
+	return nil, err
+}
+
+// matchTitle finds the title matching ref exactly or, failing that, the only
+// title containing it. Matching ignores case.
+func matchTitle(titles []string, ref, kind string) (int, error) {
+	needle := strings.ToLower(strings.TrimSpace(ref))
+
+	var partial []int
+	for i, title := range titles {
+		lower := strings.ToLower(title)
+		if lower == needle {
+			return i, nil
+		}
+		if strings.Contains(lower, needle) || strings.Contains(needle, lower) {
+			partial = append(partial, i)
+		}
+	}
+
+	switch len(partial) {
+	case 0:
+		return -1, fmt.Errorf("no %s matches %q", kind, ref)
+	case 1:
+		return partial[0], nil
+	default:
+		names := make([]string, len(partial))
+		for i, index := range partial {
+			names[i] = fmt.Sprintf("%q", titles[index])
+		}
+		return -1, fmt.Errorf("%q matches several %ss: %s", ref, kind, strings.Join(names, ", "))
+	}
+}
+
+// parseBudgetPeriod maps a spoken period to a budget period and a label.
+// It defaults to the current week.
+func parseBudgetPeriod(value string) (llm.BudgetPeriod, string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "day", "daily", "today":
+		return llm.PeriodDaily, "today", nil
+	case "", "week", "weekly", "this week":
+		return llm.PeriodWeekly, "this week", nil
+	case "month", "monthly", "this month":
+		return llm.PeriodMonthly, "this month", nil
+	default:
+		return 0, "", fmt.Errorf("unknown budget period %q (use day, week or month)", value)
+	}
+}
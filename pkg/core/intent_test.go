@@ -0,0 +1,324 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// scriptedClassifierService replies to each completion request with the next
+// scripted text. It cannot list its models.
+type scriptedClassifierService struct {
+	mu      sync.Mutex
+	replies []string
+	prompts []string
+}
+
+func (s *scriptedClassifierService) script(replies ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+func (s *scriptedClassifierService) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.prompts)
+}
+
+func (s *scriptedClassifierService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	if params["operation"] != "complete" {
+		return mcp.ServiceResult{Success: false, Error: fmt.Errorf("unsupported operation")}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prompt, _ := params["prompt"].(string)
+	s.prompts = append(s.prompts, prompt)
+	if len(s.replies) == 0 {
+		return mcp.ServiceResult{Success: false, Error: fmt.Errorf("no scripted reply")}
+	}
+
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return mcp.ServiceResult{
+		Success: true,
+		Data: &mcp.CompletionResponse{
+			Text:       reply,
+			TokensUsed: 40,
+			Model:      "scripted",
+			Provider:   "scripted",
+			Cost:       0.001,
+		},
+	}
+}
+
+// intentFixture wires a dispatcher to a scripted classifier and a test store.
+type intentFixture struct {
+	service    *scriptedClassifierService
+	dispatcher *IntentDispatcher
+	classifier *IntentClassifier
+	budget     *llm.BudgetManager
+	goals      *GoalManager
+	objectives *ObjectiveManager
+	chats      []string
+}
+
+func newIntentFixture(t *testing.T, sessionBudget float64) *intentFixture {
+	t.Helper()
+	store := setupTestStore(t)
+
+	budget, err := llm.NewBudgetManager(filepath.Join(t.TempDir(), "budget"), llm.BudgetConfig{
+		WeeklyLimit:     5.0,
+		TrackingEnabled: true,
+	}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	f := &intentFixture{
+		service:    &scriptedClassifierService{},
+		budget:     budget,
+		goals:      NewGoalManager(store),
+		objectives: NewObjectiveManager(store),
+	}
+
+	registry := NewIntentRegistry()
+	if err := RegisterBuiltinIntents(registry, IntentServices{
+		Goals:           f.goals,
+		Objectives:      f.objectives,
+		Methods:         NewMethodManager(store),
+		Budget:          budget,
+		DefaultPriority: 5,
+	}); err != nil {
+		t.Fatalf("Failed to register intents: %v", err)
+	}
+
+	f.classifier = NewIntentClassifier(registry, IntentClassifierConfig{
+		Router:        llm.NewRouter(f.service),
+		Budget:        budget,
+		SessionBudget: sessionBudget,
+	})
+	f.dispatcher = NewIntentDispatcher(registry, f.classifier, func(ctx context.Context, message string) (string, error) {
+		f.chats = append(f.chats, message)
+		return "chat: " + message, nil
+	})
+	return f
+}
+
+func (f *intentFixture) handle(t *testing.T, message string, approve bool) *IntentTurn {
+	t.Helper()
+	turn, err := f.dispatcher.HandleTurn(context.Background(), message, func(op *IntentOperation) bool {
+		return approve
+	})
+	if err != nil {
+		t.Fatalf("HandleTurn(%q) failed: %v", message, err)
+	}
+	return turn
+}
+
+func TestIntentDispatcher_Intents(t *testing.T) {
+	f := newIntentFixture(t, 0)
+	ctx := context.Background()
+
+	// Entity creation
+	f.service.script(`{"intent": "create_goal", "confidence": 0.95, "params": {"title": "Database migration", "priority": 8}}`)
+	turn := f.handle(t, "new goal: database migration, priority 8", true)
+	if turn.Intent != "create_goal" || !turn.Confirmed {
+		t.Fatalf("Expected a confirmed create_goal turn, got %+v", turn)
+	}
+	if turn.Operation.Summary != `Create goal "Database migration" (priority 8)` {
+		t.Errorf("Unexpected summary: %q", turn.Operation.Summary)
+	}
+	goals, _ := f.goals.ListGoals(ctx, GoalFilter{})
+	if len(goals) != 1 || goals[0].Priority != 8 {
+		t.Fatalf("Expected one goal with priority 8, got %+v", goals)
+	}
+
+	f.service.script("Sure! " + `{"intent": "create_objective", "confidence": 0.9, "params": {"goal": "migration", "title": "Test the rollback script"}}`)
+	turn = f.handle(t, "add an objective to the migration goal to test the rollback script", true)
+	if !strings.Contains(turn.Operation.Summary, `under goal "Database migration"`) {
+		t.Errorf("Expected the goal to be resolved in the summary, got %q", turn.Operation.Summary)
+	}
+	objectives, _ := f.objectives.GetObjectivesForGoal(ctx, goals[0].ID)
+	if len(objectives) != 1 || objectives[0].Title != "Test the rollback script" {
+		t.Fatalf("Expected the new objective, got %+v", objectives)
+	}
+
+	// Actions
+	f.service.script(`{"intent": "start_objective", "confidence": 0.9, "params": {"objective": "rollback"}}`)
+	turn = f.handle(t, "start the rollback one", true)
+	if turn.Response != `Started objective "Test the rollback script".` {
+		t.Errorf("Unexpected response: %q", turn.Response)
+	}
+
+	f.service.script(`{"intent": "complete_objective", "confidence": 0.9, "params": {"objective": "rollback", "message": "rollback works"}}`)
+	f.handle(t, "the rollback test is done, it works", true)
+	objective, _ := f.objectives.GetObjective(ctx, objectives[0].ID)
+	if objective.Status != ObjectiveStatusCompleted || objective.Result == nil || objective.Result.Message != "rollback works" {
+		t.Errorf("Expected a completed objective, got %+v", objective)
+	}
+
+	// Queries are answered locally: one LLM call for classification only
+	calls := f.service.calls()
+	f.service.script(`{"intent": "query_budget", "confidence": 0.9, "params": {"period": "week"}}`)
+	turn = f.handle(t, "how much have I spent this week?", false)
+	if f.service.calls() != calls+1 {
+		t.Errorf("Expected only the classification call for a query, got %d calls", f.service.calls()-calls)
+	}
+	wantSpent := fmt.Sprintf("Spent this week: $%.4f of $5.00", f.classifier.SessionSpent())
+	if !strings.HasPrefix(turn.Response, wantSpent) {
+		t.Errorf("Expected %q, got %q", wantSpent, turn.Response)
+	}
+
+	f.service.script(`{"intent": "query_status", "confidence": 0.8}`)
+	turn = f.handle(t, "what's going on?", false)
+	if !strings.Contains(turn.Response, "Active goals: 1") || !strings.Contains(turn.Response, "Completed today: 1") {
+		t.Errorf("Unexpected status response: %q", turn.Response)
+	}
+
+	f.service.script(`{"intent": "query_goal", "confidence": 0.8, "params": {"goal": "database migration"}}`)
+	turn = f.handle(t, "how is the migration going?", false)
+	if !strings.Contains(turn.Response, "- Test the rollback script [completed]") {
+		t.Errorf("Unexpected goal response: %q", turn.Response)
+	}
+
+	// Chat
+	f.service.script(`{"intent": "chat", "confidence": 0.9}`)
+	turn = f.handle(t, "tell me a joke", false)
+	if turn.Intent != IntentChat || turn.Response != "chat: tell me a joke" {
+		t.Errorf("Expected a chat turn, got %+v", turn)
+	}
+
+	// Classification is attributed to the meta task type
+	spent := f.budget.GetSpendingAnalysis().TaskTypeBreakdown[IntentTaskType]
+	if spent != f.classifier.SessionSpent() || spent <= 0 {
+		t.Errorf("Expected %f attributed to %q, got %f", f.classifier.SessionSpent(), IntentTaskType, spent)
+	}
+}
+
+func TestIntentDispatcher_LowConfidenceFallsBackToChat(t *testing.T) {
+	f := newIntentFixture(t, 0)
+
+	f.service.script(`{"intent": "create_goal", "confidence": 0.4, "params": {"title": "Maybe a goal"}}`)
+	turn := f.handle(t, "I might want to learn piano", true)
+
+	if turn.Intent != IntentChat || !strings.Contains(turn.FallbackReason, "low confidence") {
+		t.Errorf("Expected a low-confidence chat fallback, got %+v", turn)
+	}
+	if len(f.chats) != 1 || f.chats[0] != "I might want to learn piano" {
+		t.Errorf("Expected the message to be chatted, got %v", f.chats)
+	}
+	if goals, _ := f.goals.ListGoals(context.Background(), GoalFilter{}); len(goals) != 0 {
+		t.Errorf("Expected no goals, got %d", len(goals))
+	}
+
+	// Unparseable replies fall back as well
+	f.service.script("I think they want a goal")
+	turn = f.handle(t, "learn piano", true)
+	if turn.Intent != IntentChat || turn.FallbackReason == "" {
+		t.Errorf("Expected a chat fallback for an unparseable reply, got %+v", turn)
+	}
+}
+
+func TestIntentDispatcher_RejectedConfirmation(t *testing.T) {
+	f := newIntentFixture(t, 0)
+
+	f.service.script(`{"intent": "create_goal", "confidence": 0.95, "params": {"title": "Learn piano"}}`)
+	var shown string
+	turn, err := f.dispatcher.HandleTurn(context.Background(), "new goal: learn piano", func(op *IntentOperation) bool {
+		shown = op.Summary
+		return false
+	})
+	if err != nil {
+		t.Fatalf("HandleTurn failed: %v", err)
+	}
+
+	if shown != `Create goal "Learn piano" (priority 5)` {
+		t.Errorf("Expected the parsed operation to be shown, got %q", shown)
+	}
+	if turn.Confirmed || turn.Response != "Cancelled; nothing was changed." {
+		t.Errorf("Expected a cancelled turn, got %+v", turn)
+	}
+	if goals, _ := f.goals.ListGoals(context.Background(), GoalFilter{}); len(goals) != 0 {
+		t.Errorf("Expected no goals after rejecting, got %d", len(goals))
+	}
+
+	// A handler that cannot resolve its parameters reports it without executing
+	f.service.script(`{"intent": "start_objective", "confidence": 0.9, "params": {"objective": "nothing like this"}}`)
+	turn = f.handle(t, "start nothing", true)
+	if turn.Operation != nil || !strings.Contains(turn.Response, `no objective matches "nothing like this"`) {
+		t.Errorf("Expected an unresolved objective response, got %+v", turn)
+	}
+}
+
+func TestIntentDispatcher_ChatOverrideAndSessionCap(t *testing.T) {
+	// Each scripted classification costs $0.001 and may cost up to $0.002,
+	// so a third one would risk exceeding the cap
+	f := newIntentFixture(t, 0.0035)
+
+	turn := f.handle(t, "/chat add an objective to the migration goal", true)
+	if turn.Intent != IntentChat || f.service.calls() != 0 {
+		t.Errorf("Expected /chat to skip classification, got %+v after %d calls", turn, f.service.calls())
+	}
+	if f.chats[0] != "add an objective to the migration goal" {
+		t.Errorf("Expected the prefix to be stripped, got %q", f.chats[0])
+	}
+
+	f.service.script(`{"intent": "chat", "confidence": 0.9}`, `{"intent": "chat", "confidence": 0.9}`)
+	f.handle(t, "hello", false)
+	f.handle(t, "hello again", false)
+
+	turn = f.handle(t, "new goal: learn piano", true)
+	if f.service.calls() != 2 || !strings.Contains(turn.FallbackReason, "budget") {
+		t.Errorf("Expected the session cap to stop classification, got %+v after %d calls", turn, f.service.calls())
+	}
+}
+
+func TestIntentRegistry_Extension(t *testing.T) {
+	registry := NewIntentRegistry()
+	err := registry.Register(IntentDefinition{
+		Name:        "query_weather",
+		Kind:        IntentKindQuery,
+		Description: "Current weather.",
+		Parameters:  []string{"city"},
+		Handler: func(ctx context.Context, params map[string]string) (*IntentOperation, error) {
+			return &IntentOperation{
+				Summary: "Show weather",
+				Execute: func(ctx context.Context) (string, error) { return "Sunny in " + params["city"], nil },
+			}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register intent: %v", err)
+	}
+
+	prompt := registry.ClassifierPrompt("weather in Oslo?")
+	if !strings.Contains(prompt, "- query_weather: Current weather. Params: city.") {
+		t.Errorf("Expected the new intent in the prompt:\n%s", prompt)
+	}
+
+	if err := registry.Register(IntentDefinition{Name: "broken", Kind: IntentKindAction}); err == nil {
+		t.Error("Expected an action intent without a handler to be rejected")
+	}
+
+	service := &scriptedClassifierService{}
+	service.script(`{"intent": "query_weather", "confidence": 0.9, "params": {"city": "Oslo"}}`)
+	classifier := NewIntentClassifier(registry, IntentClassifierConfig{Router: llm.NewRouter(service)})
+	turn, err := NewIntentDispatcher(registry, classifier, nil).HandleTurn(context.Background(), "weather in Oslo?", nil)
+	if err != nil {
+		t.Fatalf("HandleTurn failed: %v", err)
+	}
+	if turn.Response != "Sunny in Oslo" {
+		t.Errorf("Expected the registered handler's response, got %q", turn.Response)
+	}
+}
@@ -2,10 +2,13 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 // LearningAgent defines the interface for LLM-based learning and method refinement.
@@ -63,6 +66,14 @@ const (
 
 	// OutcomeInsufficientData indicates more execution data is needed before making changes
 	OutcomeInsufficientData ExecutionOutcome = "insufficient_data"
+
+	// OutcomeDeferred indicates the objective was not started because a
+	// work-in-progress limit was reached; it stays pending for a later run
+	OutcomeDeferred ExecutionOutcome = "deferred"
+
+	// OutcomeTimeBoxed indicates the objective ran out of its time box; it is
+	// paused at a checkpoint until the user extends, resumes or abandons it
+	OutcomeTimeBoxed ExecutionOutcome = "time_boxed"
 )
 
 // PerformanceIssue identifies a specific problem with method execution.
@@ -232,6 +243,12 @@ type LearningLoopConfig struct {
 
 	// PreserveMethodHistory controls whether old method versions are kept
 	PreserveMethodHistory bool
+
+	// Clock times execution against objectives' time boxes (default: the system clock)
+	Clock utils.Clock
+
+	// Notifier is told when an objective is paused by its time box (optional)
+	Notifier Notifier
 }
 
 // DefaultLearningLoopConfig provides sensible defaults for learning loop configuration.
@@ -275,16 +292,78 @@ func (ll *LearningLoop) ExecuteObjective(ctx context.Context, objectiveID string
 		ExecutionAttempts: make([]AttemptResult, 0),
 	}
 
+	// Starting a pending objective must respect the work-in-progress limits;
+	// when they are reached the objective waits rather than failing
+	objective, objectiveErr := ll.objectiveManager.GetObjective(ctx, objectiveID)
+	if objectiveErr == nil && objective.Status == ObjectiveStatusPending {
+		if err := ll.objectiveManager.CheckWIPLimit(ctx, objective.GoalID); errors.Is(err, ErrWIPLimitReached) {
+			result.FinalOutcome = OutcomeDeferred
+			return ll.finalizeResult(result, nil)
+		}
+	}
+
+	// Time the whole run, re-planning included, against the objective's time box
+	var box *timeBoxRun
+	var resumeFrom string
+	if objectiveErr == nil {
+		boxes := ll.objectiveManager.TimeBoxes()
+		box = newTimeBoxRun(utils.ClockOrReal(ll.config.Clock), boxes.For(objective), boxes.grace(), objective.ActiveExecutionTime)
+		ctx = withTimeBox(ctx, box)
+		defer ll.recordExecutionTime(ctx, objectiveID, box, result)
+
+		// An objective extended or resumed after its time box ran out
+		// continues from the checkpoint it stopped at
+		if stop := objective.TimeBoxStop(); stop != nil && objective.Status == ObjectiveStatusPending {
+			resumeFrom = stop.ExecutionID
+			context := copyObjectiveContext(objective.Context)
+			delete(context, timeBoxContextKey)
+			if _, err := ll.objectiveManager.UpdateObjective(ctx, objectiveID, ObjectiveUpdates{Context: context}); err != nil {
+				return ll.finalizeResult(result, fmt.Errorf("failed to clear time box stop: %w", err))
+			}
+		}
+	}
+
 	// Main execution loop with retry on method refinement
 	for attempt := 0; attempt < ll.config.MaxRefinementAttempts; attempt++ {
-		// CC: Create execution plan
-		plan, err := ll.contemplativeCursor.CreateExecutionPlan(ctx, objectiveID)
-		if err != nil {
-			return ll.finalizeResult(result, fmt.Errorf("failed to create execution plan: %w", err))
+		// Stop re-planning once the time box has run out
+		if box.exceeded() {
+			return ll.stopForTimeBox(ctx, objective, box, result, nil)
 		}
 
-		// RTC: Execute the plan
-		executionResult, err := ll.realTimeCursor.ExecutePlan(ctx, plan)
+		var plan *ExecutionPlan
+		var executionResult *ExecutionResult
+		var err error
+		if attempt == 0 && resumeFrom != "" {
+			// RTC: Continue the checkpointed execution
+			executionResult, err = ll.realTimeCursor.ResumeExecution(ctx, resumeFrom)
+			if executionResult != nil {
+				plan = executionResult.Plan
+			}
+		} else {
+			// CC: Create execution plan. The first attempt builds on the previous
+			// execution's plan; later attempts follow a refined method.
+			if attempt == 0 {
+				plan, err = ll.contemplativeCursor.PlanExecution(ctx, objectiveID)
+			} else {
+				plan, err = ll.contemplativeCursor.CreateExecutionPlan(ctx, objectiveID)
+			}
+			if err != nil {
+				return ll.finalizeResult(result, fmt.Errorf("failed to create execution plan: %w", err))
+			}
+
+			// RTC: Execute the plan
+			executionResult, err = ll.realTimeCursor.ExecutePlan(ctx, plan)
+		}
+		if errors.Is(err, ErrTimeBoxExceeded) {
+			result.ExecutionAttempts = append(result.ExecutionAttempts, AttemptResult{
+				AttemptNumber:   attempt + 1,
+				PlanID:          plan.ID,
+				MethodID:        plan.MethodID,
+				ExecutionResult: executionResult,
+				CompletedAt:     time.Now(),
+			})
+			return ll.stopForTimeBox(ctx, objective, box, result, executionResult)
+		}
 		if err != nil {
 			return ll.finalizeResult(result, fmt.Errorf("failed to execute plan: %w", err))
 		}
@@ -297,10 +376,10 @@ func (ll *LearningLoop) ExecuteObjective(ctx context.Context, objectiveID string
 			ExecutionResult: executionResult,
 			CompletedAt:     time.Now(),
 		}
-		result.ExecutionAttempts = append(result.ExecutionAttempts, attemptResult)
 
 		// Analyze execution outcome
 		shouldContinue, err := ll.analyzeAndLearnFromExecution(ctx, plan, executionResult, &attemptResult)
+		result.ExecutionAttempts = append(result.ExecutionAttempts, attemptResult)
 		if err != nil {
 			return ll.finalizeResult(result, fmt.Errorf("failed to analyze execution: %w", err))
 		}
@@ -362,6 +441,8 @@ func (ll *LearningLoop) analyzeAndLearnFromExecution(
 
 	if err := ll.methodManager.UpdateMethodMetrics(ctx, plan.MethodID, wasSuccessful, rating); err != nil {
 		fmt.Printf("Warning: failed to update method metrics: %v\n", err)
+	} else {
+		attemptResult.MethodRating = rating
 	}
 
 	// Decide whether to attempt method refinement
@@ -587,6 +668,71 @@ func (ll *LearningLoop) finalizeResult(result *LearningResult, err error) (*Lear
 	return result, err
 }
 
+// stopForTimeBox pauses an objective whose time box ran out, keeping the
+// checkpoint a resumed run continues from, and asks the user whether to
+// extend, resume or abandon it.
+func (ll *LearningLoop) stopForTimeBox(ctx context.Context, objective *Objective, box *timeBoxRun, result *LearningResult, checkpoint *ExecutionResult) (*LearningResult, error) {
+	result.FinalOutcome = OutcomeTimeBoxed
+
+	stop := &TimeBoxStop{
+		Limit:   box.limit,
+		Elapsed: box.elapsed(),
+		At:      box.clock.Now(),
+	}
+	if checkpoint != nil {
+		stop.ExecutionID = checkpoint.ID
+		stop.TaskID = checkpoint.CheckpointTaskID
+	}
+	if _, err := ll.objectiveManager.recordTimeBoxStop(ctx, objective.ID, stop, objective.ApprovalWaitTime+box.approvalWaited()); err != nil {
+		return ll.finalizeResult(result, fmt.Errorf("failed to pause objective at its time box: %w", err))
+	}
+
+	if ll.config.Notifier != nil {
+		title := fmt.Sprintf("Time box exceeded: %s", objective.Title)
+		message := fmt.Sprintf("Paused after %s of active execution (time box %s). Extend, resume or abandon it with: time-box %s extend <duration>|resume|abandon",
+			stop.Elapsed.Round(time.Second), stop.Limit, objective.ID)
+		if err := ll.config.Notifier.Notify(ctx, title, message); err != nil {
+			fmt.Printf("Warning: failed to deliver time box alert: %v\n", err)
+		}
+	}
+	return ll.finalizeResult(result, nil)
+}
+
+// recordExecutionTime adds a run's active execution and approval-wait time
+// to the objective. Runs stopped by their time box were recorded when the
+// objective was paused; runs without a time box or approval waits are not
+// recorded.
+func (ll *LearningLoop) recordExecutionTime(ctx context.Context, objectiveID string, box *timeBoxRun, result *LearningResult) {
+	result.ActiveExecutionTime = box.elapsed()
+	result.ApprovalWaitTime = box.approvalWaited()
+	if result.FinalOutcome == OutcomeTimeBoxed || (box.limit <= 0 && result.ApprovalWaitTime == 0) {
+		return
+	}
+
+	objective, err := ll.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return
+	}
+	approvalWait := objective.ApprovalWaitTime + result.ApprovalWaitTime
+	if _, err := ll.objectiveManager.UpdateObjective(ctx, objectiveID, ObjectiveUpdates{
+		ActiveExecutionTime: &result.ActiveExecutionTime,
+		ApprovalWaitTime:    &approvalWait,
+	}); err != nil {
+		fmt.Printf("Warning: failed to record execution time: %v\n", err)
+	}
+}
+
+// SetWIPLimits sets the work-in-progress limits pending objectives are
+// deferred by.
+func (ll *LearningLoop) SetWIPLimits(limits WIPLimits) {
+	ll.objectiveManager.SetWIPLimits(limits)
+}
+
+// SetTimeBoxes sets the default time boxes objectives execute within.
+func (ll *LearningLoop) SetTimeBoxes(boxes TimeBoxes) {
+	ll.objectiveManager.SetTimeBoxes(boxes)
+}
+
 // GetConfiguration returns the current learning loop configuration.
 func (ll *LearningLoop) GetConfiguration() *LearningLoopConfig {
 	return ll.config
@@ -624,6 +770,13 @@ type LearningResult struct {
 
 	// ErrorMessage contains error details if execution failed
 	ErrorMessage string
+
+	// ActiveExecutionTime is the objective's execution time counted against
+	// its time box, across runs
+	ActiveExecutionTime time.Duration
+
+	// ApprovalWaitTime is how long this run waited for ethical approval
+	ApprovalWaitTime time.Duration
 }
 
 // AttemptResult represents the outcome of a single execution attempt.
@@ -643,6 +796,10 @@ type AttemptResult struct {
 	// ExecutionAnalysis contains the learning agent's analysis (may be nil)
 	ExecutionAnalysis *ExecutionAnalysis
 
+	// MethodRating is the rating (1-10) the attempt gave its method, or 0 if
+	// the method's metrics were not updated
+	MethodRating float64
+
 	// RefinementApplied indicates whether method refinement was applied after this attempt
 	RefinementApplied bool
 
@@ -686,6 +843,59 @@ func (lr *LearningResult) GetMethodsUsed() []string {
 	return methods
 }
 
+// Summary describes the outcome in one line, as "success after 2
+// attempt(s)", with the error when there was one.
+func (lr *LearningResult) Summary() string {
+	message := fmt.Sprintf("%s after %d attempt(s)", lr.FinalOutcome, len(lr.ExecutionAttempts))
+	if lr.ErrorMessage != "" {
+		message += ": " + lr.ErrorMessage
+	}
+	return message
+}
+
+// RecordLearningResult completes an objective with the result of a learning
+// loop run, as failed when the run did not succeed, keeping the completed
+// tasks' outputs. An objective resumed from its time box, which the run
+// leaves pending, is started first. Deferred and time-boxed runs did not
+// finish the objective and cannot be recorded.
+func (om *ObjectiveManager) RecordLearningResult(ctx context.Context, result *LearningResult) (*Objective, error) {
+	if result.FinalOutcome == OutcomeDeferred || result.FinalOutcome == OutcomeTimeBoxed {
+		return nil, errs.Newf(errs.Conflict, "a %s run did not finish objective %s", result.FinalOutcome, result.ObjectiveID).With("outcome", string(result.FinalOutcome))
+	}
+
+	outputs := map[string]interface{}{}
+	if len(result.ExecutionAttempts) > 0 {
+		last := result.ExecutionAttempts[len(result.ExecutionAttempts)-1]
+		for taskID, taskResult := range last.ExecutionResult.TaskResults {
+			if taskResult.Status == TaskStatusCompleted {
+				outputs[taskID] = taskResult.Output
+			}
+		}
+	}
+
+	objective, err := om.GetObjective(ctx, result.ObjectiveID)
+	if err == nil && objective.Status == ObjectiveStatusPending {
+		_, err = om.StartObjective(ctx, result.ObjectiveID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the objective's result: %w", err)
+	}
+	objective, err = om.CompleteObjective(ctx, result.ObjectiveID, ObjectiveResult{
+		Success: result.WasSuccessful,
+		Message: result.Summary(),
+		Data: map[string]interface{}{
+			"outcome":  string(result.FinalOutcome),
+			"attempts": len(result.ExecutionAttempts),
+			"outputs":  outputs,
+		},
+		TokensUsed: result.GetTotalTokensUsed(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the objective's result: %w", err)
+	}
+	return objective, nil
+}
+
 // String returns string representations for enums.
 func (eo ExecutionOutcome) String() string {
 	return string(eo)
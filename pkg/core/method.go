@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
 )
 
@@ -39,16 +40,16 @@ const (
 // ApproachStep represents a single step in a method's approach.
 type ApproachStep struct {
 	// Description explains what this step does
-	Description string `json:"description"`
+	Description string `json:"description" yaml:"description"`
 
 	// Tools lists the tools/capabilities needed for this step
-	Tools []string `json:"tools,omitempty"`
+	Tools []string `json:"tools,omitempty" yaml:"tools,omitempty"`
 
 	// Heuristics contains decision-making guidance for this step
-	Heuristics []string `json:"heuristics,omitempty"`
+	Heuristics []string `json:"heuristics,omitempty" yaml:"heuristics,omitempty"`
 
 	// Conditions specify when this step should be executed
-	Conditions map[string]interface{} `json:"conditions,omitempty"`
+	Conditions map[string]interface{} `json:"conditions,omitempty" yaml:"conditions,omitempty"`
 }
 
 // SuccessMetrics tracks how well a method performs over time.
@@ -64,6 +65,13 @@ type SuccessMetrics struct {
 
 	// AverageRating is the mean user/system rating (1-10) of method effectiveness
 	AverageRating float64 `json:"average_rating"`
+
+	// ComparisonRuns counts the executions that were one side of a method
+	// comparison; they are included in ExecutionCount
+	ComparisonRuns int `json:"comparison_runs,omitempty"`
+
+	// ComparisonWins counts the comparisons this method won
+	ComparisonWins int `json:"comparison_wins,omitempty"`
 }
 
 // SuccessRate calculates the success percentage for this method.
@@ -107,6 +115,10 @@ type Method struct {
 	// CreatedAt is when this method version was originally created
 	CreatedAt time.Time
 
+	// Embedding is the vector embedding of the method's name, description
+	// and steps, stored once a method cache has computed it (nil until then)
+	Embedding []float64
+
 	// store reference for database operations
 	store *storage.Store
 }
@@ -126,7 +138,7 @@ func NewMethodManager(store *storage.Store) *MethodManager {
 // CreateMethod creates a new method and stores it in the system.
 func (mm *MethodManager) CreateMethod(ctx context.Context, name, description string, approach []ApproachStep, domain MethodDomain, userContext map[string]interface{}) (*Method, error) {
 	if name == "" {
-		return nil, fmt.Errorf("method name cannot be empty")
+		return nil, errs.New(errs.Validation, "method name cannot be empty")
 	}
 	if !isValidDomain(domain) {
 		return nil, fmt.Errorf("invalid method domain: %s", domain)
@@ -205,7 +217,7 @@ func (mm *MethodManager) GetMethod(ctx context.Context, methodID string) (*Metho
 	}
 
 	if node.Type != "method" {
-		return nil, fmt.Errorf("node %s is not a method (type: %s)", methodID, node.Type)
+		return nil, errs.Newf(errs.NotFound, "node %s is not a method (type: %s)", methodID, node.Type).With("id", methodID)
 	}
 
 	return mm.nodeToMethod(node)
@@ -219,7 +231,7 @@ func (mm *MethodManager) GetMethodAtTime(ctx context.Context, methodID string, t
 	}
 
 	if node.Type != "method" {
-		return nil, fmt.Errorf("node %s is not a method (type: %s)", methodID, node.Type)
+		return nil, errs.Newf(errs.NotFound, "node %s is not a method (type: %s)", methodID, node.Type).With("id", methodID)
 	}
 
 	return mm.nodeToMethod(node)
@@ -238,7 +250,7 @@ func (mm *MethodManager) UpdateMethod(ctx context.Context, methodID string, upda
 	if updates.Name != nil {
 		name = *updates.Name
 		if name == "" {
-			return nil, fmt.Errorf("method name cannot be empty")
+			return nil, errs.New(errs.Validation, "method name cannot be empty")
 		}
 	}
 
@@ -283,6 +295,15 @@ func (mm *MethodManager) UpdateMethod(ctx context.Context, methodID string, upda
 		userContext = updates.UserContext
 	}
 
+	// An embedding no longer matches a method whose text changed
+	embedding := currentMethod.Embedding
+	if updates.Name != nil || updates.Description != nil || updates.Approach != nil {
+		embedding = nil
+	}
+	if updates.Embedding != nil {
+		embedding = updates.Embedding
+	}
+
 	// Prepare approach data for storage
 	approachData := make([]map[string]interface{}, len(approach))
 	for i, step := range approach {
@@ -305,6 +326,8 @@ func (mm *MethodManager) UpdateMethod(ctx context.Context, methodID string, upda
 		"success_count":   metrics.SuccessCount,
 		"last_used":       lastUsedStr,
 		"average_rating":  metrics.AverageRating,
+		"comparison_runs": metrics.ComparisonRuns,
+		"comparison_wins": metrics.ComparisonWins,
 	}
 
 	// Prepare updated data
@@ -319,6 +342,9 @@ func (mm *MethodManager) UpdateMethod(ctx context.Context, methodID string, upda
 		"user_context": userContext,
 		"created_at":   currentMethod.CreatedAt.Format(time.RFC3339),
 	}
+	if len(embedding) > 0 {
+		data["embedding"] = embedding
+	}
 
 	// Update in storage
 	if err := mm.store.UpdateNode(ctx, methodID, data); err != nil {
@@ -337,6 +363,7 @@ func (mm *MethodManager) UpdateMethod(ctx context.Context, methodID string, upda
 		Metrics:     metrics,
 		UserContext: userContext,
 		CreatedAt:   currentMethod.CreatedAt,
+		Embedding:   embedding,
 		store:       mm.store,
 	}, nil
 }
@@ -352,6 +379,7 @@ type MethodUpdates struct {
 	Status      *MethodStatus
 	Metrics     *SuccessMetrics
 	UserContext map[string]interface{}
+	Embedding   []float64
 }
 
 // ListMethods returns all methods with optional filtering.
@@ -368,7 +396,7 @@ func (mm *MethodManager) ListMethods(ctx context.Context, filter MethodFilter) (
 		query = query.WithData("status", string(*filter.Status))
 	}
 
-	nodes, err := query.All()
+	nodes, err := query.AllContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query methods: %w", err)
 	}
@@ -404,18 +432,21 @@ func (mm *MethodManager) CreateMethodEvolution(ctx context.Context, oldMethodID
 	node := storage.NewNode("method", mm.methodToNodeData(newMethod))
 	newMethod.ID = node.ID
 
-	if err := mm.store.AddNode(ctx, node); err != nil {
-		return fmt.Errorf("failed to store evolved method: %w", err)
-	}
-
 	// Create evolution edge: new method "evolved_from" old method
 	edge := storage.NewEdge(newMethod.ID, oldMethodID, "evolved_from", map[string]interface{}{
 		"reason":     evolutionReason,
 		"created_at": time.Now().Format(time.RFC3339),
 	})
 
-	if err := mm.store.AddEdge(ctx, edge); err != nil {
-		return fmt.Errorf("failed to create method evolution relationship: %w", err)
+	// An evolved method is never stored without its lineage
+	err := mm.store.Batch(ctx, func(tx *storage.Tx) error {
+		if err := tx.AddNode(node); err != nil {
+			return err
+		}
+		return tx.AddEdge(edge)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store evolved method: %w", err)
 	}
 
 	// Mark old method as superseded if it's still active
@@ -437,7 +468,7 @@ func (mm *MethodManager) CreateMethodEvolution(ctx context.Context, oldMethodID
 // GetMethodEvolution returns the evolution chain for a method.
 func (mm *MethodManager) GetMethodEvolution(ctx context.Context, methodID string) (*MethodEvolutionChain, error) {
 	// Find predecessors (methods this evolved from)
-	predecessorEdges, err := mm.store.Edges().OfType("evolved_from").FromNode(methodID).All()
+	predecessorEdges, err := mm.store.Edges().OfType("evolved_from").FromNode(methodID).AllContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query method predecessors: %w", err)
 	}
@@ -452,7 +483,7 @@ func (mm *MethodManager) GetMethodEvolution(ctx context.Context, methodID string
 	}
 
 	// Find successors (methods that evolved from this)
-	successorEdges, err := mm.store.Edges().OfType("evolved_from").ToNode(methodID).All()
+	successorEdges, err := mm.store.Edges().OfType("evolved_from").ToNode(methodID).AllContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query method successors: %w", err)
 	}
@@ -488,6 +519,19 @@ type MethodEvolutionChain struct {
 
 // UpdateMethodMetrics updates the success metrics for a method based on execution results.
 func (mm *MethodManager) UpdateMethodMetrics(ctx context.Context, methodID string, wasSuccessful bool, rating float64) error {
+	return mm.recordRun(ctx, methodID, wasSuccessful, rating, nil)
+}
+
+// RecordComparisonRun updates a method's metrics with its side of a method
+// comparison. The run counts like any other execution and is also tallied
+// under ComparisonRuns, with ComparisonWins when the method won.
+func (mm *MethodManager) RecordComparisonRun(ctx context.Context, methodID string, wasSuccessful bool, rating float64, won bool) error {
+	return mm.recordRun(ctx, methodID, wasSuccessful, rating, &won)
+}
+
+// recordRun adds one execution to a method's metrics. won is nil unless the
+// execution was part of a comparison.
+func (mm *MethodManager) recordRun(ctx context.Context, methodID string, wasSuccessful bool, rating float64, won *bool) error {
 	method, err := mm.GetMethod(ctx, methodID)
 	if err != nil {
 		return fmt.Errorf("failed to get method for metrics update: %w", err)
@@ -500,6 +544,12 @@ func (mm *MethodManager) UpdateMethodMetrics(ctx context.Context, methodID strin
 		newMetrics.SuccessCount++
 	}
 	newMetrics.LastUsed = time.Now()
+	if won != nil {
+		newMetrics.ComparisonRuns++
+		if *won {
+			newMetrics.ComparisonWins++
+		}
+	}
 
 	// Update average rating using incremental formula
 	if rating >= 1.0 && rating <= 10.0 {
@@ -563,25 +613,30 @@ func (mm *MethodManager) nodeToMethod(node *storage.Node) (*Method, error) {
 		return nil, fmt.Errorf("invalid created_at format in method node %s: %w", node.ID, err)
 	}
 
-	// Parse approach data
+	// Parse approach data (a JSON array once loaded from disk, typed maps while in memory)
+	var approachData []interface{}
+	switch data := node.Data["approach"].(type) {
+	case []interface{}:
+		approachData = data
+	case []map[string]interface{}:
+		for _, stepMap := range data {
+			approachData = append(approachData, stepMap)
+		}
+	}
+
 	var approach []ApproachStep
-	if approachData, ok := node.Data["approach"].([]interface{}); ok {
-		for _, stepData := range approachData {
-			if stepMap, ok := stepData.(map[string]interface{}); ok {
-				step := ApproachStep{
-					Description: stepMap["description"].(string),
-				}
-				if tools, ok := stepMap["tools"].([]interface{}); ok {
-					step.Tools = interfaceSliceToStringSlice(tools)
-				}
-				if heuristics, ok := stepMap["heuristics"].([]interface{}); ok {
-					step.Heuristics = interfaceSliceToStringSlice(heuristics)
-				}
-				if conditions, ok := stepMap["conditions"].(map[string]interface{}); ok {
-					step.Conditions = conditions
-				}
-				approach = append(approach, step)
+	for _, stepData := range approachData {
+		if stepMap, ok := stepData.(map[string]interface{}); ok {
+			description, _ := stepMap["description"].(string)
+			step := ApproachStep{
+				Description: description,
+				Tools:       toStringSlice(stepMap["tools"]),
+				Heuristics:  toStringSlice(stepMap["heuristics"]),
+			}
+			if conditions, ok := stepMap["conditions"].(map[string]interface{}); ok {
+				step.Conditions = conditions
 			}
+			approach = append(approach, step)
 		}
 	}
 
@@ -611,12 +666,28 @@ func (mm *MethodManager) nodeToMethod(node *storage.Node) (*Method, error) {
 		if avgRating, ok := metricsData["average_rating"].(float64); ok {
 			metrics.AverageRating = avgRating
 		}
+		metrics.ComparisonRuns = int(getFloat64(metricsData, "comparison_runs"))
+		metrics.ComparisonWins = int(getFloat64(metricsData, "comparison_wins"))
 		if lastUsedStr, ok := metricsData["last_used"].(string); ok {
 			lastUsed, _ := time.Parse(time.RFC3339, lastUsedStr)
 			metrics.LastUsed = lastUsed
 		}
 	}
 
+	// Parse the stored embedding (a JSON array once loaded from disk)
+	var embedding []float64
+	switch data := node.Data["embedding"].(type) {
+	case []float64:
+		embedding = data
+	case []interface{}:
+		embedding = make([]float64, 0, len(data))
+		for _, value := range data {
+			if number, ok := value.(float64); ok {
+				embedding = append(embedding, number)
+			}
+		}
+	}
+
 	return &Method{
 		ID:          node.ID,
 		Name:        name,
@@ -628,6 +699,7 @@ func (mm *MethodManager) nodeToMethod(node *storage.Node) (*Method, error) {
 		Metrics:     metrics,
 		UserContext: userContext,
 		CreatedAt:   createdAt,
+		Embedding:   embedding,
 		store:       mm.store,
 	}, nil
 }
@@ -656,9 +728,11 @@ func (mm *MethodManager) methodToNodeData(method *Method) map[string]interface{}
 		"success_count":   method.Metrics.SuccessCount,
 		"last_used":       lastUsedStr,
 		"average_rating":  method.Metrics.AverageRating,
+		"comparison_runs": method.Metrics.ComparisonRuns,
+		"comparison_wins": method.Metrics.ComparisonWins,
 	}
 
-	return map[string]interface{}{
+	data := map[string]interface{}{
 		"name":         method.Name,
 		"description":  method.Description,
 		"approach":     approachData,
@@ -669,6 +743,10 @@ func (mm *MethodManager) methodToNodeData(method *Method) map[string]interface{}
 		"user_context": method.UserContext,
 		"created_at":   method.CreatedAt.Format(time.RFC3339),
 	}
+	if len(method.Embedding) > 0 {
+		data["embedding"] = method.Embedding
+	}
+	return data
 }
 
 // Helper function to convert []interface{} to []string
@@ -682,6 +760,19 @@ func interfaceSliceToStringSlice(slice []interface{}) []string {
 	return strings
 }
 
+// toStringSlice converts a stored string list, whether still a []string or
+// decoded from JSON as []interface{}.
+func toStringSlice(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		return interfaceSliceToStringSlice(v)
+	default:
+		return nil
+	}
+}
+
 // isValidDomain checks if a method domain is valid.
 func isValidDomain(domain MethodDomain) bool {
 	switch domain {
@@ -9,16 +9,19 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
 	"github.com/Solifugus/ai-work-studio/pkg/mcp"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
 )
 
 // MethodCache provides efficient caching and retrieval of proven methods.
 // It supports semantic similarity matching, ranking by multiple factors,
-// and session-based performance optimization.
+// and session-based performance optimization. Without an embedding provider,
+// or when embedding fails, methods are matched by the words they share with
+// the objective.
 type MethodCache struct {
 	store           *storage.Store
-	llmService      *mcp.LLMService
+	embeddings      EmbeddingProvider
 	config          CacheConfig
 	sessionCache    map[string]*CacheEntry
 	embeddingCache  map[string][]float64
@@ -38,8 +41,13 @@ type CacheConfig struct {
 	CacheExpiry time.Duration
 
 	// SimilarityThreshold is the minimum similarity score (0-1) for matches
+	// by shared words
 	SimilarityThreshold float64
 
+	// EmbeddingSimilarityThreshold is the minimum cosine similarity (0-1)
+	// for matches by embedding
+	EmbeddingSimilarityThreshold float64
+
 	// MaxResults is the maximum number of results to return
 	MaxResults int
 
@@ -60,6 +68,7 @@ func DefaultCacheConfig() CacheConfig {
 		MaxCacheSize:        500,  // Keep up to 500 methods in session cache
 		CacheExpiry:         30 * time.Minute,
 		SimilarityThreshold: 0.7,  // Require 70% similarity for matches
+		EmbeddingSimilarityThreshold: 0.75, // Embeddings of unrelated text still score around 0.5
 		MaxResults:          10,   // Return top 10 matches by default
 		RecencyWeight:       0.2,  // 20% weight for how recent the method is
 		SuccessWeight:       0.4,  // 40% weight for success rate
@@ -84,6 +93,7 @@ type MatchResult struct {
 	RecencyScore     float64 // 0-1, how recent the method is
 	CompositeScore   float64 // 0-1, weighted combination of all scores
 	MatchReason      string  // Human-readable explanation of why this matched
+	Semantic         bool    // Whether the similarity is of embeddings rather than shared words
 }
 
 // CacheQuery provides a fluent interface for building method cache queries.
@@ -98,19 +108,38 @@ type CacheQuery struct {
 	excludeIDs  []string
 }
 
-// SimilarityMatcher defines the interface for calculating similarity between methods and objectives.
-type SimilarityMatcher interface {
-	CalculateSimilarity(ctx context.Context, methodDescription string, objective string) (float64, error)
+// EmbeddingProvider computes vector embeddings of text, so that methods and
+// objectives worded differently can still be matched by meaning.
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, text string) ([]float64, error)
 }
 
-// EmbeddingSimilarityMatcher uses vector embeddings to calculate semantic similarity.
-type EmbeddingSimilarityMatcher struct {
-	llmService *mcp.LLMService
-	cache      *MethodCache
+// ServiceEmbeddings is an EmbeddingProvider that uses the embed operation of
+// an LLM service, such as *mcp.LLMService.
+type ServiceEmbeddings struct {
+	Service llm.LLMServiceInterface
 }
 
-// NewMethodCache creates a new method cache instance.
-func NewMethodCache(store *storage.Store, llmService *mcp.LLMService, config ...CacheConfig) *MethodCache {
+// Embed returns the service's embedding of text.
+func (se ServiceEmbeddings) Embed(ctx context.Context, text string) ([]float64, error) {
+	result := se.Service.Execute(ctx, mcp.ServiceParams{
+		"operation": "embed",
+		"text":      text,
+	})
+	if result.Error != nil {
+		return nil, fmt.Errorf("embedding generation failed: %w", result.Error)
+	}
+
+	embeddingResp, ok := result.Data.(*mcp.EmbeddingResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected embedding response type")
+	}
+	return embeddingResp.Embedding, nil
+}
+
+// NewMethodCache creates a new method cache instance. Queries match methods
+// by embedding when embeddings is not nil, and by shared words otherwise.
+func NewMethodCache(store *storage.Store, embeddings EmbeddingProvider, config ...CacheConfig) *MethodCache {
 	cfg := DefaultCacheConfig()
 	if len(config) > 0 {
 		cfg = config[0]
@@ -118,7 +147,7 @@ func NewMethodCache(store *storage.Store, llmService *mcp.LLMService, config ...
 
 	return &MethodCache{
 		store:          store,
-		llmService:     llmService,
+		embeddings:     embeddings,
 		config:         cfg,
 		sessionCache:   make(map[string]*CacheEntry),
 		embeddingCache: make(map[string][]float64),
@@ -193,14 +222,16 @@ func (cq *CacheQuery) Execute(ctx context.Context) ([]*MatchResult, error) {
 		return nil, fmt.Errorf("failed to calculate similarity scores: %w", err)
 	}
 
-	// Apply similarity threshold
-	threshold := cq.cache.config.SimilarityThreshold
-	if cq.similarity != nil {
-		threshold = *cq.similarity
-	}
-
+	// Apply the similarity threshold of each result's kind of match
 	var filteredResults []*MatchResult
 	for _, result := range results {
+		threshold := cq.cache.config.SimilarityThreshold
+		if result.Semantic {
+			threshold = cq.cache.config.EmbeddingSimilarityThreshold
+		}
+		if cq.similarity != nil {
+			threshold = *cq.similarity
+		}
 		if result.SimilarityScore >= threshold {
 			filteredResults = append(filteredResults, result)
 		}
@@ -316,11 +347,17 @@ func (cq *CacheQuery) rankWithoutSimilarity(candidates []*Method) []*MatchResult
 	return results
 }
 
-// calculateSimilarityScores computes similarity and composite scores for candidates.
+// calculateSimilarityScores computes similarity and composite scores for
+// candidates: the cosine similarity of their embeddings to the objective's,
+// or, for methods without an embedding, the share of the objective's words
+// they contain.
 func (cq *CacheQuery) calculateSimilarityScores(ctx context.Context, candidates []*Method) ([]*MatchResult, error) {
-	matcher := &EmbeddingSimilarityMatcher{
-		llmService: cq.cache.llmService,
-		cache:      cq.cache,
+	var objectiveEmbedding []float64
+	if cq.cache.embeddings != nil {
+		embedding, err := cq.cache.embeddings.Embed(ctx, cq.objective)
+		if err == nil {
+			objectiveEmbedding = embedding
+		}
 	}
 
 	var results []*MatchResult
@@ -328,11 +365,17 @@ func (cq *CacheQuery) calculateSimilarityScores(ctx context.Context, candidates
 
 	for _, method := range candidates {
 		// Calculate similarity to objective
-		similarity, err := matcher.CalculateSimilarity(ctx, method.Description, cq.objective)
-		if err != nil {
-			// Log error but continue with other methods
-			fmt.Printf("Warning: failed to calculate similarity for method %s: %v\n", method.ID, err)
-			continue
+		var similarity float64
+		semantic := false
+		if len(objectiveEmbedding) > 0 {
+			embedding := cq.cache.getMethodEmbedding(ctx, method)
+			if len(embedding) == len(objectiveEmbedding) {
+				similarity = cosineSimilarity(objectiveEmbedding, embedding)
+				semantic = true
+			}
+		}
+		if !semantic {
+			similarity = keywordSimilarity(cq.objective, methodEmbeddingText(method))
 		}
 
 		// Calculate success score
@@ -357,6 +400,7 @@ func (cq *CacheQuery) calculateSimilarityScores(ctx context.Context, candidates
 			RecencyScore:   recencyScore,
 			CompositeScore: compositeScore,
 			MatchReason:    matchReason,
+			Semantic:       semantic,
 		}
 
 		results = append(results, result)
@@ -408,11 +452,9 @@ func (mc *MethodCache) CacheProvenMethod(ctx context.Context, method *Method) er
 		return nil // Only cache active methods
 	}
 
-	// Get or compute embedding for the method
-	embedding, err := mc.getMethodEmbedding(ctx, method)
-	if err != nil {
-		return fmt.Errorf("failed to get method embedding: %w", err)
-	}
+	// Get or compute embedding for the method; without one the method is
+	// matched by its words
+	embedding := mc.getMethodEmbedding(ctx, method)
 
 	// Add to session cache
 	mc.cacheMutex.Lock()
@@ -536,83 +578,73 @@ func (mc *MethodCache) evictLeastUsed() {
 	}
 }
 
-// getMethodEmbedding gets or computes the embedding for a method description.
-func (mc *MethodCache) getMethodEmbedding(ctx context.Context, method *Method) ([]float64, error) {
+// getMethodEmbedding returns the method's embedding: from the session's
+// embedding cache, from the method's stored embedding or, failing both,
+// computed and stored with the method so later sessions can reuse it. It
+// returns nil without an embedding provider or when embedding fails.
+func (mc *MethodCache) getMethodEmbedding(ctx context.Context, method *Method) []float64 {
+	if mc.embeddings == nil {
+		return nil
+	}
+
 	// Check embedding cache first
 	mc.embeddingMutex.RLock()
 	if embedding, exists := mc.embeddingCache[method.ID]; exists {
 		mc.embeddingMutex.RUnlock()
-		return embedding, nil
+		return embedding
 	}
 	mc.embeddingMutex.RUnlock()
 
-	// Compute embedding
-	text := method.Name + " " + method.Description
-
-	// Use LLM service to get embedding
-	params := mcp.ServiceParams{
-		"operation": "embed",
-		"text":      text,
-	}
-
-	result := mc.llmService.Execute(ctx, params)
-	if result.Error != nil {
-		return nil, fmt.Errorf("embedding generation failed: %w", result.Error)
-	}
+	embedding := method.Embedding
+	if len(embedding) == 0 {
+		computed, err := mc.embeddings.Embed(ctx, methodEmbeddingText(method))
+		if err != nil || len(computed) == 0 {
+			return nil
+		}
+		embedding = computed
 
-	// Extract embedding from result
-	embeddingResp, ok := result.Data.(*mcp.EmbeddingResponse)
-	if !ok {
-		return nil, fmt.Errorf("unexpected embedding response type")
+		// Keep the vector with the method; the cache works without it
+		if _, err := NewMethodManager(mc.store).UpdateMethod(ctx, method.ID, MethodUpdates{Embedding: embedding}); err == nil {
+			method.Embedding = embedding
+		}
 	}
 
-	embedding := embeddingResp.Embedding
-
 	// Cache the embedding
 	mc.embeddingMutex.Lock()
 	mc.embeddingCache[method.ID] = embedding
 	mc.embeddingMutex.Unlock()
 
-	return embedding, nil
+	return embedding
 }
 
-// CalculateSimilarity computes semantic similarity between method description and objective.
-func (esm *EmbeddingSimilarityMatcher) CalculateSimilarity(ctx context.Context, methodDescription string, objective string) (float64, error) {
-	// Get embedding for objective
-	params := mcp.ServiceParams{
-		"operation": "embed",
-		"text":      objective,
-	}
-
-	result := esm.llmService.Execute(ctx, params)
-	if result.Error != nil {
-		return 0.0, fmt.Errorf("failed to get objective embedding: %w", result.Error)
-	}
-
-	embeddingResp, ok := result.Data.(*mcp.EmbeddingResponse)
-	if !ok {
-		return 0.0, fmt.Errorf("unexpected embedding response type")
+// methodEmbeddingText is the text a method is matched by: its name,
+// description and the descriptions of its steps.
+func methodEmbeddingText(method *Method) string {
+	parts := []string{method.Name, method.Description}
+	for _, step := range method.Approach {
+		if step.Description != "" {
+			parts = append(parts, step.Description)
+		}
 	}
+	return strings.Join(parts, "\n")
+}
 
-	objectiveEmbedding := embeddingResp.Embedding
-
-	// Get embedding for method description
-	params["text"] = methodDescription
-	result = esm.llmService.Execute(ctx, params)
-	if result.Error != nil {
-		return 0.0, fmt.Errorf("failed to get method embedding: %w", result.Error)
+// keywordSimilarity is the share of the objective's significant words
+// (see statementWords) that appear in text, ignoring case.
+func keywordSimilarity(objective, text string) float64 {
+	objectiveWords := statementWords(objective)
+	if len(objectiveWords) == 0 {
+		return 0
 	}
 
-	embeddingResp, ok = result.Data.(*mcp.EmbeddingResponse)
-	if !ok {
-		return 0.0, fmt.Errorf("unexpected embedding response type")
+	textWords := statementWords(text)
+	shared := 0
+	for word := range objectiveWords {
+		if textWords[word] {
+			shared++
+		}
 	}
-
-	methodEmbedding := embeddingResp.Embedding
-
-	// Calculate cosine similarity
-	similarity := cosineSimilarity(objectiveEmbedding, methodEmbedding)
-	return similarity, nil
+	return float64(shared) / float64(len(objectiveWords))
 }
 
 // cosineSimilarity calculates cosine similarity between two vectors.
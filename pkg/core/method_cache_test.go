@@ -2,8 +2,9 @@ package core
 
 import (
 	"context"
-	"log"
+	"errors"
 	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,85 +12,51 @@ import (
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
 )
 
-// MockLLMProvider provides predictable embeddings for testing.
-type MockLLMProvider struct {
-	embeddings map[string][]float64
+// conceptEmbeddings embeds text as counts of the concepts its words name,
+// so that differently worded texts about the same thing embed alike. Words
+// naming no concept are ignored.
+type conceptEmbeddings struct {
+	calls int
+	err   error
 }
 
-func (m *MockLLMProvider) Name() string {
-	return "Mock Provider"
+// embeddingConcepts maps words to the dimension of the concept they name.
+var embeddingConcepts = map[string]int{
+	"file": 0, "files": 0,
+	"process": 1, "processing": 1, "processor": 1,
+	"data": 2,
+	"analyze": 3, "analysis": 3, "analyzer": 3,
+	"api": 4, "integration": 4, "connector": 4,
+	"database": 5, "db": 5,
+	"optimize": 6, "performance": 6, "tuning": 6,
+	"queries": 7, "query": 7,
 }
 
-func (m *MockLLMProvider) Complete(ctx context.Context, request mcp.CompletionRequest) (*mcp.CompletionResponse, error) {
-	return &mcp.CompletionResponse{
-		Text:       "Mock completion",
-		TokensUsed: 10,
-		Model:      request.Model,
-		Provider:   "mock",
-		Cost:       0.0,
-	}, nil
-}
-
-func (m *MockLLMProvider) Embed(ctx context.Context, request mcp.EmbeddingRequest) (*mcp.EmbeddingResponse, error) {
-	embedding, exists := m.embeddings[request.Text]
-	if !exists {
-		// Generate a simple hash-based embedding for unknown text
-		hash := simpleHash(request.Text)
-		embedding = make([]float64, 384) // Standard embedding size
-		for i := range embedding {
-			embedding[i] = math.Sin(float64(hash+i)) * 0.1
-		}
-		m.embeddings[request.Text] = embedding
+func (ce *conceptEmbeddings) Embed(ctx context.Context, text string) ([]float64, error) {
+	ce.calls++
+	if ce.err != nil {
+		return nil, ce.err
 	}
 
-	return &mcp.EmbeddingResponse{
-		Embedding:  embedding,
-		TokensUsed: len(request.Text) / 4,
-		Model:      request.Model,
-		Provider:   "mock",
-		Cost:       0.0,
-	}, nil
-}
-
-func (m *MockLLMProvider) CalculateCost(tokens int, operation string) float64 {
-	return 0.0 // Mock is free
-}
-
-// simpleHash generates a simple hash for testing purposes.
-func simpleHash(s string) int {
-	hash := 0
-	for _, char := range s {
-		hash = 31*hash + int(char)
+	embedding := make([]float64, 8)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if dimension, ok := embeddingConcepts[word]; ok {
+			embedding[dimension]++
+		}
 	}
-	return hash
+	return embedding, nil
 }
 
-// setupTestMethodCache creates a method cache with mocked LLM service.
+// setupTestMethodCache creates a method cache with concept embeddings.
 func setupTestMethodCache(t *testing.T, config ...CacheConfig) (*MethodCache, *storage.Store, *MethodManager) {
 	store := setupTestStore(t)
 
-	// Create LLM service with mock provider
-	llmService := mcp.NewLLMService(log.New(&testLogWriter{}, "test: ", log.LstdFlags))
-
-	// Create mock provider with predictable embeddings
-	mockProvider := &MockLLMProvider{
-		embeddings: map[string][]float64{
-			"file processing": createTestEmbedding(0.1, 0.8, 0.3),
-			"data analysis":   createTestEmbedding(0.9, 0.2, 0.7),
-			"api integration": createTestEmbedding(0.5, 0.9, 0.1),
-			"process files":   createTestEmbedding(0.2, 0.7, 0.4), // Similar to "file processing"
-			"analyze data":    createTestEmbedding(0.8, 0.3, 0.6), // Similar to "data analysis"
-		},
-	}
-
-	llmService.SetProvider("mock", mockProvider)
-
 	// Create cache with custom config if provided
 	var cache *MethodCache
 	if len(config) > 0 {
-		cache = NewMethodCache(store, llmService, config[0])
+		cache = NewMethodCache(store, &conceptEmbeddings{}, config[0])
 	} else {
-		cache = NewMethodCache(store, llmService)
+		cache = NewMethodCache(store, &conceptEmbeddings{})
 	}
 
 	mm := NewMethodManager(store)
@@ -97,40 +64,6 @@ func setupTestMethodCache(t *testing.T, config ...CacheConfig) (*MethodCache, *s
 	return cache, store, mm
 }
 
-// createTestEmbedding generates a normalized test embedding vector.
-func createTestEmbedding(x, y, z float64) []float64 {
-	embedding := make([]float64, 384)
-	for i := range embedding {
-		switch i % 3 {
-		case 0:
-			embedding[i] = x
-		case 1:
-			embedding[i] = y
-		case 2:
-			embedding[i] = z
-		}
-	}
-
-	// Normalize the vector
-	norm := 0.0
-	for _, val := range embedding {
-		norm += val * val
-	}
-	norm = math.Sqrt(norm)
-
-	for i := range embedding {
-		embedding[i] /= norm
-	}
-
-	return embedding
-}
-
-type testLogWriter struct{}
-
-func (w *testLogWriter) Write(p []byte) (n int, err error) {
-	return len(p), nil // Discard log output during tests
-}
-
 // createTestMethodWithMetrics creates a method with specified success metrics.
 func createTestMethodWithMetrics(t *testing.T, mm *MethodManager, name, description string, domain MethodDomain, successRate float64, lastUsed time.Time) *Method {
 	ctx := context.Background()
@@ -305,6 +238,140 @@ func TestMethodCache_QueryBySimilarity(t *testing.T) {
 	}
 }
 
+func TestMethodCache_SemanticQuery(t *testing.T) {
+	cache, _, mm := setupTestMethodCache(t)
+	ctx := context.Background()
+
+	tuning := createTestMethodWithMetrics(t, mm, "DB Tuner", "database performance tuning", MethodDomainGeneral, 85.0, time.Now())
+	connector := createTestMethodWithMetrics(t, mm, "API Connector", "api integration", MethodDomainGeneral, 90.0, time.Now())
+	cache.CacheProvenMethod(ctx, tuning)
+	cache.CacheProvenMethod(ctx, connector)
+
+	// The objective shares no significant word with the method, but its meaning
+	results, err := cache.Query().WithObjective("optimize DB queries").Execute(ctx)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Method.ID != tuning.ID || !results[0].Semantic {
+		t.Fatalf("Expected only the tuning method, matched by embedding, got %d results", len(results))
+	}
+	if results[0].SimilarityScore < cache.config.EmbeddingSimilarityThreshold {
+		t.Errorf("Expected a similarity of at least %.2f, got %.2f", cache.config.EmbeddingSimilarityThreshold, results[0].SimilarityScore)
+	}
+
+	// Word matching alone misses it
+	results, err = NewMethodCache(cache.store, nil).Query().WithObjective("optimize DB queries").Execute(ctx)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no match by shared words, got %d", len(results))
+	}
+}
+
+func TestMethodCache_StoresEmbeddings(t *testing.T) {
+	cache, store, mm := setupTestMethodCache(t)
+	ctx := context.Background()
+
+	method := createTestMethodWithMetrics(t, mm, "File Processor", "file processing", MethodDomainGeneral, 85.0, time.Now())
+	if err := cache.CacheProvenMethod(ctx, method); err != nil {
+		t.Fatalf("Failed to cache method: %v", err)
+	}
+
+	stored, err := mm.GetMethod(ctx, method.ID)
+	if err != nil {
+		t.Fatalf("Failed to get method: %v", err)
+	}
+	if len(stored.Embedding) != 8 || stored.Embedding[0] != 2 || stored.Embedding[1] != 2 {
+		t.Fatalf("Expected the method's embedding stored with it, got %v", stored.Embedding)
+	}
+
+	// A later session reuses the stored embedding, embedding only the objective
+	embeddings := &conceptEmbeddings{}
+	results, err := NewMethodCache(store, embeddings).Query().WithObjective("process files").Execute(ctx)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(results) != 1 || !results[0].Semantic {
+		t.Fatalf("Expected the method matched by embedding, got %d results", len(results))
+	}
+	if embeddings.calls != 1 {
+		t.Errorf("Expected only the objective to be embedded, got %d calls", embeddings.calls)
+	}
+
+	// Metrics leave the embedding alone, a new description drops it
+	if err := mm.UpdateMethodMetrics(ctx, method.ID, true, 8.0); err != nil {
+		t.Fatalf("Failed to update metrics: %v", err)
+	}
+	if stored, _ = mm.GetMethod(ctx, method.ID); len(stored.Embedding) == 0 {
+		t.Error("Expected the embedding to survive a metrics update")
+	}
+	description := "Rename files in bulk"
+	if _, err := mm.UpdateMethod(ctx, method.ID, MethodUpdates{Description: &description}); err != nil {
+		t.Fatalf("Failed to update description: %v", err)
+	}
+	if stored, _ = mm.GetMethod(ctx, method.ID); stored.Embedding != nil {
+		t.Errorf("Expected a new description to drop the embedding, got %v", stored.Embedding)
+	}
+}
+
+func TestMethodCache_FallsBackToWordMatching(t *testing.T) {
+	store := setupTestStore(t)
+	mm := NewMethodManager(store)
+	ctx := context.Background()
+
+	method := createTestMethodWithMetrics(t, mm, "File Processor", "file processing", MethodDomainGeneral, 85.0, time.Now())
+	createTestMethodWithMetrics(t, mm, "API Connector", "api integration", MethodDomainGeneral, 90.0, time.Now())
+
+	for name, embeddings := range map[string]EmbeddingProvider{
+		"no provider":      nil,
+		"failing provider": &conceptEmbeddings{err: errors.New("no suitable provider available")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cache := NewMethodCache(store, embeddings)
+			if err := cache.CacheProvenMethod(ctx, method); err != nil {
+				t.Fatalf("Expected caching to work without embeddings: %v", err)
+			}
+
+			results, err := cache.Query().WithObjective("file processing").Execute(ctx)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(results) != 1 || results[0].Method.ID != method.ID || results[0].Semantic || results[0].SimilarityScore != 1.0 {
+				t.Fatalf("Expected the method matched by its words, got %d results", len(results))
+			}
+		})
+	}
+}
+
+// embeddingService answers embed operations with a fixed embedding.
+type embeddingService struct {
+	texts []string
+}
+
+func (s *embeddingService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	if params["operation"] != "embed" {
+		return mcp.ErrorResult(errors.New("unsupported operation"))
+	}
+	s.texts = append(s.texts, params["text"].(string))
+	return mcp.SuccessResult(&mcp.EmbeddingResponse{Embedding: []float64{0.6, 0.8}})
+}
+
+func TestServiceEmbeddings(t *testing.T) {
+	service := &embeddingService{}
+	embedding, err := ServiceEmbeddings{Service: service}.Embed(context.Background(), "process files")
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(embedding) != 2 || embedding[1] != 0.8 || len(service.texts) != 1 || service.texts[0] != "process files" {
+		t.Errorf("Expected the service's embedding of the text, got %v for %v", embedding, service.texts)
+	}
+
+	if _, err := (ServiceEmbeddings{Service: &failingLLMService{err: errors.New("offline")}}).Embed(context.Background(), "text"); err == nil {
+		t.Error("Expected the service's failure to be returned")
+	}
+}
+
 func TestMethodCache_QueryWithRanking(t *testing.T) {
 	cache, _, mm := setupTestMethodCache(t)
 	ctx := context.Background()
@@ -0,0 +1,495 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// comparisonAlternativeEdgeType links the losing execution result of a method
+// comparison to the winning one.
+const comparisonAlternativeEdgeType = "comparison_alternative"
+
+var (
+	// ErrComparisonNotInitiated is returned when a comparison was not started
+	// by the user. Comparisons run an objective twice, so they are never automatic.
+	ErrComparisonNotInitiated = errs.New(errs.PolicyBlocked, "method comparisons must be initiated by the user")
+
+	// ErrComparisonSideEffects is returned when a plan would change things
+	// outside the agent, which running twice could do twice.
+	ErrComparisonSideEffects = errs.New(errs.PolicyBlocked, "comparison blocked by side-effecting tasks")
+
+	// ErrComparisonOverBudget is returned when the estimated cost of both
+	// branches exceeds the budget cap or cannot be afforded.
+	ErrComparisonOverBudget = errs.New(errs.BudgetExceeded, "comparison exceeds the budget")
+)
+
+// sideEffectTaskTypes are task types that write files or run commands.
+var sideEffectTaskTypes = map[string]bool{
+	"write_file":      true,
+	"file_write":      true,
+	"edit_file":       true,
+	"delete_file":     true,
+	"command":         true,
+	"run_command":     true,
+	"execute_command": true,
+	"shell":           true,
+}
+
+// TaskHasSideEffects reports whether a task writes files or runs commands,
+// either by its type or by declaring a "side_effects" parameter.
+func TaskHasSideEffects(task *ExecutionTask) bool {
+	if sideEffectTaskTypes[strings.ToLower(task.Type)] {
+		return true
+	}
+	declared, _ := task.Context.Parameters["side_effects"].(bool)
+	return declared
+}
+
+// ComparisonBranch identifies one side of a method comparison. The RTC puts
+// it in the context of every task it executes for the branch, so executors
+// can work in the branch's workspace and tag their spending with BudgetTags.
+type ComparisonBranch struct {
+	// ComparisonID identifies the comparison both branches belong to
+	ComparisonID string
+
+	// Label names the branch, "A" or "B"
+	Label string
+
+	// Workspace is the directory the branch's files belong in
+	Workspace string
+
+	// Context is the branch's own copy of the objective context
+	Context map[string]interface{}
+}
+
+// BudgetTags returns the tags that attribute spending to the branch.
+func (b *ComparisonBranch) BudgetTags() map[string]string {
+	return map[string]string{"comparison": b.ComparisonID, "branch": b.Label}
+}
+
+// toData converts the branch to storage data. The context copy is not stored.
+func (b *ComparisonBranch) toData() map[string]interface{} {
+	return map[string]interface{}{
+		"id":        b.ComparisonID,
+		"branch":    b.Label,
+		"workspace": b.Workspace,
+	}
+}
+
+// comparisonBranchFromData converts stored data back to a branch.
+func comparisonBranchFromData(data map[string]interface{}) *ComparisonBranch {
+	return &ComparisonBranch{
+		ComparisonID: getString(data, "id"),
+		Label:        getString(data, "branch"),
+		Workspace:    getString(data, "workspace"),
+	}
+}
+
+type comparisonBranchKey struct{}
+
+// WithComparisonBranch returns a context carrying the comparison branch.
+func WithComparisonBranch(ctx context.Context, branch *ComparisonBranch) context.Context {
+	return context.WithValue(ctx, comparisonBranchKey{}, branch)
+}
+
+// ComparisonBranchFromContext returns the comparison branch a task runs in,
+// or nil outside a comparison.
+func ComparisonBranchFromContext(ctx context.Context) *ComparisonBranch {
+	branch, _ := ctx.Value(comparisonBranchKey{}).(*ComparisonBranch)
+	return branch
+}
+
+// ComparisonRater asks the user to rate both branches' outputs side by side.
+// It returns a 1-10 rating per branch, in the order of report.Branches; a
+// rating of 0 keeps the branch's computed rating.
+type ComparisonRater func(ctx context.Context, report *ComparisonReport) ([2]float64, error)
+
+// ComparisonOptions configures ExecuteComparison.
+type ComparisonOptions struct {
+	// UserInitiated must be set by the command the user ran to start the comparison
+	UserInitiated bool
+
+	// Concurrent runs both branches at once instead of one after the other.
+	// The budget cap is then only checked against the up-front estimate.
+	Concurrent bool
+
+	// BudgetCap is the most both branches together may cost, in dollars (0 for no cap).
+	// Run sequentially, the second branch is skipped if the first leaves too little.
+	BudgetCap float64
+
+	// CostPerToken converts token counts to dollars for estimates and branch costs
+	CostPerToken float64
+
+	// Budget checks the combined estimate with CanAfford before either branch runs (optional)
+	Budget *llm.BudgetManager
+
+	// Sandboxed declares that the executor isolates each branch's tasks, which
+	// allows objectives whose tasks write files or run commands
+	Sandboxed bool
+
+	// WorkspaceDir is where branch workspaces are created (default: the system temp directory)
+	WorkspaceDir string
+
+	// Rate prompts the user for ratings before the winner is chosen (optional)
+	Rate ComparisonRater
+}
+
+// ComparisonBranchReport is the outcome of one side of a comparison.
+type ComparisonBranchReport struct {
+	Branch   *ComparisonBranch
+	MethodID string
+
+	// Result is nil if the branch was skipped
+	Result *ExecutionResult
+
+	Success  bool
+	Cost     float64
+	Duration time.Duration
+
+	// SystemRating is computed from the execution (1-10)
+	SystemRating float64
+
+	// UserRating is the user's rating (1-10), or 0 if not rated
+	UserRating float64
+
+	// SkipReason explains why the branch did not run (empty if it ran)
+	SkipReason string
+}
+
+// Rating returns the user's rating if given, otherwise the computed one.
+func (b *ComparisonBranchReport) Rating() float64 {
+	if b.UserRating > 0 {
+		return b.UserRating
+	}
+	return b.SystemRating
+}
+
+// ComparisonReport summarizes a method comparison.
+type ComparisonReport struct {
+	ID          string
+	ObjectiveID string
+	Branches    [2]*ComparisonBranchReport
+
+	// Winner is the index of the winning branch in Branches
+	Winner int
+
+	// Recommendation explains which method to prefer and why
+	Recommendation string
+
+	// EstimatedCost is the up-front estimate for both branches
+	EstimatedCost float64
+
+	Concurrent bool
+	StartTime  time.Time
+	EndTime    time.Time
+}
+
+// WinningBranch returns the branch whose result became the objective's result.
+func (r *ComparisonReport) WinningBranch() *ComparisonBranchReport {
+	return r.Branches[r.Winner]
+}
+
+// AlternativeBranch returns the losing branch.
+func (r *ComparisonReport) AlternativeBranch() *ComparisonBranchReport {
+	return r.Branches[1-r.Winner]
+}
+
+// TotalCost returns what both branches cost together.
+func (r *ComparisonReport) TotalCost() float64 {
+	return r.Branches[0].Cost + r.Branches[1].Cost
+}
+
+// ExecuteComparison runs an objective with two candidate methods in isolated
+// branches and compares them. The winning branch's result becomes the
+// objective's result; the other is kept, linked to it by a
+// "comparison_alternative" edge. Both methods' metrics record the run as a
+// comparison.
+func (ll *LearningLoop) ExecuteComparison(ctx context.Context, objectiveID string, methodIDs [2]string, opts ComparisonOptions) (*ComparisonReport, error) {
+	if !opts.UserInitiated {
+		return nil, ErrComparisonNotInitiated
+	}
+	if methodIDs[0] == "" || methodIDs[1] == "" || methodIDs[0] == methodIDs[1] {
+		return nil, fmt.Errorf("a comparison needs two different methods")
+	}
+
+	objective, err := ll.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get objective: %w", err)
+	}
+	if objective.Status != ObjectiveStatusPending && objective.Status != ObjectiveStatusInProgress {
+		return nil, errs.Newf(errs.Conflict, "can only compare methods on pending or in-progress objectives, current status: %s", objective.Status)
+	}
+
+	var plans [2]*ExecutionPlan
+	for i, methodID := range methodIDs {
+		plan, err := ll.contemplativeCursor.CreateExecutionPlanForMethod(ctx, objectiveID, methodID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan with method %s: %w", methodID, err)
+		}
+		if !opts.Sandboxed {
+			for _, task := range plan.Tasks {
+				if TaskHasSideEffects(&task) {
+					return nil, fmt.Errorf("%w: task %s (%s) of method %s; enable sandbox isolation to compare",
+						ErrComparisonSideEffects, task.ID, task.Type, methodID)
+				}
+			}
+		}
+		plans[i] = plan
+	}
+
+	estimated := float64(plans[0].TotalEstimatedTokens+plans[1].TotalEstimatedTokens) * opts.CostPerToken
+	if opts.BudgetCap > 0 && estimated > opts.BudgetCap {
+		return nil, fmt.Errorf("%w: estimated $%.2f is over the $%.2f cap", ErrComparisonOverBudget, estimated, opts.BudgetCap)
+	}
+	if opts.Budget != nil {
+		check, err := opts.Budget.CanAfford(estimated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check budget: %w", err)
+		}
+		if !check.Affordable {
+			return nil, fmt.Errorf("%w: %s", ErrComparisonOverBudget, strings.Join(check.Warnings, "; "))
+		}
+	}
+
+	if objective.Status == ObjectiveStatusPending {
+		if _, err := ll.objectiveManager.StartObjective(ctx, objectiveID); err != nil {
+			return nil, fmt.Errorf("failed to start objective: %w", err)
+		}
+	}
+
+	report := &ComparisonReport{
+		ID:            fmt.Sprintf("cmp_%d", time.Now().UnixNano()),
+		ObjectiveID:   objectiveID,
+		EstimatedCost: estimated,
+		Concurrent:    opts.Concurrent,
+		StartTime:     time.Now(),
+	}
+	workspaceDir := opts.WorkspaceDir
+	if workspaceDir == "" {
+		workspaceDir = filepath.Join(os.TempDir(), "ai-work-studio-comparisons")
+	}
+	for i, label := range []string{"A", "B"} {
+		branch := &ComparisonBranch{
+			ComparisonID: report.ID,
+			Label:        label,
+			Workspace:    filepath.Join(workspaceDir, report.ID, label),
+			Context:      cloneContext(objective.Context),
+		}
+		if err := os.MkdirAll(branch.Workspace, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create workspace for branch %s: %w", label, err)
+		}
+		isolatePlan(plans[i], branch)
+		report.Branches[i] = &ComparisonBranchReport{Branch: branch, MethodID: methodIDs[i]}
+	}
+
+	if opts.Concurrent {
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i := range plans {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = ll.runComparisonBranch(ctx, plans[i], report.Branches[i], opts)
+			}(i)
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := ll.runComparisonBranch(ctx, plans[0], report.Branches[0], opts); err != nil {
+			return nil, err
+		}
+		second := float64(plans[1].TotalEstimatedTokens) * opts.CostPerToken
+		if opts.BudgetCap > 0 && report.Branches[0].Cost+second > opts.BudgetCap {
+			report.Branches[1].SkipReason = fmt.Sprintf("branch A cost $%.2f, leaving less than the estimated $%.2f under the $%.2f cap",
+				report.Branches[0].Cost, second, opts.BudgetCap)
+		} else if err := ll.runComparisonBranch(ctx, plans[1], report.Branches[1], opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Rate != nil && report.Branches[1].SkipReason == "" {
+		ratings, err := opts.Rate(ctx, report)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rate branches: %w", err)
+		}
+		for i, rating := range ratings {
+			if rating >= 1.0 && rating <= 10.0 {
+				report.Branches[i].UserRating = rating
+			}
+		}
+	}
+
+	report.Winner = chooseComparisonWinner(report.Branches)
+	report.Recommendation = comparisonRecommendation(report)
+	report.EndTime = time.Now()
+
+	if err := ll.recordComparison(ctx, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// runComparisonBranch executes a branch's plan and fills in its report.
+// A failed execution is an outcome to compare; only cancellation is an error.
+func (ll *LearningLoop) runComparisonBranch(ctx context.Context, plan *ExecutionPlan, branch *ComparisonBranchReport, opts ComparisonOptions) error {
+	result, err := ll.realTimeCursor.ExecutePlan(ctx, plan)
+	if result == nil || ctx.Err() != nil {
+		return fmt.Errorf("branch %s failed to execute: %w", branch.Branch.Label, err)
+	}
+	branch.Result = result
+	branch.Success = result.Status == ExecutionStatusCompleted || result.Status == ExecutionStatusPartial
+	branch.Cost = float64(result.TotalTokensUsed) * opts.CostPerToken
+	branch.Duration = result.TotalDuration
+	branch.SystemRating = ll.realTimeCursor.calculateExecutionRating(result)
+	return nil
+}
+
+// recordComparison updates both methods' metrics, links the alternative
+// result to the winning one and completes the objective with the winner's result.
+func (ll *LearningLoop) recordComparison(ctx context.Context, report *ComparisonReport) error {
+	for i, branch := range report.Branches {
+		if branch.Result == nil {
+			continue
+		}
+		if err := ll.methodManager.RecordComparisonRun(ctx, branch.MethodID, branch.Success, branch.Rating(), i == report.Winner); err != nil {
+			return fmt.Errorf("failed to record comparison run of method %s: %w", branch.MethodID, err)
+		}
+	}
+
+	winner, alternative := report.WinningBranch(), report.AlternativeBranch()
+	data := map[string]interface{}{
+		"comparison_id":       report.ID,
+		"execution_result_id": winner.Result.ID,
+		"method_id":           winner.MethodID,
+		"alternative_method":  alternative.MethodID,
+		"recommendation":      report.Recommendation,
+	}
+	if alternative.Result != nil && alternative.Result.ID != "" && winner.Result.ID != "" {
+		edge := storage.NewEdge(alternative.Result.ID, winner.Result.ID, comparisonAlternativeEdgeType, map[string]interface{}{
+			"comparison_id": report.ID,
+			"rating":        alternative.Rating(),
+			"winner_rating": winner.Rating(),
+		})
+		if err := ll.store.AddEdge(ctx, edge); err != nil {
+			return fmt.Errorf("failed to link comparison alternative: %w", err)
+		}
+		data["alternative_result_id"] = alternative.Result.ID
+	}
+
+	_, err := ll.objectiveManager.CompleteObjective(ctx, report.ObjectiveID, ObjectiveResult{
+		Success:    winner.Success,
+		Message:    report.Recommendation,
+		Data:       data,
+		TokensUsed: winner.Result.TotalTokensUsed,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record the winning result: %w", err)
+	}
+	return nil
+}
+
+// chooseComparisonWinner prefers the branch that ran and succeeded, then the
+// higher rating, then the lower cost, and finally branch A.
+func chooseComparisonWinner(branches [2]*ComparisonBranchReport) int {
+	a, b := branches[0], branches[1]
+	switch {
+	case b.Result == nil:
+		return 0
+	case a.Success != b.Success:
+		if b.Success {
+			return 1
+		}
+		return 0
+	case a.Rating() != b.Rating():
+		if b.Rating() > a.Rating() {
+			return 1
+		}
+		return 0
+	case b.Cost < a.Cost:
+		return 1
+	}
+	return 0
+}
+
+// comparisonRecommendation explains why the winner was chosen.
+func comparisonRecommendation(report *ComparisonReport) string {
+	winner, alternative := report.WinningBranch(), report.AlternativeBranch()
+	prefer := fmt.Sprintf("Prefer method %s (branch %s)", winner.MethodID, winner.Branch.Label)
+	switch {
+	case alternative.Result == nil:
+		return fmt.Sprintf("%s by default: branch %s was skipped because %s", prefer, alternative.Branch.Label, alternative.SkipReason)
+	case !winner.Success && !alternative.Success:
+		return fmt.Sprintf("Neither method succeeded; keeping the result of method %s, rated %.1f against %.1f",
+			winner.MethodID, winner.Rating(), alternative.Rating())
+	case winner.Success != alternative.Success:
+		return fmt.Sprintf("%s: it succeeded where method %s did not", prefer, alternative.MethodID)
+	case winner.Rating() != alternative.Rating():
+		return fmt.Sprintf("%s: rated %.1f against %.1f for method %s", prefer, winner.Rating(), alternative.Rating(), alternative.MethodID)
+	}
+	return fmt.Sprintf("%s: both rated %.1f and it cost $%.2f against $%.2f", prefer, winner.Rating(), winner.Cost, alternative.Cost)
+}
+
+// isolatePlan points every task of the plan at the branch's workspace.
+func isolatePlan(plan *ExecutionPlan, branch *ComparisonBranch) {
+	plan.Comparison = branch
+	for i := range plan.Tasks {
+		parameters := make(map[string]interface{}, len(plan.Tasks[i].Context.Parameters)+2)
+		for key, value := range plan.Tasks[i].Context.Parameters {
+			parameters[key] = value
+		}
+		parameters["workspace"] = branch.Workspace
+		parameters["comparison_branch"] = branch.Label
+		plan.Tasks[i].Context.Parameters = parameters
+	}
+}
+
+// cloneContext deep-copies an objective context, so one branch cannot change
+// what the other sees.
+func cloneContext(context map[string]interface{}) map[string]interface{} {
+	clone := make(map[string]interface{}, len(context))
+	for key, value := range context {
+		clone[key] = cloneContextValue(value)
+	}
+	return clone
+}
+
+// cloneContextValue deep-copies the maps and slices within a context value.
+func cloneContextValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return cloneContext(v)
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneContextValue(item)
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// comparisonAlternatives returns the IDs of execution results that lost a method comparison.
+func comparisonAlternatives(ctx context.Context, store *storage.Store) (map[string]bool, error) {
+	edges, err := store.GetEdgesByType(ctx, comparisonAlternativeEdgeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comparison alternatives: %w", err)
+	}
+	alternatives := make(map[string]bool, len(edges))
+	for _, edge := range edges {
+		alternatives[edge.SourceID] = true
+	}
+	return alternatives, nil
+}
@@ -0,0 +1,364 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// branchOutcome scripts how every task of a comparison branch turns out.
+type branchOutcome struct {
+	fail       bool
+	tokens     int
+	confidence float64
+}
+
+// scriptedExecutor executes tasks according to the outcome scripted for
+// their comparison branch, recording the workspace each branch was given.
+type scriptedExecutor struct {
+	outcomes map[string]branchOutcome
+
+	mu         sync.Mutex
+	calls      int
+	workspaces map[string]string
+}
+
+func newScriptedExecutor(outcomes map[string]branchOutcome) *scriptedExecutor {
+	return &scriptedExecutor{outcomes: outcomes, workspaces: make(map[string]string)}
+}
+
+func (e *scriptedExecutor) ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error) {
+	branch := ComparisonBranchFromContext(ctx)
+	if branch == nil {
+		return nil, fmt.Errorf("task %s ran outside a comparison branch", task.ID)
+	}
+
+	e.mu.Lock()
+	e.calls++
+	if workspace, _ := task.Context.Parameters["workspace"].(string); workspace == branch.Workspace {
+		e.workspaces[branch.Label] = workspace
+	}
+	e.mu.Unlock()
+
+	outcome := e.outcomes[branch.Label]
+	if outcome.fail {
+		return nil, fmt.Errorf("scripted failure in branch %s", branch.Label)
+	}
+	return &TaskResult{
+		TaskID:     task.ID,
+		Status:     TaskStatusCompleted,
+		TokensUsed: outcome.tokens,
+		Confidence: outcome.confidence,
+	}, nil
+}
+
+func (e *scriptedExecutor) GetAvailableTools(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (e *scriptedExecutor) EstimateTokenUsage(ctx context.Context, task *ExecutionTask) (int, error) {
+	return task.EstimatedTokens, nil
+}
+
+// staticContextLoader loads the same empty context for every task.
+type staticContextLoader struct{}
+
+func (staticContextLoader) LoadTaskContext(ctx context.Context, task *ExecutionTask) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (staticContextLoader) LoadObjectiveContext(ctx context.Context, objectiveID string) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (staticContextLoader) ResolveReference(ctx context.Context, ref string) (interface{}, error) {
+	return nil, fmt.Errorf("unknown reference %s", ref)
+}
+
+// typedReasoner plans like MockLLMReasoner, giving the second task of a
+// method's plan the type configured for that method.
+type typedReasoner struct {
+	*MockLLMReasoner
+	taskTypes map[string]string
+}
+
+func (r typedReasoner) DecomposePlan(ctx context.Context, objective *Objective, method *Method) (*ExecutionPlan, error) {
+	plan, err := r.MockLLMReasoner.DecomposePlan(ctx, objective, method)
+	if err != nil {
+		return nil, err
+	}
+	if taskType, ok := r.taskTypes[method.ID]; ok {
+		plan.Tasks[1].Type = taskType
+	}
+	return plan, nil
+}
+
+// comparisonFixture holds a learning loop with two candidate methods for one objective.
+type comparisonFixture struct {
+	ll        *LearningLoop
+	store     *storage.Store
+	executor  *scriptedExecutor
+	reasoner  typedReasoner
+	objective *Objective
+	methods   [2]string
+}
+
+func setupComparison(t *testing.T, outcomes map[string]branchOutcome) *comparisonFixture {
+	t.Helper()
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Comparison Goal", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	mm := NewMethodManager(store)
+	var methods [2]string
+	for i, name := range []string{"Thorough", "Quick"} {
+		method, err := mm.CreateMethod(ctx, name, "", []ApproachStep{{Description: "Analyze"}, {Description: "Synthesize"}}, MethodDomainGeneral, nil)
+		if err != nil {
+			t.Fatalf("Failed to create method: %v", err)
+		}
+		methods[i] = method.ID
+	}
+	objective, err := NewObjectiveManager(store).CreateObjective(ctx, goal.ID, methods[0], "Compare approaches", "",
+		map[string]interface{}{"sources": []interface{}{"report.txt"}}, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+
+	executor := newScriptedExecutor(outcomes)
+	reasoner := typedReasoner{MockLLMReasoner: NewMockLLMReasoner(), taskTypes: make(map[string]string)}
+	rtc := NewRealTimeCursor(store, executor, staticContextLoader{})
+	rtc.SetRetryConfig(&RetryConfig{MaxRetries: 0})
+	ll := NewLearningLoop(store, NewContemplativeCursor(store, reasoner), rtc, NewMockLearningAgent())
+
+	return &comparisonFixture{ll: ll, store: store, executor: executor, reasoner: reasoner, objective: objective, methods: methods}
+}
+
+func TestExecuteComparison_AttributesOutcomes(t *testing.T) {
+	f := setupComparison(t, map[string]branchOutcome{
+		"A": {fail: true},
+		"B": {tokens: 100, confidence: 0.9},
+	})
+	ctx := context.Background()
+	workspaceDir := t.TempDir()
+
+	report, err := f.ll.ExecuteComparison(ctx, f.objective.ID, f.methods, ComparisonOptions{
+		UserInitiated: true,
+		CostPerToken:  0.25,
+		WorkspaceDir:  workspaceDir,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteComparison failed: %v", err)
+	}
+
+	if report.Winner != 1 || report.WinningBranch().MethodID != f.methods[1] {
+		t.Fatalf("Expected the succeeding branch B to win, got branch %d: %s", report.Winner, report.Recommendation)
+	}
+	a, b := report.Branches[0], report.Branches[1]
+	if a.Success || !b.Success {
+		t.Errorf("Expected A to fail and B to succeed, got %v and %v", a.Success, b.Success)
+	}
+	if a.Cost != 0 || b.Cost != 50 {
+		t.Errorf("Expected costs attributed per branch ($0 and $50), got $%v and $%v", a.Cost, b.Cost)
+	}
+
+	// Each branch worked in its own workspace
+	if f.executor.workspaces["A"] == "" || f.executor.workspaces["A"] == f.executor.workspaces["B"] {
+		t.Errorf("Expected separate workspaces, got %v", f.executor.workspaces)
+	}
+	for _, branch := range report.Branches {
+		if _, err := os.Stat(branch.Branch.Workspace); err != nil {
+			t.Errorf("Expected workspace %s to exist: %v", branch.Branch.Workspace, err)
+		}
+	}
+
+	// Both methods record the run as a comparison; only the winner counts a win
+	mm := NewMethodManager(f.store)
+	for i, want := range []SuccessMetrics{
+		{ExecutionCount: 1, SuccessCount: 0, ComparisonRuns: 1, ComparisonWins: 0},
+		{ExecutionCount: 1, SuccessCount: 1, ComparisonRuns: 1, ComparisonWins: 1},
+	} {
+		method, err := mm.GetMethod(ctx, f.methods[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := method.Metrics
+		if got.ExecutionCount != want.ExecutionCount || got.SuccessCount != want.SuccessCount ||
+			got.ComparisonRuns != want.ComparisonRuns || got.ComparisonWins != want.ComparisonWins {
+			t.Errorf("Method %d: expected metrics %+v, got %+v", i, want, got)
+		}
+	}
+
+	// The winner's result is the objective's result
+	objective, err := NewObjectiveManager(f.store).GetObjective(ctx, f.objective.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objective.Status != ObjectiveStatusCompleted || objective.Result == nil {
+		t.Fatalf("Expected the objective to be completed, got %s", objective.Status)
+	}
+	if objective.Result.Data["execution_result_id"] != b.Result.ID || objective.Result.Data["method_id"] != f.methods[1] {
+		t.Errorf("Expected the winning result to be recorded, got %v", objective.Result.Data)
+	}
+
+	// The loser is kept, linked to the winner
+	edges, err := f.store.GetEdgesByType(ctx, "comparison_alternative")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 1 || edges[0].SourceID != a.Result.ID || edges[0].TargetID != b.Result.ID {
+		t.Errorf("Expected one edge from the alternative to the winner, got %v", edges)
+	}
+	last, err := NewReplanAdvisor(f.store, nil).LastExecution(ctx, f.objective.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || last.ResultID != b.Result.ID {
+		t.Errorf("Expected re-planning to build on the winning result, got %+v", last)
+	}
+}
+
+func TestExecuteComparison_SideEffectGuard(t *testing.T) {
+	f := setupComparison(t, map[string]branchOutcome{
+		"A": {tokens: 50, confidence: 0.8},
+		"B": {tokens: 50, confidence: 0.8},
+	})
+	ctx := context.Background()
+	f.reasoner.taskTypes[f.methods[1]] = "write_file"
+
+	_, err := f.ll.ExecuteComparison(ctx, f.objective.ID, f.methods, ComparisonOptions{UserInitiated: true, WorkspaceDir: t.TempDir()})
+	if !errors.Is(err, ErrComparisonSideEffects) {
+		t.Fatalf("Expected ErrComparisonSideEffects, got %v", err)
+	}
+	if f.executor.calls != 0 {
+		t.Errorf("Expected no task to run, got %d calls", f.executor.calls)
+	}
+	objective, _ := NewObjectiveManager(f.store).GetObjective(ctx, f.objective.ID)
+	if objective.Status != ObjectiveStatusPending {
+		t.Errorf("Expected the objective to stay pending, got %s", objective.Status)
+	}
+
+	// Tasks may also declare their side effects
+	declared := &ExecutionTask{Type: "analysis", Context: TaskContext{Parameters: map[string]interface{}{"side_effects": true}}}
+	if !TaskHasSideEffects(declared) {
+		t.Error("Expected a declared side effect to be detected")
+	}
+
+	// Sandbox isolation allows the comparison
+	if _, err := f.ll.ExecuteComparison(ctx, f.objective.ID, f.methods, ComparisonOptions{UserInitiated: true, Sandboxed: true, WorkspaceDir: t.TempDir()}); err != nil {
+		t.Errorf("Expected a sandboxed comparison to run: %v", err)
+	}
+}
+
+func TestExecuteComparison_Guardrails(t *testing.T) {
+	f := setupComparison(t, map[string]branchOutcome{
+		"A": {tokens: 1000, confidence: 0.8},
+		"B": {tokens: 100, confidence: 0.8},
+	})
+	ctx := context.Background()
+
+	if _, err := f.ll.ExecuteComparison(ctx, f.objective.ID, f.methods, ComparisonOptions{}); !errors.Is(err, ErrComparisonNotInitiated) {
+		t.Errorf("Expected ErrComparisonNotInitiated, got %v", err)
+	}
+
+	// Each plan is estimated at 500 tokens
+	_, err := f.ll.ExecuteComparison(ctx, f.objective.ID, f.methods, ComparisonOptions{
+		UserInitiated: true, CostPerToken: 0.001, BudgetCap: 0.5,
+	})
+	if !errors.Is(err, ErrComparisonOverBudget) {
+		t.Errorf("Expected the cap to refuse a $1.00 estimate, got %v", err)
+	}
+
+	budget, err := llm.NewBudgetManager(t.TempDir(), llm.BudgetConfig{DailyLimit: 0.5, TrackingEnabled: true}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.ll.ExecuteComparison(ctx, f.objective.ID, f.methods, ComparisonOptions{
+		UserInitiated: true, CostPerToken: 0.001, Budget: budget,
+	})
+	if !errors.Is(err, ErrComparisonOverBudget) {
+		t.Errorf("Expected CanAfford to refuse the estimate, got %v", err)
+	}
+	if f.executor.calls != 0 {
+		t.Errorf("Expected no task to run, got %d calls", f.executor.calls)
+	}
+
+	// Branch A overspends its estimate, leaving too little of the cap for B
+	report, err := f.ll.ExecuteComparison(ctx, f.objective.ID, f.methods, ComparisonOptions{
+		UserInitiated: true, CostPerToken: 0.001, BudgetCap: 1.2, WorkspaceDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("ExecuteComparison failed: %v", err)
+	}
+	if report.Branches[1].Result != nil || report.Branches[1].SkipReason == "" {
+		t.Errorf("Expected branch B to be skipped, got %+v", report.Branches[1])
+	}
+	if report.Winner != 0 {
+		t.Errorf("Expected the only branch that ran to win, got %d", report.Winner)
+	}
+	method, _ := NewMethodManager(f.store).GetMethod(ctx, f.methods[1])
+	if method.Metrics.ExecutionCount != 0 {
+		t.Errorf("Expected the skipped method's metrics to be untouched, got %+v", method.Metrics)
+	}
+}
+
+func TestExecuteComparison_ConcurrentUserRatings(t *testing.T) {
+	f := setupComparison(t, map[string]branchOutcome{
+		"A": {tokens: 100, confidence: 0.9},
+		"B": {tokens: 100, confidence: 0.9},
+	})
+	ctx := context.Background()
+
+	var rated *ComparisonReport
+	report, err := f.ll.ExecuteComparison(ctx, f.objective.ID, f.methods, ComparisonOptions{
+		UserInitiated: true,
+		Concurrent:    true,
+		WorkspaceDir:  t.TempDir(),
+		Rate: func(ctx context.Context, report *ComparisonReport) ([2]float64, error) {
+			rated = report
+			return [2]float64{4, 9}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteComparison failed: %v", err)
+	}
+	if rated == nil || rated.Branches[0].Result == nil || rated.Branches[1].Result == nil {
+		t.Fatal("Expected both outputs to be offered for rating")
+	}
+	if report.Winner != 1 || report.Branches[1].Rating() != 9 {
+		t.Errorf("Expected the user's preferred branch to win, got %d: %s", report.Winner, report.Recommendation)
+	}
+
+	method, err := NewMethodManager(f.store).GetMethod(ctx, f.methods[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method.Metrics.AverageRating != 9 || method.Metrics.ComparisonWins != 1 {
+		t.Errorf("Expected the user's rating to be recorded, got %+v", method.Metrics)
+	}
+
+	// Executions are stored with their branch
+	nodes, err := f.store.GetNodesByType(ctx, "execution_result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches := make(map[string]bool)
+	for _, node := range nodes {
+		if result, err := executionResultFromNode(node); err == nil && result.Comparison != nil && result.Comparison.ComparisonID == report.ID {
+			branches[result.Comparison.Label] = true
+		}
+	}
+	if !branches["A"] || !branches["B"] {
+		t.Errorf("Expected stored results for both branches, got %v", branches)
+	}
+}
@@ -0,0 +1,490 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+const (
+	// MethodDefinitionFormat identifies a method definition document
+	MethodDefinitionFormat = "ai-work-studio/methods"
+
+	// MethodDefinitionVersion is the version of the definition format written
+	// by ExportMethods; ImportMethods reads this version and older
+	MethodDefinitionVersion = 1
+)
+
+// MethodFormat is the encoding of a method definition document.
+type MethodFormat string
+
+const (
+	// MethodFormatJSON documents are JSON
+	MethodFormatJSON MethodFormat = "json"
+
+	// MethodFormatYAML documents are YAML
+	MethodFormatYAML MethodFormat = "yaml"
+)
+
+// ParseMethodFormat returns the format named by name ("json", "yaml" or "yml").
+func ParseMethodFormat(name string) (MethodFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "json":
+		return MethodFormatJSON, nil
+	case "yaml", "yml":
+		return MethodFormatYAML, nil
+	default:
+		return "", errs.Newf(errs.Validation, "unknown method format %q, must be json or yaml", name)
+	}
+}
+
+// MethodDocument is a shareable set of method definitions. It carries what a
+// method does, never how it has performed: metrics stay on the machine that
+// earned them.
+type MethodDocument struct {
+	// Format is always MethodDefinitionFormat
+	Format string `json:"format" yaml:"format"`
+
+	// Version is the definition format version the document was written in
+	Version int `json:"version" yaml:"version"`
+
+	// Methods are the definitions, with unique names
+	Methods []MethodDefinition `json:"methods" yaml:"methods"`
+}
+
+// MethodDefinition is one method as it is shared between installations.
+type MethodDefinition struct {
+	Name        string       `json:"name" yaml:"name"`
+	Description string       `json:"description,omitempty" yaml:"description,omitempty"`
+	Domain      MethodDomain `json:"domain" yaml:"domain"`
+
+	// MethodVersion is the method's own version (default: 1.0.0)
+	MethodVersion string `json:"method_version,omitempty" yaml:"method_version,omitempty"`
+
+	Steps []ApproachStep `json:"steps" yaml:"steps"`
+
+	// Metadata is kept as the method's user context
+	Metadata map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// MethodDefinitionOf returns the shareable definition of a method.
+func MethodDefinitionOf(method *Method) MethodDefinition {
+	return MethodDefinition{
+		Name:          method.Name,
+		Description:   method.Description,
+		Domain:        method.Domain,
+		MethodVersion: method.Version,
+		Steps:         method.Approach,
+		Metadata:      method.UserContext,
+	}
+}
+
+// ContentHash identifies what the method does: its description, domain and
+// steps. Two definitions with the same name and hash are the same method,
+// whatever their version or metadata.
+func (d MethodDefinition) ContentHash() string {
+	type hashedStep struct {
+		Description string                 `json:"description"`
+		Tools       []string               `json:"tools,omitempty"`
+		Heuristics  []string               `json:"heuristics,omitempty"`
+		Conditions  map[string]interface{} `json:"conditions,omitempty"`
+	}
+	steps := make([]hashedStep, len(d.Steps))
+	for i, step := range d.Steps {
+		steps[i] = hashedStep{step.Description, step.Tools, step.Heuristics, step.Conditions}
+	}
+	// Maps marshal with sorted keys, and numbers read back from storage as
+	// float64 marshal as they were written
+	data, _ := json.Marshal(struct {
+		Description string       `json:"description"`
+		Domain      MethodDomain `json:"domain"`
+		Steps       []hashedStep `json:"steps"`
+	}{strings.TrimSpace(d.Description), d.Domain, steps})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// validate checks a definition can be stored as a method.
+func (d MethodDefinition) validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return errs.New(errs.Validation, "method name cannot be empty")
+	}
+	if !isValidDomain(d.Domain) {
+		return errs.Newf(errs.Validation, "method %q has invalid domain %q", d.Name, d.Domain)
+	}
+	if len(d.Steps) == 0 {
+		return errs.Newf(errs.Validation, "method %q has no steps", d.Name)
+	}
+	for i, step := range d.Steps {
+		if strings.TrimSpace(step.Description) == "" {
+			return errs.Newf(errs.Validation, "method %q step %d has no description", d.Name, i+1)
+		}
+	}
+	return nil
+}
+
+// DuplicatePolicy decides what ImportMethods does with a definition whose
+// name matches a stored method with different content.
+type DuplicatePolicy string
+
+const (
+	// DuplicateSkip keeps the stored method and ignores the definition
+	DuplicateSkip DuplicatePolicy = "skip"
+
+	// DuplicateReplace rewrites the stored method with the definition,
+	// keeping its ID and metrics
+	DuplicateReplace DuplicatePolicy = "replace"
+
+	// DuplicateSuccessor stores the definition as the next version of the
+	// stored method, which is marked superseded
+	DuplicateSuccessor DuplicatePolicy = "successor"
+)
+
+// ParseDuplicatePolicy returns the policy named by name.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case DuplicateSkip, DuplicateReplace, DuplicateSuccessor:
+		return policy, nil
+	default:
+		return "", errs.Newf(errs.Validation, "unknown duplicate policy %q, must be skip, replace or successor", name)
+	}
+}
+
+// ImportOptions controls ImportMethods.
+type ImportOptions struct {
+	// Format is the document's encoding (default: detected, JSON when it
+	// starts with '{' and YAML otherwise)
+	Format MethodFormat
+
+	// OnDuplicate decides what happens to a definition whose name matches a
+	// stored method with different content (default: DuplicateSkip). A
+	// definition identical to a stored method is never imported again.
+	OnDuplicate DuplicatePolicy
+}
+
+// MethodImportAction is what ImportMethods did with one definition.
+type MethodImportAction string
+
+const (
+	// MethodImportCreated stored the definition as a new method
+	MethodImportCreated MethodImportAction = "created"
+
+	// MethodImportUnchanged found the definition already stored
+	MethodImportUnchanged MethodImportAction = "unchanged"
+
+	// MethodImportSkipped kept a stored method of the same name
+	MethodImportSkipped MethodImportAction = "skipped"
+
+	// MethodImportReplaced rewrote a stored method of the same name
+	MethodImportReplaced MethodImportAction = "replaced"
+
+	// MethodImportSucceeded stored the definition as the successor of a
+	// stored method of the same name
+	MethodImportSucceeded MethodImportAction = "successor"
+)
+
+// MethodImportResult reports what happened to one imported definition.
+type MethodImportResult struct {
+	Name   string
+	Action MethodImportAction
+
+	// MethodID is the method holding the definition, or the stored method
+	// that was kept
+	MethodID string
+
+	// PreviousID is the method a successor evolved from
+	PreviousID string
+
+	// Version is the version of the method MethodID names
+	Version string
+}
+
+// ImportMethods reads a method definition document from r and stores its
+// methods. Definitions are matched to stored methods by name; one with the
+// same content hash as a stored method is left alone, and one with
+// different content is handled by opts.OnDuplicate. The whole document is
+// validated before anything is stored.
+func (mm *MethodManager) ImportMethods(ctx context.Context, r io.Reader, opts ImportOptions) ([]MethodImportResult, error) {
+	if opts.OnDuplicate == "" {
+		opts.OnDuplicate = DuplicateSkip
+	}
+	if _, err := ParseDuplicatePolicy(string(opts.OnDuplicate)); err != nil {
+		return nil, err
+	}
+
+	document, err := readMethodDocument(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := mm.ListMethods(ctx, MethodFilter{})
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string][]*Method)
+	for _, method := range stored {
+		byName[method.Name] = append(byName[method.Name], method)
+	}
+
+	results := make([]MethodImportResult, 0, len(document.Methods))
+	for _, definition := range document.Methods {
+		result, err := mm.importMethod(ctx, definition, byName[definition.Name], opts.OnDuplicate)
+		if err != nil {
+			return results, fmt.Errorf("failed to import method %q: %w", definition.Name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// importMethod stores one definition given the stored methods of its name.
+func (mm *MethodManager) importMethod(ctx context.Context, definition MethodDefinition, named []*Method, policy DuplicatePolicy) (MethodImportResult, error) {
+	result := MethodImportResult{Name: definition.Name}
+
+	hash := definition.ContentHash()
+	var current *Method
+	for _, method := range named {
+		if MethodDefinitionOf(method).ContentHash() == hash {
+			result.Action, result.MethodID, result.Version = MethodImportUnchanged, method.ID, method.Version
+			return result, nil
+		}
+		// The newest method still in use is the one a definition replaces
+		if method.Status != MethodStatusSuperseded && (current == nil || method.CreatedAt.After(current.CreatedAt)) {
+			current = method
+		}
+	}
+
+	switch {
+	case current == nil:
+		method := definition.method(definition.MethodVersion)
+		node := storage.NewNode("method", mm.methodToNodeData(method))
+		method.ID = node.ID
+		if err := mm.store.AddNode(ctx, node); err != nil {
+			return result, fmt.Errorf("failed to store method: %w", err)
+		}
+		result.Action, result.MethodID, result.Version = MethodImportCreated, method.ID, method.Version
+
+	case policy == DuplicateSkip:
+		result.Action, result.MethodID, result.Version = MethodImportSkipped, current.ID, current.Version
+
+	case policy == DuplicateReplace:
+		updates := MethodUpdates{
+			Description: &definition.Description,
+			Approach:    definition.Steps,
+			Domain:      &definition.Domain,
+			UserContext: definition.Metadata,
+		}
+		if definition.MethodVersion != "" {
+			updates.Version = &definition.MethodVersion
+		}
+		replaced, err := mm.UpdateMethod(ctx, current.ID, updates)
+		if err != nil {
+			return result, err
+		}
+		result.Action, result.MethodID, result.Version = MethodImportReplaced, replaced.ID, replaced.Version
+
+	default:
+		successor := definition.method(nextMinorVersion(current.Version))
+		if err := mm.CreateMethodEvolution(ctx, current.ID, successor, "Imported a newer definition"); err != nil {
+			return result, err
+		}
+		result.Action, result.MethodID, result.Version = MethodImportSucceeded, successor.ID, successor.Version
+		result.PreviousID = current.ID
+	}
+	return result, nil
+}
+
+// method returns a new active method holding the definition.
+func (d MethodDefinition) method(version string) *Method {
+	if version == "" {
+		version = "1.0.0"
+	}
+	return &Method{
+		Name:        d.Name,
+		Description: d.Description,
+		Approach:    d.Steps,
+		Domain:      d.Domain,
+		Version:     version,
+		Status:      MethodStatusActive,
+		UserContext: d.Metadata,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// readMethodDocument decodes and validates a method definition document.
+// Unknown fields, such as metrics, are refused rather than dropped.
+func readMethodDocument(r io.Reader, format MethodFormat) (*MethodDocument, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read method definitions: %w", err)
+	}
+	if format == "" {
+		format = MethodFormatYAML
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			format = MethodFormatJSON
+		}
+	}
+
+	var document MethodDocument
+	switch format {
+	case MethodFormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&document)
+	case MethodFormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&document)
+	default:
+		_, err = ParseMethodFormat(string(format))
+		return nil, err
+	}
+	if err != nil {
+		return nil, errs.Wrap(fmt.Errorf("invalid method definitions: %w", err), errs.Validation, nil)
+	}
+
+	if document.Format != MethodDefinitionFormat {
+		return nil, errs.Newf(errs.Validation, "not a method definition document: format is %q, expected %q", document.Format, MethodDefinitionFormat)
+	}
+	if document.Version < 1 || document.Version > MethodDefinitionVersion {
+		return nil, errs.Newf(errs.Validation, "unsupported method definition version %d; this build reads up to version %d", document.Version, MethodDefinitionVersion)
+	}
+	seen := make(map[string]bool, len(document.Methods))
+	for _, definition := range document.Methods {
+		if err := definition.validate(); err != nil {
+			return nil, err
+		}
+		if seen[definition.Name] {
+			return nil, errs.Newf(errs.Validation, "method %q is defined twice", definition.Name)
+		}
+		seen[definition.Name] = true
+	}
+	return &document, nil
+}
+
+// ExportMethods writes the definitions of the methods to w as a document
+// ImportMethods reads. Without IDs it exports every method not superseded,
+// by name.
+func (mm *MethodManager) ExportMethods(ctx context.Context, w io.Writer, methodIDs []string, format MethodFormat) error {
+	var methods []*Method
+	if len(methodIDs) == 0 {
+		stored, err := mm.ListMethods(ctx, MethodFilter{})
+		if err != nil {
+			return err
+		}
+		for _, method := range stored {
+			if method.Status != MethodStatusSuperseded {
+				methods = append(methods, method)
+			}
+		}
+		sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	}
+	for _, id := range methodIDs {
+		method, err := mm.GetMethod(ctx, id)
+		if err != nil {
+			return err
+		}
+		methods = append(methods, method)
+	}
+
+	document := MethodDocument{Format: MethodDefinitionFormat, Version: MethodDefinitionVersion}
+	seen := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		if seen[method.Name] {
+			return errs.Newf(errs.Validation, "cannot export two methods named %q in one document", method.Name).With("id", method.ID)
+		}
+		seen[method.Name] = true
+		document.Methods = append(document.Methods, MethodDefinitionOf(method))
+	}
+	return writeMethodDocument(w, &document, format)
+}
+
+// ExportMethod writes the definition of one method to w.
+func (mm *MethodManager) ExportMethod(ctx context.Context, w io.Writer, methodID string, format MethodFormat) error {
+	return mm.ExportMethods(ctx, w, []string{methodID}, format)
+}
+
+// writeMethodDocument encodes a document in the format (default: YAML).
+func writeMethodDocument(w io.Writer, document *MethodDocument, format MethodFormat) error {
+	switch format {
+	case MethodFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(document)
+	case MethodFormatYAML, "":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		_, err := ParseMethodFormat(string(format))
+		return err
+	}
+}
+
+//go:embed method_library/*.yaml
+var starterLibrary embed.FS
+
+// StarterMethods returns the names of the methods in the starter library
+// shipped with the application: research, code review and data analysis
+// templates for a fresh installation.
+func StarterMethods() ([]string, error) {
+	var names []string
+	err := eachStarterFile(func(name string, data []byte) error {
+		document, err := readMethodDocument(bytes.NewReader(data), MethodFormatYAML)
+		if err != nil {
+			return err
+		}
+		for _, definition := range document.Methods {
+			names = append(names, definition.Name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// InstallStarterMethods imports the starter library, leaving alone any
+// method the user already has under the same name.
+func (mm *MethodManager) InstallStarterMethods(ctx context.Context) ([]MethodImportResult, error) {
+	var results []MethodImportResult
+	err := eachStarterFile(func(name string, data []byte) error {
+		imported, err := mm.ImportMethods(ctx, bytes.NewReader(data), ImportOptions{Format: MethodFormatYAML, OnDuplicate: DuplicateSkip})
+		results = append(results, imported...)
+		return err
+	})
+	return results, err
+}
+
+// eachStarterFile visits each file of the starter library in name order.
+func eachStarterFile(visit func(name string, data []byte) error) error {
+	files, err := starterLibrary.ReadDir("method_library")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := starterLibrary.ReadFile(path.Join("method_library", file.Name()))
+		if err != nil {
+			return err
+		}
+		if err := visit(file.Name(), data); err != nil {
+			return fmt.Errorf("starter method library %s: %w", file.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// triageDocument is a YAML document defining one method whose first step is
+// described by firstStep.
+func triageDocument(firstStep string) string {
+	return `format: ai-work-studio/methods
+version: 1
+methods:
+  - name: Bug triage
+    description: Reproduce, isolate and prioritize a reported bug.
+    domain: general
+    steps:
+      - description: ` + firstStep + `
+        tools: [shell]
+        conditions:
+          max_attempts: 3
+      - description: Find the smallest failing case
+        heuristics: [Bisect recent changes first]
+    metadata:
+      author: team
+`
+}
+
+func importDocument(t *testing.T, mm *MethodManager, document string, policy DuplicatePolicy) MethodImportResult {
+	t.Helper()
+	results, err := mm.ImportMethods(context.Background(), strings.NewReader(document), ImportOptions{OnDuplicate: policy})
+	if err != nil {
+		t.Fatalf("ImportMethods failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected one result, got %+v", results)
+	}
+	return results[0]
+}
+
+func TestImportMethods_CreatesAndDetectsDuplicates(t *testing.T) {
+	mm := NewMethodManager(setupTestStore(t))
+	ctx := context.Background()
+
+	created := importDocument(t, mm, triageDocument("Reproduce the bug"), "")
+	if created.Action != MethodImportCreated || created.Version != "1.0.0" {
+		t.Fatalf("Expected the method created at 1.0.0, got %+v", created)
+	}
+	method, err := mm.GetMethod(ctx, created.MethodID)
+	if err != nil {
+		t.Fatalf("GetMethod failed: %v", err)
+	}
+	if len(method.Approach) != 2 || method.Approach[0].Tools[0] != "shell" || method.UserContext["author"] != "team" {
+		t.Errorf("Expected the steps and metadata stored, got %+v", method)
+	}
+	if method.Metrics.ExecutionCount != 0 {
+		t.Errorf("Expected an imported method to start without metrics, got %+v", method.Metrics)
+	}
+
+	// The same definition again, even as JSON exported from another
+	// machine, is recognized by its name and content
+	var exported bytes.Buffer
+	if err := mm.ExportMethod(ctx, &exported, method.ID, MethodFormatJSON); err != nil {
+		t.Fatalf("ExportMethod failed: %v", err)
+	}
+	for _, policy := range []DuplicatePolicy{DuplicateSkip, DuplicateReplace, DuplicateSuccessor} {
+		again := importDocument(t, mm, exported.String(), policy)
+		if again.Action != MethodImportUnchanged || again.MethodID != method.ID {
+			t.Errorf("Expected an identical definition left alone under %s, got %+v", policy, again)
+		}
+	}
+
+	// A changed definition is skipped by default
+	skipped := importDocument(t, mm, triageDocument("Reproduce the bug on a clean install"), "")
+	if skipped.Action != MethodImportSkipped || skipped.MethodID != method.ID {
+		t.Errorf("Expected the changed definition skipped, got %+v", skipped)
+	}
+	methods, _ := mm.ListMethods(ctx, MethodFilter{})
+	if len(methods) != 1 {
+		t.Errorf("Expected one stored method, got %d", len(methods))
+	}
+}
+
+func TestImportMethods_Replace(t *testing.T) {
+	mm := NewMethodManager(setupTestStore(t))
+	ctx := context.Background()
+
+	original := importDocument(t, mm, triageDocument("Reproduce the bug"), "")
+	if err := mm.UpdateMethodMetrics(ctx, original.MethodID, true, 8); err != nil {
+		t.Fatalf("UpdateMethodMetrics failed: %v", err)
+	}
+
+	replaced := importDocument(t, mm, triageDocument("Reproduce the bug on a clean install"), DuplicateReplace)
+	if replaced.Action != MethodImportReplaced || replaced.MethodID != original.MethodID {
+		t.Fatalf("Expected the method replaced in place, got %+v", replaced)
+	}
+	method, err := mm.GetMethod(ctx, original.MethodID)
+	if err != nil {
+		t.Fatalf("GetMethod failed: %v", err)
+	}
+	if method.Approach[0].Description != "Reproduce the bug on a clean install" || method.Metrics.ExecutionCount != 1 {
+		t.Errorf("Expected the new steps with the old metrics, got %+v", method)
+	}
+}
+
+func TestImportMethods_VersionsAsSuccessor(t *testing.T) {
+	mm := NewMethodManager(setupTestStore(t))
+	ctx := context.Background()
+
+	original := importDocument(t, mm, triageDocument("Reproduce the bug"), "")
+	successor := importDocument(t, mm, triageDocument("Reproduce the bug on a clean install"), DuplicateSuccessor)
+	if successor.Action != MethodImportSucceeded || successor.PreviousID != original.MethodID || successor.Version != "1.1.0" {
+		t.Fatalf("Expected a 1.1.0 successor of the original, got %+v", successor)
+	}
+
+	chain, err := mm.GetMethodEvolution(ctx, successor.MethodID)
+	if err != nil {
+		t.Fatalf("GetMethodEvolution failed: %v", err)
+	}
+	if len(chain.Predecessors) != 1 || chain.Predecessors[0].ID != original.MethodID || chain.Predecessors[0].Status != MethodStatusSuperseded {
+		t.Errorf("Expected the original superseded and linked, got %+v", chain.Predecessors)
+	}
+
+	// A further version succeeds the newest, not the superseded original
+	third := importDocument(t, mm, triageDocument("Reproduce the bug in a container"), DuplicateSuccessor)
+	if third.PreviousID != successor.MethodID || third.Version != "1.2.0" {
+		t.Errorf("Expected a 1.2.0 successor of 1.1.0, got %+v", third)
+	}
+
+	// Re-importing any earlier version finds it, rather than adding another
+	again := importDocument(t, mm, triageDocument("Reproduce the bug"), DuplicateSuccessor)
+	if again.Action != MethodImportUnchanged || again.MethodID != original.MethodID {
+		t.Errorf("Expected the superseded original recognized, got %+v", again)
+	}
+}
+
+func TestImportMethods_RejectsInvalidDocuments(t *testing.T) {
+	mm := NewMethodManager(setupTestStore(t))
+
+	tests := map[string]string{
+		"metrics": strings.Replace(triageDocument("Reproduce the bug"), "    metadata:", "    metrics:\n      execution_count: 5\n    metadata:", 1),
+		"format":  strings.Replace(triageDocument("Reproduce the bug"), "ai-work-studio/methods", "other/format", 1),
+		"version": strings.Replace(triageDocument("Reproduce the bug"), "version: 1", "version: 99", 1),
+		"domain":  strings.Replace(triageDocument("Reproduce the bug"), "domain: general", "domain: everywhere", 1),
+		"twice":   triageDocument("Reproduce the bug") + strings.SplitN(triageDocument("Reproduce the bug"), "methods:\n", 2)[1],
+		"json":    `{"format": "ai-work-studio/methods", "version": 1, "methods": [{"name": "x", "domain": "general", "steps": [{"description": "y"}], "metrics": {}}]}`,
+	}
+	for name, document := range tests {
+		_, err := mm.ImportMethods(context.Background(), strings.NewReader(document), ImportOptions{})
+		if errs.CodeOf(err) != errs.Validation {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+	}
+	if methods, _ := mm.ListMethods(context.Background(), MethodFilter{}); len(methods) != 0 {
+		t.Errorf("Expected nothing stored from invalid documents, got %d methods", len(methods))
+	}
+}
+
+func TestExportMethods_RoundTrip(t *testing.T) {
+	source := NewMethodManager(setupTestStore(t))
+	ctx := context.Background()
+	if _, err := source.CreateMethod(ctx, "File Processing", "Process files", []ApproachStep{
+		{Description: "Read directory", Tools: []string{"filesystem"}, Conditions: map[string]interface{}{"min_files": 1}},
+	}, MethodDomainGeneral, nil); err != nil {
+		t.Fatalf("CreateMethod failed: %v", err)
+	}
+	importDocument(t, source, triageDocument("Reproduce the bug"), "")
+
+	var exported bytes.Buffer
+	if err := source.ExportMethods(ctx, &exported, nil, MethodFormatYAML); err != nil {
+		t.Fatalf("ExportMethods failed: %v", err)
+	}
+	if strings.Contains(exported.String(), "execution_count") {
+		t.Error("Expected metrics left out of the export")
+	}
+
+	target := NewMethodManager(setupTestStore(t))
+	results, err := target.ImportMethods(ctx, &exported, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportMethods failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Name != "Bug triage" || results[1].Name != "File Processing" {
+		t.Fatalf("Expected both methods imported by name, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Action != MethodImportCreated {
+			t.Errorf("Expected %s created, got %s", result.Name, result.Action)
+		}
+	}
+}
+
+func TestInstallStarterMethods(t *testing.T) {
+	names, err := StarterMethods()
+	if err != nil {
+		t.Fatalf("StarterMethods failed: %v", err)
+	}
+	if len(names) != 3 {
+		t.Fatalf("Expected three starter methods, got %v", names)
+	}
+
+	mm := NewMethodManager(setupTestStore(t))
+	results, err := mm.InstallStarterMethods(context.Background())
+	if err != nil {
+		t.Fatalf("InstallStarterMethods failed: %v", err)
+	}
+	if len(results) != len(names) {
+		t.Fatalf("Expected every starter method installed, got %+v", results)
+	}
+
+	// Installing again changes nothing
+	results, err = mm.InstallStarterMethods(context.Background())
+	if err != nil {
+		t.Fatalf("InstallStarterMethods failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Action != MethodImportUnchanged {
+			t.Errorf("Expected %s unchanged on a second install, got %s", result.Name, result.Action)
+		}
+	}
+}
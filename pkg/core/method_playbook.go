@@ -0,0 +1,594 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+const (
+	// PlaybookTaskType is the task type narrative requests are routed and billed under
+	PlaybookTaskType = "reporting"
+
+	// playbookWeakStepRate is the success rate below which a step is flagged as weak
+	playbookWeakStepRate = 0.6
+
+	// playbookMinStepSamples is how many runs a step needs before it can be flagged
+	playbookMinStepSamples = 3
+
+	// playbookDateLayout is the date format used throughout the playbook
+	playbookDateLayout = "2006-01-02"
+)
+
+// PlaybookOptions controls what GeneratePlaybook includes.
+type PlaybookOptions struct {
+	// IncludeHistory adds the evolution lineage and recent execution outcomes
+	IncludeHistory bool
+
+	// IncludeStats adds per-step and overall performance statistics
+	IncludeStats bool
+
+	// UseLLMNarrative adds a prose summary written by an LLM.
+	// This is the only part of the playbook that makes LLM calls.
+	UseLLMNarrative bool
+
+	// Router routes the narrative request (required when UseLLMNarrative is set)
+	Router *llm.Router
+
+	// Budget records narrative spending under PlaybookTaskType (optional)
+	Budget *llm.BudgetManager
+
+	// NarrativeMaxTokens caps the length of the narrative response
+	NarrativeMaxTokens int
+
+	// NarrativeBudget is the most the narrative request may cost, in dollars
+	NarrativeBudget float64
+
+	// RecentExecutions limits how many execution outcomes are listed
+	RecentExecutions int
+}
+
+// DefaultPlaybookOptions returns options for a full playbook without a narrative.
+func DefaultPlaybookOptions() PlaybookOptions {
+	return PlaybookOptions{
+		IncludeHistory:     true,
+		IncludeStats:       true,
+		NarrativeMaxTokens: 600,
+		NarrativeBudget:    0.05,
+		RecentExecutions:   5,
+	}
+}
+
+// Playbook is the rendered documentation for a method.
+type Playbook struct {
+	// MethodID identifies the documented method
+	MethodID string
+
+	// Markdown is the complete playbook document
+	Markdown string
+
+	// Narrative is the LLM-written summary included in Markdown (empty if not requested)
+	Narrative string
+
+	// NarrativeCost is what the narrative request cost
+	NarrativeCost float64
+
+	// NarrativeError explains why a requested narrative was left out
+	NarrativeError error
+
+	// GeneratedAt is when the playbook was generated
+	GeneratedAt time.Time
+}
+
+// WriteTo writes the playbook markdown to w.
+func (p *Playbook) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, p.Markdown)
+	return int64(n), err
+}
+
+// playbookStepStats aggregates how one method step performed across executions.
+type playbookStepStats struct {
+	runs          int
+	successes     int
+	totalTokens   int
+	totalDuration time.Duration
+}
+
+// successRate returns the fraction of runs that completed (0.0-1.0).
+func (s *playbookStepStats) successRate() float64 {
+	if s.runs == 0 {
+		return 0.0
+	}
+	return float64(s.successes) / float64(s.runs)
+}
+
+// playbookLineageEntry is one version in a method's evolution lineage.
+type playbookLineageEntry struct {
+	method *Method
+	reason string // Why this version was created from the previous one
+}
+
+// GeneratePlaybook renders a markdown playbook for a method: its purpose,
+// preconditions, steps, and optionally performance statistics, evolution
+// lineage, recent execution outcomes and an LLM-written narrative.
+// A narrative that cannot be produced is reported in NarrativeError rather
+// than failing the whole playbook.
+func (mm *MethodManager) GeneratePlaybook(ctx context.Context, methodID string, opts PlaybookOptions) (*Playbook, error) {
+	if opts.UseLLMNarrative && opts.Router == nil {
+		return nil, fmt.Errorf("playbook narrative requested but no LLM router is configured")
+	}
+
+	method, err := mm.GetMethod(ctx, methodID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get method for playbook: %w", err)
+	}
+
+	var executions []*ExecutionResult
+	if opts.IncludeStats || opts.IncludeHistory {
+		executions, err = mm.methodExecutions(ctx, method.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load method executions: %w", err)
+		}
+	}
+
+	var b strings.Builder
+	writePlaybookHeader(&b, method)
+	writePlaybookPreconditions(&b, method)
+	writePlaybookSteps(&b, method, executions, opts.IncludeStats)
+
+	if opts.IncludeStats {
+		writePlaybookPerformance(&b, method)
+	}
+
+	if opts.IncludeHistory {
+		lineage, err := mm.methodLineage(ctx, method)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load method lineage: %w", err)
+		}
+		writePlaybookLineage(&b, lineage)
+		writePlaybookExecutions(&b, executions, opts.RecentExecutions)
+	}
+
+	playbook := &Playbook{
+		MethodID:    method.ID,
+		GeneratedAt: time.Now(),
+	}
+
+	if opts.UseLLMNarrative {
+		narrative, cost, err := generatePlaybookNarrative(ctx, method, b.String(), opts)
+		if err != nil {
+			playbook.NarrativeError = err
+		} else {
+			playbook.Narrative = narrative
+			playbook.NarrativeCost = cost
+			b.WriteString("## Narrative\n\n")
+			b.WriteString(narrative)
+			b.WriteString("\n")
+		}
+	}
+
+	playbook.Markdown = b.String()
+	return playbook, nil
+}
+
+// methodExecutions returns the finished executions of a method, most recent first.
+// Results stored before executions recorded their method fall back to the
+// method of the objective they served.
+func (mm *MethodManager) methodExecutions(ctx context.Context, methodID string) ([]*ExecutionResult, error) {
+	nodes, err := mm.store.GetNodesByType(ctx, "execution_result")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query execution results: %w", err)
+	}
+
+	objectiveMethods := make(map[string]string)
+	var executions []*ExecutionResult
+	for _, node := range nodes {
+		result, err := executionResultFromNode(node)
+		if err != nil || !isTerminalExecutionStatus(result.Status) {
+			continue
+		}
+
+		resultMethodID := result.MethodID
+		if resultMethodID == "" && result.ObjectiveID != "" {
+			cached, ok := objectiveMethods[result.ObjectiveID]
+			if !ok {
+				if objectiveNode, err := mm.store.GetNode(ctx, result.ObjectiveID); err == nil {
+					cached = getString(objectiveNode.Data, "method_id")
+				}
+				objectiveMethods[result.ObjectiveID] = cached
+			}
+			resultMethodID = cached
+		}
+
+		if resultMethodID == methodID {
+			executions = append(executions, result)
+		}
+	}
+
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].StartTime.After(executions[j].StartTime)
+	})
+
+	return executions, nil
+}
+
+// isTerminalExecutionStatus reports whether an execution has finished.
+func isTerminalExecutionStatus(status ExecutionStatus) bool {
+	switch status {
+	case ExecutionStatusCompleted, ExecutionStatusFailed, ExecutionStatusPartial, ExecutionStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// methodLineage walks evolved_from edges back to the original version.
+// The result is ordered from the original version to the given method.
+func (mm *MethodManager) methodLineage(ctx context.Context, method *Method) ([]playbookLineageEntry, error) {
+	lineage := []playbookLineageEntry{{method: method}}
+	visited := map[string]bool{method.ID: true}
+
+	current := method
+	for {
+		edges, err := mm.store.Edges().OfType("evolved_from").FromNode(current.ID).AllContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query method predecessors: %w", err)
+		}
+		if len(edges) == 0 {
+			break
+		}
+
+		// A method normally has one predecessor; prefer the oldest link if not
+		sort.Slice(edges, func(i, j int) bool {
+			return edges[i].CreatedAt.Before(edges[j].CreatedAt)
+		})
+		edge := edges[0]
+		if visited[edge.TargetID] {
+			break
+		}
+
+		predecessor, err := mm.GetMethod(ctx, edge.TargetID)
+		if err != nil {
+			break // Predecessor no longer exists
+		}
+
+		lineage[0].reason = getString(edge.Data, "reason")
+		lineage = append([]playbookLineageEntry{{method: predecessor}}, lineage...)
+		visited[predecessor.ID] = true
+		current = predecessor
+	}
+
+	return lineage, nil
+}
+
+// writePlaybookHeader writes the title, summary fields and purpose.
+func writePlaybookHeader(b *strings.Builder, method *Method) {
+	fmt.Fprintf(b, "# Playbook: %s\n\n", method.Name)
+	fmt.Fprintf(b, "- **Method ID:** `%s`\n", method.ID)
+	fmt.Fprintf(b, "- **Version:** %s\n", method.Version)
+	fmt.Fprintf(b, "- **Status:** %s\n", method.Status)
+	fmt.Fprintf(b, "- **Domain:** %s\n", method.Domain)
+	fmt.Fprintf(b, "- **Created:** %s\n\n", method.CreatedAt.Format(playbookDateLayout))
+
+	b.WriteString("## Purpose\n\n")
+	if method.Description != "" {
+		b.WriteString(method.Description + "\n\n")
+	} else {
+		b.WriteString("_No description recorded._\n\n")
+	}
+}
+
+// writePlaybookPreconditions lists the method's recorded preconditions and
+// the conditions that gate individual steps.
+func writePlaybookPreconditions(b *strings.Builder, method *Method) {
+	b.WriteString("## Preconditions\n\n")
+
+	var lines []string
+	switch preconditions := method.UserContext["preconditions"].(type) {
+	case string:
+		if preconditions != "" {
+			lines = append(lines, preconditions)
+		}
+	case []string:
+		lines = append(lines, preconditions...)
+	case []interface{}:
+		lines = append(lines, interfaceSliceToStringSlice(preconditions)...)
+	}
+
+	for i, step := range method.Approach {
+		if len(step.Conditions) > 0 {
+			lines = append(lines, fmt.Sprintf("Step %d runs when %s", i+1, formatPlaybookConditions(step.Conditions)))
+		}
+	}
+
+	if len(lines) == 0 {
+		b.WriteString("_None recorded._\n\n")
+		return
+	}
+	for _, line := range lines {
+		fmt.Fprintf(b, "- %s\n", line)
+	}
+	b.WriteString("\n")
+}
+
+// writePlaybookSteps writes each approach step, with per-step statistics when requested.
+func writePlaybookSteps(b *strings.Builder, method *Method, executions []*ExecutionResult, includeStats bool) {
+	b.WriteString("## Steps\n\n")
+	if len(method.Approach) == 0 {
+		b.WriteString("_No approach steps defined._\n\n")
+		return
+	}
+
+	var stats map[int]*playbookStepStats
+	if includeStats {
+		stats = collectStepStats(executions)
+	}
+
+	for i, step := range method.Approach {
+		fmt.Fprintf(b, "### Step %d: %s\n\n", i+1, step.Description)
+
+		if len(step.Tools) > 0 {
+			fmt.Fprintf(b, "- **Tools:** %s\n", strings.Join(step.Tools, ", "))
+		}
+		if len(step.Heuristics) > 0 {
+			b.WriteString("- **Heuristics:**\n")
+			for _, heuristic := range step.Heuristics {
+				fmt.Fprintf(b, "  - %s\n", heuristic)
+			}
+		}
+		if len(step.Conditions) > 0 {
+			fmt.Fprintf(b, "- **Conditions:** %s\n", formatPlaybookConditions(step.Conditions))
+		}
+
+		if includeStats {
+			stepStats := stats[i]
+			if stepStats == nil || stepStats.runs == 0 {
+				b.WriteString("- **Performance:** no recorded runs\n")
+			} else {
+				fmt.Fprintf(b, "- **Performance:** %d runs, %.0f%% success, avg %d tokens, avg %s\n",
+					stepStats.runs, stepStats.successRate()*100,
+					stepStats.totalTokens/stepStats.runs,
+					formatPlaybookDuration(stepStats.totalDuration/time.Duration(stepStats.runs)))
+				if stepStats.runs >= playbookMinStepSamples && stepStats.successRate() < playbookWeakStepRate {
+					b.WriteString("- **Weak step:** succeeds less often than the rest of the method; review its heuristics\n")
+				}
+			}
+		}
+		b.WriteString("\n")
+	}
+}
+
+// collectStepStats aggregates task outcomes by the method step they implemented.
+func collectStepStats(executions []*ExecutionResult) map[int]*playbookStepStats {
+	stats := make(map[int]*playbookStepStats)
+	for _, execution := range executions {
+		for _, task := range execution.TaskResults {
+			if task.MethodStepIndex < 0 {
+				continue
+			}
+			if task.Status != TaskStatusCompleted && task.Status != TaskStatusFailed {
+				continue
+			}
+
+			stepStats, ok := stats[task.MethodStepIndex]
+			if !ok {
+				stepStats = &playbookStepStats{}
+				stats[task.MethodStepIndex] = stepStats
+			}
+			stepStats.runs++
+			if task.Status == TaskStatusCompleted {
+				stepStats.successes++
+			}
+			stepStats.totalTokens += task.TokensUsed
+			stepStats.totalDuration += task.Duration
+		}
+	}
+	return stats
+}
+
+// writePlaybookPerformance writes the method's overall success metrics.
+func writePlaybookPerformance(b *strings.Builder, method *Method) {
+	b.WriteString("## Performance\n\n")
+	fmt.Fprintf(b, "- **Executions:** %d\n", method.Metrics.ExecutionCount)
+	fmt.Fprintf(b, "- **Success rate:** %.0f%%\n", method.Metrics.SuccessRate())
+	fmt.Fprintf(b, "- **Average rating:** %.1f/10\n", method.Metrics.AverageRating)
+	if method.Metrics.ComparisonRuns > 0 {
+		fmt.Fprintf(b, "- **Comparisons won:** %d of %d\n", method.Metrics.ComparisonWins, method.Metrics.ComparisonRuns)
+	}
+	if method.Metrics.LastUsed.IsZero() {
+		b.WriteString("- **Last used:** never\n\n")
+	} else {
+		fmt.Fprintf(b, "- **Last used:** %s\n\n", method.Metrics.LastUsed.Format(playbookDateLayout))
+	}
+}
+
+// writePlaybookLineage writes each version from the original to the current
+// one, with the recorded reason for every evolution and the resulting step changes.
+func writePlaybookLineage(b *strings.Builder, lineage []playbookLineageEntry) {
+	b.WriteString("## Evolution Lineage\n\n")
+	if len(lineage) <= 1 {
+		b.WriteString("_This is the original version; it has not evolved._\n\n")
+		return
+	}
+
+	for i, entry := range lineage {
+		method := entry.method
+		fmt.Fprintf(b, "### Version %s: %s\n\n", method.Version, method.Name)
+		fmt.Fprintf(b, "- **Method ID:** `%s`\n", method.ID)
+		fmt.Fprintf(b, "- **Created:** %s\n", method.CreatedAt.Format(playbookDateLayout))
+
+		if i == 0 {
+			b.WriteString("- **Origin:** original version\n\n")
+			continue
+		}
+
+		reason := entry.reason
+		if reason == "" {
+			reason = "no reason recorded"
+		}
+		fmt.Fprintf(b, "- **Why it changed:** %s\n", reason)
+
+		changes := diffApproachSteps(lineage[i-1].method.Approach, method.Approach)
+		if len(changes) == 0 {
+			b.WriteString("- **What changed:** no step changes\n\n")
+			continue
+		}
+		b.WriteString("- **What changed:**\n")
+		for _, change := range changes {
+			fmt.Fprintf(b, "  - %s\n", change)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// diffApproachSteps describes how the steps changed between two versions.
+// Steps are matched by description, so reordering does not show as a change.
+func diffApproachSteps(previous, current []ApproachStep) []string {
+	previousSteps := make(map[string]ApproachStep)
+	for _, step := range previous {
+		previousSteps[step.Description] = step
+	}
+	currentSteps := make(map[string]bool)
+	for _, step := range current {
+		currentSteps[step.Description] = true
+	}
+
+	var changes []string
+	for i, step := range current {
+		old, existed := previousSteps[step.Description]
+		if !existed {
+			changes = append(changes, fmt.Sprintf("Added step %d: %s", i+1, step.Description))
+			continue
+		}
+		if !equalStrings(old.Tools, step.Tools) {
+			changes = append(changes, fmt.Sprintf("Step %d tools changed from [%s] to [%s]",
+				i+1, strings.Join(old.Tools, ", "), strings.Join(step.Tools, ", ")))
+		}
+		if !equalStrings(old.Heuristics, step.Heuristics) {
+			changes = append(changes, fmt.Sprintf("Step %d heuristics revised", i+1))
+		}
+	}
+	for _, step := range previous {
+		if !currentSteps[step.Description] {
+			changes = append(changes, fmt.Sprintf("Removed step: %s", step.Description))
+		}
+	}
+	return changes
+}
+
+// writePlaybookExecutions writes a table of the most recent execution outcomes.
+func writePlaybookExecutions(b *strings.Builder, executions []*ExecutionResult, limit int) {
+	b.WriteString("## Recent Executions\n\n")
+	if len(executions) == 0 {
+		b.WriteString("_No recorded executions._\n\n")
+		return
+	}
+
+	if limit > 0 && len(executions) > limit {
+		executions = executions[:limit]
+	}
+
+	b.WriteString("| Date | Status | Tasks | Tokens | Duration | Notes |\n")
+	b.WriteString("|------|--------|-------|--------|----------|-------|\n")
+	for _, execution := range executions {
+		notes := strings.ReplaceAll(execution.ErrorMessage, "|", "/")
+		fmt.Fprintf(b, "| %s | %s | %d/%d | %d | %s | %s |\n",
+			execution.StartTime.Format(playbookDateLayout),
+			execution.Status,
+			execution.SuccessfulTasks, len(execution.TaskResults),
+			execution.TotalTokensUsed,
+			formatPlaybookDuration(execution.TotalDuration),
+			notes)
+	}
+	b.WriteString("\n")
+}
+
+// generatePlaybookNarrative asks an LLM to turn the mechanical playbook into prose.
+func generatePlaybookNarrative(ctx context.Context, method *Method, document string, opts PlaybookOptions) (string, float64, error) {
+	maxTokens := opts.NarrativeMaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultPlaybookOptions().NarrativeMaxTokens
+	}
+	budget := opts.NarrativeBudget
+	if budget <= 0 {
+		budget = DefaultPlaybookOptions().NarrativeBudget
+	}
+
+	prompt := fmt.Sprintf(`Summarize the playbook below for the method "%s" in a few short paragraphs of plain prose.
+Explain what the method is for, how its steps fit together, which steps are weak, and what its evolution has taught.
+Use only facts from the playbook. Do not add steps, tools, or numbers that are not listed.
+
+%s`, method.Name, document)
+
+	result, err := opts.Router.Route(ctx, llm.TaskRequest{
+		Prompt:           prompt,
+		MaxTokens:        maxTokens,
+		Temperature:      0.3,
+		TaskType:         PlaybookTaskType,
+		QualityRequired:  llm.QualityStandard,
+		BudgetConstraint: &budget,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("LLM routing failed: %w", err)
+	}
+	if result.ExecutionResult == nil {
+		return "", 0, fmt.Errorf("no result from LLM execution")
+	}
+
+	completion := result.ExecutionResult
+	if opts.Budget != nil {
+		if err := opts.Budget.RecordUsage(ctx, llm.Transaction{
+			Provider:   result.SelectedModel.Provider,
+			Model:      result.SelectedModel.Model,
+			TaskType:   PlaybookTaskType,
+			TokensUsed: completion.TokensUsed,
+			Cost:       completion.Cost,
+			Success:    true,
+		}); err != nil {
+			return "", 0, fmt.Errorf("failed to record narrative cost: %w", err)
+		}
+	}
+
+	return strings.TrimSpace(completion.Text), completion.Cost, nil
+}
+
+// formatPlaybookConditions renders step conditions as sorted key = value pairs.
+func formatPlaybookConditions(conditions map[string]interface{}) string {
+	keys := make([]string, 0, len(conditions))
+	for key := range conditions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s = %v", key, conditions[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatPlaybookDuration rounds a duration for display.
+func formatPlaybookDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// equalStrings reports whether two string slices hold the same values in order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+var updatePlaybookGolden = flag.Bool("update-playbook", false, "rewrite the method playbook golden file")
+
+// playbookFixture is a method that evolved twice, with recorded executions.
+type playbookFixture struct {
+	original *Method
+	refined  *Method
+	current  *Method
+}
+
+func setupPlaybookFixture(t *testing.T, store *storage.Store) playbookFixture {
+	t.Helper()
+	mm := NewMethodManager(store)
+	ctx := context.Background()
+
+	original, err := mm.CreateMethod(ctx, "Weekly report", "Compile the weekly status report from tracked work.",
+		[]ApproachStep{
+			{Description: "Collect completed objectives", Tools: []string{"storage_query"}},
+			{Description: "Draft the report", Tools: []string{"llm_complete"}, Heuristics: []string{"Lead with outcomes"}},
+		},
+		MethodDomainUser, map[string]interface{}{
+			"preconditions": []interface{}{"At least one objective completed this week"},
+		})
+	if err != nil {
+		t.Fatalf("Failed to create original method: %v", err)
+	}
+
+	refined := &Method{
+		Name:        "Weekly report",
+		Description: original.Description,
+		Approach: []ApproachStep{
+			{Description: "Collect completed objectives", Tools: []string{"storage_query", "calendar"}},
+			{Description: "Draft the report", Tools: []string{"llm_complete"}, Heuristics: []string{"Lead with outcomes"}},
+			{Description: "Ask for review", Conditions: map[string]interface{}{"audience": "manager"}},
+		},
+		Domain:      MethodDomainUser,
+		Version:     "1.1.0",
+		Status:      MethodStatusActive,
+		UserContext: original.UserContext,
+		CreatedAt:   time.Now(),
+		store:       store,
+	}
+	if err := mm.CreateMethodEvolution(ctx, original.ID, refined, "Refined due to: meetings were missing from reports"); err != nil {
+		t.Fatalf("Failed to create refined method: %v", err)
+	}
+
+	current := &Method{
+		Name:        "Weekly report (concise)",
+		Description: original.Description,
+		Approach: []ApproachStep{
+			{Description: "Collect completed objectives", Tools: []string{"storage_query", "calendar"}},
+			{Description: "Draft the report", Tools: []string{"llm_complete"}, Heuristics: []string{"Lead with outcomes", "Keep it under 200 words"}},
+			{Description: "Ask for review", Conditions: map[string]interface{}{"audience": "manager"}},
+		},
+		Domain:      MethodDomainUser,
+		Version:     "2.0.0",
+		Status:      MethodStatusActive,
+		UserContext: original.UserContext,
+		CreatedAt:   time.Now(),
+		store:       store,
+	}
+	if err := mm.CreateMethodEvolution(ctx, refined.ID, current, "Replaced due to: reports were too long to read"); err != nil {
+		t.Fatalf("Failed to create current method: %v", err)
+	}
+
+	if err := mm.UpdateMethodMetrics(ctx, current.ID, true, 8); err != nil {
+		t.Fatalf("Failed to update metrics: %v", err)
+	}
+	if err := mm.UpdateMethodMetrics(ctx, current.ID, false, 4); err != nil {
+		t.Fatalf("Failed to update metrics: %v", err)
+	}
+
+	rtc := NewRealTimeCursor(store, nil, nil)
+	day := func(d int) time.Time {
+		return time.Date(2024, time.March, d, 9, 0, 0, 0, time.UTC)
+	}
+	task := func(step int, status TaskStatus, tokens int, seconds int) *TaskResult {
+		return &TaskResult{
+			MethodStepIndex: step,
+			Status:          status,
+			TokensUsed:      tokens,
+			Duration:        time.Duration(seconds) * time.Second,
+		}
+	}
+	results := []*ExecutionResult{
+		{
+			PlanID: "plan-1", MethodID: current.ID, Status: ExecutionStatusCompleted,
+			StartTime: day(4), EndTime: day(4).Add(30 * time.Second), TotalDuration: 30 * time.Second,
+			TotalTokensUsed: 300, SuccessfulTasks: 2,
+			TaskResults: map[string]*TaskResult{
+				"collect": task(0, TaskStatusCompleted, 100, 10),
+				"draft":   task(1, TaskStatusCompleted, 200, 20),
+			},
+		},
+		{
+			PlanID: "plan-2", MethodID: current.ID, Status: ExecutionStatusPartial,
+			StartTime: day(5), EndTime: day(5).Add(40 * time.Second), TotalDuration: 40 * time.Second,
+			TotalTokensUsed: 400, SuccessfulTasks: 1, FailedTasks: 1,
+			ErrorMessage: "draft exceeded | word limit",
+			TaskResults: map[string]*TaskResult{
+				"collect": task(0, TaskStatusCompleted, 100, 10),
+				"draft":   task(1, TaskStatusFailed, 300, 30),
+			},
+		},
+		{
+			PlanID: "plan-3", MethodID: current.ID, Status: ExecutionStatusFailed,
+			StartTime: day(6), EndTime: day(6).Add(25 * time.Second), TotalDuration: 25 * time.Second,
+			TotalTokensUsed: 250, SuccessfulTasks: 1, FailedTasks: 1,
+			ErrorMessage: "Critical task draft failed",
+			TaskResults: map[string]*TaskResult{
+				"collect": task(0, TaskStatusCompleted, 100, 5),
+				"draft":   task(1, TaskStatusFailed, 150, 20),
+			},
+		},
+		{
+			// Still running, so it must not appear in the playbook
+			PlanID: "plan-4", MethodID: current.ID, Status: ExecutionStatusRunning,
+			StartTime: day(7), TaskResults: map[string]*TaskResult{},
+		},
+		{
+			// Belongs to the original version, so it must not appear either
+			PlanID: "plan-0", MethodID: original.ID, Status: ExecutionStatusCompleted,
+			StartTime: day(1), TaskResults: map[string]*TaskResult{},
+		},
+	}
+	for _, result := range results {
+		if err := rtc.storeExecutionResult(ctx, result); err != nil {
+			t.Fatalf("Failed to store execution result: %v", err)
+		}
+	}
+
+	return playbookFixture{original: original, refined: refined, current: current}
+}
+
+// normalizePlaybook replaces generated IDs and today's date with stable placeholders.
+func normalizePlaybook(markdown string, fixture playbookFixture) string {
+	replacer := strings.NewReplacer(
+		fixture.original.ID, "<original-id>",
+		fixture.refined.ID, "<refined-id>",
+		fixture.current.ID, "<current-id>",
+		time.Now().Format(playbookDateLayout), "<today>",
+	)
+	return replacer.Replace(markdown)
+}
+
+func TestMethodManager_GeneratePlaybookGolden(t *testing.T) {
+	store := setupTestStore(t)
+	mm := NewMethodManager(store)
+	fixture := setupPlaybookFixture(t, store)
+
+	playbook, err := mm.GeneratePlaybook(context.Background(), fixture.current.ID, DefaultPlaybookOptions())
+	if err != nil {
+		t.Fatalf("Failed to generate playbook: %v", err)
+	}
+
+	var out bytes.Buffer
+	if _, err := playbook.WriteTo(&out); err != nil {
+		t.Fatalf("Failed to write playbook: %v", err)
+	}
+	got := normalizePlaybook(out.String(), fixture)
+
+	goldenPath := filepath.Join("testdata", "method_playbook.golden.md")
+	if *updatePlaybookGolden {
+		if err := os.WriteFile(goldenPath, []byte(got), 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("Playbook does not match %s (run with -update-playbook to refresh)\n--- got ---\n%s", goldenPath, got)
+	}
+}
+
+func TestMethodManager_GeneratePlaybookOptions(t *testing.T) {
+	store := setupTestStore(t)
+	mm := NewMethodManager(store)
+	ctx := context.Background()
+	fixture := setupPlaybookFixture(t, store)
+
+	playbook, err := mm.GeneratePlaybook(ctx, fixture.current.ID, PlaybookOptions{})
+	if err != nil {
+		t.Fatalf("Failed to generate playbook: %v", err)
+	}
+	for _, section := range []string{"## Purpose", "## Preconditions", "## Steps"} {
+		if !strings.Contains(playbook.Markdown, section) {
+			t.Errorf("Expected section %q in minimal playbook", section)
+		}
+	}
+	for _, section := range []string{"## Performance", "## Evolution Lineage", "## Recent Executions", "**Performance:**"} {
+		if strings.Contains(playbook.Markdown, section) {
+			t.Errorf("Did not expect %q in minimal playbook", section)
+		}
+	}
+
+	// The original version has no lineage to show
+	opts := DefaultPlaybookOptions()
+	playbook, err = mm.GeneratePlaybook(ctx, fixture.original.ID, opts)
+	if err != nil {
+		t.Fatalf("Failed to generate playbook: %v", err)
+	}
+	if !strings.Contains(playbook.Markdown, "_This is the original version; it has not evolved._") {
+		t.Errorf("Expected original version note, got:\n%s", playbook.Markdown)
+	}
+
+	// A narrative needs a router
+	opts.UseLLMNarrative = true
+	if _, err := mm.GeneratePlaybook(ctx, fixture.current.ID, opts); err == nil {
+		t.Error("Expected error when requesting a narrative without a router")
+	}
+
+	if _, err := mm.GeneratePlaybook(ctx, "missing-method", DefaultPlaybookOptions()); err == nil {
+		t.Error("Expected error for a missing method")
+	}
+}
+
+func TestExecutionResultRoundTrip_MethodFields(t *testing.T) {
+	store := setupTestStore(t)
+	rtc := NewRealTimeCursor(store, nil, nil)
+	ctx := context.Background()
+
+	result := &ExecutionResult{
+		PlanID:          "plan-1",
+		MethodID:        "method-1",
+		Status:          ExecutionStatusCompleted,
+		TotalTokensUsed: 42,
+		TaskResults: map[string]*TaskResult{
+			"task-1": {MethodStepIndex: 2, Status: TaskStatusCompleted, TokensUsed: 42},
+		},
+	}
+	if err := rtc.storeExecutionResult(ctx, result); err != nil {
+		t.Fatalf("Failed to store execution result: %v", err)
+	}
+
+	history, err := rtc.GetExecutionHistory(ctx, 0)
+	if err != nil {
+		t.Fatalf("Failed to get execution history: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(history))
+	}
+
+	loaded := history[0]
+	if loaded.MethodID != "method-1" {
+		t.Errorf("Expected method ID method-1, got %q", loaded.MethodID)
+	}
+	if loaded.TotalTokensUsed != 42 {
+		t.Errorf("Expected 42 tokens, got %d", loaded.TotalTokensUsed)
+	}
+	if task := loaded.TaskResults["task-1"]; task == nil || task.MethodStepIndex != 2 {
+		t.Errorf("Expected task with step index 2, got %+v", task)
+	}
+}
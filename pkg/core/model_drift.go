@@ -0,0 +1,392 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+const (
+	// DriftMetricSuccess is the share of requests that succeeded
+	DriftMetricSuccess = "success rate"
+
+	// DriftMetricRating is the average 1-10 quality rating
+	DriftMetricRating = "rating"
+
+	// DriftMetricTokens is the average token count; a jump either way suggests
+	// the model now answers differently
+	DriftMetricTokens = "tokens"
+
+	// driftMinMetricSamples is how many values a metric needs on each side of
+	// the comparison before it is tested
+	driftMinMetricSamples = 5
+
+	// driftMinTokenChange is the smallest relative change in average tokens
+	// that counts, since answer length varies a little with every prompt
+	driftMinTokenChange = 0.25
+)
+
+// DriftConfig configures model drift detection.
+type DriftConfig struct {
+	// Window is how many of a model's most recent requests are compared
+	// against its baseline
+	Window int
+
+	// MinBaseline is how many earlier requests a model needs before it is checked
+	MinBaseline int
+
+	// Threshold is the z-score a degradation must exceed to count as drift.
+	// Lower values are more sensitive. A drifting model recovers once every
+	// metric is back under half the threshold.
+	Threshold float64
+
+	// Notifier receives an alert when a model starts drifting
+	Notifier Notifier
+}
+
+// DefaultDriftConfig returns the default drift detection settings.
+func DefaultDriftConfig() DriftConfig {
+	return DriftConfig{
+		Window:      20,
+		MinBaseline: 40,
+		Threshold:   3.0,
+	}
+}
+
+// DriftSignal compares one metric between a model's baseline and its recent window.
+type DriftSignal struct {
+	Metric   string
+	Baseline float64
+	Recent   float64
+
+	// Score is the z-score of the degradation; higher means worse
+	Score float64
+}
+
+// ModelDrift describes the drift check for one model on one task type.
+type ModelDrift struct {
+	Provider string
+	Model    string
+	TaskType string
+	Signals  []DriftSignal
+
+	// Alternative is the best ranked other model for the task type, if any
+	Alternative *llm.ModelRecommendation
+}
+
+// Degraded returns the signals whose score exceeds threshold.
+func (md *ModelDrift) Degraded(threshold float64) []DriftSignal {
+	var degraded []DriftSignal
+	for _, signal := range md.Signals {
+		if signal.Score > threshold {
+			degraded = append(degraded, signal)
+		}
+	}
+	return degraded
+}
+
+// DriftReport summarizes one drift check.
+type DriftReport struct {
+	// Checked is how many model and task type combinations had enough history
+	Checked int
+
+	// Detected lists models that started drifting in this check
+	Detected []*ModelDrift
+
+	// Recovered lists models whose drift flag was cleared in this check
+	Recovered []*ModelDrift
+}
+
+// DriftDetector watches the persisted request history for models whose
+// results have quietly degraded, e.g. because a provider changed the model
+// behind the same name. It flags them on the router and alerts the user.
+type DriftDetector struct {
+	budget *llm.BudgetManager
+	router *llm.Router
+	config DriftConfig
+}
+
+// NewDriftDetector creates a drift detector reading history from budget's
+// transaction log and flagging drift on router.
+func NewDriftDetector(budget *llm.BudgetManager, router *llm.Router, config ...DriftConfig) *DriftDetector {
+	cfg := DefaultDriftConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	defaults := DefaultDriftConfig()
+	if cfg.Window <= 0 {
+		cfg.Window = defaults.Window
+	}
+	if cfg.MinBaseline <= 0 {
+		cfg.MinBaseline = defaults.MinBaseline
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaults.Threshold
+	}
+
+	return &DriftDetector{
+		budget: budget,
+		router: router,
+		config: cfg,
+	}
+}
+
+// Check compares each model's recent window against its baseline, flags
+// newly drifting models, clears recovered ones and alerts about new drift.
+func (dd *DriftDetector) Check(ctx context.Context) (*DriftReport, error) {
+	if dd.budget == nil || dd.router == nil {
+		return nil, fmt.Errorf("drift detection needs a budget manager and a router")
+	}
+
+	flagged := make(map[string]bool)
+	for _, perf := range dd.router.GetPerformanceStats() {
+		if perf.Drifting {
+			flagged[driftKey(perf.Provider, perf.Model, perf.TaskType)] = true
+		}
+	}
+
+	report := &DriftReport{}
+	for _, history := range groupDriftHistory(dd.budget.GetTransactions()) {
+		if len(history) < dd.config.Window+dd.config.MinBaseline {
+			continue
+		}
+		report.Checked++
+
+		first := history[0]
+		key := driftKey(first.Provider, first.Model, first.TaskType)
+		split := len(history) - dd.config.Window
+		drift := &ModelDrift{
+			Provider: first.Provider,
+			Model:    first.Model,
+			TaskType: first.TaskType,
+			Signals:  compareDriftWindows(history[:split], history[split:]),
+		}
+
+		switch {
+		case !flagged[key] && len(drift.Degraded(dd.config.Threshold)) > 0:
+			dd.router.SetDrift(drift.Provider, drift.Model, drift.TaskType, true)
+			report.Detected = append(report.Detected, drift)
+		case flagged[key] && len(drift.Degraded(dd.config.Threshold/2)) == 0:
+			dd.router.SetDrift(drift.Provider, drift.Model, drift.TaskType, false)
+			report.Recovered = append(report.Recovered, drift)
+		}
+	}
+
+	// Alternatives are ranked after flagging so other drifting models lose out
+	for _, drift := range report.Detected {
+		drift.Alternative = dd.bestAlternative(drift)
+	}
+	dd.alert(ctx, report.Detected)
+
+	return report, nil
+}
+
+// bestAlternative returns the best ranked model for the drifting model's
+// task type, skipping the model itself.
+func (dd *DriftDetector) bestAlternative(drift *ModelDrift) *llm.ModelRecommendation {
+	for _, rec := range dd.router.RankModels(drift.TaskType) {
+		if rec.Provider == drift.Provider && rec.Model == drift.Model {
+			continue
+		}
+		alternative := rec
+		return &alternative
+	}
+	return nil
+}
+
+// alert sends one notification per newly drifting model, covering all its
+// affected task types, falling back to stderr so drift is never silent.
+func (dd *DriftDetector) alert(ctx context.Context, detected []*ModelDrift) {
+	var order []string
+	byModel := make(map[string][]*ModelDrift)
+	for _, drift := range detected {
+		name := drift.Provider + "/" + drift.Model
+		if _, seen := byModel[name]; !seen {
+			order = append(order, name)
+		}
+		byModel[name] = append(byModel[name], drift)
+	}
+
+	for _, name := range order {
+		title := "Model drift detected: " + name
+		var lines []string
+		for _, drift := range byModel[name] {
+			lines = append(lines, dd.describeDrift(drift))
+		}
+		message := strings.Join(lines, "\n")
+
+		if dd.config.Notifier != nil {
+			if err := dd.config.Notifier.Notify(ctx, title, message); err == nil {
+				continue
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: failed to deliver drift alert: %v\n", err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s\n", title, message)
+	}
+}
+
+// describeDrift summarizes one task type's degraded metrics and the suggested alternative.
+func (dd *DriftDetector) describeDrift(drift *ModelDrift) string {
+	var changes []string
+	for _, signal := range drift.Degraded(dd.config.Threshold) {
+		if signal.Metric == DriftMetricSuccess {
+			changes = append(changes, fmt.Sprintf("%s %.0f%% -> %.0f%%", signal.Metric, signal.Baseline*100, signal.Recent*100))
+		} else {
+			changes = append(changes, fmt.Sprintf("%s %.1f -> %.1f", signal.Metric, signal.Baseline, signal.Recent))
+		}
+	}
+
+	line := fmt.Sprintf("%s: %s over the last %d requests", driftTaskLabel(drift.TaskType), strings.Join(changes, ", "), dd.config.Window)
+	if drift.Alternative != nil {
+		line += fmt.Sprintf("; best alternative now %s/%s", drift.Alternative.Provider, drift.Alternative.Model)
+	}
+	return line
+}
+
+// groupDriftHistory splits transactions by provider, model and task type,
+// each group in time order.
+func groupDriftHistory(transactions []llm.Transaction) [][]llm.Transaction {
+	var order []string
+	groups := make(map[string][]llm.Transaction)
+	for _, tx := range transactions {
+		if tx.Model == "" {
+			continue
+		}
+		key := driftKey(tx.Provider, tx.Model, tx.TaskType)
+		if _, seen := groups[key]; !seen {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], tx)
+	}
+
+	histories := make([][]llm.Transaction, 0, len(order))
+	for _, key := range order {
+		history := groups[key]
+		sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp.Before(history[j].Timestamp) })
+		histories = append(histories, history)
+	}
+	return histories
+}
+
+// compareDriftWindows tests each metric of the recent window against the baseline.
+func compareDriftWindows(baseline, recent []llm.Transaction) []DriftSignal {
+	var signals []DriftSignal
+
+	// Success is a proportion, so it uses a pooled two-proportion test
+	baseOK, recentOK := driftSuccesses(baseline), driftSuccesses(recent)
+	pooled := (baseOK + recentOK) / float64(len(baseline)+len(recent))
+	stdErr := math.Sqrt(pooled * (1 - pooled) * (1/float64(len(baseline)) + 1/float64(len(recent))))
+	baseRate, recentRate := baseOK/float64(len(baseline)), recentOK/float64(len(recent))
+	signals = append(signals, DriftSignal{
+		Metric:   DriftMetricSuccess,
+		Baseline: baseRate,
+		Recent:   recentRate,
+		Score:    driftZScore(baseRate-recentRate, stdErr),
+	})
+
+	rating := func(tx llm.Transaction) (float64, bool) { return tx.Quality, tx.Quality > 0 }
+	if signal, ok := compareDriftMeans(DriftMetricRating, baseline, recent, rating); ok {
+		signals = append(signals, signal)
+	}
+
+	tokens := func(tx llm.Transaction) (float64, bool) { return float64(tx.TokensUsed), tx.TokensUsed > 0 }
+	if signal, ok := compareDriftMeans(DriftMetricTokens, baseline, recent, tokens); ok {
+		signal.Score = math.Abs(signal.Score)
+		if math.Abs(signal.Recent-signal.Baseline) < driftMinTokenChange*signal.Baseline {
+			signal.Score = 0
+		}
+		signals = append(signals, signal)
+	}
+
+	return signals
+}
+
+// compareDriftMeans tests whether a metric's recent mean fell below its
+// baseline mean, using Welch's z approximation. It reports false when
+// either side has too few values.
+func compareDriftMeans(metric string, baseline, recent []llm.Transaction, value func(llm.Transaction) (float64, bool)) (DriftSignal, bool) {
+	baseMean, baseVar, baseN := driftMoments(baseline, value)
+	recentMean, recentVar, recentN := driftMoments(recent, value)
+	if baseN < driftMinMetricSamples || recentN < driftMinMetricSamples {
+		return DriftSignal{}, false
+	}
+
+	stdErr := math.Sqrt(baseVar/float64(baseN) + recentVar/float64(recentN))
+	return DriftSignal{
+		Metric:   metric,
+		Baseline: baseMean,
+		Recent:   recentMean,
+		Score:    driftZScore(baseMean-recentMean, stdErr),
+	}, true
+}
+
+// driftMoments returns the mean, sample variance and count of a metric.
+func driftMoments(transactions []llm.Transaction, value func(llm.Transaction) (float64, bool)) (float64, float64, int) {
+	var values []float64
+	var sum float64
+	for _, tx := range transactions {
+		if v, ok := value(tx); ok {
+			values = append(values, v)
+			sum += v
+		}
+	}
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+
+	mean := sum / float64(len(values))
+	if len(values) == 1 {
+		return mean, 0, 1
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, squares / float64(len(values)-1), len(values)
+}
+
+// driftZScore divides a difference by its standard error. With no variance
+// at all, any difference is as significant as it gets.
+func driftZScore(diff, stdErr float64) float64 {
+	if stdErr > 0 {
+		return diff / stdErr
+	}
+	switch {
+	case diff > 0:
+		return math.Inf(1)
+	case diff < 0:
+		return math.Inf(-1)
+	default:
+		return 0
+	}
+}
+
+// driftSuccesses counts successful transactions.
+func driftSuccesses(transactions []llm.Transaction) float64 {
+	var count float64
+	for _, tx := range transactions {
+		if tx.Success {
+			count++
+		}
+	}
+	return count
+}
+
+// driftKey identifies a model on a task type.
+func driftKey(provider, model, taskType string) string {
+	return provider + "/" + model + "/" + taskType
+}
+
+// driftTaskLabel names a task type in alerts.
+func driftTaskLabel(taskType string) string {
+	if taskType == "" {
+		return "untyped requests"
+	}
+	return taskType
+}
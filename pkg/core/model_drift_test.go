@@ -0,0 +1,298 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+// driftSample is one synthetic request in a model's history.
+type driftSample struct {
+	success bool
+	rating  float64
+	tokens  int
+}
+
+// newDriftBudget creates a budget manager that keeps a transaction log.
+func newDriftBudget(t *testing.T) *llm.BudgetManager {
+	budget, err := llm.NewBudgetManager(t.TempDir(), llm.BudgetConfig{TrackingEnabled: true}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	return budget
+}
+
+// recordDriftHistory appends samples for a model on a task type, a minute apart.
+func recordDriftHistory(t *testing.T, budget *llm.BudgetManager, start time.Time, provider, model, taskType string, samples []driftSample) time.Time {
+	ctx := context.Background()
+	for i, sample := range samples {
+		at := start.Add(time.Duration(i) * time.Minute)
+		if err := budget.RecordUsage(ctx, llm.Transaction{
+			ID:         at.Format(time.RFC3339Nano) + model + taskType,
+			Timestamp:  at,
+			Provider:   provider,
+			Model:      model,
+			TaskType:   taskType,
+			TokensUsed: sample.tokens,
+			Cost:       0.001,
+			Success:    sample.success,
+			Quality:    sample.rating,
+		}); err != nil {
+			t.Fatalf("Failed to record usage: %v", err)
+		}
+	}
+	return start.Add(time.Duration(len(samples)) * time.Minute)
+}
+
+// stableDriftSamples returns a healthy, slightly noisy history.
+func stableDriftSamples(n int) []driftSample {
+	samples := make([]driftSample, n)
+	for i := range samples {
+		samples[i] = driftSample{
+			success: i%25 != 7, // 96% success
+			rating:  7.5 + float64(i%3)*0.5,
+			tokens:  400 + (i%5)*20,
+		}
+	}
+	return samples
+}
+
+// recordingNotifier keeps the alerts it receives.
+type recordingNotifier struct {
+	titles   []string
+	messages []string
+}
+
+func (rn *recordingNotifier) Notify(ctx context.Context, title, message string) error {
+	rn.titles = append(rn.titles, title)
+	rn.messages = append(rn.messages, message)
+	return nil
+}
+
+func isDrifting(router *llm.Router, provider, model, taskType string) bool {
+	perf := router.GetPerformanceStats()[provider+"_"+model+"_"+taskType]
+	return perf != nil && perf.Drifting
+}
+
+func TestDriftDetector_StepChange(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		recent func(i int) driftSample
+		metric string
+	}{
+		{
+			name:   "success drops",
+			recent: func(i int) driftSample { return driftSample{success: i%2 == 0, rating: 8, tokens: 400 + (i%5)*20} },
+			metric: DriftMetricSuccess,
+		},
+		{
+			name:   "ratings drop",
+			recent: func(i int) driftSample { return driftSample{success: true, rating: 5 + float64(i%2), tokens: 400 + (i%5)*20} },
+			metric: DriftMetricRating,
+		},
+		{
+			name:   "answers get much longer",
+			recent: func(i int) driftSample { return driftSample{success: true, rating: 8, tokens: 1500 + i*10} },
+			metric: DriftMetricTokens,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := newDriftBudget(t)
+			router := llm.NewRouter(nil)
+			notifier := &recordingNotifier{}
+			config := DefaultDriftConfig()
+			config.Notifier = notifier
+			detector := NewDriftDetector(budget, router, config)
+
+			next := recordDriftHistory(t, budget, start, "anthropic", "claude-3-haiku", "analysis", stableDriftSamples(60))
+			// A healthy model on another task type must not be flagged
+			recordDriftHistory(t, budget, start, "anthropic", "claude-3-haiku", "format", stableDriftSamples(80))
+
+			report, err := detector.Check(ctx)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if len(report.Detected) != 0 || report.Checked != 2 {
+				t.Fatalf("Expected two healthy histories, got %+v", report)
+			}
+
+			recent := make([]driftSample, config.Window)
+			for i := range recent {
+				recent[i] = tt.recent(i)
+			}
+			recordDriftHistory(t, budget, next, "anthropic", "claude-3-haiku", "analysis", recent)
+
+			report, err = detector.Check(ctx)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if len(report.Detected) != 1 {
+				t.Fatalf("Expected one drifting model, got %+v", report.Detected)
+			}
+			drift := report.Detected[0]
+			degraded := drift.Degraded(config.Threshold)
+			if len(degraded) != 1 || degraded[0].Metric != tt.metric {
+				t.Errorf("Expected only %s to degrade, got %+v", tt.metric, degraded)
+			}
+			if drift.Alternative == nil || drift.Alternative.Model == "claude-3-haiku" {
+				t.Errorf("Expected another model suggested, got %+v", drift.Alternative)
+			}
+
+			if !isDrifting(router, "anthropic", "claude-3-haiku", "analysis") || isDrifting(router, "anthropic", "claude-3-haiku", "format") {
+				t.Error("Expected only the analysis history to be flagged")
+			}
+
+			if len(notifier.titles) != 1 || !strings.Contains(notifier.titles[0], "anthropic/claude-3-haiku") {
+				t.Fatalf("Expected one alert for the model, got %v", notifier.titles)
+			}
+			if !strings.Contains(notifier.messages[0], "analysis: "+tt.metric) ||
+				!strings.Contains(notifier.messages[0], "best alternative now "+drift.Alternative.Provider+"/"+drift.Alternative.Model) {
+				t.Errorf("Unexpected alert message: %s", notifier.messages[0])
+			}
+
+			// Still drifting on the next check: flagged already, so no new alert
+			report, err = detector.Check(ctx)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if len(report.Detected) != 0 || len(notifier.titles) != 1 {
+				t.Errorf("Expected no repeated alert, got %d detected and %d alerts", len(report.Detected), len(notifier.titles))
+			}
+		})
+	}
+}
+
+func TestDriftDetector_GradualDrift(t *testing.T) {
+	ctx := context.Background()
+	budget := newDriftBudget(t)
+	router := llm.NewRouter(nil)
+	config := DefaultDriftConfig()
+	config.Notifier = &recordingNotifier{}
+	detector := NewDriftDetector(budget, router, config)
+
+	// Ratings slide from 8.5 to about 5.5 over the last 60 requests
+	samples := stableDriftSamples(100)
+	for i := 40; i < len(samples); i++ {
+		samples[i].rating -= float64(i-40) * 0.05
+	}
+	recordDriftHistory(t, budget, time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC), "openai", "gpt-4", "research", samples)
+
+	report, err := detector.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Detected) != 1 {
+		t.Fatalf("Expected the gradual drift to be detected, got %+v", report)
+	}
+	if degraded := report.Detected[0].Degraded(config.Threshold); len(degraded) != 1 || degraded[0].Metric != DriftMetricRating {
+		t.Errorf("Expected the rating to degrade, got %+v", degraded)
+	}
+
+	// A much less sensitive detector tolerates the same slide
+	budget = newDriftBudget(t)
+	recordDriftHistory(t, budget, time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC), "openai", "gpt-4", "research", samples)
+	config.Threshold = 50
+	report, err = NewDriftDetector(budget, llm.NewRouter(nil), config).Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Detected) != 0 {
+		t.Errorf("Expected no drift at a high threshold, got %+v", report.Detected)
+	}
+}
+
+func TestDriftDetector_RecoveryClearsFlag(t *testing.T) {
+	ctx := context.Background()
+	budget := newDriftBudget(t)
+	router := llm.NewRouter(nil)
+	notifier := &recordingNotifier{}
+	config := DefaultDriftConfig()
+	config.Notifier = notifier
+	detector := NewDriftDetector(budget, router, config)
+
+	degraded := make([]driftSample, config.Window)
+	for i := range degraded {
+		degraded[i] = driftSample{success: i%2 == 0, rating: 4, tokens: 420}
+	}
+	next := recordDriftHistory(t, budget, time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC), "anthropic", "claude-3-sonnet", "analysis", stableDriftSamples(60))
+	next = recordDriftHistory(t, budget, next, "anthropic", "claude-3-sonnet", "analysis", degraded)
+
+	if report, err := detector.Check(ctx); err != nil || len(report.Detected) != 1 {
+		t.Fatalf("Expected drift to be detected, got %+v, %v", report, err)
+	}
+
+	// Flagged, the model's learned quality counts for less
+	for i := 0; i < 5; i++ {
+		router.RecordPerformance("anthropic", "claude-3-sonnet", "analysis", 0.01, 10, time.Second, true)
+	}
+	drifting := sonnetQuality(router)
+	router.SetDrift("anthropic", "claude-3-sonnet", "analysis", false)
+	trusted := sonnetQuality(router)
+	router.SetDrift("anthropic", "claude-3-sonnet", "analysis", true)
+	if drifting >= trusted {
+		t.Errorf("Expected drift to pull the learned quality toward the prior, got %.3f drifting vs %.3f", drifting, trusted)
+	}
+
+	// The provider fixes the model: the window returns to its baseline
+	recordDriftHistory(t, budget, next, "anthropic", "claude-3-sonnet", "analysis", stableDriftSamples(config.Window))
+
+	report, err := detector.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Recovered) != 1 || len(report.Detected) != 0 {
+		t.Fatalf("Expected the model to recover, got %+v", report)
+	}
+	if isDrifting(router, "anthropic", "claude-3-sonnet", "analysis") {
+		t.Error("Expected the drift flag to be cleared")
+	}
+	if len(notifier.titles) != 1 {
+		t.Errorf("Expected recovery to clear silently, got %d alerts", len(notifier.titles))
+	}
+}
+
+func TestDriftDetector_NeedsHistory(t *testing.T) {
+	budget := newDriftBudget(t)
+	router := llm.NewRouter(nil)
+	config := DefaultDriftConfig()
+	config.Notifier = &recordingNotifier{}
+
+	// Too short to have a baseline, even though the recent results are poor
+	samples := make([]driftSample, config.Window+config.MinBaseline-1)
+	for i := range samples {
+		samples[i] = driftSample{success: i < 30, rating: 8}
+	}
+	recordDriftHistory(t, budget, time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC), "openai", "gpt-4", "analysis", samples)
+
+	report, err := NewDriftDetector(budget, router, config).Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if report.Checked != 0 || len(report.Detected) != 0 {
+		t.Errorf("Expected the short history to be skipped, got %+v", report)
+	}
+
+	if _, err := NewDriftDetector(nil, router).Check(context.Background()); err == nil {
+		t.Error("Expected an error without a budget manager")
+	}
+}
+
+// sonnetQuality returns claude-3-sonnet's quality score for analysis.
+func sonnetQuality(router *llm.Router) float64 {
+	for _, rec := range router.RankModels("analysis") {
+		if rec.Model == "claude-3-sonnet" {
+			return rec.QualityScore
+		}
+	}
+	return 0
+}
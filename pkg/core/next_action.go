@@ -0,0 +1,869 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+const (
+	// SuggestionTaskType is the task type of the suggestion tie-break request
+	SuggestionTaskType = "meta"
+
+	// dueDateContextKey holds an objective's due date in its context
+	dueDateContextKey = "due_date"
+
+	// duePressureHorizon is how far ahead a due date starts to add pressure
+	duePressureHorizon = 14 * 24 * time.Hour
+
+	// stalenessHorizon is how long a goal may go without work before it is fully stale
+	stalenessHorizon = 14 * 24 * time.Hour
+
+	// shortTaskScale is the estimated time at which a task counts as half short
+	shortTaskScale = 30 * time.Minute
+
+	// tokenCostScale is the average token use at which a method's value is halved
+	tokenCostScale = 10000.0
+
+	// tieBreakCount is how many top suggestions the tie-breaker may reorder
+	tieBreakCount = 3
+)
+
+// Suggestion factors, as named in weights and score breakdowns.
+const (
+	FactorPriority     = "priority"
+	FactorDuePressure  = "due"
+	FactorGoalPriority = "goal_priority"
+	FactorTimeFit      = "time_fit"
+	FactorStaleness    = "staleness"
+	FactorValueCost    = "value_cost"
+)
+
+// SuggestionFactors lists the factors in the order breakdowns show them.
+var SuggestionFactors = []string{FactorPriority, FactorDuePressure, FactorGoalPriority, FactorTimeFit, FactorStaleness, FactorValueCost}
+
+// EnergyLevel is how much focus the user has for the next piece of work.
+type EnergyLevel string
+
+const (
+	// EnergyLow favors short tasks over important ones
+	EnergyLow EnergyLevel = "low"
+
+	// EnergyNormal applies the weights as configured
+	EnergyNormal EnergyLevel = "normal"
+
+	// EnergyHigh favors important tasks and cares less whether they are long
+	EnergyHigh EnergyLevel = "high"
+)
+
+// ParseEnergyLevel parses an energy level name; empty means EnergyNormal.
+func ParseEnergyLevel(name string) (EnergyLevel, error) {
+	switch level := EnergyLevel(strings.ToLower(strings.TrimSpace(name))); level {
+	case "":
+		return EnergyNormal, nil
+	case EnergyLow, EnergyNormal, EnergyHigh:
+		return level, nil
+	default:
+		return "", errs.Newf(errs.Validation, "unknown energy level %q (want low, normal or high)", name)
+	}
+}
+
+// SuggestionWeights sets how much each factor counts towards a suggestion's
+// score. Only their proportions matter: scores are divided by the total.
+type SuggestionWeights struct {
+	// Priority favors objectives with a higher priority
+	Priority float64
+
+	// DuePressure favors objectives whose due date is near or past
+	DuePressure float64
+
+	// GoalPriority favors objectives of higher-priority goals
+	GoalPriority float64
+
+	// TimeFit favors objectives expected to fit the available time
+	TimeFit float64
+
+	// Staleness favors goals that have not been worked on for a while
+	Staleness float64
+
+	// ValueCost favors methods that succeed and are rated well for the
+	// tokens they use
+	ValueCost float64
+}
+
+// DefaultSuggestionWeights returns the default factor weights.
+func DefaultSuggestionWeights() SuggestionWeights {
+	return SuggestionWeights{
+		Priority:     0.25,
+		DuePressure:  0.2,
+		GoalPriority: 0.15,
+		TimeFit:      0.2,
+		Staleness:    0.1,
+		ValueCost:    0.1,
+	}
+}
+
+// Get returns the weight of a factor.
+func (w SuggestionWeights) Get(factor string) (float64, bool) {
+	switch factor {
+	case FactorPriority:
+		return w.Priority, true
+	case FactorDuePressure:
+		return w.DuePressure, true
+	case FactorGoalPriority:
+		return w.GoalPriority, true
+	case FactorTimeFit:
+		return w.TimeFit, true
+	case FactorStaleness:
+		return w.Staleness, true
+	case FactorValueCost:
+		return w.ValueCost, true
+	}
+	return 0, false
+}
+
+// With returns the weights with factor set to weight.
+func (w SuggestionWeights) With(factor string, weight float64) (SuggestionWeights, error) {
+	if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return w, errs.Newf(errs.Validation, "weight of %s must be a non-negative number, got %v", factor, weight)
+	}
+	switch factor {
+	case FactorPriority:
+		w.Priority = weight
+	case FactorDuePressure:
+		w.DuePressure = weight
+	case FactorGoalPriority:
+		w.GoalPriority = weight
+	case FactorTimeFit:
+		w.TimeFit = weight
+	case FactorStaleness:
+		w.Staleness = weight
+	case FactorValueCost:
+		w.ValueCost = weight
+	default:
+		return w, errs.Newf(errs.Validation, "unknown suggestion factor %q (want one of %s)", factor, strings.Join(SuggestionFactors, ", "))
+	}
+	return w, nil
+}
+
+// WithOverrides returns the weights with each factor in overrides replaced.
+func (w SuggestionWeights) WithOverrides(overrides map[string]float64) (SuggestionWeights, error) {
+	factors := make([]string, 0, len(overrides))
+	for factor := range overrides {
+		factors = append(factors, factor)
+	}
+	sort.Strings(factors)
+
+	var err error
+	for _, factor := range factors {
+		if w, err = w.With(factor, overrides[factor]); err != nil {
+			return w, err
+		}
+	}
+	return w, w.Validate()
+}
+
+// ParseSuggestionWeights applies a comma-separated list of factor=weight
+// pairs, e.g. "priority=0.4,due=0.1", to base.
+func ParseSuggestionWeights(base SuggestionWeights, spec string) (SuggestionWeights, error) {
+	overrides := make(map[string]float64)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		factor, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return base, errs.Newf(errs.Validation, "expected factor=weight, got %q", pair)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return base, errs.Newf(errs.Validation, "invalid weight for %s: %q", strings.TrimSpace(factor), value)
+		}
+		overrides[strings.TrimSpace(factor)] = weight
+	}
+	return base.WithOverrides(overrides)
+}
+
+// Map returns the weights by factor name, as stored in the configuration.
+func (w SuggestionWeights) Map() map[string]float64 {
+	weights := make(map[string]float64, len(SuggestionFactors))
+	for _, factor := range SuggestionFactors {
+		weights[factor], _ = w.Get(factor)
+	}
+	return weights
+}
+
+// Validate checks that the weights are non-negative and not all zero.
+func (w SuggestionWeights) Validate() error {
+	total := 0.0
+	for _, factor := range SuggestionFactors {
+		weight, _ := w.Get(factor)
+		if weight < 0 {
+			return errs.Newf(errs.Validation, "weight of %s cannot be negative", factor)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errs.New(errs.Validation, "at least one suggestion weight must be positive")
+	}
+	return nil
+}
+
+// forEnergy adjusts the weights for an energy level: low energy doubles the
+// weight of fitting the time and lowers that of priority, high energy raises
+// the priorities and halves the time fit.
+func (w SuggestionWeights) forEnergy(level EnergyLevel) SuggestionWeights {
+	switch level {
+	case EnergyLow:
+		w.TimeFit *= 2
+		w.Priority *= 0.75
+	case EnergyHigh:
+		w.Priority *= 1.25
+		w.GoalPriority *= 1.25
+		w.TimeFit *= 0.5
+	}
+	return w
+}
+
+// SetSuggestionWeights sets the factor weights SuggestNext uses by default.
+func (om *ObjectiveManager) SetSuggestionWeights(weights SuggestionWeights) {
+	om.suggestionWeights = weights
+}
+
+// SuggestionWeights returns the factor weights in effect.
+func (om *ObjectiveManager) SuggestionWeights() SuggestionWeights {
+	if om.suggestionWeights == (SuggestionWeights{}) {
+		return DefaultSuggestionWeights()
+	}
+	return om.suggestionWeights
+}
+
+// SuggestOptions configures SuggestNext.
+type SuggestOptions struct {
+	// MaxSuggestions caps how many suggestions are returned (0: all)
+	MaxSuggestions int
+
+	// AvailableTime is how long the user has; 0 means no limit is known
+	AvailableTime time.Duration
+
+	// EnergyLevel shifts the weights towards short or important work
+	EnergyLevel EnergyLevel
+
+	// Weights overrides the manager's weights, e.g. to preview a change
+	Weights *SuggestionWeights
+
+	// TieBreak asks an LLM to order the top three suggestions. This is the
+	// only part of SuggestNext that makes LLM calls.
+	TieBreak bool
+
+	// Router routes the tie-break request (required when TieBreak is set)
+	Router *llm.Router
+
+	// Budget records tie-break spending under SuggestionTaskType (optional)
+	Budget *llm.BudgetManager
+
+	// TieBreakBudget is the most the tie-break request may cost, in dollars
+	TieBreakBudget float64
+
+	// TieBreakMaxTokens caps the length of the tie-break response
+	TieBreakMaxTokens int
+}
+
+// DefaultSuggestOptions returns options for five suggestions without a tie-break.
+func DefaultSuggestOptions() SuggestOptions {
+	return SuggestOptions{
+		MaxSuggestions:    5,
+		EnergyLevel:       EnergyNormal,
+		TieBreakBudget:    0.01,
+		TieBreakMaxTokens: 50,
+	}
+}
+
+// ScoreComponent is one factor's part in a suggestion's score.
+type ScoreComponent struct {
+	Factor string
+
+	// Weight is the factor's weight after the energy adjustment
+	Weight float64
+
+	// Value is how strongly the factor favors the objective, from 0 to 1
+	Value float64
+
+	// Contribution is the factor's share of the score: Weight × Value over
+	// the total weight
+	Contribution float64
+
+	// Known is false when there was no data for the factor and Value is a
+	// default: 0 for due pressure, 0.5 otherwise
+	Known bool
+
+	// Detail explains the value
+	Detail string
+}
+
+// Suggestion is a startable objective ranked by SuggestNext.
+type Suggestion struct {
+	Objective *Objective
+	Goal      *Goal
+
+	// Score is the weighted average of the factor values, from 0 to 1
+	Score float64
+
+	// Components break the score down by factor, in SuggestionFactors order
+	Components []ScoreComponent
+
+	// Estimate is the expected execution time, from completed objectives
+	// using the same method (0 if unknown)
+	Estimate time.Duration
+}
+
+// Explain names the factors that contributed most to the score.
+func (s *Suggestion) Explain() string {
+	components := append([]ScoreComponent(nil), s.Components...)
+	sort.SliceStable(components, func(i, j int) bool { return components[i].Contribution > components[j].Contribution })
+	var reasons []string
+	for _, component := range components {
+		if len(reasons) == 2 || component.Contribution <= 0 {
+			break
+		}
+		if component.Known {
+			reasons = append(reasons, component.Detail)
+		}
+	}
+	if len(reasons) == 0 {
+		return "no strong reason either way"
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// SuggestionReport is the result of SuggestNext.
+type SuggestionReport struct {
+	// Suggestions are the startable objectives, best first
+	Suggestions []*Suggestion
+
+	// Weights are the weights used, after the energy adjustment
+	Weights SuggestionWeights
+
+	// Blocked counts pending objectives left out because they depend on
+	// unfinished objectives or their goal is not active
+	Blocked int
+
+	// Deferred counts pending objectives left out by the work-in-progress limits
+	Deferred int
+
+	// TieBroken is set when an LLM reordered the top suggestions
+	TieBroken bool
+
+	// TieBreakCost is what the tie-break request cost, in dollars
+	TieBreakCost float64
+
+	// TieBreakError explains why a requested tie-break was not applied
+	TieBreakError string
+}
+
+// methodHistory summarizes the completed objectives that used a method.
+type methodHistory struct {
+	duration time.Duration
+	timed    int
+	tokens   int
+	costed   int
+}
+
+// SuggestNext ranks the objectives that could be started now: pending, not
+// delegated, with every objective they depend on completed, in an active
+// goal and within the work-in-progress limits. Each is scored by a weighted
+// average of transparent factors, reported in its Components, so a user can
+// see why one objective ranks above another. A tie-break that cannot be
+// produced is reported in TieBreakError rather than failing the suggestions.
+func (om *ObjectiveManager) SuggestNext(ctx context.Context, opts SuggestOptions) (*SuggestionReport, error) {
+	if opts.TieBreak && opts.Router == nil {
+		return nil, fmt.Errorf("suggestion tie-break requested but no LLM router is configured")
+	}
+	level, err := ParseEnergyLevel(string(opts.EnergyLevel))
+	if err != nil {
+		return nil, err
+	}
+	weights := om.SuggestionWeights()
+	if opts.Weights != nil {
+		weights = *opts.Weights
+	}
+	if err := weights.Validate(); err != nil {
+		return nil, err
+	}
+	weights = weights.forEnergy(level)
+
+	objectives, err := om.ListObjectives(ctx, ObjectiveFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objectives: %w", err)
+	}
+	goalManager := NewGoalManager(om.store)
+	goals, err := goalManager.ListGoals(ctx, GoalFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	goalsByID := make(map[string]*Goal, len(goals))
+	for _, goal := range goals {
+		goalsByID[goal.ID] = goal
+	}
+	unfinished, err := om.unfinishedDependencies(ctx, objectives)
+	if err != nil {
+		return nil, err
+	}
+
+	var inProgress, candidates []*Objective
+	histories := make(map[string]*methodHistory)
+	lastWorked := make(map[string]time.Time)
+	for _, objective := range objectives {
+		switch objective.Status {
+		case ObjectiveStatusInProgress:
+			inProgress = append(inProgress, objective)
+		case ObjectiveStatusPending:
+			candidates = append(candidates, objective)
+		case ObjectiveStatusCompleted:
+			recordMethodHistory(histories, objective)
+		}
+		for _, at := range []*time.Time{objective.StartedAt, objective.CompletedAt} {
+			if at != nil && at.After(lastWorked[objective.GoalID]) {
+				lastWorked[objective.GoalID] = *at
+			}
+		}
+	}
+
+	report := &SuggestionReport{Weights: weights}
+	methods := NewMethodManager(om.store)
+	methodsByID := make(map[string]*Method)
+	now := time.Now()
+	for _, objective := range candidates {
+		if objective.IsDelegated() {
+			continue
+		}
+		// Archived goals are not listed; their few stray objectives look them up
+		goal, listed := goalsByID[objective.GoalID]
+		if !listed {
+			goal, _ = goalManager.GetGoal(ctx, objective.GoalID)
+			goalsByID[objective.GoalID] = goal
+		}
+		if unfinished[objective.ID] || (goal != nil && goal.Status != GoalStatusActive) {
+			report.Blocked++
+			continue
+		}
+		if om.wip.check(inProgress, objective.GoalID) != nil {
+			report.Deferred++
+			continue
+		}
+
+		method, cached := methodsByID[objective.MethodID]
+		if !cached {
+			if method, err = methods.GetMethod(ctx, objective.MethodID); err != nil {
+				method = nil
+			}
+			methodsByID[objective.MethodID] = method
+		}
+		report.Suggestions = append(report.Suggestions, scoreSuggestion(objective, goal, method, histories[objective.MethodID], lastWorked, weights, opts.AvailableTime, level, now))
+	}
+
+	sort.SliceStable(report.Suggestions, func(i, j int) bool {
+		a, b := report.Suggestions[i], report.Suggestions[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Objective.CreatedAt.Before(b.Objective.CreatedAt)
+	})
+	if opts.MaxSuggestions > 0 && len(report.Suggestions) > opts.MaxSuggestions {
+		report.Suggestions = report.Suggestions[:opts.MaxSuggestions]
+	}
+
+	if opts.TieBreak && len(report.Suggestions) > 1 {
+		order, cost, err := breakTie(ctx, report.Suggestions, opts, level)
+		report.TieBreakCost = cost
+		if err != nil {
+			report.TieBreakError = err.Error()
+		} else {
+			top := make([]*Suggestion, len(order))
+			for i, index := range order {
+				top[i] = report.Suggestions[index]
+			}
+			copy(report.Suggestions, top)
+			report.TieBroken = true
+		}
+	}
+	return report, nil
+}
+
+// GetNextActionable returns the pending objective to work on next, or nil
+// when none can be started. It considers the objectives SuggestNext does,
+// ranked by a blend of their priority, how close they are to being due and
+// their goal's priority, weighted as in the manager's suggestion weights.
+// Recurring objectives missing their next occurrence get it first.
+func (om *ObjectiveManager) GetNextActionable(ctx context.Context) (*Suggestion, error) {
+	if _, err := NewObjectiveScheduler(om).Reconcile(ctx); err != nil {
+		return nil, fmt.Errorf("failed to schedule recurring objectives: %w", err)
+	}
+
+	configured := om.SuggestionWeights()
+	weights := SuggestionWeights{
+		Priority:     configured.Priority,
+		DuePressure:  configured.DuePressure,
+		GoalPriority: configured.GoalPriority,
+	}
+	if weights.Validate() != nil {
+		defaults := DefaultSuggestionWeights()
+		weights = SuggestionWeights{Priority: defaults.Priority, DuePressure: defaults.DuePressure, GoalPriority: defaults.GoalPriority}
+	}
+	report, err := om.SuggestNext(ctx, SuggestOptions{MaxSuggestions: 1, EnergyLevel: EnergyNormal, Weights: &weights})
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Suggestions) == 0 {
+		return nil, nil
+	}
+	return report.Suggestions[0], nil
+}
+
+// unfinishedDependencies returns the IDs of the objectives that depend on an
+// objective that is not completed. Dependencies that were undone, or whose
+// target is no longer listed, do not block.
+func (om *ObjectiveManager) unfinishedDependencies(ctx context.Context, objectives []*Objective) (map[string]bool, error) {
+	edges, err := om.store.GetEdgesByType(ctx, dependsOnEdgeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependencies: %w", err)
+	}
+	byID := make(map[string]*Objective, len(objectives))
+	for _, objective := range objectives {
+		byID[objective.ID] = objective
+	}
+
+	blocked := make(map[string]bool)
+	for _, edge := range edges {
+		if _, undone := edge.Data[undoneByEdgeKey]; undone {
+			continue
+		}
+		if dependency, ok := byID[edge.TargetID]; ok && !dependency.IsCompleted() {
+			blocked[edge.SourceID] = true
+		}
+	}
+	return blocked, nil
+}
+
+// recordMethodHistory adds a completed objective's time and tokens to its method's history.
+func recordMethodHistory(histories map[string]*methodHistory, objective *Objective) {
+	history := histories[objective.MethodID]
+	if history == nil {
+		history = &methodHistory{}
+		histories[objective.MethodID] = history
+	}
+	duration := objective.ActiveExecutionTime
+	if duration <= 0 && objective.Result != nil {
+		duration = objective.Result.ExecutionTime
+	}
+	if duration > 0 {
+		history.duration += duration
+		history.timed++
+	}
+	if objective.Result != nil && objective.Result.TokensUsed > 0 {
+		history.tokens += objective.Result.TokensUsed
+		history.costed++
+	}
+}
+
+// DueDate returns the objective's due time: DueAt, or for objectives created
+// before it, the due date stored in the context as a date ("2006-01-02") or
+// an RFC 3339 time.
+func (o *Objective) DueDate() (time.Time, bool) {
+	if o.DueAt != nil {
+		return *o.DueAt, true
+	}
+	raw, ok := o.Context[dueDateContextKey].(string)
+	if !ok || raw == "" {
+		return time.Time{}, false
+	}
+	return ParseDueDate(raw)
+}
+
+// ParseDueDate parses a due date given as a date ("2006-01-02", due by the
+// end of that day in local time) or an RFC 3339 time.
+func ParseDueDate(raw string) (time.Time, bool) {
+	return ParseDueDateIn(raw, time.Local)
+}
+
+// ParseDueDateIn is ParseDueDate with dates due by the end of the day in location.
+func ParseDueDateIn(raw string, location *time.Location) (time.Time, bool) {
+	if due, err := time.ParseInLocation("2006-01-02", raw, location); err == nil {
+		return due.Add(24*time.Hour - time.Second), true
+	}
+	if due, err := time.Parse(time.RFC3339, raw); err == nil {
+		return due, true
+	}
+	return time.Time{}, false
+}
+
+// scoreSuggestion scores one startable objective.
+func scoreSuggestion(objective *Objective, goal *Goal, method *Method, history *methodHistory, lastWorked map[string]time.Time, weights SuggestionWeights, available time.Duration, level EnergyLevel, now time.Time) *Suggestion {
+	suggestion := &Suggestion{Objective: objective, Goal: goal}
+	if history != nil && history.timed > 0 {
+		suggestion.Estimate = history.duration / time.Duration(history.timed)
+	}
+
+	components := []ScoreComponent{
+		{Factor: FactorPriority, Value: priorityValue(objective.Priority), Known: true, Detail: fmt.Sprintf("priority %d", objective.Priority)},
+		dueComponent(objective, now),
+		goalPriorityComponent(goal),
+		timeFitComponent(suggestion.Estimate, available, level),
+		stalenessComponent(goal, lastWorked, now),
+		valueCostComponent(method, history),
+	}
+
+	total := 0.0
+	for i := range components {
+		components[i].Weight, _ = weights.Get(components[i].Factor)
+		total += components[i].Weight
+	}
+	for i := range components {
+		components[i].Contribution = components[i].Weight * components[i].Value / total
+		suggestion.Score += components[i].Contribution
+	}
+	suggestion.Components = components
+	return suggestion
+}
+
+// priorityValue maps a 1-10 priority to 0-1.
+func priorityValue(priority int) float64 {
+	return clampUnit(float64(priority-1) / 9)
+}
+
+// dueComponent rises from 0 two weeks before the due date to 1 when it is due.
+func dueComponent(objective *Objective, now time.Time) ScoreComponent {
+	component := ScoreComponent{Factor: FactorDuePressure, Detail: "no due date"}
+	due, ok := objective.DueDate()
+	if !ok {
+		return component
+	}
+	component.Known = true
+	remaining := due.Sub(now)
+	if remaining <= 0 {
+		component.Value = 1
+		component.Detail = fmt.Sprintf("overdue by %s", formatSuggestionDuration(-remaining))
+		return component
+	}
+	component.Value = clampUnit(1 - float64(remaining)/float64(duePressureHorizon))
+	component.Detail = fmt.Sprintf("due in %s", formatSuggestionDuration(remaining))
+	return component
+}
+
+// goalPriorityComponent maps the goal's priority to 0-1.
+func goalPriorityComponent(goal *Goal) ScoreComponent {
+	if goal == nil {
+		return ScoreComponent{Factor: FactorGoalPriority, Value: 0.5, Detail: "goal not found"}
+	}
+	return ScoreComponent{
+		Factor: FactorGoalPriority,
+		Value:  priorityValue(goal.Priority),
+		Known:  true,
+		Detail: fmt.Sprintf("goal %q has priority %d", goal.Title, goal.Priority),
+	}
+}
+
+// timeFitComponent is 1 for an objective expected to fit the available time,
+// falling with how far it overruns. At low energy, shorter objectives score
+// higher even when no time limit is given.
+func timeFitComponent(estimate, available time.Duration, level EnergyLevel) ScoreComponent {
+	component := ScoreComponent{Factor: FactorTimeFit, Value: 0.5}
+	if estimate <= 0 {
+		component.Detail = "no execution history to estimate its time"
+		return component
+	}
+
+	shortness := 1 / (1 + float64(estimate)/float64(shortTaskScale))
+	component.Known = true
+	switch {
+	case available > 0:
+		component.Value = math.Min(1, float64(available)/float64(estimate))
+		if estimate <= available {
+			component.Detail = fmt.Sprintf("about %s fits in %s", formatSuggestionDuration(estimate), formatSuggestionDuration(available))
+		} else {
+			component.Detail = fmt.Sprintf("about %s does not fit in %s", formatSuggestionDuration(estimate), formatSuggestionDuration(available))
+		}
+		if level == EnergyLow {
+			component.Value = (component.Value + shortness) / 2
+		}
+	case level == EnergyLow:
+		component.Value = shortness
+		component.Detail = fmt.Sprintf("about %s, short work suits low energy", formatSuggestionDuration(estimate))
+	default:
+		component.Known = false
+		component.Detail = fmt.Sprintf("about %s, no time limit given", formatSuggestionDuration(estimate))
+	}
+	return component
+}
+
+// stalenessComponent rises with the time since an objective of the goal was
+// last started or completed, or since the goal was created.
+func stalenessComponent(goal *Goal, lastWorked map[string]time.Time, now time.Time) ScoreComponent {
+	component := ScoreComponent{Factor: FactorStaleness, Value: 0.5, Detail: "goal not found"}
+	if goal == nil {
+		return component
+	}
+	last, worked := lastWorked[goal.ID]
+	if !worked {
+		last = goal.CreatedAt
+	}
+	idle := now.Sub(last)
+	component.Known = true
+	component.Value = clampUnit(float64(idle) / float64(stalenessHorizon))
+	if worked {
+		component.Detail = fmt.Sprintf("goal last worked on %s ago", formatSuggestionDuration(idle))
+	} else {
+		component.Detail = fmt.Sprintf("goal created %s ago, not worked on yet", formatSuggestionDuration(idle))
+	}
+	return component
+}
+
+// valueCostComponent weighs the method's success rate and rating against the
+// tokens its completed objectives used.
+func valueCostComponent(method *Method, history *methodHistory) ScoreComponent {
+	component := ScoreComponent{Factor: FactorValueCost, Value: 0.5, Detail: "method has no execution history"}
+	if method == nil || method.Metrics.ExecutionCount == 0 {
+		return component
+	}
+
+	value := method.Metrics.SuccessRate() / 100
+	component.Detail = fmt.Sprintf("method succeeds %.0f%%", method.Metrics.SuccessRate())
+	if method.Metrics.AverageRating > 0 {
+		value *= method.Metrics.AverageRating / 10
+		component.Detail += fmt.Sprintf(", rated %.1f/10", method.Metrics.AverageRating)
+	}
+	if history != nil && history.costed > 0 {
+		tokens := float64(history.tokens) / float64(history.costed)
+		value /= 1 + tokens/tokenCostScale
+		component.Detail += fmt.Sprintf(", ~%.0f tokens per run", tokens)
+	}
+	component.Known = true
+	component.Value = clampUnit(value)
+	return component
+}
+
+// clampUnit limits value to [0, 1].
+func clampUnit(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
+
+// formatSuggestionDuration rounds a duration to the unit that reads best.
+func formatSuggestionDuration(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%.1fh", d.Hours())
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return "under a minute"
+	}
+}
+
+// tieBreakOrderPattern finds the suggestion numbers in a tie-break reply.
+var tieBreakOrderPattern = regexp.MustCompile(`\d+`)
+
+// breakTie asks an LLM which of the top suggestions to do first. It returns
+// the new order of the top suggestions as indexes, and what the request cost.
+func breakTie(ctx context.Context, suggestions []*Suggestion, opts SuggestOptions, level EnergyLevel) ([]int, float64, error) {
+	top := suggestions
+	if len(top) > tieBreakCount {
+		top = top[:tieBreakCount]
+	}
+	budget := opts.TieBreakBudget
+	if budget <= 0 {
+		budget = DefaultSuggestOptions().TieBreakBudget
+	}
+	maxTokens := opts.TieBreakMaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultSuggestOptions().TieBreakMaxTokens
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The user has %s and %s energy. Which of these tasks should they do first?\n", availableTimeText(opts.AvailableTime), level)
+	for i, suggestion := range top {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, suggestion.Objective.Title)
+		if suggestion.Objective.Description != "" {
+			fmt.Fprintf(&b, ": %s", suggestion.Objective.Description)
+		}
+		fmt.Fprintf(&b, " (%s)", suggestion.Explain())
+	}
+	b.WriteString("\n\nReply with only the task numbers in the order to do them, e.g. 2,1,3.")
+
+	result, err := opts.Router.Route(ctx, llm.TaskRequest{
+		Prompt:           b.String(),
+		MaxTokens:        maxTokens,
+		Temperature:      0.0,
+		TaskType:         SuggestionTaskType,
+		QualityRequired:  llm.QualityBasic,
+		BudgetConstraint: &budget,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("LLM routing failed: %w", err)
+	}
+	if result.ExecutionResult == nil {
+		return nil, 0, fmt.Errorf("no result from LLM execution")
+	}
+
+	completion := result.ExecutionResult
+	if opts.Budget != nil {
+		if err := opts.Budget.RecordUsage(ctx, llm.Transaction{
+			Provider:   result.SelectedModel.Provider,
+			Model:      result.SelectedModel.Model,
+			TaskType:   SuggestionTaskType,
+			TokensUsed: completion.TokensUsed,
+			Cost:       completion.Cost,
+			Success:    true,
+		}); err != nil {
+			return nil, completion.Cost, fmt.Errorf("failed to record tie-break cost: %w", err)
+		}
+	}
+
+	order := parseTieBreakOrder(completion.Text, len(top))
+	if order == nil {
+		return nil, completion.Cost, fmt.Errorf("could not read an order from the reply %q", strings.TrimSpace(completion.Text))
+	}
+	return order, completion.Cost, nil
+}
+
+// parseTieBreakOrder reads the 1-based task numbers in reply as 0-based
+// indexes; tasks it does not name keep their relative order at the end.
+// It returns nil if reply names none of the n tasks.
+func parseTieBreakOrder(reply string, n int) []int {
+	var order []int
+	seen := make(map[int]bool)
+	for _, match := range tieBreakOrderPattern.FindAllString(reply, -1) {
+		number, err := strconv.Atoi(match)
+		if err != nil || number < 1 || number > n || seen[number-1] {
+			continue
+		}
+		seen[number-1] = true
+		order = append(order, number-1)
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		if !seen[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// availableTimeText describes the available time for the tie-break prompt.
+func availableTimeText(available time.Duration) string {
+	if available <= 0 {
+		return "no particular time limit"
+	}
+	return formatSuggestionDuration(available) + " available"
+}
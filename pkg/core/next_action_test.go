@@ -0,0 +1,284 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// suggestionFixture is a workspace with a long important objective, a short
+// less important one, and objectives that must never be suggested.
+type suggestionFixture struct {
+	store      *storage.Store
+	objectives *ObjectiveManager
+	long       *Objective
+	short      *Objective
+	hidden     map[string]string
+}
+
+func newSuggestionFixture(t *testing.T) *suggestionFixture {
+	t.Helper()
+	ctx := context.Background()
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+	om := NewObjectiveManager(store)
+	mm := NewMethodManager(store)
+	f := &suggestionFixture{store: store, objectives: om, hidden: make(map[string]string)}
+
+	createMethod := func(name string) *Method {
+		method, err := mm.CreateMethod(ctx, name, "", []ApproachStep{{Description: "Work"}}, MethodDomainGeneral, nil)
+		if err != nil {
+			t.Fatalf("Failed to create method: %v", err)
+		}
+		return method
+	}
+	createGoal := func(title string) *Goal {
+		goal, err := gm.CreateGoal(ctx, title, "", 5, nil)
+		if err != nil {
+			t.Fatalf("Failed to create goal: %v", err)
+		}
+		return goal
+	}
+	createObjective := func(goal *Goal, method *Method, title string, priority int) *Objective {
+		objective, err := om.CreateObjective(ctx, goal.ID, method.ID, title, "", nil, priority)
+		if err != nil {
+			t.Fatalf("Failed to create objective: %v", err)
+		}
+		return objective
+	}
+	setStatus := func(objective *Objective, status ObjectiveStatus, active time.Duration) {
+		if _, err := om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{Status: &status, ActiveExecutionTime: &active}); err != nil {
+			t.Fatalf("Failed to update objective: %v", err)
+		}
+	}
+
+	// Past runs give the methods their time estimates
+	writing, email := createMethod("Writing"), createMethod("Email")
+	history := createGoal("History")
+	setStatus(createObjective(history, writing, "Earlier report", 5), ObjectiveStatusCompleted, 3*time.Hour)
+	setStatus(createObjective(history, email, "Earlier reply", 5), ObjectiveStatusCompleted, 10*time.Minute)
+
+	work := createGoal("Work")
+	f.long = createObjective(work, writing, "Write the report", 9)
+	f.short = createObjective(work, email, "Reply to the client", 5)
+
+	// Waits on the report
+	blocked := createObjective(work, writing, "Publish the report", 10)
+	if err := store.AddEdge(ctx, storage.NewEdge(blocked.ID, f.long.ID, dependsOnEdgeType, nil)); err != nil {
+		t.Fatalf("Failed to add dependency: %v", err)
+	}
+	f.hidden[blocked.ID] = "blocked"
+
+	// Over its goal's work-in-progress limit
+	busy := createGoal("Busy")
+	setStatus(createObjective(busy, email, "Already running", 5), ObjectiveStatusInProgress, 0)
+	capped := createObjective(busy, email, "Also urgent", 10)
+	om.SetWIPLimits(WIPLimits{Goals: map[string]int{busy.ID: 1}})
+	f.hidden[capped.ID] = "over the WIP limit"
+
+	// Done by someone else
+	delegated := createObjective(work, email, "Ask the designer", 10)
+	if _, err := om.MarkDelegated(ctx, delegated.ID, "designer", ""); err != nil {
+		t.Fatalf("Failed to delegate: %v", err)
+	}
+	f.hidden[delegated.ID] = "delegated"
+
+	return f
+}
+
+// suggest runs SuggestNext and checks that no hidden objective is suggested.
+func (f *suggestionFixture) suggest(t *testing.T, opts SuggestOptions) *SuggestionReport {
+	t.Helper()
+	report, err := f.objectives.SuggestNext(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("SuggestNext failed: %v", err)
+	}
+	for _, suggestion := range report.Suggestions {
+		if reason, hidden := f.hidden[suggestion.Objective.ID]; hidden {
+			t.Errorf("Suggested %q, which is %s", suggestion.Objective.Title, reason)
+		}
+	}
+	return report
+}
+
+func TestSuggestNext_Reordering(t *testing.T) {
+	f := newSuggestionFixture(t)
+
+	tests := []struct {
+		name  string
+		opts  SuggestOptions
+		first *Objective
+	}{
+		{"plenty of time", SuggestOptions{}, f.long},
+		{"short available time", SuggestOptions{AvailableTime: 30 * time.Minute}, f.short},
+		{"low energy", SuggestOptions{EnergyLevel: EnergyLow}, f.short},
+		{"short time at high energy", SuggestOptions{AvailableTime: 30 * time.Minute, EnergyLevel: EnergyHigh}, f.long},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := f.suggest(t, tt.opts)
+			if len(report.Suggestions) != 2 {
+				t.Fatalf("Expected the two startable objectives, got %d", len(report.Suggestions))
+			}
+			if got := report.Suggestions[0].Objective.ID; got != tt.first.ID {
+				t.Errorf("Expected %q first, got %q", tt.first.Title, report.Suggestions[0].Objective.Title)
+			}
+			if report.Blocked != 1 || report.Deferred != 1 {
+				t.Errorf("Expected one blocked and one deferred objective, got %d and %d", report.Blocked, report.Deferred)
+			}
+		})
+	}
+}
+
+func TestSuggestNext_Breakdown(t *testing.T) {
+	f := newSuggestionFixture(t)
+	report := f.suggest(t, SuggestOptions{AvailableTime: time.Hour})
+
+	for _, suggestion := range report.Suggestions {
+		if len(suggestion.Components) != len(SuggestionFactors) {
+			t.Fatalf("Expected a component per factor, got %+v", suggestion.Components)
+		}
+		total := 0.0
+		for i, component := range suggestion.Components {
+			if component.Factor != SuggestionFactors[i] {
+				t.Errorf("Expected factor %s at %d, got %s", SuggestionFactors[i], i, component.Factor)
+			}
+			total += component.Contribution
+		}
+		if math.Abs(total-suggestion.Score) > 1e-9 {
+			t.Errorf("Expected the contributions to add up to the score %.3f, got %.3f", suggestion.Score, total)
+		}
+	}
+
+	var long *Suggestion
+	for _, suggestion := range report.Suggestions {
+		if suggestion.Objective.ID == f.long.ID {
+			long = suggestion
+		}
+	}
+	if long == nil || long.Estimate != 3*time.Hour {
+		t.Fatalf("Expected the report to be estimated from the earlier run, got %+v", long)
+	}
+	if fit := long.Components[3]; !fit.Known || math.Abs(fit.Value-1.0/3) > 1e-9 {
+		t.Errorf("Expected a 3h task to fit an hour a third of the way, got %+v", fit)
+	}
+	if due := long.Components[1]; due.Known || due.Value != 0 {
+		t.Errorf("Expected no due pressure without a due date, got %+v", due)
+	}
+}
+
+func TestSuggestNext_DueDateAndWeights(t *testing.T) {
+	f := newSuggestionFixture(t)
+	ctx := context.Background()
+
+	// An overdue reply outranks the report
+	overdue := time.Now().Add(-48 * time.Hour).Format("2006-01-02")
+	if _, err := f.objectives.UpdateObjective(ctx, f.short.ID, ObjectiveUpdates{Context: map[string]interface{}{"due_date": overdue}}); err != nil {
+		t.Fatal(err)
+	}
+	report := f.suggest(t, SuggestOptions{})
+	if report.Suggestions[0].Objective.ID != f.short.ID {
+		t.Fatalf("Expected the overdue objective first, got %q", report.Suggestions[0].Objective.Title)
+	}
+	if due := report.Suggestions[0].Components[1]; !due.Known || due.Value != 1 {
+		t.Errorf("Expected full due pressure, got %+v", due)
+	}
+
+	// Weights previewed per call override the manager's
+	weights, err := ParseSuggestionWeights(DefaultSuggestionWeights(), "due=0, time_fit=0")
+	if err != nil {
+		t.Fatalf("Failed to parse weights: %v", err)
+	}
+	report = f.suggest(t, SuggestOptions{Weights: &weights})
+	if report.Suggestions[0].Objective.ID != f.long.ID {
+		t.Errorf("Expected priority to win without due pressure, got %q", report.Suggestions[0].Objective.Title)
+	}
+	if report.Weights.DuePressure != 0 {
+		t.Errorf("Expected the report to show the weights used, got %+v", report.Weights)
+	}
+
+	f.objectives.SetSuggestionWeights(weights)
+	if report = f.suggest(t, SuggestOptions{}); report.Suggestions[0].Objective.ID != f.long.ID {
+		t.Errorf("Expected the manager's weights to apply, got %q", report.Suggestions[0].Objective.Title)
+	}
+
+	for _, spec := range []string{"urgency=1", "priority=-1", "priority", "priority=0,due=0,goal_priority=0,time_fit=0,staleness=0,value_cost=0"} {
+		if _, err := ParseSuggestionWeights(DefaultSuggestionWeights(), spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+	if _, err := f.objectives.SuggestNext(ctx, SuggestOptions{EnergyLevel: "sleepy"}); err == nil {
+		t.Error("Expected an unknown energy level to be rejected")
+	}
+}
+
+func TestSuggestNext_TieBreak(t *testing.T) {
+	f := newSuggestionFixture(t)
+	service := &scriptedClassifierService{}
+	budget, err := llm.NewBudgetManager(filepath.Join(t.TempDir(), "budget"), llm.BudgetConfig{
+		WeeklyLimit:     5.0,
+		TrackingEnabled: true,
+	}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	opts := SuggestOptions{TieBreak: true, Router: llm.NewRouter(service), Budget: budget}
+
+	if _, err := f.objectives.SuggestNext(context.Background(), SuggestOptions{TieBreak: true}); err == nil {
+		t.Error("Expected a tie-break without a router to be rejected")
+	}
+
+	service.script("2, 1")
+	report := f.suggest(t, opts)
+	if !report.TieBroken || report.Suggestions[0].Objective.ID != f.short.ID {
+		t.Fatalf("Expected the tie-break to put the reply first, got %+v", report)
+	}
+	if report.TieBreakCost != 0.001 {
+		t.Errorf("Expected the tie-break cost to be reported, got %.4f", report.TieBreakCost)
+	}
+
+	// A reply without an order keeps the scored order
+	service.script("I cannot decide")
+	report = f.suggest(t, opts)
+	if report.TieBroken || report.TieBreakError == "" {
+		t.Errorf("Expected the tie-break to be reported as failed, got %+v", report)
+	}
+	if report.Suggestions[0].Objective.ID != f.long.ID {
+		t.Errorf("Expected the scored order to stand, got %q first", report.Suggestions[0].Objective.Title)
+	}
+	if service.calls() != 2 {
+		t.Errorf("Expected two tie-break requests, got %d", service.calls())
+	}
+}
+
+func TestParseTieBreakOrder(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  []int
+	}{
+		{"2,1,3", []int{1, 0, 2}},
+		{"3", []int{2, 0, 1}},
+		{"Do 2 first, then 2 again, then 7", []int{1, 0, 2}},
+		{"none", nil},
+	}
+	for _, tt := range tests {
+		got := parseTieBreakOrder(tt.reply, 3)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseTieBreakOrder(%q) = %v, want %v", tt.reply, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseTieBreakOrder(%q) = %v, want %v", tt.reply, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package core
+
+import "context"
+
+// Notifier delivers alerts that need the user's attention, such as a backup
+// target that can no longer be reached. Background work reports problems
+// through a Notifier instead of failing silently.
+type Notifier interface {
+	// Notify delivers an alert with a short title and a longer message
+	Notify(ctx context.Context, title, message string) error
+}
+
+// NotifierFunc adapts an ordinary function to the Notifier interface.
+type NotifierFunc func(ctx context.Context, title, message string) error
+
+// Notify calls f(ctx, title, message).
+func (f NotifierFunc) Notify(ctx context.Context, title, message string) error {
+	return f(ctx, title, message)
+}
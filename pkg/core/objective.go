@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
 )
 
@@ -26,6 +27,10 @@ const (
 
 	// ObjectiveStatusPaused indicates the objective is temporarily paused
 	ObjectiveStatusPaused ObjectiveStatus = "paused"
+
+	// ObjectiveStatusCancelled indicates the objective is no longer wanted,
+	// without having been attempted to the end
+	ObjectiveStatusCancelled ObjectiveStatus = "cancelled"
 )
 
 // ObjectiveResult captures the outcome when an objective completes.
@@ -91,13 +96,39 @@ type Objective struct {
 	// CompletedAt is when this objective finished (success or failure)
 	CompletedAt *time.Time
 
+	// MaxExecutionTime is the objective's own time box: how long it may
+	// execute before it is paused at a checkpoint. 0 uses the default for
+	// its priority (see TimeBoxes).
+	MaxExecutionTime time.Duration
+
+	// ActiveExecutionTime is the execution time counted against the time
+	// box, across runs. Waits for ethical approval are not included.
+	ActiveExecutionTime time.Duration
+
+	// ApprovalWaitTime is the time execution spent waiting for ethical approval
+	ApprovalWaitTime time.Duration
+
+	// DueAt is when the objective is due (optional)
+	DueAt *time.Time
+
+	// Recurrence makes the objective repeat after it finishes (optional)
+	Recurrence *Recurrence
+
+	// RecurrenceOf is the ID of the occurrence this objective was created to
+	// follow, for objectives materialized by an ObjectiveScheduler
+	RecurrenceOf string
+
 	// store reference for database operations
 	store *storage.Store
 }
 
 // ObjectiveManager provides operations for managing objectives in the storage system.
 type ObjectiveManager struct {
-	store *storage.Store
+	store     *storage.Store
+	wip       WIPLimits
+	timeBoxes TimeBoxes
+
+	suggestionWeights SuggestionWeights
 }
 
 // NewObjectiveManager creates a new manager for objective operations.
@@ -110,17 +141,23 @@ func NewObjectiveManager(store *storage.Store) *ObjectiveManager {
 // CreateObjective creates a new objective and stores it in the system.
 // It also establishes the relationships to the goal and method via edges.
 func (om *ObjectiveManager) CreateObjective(ctx context.Context, goalID, methodID, title, description string, context map[string]interface{}, priority int) (*Objective, error) {
+	return om.createObjective(ctx, goalID, methodID, title, description, context, priority, nil, nil, "")
+}
+
+// createObjective creates an objective, with its schedule written in the
+// same node so that a materialized occurrence is never stored without it.
+func (om *ObjectiveManager) createObjective(ctx context.Context, goalID, methodID, title, description string, context map[string]interface{}, priority int, dueAt *time.Time, recurrence *Recurrence, recurrenceOf string) (*Objective, error) {
 	if title == "" {
-		return nil, fmt.Errorf("objective title cannot be empty")
+		return nil, errs.New(errs.Validation, "objective title cannot be empty")
 	}
 	if goalID == "" {
-		return nil, fmt.Errorf("goal ID cannot be empty")
+		return nil, errs.New(errs.Validation, "goal ID cannot be empty")
 	}
 	if methodID == "" {
-		return nil, fmt.Errorf("method ID cannot be empty")
+		return nil, errs.New(errs.Validation, "method ID cannot be empty")
 	}
 	if priority < 1 || priority > 10 {
-		return nil, fmt.Errorf("priority must be between 1 and 10, got %d", priority)
+		return nil, errs.Newf(errs.Validation, "priority must be between 1 and 10, got %d", priority)
 	}
 
 	now := time.Now()
@@ -144,32 +181,38 @@ func (om *ObjectiveManager) CreateObjective(ctx context.Context, goalID, methodI
 		"completed_at": nil,
 		"result":      nil,
 	}
+	setScheduleData(data, dueAt, recurrence, recurrenceOf)
 
 	// Create storage node
 	node := storage.NewNode("objective", data)
 
-	// Store the node
-	if err := om.store.AddNode(ctx, node); err != nil {
-		return nil, fmt.Errorf("failed to store objective: %w", err)
-	}
+	// The objective and its relationships are stored together or not at all
+	err := om.store.Batch(ctx, func(tx *storage.Tx) error {
+		if err := tx.AddNode(node); err != nil {
+			return fmt.Errorf("failed to store objective: %w", err)
+		}
 
-	// Create relationships via edges
-	// Objective "serves" Goal
-	servesEdge := storage.NewEdge(node.ID, goalID, "serves", map[string]interface{}{
-		"relationship": "objective_serves_goal",
-		"created_at":   now.Format(time.RFC3339),
-	})
-	if err := om.store.AddEdge(ctx, servesEdge); err != nil {
-		return nil, fmt.Errorf("failed to create objective-goal relationship: %w", err)
-	}
+		// Objective "serves" Goal
+		servesEdge := storage.NewEdge(node.ID, goalID, "serves", map[string]interface{}{
+			"relationship": "objective_serves_goal",
+			"created_at":   now.Format(time.RFC3339),
+		})
+		if err := tx.AddEdge(servesEdge); err != nil {
+			return fmt.Errorf("failed to create objective-goal relationship: %w", err)
+		}
 
-	// Objective "uses" Method
-	usesEdge := storage.NewEdge(node.ID, methodID, "uses", map[string]interface{}{
-		"relationship": "objective_uses_method",
-		"created_at":   now.Format(time.RFC3339),
+		// Objective "uses" Method
+		usesEdge := storage.NewEdge(node.ID, methodID, "uses", map[string]interface{}{
+			"relationship": "objective_uses_method",
+			"created_at":   now.Format(time.RFC3339),
+		})
+		if err := tx.AddEdge(usesEdge); err != nil {
+			return fmt.Errorf("failed to create objective-method relationship: %w", err)
+		}
+		return nil
 	})
-	if err := om.store.AddEdge(ctx, usesEdge); err != nil {
-		return nil, fmt.Errorf("failed to create objective-method relationship: %w", err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store objective: %w", err)
 	}
 
 	// Return objective object
@@ -183,7 +226,11 @@ func (om *ObjectiveManager) CreateObjective(ctx context.Context, goalID, methodI
 		Context:     context,
 		Priority:    priority,
 		CreatedAt:   now,
-		store:       om.store,
+
+		DueAt:        dueAt,
+		Recurrence:   recurrence,
+		RecurrenceOf: recurrenceOf,
+		store:        om.store,
 	}
 
 	return objective, nil
@@ -197,7 +244,7 @@ func (om *ObjectiveManager) GetObjective(ctx context.Context, objectiveID string
 	}
 
 	if node.Type != "objective" {
-		return nil, fmt.Errorf("node %s is not an objective (type: %s)", objectiveID, node.Type)
+		return nil, errs.Newf(errs.NotFound, "node %s is not an objective (type: %s)", objectiveID, node.Type).With("id", objectiveID)
 	}
 
 	return om.nodeToObjective(node)
@@ -211,7 +258,7 @@ func (om *ObjectiveManager) GetObjectiveAtTime(ctx context.Context, objectiveID
 	}
 
 	if node.Type != "objective" {
-		return nil, fmt.Errorf("node %s is not an objective (type: %s)", objectiveID, node.Type)
+		return nil, errs.Newf(errs.NotFound, "node %s is not an objective (type: %s)", objectiveID, node.Type).With("id", objectiveID)
 	}
 
 	return om.nodeToObjective(node)
@@ -230,7 +277,7 @@ func (om *ObjectiveManager) UpdateObjective(ctx context.Context, objectiveID str
 	if updates.GoalID != nil {
 		goalID = *updates.GoalID
 		if goalID == "" {
-			return nil, fmt.Errorf("goal ID cannot be empty")
+			return nil, errs.New(errs.Validation, "goal ID cannot be empty")
 		}
 	}
 
@@ -238,7 +285,7 @@ func (om *ObjectiveManager) UpdateObjective(ctx context.Context, objectiveID str
 	if updates.MethodID != nil {
 		methodID = *updates.MethodID
 		if methodID == "" {
-			return nil, fmt.Errorf("method ID cannot be empty")
+			return nil, errs.New(errs.Validation, "method ID cannot be empty")
 		}
 	}
 
@@ -246,7 +293,7 @@ func (om *ObjectiveManager) UpdateObjective(ctx context.Context, objectiveID str
 	if updates.Title != nil {
 		title = *updates.Title
 		if title == "" {
-			return nil, fmt.Errorf("objective title cannot be empty")
+			return nil, errs.New(errs.Validation, "objective title cannot be empty")
 		}
 	}
 
@@ -277,7 +324,7 @@ func (om *ObjectiveManager) UpdateObjective(ctx context.Context, objectiveID str
 	if updates.Priority != nil {
 		priority = *updates.Priority
 		if priority < 1 || priority > 10 {
-			return nil, fmt.Errorf("priority must be between 1 and 10, got %d", priority)
+			return nil, errs.Newf(errs.Validation, "priority must be between 1 and 10, got %d", priority)
 		}
 	}
 
@@ -291,6 +338,50 @@ func (om *ObjectiveManager) UpdateObjective(ctx context.Context, objectiveID str
 		completedAt = updates.CompletedAt
 	}
 
+	maxExecutionTime := currentObjective.MaxExecutionTime
+	if updates.MaxExecutionTime != nil {
+		maxExecutionTime = *updates.MaxExecutionTime
+		if maxExecutionTime < 0 {
+			return nil, errs.Newf(errs.Validation, "time box cannot be negative, got %s", maxExecutionTime)
+		}
+	}
+
+	activeExecutionTime := currentObjective.ActiveExecutionTime
+	if updates.ActiveExecutionTime != nil {
+		activeExecutionTime = *updates.ActiveExecutionTime
+	}
+
+	approvalWaitTime := currentObjective.ApprovalWaitTime
+	if updates.ApprovalWaitTime != nil {
+		approvalWaitTime = *updates.ApprovalWaitTime
+	}
+
+	dueAt := currentObjective.DueAt
+	if updates.DueAt != nil {
+		dueAt = updates.DueAt
+		if dueAt.IsZero() {
+			dueAt = nil
+		}
+	}
+
+	recurrence := currentObjective.Recurrence
+	if updates.Recurrence != nil {
+		recurrence = nil
+		if updates.Recurrence.Frequency != "" {
+			updated := *updates.Recurrence
+			if err := updated.validate(); err != nil {
+				return nil, err
+			}
+			if updated.Anchor.IsZero() && dueAt != nil {
+				updated.Anchor = *dueAt
+			}
+			recurrence = &updated
+		}
+	}
+	if recurrence != nil && dueAt == nil {
+		return nil, errs.New(errs.Validation, "a recurring objective needs a due date")
+	}
+
 	// Prepare result data for storage
 	var resultData map[string]interface{}
 	if result != nil {
@@ -331,6 +422,10 @@ func (om *ObjectiveManager) UpdateObjective(ctx context.Context, objectiveID str
 		"started_at":   startedAtStr,
 		"completed_at": completedAtStr,
 	}
+	setDurationData(data, "max_execution_time", maxExecutionTime)
+	setDurationData(data, "active_execution_time", activeExecutionTime)
+	setDurationData(data, "approval_wait_time", approvalWaitTime)
+	setScheduleData(data, dueAt, recurrence, currentObjective.RecurrenceOf)
 
 	// Update in storage
 	if err := om.store.UpdateNode(ctx, objectiveID, data); err != nil {
@@ -351,7 +446,15 @@ func (om *ObjectiveManager) UpdateObjective(ctx context.Context, objectiveID str
 		CreatedAt:   currentObjective.CreatedAt,
 		StartedAt:   startedAt,
 		CompletedAt: completedAt,
-		store:       om.store,
+
+		MaxExecutionTime:    maxExecutionTime,
+		ActiveExecutionTime: activeExecutionTime,
+		ApprovalWaitTime:    approvalWaitTime,
+
+		DueAt:        dueAt,
+		Recurrence:   recurrence,
+		RecurrenceOf: currentObjective.RecurrenceOf,
+		store:        om.store,
 	}, nil
 }
 
@@ -368,11 +471,25 @@ type ObjectiveUpdates struct {
 	Priority    *int
 	StartedAt   *time.Time
 	CompletedAt *time.Time
+
+	MaxExecutionTime    *time.Duration
+	ActiveExecutionTime *time.Duration
+	ApprovalWaitTime    *time.Duration
+
+	// DueAt sets the due time; a zero time clears it
+	DueAt *time.Time
+
+	// Recurrence sets how the objective repeats; one without a frequency
+	// stops it repeating. The anchor defaults to the due time.
+	Recurrence *Recurrence
 }
 
 // ListObjectives returns all objectives with optional filtering.
 func (om *ObjectiveManager) ListObjectives(ctx context.Context, filter ObjectiveFilter) ([]*Objective, error) {
 	query := om.store.Nodes().OfType("objective")
+	if filter.IncludeArchived {
+		query = query.IncludeArchived()
+	}
 
 	// Apply status filter if specified
 	if filter.Status != nil {
@@ -389,7 +506,7 @@ func (om *ObjectiveManager) ListObjectives(ctx context.Context, filter Objective
 		query = query.WithData("method_id", *filter.MethodID)
 	}
 
-	nodes, err := query.All()
+	nodes, err := query.AllContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query objectives: %w", err)
 	}
@@ -422,29 +539,42 @@ type ObjectiveFilter struct {
 	MethodID    *string
 	MinPriority *int
 	MaxPriority *int
+
+	// IncludeArchived also lists objectives moved to the archive (see ArchiveManager)
+	IncludeArchived bool
 }
 
 // StartObjective begins work on an objective by changing its status to in_progress.
-func (om *ObjectiveManager) StartObjective(ctx context.Context, objectiveID string) (*Objective, error) {
+// It returns a *WIPLimitError, matching ErrWIPLimitReached, when the objective
+// would exceed the work-in-progress limits, unless options override them.
+func (om *ObjectiveManager) StartObjective(ctx context.Context, objectiveID string, options ...StartOptions) (*Objective, error) {
 	objective, err := om.GetObjective(ctx, objectiveID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get objective: %w", err)
 	}
 
 	if objective.Status != ObjectiveStatusPending {
-		return nil, fmt.Errorf("can only start pending objectives, current status: %s", objective.Status)
+		return nil, errs.Newf(errs.Conflict, "can only start pending objectives, current status: %s", objective.Status).With("status", string(objective.Status))
+	}
+
+	overrideContext, err := om.admit(ctx, objective, options)
+	if err != nil {
+		return nil, err
 	}
 
 	now := time.Now()
 	updates := ObjectiveUpdates{
 		Status:    &[]ObjectiveStatus{ObjectiveStatusInProgress}[0],
 		StartedAt: &now,
+		Context:   overrideContext,
 	}
 
 	return om.UpdateObjective(ctx, objectiveID, updates)
 }
 
 // CompleteObjective marks an objective as completed with the given result.
+// A recurring objective's next occurrence is created, pending, whether it
+// succeeded or not.
 func (om *ObjectiveManager) CompleteObjective(ctx context.Context, objectiveID string, result ObjectiveResult) (*Objective, error) {
 	objective, err := om.GetObjective(ctx, objectiveID)
 	if err != nil {
@@ -452,7 +582,7 @@ func (om *ObjectiveManager) CompleteObjective(ctx context.Context, objectiveID s
 	}
 
 	if objective.Status != ObjectiveStatusInProgress {
-		return nil, fmt.Errorf("can only complete in-progress objectives, current status: %s", objective.Status)
+		return nil, errs.Newf(errs.Conflict, "can only complete in-progress objectives, current status: %s", objective.Status).With("status", string(objective.Status))
 	}
 
 	now := time.Now()
@@ -474,7 +604,16 @@ func (om *ObjectiveManager) CompleteObjective(ctx context.Context, objectiveID s
 		CompletedAt: &now,
 	}
 
-	return om.UpdateObjective(ctx, objectiveID, updates)
+	completed, err := om.UpdateObjective(ctx, objectiveID, updates)
+	if err != nil {
+		return nil, err
+	}
+	if completed.Recurrence != nil {
+		// An occurrence that cannot be created now is created by the next
+		// ObjectiveScheduler.Reconcile
+		_, _ = NewObjectiveScheduler(om).materialize(ctx, completed)
+	}
+	return completed, nil
 }
 
 // FailObjective marks an objective as failed with the given error information.
@@ -496,7 +635,7 @@ func (om *ObjectiveManager) PauseObjective(ctx context.Context, objectiveID stri
 	}
 
 	if objective.Status != ObjectiveStatusInProgress {
-		return nil, fmt.Errorf("can only pause in-progress objectives, current status: %s", objective.Status)
+		return nil, errs.Newf(errs.Conflict, "can only pause in-progress objectives, current status: %s", objective.Status).With("status", string(objective.Status))
 	}
 
 	status := ObjectiveStatusPaused
@@ -507,75 +646,87 @@ func (om *ObjectiveManager) PauseObjective(ctx context.Context, objectiveID stri
 	return om.UpdateObjective(ctx, objectiveID, updates)
 }
 
-// ResumeObjective resumes work on a paused objective.
-func (om *ObjectiveManager) ResumeObjective(ctx context.Context, objectiveID string) (*Objective, error) {
+// ResumeObjective resumes work on a paused objective. Like StartObjective it
+// respects the work-in-progress limits unless options override them.
+func (om *ObjectiveManager) ResumeObjective(ctx context.Context, objectiveID string, options ...StartOptions) (*Objective, error) {
 	objective, err := om.GetObjective(ctx, objectiveID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get objective: %w", err)
 	}
 
 	if objective.Status != ObjectiveStatusPaused {
-		return nil, fmt.Errorf("can only resume paused objectives, current status: %s", objective.Status)
+		return nil, errs.Newf(errs.Conflict, "can only resume paused objectives, current status: %s", objective.Status).With("status", string(objective.Status))
+	}
+
+	overrideContext, err := om.admit(ctx, objective, options)
+	if err != nil {
+		return nil, err
 	}
 
 	status := ObjectiveStatusInProgress
 	updates := ObjectiveUpdates{
-		Status: &status,
+		Status:  &status,
+		Context: overrideContext,
 	}
 
 	return om.UpdateObjective(ctx, objectiveID, updates)
 }
 
-// GetObjectivesForGoal returns all objectives that serve the given goal.
-func (om *ObjectiveManager) GetObjectivesForGoal(ctx context.Context, goalID string) ([]*Objective, error) {
-	// Find all edges of type "serves" targeting the goal
-	edges, err := om.store.Edges().OfType("serves").ToNode(goalID).All()
+// CancelObjective cancels a pending or paused objective that is no longer
+// wanted. Unlike failing it, cancelling says nothing about its method.
+func (om *ObjectiveManager) CancelObjective(ctx context.Context, objectiveID string) (*Objective, error) {
+	objective, err := om.GetObjective(ctx, objectiveID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query objective-goal relationships: %w", err)
+		return nil, fmt.Errorf("failed to get objective: %w", err)
 	}
 
-	var objectives []*Objective
-	for _, edge := range edges {
-		// Check if the source is an objective
-		node, err := om.store.GetNode(ctx, edge.SourceID)
-		if err != nil || node.Type != "objective" {
-			continue // Skip if not an objective or doesn't exist
-		}
-
-		objective, err := om.nodeToObjective(node)
-		if err != nil {
-			continue // Skip invalid objectives
-		}
-		objectives = append(objectives, objective)
+	if objective.Status != ObjectiveStatusPending && objective.Status != ObjectiveStatusPaused {
+		return nil, errs.Newf(errs.Conflict, "can only cancel pending or paused objectives, current status: %s", objective.Status).With("status", string(objective.Status))
 	}
 
-	return objectives, nil
+	status := ObjectiveStatusCancelled
+	return om.UpdateObjective(ctx, objectiveID, ObjectiveUpdates{Status: &status})
+}
+
+// GetObjectivesForGoal returns all objectives that serve the given goal.
+func (om *ObjectiveManager) GetObjectivesForGoal(ctx context.Context, goalID string) ([]*Objective, error) {
+	// Find the objectives with "serves" edges to the goal
+	neighbors, err := om.store.GetNeighbors(ctx, goalID, storage.NeighborOptions{
+		Direction: storage.DirectionIncoming,
+		EdgeTypes: []string{"serves"},
+		NodeTypes: []string{"objective"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query objective-goal relationships: %w", err)
+	}
+	return om.nodesToObjectives(neighbors.Nodes), nil
 }
 
 // GetObjectivesUsingMethod returns all objectives that use the given method.
 func (om *ObjectiveManager) GetObjectivesUsingMethod(ctx context.Context, methodID string) ([]*Objective, error) {
-	// Find all edges of type "uses" targeting the method
-	edges, err := om.store.Edges().OfType("uses").ToNode(methodID).All()
+	// Find the objectives with "uses" edges to the method
+	neighbors, err := om.store.GetNeighbors(ctx, methodID, storage.NeighborOptions{
+		Direction: storage.DirectionIncoming,
+		EdgeTypes: []string{"uses"},
+		NodeTypes: []string{"objective"},
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to query objective-method relationships: %w", err)
 	}
+	return om.nodesToObjectives(neighbors.Nodes), nil
+}
 
+// nodesToObjectives converts objective nodes, skipping invalid ones.
+func (om *ObjectiveManager) nodesToObjectives(nodes []*storage.Node) []*Objective {
 	var objectives []*Objective
-	for _, edge := range edges {
-		// Check if the source is an objective
-		node, err := om.store.GetNode(ctx, edge.SourceID)
-		if err != nil || node.Type != "objective" {
-			continue // Skip if not an objective or doesn't exist
-		}
-
+	for _, node := range nodes {
 		objective, err := om.nodeToObjective(node)
 		if err != nil {
 			continue // Skip invalid objectives
 		}
 		objectives = append(objectives, objective)
 	}
-
-	return objectives, nil
+	return objectives
 }
 
 // nodeToObjective converts a storage node to an Objective object.
@@ -671,19 +822,9 @@ func (om *ObjectiveManager) nodeToObjective(node *storage.Node) (*Objective, err
 	}
 
 	// Parse optional time fields
-	var startedAt *time.Time
-	if startedAtStr, ok := node.Data["started_at"].(string); ok && startedAtStr != "" {
-		if parsed, err := time.Parse(time.RFC3339, startedAtStr); err == nil {
-			startedAt = &parsed
-		}
-	}
-
-	var completedAt *time.Time
-	if completedAtStr, ok := node.Data["completed_at"].(string); ok && completedAtStr != "" {
-		if parsed, err := time.Parse(time.RFC3339, completedAtStr); err == nil {
-			completedAt = &parsed
-		}
-	}
+	startedAt := parseOptionalTime(node.Data["started_at"])
+	completedAt := parseOptionalTime(node.Data["completed_at"])
+	recurrenceOf, _ := node.Data[recurrenceOfKey].(string)
 
 	return &Objective{
 		ID:          node.ID,
@@ -698,14 +839,22 @@ func (om *ObjectiveManager) nodeToObjective(node *storage.Node) (*Objective, err
 		CreatedAt:   createdAt,
 		StartedAt:   startedAt,
 		CompletedAt: completedAt,
-		store:       om.store,
+
+		MaxExecutionTime:    parseDurationData(node.Data["max_execution_time"]),
+		ActiveExecutionTime: parseDurationData(node.Data["active_execution_time"]),
+		ApprovalWaitTime:    parseDurationData(node.Data["approval_wait_time"]),
+
+		DueAt:        parseOptionalTime(node.Data["due_at"]),
+		Recurrence:   parseRecurrenceData(node.Data["recurrence"]),
+		RecurrenceOf: recurrenceOf,
+		store:        om.store,
 	}, nil
 }
 
 // isValidObjectiveStatus checks if an objective status is valid.
 func isValidObjectiveStatus(status ObjectiveStatus) bool {
 	switch status {
-	case ObjectiveStatusPending, ObjectiveStatusInProgress, ObjectiveStatusCompleted, ObjectiveStatusFailed, ObjectiveStatusPaused:
+	case ObjectiveStatusPending, ObjectiveStatusInProgress, ObjectiveStatusCompleted, ObjectiveStatusFailed, ObjectiveStatusPaused, ObjectiveStatusCancelled:
 		return true
 	default:
 		return false
@@ -742,6 +891,11 @@ func (o *Objective) IsPaused() bool {
 	return o.Status == ObjectiveStatusPaused
 }
 
+// IsCancelled returns true if the objective was cancelled.
+func (o *Objective) IsCancelled() bool {
+	return o.Status == ObjectiveStatusCancelled
+}
+
 // IsFinished returns true if the objective has completed (either success or failure).
 func (o *Objective) IsFinished() bool {
 	return o.Status == ObjectiveStatusCompleted || o.Status == ObjectiveStatusFailed
@@ -847,4 +1001,48 @@ func (o *Objective) Resume(ctx context.Context) error {
 	// Update this instance with the new values
 	*o = *updatedObjective
 	return nil
-}
\ No newline at end of file
+}
+
+// parseOptionalTime parses an optional RFC3339 time field. Nodes that have
+// not been reloaded from disk hold the *string written by UpdateObjective.
+func parseOptionalTime(value interface{}) *time.Time {
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case *string:
+		if v != nil {
+			str = *v
+		}
+	}
+	if str == "" {
+		return nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+// setDurationData stores a non-zero duration field as a duration string, so
+// objectives without a time box keep their existing data unchanged.
+func setDurationData(data map[string]interface{}, key string, d time.Duration) {
+	if d != 0 {
+		data[key] = d.String()
+	}
+}
+
+// parseDurationData parses a duration field written by setDurationData.
+func parseDurationData(value interface{}) time.Duration {
+	str, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,670 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+const (
+	// BriefTaskType is the task type polish requests are routed and billed under
+	BriefTaskType = "reporting"
+
+	// delegationContextKey is where an objective's delegation is recorded
+	delegationContextKey = "delegation"
+
+	// attachmentsContextKey lists the artifact references handed over with a brief
+	attachmentsContextKey = "attachments"
+
+	// briefDateLayout is the date format used throughout the brief
+	briefDateLayout = "2006-01-02"
+)
+
+// BriefFormat selects how a brief is rendered.
+type BriefFormat string
+
+const (
+	// BriefFormatMarkdown renders the brief as a readable markdown document
+	BriefFormatMarkdown BriefFormat = "markdown"
+
+	// BriefFormatJSON renders the brief as indented JSON for other tools
+	BriefFormatJSON BriefFormat = "json"
+)
+
+// BriefOptions controls what GenerateBrief includes.
+type BriefOptions struct {
+	// Audience describes who the brief is written for
+	Audience string
+
+	// Format selects markdown or JSON output (default: markdown)
+	Format BriefFormat
+
+	// IncludeContext adds the objective's resolved context, redacted and capped
+	IncludeContext bool
+
+	// ContextLoader resolves the objective's full context (optional; without it
+	// only the context stored with the objective is used)
+	ContextLoader ContextLoader
+
+	// MaxContextBytes caps the total size of the context values in the brief
+	MaxContextBytes int
+
+	// UserID selects whose constraints are listed (empty: every user's)
+	UserID string
+
+	// Polish asks an LLM to write a short summary that opens the brief.
+	// This is the only part of the brief that makes LLM calls.
+	Polish bool
+
+	// Router routes the polish request (required when Polish is set)
+	Router *llm.Router
+
+	// Budget records polish spending under BriefTaskType (optional)
+	Budget *llm.BudgetManager
+
+	// PolishMaxTokens caps the length of the polish response
+	PolishMaxTokens int
+
+	// PolishBudget is the most the polish request may cost, in dollars
+	PolishBudget float64
+}
+
+// DefaultBriefOptions returns options for a markdown brief with context and no polish.
+func DefaultBriefOptions() BriefOptions {
+	return BriefOptions{
+		Audience:        "an external contractor",
+		Format:          BriefFormatMarkdown,
+		IncludeContext:  true,
+		MaxContextBytes: 4000,
+		PolishMaxTokens: 400,
+		PolishBudget:    0.02,
+	}
+}
+
+// BriefGoal is the parent goal's framing of the delegated work.
+type BriefGoal struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// BriefContextEntry is one resolved context value, already redacted.
+type BriefContextEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Brief packages everything needed to hand an objective to someone outside
+// the system. Every text field has had secrets redacted.
+type Brief struct {
+	ObjectiveID string    `json:"objective_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Audience    string    `json:"audience,omitempty"`
+	Priority    int       `json:"priority"`
+	Goal        BriefGoal `json:"goal"`
+
+	// Summary is the LLM-written opening (empty unless polish was requested and succeeded)
+	Summary string `json:"summary,omitempty"`
+
+	// Context lists the resolved context values by key
+	Context []BriefContextEntry `json:"context,omitempty"`
+
+	// ContextTruncated is set when the size cap shortened or left out context
+	ContextTruncated bool `json:"context_truncated,omitempty"`
+
+	// OmittedContext lists the context keys left out entirely by the size cap
+	OmittedContext []string `json:"omitted_context,omitempty"`
+
+	// AcceptanceCriteria say when the work is done, taken from the goal's
+	// completion criteria or, failing that, the method's steps
+	AcceptanceCriteria []string `json:"acceptance_criteria,omitempty"`
+
+	// CriteriaSource is "goal" or "method", depending on where the criteria came from
+	CriteriaSource string `json:"criteria_source,omitempty"`
+
+	// Constraints are the user's recorded limitations and boundaries
+	Constraints []string `json:"constraints,omitempty"`
+
+	// Attachments are the artifact references handed over with the brief
+	Attachments []string `json:"attachments,omitempty"`
+
+	// Delegation records who the objective was handed to, if anyone yet
+	Delegation *Delegation `json:"delegation,omitempty"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// Format is how Render writes the brief
+	Format BriefFormat `json:"-"`
+
+	// PolishCost is what the polish request cost
+	PolishCost float64 `json:"-"`
+
+	// PolishError explains why a requested polish was left out
+	PolishError error `json:"-"`
+
+	// attachmentRefs are the unredacted attachment references, for export
+	attachmentRefs []string
+}
+
+// Delegation records that an objective was handed to someone outside the
+// system. A delegated objective keeps its status, so it still counts in
+// progress rollups, but autonomous sessions no longer select it.
+type Delegation struct {
+	To   string    `json:"to"`
+	Note string    `json:"note,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// Delegation returns the objective's delegation, or nil if it was not delegated.
+func (o *Objective) Delegation() *Delegation {
+	record, ok := o.Context[delegationContextKey].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	delegation := &Delegation{
+		To:   getString(record, "to"),
+		Note: getString(record, "note"),
+	}
+	if at, err := time.Parse(time.RFC3339, getString(record, "at")); err == nil {
+		delegation.At = at
+	}
+	return delegation
+}
+
+// IsDelegated reports whether the objective was handed to someone outside the system.
+func (o *Objective) IsDelegated() bool {
+	return o.Delegation() != nil
+}
+
+// MarkDelegated records that an objective was handed to someone outside the
+// system. Its status is left unchanged.
+func (om *ObjectiveManager) MarkDelegated(ctx context.Context, objectiveID, to, note string) (*Objective, error) {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return nil, fmt.Errorf("delegation requires who the objective is delegated to")
+	}
+
+	objective, err := om.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get objective to delegate: %w", err)
+	}
+	if objective.IsFinished() {
+		return nil, errs.Newf(errs.Conflict, "objective %s is already %s", objective.ID, objective.Status)
+	}
+
+	context := copyObjectiveContext(objective.Context)
+	context[delegationContextKey] = map[string]interface{}{
+		"to":   to,
+		"note": note,
+		"at":   time.Now().Format(time.RFC3339),
+	}
+	return om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{Context: context})
+}
+
+// ClearDelegation takes an objective back from delegation so autonomous
+// sessions may select it again.
+func (om *ObjectiveManager) ClearDelegation(ctx context.Context, objectiveID string) (*Objective, error) {
+	objective, err := om.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delegated objective: %w", err)
+	}
+	if !objective.IsDelegated() {
+		return objective, nil
+	}
+
+	context := copyObjectiveContext(objective.Context)
+	delete(context, delegationContextKey)
+	return om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{Context: context})
+}
+
+// copyObjectiveContext returns a shallow copy of an objective's context.
+func copyObjectiveContext(source map[string]interface{}) map[string]interface{} {
+	context := make(map[string]interface{}, len(source)+1)
+	for key, value := range source {
+		context[key] = value
+	}
+	return context
+}
+
+// GenerateBrief packages an objective for delegation outside the system: what
+// needs doing, the parent goal's framing, the resolved context, acceptance
+// criteria, the user's constraints and the attached artifacts. Secrets are
+// always redacted and the context is capped at MaxContextBytes.
+// A polish that cannot be produced is reported in PolishError rather than
+// failing the whole brief.
+func (om *ObjectiveManager) GenerateBrief(ctx context.Context, objectiveID string, opts BriefOptions) (*Brief, error) {
+	if opts.Polish && opts.Router == nil {
+		return nil, fmt.Errorf("brief polish requested but no LLM router is configured")
+	}
+	if opts.Format == "" {
+		opts.Format = BriefFormatMarkdown
+	}
+	if opts.Format != BriefFormatMarkdown && opts.Format != BriefFormatJSON {
+		return nil, fmt.Errorf("unknown brief format: %s", opts.Format)
+	}
+	if opts.MaxContextBytes <= 0 {
+		opts.MaxContextBytes = DefaultBriefOptions().MaxContextBytes
+	}
+
+	objective, err := om.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get objective for brief: %w", err)
+	}
+	goal, err := NewGoalManager(om.store).GetGoal(ctx, objective.GoalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goal for brief: %w", err)
+	}
+
+	brief := &Brief{
+		ObjectiveID: objective.ID,
+		Title:       llm.RedactSecrets(objective.Title),
+		Description: llm.RedactSecrets(objective.Description),
+		Audience:    opts.Audience,
+		Priority:    objective.Priority,
+		Goal: BriefGoal{
+			ID:          goal.ID,
+			Title:       llm.RedactSecrets(goal.Title),
+			Description: llm.RedactSecrets(goal.Description),
+		},
+		GeneratedAt: time.Now(),
+		Format:      opts.Format,
+	}
+
+	if delegation := objective.Delegation(); delegation != nil {
+		delegation.Note = llm.RedactSecrets(delegation.Note)
+		brief.Delegation = delegation
+	}
+
+	if opts.IncludeContext {
+		context, err := om.briefContext(ctx, objective, opts.ContextLoader)
+		if err != nil {
+			return nil, err
+		}
+		brief.Context, brief.OmittedContext, brief.ContextTruncated = capBriefContext(context, opts.MaxContextBytes)
+	}
+
+	brief.AcceptanceCriteria, brief.CriteriaSource = om.briefAcceptanceCriteria(ctx, objective, goal)
+
+	constraints, err := NewUserContextManager(om.store).GetContextByCategory(ctx, ContextCategoryConstraints, opts.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load constraints for brief: %w", err)
+	}
+	for _, constraint := range constraints {
+		brief.Constraints = append(brief.Constraints, llm.RedactSecrets(constraint.Content))
+	}
+
+	brief.attachmentRefs = toStringSlice(objective.Context[attachmentsContextKey])
+	for _, ref := range brief.attachmentRefs {
+		brief.Attachments = append(brief.Attachments, llm.RedactSecrets(ref))
+	}
+
+	if opts.Polish {
+		summary, cost, err := generateBriefSummary(ctx, brief, opts)
+		if err != nil {
+			brief.PolishError = err
+		} else {
+			brief.Summary = summary
+			brief.PolishCost = cost
+		}
+	}
+
+	return brief, nil
+}
+
+// briefContext gathers the objective's context, resolved through the loader
+// when one is given, as redacted entries sorted by key. Bookkeeping kept in
+// the context, such as the delegation record, is left out.
+func (om *ObjectiveManager) briefContext(ctx context.Context, objective *Objective, loader ContextLoader) ([]BriefContextEntry, error) {
+	context := copyObjectiveContext(objective.Context)
+	if loader != nil {
+		resolved, err := loader.LoadObjectiveContext(ctx, objective.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve objective context: %w", err)
+		}
+		for key, value := range resolved {
+			context[key] = value
+		}
+	}
+	for _, key := range []string{delegationContextKey, attachmentsContextKey, "wip_override", timeBoxContextKey} {
+		delete(context, key)
+	}
+
+	entries := make([]BriefContextEntry, 0, len(context))
+	for key, value := range context {
+		entries = append(entries, BriefContextEntry{Key: key, Value: redactBriefValue(key, value)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+// briefAcceptanceCriteria prefers the goal's recorded completion criteria and
+// falls back to one criterion per method step.
+func (om *ObjectiveManager) briefAcceptanceCriteria(ctx context.Context, objective *Objective, goal *Goal) ([]string, string) {
+	var criteria []string
+	switch completion := goal.UserContext["completion_criteria"].(type) {
+	case string:
+		if strings.TrimSpace(completion) != "" {
+			criteria = append(criteria, completion)
+		}
+	default:
+		criteria = toStringSlice(completion)
+	}
+	if len(criteria) > 0 {
+		for i := range criteria {
+			criteria[i] = llm.RedactSecrets(criteria[i])
+		}
+		return criteria, "goal"
+	}
+
+	if objective.MethodID == "" {
+		return nil, ""
+	}
+	method, err := NewMethodManager(om.store).GetMethod(ctx, objective.MethodID)
+	if err != nil {
+		return nil, "" // Method no longer exists; the brief asks for criteria instead
+	}
+	for _, step := range method.Approach {
+		criteria = append(criteria, llm.RedactSecrets(step.Description))
+	}
+	if len(criteria) == 0 {
+		return nil, ""
+	}
+	return criteria, "method"
+}
+
+// redactBriefValue renders a context value as text with its secrets removed.
+// Values held under secret-sounding names are dropped whole, at any depth.
+func redactBriefValue(key string, value interface{}) string {
+	if llm.IsSecretName(key) {
+		return "[REDACTED]"
+	}
+	value = redactStructuredSecrets(value)
+	if text, ok := value.(string); ok {
+		return llm.RedactSecrets(text)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return llm.RedactSecrets(fmt.Sprintf("%v", value))
+	}
+	return llm.RedactSecrets(string(data))
+}
+
+// redactStructuredSecrets returns a copy of value with the fields under
+// secret-sounding names replaced by [REDACTED].
+func redactStructuredSecrets(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			if llm.IsSecretName(key) {
+				redacted[key] = "[REDACTED]"
+			} else {
+				redacted[key] = redactStructuredSecrets(item)
+			}
+		}
+		return redacted
+	case map[string]string:
+		redacted := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			if llm.IsSecretName(key) {
+				redacted[key] = "[REDACTED]"
+			} else {
+				redacted[key] = item
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(typed))
+		for i, item := range typed {
+			redacted[i] = redactStructuredSecrets(item)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// capBriefContext keeps entries in order until their values reach maxBytes,
+// shortening the entry that crosses the cap and leaving out the rest.
+func capBriefContext(entries []BriefContextEntry, maxBytes int) (kept []BriefContextEntry, omitted []string, truncated bool) {
+	remaining := maxBytes
+	for _, entry := range entries {
+		if remaining <= 0 {
+			omitted = append(omitted, entry.Key)
+			truncated = true
+			continue
+		}
+		if len(entry.Value) > remaining {
+			cut := remaining
+			for cut > 0 && !utf8.RuneStart(entry.Value[cut]) {
+				cut--
+			}
+			entry.Value = fmt.Sprintf("%s…[%d bytes omitted]", entry.Value[:cut], len(entry.Value)-cut)
+			truncated = true
+			remaining = 0
+		} else {
+			remaining -= len(entry.Value)
+		}
+		kept = append(kept, entry)
+	}
+	return kept, omitted, truncated
+}
+
+// Render returns the brief in its format.
+func (b *Brief) Render() (string, error) {
+	if b.Format == BriefFormatJSON {
+		data, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode brief: %w", err)
+		}
+		return string(data) + "\n", nil
+	}
+	return b.markdown(), nil
+}
+
+// WriteTo writes the rendered brief to w.
+func (b *Brief) WriteTo(w io.Writer) (int64, error) {
+	text, err := b.Render()
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.WriteString(w, text)
+	return int64(n), err
+}
+
+// ExportAttachments copies the attachments that are local files into dir and
+// returns the paths written. References to anything else, such as URLs, stay
+// listed in the brief for the recipient to fetch.
+func (b *Brief) ExportAttachments(dir string) ([]string, error) {
+	var exported []string
+	for _, ref := range b.attachmentRefs {
+		source := strings.TrimPrefix(ref, "file://")
+		info, err := os.Stat(source)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return exported, fmt.Errorf("failed to create attachment directory: %w", err)
+		}
+		target := filepath.Join(dir, filepath.Base(source))
+		if err := copyBriefAttachment(source, target); err != nil {
+			return exported, err
+		}
+		exported = append(exported, target)
+	}
+	return exported, nil
+}
+
+// copyBriefAttachment copies one attachment file.
+func copyBriefAttachment(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open attachment: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return fmt.Errorf("failed to create attachment copy: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy attachment: %w", err)
+	}
+	return out.Close()
+}
+
+// markdown renders the brief as a markdown document.
+func (b *Brief) markdown() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "# Brief: %s\n\n", b.Title)
+	fmt.Fprintf(&s, "- **Objective ID:** `%s`\n", b.ObjectiveID)
+	if b.Audience != "" {
+		fmt.Fprintf(&s, "- **Prepared for:** %s\n", b.Audience)
+	}
+	fmt.Fprintf(&s, "- **Priority:** %d/10\n", b.Priority)
+	if b.Delegation != nil {
+		fmt.Fprintf(&s, "- **Delegated to:** %s on %s\n", b.Delegation.To, b.Delegation.At.Format(briefDateLayout))
+	}
+	fmt.Fprintf(&s, "- **Generated:** %s\n\n", b.GeneratedAt.Format(briefDateLayout))
+
+	if b.Summary != "" {
+		s.WriteString("## Summary\n\n")
+		s.WriteString(b.Summary + "\n\n")
+	}
+
+	s.WriteString("## What Needs Doing\n\n")
+	if b.Description != "" {
+		s.WriteString(b.Description + "\n\n")
+	} else {
+		s.WriteString("_No description recorded beyond the title._\n\n")
+	}
+
+	fmt.Fprintf(&s, "## Why It Matters\n\nThis work serves the goal **%s**.", b.Goal.Title)
+	if b.Goal.Description != "" {
+		s.WriteString(" " + b.Goal.Description)
+	}
+	s.WriteString("\n\n")
+
+	s.WriteString("## Acceptance Criteria\n\n")
+	if len(b.AcceptanceCriteria) == 0 {
+		s.WriteString("_None recorded; agree on them before starting._\n\n")
+	} else {
+		if b.CriteriaSource == "method" {
+			s.WriteString("The work is done when each of these steps has been carried out:\n\n")
+		}
+		for _, criterion := range b.AcceptanceCriteria {
+			fmt.Fprintf(&s, "- [ ] %s\n", criterion)
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString("## Constraints\n\n")
+	writeBriefList(&s, b.Constraints, "_None recorded._")
+
+	if b.Context != nil || b.OmittedContext != nil {
+		s.WriteString("## Context\n\n")
+		for _, entry := range b.Context {
+			if strings.Contains(entry.Value, "\n") {
+				fmt.Fprintf(&s, "- **%s:**\n\n```\n%s\n```\n\n", entry.Key, entry.Value)
+			} else {
+				fmt.Fprintf(&s, "- **%s:** %s\n", entry.Key, entry.Value)
+			}
+		}
+		if len(b.OmittedContext) > 0 {
+			fmt.Fprintf(&s, "\n_Context was capped; left out: %s._\n", strings.Join(b.OmittedContext, ", "))
+		} else if b.ContextTruncated {
+			s.WriteString("\n_Context was capped; the last value is shortened._\n")
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString("## Attachments\n\n")
+	var refs []string
+	for _, attachment := range b.Attachments {
+		refs = append(refs, "`"+attachment+"`")
+	}
+	writeBriefList(&s, refs, "_None._")
+
+	return s.String()
+}
+
+// writeBriefList writes items as a bullet list, or the placeholder if there are none.
+func writeBriefList(s *strings.Builder, items []string, placeholder string) {
+	if len(items) == 0 {
+		s.WriteString(placeholder + "\n\n")
+		return
+	}
+	for _, item := range items {
+		fmt.Fprintf(s, "- %s\n", item)
+	}
+	s.WriteString("\n")
+}
+
+// generateBriefSummary asks an LLM for a short opening summary of the brief.
+// It only sees the brief as already redacted and capped.
+func generateBriefSummary(ctx context.Context, brief *Brief, opts BriefOptions) (string, float64, error) {
+	maxTokens := opts.PolishMaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultBriefOptions().PolishMaxTokens
+	}
+	budget := opts.PolishBudget
+	if budget <= 0 {
+		budget = DefaultBriefOptions().PolishBudget
+	}
+
+	audience := opts.Audience
+	if audience == "" {
+		audience = "someone outside the team"
+	}
+	prompt := fmt.Sprintf(`Write a short, plain opening paragraph for the delegation brief below, addressed to %s.
+Say what is being asked, why it matters and how the recipient will know it is done.
+Use only facts from the brief. Do not add requirements, deadlines, or numbers that are not listed.
+
+%s`, audience, brief.markdown())
+
+	result, err := opts.Router.Route(ctx, llm.TaskRequest{
+		Prompt:           prompt,
+		MaxTokens:        maxTokens,
+		Temperature:      0.3,
+		TaskType:         BriefTaskType,
+		QualityRequired:  llm.QualityStandard,
+		BudgetConstraint: &budget,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("LLM routing failed: %w", err)
+	}
+	if result.ExecutionResult == nil {
+		return "", 0, fmt.Errorf("no result from LLM execution")
+	}
+
+	completion := result.ExecutionResult
+	if opts.Budget != nil {
+		if err := opts.Budget.RecordUsage(ctx, llm.Transaction{
+			Provider:   result.SelectedModel.Provider,
+			Model:      result.SelectedModel.Model,
+			TaskType:   BriefTaskType,
+			TokensUsed: completion.TokensUsed,
+			Cost:       completion.Cost,
+			Success:    true,
+		}); err != nil {
+			return "", 0, fmt.Errorf("failed to record polish cost: %w", err)
+		}
+	}
+
+	return llm.RedactSecrets(strings.TrimSpace(completion.Text)), completion.Cost, nil
+}
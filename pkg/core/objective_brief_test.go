@@ -0,0 +1,323 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+// setupBriefTest creates an objective under a goal and a two-step method.
+func setupBriefTest(t *testing.T, objectiveContext map[string]interface{}) (*ObjectiveManager, *Objective) {
+	t.Helper()
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Launch the website", "Get the new marketing site live before the conference", 7, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Design Review", "", []ApproachStep{
+		{Description: "Review the landing page copy"},
+		{Description: "Check the layout on mobile"},
+	}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+
+	om := NewObjectiveManager(store)
+	objective, err := om.CreateObjective(ctx, goal.ID, method.ID, "Review the landing page",
+		"Review the copy and layout. Staging login: password: hunter2", objectiveContext, 6)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	return om, objective
+}
+
+func TestGenerateBrief_CompleteWithoutLLM(t *testing.T) {
+	om, objective := setupBriefTest(t, map[string]interface{}{
+		"staging_url": "https://staging.example.com",
+		"attachments": []string{"drafts/landing-copy.md"},
+	})
+	ctx := context.Background()
+
+	if _, err := NewUserContextManager(om.store).LearnContext(ctx, ContextCategoryConstraints,
+		"No changes to the pricing section", ContextSourceExplicit, nil, "user-1"); err != nil {
+		t.Fatalf("Failed to create constraint: %v", err)
+	}
+
+	brief, err := om.GenerateBrief(ctx, objective.ID, DefaultBriefOptions())
+	if err != nil {
+		t.Fatalf("Failed to generate brief: %v", err)
+	}
+	markdown, err := brief.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"# Brief: Review the landing page",
+		"Prepared for:** an external contractor",
+		"This work serves the goal **Launch the website**. Get the new marketing site live",
+		"- [ ] Review the landing page copy",
+		"- [ ] Check the layout on mobile",
+		"- No changes to the pricing section",
+		"- **staging_url:** https://staging.example.com",
+		"`drafts/landing-copy.md`",
+	} {
+		if !strings.Contains(markdown, expected) {
+			t.Errorf("Expected the brief to contain %q, got:\n%s", expected, markdown)
+		}
+	}
+	if brief.CriteriaSource != "method" {
+		t.Errorf("Expected criteria from the method's steps, got %q", brief.CriteriaSource)
+	}
+	if strings.Contains(markdown, "## Summary") || strings.Contains(markdown, "## Context\n\n- **attachments") {
+		t.Errorf("Expected no summary and no bookkeeping in the context, got:\n%s", markdown)
+	}
+}
+
+func TestGenerateBrief_RedactsSecrets(t *testing.T) {
+	om, objective := setupBriefTest(t, map[string]interface{}{
+		"deploy_notes": "Use token=ghp_abcdefghijklmnopqrstuvwx to push",
+		"api_key":      "plain-value-without-pattern",
+		"service": map[string]interface{}{
+			"host":          "db.internal",
+			"client_secret": "s3cr3t-value",
+		},
+	})
+
+	for _, format := range []BriefFormat{BriefFormatMarkdown, BriefFormatJSON} {
+		opts := DefaultBriefOptions()
+		opts.Format = format
+		brief, err := om.GenerateBrief(context.Background(), objective.ID, opts)
+		if err != nil {
+			t.Fatalf("Failed to generate %s brief: %v", format, err)
+		}
+		rendered, err := brief.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, secret := range []string{"hunter2", "ghp_abcdefghijklmnopqrstuvwx", "plain-value-without-pattern", "s3cr3t-value"} {
+			if strings.Contains(rendered, secret) {
+				t.Errorf("Expected %q to be redacted from the %s brief", secret, format)
+			}
+		}
+		if !strings.Contains(rendered, "db.internal") {
+			t.Errorf("Expected non-secret fields to survive redaction in the %s brief", format)
+		}
+	}
+}
+
+func TestGenerateBrief_CapsContext(t *testing.T) {
+	om, objective := setupBriefTest(t, map[string]interface{}{
+		"a_small":  "short note",
+		"b_large":  strings.Repeat("x", 500),
+		"c_spare":  "never reached",
+		"d_spare2": "never reached either",
+	})
+
+	opts := DefaultBriefOptions()
+	opts.MaxContextBytes = 100
+	brief, err := om.GenerateBrief(context.Background(), objective.ID, opts)
+	if err != nil {
+		t.Fatalf("Failed to generate brief: %v", err)
+	}
+
+	if !brief.ContextTruncated {
+		t.Error("Expected the context to be marked truncated")
+	}
+	if len(brief.Context) != 2 || brief.Context[0].Value != "short note" {
+		t.Fatalf("Expected the first value whole and the second shortened, got %+v", brief.Context)
+	}
+	if !strings.HasPrefix(brief.Context[1].Value, strings.Repeat("x", 90)) || !strings.Contains(brief.Context[1].Value, "bytes omitted") {
+		t.Errorf("Expected the large value to be cut at the cap, got %q", brief.Context[1].Value)
+	}
+	if len(brief.OmittedContext) != 2 {
+		t.Errorf("Expected the remaining keys to be listed as omitted, got %v", brief.OmittedContext)
+	}
+
+	total := 0
+	for _, entry := range brief.Context {
+		total += len(strings.Split(entry.Value, "…")[0])
+	}
+	if total > opts.MaxContextBytes {
+		t.Errorf("Expected at most %d bytes of context, got %d", opts.MaxContextBytes, total)
+	}
+
+	markdown, _ := brief.Render()
+	if !strings.Contains(markdown, "left out: c_spare, d_spare2") {
+		t.Errorf("Expected the brief to say what was left out, got:\n%s", markdown)
+	}
+}
+
+func TestGenerateBrief_GoalCriteriaAndJSON(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Ship v2", "", 5, map[string]interface{}{
+		"completion_criteria": []interface{}{"All tests pass", "Release notes published"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Release", "", []ApproachStep{{Description: "Draft notes"}}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	om := NewObjectiveManager(store)
+	objective, err := om.CreateObjective(ctx, goal.ID, method.ID, "Write release notes", "", nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultBriefOptions()
+	opts.Format = BriefFormatJSON
+	opts.IncludeContext = false
+	brief, err := om.GenerateBrief(ctx, objective.ID, opts)
+	if err != nil {
+		t.Fatalf("Failed to generate brief: %v", err)
+	}
+	rendered, err := brief.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Brief
+	if err := json.Unmarshal([]byte(rendered), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v:\n%s", err, rendered)
+	}
+	if decoded.CriteriaSource != "goal" || len(decoded.AcceptanceCriteria) != 2 || decoded.AcceptanceCriteria[1] != "Release notes published" {
+		t.Errorf("Expected the goal's completion criteria, got %v from %q", decoded.AcceptanceCriteria, decoded.CriteriaSource)
+	}
+	if decoded.Goal.Title != "Ship v2" || decoded.Context != nil {
+		t.Errorf("Expected the goal framing and no context, got %+v", decoded)
+	}
+
+	if _, err := om.GenerateBrief(ctx, objective.ID, BriefOptions{Format: "pdf"}); err == nil {
+		t.Error("Expected an unknown format to be refused")
+	}
+	if _, err := om.GenerateBrief(ctx, objective.ID, BriefOptions{Polish: true}); err == nil {
+		t.Error("Expected polish without a router to be refused")
+	}
+}
+
+func TestGenerateBrief_Polish(t *testing.T) {
+	om, objective := setupBriefTest(t, nil)
+	service := &scriptedClassifierService{}
+	service.script("Please review the landing page copy and mobile layout.")
+
+	opts := DefaultBriefOptions()
+	opts.Polish = true
+	opts.Router = llm.NewRouter(service)
+	brief, err := om.GenerateBrief(context.Background(), objective.ID, opts)
+	if err != nil {
+		t.Fatalf("Failed to generate brief: %v", err)
+	}
+	if brief.PolishError != nil {
+		t.Fatalf("Expected the polish to succeed: %v", brief.PolishError)
+	}
+
+	markdown, _ := brief.Render()
+	if !strings.Contains(markdown, "## Summary\n\nPlease review the landing page") {
+		t.Errorf("Expected the summary to open the brief, got:\n%s", markdown)
+	}
+	if !strings.Contains(markdown, "- [ ] Check the layout on mobile") {
+		t.Error("Expected the polished brief to keep the mechanical sections")
+	}
+	if service.calls() != 1 || strings.Contains(service.prompts[0], "hunter2") {
+		t.Error("Expected one polish request that only saw the redacted brief")
+	}
+}
+
+func TestGenerateBrief_ExportAttachments(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "spec.txt")
+	if err := os.WriteFile(source, []byte("spec"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	om, objective := setupBriefTest(t, map[string]interface{}{
+		"attachments": []string{"file://" + source, "https://example.com/mockups"},
+	})
+
+	brief, err := om.GenerateBrief(context.Background(), objective.ID, DefaultBriefOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "brief-attachments")
+	exported, err := brief.ExportAttachments(dir)
+	if err != nil {
+		t.Fatalf("Failed to export attachments: %v", err)
+	}
+	if len(exported) != 1 || exported[0] != filepath.Join(dir, "spec.txt") {
+		t.Fatalf("Expected only the local file to be exported, got %v", exported)
+	}
+	if data, err := os.ReadFile(exported[0]); err != nil || string(data) != "spec" {
+		t.Errorf("Expected the attachment to be copied, got %q, %v", data, err)
+	}
+	if len(brief.Attachments) != 2 {
+		t.Errorf("Expected both references listed in the brief, got %v", brief.Attachments)
+	}
+}
+
+func TestDelegation_ExcludedFromSelection(t *testing.T) {
+	om, gm, methodID := setupWIPTest(t)
+	ctx := context.Background()
+	_, objectives := createWIPObjectives(t, om, gm, methodID, 3)
+
+	delegated, err := om.MarkDelegated(ctx, objectives[1].ID, "Acme Contractors", "Quote accepted")
+	if err != nil {
+		t.Fatalf("Failed to delegate: %v", err)
+	}
+	if delegated.Status != ObjectiveStatusPending {
+		t.Errorf("Expected delegation to leave the status alone, got %s", delegated.Status)
+	}
+	delegation := delegated.Delegation()
+	if delegation == nil || delegation.To != "Acme Contractors" || delegation.Note != "Quote accepted" || delegation.At.IsZero() {
+		t.Fatalf("Expected who and when to be recorded, got %+v", delegation)
+	}
+
+	reloaded, err := om.ListObjectives(ctx, ObjectiveFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded) != 3 {
+		t.Errorf("Expected the delegated objective to stay listed, got %d objectives", len(reloaded))
+	}
+
+	// Both with and without work-in-progress limits, autonomous selection skips it
+	for _, limits := range []WIPLimits{{}, {MaxInProgress: 5}} {
+		om.SetWIPLimits(limits)
+		startable, deferred, err := om.SelectStartable(ctx, reloaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(startable) != 2 || len(deferred) != 0 {
+			t.Errorf("Expected two startable objectives with limits %+v, got %d startable, %d deferred", limits, len(startable), len(deferred))
+		}
+		for _, objective := range startable {
+			if objective.ID == delegated.ID {
+				t.Error("Expected the delegated objective not to be selected")
+			}
+		}
+	}
+
+	if _, err := om.MarkDelegated(ctx, objectives[0].ID, "  ", ""); err == nil {
+		t.Error("Expected a delegation without a delegate to be refused")
+	}
+
+	cleared, err := om.ClearDelegation(ctx, delegated.ID)
+	if err != nil {
+		t.Fatalf("Failed to clear delegation: %v", err)
+	}
+	if cleared.IsDelegated() {
+		t.Error("Expected the delegation to be cleared")
+	}
+	startable, _, _ := om.SelectStartable(ctx, []*Objective{cleared})
+	if len(startable) != 1 {
+		t.Error("Expected a cleared objective to be selectable again")
+	}
+}
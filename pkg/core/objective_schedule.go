@@ -0,0 +1,285 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// recurrenceOfKey holds, in a materialized objective's data, the ID of the
+// objective it recurs from. It is what keeps a series from being
+// materialized twice.
+const recurrenceOfKey = "recurrence_of"
+
+// RecurrenceFrequency is the unit a recurring objective repeats in.
+type RecurrenceFrequency string
+
+const (
+	// RecurrenceDaily repeats every Interval days
+	RecurrenceDaily RecurrenceFrequency = "DAILY"
+
+	// RecurrenceWeekly repeats every Interval weeks
+	RecurrenceWeekly RecurrenceFrequency = "WEEKLY"
+
+	// RecurrenceMonthly repeats every Interval months, on the anchor's day of
+	// the month or the month's last day when it is shorter
+	RecurrenceMonthly RecurrenceFrequency = "MONTHLY"
+)
+
+// Recurrence makes an objective repeat: when one occurrence finishes, the
+// next is created, pending, due one interval after it. Occurrences are
+// counted from the series' first due time in its time zone, so a monthly
+// objective due on the 31st returns to the 31st after a short month and a
+// daily one keeps its wall-clock time across daylight saving changes.
+type Recurrence struct {
+	// Frequency is the unit the objective repeats in
+	Frequency RecurrenceFrequency
+
+	// Interval is how many units lie between occurrences (at least 1)
+	Interval int
+
+	// TimeZone is the IANA zone the dates recur in; "" is the local zone
+	TimeZone string
+
+	// Anchor is the due time of the series' first occurrence. It defaults to
+	// the objective's due time.
+	Anchor time.Time
+
+	// Sequence counts the occurrences before this one in the series
+	Sequence int
+}
+
+// ParseRecurrence parses a recurrence rule: "daily", "weekly" or "monthly",
+// or a subset of an RFC 5545 RRULE such as "FREQ=WEEKLY;INTERVAL=2". A TZID
+// part sets the time zone the dates recur in.
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	recurrence := &Recurrence{Interval: 1}
+	trimmed := strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if !strings.Contains(trimmed, "=") {
+		recurrence.Frequency = RecurrenceFrequency(strings.ToUpper(trimmed))
+		return recurrence, recurrence.validate()
+	}
+
+	for _, part := range strings.Split(trimmed, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(strings.TrimSpace(name)) {
+		case "FREQ":
+			recurrence.Frequency = RecurrenceFrequency(strings.ToUpper(strings.TrimSpace(value)))
+		case "INTERVAL":
+			interval, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, errs.Newf(errs.Validation, "invalid recurrence interval %q", value).With("rule", rule)
+			}
+			recurrence.Interval = interval
+		case "TZID":
+			recurrence.TimeZone = strings.TrimSpace(value)
+		default:
+			return nil, errs.Newf(errs.Validation, "unsupported recurrence part %q (want FREQ, INTERVAL or TZID)", name).With("rule", rule)
+		}
+	}
+	return recurrence, recurrence.validate()
+}
+
+// String returns the recurrence as a rule ParseRecurrence reads back.
+func (r *Recurrence) String() string {
+	rule := fmt.Sprintf("FREQ=%s;INTERVAL=%d", r.Frequency, r.Interval)
+	if r.TimeZone != "" {
+		rule += ";TZID=" + r.TimeZone
+	}
+	return rule
+}
+
+// Location returns the time zone the dates recur in.
+func (r *Recurrence) Location() (*time.Location, error) {
+	if r.TimeZone == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return nil, errs.Newf(errs.Validation, "unknown time zone %q", r.TimeZone).With("time_zone", r.TimeZone)
+	}
+	return location, nil
+}
+
+// Occurrence returns the due time of the series' nth occurrence, counting the
+// anchor as the 0th.
+func (r *Recurrence) Occurrence(n int) (time.Time, error) {
+	location, err := r.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	anchor := r.Anchor.In(location)
+	steps := n * r.Interval
+	switch r.Frequency {
+	case RecurrenceDaily:
+		return anchor.AddDate(0, 0, steps), nil
+	case RecurrenceWeekly:
+		return anchor.AddDate(0, 0, 7*steps), nil
+	case RecurrenceMonthly:
+		year, month, day := anchor.Date()
+		first := time.Date(year, month+time.Month(steps), 1, 0, 0, 0, 0, location)
+		if last := first.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		return time.Date(first.Year(), first.Month(), day, anchor.Hour(), anchor.Minute(), anchor.Second(), anchor.Nanosecond(), location), nil
+	}
+	return time.Time{}, r.validate()
+}
+
+// validate checks the frequency, interval and time zone.
+func (r *Recurrence) validate() error {
+	switch r.Frequency {
+	case RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly:
+	default:
+		return errs.Newf(errs.Validation, "unsupported recurrence frequency %q (want DAILY, WEEKLY or MONTHLY)", r.Frequency)
+	}
+	if r.Interval < 1 {
+		return errs.Newf(errs.Validation, "recurrence interval must be at least 1, got %d", r.Interval)
+	}
+	_, err := r.Location()
+	return err
+}
+
+// IsOverdue reports whether the objective is unfinished past its due date.
+func (o *Objective) IsOverdue(now time.Time) bool {
+	due, ok := o.DueDate()
+	return ok && !o.IsFinished() && now.After(due)
+}
+
+// setScheduleData stores an objective's due time and recurrence in its data.
+func setScheduleData(data map[string]interface{}, dueAt *time.Time, recurrence *Recurrence, recurrenceOf string) {
+	if dueAt != nil {
+		data["due_at"] = dueAt.Format(time.RFC3339)
+	}
+	if recurrence != nil {
+		data["recurrence"] = map[string]interface{}{
+			"rule":     recurrence.String(),
+			"anchor":   recurrence.Anchor.Format(time.RFC3339),
+			"sequence": recurrence.Sequence,
+		}
+	}
+	if recurrenceOf != "" {
+		data[recurrenceOfKey] = recurrenceOf
+	}
+}
+
+// parseRecurrenceData parses a recurrence written by setScheduleData.
+func parseRecurrenceData(value interface{}) *Recurrence {
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	rule, _ := data["rule"].(string)
+	recurrence, err := ParseRecurrence(rule)
+	if err != nil {
+		return nil
+	}
+	if anchor := parseOptionalTime(data["anchor"]); anchor != nil {
+		recurrence.Anchor = *anchor
+	}
+	switch sequence := data["sequence"].(type) {
+	case float64:
+		recurrence.Sequence = int(sequence)
+	case int:
+		recurrence.Sequence = sequence
+	}
+	return recurrence
+}
+
+// materializeMu serializes materializing successors, so that an objective
+// finished twice at once still gets one.
+var materializeMu sync.Mutex
+
+// ObjectiveScheduler creates the occurrences of recurring objectives. An
+// occurrence is created when the one before it finishes, and records the ID
+// of that one, so each finished occurrence has at most one successor however
+// often it is materialized, including after a restart.
+type ObjectiveScheduler struct {
+	objectives *ObjectiveManager
+}
+
+// NewObjectiveScheduler creates a scheduler for the objectives managed by om.
+func NewObjectiveScheduler(om *ObjectiveManager) *ObjectiveScheduler {
+	return &ObjectiveScheduler{objectives: om}
+}
+
+// MaterializeNext returns the pending occurrence that follows a finished
+// recurring objective, creating it unless it already exists.
+func (s *ObjectiveScheduler) MaterializeNext(ctx context.Context, objectiveID string) (*Objective, error) {
+	objective, err := s.objectives.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, err
+	}
+	if objective.Recurrence == nil {
+		return nil, errs.Newf(errs.Validation, "objective %s does not recur", objectiveID).With("objective_id", objectiveID)
+	}
+	if !objective.IsFinished() {
+		return nil, errs.Newf(errs.Conflict, "objective %s has not finished, current status: %s", objectiveID, objective.Status).
+			With("objective_id", objectiveID)
+	}
+	return s.materialize(ctx, objective)
+}
+
+// Reconcile creates the successors that finished recurring objectives lack,
+// such as when the process stopped between finishing one and creating the
+// next. It returns the objectives it created.
+func (s *ObjectiveScheduler) Reconcile(ctx context.Context) ([]*Objective, error) {
+	objectives, err := s.objectives.ListObjectives(ctx, ObjectiveFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objectives: %w", err)
+	}
+	succeeded := make(map[string]bool)
+	for _, objective := range objectives {
+		if objective.RecurrenceOf != "" {
+			succeeded[objective.RecurrenceOf] = true
+		}
+	}
+
+	var created []*Objective
+	for _, objective := range objectives {
+		if objective.Recurrence == nil || !objective.IsFinished() || succeeded[objective.ID] {
+			continue
+		}
+		next, err := s.materialize(ctx, objective)
+		if err != nil {
+			return created, err
+		}
+		created = append(created, next)
+	}
+	return created, nil
+}
+
+// materialize returns the successor of a finished recurring objective,
+// creating it unless it already exists.
+func (s *ObjectiveScheduler) materialize(ctx context.Context, objective *Objective) (*Objective, error) {
+	materializeMu.Lock()
+	defer materializeMu.Unlock()
+
+	store := s.objectives.store
+	existing, err := store.Nodes().OfType("objective").IncludeArchived().WithData(recurrenceOfKey, objective.ID).AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for the next occurrence of %s: %w", objective.ID, err)
+	}
+	if len(existing) > 0 {
+		return s.objectives.nodeToObjective(existing[0])
+	}
+
+	recurrence := *objective.Recurrence
+	recurrence.Sequence++
+	dueAt, err := recurrence.Occurrence(recurrence.Sequence)
+	if err != nil {
+		return nil, err
+	}
+
+	context := copyObjectiveContext(objective.Context)
+	for _, key := range []string{delegationContextKey, "wip_override", timeBoxContextKey, dueDateContextKey} {
+		delete(context, key)
+	}
+	return s.objectives.createObjective(ctx, objective.GoalID, objective.MethodID, objective.Title, objective.Description,
+		context, objective.Priority, &dueAt, &recurrence, objective.ID)
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	rules := map[string]string{
+		"weekly":                               "FREQ=WEEKLY;INTERVAL=1",
+		"FREQ=MONTHLY;INTERVAL=3":              "FREQ=MONTHLY;INTERVAL=3",
+		"RRULE:freq=daily;interval=2;TZID=UTC": "FREQ=DAILY;INTERVAL=2;TZID=UTC",
+	}
+	for rule, want := range rules {
+		recurrence, err := ParseRecurrence(rule)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", rule, err)
+		} else if recurrence.String() != want {
+			t.Errorf("Expected %q to parse as %s, got %s", rule, want, recurrence)
+		}
+	}
+
+	for _, rule := range []string{"yearly", "FREQ=WEEKLY;INTERVAL=0", "FREQ=WEEKLY;BYDAY=MO", "FREQ=DAILY;TZID=Nowhere/Special"} {
+		if _, err := ParseRecurrence(rule); errs.CodeOf(err) != errs.Validation {
+			t.Errorf("Expected %q to be refused, got %v", rule, err)
+		}
+	}
+}
+
+func TestRecurrence_Occurrence(t *testing.T) {
+	// Monthly occurrences return to the anchor's day after a short month
+	monthly := &Recurrence{Frequency: RecurrenceMonthly, Interval: 1, TimeZone: "UTC",
+		Anchor: time.Date(2026, 1, 31, 17, 0, 0, 0, time.UTC)}
+	for n, want := range []time.Time{
+		time.Date(2026, 1, 31, 17, 0, 0, 0, time.UTC),
+		time.Date(2026, 2, 28, 17, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 31, 17, 0, 0, 0, time.UTC),
+		time.Date(2026, 4, 30, 17, 0, 0, 0, time.UTC),
+	} {
+		if got, _ := monthly.Occurrence(n); !got.Equal(want) {
+			t.Errorf("Expected occurrence %d on %s, got %s", n, want, got)
+		}
+	}
+
+	// Daily occurrences keep their wall-clock time across daylight saving
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+	daily := &Recurrence{Frequency: RecurrenceDaily, Interval: 1, TimeZone: "America/New_York",
+		Anchor: time.Date(2026, 3, 7, 9, 0, 0, 0, newYork).UTC()}
+	if got, _ := daily.Occurrence(2); got.Hour() != 9 || got.Day() != 9 || got.Sub(daily.Anchor) != 47*time.Hour {
+		t.Errorf("Expected 9:00 on March 9 after the clocks went forward, got %s", got)
+	}
+}
+
+func TestObjectiveScheduler_MaterializesOnce(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	objectives := NewObjectiveManager(store)
+	scheduler := NewObjectiveScheduler(objectives)
+
+	due := time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC)
+	weekly := &Recurrence{Frequency: RecurrenceWeekly, Interval: 1, TimeZone: "UTC"}
+	first := loaderObjective(t, store, nil, map[string]interface{}{"inbox": "work"})
+	if _, err := objectives.UpdateObjective(ctx, first.ID, ObjectiveUpdates{Recurrence: weekly}); errs.CodeOf(err) != errs.Validation {
+		t.Errorf("Expected a recurrence without a due date to be refused, got %v", err)
+	}
+	if _, err := objectives.UpdateObjective(ctx, first.ID, ObjectiveUpdates{DueAt: &due, Recurrence: weekly}); err != nil {
+		t.Fatalf("Failed to schedule objective: %v", err)
+	}
+	if _, err := scheduler.MaterializeNext(ctx, first.ID); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected an unfinished objective to have no next occurrence yet, got %v", err)
+	}
+
+	if _, err := objectives.StartObjective(ctx, first.ID); err != nil {
+		t.Fatalf("Failed to start objective: %v", err)
+	}
+	if _, err := objectives.CompleteObjective(ctx, first.ID, ObjectiveResult{Success: true}); err != nil {
+		t.Fatalf("Failed to complete objective: %v", err)
+	}
+
+	// Finishing created the next occurrence, a week later
+	next, err := scheduler.MaterializeNext(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("MaterializeNext failed: %v", err)
+	}
+	if next.Status != ObjectiveStatusPending || next.RecurrenceOf != first.ID || next.Context["inbox"] != "work" {
+		t.Errorf("Expected a pending copy of the objective, got %+v", next)
+	}
+	if next.DueAt == nil || !next.DueAt.Equal(due.AddDate(0, 0, 7)) || next.Recurrence.Sequence != 1 {
+		t.Errorf("Expected the second occurrence due a week later, got %v (%+v)", next.DueAt, next.Recurrence)
+	}
+
+	// Materializing again, or reconciling after a restart, finds it
+	if again, err := scheduler.MaterializeNext(ctx, first.ID); err != nil || again.ID != next.ID {
+		t.Errorf("Expected the same occurrence again, got %v, %v", again, err)
+	}
+	if created, err := NewObjectiveScheduler(NewObjectiveManager(store)).Reconcile(ctx); err != nil || len(created) != 0 {
+		t.Errorf("Expected nothing to reconcile, got %d created, %v", len(created), err)
+	}
+	all, err := objectives.ListObjectives(ctx, ObjectiveFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list objectives: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected two occurrences, got %d", len(all))
+	}
+}
+
+func TestObjectiveScheduler_ReconcilesMissedOccurrences(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	objectives := NewObjectiveManager(store)
+
+	// Finished without materializing, as when the process stopped in between
+	due := time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC)
+	objective := loaderObjective(t, store, nil, nil)
+	failed := ObjectiveStatusFailed
+	if _, err := objectives.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{
+		Status:      &failed,
+		CompletedAt: &due,
+		DueAt:       &due,
+		Recurrence:  &Recurrence{Frequency: RecurrenceMonthly, Interval: 2, TimeZone: "UTC"},
+	}); err != nil {
+		t.Fatalf("Failed to update objective: %v", err)
+	}
+
+	scheduler := NewObjectiveScheduler(objectives)
+	created, err := scheduler.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(created) != 1 || created[0].DueAt == nil || !created[0].DueAt.Equal(due.AddDate(0, 2, 0)) {
+		t.Fatalf("Expected the next occurrence two months later, got %+v", created)
+	}
+	if created, err := scheduler.Reconcile(ctx); err != nil || len(created) != 0 {
+		t.Errorf("Expected the occurrence created once, got %d created, %v", len(created), err)
+	}
+}
+
+func TestGetNextActionable_PrefersOverdue(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	objectives := NewObjectiveManager(store)
+
+	if next, err := objectives.GetNextActionable(ctx); err != nil || next != nil {
+		t.Errorf("Expected nothing actionable in an empty store, got %v, %v", next, err)
+	}
+
+	important := loaderObjective(t, store, nil, nil)
+	overdue := loaderObjective(t, store, nil, nil)
+	priority := 6
+	yesterday := time.Now().Add(-24 * time.Hour)
+	if _, err := objectives.UpdateObjective(ctx, important.ID, ObjectiveUpdates{Priority: &priority}); err != nil {
+		t.Fatalf("Failed to update objective: %v", err)
+	}
+	overdue, err := objectives.UpdateObjective(ctx, overdue.ID, ObjectiveUpdates{DueAt: &yesterday})
+	if err != nil {
+		t.Fatalf("Failed to update objective: %v", err)
+	}
+	if !overdue.IsOverdue(time.Now()) {
+		t.Error("Expected the objective due yesterday to be overdue")
+	}
+
+	next, err := objectives.GetNextActionable(ctx)
+	if err != nil {
+		t.Fatalf("GetNextActionable failed: %v", err)
+	}
+	if next == nil || next.Objective.ID != overdue.ID {
+		t.Errorf("Expected the overdue objective first, got %+v", next)
+	}
+	if len(next.Components) != len(SuggestionFactors) {
+		t.Fatalf("Expected a full score breakdown, got %v", next.Components)
+	}
+	for _, component := range next.Components {
+		blended := component.Factor == FactorPriority || component.Factor == FactorDuePressure || component.Factor == FactorGoalPriority
+		if (component.Weight > 0) != blended {
+			t.Errorf("Expected only priority, due date and goal priority to count, got %s weighted %g", component.Factor, component.Weight)
+		}
+	}
+}
@@ -2,8 +2,14 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 func TestObjectiveManager_CreateObjective(t *testing.T) {
@@ -157,6 +163,29 @@ func TestObjectiveManager_CreateObjective(t *testing.T) {
 	}
 }
 
+func TestObjectiveManager_CreateObjectiveIsAtomic(t *testing.T) {
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+	om := NewObjectiveManager(store)
+	ctx := context.Background()
+
+	goal, err := gm.CreateGoal(ctx, "Test Goal", "A goal for testing", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test goal: %v", err)
+	}
+
+	// The "uses" edge fails, so neither the objective nor its "serves" edge is kept
+	if _, err := om.CreateObjective(ctx, goal.ID, "missing-method", "Orphan", "", nil, 5); errs.CodeOf(err) != errs.NotFound {
+		t.Fatalf("Expected the missing method reported, got %v", err)
+	}
+	if nodes, _ := store.GetNodesByType(ctx, "objective"); len(nodes) != 0 {
+		t.Errorf("Expected no objective stored, got %d", len(nodes))
+	}
+	if edges, _ := store.GetEdgesByType(ctx, "serves"); len(edges) != 0 {
+		t.Errorf("Expected no relationship stored, got %d", len(edges))
+	}
+}
+
 func TestObjectiveManager_GetObjective(t *testing.T) {
 	store := setupTestStore(t)
 	gm := NewGoalManager(store)
@@ -491,6 +520,71 @@ func TestObjectiveManager_ListObjectives(t *testing.T) {
 	_ = obj3
 }
 
+// checkCountingContext reports itself cancelled once its Err has been checked
+// more than allowed times, so a scan is cancelled at a known point.
+type checkCountingContext struct {
+	context.Context
+	allowed int
+	checks  int
+}
+
+func (c *checkCountingContext) Err() error {
+	c.checks++
+	if c.checks > c.allowed {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestObjectiveManager_ListObjectivesCancelled(t *testing.T) {
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+	mm := NewMethodManager(store)
+	om := NewObjectiveManager(store)
+	ctx := context.Background()
+
+	goal, err := gm.CreateGoal(ctx, "Large Goal", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := mm.CreateMethod(ctx, "Large Method", "", []ApproachStep{{Description: "Work"}}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	const total = 1000
+	for i := 0; i < total; i++ {
+		if _, err := om.CreateObjective(ctx, goal.ID, method.ID, fmt.Sprintf("Objective %d", i), "", nil, 5); err != nil {
+			t.Fatalf("Failed to create objective: %v", err)
+		}
+	}
+
+	// Cancelled after the check before the scan, so part way through it
+	scanCtx := &checkCountingContext{Context: ctx, allowed: 1}
+	start := time.Now()
+	objectives, err := om.ListObjectives(scanCtx, ObjectiveFilter{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if objectives != nil {
+		t.Errorf("Expected no objectives from a cancelled listing, got %d", len(objectives))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the cancelled listing to return promptly, took %v", elapsed)
+	}
+
+	// A context cancelled up front fails before any scan
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := om.ListObjectives(cancelled, ObjectiveFilter{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	all, err := om.ListObjectives(ctx, ObjectiveFilter{})
+	if err != nil || len(all) != total {
+		t.Errorf("Expected all %d objectives without cancelling, got %d (%v)", total, len(all), err)
+	}
+}
+
 func TestObjectiveManager_GetObjectivesForGoal(t *testing.T) {
 	store := setupTestStore(t)
 	gm := NewGoalManager(store)
@@ -787,7 +881,11 @@ func TestObjectiveInstanceMethods(t *testing.T) {
 }
 
 func TestObjectiveTemporalQueries(t *testing.T) {
-	store := setupTestStore(t)
+	clock := utils.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	store, err := storage.NewStore(t.TempDir(), storage.WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create test store: %v", err)
+	}
 	gm := NewGoalManager(store)
 	mm := NewMethodManager(store)
 	om := NewObjectiveManager(store)
@@ -802,13 +900,13 @@ func TestObjectiveTemporalQueries(t *testing.T) {
 	objective, _ := om.CreateObjective(ctx, goal.ID, method.ID, originalTitle, "Original description", nil, 5)
 
 	// Record time after creation
-	creationTime := time.Now()
-	time.Sleep(10 * time.Millisecond) // Small delay to ensure different timestamps
+	creationTime := clock.Now()
+	clock.Advance(time.Minute)
 
 	// Update the objective
 	newTitle := "Updated Title"
 	updates := ObjectiveUpdates{Title: &newTitle}
-	_, err := om.UpdateObjective(ctx, objective.ID, updates)
+	_, err = om.UpdateObjective(ctx, objective.ID, updates)
 	if err != nil {
 		t.Fatalf("Failed to update objective: %v", err)
 	}
@@ -0,0 +1,550 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// operationNodeType is the node type of operation journal entries
+const operationNodeType = "operation"
+
+// undoneByEdgeKey marks an edge version written when its operation was undone
+const undoneByEdgeKey = "undone_by"
+
+// OperationKind identifies a composite operation in the journal.
+type OperationKind string
+
+const (
+	// OperationContextConsolidation merged duplicate user context entries
+	OperationContextConsolidation OperationKind = "context_consolidation"
+
+	// OperationAcceptProposals created objectives from a goal decomposition
+	OperationAcceptProposals OperationKind = "accept_proposals"
+
+	// OperationArchive moved settled goals and their work to the archive
+	OperationArchive OperationKind = "archive"
+
+	// OperationUndo reverted an earlier operation; undoing it redoes that operation
+	OperationUndo OperationKind = "undo"
+)
+
+// ChangeAction is what an operation did to one node or edge.
+type ChangeAction string
+
+const (
+	// ChangeCreated entities did not exist before the operation
+	ChangeCreated ChangeAction = "created"
+
+	// ChangeUpdated entities got a new version
+	ChangeUpdated ChangeAction = "updated"
+
+	// ChangeArchived entities were moved to the archive
+	ChangeArchived ChangeAction = "archived"
+
+	// ChangeRestored entities were brought back from the archive
+	ChangeRestored ChangeAction = "restored"
+)
+
+// OperationChange is one node or edge an operation changed, with its data
+// before and after. Before is nil for entities the operation created.
+type OperationChange struct {
+	// Entity is "node" or "edge"
+	Entity string
+	ID     string
+	Type   string
+	Action ChangeAction
+	Before map[string]interface{}
+	After  map[string]interface{}
+}
+
+// OperationRecord is a journal entry for a composite operation: every node
+// and edge version it superseded or created, so that it can be undone.
+type OperationRecord struct {
+	ID      string
+	Kind    OperationKind
+	Summary string
+	Changes []OperationChange
+
+	// Undoes is the operation an undo reverted
+	Undoes string
+
+	// UndoneBy is the undo that reverted this operation, if any
+	UndoneBy string
+
+	// Skipped lists entities a partial undo left alone because they had
+	// changed since the operation
+	Skipped []string
+
+	CreatedAt time.Time
+}
+
+// Undoable reports whether the operation can still be undone.
+func (r *OperationRecord) Undoable() bool {
+	return r.UndoneBy == "" && len(r.Changes) > 0
+}
+
+// OperationConflict is an entity changed since the operation that touched it.
+type OperationConflict struct {
+	Entity string
+	ID     string
+	Type   string
+	Reason string
+}
+
+// UndoOptions controls UndoOperation.
+type UndoOptions struct {
+	// Partial undoes the entities that have not changed since the operation
+	// and leaves the conflicting ones as they are
+	Partial bool
+
+	// DryRun reports what would be undone and the conflicts without changing anything
+	DryRun bool
+}
+
+// UndoResult reports an undo.
+type UndoResult struct {
+	// Operation is the operation that was undone
+	Operation *OperationRecord
+
+	// Undo is the journal entry for the undo itself; nil on a dry run or
+	// when nothing was undone
+	Undo *OperationRecord
+
+	// Reverted lists the changes undone
+	Reverted []OperationChange
+
+	// Conflicts lists entities changed since the operation
+	Conflicts []OperationConflict
+}
+
+// OperationJournal records composite operations and undoes them. Undoing
+// never deletes anything: each affected entity gets a new version with its
+// earlier data, and nodes the operation created are archived.
+type OperationJournal struct {
+	store *storage.Store
+	clock utils.Clock
+}
+
+// NewOperationJournal creates an operation journal on the store.
+func NewOperationJournal(store *storage.Store) *OperationJournal {
+	return &OperationJournal{store: store, clock: utils.ClockOrReal(nil)}
+}
+
+// Record runs an operation and journals the node and edge versions it
+// wrote through the context it is given. The operation returns a one-line
+// summary. Changes are journaled even if the operation fails part way, so
+// what it did can still be undone. Returns nil when nothing was changed.
+func (oj *OperationJournal) Record(ctx context.Context, kind OperationKind, operation func(ctx context.Context) (string, error)) (*OperationRecord, error) {
+	recorder := &storage.ChangeRecorder{}
+	summary, opErr := operation(storage.WithChangeRecorder(ctx, recorder))
+
+	changes := coalesceChanges(recorder.Events())
+	if len(changes) == 0 {
+		return nil, opErr
+	}
+	if summary == "" {
+		summary = fmt.Sprintf("%s (%d changes)", kind, len(changes))
+	}
+	record, err := oj.save(ctx, &OperationRecord{Kind: kind, Summary: summary, Changes: changes})
+	if err != nil {
+		if opErr != nil {
+			return nil, fmt.Errorf("%w (journaling it also failed: %v)", opErr, err)
+		}
+		return nil, err
+	}
+	return record, opErr
+}
+
+// coalesceChanges reduces recorded events to one change per entity: its
+// data before the first write and after the last. The first event decides
+// the action, except that an updated entity archived later counts as
+// archived, and one created and archived again, as by a rollback, is dropped.
+func coalesceChanges(events []storage.ChangeEvent) []OperationChange {
+	var changes []OperationChange
+	index := make(map[string]int)
+	dropped := make(map[int]bool)
+	for _, event := range events {
+		var change OperationChange
+		switch {
+		case event.Node != nil:
+			change = OperationChange{Entity: "node", ID: event.Node.ID, Type: event.Node.Type, After: event.Node.Data}
+			if event.PreviousNode != nil {
+				change.Before = event.PreviousNode.Data
+			}
+		case event.Edge != nil:
+			change = OperationChange{Entity: "edge", ID: event.Edge.ID, Type: event.Edge.Type, After: event.Edge.Data}
+			if event.PreviousEdge != nil {
+				change.Before = event.PreviousEdge.Data
+			}
+		default:
+			continue
+		}
+		switch event.Kind {
+		case storage.ChangeNodeArchived, storage.ChangeEdgeArchived:
+			change.Action = ChangeArchived
+		case storage.ChangeNodeRestored, storage.ChangeEdgeRestored:
+			change.Action = ChangeRestored
+		case storage.ChangeNodeAdded, storage.ChangeEdgeAdded:
+			change.Action = ChangeCreated
+		default:
+			change.Action = ChangeUpdated
+		}
+
+		key := change.Entity + ":" + change.ID
+		if i, seen := index[key]; seen {
+			changes[i].After = change.After
+			switch {
+			case change.Action == ChangeArchived && changes[i].Action == ChangeUpdated:
+				changes[i].Action = ChangeArchived
+			case change.Action == ChangeArchived && changes[i].Action == ChangeCreated:
+				dropped[i] = true
+			case change.Action == ChangeRestored:
+				delete(dropped, i)
+			}
+			continue
+		}
+		index[key] = len(changes)
+		changes = append(changes, change)
+	}
+
+	kept := changes[:0]
+	for i, change := range changes {
+		if !dropped[i] {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// ListOperations returns the journal, most recent first.
+func (oj *OperationJournal) ListOperations(ctx context.Context) ([]*OperationRecord, error) {
+	nodes, err := oj.store.GetNodesByType(ctx, operationNodeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list operations: %w", err)
+	}
+
+	records := make([]*OperationRecord, 0, len(nodes))
+	for _, node := range nodes {
+		records = append(records, nodeToOperationRecord(node))
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.After(records[j].CreatedAt)
+		}
+		return records[i].ID > records[j].ID
+	})
+	return records, nil
+}
+
+// GetOperation returns a journal entry by ID.
+func (oj *OperationJournal) GetOperation(ctx context.Context, operationID string) (*OperationRecord, error) {
+	node, err := oj.store.GetNode(ctx, operationID)
+	if err != nil {
+		return nil, err
+	}
+	if node.Type != operationNodeType {
+		return nil, errs.Newf(errs.NotFound, "operation %s not found", operationID).With("operation_id", operationID)
+	}
+	return nodeToOperationRecord(node), nil
+}
+
+// LastUndoable returns the most recent operation that has not been undone,
+// which may itself be an undo. Returns a NotFound error if there is none.
+func (oj *OperationJournal) LastUndoable(ctx context.Context) (*OperationRecord, error) {
+	records, err := oj.ListOperations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Undoable() {
+			return record, nil
+		}
+	}
+	return nil, errs.New(errs.NotFound, "no operation to undo")
+}
+
+// UndoOperation reverts an operation: each entity it updated or archived gets
+// a new version with its earlier data, nodes it created or restored are
+// archived, and edges it created between surviving nodes get a version marked
+// undone. The undo is journaled in turn, so undoing it redoes the operation.
+// If any entity has changed since the operation the undo is refused with a
+// Conflict error listing them, unless opts.Partial is set.
+func (oj *OperationJournal) UndoOperation(ctx context.Context, operationID string, opts UndoOptions) (*UndoResult, error) {
+	operation, err := oj.GetOperation(ctx, operationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+	if operation.UndoneBy != "" {
+		return nil, errs.Newf(errs.Conflict, "operation %s was already undone by %s", operation.ID, operation.UndoneBy).With("undone_by", operation.UndoneBy)
+	}
+
+	result := &UndoResult{Operation: operation}
+	var pending []OperationChange
+	for _, change := range operation.Changes {
+		if conflict := oj.checkUnchanged(ctx, change); conflict != nil {
+			result.Conflicts = append(result.Conflicts, *conflict)
+			continue
+		}
+		pending = append(pending, change)
+	}
+	if len(result.Conflicts) > 0 && !opts.Partial {
+		return result, errs.Newf(errs.Conflict, "%d of %d entities changed since operation %s; undo partially to revert the rest",
+			len(result.Conflicts), len(operation.Changes), operation.ID).With("conflicts", len(result.Conflicts))
+	}
+	if opts.DryRun || len(pending) == 0 {
+		result.Reverted = pending
+		return result, nil
+	}
+
+	undo, err := oj.Record(ctx, OperationUndo, func(ctx context.Context) (string, error) {
+		return undoSummary(operation), oj.revert(ctx, operation.ID, pending)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Reverted = pending
+	if undo == nil {
+		return result, nil
+	}
+
+	undo.Undoes = operation.ID
+	for _, conflict := range result.Conflicts {
+		undo.Skipped = append(undo.Skipped, conflict.ID)
+	}
+	if undo, err = oj.save(ctx, undo); err != nil {
+		return nil, err
+	}
+	result.Undo = undo
+
+	if err := oj.store.UpdateNode(ctx, operation.ID, operationRecordData(&OperationRecord{
+		Kind: operation.Kind, Summary: operation.Summary, Changes: operation.Changes,
+		Undoes: operation.Undoes, UndoneBy: undo.ID, Skipped: operation.Skipped, CreatedAt: operation.CreatedAt,
+	})); err != nil {
+		return nil, fmt.Errorf("failed to mark operation %s undone: %w", operation.ID, err)
+	}
+	operation.UndoneBy = undo.ID
+	return result, nil
+}
+
+// undoSummary describes undoing an operation; undoing an undo is a redo.
+func undoSummary(operation *OperationRecord) string {
+	switch {
+	case strings.HasPrefix(operation.Summary, "Undo "):
+		return "Redo " + strings.TrimPrefix(operation.Summary, "Undo ")
+	case strings.HasPrefix(operation.Summary, "Redo "):
+		return "Undo " + strings.TrimPrefix(operation.Summary, "Redo ")
+	}
+	return "Undo " + operation.Summary
+}
+
+// checkUnchanged returns a conflict if the entity no longer has the data
+// the operation left it with.
+func (oj *OperationJournal) checkUnchanged(ctx context.Context, change OperationChange) *OperationConflict {
+	conflict := &OperationConflict{Entity: change.Entity, ID: change.ID, Type: change.Type}
+
+	var current map[string]interface{}
+	if change.Entity == "edge" {
+		edge, err := oj.store.GetEdge(ctx, change.ID)
+		if err != nil {
+			conflict.Reason = err.Error()
+			return conflict
+		}
+		current = edge.Data
+	} else {
+		node, err := oj.store.GetNode(ctx, change.ID)
+		if err != nil {
+			conflict.Reason = err.Error()
+			return conflict
+		}
+		current = node.Data
+		if change.Action == ChangeArchived && !oj.store.IsArchived(ctx, change.ID) {
+			conflict.Reason = "restored from the archive since"
+			return conflict
+		}
+		if change.Action == ChangeCreated || change.Action == ChangeRestored {
+			if oj.store.IsArchived(ctx, change.ID) {
+				conflict.Reason = "archived since"
+				return conflict
+			}
+		}
+	}
+
+	if hashContextValue(current) != hashContextValue(change.After) {
+		conflict.Reason = "modified since"
+		return conflict
+	}
+	return nil
+}
+
+// revert writes the new versions that undo the changes, latest first, and
+// archives the nodes the operation created or restored. Writing an archived
+// entity restores it.
+func (oj *OperationJournal) revert(ctx context.Context, operationID string, changes []OperationChange) error {
+	var archive []string
+	archived := make(map[string]bool)
+	for _, change := range changes {
+		if change.Entity == "node" && (change.Action == ChangeCreated || change.Action == ChangeRestored) {
+			archive = append(archive, change.ID)
+			archived[change.ID] = true
+		}
+	}
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		var err error
+		switch {
+		case change.Entity == "node" && change.Action == ChangeRestored:
+			if hashContextValue(change.Before) != hashContextValue(change.After) {
+				err = oj.store.UpdateNode(ctx, change.ID, copyObjectiveContext(change.Before))
+			}
+		case change.Entity == "node" && change.Action != ChangeCreated:
+			err = oj.store.UpdateNode(ctx, change.ID, copyObjectiveContext(change.Before))
+		case change.Entity == "edge" && change.Action != ChangeCreated:
+			err = oj.store.UpdateEdge(ctx, change.ID, copyObjectiveContext(change.Before))
+		case change.Entity == "edge" && change.Action == ChangeCreated:
+			edge, getErr := oj.store.GetEdge(ctx, change.ID)
+			if getErr != nil {
+				err = getErr
+				break
+			}
+			if archived[edge.SourceID] {
+				// Archived with its source node
+				continue
+			}
+			data := copyObjectiveContext(change.After)
+			data[undoneByEdgeKey] = operationID
+			err = oj.store.UpdateEdge(ctx, change.ID, data)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to revert %s %s: %w", change.Entity, change.ID, err)
+		}
+	}
+
+	if len(archive) > 0 {
+		if _, err := oj.store.ArchiveNodes(ctx, archive); err != nil {
+			return fmt.Errorf("failed to archive created nodes: %w", err)
+		}
+	}
+	return nil
+}
+
+// save writes a journal entry, or a new version of one that has an ID.
+func (oj *OperationJournal) save(ctx context.Context, record *OperationRecord) (*OperationRecord, error) {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = oj.clock.Now()
+	}
+	data := operationRecordData(record)
+	if record.ID != "" {
+		if err := oj.store.UpdateNode(ctx, record.ID, data); err != nil {
+			return nil, fmt.Errorf("failed to journal operation: %w", err)
+		}
+		return record, nil
+	}
+
+	node := storage.NewNode(operationNodeType, data)
+	if err := oj.store.AddNode(ctx, node); err != nil {
+		return nil, fmt.Errorf("failed to journal operation: %w", err)
+	}
+	record.ID = node.ID
+	return record, nil
+}
+
+// operationRecordData converts a journal entry to node data.
+func operationRecordData(record *OperationRecord) map[string]interface{} {
+	changes := make([]map[string]interface{}, len(record.Changes))
+	for i, change := range record.Changes {
+		changes[i] = map[string]interface{}{
+			"entity": change.Entity,
+			"id":     change.ID,
+			"type":   change.Type,
+			"action": string(change.Action),
+			"before": change.Before,
+			"after":  change.After,
+		}
+	}
+	data := map[string]interface{}{
+		"kind":       string(record.Kind),
+		"summary":    record.Summary,
+		"changes":    changes,
+		"created_at": record.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if record.Undoes != "" {
+		data["undoes"] = record.Undoes
+	}
+	if record.UndoneBy != "" {
+		data["undone_by"] = record.UndoneBy
+	}
+	if len(record.Skipped) > 0 {
+		data["skipped"] = record.Skipped
+	}
+	return data
+}
+
+// nodeToOperationRecord converts a node to a journal entry.
+func nodeToOperationRecord(node *storage.Node) *OperationRecord {
+	record := &OperationRecord{
+		ID:       node.ID,
+		Kind:     OperationKind(getString(node.Data, "kind")),
+		Summary:  getString(node.Data, "summary"),
+		Undoes:   getString(node.Data, "undoes"),
+		UndoneBy: getString(node.Data, "undone_by"),
+		Skipped:  toStringSlice(node.Data["skipped"]),
+	}
+	if createdAt, err := time.Parse(time.RFC3339Nano, getString(node.Data, "created_at")); err == nil {
+		record.CreatedAt = createdAt
+	}
+
+	// A JSON array once loaded from disk, typed maps while in memory
+	var changes []interface{}
+	switch data := node.Data["changes"].(type) {
+	case []interface{}:
+		changes = data
+	case []map[string]interface{}:
+		for _, change := range data {
+			changes = append(changes, change)
+		}
+	}
+	for _, item := range changes {
+		change, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		before, _ := change["before"].(map[string]interface{})
+		after, _ := change["after"].(map[string]interface{})
+		record.Changes = append(record.Changes, OperationChange{
+			Entity: getString(change, "entity"),
+			ID:     getString(change, "id"),
+			Type:   getString(change, "type"),
+			Action: ChangeAction(getString(change, "action")),
+			Before: before,
+			After:  after,
+		})
+	}
+	return record
+}
+
+// Describe summarizes what undoing the operation would change, by entity type.
+func (r *OperationRecord) Describe() string {
+	counts := make(map[string]int)
+	for _, change := range r.Changes {
+		counts[fmt.Sprintf("%s %s", change.Action, change.Type)]++
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%d %s", counts[key], key)
+	}
+	return strings.Join(parts, ", ")
+}
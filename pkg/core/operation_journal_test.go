@@ -0,0 +1,325 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// consolidateTestDuplicates stores an entry and two exact duplicates of it,
+// merges them, and returns the entries as they were before the merge.
+func consolidateTestDuplicates(t *testing.T, ucm *UserContextManager) (*ContextConsolidationReport, *UserContext, []*UserContext) {
+	t.Helper()
+	keeper := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceExplicit, "Prefers short answers")[0]
+	duplicates := learnContexts(t, ucm, ContextCategoryPreferences, ContextSourceInferred, "prefers short answers.", "Prefers  SHORT answers")
+
+	report, err := ucm.ConsolidateContext(context.Background(), "user1", DefaultContextConsolidationOptions())
+	if err != nil {
+		t.Fatalf("Consolidation failed: %v", err)
+	}
+	if len(report.Merged) != 1 || report.OperationID == "" {
+		t.Fatalf("Expected a journaled merge, got %+v", report)
+	}
+	return report, keeper, duplicates
+}
+
+// acceptTestProposals accepts two dependent objectives, one with a draft method.
+func acceptTestProposals(t *testing.T, gm *GoalManager) (*AcceptedDecomposition, string) {
+	t.Helper()
+	ctx := context.Background()
+	goal, err := gm.CreateGoal(ctx, "Tame email", "", 6, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	proposal := &DecompositionProposal{
+		GoalID: goal.ID,
+		Items: []*ObjectiveProposal{
+			{Key: "1", Title: "Automate filing", NewMethodNeeded: true, Priority: 5},
+			{Key: "2", Title: "Report progress", NewMethodNeeded: true, Priority: 4, DependsOn: []string{"1"}},
+		},
+	}
+	accepted, err := gm.AcceptProposals(ctx, proposal, []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("AcceptProposals failed: %v", err)
+	}
+	if accepted.OperationID == "" {
+		t.Fatal("Expected the acceptance to be journaled")
+	}
+	return accepted, goal.ID
+}
+
+func TestUndoContextConsolidation(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+	journal := NewOperationJournal(ucm.store)
+	report, keeper, duplicates := consolidateTestDuplicates(t, ucm)
+
+	operation, err := journal.GetOperation(ctx, report.OperationID)
+	if err != nil {
+		t.Fatalf("Failed to get operation: %v", err)
+	}
+	if operation.Kind != OperationContextConsolidation || !operation.Undoable() {
+		t.Fatalf("Expected an undoable consolidation, got %+v", operation)
+	}
+	// The keeper and both duplicates were updated, and each duplicate linked
+	var nodes, edges int
+	for _, change := range operation.Changes {
+		switch {
+		case change.Entity == "node" && change.Action == ChangeUpdated:
+			nodes++
+		case change.Entity == "edge" && change.Action == ChangeCreated:
+			edges++
+		}
+	}
+	if nodes != 3 || edges != 2 {
+		t.Errorf("Expected 3 updated entries and 2 created edges, got %d and %d: %s", nodes, edges, operation.Describe())
+	}
+
+	mergedAt := time.Now()
+
+	result, err := journal.UndoOperation(ctx, operation.ID, UndoOptions{})
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(result.Reverted) != 5 || len(result.Conflicts) != 0 || result.Undo == nil {
+		t.Fatalf("Expected every change reverted, got %+v", result)
+	}
+
+	for _, entry := range append([]*UserContext{keeper}, duplicates...) {
+		current, err := ucm.GetContext(ctx, entry.ID)
+		if err != nil {
+			t.Fatalf("Failed to get context: %v", err)
+		}
+		if current.Status != ContextStatusActive || current.Reinforcements != entry.Reinforcements || len(current.MergedFrom) != 0 {
+			t.Errorf("Expected %s to be as it was before the merge, got %+v", entry.ID, current)
+		}
+	}
+
+	// Nothing is deleted: the merged version stays in the history
+	merged, err := ucm.store.GetNodeAtTime(ctx, duplicates[0].ID, mergedAt)
+	if err != nil || merged.Data["status"] != string(ContextStatusSuperseded) {
+		t.Errorf("Expected the merged version to be kept, got %v, %v", merged, err)
+	}
+	links, err := ucm.store.Edges().OfType(supersededByEdgeType).All()
+	if err != nil {
+		t.Fatalf("Failed to query edges: %v", err)
+	}
+	for _, link := range links {
+		if link.Data[undoneByEdgeKey] != operation.ID {
+			t.Errorf("Expected the superseded link %s to be marked undone, got %v", link.ID, link.Data)
+		}
+	}
+
+	if _, err := journal.UndoOperation(ctx, operation.ID, UndoOptions{}); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected a second undo to be refused, got %v", err)
+	}
+}
+
+func TestUndoAcceptProposals(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+	journal := NewOperationJournal(store)
+	accepted, goalID := acceptTestProposals(t, gm)
+
+	result, err := journal.UndoOperation(ctx, accepted.OperationID, UndoOptions{})
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if result.Undo == nil || result.Undo.Undoes != accepted.OperationID {
+		t.Fatalf("Expected the undo to be journaled, got %+v", result.Undo)
+	}
+
+	objectives, err := NewObjectiveManager(store).GetObjectivesForGoal(ctx, goalID)
+	if err != nil {
+		t.Fatalf("Failed to list objectives: %v", err)
+	}
+	if len(objectives) != 0 {
+		t.Errorf("Expected the created objectives to be gone from listings, got %d", len(objectives))
+	}
+	for _, objective := range accepted.Objectives {
+		if !store.IsArchived(ctx, objective.ID) {
+			t.Errorf("Expected objective %s to be archived", objective.ID)
+		}
+		if _, err := store.GetNode(ctx, objective.ID); err != nil {
+			t.Errorf("Expected archived objective %s to stay reachable: %v", objective.ID, err)
+		}
+	}
+	for _, method := range accepted.MethodSuggestions {
+		if !store.IsArchived(ctx, method.ID) {
+			t.Errorf("Expected draft method %s to be archived", method.ID)
+		}
+	}
+}
+
+func TestUndoRefusesConflicts(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+	journal := NewOperationJournal(ucm.store)
+	report, keeper, duplicates := consolidateTestDuplicates(t, ucm)
+
+	// The user edits one of the superseded entries afterwards
+	edited, err := ucm.store.GetNode(ctx, duplicates[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to get context: %v", err)
+	}
+	data := copyObjectiveContext(edited.Data)
+	data["content"] = "Prefers short answers in chat"
+	if err := ucm.store.UpdateNode(ctx, edited.ID, data); err != nil {
+		t.Fatalf("Failed to edit context: %v", err)
+	}
+
+	result, err := journal.UndoOperation(ctx, report.OperationID, UndoOptions{})
+	if errs.CodeOf(err) != errs.Conflict {
+		t.Fatalf("Expected the undo to be refused, got %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].ID != edited.ID || result.Conflicts[0].Reason != "modified since" {
+		t.Fatalf("Expected a conflict for the edited entry, got %+v", result.Conflicts)
+	}
+	if current, _ := ucm.GetContext(ctx, keeper.ID); len(current.MergedFrom) == 0 {
+		t.Error("Expected a refused undo to change nothing")
+	}
+
+	result, err = journal.UndoOperation(ctx, report.OperationID, UndoOptions{Partial: true})
+	if err != nil {
+		t.Fatalf("Partial undo failed: %v", err)
+	}
+	if len(result.Reverted) != 4 || len(result.Undo.Skipped) != 1 || result.Undo.Skipped[0] != edited.ID {
+		t.Fatalf("Expected all but the edited entry reverted, got %+v", result)
+	}
+	if current, _ := ucm.GetContext(ctx, keeper.ID); len(current.MergedFrom) != 0 || current.Reinforcements != keeper.Reinforcements {
+		t.Errorf("Expected the keeper to be reverted, got %+v", current)
+	}
+	if current, _ := ucm.GetContext(ctx, duplicates[1].ID); current.Status != ContextStatusActive {
+		t.Errorf("Expected the unedited duplicate to be active again, got %s", current.Status)
+	}
+	if current, _ := ucm.GetContext(ctx, edited.ID); current.Content != "Prefers short answers in chat" {
+		t.Errorf("Expected the edited entry to be left alone, got %q", current.Content)
+	}
+}
+
+func TestUndoIsJournaledAndRedoable(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	journal := NewOperationJournal(store)
+	accepted, goalID := acceptTestProposals(t, NewGoalManager(store))
+
+	last, err := journal.LastUndoable(ctx)
+	if err != nil || last.ID != accepted.OperationID {
+		t.Fatalf("Expected the acceptance to be the last undoable operation, got %+v (%v)", last, err)
+	}
+	undo, err := journal.UndoOperation(ctx, last.ID, UndoOptions{})
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+
+	operations, err := journal.ListOperations(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list operations: %v", err)
+	}
+	if len(operations) != 2 || operations[0].ID != undo.Undo.ID || operations[0].Kind != OperationUndo {
+		t.Fatalf("Expected the undo first in the journal, got %+v", operations)
+	}
+	if operations[1].UndoneBy != undo.Undo.ID || operations[1].Undoable() {
+		t.Errorf("Expected the acceptance to be marked undone, got %+v", operations[1])
+	}
+
+	// Undoing the undo redoes the acceptance
+	last, err = journal.LastUndoable(ctx)
+	if err != nil || last.ID != undo.Undo.ID {
+		t.Fatalf("Expected the undo to be the last undoable operation, got %+v (%v)", last, err)
+	}
+	redo, err := journal.UndoOperation(ctx, last.ID, UndoOptions{})
+	if err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if redo.Undo.Summary != "Redo "+strings.TrimPrefix(undo.Undo.Summary, "Undo ") {
+		t.Errorf("Expected the undo of an undo to be a redo, got %q", redo.Undo.Summary)
+	}
+	objectives, err := NewObjectiveManager(store).GetObjectivesForGoal(ctx, goalID)
+	if err != nil {
+		t.Fatalf("Failed to list objectives: %v", err)
+	}
+	if len(objectives) != 2 {
+		t.Errorf("Expected both objectives back, got %d", len(objectives))
+	}
+	for _, objective := range accepted.Objectives {
+		if store.IsArchived(ctx, objective.ID) {
+			t.Errorf("Expected objective %s to be live again", objective.ID)
+		}
+	}
+	if edges, _ := store.GetEdgesByType(ctx, dependsOnEdgeType); len(edges) != 1 {
+		t.Errorf("Expected the dependency edge back, got %d", len(edges))
+	}
+
+	// And the redo can be undone in turn
+	if _, err := journal.UndoOperation(ctx, redo.Undo.ID, UndoOptions{}); err != nil {
+		t.Fatalf("Undoing the redo failed: %v", err)
+	}
+	if objectives, _ := NewObjectiveManager(store).GetObjectivesForGoal(ctx, goalID); len(objectives) != 0 {
+		t.Errorf("Expected the objectives to be archived again, got %d", len(objectives))
+	}
+}
+
+func TestUndoArchiveSettled(t *testing.T) {
+	ws := setupArchiveTestWorkspace(t)
+	ctx := context.Background()
+
+	report, err := NewArchiveManager(ws.store).ArchiveSettled(ctx, ArchivePolicy{}, false)
+	if err != nil {
+		t.Fatalf("ArchiveSettled failed: %v", err)
+	}
+	if report.OperationID == "" {
+		t.Fatal("Expected the archive pass to be journaled")
+	}
+
+	if _, err := NewOperationJournal(ws.store).UndoOperation(ctx, report.OperationID, UndoOptions{}); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	for _, id := range []string{ws.archivedGoal, ws.archivedDone, ws.archivedResult} {
+		if ws.store.IsArchived(ctx, id) {
+			t.Errorf("Expected %s to be live again", id)
+		}
+	}
+	objectives, err := ws.om.GetObjectivesForGoal(ctx, ws.archivedGoal)
+	if err != nil {
+		t.Fatalf("Failed to list objectives: %v", err)
+	}
+	if len(objectives) != 2 {
+		t.Errorf("Expected the archived goal's objectives to be linked again, got %d", len(objectives))
+	}
+}
+
+func TestRecordJournalsNothingForRolledBackOperations(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+	goal, err := gm.CreateGoal(ctx, "Tame email", "", 6, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+
+	proposal := &DecompositionProposal{
+		GoalID: goal.ID,
+		Items: []*ObjectiveProposal{
+			{Key: "1", Title: "Automate filing", NewMethodNeeded: true, Priority: 5},
+			{Key: "2", Title: "Report progress", MethodID: "missing-method", Priority: 4, DependsOn: []string{"1"}},
+		},
+	}
+	if _, err := gm.AcceptProposals(ctx, proposal, []string{"1", "2"}); err == nil {
+		t.Fatal("Expected acceptance to fail")
+	}
+	operations, err := NewOperationJournal(store).ListOperations(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list operations: %v", err)
+	}
+	if len(operations) != 0 {
+		t.Errorf("Expected a rolled back acceptance to leave no journal entry, got %+v", operations)
+	}
+	if _, err := NewOperationJournal(store).LastUndoable(ctx); errs.CodeOf(err) != errs.NotFound {
+		t.Errorf("Expected nothing to undo, got %v", err)
+	}
+}
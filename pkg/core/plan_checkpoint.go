@@ -0,0 +1,252 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// planCheckpointNodeType is the storage node type of plan checkpoints.
+const planCheckpointNodeType = "plan_checkpoint"
+
+// PlanCheckpoint records the tasks an execution of a plan has completed, so
+// that ResumePlan can continue the plan after a crash or cancellation rather
+// than running it again from the start.
+type PlanCheckpoint struct {
+	// ID is the storage node the checkpoint is recorded in
+	ID string
+
+	PlanID      string
+	ObjectiveID string
+
+	// Plan is the plan being executed
+	Plan *ExecutionPlan
+
+	// CompletedTasks are the results of the tasks completed so far, with
+	// their outputs, output references and token use
+	CompletedTasks map[string]*TaskResult
+
+	// Elapsed is how long the execution had run when the checkpoint was saved
+	Elapsed time.Duration
+
+	// UpdatedAt is when the checkpoint was last saved
+	UpdatedAt time.Time
+}
+
+// ResumePlan continues the execution of a plan from its latest checkpoint.
+// Tasks the checkpoint completed are skipped and their stored outputs are
+// passed on to the tasks that depend on them. The result is flagged as
+// resumed and its TotalDuration includes the interrupted run.
+//
+// A plan whose objective has since been completed is not resumed; its
+// checkpoints are cleaned up and a Conflict error is returned.
+func (rtc *RealTimeCursor) ResumePlan(ctx context.Context, planID string) (*ExecutionResult, error) {
+	checkpoints, err := rtc.planCheckpoints(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, errs.Newf(errs.NotFound, "no checkpoint found for plan %s", planID).With("plan_id", planID)
+	}
+	latest := checkpoints[0]
+	for _, checkpoint := range checkpoints[1:] {
+		if checkpoint.UpdatedAt.After(latest.UpdatedAt) {
+			latest = checkpoint
+		}
+	}
+	if latest.Plan == nil {
+		return nil, errs.Newf(errs.Conflict, "checkpoint %s of plan %s has no plan to resume", latest.ID, planID)
+	}
+
+	if rtc.objectiveCompleted(ctx, latest.ObjectiveID) {
+		if _, err := rtc.discardCheckpoints(ctx, checkpoints); err != nil {
+			fmt.Printf("Warning: failed to clean up checkpoints of plan %s: %v\n", planID, err)
+		}
+		return nil, errs.Newf(errs.Conflict, "objective %s of plan %s is already completed", latest.ObjectiveID, planID)
+	}
+
+	resumeFrom := &ExecutionResult{
+		PlanID:        latest.PlanID,
+		ObjectiveID:   latest.ObjectiveID,
+		TaskResults:   latest.CompletedTasks,
+		TotalDuration: latest.Elapsed,
+		checkpointID:  latest.ID,
+	}
+	return rtc.executePlan(ctx, latest.Plan, resumeFrom)
+}
+
+// CleanupCheckpoints removes the checkpoints of plans whose objective has
+// been completed, which can no longer be resumed. It returns how many
+// checkpoints were removed. Removed checkpoints are archived, not deleted.
+func (rtc *RealTimeCursor) CleanupCheckpoints(ctx context.Context) (int, error) {
+	checkpoints, err := rtc.planCheckpoints(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	var stale []*PlanCheckpoint
+	for _, checkpoint := range checkpoints {
+		if rtc.objectiveCompleted(ctx, checkpoint.ObjectiveID) {
+			stale = append(stale, checkpoint)
+		}
+	}
+	return rtc.discardCheckpoints(ctx, stale)
+}
+
+// saveCheckpoint records the tasks the execution has completed so far. It
+// is saved even when ctx was cancelled after the last task completed, so
+// that task is not run again on resume.
+func (rtc *RealTimeCursor) saveCheckpoint(ctx context.Context, plan *ExecutionPlan, result *ExecutionResult) error {
+	completed := make(map[string]interface{})
+	for taskID, taskResult := range result.TaskResults {
+		if taskResult.Status != TaskStatusCompleted {
+			continue
+		}
+		taskData := map[string]interface{}{
+			"output_ref":        taskResult.OutputRef,
+			"tokens_used":       taskResult.TokensUsed,
+			"duration":          taskResult.Duration.Seconds(),
+			"confidence":        taskResult.Confidence,
+			"tools_used":        stringsToInterfaces(taskResult.ToolsUsed),
+			"method_step_index": taskResult.MethodStepIndex,
+			"completed_at":      taskResult.CompletedAt.Format(time.RFC3339Nano),
+		}
+		outputData(taskResult, taskData)
+		completed[taskID] = taskData
+	}
+
+	data := map[string]interface{}{
+		"plan_id":         plan.ID,
+		"objective_id":    plan.ObjectiveID,
+		"plan":            planToData(plan),
+		"completed_tasks": completed,
+		"elapsed":         result.elapsed().Seconds(),
+		"updated_at":      time.Now().Format(time.RFC3339Nano),
+	}
+	if plan.ContextFingerprint != nil {
+		data["context_fingerprint"] = plan.ContextFingerprint.toData()
+	}
+	if plan.Replan != nil {
+		data["replan"] = plan.Replan.toData()
+	}
+	if plan.Comparison != nil {
+		data["comparison"] = plan.Comparison.toData()
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	if result.checkpointID != "" {
+		return rtc.store.UpdateNode(ctx, result.checkpointID, data)
+	}
+	node := storage.NewNode(planCheckpointNodeType, data)
+	if err := rtc.store.AddNode(ctx, node); err != nil {
+		return err
+	}
+	result.checkpointID = node.ID
+	return nil
+}
+
+// discardPlanCheckpoints removes every checkpoint of a plan whose execution
+// has finished.
+func (rtc *RealTimeCursor) discardPlanCheckpoints(ctx context.Context, planID string) error {
+	checkpoints, err := rtc.planCheckpoints(ctx, planID)
+	if err != nil {
+		return err
+	}
+	_, err = rtc.discardCheckpoints(ctx, checkpoints)
+	return err
+}
+
+// discardCheckpoints archives the given checkpoints, returning how many there were.
+func (rtc *RealTimeCursor) discardCheckpoints(ctx context.Context, checkpoints []*PlanCheckpoint) (int, error) {
+	if len(checkpoints) == 0 {
+		return 0, nil
+	}
+	nodeIDs := make([]string, len(checkpoints))
+	for i, checkpoint := range checkpoints {
+		nodeIDs[i] = checkpoint.ID
+	}
+	if _, err := rtc.store.ArchiveNodes(ctx, nodeIDs); err != nil {
+		return 0, fmt.Errorf("failed to archive checkpoints: %w", err)
+	}
+	return len(nodeIDs), nil
+}
+
+// planCheckpoints returns the live checkpoints of a plan, or of every plan
+// when planID is empty.
+func (rtc *RealTimeCursor) planCheckpoints(ctx context.Context, planID string) ([]*PlanCheckpoint, error) {
+	nodes, err := rtc.store.GetNodesByType(ctx, planCheckpointNodeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plan checkpoints: %w", err)
+	}
+
+	var checkpoints []*PlanCheckpoint
+	for _, node := range nodes {
+		if planID != "" && getString(node.Data, "plan_id") != planID {
+			continue
+		}
+		checkpoints = append(checkpoints, planCheckpointFromNode(node))
+	}
+	return checkpoints, nil
+}
+
+// objectiveCompleted reports whether an objective exists and is completed.
+func (rtc *RealTimeCursor) objectiveCompleted(ctx context.Context, objectiveID string) bool {
+	objective, err := NewObjectiveManager(rtc.store).GetObjective(ctx, objectiveID)
+	return err == nil && objective.Status == ObjectiveStatusCompleted
+}
+
+// planCheckpointFromNode converts a stored plan_checkpoint node to a PlanCheckpoint.
+func planCheckpointFromNode(node *storage.Node) *PlanCheckpoint {
+	checkpoint := &PlanCheckpoint{
+		ID:             node.ID,
+		PlanID:         getString(node.Data, "plan_id"),
+		ObjectiveID:    getString(node.Data, "objective_id"),
+		CompletedTasks: make(map[string]*TaskResult),
+		Elapsed:        time.Duration(getFloat64(node.Data, "elapsed") * float64(time.Second)),
+	}
+	if updatedAt, err := time.Parse(time.RFC3339Nano, getString(node.Data, "updated_at")); err == nil {
+		checkpoint.UpdatedAt = updatedAt
+	}
+
+	if planData, ok := node.Data["plan"].(map[string]interface{}); ok {
+		checkpoint.Plan = planFromData(planData)
+		if fingerprintData, ok := node.Data["context_fingerprint"].(map[string]interface{}); ok {
+			checkpoint.Plan.ContextFingerprint = contextFingerprintFromData(fingerprintData)
+		}
+		if replanData, ok := node.Data["replan"].(map[string]interface{}); ok {
+			checkpoint.Plan.Replan = replanDecisionFromData(replanData)
+			checkpoint.Plan.Replan.Fingerprint = checkpoint.Plan.ContextFingerprint
+		}
+		if comparisonData, ok := node.Data["comparison"].(map[string]interface{}); ok {
+			checkpoint.Plan.Comparison = comparisonBranchFromData(comparisonData)
+		}
+	}
+
+	completed, _ := node.Data["completed_tasks"].(map[string]interface{})
+	for taskID, item := range completed {
+		taskData, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		taskResult := &TaskResult{
+			TaskID:          taskID,
+			MethodStepIndex: int(getFloat64(taskData, "method_step_index")),
+			Status:          TaskStatusCompleted,
+			Output:          taskData["output"],
+			OutputRef:       getString(taskData, "output_ref"),
+			TokensUsed:      int(getFloat64(taskData, "tokens_used")),
+			Duration:        time.Duration(getFloat64(taskData, "duration") * float64(time.Second)),
+			ToolsUsed:       toStringSlice(taskData["tools_used"]),
+			Confidence:      getFloat64(taskData, "confidence"),
+		}
+		taskResult.outputJSON, _ = taskData["output_json"].(bool)
+		if completedAt, err := time.Parse(time.RFC3339Nano, getString(taskData, "completed_at")); err == nil {
+			taskResult.CompletedAt = completedAt
+		}
+		checkpoint.CompletedTasks[taskID] = taskResult
+	}
+	return checkpoint
+}
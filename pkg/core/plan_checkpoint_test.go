@@ -0,0 +1,242 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// chainExecutor outputs each task's ID followed by the outputs of its
+// prerequisites, so a task run without its dependency context gives a
+// different output. It calls interrupt once the task interruptAfter completes.
+type chainExecutor struct {
+	calls          []string
+	interruptAfter string
+	interrupt      func()
+}
+
+func (e *chainExecutor) ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e.calls = append(e.calls, task.ID)
+
+	var inputs []string
+	if outputs, ok := fullContext[DependencyOutputsKey].(map[string]interface{}); ok {
+		for _, output := range outputs {
+			inputs = append(inputs, output.(string))
+		}
+	}
+	sort.Strings(inputs)
+	output := task.ID + "(" + strings.Join(inputs, ",") + ")"
+
+	if task.ID == e.interruptAfter && e.interrupt != nil {
+		e.interrupt()
+	}
+	return &TaskResult{
+		TaskID:     task.ID,
+		Status:     TaskStatusCompleted,
+		Output:     output,
+		OutputRef:  "ref:" + task.ID,
+		TokensUsed: 10 * len(output),
+		Confidence: 0.9,
+		ToolsUsed:  []string{"llm"},
+	}, nil
+}
+
+func (e *chainExecutor) GetAvailableTools(ctx context.Context) ([]string, error) {
+	return []string{"llm"}, nil
+}
+
+func (e *chainExecutor) EstimateTokenUsage(ctx context.Context, task *ExecutionTask) (int, error) {
+	return task.EstimatedTokens, nil
+}
+
+// chainPlan returns a plan of three tasks, each depending on the one before.
+func chainPlan(objectiveID string) *ExecutionPlan {
+	plan := &ExecutionPlan{
+		ID:          "chain_plan",
+		ObjectiveID: objectiveID,
+		Title:       "Chained plan",
+		CreatedAt:   time.Now(),
+	}
+	for _, id := range []string{"gather", "draft", "review"} {
+		plan.Tasks = append(plan.Tasks, ExecutionTask{ID: id, Type: "generate", Description: "Do " + id, MethodStepIndex: -1, EstimatedTokens: 100})
+	}
+	plan.Dependencies = []TaskDependency{
+		{TaskID: "draft", DependsOnTaskID: "gather"},
+		{TaskID: "review", DependsOnTaskID: "draft"},
+	}
+	return plan
+}
+
+// interruptPlan runs the chain plan, cancelling it once the draft task
+// completes, as a crash mid-plan would stop it.
+func interruptPlan(t *testing.T, store *storage.Store, objectiveID string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	executor := &chainExecutor{interruptAfter: "draft", interrupt: cancel}
+	result, err := NewRealTimeCursor(store, executor, NewMockContextLoader()).ExecutePlan(ctx, chainPlan(objectiveID))
+	if err == nil || result.Status != ExecutionStatusCancelled {
+		t.Fatalf("Expected the execution to be cancelled, got %v, %v", result.Status, err)
+	}
+	if strings.Join(executor.calls, ",") != "gather,draft" {
+		t.Fatalf("Expected the cancel to stop before review, got calls %v", executor.calls)
+	}
+}
+
+func TestResumePlan_MatchesUninterruptedRun(t *testing.T) {
+	ctx := context.Background()
+
+	// The reference run is never interrupted
+	uninterrupted, err := NewRealTimeCursor(setupTestStore(t), &chainExecutor{}, NewMockContextLoader()).ExecutePlan(ctx, chainPlan("objective_1"))
+	if err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+
+	// The interrupted run resumes after a restart
+	dir := t.TempDir()
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	interruptPlan(t, store, "objective_1")
+	store.Close()
+
+	store, err = storage.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	executor := &chainExecutor{}
+	resumed, err := NewRealTimeCursor(store, executor, NewMockContextLoader()).ResumePlan(ctx, "chain_plan")
+	if err != nil {
+		t.Fatalf("ResumePlan failed: %v", err)
+	}
+
+	if strings.Join(executor.calls, ",") != "review" {
+		t.Errorf("Expected only the review task to run again, got calls %v", executor.calls)
+	}
+	if resumed.Status != uninterrupted.Status || resumed.SuccessfulTasks != 3 || resumed.TotalTokensUsed != uninterrupted.TotalTokensUsed {
+		t.Errorf("Expected the resumed run to match the uninterrupted one, got %s with %d tasks and %d tokens, want %s with 3 tasks and %d tokens",
+			resumed.Status, resumed.SuccessfulTasks, resumed.TotalTokensUsed, uninterrupted.Status, uninterrupted.TotalTokensUsed)
+	}
+	for taskID, want := range uninterrupted.TaskResults {
+		got := resumed.TaskResults[taskID]
+		if got == nil || got.Output != want.Output || got.OutputRef != want.OutputRef || got.TokensUsed != want.TokensUsed {
+			t.Errorf("Expected task %s to match the uninterrupted run's %+v, got %+v", taskID, want, got)
+		}
+	}
+
+	if !resumed.Resumed || uninterrupted.Resumed {
+		t.Errorf("Expected only the resumed run to be flagged, got %v and %v", resumed.Resumed, uninterrupted.Resumed)
+	}
+	if resumed.TotalDuration != resumed.OriginalDuration+resumed.ResumedDuration {
+		t.Errorf("Expected the total duration %s to combine the original %s and resumed %s",
+			resumed.TotalDuration, resumed.OriginalDuration, resumed.ResumedDuration)
+	}
+	stored, err := executionResultFromNode(mustGetNode(t, store, resumed.ID))
+	if err != nil || !stored.Resumed {
+		t.Errorf("Expected the stored result to be flagged as resumed, got %+v, %v", stored, err)
+	}
+
+	// A finished plan leaves nothing to resume
+	if _, err := NewRealTimeCursor(store, executor, NewMockContextLoader()).ResumePlan(ctx, "chain_plan"); errs.CodeOf(err) != errs.NotFound {
+		t.Errorf("Expected no checkpoint to be left, got %v", err)
+	}
+}
+
+func TestCleanupCheckpoints_CompletedObjectives(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Publish the guide", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Writing", "", []ApproachStep{{Description: "Write"}}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	objectives := NewObjectiveManager(store)
+	objective, err := objectives.CreateObjective(ctx, goal.ID, method.ID, "Write the guide", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+
+	interruptPlan(t, store, objective.ID)
+	rtc := NewRealTimeCursor(store, &chainExecutor{}, NewMockContextLoader())
+
+	// Checkpoints of open objectives are kept
+	if removed, err := rtc.CleanupCheckpoints(ctx); err != nil || removed != 0 {
+		t.Fatalf("Expected no checkpoints removed, got %d, %v", removed, err)
+	}
+
+	if _, err := objectives.StartObjective(ctx, objective.ID); err != nil {
+		t.Fatalf("Failed to start objective: %v", err)
+	}
+	if _, err := objectives.CompleteObjective(ctx, objective.ID, ObjectiveResult{Success: true}); err != nil {
+		t.Fatalf("Failed to complete objective: %v", err)
+	}
+	if removed, err := rtc.CleanupCheckpoints(ctx); err != nil || removed != 1 {
+		t.Errorf("Expected the checkpoint of the completed objective removed, got %d, %v", removed, err)
+	}
+	if _, err := rtc.ResumePlan(ctx, "chain_plan"); errs.CodeOf(err) != errs.NotFound {
+		t.Errorf("Expected the cleaned up plan not to resume, got %v", err)
+	}
+}
+
+func TestResumePlan_RefusesCompletedObjective(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Publish the guide", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Writing", "", []ApproachStep{{Description: "Write"}}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	objectives := NewObjectiveManager(store)
+	objective, err := objectives.CreateObjective(ctx, goal.ID, method.ID, "Write the guide", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	interruptPlan(t, store, objective.ID)
+
+	if _, err := objectives.StartObjective(ctx, objective.ID); err != nil {
+		t.Fatalf("Failed to start objective: %v", err)
+	}
+	if _, err := objectives.CompleteObjective(ctx, objective.ID, ObjectiveResult{Success: true}); err != nil {
+		t.Fatalf("Failed to complete objective: %v", err)
+	}
+
+	executor := &chainExecutor{}
+	rtc := NewRealTimeCursor(store, executor, NewMockContextLoader())
+	if _, err := rtc.ResumePlan(ctx, "chain_plan"); errs.CodeOf(err) != errs.Conflict {
+		t.Fatalf("Expected a completed objective's plan to be refused, got %v", err)
+	}
+	if len(executor.calls) != 0 {
+		t.Errorf("Expected nothing to run, got calls %v", executor.calls)
+	}
+	if _, err := rtc.ResumePlan(ctx, "chain_plan"); errs.CodeOf(err) != errs.NotFound {
+		t.Errorf("Expected the refused plan's checkpoints to be cleaned up, got %v", err)
+	}
+}
+
+func mustGetNode(t *testing.T, store *storage.Store, nodeID string) *storage.Node {
+	t.Helper()
+	node, err := store.GetNode(context.Background(), nodeID)
+	if err != nil {
+		t.Fatalf("Failed to get node %s: %v", nodeID, err)
+	}
+	return node
+}
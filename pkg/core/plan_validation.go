@@ -0,0 +1,298 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// ErrPlanInvalid is returned when a plan fails validation before it runs.
+var ErrPlanInvalid = errs.New(errs.Validation, "execution plan is invalid")
+
+// ToolsParameter is the task parameter that lists the tools a task needs.
+const ToolsParameter = "tools"
+
+// PlanIssueKind classifies a problem found in a plan.
+type PlanIssueKind string
+
+const (
+	// PlanIssueStructure is a plan without an ID, objective or tasks, or
+	// with dependencies on tasks it does not have
+	PlanIssueStructure PlanIssueKind = "structure"
+
+	// PlanIssueUnavailableTool is a tool no service of the executor provides
+	PlanIssueUnavailableTool PlanIssueKind = "unavailable_tool"
+
+	// PlanIssueUnknownTaskType is a task type the executor does not handle
+	PlanIssueUnknownTaskType PlanIssueKind = "unknown_task_type"
+
+	// PlanIssueUnknownMethod is a method the plan implements that is not stored
+	PlanIssueUnknownMethod PlanIssueKind = "unknown_method"
+
+	// PlanIssueUncoveredStep is a method step no task of the plan implements
+	PlanIssueUncoveredStep PlanIssueKind = "uncovered_step"
+
+	// PlanIssueOverBudget is an estimate the remaining budget cannot afford
+	PlanIssueOverBudget PlanIssueKind = "over_budget"
+)
+
+// PlanIssue is one problem found in a plan.
+type PlanIssue struct {
+	Kind PlanIssueKind
+
+	// TaskID is the task the issue is about ("" for the whole plan)
+	TaskID string
+
+	// StepIndex is the method step the issue is about (-1 if none)
+	StepIndex int
+
+	Message string
+}
+
+// PlanValidationReport lists what validating a plan found. Errors stop the
+// plan from running; warnings are recorded with it.
+type PlanValidationReport struct {
+	PlanID      string
+	Errors      []PlanIssue
+	Warnings    []PlanIssue
+	ValidatedAt time.Time
+}
+
+// Valid reports whether the plan may run.
+func (r *PlanValidationReport) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Err returns an error matching ErrPlanInvalid that lists the errors, or
+// nil for a valid plan.
+func (r *PlanValidationReport) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	messages := make([]string, len(r.Errors))
+	for i, issue := range r.Errors {
+		messages[i] = issue.Message
+	}
+	return fmt.Errorf("%w: %s", ErrPlanInvalid, strings.Join(messages, "; "))
+}
+
+func (r *PlanValidationReport) addError(kind PlanIssueKind, taskID string, step int, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, PlanIssue{Kind: kind, TaskID: taskID, StepIndex: step, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *PlanValidationReport) addWarning(kind PlanIssueKind, taskID string, step int, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, PlanIssue{Kind: kind, TaskID: taskID, StepIndex: step, Message: fmt.Sprintf(format, args...)})
+}
+
+// PlanValidator checks a plan against what can actually run it: the
+// executor's tools and task types, the method it implements, and the budget.
+type PlanValidator struct {
+	methodManager *MethodManager
+	executor      TaskExecutor
+
+	// taskTypes are the task types the executor handles; none registered
+	// means any type is accepted
+	taskTypes map[string]bool
+
+	budget       *llm.BudgetManager
+	costPerToken float64
+}
+
+// NewPlanValidator creates a validator for plans run by executor. Without
+// an executor, tools and token estimates are not checked.
+func NewPlanValidator(store *storage.Store, executor TaskExecutor) *PlanValidator {
+	return &PlanValidator{
+		methodManager: NewMethodManager(store),
+		executor:      executor,
+		taskTypes:     make(map[string]bool),
+	}
+}
+
+// RegisterTaskTypes adds task types the executor handles. Once any are
+// registered, tasks of other types are errors.
+func (v *PlanValidator) RegisterTaskTypes(types ...string) {
+	for _, taskType := range types {
+		v.taskTypes[strings.ToLower(taskType)] = true
+	}
+}
+
+// SetBudgetManager checks plan estimates, priced at costPerToken, against
+// the remaining budget. A nil budget or a zero price turns the check off.
+func (v *PlanValidator) SetBudgetManager(budget *llm.BudgetManager, costPerToken float64) {
+	v.budget = budget
+	v.costPerToken = costPerToken
+}
+
+// Validate checks a plan and returns what it found. A plan that is not
+// structurally sound is not checked further.
+func (v *PlanValidator) Validate(ctx context.Context, plan *ExecutionPlan) *PlanValidationReport {
+	report := &PlanValidationReport{ValidatedAt: time.Now()}
+	if err := validatePlanStructure(plan); err != nil {
+		report.addError(PlanIssueStructure, "", -1, "%v", err)
+		return report
+	}
+	report.PlanID = plan.ID
+
+	v.checkTaskTypes(plan, report)
+	method := v.checkMethod(ctx, plan, report)
+	v.checkTools(ctx, plan, method, report)
+	v.checkBudget(ctx, plan, report)
+	return report
+}
+
+// checkTaskTypes reports tasks of types the executor does not handle.
+func (v *PlanValidator) checkTaskTypes(plan *ExecutionPlan, report *PlanValidationReport) {
+	if len(v.taskTypes) == 0 {
+		return
+	}
+	for _, task := range plan.Tasks {
+		if !v.taskTypes[strings.ToLower(task.Type)] {
+			report.addError(PlanIssueUnknownTaskType, task.ID, task.MethodStepIndex, "task %s has unknown type %q", task.ID, task.Type)
+		}
+	}
+}
+
+// checkMethod confirms the plan's method exists, warns about its steps no
+// task implements, and returns it (nil for custom plans or a missing method).
+func (v *PlanValidator) checkMethod(ctx context.Context, plan *ExecutionPlan, report *PlanValidationReport) *Method {
+	if plan.MethodID == "" {
+		return nil
+	}
+	method, err := v.methodManager.GetMethod(ctx, plan.MethodID)
+	if err != nil {
+		report.addError(PlanIssueUnknownMethod, "", -1, "method %s not found", plan.MethodID)
+		return nil
+	}
+
+	covered := make(map[int]bool, len(plan.Tasks))
+	for _, task := range plan.Tasks {
+		covered[task.MethodStepIndex] = true
+	}
+	for i, step := range method.Approach {
+		if !covered[i] {
+			report.addWarning(PlanIssueUncoveredStep, "", i, "no task implements step %d of method %s: %s", i+1, method.Name, step.Description)
+		}
+	}
+	return method
+}
+
+// checkTools reports tools a task needs that the executor does not offer.
+// Tools a task declares in its ToolsParameter are errors; tools its method
+// step lists are warnings, as steps name them loosely.
+func (v *PlanValidator) checkTools(ctx context.Context, plan *ExecutionPlan, method *Method, report *PlanValidationReport) {
+	if v.executor == nil {
+		return
+	}
+	tools, err := v.executor.GetAvailableTools(ctx)
+	if err != nil {
+		report.addWarning(PlanIssueUnavailableTool, "", -1, "could not list available tools: %v", err)
+		return
+	}
+	available := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		available[strings.ToLower(tool)] = true
+	}
+
+	for _, task := range plan.Tasks {
+		for _, tool := range toStringSlice(task.Context.Parameters[ToolsParameter]) {
+			if !available[strings.ToLower(tool)] {
+				report.addError(PlanIssueUnavailableTool, task.ID, task.MethodStepIndex, "task %s needs tool %q, which no service provides", task.ID, tool)
+			}
+		}
+		if method == nil || task.MethodStepIndex < 0 || task.MethodStepIndex >= len(method.Approach) {
+			continue
+		}
+		for _, tool := range method.Approach[task.MethodStepIndex].Tools {
+			if !available[strings.ToLower(tool)] {
+				report.addWarning(PlanIssueUnavailableTool, task.ID, task.MethodStepIndex, "step %d of task %s lists tool %q, which no service provides", task.MethodStepIndex+1, task.ID, tool)
+			}
+		}
+	}
+}
+
+// checkBudget estimates the plan's tokens and checks their cost against the
+// remaining budget.
+func (v *PlanValidator) checkBudget(ctx context.Context, plan *ExecutionPlan, report *PlanValidationReport) {
+	if v.budget == nil || v.costPerToken <= 0 {
+		return
+	}
+	tokens := plan.TotalEstimatedTokens
+	if tokens == 0 && v.executor != nil {
+		for i := range plan.Tasks {
+			if estimate, err := v.executor.EstimateTokenUsage(ctx, &plan.Tasks[i]); err == nil {
+				tokens += estimate
+			}
+		}
+	}
+
+	cost := float64(tokens) * v.costPerToken
+	check, err := v.budget.CanAfford(cost)
+	if err != nil {
+		report.addWarning(PlanIssueOverBudget, "", -1, "could not check budget: %v", err)
+		return
+	}
+	if !check.Affordable {
+		report.addError(PlanIssueOverBudget, "", -1, "estimated %d tokens ($%.2f) exceed the remaining budget: %s", tokens, cost, strings.Join(check.Warnings, "; "))
+		return
+	}
+	for _, warning := range check.Warnings {
+		report.addWarning(PlanIssueOverBudget, "", -1, "estimated %d tokens ($%.2f): %s", tokens, cost, warning)
+	}
+}
+
+// toData converts the report to storage data.
+func (r *PlanValidationReport) toData() map[string]interface{} {
+	issues := func(list []PlanIssue) []interface{} {
+		items := make([]interface{}, len(list))
+		for i, issue := range list {
+			items[i] = map[string]interface{}{
+				"kind":       string(issue.Kind),
+				"task_id":    issue.TaskID,
+				"step_index": issue.StepIndex,
+				"message":    issue.Message,
+			}
+		}
+		return items
+	}
+	return map[string]interface{}{
+		"plan_id":      r.PlanID,
+		"errors":       issues(r.Errors),
+		"warnings":     issues(r.Warnings),
+		"validated_at": r.ValidatedAt.Format(time.RFC3339),
+	}
+}
+
+// planValidationFromData converts stored data back to a report.
+func planValidationFromData(data map[string]interface{}) *PlanValidationReport {
+	issues := func(value interface{}) []PlanIssue {
+		items, _ := value.([]interface{})
+		var list []PlanIssue
+		for _, item := range items {
+			issueData, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			list = append(list, PlanIssue{
+				Kind:      PlanIssueKind(getString(issueData, "kind")),
+				TaskID:    getString(issueData, "task_id"),
+				StepIndex: int(getFloat64(issueData, "step_index")),
+				Message:   getString(issueData, "message"),
+			})
+		}
+		return list
+	}
+	report := &PlanValidationReport{
+		PlanID:   getString(data, "plan_id"),
+		Errors:   issues(data["errors"]),
+		Warnings: issues(data["warnings"]),
+	}
+	if validatedAt := parseOptionalTime(data["validated_at"]); validatedAt != nil {
+		report.ValidatedAt = *validatedAt
+	}
+	return report
+}
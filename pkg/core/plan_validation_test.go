@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+// createValidationMethod stores a two-step method whose steps use the mock
+// executor's tools.
+func createValidationMethod(t *testing.T, rtc *RealTimeCursor) *Method {
+	t.Helper()
+	method, err := rtc.methodManager.CreateMethod(context.Background(), "Analyze and report", "Two steps",
+		[]ApproachStep{
+			{Description: "Analyze the data", Tools: []string{"mock_tool"}},
+			{Description: "Write the report", Tools: []string{"another_tool"}},
+		}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	return method
+}
+
+// validationPlan returns the test plan implementing both steps of method.
+func validationPlan(method *Method) *ExecutionPlan {
+	plan := createTestPlan()
+	plan.MethodID = method.ID
+	plan.Tasks[0].MethodStepIndex = 0
+	plan.Tasks[1].MethodStepIndex = 1
+	return plan
+}
+
+// issueKinds returns the kinds of a list of issues.
+func issueKinds(issues []PlanIssue) []PlanIssueKind {
+	kinds := make([]PlanIssueKind, len(issues))
+	for i, issue := range issues {
+		kinds[i] = issue.Kind
+	}
+	return kinds
+}
+
+func TestPlanValidator_ValidPlan(t *testing.T) {
+	rtc, store, executor, _ := setupTestRTC(t)
+	method := createValidationMethod(t, rtc)
+	validator := NewPlanValidator(store, executor)
+	validator.RegisterTaskTypes("Analyze", "generate")
+
+	report := validator.Validate(context.Background(), validationPlan(method))
+	if !report.Valid() || len(report.Warnings) != 0 || report.Err() != nil {
+		t.Errorf("Expected a clean report, got errors %v and warnings %v", report.Errors, report.Warnings)
+	}
+	if report.PlanID != "test_plan_123" {
+		t.Errorf("Expected the report to name the plan, got %q", report.PlanID)
+	}
+}
+
+func TestPlanValidator_FailureClasses(t *testing.T) {
+	ctx := context.Background()
+	rtc, store, executor, _ := setupTestRTC(t)
+	method := createValidationMethod(t, rtc)
+
+	tests := []struct {
+		name     string
+		setup    func(v *PlanValidator, plan *ExecutionPlan)
+		errors   []PlanIssueKind
+		warnings []PlanIssueKind
+	}{
+		{
+			name:   "structure",
+			setup:  func(v *PlanValidator, plan *ExecutionPlan) { plan.Tasks = nil },
+			errors: []PlanIssueKind{PlanIssueStructure},
+		},
+		{
+			name: "declared tool unavailable",
+			setup: func(v *PlanValidator, plan *ExecutionPlan) {
+				plan.Tasks[1].Context.Parameters[ToolsParameter] = []interface{}{"another_tool", "email"}
+			},
+			errors: []PlanIssueKind{PlanIssueUnavailableTool},
+		},
+		{
+			name:     "step tool unavailable",
+			setup:    func(v *PlanValidator, plan *ExecutionPlan) { executor.mockAvailableTools = []string{"mock_tool"} },
+			warnings: []PlanIssueKind{PlanIssueUnavailableTool},
+		},
+		{
+			name: "unknown task type",
+			setup: func(v *PlanValidator, plan *ExecutionPlan) {
+				v.RegisterTaskTypes("analyze")
+			},
+			errors: []PlanIssueKind{PlanIssueUnknownTaskType},
+		},
+		{
+			name:   "unknown method",
+			setup:  func(v *PlanValidator, plan *ExecutionPlan) { plan.MethodID = "method-missing" },
+			errors: []PlanIssueKind{PlanIssueUnknownMethod},
+		},
+		{
+			name:     "uncovered step",
+			setup:    func(v *PlanValidator, plan *ExecutionPlan) { plan.Tasks[1].MethodStepIndex = 0 },
+			warnings: []PlanIssueKind{PlanIssueUncoveredStep},
+		},
+		{
+			name: "over budget",
+			setup: func(v *PlanValidator, plan *ExecutionPlan) {
+				budget, err := llm.NewBudgetManager(t.TempDir(), llm.BudgetConfig{DailyLimit: 0.1, TrackingEnabled: true}, log.New(io.Discard, "", 0))
+				if err != nil {
+					t.Fatalf("Failed to create budget manager: %v", err)
+				}
+				v.SetBudgetManager(budget, 0.001)
+			},
+			errors: []PlanIssueKind{PlanIssueOverBudget},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor.mockAvailableTools = []string{"mock_tool", "another_tool"}
+			validator := NewPlanValidator(store, executor)
+			plan := validationPlan(method)
+			tt.setup(validator, plan)
+
+			report := validator.Validate(ctx, plan)
+			if got := issueKinds(report.Errors); len(got) != len(tt.errors) || len(got) > 0 && got[0] != tt.errors[0] {
+				t.Errorf("Expected errors %v, got %v", tt.errors, report.Errors)
+			}
+			if got := issueKinds(report.Warnings); len(got) != len(tt.warnings) || len(got) > 0 && got[0] != tt.warnings[0] {
+				t.Errorf("Expected warnings %v, got %v", tt.warnings, report.Warnings)
+			}
+			if err := report.Err(); (err != nil) != (len(tt.errors) > 0) || err != nil && !errors.Is(err, ErrPlanInvalid) {
+				t.Errorf("Expected an error matching ErrPlanInvalid only for errors, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRealTimeCursor_RejectsInvalidPlan(t *testing.T) {
+	ctx := context.Background()
+	rtc, store, executor, _ := setupTestRTC(t)
+	method := createValidationMethod(t, rtc)
+	rtc.SetPlanValidator(NewPlanValidator(store, executor))
+
+	plan := validationPlan(method)
+	plan.Tasks[0].Context.Parameters[ToolsParameter] = []string{"email"}
+	result, err := rtc.ExecutePlan(ctx, plan)
+	if !errors.Is(err, ErrPlanInvalid) {
+		t.Fatalf("Expected ErrPlanInvalid, got %v", err)
+	}
+	if len(executor.executeTaskCalls) != 0 || result.Status != ExecutionStatusFailed {
+		t.Errorf("Expected a failed execution without tasks run, got %s after %d tasks", result.Status, len(executor.executeTaskCalls))
+	}
+
+	// The rejected plan is stored with its report
+	node, err := store.GetNode(ctx, result.ID)
+	if err != nil {
+		t.Fatalf("Expected the rejected plan to be stored: %v", err)
+	}
+	stored, err := executionResultFromNode(node)
+	if err != nil {
+		t.Fatalf("Failed to load execution: %v", err)
+	}
+	validation := stored.Plan.Validation
+	if validation == nil || len(validation.Errors) != 1 || validation.Errors[0].Kind != PlanIssueUnavailableTool || validation.Errors[0].TaskID != "task_1" {
+		t.Errorf("Expected the stored plan to carry its report, got %+v", validation)
+	}
+
+	// A valid plan runs, its warnings kept with it
+	plan = validationPlan(method)
+	plan.Tasks[1].MethodStepIndex = 0
+	result, err = rtc.ExecutePlan(ctx, plan)
+	if err != nil || result.Status != ExecutionStatusCompleted {
+		t.Fatalf("Expected the plan to run, got %v, %v", result.Status, err)
+	}
+	if plan.Validation == nil || len(plan.Validation.Warnings) != 1 {
+		t.Errorf("Expected the uncovered step to be warned about, got %+v", plan.Validation)
+	}
+}
@@ -0,0 +1,706 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+const (
+	// preferenceSignalNodeType is the storage node type of queued rating and edit signals
+	preferenceSignalNodeType = "preference_signal"
+
+	// artifactSnapshotNodeType records the content of an output artifact as last seen
+	artifactSnapshotNodeType = "artifact_snapshot"
+
+	// PreferenceMiningTaskType attributes preference mining spending in the budget
+	PreferenceMiningTaskType = "meta"
+
+	// ResultArtifactsKey lists the files an objective produced, in ObjectiveResult.Data
+	ResultArtifactsKey = "artifacts"
+
+	// preferenceMiningWindow is the period the weekly mining budget covers
+	preferenceMiningWindow = 7 * 24 * time.Hour
+)
+
+// PreferenceSignalKind is the kind of user reaction a signal records.
+type PreferenceSignalKind string
+
+const (
+	// PreferenceSignalRating is a rating of an objective's output
+	PreferenceSignalRating PreferenceSignalKind = "rating"
+
+	// PreferenceSignalEdit is an edit the user made to an output artifact
+	PreferenceSignalEdit PreferenceSignalKind = "edit"
+)
+
+// PreferenceSignal is a user reaction to an objective's output, queued until
+// the miner has looked for preferences in it.
+type PreferenceSignal struct {
+	ID          string
+	ObjectiveID string
+	Kind        PreferenceSignalKind
+	UserID      string
+
+	// Rating is the 1-10 rating, for rating signals
+	Rating int
+
+	// Comment is what the user said with a rating
+	Comment string
+
+	// Path is the edited artifact, for edit signals
+	Path string
+
+	// Original is the output as produced, and Edited the user's version of it
+	Original string
+	Edited   string
+
+	CreatedAt time.Time
+	MinedAt   *time.Time
+
+	// Cost is what mining the signal cost, in dollars
+	Cost float64
+
+	// Contexts are the user context entries the signal proposed or reinforced
+	Contexts []string
+}
+
+// IsMined reports whether the miner has processed the signal.
+func (s *PreferenceSignal) IsMined() bool {
+	return s.MinedAt != nil
+}
+
+// PreferenceCandidate is a preference statement the model inferred from a signal.
+type PreferenceCandidate struct {
+	Statement  string   `json:"statement"`
+	Confidence float64  `json:"confidence"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// PreferenceMiningConfig configures preference mining.
+type PreferenceMiningConfig struct {
+	// Router routes mining requests to a cheap model (required to mine)
+	Router *llm.Router
+
+	// Budget records mining spending under PreferenceMiningTaskType (optional)
+	Budget *llm.BudgetManager
+
+	// WeeklyBudget caps mining spending over any seven days, in dollars
+	WeeklyBudget float64
+
+	// RequestBudget is the most a single mining request may cost, in dollars
+	RequestBudget float64
+
+	// ConfidenceThreshold is the confidence a candidate needs to be proposed
+	ConfidenceThreshold float64
+
+	// MergeSimilarity is the word overlap (0-1) at which a candidate is
+	// treated as a statement already known and reinforces it instead
+	MergeSimilarity float64
+
+	// ReinforceBoost is the confidence a merged candidate adds to the entry it matched
+	ReinforceBoost float64
+
+	// MaxExcerpt caps the characters of output sent to the model per side
+	MaxExcerpt int
+
+	// MaxTokens caps the mining response length
+	MaxTokens int
+
+	// Clock times signals and the weekly budget window (default: the system clock)
+	Clock utils.Clock
+}
+
+// DefaultPreferenceMiningConfig returns defaults suited to a cheap model.
+func DefaultPreferenceMiningConfig() PreferenceMiningConfig {
+	return PreferenceMiningConfig{
+		WeeklyBudget:        0.10,
+		RequestBudget:       0.005,
+		ConfidenceThreshold: 0.6,
+		MergeSimilarity:     0.6,
+		ReinforceBoost:      0.1,
+		MaxExcerpt:          1500,
+		MaxTokens:           300,
+	}
+}
+
+// PreferenceMiningReport summarizes a mining pass.
+type PreferenceMiningReport struct {
+	Mined      int     // Signals processed
+	Proposed   int     // New pending preferences
+	Reinforced int     // Existing preferences a candidate merged into
+	Ignored    int     // Candidates below the threshold or matching dismissed preferences
+	Spent      float64 // Dollars spent in this pass
+
+	// BudgetExhausted is set when signals were left queued because the
+	// weekly budget ran out
+	BudgetExhausted bool
+}
+
+// PreferenceMiner learns candidate preferences from how the user rates and
+// edits objective outputs. Candidates are stored as pending user context and
+// only inform the system once the user confirms them.
+type PreferenceMiner struct {
+	store      *storage.Store
+	contexts   *UserContextManager
+	objectives *ObjectiveManager
+	config     PreferenceMiningConfig
+	clock      utils.Clock
+}
+
+// NewPreferenceMiner creates a miner. Zero config values fall back to the defaults.
+func NewPreferenceMiner(store *storage.Store, contexts *UserContextManager, config PreferenceMiningConfig) *PreferenceMiner {
+	defaults := DefaultPreferenceMiningConfig()
+	if config.WeeklyBudget <= 0 {
+		config.WeeklyBudget = defaults.WeeklyBudget
+	}
+	if config.RequestBudget <= 0 {
+		config.RequestBudget = defaults.RequestBudget
+	}
+	if config.ConfidenceThreshold <= 0 {
+		config.ConfidenceThreshold = defaults.ConfidenceThreshold
+	}
+	if config.MergeSimilarity <= 0 {
+		config.MergeSimilarity = defaults.MergeSimilarity
+	}
+	if config.ReinforceBoost <= 0 {
+		config.ReinforceBoost = defaults.ReinforceBoost
+	}
+	if config.MaxExcerpt <= 0 {
+		config.MaxExcerpt = defaults.MaxExcerpt
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = defaults.MaxTokens
+	}
+
+	return &PreferenceMiner{
+		store:      store,
+		contexts:   contexts,
+		objectives: NewObjectiveManager(store),
+		config:     config,
+		clock:      utils.ClockOrReal(config.Clock),
+	}
+}
+
+// RecordRating queues a 1-10 rating of a finished objective's output.
+func (pm *PreferenceMiner) RecordRating(ctx context.Context, objectiveID string, rating int, comment, userID string) (*PreferenceSignal, error) {
+	if rating < 1 || rating > 10 {
+		return nil, fmt.Errorf("rating must be between 1 and 10, got %d", rating)
+	}
+
+	objective, err := pm.objectives.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get objective: %w", err)
+	}
+	if objective.Result == nil {
+		return nil, fmt.Errorf("objective %s has no output to rate", objectiveID)
+	}
+
+	return pm.queueSignal(ctx, &PreferenceSignal{
+		ObjectiveID: objectiveID,
+		Kind:        PreferenceSignalRating,
+		UserID:      userID,
+		Rating:      rating,
+		Comment:     strings.TrimSpace(comment),
+		Original:    pm.excerpt(resultSummary(objective.Result)),
+	})
+}
+
+// DetectEdits compares the artifacts of finished objectives with their last
+// snapshots and queues an edit signal for each one whose content changed.
+// Artifacts seen for the first time are snapshotted as produced.
+func (pm *PreferenceMiner) DetectEdits(ctx context.Context, userID string) ([]*PreferenceSignal, error) {
+	snapshots, err := pm.store.Nodes().OfType(artifactSnapshotNodeType).AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query artifact snapshots: %w", err)
+	}
+	known := make(map[string]*storage.Node, len(snapshots))
+	for _, snapshot := range snapshots {
+		objectiveID, _ := snapshot.Data["objective_id"].(string)
+		path, _ := snapshot.Data["path"].(string)
+		known[objectiveID+"\x00"+path] = snapshot
+	}
+
+	var signals []*PreferenceSignal
+	for _, status := range []ObjectiveStatus{ObjectiveStatusCompleted, ObjectiveStatusFailed} {
+		status := status
+		objectives, err := pm.objectives.ListObjectives(ctx, ObjectiveFilter{Status: &status})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list finished objectives: %w", err)
+		}
+
+		for _, objective := range objectives {
+			if objective.Result == nil {
+				continue
+			}
+			for _, path := range toStringSlice(objective.Result.Data[ResultArtifactsKey]) {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					continue // Moved or deleted artifacts say nothing about preferences
+				}
+				sum := sha256.Sum256(content)
+				hash := hex.EncodeToString(sum[:])
+				current := pm.excerpt(string(content))
+
+				snapshot, exists := known[objective.ID+"\x00"+path]
+				if !exists {
+					node := storage.NewNode(artifactSnapshotNodeType, map[string]interface{}{
+						"objective_id": objective.ID,
+						"path":         path,
+						"sha256":       hash,
+						"content":      current,
+					})
+					if err := pm.store.AddNode(ctx, node); err != nil {
+						return nil, fmt.Errorf("failed to snapshot artifact %s: %w", path, err)
+					}
+					continue
+				}
+				if snapshot.Data["sha256"] == hash {
+					continue
+				}
+
+				original, _ := snapshot.Data["content"].(string)
+				signal, err := pm.queueSignal(ctx, &PreferenceSignal{
+					ObjectiveID: objective.ID,
+					Kind:        PreferenceSignalEdit,
+					UserID:      userID,
+					Path:        path,
+					Original:    original,
+					Edited:      current,
+				})
+				if err != nil {
+					return nil, err
+				}
+				signals = append(signals, signal)
+
+				// Later edits are compared with this version
+				if err := pm.store.UpdateNode(ctx, snapshot.ID, map[string]interface{}{
+					"objective_id": objective.ID,
+					"path":         path,
+					"sha256":       hash,
+					"content":      current,
+				}); err != nil {
+					return nil, fmt.Errorf("failed to update artifact snapshot %s: %w", path, err)
+				}
+			}
+		}
+	}
+
+	return signals, nil
+}
+
+// PendingSignals returns the signals not yet mined, oldest first.
+func (pm *PreferenceMiner) PendingSignals(ctx context.Context) ([]*PreferenceSignal, error) {
+	signals, err := pm.listSignals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*PreferenceSignal
+	for _, signal := range signals {
+		if !signal.IsMined() {
+			pending = append(pending, signal)
+		}
+	}
+	return pending, nil
+}
+
+// WeeklySpend returns what mining has cost over the last seven days.
+func (pm *PreferenceMiner) WeeklySpend(ctx context.Context) (float64, error) {
+	signals, err := pm.listSignals(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	since := pm.clock.Now().Add(-preferenceMiningWindow)
+	spent := 0.0
+	for _, signal := range signals {
+		if signal.MinedAt != nil && signal.MinedAt.After(since) {
+			spent += signal.Cost
+		}
+	}
+	return spent, nil
+}
+
+// MinePending asks the model for preferences behind each queued signal, as
+// far as the weekly budget allows. Candidates above the confidence threshold
+// are stored as pending user context, or reinforce a matching entry.
+func (pm *PreferenceMiner) MinePending(ctx context.Context) (*PreferenceMiningReport, error) {
+	report := &PreferenceMiningReport{}
+
+	pending, err := pm.PendingSignals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	spent, err := pm.WeeklySpend(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, signal := range pending {
+		// Middling ratings without a comment carry no signal worth paying for
+		if signal.Kind == PreferenceSignalRating && signal.Comment == "" && signal.Rating > 3 && signal.Rating < 8 {
+			if err := pm.markMined(ctx, signal, 0, nil); err != nil {
+				return nil, err
+			}
+			report.Mined++
+			continue
+		}
+
+		// Signals that need the model wait for the budget; free ones are still settled
+		if spent+pm.config.RequestBudget > pm.config.WeeklyBudget {
+			report.BudgetExhausted = true
+			continue
+		}
+
+		candidates, cost, err := pm.extract(ctx, signal)
+		if err != nil {
+			return report, err
+		}
+		spent += cost
+		report.Spent += cost
+
+		var contextIDs []string
+		for _, candidate := range candidates {
+			if candidate.Confidence < pm.config.ConfidenceThreshold {
+				report.Ignored++
+				continue
+			}
+			learned, reinforced, err := pm.learn(ctx, candidate, signal.UserID)
+			if err != nil {
+				return report, err
+			}
+			switch {
+			case learned == nil:
+				report.Ignored++
+			case reinforced:
+				report.Reinforced++
+				contextIDs = append(contextIDs, learned.ID)
+			default:
+				report.Proposed++
+				contextIDs = append(contextIDs, learned.ID)
+			}
+		}
+
+		if err := pm.markMined(ctx, signal, cost, contextIDs); err != nil {
+			return report, err
+		}
+		report.Mined++
+	}
+
+	return report, nil
+}
+
+// extract sends one signal to the model and parses the candidates it names.
+func (pm *PreferenceMiner) extract(ctx context.Context, signal *PreferenceSignal) ([]PreferenceCandidate, float64, error) {
+	if pm.config.Router == nil {
+		return nil, 0, fmt.Errorf("preference miner has no router")
+	}
+
+	objective, err := pm.objectives.GetObjective(ctx, signal.ObjectiveID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get objective: %w", err)
+	}
+
+	budget := pm.config.RequestBudget
+	result, err := pm.config.Router.Route(ctx, llm.TaskRequest{
+		Prompt:           preferenceMiningPrompt(objective, signal),
+		MaxTokens:        pm.config.MaxTokens,
+		Temperature:      0.0,
+		TaskType:         PreferenceMiningTaskType,
+		QualityRequired:  llm.QualityBasic,
+		BudgetConstraint: &budget,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("LLM routing failed: %w", err)
+	}
+	if result.ExecutionResult == nil {
+		return nil, 0, fmt.Errorf("no result from LLM execution")
+	}
+	completion := result.ExecutionResult
+
+	if pm.config.Budget != nil {
+		if err := pm.config.Budget.RecordUsage(ctx, llm.Transaction{
+			Provider:   result.SelectedModel.Provider,
+			Model:      result.SelectedModel.Model,
+			TaskType:   PreferenceMiningTaskType,
+			TokensUsed: completion.TokensUsed,
+			Cost:       completion.Cost,
+			Success:    true,
+		}); err != nil {
+			return nil, completion.Cost, fmt.Errorf("failed to record mining cost: %w", err)
+		}
+	}
+
+	candidates, err := parsePreferenceCandidates(completion.Text)
+	if err != nil {
+		// A malformed reply still cost money; treat it as naming nothing
+		return nil, completion.Cost, nil
+	}
+	return candidates, completion.Cost, nil
+}
+
+// learn merges a candidate into the most similar known preference, or
+// proposes it as a new pending one. It returns nil when the candidate matches
+// a preference the user dismissed or had forgotten.
+func (pm *PreferenceMiner) learn(ctx context.Context, candidate PreferenceCandidate, userID string) (*UserContext, bool, error) {
+	known, err := pm.contexts.listContexts(ctx, ContextCategoryPreferences, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var match *UserContext
+	best := 0.0
+	for _, existing := range known {
+		if existing.Status == ContextStatusSuperseded {
+			continue // Its canonical entry is among the known ones
+		}
+		if similarity := statementSimilarity(existing.Content, candidate.Statement); similarity > best {
+			match, best = existing, similarity
+		}
+	}
+
+	if match == nil || best < pm.config.MergeSimilarity {
+		proposed, err := pm.contexts.ProposeContext(ctx, ContextCategoryPreferences, candidate.Statement,
+			candidate.Confidence, candidate.Tags, userID)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to propose preference: %w", err)
+		}
+		return proposed, false, nil
+	}
+
+	// What the user dismissed or had forgotten is not learned again
+	if match.Status == ContextStatusDismissed || match.Status == ContextStatusRevoked {
+		return nil, false, nil
+	}
+
+	confidence := math.Min(math.Max(match.Confidence, candidate.Confidence)+pm.config.ReinforceBoost, 1.0)
+	reinforcements := match.Reinforcements + 1
+	reinforced, err := pm.contexts.UpdateContext(ctx, match.ID, UserContextUpdates{
+		Confidence:     &confidence,
+		Reinforcements: &reinforcements,
+		RelevanceTags:  mergeTags(match.RelevanceTags, candidate.Tags),
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reinforce preference: %w", err)
+	}
+	return reinforced, true, nil
+}
+
+// queueSignal stores a new signal for the miner.
+func (pm *PreferenceMiner) queueSignal(ctx context.Context, signal *PreferenceSignal) (*PreferenceSignal, error) {
+	signal.CreatedAt = pm.clock.Now()
+	node := storage.NewNode(preferenceSignalNodeType, signalToData(signal))
+	if err := pm.store.AddNode(ctx, node); err != nil {
+		return nil, fmt.Errorf("failed to queue preference signal: %w", err)
+	}
+	signal.ID = node.ID
+	return signal, nil
+}
+
+// markMined records that a signal was processed and what it cost.
+func (pm *PreferenceMiner) markMined(ctx context.Context, signal *PreferenceSignal, cost float64, contextIDs []string) error {
+	now := pm.clock.Now()
+	signal.MinedAt = &now
+	signal.Cost = cost
+	signal.Contexts = contextIDs
+	if err := pm.store.UpdateNode(ctx, signal.ID, signalToData(signal)); err != nil {
+		return fmt.Errorf("failed to mark signal %s mined: %w", signal.ID, err)
+	}
+	return nil
+}
+
+// listSignals returns every stored signal, oldest first.
+func (pm *PreferenceMiner) listSignals(ctx context.Context) ([]*PreferenceSignal, error) {
+	nodes, err := pm.store.Nodes().OfType(preferenceSignalNodeType).AllContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query preference signals: %w", err)
+	}
+
+	signals := make([]*PreferenceSignal, 0, len(nodes))
+	for _, node := range nodes {
+		signals = append(signals, signalFromNode(node))
+	}
+	sort.Slice(signals, func(i, j int) bool {
+		if !signals[i].CreatedAt.Equal(signals[j].CreatedAt) {
+			return signals[i].CreatedAt.Before(signals[j].CreatedAt)
+		}
+		return signals[i].ID < signals[j].ID
+	})
+	return signals, nil
+}
+
+// excerpt caps text at the configured excerpt length.
+func (pm *PreferenceMiner) excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= pm.config.MaxExcerpt {
+		return text
+	}
+	return text[:pm.config.MaxExcerpt] + " [...]"
+}
+
+// preferenceMiningPrompt builds the compact prompt comparing an output with
+// the user's reaction to it.
+func preferenceMiningPrompt(objective *Objective, signal *PreferenceSignal) string {
+	var b strings.Builder
+	b.WriteString("Infer the user's general preferences from how they reacted to a task's output.\n\n")
+	fmt.Fprintf(&b, "Task: %s\n", llm.RedactSecrets(objective.Title))
+
+	switch signal.Kind {
+	case PreferenceSignalRating:
+		fmt.Fprintf(&b, "Output:\n%s\n\n", llm.RedactSecrets(signal.Original))
+		fmt.Fprintf(&b, "Reaction: rated %d/10", signal.Rating)
+		if signal.Comment != "" {
+			fmt.Fprintf(&b, " with the comment %q", llm.RedactSecrets(signal.Comment))
+		}
+		b.WriteString("\n\n")
+	case PreferenceSignalEdit:
+		fmt.Fprintf(&b, "Output as produced:\n%s\n\n", llm.RedactSecrets(signal.Original))
+		fmt.Fprintf(&b, "Output after the user's edits:\n%s\n\n", llm.RedactSecrets(signal.Edited))
+	}
+
+	b.WriteString("Name at most 3 reusable preferences the reaction shows, such as \"prefers concise bullet summaries over prose\". ")
+	b.WriteString("Use an empty list if it shows nothing general.\n")
+	b.WriteString(`Reply with JSON only: {"preferences": [{"statement": "...", "confidence": 0.0-1.0, "tags": ["..."]}]}`)
+	return b.String()
+}
+
+// parsePreferenceCandidates extracts the JSON object from a mining reply.
+func parsePreferenceCandidates(text string) ([]PreferenceCandidate, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("mining reply contains no JSON object")
+	}
+
+	var raw struct {
+		Preferences []PreferenceCandidate `json:"preferences"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse mining reply: %w", err)
+	}
+
+	var candidates []PreferenceCandidate
+	for _, candidate := range raw.Preferences {
+		candidate.Statement = strings.TrimSpace(candidate.Statement)
+		if candidate.Statement == "" {
+			continue
+		}
+		candidate.Confidence = clampFloat(candidate.Confidence, 0, 1)
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// statementSimilarity is the word overlap of two statements (Jaccard index
+// over words of three letters or more), ignoring case.
+func statementSimilarity(a, b string) float64 {
+	wordsA, wordsB := statementWords(a), statementWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+// statementWords returns the set of significant words in a statement.
+func statementWords(statement string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(statement), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		if len(word) >= 3 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// mergeTags adds new tags to existing ones, without duplicates.
+func mergeTags(existing, added []string) []string {
+	merged := append([]string(nil), existing...)
+	for _, tag := range added {
+		if !containsString(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// resultSummary renders an objective result as the output a rating judged.
+func resultSummary(result *ObjectiveResult) string {
+	summary := result.Message
+	if len(result.Data) > 0 {
+		if data, err := json.Marshal(result.Data); err == nil {
+			summary += "\n" + string(data)
+		}
+	}
+	return summary
+}
+
+// signalToData converts a signal to storage node data.
+func signalToData(signal *PreferenceSignal) map[string]interface{} {
+	data := map[string]interface{}{
+		"objective_id": signal.ObjectiveID,
+		"kind":         string(signal.Kind),
+		"user_id":      signal.UserID,
+		"rating":       signal.Rating,
+		"comment":      signal.Comment,
+		"path":         signal.Path,
+		"original":     signal.Original,
+		"edited":       signal.Edited,
+		"created_at":   signal.CreatedAt.Format(time.RFC3339Nano),
+		"cost":         signal.Cost,
+		"contexts":     signal.Contexts,
+	}
+	if signal.MinedAt != nil {
+		data["mined_at"] = signal.MinedAt.Format(time.RFC3339Nano)
+	}
+	return data
+}
+
+// signalFromNode converts a storage node to a signal.
+func signalFromNode(node *storage.Node) *PreferenceSignal {
+	signal := &PreferenceSignal{ID: node.ID}
+	signal.ObjectiveID, _ = node.Data["objective_id"].(string)
+	kind, _ := node.Data["kind"].(string)
+	signal.Kind = PreferenceSignalKind(kind)
+	signal.UserID, _ = node.Data["user_id"].(string)
+	signal.Rating = int(getFloat64(node.Data, "rating"))
+	signal.Comment, _ = node.Data["comment"].(string)
+	signal.Path, _ = node.Data["path"].(string)
+	signal.Original, _ = node.Data["original"].(string)
+	signal.Edited, _ = node.Data["edited"].(string)
+	signal.Cost = getFloat64(node.Data, "cost")
+	signal.Contexts = toStringSlice(node.Data["contexts"])
+	if createdAt, ok := node.Data["created_at"].(string); ok {
+		signal.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	}
+	if minedAt, ok := node.Data["mined_at"].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, minedAt); err == nil {
+			signal.MinedAt = &parsed
+		}
+	}
+	return signal
+}
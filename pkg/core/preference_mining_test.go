@@ -0,0 +1,313 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// miningFixture wires a preference miner to a scripted model and a test store.
+type miningFixture struct {
+	service    *scriptedClassifierService
+	clock      *utils.FakeClock
+	contexts   *UserContextManager
+	objectives *ObjectiveManager
+	miner      *PreferenceMiner
+	objective  *Objective
+}
+
+func newMiningFixture(t *testing.T, config PreferenceMiningConfig) *miningFixture {
+	t.Helper()
+	ctx := context.Background()
+	store := setupTestStore(t)
+
+	f := &miningFixture{
+		service:    &scriptedClassifierService{},
+		clock:      utils.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)),
+		contexts:   NewUserContextManager(store),
+		objectives: NewObjectiveManager(store),
+	}
+	config.Router = llm.NewRouter(f.service)
+	config.Clock = f.clock
+	f.miner = NewPreferenceMiner(store, f.contexts, config)
+
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Keep stakeholders informed", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Status report", "",
+		[]ApproachStep{{Description: "Summarize the week"}}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	f.objective = f.finishedObjective(t, goal.ID, method.ID, nil)
+	return f
+}
+
+// finishedObjective creates and completes an objective with the given result data.
+func (f *miningFixture) finishedObjective(t *testing.T, goalID, methodID string, data map[string]interface{}) *Objective {
+	t.Helper()
+	ctx := context.Background()
+
+	objective, err := f.objectives.CreateObjective(ctx, goalID, methodID, "Write the weekly report", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	if _, err := f.objectives.StartObjective(ctx, objective.ID); err != nil {
+		t.Fatalf("Failed to start objective: %v", err)
+	}
+	completed, err := f.objectives.CompleteObjective(ctx, objective.ID, ObjectiveResult{
+		Success:     true,
+		Message:     "Report written as four paragraphs of prose",
+		Data:        data,
+		CompletedAt: f.clock.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to complete objective: %v", err)
+	}
+	return completed
+}
+
+const conciseReply = `{"preferences": [{"statement": "Prefers concise bullet point summaries over prose", "confidence": 0.8, "tags": ["reports", "bullet"]}]}`
+
+func TestPreferenceMinerExtractsFromRating(t *testing.T) {
+	ctx := context.Background()
+	f := newMiningFixture(t, DefaultPreferenceMiningConfig())
+
+	if _, err := f.miner.RecordRating(ctx, f.objective.ID, 0, "", "user"); err == nil {
+		t.Error("Expected a rating outside 1-10 to be rejected")
+	}
+	if _, err := f.miner.RecordRating(ctx, f.objective.ID, 3, "Too wordy, use bullets", "user"); err != nil {
+		t.Fatalf("Failed to record rating: %v", err)
+	}
+
+	f.service.script(`Sure: {"preferences": [
+		{"statement": "Prefers concise bullet point summaries over prose", "confidence": 0.8, "tags": ["reports"]},
+		{"statement": "Likes reports on Mondays", "confidence": 0.3}
+	]}`)
+	report, err := f.miner.MinePending(ctx)
+	if err != nil {
+		t.Fatalf("Mining failed: %v", err)
+	}
+	if report.Mined != 1 || report.Proposed != 1 || report.Ignored != 1 {
+		t.Errorf("Expected 1 mined, 1 proposed, 1 ignored, got %+v", report)
+	}
+	if report.Spent != 0.001 {
+		t.Errorf("Expected the request cost to be reported, got %.4f", report.Spent)
+	}
+
+	prompt := f.service.prompts[0]
+	for _, want := range []string{"Write the weekly report", "rated 3/10", "Too wordy, use bullets", "four paragraphs"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected the prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+
+	pending, err := f.contexts.GetPendingContext(ctx, "user")
+	if err != nil {
+		t.Fatalf("Failed to get pending context: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Category != ContextCategoryPreferences {
+		t.Fatalf("Expected one pending preference, got %d", len(pending))
+	}
+	if pending[0].Confidence < 0.79 || pending[0].Confidence > 0.8 {
+		t.Errorf("Expected the model's 0.8 confidence, got %.2f", pending[0].Confidence)
+	}
+
+	// A mined signal is not mined again
+	if report, err := f.miner.MinePending(ctx); err != nil || report.Mined != 0 || f.service.calls() != 1 {
+		t.Errorf("Expected nothing left to mine, got %+v, %v", report, err)
+	}
+}
+
+func TestPreferenceMinerConfirmationGate(t *testing.T) {
+	ctx := context.Background()
+	f := newMiningFixture(t, DefaultPreferenceMiningConfig())
+
+	if _, err := f.miner.RecordRating(ctx, f.objective.ID, 2, "Use bullet points", "user"); err != nil {
+		t.Fatalf("Failed to record rating: %v", err)
+	}
+	f.service.script(conciseReply)
+	if _, err := f.miner.MinePending(ctx); err != nil {
+		t.Fatalf("Mining failed: %v", err)
+	}
+
+	// Unconfirmed candidates never reach retrieval
+	relevant, err := f.contexts.GetRelevantContext(ctx, "write a concise bullet summary report", "user", 10)
+	if err != nil {
+		t.Fatalf("Failed to get relevant context: %v", err)
+	}
+	if len(relevant) != 0 {
+		t.Errorf("Expected pending preferences to be excluded from retrieval, got %d", len(relevant))
+	}
+	byCategory, err := f.contexts.GetContextByCategory(ctx, ContextCategoryPreferences, "user")
+	if err != nil || len(byCategory) != 0 {
+		t.Errorf("Expected no active preferences before confirmation, got %d, %v", len(byCategory), err)
+	}
+
+	pending, err := f.contexts.GetPendingContext(ctx, "user")
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("Expected one pending preference, got %d, %v", len(pending), err)
+	}
+	if _, err := f.contexts.DismissContext(ctx, pending[0].ID); err != nil {
+		t.Fatalf("Failed to dismiss: %v", err)
+	}
+	if _, err := f.contexts.ConfirmContext(ctx, pending[0].ID); err == nil {
+		t.Error("Expected a dismissed preference not to be confirmable")
+	}
+
+	// A dismissed preference is not proposed again
+	if _, err := f.miner.RecordRating(ctx, f.objective.ID, 2, "Bullets please", "user"); err != nil {
+		t.Fatalf("Failed to record rating: %v", err)
+	}
+	f.service.script(conciseReply)
+	report, err := f.miner.MinePending(ctx)
+	if err != nil {
+		t.Fatalf("Mining failed: %v", err)
+	}
+	if report.Proposed != 0 || report.Ignored != 1 {
+		t.Errorf("Expected the dismissed preference to be ignored, got %+v", report)
+	}
+
+	// A confirmed one is used
+	proposed, err := f.contexts.ProposeContext(ctx, ContextCategoryPreferences, "Prefers reports sent before noon", 0.65, nil, "user")
+	if err != nil {
+		t.Fatalf("Failed to propose: %v", err)
+	}
+	confirmed, err := f.contexts.ConfirmContext(ctx, proposed.ID)
+	if err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+	if confirmed.Status != ContextStatusActive || confirmed.Confidence < 0.8 {
+		t.Errorf("Expected an active preference with feedback confidence, got %s at %.2f", confirmed.Status, confirmed.Confidence)
+	}
+	relevant, err = f.contexts.GetRelevantContext(ctx, "send the reports", "user", 10)
+	if err != nil || len(relevant) != 1 || relevant[0].ID != proposed.ID {
+		t.Errorf("Expected the confirmed preference in retrieval, got %d, %v", len(relevant), err)
+	}
+}
+
+func TestPreferenceMinerMergesDuplicates(t *testing.T) {
+	ctx := context.Background()
+	f := newMiningFixture(t, DefaultPreferenceMiningConfig())
+
+	for _, comment := range []string{"Use bullets", "Bullets, not paragraphs"} {
+		if _, err := f.miner.RecordRating(ctx, f.objective.ID, 3, comment, "user"); err != nil {
+			t.Fatalf("Failed to record rating: %v", err)
+		}
+	}
+	f.service.script(conciseReply,
+		`{"preferences": [{"statement": "prefers concise bullet point summaries, not prose", "confidence": 0.7, "tags": ["summaries"]}]}`)
+
+	report, err := f.miner.MinePending(ctx)
+	if err != nil {
+		t.Fatalf("Mining failed: %v", err)
+	}
+	if report.Proposed != 1 || report.Reinforced != 1 {
+		t.Errorf("Expected 1 proposed and 1 reinforced, got %+v", report)
+	}
+
+	pending, err := f.contexts.GetPendingContext(ctx, "user")
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("Expected the near-duplicate to merge into one preference, got %d, %v", len(pending), err)
+	}
+	merged := pending[0]
+	if merged.Reinforcements != 1 {
+		t.Errorf("Expected 1 reinforcement, got %d", merged.Reinforcements)
+	}
+	if merged.Confidence < 0.89 {
+		t.Errorf("Expected reinforcement to raise confidence to 0.9, got %.2f", merged.Confidence)
+	}
+	if !containsString(merged.RelevanceTags, "summaries") || !containsString(merged.RelevanceTags, "reports") {
+		t.Errorf("Expected the tags to be merged, got %v", merged.RelevanceTags)
+	}
+}
+
+func TestPreferenceMinerWeeklyCap(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultPreferenceMiningConfig()
+	config.WeeklyBudget = 0.0025
+	config.RequestBudget = 0.001
+	f := newMiningFixture(t, config)
+
+	for i := 0; i < 3; i++ {
+		if _, err := f.miner.RecordRating(ctx, f.objective.ID, 9, "Exactly right", "user"); err != nil {
+			t.Fatalf("Failed to record rating: %v", err)
+		}
+	}
+	// A middling rating without a comment is settled without the model
+	if _, err := f.miner.RecordRating(ctx, f.objective.ID, 6, "", "user"); err != nil {
+		t.Fatalf("Failed to record rating: %v", err)
+	}
+	f.service.script(`{"preferences": []}`, `{"preferences": []}`, `{"preferences": []}`)
+
+	report, err := f.miner.MinePending(ctx)
+	if err != nil {
+		t.Fatalf("Mining failed: %v", err)
+	}
+	if report.Mined != 3 || !report.BudgetExhausted || f.service.calls() != 2 {
+		t.Errorf("Expected 2 paid and 1 free signal mined before the cap, got %+v after %d calls", report, f.service.calls())
+	}
+	if spent, err := f.miner.WeeklySpend(ctx); err != nil || spent != 0.002 {
+		t.Errorf("Expected 0.002 spent this week, got %.4f, %v", spent, err)
+	}
+
+	// The last paid signal waits for the window to move on
+	f.clock.Advance(8 * 24 * time.Hour)
+	report, err = f.miner.MinePending(ctx)
+	if err != nil {
+		t.Fatalf("Mining failed: %v", err)
+	}
+	if report.Mined != 1 || report.BudgetExhausted || f.service.calls() != 3 {
+		t.Errorf("Expected the remaining signal mined next week, got %+v after %d calls", report, f.service.calls())
+	}
+}
+
+func TestPreferenceMinerDetectsEdits(t *testing.T) {
+	ctx := context.Background()
+	f := newMiningFixture(t, DefaultPreferenceMiningConfig())
+
+	path := filepath.Join(t.TempDir(), "report.md")
+	if err := os.WriteFile(path, []byte("The week went well. We shipped the release."), 0644); err != nil {
+		t.Fatalf("Failed to write artifact: %v", err)
+	}
+	f.finishedObjective(t, f.objective.GoalID, f.objective.MethodID, map[string]interface{}{
+		ResultArtifactsKey: []string{path},
+	})
+
+	// The first pass records the artifact as produced
+	signals, err := f.miner.DetectEdits(ctx, "user")
+	if err != nil || len(signals) != 0 {
+		t.Fatalf("Expected no edits on first sight, got %d, %v", len(signals), err)
+	}
+
+	if err := os.WriteFile(path, []byte("- Shipped the release"), 0644); err != nil {
+		t.Fatalf("Failed to edit artifact: %v", err)
+	}
+	signals, err = f.miner.DetectEdits(ctx, "user")
+	if err != nil || len(signals) != 1 {
+		t.Fatalf("Expected one edit signal, got %d, %v", len(signals), err)
+	}
+	if signals[0].Original != "The week went well. We shipped the release." || signals[0].Edited != "- Shipped the release" {
+		t.Errorf("Expected the edit to carry both versions, got %+v", signals[0])
+	}
+
+	// An unchanged artifact is not reported again
+	if signals, err := f.miner.DetectEdits(ctx, "user"); err != nil || len(signals) != 0 {
+		t.Errorf("Expected no new edits, got %d, %v", len(signals), err)
+	}
+
+	f.service.script(conciseReply)
+	if _, err := f.miner.MinePending(ctx); err != nil {
+		t.Fatalf("Mining failed: %v", err)
+	}
+	if !strings.Contains(f.service.prompts[0], "after the user's edits") {
+		t.Errorf("Expected an edit prompt, got:\n%s", f.service.prompts[0])
+	}
+}
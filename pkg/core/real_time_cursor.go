@@ -3,15 +3,25 @@ package core
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/metrics"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/retry"
 )
 
 // TaskExecutor defines the interface for executing individual tasks.
 // This abstracts the LLM and MCP tool usage to allow for testing and flexibility.
 type TaskExecutor interface {
-	// ExecuteTask runs a single task using available tools via MCP
+	// ExecuteTask runs a single task using available tools via MCP. The
+	// full context carries the goal and objective IDs of the execution under
+	// llm.MetadataGoalID and llm.MetadataObjectiveID, and the outputs of the
+	// task's completed prerequisites under DependencyOutputsKey.
 	ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error)
 
 	// GetAvailableTools returns the list of MCP tools available for execution
@@ -21,6 +31,10 @@ type TaskExecutor interface {
 	EstimateTokenUsage(ctx context.Context, task *ExecutionTask) (int, error)
 }
 
+// DependencyOutputsKey is the full context entry that maps the IDs of a
+// task's completed prerequisite tasks to their outputs.
+const DependencyOutputsKey = "dependency_outputs"
+
 // ContextLoader defines the interface for loading full context when needed.
 // Follows the minimal context design principle.
 type ContextLoader interface {
@@ -39,13 +53,17 @@ type TaskResult struct {
 	// TaskID identifies which task this result belongs to
 	TaskID string
 
+	// MethodStepIndex is the method step the task implemented (-1 if not method-based)
+	MethodStepIndex int
+
 	// Status indicates whether the task completed successfully
 	Status TaskStatus
 
 	// Output contains the task's output data (may be large, stored by reference)
 	Output interface{}
 
-	// OutputRef is a reference to where the output is stored
+	// OutputRef is a reference to where the output is stored; outputs over
+	// the RTC's blob threshold are stored as "blob://<hash>"
 	OutputRef string
 
 	// TokensUsed tracks actual LLM token consumption
@@ -60,11 +78,18 @@ type TaskResult struct {
 	// ToolsUsed lists the MCP tools that were invoked
 	ToolsUsed []string
 
+	// RoutingID names the LLM routing that produced the output, for rating
+	// it with the router's SubmitFeedback ("" when none did)
+	RoutingID string
+
 	// Confidence indicates how confident the system is in this result (0.0-1.0)
 	Confidence float64
 
 	// CompletedAt is when the task finished execution
 	CompletedAt time.Time
+
+	// outputJSON is set when the output stored as a blob was encoded as JSON
+	outputJSON bool
 }
 
 // TaskStatus represents the execution status of a task.
@@ -92,12 +117,18 @@ const (
 
 // ExecutionResult represents the overall result of executing an entire plan.
 type ExecutionResult struct {
+	// ID is the storage node the result was last recorded in (empty until stored)
+	ID string
+
 	// PlanID identifies the execution plan that was run
 	PlanID string
 
 	// ObjectiveID identifies the objective this execution served
 	ObjectiveID string
 
+	// MethodID identifies the method the plan implemented (empty if custom)
+	MethodID string
+
 	// Status indicates the overall outcome of the execution
 	Status ExecutionStatus
 
@@ -127,6 +158,46 @@ type ExecutionResult struct {
 
 	// MethodRefinementData contains feedback for improving the method
 	MethodRefinementData map[string]interface{}
+
+	// Plan is the plan that was executed, kept so a re-run can reuse it
+	Plan *ExecutionPlan
+
+	// ContextFingerprint records the objective context the plan was made for
+	ContextFingerprint *ContextFingerprint
+
+	// Replan records how the plan was derived from the previous execution's plan
+	Replan *ReplanDecision
+
+	// Comparison identifies the comparison branch the execution ran in (nil otherwise)
+	Comparison *ComparisonBranch
+
+	// CheckpointTaskID is the task a checkpointed execution stopped at
+	CheckpointTaskID string
+
+	// Resumed is set for executions that continued an interrupted one, whose
+	// run took OriginalDuration before this run took ResumedDuration.
+	// TotalDuration is the sum of both.
+	Resumed          bool
+	OriginalDuration time.Duration
+	ResumedDuration  time.Duration
+
+	// checkpointID is the plan checkpoint node the execution saves its progress in
+	checkpointID string
+}
+
+// elapsed returns how long the execution has run, including the run it resumed.
+func (r *ExecutionResult) elapsed() time.Duration {
+	return r.OriginalDuration + time.Since(r.StartTime)
+}
+
+// finish records the end of the execution and its durations.
+func (r *ExecutionResult) finish() {
+	r.EndTime = time.Now()
+	run := r.EndTime.Sub(r.StartTime)
+	r.TotalDuration = r.OriginalDuration + run
+	if r.Resumed {
+		r.ResumedDuration = run
+	}
 }
 
 // ExecutionStatus represents the overall execution status of a plan.
@@ -150,6 +221,10 @@ const (
 
 	// ExecutionStatusCancelled indicates execution was cancelled by user
 	ExecutionStatusCancelled ExecutionStatus = "cancelled"
+
+	// ExecutionStatusCheckpointed indicates execution stopped when its time
+	// box ran out; it can be resumed from the tasks it completed
+	ExecutionStatusCheckpointed ExecutionStatus = "checkpointed"
 )
 
 // RetryConfig defines configuration for task retry behavior.
@@ -168,6 +243,44 @@ type RetryConfig struct {
 
 	// RetriableErrors lists error types that should trigger retries
 	RetriableErrors []string
+
+	// Jitter randomizes each wait between zero and the backed-off delay.
+	// It is off by default so task retries keep a predictable schedule;
+	// the LLM service already jitters its own calls to providers.
+	Jitter bool
+
+	// OnRetry is called before each retry wait, for instrumentation
+	OnRetry func(retry.Event)
+}
+
+// Policy returns the shared retry policy these settings describe. A failed
+// task is retried once at once; later retries back off from BaseDelay.
+func (c *RetryConfig) Policy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts:         c.MaxRetries + 1,
+		BaseDelay:           c.BaseDelay,
+		MaxDelay:            c.MaxDelay,
+		Multiplier:          c.BackoffMultiplier,
+		ImmediateFirstRetry: true,
+		Jitter:              c.Jitter,
+		Retryable:           c.retriable,
+		OnRetry:             c.OnRetry,
+	}
+}
+
+// retriable reports whether an error matches one of the RetriableErrors.
+// Context cancellation and deadlines are never retried.
+func (c *RetryConfig) retriable(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	errorMsg := err.Error()
+	for _, retriableError := range c.RetriableErrors {
+		if strings.Contains(errorMsg, retriableError) {
+			return true
+		}
+	}
+	return false
 }
 
 // DefaultRetryConfig provides sensible defaults for retry configuration.
@@ -206,23 +319,74 @@ type RealTimeCursor struct {
 
 	// maxConcurrentTasks limits parallel task execution (future enhancement)
 	maxConcurrentTasks int
+
+	// costPerToken prices estimates and actual use in estimate records (0: unpriced)
+	costPerToken float64
+
+	// outputBlobThreshold is the size above which task outputs are stored as blobs
+	outputBlobThreshold int
+
+	// planValidator checks plans against tools, task types, methods and
+	// budget before they run (nil: structural checks only)
+	planValidator *PlanValidator
+
+	// progressMu guards the progress subscribers and the trackers of the
+	// executions running, by objective
+	progressMu       sync.RWMutex
+	subscribers      map[int]ProgressHandler
+	nextSubscriberID int
+	running          map[string]*progressTracker
 }
 
 // NewRealTimeCursor creates a new RTC instance with the given dependencies.
 func NewRealTimeCursor(store *storage.Store, executor TaskExecutor, contextLoader ContextLoader) *RealTimeCursor {
 	return &RealTimeCursor{
-		store:              store,
-		executor:           executor,
-		contextLoader:      contextLoader,
-		methodManager:      NewMethodManager(store),
-		retryConfig:        DefaultRetryConfig(),
-		maxConcurrentTasks: 1, // Sequential execution for now
+		store:               store,
+		executor:            executor,
+		contextLoader:       contextLoader,
+		methodManager:       NewMethodManager(store),
+		retryConfig:         DefaultRetryConfig(),
+		maxConcurrentTasks:  1, // Sequential execution for now
+		outputBlobThreshold: DefaultOutputBlobThreshold,
+		subscribers:         make(map[int]ProgressHandler),
+		running:             make(map[string]*progressTracker),
 	}
 }
 
 // ExecutePlan runs the given execution plan and returns the overall result.
 // This is the main entry point for RTC execution capabilities.
+//
+// When the objective's time box runs out, the task in flight gets a grace
+// window to finish, then the execution stops with a checkpointed result and
+// an error matching ErrTimeBoxExceeded; ResumeExecution continues it.
 func (rtc *RealTimeCursor) ExecutePlan(ctx context.Context, plan *ExecutionPlan) (*ExecutionResult, error) {
+	return rtc.executePlan(ctx, plan, nil)
+}
+
+// ResumeExecution continues a checkpointed execution: its plan runs again,
+// skipping the tasks the checkpoint completed.
+func (rtc *RealTimeCursor) ResumeExecution(ctx context.Context, checkpointID string) (*ExecutionResult, error) {
+	node, err := rtc.store.GetNode(ctx, checkpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint %s: %w", checkpointID, err)
+	}
+	checkpoint, err := executionResultFromNode(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint %s: %w", checkpointID, err)
+	}
+	if checkpoint.Status != ExecutionStatusCheckpointed || checkpoint.Plan == nil {
+		return nil, errs.Newf(errs.Conflict, "execution %s is not a checkpoint that can be resumed", checkpointID).With("status", string(checkpoint.Status))
+	}
+
+	plan := checkpoint.Plan
+	plan.ContextFingerprint = checkpoint.ContextFingerprint
+	plan.Replan = checkpoint.Replan
+	plan.Comparison = checkpoint.Comparison
+	return rtc.executePlan(ctx, plan, checkpoint)
+}
+
+// executePlan runs plan, continuing from checkpoint when it is not nil.
+func (rtc *RealTimeCursor) executePlan(ctx context.Context, plan *ExecutionPlan, checkpoint *ExecutionResult) (*ExecutionResult, error) {
 	startTime := time.Now()
 
 	// Validate the plan before creating result to avoid nil pointer access
@@ -240,17 +404,77 @@ func (rtc *RealTimeCursor) ExecutePlan(ctx context.Context, plan *ExecutionPlan)
 		if plan != nil {
 			result.PlanID = plan.ID
 			result.ObjectiveID = plan.ObjectiveID
+			result.MethodID = plan.MethodID
 		}
 		return result, fmt.Errorf("plan validation failed: %w", err)
 	}
+	// A resumed plan was validated when it first ran
+	if rtc.planValidator != nil && checkpoint == nil {
+		plan.Validation = rtc.planValidator.Validate(ctx, plan)
+		if err := plan.Validation.Err(); err != nil {
+			result := &ExecutionResult{
+				PlanID:               plan.ID,
+				ObjectiveID:          plan.ObjectiveID,
+				MethodID:             plan.MethodID,
+				Status:               ExecutionStatusFailed,
+				ErrorMessage:         err.Error(),
+				StartTime:            startTime,
+				EndTime:              time.Now(),
+				TotalDuration:        time.Since(startTime),
+				TaskResults:          make(map[string]*TaskResult),
+				MethodRefinementData: make(map[string]interface{}),
+				Plan:                 plan,
+				ContextFingerprint:   plan.ContextFingerprint,
+				Replan:               plan.Replan,
+				Comparison:           plan.Comparison,
+			}
+			// Keep the rejected plan with its report for review
+			if storeErr := rtc.storeExecutionResult(ctx, result); storeErr != nil {
+				fmt.Printf("Warning: failed to store rejected plan: %v\n", storeErr)
+			}
+			return result, err
+		}
+	}
 
 	result := &ExecutionResult{
 		PlanID:               plan.ID,
 		ObjectiveID:          plan.ObjectiveID,
+		MethodID:             plan.MethodID,
 		Status:               ExecutionStatusRunning,
 		TaskResults:          make(map[string]*TaskResult),
 		StartTime:            startTime,
 		MethodRefinementData: make(map[string]interface{}),
+		Plan:                 plan,
+		ContextFingerprint:   plan.ContextFingerprint,
+		Replan:               plan.Replan,
+		Comparison:           plan.Comparison,
+	}
+
+	// A resumed execution keeps the tasks its checkpoint completed
+	if checkpoint != nil {
+		result.Resumed = true
+		result.OriginalDuration = checkpoint.TotalDuration
+		result.checkpointID = checkpoint.checkpointID
+		for taskID, taskResult := range checkpoint.TaskResults {
+			if taskResult.Status == TaskStatusCompleted {
+				result.TaskResults[taskID] = taskResult
+				result.SuccessfulTasks++
+				result.TotalTokensUsed += taskResult.TokensUsed
+			}
+		}
+	}
+
+	// Correlate everything the execution logs or records with its plan
+	ctx = utils.WithLogContext(ctx, utils.LogContext{
+		GoalID:      rtc.goalIDFor(ctx, plan.ObjectiveID),
+		ObjectiveID: plan.ObjectiveID,
+		PlanID:      plan.ID,
+		MethodID:    plan.MethodID,
+	})
+
+	// Let the executor see which comparison branch it works in
+	if plan.Comparison != nil {
+		ctx = WithComparisonBranch(ctx, plan.Comparison)
 	}
 
 	// Store the execution result for tracking
@@ -269,19 +493,44 @@ func (rtc *RealTimeCursor) ExecutePlan(ctx context.Context, plan *ExecutionPlan)
 		return result, fmt.Errorf("dependency resolution failed: %w", err)
 	}
 
+	// Let subscribers follow the execution until it stops
+	ctx, progress := rtc.trackProgress(ctx, result, taskOrder)
+	defer progress.finish()
+
 	// Execute each task in order
+	box := timeBoxFrom(ctx)
 	for _, task := range taskOrder {
+		if done, ok := result.TaskResults[task.ID]; ok && done.Status == TaskStatusCompleted {
+			continue
+		}
+
 		select {
 		case <-ctx.Done():
-			result.Status = ExecutionStatusCancelled
-			result.ErrorMessage = "Execution cancelled"
-			result.EndTime = time.Now()
-			result.TotalDuration = time.Since(startTime)
-			return result, ctx.Err()
+			return rtc.cancel(ctx, result, ctx.Err())
 		default:
-			// Execute the task
-			taskResult, err := rtc.executeTaskWithRetries(ctx, task)
+			// Stop at a checkpoint once the time box has run out
+			if box.exceeded() {
+				return rtc.checkpoint(ctx, result, box, task.ID)
+			}
+
+			// Hold here while the objective is paused
+			if err := rtc.waitWhilePaused(ctx, plan.ObjectiveID, progress); err != nil {
+				return rtc.cancel(ctx, result, err)
+			}
+
+			// Execute the task, cancelling it if it overruns the grace window
+			progress.taskStarted(task)
+			taskCtx, release := box.enforce(ctx)
+			taskResult, err := rtc.executeTaskWithRetries(taskCtx, task, rtc.dependencyOutputs(ctx, plan, task, result))
+			cutOff := err != nil && ctx.Err() == nil && taskCtx.Err() != nil
+			release()
+
+			// A task cut off by the time box is left for the resumed run
+			if cutOff {
+				return rtc.checkpoint(ctx, result, box, task.ID)
+			}
 			result.TaskResults[task.ID] = taskResult
+			progress.taskFinished(task, taskResult)
 
 			// Update counters
 			if taskResult.Status == TaskStatusCompleted {
@@ -293,30 +542,32 @@ func (rtc *RealTimeCursor) ExecutePlan(ctx context.Context, plan *ExecutionPlan)
 			// Update token usage
 			result.TotalTokensUsed += taskResult.TokensUsed
 
+			// Save progress so an interrupted execution can be resumed
+			if taskResult.Status == TaskStatusCompleted {
+				if err := rtc.saveCheckpoint(ctx, plan, result); err != nil {
+					fmt.Printf("Warning: failed to save checkpoint after task %s: %v\n", task.ID, err)
+				}
+			}
+
 			// Handle task failure
 			if err != nil {
 				// Check if this is a cancellation error
 				if err == context.Canceled || err == context.DeadlineExceeded {
-					result.Status = ExecutionStatusCancelled
-					result.ErrorMessage = "Execution cancelled"
-					result.EndTime = time.Now()
-					result.TotalDuration = time.Since(startTime)
-					rtc.storeExecutionResult(ctx, result)
-					return result, err
+					return rtc.cancel(ctx, result, err)
 				}
 
 				// Check if this is a critical task that should fail the entire plan
 				if rtc.isCriticalTask(task, plan) {
 					result.Status = ExecutionStatusFailed
 					result.ErrorMessage = fmt.Sprintf("Critical task %s failed: %v", task.ID, err)
-					result.EndTime = time.Now()
-					result.TotalDuration = time.Since(startTime)
+					result.finish()
 
 					// Still record refinement data even on failure
 					rtc.collectRefinementData(result, plan)
 
 					// Update stored result
 					rtc.storeExecutionResult(ctx, result)
+					rtc.finishCheckpoints(ctx, plan)
 					return result, fmt.Errorf("execution failed on critical task: %w", err)
 				}
 
@@ -337,75 +588,159 @@ func (rtc *RealTimeCursor) ExecutePlan(ctx context.Context, plan *ExecutionPlan)
 	}
 
 	// Finalize result
-	result.EndTime = time.Now()
-	result.TotalDuration = time.Since(startTime)
+	result.finish()
 
 	// Collect method refinement data
 	rtc.collectRefinementData(result, plan)
 
-	// Update method metrics based on execution outcome
-	if err := rtc.updateMethodMetrics(ctx, plan, result); err != nil {
-		fmt.Printf("Warning: failed to update method metrics: %v\n", err)
+	// Update method metrics based on execution outcome. Comparison runs are
+	// recorded once both branches are rated.
+	if plan.Comparison == nil {
+		if err := rtc.updateMethodMetrics(ctx, plan, result); err != nil {
+			fmt.Printf("Warning: failed to update method metrics: %v\n", err)
+		}
 	}
 
 	// Store final result
 	if err := rtc.storeExecutionResult(ctx, result); err != nil {
 		fmt.Printf("Warning: failed to store final execution result: %v\n", err)
 	}
+	rtc.finishCheckpoints(ctx, plan)
+
+	// Record the estimate next to actual use, to calibrate the method's later estimates
+	if err := rtc.recordEstimate(ctx, plan, result); err != nil {
+		fmt.Printf("Warning: failed to record estimate accuracy: %v\n", err)
+	}
 
 	return result, nil
 }
 
-// executeTaskWithRetries executes a single task with retry logic.
-func (rtc *RealTimeCursor) executeTaskWithRetries(ctx context.Context, task *ExecutionTask) (*TaskResult, error) {
-	result := &TaskResult{
-		TaskID:      task.ID,
-		Status:      TaskStatusPending,
-		CompletedAt: time.Time{},
+// cancel stops a cancelled execution. No later task runs, but the outcome is
+// still stored, since storage refuses writes for the cancelled ctx and the
+// execution would otherwise stay running in storage.
+func (rtc *RealTimeCursor) cancel(ctx context.Context, result *ExecutionResult, err error) (*ExecutionResult, error) {
+	result.Status = ExecutionStatusCancelled
+	result.ErrorMessage = "Execution cancelled"
+	result.finish()
+	if storeErr := rtc.storeExecutionResult(context.WithoutCancel(ctx), result); storeErr != nil {
+		fmt.Printf("Warning: failed to store cancelled execution result: %v\n", storeErr)
 	}
+	return result, err
+}
 
-	var lastError error
-	for attempt := 0; attempt <= rtc.retryConfig.MaxRetries; attempt++ {
-		// Check for context cancellation before each attempt
-		select {
-		case <-ctx.Done():
-			result.Status = TaskStatusFailed
-			result.ErrorMessage = "Task cancelled"
-			result.CompletedAt = time.Now()
-			return result, ctx.Err()
-		default:
+// checkpoint stops an execution whose time box ran out at taskID. The tasks
+// completed so far are stored with the plan, so a resumed run can skip them.
+func (rtc *RealTimeCursor) checkpoint(ctx context.Context, result *ExecutionResult, box *timeBoxRun, taskID string) (*ExecutionResult, error) {
+	elapsed := box.elapsed()
+	result.Status = ExecutionStatusCheckpointed
+	result.CheckpointTaskID = taskID
+	result.ErrorMessage = fmt.Sprintf("Time box of %s exceeded at task %s", box.limit, taskID)
+	result.finish()
+
+	if err := rtc.storeExecutionResult(ctx, result); err != nil {
+		return result, fmt.Errorf("failed to store checkpoint: %w", err)
+	}
+	return result, fmt.Errorf("%w: %s of %s used, stopped at task %s", ErrTimeBoxExceeded, elapsed.Round(time.Second), box.limit, taskID)
+}
+
+// recordEstimate stores the plan's estimate and the execution's actual use
+// for the plan's method. Failed and interrupted executions are not recorded,
+// since what they used says little about the estimate.
+func (rtc *RealTimeCursor) recordEstimate(ctx context.Context, plan *ExecutionPlan, result *ExecutionResult) error {
+	rawTokens := plan.RawEstimatedTokens()
+	if plan.MethodID == "" || rawTokens == 0 || result.TotalTokensUsed == 0 || result.Status == ExecutionStatusFailed {
+		return nil
+	}
+
+	factor := 1.0
+	if plan.Calibration != nil && plan.Calibration.Applied {
+		factor = plan.Calibration.Factor
+	}
+	return rtc.methodManager.RecordEstimate(ctx, &EstimateRecord{
+		MethodID:          plan.MethodID,
+		ObjectiveID:       plan.ObjectiveID,
+		PlanID:            plan.ID,
+		ExecutionID:       result.ID,
+		RawTokens:         rawTokens,
+		EstimatedTokens:   plan.TotalEstimatedTokens,
+		ActualTokens:      result.TotalTokensUsed,
+		EstimatedCost:     float64(plan.TotalEstimatedTokens) * rtc.costPerToken,
+		ActualCost:        float64(result.TotalTokensUsed) * rtc.costPerToken,
+		CalibrationFactor: factor,
+		RecordedAt:        result.EndTime,
+	})
+}
+
+// finishCheckpoints removes the checkpoints of a plan whose execution ran to
+// the end, since there is nothing left to resume.
+func (rtc *RealTimeCursor) finishCheckpoints(ctx context.Context, plan *ExecutionPlan) {
+	if err := rtc.discardPlanCheckpoints(ctx, plan.ID); err != nil {
+		fmt.Printf("Warning: failed to clean up checkpoints of plan %s: %v\n", plan.ID, err)
+	}
+}
+
+// dependencyOutputs returns the outputs of the completed tasks a task depends on.
+func (rtc *RealTimeCursor) dependencyOutputs(ctx context.Context, plan *ExecutionPlan, task *ExecutionTask, result *ExecutionResult) map[string]interface{} {
+	outputs := make(map[string]interface{})
+	for _, dep := range plan.Dependencies {
+		if dep.TaskID != task.ID {
+			continue
+		}
+		if done, ok := result.TaskResults[dep.DependsOnTaskID]; ok && done.Status == TaskStatusCompleted {
+			outputs[dep.DependsOnTaskID] = rtc.taskOutput(ctx, done)
 		}
+	}
+	return outputs
+}
+
+// executeTaskWithRetries executes a single task with retry logic, passing
+// the outputs of its prerequisites to the executor.
+func (rtc *RealTimeCursor) executeTaskWithRetries(ctx context.Context, task *ExecutionTask, dependencyOutputs map[string]interface{}) (*TaskResult, error) {
+	result := &TaskResult{
+		TaskID:          task.ID,
+		MethodStepIndex: task.MethodStepIndex,
+		Status:          TaskStatusPending,
+		CompletedAt:     time.Time{},
+	}
+
+	// Check for context cancellation before the first attempt
+	if ctx.Err() != nil {
+		result.Status = TaskStatusFailed
+		result.ErrorMessage = "Task cancelled"
+		result.CompletedAt = time.Now()
+		return result, ctx.Err()
+	}
 
+	attempt := 0
+	started := time.Now()
+	stats, lastError := retry.Do(ctx, rtc.retryConfig.Policy(), func(ctx context.Context) error {
 		// Update status for tracking
 		if attempt == 0 {
 			result.Status = TaskStatusRunning
 		} else {
 			result.Status = TaskStatusRetrying
 		}
+		attempt++
 
 		// Load context for this task
 		fullContext, err := rtc.contextLoader.LoadTaskContext(ctx, task)
 		if err != nil {
-			lastError = fmt.Errorf("failed to load task context: %w", err)
-			if err == context.Canceled || err == context.DeadlineExceeded || !rtc.shouldRetry(lastError, attempt) {
-				break
+			if err == context.Canceled || err == context.DeadlineExceeded {
+				return retry.Stop(fmt.Errorf("failed to load task context: %w", err))
 			}
-			rtc.waitForRetryWithContext(ctx, attempt)
-			continue
+			return fmt.Errorf("failed to load task context: %w", err)
+		}
+		fullContext = withAttribution(ctx, fullContext)
+		if len(dependencyOutputs) > 0 {
+			fullContext[DependencyOutputsKey] = dependencyOutputs
 		}
 
 		// Execute the task
 		startTime := time.Now()
 		taskResult, err := rtc.executor.ExecuteTask(ctx, task, fullContext)
 		duration := time.Since(startTime)
-
 		if err != nil {
-			lastError = err
-			if err == context.Canceled || err == context.DeadlineExceeded || !rtc.shouldRetry(err, attempt) {
-				break
-			}
-			rtc.waitForRetryWithContext(ctx, attempt)
-			continue
+			return err
 		}
 
 		// Success - update result with execution data
@@ -415,11 +750,22 @@ func (rtc *RealTimeCursor) executeTaskWithRetries(ctx context.Context, task *Exe
 		result.TokensUsed = taskResult.TokensUsed
 		result.Duration = duration
 		result.ToolsUsed = taskResult.ToolsUsed
+		result.RoutingID = taskResult.RoutingID
 		result.Confidence = taskResult.Confidence
 		result.CompletedAt = time.Now()
-
+		return nil
+	})
+	defer recordTaskMetrics(result, stats.Attempts, started)
+	if lastError == nil {
+		rtc.storeOutput(ctx, result)
 		return result, nil
 	}
+	if stats.Cancelled {
+		result.Status = TaskStatusFailed
+		result.ErrorMessage = "Task cancelled"
+		result.CompletedAt = time.Now()
+		return result, lastError
+	}
 
 	// All retries exhausted - mark as failed
 	result.Status = TaskStatusFailed
@@ -429,8 +775,56 @@ func (rtc *RealTimeCursor) executeTaskWithRetries(ctx context.Context, task *Exe
 	return result, lastError
 }
 
+// recordTaskMetrics counts a finished task by its final status, with the
+// attempts it took beyond the first and its duration since started.
+func recordTaskMetrics(result *TaskResult, attempts int, started time.Time) {
+	status := string(result.Status)
+	metrics.Tasks.Inc(status)
+	if attempts > 1 {
+		metrics.TaskRetries.Add(float64(attempts - 1))
+	}
+	metrics.TaskDuration.Observe(time.Since(started).Seconds(), status)
+}
+
+// goalIDFor returns the ID of the goal an objective serves, or "" when the
+// objective cannot be loaded.
+func (rtc *RealTimeCursor) goalIDFor(ctx context.Context, objectiveID string) string {
+	if rtc.store == nil {
+		return ""
+	}
+	objective, err := NewObjectiveManager(rtc.store).GetObjective(ctx, objectiveID)
+	if err != nil {
+		return ""
+	}
+	return objective.GoalID
+}
+
+// withAttribution returns a copy of a task's full context with the goal and
+// objective IDs of the execution under llm.MetadataGoalID and
+// llm.MetadataObjectiveID, so executors can attribute their LLM spending.
+func withAttribution(ctx context.Context, fullContext map[string]interface{}) map[string]interface{} {
+	lc := utils.LogContextFrom(ctx)
+	attributed := make(map[string]interface{}, len(fullContext)+2)
+	for key, value := range fullContext {
+		attributed[key] = value
+	}
+	if lc.GoalID != "" {
+		attributed[llm.MetadataGoalID] = lc.GoalID
+	}
+	if lc.ObjectiveID != "" {
+		attributed[llm.MetadataObjectiveID] = lc.ObjectiveID
+	}
+	return attributed
+}
+
 // validatePlan performs basic validation on the execution plan.
 func (rtc *RealTimeCursor) validatePlan(plan *ExecutionPlan) error {
+	return validatePlanStructure(plan)
+}
+
+// validatePlanStructure checks that a plan is complete and that its
+// dependencies reference its own tasks.
+func validatePlanStructure(plan *ExecutionPlan) error {
 	if plan == nil {
 		return fmt.Errorf("plan cannot be nil")
 	}
@@ -497,7 +891,7 @@ func (rtc *RealTimeCursor) resolveDependencies(plan *ExecutionPlan) ([]*Executio
 	var visit func(taskID string) error
 	visit = func(taskID string) error {
 		if visiting[taskID] {
-			return fmt.Errorf("circular dependency detected involving task: %s", taskID)
+			return errs.Newf(errs.DependenciesNotMet, "circular dependency detected involving task: %s", taskID).With("task_id", taskID)
 		}
 		if visited[taskID] {
 			return nil
@@ -533,70 +927,11 @@ func (rtc *RealTimeCursor) resolveDependencies(plan *ExecutionPlan) ([]*Executio
 	return result, nil
 }
 
-// shouldRetry determines if a task execution error should trigger a retry.
+// shouldRetry determines if a task execution error should trigger a retry
+// after the given zero-based attempt.
 func (rtc *RealTimeCursor) shouldRetry(err error, attempt int) bool {
-	if attempt >= rtc.retryConfig.MaxRetries {
-		return false
-	}
-
-	errorMsg := err.Error()
-	for _, retriableError := range rtc.retryConfig.RetriableErrors {
-		if len(errorMsg) >= len(retriableError) {
-			for i := 0; i <= len(errorMsg)-len(retriableError); i++ {
-				if errorMsg[i:i+len(retriableError)] == retriableError {
-					return true
-				}
-			}
-		}
-	}
-
-	return false
-}
-
-// waitForRetry implements exponential backoff delay between retries.
-func (rtc *RealTimeCursor) waitForRetry(attempt int) {
-	if attempt <= 0 {
-		return
-	}
-
-	delay := float64(rtc.retryConfig.BaseDelay)
-	for i := 1; i < attempt; i++ {
-		delay *= rtc.retryConfig.BackoffMultiplier
-	}
-
-	finalDelay := time.Duration(delay)
-	if finalDelay > rtc.retryConfig.MaxDelay {
-		finalDelay = rtc.retryConfig.MaxDelay
-	}
-
-	time.Sleep(finalDelay)
-}
-
-// waitForRetryWithContext implements exponential backoff delay between retries that can be cancelled.
-func (rtc *RealTimeCursor) waitForRetryWithContext(ctx context.Context, attempt int) {
-	if attempt <= 0 {
-		return
-	}
-
-	delay := float64(rtc.retryConfig.BaseDelay)
-	for i := 1; i < attempt; i++ {
-		delay *= rtc.retryConfig.BackoffMultiplier
-	}
-
-	finalDelay := time.Duration(delay)
-	if finalDelay > rtc.retryConfig.MaxDelay {
-		finalDelay = rtc.retryConfig.MaxDelay
-	}
-
-	timer := time.NewTimer(finalDelay)
-	defer timer.Stop()
-
-	select {
-	case <-timer.C:
-		// Normal delay completed
-	case <-ctx.Done():
-		// Context cancelled, return immediately
-	}
+	_, ok := rtc.retryConfig.Policy().Next(attempt+1, err)
+	return ok
 }
 
 // isCriticalTask determines if a task failure should stop the entire plan execution.
@@ -734,6 +1069,7 @@ func (rtc *RealTimeCursor) storeExecutionResult(ctx context.Context, result *Exe
 	data := map[string]interface{}{
 		"plan_id":                result.PlanID,
 		"objective_id":           result.ObjectiveID,
+		"method_id":              result.MethodID,
 		"status":                 string(result.Status),
 		"total_tokens_used":      result.TotalTokensUsed,
 		"total_duration":         result.TotalDuration.Seconds(),
@@ -748,21 +1084,58 @@ func (rtc *RealTimeCursor) storeExecutionResult(ctx context.Context, result *Exe
 	// Add task results summary (avoiding too much detail in main node)
 	taskSummary := make(map[string]interface{})
 	for taskID, taskResult := range result.TaskResults {
-		taskSummary[taskID] = map[string]interface{}{
-			"status":       string(taskResult.Status),
-			"tokens_used":  taskResult.TokensUsed,
-			"duration":     taskResult.Duration.Seconds(),
-			"confidence":   taskResult.Confidence,
-			"tools_used":   taskResult.ToolsUsed,
+		summary := map[string]interface{}{
+			"status":            string(taskResult.Status),
+			"tokens_used":       taskResult.TokensUsed,
+			"duration":          taskResult.Duration.Seconds(),
+			"confidence":        taskResult.Confidence,
+			"tools_used":        taskResult.ToolsUsed,
+			"method_step_index": taskResult.MethodStepIndex,
+		}
+		if taskResult.OutputRef != "" {
+			summary["output_ref"] = taskResult.OutputRef
+			if taskResult.outputJSON {
+				summary["output_json"] = true
+			}
 		}
+		if taskResult.RoutingID != "" {
+			summary["routing_id"] = taskResult.RoutingID
+		}
+		taskSummary[taskID] = summary
 	}
 	data["task_summary"] = taskSummary
 
+	// Record the plan and the context it was made for, for re-planning and audit
+	if result.Plan != nil {
+		data["plan"] = planToData(result.Plan)
+	}
+	if result.ContextFingerprint != nil {
+		data["context_fingerprint"] = result.ContextFingerprint.toData()
+	}
+	if result.Replan != nil {
+		data["replan"] = result.Replan.toData()
+	}
+	if result.Comparison != nil {
+		data["comparison"] = result.Comparison.toData()
+	}
+	if result.CheckpointTaskID != "" {
+		data["checkpoint_task_id"] = result.CheckpointTaskID
+	}
+	if result.Resumed {
+		data["resumed"] = true
+		data["original_duration"] = result.OriginalDuration.Seconds()
+		data["resumed_duration"] = result.ResumedDuration.Seconds()
+	}
+
 	// Create storage node
 	node := storage.NewNode("execution_result", data)
 
 	// Store the node
-	return rtc.store.AddNode(ctx, node)
+	if err := rtc.store.AddNode(ctx, node); err != nil {
+		return err
+	}
+	result.ID = node.ID
+	return nil
 }
 
 // GetExecutionHistory returns recent execution results for analysis.
@@ -793,11 +1166,17 @@ func (rtc *RealTimeCursor) GetExecutionHistory(ctx context.Context, limit int) (
 
 // nodeToExecutionResult converts a storage node to an ExecutionResult object.
 func (rtc *RealTimeCursor) nodeToExecutionResult(node *storage.Node) (*ExecutionResult, error) {
+	return executionResultFromNode(node)
+}
+
+// executionResultFromNode converts a stored execution_result node to an ExecutionResult.
+func executionResultFromNode(node *storage.Node) (*ExecutionResult, error) {
 	if node == nil || node.Type != "execution_result" {
 		return nil, fmt.Errorf("invalid execution result node")
 	}
 
 	result := &ExecutionResult{
+		ID:                   node.ID,
 		TaskResults:          make(map[string]*TaskResult),
 		MethodRefinementData: make(map[string]interface{}),
 	}
@@ -809,25 +1188,20 @@ func (rtc *RealTimeCursor) nodeToExecutionResult(node *storage.Node) (*Execution
 	if objectiveID, ok := node.Data["objective_id"].(string); ok {
 		result.ObjectiveID = objectiveID
 	}
+	if methodID, ok := node.Data["method_id"].(string); ok {
+		result.MethodID = methodID
+	}
 	if statusStr, ok := node.Data["status"].(string); ok {
 		result.Status = ExecutionStatus(statusStr)
 	}
 
 	// Extract numeric fields (handle both int and float64 from JSON)
-	if tokensUsed, ok := node.Data["total_tokens_used"].(float64); ok {
-		result.TotalTokensUsed = int(tokensUsed)
-	}
-	if successfulTasks, ok := node.Data["successful_tasks"].(float64); ok {
-		result.SuccessfulTasks = int(successfulTasks)
-	}
-	if failedTasks, ok := node.Data["failed_tasks"].(float64); ok {
-		result.FailedTasks = int(failedTasks)
-	}
+	result.TotalTokensUsed = int(getFloat64(node.Data, "total_tokens_used"))
+	result.SuccessfulTasks = int(getFloat64(node.Data, "successful_tasks"))
+	result.FailedTasks = int(getFloat64(node.Data, "failed_tasks"))
 
 	// Extract duration
-	if duration, ok := node.Data["total_duration"].(float64); ok {
-		result.TotalDuration = time.Duration(duration) * time.Second
-	}
+	result.TotalDuration = time.Duration(getFloat64(node.Data, "total_duration") * float64(time.Second))
 
 	// Extract timestamps
 	if startTime, ok := node.Data["start_time"].(string); ok {
@@ -851,26 +1225,48 @@ func (rtc *RealTimeCursor) nodeToExecutionResult(node *storage.Node) (*Execution
 		result.MethodRefinementData = refinementData
 	}
 
+	// Extract the plan and the context it was made for
+	if planData, ok := node.Data["plan"].(map[string]interface{}); ok {
+		result.Plan = planFromData(planData)
+	}
+	if fingerprintData, ok := node.Data["context_fingerprint"].(map[string]interface{}); ok {
+		result.ContextFingerprint = contextFingerprintFromData(fingerprintData)
+	}
+	if replanData, ok := node.Data["replan"].(map[string]interface{}); ok {
+		result.Replan = replanDecisionFromData(replanData)
+		result.Replan.Fingerprint = result.ContextFingerprint
+	}
+	if comparisonData, ok := node.Data["comparison"].(map[string]interface{}); ok {
+		result.Comparison = comparisonBranchFromData(comparisonData)
+	}
+	result.CheckpointTaskID = getString(node.Data, "checkpoint_task_id")
+	if resumed, ok := node.Data["resumed"].(bool); ok && resumed {
+		result.Resumed = true
+		result.OriginalDuration = time.Duration(getFloat64(node.Data, "original_duration") * float64(time.Second))
+		result.ResumedDuration = time.Duration(getFloat64(node.Data, "resumed_duration") * float64(time.Second))
+	}
+
 	// For task results, we store a summary to avoid excessive data in the main node
 	// Full task results would be stored separately if needed
 	if taskSummary, ok := node.Data["task_summary"].(map[string]interface{}); ok {
 		for taskID, summaryData := range taskSummary {
 			if summary, ok := summaryData.(map[string]interface{}); ok {
 				taskResult := &TaskResult{
-					TaskID: taskID,
+					TaskID:          taskID,
+					MethodStepIndex: -1,
 				}
 				if status, ok := summary["status"].(string); ok {
 					taskResult.Status = TaskStatus(status)
 				}
-				if tokensUsed, ok := summary["tokens_used"].(float64); ok {
-					taskResult.TokensUsed = int(tokensUsed)
-				}
-				if duration, ok := summary["duration"].(float64); ok {
-					taskResult.Duration = time.Duration(duration) * time.Second
-				}
-				if confidence, ok := summary["confidence"].(float64); ok {
-					taskResult.Confidence = confidence
+				if _, ok := summary["method_step_index"]; ok {
+					taskResult.MethodStepIndex = int(getFloat64(summary, "method_step_index"))
 				}
+				taskResult.TokensUsed = int(getFloat64(summary, "tokens_used"))
+				taskResult.Duration = time.Duration(getFloat64(summary, "duration") * float64(time.Second))
+				taskResult.Confidence = getFloat64(summary, "confidence")
+				taskResult.OutputRef = getString(summary, "output_ref")
+				taskResult.RoutingID = getString(summary, "routing_id")
+				taskResult.outputJSON, _ = summary["output_json"].(bool)
 				if toolsUsed, ok := summary["tools_used"].([]interface{}); ok {
 					var tools []string
 					for _, tool := range toolsUsed {
@@ -895,6 +1291,19 @@ func (rtc *RealTimeCursor) SetRetryConfig(config *RetryConfig) {
 	}
 }
 
+// SetPlanValidator sets the validator that checks plans before they run.
+// Plans with errors fail without running a task; the report, warnings
+// included, is stored with the plan.
+func (rtc *RealTimeCursor) SetPlanValidator(validator *PlanValidator) {
+	rtc.planValidator = validator
+}
+
+// SetCostPerToken sets the price used to record estimated and actual cost
+// alongside token counts.
+func (rtc *RealTimeCursor) SetCostPerToken(costPerToken float64) {
+	rtc.costPerToken = costPerToken
+}
+
 // GetRetryConfig returns the current retry configuration.
 func (rtc *RealTimeCursor) GetRetryConfig() *RetryConfig {
 	return rtc.retryConfig
@@ -918,5 +1327,5 @@ func (ts TaskStatus) IsTerminal() bool {
 // IsTerminal returns true if the execution status indicates execution is finished.
 func (es ExecutionStatus) IsTerminal() bool {
 	return es == ExecutionStatusCompleted || es == ExecutionStatusFailed ||
-	       es == ExecutionStatusPartial || es == ExecutionStatusCancelled
-}
\ No newline at end of file
+		es == ExecutionStatusPartial || es == ExecutionStatusCancelled
+}
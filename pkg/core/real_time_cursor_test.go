@@ -2,13 +2,16 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 // MockTaskExecutor implements TaskExecutor interface for testing.
@@ -343,6 +346,37 @@ func TestExecutePlan_Success(t *testing.T) {
 	}
 }
 
+func TestExecutePlan_AttributesTasks(t *testing.T) {
+	rtc, store, executor, _ := setupTestRTC(t)
+	ctx := context.Background()
+
+	goal, err := NewGoalManager(store).CreateGoal(ctx, "Ship the release", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := NewMethodManager(store).CreateMethod(ctx, "Reporting", "", []ApproachStep{{Description: "Write"}}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	objective, err := NewObjectiveManager(store).CreateObjective(ctx, goal.ID, method.ID, "Write the report", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	plan := createTestPlan()
+	plan.ObjectiveID = objective.ID
+
+	if _, err := rtc.ExecutePlan(ctx, plan); err != nil {
+		t.Fatalf("ExecutePlan should not fail: %v", err)
+	}
+
+	// Executors see whose spending each task is
+	for _, call := range executor.executeTaskCalls {
+		if call.FullContext[llm.MetadataGoalID] != goal.ID || call.FullContext[llm.MetadataObjectiveID] != objective.ID {
+			t.Errorf("Expected task %s attributed to %s and %s, got %v", call.Task.ID, goal.ID, objective.ID, call.FullContext)
+		}
+	}
+}
+
 func TestExecutePlan_TaskFailure(t *testing.T) {
 	rtc, _, executor, _ := setupTestRTC(t)
 	plan := createTestPlan()
@@ -441,6 +475,77 @@ func TestExecutePlan_CancellationHandling(t *testing.T) {
 	}
 }
 
+// cancellingExecutor stores an output node per task and cancels the
+// execution during the first task, then tries to keep writing.
+type cancellingExecutor struct {
+	MockTaskExecutor
+	store        *storage.Store
+	cancel       context.CancelFunc
+	logContext   utils.LogContext
+	lateWriteErr error
+}
+
+func (e *cancellingExecutor) ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error) {
+	e.executeTaskCalls = append(e.executeTaskCalls, ExecuteTaskCall{Task: task})
+	e.logContext = utils.LogContextFrom(ctx)
+	if err := e.store.AddNode(ctx, storage.NewNode("task_output", map[string]interface{}{"task_id": task.ID})); err != nil {
+		return nil, err
+	}
+	e.cancel()
+	e.lateWriteErr = e.store.AddNode(ctx, storage.NewNode("task_output", map[string]interface{}{"task_id": task.ID + "_late"}))
+	return &TaskResult{Status: TaskStatusCompleted, TokensUsed: 10}, nil
+}
+
+func TestExecutePlan_CancelledStopsLaterWrites(t *testing.T) {
+	rtc, store, _, _ := setupTestRTC(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	executor := &cancellingExecutor{store: store, cancel: cancel}
+	rtc.executor = executor
+	plan := createTestPlan()
+
+	result, err := rtc.ExecutePlan(ctx, plan)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if result.Status != ExecutionStatusCancelled {
+		t.Errorf("Expected status %s, got %s", ExecutionStatusCancelled, result.Status)
+	}
+
+	// Only the first task ran, and nothing was written after the cancel
+	if len(executor.executeTaskCalls) != 1 {
+		t.Errorf("Expected only the first task to run, got %d calls", len(executor.executeTaskCalls))
+	}
+	if !errors.Is(executor.lateWriteErr, context.Canceled) {
+		t.Errorf("Expected a write after the cancel to be refused, got %v", executor.lateWriteErr)
+	}
+	outputs, err := store.GetNodesByType(context.Background(), "task_output")
+	if err != nil {
+		t.Fatalf("Failed to list task outputs: %v", err)
+	}
+	if len(outputs) != 1 || outputs[0].Data["task_id"] != "task_1" {
+		t.Errorf("Expected only the first task's output, got %d outputs", len(outputs))
+	}
+
+	// The cancelled outcome is still recorded
+	history, err := rtc.GetExecutionHistory(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Failed to load execution history: %v", err)
+	}
+	recorded := false
+	for _, stored := range history {
+		recorded = recorded || stored.Status == ExecutionStatusCancelled
+	}
+	if !recorded {
+		t.Error("Expected the cancelled execution to be stored")
+	}
+
+	// The executor's context carried the plan's correlation IDs
+	if executor.logContext.PlanID != plan.ID || executor.logContext.ObjectiveID != plan.ObjectiveID {
+		t.Errorf("Expected the plan and objective IDs in the log context, got %+v", executor.logContext)
+	}
+}
+
 func TestValidatePlan(t *testing.T) {
 	rtc, _, _, _ := setupTestRTC(t)
 
@@ -518,7 +623,7 @@ func TestRetryLogic(t *testing.T) {
 	executor.shouldFailExecution = true
 
 	startTime := time.Now()
-	result, err := rtc.executeTaskWithRetries(context.Background(), task)
+	result, err := rtc.executeTaskWithRetries(context.Background(), task, nil)
 	duration := time.Since(startTime)
 
 	// Should have failed after all retries
@@ -541,6 +646,86 @@ func TestRetryLogic(t *testing.T) {
 	}
 }
 
+// attemptTimingExecutor fails every task with a retriable error and records
+// when each attempt started.
+type attemptTimingExecutor struct {
+	MockTaskExecutor
+	attempts []time.Time
+}
+
+func (e *attemptTimingExecutor) ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error) {
+	e.attempts = append(e.attempts, time.Now())
+	return nil, fmt.Errorf("timeout talking to tool")
+}
+
+func TestRetryLogic_BackoffSchedule(t *testing.T) {
+	rtc, _, _, _ := setupTestRTC(t)
+	executor := &attemptTimingExecutor{}
+	rtc.executor = executor
+
+	// The first retry follows at once; later ones back off up to MaxDelay
+	rtc.SetRetryConfig(&RetryConfig{
+		MaxRetries:        3,
+		BaseDelay:         20 * time.Millisecond,
+		MaxDelay:          30 * time.Millisecond,
+		BackoffMultiplier: 4.0,
+		RetriableErrors:   []string{"timeout"},
+	})
+
+	task := &ExecutionTask{ID: "backoff_task", Type: "test"}
+	if _, err := rtc.executeTaskWithRetries(context.Background(), task, nil); err == nil {
+		t.Fatal("Expected the task to fail after all retries")
+	}
+	if len(executor.attempts) != 4 {
+		t.Fatalf("Expected 4 attempts, got %d", len(executor.attempts))
+	}
+
+	gaps := make([]time.Duration, 3)
+	for i := range gaps {
+		gaps[i] = executor.attempts[i+1].Sub(executor.attempts[i])
+	}
+	if gaps[0] >= 15*time.Millisecond {
+		t.Errorf("Expected the first retry without a wait, got %v", gaps[0])
+	}
+	if gaps[1] < 20*time.Millisecond {
+		t.Errorf("Expected the second retry after BaseDelay, got %v", gaps[1])
+	}
+	if gaps[2] < 30*time.Millisecond || gaps[2] >= 70*time.Millisecond {
+		t.Errorf("Expected the third retry after MaxDelay, got %v", gaps[2])
+	}
+}
+
+func TestRetryLogic_CancelledDuringWait(t *testing.T) {
+	rtc, _, _, _ := setupTestRTC(t)
+	executor := &attemptTimingExecutor{}
+	rtc.executor = executor
+	rtc.SetRetryConfig(&RetryConfig{
+		MaxRetries:        3,
+		BaseDelay:         time.Second,
+		MaxDelay:          time.Second,
+		BackoffMultiplier: 2.0,
+		RetriableErrors:   []string{"timeout"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result, err := rtc.executeTaskWithRetries(ctx, &ExecutionTask{ID: "cancel_task", Type: "test"}, nil)
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Errorf("Expected the wait to end with the context, took %v", elapsed)
+	}
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+	if result.Status != TaskStatusFailed || result.ErrorMessage != "Task cancelled" {
+		t.Errorf("Expected a cancelled task, got %s: %q", result.Status, result.ErrorMessage)
+	}
+	if len(executor.attempts) != 2 {
+		t.Errorf("Expected the immediate retry before the wait, got %d attempts", len(executor.attempts))
+	}
+}
+
 func TestShouldRetry(t *testing.T) {
 	rtc, _, _, _ := setupTestRTC(t)
 
@@ -0,0 +1,709 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// ReplanStrategy is how the plan for a re-run objective is derived from the
+// plan of its previous execution.
+type ReplanStrategy string
+
+const (
+	// ReplanFull plans the objective from scratch
+	ReplanFull ReplanStrategy = "full"
+	// ReplanReuse runs the previous plan again, resolving its references afresh
+	ReplanReuse ReplanStrategy = "reuse"
+	// ReplanPartial re-plans only the tasks that consume changed context keys
+	ReplanPartial ReplanStrategy = "partial"
+)
+
+// Description returns a short phrase for showing the strategy to the user.
+func (s ReplanStrategy) Description() string {
+	switch s {
+	case ReplanReuse:
+		return "reuse the last plan"
+	case ReplanPartial:
+		return "partial re-plan"
+	default:
+		return "full re-plan"
+	}
+}
+
+// ContextFingerprint records an objective's context as it was when a plan was made.
+type ContextFingerprint struct {
+	// Values maps each context key to a hash of its value
+	Values map[string]string
+
+	// References maps keys whose value is a reference (such as "data://sales")
+	// to a hash of the resolved content, or "" if it could not be resolved
+	References map[string]string
+
+	// CreatedAt is when the fingerprint was taken
+	CreatedAt time.Time
+}
+
+// ContextDiff lists how an objective's context differs from a fingerprint.
+// Every list is sorted.
+type ContextDiff struct {
+	// Added lists keys that were not in the fingerprinted context
+	Added []string
+
+	// Removed lists keys that are no longer in the context
+	Removed []string
+
+	// Changed lists keys whose value changed
+	Changed []string
+
+	// ReferencesChanged lists keys whose reference is unchanged but whose resolved content differs
+	ReferencesChanged []string
+
+	// Unresolved lists reference keys whose content could not be compared
+	Unresolved []string
+}
+
+// ReplanDecision records how a re-run objective was planned and why.
+type ReplanDecision struct {
+	// Strategy is the chosen way of planning
+	Strategy ReplanStrategy
+
+	// Reason explains the choice for the audit trail
+	Reason string
+
+	// PreviousExecutionID identifies the execution result compared against
+	PreviousExecutionID string
+
+	// PreviousPlanID identifies the plan of that execution
+	PreviousPlanID string
+
+	// Diff is the context diff, nil if there was nothing to compare against
+	Diff *ContextDiff
+
+	// AffectedTasks lists the tasks re-planned by a partial re-plan
+	AffectedTasks []string
+
+	// Fingerprint is the fingerprint of the current context
+	Fingerprint *ContextFingerprint
+
+	// PreviousPlan is the plan of the previous execution; it is not persisted
+	PreviousPlan *ExecutionPlan
+}
+
+// PlannedExecution is a finished execution together with the plan and
+// context fingerprint recorded with it.
+type PlannedExecution struct {
+	ResultID    string
+	Status      ExecutionStatus
+	StartTime   time.Time
+	Plan        *ExecutionPlan
+	Fingerprint *ContextFingerprint
+}
+
+// ReplanAdvisor compares an objective's context with the context its last
+// executed plan was made for, and decides how much of that plan can be kept.
+type ReplanAdvisor struct {
+	store            *storage.Store
+	objectiveManager *ObjectiveManager
+	methodManager    *MethodManager
+	loader           ContextLoader
+}
+
+// NewReplanAdvisor creates a replan advisor. The loader resolves references in
+// objective contexts; without one, only the references themselves are compared.
+func NewReplanAdvisor(store *storage.Store, loader ContextLoader) *ReplanAdvisor {
+	return &ReplanAdvisor{
+		store:            store,
+		objectiveManager: NewObjectiveManager(store),
+		methodManager:    NewMethodManager(store),
+		loader:           loader,
+	}
+}
+
+// Fingerprint takes the fingerprint of an objective's current context.
+func (ra *ReplanAdvisor) Fingerprint(ctx context.Context, objective *Objective) *ContextFingerprint {
+	return FingerprintContext(ctx, objective.Context, ra.loader)
+}
+
+// LastExecution returns the most recent finished execution of an objective
+// that recorded its plan, or nil if there is none.
+func (ra *ReplanAdvisor) LastExecution(ctx context.Context, objectiveID string) (*PlannedExecution, error) {
+	nodes, err := ra.store.GetNodesByType(ctx, "execution_result")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query execution results: %w", err)
+	}
+
+	// The losing side of a method comparison is not the objective's result
+	alternatives, err := comparisonAlternatives(ctx, ra.store)
+	if err != nil {
+		return nil, err
+	}
+
+	// Results are ordered by when they were stored, since start times are
+	// only kept to the second
+	var last *PlannedExecution
+	var lastStored time.Time
+	for _, node := range nodes {
+		if getString(node.Data, "objective_id") != objectiveID || alternatives[node.ID] {
+			continue
+		}
+		result, err := executionResultFromNode(node)
+		if err != nil || result.Plan == nil || !isTerminalExecutionStatus(result.Status) {
+			continue
+		}
+		if last != nil && !node.CreatedAt.After(lastStored) {
+			continue
+		}
+		lastStored = node.CreatedAt
+		last = &PlannedExecution{
+			ResultID:    node.ID,
+			Status:      result.Status,
+			StartTime:   result.StartTime,
+			Plan:        result.Plan,
+			Fingerprint: result.ContextFingerprint,
+		}
+	}
+	return last, nil
+}
+
+// Preview decides how the objective would be planned if it ran now, without planning it.
+func (ra *ReplanAdvisor) Preview(ctx context.Context, objectiveID string) (*ReplanDecision, error) {
+	objective, err := ra.objectiveManager.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve objective: %w", err)
+	}
+
+	decision := &ReplanDecision{
+		Strategy:    ReplanFull,
+		Fingerprint: ra.Fingerprint(ctx, objective),
+	}
+
+	last, err := ra.LastExecution(ctx, objectiveID)
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		decision.Reason = "no previous execution recorded its plan"
+		return decision, nil
+	}
+	decision.PreviousExecutionID = last.ResultID
+	decision.PreviousPlanID = last.Plan.ID
+	decision.PreviousPlan = last.Plan
+
+	if last.Fingerprint == nil {
+		decision.Reason = "the previous execution did not record its context"
+		return decision, nil
+	}
+	decision.Diff = DiffContext(last.Fingerprint, decision.Fingerprint)
+
+	if last.Status != ExecutionStatusCompleted && last.Status != ExecutionStatusPartial {
+		decision.Reason = fmt.Sprintf("the previous execution was %s", last.Status)
+		return decision, nil
+	}
+	if last.Plan.MethodID != "" {
+		method, err := ra.methodManager.GetMethod(ctx, last.Plan.MethodID)
+		if err != nil || method.Status != MethodStatusActive {
+			decision.Reason = fmt.Sprintf("method %s is no longer active", last.Plan.MethodID)
+			return decision, nil
+		}
+	}
+
+	decision.Strategy, decision.Reason, decision.AffectedTasks = chooseReplanStrategy(last.Plan, decision.Diff)
+	return decision, nil
+}
+
+// chooseReplanStrategy decides how to plan a re-run from the previous plan and
+// the context diff, returning the strategy, the reason, and the tasks to re-plan.
+// A removed key that a task consumes always forces a full re-plan, so a task
+// never runs with a missing input.
+func chooseReplanStrategy(previous *ExecutionPlan, diff *ContextDiff) (ReplanStrategy, string, []string) {
+	for _, key := range diff.Removed {
+		for _, task := range previous.Tasks {
+			if containsString(task.Context.Consumes, key) {
+				return ReplanFull, fmt.Sprintf("removed key %q is consumed by task %s", key, task.ID), nil
+			}
+		}
+	}
+
+	var keys []string
+	keys = append(keys, diff.Added...)
+	keys = append(keys, diff.Removed...)
+	keys = append(keys, diff.Changed...)
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		if len(diff.ReferencesChanged) > 0 {
+			return ReplanReuse, fmt.Sprintf("only content behind references changed (%s)", strings.Join(diff.ReferencesChanged, ", ")), nil
+		}
+		return ReplanReuse, "the context is unchanged", nil
+	}
+
+	// Tasks that do not declare what they consume may read any key
+	var affected []string
+	declared := false
+	for _, task := range previous.Tasks {
+		if len(task.Context.Consumes) > 0 {
+			declared = true
+		}
+		if len(task.Context.Consumes) == 0 || intersectsStrings(task.Context.Consumes, keys) {
+			affected = append(affected, task.ID)
+		}
+	}
+
+	switch {
+	case !declared:
+		return ReplanFull, "the previous plan does not declare what its tasks consume", nil
+	case len(affected) == 0:
+		return ReplanReuse, fmt.Sprintf("no task consumes the changed keys (%s)", strings.Join(keys, ", ")), nil
+	case len(affected) == len(previous.Tasks):
+		return ReplanFull, fmt.Sprintf("every task consumes the changed keys (%s)", strings.Join(keys, ", ")), nil
+	default:
+		return ReplanPartial, fmt.Sprintf("tasks %s consume the changed keys (%s)",
+			strings.Join(affected, ", "), strings.Join(keys, ", ")), affected
+	}
+}
+
+// mergePartialPlan builds a plan that keeps the previous plan's tasks except
+// the affected ones, which are taken from the fresh plan. Tasks are matched by
+// method step, or by ID when they are not method based. When a replaced task
+// writes its output somewhere else, the tasks depending on it are replaced too.
+// Returns the merged plan and the tasks actually replaced.
+func mergePartialPlan(previous, fresh *ExecutionPlan, affected []string) (*ExecutionPlan, []string, error) {
+	if previous.MethodID != fresh.MethodID {
+		return nil, nil, fmt.Errorf("the planner chose method %s instead of %s", fresh.MethodID, previous.MethodID)
+	}
+
+	freshTasks := make(map[string]ExecutionTask, len(fresh.Tasks))
+	for _, task := range fresh.Tasks {
+		freshTasks[planTaskKey(task)] = task
+	}
+
+	merged := previous.reissue()
+	merged.ID = fresh.ID
+	merged.CreatedAt = fresh.CreatedAt
+
+	replaced := make(map[string]bool)
+	queue := append([]string(nil), affected...)
+	for len(queue) > 0 {
+		taskID := queue[0]
+		queue = queue[1:]
+		if replaced[taskID] {
+			continue
+		}
+
+		index := -1
+		for i, task := range merged.Tasks {
+			if task.ID == taskID {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, nil, fmt.Errorf("task %s is not in the previous plan", taskID)
+		}
+		old := merged.Tasks[index]
+		counterpart, ok := freshTasks[planTaskKey(old)]
+		if !ok {
+			return nil, nil, fmt.Errorf("the new plan has no counterpart for task %s", taskID)
+		}
+
+		// Keep the ID so dependencies on the task stay valid
+		counterpart.ID = old.ID
+		merged.Tasks[index] = counterpart
+		replaced[taskID] = true
+
+		if counterpart.Context.OutputRef != old.Context.OutputRef {
+			for _, dep := range merged.Dependencies {
+				if dep.DependsOnTaskID == taskID {
+					queue = append(queue, dep.TaskID)
+				}
+			}
+		}
+	}
+
+	var replacedIDs []string
+	merged.TotalEstimatedTokens = 0
+	for _, task := range merged.Tasks {
+		merged.TotalEstimatedTokens += task.EstimatedTokens
+		if replaced[task.ID] {
+			replacedIDs = append(replacedIDs, task.ID)
+		}
+	}
+	return merged, replacedIDs, nil
+}
+
+// planTaskKey identifies a task across plans of the same method.
+func planTaskKey(task ExecutionTask) string {
+	if task.MethodStepIndex >= 0 {
+		return fmt.Sprintf("step:%d", task.MethodStepIndex)
+	}
+	return "id:" + task.ID
+}
+
+// reissue copies the plan under a new ID, for running it again.
+func (p *ExecutionPlan) reissue() *ExecutionPlan {
+	plan := *p
+	plan.ID = generatePlanID()
+	plan.Tasks = append([]ExecutionTask(nil), p.Tasks...)
+	plan.Dependencies = append([]TaskDependency(nil), p.Dependencies...)
+	plan.ContextFingerprint = nil
+	plan.Replan = nil
+	plan.Validation = nil
+	plan.CreatedAt = time.Now()
+	return &plan
+}
+
+// FingerprintContext hashes each value of an objective context. Values that
+// are references are also resolved through the loader, when one is given, so
+// that a change behind an unchanged reference is noticed.
+func FingerprintContext(ctx context.Context, objectiveContext map[string]interface{}, loader ContextLoader) *ContextFingerprint {
+	fingerprint := &ContextFingerprint{
+		Values:     make(map[string]string, len(objectiveContext)),
+		References: make(map[string]string),
+		CreatedAt:  time.Now(),
+	}
+
+	for key, value := range objectiveContext {
+		fingerprint.Values[key] = hashContextValue(value)
+
+		ref, ok := value.(string)
+		if !ok || !isContextReference(ref) {
+			continue
+		}
+		fingerprint.References[key] = ""
+		if loader == nil {
+			continue
+		}
+		if content, err := loader.ResolveReference(ctx, ref); err == nil {
+			fingerprint.References[key] = hashContextValue(content)
+		}
+	}
+	return fingerprint
+}
+
+// DiffContext compares a current context fingerprint with a previous one.
+func DiffContext(previous, current *ContextFingerprint) *ContextDiff {
+	diff := &ContextDiff{}
+
+	for key, hash := range current.Values {
+		previousHash, ok := previous.Values[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, key)
+		case previousHash != hash:
+			diff.Changed = append(diff.Changed, key)
+		default:
+			currentContent, isRef := current.References[key]
+			if !isRef {
+				continue
+			}
+			previousContent := previous.References[key]
+			switch {
+			case currentContent == "" || previousContent == "":
+				diff.Unresolved = append(diff.Unresolved, key)
+			case currentContent != previousContent:
+				diff.ReferencesChanged = append(diff.ReferencesChanged, key)
+			}
+		}
+	}
+	for key := range previous.Values {
+		if _, ok := current.Values[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.ReferencesChanged)
+	sort.Strings(diff.Unresolved)
+	return diff
+}
+
+// IsEmpty reports whether nothing changed, including behind references.
+func (d *ContextDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.ReferencesChanged) == 0
+}
+
+// Lines renders the diff one key per line, marked +, -, ~ for added, removed
+// and changed keys, > for changed content behind a reference, and ? for
+// references that could not be compared.
+func (d *ContextDiff) Lines() []string {
+	var lines []string
+	for _, section := range []struct {
+		mark string
+		keys []string
+		note string
+	}{
+		{"+", d.Added, "added"},
+		{"-", d.Removed, "removed"},
+		{"~", d.Changed, "changed"},
+		{">", d.ReferencesChanged, "content behind reference changed"},
+		{"?", d.Unresolved, "reference could not be resolved"},
+	} {
+		for _, key := range section.keys {
+			lines = append(lines, fmt.Sprintf("%s %s (%s)", section.mark, key, section.note))
+		}
+	}
+	return lines
+}
+
+// String summarizes the diff, such as "1 added, 2 changed".
+func (d *ContextDiff) String() string {
+	var parts []string
+	for _, count := range []struct {
+		n    int
+		name string
+	}{
+		{len(d.Added), "added"},
+		{len(d.Removed), "removed"},
+		{len(d.Changed), "changed"},
+		{len(d.ReferencesChanged), "changed behind references"},
+	} {
+		if count.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count.n, count.name))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// isContextReference reports whether a context value refers to data held
+// elsewhere, such as "data://survey_responses".
+func isContextReference(value string) bool {
+	scheme, rest, ok := strings.Cut(value, "://")
+	return ok && scheme != "" && rest != "" && !strings.ContainsAny(value, " \t\n")
+}
+
+// hashContextValue hashes a context value. JSON encoding sorts map keys and
+// writes whole numbers the same whether they are ints or reloaded float64s.
+func hashContextValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded = []byte(fmt.Sprintf("%v", value))
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func intersectsStrings(a, b []string) bool {
+	for _, item := range a {
+		if containsString(b, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// toData converts the fingerprint to storage data.
+func (f *ContextFingerprint) toData() map[string]interface{} {
+	values := make(map[string]interface{}, len(f.Values))
+	for key, hash := range f.Values {
+		values[key] = hash
+	}
+	references := make(map[string]interface{}, len(f.References))
+	for key, hash := range f.References {
+		references[key] = hash
+	}
+	return map[string]interface{}{
+		"values":     values,
+		"references": references,
+		"created_at": f.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// contextFingerprintFromData converts stored data back to a fingerprint.
+func contextFingerprintFromData(data map[string]interface{}) *ContextFingerprint {
+	fingerprint := &ContextFingerprint{
+		Values:     make(map[string]string),
+		References: make(map[string]string),
+	}
+	if values, ok := data["values"].(map[string]interface{}); ok {
+		for key, hash := range values {
+			fingerprint.Values[key], _ = hash.(string)
+		}
+	}
+	if references, ok := data["references"].(map[string]interface{}); ok {
+		for key, hash := range references {
+			fingerprint.References[key], _ = hash.(string)
+		}
+	}
+	if createdAt := parseOptionalTime(data["created_at"]); createdAt != nil {
+		fingerprint.CreatedAt = *createdAt
+	}
+	return fingerprint
+}
+
+// toData converts the decision to storage data. The fingerprint is stored
+// separately on the execution result.
+func (d *ReplanDecision) toData() map[string]interface{} {
+	data := map[string]interface{}{
+		"strategy":              string(d.Strategy),
+		"reason":                d.Reason,
+		"previous_execution_id": d.PreviousExecutionID,
+		"previous_plan_id":      d.PreviousPlanID,
+		"affected_tasks":        stringsToInterfaces(d.AffectedTasks),
+	}
+	if d.Diff != nil {
+		data["diff"] = map[string]interface{}{
+			"added":              stringsToInterfaces(d.Diff.Added),
+			"removed":            stringsToInterfaces(d.Diff.Removed),
+			"changed":            stringsToInterfaces(d.Diff.Changed),
+			"references_changed": stringsToInterfaces(d.Diff.ReferencesChanged),
+			"unresolved":         stringsToInterfaces(d.Diff.Unresolved),
+		}
+	}
+	return data
+}
+
+// replanDecisionFromData converts stored data back to a decision.
+func replanDecisionFromData(data map[string]interface{}) *ReplanDecision {
+	decision := &ReplanDecision{
+		Strategy:            ReplanStrategy(getString(data, "strategy")),
+		Reason:              getString(data, "reason"),
+		PreviousExecutionID: getString(data, "previous_execution_id"),
+		PreviousPlanID:      getString(data, "previous_plan_id"),
+		AffectedTasks:       toStringSlice(data["affected_tasks"]),
+	}
+	if diff, ok := data["diff"].(map[string]interface{}); ok {
+		decision.Diff = &ContextDiff{
+			Added:             toStringSlice(diff["added"]),
+			Removed:           toStringSlice(diff["removed"]),
+			Changed:           toStringSlice(diff["changed"]),
+			ReferencesChanged: toStringSlice(diff["references_changed"]),
+			Unresolved:        toStringSlice(diff["unresolved"]),
+		}
+	}
+	return decision
+}
+
+// planToData converts a plan to storage data, so it can be reused later.
+func planToData(plan *ExecutionPlan) map[string]interface{} {
+	tasks := make([]interface{}, len(plan.Tasks))
+	for i, task := range plan.Tasks {
+		tasks[i] = map[string]interface{}{
+			"id":                task.ID,
+			"type":              task.Type,
+			"description":       task.Description,
+			"input_refs":        stringsToInterfaces(task.Context.InputRefs),
+			"output_ref":        task.Context.OutputRef,
+			"parameters":        task.Context.Parameters,
+			"token_budget":      task.Context.TokenBudget,
+			"priority":          task.Context.Priority,
+			"consumes":          stringsToInterfaces(task.Context.Consumes),
+			"method_step_index": task.MethodStepIndex,
+			"estimated_tokens":  task.EstimatedTokens,
+			"created_at":        task.CreatedAt.Format(time.RFC3339),
+		}
+	}
+	dependencies := make([]interface{}, len(plan.Dependencies))
+	for i, dep := range plan.Dependencies {
+		dependencies[i] = map[string]interface{}{
+			"task_id":            dep.TaskID,
+			"depends_on_task_id": dep.DependsOnTaskID,
+			"reason":             dep.Reason,
+		}
+	}
+	data := map[string]interface{}{
+		"id":                     plan.ID,
+		"objective_id":           plan.ObjectiveID,
+		"method_id":              plan.MethodID,
+		"title":                  plan.Title,
+		"tasks":                  tasks,
+		"dependencies":           dependencies,
+		"total_estimated_tokens": plan.TotalEstimatedTokens,
+		"created_by":             plan.CreatedBy,
+		"created_at":             plan.CreatedAt.Format(time.RFC3339),
+	}
+	if plan.Validation != nil {
+		data["validation"] = plan.Validation.toData()
+	}
+	return data
+}
+
+// planFromData converts stored data back to a plan.
+func planFromData(data map[string]interface{}) *ExecutionPlan {
+	plan := &ExecutionPlan{
+		ID:                   getString(data, "id"),
+		ObjectiveID:          getString(data, "objective_id"),
+		MethodID:             getString(data, "method_id"),
+		Title:                getString(data, "title"),
+		TotalEstimatedTokens: int(getFloat64(data, "total_estimated_tokens")),
+		CreatedBy:            getString(data, "created_by"),
+	}
+	if createdAt := parseOptionalTime(data["created_at"]); createdAt != nil {
+		plan.CreatedAt = *createdAt
+	}
+
+	tasks, _ := data["tasks"].([]interface{})
+	for _, item := range tasks {
+		taskData, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		task := ExecutionTask{
+			ID:          getString(taskData, "id"),
+			Type:        getString(taskData, "type"),
+			Description: getString(taskData, "description"),
+			Context: TaskContext{
+				InputRefs:   toStringSlice(taskData["input_refs"]),
+				OutputRef:   getString(taskData, "output_ref"),
+				TokenBudget: int(getFloat64(taskData, "token_budget")),
+				Priority:    int(getFloat64(taskData, "priority")),
+				Consumes:    toStringSlice(taskData["consumes"]),
+			},
+			MethodStepIndex: int(getFloat64(taskData, "method_step_index")),
+			EstimatedTokens: int(getFloat64(taskData, "estimated_tokens")),
+		}
+		if parameters, ok := taskData["parameters"].(map[string]interface{}); ok {
+			task.Context.Parameters = parameters
+		}
+		if createdAt := parseOptionalTime(taskData["created_at"]); createdAt != nil {
+			task.CreatedAt = *createdAt
+		}
+		plan.Tasks = append(plan.Tasks, task)
+	}
+
+	dependencies, _ := data["dependencies"].([]interface{})
+	for _, item := range dependencies {
+		depData, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		plan.Dependencies = append(plan.Dependencies, TaskDependency{
+			TaskID:          getString(depData, "task_id"),
+			DependsOnTaskID: getString(depData, "depends_on_task_id"),
+			Reason:          getString(depData, "reason"),
+		})
+	}
+	if validation, ok := data["validation"].(map[string]interface{}); ok {
+		plan.Validation = planValidationFromData(validation)
+	}
+	return plan
+}
+
+func stringsToInterfaces(list []string) []interface{} {
+	items := make([]interface{}, len(list))
+	for i, item := range list {
+		items[i] = item
+	}
+	return items
+}
@@ -0,0 +1,261 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// refTestLoader resolves references from a map.
+type refTestLoader map[string]interface{}
+
+func (l refTestLoader) LoadTaskContext(ctx context.Context, task *ExecutionTask) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (l refTestLoader) LoadObjectiveContext(ctx context.Context, objectiveID string) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (l refTestLoader) ResolveReference(ctx context.Context, ref string) (interface{}, error) {
+	content, ok := l[ref]
+	if !ok {
+		return nil, fmt.Errorf("unknown reference %s", ref)
+	}
+	return content, nil
+}
+
+// declaringReasoner plans like MockLLMReasoner, with tasks that declare the
+// context keys they consume: task_1 reads "region", task_2 reads "format".
+type declaringReasoner struct {
+	*MockLLMReasoner
+}
+
+func (r declaringReasoner) DecomposePlan(ctx context.Context, objective *Objective, method *Method) (*ExecutionPlan, error) {
+	plan, err := r.MockLLMReasoner.DecomposePlan(ctx, objective, method)
+	if err != nil {
+		return nil, err
+	}
+	plan.Tasks[0].Context.Consumes = []string{"region"}
+	plan.Tasks[1].Context.Consumes = []string{"format"}
+	return plan, nil
+}
+
+func TestDiffContext(t *testing.T) {
+	ctx := context.Background()
+	previous := FingerprintContext(ctx, map[string]interface{}{
+		"region":  "north",
+		"limit":   10,
+		"dataset": "data://sales",
+		"notes":   "first run",
+	}, refTestLoader{"data://sales": "q1 figures"})
+
+	current := FingerprintContext(ctx, map[string]interface{}{
+		"region":  "south",
+		"limit":   float64(10), // as reloaded from disk
+		"dataset": "data://sales",
+		"owner":   "me",
+	}, refTestLoader{"data://sales": "q2 figures"})
+
+	diff := DiffContext(previous, current)
+	want := &ContextDiff{
+		Added:             []string{"owner"},
+		Removed:           []string{"notes"},
+		Changed:           []string{"region"},
+		ReferencesChanged: []string{"dataset"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("Expected %+v, got %+v", want, diff)
+	}
+	if diff.String() != "1 added, 1 removed, 1 changed, 1 changed behind references" {
+		t.Errorf("Unexpected summary %q", diff.String())
+	}
+	if len(diff.Lines()) != 4 || diff.Lines()[1] != "- notes (removed)" {
+		t.Errorf("Unexpected lines %v", diff.Lines())
+	}
+
+	// Without a loader, content behind references cannot be compared
+	unresolved := DiffContext(previous, FingerprintContext(ctx, map[string]interface{}{
+		"region": "north", "limit": 10, "dataset": "data://sales", "notes": "first run",
+	}, nil))
+	if !unresolved.IsEmpty() || !reflect.DeepEqual(unresolved.Unresolved, []string{"dataset"}) {
+		t.Errorf("Expected only an unresolved reference, got %+v", unresolved)
+	}
+}
+
+func TestChooseReplanStrategy(t *testing.T) {
+	declared := &ExecutionPlan{Tasks: []ExecutionTask{
+		{ID: "gather", Context: TaskContext{Consumes: []string{"region"}}},
+		{ID: "report", Context: TaskContext{Consumes: []string{"format"}}},
+	}}
+	undeclared := &ExecutionPlan{Tasks: []ExecutionTask{{ID: "gather"}, {ID: "report"}}}
+
+	tests := []struct {
+		name     string
+		plan     *ExecutionPlan
+		diff     ContextDiff
+		strategy ReplanStrategy
+		affected []string
+		reason   string
+	}{
+		{name: "unchanged", plan: declared, strategy: ReplanReuse, reason: "unchanged"},
+		{name: "references only", plan: undeclared, diff: ContextDiff{ReferencesChanged: []string{"dataset"}}, strategy: ReplanReuse, reason: "dataset"},
+		{name: "consumed by one task", plan: declared, diff: ContextDiff{Changed: []string{"format"}}, strategy: ReplanPartial, affected: []string{"report"}},
+		{name: "added key consumed", plan: declared, diff: ContextDiff{Added: []string{"region"}}, strategy: ReplanPartial, affected: []string{"gather"}},
+		{name: "consumed by every task", plan: declared, diff: ContextDiff{Changed: []string{"format", "region"}}, strategy: ReplanFull},
+		{name: "consumed by no task", plan: declared, diff: ContextDiff{Changed: []string{"owner"}}, strategy: ReplanReuse, reason: "owner"},
+		{name: "removed key consumed", plan: declared, diff: ContextDiff{Removed: []string{"region"}}, strategy: ReplanFull, reason: `"region" is consumed by task gather`},
+		{name: "removed key not consumed", plan: declared, diff: ContextDiff{Removed: []string{"owner"}}, strategy: ReplanReuse},
+		{name: "undeclared inputs", plan: undeclared, diff: ContextDiff{Changed: []string{"format"}}, strategy: ReplanFull, reason: "does not declare"},
+		{name: "removed key with undeclared inputs", plan: undeclared, diff: ContextDiff{Removed: []string{"region"}}, strategy: ReplanFull},
+	}
+
+	for _, tt := range tests {
+		strategy, reason, affected := chooseReplanStrategy(tt.plan, &tt.diff)
+		if strategy != tt.strategy {
+			t.Errorf("%s: expected %s, got %s (%s)", tt.name, tt.strategy, strategy, reason)
+		}
+		if !reflect.DeepEqual(affected, tt.affected) {
+			t.Errorf("%s: expected affected tasks %v, got %v", tt.name, tt.affected, affected)
+		}
+		if !strings.Contains(reason, tt.reason) {
+			t.Errorf("%s: expected the reason to mention %q, got %q", tt.name, tt.reason, reason)
+		}
+	}
+}
+
+func TestMergePartialPlan(t *testing.T) {
+	previous := &ExecutionPlan{
+		ID:       "old",
+		MethodID: "m1",
+		Tasks: []ExecutionTask{
+			{ID: "a", MethodStepIndex: 0, Description: "old a", Context: TaskContext{OutputRef: "out_a"}, EstimatedTokens: 10},
+			{ID: "b", MethodStepIndex: 1, Description: "old b", Context: TaskContext{OutputRef: "out_b"}, EstimatedTokens: 10},
+			{ID: "c", MethodStepIndex: 2, Description: "old c", EstimatedTokens: 10},
+		},
+		Dependencies: []TaskDependency{{TaskID: "b", DependsOnTaskID: "a"}, {TaskID: "c", DependsOnTaskID: "b"}},
+	}
+	fresh := &ExecutionPlan{
+		ID:       "new",
+		MethodID: "m1",
+		Tasks: []ExecutionTask{
+			{ID: "x", MethodStepIndex: 0, Description: "new a", Context: TaskContext{OutputRef: "out_a"}, EstimatedTokens: 20},
+			{ID: "y", MethodStepIndex: 1, Description: "new b", Context: TaskContext{OutputRef: "out_b2"}, EstimatedTokens: 20},
+			{ID: "z", MethodStepIndex: 2, Description: "new c", EstimatedTokens: 20},
+		},
+	}
+
+	// Re-planning a keeps b and c, since a still writes to the same place
+	merged, replaced, err := mergePartialPlan(previous, fresh, []string{"a"})
+	if err != nil {
+		t.Fatalf("mergePartialPlan failed: %v", err)
+	}
+	if !reflect.DeepEqual(replaced, []string{"a"}) || merged.Tasks[0].ID != "a" || merged.Tasks[0].Description != "new a" {
+		t.Errorf("Expected only a to be replaced under its old ID, got %v", replaced)
+	}
+	if merged.Tasks[1].Description != "old b" || merged.TotalEstimatedTokens != 40 || merged.ID != "new" {
+		t.Errorf("Unexpected merged plan %+v", merged)
+	}
+
+	// b now writes elsewhere, so c, which depends on it, is re-planned too
+	_, replaced, _ = mergePartialPlan(previous, fresh, []string{"b"})
+	if !reflect.DeepEqual(replaced, []string{"b", "c"}) {
+		t.Errorf("Expected b and its dependent c to be replaced, got %v", replaced)
+	}
+
+	fresh.MethodID = "m2"
+	if _, _, err := mergePartialPlan(previous, fresh, []string{"a"}); err == nil {
+		t.Error("Expected an error when the planner chose another method")
+	}
+}
+
+func TestContemplativeCursor_PlanExecution(t *testing.T) {
+	store := createTestStore(t)
+	ctx := context.Background()
+	reasoner := declaringReasoner{NewMockLLMReasoner()}
+	cc := NewContemplativeCursor(store, reasoner)
+	rtc := NewRealTimeCursor(store, NewMockTaskExecutor(), NewMockContextLoader())
+	om := NewObjectiveManager(store)
+
+	goal := createTestGoal(t, store)
+	method := createTestMethod(t, store)
+	objective, err := om.CreateObjective(ctx, goal.ID, method.ID, "Regional report", "",
+		map[string]interface{}{"region": "north", "format": "pdf"}, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+
+	run := func(want ReplanStrategy) *ExecutionPlan {
+		t.Helper()
+		plan, err := cc.PlanExecution(ctx, objective.ID)
+		if err != nil {
+			t.Fatalf("PlanExecution failed: %v", err)
+		}
+		if plan.Replan == nil || plan.Replan.Strategy != want {
+			t.Fatalf("Expected strategy %s, got %+v", want, plan.Replan)
+		}
+		if _, err := rtc.ExecutePlan(ctx, plan); err != nil {
+			t.Fatalf("ExecutePlan failed: %v", err)
+		}
+		return plan
+	}
+	setContext := func(context map[string]interface{}) {
+		t.Helper()
+		if _, err := om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{Context: context}); err != nil {
+			t.Fatalf("Failed to update context: %v", err)
+		}
+	}
+
+	first := run(ReplanFull)
+	if first.Replan.Diff != nil {
+		t.Error("Expected no diff without a previous execution")
+	}
+
+	// Nothing changed, so the plan is run again without asking the planner
+	decomposeCalls := len(reasoner.decomposePlanCalls)
+	second := run(ReplanReuse)
+	if len(reasoner.decomposePlanCalls) != decomposeCalls || second.ID == first.ID || len(second.Tasks) != len(first.Tasks) {
+		t.Errorf("Expected the previous plan to be reissued without planning")
+	}
+
+	// Only the report consumes the format
+	setContext(map[string]interface{}{"region": "north", "format": "html"})
+	third := run(ReplanPartial)
+	if !reflect.DeepEqual(third.Replan.AffectedTasks, []string{"task_2"}) {
+		t.Errorf("Expected only task_2 to be re-planned, got %v", third.Replan.AffectedTasks)
+	}
+	if !reflect.DeepEqual(third.Replan.Diff.Changed, []string{"format"}) {
+		t.Errorf("Expected the format to be reported as changed, got %+v", third.Replan.Diff)
+	}
+
+	// The decision and diff are recorded on the execution result
+	last, err := cc.replanAdvisor.LastExecution(ctx, objective.ID)
+	if err != nil || last == nil {
+		t.Fatalf("Expected a last execution, got %v", err)
+	}
+	if last.Plan.ID != third.ID || last.Fingerprint == nil {
+		t.Fatalf("Expected the last execution to hold the partial plan and its fingerprint")
+	}
+	node, _ := store.GetNode(ctx, last.ResultID)
+	stored, _ := executionResultFromNode(node)
+	if stored.Replan == nil || stored.Replan.Strategy != ReplanPartial || !reflect.DeepEqual(stored.Replan.Diff.Changed, []string{"format"}) {
+		t.Errorf("Expected the partial decision on the stored result, got %+v", stored.Replan)
+	}
+	if len(stored.Plan.Tasks) != 2 || !reflect.DeepEqual(stored.Plan.Tasks[1].Context.Consumes, []string{"format"}) {
+		t.Errorf("Expected the stored plan to keep what its tasks consume, got %+v", stored.Plan.Tasks)
+	}
+
+	// A removed key that a task consumes must never be run with the old plan
+	setContext(map[string]interface{}{"format": "html"})
+	decision, err := cc.replanAdvisor.Preview(ctx, objective.ID)
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if decision.Strategy != ReplanFull || !strings.Contains(decision.Reason, `"region"`) ||
+		!reflect.DeepEqual(decision.Diff.Removed, []string{"region"}) {
+		t.Errorf("Expected a full re-plan for the removed region, got %s: %s", decision.Strategy, decision.Reason)
+	}
+	run(ReplanFull)
+}
@@ -0,0 +1,702 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+const (
+	// rollupNodeType is the storage node type used for persisted rollups
+	rollupNodeType = "rollup"
+
+	// rollupIndexNodeID identifies the node recording the last consistent flush
+	rollupIndexNodeID = "rollup_index"
+
+	// rollupRebuildAttempts bounds how often a rebuild retries while the store is changing
+	rollupRebuildAttempts = 3
+)
+
+// Rollup keys. Keys ending in ":" are prefixes completed with a goal ID or date.
+const (
+	RollupKeyNodeCounts        = "node_counts"
+	RollupKeyGoalStatus        = "goal_status"
+	RollupKeyObjectiveStatus   = "objective_status"
+	RollupKeyPendingDecisions  = "pending_decisions"
+	RollupKeyGoalObjectives    = "goal_objectives:"
+	RollupKeyDailyCompletions  = "completions:"
+	RollupKeyDailyUsage        = "usage:"
+	RollupKeyDailyEvalFailures = "evaluation_failures:"
+)
+
+// Rollup count fields and formats.
+const (
+	rollupDateLayout      = "2006-01-02"
+	rollupFieldCount      = "count"
+	rollupFieldExecutions = "executions"
+	rollupFieldTokens     = "tokens"
+	rollupFieldCost       = "cost"
+)
+
+// Rollup is a small materialized summary of the store, keyed by what it summarizes.
+// Counts are kept as float64 so the same structure can hold counts and spend.
+type Rollup struct {
+	Key       string
+	Counts    map[string]float64
+	Sequence  uint64 // Store sequence of the last change applied to this rollup
+	UpdatedAt time.Time
+}
+
+// DailyUsage summarizes execution usage recorded on a single day.
+type DailyUsage struct {
+	Date       string
+	Executions int
+	TokensUsed int
+	Cost       float64
+}
+
+// RollupManager maintains rollups incrementally from store change events so that
+// dashboards and status commands can read aggregates without scanning nodes.
+//
+// Readers always get correct numbers: whenever the manager has not been
+// initialized, a rollup is missing, or a gap in the event sequence has been
+// detected, reads fall back to recomputing from the store. RebuildRollups
+// restores the fast path.
+type RollupManager struct {
+	store       *storage.Store
+	mu          sync.RWMutex
+	rollups     map[string]*Rollup
+	dirty       map[string]bool
+	sequence    uint64 // Last store sequence observed
+	ready       bool   // Rollups have been built or loaded
+	stale       bool   // An event gap was detected since the last rebuild
+	unsubscribe func()
+}
+
+// NewRollupManager creates a rollup manager subscribed to store changes.
+// Reads fall back to full recomputation until Initialize or RebuildRollups is called.
+func NewRollupManager(store *storage.Store) *RollupManager {
+	rm := &RollupManager{
+		store:   store,
+		rollups: make(map[string]*Rollup),
+		dirty:   make(map[string]bool),
+	}
+	rm.unsubscribe = store.Subscribe(rm.handleChange)
+	return rm
+}
+
+// Initialize loads the persisted rollups if they are consistent with the store,
+// and rebuilds them from scratch otherwise.
+func (rm *RollupManager) Initialize(ctx context.Context) error {
+	loaded, err := rm.loadSnapshot(ctx)
+	if err != nil {
+		fmt.Printf("Warning: failed to load persisted rollups: %v\n", err)
+	}
+	if loaded {
+		return nil
+	}
+	return rm.RebuildRollups(ctx)
+}
+
+// Close persists pending rollup changes and stops listening for store changes.
+func (rm *RollupManager) Close(ctx context.Context) error {
+	if rm.unsubscribe != nil {
+		rm.unsubscribe()
+		rm.unsubscribe = nil
+	}
+	return rm.Flush(ctx)
+}
+
+// IsFresh reports whether reads are currently served from the maintained rollups.
+func (rm *RollupManager) IsFresh() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.ready && !rm.stale
+}
+
+// RebuildRollups recomputes every rollup from the current store contents and
+// persists the result. It is the recovery path after missed events.
+func (rm *RollupManager) RebuildRollups(ctx context.Context) error {
+	persistedKeys, err := rm.persistedRollupKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list persisted rollups: %w", err)
+	}
+
+	for attempt := 0; attempt < rollupRebuildAttempts; attempt++ {
+		seq := rm.store.Sequence()
+
+		rollups, err := computeRollups(ctx, rm.store, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compute rollups: %w", err)
+		}
+
+		rm.mu.Lock()
+		// Only install the result if nothing changed while computing it
+		if rm.store.Sequence() != seq {
+			rm.mu.Unlock()
+			continue
+		}
+
+		now := time.Now()
+		// Keep previously known keys so their persisted values are zeroed out
+		for key := range rm.rollups {
+			persistedKeys[key] = true
+		}
+		for key := range persistedKeys {
+			if _, exists := rollups[key]; !exists {
+				rollups[key] = &Rollup{Key: key, Counts: make(map[string]float64)}
+			}
+		}
+
+		rm.dirty = make(map[string]bool)
+		for key, rollup := range rollups {
+			rollup.Sequence = seq
+			rollup.UpdatedAt = now
+			rm.dirty[key] = true
+		}
+
+		rm.rollups = rollups
+		rm.sequence = seq
+		rm.ready = true
+		rm.stale = false
+		rm.mu.Unlock()
+
+		return rm.Flush(ctx)
+	}
+
+	return fmt.Errorf("store kept changing during rollup rebuild after %d attempts", rollupRebuildAttempts)
+}
+
+// Flush persists rollups changed since the last flush as rollup nodes.
+// Nothing is written while the rollups are stale, or to a read-only store
+// such as a replica, whose rollups are kept in memory only.
+func (rm *RollupManager) Flush(ctx context.Context) error {
+	rm.mu.Lock()
+	if !rm.ready || rm.stale {
+		rm.mu.Unlock()
+		return nil
+	}
+	if rm.store.IsReadOnly() {
+		rm.dirty = make(map[string]bool)
+		rm.mu.Unlock()
+		return nil
+	}
+
+	pending := make([]*Rollup, 0, len(rm.dirty))
+	for key := range rm.dirty {
+		if rollup, exists := rm.rollups[key]; exists {
+			pending = append(pending, cloneRollup(rollup))
+		}
+	}
+	rm.dirty = make(map[string]bool)
+	total := len(rm.rollups)
+	rm.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Key < pending[j].Key
+	})
+
+	// Writes happen without holding the lock; the resulting change events
+	// are rollup nodes, which handleChange only uses for sequence tracking.
+	for i, rollup := range pending {
+		node := storage.NewNodeWithID(rollupNodeID(rollup.Key), rollupNodeType, rollupToNodeData(rollup))
+		if err := rm.store.AddNode(ctx, node); err != nil {
+			rm.mu.Lock()
+			for _, unsaved := range pending[i:] {
+				rm.dirty[unsaved.Key] = true
+			}
+			rm.mu.Unlock()
+			return fmt.Errorf("failed to persist rollup %s: %w", rollup.Key, err)
+		}
+	}
+
+	if len(pending) == 0 && rm.indexMatchesStore(ctx) {
+		return nil
+	}
+
+	// The index records the sequence the store will be at once it is written,
+	// which lets Initialize trust the snapshot only if nothing changed since.
+	index := storage.NewNodeWithID(rollupIndexNodeID, rollupNodeType, map[string]interface{}{
+		"key":          "_index",
+		"sequence":     rm.store.Sequence() + 1,
+		"rollup_count": total,
+		"flushed_at":   time.Now().Format(time.RFC3339),
+	})
+	if err := rm.store.AddNode(ctx, index); err != nil {
+		return fmt.Errorf("failed to persist rollup index: %w", err)
+	}
+
+	return nil
+}
+
+// NodeCounts returns the number of current nodes of each type.
+func (rm *RollupManager) NodeCounts(ctx context.Context) (map[string]int, error) {
+	counts, err := rm.counts(ctx, RollupKeyNodeCounts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int, len(counts))
+	for nodeType, count := range counts {
+		result[nodeType] = int(count)
+	}
+	return result, nil
+}
+
+// GoalStatusCounts returns the number of goals in each status.
+func (rm *RollupManager) GoalStatusCounts(ctx context.Context) (map[GoalStatus]int, error) {
+	counts, err := rm.counts(ctx, RollupKeyGoalStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[GoalStatus]int, len(counts))
+	for status, count := range counts {
+		result[GoalStatus(status)] = int(count)
+	}
+	return result, nil
+}
+
+// ObjectiveStatusCounts returns the number of objectives in each status across all goals.
+func (rm *RollupManager) ObjectiveStatusCounts(ctx context.Context) (map[ObjectiveStatus]int, error) {
+	return rm.objectiveCounts(ctx, RollupKeyObjectiveStatus)
+}
+
+// ObjectiveCountsForGoal returns the number of a goal's objectives in each status.
+func (rm *RollupManager) ObjectiveCountsForGoal(ctx context.Context, goalID string) (map[ObjectiveStatus]int, error) {
+	if goalID == "" {
+		return nil, fmt.Errorf("goal ID cannot be empty")
+	}
+	return rm.objectiveCounts(ctx, RollupKeyGoalObjectives+goalID)
+}
+
+// CompletionsOnDay returns the number of objectives completed on the given day.
+func (rm *RollupManager) CompletionsOnDay(ctx context.Context, day time.Time) (int, error) {
+	counts, err := rm.counts(ctx, RollupKeyDailyCompletions+rollupDate(day))
+	if err != nil {
+		return 0, err
+	}
+	return int(counts[rollupFieldCount]), nil
+}
+
+// UsageOnDay returns execution usage recorded on the given day.
+func (rm *RollupManager) UsageOnDay(ctx context.Context, day time.Time) (*DailyUsage, error) {
+	date := rollupDate(day)
+	counts, err := rm.counts(ctx, RollupKeyDailyUsage+date)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DailyUsage{
+		Date:       date,
+		Executions: int(counts[rollupFieldExecutions]),
+		TokensUsed: int(counts[rollupFieldTokens]),
+		Cost:       counts[rollupFieldCost],
+	}, nil
+}
+
+// PendingDecisionCount returns the number of ethical decisions awaiting approval.
+func (rm *RollupManager) PendingDecisionCount(ctx context.Context) (int, error) {
+	counts, err := rm.counts(ctx, RollupKeyPendingDecisions)
+	if err != nil {
+		return 0, err
+	}
+	return int(counts[rollupFieldCount]), nil
+}
+
+// EvaluationFailuresOnDay returns the ethical evaluation failures recorded on
+// the given day, per cause.
+func (rm *RollupManager) EvaluationFailuresOnDay(ctx context.Context, day time.Time) (map[EvaluationFailureCause]int, error) {
+	counts, err := rm.counts(ctx, RollupKeyDailyEvalFailures+rollupDate(day))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[EvaluationFailureCause]int, len(counts))
+	for cause, count := range counts {
+		result[EvaluationFailureCause(cause)] = int(count)
+	}
+	return result, nil
+}
+
+// GetRollup returns a copy of the maintained rollup for a key, if it is fresh.
+func (rm *RollupManager) GetRollup(key string) (*Rollup, bool) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	if !rm.ready || rm.stale {
+		return nil, false
+	}
+	rollup, exists := rm.rollups[key]
+	if !exists {
+		return nil, false
+	}
+	return cloneRollup(rollup), true
+}
+
+// objectiveCounts converts a status-keyed rollup into objective status counts.
+func (rm *RollupManager) objectiveCounts(ctx context.Context, key string) (map[ObjectiveStatus]int, error) {
+	counts, err := rm.counts(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[ObjectiveStatus]int, len(counts))
+	for status, count := range counts {
+		result[ObjectiveStatus(status)] = int(count)
+	}
+	return result, nil
+}
+
+// counts returns the counts for a rollup key, from the maintained rollup when
+// it is fresh and by recomputing from the store otherwise.
+func (rm *RollupManager) counts(ctx context.Context, key string) (map[string]float64, error) {
+	rm.mu.RLock()
+	if rm.ready && !rm.stale {
+		if rollup, exists := rm.rollups[key]; exists {
+			counts := copyCounts(rollup.Counts)
+			rm.mu.RUnlock()
+			return counts, nil
+		}
+	}
+	rm.mu.RUnlock()
+
+	rollups, err := computeRollups(ctx, rm.store, rollupSourceTypes(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to recompute rollup %s: %w", key, err)
+	}
+
+	if rollup, exists := rollups[key]; exists {
+		return rollup.Counts, nil
+	}
+	return make(map[string]float64), nil
+}
+
+// handleChange applies a store change event to the maintained rollups.
+func (rm *RollupManager) handleChange(event storage.ChangeEvent) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	// Any gap or reordering means some change may have been missed, and a
+	// reloaded store may have changed entirely
+	if event.Sequence != rm.sequence+1 || event.Kind == storage.ChangeStoreReloaded {
+		rm.stale = true
+	}
+	if event.Sequence > rm.sequence {
+		rm.sequence = event.Sequence
+	}
+
+	if !rm.ready || rm.stale || event.Node == nil || event.Node.Type == rollupNodeType {
+		return
+	}
+
+	// A failure while applying must never leave wrong numbers behind
+	defer func() {
+		if r := recover(); r != nil {
+			rm.stale = true
+			fmt.Printf("Warning: rollup update failed, falling back to recomputation: %v\n", r)
+		}
+	}()
+
+	if event.PreviousNode != nil {
+		rm.applyContributions(event.PreviousNode, -1, event)
+	}
+	rm.applyContributions(event.Node, 1, event)
+}
+
+// applyContributions adds (sign 1) or removes (sign -1) a node version's
+// contributions to the rollups. Callers must hold rm.mu.
+func (rm *RollupManager) applyContributions(node *storage.Node, sign float64, event storage.ChangeEvent) {
+	for key, fields := range rollupContributions(node) {
+		rollup, exists := rm.rollups[key]
+		if !exists {
+			rollup = &Rollup{Key: key, Counts: make(map[string]float64)}
+			rm.rollups[key] = rollup
+		}
+
+		for field, value := range fields {
+			rollup.Counts[field] += sign * value
+			if rollup.Counts[field] == 0 {
+				delete(rollup.Counts, field)
+			}
+		}
+
+		rollup.Sequence = event.Sequence
+		rollup.UpdatedAt = event.Timestamp
+		rm.dirty[key] = true
+	}
+}
+
+// loadSnapshot installs the persisted rollups if the index shows the store
+// has not changed since they were flushed.
+func (rm *RollupManager) loadSnapshot(ctx context.Context) (bool, error) {
+	if !rm.indexMatchesStore(ctx) {
+		return false, nil
+	}
+
+	nodes, err := rm.store.GetNodesByType(ctx, rollupNodeType)
+	if err != nil {
+		return false, fmt.Errorf("failed to load rollup nodes: %w", err)
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	seq := rm.store.Sequence()
+	index, err := rm.store.GetNode(ctx, rollupIndexNodeID)
+	if err != nil || uint64(getFloat64(index.Data, "sequence")) != seq {
+		return false, nil
+	}
+
+	rollups := make(map[string]*Rollup)
+	for _, node := range nodes {
+		if node.ID == rollupIndexNodeID {
+			continue
+		}
+		rollup := nodeToRollup(node)
+		if rollup.Key == "" {
+			return false, fmt.Errorf("rollup node %s has no key", node.ID)
+		}
+		rollups[rollup.Key] = rollup
+	}
+
+	if len(rollups) != int(getFloat64(index.Data, "rollup_count")) {
+		return false, nil
+	}
+
+	rm.rollups = rollups
+	rm.dirty = make(map[string]bool)
+	rm.sequence = seq
+	rm.ready = true
+	rm.stale = false
+
+	return true, nil
+}
+
+// indexMatchesStore reports whether the persisted rollup index is current.
+func (rm *RollupManager) indexMatchesStore(ctx context.Context) bool {
+	index, err := rm.store.GetNode(ctx, rollupIndexNodeID)
+	if err != nil {
+		return false
+	}
+	return uint64(getFloat64(index.Data, "sequence")) == rm.store.Sequence()
+}
+
+// persistedRollupKeys returns the keys of all persisted rollup nodes.
+func (rm *RollupManager) persistedRollupKeys(ctx context.Context) (map[string]bool, error) {
+	nodes, err := rm.store.GetNodesByType(ctx, rollupNodeType)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for _, node := range nodes {
+		if node.ID == rollupIndexNodeID {
+			continue
+		}
+		if key := getString(node.Data, "key"); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
+
+// computeRollups builds rollups from scratch by scanning current nodes.
+// If nodeTypes is nil, every node type is scanned.
+func computeRollups(ctx context.Context, store *storage.Store, nodeTypes []string) (map[string]*Rollup, error) {
+	if nodeTypes == nil {
+		allTypes, err := store.GetNodeTypes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		nodeTypes = allTypes
+	}
+
+	rollups := make(map[string]*Rollup)
+	for _, nodeType := range nodeTypes {
+		if nodeType == rollupNodeType {
+			continue
+		}
+
+		// Archived nodes still count; they are only moved out of the live store
+		nodes, err := store.Nodes().OfType(nodeType).IncludeArchived().AllContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range nodes {
+			for key, fields := range rollupContributions(node) {
+				rollup, exists := rollups[key]
+				if !exists {
+					rollup = &Rollup{Key: key, Counts: make(map[string]float64)}
+					rollups[key] = rollup
+				}
+				for field, value := range fields {
+					rollup.Counts[field] += value
+				}
+			}
+		}
+	}
+
+	// Drop zero entries so recomputed and incremental rollups compare equal
+	for _, rollup := range rollups {
+		for field, value := range rollup.Counts {
+			if value == 0 {
+				delete(rollup.Counts, field)
+			}
+		}
+	}
+
+	return rollups, nil
+}
+
+// rollupSourceTypes returns the node types that feed a rollup key.
+// A nil result means every node type contributes.
+func rollupSourceTypes(key string) []string {
+	switch {
+	case key == RollupKeyNodeCounts:
+		return nil
+	case key == RollupKeyGoalStatus:
+		return []string{"goal"}
+	case key == RollupKeyObjectiveStatus,
+		strings.HasPrefix(key, RollupKeyGoalObjectives),
+		strings.HasPrefix(key, RollupKeyDailyCompletions):
+		return []string{"objective"}
+	case strings.HasPrefix(key, RollupKeyDailyUsage):
+		return []string{"execution_result"}
+	case key == RollupKeyPendingDecisions:
+		return []string{"ethical_decision"}
+	case strings.HasPrefix(key, RollupKeyDailyEvalFailures):
+		return []string{evaluationFailureNodeType}
+	default:
+		return nil
+	}
+}
+
+// rollupContributions returns what a single node version adds to each rollup.
+// Incremental maintenance and full recomputation both use this function,
+// so the two paths cannot disagree about what is being counted.
+func rollupContributions(node *storage.Node) map[string]map[string]float64 {
+	if node == nil || node.Type == rollupNodeType {
+		return nil
+	}
+
+	contributions := map[string]map[string]float64{
+		RollupKeyNodeCounts: {node.Type: 1},
+	}
+
+	switch node.Type {
+	case "goal":
+		if status := getString(node.Data, "status"); status != "" {
+			contributions[RollupKeyGoalStatus] = map[string]float64{status: 1}
+		}
+
+	case "objective":
+		status := getString(node.Data, "status")
+		if status == "" {
+			break
+		}
+		contributions[RollupKeyObjectiveStatus] = map[string]float64{status: 1}
+		if goalID := getString(node.Data, "goal_id"); goalID != "" {
+			contributions[RollupKeyGoalObjectives+goalID] = map[string]float64{status: 1}
+		}
+		if status == string(ObjectiveStatusCompleted) {
+			if completedAt := parseOptionalTime(node.Data["completed_at"]); completedAt != nil {
+				contributions[RollupKeyDailyCompletions+rollupDate(*completedAt)] = map[string]float64{rollupFieldCount: 1}
+			}
+		}
+
+	case "execution_result":
+		day := node.CreatedAt
+		if endTime, err := time.Parse(time.RFC3339, getString(node.Data, "end_time")); err == nil && !endTime.IsZero() {
+			day = endTime
+		}
+		contributions[RollupKeyDailyUsage+rollupDate(day)] = map[string]float64{
+			rollupFieldExecutions: 1,
+			rollupFieldTokens:     getFloat64(node.Data, "total_tokens_used"),
+			rollupFieldCost:       getFloat64(node.Data, "total_cost"),
+		}
+
+	case "ethical_decision":
+		if getString(node.Data, "approval_status") == string(DecisionApprovalPending) {
+			contributions[RollupKeyPendingDecisions] = map[string]float64{rollupFieldCount: 1}
+		}
+
+	case evaluationFailureNodeType:
+		if cause := getString(node.Data, "cause"); cause != "" {
+			contributions[RollupKeyDailyEvalFailures+rollupDate(node.CreatedAt)] = map[string]float64{cause: 1}
+		}
+	}
+
+	return contributions
+}
+
+// rollupDate formats the local calendar day used in dated rollup keys.
+func rollupDate(t time.Time) string {
+	return t.Local().Format(rollupDateLayout)
+}
+
+// rollupNodeID returns a filesystem-safe node ID for a rollup key.
+func rollupNodeID(key string) string {
+	return "rollup_" + strings.ReplaceAll(key, ":", "_")
+}
+
+// rollupToNodeData converts a rollup to storage node data.
+func rollupToNodeData(rollup *Rollup) map[string]interface{} {
+	counts := make(map[string]interface{}, len(rollup.Counts))
+	for field, value := range rollup.Counts {
+		counts[field] = value
+	}
+
+	return map[string]interface{}{
+		"key":        rollup.Key,
+		"counts":     counts,
+		"sequence":   rollup.Sequence,
+		"updated_at": rollup.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// nodeToRollup converts a persisted rollup node back into a Rollup.
+func nodeToRollup(node *storage.Node) *Rollup {
+	rollup := &Rollup{
+		Key:      getString(node.Data, "key"),
+		Counts:   make(map[string]float64),
+		Sequence: uint64(getFloat64(node.Data, "sequence")),
+	}
+
+	if counts, ok := node.Data["counts"].(map[string]interface{}); ok {
+		for field := range counts {
+			rollup.Counts[field] = getFloat64(counts, field)
+		}
+	}
+
+	if updatedAt, err := time.Parse(time.RFC3339, getString(node.Data, "updated_at")); err == nil {
+		rollup.UpdatedAt = updatedAt
+	}
+
+	return rollup
+}
+
+// cloneRollup returns a deep copy of a rollup.
+func cloneRollup(rollup *Rollup) *Rollup {
+	return &Rollup{
+		Key:       rollup.Key,
+		Counts:    copyCounts(rollup.Counts),
+		Sequence:  rollup.Sequence,
+		UpdatedAt: rollup.UpdatedAt,
+	}
+}
+
+// copyCounts returns a copy of a counts map.
+func copyCounts(counts map[string]float64) map[string]float64 {
+	result := make(map[string]float64, len(counts))
+	for field, value := range counts {
+		result[field] = value
+	}
+	return result
+}
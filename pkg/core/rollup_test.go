@@ -0,0 +1,329 @@
+package core
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// expectedRollupCounts recomputes the dashboard numbers through the managers.
+type expectedRollupCounts struct {
+	goalStatus      map[GoalStatus]int
+	objectiveStatus map[ObjectiveStatus]int
+	perGoal         map[string]map[ObjectiveStatus]int
+	completedToday  int
+}
+
+func computeExpectedCounts(t *testing.T, gm *GoalManager, om *ObjectiveManager) expectedRollupCounts {
+	ctx := context.Background()
+
+	goals, err := gm.ListGoals(ctx, GoalFilter{IncludeArchived: true})
+	if err != nil {
+		t.Fatalf("Failed to list goals: %v", err)
+	}
+	objectives, err := om.ListObjectives(ctx, ObjectiveFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list objectives: %v", err)
+	}
+
+	expected := expectedRollupCounts{
+		goalStatus:      make(map[GoalStatus]int),
+		objectiveStatus: make(map[ObjectiveStatus]int),
+		perGoal:         make(map[string]map[ObjectiveStatus]int),
+	}
+	today := rollupDate(time.Now())
+	for _, goal := range goals {
+		expected.goalStatus[goal.Status]++
+		expected.perGoal[goal.ID] = make(map[ObjectiveStatus]int)
+	}
+	for _, objective := range objectives {
+		expected.objectiveStatus[objective.Status]++
+		expected.perGoal[objective.GoalID][objective.Status]++
+		if objective.Status == ObjectiveStatusCompleted && objective.CompletedAt != nil &&
+			rollupDate(*objective.CompletedAt) == today {
+			expected.completedToday++
+		}
+	}
+	return expected
+}
+
+func assertRollupsMatch(t *testing.T, step int, rm *RollupManager, expected expectedRollupCounts) {
+	ctx := context.Background()
+
+	goalCounts, err := rm.GoalStatusCounts(ctx)
+	if err != nil {
+		t.Fatalf("Step %d: failed to get goal counts: %v", step, err)
+	}
+	if !equalStatusCounts(goalCounts, expected.goalStatus) {
+		t.Fatalf("Step %d: goal counts %v, expected %v", step, goalCounts, expected.goalStatus)
+	}
+
+	objectiveCounts, err := rm.ObjectiveStatusCounts(ctx)
+	if err != nil {
+		t.Fatalf("Step %d: failed to get objective counts: %v", step, err)
+	}
+	if !equalStatusCounts(objectiveCounts, expected.objectiveStatus) {
+		t.Fatalf("Step %d: objective counts %v, expected %v", step, objectiveCounts, expected.objectiveStatus)
+	}
+
+	for goalID, want := range expected.perGoal {
+		got, err := rm.ObjectiveCountsForGoal(ctx, goalID)
+		if err != nil {
+			t.Fatalf("Step %d: failed to get counts for goal %s: %v", step, goalID, err)
+		}
+		if !equalStatusCounts(got, want) {
+			t.Fatalf("Step %d: goal %s counts %v, expected %v", step, goalID, got, want)
+		}
+	}
+
+	completed, err := rm.CompletionsOnDay(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("Step %d: failed to get completions: %v", step, err)
+	}
+	if completed != expected.completedToday {
+		t.Fatalf("Step %d: completions %d, expected %d", step, completed, expected.completedToday)
+	}
+}
+
+// addRollupTestMethod stores a minimal method node for objectives to reference.
+func addRollupTestMethod(t *testing.T, store *storage.Store) string {
+	method := storage.NewNode("method", map[string]interface{}{
+		"name":       "Test method",
+		"domain":     string(MethodDomainGeneral),
+		"status":     string(MethodStatusActive),
+		"created_at": time.Now().Format(time.RFC3339),
+	})
+	if err := store.AddNode(context.Background(), method); err != nil {
+		t.Fatalf("Failed to add method: %v", err)
+	}
+	return method.ID
+}
+
+func equalStatusCounts[K comparable](got, want map[K]int) bool {
+	for key, count := range want {
+		if got[key] != count {
+			return false
+		}
+	}
+	for key, count := range got {
+		if want[key] != count {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRollupManager_MatchesRecomputationUnderRandomChanges(t *testing.T) {
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+	om := NewObjectiveManager(store)
+	rm := NewRollupManager(store)
+	ctx := context.Background()
+
+	if err := rm.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize rollups: %v", err)
+	}
+
+	methodID := addRollupTestMethod(t, store)
+	rng := rand.New(rand.NewSource(42))
+	goalStatuses := []GoalStatus{GoalStatusActive, GoalStatusPaused, GoalStatusCompleted, GoalStatusArchived}
+	var goalIDs, objectiveIDs []string
+
+	for step := 0; step < 150; step++ {
+		switch op := rng.Intn(5); {
+		case op == 0 || len(goalIDs) == 0:
+			goal, err := gm.CreateGoal(ctx, "Goal", "", rng.Intn(10)+1, nil)
+			if err != nil {
+				t.Fatalf("Step %d: failed to create goal: %v", step, err)
+			}
+			goalIDs = append(goalIDs, goal.ID)
+
+		case op == 1 || len(objectiveIDs) == 0:
+			goalID := goalIDs[rng.Intn(len(goalIDs))]
+			objective, err := om.CreateObjective(ctx, goalID, methodID, "Objective", "", nil, rng.Intn(10)+1)
+			if err != nil {
+				t.Fatalf("Step %d: failed to create objective: %v", step, err)
+			}
+			objectiveIDs = append(objectiveIDs, objective.ID)
+
+		case op == 2:
+			status := goalStatuses[rng.Intn(len(goalStatuses))]
+			if _, err := gm.UpdateGoal(ctx, goalIDs[rng.Intn(len(goalIDs))], GoalUpdates{Status: &status}); err != nil {
+				t.Fatalf("Step %d: failed to update goal: %v", step, err)
+			}
+
+		case op == 3:
+			// Start or complete an objective, whichever is valid
+			objectiveID := objectiveIDs[rng.Intn(len(objectiveIDs))]
+			objective, err := om.GetObjective(ctx, objectiveID)
+			if err != nil {
+				t.Fatalf("Step %d: failed to get objective: %v", step, err)
+			}
+			switch objective.Status {
+			case ObjectiveStatusPending:
+				_, err = om.StartObjective(ctx, objectiveID)
+			case ObjectiveStatusInProgress:
+				_, err = om.CompleteObjective(ctx, objectiveID, ObjectiveResult{Success: rng.Intn(3) > 0})
+			}
+			if err != nil {
+				t.Fatalf("Step %d: failed to advance objective: %v", step, err)
+			}
+
+		default:
+			// Move an objective to another goal
+			goalID := goalIDs[rng.Intn(len(goalIDs))]
+			objectiveID := objectiveIDs[rng.Intn(len(objectiveIDs))]
+			if _, err := om.UpdateObjective(ctx, objectiveID, ObjectiveUpdates{GoalID: &goalID}); err != nil {
+				t.Fatalf("Step %d: failed to move objective: %v", step, err)
+			}
+		}
+
+		if !rm.IsFresh() {
+			t.Fatalf("Step %d: rollups unexpectedly stale", step)
+		}
+		assertRollupsMatch(t, step, rm, computeExpectedCounts(t, gm, om))
+
+		// Periodically persist to make sure flushing does not disturb the counts
+		if step%25 == 0 {
+			if err := rm.Flush(ctx); err != nil {
+				t.Fatalf("Step %d: failed to flush rollups: %v", step, err)
+			}
+		}
+	}
+}
+
+func TestRollupManager_FallsBackAfterMissedEvents(t *testing.T) {
+	store := setupTestStore(t)
+	gm := NewGoalManager(store)
+	rm := NewRollupManager(store)
+	ctx := context.Background()
+
+	if err := rm.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize rollups: %v", err)
+	}
+	if _, err := gm.CreateGoal(ctx, "First", "", 5, nil); err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+
+	// Simulate a lost delivery by feeding an event that skips a sequence number
+	rm.handleChange(storage.ChangeEvent{Sequence: store.Sequence() + 2})
+	if rm.IsFresh() {
+		t.Fatal("Expected rollups to be stale after a sequence gap")
+	}
+
+	if _, err := gm.CreateGoal(ctx, "Second", "", 5, nil); err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+
+	counts, err := rm.GoalStatusCounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get goal counts: %v", err)
+	}
+	if counts[GoalStatusActive] != 2 {
+		t.Errorf("Expected 2 active goals from recomputation, got %d", counts[GoalStatusActive])
+	}
+
+	if err := rm.RebuildRollups(ctx); err != nil {
+		t.Fatalf("Failed to rebuild rollups: %v", err)
+	}
+	if !rm.IsFresh() {
+		t.Error("Expected rollups to be fresh after rebuild")
+	}
+
+	counts, err = rm.GoalStatusCounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get goal counts: %v", err)
+	}
+	if counts[GoalStatusActive] != 2 {
+		t.Errorf("Expected 2 active goals after rebuild, got %d", counts[GoalStatusActive])
+	}
+}
+
+func TestRollupManager_LoadsPersistedSnapshot(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := storage.NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	gm := NewGoalManager(store)
+	om := NewObjectiveManager(store)
+	rm := NewRollupManager(store)
+	if err := rm.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize rollups: %v", err)
+	}
+
+	goal, err := gm.CreateGoal(ctx, "Goal", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	objective, err := om.CreateObjective(ctx, goal.ID, addRollupTestMethod(t, store), "Objective", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	if _, err := om.StartObjective(ctx, objective.ID); err != nil {
+		t.Fatalf("Failed to start objective: %v", err)
+	}
+	if _, err := om.CompleteObjective(ctx, objective.ID, ObjectiveResult{Success: true}); err != nil {
+		t.Fatalf("Failed to complete objective: %v", err)
+	}
+	usage := storage.NewNode("execution_result", map[string]interface{}{
+		"total_tokens_used": 120,
+		"total_cost":        0.25,
+		"end_time":          time.Now().Format(time.RFC3339),
+	})
+	if err := store.AddNode(ctx, usage); err != nil {
+		t.Fatalf("Failed to add execution result: %v", err)
+	}
+
+	if err := rm.Close(ctx); err != nil {
+		t.Fatalf("Failed to close rollups: %v", err)
+	}
+	store.Close()
+
+	reopened, err := storage.NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	before := reopened.Sequence()
+	loaded := NewRollupManager(reopened)
+	if err := loaded.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize rollups: %v", err)
+	}
+	if reopened.Sequence() != before {
+		t.Errorf("Expected snapshot to load without rebuilding, sequence moved from %d to %d", before, reopened.Sequence())
+	}
+	if !loaded.IsFresh() {
+		t.Fatal("Expected loaded rollups to be fresh")
+	}
+
+	counts, err := loaded.ObjectiveCountsForGoal(ctx, goal.ID)
+	if err != nil {
+		t.Fatalf("Failed to get goal objective counts: %v", err)
+	}
+	if counts[ObjectiveStatusCompleted] != 1 || len(counts) != 1 {
+		t.Errorf("Expected one completed objective, got %v", counts)
+	}
+
+	day, err := loaded.UsageOnDay(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to get usage: %v", err)
+	}
+	if day.Executions != 1 || day.TokensUsed != 120 || day.Cost != 0.25 {
+		t.Errorf("Unexpected usage: %+v", day)
+	}
+
+	nodeCounts, err := loaded.NodeCounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get node counts: %v", err)
+	}
+	if nodeCounts["goal"] != 1 || nodeCounts["objective"] != 1 || nodeCounts["method"] != 1 || nodeCounts[rollupNodeType] != 0 {
+		t.Errorf("Unexpected node counts: %v", nodeCounts)
+	}
+}
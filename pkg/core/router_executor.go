@@ -0,0 +1,282 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/retry"
+)
+
+// ErrTaskNotApproved is returned for a task whose ethical review did not
+// clear it: the user rejected it, or it needed approval nobody could give.
+var ErrTaskNotApproved = errs.New(errs.PolicyBlocked, "task was not approved")
+
+// ApprovalPrompt asks the user whether a decision awaiting approval may go
+// ahead, returning their answer and any feedback to record with it.
+type ApprovalPrompt func(ctx context.Context, decision *EthicalDecision) (approved bool, feedback string, err error)
+
+// RouterTaskExecutor executes plan tasks by routing one completion per task.
+// Tasks with side effects are first put through the ethical framework, when
+// one is set, and wait for the user's approval where the framework asks for it.
+type RouterTaskExecutor struct {
+	router *llm.Router
+
+	ethics  *EthicalFramework
+	userID  string
+	approve ApprovalPrompt
+
+	// tools are the MCP services advertised alongside the LLM
+	tools *mcp.ServiceRegistry
+
+	// reviewed holds the decisions of tasks already cleared, by plan and
+	// task, so a retried task is not reviewed again
+	mu       sync.Mutex
+	reviewed map[string]string
+}
+
+// NewRouterTaskExecutor creates an executor that routes tasks through router.
+func NewRouterTaskExecutor(router *llm.Router) *RouterTaskExecutor {
+	return &RouterTaskExecutor{
+		router:   router,
+		reviewed: make(map[string]string),
+	}
+}
+
+// SetEthicalReview makes the executor evaluate side-effecting tasks with
+// ethics on behalf of userID before running them. Decisions awaiting
+// approval are put to approve; without it they fail with ErrTaskNotApproved.
+func (e *RouterTaskExecutor) SetEthicalReview(ethics *EthicalFramework, userID string, approve ApprovalPrompt) {
+	e.ethics = ethics
+	e.userID = userID
+	e.approve = approve
+}
+
+// SetTools makes the executor advertise the services of registry, such as
+// the web service research tasks use, as tools available to plans.
+func (e *RouterTaskExecutor) SetTools(registry *mcp.ServiceRegistry) {
+	e.tools = registry
+}
+
+// ExecuteTask implements TaskExecutor.
+func (e *RouterTaskExecutor) ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error) {
+	if err := e.review(ctx, task, fullContext); err != nil {
+		return nil, err
+	}
+
+	logContext := utils.LogContextFrom(ctx)
+	request := llm.TaskRequest{
+		Prompt:    taskPrompt(task, fullContext),
+		MaxTokens: task.Context.TokenBudget,
+		TaskType:  task.Type,
+		Metadata: map[string]interface{}{
+			llm.MetadataGoalID:      logContext.GoalID,
+			llm.MetadataObjectiveID: logContext.ObjectiveID,
+			llm.MetadataPlanID:      logContext.PlanID,
+		},
+	}
+	if task.Context.Parameters["format"] == "json" {
+		request.ResponseSchema = mcp.JSONMode()
+	}
+
+	ReportToolUse(ctx, "llm")
+	result, err := e.router.Route(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("task %s failed: %w", task.ID, err)
+	}
+
+	return &TaskResult{
+		TaskID:          task.ID,
+		MethodStepIndex: task.MethodStepIndex,
+		Status:          TaskStatusCompleted,
+		Output:          result.ExecutionResult.Text,
+		TokensUsed:      result.ExecutionResult.TokensUsed,
+		ToolsUsed:       []string{"llm"},
+		RoutingID:       result.ID,
+		Confidence:      1.0,
+	}, nil
+}
+
+// GetAvailableTools implements TaskExecutor: the LLM, and the services set
+// with SetTools.
+func (e *RouterTaskExecutor) GetAvailableTools(ctx context.Context) ([]string, error) {
+	tools := []string{"llm"}
+	if e.tools != nil {
+		tools = append(tools, e.tools.GetServiceNames()...)
+	}
+	return tools, nil
+}
+
+// EstimateTokenUsage implements TaskExecutor, falling back to the task's
+// prompt and token budget when the plan made no estimate.
+func (e *RouterTaskExecutor) EstimateTokenUsage(ctx context.Context, task *ExecutionTask) (int, error) {
+	if task.EstimatedTokens > 0 {
+		return task.EstimatedTokens, nil
+	}
+	return e.router.CountTokens("", taskPrompt(task, nil)) + task.Context.TokenBudget, nil
+}
+
+// review clears a side-effecting task with the ethical framework, asking the
+// user when the decision awaits approval, and marks the decision implemented.
+// Its failures stop the task's retries.
+func (e *RouterTaskExecutor) review(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) error {
+	if e.ethics == nil || !TaskHasSideEffects(task) {
+		return nil
+	}
+
+	key := utils.LogContextFrom(ctx).PlanID + "/" + task.ID
+	e.mu.Lock()
+	_, cleared := e.reviewed[key]
+	e.mu.Unlock()
+	if cleared {
+		return nil
+	}
+
+	objectiveID := utils.LogContextFrom(ctx).ObjectiveID
+	decisionContext := fmt.Sprintf("Task %s (%s) of an objective being executed", task.ID, task.Type)
+	if title, _ := fullContext["objective_title"].(string); title != "" {
+		decisionContext = fmt.Sprintf("Task %s (%s) of the objective %q", task.ID, task.Type, title)
+	}
+	decision, err := e.ethics.EvaluateDecision(ctx, objectiveID, decisionContext, task.Description, nil, e.userID)
+	if err != nil {
+		return retry.Stop(fmt.Errorf("failed to review task %s: %w", task.ID, err))
+	}
+
+	if err := settleDecision(ctx, e.ethics, e.approve, decision, "task "+task.ID); err != nil {
+		return retry.Stop(err)
+	}
+	e.mu.Lock()
+	e.reviewed[key] = decision.ID
+	e.mu.Unlock()
+	return nil
+}
+
+// settleDecision waits on the user's approval of a decision that needs it,
+// refuses one that was rejected, and marks a cleared decision implemented.
+// subject names what the decision is about in its errors.
+func settleDecision(ctx context.Context, ethics *EthicalFramework, approve ApprovalPrompt, decision *EthicalDecision, subject string) error {
+	switch decision.State() {
+	case DecisionStateRejected:
+		return fmt.Errorf("%w: decision %s on %s was rejected", ErrTaskNotApproved, decision.ID, subject)
+
+	case DecisionStateAwaitingApproval:
+		if approve == nil {
+			return fmt.Errorf("%w: %s awaits approval of decision %s", ErrTaskNotApproved, subject, decision.ID)
+		}
+		done := BeginApprovalWait(ctx)
+		approved, feedback, err := approve(ctx, decision)
+		done()
+		if err != nil {
+			return fmt.Errorf("failed to get approval for %s: %w", subject, err)
+		}
+		// A prompt that waits for the user to answer elsewhere, as a work
+		// loop's does, finds the decision already settled
+		if current, err := ethics.GetDecision(ctx, decision.ID); err == nil && current.State() != DecisionStateAwaitingApproval {
+			if current.State() != DecisionStateApproved {
+				return fmt.Errorf("%w: decision %s on %s was %s", ErrTaskNotApproved, decision.ID, subject, current.State())
+			}
+			break
+		}
+		if !approved {
+			if err := ethics.RejectDecision(ctx, decision.ID, feedback); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: decision %s on %s was rejected", ErrTaskNotApproved, decision.ID, subject)
+		}
+		if err := ethics.ApproveDecision(ctx, decision.ID, feedback); err != nil {
+			return err
+		}
+	}
+
+	return ethics.ImplementDecision(ctx, decision.ID)
+}
+
+// NewCommandReviewer returns a reviewer for a shell service that puts each
+// risky command through ethics on behalf of userID, asking approve when the
+// decision awaits approval. Commands not cleared fail with
+// ErrTaskNotApproved.
+func NewCommandReviewer(ethics *EthicalFramework, userID string, approve ApprovalPrompt) mcp.CommandReviewer {
+	return func(ctx context.Context, commandLine, risk string) error {
+		subject := fmt.Sprintf("command %q", commandLine)
+		decisionContext := fmt.Sprintf("Running a shell command that matches the risky pattern %q", risk)
+		decision, err := ethics.EvaluateDecision(ctx, utils.LogContextFrom(ctx).ObjectiveID, decisionContext, "Run "+commandLine, nil, userID)
+		if err != nil {
+			return fmt.Errorf("failed to review %s: %w", subject, err)
+		}
+		return settleDecision(ctx, ethics, approve, decision, subject)
+	}
+}
+
+// taskPrompt writes the prompt a task is executed with: the objective it
+// serves, the task itself, its parameters and inputs, and what the tasks it
+// depends on produced.
+func taskPrompt(task *ExecutionTask, fullContext map[string]interface{}) string {
+	var b strings.Builder
+	b.WriteString("You are carrying out one task of a larger objective. Do only this task and reply with its result.\n")
+
+	if title, _ := fullContext["objective_title"].(string); title != "" {
+		fmt.Fprintf(&b, "\nOBJECTIVE: %s\n", title)
+		if description, _ := fullContext["objective_description"].(string); description != "" {
+			b.WriteString(description + "\n")
+		}
+	}
+	if goal, _ := fullContext["goal_title"].(string); goal != "" {
+		fmt.Fprintf(&b, "GOAL: %s\n", goal)
+	}
+
+	fmt.Fprintf(&b, "\nTASK (%s): %s\n", task.Type, task.Description)
+
+	writeSection := func(title string, values map[string]interface{}) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, key := range sortedKeys(values) {
+			fmt.Fprintf(&b, "- %s: %s\n", key, promptValue(values[key]))
+		}
+	}
+	writeSection("PARAMETERS", task.Context.Parameters)
+	if context, ok := fullContext["objective_context"].(map[string]interface{}); ok {
+		writeSection("CONTEXT", context)
+	}
+	if inputs, ok := fullContext["inputs"].(map[string]interface{}); ok {
+		writeSection("INPUTS", inputs)
+	}
+
+	if outputs, ok := fullContext[DependencyOutputsKey].(map[string]interface{}); ok && len(outputs) > 0 {
+		b.WriteString("\nRESULTS OF EARLIER TASKS:\n")
+		for _, taskID := range sortedKeys(outputs) {
+			fmt.Fprintf(&b, "[%s]\n%s\n", taskID, promptValue(outputs[taskID]))
+		}
+	}
+	return b.String()
+}
+
+// promptValue renders a context value for a prompt.
+func promptValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
+
+// sortedKeys returns the keys of values in order.
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,541 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// planningMaxTokens bounds the replies of planning and refinement requests.
+const planningMaxTokens = 1500
+
+// defaultTaskTokens is the estimate given to planned tasks that make none.
+const defaultTaskTokens = 500
+
+// stringList is the schema of a list of strings.
+var stringList = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+
+// methodStepSchema is the schema of one method step.
+var methodStepSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"description": map[string]interface{}{"type": "string"},
+		"tools":       stringList,
+		"heuristics":  stringList,
+	},
+	"required": []interface{}{"description"},
+}
+
+// objectiveAnalysisSchema is the JSON reply objective analysis asks for.
+var objectiveAnalysisSchema = &mcp.ResponseSchema{
+	Name: "objective_analysis",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"complexity_level":       map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10},
+			"required_capabilities":  stringList,
+			"key_challenges":         stringList,
+			"success_criteria":       stringList,
+			"estimated_token_budget": map[string]interface{}{"type": "integer", "minimum": 0},
+			"recommended_approach":   map[string]interface{}{"type": "string"},
+			"domain_context":         map[string]interface{}{"type": "string"},
+		},
+		"required": []interface{}{"complexity_level", "success_criteria", "recommended_approach"},
+	},
+}
+
+// methodSelectionSchema is the JSON reply method selection asks for.
+var methodSelectionSchema = &mcp.ResponseSchema{
+	Name: "method_selection",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"method_id":              map[string]interface{}{"type": "string"},
+			"confidence":             map[string]interface{}{"type": "number", "minimum": 0.0, "maximum": 1.0},
+			"reason":                 map[string]interface{}{"type": "string"},
+			"adaptations":            stringList,
+			"alternative_method_ids": stringList,
+		},
+		"required": []interface{}{"method_id", "confidence", "reason"},
+	},
+}
+
+// methodDesignSchema is the JSON reply method design asks for.
+var methodDesignSchema = &mcp.ResponseSchema{
+	Name: "method_design",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":        map[string]interface{}{"type": "string"},
+			"description": map[string]interface{}{"type": "string"},
+			"domain":      map[string]interface{}{"type": "string", "enum": []interface{}{string(MethodDomainGeneral), string(MethodDomainSpecific), string(MethodDomainUser)}},
+			"steps":       map[string]interface{}{"type": "array", "items": methodStepSchema},
+		},
+		"required": []interface{}{"name", "description", "steps"},
+	},
+}
+
+// planSchema is the JSON reply plan decomposition asks for.
+var planSchema = &mcp.ResponseSchema{
+	Name: "execution_plan",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title": map[string]interface{}{"type": "string"},
+			"tasks": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"id":               map[string]interface{}{"type": "string"},
+						"type":             map[string]interface{}{"type": "string"},
+						"description":      map[string]interface{}{"type": "string"},
+						"method_step":      map[string]interface{}{"type": "integer"},
+						"estimated_tokens": map[string]interface{}{"type": "integer", "minimum": 0},
+						"side_effects":     map[string]interface{}{"type": "boolean"},
+						"depends_on":       stringList,
+					},
+					"required": []interface{}{"id", "type", "description"},
+				},
+			},
+		},
+		"required": []interface{}{"title", "tasks"},
+	},
+}
+
+// refinementSchema is the JSON reply method refinement asks for.
+var refinementSchema = &mcp.ResponseSchema{
+	Name: "method_refinement",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"type":                         map[string]interface{}{"type": "string", "enum": []interface{}{string(RefinementNone), string(RefinementModify), string(RefinementReplace), string(RefinementRetire)}},
+			"steps":                        map[string]interface{}{"type": "array", "items": methodStepSchema},
+			"reasoning":                    map[string]interface{}{"type": "string"},
+			"expected_success_improvement": map[string]interface{}{"type": "number", "minimum": 0.0, "maximum": 100.0},
+		},
+		"required": []interface{}{"type", "reasoning"},
+	},
+}
+
+// RouterReasoner plans objectives for the contemplative cursor by asking
+// the LLM router for JSON replies.
+type RouterReasoner struct {
+	router *llm.Router
+}
+
+// NewRouterReasoner creates a reasoner that plans through router.
+func NewRouterReasoner(router *llm.Router) *RouterReasoner {
+	return &RouterReasoner{router: router}
+}
+
+// AnalyzeObjective implements LLMReasoner.
+func (r *RouterReasoner) AnalyzeObjective(ctx context.Context, objective *Objective, goalContext *Goal) (*ObjectiveAnalysis, error) {
+	prompt := fmt.Sprintf(`Analyze what it takes to achieve this objective.
+
+GOAL: %s
+%s
+
+OBJECTIVE: %s
+%s
+%s
+Rate its complexity from 1 to 10, list the capabilities it needs, the key challenges, how to tell it is done, the LLM tokens it will take, the approach you recommend and the domain it belongs to.`,
+		goalContext.Title, goalContext.Description, objective.Title, objective.Description, contextLines(objective.Context))
+
+	var reply struct {
+		ComplexityLevel      int      `json:"complexity_level"`
+		RequiredCapabilities []string `json:"required_capabilities"`
+		KeyChallenges        []string `json:"key_challenges"`
+		SuccessCriteria      []string `json:"success_criteria"`
+		EstimatedTokenBudget int      `json:"estimated_token_budget"`
+		RecommendedApproach  string   `json:"recommended_approach"`
+		DomainContext        string   `json:"domain_context"`
+	}
+	if err := routeJSON(ctx, r.router, objective, "objective_analysis", prompt, objectiveAnalysisSchema, &reply); err != nil {
+		return nil, err
+	}
+
+	return &ObjectiveAnalysis{
+		ComplexityLevel:      reply.ComplexityLevel,
+		RequiredCapabilities: reply.RequiredCapabilities,
+		KeyChallenges:        reply.KeyChallenges,
+		SuccessCriteria:      reply.SuccessCriteria,
+		EstimatedTokenBudget: reply.EstimatedTokenBudget,
+		RecommendedApproach:  reply.RecommendedApproach,
+		DomainContext:        reply.DomainContext,
+	}, nil
+}
+
+// SelectMethod implements LLMReasoner. The selected method must be one of
+// methods.
+func (r *RouterReasoner) SelectMethod(ctx context.Context, analysis *ObjectiveAnalysis, methods []*Method) (*MethodSelection, error) {
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no methods to select from")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Choose the method best suited to an objective.\n\nOBJECTIVE ANALYSIS:\n%s\n\nMETHODS:\n", analysisLines(analysis))
+	for _, method := range methods {
+		fmt.Fprintf(&b, "- %s: %s (%s; %.0f%% success over %d runs)\n%s",
+			method.ID, method.Name, method.Description, method.Metrics.SuccessRate(), method.Metrics.ExecutionCount, stepLines(method.Approach, "    "))
+	}
+
+	var reply struct {
+		MethodID             string   `json:"method_id"`
+		Confidence           float64  `json:"confidence"`
+		Reason               string   `json:"reason"`
+		Adaptations          []string `json:"adaptations"`
+		AlternativeMethodIDs []string `json:"alternative_method_ids"`
+	}
+	if err := routeJSON(ctx, r.router, nil, "method_selection", b.String(), methodSelectionSchema, &reply); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		known[method.ID] = true
+	}
+	if !known[reply.MethodID] {
+		return nil, fmt.Errorf("selected method %q is not one of the candidates", reply.MethodID)
+	}
+	var alternatives []string
+	for _, id := range reply.AlternativeMethodIDs {
+		if known[id] && id != reply.MethodID {
+			alternatives = append(alternatives, id)
+		}
+	}
+
+	return &MethodSelection{
+		SelectedMethodID:     reply.MethodID,
+		ConfidenceLevel:      reply.Confidence,
+		SelectionReason:      reply.Reason,
+		RequiredAdaptations:  reply.Adaptations,
+		AlternativeMethodIDs: alternatives,
+	}, nil
+}
+
+// DesignMethod implements LLMReasoner.
+func (r *RouterReasoner) DesignMethod(ctx context.Context, analysis *ObjectiveAnalysis) (*Method, error) {
+	prompt := fmt.Sprintf(`Design a reusable method for objectives like the one analyzed below: a short name, a description, the domain it applies to, and as few steps as will do the job, each with the tools it needs and heuristics for carrying it out.
+
+OBJECTIVE ANALYSIS:
+%s`, analysisLines(analysis))
+
+	var reply struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Domain      string         `json:"domain"`
+		Steps       []ApproachStep `json:"steps"`
+	}
+	if err := routeJSON(ctx, r.router, nil, "method_design", prompt, methodDesignSchema, &reply); err != nil {
+		return nil, err
+	}
+	if len(reply.Steps) == 0 {
+		return nil, fmt.Errorf("designed method has no steps")
+	}
+
+	domain := MethodDomain(reply.Domain)
+	if domain == "" {
+		domain = MethodDomainGeneral
+	}
+	return &Method{
+		Name:        reply.Name,
+		Description: reply.Description,
+		Approach:    reply.Steps,
+		Domain:      domain,
+		Version:     "1.0.0",
+		Status:      MethodStatusActive,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// DecomposePlan implements LLMReasoner. Tasks that depend on unknown tasks
+// are rejected, so the plan's dependencies always resolve.
+func (r *RouterReasoner) DecomposePlan(ctx context.Context, objective *Objective, method *Method) (*ExecutionPlan, error) {
+	prompt := fmt.Sprintf(`Break this objective into tasks following the method below. Give each task a short unique id, a type (such as analyze, generate or validate; write_file or run_command for tasks that change anything outside the conversation), a description complete enough to carry out alone, the method step it implements (0-based), its estimated LLM tokens, whether it has side effects, and the ids of the tasks whose results it needs.
+
+OBJECTIVE: %s
+%s
+%s
+METHOD: %s
+%s`, objective.Title, objective.Description, contextLines(objective.Context), method.Name, stepLines(method.Approach, "  "))
+
+	var reply struct {
+		Title string `json:"title"`
+		Tasks []struct {
+			ID              string   `json:"id"`
+			Type            string   `json:"type"`
+			Description     string   `json:"description"`
+			MethodStep      *int     `json:"method_step"`
+			EstimatedTokens int      `json:"estimated_tokens"`
+			SideEffects     bool     `json:"side_effects"`
+			DependsOn       []string `json:"depends_on"`
+		} `json:"tasks"`
+	}
+	if err := routeJSON(ctx, r.router, objective, "planning", prompt, planSchema, &reply); err != nil {
+		return nil, err
+	}
+	if len(reply.Tasks) == 0 {
+		return nil, fmt.Errorf("plan has no tasks")
+	}
+
+	plan := &ExecutionPlan{Title: reply.Title}
+	ids := make(map[string]bool, len(reply.Tasks))
+	for _, task := range reply.Tasks {
+		if ids[task.ID] {
+			return nil, fmt.Errorf("plan has two tasks with ID %q", task.ID)
+		}
+		ids[task.ID] = true
+	}
+	for _, task := range reply.Tasks {
+		step := -1
+		if task.MethodStep != nil && *task.MethodStep >= 0 && *task.MethodStep < len(method.Approach) {
+			step = *task.MethodStep
+		}
+		estimate := task.EstimatedTokens
+		if estimate <= 0 {
+			estimate = defaultTaskTokens
+		}
+		parameters := map[string]interface{}{}
+		if task.SideEffects {
+			parameters["side_effects"] = true
+		}
+
+		plan.Tasks = append(plan.Tasks, ExecutionTask{
+			ID:          task.ID,
+			Type:        task.Type,
+			Description: task.Description,
+			Context: TaskContext{
+				Parameters:  parameters,
+				TokenBudget: estimate * 2,
+				Priority:    objective.Priority,
+			},
+			MethodStepIndex: step,
+			EstimatedTokens: estimate,
+			CreatedAt:       time.Now(),
+		})
+		for _, dependency := range task.DependsOn {
+			if !ids[dependency] {
+				return nil, fmt.Errorf("task %s depends on unknown task %q", task.ID, dependency)
+			}
+			plan.Dependencies = append(plan.Dependencies, TaskDependency{
+				TaskID:          task.ID,
+				DependsOnTaskID: dependency,
+				Reason:          "Uses the result of " + dependency,
+			})
+		}
+	}
+	return plan, nil
+}
+
+// RouterLearningAgent learns from executions for the learning loop. It
+// analyzes outcomes from the execution results alone, and asks the LLM
+// router only when a method is to be refined.
+type RouterLearningAgent struct {
+	router *llm.Router
+}
+
+// NewRouterLearningAgent creates a learning agent that refines methods
+// through router.
+func NewRouterLearningAgent(router *llm.Router) *RouterLearningAgent {
+	return &RouterLearningAgent{router: router}
+}
+
+// AnalyzeExecutionOutcome implements LearningAgent.
+func (a *RouterLearningAgent) AnalyzeExecutionOutcome(ctx context.Context, result *ExecutionResult, plan *ExecutionPlan, method *Method) (*ExecutionAnalysis, error) {
+	analysis := &ExecutionAnalysis{
+		ComplexityAssessment: ComplexityAnalysis{
+			CurrentComplexityLevel: min(len(method.Approach), 10),
+			OptimalComplexityLevel: min(len(method.Approach), 10),
+		},
+	}
+
+	total := result.SuccessfulTasks + result.FailedTasks
+	switch {
+	case result.Status == ExecutionStatusCompleted:
+		analysis.OverallAssessment = OutcomeSuccess
+		analysis.SuccessFactors = []string{fmt.Sprintf("all %d tasks completed", result.SuccessfulTasks)}
+	case total == 0:
+		analysis.OverallAssessment = OutcomeInsufficientData
+	case result.Status == ExecutionStatusPartial:
+		analysis.OverallAssessment = OutcomePartialSuccess
+	default:
+		analysis.OverallAssessment = OutcomeMethodFailure
+	}
+
+	var failed []string
+	for taskID, taskResult := range result.TaskResults {
+		if taskResult.Status != TaskStatusFailed {
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%s: %s", taskID, taskResult.ErrorMessage))
+		issue := PerformanceIssue{
+			Category:    IssueReliability,
+			Description: fmt.Sprintf("task %s failed: %s", taskID, taskResult.ErrorMessage),
+			Severity:    5,
+		}
+		if taskResult.MethodStepIndex >= 0 {
+			issue.AffectedSteps = []int{taskResult.MethodStepIndex}
+		}
+		analysis.MethodPerformanceIssues = append(analysis.MethodPerformanceIssues, issue)
+	}
+	if len(failed) > 0 {
+		analysis.PrimaryFailureCause = failed[0]
+		analysis.ImprovementOpportunities = failed
+	}
+
+	// The more tasks ran, the more the outcome says about the method
+	analysis.ConfidenceLevel = 0.5
+	if total > 0 {
+		analysis.ConfidenceLevel = min(0.5+0.1*float64(total), 0.9)
+	}
+	return analysis, nil
+}
+
+// ProposeMethodRefinement implements LearningAgent.
+func (a *RouterLearningAgent) ProposeMethodRefinement(ctx context.Context, analysis *ExecutionAnalysis, method *Method) (*MethodRefinement, error) {
+	var issues strings.Builder
+	for _, issue := range analysis.MethodPerformanceIssues {
+		fmt.Fprintf(&issues, "- %s (steps %v)\n", issue.Description, issue.AffectedSteps)
+	}
+	prompt := fmt.Sprintf(`A method keeps failing. Propose how to refine it: "none" to keep it, "modify" or "replace" with new steps, or "retire" if it should no longer be used. Prefer fewer, simpler steps.
+
+METHOD: %s (%s)
+%s
+SUCCESS RATE: %.0f%% over %d runs
+OUTCOME: %s
+ISSUES:
+%s`, method.Name, method.Description, stepLines(method.Approach, "  "), method.Metrics.SuccessRate(), method.Metrics.ExecutionCount, analysis.OverallAssessment, issues.String())
+
+	var reply struct {
+		Type                       RefinementType `json:"type"`
+		Steps                      []ApproachStep `json:"steps"`
+		Reasoning                  string         `json:"reasoning"`
+		ExpectedSuccessImprovement float64        `json:"expected_success_improvement"`
+	}
+	if err := routeJSON(ctx, a.router, nil, "method_refinement", prompt, refinementSchema, &reply); err != nil {
+		return nil, err
+	}
+	if (reply.Type == RefinementModify || reply.Type == RefinementReplace) && len(reply.Steps) == 0 {
+		return nil, fmt.Errorf("%s refinement has no steps", reply.Type)
+	}
+
+	return &MethodRefinement{
+		Type:                           reply.Type,
+		NewApproach:                    reply.Steps,
+		Reasoning:                      reply.Reasoning,
+		ExpectedComplexityChange:       len(reply.Steps) - len(method.Approach),
+		ExpectedSuccessRateImprovement: reply.ExpectedSuccessImprovement,
+		RequiredVersion:                nextMinorVersion(method.Version),
+	}, nil
+}
+
+// EvaluateRefinement implements LearningAgent. A refinement is recommended
+// when it promises an improvement without adding steps.
+func (a *RouterLearningAgent) EvaluateRefinement(ctx context.Context, original *Method, refinement *MethodRefinement) (*RefinementEvaluation, error) {
+	evaluation := &RefinementEvaluation{
+		IsImprovement:     refinement.ExpectedSuccessRateImprovement > 0 || refinement.Type == RefinementRetire,
+		ReducesComplexity: refinement.Type == RefinementRetire || len(refinement.NewApproach) < len(original.Approach),
+		QualityScore:      5,
+	}
+	if len(refinement.NewApproach) > len(original.Approach) {
+		evaluation.Concerns = append(evaluation.Concerns, "adds steps to the method")
+	}
+
+	switch {
+	case !evaluation.IsImprovement:
+		evaluation.Recommendation = RecommendReject
+	case len(evaluation.Concerns) > 0:
+		evaluation.Recommendation = RecommendRevise
+	default:
+		evaluation.Recommendation = RecommendApply
+		evaluation.QualityScore = 7
+	}
+	return evaluation, nil
+}
+
+// routeJSON routes a prompt asking for a JSON reply matching schema and
+// decodes the reply into out. Requests made for an objective are attributed
+// to it.
+func routeJSON(ctx context.Context, router *llm.Router, objective *Objective, taskType, prompt string, schema *mcp.ResponseSchema, out interface{}) error {
+	request := llm.TaskRequest{
+		Prompt:          prompt,
+		MaxTokens:       planningMaxTokens,
+		Temperature:     0.3,
+		TaskType:        taskType,
+		QualityRequired: llm.QualityStandard,
+		ResponseSchema:  schema,
+	}
+	if objective != nil {
+		request.Metadata = map[string]interface{}{
+			llm.MetadataGoalID:      objective.GoalID,
+			llm.MetadataObjectiveID: objective.ID,
+		}
+	}
+
+	result, err := router.Route(ctx, request)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", schema.Name, err)
+	}
+	text, _, err := schema.Parse(result.ExecutionResult.Text)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s reply: %w", schema.Name, err)
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return fmt.Errorf("failed to decode %s reply: %w", schema.Name, err)
+	}
+	return nil
+}
+
+// contextLines renders an objective's context for a prompt, leaving out
+// bookkeeping.
+func contextLines(context map[string]interface{}) string {
+	var b strings.Builder
+	for _, key := range sortedKeys(context) {
+		switch key {
+		case delegationContextKey, attachmentsContextKey, "wip_override", timeBoxContextKey:
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", key, promptValue(context[key]))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "CONTEXT:\n" + b.String()
+}
+
+// analysisLines renders an objective analysis for a prompt.
+func analysisLines(analysis *ObjectiveAnalysis) string {
+	return fmt.Sprintf("Complexity: %d/10\nDomain: %s\nCapabilities: %s\nChallenges: %s\nSuccess criteria: %s\nApproach: %s",
+		analysis.ComplexityLevel, analysis.DomainContext,
+		strings.Join(analysis.RequiredCapabilities, "; "), strings.Join(analysis.KeyChallenges, "; "),
+		strings.Join(analysis.SuccessCriteria, "; "), analysis.RecommendedApproach)
+}
+
+// stepLines renders a method's steps for a prompt, numbered from 0.
+func stepLines(steps []ApproachStep, indent string) string {
+	var b strings.Builder
+	for i, step := range steps {
+		fmt.Fprintf(&b, "%s%d. %s", indent, i, step.Description)
+		if len(step.Tools) > 0 {
+			fmt.Fprintf(&b, " [tools: %s]", strings.Join(step.Tools, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// nextMinorVersion returns the version after a "major.minor.patch" version,
+// or "1.1.0" when it cannot be read.
+func nextMinorVersion(version string) string {
+	var major, minor, patch int
+	if _, err := fmt.Sscanf(version, "%d.%d.%d", &major, &minor, &patch); err != nil {
+		return "1.1.0"
+	}
+	return fmt.Sprintf("%d.%d.0", major, minor+1)
+}
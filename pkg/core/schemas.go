@@ -0,0 +1,126 @@
+package core
+
+import "github.com/Solifugus/ai-work-studio/pkg/storage"
+
+// The stored forms of the core entities are checked on every write, so a
+// node their readers would skip is rejected instead of disappearing from
+// lists. Each schema requires the fields the node's reader cannot do
+// without, and types the ones it reads.
+func init() {
+	storage.RegisterNodeSchema("goal", goalSchema())
+	storage.RegisterNodeSchema("objective", objectiveSchema())
+	storage.RegisterNodeSchema("method", methodSchema())
+	storage.RegisterNodeSchema("ethical_decision", ethicalDecisionSchema())
+	storage.RegisterNodeSchema("execution_result", executionResultSchema())
+	storage.RegisterNodeSchema(workLoopStatusNodeType, workLoopStatusSchema())
+}
+
+// goalSchema is the schema of goal nodes, read by nodeToGoal.
+func goalSchema() storage.Schema {
+	return storage.Schema{Fields: map[string]storage.Field{
+		"title":        {Type: storage.FieldString, Required: true},
+		"status":       {Type: storage.FieldString, Required: true, Check: storage.OneOf(string(GoalStatusActive), string(GoalStatusPaused), string(GoalStatusCompleted), string(GoalStatusArchived))},
+		"priority":     {Type: storage.FieldInteger, Required: true},
+		"created_at":   {Type: storage.FieldTime, Required: true},
+		"description":  {Type: storage.FieldString},
+		"user_context": {Type: storage.FieldMap},
+
+		"archived_reason":  {Type: storage.FieldString},
+		"archived_at":      {Type: storage.FieldTime},
+		"archived_from":    {Type: storage.FieldString},
+		"archived_by_goal": {Type: storage.FieldString},
+	}}
+}
+
+// objectiveSchema is the schema of objective nodes, read by nodeToObjective.
+func objectiveSchema() storage.Schema {
+	return storage.Schema{Fields: map[string]storage.Field{
+		"goal_id":   {Type: storage.FieldString, Required: true},
+		"method_id": {Type: storage.FieldString, Required: true},
+		"title":     {Type: storage.FieldString, Required: true},
+		"status": {Type: storage.FieldString, Required: true, Check: storage.OneOf(string(ObjectiveStatusPending), string(ObjectiveStatusInProgress),
+			string(ObjectiveStatusCompleted), string(ObjectiveStatusFailed), string(ObjectiveStatusPaused), string(ObjectiveStatusCancelled))},
+		"created_at":   {Type: storage.FieldTime, Required: true},
+		"priority":     {Type: storage.FieldInteger},
+		"description":  {Type: storage.FieldString},
+		"context":      {Type: storage.FieldMap},
+		"started_at":   {Type: storage.FieldTime},
+		"completed_at": {Type: storage.FieldTime},
+	}}
+}
+
+// methodSchema is the schema of method nodes, read by nodeToMethod.
+func methodSchema() storage.Schema {
+	return storage.Schema{Fields: map[string]storage.Field{
+		"name":         {Type: storage.FieldString, Required: true},
+		"domain":       {Type: storage.FieldString, Required: true},
+		"status":       {Type: storage.FieldString, Required: true, Check: storage.OneOf(string(MethodStatusActive), string(MethodStatusDeprecated), string(MethodStatusSuperseded))},
+		"created_at":   {Type: storage.FieldTime, Required: true},
+		"description":  {Type: storage.FieldString},
+		"version":      {Type: storage.FieldString},
+		"approach":     {Type: storage.FieldList},
+		"metrics":      {Type: storage.FieldMap},
+		"user_context": {Type: storage.FieldMap},
+	}}
+}
+
+// ethicalDecisionSchema is the schema of ethical_decision nodes, read by
+// nodeToEthicalDecision.
+func ethicalDecisionSchema() storage.Schema {
+	return storage.Schema{Fields: map[string]storage.Field{
+		"decision_context":      {Type: storage.FieldString},
+		"approval_status":       {Type: storage.FieldString},
+		"created_at":            {Type: storage.FieldTime},
+		"objective_id":          {Type: storage.FieldString},
+		"proposed_action":       {Type: storage.FieldString},
+		"alternative_actions":   {Type: storage.FieldList},
+		"freedom_impact":        {Type: storage.FieldNumber},
+		"wellbeing_impact":      {Type: storage.FieldNumber},
+		"sustainability_impact": {Type: storage.FieldNumber},
+		"confidence_score":      {Type: storage.FieldNumber},
+		"urgency":               {Type: storage.FieldString},
+		"user_id":               {Type: storage.FieldString},
+		"approved_at":           {Type: storage.FieldTime},
+		"implemented_at":        {Type: storage.FieldTime},
+	}}
+}
+
+// executionResultSchema is the schema of execution_result nodes, read by
+// executionResultFromNode.
+func executionResultSchema() storage.Schema {
+	return storage.Schema{Fields: map[string]storage.Field{
+		"plan_id":      {Type: storage.FieldString},
+		"objective_id": {Type: storage.FieldString},
+		"status": {Type: storage.FieldString, Check: storage.OneOf(string(ExecutionStatusPending), string(ExecutionStatusRunning),
+			string(ExecutionStatusCompleted), string(ExecutionStatusFailed), string(ExecutionStatusPartial),
+			string(ExecutionStatusCancelled), string(ExecutionStatusCheckpointed))},
+		"method_id":         {Type: storage.FieldString},
+		"total_tokens_used": {Type: storage.FieldInteger},
+		"successful_tasks":  {Type: storage.FieldInteger},
+		"failed_tasks":      {Type: storage.FieldInteger},
+		"total_duration":    {Type: storage.FieldNumber},
+		"start_time":        {Type: storage.FieldTime},
+		"end_time":          {Type: storage.FieldTime},
+		"task_summary":      {Type: storage.FieldMap},
+		"plan":              {Type: storage.FieldMap},
+	}}
+}
+
+// workLoopStatusSchema is the schema of work loop status nodes, read by
+// nodeToWorkLoopStatus.
+func workLoopStatusSchema() storage.Schema {
+	return storage.Schema{Fields: map[string]storage.Field{
+		"state": {Type: storage.FieldString, Required: true, Check: storage.OneOf(string(WorkLoopRunning), string(WorkLoopIdle),
+			string(WorkLoopRateLimited), string(WorkLoopAwaitingApproval), string(WorkLoopStopped))},
+		"started_at":           {Type: storage.FieldTime, Required: true},
+		"updated_at":           {Type: storage.FieldTime, Required: true},
+		"pid":                  {Type: storage.FieldInteger},
+		"completed":            {Type: storage.FieldInteger},
+		"failed":               {Type: storage.FieldInteger},
+		"consecutive_failures": {Type: storage.FieldInteger},
+		"session_spend":        {Type: storage.FieldNumber},
+		"pending_decisions":    {Type: storage.FieldInteger},
+		"current_objective_id": {Type: storage.FieldString},
+		"stop_reason":          {Type: storage.FieldString},
+	}}
+}
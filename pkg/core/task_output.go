@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// DefaultOutputBlobThreshold is the size in bytes above which task outputs
+// are stored as blobs rather than in execution records.
+const DefaultOutputBlobThreshold = 64 * 1024
+
+// SetOutputBlobThreshold sets the size in bytes above which task outputs are
+// stored as blobs. Zero or less keeps every output in the execution records.
+func (rtc *RealTimeCursor) SetOutputBlobThreshold(bytes int) {
+	rtc.outputBlobThreshold = bytes
+}
+
+// storeOutput stores a completed task's output as a blob when it is over the
+// threshold, recording its reference in OutputRef. Text is stored as is and
+// anything else as JSON. The output stays in memory for the rest of the
+// execution; records of the task keep only the reference. Outputs the
+// executor already stored elsewhere are left alone.
+func (rtc *RealTimeCursor) storeOutput(ctx context.Context, result *TaskResult) {
+	if rtc.store == nil || rtc.outputBlobThreshold <= 0 || result.Output == nil || result.OutputRef != "" {
+		return
+	}
+
+	var data []byte
+	isJSON := false
+	if text, ok := result.Output.(string); ok {
+		data = []byte(text)
+	} else {
+		encoded, err := json.Marshal(result.Output)
+		if err != nil {
+			return
+		}
+		data = encoded
+		isJSON = true
+	}
+	if len(data) <= rtc.outputBlobThreshold {
+		return
+	}
+
+	// Like its checkpoint, a completed task's output is kept even when ctx
+	// was cancelled as it completed
+	hash, err := rtc.store.PutBlob(context.WithoutCancel(ctx), data)
+	if err != nil {
+		fmt.Printf("Warning: failed to store output of task %s: %v\n", result.TaskID, err)
+		return
+	}
+	result.OutputRef = storage.BlobRef(hash)
+	result.outputJSON = isJSON
+}
+
+// taskOutput returns a completed task's output, loading it from its blob
+// when only the reference was kept, as for tasks a resumed execution skips.
+func (rtc *RealTimeCursor) taskOutput(ctx context.Context, result *TaskResult) interface{} {
+	if result.Output != nil || rtc.store == nil {
+		return result.Output
+	}
+	hash, ok := storage.ParseBlobRef(result.OutputRef)
+	if !ok {
+		return nil
+	}
+	output, err := loadOutputBlob(ctx, rtc.store, hash, result.outputJSON)
+	if err != nil {
+		fmt.Printf("Warning: failed to load output of task %s: %v\n", result.TaskID, err)
+		return nil
+	}
+	result.Output = output
+	return output
+}
+
+// loadOutputBlob reads a task output stored as a blob.
+func loadOutputBlob(ctx context.Context, store *storage.Store, hash string, isJSON bool) (interface{}, error) {
+	data, err := store.GetBlob(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !isJSON {
+		return string(data), nil
+	}
+	var output interface{}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to decode output blob %s: %w", hash, err)
+	}
+	return output, nil
+}
+
+// outputData records a task's output in execution records: its reference
+// when it was stored as a blob, or else the output itself.
+func outputData(result *TaskResult, data map[string]interface{}) {
+	if _, ok := storage.ParseBlobRef(result.OutputRef); ok {
+		if result.outputJSON {
+			data["output_json"] = true
+		}
+		return
+	}
+	data["output"] = result.Output
+}
+
+// CollectOutputBlobs removes the task output blobs that no current execution
+// result or plan checkpoint refers to, and that are older than opts.MinAge.
+// Any Referenced hashes in opts are kept as well.
+func CollectOutputBlobs(ctx context.Context, store *storage.Store, opts storage.BlobGCOptions) (*storage.BlobGCResult, error) {
+	referenced := make(map[string]bool, len(opts.Referenced))
+	for hash, keep := range opts.Referenced {
+		referenced[hash] = keep
+	}
+
+	for _, source := range []struct{ nodeType, tasksKey string }{
+		{"execution_result", "task_summary"},
+		{planCheckpointNodeType, "completed_tasks"},
+	} {
+		nodes, err := store.GetNodesByType(ctx, source.nodeType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s nodes: %w", source.nodeType, err)
+		}
+		for _, node := range nodes {
+			tasks, _ := node.Data[source.tasksKey].(map[string]interface{})
+			for _, item := range tasks {
+				taskData, _ := item.(map[string]interface{})
+				if hash, ok := storage.ParseBlobRef(getString(taskData, "output_ref")); ok {
+					referenced[hash] = true
+				}
+			}
+		}
+	}
+
+	opts.Referenced = referenced
+	return store.CollectBlobs(ctx, opts)
+}
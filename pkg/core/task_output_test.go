@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// blobExecutor gives the chain plan's gather task a multi-megabyte text
+// output and its draft task a large structured one, and records the
+// dependency outputs each task receives. It calls interrupt once draft
+// completes, if set.
+type blobExecutor struct {
+	received  map[string]map[string]interface{}
+	interrupt func()
+}
+
+func (e *blobExecutor) ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	outputs, _ := fullContext[DependencyOutputsKey].(map[string]interface{})
+	e.received[task.ID] = outputs
+
+	var output interface{}
+	switch task.ID {
+	case "gather":
+		output = strings.Repeat("g", 3<<20)
+	case "draft":
+		output = map[string]interface{}{"summary": strings.Repeat("d", 100<<10), "words": 2.0}
+	default:
+		output = "ok"
+	}
+	if task.ID == "draft" && e.interrupt != nil {
+		e.interrupt()
+	}
+	return &TaskResult{TaskID: task.ID, Status: TaskStatusCompleted, Output: output, TokensUsed: 10}, nil
+}
+
+func (e *blobExecutor) GetAvailableTools(ctx context.Context) ([]string, error) {
+	return []string{"llm"}, nil
+}
+
+func (e *blobExecutor) EstimateTokenUsage(ctx context.Context, task *ExecutionTask) (int, error) {
+	return task.EstimatedTokens, nil
+}
+
+func TestTaskOutputs_LargeOutputsStoredAsBlobs(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	executor := &blobExecutor{received: map[string]map[string]interface{}{}}
+
+	result, err := NewRealTimeCursor(store, executor, NewMockContextLoader()).ExecutePlan(ctx, chainPlan("objective_1"))
+	if err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+
+	gather, draft, review := result.TaskResults["gather"], result.TaskResults["draft"], result.TaskResults["review"]
+	if !strings.HasPrefix(gather.OutputRef, storage.BlobScheme) || !strings.HasPrefix(draft.OutputRef, storage.BlobScheme) {
+		t.Fatalf("Expected the large outputs stored as blobs, got refs %q and %q", gather.OutputRef, draft.OutputRef)
+	}
+	if review.OutputRef != "" {
+		t.Errorf("Expected the small output kept inline, got ref %q", review.OutputRef)
+	}
+	if !reflect.DeepEqual(executor.received["review"]["draft"], draft.Output) {
+		t.Error("Expected review to receive draft's output")
+	}
+
+	// The blob holds the text, and the context loader resolves its reference
+	resolved, err := NewStoreContextLoader(store).ResolveReference(ctx, gather.OutputRef)
+	if err != nil || resolved != gather.Output {
+		t.Errorf("Expected the gather output from its reference, got %d characters, %v", len(resolved.(string)), err)
+	}
+
+	// The stored summary records the references, not the outputs
+	summary := mustGetNode(t, store, result.ID).Data["task_summary"].(map[string]interface{})
+	gatherSummary := summary["gather"].(map[string]interface{})
+	draftSummary := summary["draft"].(map[string]interface{})
+	if gatherSummary["output_ref"] != gather.OutputRef || draftSummary["output_ref"] != draft.OutputRef || draftSummary["output_json"] != true {
+		t.Errorf("Expected the summary to record the output references, got %v and %v", gatherSummary, draftSummary)
+	}
+	stored, err := executionResultFromNode(mustGetNode(t, store, result.ID))
+	if err != nil || stored.TaskResults["draft"].OutputRef != draft.OutputRef {
+		t.Errorf("Expected the reloaded result to keep the reference, got %+v, %v", stored.TaskResults["draft"], err)
+	}
+}
+
+func TestTaskOutputs_ResumeLoadsBlobs(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	interrupted, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	executor := &blobExecutor{received: map[string]map[string]interface{}{}, interrupt: cancel}
+	if _, err := NewRealTimeCursor(store, executor, NewMockContextLoader()).ExecutePlan(interrupted, chainPlan("objective_1")); err == nil {
+		t.Fatal("Expected the execution to be cancelled")
+	}
+	if _, ran := executor.received["review"]; ran {
+		t.Fatal("Expected review not to run before the interruption")
+	}
+
+	// The checkpoint keeps references in place of the large outputs
+	checkpoints, err := NewRealTimeCursor(store, nil, nil).planCheckpoints(context.Background(), "chain_plan")
+	if err != nil || len(checkpoints) != 1 {
+		t.Fatalf("Expected one checkpoint, got %d, %v", len(checkpoints), err)
+	}
+	node := mustGetNode(t, store, checkpoints[0].ID)
+	for taskID, item := range node.Data["completed_tasks"].(map[string]interface{}) {
+		taskData := item.(map[string]interface{})
+		if _, ok := taskData["output"]; ok || !strings.HasPrefix(getString(taskData, "output_ref"), storage.BlobScheme) {
+			t.Errorf("Expected task %s checkpointed by reference, got output_ref %q", taskID, getString(taskData, "output_ref"))
+		}
+	}
+
+	// After a restart, review receives draft's output from its blob
+	store, err = storage.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	executor = &blobExecutor{received: map[string]map[string]interface{}{}}
+	resumed, err := NewRealTimeCursor(store, executor, NewMockContextLoader()).ResumePlan(context.Background(), "chain_plan")
+	if err != nil {
+		t.Fatalf("ResumePlan failed: %v", err)
+	}
+	want := map[string]interface{}{"summary": strings.Repeat("d", 100<<10), "words": 2.0}
+	if !reflect.DeepEqual(executor.received["review"]["draft"], want) {
+		t.Errorf("Expected review to receive draft's output from its blob")
+	}
+	if resumed.Status != ExecutionStatusCompleted || resumed.SuccessfulTasks != 3 {
+		t.Errorf("Expected the resumed run to complete all 3 tasks, got %s with %d", resumed.Status, resumed.SuccessfulTasks)
+	}
+}
+
+func TestCollectOutputBlobs(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	result, err := NewRealTimeCursor(store, &blobExecutor{received: map[string]map[string]interface{}{}}, NewMockContextLoader()).ExecutePlan(ctx, chainPlan("objective_1"))
+	if err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+	orphan, err := store.PutBlob(ctx, []byte("output of a deleted execution"))
+	if err != nil {
+		t.Fatalf("PutBlob failed: %v", err)
+	}
+
+	gc, err := CollectOutputBlobs(ctx, store, storage.BlobGCOptions{MinAge: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("CollectOutputBlobs failed: %v", err)
+	}
+	if gc.Removed != 1 || gc.Kept != 2 {
+		t.Errorf("Expected only the unreferenced blob removed, got %s", gc)
+	}
+	if _, err := store.GetBlob(ctx, orphan); err == nil {
+		t.Error("Expected the unreferenced blob to be gone")
+	}
+	for _, taskID := range []string{"gather", "draft"} {
+		hash, _ := storage.ParseBlobRef(result.TaskResults[taskID].OutputRef)
+		if _, err := store.GetBlob(ctx, hash); err != nil {
+			t.Errorf("Expected the output of %s to be kept: %v", taskID, err)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// ErrTimeBoxExceeded is returned when an execution runs past its objective's
+// time box. The execution is checkpointed and the objective paused, not failed.
+var ErrTimeBoxExceeded = errs.New(errs.BudgetExceeded, "time box exceeded")
+
+// DefaultTimeBoxGrace is how long the task in flight when a time box runs
+// out may take to finish before it is cancelled.
+const DefaultTimeBoxGrace = 30 * time.Second
+
+// timeBoxContextKey is where a time box stop is recorded in an objective's context
+const timeBoxContextKey = "time_box_exceeded"
+
+// TimeBoxes sets the default time box for objectives by priority tier, for
+// objectives without a MaxExecutionTime of their own. Zero means no limit.
+// A time box only counts active execution: time spent waiting for ethical
+// approval is tracked separately and never runs the clock down.
+type TimeBoxes struct {
+	// High applies to priorities 8-10
+	High time.Duration
+
+	// Normal applies to priorities 4-7
+	Normal time.Duration
+
+	// Low applies to priorities 1-3
+	Low time.Duration
+
+	// Grace is how long the task in flight may take to finish once the
+	// time box runs out (default: DefaultTimeBoxGrace)
+	Grace time.Duration
+}
+
+// For returns the time box that applies to objective: its own limit if set,
+// or the default for its priority tier.
+func (tb TimeBoxes) For(objective *Objective) time.Duration {
+	if objective.MaxExecutionTime > 0 {
+		return objective.MaxExecutionTime
+	}
+	switch {
+	case objective.Priority >= 8:
+		return tb.High
+	case objective.Priority >= 4:
+		return tb.Normal
+	default:
+		return tb.Low
+	}
+}
+
+// grace returns the grace window, falling back to the default.
+func (tb TimeBoxes) grace() time.Duration {
+	if tb.Grace > 0 {
+		return tb.Grace
+	}
+	return DefaultTimeBoxGrace
+}
+
+// SetTimeBoxes sets the default time boxes by priority tier.
+func (om *ObjectiveManager) SetTimeBoxes(boxes TimeBoxes) {
+	om.timeBoxes = boxes
+}
+
+// TimeBoxes returns the default time boxes in effect.
+func (om *ObjectiveManager) TimeBoxes() TimeBoxes {
+	return om.timeBoxes
+}
+
+// TimeBoxStop records why an objective was paused by its time box.
+type TimeBoxStop struct {
+	Limit   time.Duration `json:"limit"`
+	Elapsed time.Duration `json:"elapsed"`
+	At      time.Time     `json:"at"`
+
+	// ExecutionID is the checkpointed execution a resumed run continues
+	// from; empty when the time box ran out between attempts
+	ExecutionID string `json:"execution_id,omitempty"`
+
+	// TaskID is the task that was in flight or next when the time box ran out
+	TaskID string `json:"task_id,omitempty"`
+}
+
+// TimeBoxStop returns why the objective was last stopped by its time box,
+// or nil if it was not.
+func (o *Objective) TimeBoxStop() *TimeBoxStop {
+	record, ok := o.Context[timeBoxContextKey].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	stop := &TimeBoxStop{
+		Limit:       parseDurationData(record["limit"]),
+		Elapsed:     parseDurationData(record["elapsed"]),
+		ExecutionID: getString(record, "execution_id"),
+		TaskID:      getString(record, "task_id"),
+	}
+	if at, err := time.Parse(time.RFC3339, getString(record, "at")); err == nil {
+		stop.At = at
+	}
+	return stop
+}
+
+// TimeBoxResolution is the user's answer to an objective paused by its time box.
+type TimeBoxResolution string
+
+const (
+	// TimeBoxExtend raises the time box and continues from the checkpoint
+	TimeBoxExtend TimeBoxResolution = "extend"
+
+	// TimeBoxResume continues from the checkpoint with a fresh time box
+	TimeBoxResume TimeBoxResolution = "resume"
+
+	// TimeBoxAbandon fails the objective
+	TimeBoxAbandon TimeBoxResolution = "abandon"
+)
+
+// ParseTimeBoxResolution parses "extend", "resume" or "abandon".
+func ParseTimeBoxResolution(s string) (TimeBoxResolution, error) {
+	switch resolution := TimeBoxResolution(s); resolution {
+	case TimeBoxExtend, TimeBoxResume, TimeBoxAbandon:
+		return resolution, nil
+	default:
+		return "", errs.Newf(errs.Validation, "unknown time box resolution %q (want extend, resume or abandon)", s)
+	}
+}
+
+// TimeBoxedObjectives returns the objectives paused by their time box that
+// are waiting for the user to extend, resume or abandon them.
+func (om *ObjectiveManager) TimeBoxedObjectives(ctx context.Context) ([]*Objective, error) {
+	paused := ObjectiveStatusPaused
+	objectives, err := om.ListObjectives(ctx, ObjectiveFilter{Status: &paused})
+	if err != nil {
+		return nil, err
+	}
+
+	var stopped []*Objective
+	for _, objective := range objectives {
+		if objective.TimeBoxStop() != nil {
+			stopped = append(stopped, objective)
+		}
+	}
+	return stopped, nil
+}
+
+// ResolveTimeBox applies the user's answer to an objective paused by its
+// time box. Extending adds extension to the time box the objective stopped
+// at; extending and resuming both return the objective to pending, so its
+// next run continues from the checkpoint. Abandoning fails it.
+func (om *ObjectiveManager) ResolveTimeBox(ctx context.Context, objectiveID string, resolution TimeBoxResolution, extension time.Duration) (*Objective, error) {
+	objective, err := om.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get objective: %w", err)
+	}
+	stop := objective.TimeBoxStop()
+	if objective.Status != ObjectiveStatusPaused || stop == nil {
+		return nil, errs.Newf(errs.Conflict, "objective %s was not paused by its time box", objective.ID).With("status", string(objective.Status))
+	}
+
+	switch resolution {
+	case TimeBoxExtend:
+		if extension <= 0 {
+			return nil, errs.Newf(errs.Validation, "time box extension must be positive, got %s", extension)
+		}
+		limit := stop.Limit + extension
+		status := ObjectiveStatusPending
+		return om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{
+			Status:           &status,
+			MaxExecutionTime: &limit,
+		})
+	case TimeBoxResume:
+		var active time.Duration
+		status := ObjectiveStatusPending
+		return om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{
+			Status:              &status,
+			ActiveExecutionTime: &active,
+		})
+	case TimeBoxAbandon:
+		context := copyObjectiveContext(objective.Context)
+		delete(context, timeBoxContextKey)
+		if _, err := om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{Context: context}); err != nil {
+			return nil, err
+		}
+		return om.FailObjective(ctx, objective.ID, fmt.Sprintf("Abandoned after exceeding its %s time box", stop.Limit), 0)
+	default:
+		return nil, errs.Newf(errs.Validation, "unknown time box resolution %q", resolution)
+	}
+}
+
+// recordTimeBoxStop pauses an objective whose time box ran out and records
+// why, along with the time it used.
+func (om *ObjectiveManager) recordTimeBoxStop(ctx context.Context, objectiveID string, stop *TimeBoxStop, approvalWait time.Duration) (*Objective, error) {
+	objective, err := om.GetObjective(ctx, objectiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get objective: %w", err)
+	}
+
+	context := copyObjectiveContext(objective.Context)
+	record := map[string]interface{}{
+		"limit":   stop.Limit.String(),
+		"elapsed": stop.Elapsed.String(),
+		"at":      stop.At.Format(time.RFC3339),
+	}
+	if stop.ExecutionID != "" {
+		record["execution_id"] = stop.ExecutionID
+	}
+	if stop.TaskID != "" {
+		record["task_id"] = stop.TaskID
+	}
+	context[timeBoxContextKey] = record
+
+	status := ObjectiveStatusPaused
+	return om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{
+		Status:              &status,
+		Context:             context,
+		ActiveExecutionTime: &stop.Elapsed,
+		ApprovalWaitTime:    &approvalWait,
+	})
+}
+
+// timeBoxRun measures one execution of an objective against its time box.
+// Only active execution counts: the clock stops while the execution waits
+// for ethical approval.
+type timeBoxRun struct {
+	clock utils.Clock
+	limit time.Duration
+	grace time.Duration
+
+	mu sync.Mutex
+
+	// active is the active time before the current segment, including
+	// earlier runs of the objective
+	active       time.Duration
+	segmentStart time.Time
+
+	// waiting counts approval waits in progress; the clock is stopped while it is positive
+	waiting      int
+	waitStart    time.Time
+	approvalWait time.Duration
+}
+
+// newTimeBoxRun starts measuring an execution. used is the active time
+// earlier runs already counted against the time box.
+func newTimeBoxRun(clock utils.Clock, limit, grace, used time.Duration) *timeBoxRun {
+	return &timeBoxRun{
+		clock:        clock,
+		limit:        limit,
+		grace:        grace,
+		active:       used,
+		segmentStart: clock.Now(),
+	}
+}
+
+// elapsed returns the active time counted against the time box.
+func (r *timeBoxRun) elapsed() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.elapsedLocked()
+}
+
+func (r *timeBoxRun) elapsedLocked() time.Duration {
+	if r.waiting > 0 {
+		return r.active
+	}
+	return r.active + r.clock.Since(r.segmentStart)
+}
+
+// approvalWaited returns the time spent waiting for approval during the run.
+func (r *timeBoxRun) approvalWaited() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.waiting > 0 {
+		return r.approvalWait + r.clock.Since(r.waitStart)
+	}
+	return r.approvalWait
+}
+
+// exceeded reports whether the time box has run out. A nil run has no limit.
+func (r *timeBoxRun) exceeded() bool {
+	return r != nil && r.limit > 0 && r.elapsed() >= r.limit
+}
+
+// beginWait stops the clock until the returned function is called.
+func (r *timeBoxRun) beginWait() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.waiting == 0 {
+		r.active += r.clock.Since(r.segmentStart)
+		r.waitStart = r.clock.Now()
+	}
+	r.waiting++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.waiting--
+			if r.waiting == 0 {
+				r.approvalWait += r.clock.Since(r.waitStart)
+				r.segmentStart = r.clock.Now()
+			}
+		})
+	}
+}
+
+// enforce returns a context for running one task that is cancelled once the
+// time box and its grace window have run out. The returned function releases
+// it. A nil run, or one without a limit, never cancels the task.
+func (r *timeBoxRun) enforce(ctx context.Context) (context.Context, func()) {
+	if r == nil || r.limit <= 0 {
+		return ctx, func() {}
+	}
+
+	taskCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		for {
+			left := r.limit + r.grace - r.elapsed()
+			if left <= 0 {
+				cancel()
+				return
+			}
+			// Approval waits stop the clock, so re-check when the timer fires
+			timer := r.clock.NewTimer(left)
+			select {
+			case <-taskCtx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+		}
+	}()
+	return taskCtx, cancel
+}
+
+type timeBoxKey struct{}
+
+// withTimeBox attaches a time box run to ctx for the execution beneath it.
+func withTimeBox(ctx context.Context, run *timeBoxRun) context.Context {
+	return context.WithValue(ctx, timeBoxKey{}, run)
+}
+
+// timeBoxFrom returns the time box run attached to ctx, or nil.
+func timeBoxFrom(ctx context.Context) *timeBoxRun {
+	run, _ := ctx.Value(timeBoxKey{}).(*timeBoxRun)
+	return run
+}
+
+// BeginApprovalWait tells the time box that the execution is waiting for
+// the user's ethical approval. Until the returned function is called the
+// wait is recorded as approval-wait time rather than counted against the
+// time box. Task executors call it around any wait for approval; outside a
+// time-boxed execution it does nothing.
+func BeginApprovalWait(ctx context.Context) func() {
+	run := timeBoxFrom(ctx)
+	if run == nil {
+		return func() {}
+	}
+	return run.beginWait()
+}
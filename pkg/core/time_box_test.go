@@ -0,0 +1,344 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// steppedExecutor completes every task, running step first when it is set.
+type steppedExecutor struct {
+	*MockTaskExecutor
+
+	mu    sync.Mutex
+	calls []string
+	step  func(ctx context.Context, task *ExecutionTask) error
+}
+
+func newSteppedExecutor(step func(ctx context.Context, task *ExecutionTask) error) *steppedExecutor {
+	return &steppedExecutor{MockTaskExecutor: NewMockTaskExecutor(), step: step}
+}
+
+func (e *steppedExecutor) ExecuteTask(ctx context.Context, task *ExecutionTask, fullContext map[string]interface{}) (*TaskResult, error) {
+	e.mu.Lock()
+	e.calls = append(e.calls, task.ID)
+	step := e.step
+	e.mu.Unlock()
+
+	if step != nil {
+		if err := step(ctx, task); err != nil {
+			return nil, err
+		}
+	}
+	return &TaskResult{TaskID: task.ID, Status: TaskStatusCompleted, TokensUsed: 10, Confidence: 0.9}, nil
+}
+
+func (e *steppedExecutor) executed() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.calls...)
+}
+
+func newTimeBoxClock() *utils.FakeClock {
+	return utils.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+}
+
+func TestTimeBoxes_For(t *testing.T) {
+	boxes := TimeBoxes{High: 3 * time.Hour, Normal: time.Hour, Low: 15 * time.Minute}
+
+	tests := []struct {
+		priority int
+		own      time.Duration
+		want     time.Duration
+	}{
+		{10, 0, 3 * time.Hour},
+		{8, 0, 3 * time.Hour},
+		{7, 0, time.Hour},
+		{4, 0, time.Hour},
+		{3, 0, 15 * time.Minute},
+		{1, 0, 15 * time.Minute},
+		{2, 5 * time.Hour, 5 * time.Hour},
+	}
+	for _, tt := range tests {
+		objective := &Objective{Priority: tt.priority, MaxExecutionTime: tt.own}
+		if got := boxes.For(objective); got != tt.want {
+			t.Errorf("Priority %d with own limit %s: expected %s, got %s", tt.priority, tt.own, tt.want, got)
+		}
+	}
+
+	if (TimeBoxes{}).grace() != DefaultTimeBoxGrace {
+		t.Error("Expected the default grace window when none is set")
+	}
+}
+
+func TestTimeBox_DeadlineMidTask(t *testing.T) {
+	rtc, store, _, _ := setupTestRTC(t)
+	clock := newTimeBoxClock()
+
+	started := make(chan struct{})
+	executor := newSteppedExecutor(func(ctx context.Context, task *ExecutionTask) error {
+		if task.ID != "task_2" {
+			return nil
+		}
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	rtc.executor = executor
+
+	ctx := withTimeBox(context.Background(), newTimeBoxRun(clock, time.Hour, time.Minute, 0))
+	type outcome struct {
+		result *ExecutionResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := rtc.ExecutePlan(ctx, createTestPlan())
+		done <- outcome{result, err}
+	}()
+
+	<-started
+	for clock.PendingTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The time box runs out while task_2 is in flight; it gets its grace window
+	clock.Advance(time.Hour)
+	select {
+	case <-done:
+		t.Fatal("Expected the task in flight to get a grace window")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Minute)
+	var out outcome
+	select {
+	case out = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the task to be cancelled after the grace window")
+	}
+
+	if !errors.Is(out.err, ErrTimeBoxExceeded) || errs.CodeOf(out.err) != errs.BudgetExceeded {
+		t.Fatalf("Expected a time box error, got %v", out.err)
+	}
+	result := out.result
+	if result.Status != ExecutionStatusCheckpointed || result.CheckpointTaskID != "task_2" {
+		t.Errorf("Expected a checkpoint at task_2, got %s at %q", result.Status, result.CheckpointTaskID)
+	}
+	if len(result.TaskResults) != 1 || result.TaskResults["task_1"] == nil {
+		t.Errorf("Expected only the completed task in the checkpoint, got %v", result.TaskResults)
+	}
+
+	// The stored checkpoint holds the plan and the completed task
+	node, err := store.GetNode(context.Background(), result.ID)
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	stored, err := executionResultFromNode(node)
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	if stored.Status != ExecutionStatusCheckpointed || stored.Plan == nil || len(stored.Plan.Tasks) != 2 {
+		t.Fatalf("Expected the checkpoint to keep its plan, got %s with %+v", stored.Status, stored.Plan)
+	}
+	if stored.TaskResults["task_1"] == nil || stored.TaskResults["task_1"].Status != TaskStatusCompleted || stored.TaskResults["task_2"] != nil {
+		t.Errorf("Expected task_1 completed and task_2 left for the resumed run, got %v", stored.TaskResults)
+	}
+	if stored.Status.IsTerminal() {
+		t.Error("Expected a checkpoint not to count as finished")
+	}
+
+	// Resuming runs only the task the checkpoint did not complete
+	executor.step = nil
+	resumed, err := rtc.ResumeExecution(context.Background(), result.ID)
+	if err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+	if resumed.Status != ExecutionStatusCompleted || resumed.SuccessfulTasks != 2 || len(resumed.TaskResults) != 2 {
+		t.Errorf("Expected the resumed run to complete both tasks, got %s with %d", resumed.Status, resumed.SuccessfulTasks)
+	}
+	if calls := executor.executed(); len(calls) != 3 || calls[2] != "task_2" {
+		t.Errorf("Expected only task_2 to run again, got %v", calls)
+	}
+
+	if _, err := rtc.ResumeExecution(context.Background(), resumed.ID); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected CONFLICT resuming a finished execution, got %v", err)
+	}
+}
+
+func TestTimeBox_GraceWindowKeepsFinishedTask(t *testing.T) {
+	rtc, _, _, _ := setupTestRTC(t)
+	clock := newTimeBoxClock()
+
+	// task_1 runs past the time box but finishes within the grace window
+	executor := newSteppedExecutor(func(ctx context.Context, task *ExecutionTask) error {
+		clock.Advance(time.Hour + 10*time.Second)
+		return nil
+	})
+	rtc.executor = executor
+
+	ctx := withTimeBox(context.Background(), newTimeBoxRun(clock, time.Hour, time.Minute, 0))
+	result, err := rtc.ExecutePlan(ctx, createTestPlan())
+	if !errors.Is(err, ErrTimeBoxExceeded) {
+		t.Fatalf("Expected a time box error, got %v", err)
+	}
+	if result.TaskResults["task_1"] == nil || result.TaskResults["task_1"].Status != TaskStatusCompleted {
+		t.Errorf("Expected the task finished in the grace window to be kept, got %v", result.TaskResults)
+	}
+	if calls := executor.executed(); len(calls) != 1 {
+		t.Errorf("Expected no task to start after the time box ran out, got %v", calls)
+	}
+}
+
+// newTimeBoxLearningLoop creates a learning loop whose tasks run on executor
+// and whose time boxes run on clock.
+func newTimeBoxLearningLoop(t *testing.T, executor TaskExecutor, clock utils.Clock) (*LearningLoop, *storage.Store, *MockLearningAgent) {
+	store, err := storage.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	learningAgent := NewMockLearningAgent()
+	learningAgent.mockAnalysis.OverallAssessment = OutcomeSuccess
+	cc := NewContemplativeCursor(store, NewMockLLMReasoner())
+	rtc := NewRealTimeCursor(store, executor, NewMockContextLoader())
+	ll := NewLearningLoop(store, cc, rtc, learningAgent)
+	ll.config.Clock = clock
+	return ll, store, learningAgent
+}
+
+func TestLearningLoop_TimeBoxPausesAndResumes(t *testing.T) {
+	clock := newTimeBoxClock()
+	executor := newSteppedExecutor(func(ctx context.Context, task *ExecutionTask) error {
+		if task.ID == "task_1" {
+			clock.Advance(2 * time.Hour)
+		}
+		return nil
+	})
+	ll, store, _ := newTimeBoxLearningLoop(t, executor, clock)
+	ll.SetTimeBoxes(TimeBoxes{Normal: time.Hour})
+
+	var alerts []string
+	ll.config.Notifier = NotifierFunc(func(ctx context.Context, title, message string) error {
+		alerts = append(alerts, title)
+		return nil
+	})
+
+	ctx := context.Background()
+	_, _, objective := createTestLearningObjective(t, store)
+	result, err := ll.ExecuteObjective(ctx, objective.ID)
+	if err != nil {
+		t.Fatalf("Expected the time box to pause rather than fail, got %v", err)
+	}
+	if result.FinalOutcome != OutcomeTimeBoxed || result.WasSuccessful {
+		t.Errorf("Expected a time-boxed outcome, got %s", result.FinalOutcome)
+	}
+
+	om := NewObjectiveManager(store)
+	paused, err := om.GetObjective(ctx, objective.ID)
+	if err != nil {
+		t.Fatalf("Failed to get objective: %v", err)
+	}
+	stop := paused.TimeBoxStop()
+	if paused.Status != ObjectiveStatusPaused || stop == nil {
+		t.Fatalf("Expected the objective paused with a time box stop, got %s", paused.Status)
+	}
+	if stop.Limit != time.Hour || stop.Elapsed != 2*time.Hour || stop.TaskID != "task_2" || stop.ExecutionID == "" {
+		t.Errorf("Unexpected time box stop: %+v", stop)
+	}
+	if paused.ActiveExecutionTime != 2*time.Hour {
+		t.Errorf("Expected 2h of active execution, got %s", paused.ActiveExecutionTime)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("Expected one alert asking how to continue, got %v", alerts)
+	}
+	if stopped, err := om.TimeBoxedObjectives(ctx); err != nil || len(stopped) != 1 {
+		t.Errorf("Expected the objective to wait for an answer, got %d (%v)", len(stopped), err)
+	}
+
+	// Extending continues from the checkpoint
+	if _, err := om.ResolveTimeBox(ctx, objective.ID, TimeBoxExtend, 0); errs.CodeOf(err) != errs.Validation {
+		t.Errorf("Expected VALIDATION for an empty extension, got %v", err)
+	}
+	extended, err := om.ResolveTimeBox(ctx, objective.ID, TimeBoxExtend, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to extend time box: %v", err)
+	}
+	if extended.Status != ObjectiveStatusPending || extended.MaxExecutionTime != 3*time.Hour {
+		t.Errorf("Expected a pending objective with a 3h time box, got %s with %s", extended.Status, extended.MaxExecutionTime)
+	}
+
+	firstRun := len(executor.executed())
+	result, err = ll.ExecuteObjective(ctx, objective.ID)
+	if err != nil || !result.WasSuccessful {
+		t.Fatalf("Expected the resumed run to succeed, got %v (%s)", err, result.FinalOutcome)
+	}
+	for _, taskID := range executor.executed()[firstRun:] {
+		if taskID == "task_1" {
+			t.Error("Expected the resumed run to skip the task the checkpoint completed")
+		}
+	}
+
+	finished, err := om.GetObjective(ctx, objective.ID)
+	if err != nil {
+		t.Fatalf("Failed to get objective: %v", err)
+	}
+	if finished.TimeBoxStop() != nil {
+		t.Error("Expected the time box stop to be cleared once resumed")
+	}
+	if _, err := om.ResolveTimeBox(ctx, objective.ID, TimeBoxResume, 0); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected CONFLICT resolving an objective that is not paused, got %v", err)
+	}
+}
+
+func TestLearningLoop_ApprovalWaitNotCounted(t *testing.T) {
+	clock := newTimeBoxClock()
+	executor := newSteppedExecutor(func(ctx context.Context, task *ExecutionTask) error {
+		if task.ID != "task_1" {
+			return nil
+		}
+		done := BeginApprovalWait(ctx)
+		clock.Advance(3 * time.Hour)
+		done()
+		clock.Advance(10 * time.Minute)
+		return nil
+	})
+	ll, store, _ := newTimeBoxLearningLoop(t, executor, clock)
+
+	ctx := context.Background()
+	_, _, objective := createTestLearningObjective(t, store)
+	om := NewObjectiveManager(store)
+	limit := time.Hour
+	if _, err := om.UpdateObjective(ctx, objective.ID, ObjectiveUpdates{MaxExecutionTime: &limit}); err != nil {
+		t.Fatalf("Failed to set time box: %v", err)
+	}
+
+	result, err := ll.ExecuteObjective(ctx, objective.ID)
+	if err != nil {
+		t.Fatalf("ExecuteObjective failed: %v", err)
+	}
+	if result.FinalOutcome == OutcomeTimeBoxed || !result.WasSuccessful {
+		t.Errorf("Expected approval waits not to run down the time box, got %s", result.FinalOutcome)
+	}
+	if result.ApprovalWaitTime != 3*time.Hour || result.ActiveExecutionTime != 10*time.Minute {
+		t.Errorf("Expected 10m active and 3h waiting, got %s and %s", result.ActiveExecutionTime, result.ApprovalWaitTime)
+	}
+
+	updated, err := om.GetObjective(ctx, objective.ID)
+	if err != nil {
+		t.Fatalf("Failed to get objective: %v", err)
+	}
+	if updated.ActiveExecutionTime != 10*time.Minute || updated.ApprovalWaitTime != 3*time.Hour {
+		t.Errorf("Expected the times to be recorded, got %s active and %s waiting", updated.ActiveExecutionTime, updated.ApprovalWaitTime)
+	}
+	if updated.MaxExecutionTime != time.Hour {
+		t.Errorf("Expected the time box to be kept, got %s", updated.MaxExecutionTime)
+	}
+}
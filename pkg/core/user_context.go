@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
 )
 
@@ -45,6 +46,31 @@ const (
 	ContextSourceFeedback ContextSource = "feedback"
 )
 
+// ContextStatus gates whether a context entry informs the system.
+type ContextStatus string
+
+const (
+	// ContextStatusActive entries are retrieved for routing and planning
+	ContextStatusActive ContextStatus = "active"
+
+	// ContextStatusPending entries were mined from behavior and wait for
+	// the user to confirm them; they are never retrieved
+	ContextStatusPending ContextStatus = "pending"
+
+	// ContextStatusDismissed entries were rejected by the user and are kept
+	// so the same statement is not proposed again
+	ContextStatusDismissed ContextStatus = "dismissed"
+
+	// ContextStatusSuperseded entries were merged into a canonical entry by
+	// consolidation; they are kept for history and never retrieved
+	ContextStatusSuperseded ContextStatus = "superseded"
+
+	// ContextStatusRevoked entries were forgotten at the user's request;
+	// they are never retrieved, reinforced or changed again, and are kept
+	// so their history stays auditable
+	ContextStatusRevoked ContextStatus = "revoked"
+)
+
 // UserContext represents learned information about the user that informs
 // system judgment and method selection. Context evolves temporally and
 // includes confidence scoring for reliability.
@@ -76,10 +102,59 @@ type UserContext struct {
 	// UserID identifies which user this context belongs to (for future multi-user)
 	UserID string
 
+	// Status is whether the entry is active, pending confirmation or dismissed
+	Status ContextStatus
+
+	// Reinforcements counts how often the entry was learned again and merged
+	Reinforcements int
+
+	// MergedFrom lists the entries consolidation merged into this one. On a
+	// pending entry, they are superseded when it is confirmed.
+	MergedFrom []string
+
+	// RevokedAt is when the user had a revoked entry forgotten, and
+	// RevokeReason why
+	RevokedAt    time.Time
+	RevokeReason string
+
 	// store reference for database operations
 	store *storage.Store
 }
 
+// ContextRankingConfig configures how GetRelevantContext ranks entries and
+// when LearnContext treats a statement as one it already knows.
+type ContextRankingConfig struct {
+	// HalfLife is the time since an entry was last learned or validated at
+	// which its recency halves the entry's score
+	HalfLife time.Duration
+
+	// SourceWeights scale an entry's score by where it came from, so what
+	// the user said outranks what was learned from feedback or inferred
+	SourceWeights map[ContextSource]float64
+
+	// DuplicateSimilarity is the word overlap (0-1) at which a learned
+	// statement reinforces an active entry of its category instead of
+	// being stored again
+	DuplicateSimilarity float64
+
+	// ReinforceBoost is the confidence a reinforced entry gains
+	ReinforceBoost float64
+}
+
+// DefaultContextRankingConfig returns the default ranking configuration.
+func DefaultContextRankingConfig() ContextRankingConfig {
+	return ContextRankingConfig{
+		HalfLife: 30 * 24 * time.Hour,
+		SourceWeights: map[ContextSource]float64{
+			ContextSourceExplicit: 1.0,
+			ContextSourceFeedback: 0.85,
+			ContextSourceInferred: 0.7,
+		},
+		DuplicateSimilarity: 0.8,
+		ReinforceBoost:      0.1,
+	}
+}
+
 // UserContextManager provides operations for managing user context in the storage system.
 type UserContextManager struct {
 	store *storage.Store
@@ -87,6 +162,8 @@ type UserContextManager struct {
 	// Configuration for temporal confidence decay
 	confidenceDecayRate float64 // How much confidence decreases per day
 	minConfidence      float64 // Minimum confidence before context is considered stale
+
+	ranking ContextRankingConfig
 }
 
 // NewUserContextManager creates a new manager for user context operations.
@@ -95,23 +172,118 @@ func NewUserContextManager(store *storage.Store) *UserContextManager {
 		store:               store,
 		confidenceDecayRate: 0.01, // 1% decay per day
 		minConfidence:      0.1,   // 10% minimum confidence
+		ranking:             DefaultContextRankingConfig(),
 	}
 }
 
-// LearnContext creates a new context entry and stores it in the system.
+// SetRankingConfig replaces the ranking configuration. Zero values fall back
+// to the defaults, as do sources missing from SourceWeights.
+func (ucm *UserContextManager) SetRankingConfig(config ContextRankingConfig) {
+	defaults := DefaultContextRankingConfig()
+	if config.HalfLife <= 0 {
+		config.HalfLife = defaults.HalfLife
+	}
+	weights := defaults.SourceWeights
+	for source, weight := range config.SourceWeights {
+		weights[source] = weight
+	}
+	config.SourceWeights = weights
+	if config.DuplicateSimilarity <= 0 {
+		config.DuplicateSimilarity = defaults.DuplicateSimilarity
+	}
+	if config.ReinforceBoost <= 0 {
+		config.ReinforceBoost = defaults.ReinforceBoost
+	}
+	ucm.ranking = config
+}
+
+// LearnContext records a context entry. A statement that nearly repeats an
+// active entry of the same category reinforces that entry instead: its
+// confidence rises, it counts as validated now, and the stronger of the two
+// sources is kept.
 func (ucm *UserContextManager) LearnContext(ctx context.Context, category ContextCategory, content string, source ContextSource, relevanceTags []string, userID string) (*UserContext, error) {
+	if err := checkContextFields(category, content, source); err != nil {
+		return nil, err
+	}
+
+	known, err := ucm.findDuplicate(ctx, category, content, userID)
+	if err != nil {
+		return nil, err
+	}
+	if known != nil {
+		return ucm.reinforceContext(ctx, known, source, relevanceTags)
+	}
+	return ucm.storeContext(ctx, category, content, source, relevanceTags, userID)
+}
+
+// checkContextFields validates the fields of a new context entry.
+func checkContextFields(category ContextCategory, content string, source ContextSource) error {
 	if content == "" {
-		return nil, fmt.Errorf("context content cannot be empty")
+		return errs.New(errs.Validation, "context content cannot be empty")
 	}
 
 	if !isValidCategory(category) {
-		return nil, fmt.Errorf("invalid context category: %s", category)
+		return fmt.Errorf("invalid context category: %s", category)
 	}
 
 	if !isValidSource(source) {
-		return nil, fmt.Errorf("invalid context source: %s", source)
+		return fmt.Errorf("invalid context source: %s", source)
 	}
+	return nil
+}
 
+// findDuplicate returns the active entry of the user and category most
+// similar to content, if it is similar enough to be the same statement.
+func (ucm *UserContextManager) findDuplicate(ctx context.Context, category ContextCategory, content string, userID string) (*UserContext, error) {
+	known, err := ucm.listContexts(ctx, category, userID)
+	if err != nil {
+		return nil, err
+	}
+	sortByStrength(known)
+
+	var match *UserContext
+	best := 0.0
+	for _, existing := range known {
+		if existing.Status != ContextStatusActive || existing.UserID != userID {
+			continue
+		}
+		similarity := statementSimilarity(existing.Content, content)
+		if normalizeStatement(existing.Content) == normalizeStatement(content) {
+			similarity = 1
+		}
+		if similarity > best {
+			match, best = existing, similarity
+		}
+	}
+	if best < ucm.ranking.DuplicateSimilarity {
+		return nil, nil
+	}
+	return match, nil
+}
+
+// reinforceContext strengthens an entry that was learned again.
+func (ucm *UserContextManager) reinforceContext(ctx context.Context, known *UserContext, source ContextSource, relevanceTags []string) (*UserContext, error) {
+	confidence := math.Min(math.Max(known.Confidence, ucm.getInitialConfidence(source))+ucm.ranking.ReinforceBoost, 1.0)
+	reinforcements := known.Reinforcements + 1
+	if sourceRank(known.Source) > sourceRank(source) {
+		source = known.Source
+	}
+
+	reinforced, err := ucm.UpdateContext(ctx, known.ID, UserContextUpdates{
+		Source:         &source,
+		Confidence:     &confidence,
+		Reinforcements: &reinforcements,
+		RelevanceTags:  mergeTags(known.RelevanceTags, relevanceTags),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reinforce user context: %w", err)
+	}
+	return reinforced, nil
+}
+
+// storeContext stores a new active context entry without looking for one
+// it repeats.
+func (ucm *UserContextManager) storeContext(ctx context.Context, category ContextCategory, content string, source ContextSource, relevanceTags []string, userID string) (*UserContext, error) {
 	now := time.Now()
 
 	// Initial confidence based on source reliability
@@ -127,6 +299,7 @@ func (ucm *UserContextManager) LearnContext(ctx context.Context, category Contex
 		"last_validated": now.Format(time.RFC3339),
 		"created_at":     now.Format(time.RFC3339),
 		"user_id":        userID,
+		"status":         string(ContextStatusActive),
 	}
 
 	// Create storage node
@@ -148,12 +321,88 @@ func (ucm *UserContextManager) LearnContext(ctx context.Context, category Contex
 		LastValidated: now,
 		CreatedAt:     now,
 		UserID:        userID,
+		Status:        ContextStatusActive,
 		store:         ucm.store,
 	}
 
 	return userContext, nil
 }
 
+// ProposeContext stores a context entry learned from behavior as pending.
+// It has no effect on retrieval until ConfirmContext activates it.
+func (ucm *UserContextManager) ProposeContext(ctx context.Context, category ContextCategory, content string, confidence float64, relevanceTags []string, userID string) (*UserContext, error) {
+	if err := checkContextFields(category, content, ContextSourceInferred); err != nil {
+		return nil, err
+	}
+	proposed, err := ucm.storeContext(ctx, category, content, ContextSourceInferred, relevanceTags, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := ContextStatusPending
+	confidence = math.Max(0, math.Min(confidence, 1))
+	return ucm.UpdateContext(ctx, proposed.ID, UserContextUpdates{Status: &status, Confidence: &confidence})
+}
+
+// ConfirmContext activates a pending entry. Confirmation counts as feedback,
+// so the entry starts with at least feedback confidence.
+func (ucm *UserContextManager) ConfirmContext(ctx context.Context, contextID string) (*UserContext, error) {
+	current, err := ucm.GetContext(ctx, contextID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != ContextStatusPending {
+		return nil, fmt.Errorf("context %s is %s, not pending", contextID, current.Status)
+	}
+
+	status := ContextStatusActive
+	confidence := math.Max(current.Confidence, ucm.getInitialConfidence(ContextSourceFeedback))
+	confirmed, err := ucm.UpdateContext(ctx, contextID, UserContextUpdates{Status: &status, Confidence: &confidence})
+	if err != nil {
+		return nil, err
+	}
+
+	// A confirmed merge replaces the entries it was proposed for
+	if err := ucm.supersede(ctx, confirmed.MergedFrom, confirmed.ID); err != nil {
+		return nil, err
+	}
+	return confirmed, nil
+}
+
+// DismissContext rejects a pending entry.
+func (ucm *UserContextManager) DismissContext(ctx context.Context, contextID string) (*UserContext, error) {
+	current, err := ucm.GetContext(ctx, contextID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != ContextStatusPending {
+		return nil, fmt.Errorf("context %s is %s, not pending", contextID, current.Status)
+	}
+
+	status := ContextStatusDismissed
+	return ucm.UpdateContext(ctx, contextID, UserContextUpdates{Status: &status})
+}
+
+// GetPendingContext lists the entries waiting for confirmation, most
+// confident first.
+func (ucm *UserContextManager) GetPendingContext(ctx context.Context, userID string) ([]*UserContext, error) {
+	contexts, err := ucm.listContexts(ctx, "", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*UserContext
+	for _, userContext := range contexts {
+		if userContext.Status == ContextStatusPending {
+			pending = append(pending, userContext)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Confidence > pending[j].Confidence
+	})
+	return pending, nil
+}
+
 // GetContext retrieves a context entry by ID.
 func (ucm *UserContextManager) GetContext(ctx context.Context, contextID string) (*UserContext, error) {
 	node, err := ucm.store.GetNode(ctx, contextID)
@@ -162,7 +411,7 @@ func (ucm *UserContextManager) GetContext(ctx context.Context, contextID string)
 	}
 
 	if node.Type != "user_context" {
-		return nil, fmt.Errorf("node %s is not a user context (type: %s)", contextID, node.Type)
+		return nil, errs.Newf(errs.NotFound, "node %s is not a user context (type: %s)", contextID, node.Type).With("id", contextID)
 	}
 
 	userContext, err := ucm.nodeToUserContext(node)
@@ -183,6 +432,9 @@ func (ucm *UserContextManager) UpdateContext(ctx context.Context, contextID stri
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current context for update: %w", err)
 	}
+	if currentContext.Status == ContextStatusRevoked {
+		return nil, errs.Newf(errs.Conflict, "context %s was forgotten and cannot be changed", contextID).With("id", contextID)
+	}
 
 	// Apply updates with defaults from current context
 	category := currentContext.Category
@@ -197,7 +449,7 @@ func (ucm *UserContextManager) UpdateContext(ctx context.Context, contextID stri
 	if updates.Content != nil {
 		content = *updates.Content
 		if content == "" {
-			return nil, fmt.Errorf("context content cannot be empty")
+			return nil, errs.New(errs.Validation, "context content cannot be empty")
 		}
 	}
 
@@ -213,7 +465,7 @@ func (ucm *UserContextManager) UpdateContext(ctx context.Context, contextID stri
 	if updates.Confidence != nil {
 		confidence = *updates.Confidence
 		if confidence < 0.0 || confidence > 1.0 {
-			return nil, fmt.Errorf("confidence must be between 0.0 and 1.0, got %f", confidence)
+			return nil, errs.Newf(errs.Validation, "confidence must be between 0.0 and 1.0, got %f", confidence)
 		}
 	}
 
@@ -222,9 +474,33 @@ func (ucm *UserContextManager) UpdateContext(ctx context.Context, contextID stri
 		relevanceTags = updates.RelevanceTags
 	}
 
+	status := currentContext.Status
+	if updates.Status != nil {
+		status = *updates.Status
+	}
+
+	reinforcements := currentContext.Reinforcements
+	if updates.Reinforcements != nil {
+		reinforcements = *updates.Reinforcements
+	}
+
+	mergedFrom := currentContext.MergedFrom
+	if updates.MergedFrom != nil {
+		mergedFrom = updates.MergedFrom
+	}
+
 	// Update validation time
 	now := time.Now()
 
+	var revokedAt time.Time
+	var revokeReason string
+	if status == ContextStatusRevoked {
+		revokedAt = now
+		if updates.RevokeReason != nil {
+			revokeReason = *updates.RevokeReason
+		}
+	}
+
 	// Prepare updated data
 	data := map[string]interface{}{
 		"category":       string(category),
@@ -235,6 +511,15 @@ func (ucm *UserContextManager) UpdateContext(ctx context.Context, contextID stri
 		"last_validated": now.Format(time.RFC3339),
 		"created_at":     currentContext.CreatedAt.Format(time.RFC3339),
 		"user_id":        currentContext.UserID,
+		"status":         string(status),
+		"reinforcements": reinforcements,
+	}
+	if len(mergedFrom) > 0 {
+		data["merged_from"] = mergedFrom
+	}
+	if !revokedAt.IsZero() {
+		data["revoked_at"] = revokedAt.Format(time.RFC3339)
+		data["revoke_reason"] = revokeReason
 	}
 
 	// Update in storage
@@ -244,16 +529,21 @@ func (ucm *UserContextManager) UpdateContext(ctx context.Context, contextID stri
 
 	// Return updated context
 	return &UserContext{
-		ID:            contextID,
-		Category:      category,
-		Content:       content,
-		Source:        source,
-		Confidence:    confidence,
-		RelevanceTags: relevanceTags,
-		LastValidated: now,
-		CreatedAt:     currentContext.CreatedAt,
-		UserID:        currentContext.UserID,
-		store:         ucm.store,
+		ID:             contextID,
+		Category:       category,
+		Content:        content,
+		Source:         source,
+		Confidence:     confidence,
+		RelevanceTags:  relevanceTags,
+		LastValidated:  now,
+		CreatedAt:      currentContext.CreatedAt,
+		UserID:         currentContext.UserID,
+		Status:         status,
+		Reinforcements: reinforcements,
+		MergedFrom:     mergedFrom,
+		RevokedAt:      revokedAt,
+		RevokeReason:   revokeReason,
+		store:          ucm.store,
 	}, nil
 }
 
@@ -265,36 +555,37 @@ type UserContextUpdates struct {
 	Source        *ContextSource
 	Confidence    *float64
 	RelevanceTags []string
+
+	Status         *ContextStatus
+	Reinforcements *int
+	MergedFrom     []string
+
+	// RevokeReason is recorded when Status changes to ContextStatusRevoked
+	RevokeReason *string
 }
 
 // GetRelevantContext retrieves context entries relevant to the given objective.
-// Results are ranked by relevance score combining confidence and tag matching.
+// Results are ranked by relevance score combining confidence, tag and text
+// matching, recency and source, so a recent statement outranks an older one
+// it contradicts.
 func (ucm *UserContextManager) GetRelevantContext(ctx context.Context, objectiveText string, userID string, limit int) ([]*UserContext, error) {
 	if limit <= 0 {
 		limit = 10 // Default limit
 	}
 
 	// Get all user contexts for this user
-	query := ucm.store.Nodes().OfType("user_context")
-	if userID != "" {
-		query = query.WithData("user_id", userID)
-	}
-
-	nodes, err := query.All()
+	all, err := ucm.listContexts(ctx, "", userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query user contexts: %w", err)
+		return nil, err
 	}
 
 	var contexts []*UserContext
-	for _, node := range nodes {
-		userContext, err := ucm.nodeToUserContext(node)
-		if err != nil {
-			continue // Skip invalid nodes
+	for _, userContext := range all {
+		// Unconfirmed and dismissed entries never inform the system
+		if userContext.Status != ContextStatusActive {
+			continue
 		}
 
-		// Apply temporal confidence decay
-		userContext.Confidence = ucm.applyConfidenceDecay(userContext.Confidence, userContext.LastValidated)
-
 		// Skip contexts with very low confidence
 		if userContext.Confidence < ucm.minConfidence {
 			continue
@@ -329,16 +620,42 @@ type ScoredContext struct {
 	RelevanceScore float64
 }
 
-// GetContextByCategory retrieves all context entries of a specific category for a user.
+// GetContextByCategory retrieves all active context entries of a specific category for a user.
 func (ucm *UserContextManager) GetContextByCategory(ctx context.Context, category ContextCategory, userID string) ([]*UserContext, error) {
-	query := ucm.store.Nodes().OfType("user_context").WithData("category", string(category))
+	all, err := ucm.listContexts(ctx, category, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contexts by category: %w", err)
+	}
+
+	var contexts []*UserContext
+	for _, userContext := range all {
+		if userContext.Status == ContextStatusActive {
+			contexts = append(contexts, userContext)
+		}
+	}
+
+	// Sort by confidence (descending)
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].Confidence > contexts[j].Confidence
+	})
+
+	return contexts, nil
+}
+
+// listContexts returns every context entry of a user in any status, with
+// confidence decay applied. An empty category matches all categories.
+func (ucm *UserContextManager) listContexts(ctx context.Context, category ContextCategory, userID string) ([]*UserContext, error) {
+	query := ucm.store.Nodes().OfType("user_context")
+	if category != "" {
+		query = query.WithData("category", string(category))
+	}
 	if userID != "" {
 		query = query.WithData("user_id", userID)
 	}
 
-	nodes, err := query.All()
+	nodes, err := query.AllContext(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query contexts by category: %w", err)
+		return nil, fmt.Errorf("failed to query user contexts: %w", err)
 	}
 
 	var contexts []*UserContext
@@ -353,12 +670,6 @@ func (ucm *UserContextManager) GetContextByCategory(ctx context.Context, categor
 
 		contexts = append(contexts, userContext)
 	}
-
-	// Sort by confidence (descending)
-	sort.Slice(contexts, func(i, j int) bool {
-		return contexts[i].Confidence > contexts[j].Confidence
-	})
-
 	return contexts, nil
 }
 
@@ -397,10 +708,12 @@ func (ucm *UserContextManager) scoreContexts(contexts []*UserContext, objectiveT
 	return scoredContexts
 }
 
-// calculateRelevanceScore computes a relevance score based on confidence and keyword matching.
+// calculateRelevanceScore computes a relevance score based on confidence,
+// keyword matching, recency and source.
 func (ucm *UserContextManager) calculateRelevanceScore(context *UserContext, objectiveWords []string) float64 {
-	// Base score is the confidence
-	score := context.Confidence
+	// Base score is the confidence, weighted by how recently the entry was
+	// learned or validated and by how reliable its source is
+	score := context.Confidence * ucm.recencyWeight(context.LastValidated) * ucm.sourceWeight(context.Source)
 
 	// Content matching score
 	contextWords := strings.Fields(strings.ToLower(context.Content))
@@ -422,6 +735,23 @@ func (ucm *UserContextManager) calculateRelevanceScore(context *UserContext, obj
 	return totalScore
 }
 
+// recencyWeight halves for every half-life since lastValidated.
+func (ucm *UserContextManager) recencyWeight(lastValidated time.Time) float64 {
+	age := time.Since(lastValidated)
+	if age <= 0 {
+		return 1.0
+	}
+	return math.Pow(0.5, float64(age)/float64(ucm.ranking.HalfLife))
+}
+
+// sourceWeight returns the ranking weight of a source (1 if unweighted).
+func (ucm *UserContextManager) sourceWeight(source ContextSource) float64 {
+	if weight, ok := ucm.ranking.SourceWeights[source]; ok {
+		return weight
+	}
+	return 1.0
+}
+
 // calculateWordMatchScore computes how well two sets of words match.
 func (ucm *UserContextManager) calculateWordMatchScore(contextWords, objectiveWords []string) float64 {
 	if len(contextWords) == 0 || len(objectiveWords) == 0 {
@@ -511,17 +841,8 @@ func (ucm *UserContextManager) nodeToUserContext(node *storage.Node) (*UserConte
 		return nil, fmt.Errorf("invalid or missing confidence in context node %s", node.ID)
 	}
 
-	// Handle relevance tags (could be stored as interface{})
-	var relevanceTags []string
-	if tags, exists := node.Data["relevance_tags"]; exists {
-		if tagSlice, ok := tags.([]interface{}); ok {
-			for _, tag := range tagSlice {
-				if tagStr, ok := tag.(string); ok {
-					relevanceTags = append(relevanceTags, tagStr)
-				}
-			}
-		}
-	}
+	// Handle relevance tags (a []string until a JSON round trip makes them []interface{})
+	relevanceTags := toStringSlice(node.Data["relevance_tags"])
 
 	lastValidatedStr, ok := node.Data["last_validated"].(string)
 	if !ok {
@@ -543,17 +864,37 @@ func (ucm *UserContextManager) nodeToUserContext(node *storage.Node) (*UserConte
 
 	userID, _ := node.Data["user_id"].(string) // Optional field
 
+	// Entries stored before confirmation gating are active
+	status := ContextStatusActive
+	if statusStr, ok := node.Data["status"].(string); ok && statusStr != "" {
+		status = ContextStatus(statusStr)
+	}
+
+	reinforcements := int(getFloat64(node.Data, "reinforcements"))
+	mergedFrom := toStringSlice(node.Data["merged_from"])
+
+	var revokedAt time.Time
+	if revokedAtStr, ok := node.Data["revoked_at"].(string); ok {
+		revokedAt, _ = time.Parse(time.RFC3339, revokedAtStr)
+	}
+	revokeReason, _ := node.Data["revoke_reason"].(string)
+
 	return &UserContext{
-		ID:            node.ID,
-		Category:      category,
-		Content:       content,
-		Source:        source,
-		Confidence:    confidence,
-		RelevanceTags: relevanceTags,
-		LastValidated: lastValidated,
-		CreatedAt:     createdAt,
-		UserID:        userID,
-		store:         ucm.store,
+		ID:             node.ID,
+		Category:       category,
+		Content:        content,
+		Source:         source,
+		Confidence:     confidence,
+		RelevanceTags:  relevanceTags,
+		LastValidated:  lastValidated,
+		CreatedAt:      createdAt,
+		UserID:         userID,
+		Status:         status,
+		Reinforcements: reinforcements,
+		MergedFrom:     mergedFrom,
+		RevokedAt:      revokedAt,
+		RevokeReason:   revokeReason,
+		store:          ucm.store,
 	}, nil
 }
 
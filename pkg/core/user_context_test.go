@@ -0,0 +1,164 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// ageContext makes an entry look learned and last validated age ago.
+func ageContext(t *testing.T, ucm *UserContextManager, id string, age time.Duration) {
+	t.Helper()
+	node, err := ucm.store.GetNode(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get context node: %v", err)
+	}
+	data := make(map[string]interface{}, len(node.Data))
+	for key, value := range node.Data {
+		data[key] = value
+	}
+	then := time.Now().Add(-age).Format(time.RFC3339)
+	data["created_at"] = then
+	data["last_validated"] = then
+	if err := ucm.store.UpdateNode(context.Background(), id, data); err != nil {
+		t.Fatalf("Failed to age context: %v", err)
+	}
+}
+
+func TestGetRelevantContextPrefersNewerStatement(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	old, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "I prefer short answers",
+		ContextSourceExplicit, []string{"answers"}, "user1")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+	ageContext(t, ucm, old.ID, 30*24*time.Hour)
+
+	// The correction comes from feedback, which alone weighs less than an
+	// explicit statement
+	correction, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "I prefer detailed answers with examples",
+		ContextSourceFeedback, []string{"answers"}, "user1")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+	if correction.ID == old.ID {
+		t.Fatal("Expected a contradicting statement to be stored separately")
+	}
+
+	ranked, err := ucm.GetRelevantContext(ctx, "Draft answers to the customer questions", "user1", 1)
+	if err != nil {
+		t.Fatalf("Failed to get relevant context: %v", err)
+	}
+	if len(ranked) != 1 || ranked[0].ID != correction.ID {
+		t.Fatalf("Expected the newer correction to rank first, got %+v", ranked)
+	}
+
+	// Stating the old preference again makes it current
+	restated, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "I prefer short answers!",
+		ContextSourceExplicit, []string{"brevity"}, "user1")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+	if restated.ID != old.ID || restated.Reinforcements != 1 {
+		t.Fatalf("Expected the restatement to reinforce %s, got %+v", old.ID, restated)
+	}
+	ranked, err = ucm.GetRelevantContext(ctx, "Draft answers to the customer questions", "user1", 2)
+	if err != nil {
+		t.Fatalf("Failed to get relevant context: %v", err)
+	}
+	if len(ranked) != 2 || ranked[0].ID != old.ID {
+		t.Errorf("Expected the restated preference to rank first, got %+v", ranked)
+	}
+}
+
+func TestLearnContextReinforcesDuplicates(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	first, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "Prefers markdown tables for comparisons",
+		ContextSourceInferred, []string{"markdown"}, "user1")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+	again, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "prefers Markdown tables for comparisons.",
+		ContextSourceExplicit, []string{"tables"}, "user1")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+
+	if again.ID != first.ID {
+		t.Fatalf("Expected the duplicate to reinforce %s, got a new entry %s", first.ID, again.ID)
+	}
+	if again.Confidence <= first.Confidence || again.Reinforcements != 1 {
+		t.Errorf("Expected confidence above %.2f and one reinforcement, got %.2f and %d",
+			first.Confidence, again.Confidence, again.Reinforcements)
+	}
+	if again.Source != ContextSourceExplicit {
+		t.Errorf("Expected the stronger source to be kept, got %s", again.Source)
+	}
+	if len(again.RelevanceTags) != 2 {
+		t.Errorf("Expected the tags to be merged, got %v", again.RelevanceTags)
+	}
+
+	// Other users and categories keep their own entries
+	other, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "Prefers markdown tables for comparisons",
+		ContextSourceInferred, nil, "user2")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+	pattern, err := ucm.LearnContext(ctx, ContextCategoryPatterns, "Prefers markdown tables for comparisons",
+		ContextSourceInferred, nil, "user1")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+	if other.ID == first.ID || pattern.ID == first.ID {
+		t.Error("Expected entries of other users and categories not to be reinforced")
+	}
+
+	entries, err := ucm.GetContextByCategory(ctx, ContextCategoryPreferences, "user1")
+	if err != nil {
+		t.Fatalf("Failed to list context: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected one preference entry, got %d", len(entries))
+	}
+}
+
+func TestGetRelevantContextRankingConfig(t *testing.T) {
+	ctx := context.Background()
+	ucm := NewUserContextManager(setupTestStore(t))
+
+	explicit, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "Use metric units in reports",
+		ContextSourceExplicit, []string{"reports"}, "user1")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+	feedback, err := ucm.LearnContext(ctx, ContextCategoryPreferences, "Keep reports under two pages",
+		ContextSourceFeedback, []string{"reports"}, "user1")
+	if err != nil {
+		t.Fatalf("Failed to learn context: %v", err)
+	}
+	ageContext(t, ucm, explicit.ID, 7*24*time.Hour)
+
+	rankFirst := func() string {
+		t.Helper()
+		ranked, err := ucm.GetRelevantContext(ctx, "Write the quarterly reports", "user1", 1)
+		if err != nil || len(ranked) != 1 {
+			t.Fatalf("Failed to get relevant context: %v", err)
+		}
+		return ranked[0].ID
+	}
+
+	// A week barely matters against a month's half-life
+	if first := rankFirst(); first != explicit.ID {
+		t.Errorf("Expected the explicit statement to rank first, got %s", first)
+	}
+
+	// With a one-day half-life the week-old statement has faded
+	ucm.SetRankingConfig(ContextRankingConfig{HalfLife: 24 * time.Hour})
+	if first := rankFirst(); first != feedback.ID {
+		t.Errorf("Expected the recent feedback to rank first, got %s", first)
+	}
+}
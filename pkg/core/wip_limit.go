@@ -0,0 +1,244 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// ErrWIPLimitReached is matched by the *WIPLimitError returned when starting an
+// objective would exceed a work-in-progress limit.
+var ErrWIPLimitReached = errs.New(errs.WIPLimit, "work-in-progress limit reached")
+
+// WIPLimits caps how many objectives may be in progress at once, so work
+// finishes instead of thrashing across many parallel objectives. Zero means
+// no limit. Limits only prevent new starts: lowering one never pauses
+// objectives already in progress.
+type WIPLimits struct {
+	// MaxInProgress caps in-progress objectives across all goals
+	MaxInProgress int
+
+	// MaxInProgressPerGoal caps in-progress objectives within each goal
+	MaxInProgressPerGoal int
+
+	// Goals overrides MaxInProgressPerGoal for individual goals, by goal ID
+	Goals map[string]int
+}
+
+// GoalLimit returns the per-goal limit that applies to goalID.
+func (l WIPLimits) GoalLimit(goalID string) int {
+	if limit, ok := l.Goals[goalID]; ok {
+		return limit
+	}
+	return l.MaxInProgressPerGoal
+}
+
+// WIPLimitError reports which limit a start would exceed and the objectives
+// holding it, so the caller can decide what to pause.
+type WIPLimitError struct {
+	// GoalID is set when the goal's own limit was reached, and empty for the global limit
+	GoalID string
+
+	Limit int
+
+	// InProgress are the objectives counted against the limit
+	InProgress []*Objective
+}
+
+func (e *WIPLimitError) Error() string {
+	var b strings.Builder
+	if e.GoalID != "" {
+		fmt.Fprintf(&b, "goal %s already has %d of %d objectives in progress", e.GoalID, len(e.InProgress), e.Limit)
+	} else {
+		fmt.Fprintf(&b, "%d of %d objectives already in progress", len(e.InProgress), e.Limit)
+	}
+	b.WriteString("; pause one first or override the limit:")
+	for _, objective := range e.InProgress {
+		fmt.Fprintf(&b, "\n  %s  %s", objective.ID, objective.Title)
+	}
+	return b.String()
+}
+
+// Is matches ErrWIPLimitReached.
+func (e *WIPLimitError) Is(target error) bool {
+	return target == ErrWIPLimitReached
+}
+
+// ErrorCode returns errs.WIPLimit.
+func (e *WIPLimitError) ErrorCode() errs.Code {
+	return errs.WIPLimit
+}
+
+// StartOptions adjusts how StartObjective and ResumeObjective move an
+// objective into progress.
+type StartOptions struct {
+	// OverrideWIPLimit starts the objective even when a limit is reached.
+	// The exception is recorded in the objective's context under "wip_override".
+	OverrideWIPLimit bool
+
+	// Reason explains the override
+	Reason string
+}
+
+// WIPUsage is the number of in-progress objectives counted against a limit.
+type WIPUsage struct {
+	InProgress int
+	Limit      int // 0 when unlimited
+}
+
+// NearLimit reports whether work is in progress and at most one more
+// objective may start.
+func (u WIPUsage) NearLimit() bool {
+	return u.Limit > 0 && u.InProgress > 0 && u.InProgress >= u.Limit-1
+}
+
+// AtLimit reports whether no more objectives may start.
+func (u WIPUsage) AtLimit() bool {
+	return u.Limit > 0 && u.InProgress >= u.Limit
+}
+
+// WIPStatus summarizes work in progress against the configured limits.
+type WIPStatus struct {
+	Total WIPUsage
+
+	// Goals holds the usage of each goal with objectives in progress or a limit of its own
+	Goals map[string]WIPUsage
+}
+
+// SetWIPLimits sets the work-in-progress limits enforced when objectives start.
+func (om *ObjectiveManager) SetWIPLimits(limits WIPLimits) {
+	om.wip = limits
+}
+
+// WIPLimits returns the work-in-progress limits in effect.
+func (om *ObjectiveManager) WIPLimits() WIPLimits {
+	return om.wip
+}
+
+// WIPStatus returns how many objectives are in progress, overall and per goal.
+func (om *ObjectiveManager) WIPStatus(ctx context.Context) (*WIPStatus, error) {
+	inProgress, err := om.inProgressObjectives(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &WIPStatus{
+		Total: WIPUsage{InProgress: len(inProgress), Limit: om.wip.MaxInProgress},
+		Goals: make(map[string]WIPUsage),
+	}
+	for goalID, limit := range om.wip.Goals {
+		status.Goals[goalID] = WIPUsage{Limit: limit}
+	}
+	for _, objective := range inProgress {
+		usage := status.Goals[objective.GoalID]
+		usage.InProgress++
+		usage.Limit = om.wip.GoalLimit(objective.GoalID)
+		status.Goals[objective.GoalID] = usage
+	}
+	return status, nil
+}
+
+// CheckWIPLimit returns a *WIPLimitError if starting another objective of
+// goalID would exceed a work-in-progress limit.
+func (om *ObjectiveManager) CheckWIPLimit(ctx context.Context, goalID string) error {
+	if om.wip.MaxInProgress <= 0 && om.wip.GoalLimit(goalID) <= 0 {
+		return nil
+	}
+	inProgress, err := om.inProgressObjectives(ctx)
+	if err != nil {
+		return err
+	}
+	return om.wip.check(inProgress, goalID)
+}
+
+// SelectStartable splits candidates into those that may start within the
+// work-in-progress limits and those deferred, admitting them in order as if
+// each admitted one had started. Schedulers use it to skip work rather than
+// fail when the limits are reached. Delegated objectives are in neither list,
+// since someone outside the system is doing them.
+func (om *ObjectiveManager) SelectStartable(ctx context.Context, candidates []*Objective) (startable, deferred []*Objective, err error) {
+	if om.wip.MaxInProgress <= 0 && om.wip.MaxInProgressPerGoal <= 0 && len(om.wip.Goals) == 0 {
+		for _, candidate := range candidates {
+			if !candidate.IsDelegated() {
+				startable = append(startable, candidate)
+			}
+		}
+		return startable, nil, nil
+	}
+	inProgress, err := om.inProgressObjectives(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, candidate := range candidates {
+		if candidate.IsDelegated() {
+			continue
+		}
+		if om.wip.check(inProgress, candidate.GoalID) != nil {
+			deferred = append(deferred, candidate)
+			continue
+		}
+		startable = append(startable, candidate)
+		inProgress = append(inProgress, candidate)
+	}
+	return startable, deferred, nil
+}
+
+// check tests a new start of goalID against the limits, given the objectives in progress.
+func (l WIPLimits) check(inProgress []*Objective, goalID string) error {
+	if l.MaxInProgress > 0 && len(inProgress) >= l.MaxInProgress {
+		return &WIPLimitError{Limit: l.MaxInProgress, InProgress: inProgress}
+	}
+	if limit := l.GoalLimit(goalID); limit > 0 {
+		var inGoal []*Objective
+		for _, objective := range inProgress {
+			if objective.GoalID == goalID {
+				inGoal = append(inGoal, objective)
+			}
+		}
+		if len(inGoal) >= limit {
+			return &WIPLimitError{GoalID: goalID, Limit: limit, InProgress: inGoal}
+		}
+	}
+	return nil
+}
+
+// admit checks whether objective may move into progress. An override of a
+// reached limit is allowed and returned as context to record with the objective.
+func (om *ObjectiveManager) admit(ctx context.Context, objective *Objective, options []StartOptions) (map[string]interface{}, error) {
+	var opts StartOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	err := om.CheckWIPLimit(ctx, objective.GoalID)
+	var limitErr *WIPLimitError
+	if err == nil || !errors.As(err, &limitErr) {
+		return nil, err
+	}
+	if !opts.OverrideWIPLimit {
+		return nil, err
+	}
+
+	context := make(map[string]interface{}, len(objective.Context)+1)
+	for key, value := range objective.Context {
+		context[key] = value
+	}
+	context["wip_override"] = map[string]interface{}{
+		"at":          time.Now().Format(time.RFC3339),
+		"reason":      opts.Reason,
+		"limit":       limitErr.Limit,
+		"goal_limit":  limitErr.GoalID != "",
+		"in_progress": len(limitErr.InProgress),
+	}
+	return context, nil
+}
+
+// inProgressObjectives lists the objectives currently in progress.
+func (om *ObjectiveManager) inProgressObjectives(ctx context.Context) ([]*Objective, error) {
+	status := ObjectiveStatusInProgress
+	return om.ListObjectives(ctx, ObjectiveFilter{Status: &status})
+}
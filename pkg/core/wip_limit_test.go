@@ -0,0 +1,238 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// createWIPObjectives creates a goal with count pending objectives.
+func createWIPObjectives(t *testing.T, om *ObjectiveManager, gm *GoalManager, methodID string, count int) (*Goal, []*Objective) {
+	t.Helper()
+	ctx := context.Background()
+	goal, err := gm.CreateGoal(ctx, "WIP Goal", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	var objectives []*Objective
+	for i := 0; i < count; i++ {
+		objective, err := om.CreateObjective(ctx, goal.ID, methodID, fmt.Sprintf("Objective %d", i+1), "", nil, 5)
+		if err != nil {
+			t.Fatalf("Failed to create objective: %v", err)
+		}
+		objectives = append(objectives, objective)
+	}
+	return goal, objectives
+}
+
+// setupWIPTest returns managers over a fresh store and a method for objectives to use.
+func setupWIPTest(t *testing.T) (*ObjectiveManager, *GoalManager, string) {
+	t.Helper()
+	store := setupTestStore(t)
+	method, err := NewMethodManager(store).CreateMethod(context.Background(), "WIP Method", "", []ApproachStep{{Description: "Work"}}, MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	return NewObjectiveManager(store), NewGoalManager(store), method.ID
+}
+
+func TestWIPLimit_Global(t *testing.T) {
+	om, gm, methodID := setupWIPTest(t)
+	ctx := context.Background()
+	_, objectives := createWIPObjectives(t, om, gm, methodID, 3)
+	om.SetWIPLimits(WIPLimits{MaxInProgress: 2})
+
+	for _, objective := range objectives[:2] {
+		if _, err := om.StartObjective(ctx, objective.ID); err != nil {
+			t.Fatalf("Expected start within the limit to succeed: %v", err)
+		}
+	}
+
+	_, err := om.StartObjective(ctx, objectives[2].ID)
+	if !errors.Is(err, ErrWIPLimitReached) {
+		t.Fatalf("Expected ErrWIPLimitReached, got %v", err)
+	}
+	var limitErr *WIPLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Expected a *WIPLimitError, got %T", err)
+	}
+	if limitErr.GoalID != "" || limitErr.Limit != 2 || len(limitErr.InProgress) != 2 {
+		t.Errorf("Expected the global limit with both in-progress objectives, got %+v", limitErr)
+	}
+
+	refused, _ := om.GetObjective(ctx, objectives[2].ID)
+	if refused.Status != ObjectiveStatusPending {
+		t.Errorf("Expected the refused objective to stay pending, got %s", refused.Status)
+	}
+
+	// Pausing frees a slot, and resuming counts against the limit again
+	if _, err := om.PauseObjective(ctx, objectives[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.StartObjective(ctx, objectives[2].ID); err != nil {
+		t.Fatalf("Expected start after pausing to succeed: %v", err)
+	}
+	if _, err := om.ResumeObjective(ctx, objectives[0].ID); !errors.Is(err, ErrWIPLimitReached) {
+		t.Errorf("Expected resume over the limit to be refused, got %v", err)
+	}
+}
+
+func TestWIPLimit_GoalOverride(t *testing.T) {
+	om, gm, methodID := setupWIPTest(t)
+	ctx := context.Background()
+	strict, strictObjectives := createWIPObjectives(t, om, gm, methodID, 2)
+	_, otherObjectives := createWIPObjectives(t, om, gm, methodID, 2)
+
+	// The goal's own limit is stricter than the global one
+	om.SetWIPLimits(WIPLimits{MaxInProgress: 5, Goals: map[string]int{strict.ID: 1}})
+
+	if _, err := om.StartObjective(ctx, strictObjectives[0].ID); err != nil {
+		t.Fatalf("Expected the first start to succeed: %v", err)
+	}
+	_, err := om.StartObjective(ctx, strictObjectives[1].ID)
+	var limitErr *WIPLimitError
+	if !errors.As(err, &limitErr) || limitErr.GoalID != strict.ID || limitErr.Limit != 1 {
+		t.Fatalf("Expected the goal limit to be reached, got %v", err)
+	}
+	if len(limitErr.InProgress) != 1 || limitErr.InProgress[0].ID != strictObjectives[0].ID {
+		t.Errorf("Expected the goal's in-progress objective to be listed, got %v", limitErr.InProgress)
+	}
+
+	// Other goals only answer to the global limit
+	for _, objective := range otherObjectives {
+		if _, err := om.StartObjective(ctx, objective.ID); err != nil {
+			t.Errorf("Expected other goals to start freely: %v", err)
+		}
+	}
+
+	status, err := om.WIPStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Total != (WIPUsage{InProgress: 3, Limit: 5}) {
+		t.Errorf("Expected 3 of 5 in progress, got %+v", status.Total)
+	}
+	if usage := status.Goals[strict.ID]; usage != (WIPUsage{InProgress: 1, Limit: 1}) || !usage.AtLimit() {
+		t.Errorf("Expected the strict goal at its limit, got %+v", usage)
+	}
+}
+
+func TestWIPLimit_Override(t *testing.T) {
+	om, gm, methodID := setupWIPTest(t)
+	ctx := context.Background()
+	_, objectives := createWIPObjectives(t, om, gm, methodID, 2)
+	om.SetWIPLimits(WIPLimits{MaxInProgress: 1})
+
+	first, err := om.StartObjective(ctx, objectives[0].ID, StartOptions{OverrideWIPLimit: true, Reason: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, recorded := first.Context["wip_override"]; recorded {
+		t.Error("Expected no override to be recorded while under the limit")
+	}
+
+	if _, err := om.StartObjective(ctx, objectives[1].ID, StartOptions{OverrideWIPLimit: true, Reason: "release blocker"}); err != nil {
+		t.Fatalf("Expected the override to start the objective: %v", err)
+	}
+
+	started, err := om.GetObjective(ctx, objectives[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started.Status != ObjectiveStatusInProgress {
+		t.Errorf("Expected the objective in progress, got %s", started.Status)
+	}
+	record, ok := started.Context["wip_override"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected the override to be recorded, got context %v", started.Context)
+	}
+	if record["reason"] != "release blocker" || record["at"] == nil {
+		t.Errorf("Expected the override reason and time to be recorded, got %v", record)
+	}
+}
+
+func TestWIPLimit_LoweringKeepsWork(t *testing.T) {
+	om, gm, methodID := setupWIPTest(t)
+	ctx := context.Background()
+	_, objectives := createWIPObjectives(t, om, gm, methodID, 4)
+
+	for _, objective := range objectives[:3] {
+		if _, err := om.StartObjective(ctx, objective.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	om.SetWIPLimits(WIPLimits{MaxInProgress: 1})
+
+	status, err := om.WIPStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Total.InProgress != 3 || !status.Total.AtLimit() {
+		t.Errorf("Expected existing work to stay in progress over the new limit, got %+v", status.Total)
+	}
+	if _, err := om.StartObjective(ctx, objectives[3].ID); !errors.Is(err, ErrWIPLimitReached) {
+		t.Errorf("Expected new starts to be refused, got %v", err)
+	}
+}
+
+func TestWIPLimit_SelectStartableDefers(t *testing.T) {
+	om, gm, methodID := setupWIPTest(t)
+	ctx := context.Background()
+	_, objectives := createWIPObjectives(t, om, gm, methodID, 4)
+
+	// Without limits every candidate may start
+	startable, deferred, err := om.SelectStartable(ctx, objectives)
+	if err != nil || len(startable) != 4 || len(deferred) != 0 {
+		t.Fatalf("Expected all candidates without limits, got %d startable, %d deferred, err %v", len(startable), len(deferred), err)
+	}
+
+	if _, err := om.StartObjective(ctx, objectives[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	om.SetWIPLimits(WIPLimits{MaxInProgress: 2})
+
+	startable, deferred, err = om.SelectStartable(ctx, objectives[1:])
+	if err != nil {
+		t.Fatalf("Expected the limit to defer rather than fail, got %v", err)
+	}
+	if len(startable) != 1 || startable[0].ID != objectives[1].ID {
+		t.Errorf("Expected only the first candidate to fit, got %v", startable)
+	}
+	if len(deferred) != 2 {
+		t.Errorf("Expected the rest to be deferred, got %v", deferred)
+	}
+}
+
+func TestLearningLoop_DefersAtWIPLimit(t *testing.T) {
+	ll, store, _, _, _, _ := setupTestLearningLoop(t)
+	ctx := context.Background()
+	goal, method, pending := createTestLearningObjective(t, store)
+
+	om := NewObjectiveManager(store)
+	busy, err := om.CreateObjective(ctx, goal.ID, method.ID, "Busy", "", nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.StartObjective(ctx, busy.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ll.SetWIPLimits(WIPLimits{MaxInProgress: 1})
+	result, err := ll.ExecuteObjective(ctx, pending.ID)
+	if err != nil {
+		t.Fatalf("Expected the objective to be deferred, not failed: %v", err)
+	}
+	if result.FinalOutcome != OutcomeDeferred || len(result.ExecutionAttempts) != 0 {
+		t.Errorf("Expected a deferred outcome without attempts, got %s with %d attempts", result.FinalOutcome, len(result.ExecutionAttempts))
+	}
+
+	objective, err := om.GetObjective(ctx, pending.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objective.Status != ObjectiveStatusPending {
+		t.Errorf("Expected the deferred objective to stay pending, got %s", objective.Status)
+	}
+}
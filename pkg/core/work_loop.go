@@ -0,0 +1,710 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// workLoopStatusNodeType is the node type of work loop status records
+const workLoopStatusNodeType = "work_loop_status"
+
+// WorkLoopHeartbeatInterval is how often a running work loop refreshes its
+// status node and lock file. A loop not heard from for three intervals is
+// taken to have died.
+const WorkLoopHeartbeatInterval = time.Minute
+
+// ErrWorkLoopLocked is returned when another work loop holds the lock file.
+var ErrWorkLoopLocked = errs.New(errs.Conflict, "another work loop is running")
+
+// WorkLoopLimits bound what a work loop does on its own. Zero means no limit.
+type WorkLoopLimits struct {
+	// MaxObjectivesPerHour caps the objectives run in any hour
+	MaxObjectivesPerHour int
+
+	// MaxSessionSpend stops the loop once it has spent this much, in dollars
+	MaxSessionSpend float64
+
+	// MaxConsecutiveFailures stops the loop after this many objectives in a row failed
+	MaxConsecutiveFailures int
+
+	// IdleInterval is how long the loop sleeps when there is nothing to do
+	// (default: one minute)
+	IdleInterval time.Duration
+}
+
+// DefaultWorkLoopLimits returns conservative limits for unattended work.
+func DefaultWorkLoopLimits() WorkLoopLimits {
+	return WorkLoopLimits{
+		MaxObjectivesPerHour:   6,
+		MaxSessionSpend:        1.00,
+		MaxConsecutiveFailures: 3,
+		IdleInterval:           time.Minute,
+	}
+}
+
+// WorkLoopState is what a work loop is doing.
+type WorkLoopState string
+
+const (
+	// WorkLoopRunning loops are running an objective
+	WorkLoopRunning WorkLoopState = "running"
+
+	// WorkLoopIdle loops found nothing to work on and are sleeping
+	WorkLoopIdle WorkLoopState = "idle"
+
+	// WorkLoopRateLimited loops ran their hourly number of objectives
+	WorkLoopRateLimited WorkLoopState = "rate_limited"
+
+	// WorkLoopAwaitingApproval loops are paused until the user settles the
+	// ethical decisions awaiting approval
+	WorkLoopAwaitingApproval WorkLoopState = "awaiting_approval"
+
+	// WorkLoopStopped loops have ended; StopReason says why
+	WorkLoopStopped WorkLoopState = "stopped"
+)
+
+// WorkLoopStatus is the heartbeat a work loop keeps in the store, one node
+// per session, for status displays.
+type WorkLoopStatus struct {
+	ID    string
+	State WorkLoopState
+	PID   int
+
+	Completed int
+	Failed    int
+
+	// ConsecutiveFailures counts the failures since the last success
+	ConsecutiveFailures int
+
+	// SessionSpend is what the session has spent, in dollars
+	SessionSpend float64
+
+	// CurrentObjectiveID is the objective being run, or the one a stop
+	// interrupted, which the next session picks up first
+	CurrentObjectiveID    string
+	CurrentObjectiveTitle string
+
+	// PendingDecisions counts the decisions a paused loop waits on
+	PendingDecisions int
+
+	StopReason string
+	StartedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Alive reports whether the loop is running and has been heard from lately.
+func (s *WorkLoopStatus) Alive(now time.Time) bool {
+	return s.State != WorkLoopStopped && now.Sub(s.UpdatedAt) < 3*WorkLoopHeartbeatInterval
+}
+
+// Summary describes the session in one line, as "agent running, 3
+// objectives completed, $0.42 spent this session".
+func (s *WorkLoopStatus) Summary(now time.Time) string {
+	counts := fmt.Sprintf("%d objectives completed", s.Completed)
+	if s.Failed > 0 {
+		counts += fmt.Sprintf(", %d failed", s.Failed)
+	}
+	spend := fmt.Sprintf("$%.2f spent", s.SessionSpend)
+
+	switch {
+	case !s.Alive(now) && s.State != WorkLoopStopped:
+		return fmt.Sprintf("agent not responding since %s (%s, %s)", s.UpdatedAt.Format("Jan 2 15:04"), counts, spend)
+	case s.State == WorkLoopStopped:
+		return fmt.Sprintf("agent stopped: %s (%s, %s last session)", s.StopReason, counts, spend)
+	case s.State == WorkLoopAwaitingApproval:
+		return fmt.Sprintf("agent paused for approval of %d decision(s), %s, %s this session", s.PendingDecisions, counts, spend)
+	case s.State == WorkLoopRateLimited:
+		return fmt.Sprintf("agent waiting on its hourly limit, %s, %s this session", counts, spend)
+	case s.State == WorkLoopIdle:
+		return fmt.Sprintf("agent idle, %s, %s this session", counts, spend)
+	}
+	return fmt.Sprintf("agent running, %s, %s this session", counts, spend)
+}
+
+// WorkLoopEvent is a change in a work loop's progress.
+type WorkLoopEvent struct {
+	Status  WorkLoopStatus
+	Message string
+}
+
+// ObjectiveRunner runs an objective to its end, as the LearningLoop does.
+type ObjectiveRunner interface {
+	ExecuteObjective(ctx context.Context, objectiveID string) (*LearningResult, error)
+}
+
+// WorkLoopConfig configures a WorkLoop.
+type WorkLoopConfig struct {
+	Limits WorkLoopLimits
+
+	// Budget measures the session's spending (nil: the spend limit is not checked)
+	Budget *llm.BudgetManager
+
+	// Ethics finds the decisions awaiting approval (nil: the loop never pauses for them)
+	Ethics *EthicalFramework
+
+	// UserID limits the decisions waited on to one user's ("" for everyone's)
+	UserID string
+
+	// LockFile keeps a second loop on the same data from starting ("": no lock)
+	LockFile string
+
+	// ApprovalPollInterval is how often a paused loop checks whether the
+	// user has settled the decisions (default: five seconds)
+	ApprovalPollInterval time.Duration
+
+	// OnEvent is told of each change, from the loop's goroutine
+	OnEvent func(event WorkLoopEvent)
+
+	// Clock times the limits (default: the system clock)
+	Clock utils.Clock
+}
+
+// WorkLoop works through actionable objectives unattended: it asks the
+// objective manager for the next one, runs it through the runner, and sleeps
+// when there is nothing to do. It stops at its limits, and pauses while any
+// ethical decision awaits the user's approval.
+type WorkLoop struct {
+	store            *storage.Store
+	objectiveManager *ObjectiveManager
+	runner           ObjectiveRunner
+	config           WorkLoopConfig
+	clock            utils.Clock
+
+	// mu guards status and runs; saveMu orders the writes of the status node
+	mu     sync.Mutex
+	saveMu sync.Mutex
+	status WorkLoopStatus
+
+	// runs are the start times of the objectives run within the last hour
+	runs []time.Time
+
+	baseSpend float64
+	lockFile  string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWorkLoop creates a work loop running the manager's objectives with runner.
+func NewWorkLoop(store *storage.Store, objectiveManager *ObjectiveManager, runner ObjectiveRunner, config WorkLoopConfig) *WorkLoop {
+	if config.Limits.IdleInterval <= 0 {
+		config.Limits.IdleInterval = time.Minute
+	}
+	if config.ApprovalPollInterval <= 0 {
+		config.ApprovalPollInterval = 5 * time.Second
+	}
+	return &WorkLoop{
+		store:            store,
+		objectiveManager: objectiveManager,
+		runner:           runner,
+		config:           config,
+		clock:            utils.ClockOrReal(config.Clock),
+		stop:             make(chan struct{}),
+	}
+}
+
+// Stop asks the loop to stop once the objective in progress has finished.
+// Cancelling Run's context stops it sooner, leaving the objective's plan at
+// its last checkpoint.
+func (wl *WorkLoop) Stop() {
+	wl.stopOnce.Do(func() { close(wl.stop) })
+}
+
+// Status returns the loop's current status.
+func (wl *WorkLoop) Status() WorkLoopStatus {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	return wl.status
+}
+
+// Run works until a limit is reached, Stop is called or ctx is cancelled,
+// and returns the final status. It fails with ErrWorkLoopLocked when another
+// loop holds the lock file. An objective a cancellation interrupted is left
+// in progress and is the first one the next Run picks up.
+func (wl *WorkLoop) Run(ctx context.Context) (*WorkLoopStatus, error) {
+	if wl.config.LockFile != "" {
+		if err := acquireWorkLoopLock(wl.config.LockFile); err != nil {
+			return nil, err
+		}
+		wl.lockFile = wl.config.LockFile
+		defer os.Remove(wl.lockFile)
+	}
+
+	resume := wl.interruptedObjective()
+	wl.baseSpend = wl.totalSpend()
+	wl.mu.Lock()
+	wl.status = WorkLoopStatus{State: WorkLoopIdle, PID: os.Getpid(), StartedAt: wl.clock.Now()}
+	wl.mu.Unlock()
+	if err := wl.save(ctx); err != nil {
+		return nil, err
+	}
+
+	beatCtx, stopBeat := context.WithCancel(context.Background())
+	beaten := make(chan struct{})
+	go func() {
+		defer close(beaten)
+		wl.heartbeat(beatCtx)
+	}()
+
+	reason := wl.work(ctx, resume)
+
+	stopBeat()
+	<-beaten
+	wl.mu.Lock()
+	wl.status.State = WorkLoopStopped
+	wl.status.StopReason = reason
+	wl.status.PendingDecisions = 0
+	wl.status.SessionSpend = wl.totalSpend() - wl.baseSpend
+	wl.mu.Unlock()
+	wl.emit(fmt.Sprintf("Stopped: %s", reason))
+
+	// The context may be cancelled; the final status is still recorded
+	if err := wl.save(context.Background()); err != nil {
+		return nil, err
+	}
+	status := wl.Status()
+	return &status, nil
+}
+
+// work picks and runs objectives until the loop has to stop, and returns why.
+func (wl *WorkLoop) work(ctx context.Context, resume string) string {
+	limits := wl.config.Limits
+	for {
+		if reason := wl.stopped(ctx); reason != "" {
+			return reason
+		}
+
+		// Nothing runs while a decision waits on the user
+		pending, err := wl.pendingApprovals(ctx)
+		if err != nil {
+			wl.emit(fmt.Sprintf("Could not check for decisions awaiting approval: %v", err))
+			wl.wait(ctx, limits.IdleInterval)
+			continue
+		}
+		if pending > 0 {
+			wl.setState(WorkLoopAwaitingApproval, pending, fmt.Sprintf("Paused: %d decision(s) await approval", pending))
+			wl.wait(ctx, wl.config.ApprovalPollInterval)
+			continue
+		}
+
+		status := wl.Status()
+		if limits.MaxConsecutiveFailures > 0 && status.ConsecutiveFailures >= limits.MaxConsecutiveFailures {
+			return fmt.Sprintf("%d objectives failed in a row", status.ConsecutiveFailures)
+		}
+		if limits.MaxSessionSpend > 0 {
+			if spent := wl.refreshSpend(); spent >= limits.MaxSessionSpend {
+				return fmt.Sprintf("session spend limit of $%.2f reached ($%.2f spent)", limits.MaxSessionSpend, spent)
+			}
+		}
+		if wait := wl.rateWait(); wait > 0 {
+			wl.setState(WorkLoopRateLimited, 0, fmt.Sprintf("Ran %d objectives this hour; resuming in %s", limits.MaxObjectivesPerHour, wait.Round(time.Second)))
+			wl.wait(ctx, wait)
+			continue
+		}
+
+		objective, err := wl.next(ctx, resume)
+		resume = ""
+		if err != nil {
+			if ctx.Err() == nil {
+				wl.emit(fmt.Sprintf("Could not find the next objective: %v", err))
+				wl.wait(ctx, limits.IdleInterval)
+			}
+			continue
+		}
+		if objective == nil {
+			wl.setState(WorkLoopIdle, 0, fmt.Sprintf("Nothing to do; checking again in %s", limits.IdleInterval))
+			wl.wait(ctx, limits.IdleInterval)
+			continue
+		}
+		wl.runObjective(ctx, objective)
+	}
+}
+
+// stopped returns why the loop must stop now, or "".
+func (wl *WorkLoop) stopped(ctx context.Context) string {
+	if ctx.Err() != nil {
+		return "interrupted"
+	}
+	select {
+	case <-wl.stop:
+		return "stopped on request"
+	default:
+		return ""
+	}
+}
+
+// next returns the objective to run: the one the last session was
+// interrupted in, if given, or the manager's next actionable one.
+func (wl *WorkLoop) next(ctx context.Context, resume string) (*Objective, error) {
+	if resume != "" {
+		if objective, err := wl.objectiveManager.GetObjective(ctx, resume); err == nil && objective.Status == ObjectiveStatusInProgress {
+			return objective, nil
+		}
+	}
+	suggestion, err := wl.objectiveManager.GetNextActionable(ctx)
+	if err != nil || suggestion == nil {
+		return nil, err
+	}
+	return suggestion.Objective, nil
+}
+
+// runObjective starts an objective, runs it, and records how it went.
+func (wl *WorkLoop) runObjective(ctx context.Context, objective *Objective) {
+	om := wl.objectiveManager
+
+	// The learning loop itself continues an objective stopped by its time box
+	if objective.Status == ObjectiveStatusPending && objective.TimeBoxStop() == nil {
+		if _, err := om.StartObjective(ctx, objective.ID); err != nil {
+			if errors.Is(err, ErrWIPLimitReached) {
+				wl.setState(WorkLoopIdle, 0, fmt.Sprintf("Waiting: %v", err))
+			} else {
+				wl.emit(fmt.Sprintf("Could not start %s: %v", objective.Title, err))
+			}
+			wl.wait(ctx, wl.config.Limits.IdleInterval)
+			return
+		}
+	}
+
+	wl.mu.Lock()
+	wl.runs = append(wl.runs, wl.clock.Now())
+	wl.status.CurrentObjectiveID = objective.ID
+	wl.status.CurrentObjectiveTitle = objective.Title
+	wl.mu.Unlock()
+	wl.setState(WorkLoopRunning, 0, fmt.Sprintf("Running objective: %s", objective.Title))
+
+	result, err := wl.runner.ExecuteObjective(ctx, objective.ID)
+	if ctx.Err() != nil {
+		// Leave the objective in progress for the next session, its plan
+		// checkpointed where the cancellation stopped it
+		wl.emit(fmt.Sprintf("Interrupted %s; it resumes from its checkpoint next time", objective.Title))
+		return
+	}
+	wl.clearObjective()
+
+	if err != nil {
+		if _, failErr := om.FailObjective(ctx, objective.ID, err.Error(), 0); failErr != nil {
+			wl.emit(fmt.Sprintf("Could not record the failure of %s: %v", objective.Title, failErr))
+		}
+		wl.recordOutcome(false, fmt.Sprintf("Failed: %s: %v", objective.Title, err))
+		return
+	}
+
+	switch result.FinalOutcome {
+	case OutcomeDeferred:
+		wl.setState(WorkLoopIdle, 0, fmt.Sprintf("Deferred %s: a work-in-progress limit is reached", objective.Title))
+		wl.wait(ctx, wl.config.Limits.IdleInterval)
+		return
+	case OutcomeTimeBoxed:
+		wl.emit(fmt.Sprintf("Paused %s: it ran out of its time box", objective.Title))
+		return
+	}
+
+	if _, err := om.RecordLearningResult(ctx, result); err != nil {
+		wl.emit(fmt.Sprintf("Could not record the result of %s: %v", objective.Title, err))
+	}
+	if result.WasSuccessful {
+		wl.recordOutcome(true, fmt.Sprintf("Completed: %s", objective.Title))
+	} else {
+		wl.recordOutcome(false, fmt.Sprintf("Failed: %s: %s", objective.Title, result.ErrorMessage))
+	}
+}
+
+// recordOutcome counts a finished objective and saves the status.
+func (wl *WorkLoop) recordOutcome(success bool, message string) {
+	wl.mu.Lock()
+	if success {
+		wl.status.Completed++
+		wl.status.ConsecutiveFailures = 0
+	} else {
+		wl.status.Failed++
+		wl.status.ConsecutiveFailures++
+	}
+	wl.status.SessionSpend = wl.totalSpend() - wl.baseSpend
+	wl.mu.Unlock()
+	wl.emit(message)
+	wl.saveQuietly()
+}
+
+// clearObjective records that no objective is being run.
+func (wl *WorkLoop) clearObjective() {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	wl.status.CurrentObjectiveID = ""
+	wl.status.CurrentObjectiveTitle = ""
+}
+
+// setState changes the loop's state, saving and reporting it when it changed.
+func (wl *WorkLoop) setState(state WorkLoopState, pending int, message string) {
+	wl.mu.Lock()
+	changed := wl.status.State != state || wl.status.PendingDecisions != pending
+	wl.status.State = state
+	wl.status.PendingDecisions = pending
+	wl.mu.Unlock()
+	if changed {
+		wl.emit(message)
+		wl.saveQuietly()
+	}
+}
+
+// emit tells OnEvent of a change.
+func (wl *WorkLoop) emit(message string) {
+	if wl.config.OnEvent != nil {
+		wl.config.OnEvent(WorkLoopEvent{Status: wl.Status(), Message: message})
+	}
+}
+
+// wait sleeps for d, or until the loop is stopped or ctx is cancelled.
+func (wl *WorkLoop) wait(ctx context.Context, d time.Duration) {
+	timer := wl.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-wl.stop:
+	case <-timer.C():
+	}
+}
+
+// rateWait returns how long the hourly limit keeps the next objective waiting.
+func (wl *WorkLoop) rateWait() time.Duration {
+	limit := wl.config.Limits.MaxObjectivesPerHour
+	if limit <= 0 {
+		return 0
+	}
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	now := wl.clock.Now()
+	recent := wl.runs[:0]
+	for _, started := range wl.runs {
+		if now.Sub(started) < time.Hour {
+			recent = append(recent, started)
+		}
+	}
+	wl.runs = recent
+	if len(recent) < limit {
+		return 0
+	}
+	return recent[len(recent)-limit].Add(time.Hour).Sub(now)
+}
+
+// pendingApprovals counts the decisions awaiting the user's approval.
+func (wl *WorkLoop) pendingApprovals(ctx context.Context) (int, error) {
+	if wl.config.Ethics == nil {
+		return 0, nil
+	}
+	decisions, err := wl.config.Ethics.ListPendingDecisions(ctx, wl.config.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return len(decisions), nil
+}
+
+// AwaitApproval is an ApprovalPrompt for unattended work: it pauses the loop
+// until the user approves or rejects the decision by other means, such as
+// the CLI or the GUI, and returns their answer.
+func (wl *WorkLoop) AwaitApproval(ctx context.Context, decision *EthicalDecision) (bool, string, error) {
+	if wl.config.Ethics == nil {
+		return false, "", fmt.Errorf("decision %s awaits approval and the work loop has no ethical framework", decision.ID)
+	}
+	wl.setState(WorkLoopAwaitingApproval, 1, fmt.Sprintf("Paused: decision %s awaits approval: %s", decision.ID, decision.ProposedAction))
+	defer wl.setState(WorkLoopRunning, 0, "Resumed")
+
+	for {
+		current, err := wl.config.Ethics.GetDecision(ctx, decision.ID)
+		if err != nil {
+			return false, "", err
+		}
+		if state := current.State(); state != DecisionStateAwaitingApproval {
+			return state != DecisionStateRejected && state != DecisionStateAborted, current.UserFeedback, nil
+		}
+
+		timer := wl.clock.NewTimer(wl.config.ApprovalPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, "", ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// totalSpend returns everything the budget has recorded, or 0 without one.
+func (wl *WorkLoop) totalSpend() float64 {
+	if wl.config.Budget == nil {
+		return 0
+	}
+	return wl.config.Budget.GetSpendingAnalysis().TotalSpent
+}
+
+// refreshSpend updates and returns the session's spending.
+func (wl *WorkLoop) refreshSpend() float64 {
+	spent := wl.totalSpend() - wl.baseSpend
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	wl.status.SessionSpend = spent
+	return spent
+}
+
+// heartbeat refreshes the status node and lock file until ctx is cancelled,
+// so that status displays can tell a running loop from one that died.
+func (wl *WorkLoop) heartbeat(ctx context.Context) {
+	ticker := wl.clock.NewTicker(WorkLoopHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			wl.refreshSpend()
+			wl.saveQuietly()
+			if wl.lockFile != "" {
+				now := time.Now()
+				_ = os.Chtimes(wl.lockFile, now, now)
+			}
+		}
+	}
+}
+
+// interruptedObjective returns the objective the last session was
+// interrupted in, if it is still in progress.
+func (wl *WorkLoop) interruptedObjective() string {
+	last, err := LatestWorkLoopStatus(wl.store.DataDir())
+	if err != nil || last == nil {
+		return ""
+	}
+	return last.CurrentObjectiveID
+}
+
+// saveQuietly saves the status, reporting a failure as an event; a status
+// that could not be written must not stop the work.
+func (wl *WorkLoop) saveQuietly() {
+	if err := wl.save(context.Background()); err != nil {
+		wl.emit(fmt.Sprintf("Could not save the work loop status: %v", err))
+	}
+}
+
+// save writes the status node, creating it on the first save.
+func (wl *WorkLoop) save(ctx context.Context) error {
+	wl.saveMu.Lock()
+	defer wl.saveMu.Unlock()
+
+	wl.mu.Lock()
+	wl.status.UpdatedAt = wl.clock.Now()
+	status := wl.status
+	wl.mu.Unlock()
+
+	data := workLoopStatusData(&status)
+	if status.ID != "" {
+		if err := wl.store.UpdateNode(ctx, status.ID, data); err != nil {
+			return fmt.Errorf("failed to update work loop status: %w", err)
+		}
+		return nil
+	}
+	node := storage.NewNode(workLoopStatusNodeType, data)
+	if err := wl.store.AddNode(ctx, node); err != nil {
+		return fmt.Errorf("failed to record work loop status: %w", err)
+	}
+	wl.mu.Lock()
+	wl.status.ID = node.ID
+	wl.mu.Unlock()
+	return nil
+}
+
+// LatestWorkLoopStatus returns the status of the most recently started work
+// loop session on the data directory, or nil when no loop has run. It reads
+// the status from disk, as a loop runs in a process of its own.
+func LatestWorkLoopStatus(dataDir string) (*WorkLoopStatus, error) {
+	nodes, err := storage.ReadNodesOfType(dataDir, workLoopStatusNodeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read work loop status: %w", err)
+	}
+	var latest *WorkLoopStatus
+	for _, node := range nodes {
+		status := nodeToWorkLoopStatus(node)
+		if latest == nil || status.StartedAt.After(latest.StartedAt) {
+			latest = status
+		}
+	}
+	return latest, nil
+}
+
+// workLoopStatusData converts a status to node data.
+func workLoopStatusData(status *WorkLoopStatus) map[string]interface{} {
+	return map[string]interface{}{
+		"state":                   string(status.State),
+		"pid":                     status.PID,
+		"completed":               status.Completed,
+		"failed":                  status.Failed,
+		"consecutive_failures":    status.ConsecutiveFailures,
+		"session_spend":           status.SessionSpend,
+		"current_objective_id":    status.CurrentObjectiveID,
+		"current_objective_title": status.CurrentObjectiveTitle,
+		"pending_decisions":       status.PendingDecisions,
+		"stop_reason":             status.StopReason,
+		"started_at":              status.StartedAt.Format(time.RFC3339Nano),
+		"updated_at":              status.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// nodeToWorkLoopStatus converts a node to a status.
+func nodeToWorkLoopStatus(node *storage.Node) *WorkLoopStatus {
+	status := &WorkLoopStatus{
+		ID:                    node.ID,
+		State:                 WorkLoopState(getString(node.Data, "state")),
+		PID:                   int(getFloat64(node.Data, "pid")),
+		Completed:             int(getFloat64(node.Data, "completed")),
+		Failed:                int(getFloat64(node.Data, "failed")),
+		ConsecutiveFailures:   int(getFloat64(node.Data, "consecutive_failures")),
+		SessionSpend:          getFloat64(node.Data, "session_spend"),
+		CurrentObjectiveID:    getString(node.Data, "current_objective_id"),
+		CurrentObjectiveTitle: getString(node.Data, "current_objective_title"),
+		PendingDecisions:      int(getFloat64(node.Data, "pending_decisions")),
+		StopReason:            getString(node.Data, "stop_reason"),
+	}
+	status.StartedAt, _ = time.Parse(time.RFC3339Nano, getString(node.Data, "started_at"))
+	status.UpdatedAt, _ = time.Parse(time.RFC3339Nano, getString(node.Data, "updated_at"))
+	return status
+}
+
+// acquireWorkLoopLock creates the lock file holding this process's ID. A
+// lock its holder stopped refreshing was left by a loop that died, and is
+// taken over.
+func acquireWorkLoopLock(path string) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = fmt.Fprintf(file, "%d\n", os.Getpid())
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return fmt.Errorf("failed to write lock file: %w", err)
+			}
+			return nil
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		info, err := os.Stat(path)
+		if err == nil && time.Since(info.ModTime()) < 3*WorkLoopHeartbeatInterval {
+			holder, _ := os.ReadFile(path)
+			return fmt.Errorf("%w: process %s holds %s", ErrWorkLoopLocked, strings.TrimSpace(string(holder)), path)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+	return fmt.Errorf("%w: could not take over %s", ErrWorkLoopLocked, path)
+}
@@ -0,0 +1,317 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// scriptedRunner stands in for the learning loop: each run spends cost and
+// succeeds unless its objective is listed in fail. With block set it waits
+// for the context to be cancelled instead.
+type scriptedRunner struct {
+	budget *llm.BudgetManager
+	cost   float64
+	fail   map[string]bool
+	block  bool
+
+	mu   sync.Mutex
+	runs []string
+}
+
+func (r *scriptedRunner) ExecuteObjective(ctx context.Context, objectiveID string) (*LearningResult, error) {
+	r.mu.Lock()
+	r.runs = append(r.runs, objectiveID)
+	r.mu.Unlock()
+
+	if r.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if r.budget != nil {
+		if err := r.budget.RecordUsage(ctx, llm.Transaction{Provider: "test", Cost: r.cost, Success: true, ObjectiveID: objectiveID}); err != nil {
+			return nil, err
+		}
+	}
+	if r.fail[objectiveID] {
+		return &LearningResult{ObjectiveID: objectiveID, FinalOutcome: OutcomeMethodFailure, ErrorMessage: "no luck"}, nil
+	}
+	return &LearningResult{ObjectiveID: objectiveID, WasSuccessful: true, FinalOutcome: OutcomeSuccess}, nil
+}
+
+func (r *scriptedRunner) ran() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.runs...)
+}
+
+// setupWorkLoopObjectives creates count pending objectives under one goal.
+func setupWorkLoopObjectives(t *testing.T, store *storage.Store, count int) []*Objective {
+	t.Helper()
+	goal := createTestGoal(t, store)
+	method := createTestMethod(t, store)
+	objectives := make([]*Objective, count)
+	for i := range objectives {
+		objectives[i] = createTestObjective(t, store, goal.ID, method.ID)
+	}
+	return objectives
+}
+
+// awaitWorkLoop polls until the loop's status satisfies done.
+func awaitWorkLoop(t *testing.T, wl *WorkLoop, done func(WorkLoopStatus) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done(wl.Status()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting on the work loop, status %+v", wl.Status())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWorkLoop_StopsAtSpendAndFailureLimits(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("session spend", func(t *testing.T) {
+		store := setupTestStore(t)
+		om := NewObjectiveManager(store)
+		setupWorkLoopObjectives(t, store, 3)
+		budget, err := llm.NewBudgetManager(t.TempDir(), llm.BudgetConfig{}, log.New(io.Discard, "", 0))
+		if err != nil {
+			t.Fatalf("Failed to create budget manager: %v", err)
+		}
+		// Spending before the session does not count against it
+		if err := budget.RecordUsage(ctx, llm.Transaction{Provider: "test", Cost: 5}); err != nil {
+			t.Fatalf("RecordUsage failed: %v", err)
+		}
+
+		runner := &scriptedRunner{budget: budget, cost: 0.3}
+		wl := NewWorkLoop(store, om, runner, WorkLoopConfig{
+			Limits: WorkLoopLimits{MaxSessionSpend: 0.5, IdleInterval: time.Millisecond},
+			Budget: budget,
+		})
+		status, err := wl.Run(ctx)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(runner.ran()) != 2 || status.Completed != 2 || !strings.Contains(status.StopReason, "spend limit") {
+			t.Errorf("Expected two runs before the spend limit, got %d runs and %+v", len(runner.ran()), status)
+		}
+		if status.SessionSpend < 0.59 || status.SessionSpend > 0.61 {
+			t.Errorf("Expected $0.60 spent this session, got %.2f", status.SessionSpend)
+		}
+		for _, id := range runner.ran() {
+			if objective, _ := om.GetObjective(ctx, id); objective.Status != ObjectiveStatusCompleted {
+				t.Errorf("Expected %s completed, got %s", id, objective.Status)
+			}
+		}
+
+		saved, err := LatestWorkLoopStatus(store.DataDir())
+		if err != nil || saved == nil {
+			t.Fatalf("Expected the status saved, got %v, %v", saved, err)
+		}
+		if saved.ID != status.ID || saved.State != WorkLoopStopped || saved.Completed != 2 || saved.Alive(time.Now()) {
+			t.Errorf("Expected the final status saved, got %+v", saved)
+		}
+		if summary := saved.Summary(time.Now()); !strings.Contains(summary, "2 objectives completed, $0.60 spent") {
+			t.Errorf("Unexpected summary %q", summary)
+		}
+	})
+
+	t.Run("consecutive failures", func(t *testing.T) {
+		store := setupTestStore(t)
+		om := NewObjectiveManager(store)
+		objectives := setupWorkLoopObjectives(t, store, 3)
+		fail := map[string]bool{}
+		for _, objective := range objectives {
+			fail[objective.ID] = true
+		}
+
+		runner := &scriptedRunner{fail: fail}
+		wl := NewWorkLoop(store, om, runner, WorkLoopConfig{Limits: WorkLoopLimits{MaxConsecutiveFailures: 2, IdleInterval: time.Millisecond}})
+		status, err := wl.Run(ctx)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(runner.ran()) != 2 || status.Failed != 2 || status.StopReason != "2 objectives failed in a row" {
+			t.Errorf("Expected to stop after two failures, got %d runs and %+v", len(runner.ran()), status)
+		}
+		failed, _ := om.GetObjective(ctx, runner.ran()[0])
+		if failed.Status != ObjectiveStatusFailed || failed.Result == nil || !strings.Contains(failed.Result.Message, "no luck") {
+			t.Errorf("Expected the failure recorded, got %s, %+v", failed.Status, failed.Result)
+		}
+	})
+}
+
+func TestWorkLoop_PausesForPendingApproval(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	om := NewObjectiveManager(store)
+	ef := NewEthicalFramework(store, nil, NewUserContextManager(store))
+	setupWorkLoopObjectives(t, store, 1)
+
+	pending := &EthicalDecision{
+		DecisionContext: "Send the weekly report",
+		ProposedAction:  "Email the team",
+		ApprovalStatus:  DecisionApprovalPending,
+		Outcome:         DecisionOutcomeUnknown,
+		CreatedAt:       time.Now(),
+		UserID:          "user-1",
+	}
+	if err := ef.storeDecision(ctx, pending); err != nil {
+		t.Fatalf("Failed to store decision: %v", err)
+	}
+
+	runner := &scriptedRunner{}
+	wl := NewWorkLoop(store, om, runner, WorkLoopConfig{
+		Limits:               WorkLoopLimits{IdleInterval: time.Millisecond},
+		Ethics:               ef,
+		UserID:               "user-1",
+		ApprovalPollInterval: time.Millisecond,
+	})
+	done := make(chan *WorkLoopStatus)
+	go func() {
+		status, _ := wl.Run(ctx)
+		done <- status
+	}()
+
+	awaitWorkLoop(t, wl, func(s WorkLoopStatus) bool { return s.State == WorkLoopAwaitingApproval })
+	if len(runner.ran()) != 0 {
+		t.Fatal("Expected nothing to run while a decision awaits approval")
+	}
+	if saved, _ := LatestWorkLoopStatus(store.DataDir()); saved == nil || saved.PendingDecisions != 1 || !strings.Contains(saved.Summary(time.Now()), "paused for approval") {
+		t.Errorf("Expected the pause saved, got %+v", saved)
+	}
+
+	if err := ef.ApproveDecision(ctx, pending.ID, "go ahead"); err != nil {
+		t.Fatalf("ApproveDecision failed: %v", err)
+	}
+	awaitWorkLoop(t, wl, func(s WorkLoopStatus) bool { return s.Completed == 1 })
+	wl.Stop()
+	if status := <-done; status.StopReason != "stopped on request" {
+		t.Errorf("Expected a requested stop, got %+v", status)
+	}
+}
+
+func TestWorkLoop_AwaitApprovalSettledElsewhere(t *testing.T) {
+	ctx := context.Background()
+	store := setupTestStore(t)
+	ef := NewEthicalFramework(store, nil, NewUserContextManager(store))
+	wl := NewWorkLoop(store, NewObjectiveManager(store), &scriptedRunner{}, WorkLoopConfig{Ethics: ef, ApprovalPollInterval: time.Millisecond})
+
+	for _, approve := range []bool{true, false} {
+		decision := &EthicalDecision{
+			DecisionContext: "Publish the draft",
+			ProposedAction:  "Post it",
+			ApprovalStatus:  DecisionApprovalPending,
+			Outcome:         DecisionOutcomeUnknown,
+			CreatedAt:       time.Now(),
+		}
+		if err := ef.storeDecision(ctx, decision); err != nil {
+			t.Fatalf("Failed to store decision: %v", err)
+		}
+
+		settled := make(chan error)
+		go func() { settled <- settleDecision(ctx, ef, wl.AwaitApproval, decision, "task publish") }()
+		awaitWorkLoop(t, wl, func(s WorkLoopStatus) bool { return s.State == WorkLoopAwaitingApproval })
+		if approve {
+			if err := ef.ApproveDecision(ctx, decision.ID, "fine"); err != nil {
+				t.Fatalf("ApproveDecision failed: %v", err)
+			}
+		} else if err := ef.RejectDecision(ctx, decision.ID, "not yet"); err != nil {
+			t.Fatalf("RejectDecision failed: %v", err)
+		}
+
+		err := <-settled
+		current, _ := ef.GetDecision(ctx, decision.ID)
+		if approve && (err != nil || current.State() != DecisionStateImplemented) {
+			t.Errorf("Expected the approved decision implemented, got %v, %s", err, current.State())
+		}
+		if !approve && (!errors.Is(err, ErrTaskNotApproved) || current.State() != DecisionStateRejected) {
+			t.Errorf("Expected the rejection to stop the task, got %v, %s", err, current.State())
+		}
+		if wl.Status().State != WorkLoopRunning {
+			t.Errorf("Expected the loop to resume, got %s", wl.Status().State)
+		}
+	}
+}
+
+func TestWorkLoop_InterruptedObjectiveResumesFirst(t *testing.T) {
+	store := setupTestStore(t)
+	om := NewObjectiveManager(store)
+	setupWorkLoopObjectives(t, store, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	blocked := &scriptedRunner{block: true}
+	wl := NewWorkLoop(store, om, blocked, WorkLoopConfig{Limits: WorkLoopLimits{IdleInterval: time.Millisecond}})
+	done := make(chan *WorkLoopStatus)
+	go func() {
+		status, _ := wl.Run(ctx)
+		done <- status
+	}()
+	awaitWorkLoop(t, wl, func(s WorkLoopStatus) bool { return s.CurrentObjectiveID != "" })
+	cancel()
+	status := <-done
+
+	interrupted := blocked.ran()[0]
+	if status.StopReason != "interrupted" || status.CurrentObjectiveID != interrupted {
+		t.Errorf("Expected the interrupted objective recorded, got %+v", status)
+	}
+	if objective, _ := om.GetObjective(context.Background(), interrupted); objective.Status != ObjectiveStatusInProgress {
+		t.Errorf("Expected the interrupted objective left in progress, got %s", objective.Status)
+	}
+
+	// The next session finishes it before anything else
+	runner := &scriptedRunner{}
+	next := NewWorkLoop(store, om, runner, WorkLoopConfig{Limits: WorkLoopLimits{MaxObjectivesPerHour: 1, IdleInterval: time.Millisecond}})
+	done2 := make(chan *WorkLoopStatus)
+	go func() {
+		status, _ := next.Run(context.Background())
+		done2 <- status
+	}()
+	awaitWorkLoop(t, next, func(s WorkLoopStatus) bool { return s.State == WorkLoopRateLimited })
+	next.Stop()
+	<-done2
+	if ran := runner.ran(); len(ran) != 1 || ran[0] != interrupted {
+		t.Errorf("Expected only the interrupted objective run within the hourly limit, got %v", ran)
+	}
+}
+
+func TestWorkLoop_LockFile(t *testing.T) {
+	store := setupTestStore(t)
+	lockFile := filepath.Join(t.TempDir(), "work.lock")
+	if err := os.WriteFile(lockFile, []byte("4242\n"), 0600); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	config := WorkLoopConfig{Limits: WorkLoopLimits{IdleInterval: time.Millisecond}, LockFile: lockFile}
+
+	// A live holder keeps the loop from starting
+	wl := NewWorkLoop(store, NewObjectiveManager(store), &scriptedRunner{}, config)
+	if _, err := wl.Run(context.Background()); !errors.Is(err, ErrWorkLoopLocked) || !strings.Contains(err.Error(), "4242") {
+		t.Fatalf("Expected the lock to be refused, got %v", err)
+	}
+
+	// A holder that stopped refreshing the lock is taken over
+	stale := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lockFile, stale, stale); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+	wl = NewWorkLoop(store, NewObjectiveManager(store), &scriptedRunner{}, config)
+	wl.Stop()
+	if _, err := wl.Run(context.Background()); err != nil {
+		t.Fatalf("Expected a stale lock to be taken over, got %v", err)
+	}
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock released, got %v", err)
+	}
+}
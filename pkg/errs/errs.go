@@ -0,0 +1,254 @@
+// Package errs defines the public error code catalogue: a fixed set of codes
+// that say why an operation failed, independent of the message wording.
+// Scripts read them from the CLI's exit status and --json output, and the GUI
+// branches on them to pick how an error is shown.
+//
+// Errors carry a code by wrapping it, by being created with one, or by
+// implementing Coder; CodeOf finds it through any amount of wrapping:
+//
+//	err := errs.Newf(errs.NotFound, "goal %s not found", id).With("id", id)
+//	wrapped := fmt.Errorf("failed to load plan: %w", err)
+//	errs.CodeOf(wrapped)               // errs.NotFound
+//	errors.Is(wrapped, errs.NotFound) // true
+//
+// Codes are part of the public interface: once released, a code keeps its
+// name and exit status. Add new codes rather than repurposing old ones.
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code identifies a category of failure. A Code is itself an error, so it
+// can be wrapped directly or matched with errors.Is.
+type Code string
+
+const (
+	// NotFound means the named goal, objective, method or other record does not exist
+	NotFound Code = "NOT_FOUND"
+
+	// Conflict means the request contradicts the current state, such as an
+	// invalid status transition or an ambiguous ID prefix
+	Conflict Code = "CONFLICT"
+
+	// Validation means the input was malformed or out of range
+	Validation Code = "VALIDATION"
+
+	// BudgetExceeded means the work would spend more than a configured budget
+	BudgetExceeded Code = "BUDGET_EXCEEDED"
+
+	// QuotaExceeded means a provider rate-limited the request
+	QuotaExceeded Code = "QUOTA_EXCEEDED"
+
+	// PolicyBlocked means a policy refused the action, such as the network
+	// allowlist or the ethical framework
+	PolicyBlocked Code = "POLICY_BLOCKED"
+
+	// DependenciesNotMet means prerequisite work has not finished yet
+	DependenciesNotMet Code = "DEPENDENCIES_NOT_MET"
+
+	// ProviderAuth means an LLM or service provider rejected the credentials
+	ProviderAuth Code = "PROVIDER_AUTH"
+
+	// ProviderUnavailable means no provider could serve the request
+	ProviderUnavailable Code = "PROVIDER_UNAVAILABLE"
+
+	// ReadOnly means the store cannot be changed here, such as on a replica
+	ReadOnly Code = "READ_ONLY"
+
+	// WIPLimit means starting the work would exceed a work-in-progress limit
+	WIPLimit Code = "WIP_LIMIT"
+
+	// ApprovalRequired means the action waits on the user's approval
+	ApprovalRequired Code = "APPROVAL_REQUIRED"
+
+	// Timeout means the request ran past its deadline before it finished
+	Timeout Code = "TIMEOUT"
+
+	// Internal means an unexpected failure; uncoded errors report it too
+	Internal Code = "INTERNAL"
+)
+
+// catalogue lists every code with its CLI exit status, in documentation order.
+// Exit status 2 is left to flag parsing errors.
+var catalogue = []struct {
+	code        Code
+	exitCode    int
+	description string
+}{
+	{Internal, 1, "unexpected failure"},
+	{NotFound, 3, "the record does not exist"},
+	{Conflict, 4, "the request contradicts the current state"},
+	{Validation, 5, "the input is malformed or out of range"},
+	{BudgetExceeded, 6, "the work would exceed a budget"},
+	{QuotaExceeded, 7, "a provider rate-limited the request"},
+	{PolicyBlocked, 8, "a policy refused the action"},
+	{DependenciesNotMet, 9, "prerequisite work has not finished"},
+	{ProviderAuth, 10, "a provider rejected the credentials"},
+	{ProviderUnavailable, 11, "no provider could serve the request"},
+	{ReadOnly, 12, "the store cannot be changed here"},
+	{WIPLimit, 13, "a work-in-progress limit was reached"},
+	{ApprovalRequired, 14, "the action needs approval"},
+	{Timeout, 15, "the request ran past its deadline"},
+}
+
+// Codes returns every catalogued code.
+func Codes() []Code {
+	codes := make([]Code, len(catalogue))
+	for i, entry := range catalogue {
+		codes[i] = entry.code
+	}
+	return codes
+}
+
+// Known reports whether c is in the catalogue.
+func (c Code) Known() bool {
+	for _, entry := range catalogue {
+		if entry.code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitCode returns the CLI exit status for c: 0 for no code, and the status
+// of Internal for codes outside the catalogue.
+func (c Code) ExitCode() int {
+	if c == "" {
+		return 0
+	}
+	for _, entry := range catalogue {
+		if entry.code == c {
+			return entry.exitCode
+		}
+	}
+	return 1
+}
+
+// Description returns a short explanation of c.
+func (c Code) Description() string {
+	for _, entry := range catalogue {
+		if entry.code == c {
+			return entry.description
+		}
+	}
+	return ""
+}
+
+func (c Code) Error() string {
+	return string(c)
+}
+
+// ErrorCode returns c, so a wrapped Code is found by CodeOf.
+func (c Code) ErrorCode() Code {
+	return c
+}
+
+// Coder is implemented by errors that carry a code.
+type Coder interface {
+	ErrorCode() Code
+}
+
+// Error is an error with a code and structured details.
+type Error struct {
+	Code Code
+
+	// Message replaces Err's message when set
+	Message string
+
+	// Details are machine-readable facts about the failure, such as the ID
+	// that was not found; they appear in the CLI's JSON output
+	Details map[string]interface{}
+
+	// Err is the underlying error, if any
+	Err error
+}
+
+// New returns an error with a code and message, for sentinel errors.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Newf returns an error with a code and a formatted message. A %w verb
+// wraps its operand as with fmt.Errorf.
+func Newf(code Code, format string, args ...interface{}) *Error {
+	err := fmt.Errorf(format, args...)
+	return &Error{Code: code, Message: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// Wrap gives err a code and optional details, keeping its message. It
+// returns nil for a nil err.
+func Wrap(err error, code Code, details map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Details: details, Err: err}
+}
+
+// With adds a detail and returns e.
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" || e.Err == nil {
+		return e.Message
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns e's code.
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
+
+// Is matches e's code, so errors.Is(err, errs.NotFound) works.
+func (e *Error) Is(target error) bool {
+	code, ok := target.(Code)
+	return ok && code == e.Code
+}
+
+// CodeOf returns the code of the outermost coded error in err's chain,
+// Internal when nothing in the chain has one, and "" for a nil err.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var coder Coder
+	if errors.As(err, &coder) {
+		if code := coder.ErrorCode(); code != "" {
+			return code
+		}
+	}
+	return Internal
+}
+
+// DetailsOf merges the details of every Error in err's chain; outer errors
+// win when keys collide. It returns nil when there are none.
+func DetailsOf(err error) map[string]interface{} {
+	var details map[string]interface{}
+	for ; err != nil; err = errors.Unwrap(err) {
+		e, ok := err.(*Error)
+		if !ok {
+			continue
+		}
+		for key, value := range e.Details {
+			if details == nil {
+				details = make(map[string]interface{})
+			}
+			if _, exists := details[key]; !exists {
+				details[key] = value
+			}
+		}
+	}
+	return details
+}
@@ -0,0 +1,77 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCatalogue(t *testing.T) {
+	exitCodes := make(map[int]Code)
+	for _, code := range Codes() {
+		if !code.Known() || code.Description() == "" {
+			t.Errorf("Expected %s to be documented", code)
+		}
+		exit := code.ExitCode()
+		if exit == 0 || exit == 2 {
+			t.Errorf("%s uses reserved exit status %d", code, exit)
+		}
+		if other, exists := exitCodes[exit]; exists {
+			t.Errorf("%s and %s share exit status %d", code, other, exit)
+		}
+		exitCodes[exit] = code
+	}
+	if len(Codes()) != 14 {
+		t.Errorf("Expected 14 codes, got %d", len(Codes()))
+	}
+
+	if Code("").ExitCode() != 0 {
+		t.Error("Expected success for no code")
+	}
+	if Code("MADE_UP").Known() || Code("MADE_UP").ExitCode() != Internal.ExitCode() {
+		t.Error("Expected unknown codes to exit like INTERNAL")
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	if code := CodeOf(nil); code != "" {
+		t.Errorf("Expected no code for nil, got %q", code)
+	}
+	if code := CodeOf(errors.New("disk on fire")); code != Internal {
+		t.Errorf("Expected INTERNAL for an uncoded error, got %q", code)
+	}
+
+	// The outermost code wins, through any wrapping
+	inner := Newf(NotFound, "goal %s not found", "g1").With("id", "g1")
+	outer := fmt.Errorf("failed to plan: %w", Wrap(fmt.Errorf("lookup: %w", inner), Conflict, map[string]interface{}{"id": "p1", "step": 2}))
+	if code := CodeOf(outer); code != Conflict {
+		t.Errorf("Expected the outer code, got %q", code)
+	}
+	if !errors.Is(outer, Conflict) || !errors.Is(outer, NotFound) || errors.Is(outer, Validation) {
+		t.Error("Expected errors.Is to match the codes in the chain only")
+	}
+	details := DetailsOf(outer)
+	if details["id"] != "p1" || details["step"] != 2 {
+		t.Errorf("Expected outer details to win, got %v", details)
+	}
+	if outer.Error() != "failed to plan: lookup: goal g1 not found" {
+		t.Errorf("Expected messages to be kept, got %q", outer.Error())
+	}
+
+	// A bare code and a %w operand of Newf both work
+	if code := CodeOf(fmt.Errorf("stopped: %w", BudgetExceeded)); code != BudgetExceeded {
+		t.Errorf("Expected a wrapped code to be found, got %q", code)
+	}
+	cause := errors.New("connection refused")
+	err := Newf(ProviderUnavailable, "no provider answered: %w", cause)
+	if !errors.Is(err, cause) || err.Error() != "no provider answered: connection refused" {
+		t.Errorf("Expected Newf to wrap its cause, got %q", err)
+	}
+
+	if Wrap(nil, Internal, nil) != nil {
+		t.Error("Expected Wrap(nil) to be nil")
+	}
+	if DetailsOf(errors.New("plain")) != nil {
+		t.Error("Expected no details for an uncoded error")
+	}
+}
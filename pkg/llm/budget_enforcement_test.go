@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// enforcedBudget returns a budget manager with a $1.00 daily limit under
+// enforcement, of which spent is spent.
+func enforcedBudget(t *testing.T, enforcement mcp.BudgetEnforcement, spent float64) *BudgetManager {
+	t.Helper()
+	config := BudgetConfig{
+		DailyLimit:      1.0,
+		TrackingEnabled: true,
+		Enforcement:     enforcement,
+		GracePercent:    10,
+		Clock:           utils.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)),
+	}
+	budget, err := NewBudgetManager(t.TempDir(), config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	if err := budget.RecordUsage(context.Background(), Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: spent, Success: true}); err != nil {
+		t.Fatalf("Failed to record earlier spending: %v", err)
+	}
+	return budget
+}
+
+func approximately(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCanAfford_SoftEnforcementGraceExhaustedMidPlan(t *testing.T) {
+	ctx := context.Background()
+	budget := enforcedBudget(t, mcp.BudgetEnforcementSoftWithGrace, 0.90)
+
+	// Within the limit the grace is untouched
+	check, _ := budget.CanAffordInPlan(0.05, "plan_1")
+	if !check.Affordable || check.ViaGrace || !approximately(check.GraceRemaining, 0.10) {
+		t.Errorf("Expected the cost within the limit with all grace left, got %+v", check)
+	}
+
+	if err := budget.RecordUsage(ctx, Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 0.12, Success: true}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+
+	// Past the limit only the running plan goes on
+	if check, _ := budget.CanAfford(0.05); check.Affordable {
+		t.Errorf("Expected a request outside a plan refused at the limit, got %+v", check)
+	}
+	check, _ = budget.CanAffordInPlan(0.05, "plan_1")
+	if !check.Affordable || !check.ViaGrace || !approximately(check.GraceRemaining, 0.03) {
+		t.Errorf("Expected the plan's request allowed through grace with $0.03 left, got %+v", check)
+	}
+	if err := budget.RecordUsage(ctx, Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 0.05, Success: true}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+
+	// The grace itself runs out: what is left of it fits, more does not
+	check, _ = budget.CanAffordInPlan(0.025, "plan_1")
+	if !check.Affordable || !check.ViaGrace || !approximately(check.GraceRemaining, 0.005) {
+		t.Errorf("Expected the remaining grace to cover $0.025, got %+v", check)
+	}
+	check, _ = budget.CanAffordInPlan(0.04, "plan_1")
+	if check.Affordable || check.ViaGrace || check.GraceRemaining != 0 {
+		t.Errorf("Expected the plan refused once its grace is used up, got %+v", check)
+	}
+	if len(check.Exceeded) != 1 || check.Exceeded[0] != PeriodDaily {
+		t.Errorf("Expected the daily limit reported exceeded, got %v", check.Exceeded)
+	}
+}
+
+func TestCanAfford_EnforcementModes(t *testing.T) {
+	// Hard enforcement gives running plans no grace
+	hard := enforcedBudget(t, mcp.BudgetEnforcementHard, 1.0)
+	if check, _ := hard.CanAffordInPlan(0.01, "plan_1"); check.Affordable || check.GraceRemaining != 0 {
+		t.Errorf("Expected hard enforcement to refuse the plan, got %+v", check)
+	}
+
+	// Warn-only enforcement allows anything, saying what it is over
+	warn := enforcedBudget(t, mcp.BudgetEnforcementWarnOnly, 1.0)
+	check, _ := warn.CanAfford(5.0)
+	if !check.Affordable || check.ViaGrace || len(check.Exceeded) != 1 {
+		t.Errorf("Expected warn-only enforcement to allow the cost over the limit, got %+v", check)
+	}
+}
+
+func TestRoute_RunningPlanSpendsIntoGrace(t *testing.T) {
+	router := NewRouter(&anthropicOnlyService{})
+	router.SetBudgetManager(enforcedBudget(t, mcp.BudgetEnforcementSoftWithGrace, 1.0))
+
+	if _, err := router.Route(context.Background(), budgetRequest()); errs.CodeOf(err) != errs.BudgetExceeded {
+		t.Errorf("Expected a request outside a plan refused, got %v", err)
+	}
+
+	req := budgetRequest()
+	req.Metadata = map[string]interface{}{MetadataPlanID: "plan_1"}
+	if _, err := router.Route(context.Background(), req); err != nil {
+		t.Errorf("Expected the running plan's request allowed through grace, got %v", err)
+	}
+	plan, err := router.RoutePlan(context.Background(), req)
+	if err != nil {
+		t.Fatalf("RoutePlan failed: %v", err)
+	}
+	if metadata, _ := plan.PlannedParams["metadata"].(map[string]interface{}); metadata[MetadataPlanID] != "plan_1" {
+		t.Errorf("Expected the plan ID passed on to the service, got %v", plan.PlannedParams["metadata"])
+	}
+}
@@ -0,0 +1,464 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// DefaultSnapshotInterval is the number of journal records between the
+// snapshots that bound how much of the journal startup replays.
+const DefaultSnapshotInterval = 100
+
+const (
+	// journalFile is the append-only transaction journal, one JSON
+	// Transaction, or quality rating of one, per line
+	journalFile = "budget_journal.jsonl"
+
+	// snapshotFile holds the aggregates up to an offset in the journal
+	snapshotFile = "budget_snapshot.json"
+
+	// usageFile is the usage file kept before the journal, which the
+	// journal's records are replayed on top of
+	usageFile = "budget_usage.json"
+)
+
+// integrityTolerance absorbs float rounding when aggregates are compared.
+const integrityTolerance = 1e-9
+
+// journalEntry is one journal record: a transaction, or a quality rating of
+// an earlier one.
+type journalEntry struct {
+	Transaction
+	Rating *qualityRating `json:"rating,omitempty"`
+}
+
+// qualityRating rates the quality of a recorded transaction.
+type qualityRating struct {
+	TransactionID string    `json:"transaction_id"`
+	Quality       float64   `json:"quality"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// ratingLine is the journal line of a rating, without the empty transaction
+// a journalEntry would carry.
+type ratingLine struct {
+	Rating *qualityRating `json:"rating"`
+}
+
+// budgetSnapshot is the usage recorded by the journal up to JournalOffset.
+type budgetSnapshot struct {
+	JournalOffset int64         `json:"journal_offset"`
+	Usage         *UsageTracker `json:"usage"`
+}
+
+// budgetJournal appends transactions to the journal file.
+type budgetJournal struct {
+	path string
+
+	// size is the length of the journal's valid records
+	size int64
+
+	// sinceSnapshot counts the records appended since the last snapshot
+	sinceSnapshot int
+}
+
+// append writes record, a Transaction or a rating, as one line and syncs
+// it to disk. A failed write is cut off again so the journal never keeps
+// half a record.
+func (j *budgetJournal) append(record interface{}) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal record: %w", err)
+	}
+	line = append(line, '\n')
+
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open budget journal: %w", err)
+	}
+	if _, err := file.Write(line); err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Truncate(j.size)
+		file.Close()
+		return fmt.Errorf("failed to write budget journal: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close budget journal: %w", err)
+	}
+
+	j.size += int64(len(line))
+	j.sinceSnapshot++
+	return nil
+}
+
+// read returns the journal's records from offset on, and the offset
+// its valid records end at. A record that does not end in a newline or does
+// not parse is only allowed last, where it is what a write interrupted by a
+// crash leaves behind; corrupt reports whether there was one.
+func (j *budgetJournal) read(ctx context.Context, offset int64) (entries []journalEntry, end int64, corrupt bool, err error) {
+	file, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to open budget journal: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, false, fmt.Errorf("failed to seek budget journal: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	end = offset
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, false, err
+		}
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, 0, false, fmt.Errorf("failed to read budget journal: %w", readErr)
+		}
+		if len(line) == 0 {
+			return entries, end, false, nil
+		}
+
+		var entry journalEntry
+		complete := readErr == nil
+		if complete && json.Unmarshal(bytes.TrimSpace(line), &entry) == nil {
+			entries = append(entries, entry)
+			end += int64(len(line))
+			continue
+		}
+
+		// Only the last record can be cut short
+		if _, err := reader.Peek(1); err == io.EOF {
+			return entries, end, true, nil
+		}
+		return nil, 0, false, errs.Newf(errs.Internal, "budget journal is corrupt after offset %d", end).
+			With("path", j.path)
+	}
+}
+
+// truncate cuts the journal back to its valid records.
+func (j *budgetJournal) truncate(size int64) error {
+	if err := os.Truncate(j.path, size); err != nil {
+		return fmt.Errorf("failed to truncate budget journal: %w", err)
+	}
+	j.size = size
+	return nil
+}
+
+// SaveSnapshot saves usage as the aggregates of the journal up to offset.
+// It is written to a temporary file first, so a crash leaves either the old
+// snapshot or the new one.
+func (bp *BudgetPersistence) SaveSnapshot(usage *UsageTracker, offset int64) error {
+	data, err := json.Marshal(budgetSnapshot{JournalOffset: offset, Usage: usage})
+	if err != nil {
+		return fmt.Errorf("failed to marshal budget snapshot: %w", err)
+	}
+
+	filePath := filepath.Join(bp.dataPath, snapshotFile)
+	tmp, err := os.CreateTemp(bp.dataPath, snapshotFile+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create budget snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write budget snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to replace budget snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot loads the latest snapshot and the journal offset it covers.
+func (bp *BudgetPersistence) LoadSnapshot() (*UsageTracker, int64, error) {
+	data, err := os.ReadFile(filepath.Join(bp.dataPath, snapshotFile))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var snapshot budgetSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal budget snapshot: %w", err)
+	}
+	if snapshot.Usage == nil {
+		return nil, 0, fmt.Errorf("budget snapshot has no usage")
+	}
+	snapshot.Usage.initMaps()
+	return snapshot.Usage, snapshot.JournalOffset, nil
+}
+
+// load restores the usage from the latest snapshot and the journal records
+// after it, truncating a record a crash cut short.
+func (bm *BudgetManager) load(ctx context.Context) error {
+	usage, offset, err := bm.persistence.LoadSnapshot()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			bm.warnf("could not load budget snapshot: %v. Replaying the whole journal.", err)
+		}
+		usage, offset = bm.baseUsage(), 0
+	}
+	if offset > bm.journalSize() {
+		bm.warnf("budget snapshot is ahead of the journal (offset %d). Replaying the whole journal.", offset)
+		usage, offset = bm.baseUsage(), 0
+	}
+	bm.usage = usage
+
+	entries, end, corrupt, err := bm.journal.read(ctx, offset)
+	if err != nil {
+		return err
+	}
+	if corrupt {
+		bm.warnf("budget journal ends in an incomplete record; truncating it to its last valid record at offset %d", end)
+		if err := bm.journal.truncate(end); err != nil {
+			return err
+		}
+	}
+	bm.journal.size = end
+
+	for _, entry := range entries {
+		bm.applyEntry(entry)
+	}
+	bm.journal.sinceSnapshot = len(entries)
+	bm.maybeSnapshot()
+	return nil
+}
+
+// applyEntry applies a journal record to the aggregates.
+func (bm *BudgetManager) applyEntry(entry journalEntry) {
+	if entry.Rating != nil {
+		bm.applyQuality(*entry.Rating)
+		return
+	}
+	bm.apply(entry.Transaction)
+}
+
+// RecordQuality rates the quality (1-10) of a recorded transaction, such as
+// from feedback on the completion, replacing any rating it had. The rating is
+// journaled like the transaction and folded into its provider's ROI metrics.
+// Only transactions in the log, kept while TrackingEnabled is set, can be
+// rated.
+func (bm *BudgetManager) RecordQuality(ctx context.Context, transactionID string, quality float64) error {
+	if quality < 1 || quality > 10 {
+		return errs.Newf(errs.Validation, "quality must be between 1 and 10, got %g", quality)
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if bm.findTransaction(transactionID) < 0 {
+		return errs.Newf(errs.NotFound, "transaction %s is not in the transaction log", transactionID).
+			With("transaction_id", transactionID)
+	}
+	rating := qualityRating{TransactionID: transactionID, Quality: quality, Timestamp: bm.config.Clock.Now()}
+	if err := bm.journal.append(ratingLine{Rating: &rating}); err != nil {
+		return err
+	}
+	bm.applyQuality(rating)
+	bm.maybeSnapshot()
+	return nil
+}
+
+// findTransaction returns the index of the latest logged transaction with
+// the ID, or -1.
+func (bm *BudgetManager) findTransaction(id string) int {
+	for i := len(bm.usage.Transactions) - 1; i >= 0; i-- {
+		if bm.usage.Transactions[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyQuality sets a logged transaction's quality and folds it into its
+// provider's average quality, in place of the quality it replaces.
+func (bm *BudgetManager) applyQuality(rating qualityRating) {
+	i := bm.findTransaction(rating.TransactionID)
+	if i < 0 {
+		return
+	}
+	tx := &bm.usage.Transactions[i]
+	previous := tx.Quality
+	tx.Quality = rating.Quality
+
+	roi, exists := bm.usage.ProviderROI[tx.Provider]
+	if !exists || roi.TotalRequests == 0 {
+		return
+	}
+	n := float64(roi.TotalRequests)
+	if previous >= 1.0 && previous <= 10.0 {
+		roi.AverageQuality += (rating.Quality - previous) / n
+	} else if roi.AverageQuality == 0 {
+		roi.AverageQuality = rating.Quality
+	} else {
+		roi.AverageQuality = (roi.AverageQuality*(n-1) + rating.Quality) / n
+	}
+	if roi.TotalSpent > 0 && roi.AverageQuality > 0 {
+		roi.QualityPerDollar = roi.AverageQuality / roi.TotalSpent
+	}
+	roi.LastUpdated = rating.Timestamp
+}
+
+// journalSize returns the journal file's length.
+func (bm *BudgetManager) journalSize() int64 {
+	info, err := os.Stat(bm.journal.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// baseUsage returns the usage the journal starts from: that of the usage
+// file kept before the journal, if any, or none.
+func (bm *BudgetManager) baseUsage() *UsageTracker {
+	usage, err := bm.persistence.LoadUsage()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			bm.warnf("could not load existing usage data: %v. Starting fresh.", err)
+		}
+		usage = newUsageTracker()
+	}
+
+	base := &BudgetManager{config: bm.config, usage: usage, logger: bm.logger}
+	base.migrateUsage()
+	return usage
+}
+
+// maybeSnapshot saves a snapshot once enough records were appended since
+// the last one.
+func (bm *BudgetManager) maybeSnapshot() {
+	if bm.journal.sinceSnapshot < bm.config.SnapshotInterval {
+		return
+	}
+	if err := bm.persistence.SaveSnapshot(bm.usage, bm.journal.size); err != nil {
+		bm.warnf("failed to save budget snapshot: %v", err)
+		return
+	}
+	bm.journal.sinceSnapshot = 0
+}
+
+// warnf logs a warning through the configured utils.Logger, or the
+// manager's logger without one.
+func (bm *BudgetManager) warnf(format string, args ...interface{}) {
+	if bm.config.Logger != nil {
+		bm.config.Logger.Warning(utils.LogContext{Component: "budget"}, fmt.Sprintf(format, args...))
+		return
+	}
+	bm.logger.Printf("Warning: "+format, args...)
+}
+
+// IntegrityReport is the result of checking the budget's aggregates
+// against its journal.
+type IntegrityReport struct {
+	// Records is the number of journal records replayed
+	Records int
+
+	// Discrepancies lists the aggregates that differ from the replay, in
+	// aggregate and key order
+	Discrepancies []Discrepancy
+}
+
+// OK reports whether the aggregates match the journal.
+func (r *IntegrityReport) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// Discrepancy is an aggregate that differs from the journal's replay.
+type Discrepancy struct {
+	// Aggregate names the total, such as "monthly" or "provider"
+	Aggregate string
+
+	// Key is the period or name the total is kept under
+	Key string
+
+	// Recorded is the total the manager holds, Replayed the journal's
+	Recorded float64
+	Replayed float64
+}
+
+// VerifyIntegrity recomputes the aggregates by replaying the whole journal
+// and reports where they differ from the ones the manager holds.
+func (bm *BudgetManager) VerifyIntegrity(ctx context.Context) (*IntegrityReport, error) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	entries, _, _, err := bm.journal.read(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	replica := &BudgetManager{config: bm.config, logger: bm.logger}
+	replica.usage = bm.baseUsage()
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		replica.applyEntry(entry)
+	}
+
+	report := &IntegrityReport{Records: len(entries)}
+	compare := func(aggregate string, recorded, replayed map[string]float64) {
+		keys := make(map[string]bool)
+		for key := range recorded {
+			keys[key] = true
+		}
+		for key := range replayed {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			if math.Abs(recorded[key]-replayed[key]) > integrityTolerance {
+				report.Discrepancies = append(report.Discrepancies, Discrepancy{
+					Aggregate: aggregate,
+					Key:       key,
+					Recorded:  recorded[key],
+					Replayed:  replayed[key],
+				})
+			}
+		}
+	}
+	compare("daily", bm.usage.Daily, replica.usage.Daily)
+	compare("weekly", bm.usage.Weekly, replica.usage.Weekly)
+	compare("monthly", bm.usage.Monthly, replica.usage.Monthly)
+	compare("provider", bm.usage.ProviderSpending, replica.usage.ProviderSpending)
+	compare("model", bm.usage.ModelSpending, replica.usage.ModelSpending)
+	compare("task_type", bm.usage.TaskTypeSpending, replica.usage.TaskTypeSpending)
+	if recorded, replayed := len(bm.usage.Transactions), len(replica.usage.Transactions); recorded != replayed {
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			Aggregate: "transactions",
+			Recorded:  float64(recorded),
+			Replayed:  float64(replayed),
+		})
+	}
+	return report, nil
+}
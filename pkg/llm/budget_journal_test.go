@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// warningLogger records the warnings logged through it.
+type warningLogger struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *warningLogger) Debug(utils.LogContext, string, ...map[string]interface{}) {}
+func (l *warningLogger) Info(utils.LogContext, string, ...map[string]interface{})  {}
+func (l *warningLogger) Error(utils.LogContext, string, ...map[string]interface{}) {}
+func (l *warningLogger) WithContext(utils.LogContext) utils.Logger                 { return l }
+func (l *warningLogger) Close() error                                              { return nil }
+
+func (l *warningLogger) Warning(ctx utils.LogContext, message string, fields ...map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, message)
+}
+
+func journalConfig() BudgetConfig {
+	config := DefaultBudgetConfig()
+	config.Clock = utils.NewFakeClock(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	return config
+}
+
+func TestBudgetJournal_TruncatesPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	config := journalConfig()
+
+	bm, err := NewBudgetManager(dir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	for _, cost := range []float64{0.25, 0.5} {
+		if err := bm.RecordUsage(ctx, Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: cost, Success: true}); err != nil {
+			t.Fatalf("Failed to record usage: %v", err)
+		}
+	}
+
+	// The process dies halfway through writing a third record
+	path := filepath.Join(dir, journalFile)
+	valid, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	if err := os.WriteFile(path, append(append([]byte{}, valid...), `{"id": "3", "provider": "anth`...), 0644); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+
+	logger := &warningLogger{}
+	config.Logger = logger
+	reopened, err := NewBudgetManager(dir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to reopen budget manager: %v", err)
+	}
+	if got := reopened.GetSpending(PeriodMonthly, config.Clock.Now()); got != 0.75 {
+		t.Errorf("Expected monthly spending of the valid records, 0.75, got %.2f", got)
+	}
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "truncating") {
+		t.Errorf("Expected a truncation warning, got %v", logger.warnings)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(valid) {
+		t.Errorf("Expected the journal truncated to its valid records, got %q", data)
+	}
+
+	// New records follow the valid ones
+	if err := reopened.RecordUsage(ctx, Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 1.0, Success: true}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+	report, err := reopened.VerifyIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if !report.OK() || report.Records != 3 {
+		t.Errorf("Expected 3 consistent records, got %+v", report)
+	}
+
+	// A corrupt record before valid ones is not a partial write
+	if err := os.WriteFile(path, append([]byte("not json\n"), valid...), 0644); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+	os.Remove(filepath.Join(dir, snapshotFile))
+	if _, err := NewBudgetManager(dir, config, testLogger()); err == nil {
+		t.Error("Expected a journal corrupt before its end to be refused")
+	}
+}
+
+func TestBudgetJournal_SnapshotsBoundReplay(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	config := journalConfig()
+	config.SnapshotInterval = 3
+
+	bm, err := NewBudgetManager(dir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := bm.RecordUsage(ctx, Transaction{Provider: "openai", Model: "gpt-4o-mini", TaskType: "analysis", Cost: 0.1, Success: true}); err != nil {
+			t.Fatalf("Failed to record usage: %v", err)
+		}
+	}
+
+	_, offset, err := bm.persistence.LoadSnapshot()
+	if err != nil {
+		t.Fatalf("Expected a snapshot after 3 records: %v", err)
+	}
+	if offset == 0 || offset >= bm.journal.size {
+		t.Errorf("Expected the snapshot to cover 3 of the 4 records, got offset %d of %d", offset, bm.journal.size)
+	}
+
+	reopened, err := NewBudgetManager(dir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to reopen budget manager: %v", err)
+	}
+	if reopened.journal.sinceSnapshot != 1 {
+		t.Errorf("Expected only the record after the snapshot replayed, got %d", reopened.journal.sinceSnapshot)
+	}
+	if got := len(reopened.GetTransactions()); got != 4 {
+		t.Errorf("Expected 4 transactions, got %d", got)
+	}
+	if got := reopened.GetSpending(PeriodDaily, config.Clock.Now()); got < 0.4-integrityTolerance || got > 0.4+integrityTolerance {
+		t.Errorf("Expected daily spending of 0.40, got %.2f", got)
+	}
+}
+
+func TestBudgetJournal_VerifyIntegrityReportsDiscrepancies(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	config := journalConfig()
+
+	bm, err := NewBudgetManager(dir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	// Concurrent callers each get their transaction journaled and counted
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := bm.RecordUsage(ctx, Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 0.125, Success: true}); err != nil {
+				t.Errorf("Failed to record usage: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	report, err := bm.VerifyIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if !report.OK() || report.Records != 20 {
+		t.Fatalf("Expected 20 consistent records, got %+v", report)
+	}
+
+	// Totals that drifted from the journal are reported
+	month := bm.getPeriodKey(PeriodMonthly, config.Clock.Now())
+	bm.usage.Monthly[month] += 1.0
+	report, err = bm.VerifyIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	want := Discrepancy{Aggregate: "monthly", Key: month, Recorded: 3.5, Replayed: 2.5}
+	if len(report.Discrepancies) != 1 || report.Discrepancies[0] != want {
+		t.Errorf("Expected discrepancy %+v, got %+v", want, report.Discrepancies)
+	}
+}
@@ -5,10 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/metrics"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 // BudgetPeriod represents different budget tracking periods.
@@ -48,10 +55,12 @@ type BudgetConfig struct {
 	// MonthlyLimit is the maximum monthly spending allowed
 	MonthlyLimit float64
 
-	// AlertThresholds defines when to send alerts (default: 75%, 90%, 100%)
+	// AlertThresholds defines when to send alerts, as percentages of each
+	// limit (default when nil: 75%, 90%, 100%; empty disables alerts)
 	AlertThresholds []float64
 
-	// AlertCallback is called when thresholds are exceeded
+	// AlertCallback is called when thresholds are exceeded, before the
+	// callbacks registered with OnAlert
 	AlertCallback func(AlertInfo)
 
 	// AutoStop prevents further requests when budget is exceeded
@@ -62,6 +71,33 @@ type BudgetConfig struct {
 
 	// TrackingEnabled enables detailed expense tracking
 	TrackingEnabled bool
+
+	// Clock decides which day, week and month spending falls in
+	// (default: the system clock)
+	Clock utils.Clock
+
+	// Location is the time zone whose midnights start budget days, whose
+	// Mondays start budget weeks (ISO weeks) and whose first days of the
+	// month start budget months (default: the zone of the clock's times,
+	// the local time zone for the system clock)
+	Location *time.Location
+
+	// SnapshotInterval is the number of journal records between snapshots
+	// of the aggregates (default: DefaultSnapshotInterval)
+	SnapshotInterval int
+
+	// Logger receives warnings about the budget's files, like a journal
+	// record cut short by a crash (default: the manager's logger)
+	Logger utils.Logger
+
+	// Enforcement is how spending past a limit is treated (default:
+	// mcp.BudgetEnforcementHard, which AutoStop and GracePeriod refine)
+	Enforcement mcp.BudgetEnforcement
+
+	// GracePercent is how far past a limit, as a percentage of it, running
+	// plans may spend under mcp.BudgetEnforcementSoftWithGrace (default:
+	// mcp.DefaultGracePercent)
+	GracePercent float64
 }
 
 // DefaultBudgetConfig returns sensible defaults for budget configuration.
@@ -85,6 +121,9 @@ type BudgetManager struct {
 	alerts       *AlertManager
 	mu           sync.RWMutex
 	logger       *log.Logger
+
+	// journal records every transaction; the aggregates are its replay
+	journal *budgetJournal
 }
 
 // UsageTracker tracks spending across different time periods.
@@ -103,6 +142,10 @@ type UsageTracker struct {
 
 	// Performance tracking for ROI analysis
 	ProviderROI map[string]*ProviderROI // provider -> ROI metrics
+
+	// Alerts fired in the current periods, keyed by alertKey. They are kept
+	// so that each threshold fires once per period, across restarts too.
+	Alerts map[string]AlertInfo `json:",omitempty"`
 }
 
 // Transaction represents a single LLM request transaction.
@@ -118,6 +161,13 @@ type Transaction struct {
 	Quality     float64   `json:"quality,omitempty"` // 1-10 rating
 	Latency     int64     `json:"latency_ms"`        // milliseconds
 	UserID      string    `json:"user_id,omitempty"`
+
+	// GoalID and ObjectiveID attribute the spending to the work it was for
+	GoalID      string `json:"goal_id,omitempty"`
+	ObjectiveID string `json:"objective_id,omitempty"`
+
+	// Tags attribute the spending further, e.g. to a method comparison branch
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 // ProviderROI tracks return on investment metrics for each provider.
@@ -134,8 +184,8 @@ type ProviderROI struct {
 
 // AlertManager handles budget alert notifications.
 type AlertManager struct {
-	triggeredAlerts map[string]time.Time // threshold_period -> last alert time
-	mu              sync.RWMutex
+	callbacks []func(AlertInfo)
+	mu        sync.RWMutex
 }
 
 // BudgetPersistence handles saving/loading budget data.
@@ -154,7 +204,9 @@ type AlertInfo struct {
 	Message       string
 }
 
-// NewBudgetManager creates a new budget manager with persistence.
+// NewBudgetManager creates a new budget manager with persistence. Every
+// transaction is appended to a journal in dataPath, and the aggregates are
+// restored by replaying it from the latest snapshot.
 func NewBudgetManager(dataPath string, config BudgetConfig, logger *log.Logger) (*BudgetManager, error) {
 	if logger == nil {
 		logger = log.New(os.Stdout, "[BudgetManager] ", log.LstdFlags)
@@ -165,46 +217,188 @@ func NewBudgetManager(dataPath string, config BudgetConfig, logger *log.Logger)
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	persistence := &BudgetPersistence{dataPath: dataPath}
-
-	// Load existing usage data
-	usage, err := persistence.LoadUsage()
-	if err != nil {
-		// If loading fails, start with fresh usage tracker
-		logger.Printf("Warning: could not load existing usage data: %v. Starting fresh.", err)
-		usage = &UsageTracker{
-			Daily:            make(map[string]float64),
-			Weekly:           make(map[string]float64),
-			Monthly:          make(map[string]float64),
-			Transactions:     make([]Transaction, 0),
-			ProviderSpending: make(map[string]float64),
-			ModelSpending:    make(map[string]float64),
-			TaskTypeSpending: make(map[string]float64),
-			ProviderROI:      make(map[string]*ProviderROI),
-		}
+	config.Clock = utils.ClockOrReal(config.Clock)
+	if config.Location == nil {
+		config.Location = config.Clock.Now().Location()
+	}
+	if config.AlertThresholds == nil {
+		config.AlertThresholds = DefaultBudgetConfig().AlertThresholds
+	}
+	if config.SnapshotInterval <= 0 {
+		config.SnapshotInterval = DefaultSnapshotInterval
+	}
+	if config.Enforcement == "" {
+		config.Enforcement = mcp.BudgetEnforcementHard
+	}
+	if config.GracePercent <= 0 {
+		config.GracePercent = mcp.DefaultGracePercent
 	}
 
 	manager := &BudgetManager{
 		config:      config,
-		usage:       usage,
-		persistence: persistence,
-		alerts: &AlertManager{
-			triggeredAlerts: make(map[string]time.Time),
-		},
-		logger: logger,
+		persistence: &BudgetPersistence{dataPath: dataPath},
+		alerts:      &AlertManager{},
+		logger:      logger,
+		journal:     &budgetJournal{path: filepath.Join(dataPath, journalFile)},
+	}
+	if err := manager.load(context.Background()); err != nil {
+		return nil, err
 	}
 
 	return manager, nil
 }
 
-// RecordUsage records a new transaction and updates budget tracking.
+// newUsageTracker returns an empty usage tracker.
+func newUsageTracker() *UsageTracker {
+	usage := &UsageTracker{Transactions: make([]Transaction, 0)}
+	usage.initMaps()
+	return usage
+}
+
+// initMaps makes the maps that are nil, as in files written before they
+// were kept.
+func (usage *UsageTracker) initMaps() {
+	if usage.Daily == nil {
+		usage.Daily = make(map[string]float64)
+	}
+	if usage.Weekly == nil {
+		usage.Weekly = make(map[string]float64)
+	}
+	if usage.Monthly == nil {
+		usage.Monthly = make(map[string]float64)
+	}
+	if usage.ProviderSpending == nil {
+		usage.ProviderSpending = make(map[string]float64)
+	}
+	if usage.ModelSpending == nil {
+		usage.ModelSpending = make(map[string]float64)
+	}
+	if usage.TaskTypeSpending == nil {
+		usage.TaskTypeSpending = make(map[string]float64)
+	}
+	if usage.ProviderROI == nil {
+		usage.ProviderROI = make(map[string]*ProviderROI)
+	}
+}
+
+// migrateUsage fills in the period totals a usage file written before they
+// were all kept lacks, and reports whether it changed anything. Files that
+// only kept daily totals get their weekly and monthly totals summed from
+// them; files with no totals at all get them from the transaction log.
+func (bm *BudgetManager) migrateUsage() bool {
+	usage := bm.usage
+	if len(usage.Daily) == 0 && len(usage.Weekly) == 0 && len(usage.Monthly) == 0 {
+		for _, tx := range usage.Transactions {
+			bm.updateTimeBasedSpending(tx)
+		}
+		return len(usage.Transactions) > 0
+	}
+	if len(usage.Daily) == 0 || (len(usage.Weekly) > 0 && len(usage.Monthly) > 0) {
+		return false
+	}
+
+	weekly := make(map[string]float64)
+	monthly := make(map[string]float64)
+	for date, amount := range usage.Daily {
+		day, err := time.ParseInLocation("2006-01-02", date, bm.config.Location)
+		if err != nil {
+			bm.logger.Printf("Warning: skipping budget day %q: %v", date, err)
+			continue
+		}
+		weekly[bm.getPeriodKey(PeriodWeekly, day)] += amount
+		monthly[bm.getPeriodKey(PeriodMonthly, day)] += amount
+	}
+	if len(usage.Weekly) == 0 {
+		usage.Weekly = weekly
+	}
+	if len(usage.Monthly) == 0 {
+		usage.Monthly = monthly
+	}
+	return true
+}
+
+// OnAlert registers fn to be called with every alert RecordUsage fires.
+// Callbacks run after the usage is recorded, outside the manager's lock, so
+// they may query the manager.
+func (bm *BudgetManager) OnAlert(fn func(AlertInfo)) {
+	bm.alerts.mu.Lock()
+	defer bm.alerts.mu.Unlock()
+	bm.alerts.callbacks = append(bm.alerts.callbacks, fn)
+}
+
+// ApplyConfig updates the limits and how they are enforced while the
+// manager runs: the daily, weekly and monthly limits, TrackingEnabled,
+// Enforcement, GracePercent and, when set, Location. Empty fields take the
+// defaults NewBudgetManager gives them. The other fields of config are fixed
+// when the manager is created and are ignored. Spending already recorded is
+// checked against the new limits from the next request on.
+func (bm *BudgetManager) ApplyConfig(config BudgetConfig) error {
+	if config.DailyLimit < 0 || config.WeeklyLimit < 0 || config.MonthlyLimit < 0 {
+		return errs.New(errs.Validation, "budget limits cannot be negative")
+	}
+	if config.GracePercent < 0 {
+		return errs.Newf(errs.Validation, "grace percent cannot be negative, got %g", config.GracePercent)
+	}
+	enforcement, err := mcp.ParseBudgetEnforcement(string(config.Enforcement))
+	if err != nil {
+		return err
+	}
+	config.Enforcement = enforcement
+	if config.GracePercent == 0 {
+		config.GracePercent = mcp.DefaultGracePercent
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.config.DailyLimit = config.DailyLimit
+	bm.config.WeeklyLimit = config.WeeklyLimit
+	bm.config.MonthlyLimit = config.MonthlyLimit
+	bm.config.TrackingEnabled = config.TrackingEnabled
+	bm.config.Enforcement = config.Enforcement
+	bm.config.GracePercent = config.GracePercent
+	if config.Location != nil {
+		bm.config.Location = config.Location
+	}
+	return nil
+}
+
+// RecordUsage records a new transaction and updates budget tracking, then
+// notifies alert callbacks of any threshold the spending crossed. The
+// transaction is synced to the journal before the aggregates change, under
+// the manager's lock, so concurrent callers and crashes never leave the
+// aggregates out of step with the journal. If it cannot be journaled,
+// nothing is recorded and the error is returned.
 func (bm *BudgetManager) RecordUsage(ctx context.Context, transaction Transaction) error {
+	alerts, err := bm.recordUsage(transaction)
+	if err != nil {
+		return err
+	}
+
+	bm.alerts.mu.RLock()
+	callbacks := append([]func(AlertInfo){bm.config.AlertCallback}, bm.alerts.callbacks...)
+	bm.alerts.mu.RUnlock()
+	for _, alert := range alerts {
+		bm.logger.Printf("Budget Alert: %s", alert.Message)
+		metrics.BudgetAlerts.Inc(alert.Period.String(), strconv.FormatFloat(alert.Threshold, 'g', -1, 64))
+		for _, callback := range callbacks {
+			if callback != nil {
+				callback(alert)
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordUsage journals a transaction, updates the spending totals for it
+// and returns the alerts it fired.
+func (bm *BudgetManager) recordUsage(transaction Transaction) ([]AlertInfo, error) {
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
 
 	// Set transaction timestamp if not provided
 	if transaction.Timestamp.IsZero() {
-		transaction.Timestamp = time.Now()
+		transaction.Timestamp = bm.config.Clock.Now()
 	}
 
 	// Generate ID if not provided
@@ -215,6 +409,19 @@ func (bm *BudgetManager) RecordUsage(ctx context.Context, transaction Transactio
 			transaction.Model)
 	}
 
+	if err := bm.journal.append(transaction); err != nil {
+		return nil, err
+	}
+	alerts := bm.apply(transaction)
+	bm.maybeSnapshot()
+	bm.recordSpendMetrics()
+
+	return alerts, nil
+}
+
+// apply updates the spending totals for a journaled transaction and returns
+// the alerts it fired.
+func (bm *BudgetManager) apply(transaction Transaction) []AlertInfo {
 	// Add to transaction log
 	if bm.config.TrackingEnabled {
 		bm.usage.Transactions = append(bm.usage.Transactions, transaction)
@@ -230,25 +437,14 @@ func (bm *BudgetManager) RecordUsage(ctx context.Context, transaction Transactio
 	bm.updateROIMetrics(transaction)
 
 	// Check for budget alerts
-	bm.checkBudgetAlerts(transaction.Timestamp)
-
-	// Persist data
-	if err := bm.persistence.SaveUsage(bm.usage); err != nil {
-		bm.logger.Printf("Warning: failed to persist budget data: %v", err)
-	}
-
-	return nil
+	return bm.checkBudgetAlerts(transaction.Timestamp)
 }
 
 // updateTimeBasedSpending updates daily, weekly, and monthly spending totals.
 func (bm *BudgetManager) updateTimeBasedSpending(tx Transaction) {
-	date := tx.Timestamp.Format("2006-01-02")
-	week := bm.getWeekKey(tx.Timestamp)
-	month := tx.Timestamp.Format("2006-01")
-
-	bm.usage.Daily[date] += tx.Cost
-	bm.usage.Weekly[week] += tx.Cost
-	bm.usage.Monthly[month] += tx.Cost
+	bm.usage.Daily[bm.getPeriodKey(PeriodDaily, tx.Timestamp)] += tx.Cost
+	bm.usage.Weekly[bm.getPeriodKey(PeriodWeekly, tx.Timestamp)] += tx.Cost
+	bm.usage.Monthly[bm.getPeriodKey(PeriodMonthly, tx.Timestamp)] += tx.Cost
 }
 
 // updateProviderSpending updates provider and model spending breakdowns.
@@ -307,67 +503,106 @@ func (bm *BudgetManager) updateROIMetrics(tx Transaction) {
 		roi.QualityPerDollar = roi.AverageQuality / roi.TotalSpent
 	}
 
-	roi.LastUpdated = time.Now()
+	roi.LastUpdated = bm.config.Clock.Now()
 }
 
-// checkBudgetAlerts checks if any budget thresholds have been exceeded.
-func (bm *BudgetManager) checkBudgetAlerts(timestamp time.Time) {
-	// Check daily budget
-	if bm.config.DailyLimit > 0 {
-		bm.checkPeriodAlert(PeriodDaily, timestamp, bm.config.DailyLimit)
+// checkBudgetAlerts returns the alerts for thresholds the spending has
+// reached and that have not fired yet in their period, and marks them fired.
+func (bm *BudgetManager) checkBudgetAlerts(timestamp time.Time) []AlertInfo {
+	if bm.usage.Alerts == nil {
+		bm.usage.Alerts = make(map[string]AlertInfo)
 	}
+	bm.pruneAlerts(bm.config.Clock.Now())
 
-	// Check weekly budget
-	if bm.config.WeeklyLimit > 0 {
-		bm.checkPeriodAlert(PeriodWeekly, timestamp, bm.config.WeeklyLimit)
+	var alerts []AlertInfo
+	limits := []struct {
+		period BudgetPeriod
+		limit  float64
+	}{
+		{PeriodDaily, bm.config.DailyLimit},
+		{PeriodWeekly, bm.config.WeeklyLimit},
+		{PeriodMonthly, bm.config.MonthlyLimit},
 	}
-
-	// Check monthly budget
-	if bm.config.MonthlyLimit > 0 {
-		bm.checkPeriodAlert(PeriodMonthly, timestamp, bm.config.MonthlyLimit)
+	for _, l := range limits {
+		if l.limit > 0 {
+			alerts = append(alerts, bm.checkPeriodAlert(l.period, timestamp, l.limit)...)
+		}
 	}
+	return alerts
 }
 
-// checkPeriodAlert checks if alerts should be triggered for a specific period.
-func (bm *BudgetManager) checkPeriodAlert(period BudgetPeriod, timestamp time.Time, limit float64) {
+// checkPeriodAlert returns the alerts to fire for a specific period. A fired
+// threshold stays fired for the rest of the period even if a refund takes
+// the spending back below it, so crossing it again does not alert twice.
+func (bm *BudgetManager) checkPeriodAlert(period BudgetPeriod, timestamp time.Time, limit float64) []AlertInfo {
 	usage := bm.getCurrentUsage(period, timestamp)
 	percentage := (usage / limit) * 100
 
+	var alerts []AlertInfo
 	for _, threshold := range bm.config.AlertThresholds {
-		if percentage >= threshold {
-			alertKey := fmt.Sprintf("%.0f_%s_%s", threshold, period.String(), bm.getPeriodKey(period, timestamp))
-
-			// Check if we've already alerted for this threshold in this period
-			bm.alerts.mu.Lock()
-			lastAlert, exists := bm.alerts.triggeredAlerts[alertKey]
-			bm.alerts.mu.Unlock()
-
-			if !exists || time.Since(lastAlert) > time.Hour {
-				// Trigger alert
-				alert := AlertInfo{
-					Period:        period,
-					Threshold:     threshold,
-					CurrentUsage:  usage,
-					BudgetLimit:   limit,
-					OverageAmount: usage - limit,
-					Timestamp:     timestamp,
-					Message:       bm.formatAlertMessage(period, threshold, usage, limit),
-				}
-
-				// Mark alert as triggered
-				bm.alerts.mu.Lock()
-				bm.alerts.triggeredAlerts[alertKey] = timestamp
-				bm.alerts.mu.Unlock()
-
-				// Call alert callback if configured
-				if bm.config.AlertCallback != nil {
-					bm.config.AlertCallback(alert)
-				}
-
-				// Log the alert
-				bm.logger.Printf("Budget Alert: %s", alert.Message)
-			}
+		if percentage < threshold {
+			continue
+		}
+		key := bm.alertKey(period, threshold, timestamp)
+		if _, fired := bm.usage.Alerts[key]; fired {
+			continue
+		}
+
+		alert := AlertInfo{
+			Period:        period,
+			Threshold:     threshold,
+			CurrentUsage:  usage,
+			BudgetLimit:   limit,
+			OverageAmount: usage - limit,
+			Timestamp:     timestamp,
+			Message:       bm.formatAlertMessage(period, threshold, usage, limit),
+		}
+		bm.usage.Alerts[key] = alert
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// alertKey identifies a threshold of a period, such as "75_daily_2026-01-05".
+func (bm *BudgetManager) alertKey(period BudgetPeriod, threshold float64, timestamp time.Time) string {
+	return fmt.Sprintf("%g_%s_%s", threshold, period.String(), bm.getPeriodKey(period, timestamp))
+}
+
+// pruneAlerts forgets alerts of periods other than those containing now.
+func (bm *BudgetManager) pruneAlerts(now time.Time) {
+	for key, alert := range bm.usage.Alerts {
+		if bm.alertKey(alert.Period, alert.Threshold, now) != key {
+			delete(bm.usage.Alerts, key)
+		}
+	}
+}
+
+// latestAlert returns the most recent alert of the periods containing now,
+// or nil if none fired.
+func (bm *BudgetManager) latestAlert(now time.Time) *AlertInfo {
+	var latest *AlertInfo
+	for key, alert := range bm.usage.Alerts {
+		if bm.alertKey(alert.Period, alert.Threshold, now) != key {
+			continue
 		}
+		if latest == nil || alert.Timestamp.After(latest.Timestamp) ||
+			(alert.Timestamp.Equal(latest.Timestamp) && alert.Threshold > latest.Threshold) {
+			alert := alert
+			latest = &alert
+		}
+	}
+	return latest
+}
+
+// recordSpendMetrics sets the spending gauges to the current periods'
+// totals. The caller holds bm.mu.
+func (bm *BudgetManager) recordSpendMetrics() {
+	if !metrics.Enabled() {
+		return
+	}
+	now := bm.config.Clock.Now()
+	for _, period := range []BudgetPeriod{PeriodDaily, PeriodWeekly, PeriodMonthly} {
+		metrics.BudgetSpend.Set(bm.getCurrentUsage(period, now), period.String())
 	}
 }
 
@@ -387,8 +622,10 @@ func (bm *BudgetManager) getCurrentUsage(period BudgetPeriod, timestamp time.Tim
 	}
 }
 
-// getPeriodKey gets the key for a specific period and timestamp.
+// getPeriodKey gets the key for a specific period and timestamp, in the
+// budget's time zone.
 func (bm *BudgetManager) getPeriodKey(period BudgetPeriod, timestamp time.Time) string {
+	timestamp = timestamp.In(bm.config.Location)
 	switch period {
 	case PeriodDaily:
 		return timestamp.Format("2006-01-02")
@@ -407,6 +644,24 @@ func (bm *BudgetManager) getWeekKey(timestamp time.Time) string {
 	return fmt.Sprintf("%d-W%02d", year, week)
 }
 
+// periodBounds returns when the period containing timestamp starts and
+// ends, in the budget's time zone.
+func (bm *BudgetManager) periodBounds(period BudgetPeriod, timestamp time.Time) (time.Time, time.Time) {
+	year, month, day := timestamp.In(bm.config.Location).Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, bm.config.Location)
+
+	switch period {
+	case PeriodWeekly:
+		start := midnight.AddDate(0, 0, -(int(midnight.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7)
+	case PeriodMonthly:
+		start := time.Date(year, month, 1, 0, 0, 0, 0, bm.config.Location)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return midnight, midnight.AddDate(0, 0, 1)
+	}
+}
+
 // formatAlertMessage creates a human-readable alert message.
 func (bm *BudgetManager) formatAlertMessage(period BudgetPeriod, threshold, usage, limit float64) string {
 	periodStr := period.String()
@@ -422,16 +677,27 @@ func (bm *BudgetManager) formatAlertMessage(period BudgetPeriod, threshold, usag
 
 // CanAfford checks if a potential expense is within budget limits.
 func (bm *BudgetManager) CanAfford(estimatedCost float64) (*AffordabilityCheck, error) {
+	return bm.CanAffordInPlan(estimatedCost, "")
+}
+
+// CanAffordInPlan checks if a potential expense of the running execution
+// plan planID is within budget limits, which under
+// mcp.BudgetEnforcementSoftWithGrace extend by the grace past them. A
+// planID of "" is an expense outside any plan, as for CanAfford.
+func (bm *BudgetManager) CanAffordInPlan(estimatedCost float64, planID string) (*AffordabilityCheck, error) {
 	bm.mu.RLock()
 	defer bm.mu.RUnlock()
 
-	now := time.Now()
+	now := bm.config.Clock.Now()
 	result := &AffordabilityCheck{
 		EstimatedCost: estimatedCost,
 		Timestamp:     now,
 		Affordable:    true,
 		Warnings:      make([]string, 0),
 	}
+	soft := bm.config.Enforcement == mcp.BudgetEnforcementSoftWithGrace
+	withinGrace := true
+	graceRemaining := math.Inf(1)
 
 	// Check each budget period
 	periods := []struct {
@@ -451,8 +717,15 @@ func (bm *BudgetManager) CanAfford(estimatedCost float64) (*AffordabilityCheck,
 		currentUsage := bm.getCurrentUsage(p.period, now)
 		projectedUsage := currentUsage + estimatedCost
 
+		if soft {
+			graceLimit := p.limit * (1 + bm.config.GracePercent/100)
+			withinGrace = withinGrace && projectedUsage <= graceLimit
+			graceRemaining = math.Min(graceRemaining, graceLimit-math.Max(projectedUsage, p.limit))
+		}
+
 		if projectedUsage > p.limit {
 			result.Affordable = false
+			result.Exceeded = append(result.Exceeded, p.period)
 			result.Warnings = append(result.Warnings,
 				fmt.Sprintf("%s budget would be exceeded ($%.2f + $%.2f > $%.2f)",
 					p.period.String(), currentUsage, estimatedCost, p.limit))
@@ -463,6 +736,22 @@ func (bm *BudgetManager) CanAfford(estimatedCost float64) (*AffordabilityCheck,
 		}
 	}
 
+	if soft && !math.IsInf(graceRemaining, 1) {
+		result.GraceRemaining = math.Max(graceRemaining, 0)
+	}
+	switch {
+	case result.Affordable:
+	case bm.config.Enforcement == mcp.BudgetEnforcementWarnOnly:
+		result.Affordable = true
+		result.Warnings = append(result.Warnings, "Request allowed over budget: enforcement only warns")
+	case soft && planID != "" && withinGrace:
+		result.Affordable = true
+		result.ViaGrace = true
+		result.Warnings = append(result.Warnings,
+			fmt.Sprintf("Request of running plan %s allowed within the grace past the limit ($%.2f of grace left)",
+				planID, result.GraceRemaining))
+	}
+
 	// Apply auto-stop logic
 	if !result.Affordable && bm.config.AutoStop {
 		// Check if within grace period
@@ -483,6 +772,19 @@ type AffordabilityCheck struct {
 	Affordable    bool
 	Warnings      []string
 	Timestamp     time.Time
+
+	// Exceeded lists the periods whose remaining budget the cost is over,
+	// shortest first. They stay listed when the grace period allows the
+	// cost anyway.
+	Exceeded []BudgetPeriod
+
+	// ViaGrace is set when the cost is allowed only through the grace
+	// mcp.BudgetEnforcementSoftWithGrace gives running plans
+	ViaGrace bool
+
+	// GraceRemaining is how much of that grace would be left after the
+	// cost, in the period with the least (always 0 under other enforcement)
+	GraceRemaining float64
 }
 
 // GetBudgetStatus returns current budget status across all periods.
@@ -490,10 +792,11 @@ func (bm *BudgetManager) GetBudgetStatus() *BudgetStatus {
 	bm.mu.RLock()
 	defer bm.mu.RUnlock()
 
-	now := time.Now()
+	now := bm.config.Clock.Now()
 	status := &BudgetStatus{
-		Timestamp: now,
-		Periods:   make(map[string]*PeriodStatus),
+		Timestamp:   now,
+		Periods:     make(map[string]*PeriodStatus),
+		LatestAlert: bm.latestAlert(now),
 	}
 
 	// Get status for each period
@@ -513,30 +816,106 @@ func (bm *BudgetManager) GetBudgetStatus() *BudgetStatus {
 
 		usage := bm.getCurrentUsage(p.period, now)
 		percentage := (usage / p.limit) * 100
+		start, end := bm.periodBounds(p.period, now)
 
 		status.Periods[name] = &PeriodStatus{
+			Period:     p.period,
 			Usage:      usage,
 			Limit:      p.limit,
 			Percentage: percentage,
 			Remaining:  p.limit - usage,
+			Start:      start,
+			End:        end,
 		}
 	}
 
 	return status
 }
 
+// GetSpending returns how much was spent in the period containing timestamp,
+// whether or not the period has a limit.
+func (bm *BudgetManager) GetSpending(period BudgetPeriod, timestamp time.Time) float64 {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	return bm.getCurrentUsage(period, timestamp)
+}
+
 // BudgetStatus contains comprehensive budget status information.
 type BudgetStatus struct {
 	Timestamp time.Time
 	Periods   map[string]*PeriodStatus // "daily", "weekly", "monthly"
+
+	// LatestAlert is the most recent alert fired in the current periods,
+	// or nil if none has
+	LatestAlert *AlertInfo
 }
 
 // PeriodStatus contains budget status for a specific time period.
 type PeriodStatus struct {
+	Period     BudgetPeriod
 	Usage      float64
 	Limit      float64
 	Percentage float64
 	Remaining  float64
+
+	// Start and End bound the current period; its budget resets at End
+	Start time.Time
+	End   time.Time
+}
+
+// GetTransactions returns a copy of the transaction log in recording order. The log
+// is only kept when TrackingEnabled is set.
+func (bm *BudgetManager) GetTransactions() []Transaction {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	transactions := make([]Transaction, len(bm.usage.Transactions))
+	copy(transactions, bm.usage.Transactions)
+	return transactions
+}
+
+// UnattributedGoal is the GetSpendByGoal key of spending not attributed to a goal.
+const UnattributedGoal = "unattributed"
+
+// GoalSpend totals the spending attributed to one goal.
+type GoalSpend struct {
+	Cost   float64
+	Tokens int
+	Calls  int
+}
+
+// GetSpendByGoal totals the current period's transactions by goal ID.
+// Transactions without a goal are totalled under UnattributedGoal. Only
+// transactions kept while TrackingEnabled was set are counted.
+func (bm *BudgetManager) GetSpendByGoal(ctx context.Context, period BudgetPeriod) (map[string]GoalSpend, error) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	key := bm.getPeriodKey(period, bm.config.Clock.Now())
+	if key == "" {
+		return nil, errs.Newf(errs.Validation, "unknown budget period %d", period)
+	}
+
+	spend := make(map[string]GoalSpend)
+	for _, tx := range bm.usage.Transactions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if bm.getPeriodKey(period, tx.Timestamp) != key {
+			continue
+		}
+		goalID := tx.GoalID
+		if goalID == "" {
+			goalID = UnattributedGoal
+		}
+		total := spend[goalID]
+		total.Cost += tx.Cost
+		total.Tokens += tx.TokensUsed
+		total.Calls++
+		spend[goalID] = total
+	}
+	return spend, nil
 }
 
 // GetSpendingAnalysis returns detailed spending analysis and insights.
@@ -545,7 +924,7 @@ func (bm *BudgetManager) GetSpendingAnalysis() *SpendingAnalysis {
 	defer bm.mu.RUnlock()
 
 	analysis := &SpendingAnalysis{
-		Timestamp:        time.Now(),
+		Timestamp:        bm.config.Clock.Now(),
 		ProviderBreakdown: make(map[string]float64),
 		ModelBreakdown:   make(map[string]float64),
 		TaskTypeBreakdown: make(map[string]float64),
@@ -677,29 +1056,14 @@ func (at AlertThreshold) String() string {
 
 // Persistence methods
 
-// SaveUsage saves usage data to disk.
-func (bp *BudgetPersistence) SaveUsage(usage *UsageTracker) error {
-	data, err := json.MarshalIndent(usage, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal usage data: %w", err)
-	}
-
-	filePath := filepath.Join(bp.dataPath, "budget_usage.json")
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write usage file: %w", err)
-	}
-
-	return nil
-}
-
-// LoadUsage loads usage data from disk.
+// LoadUsage loads the usage file kept before the journal.
 func (bp *BudgetPersistence) LoadUsage() (*UsageTracker, error) {
-	filePath := filepath.Join(bp.dataPath, "budget_usage.json")
+	filePath := filepath.Join(bp.dataPath, usageFile)
 
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("usage file does not exist")
+			return nil, fmt.Errorf("usage file does not exist: %w", err)
 		}
 		return nil, fmt.Errorf("failed to read usage file: %w", err)
 	}
@@ -709,28 +1073,7 @@ func (bp *BudgetPersistence) LoadUsage() (*UsageTracker, error) {
 		return nil, fmt.Errorf("failed to unmarshal usage data: %w", err)
 	}
 
-	// Initialize maps if they're nil (backwards compatibility)
-	if usage.Daily == nil {
-		usage.Daily = make(map[string]float64)
-	}
-	if usage.Weekly == nil {
-		usage.Weekly = make(map[string]float64)
-	}
-	if usage.Monthly == nil {
-		usage.Monthly = make(map[string]float64)
-	}
-	if usage.ProviderSpending == nil {
-		usage.ProviderSpending = make(map[string]float64)
-	}
-	if usage.ModelSpending == nil {
-		usage.ModelSpending = make(map[string]float64)
-	}
-	if usage.TaskTypeSpending == nil {
-		usage.TaskTypeSpending = make(map[string]float64)
-	}
-	if usage.ProviderROI == nil {
-		usage.ProviderROI = make(map[string]*ProviderROI)
-	}
+	usage.initMaps()
 
 	return &usage, nil
 }
\ No newline at end of file
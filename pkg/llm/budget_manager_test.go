@@ -2,9 +2,16 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+	_ "time/tzdata" // DST tests need America/New_York on any host
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 // testLogger creates a logger that discards output during tests.
@@ -426,12 +433,133 @@ func TestBudgetAlerts(t *testing.T) {
 	}
 }
 
+func TestBudgetAlerts_OncePerPeriod(t *testing.T) {
+	tempDir := t.TempDir()
+	clock := utils.NewFakeClock(time.Date(2026, 6, 10, 9, 0, 0, 0, time.UTC))
+	config := BudgetConfig{DailyLimit: 1.0, Clock: clock}
+	bm, err := NewBudgetManager(tempDir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	var alerts []AlertInfo
+	bm.OnAlert(func(alert AlertInfo) {
+		// Callbacks run outside the lock, so they may query the manager
+		if status := bm.GetBudgetStatus(); status.LatestAlert == nil {
+			t.Error("Expected the fired alert to be recorded before callbacks run")
+		}
+		alerts = append(alerts, alert)
+	})
+
+	ctx := context.Background()
+	spend := func(cost float64) {
+		clock.Advance(time.Minute)
+		if err := bm.RecordUsage(ctx, Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: cost}); err != nil {
+			t.Fatalf("Failed to record usage: %v", err)
+		}
+	}
+
+	// The default thresholds apply, and one transaction can cross several
+	spend(0.95)
+	if len(alerts) != 2 || alerts[0].Threshold != 75 || alerts[1].Threshold != 90 || alerts[1].BudgetLimit != 1.0 {
+		t.Fatalf("Expected the 75%% and 90%% alerts, got %+v", alerts)
+	}
+
+	// A refund below a threshold and spending back over it do not repeat it
+	spend(-0.30)
+	spend(0.30)
+	if len(alerts) != 2 {
+		t.Errorf("Expected no repeated alerts after a refund, got %+v", alerts[2:])
+	}
+	spend(0.10)
+	if len(alerts) != 3 || alerts[2].Threshold != 100 || alerts[2].Period != PeriodDaily {
+		t.Fatalf("Expected the 100%% alert, got %+v", alerts)
+	}
+
+	// Fired thresholds survive a restart
+	reopened, err := NewBudgetManager(tempDir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to reopen budget manager: %v", err)
+	}
+	reopened.OnAlert(func(alert AlertInfo) { alerts = append(alerts, alert) })
+	if latest := reopened.GetBudgetStatus().LatestAlert; latest == nil || latest.Threshold != 100 {
+		t.Errorf("Expected the 100%% alert to be the latest after a restart, got %+v", latest)
+	}
+	clock.Advance(time.Minute)
+	if err := reopened.RecordUsage(ctx, Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 0.01}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+	if len(alerts) != 3 {
+		t.Errorf("Expected no repeated alerts after a restart, got %+v", alerts[3:])
+	}
+
+	// A new day starts over
+	clock.Advance(24 * time.Hour)
+	if latest := reopened.GetBudgetStatus().LatestAlert; latest != nil {
+		t.Errorf("Expected no alerts on a new day, got %+v", latest)
+	}
+	if err := reopened.RecordUsage(ctx, Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 0.80}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+	if len(alerts) != 4 || alerts[3].Threshold != 75 {
+		t.Errorf("Expected the 75%% alert again the next day, got %+v", alerts)
+	}
+}
+
+func TestGetSpendByGoal(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 6, 20, 12, 0, 0, 0, time.UTC))
+	bm, err := NewBudgetManager(t.TempDir(), BudgetConfig{TrackingEnabled: true, Clock: clock}, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	ctx := context.Background()
+	transactions := []Transaction{
+		{Timestamp: time.Date(2026, 5, 31, 9, 0, 0, 0, time.UTC), GoalID: "goal-1", Cost: 5.00, TokensUsed: 5000},
+		{Timestamp: time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC), GoalID: "goal-1", ObjectiveID: "objective-1", Cost: 0.10, TokensUsed: 100},
+		{Timestamp: time.Date(2026, 6, 20, 9, 0, 0, 0, time.UTC), GoalID: "goal-1", ObjectiveID: "objective-2", Cost: 0.20, TokensUsed: 200},
+		{Timestamp: time.Date(2026, 6, 20, 10, 0, 0, 0, time.UTC), GoalID: "goal-2", Cost: 0.05, TokensUsed: 50},
+		{Timestamp: time.Date(2026, 6, 20, 11, 0, 0, 0, time.UTC), Cost: 0.01, TokensUsed: 10},
+	}
+	for _, tx := range transactions {
+		if err := bm.RecordUsage(ctx, tx); err != nil {
+			t.Fatalf("Failed to record usage: %v", err)
+		}
+	}
+
+	// Last month's spending is left out, and unattributed spending is kept
+	spend, err := bm.GetSpendByGoal(ctx, PeriodMonthly)
+	if err != nil {
+		t.Fatalf("GetSpendByGoal failed: %v", err)
+	}
+	if len(spend) != 3 {
+		t.Errorf("Expected 3 buckets, got %+v", spend)
+	}
+	if got := spend["goal-1"]; got.Calls != 2 || got.Tokens != 300 || got.Cost < 0.299 || got.Cost > 0.301 {
+		t.Errorf("Expected goal-1 to have 2 calls, 300 tokens and $0.30 this month, got %+v", got)
+	}
+	if got := spend[UnattributedGoal]; got.Calls != 1 || got.Cost != 0.01 {
+		t.Errorf("Expected the unattributed call to be bucketed, got %+v", got)
+	}
+
+	daily, err := bm.GetSpendByGoal(ctx, PeriodDaily)
+	if err != nil || daily["goal-1"].Calls != 1 || daily["goal-2"].Calls != 1 {
+		t.Errorf("Expected one call per goal today, got %+v, %v", daily, err)
+	}
+
+	if _, err := bm.GetSpendByGoal(ctx, BudgetPeriod(99)); errs.CodeOf(err) != errs.Validation {
+		t.Errorf("Expected an unknown period to be rejected, got %v", err)
+	}
+}
+
 func TestGetBudgetStatus(t *testing.T) {
 	tempDir := t.TempDir()
+	clock := utils.NewFakeClock(time.Date(2026, 6, 10, 23, 59, 0, 0, time.UTC))
 	config := BudgetConfig{
 		DailyLimit:   1.0,
 		WeeklyLimit:  5.0,
 		MonthlyLimit: 20.0,
+		Clock:        clock,
 	}
 	bm, err := NewBudgetManager(tempDir, config, testLogger())
 	if err != nil {
@@ -439,7 +567,7 @@ func TestGetBudgetStatus(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	now := time.Now()
+	now := clock.Now()
 
 	// Record some usage
 	tx := Transaction{
@@ -676,6 +804,297 @@ func TestWeekKeyGeneration(t *testing.T) {
 	}
 }
 
+func TestBudgetPeriodRollover(t *testing.T) {
+	// Saturday evening, an hour before both the day and the month roll over
+	clock := utils.NewFakeClock(time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC))
+	bm, err := NewBudgetManager(t.TempDir(), BudgetConfig{
+		DailyLimit:   1.0,
+		WeeklyLimit:  5.0,
+		MonthlyLimit: 20.0,
+		Clock:        clock,
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	// No timestamp, so the usage is stamped with the budget clock
+	if err := bm.RecordUsage(context.Background(), Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 0.40, Success: true}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+
+	steps := []struct {
+		name                   string
+		advance                time.Duration
+		daily, weekly, monthly float64
+	}{
+		{"same day", 0, 0.40, 0.40, 0.40},
+		{"next day and month", time.Hour, 0, 0.40, 0},
+		{"next week", 24 * time.Hour, 0, 0, 0},
+	}
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		status := bm.GetBudgetStatus()
+		if got := status.Periods["daily"].Usage; got != step.daily {
+			t.Errorf("%s: expected daily usage %.2f, got %.2f", step.name, step.daily, got)
+		}
+		if got := status.Periods["weekly"].Usage; got != step.weekly {
+			t.Errorf("%s: expected weekly usage %.2f, got %.2f", step.name, step.weekly, got)
+		}
+		if got := status.Periods["monthly"].Usage; got != step.monthly {
+			t.Errorf("%s: expected monthly usage %.2f, got %.2f", step.name, step.monthly, got)
+		}
+	}
+}
+
+func TestWeeklyBudgetAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v", err)
+	}
+
+	// Both weeks end on the Sunday the clocks change, so the week is an
+	// hour shorter or longer than seven days but must still end at local
+	// midnight going into Monday
+	tests := []struct {
+		name     string
+		start    time.Time // Monday morning
+		weekKey  string
+		usage    []time.Time
+		nextWeek time.Time
+	}{
+		{
+			name:    "spring forward",
+			start:   time.Date(2026, 3, 2, 9, 0, 0, 0, loc),
+			weekKey: "2026-W10",
+			usage: []time.Time{
+				time.Date(2026, 3, 8, 1, 30, 0, 0, loc), // EST, just before the change
+				time.Date(2026, 3, 8, 3, 30, 0, 0, loc), // EDT, just after
+				time.Date(2026, 3, 8, 23, 59, 59, 0, loc),
+			},
+			nextWeek: time.Date(2026, 3, 9, 0, 0, 0, 0, loc),
+		},
+		{
+			name:    "fall back",
+			start:   time.Date(2026, 10, 26, 9, 0, 0, 0, loc),
+			weekKey: "2026-W44",
+			usage: []time.Time{
+				time.Date(2026, 11, 1, 1, 30, 0, 0, loc), // EDT, the first 1:30
+				time.Date(2026, 11, 1, 1, 30, 0, 0, loc).Add(time.Hour), // EST, the second 1:30
+				time.Date(2026, 11, 1, 23, 59, 59, 0, loc),
+			},
+			nextWeek: time.Date(2026, 11, 2, 0, 0, 0, 0, loc),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := utils.NewFakeClock(tt.start)
+			bm, err := NewBudgetManager(t.TempDir(), BudgetConfig{WeeklyLimit: 5.0, Clock: clock}, testLogger())
+			if err != nil {
+				t.Fatalf("Failed to create budget manager: %v", err)
+			}
+
+			for _, at := range tt.usage {
+				clock.Set(at)
+				if key := bm.getWeekKey(clock.Now()); key != tt.weekKey {
+					t.Errorf("Expected %s to be in week %s, got %s", at, tt.weekKey, key)
+				}
+				if err := bm.RecordUsage(context.Background(), Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 1.0, Success: true}); err != nil {
+					t.Fatalf("Failed to record usage: %v", err)
+				}
+			}
+
+			if got := bm.GetBudgetStatus().Periods["weekly"].Usage; got != 3.0 {
+				t.Errorf("Expected weekly usage 3.00 before Monday, got %.2f", got)
+			}
+
+			clock.Set(tt.nextWeek)
+			if got := bm.GetBudgetStatus().Periods["weekly"].Usage; got != 0 {
+				t.Errorf("Expected weekly usage to reset at local midnight, got %.2f", got)
+			}
+			if got := bm.GetSpending(PeriodWeekly, tt.start); got != 3.0 {
+				t.Errorf("Expected the previous week to keep its spending, got %.2f", got)
+			}
+		})
+	}
+}
+
+func TestBudgetLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v", err)
+	}
+
+	// Saturday 23:00 in Tokyo, recorded by a clock that reads UTC
+	clock := utils.NewFakeClock(time.Date(2026, 1, 31, 14, 0, 0, 0, time.UTC))
+	bm, err := NewBudgetManager(t.TempDir(), BudgetConfig{
+		DailyLimit:   1.0,
+		WeeklyLimit:  5.0,
+		MonthlyLimit: 20.0,
+		Clock:        clock,
+		Location:     tokyo,
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	if err := bm.RecordUsage(context.Background(), Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 0.40, Success: true}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+
+	// Two hours later it is still January in UTC, but Sunday 1 February in
+	// Tokyo: the day and month have started again, the week has not
+	clock.Advance(2 * time.Hour)
+	status := bm.GetBudgetStatus()
+	daily, weekly, monthly := status.Periods["daily"], status.Periods["weekly"], status.Periods["monthly"]
+	if daily.Usage != 0 || weekly.Usage != 0.40 || monthly.Usage != 0 {
+		t.Errorf("Expected only the week to keep the spending, got daily %.2f, weekly %.2f, monthly %.2f",
+			daily.Usage, weekly.Usage, monthly.Usage)
+	}
+
+	bounds := []struct {
+		status     *PeriodStatus
+		start, end time.Time
+	}{
+		{daily, time.Date(2026, 2, 1, 0, 0, 0, 0, tokyo), time.Date(2026, 2, 2, 0, 0, 0, 0, tokyo)},
+		{weekly, time.Date(2026, 1, 26, 0, 0, 0, 0, tokyo), time.Date(2026, 2, 2, 0, 0, 0, 0, tokyo)},
+		{monthly, time.Date(2026, 2, 1, 0, 0, 0, 0, tokyo), time.Date(2026, 3, 1, 0, 0, 0, 0, tokyo)},
+	}
+	for _, b := range bounds {
+		if !b.status.Start.Equal(b.start) || !b.status.End.Equal(b.end) {
+			t.Errorf("Expected the %s period to run from %s to %s, got %s to %s",
+				b.status.Period, b.start, b.end, b.status.Start, b.status.End)
+		}
+	}
+}
+
+func TestCanAfford_ReportsExceededPeriods(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 6, 10, 12, 0, 0, 0, time.UTC))
+	bm, err := NewBudgetManager(t.TempDir(), BudgetConfig{
+		DailyLimit:   5.0,
+		WeeklyLimit:  3.0,
+		MonthlyLimit: 3.05,
+		AutoStop:     true,
+		Clock:        clock,
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	// Spent on Monday, so the week and month have it but the day does not
+	clock.Set(time.Date(2026, 6, 8, 12, 0, 0, 0, time.UTC))
+	if err := bm.RecordUsage(context.Background(), Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 2.5, Success: true}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+	clock.Set(time.Date(2026, 6, 10, 12, 0, 0, 0, time.UTC))
+
+	check, err := bm.CanAfford(0.6)
+	if err != nil {
+		t.Fatalf("CanAfford failed: %v", err)
+	}
+	if check.Affordable {
+		t.Error("Expected the cost not to be affordable")
+	}
+	if len(check.Exceeded) != 2 || check.Exceeded[0] != PeriodWeekly || check.Exceeded[1] != PeriodMonthly {
+		t.Errorf("Expected the weekly and monthly budgets to be exceeded, got %v", check.Exceeded)
+	}
+
+	check, err = bm.CanAfford(0.5)
+	if err != nil {
+		t.Fatalf("CanAfford failed: %v", err)
+	}
+	if !check.Affordable || len(check.Exceeded) != 0 {
+		t.Errorf("Expected a cost within every budget to be affordable, got exceeded %v", check.Exceeded)
+	}
+}
+
+func TestBudgetManagerApplyConfig(t *testing.T) {
+	bm, err := NewBudgetManager(t.TempDir(), BudgetConfig{
+		DailyLimit:   1.0,
+		WeeklyLimit:  5.0,
+		MonthlyLimit: 20.0,
+		AutoStop:     true,
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	if err := bm.RecordUsage(context.Background(), Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 0.80, Success: true}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+	if check, err := bm.CanAfford(0.50); err != nil || check.Affordable {
+		t.Fatalf("Expected the cost over the daily limit, got %+v, %v", check, err)
+	}
+
+	// Raising the limit applies to the spending already recorded
+	if err := bm.ApplyConfig(BudgetConfig{DailyLimit: 2.0, WeeklyLimit: 5.0, MonthlyLimit: 20.0}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if check, err := bm.CanAfford(0.50); err != nil || !check.Affordable {
+		t.Errorf("Expected the cost affordable under the raised limit, got %+v, %v", check, err)
+	}
+
+	for _, config := range []BudgetConfig{
+		{DailyLimit: -1},
+		{DailyLimit: 2.0, GracePercent: -5},
+		{DailyLimit: 2.0, Enforcement: "lenient"},
+	} {
+		if err := bm.ApplyConfig(config); !errors.Is(err, errs.Validation) {
+			t.Errorf("Expected %+v to be rejected, got %v", config, err)
+		}
+	}
+	if limit := bm.GetBudgetStatus().Periods["daily"].Limit; limit != 2.0 {
+		t.Errorf("Expected rejected configs to keep the daily limit, got %.2f", limit)
+	}
+}
+
+func TestBudgetMigration_DailyOnlyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// A usage file from before weekly and monthly totals were kept
+	legacy := `{"Daily": {"2026-01-30": 1.0, "2026-01-31": 2.0, "2026-02-02": 0.5}, "ProviderSpending": {"anthropic": 3.5}}`
+	if err := os.WriteFile(filepath.Join(dir, "budget_usage.json"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write usage file: %v", err)
+	}
+
+	clock := utils.NewFakeClock(time.Date(2026, 2, 2, 12, 0, 0, 0, time.UTC))
+	config := BudgetConfig{DailyLimit: 5.0, WeeklyLimit: 10.0, MonthlyLimit: 50.0, Clock: clock}
+	bm, err := NewBudgetManager(dir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	spending := []struct {
+		period BudgetPeriod
+		at     time.Time
+		want   float64
+	}{
+		{PeriodDaily, time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC), 2.0},
+		{PeriodWeekly, time.Date(2026, 1, 30, 9, 0, 0, 0, time.UTC), 3.0},
+		{PeriodWeekly, clock.Now(), 0.5},
+		{PeriodMonthly, time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC), 3.0},
+		{PeriodMonthly, clock.Now(), 0.5},
+	}
+	for _, s := range spending {
+		if got := bm.GetSpending(s.period, s.at); got != s.want {
+			t.Errorf("Expected %s spending of %.2f on %s, got %.2f", s.period, s.want, s.at.Format("2006-01-02"), got)
+		}
+	}
+
+	// The migrated totals are saved, and new spending adds to them
+	if err := bm.RecordUsage(context.Background(), Transaction{Provider: "anthropic", Model: "claude-3-haiku", Cost: 1.0, Success: true}); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+	reopened, err := NewBudgetManager(dir, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to reopen budget manager: %v", err)
+	}
+	if got := reopened.GetBudgetStatus().Periods["weekly"].Usage; got != 1.5 {
+		t.Errorf("Expected weekly usage 1.50 after reopening, got %.2f", got)
+	}
+	if got := reopened.GetSpending(PeriodMonthly, time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)); got != 3.0 {
+		t.Errorf("Expected January to keep its migrated spending, got %.2f", got)
+	}
+}
+
 func TestBudgetPeriodEnum(t *testing.T) {
 	periods := []BudgetPeriod{PeriodDaily, PeriodWeekly, PeriodMonthly}
 	for _, period := range periods {
@@ -0,0 +1,270 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// reportDateLayout is the date format of report days.
+const reportDateLayout = "2006-01-02"
+
+// reportGapNote marks the row of a day without transactions.
+const reportGapNote = "no transactions recorded"
+
+// ReportFormat is the output format of a usage report.
+type ReportFormat string
+
+const (
+	// ReportFormatCSV renders the report as CSV with a header row
+	ReportFormatCSV ReportFormat = "csv"
+	// ReportFormatJSON renders the report as indented JSON
+	ReportFormatJSON ReportFormat = "json"
+)
+
+// ParseReportFormat parses a report format name.
+func ParseReportFormat(name string) (ReportFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "csv":
+		return ReportFormatCSV, nil
+	case "json":
+		return ReportFormatJSON, nil
+	default:
+		return "", errs.Newf(errs.Validation, "unknown report format %q, must be csv or json", name)
+	}
+}
+
+// ReportOptions selects the transactions ExportReport covers and how the
+// report renders.
+type ReportOptions struct {
+	// From is the first day covered, and To the day after the last; days
+	// start at midnight in From's location. A zero From starts on the day of
+	// the first recorded transaction, in the clock's location, and a zero
+	// To ends with today.
+	From time.Time
+	To   time.Time
+
+	// Format is the rendering of the report's Content (default: CSV)
+	Format ReportFormat
+}
+
+// UsageReport totals recorded transactions by day, provider and model.
+type UsageReport struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// Rows are in date, provider and model order. A day without
+	// transactions has a single row with no provider and a Note.
+	Rows []UsageReportRow `json:"rows"`
+
+	// GapDays are the days without transactions
+	GapDays []string `json:"gap_days"`
+
+	// Totals sums every row; its Date, Provider and Model are empty
+	Totals UsageReportRow `json:"totals"`
+
+	// Format and Content are the rendered report
+	Format  ReportFormat `json:"-"`
+	Content string       `json:"-"`
+}
+
+// UsageReportRow is the usage of one model on one day.
+type UsageReportRow struct {
+	Date     string `json:"date,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	Calls       int     `json:"calls"`
+	Tokens      int     `json:"tokens"`
+	Cost        float64 `json:"cost"`
+	SuccessRate float64 `json:"success_rate"` // Share of calls that succeeded, 0 to 1
+
+	// AverageQuality is the mean of the calls' 1-10 quality ratings, or 0
+	// when none were rated
+	AverageQuality float64 `json:"average_quality,omitempty"`
+
+	Note string `json:"note,omitempty"`
+
+	successes int
+	rated     int
+	quality   float64
+}
+
+// add counts a transaction in the row.
+func (row *UsageReportRow) add(tx Transaction) {
+	row.Calls++
+	row.Tokens += tx.TokensUsed
+	row.Cost += tx.Cost
+	if tx.Success {
+		row.successes++
+	}
+	if tx.Quality >= 1.0 && tx.Quality <= 10.0 {
+		row.rated++
+		row.quality += tx.Quality
+	}
+}
+
+// finish works out the row's rates from its counts.
+func (row *UsageReportRow) finish() {
+	if row.Calls > 0 {
+		row.SuccessRate = float64(row.successes) / float64(row.Calls)
+	}
+	if row.rated > 0 {
+		row.AverageQuality = row.quality / float64(row.rated)
+	}
+}
+
+// WriteTo writes the rendered report to w.
+func (r *UsageReport) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, r.Content)
+	return int64(n), err
+}
+
+// LastDay returns the last day the report covers; To itself is excluded.
+func (r *UsageReport) LastDay() time.Time {
+	return r.To.Add(-time.Nanosecond)
+}
+
+// ExportReport totals the recorded transactions of a date range by day,
+// provider and model, for reconciling spending against provider invoices.
+// Transactions that cost nothing, such as those of local models, are
+// counted like any other. Days without transactions get a row saying so
+// rather than being left out. Only transactions kept while TrackingEnabled
+// was set can be reported.
+func (bm *BudgetManager) ExportReport(ctx context.Context, opts ReportOptions) (*UsageReport, error) {
+	if opts.Format == "" {
+		opts.Format = ReportFormatCSV
+	}
+	if _, err := ParseReportFormat(string(opts.Format)); err != nil {
+		return nil, err
+	}
+
+	bm.mu.RLock()
+	transactions := make([]Transaction, len(bm.usage.Transactions))
+	copy(transactions, bm.usage.Transactions)
+	bm.mu.RUnlock()
+
+	now := bm.config.Clock.Now()
+	location := opts.From.Location()
+	if opts.From.IsZero() {
+		location = now.Location()
+		opts.From = now
+		for _, tx := range transactions {
+			if tx.Timestamp.Before(opts.From) {
+				opts.From = tx.Timestamp
+			}
+		}
+	}
+	from := startOfDay(opts.From.In(location))
+	to := startOfDay(now.In(location)).AddDate(0, 0, 1)
+	if !opts.To.IsZero() {
+		to = startOfDay(opts.To.In(location))
+	}
+	if !to.After(from) {
+		return nil, errs.Newf(errs.Validation, "report range ends on %s, before it starts on %s",
+			to.Format(reportDateLayout), from.Format(reportDateLayout))
+	}
+
+	report := &UsageReport{From: from, To: to, GeneratedAt: now, Format: opts.Format}
+
+	// Group by day, then provider and model
+	rows := make(map[string]*UsageReportRow)
+	for _, tx := range transactions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if tx.Timestamp.Before(from) || !tx.Timestamp.Before(to) {
+			continue
+		}
+		date := tx.Timestamp.In(location).Format(reportDateLayout)
+		key := date + "\x00" + tx.Provider + "\x00" + tx.Model
+		row := rows[key]
+		if row == nil {
+			row = &UsageReportRow{Date: date, Provider: tx.Provider, Model: tx.Model}
+			rows[key] = row
+		}
+		row.add(tx)
+		report.Totals.add(tx)
+	}
+
+	days := make(map[string]bool)
+	for _, row := range rows {
+		row.finish()
+		report.Rows = append(report.Rows, *row)
+		days[row.Date] = true
+	}
+	report.Totals.finish()
+
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(reportDateLayout)
+		if !days[date] {
+			report.GapDays = append(report.GapDays, date)
+			report.Rows = append(report.Rows, UsageReportRow{Date: date, Note: reportGapNote})
+		}
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.Model < b.Model
+	})
+
+	content, err := report.render()
+	if err != nil {
+		return nil, err
+	}
+	report.Content = content
+	return report, nil
+}
+
+// render renders the report in its format.
+func (r *UsageReport) render() (string, error) {
+	if r.Format == ReportFormatJSON {
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize report: %w", err)
+		}
+		return string(data) + "\n", nil
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"date", "provider", "model", "calls", "tokens", "cost", "success_rate", "average_quality", "note"})
+	for _, row := range r.Rows {
+		record := []string{row.Date, row.Provider, row.Model, "", "", "", "", "", row.Note}
+		if row.Note != reportGapNote {
+			record[3] = strconv.Itoa(row.Calls)
+			record[4] = strconv.Itoa(row.Tokens)
+			record[5] = strconv.FormatFloat(row.Cost, 'f', 6, 64)
+			record[6] = strconv.FormatFloat(row.SuccessRate, 'f', 4, 64)
+			if row.rated > 0 {
+				record[7] = strconv.FormatFloat(row.AverageQuality, 'f', 2, 64)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// startOfDay returns midnight of t's day in t's location.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
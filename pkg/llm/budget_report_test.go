@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// setupReportBudget records usage on June 1 and 3, 2026 and none on June 2.
+func setupReportBudget(t *testing.T) *BudgetManager {
+	t.Helper()
+	clock := utils.NewFakeClock(time.Date(2026, 6, 4, 12, 0, 0, 0, time.UTC))
+	bm, err := NewBudgetManager(t.TempDir(), BudgetConfig{TrackingEnabled: true, Clock: clock}, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	at := func(day, hour int) time.Time { return time.Date(2026, 6, day, hour, 0, 0, 0, time.UTC) }
+	transactions := []Transaction{
+		{Timestamp: at(1, 9), Provider: "anthropic", Model: "claude-3-haiku", TokensUsed: 100, Cost: 0.01, Success: true, Quality: 8},
+		{Timestamp: at(1, 10), Provider: "anthropic", Model: "claude-3-haiku", TokensUsed: 300, Cost: 0.03, Success: false},
+		{Timestamp: at(1, 11), Provider: "local", Model: "llama", TokensUsed: 50, Cost: 0, Success: true},
+		{Timestamp: at(3, 9), Provider: "anthropic", Model: "claude-3-haiku", TokensUsed: 200, Cost: 0.02, Success: true, Quality: 6},
+		{Timestamp: at(3, 10), Provider: "anthropic", Model: "claude-3-haiku", TokensUsed: 200, Cost: 0.02, Success: true, Quality: 9},
+		{Timestamp: at(5, 10), Provider: "openai", Model: "gpt-4", TokensUsed: 1000, Cost: 0.50, Success: true},
+	}
+	for _, tx := range transactions {
+		if err := bm.RecordUsage(context.Background(), tx); err != nil {
+			t.Fatalf("Failed to record usage: %v", err)
+		}
+	}
+	return bm
+}
+
+func TestExportReport_CSV(t *testing.T) {
+	bm := setupReportBudget(t)
+	report, err := bm.ExportReport(context.Background(), ReportOptions{
+		From: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2026, 6, 4, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("ExportReport failed: %v", err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(report.Content)).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	want := [][]string{
+		{"date", "provider", "model", "calls", "tokens", "cost", "success_rate", "average_quality", "note"},
+		{"2026-06-01", "anthropic", "claude-3-haiku", "2", "400", "0.040000", "0.5000", "8.00", ""},
+		{"2026-06-01", "local", "llama", "1", "50", "0.000000", "1.0000", "", ""},
+		{"2026-06-02", "", "", "", "", "", "", "", "no transactions recorded"},
+		{"2026-06-03", "anthropic", "claude-3-haiku", "2", "400", "0.040000", "1.0000", "7.50", ""},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d records, got %d:\n%s", len(want), len(records), report.Content)
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("Record %d: expected %v, got %v", i, want[i], records[i])
+		}
+	}
+
+	if len(report.GapDays) != 1 || report.GapDays[0] != "2026-06-02" {
+		t.Errorf("Expected June 2 as the only gap day, got %v", report.GapDays)
+	}
+	if report.Totals.Calls != 5 || report.Totals.Tokens != 850 || report.Totals.SuccessRate != 0.8 {
+		t.Errorf("Expected totals of 5 calls, 850 tokens and 80%% success, got %+v", report.Totals)
+	}
+	if report.LastDay().Format("2006-01-02") != "2026-06-03" {
+		t.Errorf("Expected the report to end on June 3, got %s", report.LastDay())
+	}
+}
+
+func TestExportReport_JSONAndRanges(t *testing.T) {
+	bm := setupReportBudget(t)
+	ctx := context.Background()
+
+	// With no range, the report runs from the first transaction through today
+	report, err := bm.ExportReport(ctx, ReportOptions{Format: ReportFormatJSON})
+	if err != nil {
+		t.Fatalf("ExportReport failed: %v", err)
+	}
+	var decoded UsageReport
+	if err := json.Unmarshal([]byte(report.Content), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if got := strings.Join(decoded.GapDays, ","); got != "2026-06-02,2026-06-04" {
+		t.Errorf("Expected June 2 and 4 as gap days, got %s", got)
+	}
+	if decoded.Totals.Calls != 5 || len(decoded.Rows) != 5 {
+		t.Errorf("Expected 5 calls in 5 rows up to June 4, got %+v", decoded)
+	}
+
+	// The last day is included up to its midnight
+	report, err = bm.ExportReport(ctx, ReportOptions{
+		From: time.Date(2026, 6, 5, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2026, 6, 6, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil || report.Totals.Calls != 1 || report.Totals.Cost != 0.50 || len(report.GapDays) != 0 {
+		t.Errorf("Expected the June 5 call only, got %+v, %v", report, err)
+	}
+
+	if _, err := bm.ExportReport(ctx, ReportOptions{From: time.Date(2026, 6, 5, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 6, 5, 0, 0, 0, 0, time.UTC)}); errs.CodeOf(err) != errs.Validation {
+		t.Errorf("Expected an empty range to be rejected, got %v", err)
+	}
+	if _, err := bm.ExportReport(ctx, ReportOptions{Format: "xlsx"}); errs.CodeOf(err) != errs.Validation {
+		t.Errorf("Expected an unknown format to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// anthropicOnlyService offers Claude 3 Haiku and Sonnet and answers every
+// completion request at a fixed cost.
+type anthropicOnlyService struct {
+	calls []string
+}
+
+func (s *anthropicOnlyService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	switch params["operation"] {
+	case "list_models":
+		return mcp.SuccessResult([]mcp.ModelListing{
+			{Provider: "anthropic", Model: "claude-3-haiku", InputCost: 0.25, OutputCost: 1.25, MaxTokens: 4096, ContextSize: 200000, SupportsChat: true, QualityTier: "standard", SpeedTier: 1},
+			{Provider: "anthropic", Model: "claude-3-sonnet", InputCost: 3.0, OutputCost: 15.0, MaxTokens: 4096, ContextSize: 200000, SupportsChat: true, QualityTier: "premium", SpeedTier: 2},
+		})
+	case "complete":
+		model, _ := params["model"].(string)
+		s.calls = append(s.calls, model)
+		return mcp.SuccessResult(&mcp.CompletionResponse{Text: "Done.", TokensUsed: 1200, Model: model, Provider: "anthropic", Cost: 0.002})
+	default:
+		return mcp.ErrorResult(errors.New("unsupported operation"))
+	}
+}
+
+// budgetWithRemaining returns a budget manager with only remaining dollars of
+// its daily limit left.
+func budgetWithRemaining(t *testing.T, clock utils.Clock, remaining float64) *BudgetManager {
+	t.Helper()
+	budget, err := NewBudgetManager(t.TempDir(), BudgetConfig{DailyLimit: 1.0, AutoStop: true, TrackingEnabled: true, Clock: clock}, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	if err := budget.RecordUsage(context.Background(), Transaction{Provider: "openai", Model: "gpt-4", Cost: 1.0 - remaining, Success: true}); err != nil {
+		t.Fatalf("Failed to record earlier spending: %v", err)
+	}
+	return budget
+}
+
+func budgetRequest() TaskRequest {
+	return TaskRequest{Prompt: "Draft a detailed project proposal", TaskType: "critical", QualityRequired: QualityPremium, MaxTokens: 2000}
+}
+
+func TestRoute_DowngradesToModelTheBudgetAffords(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+	service := &anthropicOnlyService{}
+	router := NewRouter(service)
+
+	// With the budget untouched the premium model is preferred
+	ranked := router.scoreModels(router.getAvailableModels(), router.assessTask(budgetRequest()), budgetRequest())
+	if ranked[0].Model != "claude-3-sonnet" {
+		t.Fatalf("Expected sonnet to be preferred, got %s", ranked[0].Model)
+	}
+
+	// A few cents left covers haiku but not sonnet
+	budget := budgetWithRemaining(t, clock, 0.02)
+	router.SetBudgetManager(budget)
+	result, err := router.Route(context.Background(), budgetRequest())
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	if result.SelectedModel.Model != "claude-3-haiku" {
+		t.Errorf("Expected haiku to fit the remaining budget, got %s", result.SelectedModel.Model)
+	}
+	if len(service.calls) != 1 || service.calls[0] != "claude-3-haiku" {
+		t.Errorf("Expected sonnet never to be executed, got calls %v", service.calls)
+	}
+	if len(result.ExcludedModels) != 1 || result.ExcludedModels[0].Model.Model != "claude-3-sonnet" || result.ExcludedModels[0].Reason != ExclusionOverBudget {
+		t.Errorf("Expected sonnet excluded as over budget, got %+v", result.ExcludedModels)
+	}
+
+	// The completion's actual cost is recorded
+	transactions := budget.GetTransactions()
+	last := transactions[len(transactions)-1]
+	if last.Provider != "anthropic" || last.Model != "claude-3-haiku" || last.Cost != 0.002 || last.TokensUsed != 1200 || !last.Success {
+		t.Errorf("Expected the haiku completion to be recorded, got %+v", last)
+	}
+	if spent := budget.GetSpending(PeriodDaily, clock.Now()); spent < 0.981 || spent > 0.983 {
+		t.Errorf("Expected $0.982 spent today, got $%.4f", spent)
+	}
+}
+
+func TestRoute_ReturnsBudgetExceededWhenNothingFits(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+	service := &anthropicOnlyService{}
+	router := NewRouter(service)
+	router.SetBudgetManager(budgetWithRemaining(t, clock, 0.001))
+
+	_, err := router.Route(context.Background(), budgetRequest())
+	if !errors.Is(err, ErrBudgetExceeded) || errs.CodeOf(err) != errs.BudgetExceeded {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	var exceeded *BudgetExceededError
+	if !errors.As(err, &exceeded) {
+		t.Fatalf("Expected a BudgetExceededError, got %T", err)
+	}
+	if exceeded.Model.Model != "claude-3-sonnet" || exceeded.Affordability == nil || exceeded.Affordability.Affordable || len(exceeded.Affordability.Warnings) == 0 {
+		t.Errorf("Expected the affordability check for the preferred model, got %+v", exceeded)
+	}
+	if len(service.calls) != 0 {
+		t.Errorf("Expected nothing to be executed, got calls %v", service.calls)
+	}
+}
+
+func TestRoute_AttributesUsageToGoal(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+	router := NewRouter(&anthropicOnlyService{})
+	budget := budgetWithRemaining(t, clock, 0.5)
+	router.SetBudgetManager(budget)
+
+	req := budgetRequest()
+	req.Metadata = map[string]interface{}{MetadataGoalID: "goal-1", MetadataObjectiveID: "objective-1"}
+	if _, err := router.Route(context.Background(), req); err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+
+	transactions := budget.GetTransactions()
+	last := transactions[len(transactions)-1]
+	if last.GoalID != "goal-1" || last.ObjectiveID != "objective-1" {
+		t.Errorf("Expected the completion attributed to goal-1 and objective-1, got %+v", last)
+	}
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// DefaultConversationTurns is how many exchanges a conversation keeps when
+// none is given.
+const DefaultConversationTurns = 20
+
+// Conversation holds a multi-turn exchange with the router, sending the
+// earlier turns with each new message so the model can refer back to them.
+type Conversation struct {
+	router   *Router
+	template TaskRequest
+	maxTurns int
+
+	mu      sync.Mutex
+	history []mcp.Message
+}
+
+// NewConversation creates a conversation whose requests are built from
+// template, keeping at most maxTurns exchanges of history (0: the default).
+// A system prompt in template.Prompt opens every request.
+func NewConversation(router *Router, template TaskRequest, maxTurns int) *Conversation {
+	if maxTurns <= 0 {
+		maxTurns = DefaultConversationTurns
+	}
+	return &Conversation{router: router, template: template, maxTurns: maxTurns}
+}
+
+// Send routes message with the conversation so far and records the reply.
+// A failed exchange leaves the history unchanged.
+func (c *Conversation) Send(ctx context.Context, message string) (*RoutingResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req := c.template
+	req.Prompt = ""
+	if system := strings.TrimSpace(c.template.Prompt); system != "" {
+		req.Messages = append(req.Messages, mcp.Message{Role: mcp.RoleSystem, Content: system})
+	}
+	req.Messages = append(req.Messages, c.history...)
+	req.Messages = append(req.Messages, mcp.Message{Role: mcp.RoleUser, Content: message})
+
+	result, err := c.router.Route(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if result.ExecutionResult == nil {
+		return nil, fmt.Errorf("no result from LLM execution")
+	}
+
+	c.history = append(c.history,
+		mcp.Message{Role: mcp.RoleUser, Content: message},
+		mcp.Message{Role: mcp.RoleAssistant, Content: result.ExecutionResult.Text},
+	)
+	if excess := len(c.history) - c.maxTurns*2; excess > 0 {
+		c.history = append([]mcp.Message(nil), c.history[excess:]...)
+	}
+	return result, nil
+}
+
+// SetSystemPrompt replaces the system prompt that opens each request,
+// keeping the history, as when the conversation turns to other work.
+func (c *Conversation) SetSystemPrompt(prompt string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.template.Prompt = prompt
+}
+
+// History returns the exchanges kept so far, oldest first.
+func (c *Conversation) History() []mcp.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]mcp.Message(nil), c.history...)
+}
+
+// Reset forgets the conversation so far.
+func (c *Conversation) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = nil
+}
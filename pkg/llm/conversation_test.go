@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// rememberingService answers from the conversation it is sent: it recalls
+// the name the user gave and otherwise acknowledges the turn.
+type rememberingService struct {
+	requests [][]mcp.Message
+}
+
+func (s *rememberingService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	if params["operation"] != "complete" {
+		return mcp.ErrorResult(errors.New("unsupported operation"))
+	}
+	messages, ok := params["messages"].([]mcp.Message)
+	if !ok {
+		return mcp.ErrorResult(errors.New("expected a conversation"))
+	}
+	s.requests = append(s.requests, messages)
+
+	last := messages[len(messages)-1].Content
+	text := "Noted."
+	if strings.Contains(last, "my name") {
+		text = "I don't know your name."
+		for _, message := range messages {
+			if name, ok := strings.CutPrefix(message.Content, "I am "); ok && message.Role == mcp.RoleUser {
+				text = "Your name is " + name + "."
+			}
+		}
+	}
+	model, _ := params["model"].(string)
+	return mcp.SuccessResult(&mcp.CompletionResponse{Text: text, TokensUsed: 10, Model: model})
+}
+
+func TestConversation_RemembersEarlierTurns(t *testing.T) {
+	service := &rememberingService{}
+	conversation := NewConversation(NewRouter(service), TaskRequest{
+		Prompt:    "You are a helpful assistant.",
+		MaxTokens: 200,
+		TaskType:  "chat",
+	}, 0)
+
+	turns := []string{"I am Robin", "I work on the storage layer", "It uses a graph", "We ship on Fridays", "What is my name?"}
+	var reply string
+	for _, turn := range turns {
+		result, err := conversation.Send(context.Background(), turn)
+		if err != nil {
+			t.Fatalf("Turn %q failed: %v", turn, err)
+		}
+		reply = result.ExecutionResult.Text
+	}
+
+	if reply != "Your name is Robin." {
+		t.Errorf("Expected the fifth turn to recall the first, got %q", reply)
+	}
+	last := service.requests[len(service.requests)-1]
+	if len(last) != 1+2*4+1 || last[0].Role != mcp.RoleSystem || last[1].Content != "I am Robin" || last[2].Role != mcp.RoleAssistant {
+		t.Errorf("Expected the system prompt and every earlier turn to be sent, got %+v", last)
+	}
+	if history := conversation.History(); len(history) != 10 || history[9].Content != reply {
+		t.Errorf("Expected five exchanges in the history, got %+v", history)
+	}
+}
+
+func TestConversation_KeepsOnlyRecentTurns(t *testing.T) {
+	service := &rememberingService{}
+	conversation := NewConversation(NewRouter(service), TaskRequest{MaxTokens: 200, TaskType: "chat"}, 2)
+
+	for _, turn := range []string{"I am Robin", "one", "two", "What is my name?"} {
+		if _, err := conversation.Send(context.Background(), turn); err != nil {
+			t.Fatalf("Turn %q failed: %v", turn, err)
+		}
+	}
+
+	history := conversation.History()
+	if len(history) != 4 || history[0].Content != "two" {
+		t.Errorf("Expected the last two exchanges to be kept, got %+v", history)
+	}
+	if history[3].Content != "I don't know your name." {
+		t.Errorf("Expected the dropped turn to be forgotten, got %q", history[3].Content)
+	}
+}
+
+func TestConversation_SetSystemPrompt(t *testing.T) {
+	service := &rememberingService{}
+	conversation := NewConversation(NewRouter(service), TaskRequest{MaxTokens: 200, TaskType: "chat"}, 0)
+
+	if _, err := conversation.Send(context.Background(), "I am Robin"); err != nil {
+		t.Fatalf("Turn failed: %v", err)
+	}
+	conversation.SetSystemPrompt("The user is working on the storage layer.")
+	result, err := conversation.Send(context.Background(), "What is my name?")
+	if err != nil {
+		t.Fatalf("Turn failed: %v", err)
+	}
+
+	if len(service.requests[0]) != 1 {
+		t.Errorf("Expected no system prompt before one was set, got %+v", service.requests[0])
+	}
+	last := service.requests[1]
+	if last[0].Role != mcp.RoleSystem || last[0].Content != "The user is working on the storage layer." {
+		t.Errorf("Expected the new system prompt to open the request, got %+v", last[0])
+	}
+	if result.ExecutionResult.Text != "Your name is Robin." {
+		t.Errorf("Expected the history to be kept, got %q", result.ExecutionResult.Text)
+	}
+}
+
+func TestConversation_FailedTurnIsNotKept(t *testing.T) {
+	down := errs.New(errs.ProviderUnavailable, "service unavailable")
+	service := &failingService{failures: map[string]error{"anthropic": down, "openai": down, "local": down}}
+	conversation := NewConversation(NewRouter(service), TaskRequest{MaxTokens: 200, TaskType: "chat"}, 0)
+
+	if _, err := conversation.Send(context.Background(), "hello"); err == nil {
+		t.Fatal("Expected the turn to fail with every provider down")
+	}
+	if history := conversation.History(); len(history) != 0 {
+		t.Errorf("Expected the failed turn to be dropped, got %+v", history)
+	}
+}
+
+func TestScoreModels_SumsTokensAcrossMessages(t *testing.T) {
+	router := NewRouter(&failingService{})
+	prompt := TaskRequest{Prompt: "What did we decide about the release date?", MaxTokens: 200, TaskType: "chat"}
+	conversation := prompt
+	conversation.Prompt = ""
+	conversation.Messages = []mcp.Message{
+		{Role: mcp.RoleSystem, Content: "You are a helpful assistant."},
+		{Role: mcp.RoleUser, Content: "We agreed to ship the release on the first Friday of the month."},
+		{Role: mcp.RoleAssistant, Content: "Understood, the first Friday of the month."},
+		{Role: mcp.RoleUser, Content: prompt.Prompt},
+	}
+
+	single := router.scoreModels(router.getAvailableModels(), router.assessTask(prompt), prompt)[0]
+	estimate := router.estimatePromptTokens(single.Model, conversation)
+
+	var want int
+	for _, message := range conversation.Messages {
+		want += router.config.TokenEstimator.Estimate(single.Model, message.Content).Tokens
+	}
+	if estimate.Tokens != want || estimate.Tokens <= single.TokenEstimate.Tokens {
+		t.Errorf("Expected %d tokens summed across the conversation, got %d (prompt alone: %d)", want, estimate.Tokens, single.TokenEstimate.Tokens)
+	}
+	for _, model := range router.scoreModels(router.getAvailableModels(), router.assessTask(conversation), conversation) {
+		if model.Model == single.Model && model.EstimatedCost <= single.EstimatedCost {
+			t.Errorf("Expected the conversation to cost more than its last turn, got $%.6f and $%.6f", model.EstimatedCost, single.EstimatedCost)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// DefaultCostPreviewDebounce is how long typing must pause before a preview
+// is estimated.
+const DefaultCostPreviewDebounce = 300 * time.Millisecond
+
+// CostLimits are the spending limits a cost preview is checked against.
+type CostLimits struct {
+	// PerRequest is the most a single request may cost (0: no limit)
+	PerRequest float64
+
+	// DailyRemaining is what is left of the daily budget (nil: no daily limit)
+	DailyRemaining *float64
+}
+
+// BudgetCostLimits returns the limits from the budget's daily period and the
+// per-request maximum. A nil budget contributes no daily limit.
+func BudgetCostLimits(budget *BudgetManager, perRequest float64) CostLimits {
+	limits := CostLimits{PerRequest: perRequest}
+	if budget == nil {
+		return limits
+	}
+	if daily, ok := budget.GetBudgetStatus().Periods["daily"]; ok {
+		remaining := daily.Remaining
+		limits.DailyRemaining = &remaining
+	}
+	return limits
+}
+
+// CostPreview is what a request is expected to cost before it is sent, for
+// display while a prompt is being written and for dry runs.
+type CostPreview struct {
+	// Tokens is the estimated prompt and response tokens
+	Tokens int
+
+	// Low and High are the cheapest and dearest of the top-ranked options
+	Low  ModelCostEstimate
+	High ModelCostEstimate
+
+	// Expected is the option routing would pick
+	Expected ModelCostEstimate
+
+	// Warning says which limit the expected cost exceeds ("" if none)
+	Warning string
+}
+
+// NewCostPreview summarizes an estimate and checks the expected cost against
+// the limits. It returns nil if the estimate has no options.
+func NewCostPreview(estimate *CostEstimate, limits CostLimits) *CostPreview {
+	if estimate == nil || len(estimate.Options) == 0 {
+		return nil
+	}
+
+	preview := &CostPreview{
+		Tokens:   estimate.Assessment.EstimatedTokens,
+		Low:      estimate.Options[0],
+		High:     estimate.Options[0],
+		Expected: estimate.Options[0],
+	}
+	for _, option := range estimate.Options[1:] {
+		if option.EstimatedCost < preview.Low.EstimatedCost {
+			preview.Low = option
+		}
+		if option.EstimatedCost > preview.High.EstimatedCost {
+			preview.High = option
+		}
+	}
+
+	cost := preview.Expected.EstimatedCost
+	switch {
+	case limits.PerRequest > 0 && cost > limits.PerRequest:
+		preview.Warning = fmt.Sprintf("over the %s per-request maximum", formatCost(limits.PerRequest))
+	case limits.DailyRemaining != nil && cost > *limits.DailyRemaining:
+		preview.Warning = fmt.Sprintf("over the %s left in today's budget", formatCost(math.Max(*limits.DailyRemaining, 0)))
+	}
+	return preview
+}
+
+// PreviewCost estimates a request for display. It returns nil when there is
+// nothing to show: the prompt is empty, no provider is configured, or no
+// model suits the request. Like EstimateCost, it sends nothing.
+func (r *Router) PreviewCost(req TaskRequest, limits CostLimits) *CostPreview {
+	if strings.TrimSpace(req.text()) == "" || !r.HasProviders() {
+		return nil
+	}
+	estimate, err := r.EstimateCost(req)
+	if err != nil {
+		return nil
+	}
+	return NewCostPreview(estimate, limits)
+}
+
+// OverBudget reports whether the expected cost exceeds a limit.
+func (p *CostPreview) OverBudget() bool {
+	return p.Warning != ""
+}
+
+// String formats the preview as "~1,240 tokens · est. $0.004–$0.011 (haiku–sonnet)".
+func (p *CostPreview) String() string {
+	text := fmt.Sprintf("~%s tokens · est. %s", formatThousands(p.Tokens), formatCost(p.Low.EstimatedCost))
+	if p.High.EstimatedCost > p.Low.EstimatedCost {
+		text += fmt.Sprintf("–%s (%s–%s)", formatCost(p.High.EstimatedCost), shortModelName(p.Low.Model), shortModelName(p.High.Model))
+	} else {
+		text += fmt.Sprintf(" (%s)", shortModelName(p.Low.Model))
+	}
+	return text
+}
+
+// formatCost formats a dollar amount to a tenth of a cent.
+func formatCost(cost float64) string {
+	switch {
+	case cost == 0:
+		return "$0"
+	case cost < 0.001:
+		return "<$0.001"
+	case cost < 1:
+		return fmt.Sprintf("$%.3f", cost)
+	default:
+		return fmt.Sprintf("$%.2f", cost)
+	}
+}
+
+// formatThousands formats a non-negative n with comma thousands separators.
+func formatThousands(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
+
+// shortModelName drops the family and version prefix of model names such
+// as "claude-3-haiku", which are told apart by their last word.
+func shortModelName(model string) string {
+	if strings.HasPrefix(model, "claude-") {
+		return model[strings.LastIndex(model, "-")+1:]
+	}
+	return model
+}
+
+// CostPreviewConfig configures a CostPreviewer.
+type CostPreviewConfig struct {
+	// Debounce is how long updates must pause before estimating (default: 300ms)
+	Debounce time.Duration
+
+	// Limits returns the limits to check previews against when estimating
+	// (default: none)
+	Limits func() CostLimits
+
+	// Clock times the debounce (default: the system clock)
+	Clock utils.Clock
+}
+
+// CostPreviewer estimates the cost of a request as it is being written.
+// Updates are debounced, estimates run off the caller's goroutine, and a
+// result is delivered only if no newer update has arrived, so a slow
+// estimate never replaces a newer one. It only estimates: no request is
+// sent and no budget is spent.
+type CostPreviewer struct {
+	config    CostPreviewConfig
+	onPreview func(seq uint64, preview *CostPreview)
+	estimate  func(req TaskRequest) *CostPreview
+
+	mu      sync.Mutex
+	seq     uint64
+	pending TaskRequest
+	timer   utils.Timer
+	closed  chan struct{}
+	once    sync.Once
+
+	// deliverMu orders deliveries; delivered is the newest one made
+	deliverMu sync.Mutex
+	delivered uint64
+}
+
+// NewCostPreviewer creates a previewer that calls onPreview with each
+// current estimate, from its own goroutine. A nil preview means there is
+// nothing to show, as with PreviewCost.
+func NewCostPreviewer(router *Router, config CostPreviewConfig, onPreview func(seq uint64, preview *CostPreview)) *CostPreviewer {
+	if config.Debounce <= 0 {
+		config.Debounce = DefaultCostPreviewDebounce
+	}
+	if config.Limits == nil {
+		config.Limits = func() CostLimits { return CostLimits{} }
+	}
+	config.Clock = utils.ClockOrReal(config.Clock)
+
+	p := &CostPreviewer{
+		config:    config,
+		onPreview: onPreview,
+		estimate: func(req TaskRequest) *CostPreview {
+			return router.PreviewCost(req, config.Limits())
+		},
+		timer:  config.Clock.NewTimer(config.Debounce),
+		closed: make(chan struct{}),
+	}
+	p.timer.Stop()
+	go p.run()
+	return p
+}
+
+// Update replaces the request being previewed and returns its sequence
+// number. It is estimated once updates pause for the debounce interval.
+func (p *CostPreviewer) Update(req TaskRequest) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seq++
+	p.pending = req
+	p.timer.Reset(p.config.Debounce)
+	return p.seq
+}
+
+// Close stops previewing; pending updates are dropped.
+func (p *CostPreviewer) Close() {
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.timer.Stop()
+		p.mu.Unlock()
+		close(p.closed)
+	})
+}
+
+// run starts an estimate each time the debounce timer fires.
+func (p *CostPreviewer) run() {
+	for {
+		select {
+		case <-p.closed:
+			return
+		case <-p.timer.C():
+			p.mu.Lock()
+			seq, req := p.seq, p.pending
+			p.mu.Unlock()
+			go p.preview(seq, req)
+		}
+	}
+}
+
+// preview estimates one request and delivers the result unless it is stale.
+func (p *CostPreviewer) preview(seq uint64, req TaskRequest) {
+	preview := p.estimate(req)
+
+	p.deliverMu.Lock()
+	defer p.deliverMu.Unlock()
+	p.mu.Lock()
+	current := p.seq
+	p.mu.Unlock()
+	select {
+	case <-p.closed:
+		return
+	default:
+	}
+	if seq != current || seq <= p.delivered {
+		return
+	}
+	p.delivered = seq
+	p.onPreview(seq, preview)
+}
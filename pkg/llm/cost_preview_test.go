@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// countingLLMService counts completion requests and reports a provider count.
+// It cannot list its models.
+type countingLLMService struct {
+	providers int
+	calls     atomic.Int32
+}
+
+func (s *countingLLMService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	if params["operation"] != "complete" {
+		return mcp.ErrorResult(errors.New("unsupported operation"))
+	}
+	s.calls.Add(1)
+	return mcp.SuccessResult(&mcp.CompletionResponse{Text: "unexpected"})
+}
+
+func (s *countingLLMService) GetProviderCount() int {
+	return s.providers
+}
+
+// TestEstimateCostSendsNothing guards the estimation path: previewing a
+// request must never execute it or record spending.
+func TestEstimateCostSendsNothing(t *testing.T) {
+	service := &countingLLMService{providers: 2}
+	router := NewRouter(service)
+	budget, err := NewBudgetManager(t.TempDir(), BudgetConfig{DailyLimit: 1, TrackingEnabled: true}, log.Default())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	req := TaskRequest{Prompt: "Analyze this business proposal", TaskType: "analysis", QualityRequired: QualityStandard}
+	if _, err := router.EstimateCost(req); err != nil {
+		t.Fatalf("Cost estimation failed: %v", err)
+	}
+	if preview := router.PreviewCost(req, BudgetCostLimits(budget, 0.10)); preview == nil {
+		t.Fatal("Expected a preview")
+	}
+
+	delivered := make(chan *CostPreview, 1)
+	previewer := NewCostPreviewer(router, CostPreviewConfig{
+		Debounce: time.Millisecond,
+		Limits:   func() CostLimits { return BudgetCostLimits(budget, 0.10) },
+	}, func(seq uint64, preview *CostPreview) { delivered <- preview })
+	defer previewer.Close()
+	previewer.Update(req)
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the previewer to deliver an estimate")
+	}
+
+	if calls := service.calls.Load(); calls != 0 {
+		t.Errorf("Expected no service calls while estimating, got %d", calls)
+	}
+	if transactions := budget.GetTransactions(); len(transactions) != 0 {
+		t.Errorf("Expected no budget transactions while estimating, got %d", len(transactions))
+	}
+}
+
+func TestCostPreviewFormat(t *testing.T) {
+	estimate := &CostEstimate{
+		Assessment: TaskAssessment{EstimatedTokens: 1240},
+		Options: []ModelCostEstimate{
+			{Provider: "anthropic", Model: "claude-3-sonnet", EstimatedCost: 0.011},
+			{Provider: "openai", Model: "gpt-3.5-turbo", EstimatedCost: 0.005},
+			{Provider: "anthropic", Model: "claude-3-haiku", EstimatedCost: 0.004},
+		},
+	}
+
+	preview := NewCostPreview(estimate, CostLimits{})
+	if got := preview.String(); got != "~1,240 tokens · est. $0.004–$0.011 (haiku–sonnet)" {
+		t.Errorf("Unexpected preview %q", got)
+	}
+	if preview.OverBudget() {
+		t.Errorf("Expected no warning without limits, got %q", preview.Warning)
+	}
+
+	single := NewCostPreview(&CostEstimate{
+		Assessment: TaskAssessment{EstimatedTokens: 12},
+		Options:    []ModelCostEstimate{{Model: "local-llama"}},
+	}, CostLimits{})
+	if got := single.String(); got != "~12 tokens · est. $0 (local-llama)" {
+		t.Errorf("Unexpected single-option preview %q", got)
+	}
+
+	// Limits are checked against the option routing would pick
+	if preview := NewCostPreview(estimate, CostLimits{PerRequest: 0.01}); !preview.OverBudget() {
+		t.Error("Expected a warning over the per-request maximum")
+	}
+	remaining := 0.02
+	if preview := NewCostPreview(estimate, CostLimits{PerRequest: 0.05, DailyRemaining: &remaining}); preview.OverBudget() {
+		t.Errorf("Expected no warning within both limits, got %q", preview.Warning)
+	}
+	remaining = 0.01
+	if preview := NewCostPreview(estimate, CostLimits{DailyRemaining: &remaining}); preview.Warning != "over the $0.010 left in today's budget" {
+		t.Errorf("Expected a warning over the daily budget, got %q", preview.Warning)
+	}
+
+	if NewCostPreview(&CostEstimate{}, CostLimits{}) != nil {
+		t.Error("Expected no preview without options")
+	}
+}
+
+func TestPreviewCostHidden(t *testing.T) {
+	req := TaskRequest{Prompt: "Summarize the meeting notes", TaskType: "generation"}
+
+	if preview := NewRouter(&countingLLMService{}).PreviewCost(req, CostLimits{}); preview != nil {
+		t.Errorf("Expected no preview without providers, got %v", preview)
+	}
+	router := NewRouter(&countingLLMService{providers: 1})
+	if preview := router.PreviewCost(TaskRequest{Prompt: "  "}, CostLimits{}); preview != nil {
+		t.Errorf("Expected no preview for an empty prompt, got %v", preview)
+	}
+	if preview := router.PreviewCost(req, CostLimits{}); preview == nil {
+		t.Error("Expected a preview with a provider configured")
+	}
+}
+
+func TestCostPreviewerDebouncesAndDropsStale(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	delivered := make(chan string, 10)
+	previewer := NewCostPreviewer(NewRouter(&countingLLMService{providers: 1}), CostPreviewConfig{Clock: clock},
+		func(seq uint64, preview *CostPreview) { delivered <- preview.Expected.Model })
+	defer previewer.Close()
+
+	// Each estimate reports the prompt it was made for; "slow" blocks
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	previewer.estimate = func(req TaskRequest) *CostPreview {
+		started <- req.Prompt
+		if req.Prompt == "slow" {
+			<-release
+		}
+		return &CostPreview{Expected: ModelCostEstimate{Model: req.Prompt}}
+	}
+
+	// Typing within the debounce interval estimates only the last text
+	previewer.Update(TaskRequest{Prompt: "s"})
+	clock.Advance(200 * time.Millisecond)
+	previewer.Update(TaskRequest{Prompt: "sl"})
+	clock.Advance(200 * time.Millisecond)
+	previewer.Update(TaskRequest{Prompt: "slow"})
+	clock.Advance(299 * time.Millisecond)
+	select {
+	case prompt := <-started:
+		t.Fatalf("Expected no estimate before the debounce interval, got one for %q", prompt)
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Advance(time.Millisecond)
+	if prompt := <-started; prompt != "slow" {
+		t.Fatalf("Expected the last text to be estimated, got %q", prompt)
+	}
+
+	// A newer update is delivered; the slow estimate finishing later is not
+	previewer.Update(TaskRequest{Prompt: "slower"})
+	clock.Advance(DefaultCostPreviewDebounce)
+	if prompt := <-started; prompt != "slower" {
+		t.Fatalf("Expected the newer text to be estimated, got %q", prompt)
+	}
+	if model := <-delivered; model != "slower" {
+		t.Fatalf("Expected the newer estimate to be delivered, got %q", model)
+	}
+	close(release)
+	select {
+	case model := <-delivered:
+		t.Errorf("Expected the stale estimate to be dropped, got %q", model)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
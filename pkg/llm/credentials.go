@@ -0,0 +1,286 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// CredentialState is what the router last learned about a provider's credentials.
+type CredentialState string
+
+const (
+	// CredentialUnknown means the provider has not been used or probed yet
+	CredentialUnknown CredentialState = "unknown"
+	// CredentialHealthy means the provider last accepted its credentials
+	CredentialHealthy CredentialState = "healthy"
+	// CredentialAuthFailed means the provider rejected its credentials; it is
+	// excluded from routing until a request or probe succeeds again
+	CredentialAuthFailed CredentialState = "auth_failed"
+)
+
+// CredentialSource is where a provider's API key comes from. It decides how
+// the user is asked to replace a rejected key.
+type CredentialSource string
+
+const (
+	// CredentialSourceEnv keys are read from an environment variable and can
+	// only be replaced where the variable is set
+	CredentialSourceEnv CredentialSource = "env"
+	// CredentialSourceConfig keys are stored in the configuration file
+	CredentialSourceConfig CredentialSource = "config"
+	// CredentialSourceKeyring keys are stored in the OS keyring
+	CredentialSourceKeyring CredentialSource = "keyring"
+)
+
+// CredentialStatus reports the credential health of one provider.
+type CredentialStatus struct {
+	Provider    string
+	State       CredentialState
+	Source      CredentialSource
+	LastChecked time.Time
+
+	// FailedAt and LastError describe the current auth failure, if any
+	FailedAt  time.Time
+	LastError string
+}
+
+// CredentialAlert is raised once when a provider starts rejecting its
+// credentials, so the user can replace the key before more work reaches it.
+type CredentialAlert struct {
+	Provider string
+	Source   CredentialSource
+
+	// WasHealthy is set when the provider accepted the same credentials earlier
+	WasHealthy bool
+
+	Err error
+	At  time.Time
+}
+
+// Title returns a short heading for the alert.
+func (a CredentialAlert) Title() string {
+	return fmt.Sprintf("%s credentials rejected", a.Provider)
+}
+
+// Message explains the failure and how to replace the key.
+func (a CredentialAlert) Message() string {
+	message := fmt.Sprintf("%s rejected its API key", a.Provider)
+	if a.WasHealthy {
+		message += " after accepting it earlier; it may have expired or been revoked"
+	}
+	message += ". Work is being routed to other providers until the key is replaced."
+	switch a.Source {
+	case CredentialSourceEnv:
+		return message + fmt.Sprintf(" Update the %s environment variable and restart, or run 'studio config set-credential %s' to override it.", ProviderEnvVar(a.Provider), a.Provider)
+	case CredentialSourceKeyring:
+		return message + fmt.Sprintf(" Run 'studio config set-credential %s' or update it in Settings.", a.Provider)
+	}
+	return message + fmt.Sprintf(" Run 'studio providers set-key %s' or update it in Settings.", a.Provider)
+}
+
+// CredentialMonitorConfig configures a CredentialMonitor.
+type CredentialMonitorConfig struct {
+	// Clock timestamps checks and failures (default: the system clock)
+	Clock utils.Clock
+
+	// Sources records where each provider's key comes from (default: config)
+	Sources map[string]CredentialSource
+
+	// OnAlert is called when a provider starts rejecting its credentials.
+	// It is called without the monitor's lock held.
+	OnAlert func(CredentialAlert)
+}
+
+// CredentialMonitor tracks which providers accept their credentials. The
+// router feeds it the outcome of every request and probe, and skips providers
+// whose credentials were rejected instead of retrying them within the session.
+type CredentialMonitor struct {
+	mu       sync.Mutex
+	clock    utils.Clock
+	sources  map[string]CredentialSource
+	statuses map[string]*CredentialStatus
+	onAlert  func(CredentialAlert)
+}
+
+// NewCredentialMonitor creates a monitor with every provider in the unknown state.
+func NewCredentialMonitor(config ...CredentialMonitorConfig) *CredentialMonitor {
+	var cfg CredentialMonitorConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	sources := make(map[string]CredentialSource, len(cfg.Sources))
+	for provider, source := range cfg.Sources {
+		sources[provider] = source
+	}
+
+	return &CredentialMonitor{
+		clock:    utils.ClockOrReal(cfg.Clock),
+		sources:  sources,
+		statuses: make(map[string]*CredentialStatus),
+		onAlert:  cfg.OnAlert,
+	}
+}
+
+// SetOnAlert replaces the function called when a provider starts rejecting
+// its credentials.
+func (m *CredentialMonitor) SetOnAlert(onAlert func(CredentialAlert)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onAlert = onAlert
+}
+
+// SetSource records where a provider's key comes from.
+func (m *CredentialMonitor) SetSource(provider string, source CredentialSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sources[provider] = source
+	if status, exists := m.statuses[provider]; exists {
+		status.Source = source
+	}
+}
+
+// RecordSuccess marks the provider's credentials as accepted, clearing any
+// auth failure. It reports whether the provider was excluded until now.
+func (m *CredentialMonitor) RecordSuccess(provider string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := m.status(provider)
+	recovered := status.State == CredentialAuthFailed
+	status.State = CredentialHealthy
+	status.LastChecked = m.clock.Now()
+	status.FailedAt = time.Time{}
+	status.LastError = ""
+	return recovered
+}
+
+// RecordAuthFailure marks the provider's credentials as rejected, excluding it
+// from routing. Only the first failure raises an alert; the next one alerts
+// again after the provider has recovered. It reports whether an alert was raised.
+func (m *CredentialMonitor) RecordAuthFailure(provider string, err error) bool {
+	m.mu.Lock()
+	status := m.status(provider)
+	now := m.clock.Now()
+	status.LastChecked = now
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	if status.State == CredentialAuthFailed {
+		m.mu.Unlock()
+		return false
+	}
+
+	alert := CredentialAlert{
+		Provider:   provider,
+		Source:     status.Source,
+		WasHealthy: status.State == CredentialHealthy,
+		Err:        err,
+		At:         now,
+	}
+	status.State = CredentialAuthFailed
+	status.FailedAt = now
+	onAlert := m.onAlert
+	m.mu.Unlock()
+
+	if onAlert != nil {
+		onAlert(alert)
+	}
+	return true
+}
+
+// Excluded reports whether routing should skip the provider because it
+// rejected its credentials.
+func (m *CredentialMonitor) Excluded(provider string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status, exists := m.statuses[provider]
+	return exists && status.State == CredentialAuthFailed
+}
+
+// Status returns the credential status of a provider.
+func (m *CredentialMonitor) Status(provider string) CredentialStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if status, exists := m.statuses[provider]; exists {
+		return *status
+	}
+	return CredentialStatus{Provider: provider, State: CredentialUnknown, Source: m.source(provider)}
+}
+
+// Statuses returns the status of every provider seen so far, by provider name.
+func (m *CredentialMonitor) Statuses() []CredentialStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]CredentialStatus, 0, len(m.statuses))
+	for _, status := range m.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Provider < statuses[j].Provider
+	})
+	return statuses
+}
+
+// status returns the provider's status record, creating it if needed.
+// The caller must hold the lock.
+func (m *CredentialMonitor) status(provider string) *CredentialStatus {
+	status, exists := m.statuses[provider]
+	if !exists {
+		status = &CredentialStatus{Provider: provider, State: CredentialUnknown, Source: m.source(provider)}
+		m.statuses[provider] = status
+	}
+	return status
+}
+
+// source returns where the provider's key comes from. The caller must hold the lock.
+func (m *CredentialMonitor) source(provider string) CredentialSource {
+	if source, exists := m.sources[provider]; exists {
+		return source
+	}
+	return CredentialSourceConfig
+}
+
+// CredentialProviders are the providers that authenticate with an API key.
+var CredentialProviders = []string{"anthropic", "openai"}
+
+// ProviderEnvVar returns the environment variable holding a provider's API key.
+func ProviderEnvVar(provider string) string {
+	return strings.ToUpper(provider) + "_API_KEY"
+}
+
+// EnvironmentCredentialSources returns CredentialSourceEnv for each provider
+// whose API key is set in the environment.
+func EnvironmentCredentialSources() map[string]CredentialSource {
+	sources := make(map[string]CredentialSource)
+	for _, provider := range CredentialProviders {
+		if os.Getenv(ProviderEnvVar(provider)) != "" {
+			sources[provider] = CredentialSourceEnv
+		}
+	}
+	return sources
+}
+
+// DiagnosedCredentialSources returns where each provider's API key was found
+// by the service's diagnosis: the OS keyring, the environment, or otherwise
+// the configuration file.
+func DiagnosedCredentialSources(diagnosis *mcp.ProviderDiagnosis) map[string]CredentialSource {
+	sources := make(map[string]CredentialSource)
+	for _, status := range diagnosis.Providers {
+		switch {
+		case !status.Available || status.Source == "":
+		case status.Source == mcp.CredentialSourceKeyring:
+			sources[status.Provider] = CredentialSourceKeyring
+		case status.Source == ProviderEnvVar(status.Provider):
+			sources[status.Provider] = CredentialSourceEnv
+		}
+	}
+	return sources
+}
@@ -0,0 +1,203 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// expireKey makes every model of the provider reject its credentials.
+func expireKey(router *Router, service *MockLLMService, provider string) {
+	for _, model := range router.getAvailableModels() {
+		if model.Provider == provider {
+			service.SetError("complete", provider, model.Model, fmt.Errorf("completion failed: %w",
+				&mcp.APIError{StatusCode: 401, Message: "invalid x-api-key"}))
+		}
+	}
+}
+
+// renewKey clears the provider's rejected credentials.
+func renewKey(router *Router, service *MockLLMService, provider string) {
+	for _, model := range router.getAvailableModels() {
+		if model.Provider == provider {
+			delete(service.errors, "complete_"+provider+"_"+model.Model)
+		}
+	}
+}
+
+func TestAPIError_MatchesAuthFailures(t *testing.T) {
+	for status, auth := range map[int]bool{401: true, 403: true, 429: false, 500: false} {
+		err := fmt.Errorf("operation failed: %w", &mcp.APIError{StatusCode: status, Message: "error"})
+		if errors.Is(err, mcp.ErrAuthFailed) != auth {
+			t.Errorf("Status %d: expected auth failure %v", status, auth)
+		}
+	}
+}
+
+func TestRouter_CredentialsExpireMidPlan(t *testing.T) {
+	service := NewMockLLMService()
+	var alerts []CredentialAlert
+	config := DefaultRouterConfig()
+	config.Credentials = NewCredentialMonitor(CredentialMonitorConfig{
+		OnAlert: func(alert CredentialAlert) { alerts = append(alerts, alert) },
+	})
+	router := NewRouter(service, config)
+	ctx := context.Background()
+
+	req := TaskRequest{Prompt: "Summarize the quarterly report", TaskType: "summarization", QualityRequired: QualityStandard, MaxTokens: 500}
+
+	// The plan's first task succeeds with the preferred provider
+	first, err := router.Route(ctx, req)
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	provider := first.SelectedModel.Provider
+	if state := router.Credentials().Status(provider).State; state != CredentialHealthy {
+		t.Errorf("Expected %s to be healthy after a success, got %s", provider, state)
+	}
+
+	// The key expires before the second task, which falls back to another provider
+	expireKey(router, service, provider)
+	second, err := router.Route(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected the second task to fall back: %v", err)
+	}
+	if second.SelectedModel.Provider == provider {
+		t.Errorf("Expected a provider other than %s, got %s", provider, second.SelectedModel.Provider)
+	}
+	if len(second.FailedModels) != 1 || second.FailedModels[0].Provider != provider {
+		t.Errorf("Expected only the first rejected model to be tried, got %v", second.FailedModels)
+	}
+
+	if len(alerts) != 1 {
+		t.Fatalf("Expected one alert, got %d", len(alerts))
+	}
+	alert := alerts[0]
+	if alert.Provider != provider || !alert.WasHealthy || !errors.Is(alert.Err, mcp.ErrAuthFailed) {
+		t.Errorf("Expected an alert for the previously healthy %s, got %+v", provider, alert)
+	}
+	if !strings.Contains(alert.Message(), "studio providers set-key "+provider) {
+		t.Errorf("Expected the alert to explain how to replace the key, got %q", alert.Message())
+	}
+
+	// Later tasks skip the provider without retrying it or alerting again
+	third, err := router.Route(ctx, req)
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	if len(third.FailedModels) != 0 {
+		t.Errorf("Expected the rejected provider not to be retried, got %v", third.FailedModels)
+	}
+	if len(third.ExcludedModels) == 0 {
+		t.Fatal("Expected the rejected provider's models to be excluded")
+	}
+	for _, exclusion := range third.ExcludedModels {
+		if exclusion.Model.Provider != provider || exclusion.Reason != ExclusionAuthFailed {
+			t.Errorf("Expected only %s excluded for auth, got %+v", provider, exclusion)
+		}
+	}
+	if len(alerts) != 1 {
+		t.Errorf("Expected no repeated alert, got %d", len(alerts))
+	}
+
+	// A failed probe keeps the provider excluded; a successful one restores routing
+	if err := router.ProbeProvider(ctx, provider); !errors.Is(err, mcp.ErrAuthFailed) {
+		t.Errorf("Expected the probe to report rejected credentials, got %v", err)
+	}
+	renewKey(router, service, provider)
+	if err := router.ProbeProvider(ctx, provider); err != nil {
+		t.Fatalf("Expected the probe to succeed with a renewed key: %v", err)
+	}
+	if router.Credentials().Excluded(provider) {
+		t.Error("Expected a successful probe to clear the exclusion")
+	}
+	restored, err := router.Route(ctx, req)
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	if restored.SelectedModel != first.SelectedModel || len(restored.ExcludedModels) != 0 {
+		t.Errorf("Expected routing to return to %s, got %s with exclusions %v",
+			first.SelectedModel.Model, restored.SelectedModel.Model, restored.ExcludedModels)
+	}
+}
+
+func TestRouter_OtherFailuresKeepCredentials(t *testing.T) {
+	service := NewMockLLMService()
+	router := NewRouter(service)
+	ctx := context.Background()
+
+	service.SetError("complete", "anthropic", "claude-3-haiku", fmt.Errorf("completion failed: %w",
+		&mcp.APIError{StatusCode: 503, Message: "overloaded"}))
+	if err := router.ProbeProvider(ctx, "anthropic"); err == nil {
+		t.Fatal("Expected the probe to fail")
+	}
+	if state := router.Credentials().Status("anthropic").State; state != CredentialUnknown {
+		t.Errorf("Expected an outage not to change the credential state, got %s", state)
+	}
+	if err := router.ProbeProvider(ctx, "nonexistent"); err == nil {
+		t.Error("Expected an unknown provider to be refused")
+	}
+}
+
+func TestRouter_AllProvidersRejected(t *testing.T) {
+	service := NewMockLLMService()
+	router := NewRouter(service)
+	for _, provider := range []string{"anthropic", "openai", "local"} {
+		router.Credentials().RecordAuthFailure(provider, nil)
+	}
+
+	_, err := router.Route(context.Background(), TaskRequest{Prompt: "Hello", TaskType: "qa", MaxTokens: 10})
+	if !errors.Is(err, mcp.ErrAuthFailed) {
+		t.Errorf("Expected routing to report rejected credentials, got %v", err)
+	}
+}
+
+func TestCredentialAlert_Message(t *testing.T) {
+	monitor := NewCredentialMonitor(CredentialMonitorConfig{
+		Sources: map[string]CredentialSource{"openai": CredentialSourceEnv},
+	})
+	var alert CredentialAlert
+	monitor.SetOnAlert(func(raised CredentialAlert) { alert = raised })
+
+	// A key that never worked is still excluded and reported
+	if !monitor.RecordAuthFailure("openai", errors.New("rejected")) {
+		t.Fatal("Expected the first failure to raise an alert")
+	}
+	if alert.WasHealthy || alert.Source != CredentialSourceEnv {
+		t.Errorf("Expected an alert for a never-healthy env key, got %+v", alert)
+	}
+	if message := alert.Message(); !strings.Contains(message, "OPENAI_API_KEY") || strings.Contains(message, "set-key") {
+		t.Errorf("Expected env keys to be replaced in the environment, got %q", message)
+	}
+
+	if monitor.RecordSuccess("anthropic") {
+		t.Error("Expected a first success not to count as a recovery")
+	}
+	if !monitor.RecordSuccess("openai") {
+		t.Error("Expected a success after a failure to count as a recovery")
+	}
+	statuses := monitor.Statuses()
+	if len(statuses) != 2 || statuses[0].Provider != "anthropic" || statuses[1].LastError != "" {
+		t.Errorf("Expected both providers healthy in name order, got %+v", statuses)
+	}
+}
+
+func TestDiagnosedCredentialSources(t *testing.T) {
+	sources := DiagnosedCredentialSources(&mcp.ProviderDiagnosis{Providers: []mcp.ProviderStatus{
+		{Provider: "anthropic", Available: true, Source: mcp.CredentialSourceKeyring},
+		{Provider: "openai", Available: true, Source: "OPENAI_API_KEY"},
+		{Provider: "local", Available: true, Source: "LOCAL_LLM_URL"},
+	}})
+	if len(sources) != 2 || sources["anthropic"] != CredentialSourceKeyring || sources["openai"] != CredentialSourceEnv {
+		t.Errorf("Expected the keyring and environment sources, got %v", sources)
+	}
+
+	alert := CredentialAlert{Provider: "anthropic", Source: CredentialSourceKeyring}
+	if !strings.Contains(alert.Message(), "config set-credential anthropic") {
+		t.Errorf("Expected keyring keys to be replaced in the keyring, got %q", alert.Message())
+	}
+}
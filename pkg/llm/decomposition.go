@@ -0,0 +1,376 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+const (
+	// DefaultDecompositionOverlap is how many tokens consecutive chunks of a
+	// decomposed prompt share when RouterConfig.DecompositionOverlap is unset
+	DefaultDecompositionOverlap = 200
+
+	// decompositionOutputTokens is how much each call of a decomposed request
+	// may generate when the request does not set MaxTokens
+	decompositionOutputTokens = 1024
+
+	// decompositionMargin is kept free in every decomposed call, for the
+	// difference between counting a prompt in pieces and as a whole
+	decompositionMargin = 32
+)
+
+// ChunkResult describes the map call that processed one chunk of a
+// decomposed prompt.
+type ChunkResult struct {
+	Index        int     // Position of the chunk, from 0
+	Start        int     // Byte offset of the chunk in the prompt
+	End          int     // Byte offset just past the chunk
+	PromptTokens int     // Estimated tokens of the chunk's map prompt
+	RoutingID    string  // Names the chunk's routing for SubmitFeedback
+	Provider     string  // Provider that processed the chunk
+	Model        string  // Model that processed the chunk
+	TokensUsed   int     // Tokens the map call used
+	Cost         float64 // What the map call cost
+}
+
+// promptChunk is one piece of a prompt being decomposed.
+type promptChunk struct {
+	Start, End int
+	Text       string
+}
+
+// routeDecomposed runs a request too long for any model as a map phase over
+// overlapping chunks of its prompt, sized to the model with the largest
+// context, and a reduce phase that combines the partial answers. Every call
+// is routed on its own; the result adds up their tokens and cost and is
+// otherwise the reduce call's. A BudgetConstraint bounds the whole pipeline:
+// each map call may only spend what leaves enough for the reduce call, and
+// the reduce call is not made if what is left cannot cover it.
+func (r *Router) routeDecomposed(ctx context.Context, req TaskRequest, assessment TaskAssessment, models []ModelInfo) (*RoutingResult, error) {
+	settings := r.policySettings(req)
+	var target *ModelInfo
+	for i, model := range models {
+		if settings.MaxInputCost > 0 && model.InputCost > settings.MaxInputCost {
+			continue
+		}
+		if target == nil || model.ContextSize > target.ContextSize ||
+			(model.ContextSize == target.ContextSize && model.InputCost < target.InputCost) {
+			target = &models[i]
+		}
+	}
+	if target == nil {
+		return nil, errs.New(errs.ProviderUnavailable, "no suitable models available for this task")
+	}
+
+	outputTokens := req.MaxTokens
+	if outputTokens <= 0 {
+		outputTokens = decompositionOutputTokens
+	}
+	count := func(text string) int { return r.CountTokens(target.Model, text) }
+
+	excerpt := requestExcerpt(req.Prompt, target.ContextSize/8, count)
+	overhead := count(mapPrompt(excerpt, "", 1, 1)) + decompositionMargin
+	chunkTokens := target.ContextSize - outputTokens - overhead
+	if chunkTokens <= 0 {
+		return nil, errs.Newf(errs.ProviderUnavailable, "no model has room to process this task in parts: %s/%s holds %d tokens",
+			target.Provider, target.Model, target.ContextSize)
+	}
+	overlap := min(r.config.DecompositionOverlap, chunkTokens/4)
+	chunks := splitPrompt(req.Prompt, chunkTokens, overlap, count)
+	if len(chunks) == 1 {
+		// Only the room left for the answer was short: bounding it is enough
+		whole := req
+		whole.MaxTokens = outputTokens
+		whole.AllowDecomposition = false
+		return r.Route(ctx, whole)
+	}
+
+	// The partial answers must all fit one reduce call
+	reduceTokens := len(chunks)*outputTokens + count(reducePrompt(excerpt, nil)) + decompositionMargin
+	if reduceTokens+outputTokens > target.ContextSize {
+		return nil, errs.Newf(errs.ProviderUnavailable, "task is too long to process in parts: %d parts would not fit in one %s/%s call",
+			len(chunks), target.Provider, target.Model).With("chunks", len(chunks))
+	}
+
+	// Fail before spending anything when even the cheapest models cannot
+	// cover the whole pipeline
+	remaining := math.Inf(1)
+	if req.BudgetConstraint != nil {
+		remaining = *req.BudgetConstraint
+	}
+	reduceReserve, _ := r.cheapestCost(models, req, reduceTokens, outputTokens)
+	estimate := reduceReserve
+	for _, chunk := range chunks {
+		cost, _ := r.cheapestCost(models, req, count(chunk.Text)+overhead, outputTokens)
+		estimate += cost
+	}
+	if estimate > remaining {
+		return nil, fmt.Errorf("%w: %d parts and their combination would cost at least $%.4f, over the $%.4f budget constraint",
+			ErrBudgetExceeded, len(chunks), estimate, remaining)
+	}
+
+	var results []ChunkResult
+	var partials []string
+	var failedModels []ModelRecommendation
+	var failures []ModelFailure
+	var refusals []ModelRefusal
+	rephrased := false
+	total := mcp.CompletionResponse{}
+	for i, chunk := range chunks {
+		mapReq := req
+		mapReq.Prompt = mapPrompt(excerpt, chunk.Text, i+1, len(chunks))
+		mapReq.Messages = nil
+		mapReq.MaxTokens = outputTokens
+		mapReq.AllowDecomposition = false
+		if req.BudgetConstraint != nil {
+			limit := remaining - reduceReserve
+			mapReq.BudgetConstraint = &limit
+		}
+
+		routing, err := r.Route(ctx, mapReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process part %d of %d: %w", i+1, len(chunks), err)
+		}
+		response := routing.ExecutionResult
+		remaining -= response.Cost
+		addUsage(&total, response)
+		partials = append(partials, response.Text)
+		failedModels = append(failedModels, routing.FailedModels...)
+		failures = append(failures, routing.Failures...)
+		refusals = append(refusals, routing.Refusals...)
+		rephrased = rephrased || routing.Rephrased
+		results = append(results, ChunkResult{
+			Index:        i,
+			Start:        chunk.Start,
+			End:          chunk.End,
+			PromptTokens: count(mapReq.Prompt),
+			RoutingID:    routing.ID,
+			Provider:     routing.SelectedModel.Provider,
+			Model:        routing.SelectedModel.Model,
+			TokensUsed:   response.TokensUsed,
+			Cost:         response.Cost,
+		})
+	}
+
+	reduceReq := req
+	reduceReq.Prompt = reducePrompt(excerpt, partials)
+	reduceReq.Messages = nil
+	reduceReq.MaxTokens = outputTokens
+	reduceReq.AllowDecomposition = false
+	reduceTokens = count(reduceReq.Prompt)
+	reduceCost, ok := r.cheapestCost(models, reduceReq, reduceTokens, outputTokens)
+	if !ok {
+		return nil, errs.Newf(errs.ProviderUnavailable, "no model can combine the %d partial answers", len(chunks)).With("spent", total.Cost)
+	}
+	if reduceCost > remaining {
+		return nil, fmt.Errorf("%w: combining %d parts would cost at least $%.4f with $%.4f of the budget constraint left ($%.4f spent on the parts)",
+			ErrBudgetExceeded, len(chunks), reduceCost, remaining, total.Cost)
+	}
+	if r.budget != nil {
+		check, err := r.budget.CanAffordInPlan(reduceCost, req.metadataString(MetadataPlanID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check budget: %w", err)
+		}
+		if !check.Affordable {
+			return nil, fmt.Errorf("%w: combining %d parts would cost at least $%.4f, more than the budget has left ($%.4f spent on the parts)",
+				ErrBudgetExceeded, len(chunks), reduceCost, total.Cost)
+		}
+	}
+	if req.BudgetConstraint != nil {
+		limit := remaining
+		reduceReq.BudgetConstraint = &limit
+	}
+
+	reduced, err := r.Route(ctx, reduceReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to combine %d parts: %w", len(chunks), err)
+	}
+	execution := *reduced.ExecutionResult
+	addUsage(&total, &execution)
+	execution.TokensUsed = total.TokensUsed
+	execution.InputTokens = total.InputTokens
+	execution.OutputTokens = total.OutputTokens
+	execution.Cost = total.Cost
+
+	return &RoutingResult{
+		ID:                reduced.ID,
+		Assessment:        assessment,
+		SelectedModel:     reduced.SelectedModel,
+		AlternativeModels: reduced.AlternativeModels,
+		FailedModels:      append(failedModels, reduced.FailedModels...),
+		Failures:          append(failures, reduced.Failures...),
+		ExcludedModels:    reduced.ExcludedModels,
+		Refusals:          append(refusals, reduced.Refusals...),
+		Rephrased:         rephrased || reduced.Rephrased,
+		Decomposed:        true,
+		Chunks:            results,
+		ExecutionResult:   &execution,
+		ExecutionTime:     r.config.Clock.Now(),
+	}, nil
+}
+
+// fitsNoModel reports whether the request is too long for every model the
+// request's policy allows, rather than ruled out by its budget constraint.
+func (r *Router) fitsNoModel(models []ModelInfo, req TaskRequest) bool {
+	settings := r.policySettings(req)
+	for _, model := range models {
+		if settings.MaxInputCost > 0 && model.InputCost > settings.MaxInputCost {
+			continue
+		}
+		inputTokens := r.estimatePromptTokens(model.Model, req).Tokens
+		if inputTokens+estimateOutputTokens(inputTokens, req.MaxTokens) <= model.ContextSize {
+			return false
+		}
+	}
+	return true
+}
+
+// cheapestCost returns the lowest estimated cost of a call of inputTokens
+// and outputTokens among the models that fit it and the request's policy.
+func (r *Router) cheapestCost(models []ModelInfo, req TaskRequest, inputTokens, outputTokens int) (float64, bool) {
+	settings := r.policySettings(req)
+	cheapest, found := 0.0, false
+	for _, model := range models {
+		if settings.MaxInputCost > 0 && model.InputCost > settings.MaxInputCost {
+			continue
+		}
+		if inputTokens+outputTokens > model.ContextSize {
+			continue
+		}
+		if cost := model.cost(inputTokens, outputTokens); !found || cost < cheapest {
+			cheapest, found = cost, true
+		}
+	}
+	return cheapest, found
+}
+
+// addUsage adds the tokens and cost of a completion to a running total.
+func addUsage(total, response *mcp.CompletionResponse) {
+	total.TokensUsed += response.TokensUsed
+	total.InputTokens += response.InputTokens
+	total.OutputTokens += response.OutputTokens
+	total.Cost += response.Cost
+}
+
+// mapPrompt asks for one chunk of a decomposed request to be processed.
+func mapPrompt(excerpt, chunk string, part, parts int) string {
+	return fmt.Sprintf("The request below is too long to process at once, so it has been split into %d parts that overlap slightly at their edges. "+
+		"Carry out the request for part %d only; your answer will be combined with those for the other parts.\n\n"+
+		"The request begins:\n%s\n\nPart %d of %d:\n%s", parts, part, excerpt, part, parts, chunk)
+}
+
+// reducePrompt asks for the partial answers of a decomposed request to be
+// combined into one.
+func reducePrompt(excerpt string, partials []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The request below was too long to process at once, so it was split into %d overlapping parts and each part was answered on its own. "+
+		"Combine the partial answers into a single answer to the whole request, without repeating what the overlaps answered twice.\n\n"+
+		"The request begins:\n%s", len(partials), excerpt)
+	for i, partial := range partials {
+		fmt.Fprintf(&b, "\n\nPartial answer %d of %d:\n%s", i+1, len(partials), partial)
+	}
+	return b.String()
+}
+
+// requestExcerpt returns the opening of a prompt, where requests usually
+// state what they want: its first paragraph, cut to at most maxTokens.
+func requestExcerpt(prompt string, maxTokens int, count func(string) int) string {
+	excerpt := strings.TrimSpace(prompt)
+	if end := strings.Index(excerpt, "\n\n"); end >= 0 {
+		excerpt = excerpt[:end]
+	}
+	for len(excerpt) > 0 && count(excerpt) > maxTokens {
+		excerpt = excerpt[:runeStart(excerpt, len(excerpt)*3/4)]
+	}
+	return excerpt
+}
+
+// splitPrompt splits text into chunks of at most maxTokens, each sharing
+// about overlap tokens with the one before. Chunks end at a paragraph break
+// where one falls in the second half of the chunk, otherwise at a line
+// break or a space, and only mid-word in text without any.
+func splitPrompt(text string, maxTokens, overlap int, count func(string) int) []promptChunk {
+	total := count(text)
+	if total <= maxTokens {
+		return []promptChunk{{Start: 0, End: len(text), Text: text}}
+	}
+	bytesPerToken := float64(len(text)) / float64(max(total, 1))
+	overlapBytes := int(float64(overlap) * bytesPerToken)
+
+	var chunks []promptChunk
+	start := 0
+	for start < len(text) {
+		window := int(float64(maxTokens) * bytesPerToken)
+		end := len(text)
+		for {
+			if start+window < len(text) {
+				end = breakBefore(text, start+window/2, start+window)
+			} else {
+				end = len(text)
+			}
+			if count(text[start:end]) <= maxTokens || window <= 1 {
+				break
+			}
+			window = window * 9 / 10
+		}
+		if end <= start {
+			_, size := utf8.DecodeRuneInString(text[start:])
+			end = start + size
+		}
+		chunks = append(chunks, promptChunk{Start: start, End: end, Text: text[start:end]})
+		if end == len(text) {
+			break
+		}
+
+		next := breakAfter(text, end-overlapBytes, end)
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// breakBefore returns the position of the last paragraph break, or failing
+// that line break or space, in text[from:to], just past the break; to if
+// there is none.
+func breakBefore(text string, from, to int) int {
+	from, to = runeStart(text, from), runeStart(text, to)
+	window := text[from:to]
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(window, sep); i >= 0 {
+			return from + i + len(sep)
+		}
+	}
+	return to
+}
+
+// breakAfter returns the position just past the first paragraph break, or
+// failing that line break or space, in text[from:to]; from if there is none.
+func breakAfter(text string, from, to int) int {
+	from = runeStart(text, max(from, 0))
+	window := text[from:to]
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.Index(window, sep); i >= 0 && from+i+len(sep) < to {
+			return from + i + len(sep)
+		}
+	}
+	return from
+}
+
+// runeStart moves i back to the start of the rune it falls in.
+func runeStart(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
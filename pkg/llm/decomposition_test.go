@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// smallContextService offers a single model with a 2,000 token context and
+// answers every completion at a fixed cost, recording the prompts it is sent.
+type smallContextService struct {
+	cost    float64
+	prompts []string
+}
+
+func (s *smallContextService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	switch params["operation"] {
+	case "list_models":
+		return mcp.SuccessResult([]mcp.ModelListing{
+			{Provider: "local", Model: "small", InputCost: 0.001, OutputCost: 0.001, MaxTokens: 1000, ContextSize: 2000, SupportsChat: true, QualityTier: "standard", SpeedTier: 1},
+		})
+	case "complete":
+		prompt, _ := params["prompt"].(string)
+		s.prompts = append(s.prompts, prompt)
+		return mcp.SuccessResult(&mcp.CompletionResponse{
+			Text:         fmt.Sprintf("Answer %d.", len(s.prompts)),
+			TokensUsed:   150,
+			InputTokens:  100,
+			OutputTokens: 50,
+			Model:        "small",
+			Provider:     "local",
+			Cost:         s.cost,
+		})
+	default:
+		return mcp.ErrorResult(errors.New("unsupported operation"))
+	}
+}
+
+// longDocument returns a request to summarize 40 paragraphs of about 100
+// tokens each, twice what the small model holds.
+func longDocument() TaskRequest {
+	var b strings.Builder
+	b.WriteString("Summarize the findings of the report below.")
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&b, "\n\nParagraph %02d. %s", i, strings.Repeat("The survey results were consistent. ", 8))
+	}
+	return TaskRequest{Prompt: b.String(), TaskType: "analysis", MaxTokens: 200, AllowDecomposition: true}
+}
+
+func TestRoute_DecomposesPromptTooLongForAnyModel(t *testing.T) {
+	service := &smallContextService{cost: 0.01}
+	router := NewRouter(service)
+	req := longDocument()
+
+	result, err := router.Route(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	if !result.Decomposed {
+		t.Fatal("Expected the routing to be decomposed")
+	}
+	if len(result.Chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(result.Chunks))
+	}
+	if len(service.prompts) != len(result.Chunks)+1 {
+		t.Fatalf("Expected a map call per chunk and one reduce call, got %d calls", len(service.prompts))
+	}
+
+	prompt := req.Prompt
+	if result.Chunks[0].Start != 0 || result.Chunks[len(result.Chunks)-1].End != len(prompt) {
+		t.Errorf("Expected the chunks to cover the whole prompt, got %+v", result.Chunks)
+	}
+	for i, chunk := range result.Chunks {
+		if chunk.Index != i {
+			t.Errorf("Chunk %d has index %d", i, chunk.Index)
+		}
+		if chunk.PromptTokens+req.MaxTokens > 2000 {
+			t.Errorf("Chunk %d map prompt of %d tokens does not fit the model", i, chunk.PromptTokens)
+		}
+		if !strings.Contains(service.prompts[i], prompt[chunk.Start:chunk.End]) {
+			t.Errorf("Map call %d was not sent chunk %d", i, i)
+		}
+		if i == 0 {
+			continue
+		}
+		previous := result.Chunks[i-1]
+		if !strings.HasSuffix(prompt[:previous.End], "\n\n") || !strings.HasPrefix(prompt[chunk.Start:], "Paragraph") {
+			t.Errorf("Expected chunks to break at paragraphs, got %q ... %q", prompt[previous.End-20:previous.End], prompt[chunk.Start:chunk.Start+20])
+		}
+		overlap := router.CountTokens("small", prompt[chunk.Start:previous.End])
+		if chunk.Start >= previous.End || overlap < 100 || overlap > DefaultDecompositionOverlap {
+			t.Errorf("Expected chunk %d to overlap the one before by up to %d tokens, got %d", i, DefaultDecompositionOverlap, overlap)
+		}
+	}
+
+	reduce := service.prompts[len(service.prompts)-1]
+	for i := range result.Chunks {
+		if !strings.Contains(reduce, fmt.Sprintf("Answer %d.", i+1)) {
+			t.Errorf("Expected the reduce call to combine partial answer %d", i+1)
+		}
+	}
+	if result.ExecutionResult.Text != "Answer 4." {
+		t.Errorf("Expected the reduce call's answer, got %q", result.ExecutionResult.Text)
+	}
+}
+
+func TestRoute_DecompositionAggregatesAccounting(t *testing.T) {
+	service := &smallContextService{cost: 0.01}
+	router := NewRouter(service)
+
+	result, err := router.Route(context.Background(), longDocument())
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	calls := len(result.Chunks) + 1
+	execution := result.ExecutionResult
+	if execution.TokensUsed != 150*calls || execution.InputTokens != 100*calls || execution.OutputTokens != 50*calls {
+		t.Errorf("Expected tokens summed over %d calls, got %d (%d in, %d out)", calls, execution.TokensUsed, execution.InputTokens, execution.OutputTokens)
+	}
+	if diff := execution.Cost - 0.01*float64(calls); diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected cost summed over %d calls, got $%.4f", calls, execution.Cost)
+	}
+
+	ids := map[string]bool{result.ID: true}
+	for _, chunk := range result.Chunks {
+		if chunk.TokensUsed != 150 || chunk.Cost != 0.01 || chunk.Provider != "local" || chunk.Model != "small" {
+			t.Errorf("Unexpected chunk accounting: %+v", chunk)
+		}
+		if chunk.RoutingID == "" || ids[chunk.RoutingID] {
+			t.Errorf("Expected each call to have its own routing ID, got %q", chunk.RoutingID)
+		}
+		ids[chunk.RoutingID] = true
+	}
+}
+
+func TestRoute_DecompositionAbortsBeforeReduceOverBudget(t *testing.T) {
+	// The parts cost far more than estimated, leaving nothing to combine them
+	service := &smallContextService{cost: 0.025}
+	router := NewRouter(service)
+	req := longDocument()
+	budget := 0.075
+	req.BudgetConstraint = &budget
+
+	_, err := router.Route(context.Background(), req)
+	if !errors.Is(err, ErrBudgetExceeded) || errs.CodeOf(err) != errs.BudgetExceeded {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if len(service.prompts) != 3 {
+		t.Errorf("Expected the 3 map calls and no reduce call, got %d calls", len(service.prompts))
+	}
+}
+
+func TestRoute_DecompositionNeedsToBeAllowed(t *testing.T) {
+	service := &smallContextService{cost: 0.01}
+	router := NewRouter(service)
+
+	req := longDocument()
+	req.AllowDecomposition = false
+	if _, err := router.Route(context.Background(), req); errs.CodeOf(err) != errs.ProviderUnavailable {
+		t.Errorf("Expected no suitable model without decomposition, got %v", err)
+	}
+
+	// A conversation is never split
+	req = longDocument()
+	req.Messages = []mcp.Message{{Role: mcp.RoleUser, Content: req.Prompt}}
+	if _, err := router.Route(context.Background(), req); errs.CodeOf(err) != errs.ProviderUnavailable {
+		t.Errorf("Expected a conversation not to be decomposed, got %v", err)
+	}
+	if len(service.prompts) != 0 {
+		t.Errorf("Expected no completions, got %d", len(service.prompts))
+	}
+}
+
+func TestSplitPrompt_BreaksWithoutParagraphs(t *testing.T) {
+	count := HeuristicTokenizer().CountTokens
+	text := strings.Repeat("日本語のテキスト", 300)
+	chunks := splitPrompt(text, 500, 50, count)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if count(chunk.Text) > 500 {
+			t.Errorf("Chunk %d has %d tokens", i, count(chunk.Text))
+		}
+		if !strings.HasPrefix(text[chunk.Start:], chunk.Text) || !utf8.ValidString(chunk.Text) {
+			t.Errorf("Chunk %d splits a character", i)
+		}
+	}
+	if chunks[len(chunks)-1].End != len(text) {
+		t.Error("Expected the chunks to reach the end of the text")
+	}
+}
@@ -1,18 +1,62 @@
 // Package llm provides intelligent LLM routing and budget management for the AI Work Studio.
 //
-// This package implements two main components:
+// This package implements three main components:
 //
 // 1. Router: Intelligent task assessment and model selection
 //    - Analyzes task complexity, token requirements, and quality needs
 //    - Selects the most cost-effective model meeting requirements
-//    - Learns from historical performance to improve routing decisions
+//    - Learns from historical performance to improve routing decisions, and
+//      persists it through a PerformanceStore so it survives restarts
 //    - Provides cost estimation before execution
 //
 // 2. BudgetManager: Comprehensive budget tracking and alerts
 //    - Tracks spending across daily, weekly, and monthly periods
 //    - Triggers alerts at configurable thresholds (75%, 90%, 100%)
 //    - Provides detailed ROI analysis for different providers and models
-//    - Enforces budget limits with optional grace periods
+//    - Enforces budget limits strictly, with a grace past them for execution
+//      plans already running, or by warning only
+//
+// 3. ExchangeLogger: Sampled records of LLM exchanges for debugging
+//    - Always records failures, refusals and costly exchanges, samples the rest
+//    - Redacts secrets, then keeps the head and tail of prompts and responses
+//    - Writes one file per day, with a daily size budget and retention
+//
+// TaskRequest and RoutingResult encode to a versioned JSON wire format, so
+// they can be persisted or passed between processes: enums are written by
+// name, metadata values carry their type, and records written by the older
+// unversioned encoding are converted when decoded.
+//
+// The candidate models come from the LLM service's list_models operation, so
+// costs and context sizes follow the providers' ModelConfig. The listing is
+// cached for RouterConfig.ModelCacheTTL, and a service that cannot list its
+// models is taken to offer the built-in default set.
+//
+// Prompt tokens are counted per candidate model by a TokenEstimator: exactly,
+// with a BPE vocabulary loaded lazily from a local directory, when one exists
+// for the model, and with a character heuristic otherwise. Each
+// recommendation records which tokenizer its estimate came from.
+//
+// Completions that decline the request on content-policy grounds are
+// detected from the provider's finish reason or a short list of refusal
+// openings. The router retries a refused request once with a framing that
+// states its legitimate purpose, then falls back to another provider; a
+// request marked Sensitive, or matching the sensitive-topic patterns, keeps
+// its refusal. Each model's refusal rate lowers its score.
+//
+// When a model fails because its provider is down, rate limited or
+// unreachable, the router tries the next recommendation, up to
+// RouterConfig.MaxFallbacks more, and records the failure so the model's
+// failure rate lowers its score. RoutingResult.Failures says why each tried
+// model failed. A budget failure only falls back to cheaper models, and a
+// rejected request is returned without trying another.
+//
+// A prompt too long for every model fails to route unless the request sets
+// AllowDecomposition. Then it is split into overlapping chunks, broken at
+// paragraphs where possible and sized to the model with the largest context;
+// each chunk is routed on its own and a final call combines their answers.
+// The result adds up the tokens and cost of every call and lists them in
+// RoutingResult.Chunks. A BudgetConstraint covers the calls together, and the
+// combining call is not made once the budget left cannot pay for it.
 //
 // The router uses a multi-factor scoring algorithm that balances:
 //   - Quality requirements vs model capabilities
@@ -54,6 +98,15 @@
 //	}
 //	err = budgetManager.RecordUsage(ctx, transaction)
 //
+//	// Or let the router do both: it skips models the budget cannot afford,
+//	// returns ErrBudgetExceeded when none fits, and records each completion
+//	router.SetBudgetManager(budgetManager)
+//
+//	// Hold a multi-turn exchange; each message is sent with the turns before it
+//	chat := llm.NewConversation(router, llm.TaskRequest{TaskType: "chat", MaxTokens: 1000}, 0)
+//	result, err = chat.Send(ctx, "My name is Robin")
+//	result, err = chat.Send(ctx, "What is my name?")
+//
 // The package is designed to work seamlessly with the existing MCP LLM service
 // while providing enhanced routing intelligence and budget controls.
 package llm
\ No newline at end of file
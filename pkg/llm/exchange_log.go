@@ -0,0 +1,455 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/jsonlog"
+)
+
+// ExchangeLogConfig controls which LLM exchanges are recorded and how much of them.
+type ExchangeLogConfig struct {
+	// PromptCap is the number of prompt bytes kept, split between head and tail
+	PromptCap int
+
+	// ResponseCap is the number of response bytes kept, split between head and tail
+	ResponseCap int
+
+	// SampleRate is the fraction (0-1) of successful exchanges below
+	// AlwaysLogCost that are recorded
+	SampleRate float64
+
+	// AlwaysLogCost is the cost at or above which an exchange is always recorded
+	AlwaysLogCost float64
+
+	// DailyBudgetBytes caps a day's log; past it only metadata is recorded
+	DailyBudgetBytes int64
+
+	// RetentionDays is how many days of logs are kept
+	RetentionDays int
+
+	// Redact removes secrets from prompts, responses and errors before they
+	// are written (defaults to RedactSecrets)
+	Redact func(string) string
+
+	// Random is the sampling source, returning values in [0, 1)
+	// (defaults to math/rand)
+	Random func() float64
+
+	// Clock stamps records, times streams and decides which day's file a
+	// record goes in (default: the system clock)
+	Clock utils.Clock
+}
+
+// DefaultExchangeLogConfig returns sensible defaults for exchange logging.
+func DefaultExchangeLogConfig() ExchangeLogConfig {
+	return ExchangeLogConfig{
+		PromptCap:        2000,
+		ResponseCap:      2000,
+		SampleRate:       0.10,             // 10% of routine exchanges
+		AlwaysLogCost:    0.05,             // $0.05 and up is always logged
+		DailyBudgetBytes: 10 * 1024 * 1024, // 10MB of text per day
+		RetentionDays:    14,
+	}
+}
+
+// Exchange is one LLM request and its outcome, as handed to the exchange logger.
+type Exchange struct {
+	Provider   string
+	Model      string
+	TaskType   string
+	Prompt     string
+	Response   string
+	TokensUsed int
+	Cost       float64
+	Latency    time.Duration
+	Err        error
+
+	// Streamed marks a response assembled from streamed chunks
+	Streamed bool
+
+	// Refusal is why the response was classified as a content-policy
+	// refusal, empty if it was not
+	Refusal string
+
+	// Rephrased marks the rephrased retry of a refused request
+	Rephrased bool
+}
+
+// ExchangeRecord is the logged form of an exchange.
+type ExchangeRecord struct {
+	ID            string    `json:"id"`
+	Fingerprint   string    `json:"fingerprint"`
+	Timestamp     time.Time `json:"timestamp"`
+	Provider      string    `json:"provider"`
+	Model         string    `json:"model"`
+	TaskType      string    `json:"task_type,omitempty"`
+	Prompt        string    `json:"prompt,omitempty"`
+	PromptBytes   int       `json:"prompt_bytes"`
+	Response      string    `json:"response,omitempty"`
+	ResponseBytes int       `json:"response_bytes"`
+	TokensUsed    int       `json:"tokens_used"`
+	Cost          float64   `json:"cost"`
+	LatencyMs     int64     `json:"latency_ms"`
+	Success       bool      `json:"success"`
+	Error         string    `json:"error,omitempty"`
+	Streamed      bool      `json:"streamed,omitempty"`
+	Refusal       string    `json:"refusal,omitempty"`
+	Rephrased     bool      `json:"rephrased,omitempty"`
+
+	// LoggedBecause is "failure", "refusal", "cost" or "sample"
+	LoggedBecause string `json:"logged_because"`
+
+	// TextOmitted explains why prompt and response text were left out
+	TextOmitted string `json:"text_omitted,omitempty"`
+}
+
+// ExchangeLogger writes sampled, truncated and redacted LLM exchanges to one
+// JSON-lines file per day, so they can be inspected without the prompts and
+// responses swamping other logs.
+type ExchangeLogger struct {
+	files    jsonlog.Daily
+	config   ExchangeLogConfig
+	logger   *log.Logger
+	mu       sync.Mutex
+	prunedOn string
+	sequence int
+}
+
+// NewExchangeLogger creates an exchange logger writing under dataPath.
+func NewExchangeLogger(dataPath string, config ExchangeLogConfig, logger *log.Logger) (*ExchangeLogger, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[ExchangeLogger] ", log.LstdFlags)
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("sample rate must be between 0 and 1, got %.2f", config.SampleRate)
+	}
+	if config.PromptCap < 0 || config.ResponseCap < 0 {
+		return nil, fmt.Errorf("text caps cannot be negative")
+	}
+	if config.Redact == nil {
+		config.Redact = RedactSecrets
+	}
+	if config.Random == nil {
+		config.Random = rand.Float64
+	}
+	config.Clock = utils.ClockOrReal(config.Clock)
+
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create exchange log directory: %w", err)
+	}
+
+	return &ExchangeLogger{
+		files:  jsonlog.Daily{Dir: dataPath, Prefix: "exchanges-", Suffix: ".jsonl"},
+		config: config,
+		logger: logger,
+	}, nil
+}
+
+// Log records an exchange if it is selected: failures, refusals and
+// exchanges costing at least AlwaysLogCost always are, others at the sample
+// rate. Returns the
+// written record, or nil if the exchange was not selected.
+func (l *ExchangeLogger) Log(exchange Exchange) (*ExchangeRecord, error) {
+	reason := l.selectExchange(exchange)
+	if reason == "" {
+		return nil, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.config.Clock.Now()
+	l.sequence++
+	fingerprint := RequestFingerprint(exchange.TaskType, exchange.Prompt)
+	record := &ExchangeRecord{
+		ID:            fmt.Sprintf("%s-%d-%d", fingerprint[:8], now.UnixNano(), l.sequence),
+		Fingerprint:   fingerprint,
+		Timestamp:     now,
+		Provider:      exchange.Provider,
+		Model:         exchange.Model,
+		TaskType:      exchange.TaskType,
+		PromptBytes:   len(exchange.Prompt),
+		ResponseBytes: len(exchange.Response),
+		TokensUsed:    exchange.TokensUsed,
+		Cost:          exchange.Cost,
+		LatencyMs:     exchange.Latency.Milliseconds(),
+		Success:       exchange.Err == nil,
+		Streamed:      exchange.Streamed,
+		Refusal:       exchange.Refusal,
+		Rephrased:     exchange.Rephrased,
+		LoggedBecause: reason,
+	}
+	if exchange.Err != nil {
+		record.Error = l.config.Redact(exchange.Err.Error())
+	}
+
+	// Redact before truncating, so a secret is never cut into an unrecognizable piece
+	record.Prompt = truncateHeadTail(l.config.Redact(exchange.Prompt), l.config.PromptCap)
+	record.Response = truncateHeadTail(l.config.Redact(exchange.Response), l.config.ResponseCap)
+
+	if err := l.write(record, now); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// selectExchange decides whether an exchange is logged and why. The sampling
+// source is only consulted for exchanges that are not always logged.
+func (l *ExchangeLogger) selectExchange(exchange Exchange) string {
+	switch {
+	case exchange.Err != nil:
+		return "failure"
+	case exchange.Refusal != "" || exchange.Rephrased:
+		return "refusal"
+	case l.config.AlwaysLogCost > 0 && exchange.Cost >= l.config.AlwaysLogCost:
+		return "cost"
+	case l.config.SampleRate >= 1:
+		return "sample"
+	case l.config.SampleRate > 0 && l.config.Random() < l.config.SampleRate:
+		return "sample"
+	default:
+		return ""
+	}
+}
+
+// write appends the record to the day's file, dropping its text once the
+// day's budget is spent. Callers hold the lock.
+func (l *ExchangeLogger) write(record *ExchangeRecord, now time.Time) error {
+	day := jsonlog.Day(now)
+	if l.prunedOn != day {
+		if _, err := l.prune(now); err != nil {
+			l.logger.Printf("Warning: %v", err)
+		}
+		l.prunedOn = day
+	}
+
+	file, err := l.files.Open(day)
+	if err != nil {
+		return fmt.Errorf("failed to open exchange log: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat exchange log: %w", err)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exchange record: %w", err)
+	}
+	if l.config.DailyBudgetBytes > 0 && info.Size()+int64(len(line))+1 > l.config.DailyBudgetBytes &&
+		(record.Prompt != "" || record.Response != "") {
+		record.Prompt = ""
+		record.Response = ""
+		record.TextOmitted = "daily log budget reached"
+		if line, err = json.Marshal(record); err != nil {
+			return fmt.Errorf("failed to marshal exchange record: %w", err)
+		}
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write exchange record: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes the logs of days outside the retention period and returns how
+// many files were removed.
+func (l *ExchangeLogger) Prune() (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.prune(l.config.Clock.Now())
+}
+
+func (l *ExchangeLogger) prune(now time.Time) (int, error) {
+	if l.config.RetentionDays <= 0 {
+		return 0, nil
+	}
+	removed, err := l.files.Prune(now, l.config.RetentionDays)
+	if err != nil {
+		return removed, fmt.Errorf("failed to prune exchange logs: %w", err)
+	}
+	return removed, nil
+}
+
+// Tail returns the most recent records, oldest first.
+func (l *ExchangeLogger) Tail(limit int) ([]*ExchangeRecord, error) {
+	days, err := l.days()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*ExchangeRecord
+	for i := len(days) - 1; i >= 0 && len(records) < limit; i-- {
+		dayRecords, err := l.readDay(days[i])
+		if err != nil {
+			return nil, err
+		}
+		records = append(dayRecords, records...)
+	}
+	if len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records, nil
+}
+
+// Find returns the records whose fingerprint starts with the given prefix,
+// oldest first.
+func (l *ExchangeLogger) Find(fingerprint string) ([]*ExchangeRecord, error) {
+	if len(fingerprint) < 4 {
+		return nil, fmt.Errorf("fingerprint prefix must be at least 4 characters")
+	}
+	days, err := l.days()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*ExchangeRecord
+	for _, day := range days {
+		records, err := l.readDay(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if strings.HasPrefix(record.Fingerprint, fingerprint) {
+				matches = append(matches, record)
+			}
+		}
+	}
+	return matches, nil
+}
+
+// days lists the days that have a log file, oldest first.
+func (l *ExchangeLogger) days() ([]string, error) {
+	days, err := l.files.Days()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exchange logs: %w", err)
+	}
+	return days, nil
+}
+
+// readDay reads one day's records, skipping lines that do not parse.
+func (l *ExchangeLogger) readDay(day string) ([]*ExchangeRecord, error) {
+	var records []*ExchangeRecord
+	if err := jsonlog.ReadFile(l.files.Path(day), func(record ExchangeRecord) {
+		records = append(records, &record)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read exchange log for %s: %w", day, err)
+	}
+	return records, nil
+}
+
+// ExchangeStream assembles a streamed response so the exchange is logged once
+// when it completes, rather than per chunk.
+type ExchangeStream struct {
+	logger   *ExchangeLogger
+	exchange Exchange
+	started  time.Time
+	text     strings.Builder
+}
+
+// BeginStream starts capturing a streamed exchange.
+func (l *ExchangeLogger) BeginStream(provider, model, taskType, prompt string) *ExchangeStream {
+	return &ExchangeStream{
+		logger: l,
+		exchange: Exchange{
+			Provider: provider,
+			Model:    model,
+			TaskType: taskType,
+			Prompt:   prompt,
+			Streamed: true,
+		},
+		started: l.config.Clock.Now(),
+	}
+}
+
+// Write appends a chunk of the streamed response.
+func (s *ExchangeStream) Write(chunk []byte) (int, error) {
+	return s.text.Write(chunk)
+}
+
+// Finish logs the exchange with the assembled response.
+func (s *ExchangeStream) Finish(tokensUsed int, cost float64, err error) (*ExchangeRecord, error) {
+	s.exchange.Response = s.text.String()
+	s.exchange.TokensUsed = tokensUsed
+	s.exchange.Cost = cost
+	s.exchange.Err = err
+	s.exchange.Latency = s.logger.config.Clock.Since(s.started)
+	return s.logger.Log(s.exchange)
+}
+
+// RequestFingerprint identifies a request by its task type and prompt, so the
+// attempts at one request on different models share a fingerprint.
+func RequestFingerprint(taskType, prompt string) string {
+	sum := sha256.Sum256([]byte(taskType + "\x00" + prompt))
+	return hex.EncodeToString(sum[:8])
+}
+
+// truncateHeadTail keeps at most limit bytes of text, taken from its head and
+// tail in equal parts and cut on character boundaries, with a marker noting
+// how much was omitted between them.
+func truncateHeadTail(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+
+	headEnd := limit - limit/2
+	for headEnd > 0 && !utf8.RuneStart(text[headEnd]) {
+		headEnd--
+	}
+	tailStart := len(text) - limit/2
+	for tailStart < len(text) && !utf8.RuneStart(text[tailStart]) {
+		tailStart++
+	}
+
+	return fmt.Sprintf("%s\n…[%d bytes omitted]…\n%s", text[:headEnd], tailStart-headEnd, text[tailStart:])
+}
+
+// IsSecretName reports whether a field name, such as "api_key" or
+// "password", suggests its value is a credential.
+func IsSecretName(name string) bool {
+	return secretName.MatchString(name)
+}
+
+// secretPatterns match credentials that must never reach the exchange log.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{20,}`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`),
+}
+
+// secretAssignment matches values assigned to secret-sounding names, such as
+// "password: hunter2" or "api_key=abc".
+var secretAssignment = regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token|token)(\s*[:=]\s*)("[^"]*"|'[^']*'|\S+)`)
+
+// secretName matches field names whose values are credentials, including
+// prefixed ones such as "github_token".
+var secretName = regexp.MustCompile(`(?i)(^|[_.-])(password|passwd|pwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token|token|private[_-]?key)$`)
+
+// RedactSecrets replaces API keys, tokens, private keys and assigned
+// passwords in text with [REDACTED].
+func RedactSecrets(text string) string {
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, "[REDACTED]")
+	}
+	return secretAssignment.ReplaceAllString(text, "${1}${2}[REDACTED]")
+}
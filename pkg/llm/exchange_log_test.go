@@ -0,0 +1,248 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// sequenceRandom returns the given values in turn, for deterministic sampling.
+func sequenceRandom(values ...float64) func() float64 {
+	i := 0
+	return func() float64 {
+		value := values[i%len(values)]
+		i++
+		return value
+	}
+}
+
+func newTestExchangeLogger(t *testing.T, config ExchangeLogConfig) *ExchangeLogger {
+	t.Helper()
+	logger, err := NewExchangeLogger(t.TempDir(), config, testLogger())
+	if err != nil {
+		t.Fatalf("NewExchangeLogger failed: %v", err)
+	}
+	return logger
+}
+
+func TestTruncateHeadTail(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{strings.Repeat("a", 50) + strings.Repeat("b", 50), 10, "aaaaa\n…[90 bytes omitted]…\nbbbbb"},
+		{"abcdefghijk", 5, "abc\n…[6 bytes omitted]…\njk"}, // odd caps favour the head
+		{"anything", 0, ""},
+		// Multi-byte characters are never split: each é is two bytes
+		{"éééééééééé", 5, "é\n…[16 bytes omitted]…\né"},
+	}
+	for _, tt := range tests {
+		if got := truncateHeadTail(tt.text, tt.limit); got != tt.want {
+			t.Errorf("truncateHeadTail(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestExchangeLogger_Sampling(t *testing.T) {
+	config := DefaultExchangeLogConfig()
+	config.SampleRate = 0.5
+	config.AlwaysLogCost = 0.10
+	config.Random = sequenceRandom(0.2, 0.7, 0.49, 0.5)
+	logger := newTestExchangeLogger(t, config)
+
+	var logged []bool
+	for i := 0; i < 4; i++ {
+		record, err := logger.Log(Exchange{Provider: "anthropic", Model: "claude-3-haiku", Prompt: fmt.Sprintf("prompt %d", i), Cost: 0.01})
+		if err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		logged = append(logged, record != nil)
+	}
+	if fmt.Sprint(logged) != "[true false true false]" {
+		t.Errorf("Expected sampling to follow the random source, got %v", logged)
+	}
+
+	// Failures and costly exchanges are logged without consulting the random source
+	config.SampleRate = 0
+	config.Random = func() float64 {
+		t.Fatal("The random source should not be consulted")
+		return 0
+	}
+	logger = newTestExchangeLogger(t, config)
+
+	record, err := logger.Log(Exchange{Provider: "openai", Prompt: "p", Err: fmt.Errorf("rate limited")})
+	if err != nil || record == nil || record.LoggedBecause != "failure" || record.Success || record.Error != "rate limited" {
+		t.Errorf("Expected a failure to always be logged, got %+v, %v", record, err)
+	}
+	record, _ = logger.Log(Exchange{Provider: "openai", Prompt: "p", Cost: 0.10})
+	if record == nil || record.LoggedBecause != "cost" {
+		t.Errorf("Expected an exchange at the cost threshold to be logged, got %+v", record)
+	}
+	if record, _ := logger.Log(Exchange{Provider: "openai", Prompt: "p", Cost: 0.09}); record != nil {
+		t.Error("Expected a cheap exchange not to be logged at a zero sample rate")
+	}
+}
+
+func TestExchangeLogger_Redaction(t *testing.T) {
+	config := DefaultExchangeLogConfig()
+	config.SampleRate = 1
+	logger := newTestExchangeLogger(t, config)
+
+	secret := "sk-ant-REDACTED"
+	prompt := strings.Repeat("context ", 400) + "use key " + secret + " and password: hunter2 to log in"
+	if _, err := logger.Log(Exchange{
+		Provider: "anthropic",
+		Prompt:   prompt,
+		Response: "Done with " + secret,
+		Err:      fmt.Errorf("auth failed for Bearer abc.def.ghi"),
+	}); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+
+	written := readExchangeFiles(t, logger)
+	for _, planted := range []string{"PLANTEDsecret", "hunter2", "abc.def.ghi"} {
+		if strings.Contains(written, planted) {
+			t.Errorf("Expected %q to be redacted from the written record", planted)
+		}
+	}
+	if !strings.Contains(written, "[REDACTED]") || !strings.Contains(written, "password: [REDACTED]") {
+		t.Errorf("Expected redaction markers in the written record")
+	}
+}
+
+func TestIsSecretName(t *testing.T) {
+	for name, secret := range map[string]bool{
+		"password": true, "API_KEY": true, "apiKey": true, "github_token": true, "client_secret": true,
+		"host": false, "token_count": false, "secretary": false,
+	} {
+		if IsSecretName(name) != secret {
+			t.Errorf("IsSecretName(%q): expected %v", name, secret)
+		}
+	}
+}
+
+func TestExchangeLogger_DailyBudget(t *testing.T) {
+	config := DefaultExchangeLogConfig()
+	config.SampleRate = 1
+	config.DailyBudgetBytes = 1500
+	logger := newTestExchangeLogger(t, config)
+
+	var records []*ExchangeRecord
+	for i := 0; i < 3; i++ {
+		record, err := logger.Log(Exchange{Provider: "local", Prompt: strings.Repeat("x", 400), Response: strings.Repeat("y", 400)})
+		if err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	if records[0].TextOmitted != "" || records[0].Prompt == "" {
+		t.Error("Expected the first record to keep its text")
+	}
+	last := records[2]
+	if last.TextOmitted == "" || last.Prompt != "" || last.Response != "" || last.PromptBytes != 400 {
+		t.Errorf("Expected only metadata once the budget is spent, got %+v", last)
+	}
+}
+
+func TestExchangeLogger_StreamTailFindAndPrune(t *testing.T) {
+	config := DefaultExchangeLogConfig()
+	config.SampleRate = 1
+	config.RetentionDays = 7
+	clock := utils.NewFakeClock(time.Now())
+	config.Clock = clock
+	logger := newTestExchangeLogger(t, config)
+
+	stream := logger.BeginStream("anthropic", "claude-3-haiku", "qa", "What is Go?")
+	for _, chunk := range []string{"Go is ", "a programming ", "language."} {
+		fmt.Fprint(stream, chunk)
+	}
+	clock.Advance(1500 * time.Millisecond)
+	record, err := stream.Finish(42, 0.001, nil)
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if record.Response != "Go is a programming language." || !record.Streamed || record.TokensUsed != 42 {
+		t.Errorf("Expected one record with the assembled response, got %+v", record)
+	}
+	// The stream is timed and stamped by the logger's clock
+	if record.LatencyMs != 1500 || !record.Timestamp.Equal(clock.Now()) {
+		t.Errorf("Expected the stream timed by the clock, got %dms at %v", record.LatencyMs, record.Timestamp)
+	}
+	logger.Log(Exchange{Provider: "openai", TaskType: "qa", Prompt: "What is Go?"})
+	logger.Log(Exchange{Provider: "openai", TaskType: "qa", Prompt: "Something else"})
+
+	tail, err := logger.Tail(2)
+	if err != nil || len(tail) != 2 || tail[1].Prompt != "Something else" {
+		t.Errorf("Expected the last 2 records oldest first, got %d, %v", len(tail), err)
+	}
+	found, err := logger.Find(record.Fingerprint[:6])
+	if err != nil || len(found) != 2 {
+		t.Errorf("Expected both attempts at the same request, got %d, %v", len(found), err)
+	}
+
+	// Logs older than the retention period are removed on the first write of a day
+	oldFile := logger.files.Path(time.Now().AddDate(0, 0, -8).Format("2006-01-02"))
+	if err := os.WriteFile(oldFile, []byte("{}\n"), 0600); err != nil {
+		t.Fatalf("Failed to plant old log: %v", err)
+	}
+	keptFile := logger.files.Path(time.Now().AddDate(0, 0, -6).Format("2006-01-02"))
+	if err := os.WriteFile(keptFile, []byte("{}\n"), 0600); err != nil {
+		t.Fatalf("Failed to plant recent log: %v", err)
+	}
+	if removed, err := logger.Prune(); err != nil || removed != 1 {
+		t.Errorf("Expected 1 log to be pruned, got %d, %v", removed, err)
+	}
+	if _, err := os.Stat(keptFile); err != nil {
+		t.Error("Expected logs within the retention period to be kept")
+	}
+}
+
+func TestRouter_LogsExchanges(t *testing.T) {
+	service := NewMockLLMService()
+	router := NewRouter(service)
+	config := DefaultExchangeLogConfig()
+	config.SampleRate = 0
+	logger := newTestExchangeLogger(t, config)
+	router.SetExchangeLogger(logger)
+
+	// The preferred model fails first, so the failure is logged before the fallback succeeds
+	req := TaskRequest{Prompt: "Summarize this", TaskType: "qa", QualityRequired: QualityBasic, MaxTokens: 100}
+	first := router.scoreModels(router.getAvailableModels(), router.assessTask(req), req)[0]
+	service.SetError("complete", first.Provider, first.Model, fmt.Errorf("overloaded"))
+
+	if _, err := router.Route(context.Background(), req); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	records, err := logger.Tail(10)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Success || records[0].Model != first.Model || records[0].TaskType != "qa" {
+		t.Errorf("Expected only the failed attempt to be logged at a zero sample rate, got %+v", records)
+	}
+}
+
+// readExchangeFiles returns the raw contents of every log file.
+func readExchangeFiles(t *testing.T, logger *ExchangeLogger) string {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(logger.files.Dir, "*"))
+	var b strings.Builder
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		b.Write(data)
+	}
+	return b.String()
+}
@@ -0,0 +1,262 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// failingService fails every completion request to the providers it has an
+// error for and answers the rest. It cannot list its models.
+type failingService struct {
+	failures map[string]error
+	calls    []string
+}
+
+func (s *failingService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	if params["operation"] != "complete" {
+		return mcp.ErrorResult(errors.New("unsupported operation"))
+	}
+	provider, _ := params["provider"].(string)
+	model, _ := params["model"].(string)
+	s.calls = append(s.calls, provider+"/"+model)
+
+	if err := s.failures[provider]; err != nil {
+		return mcp.ErrorResult(err)
+	}
+	return mcp.SuccessResult(&mcp.CompletionResponse{Text: "Done.", TokensUsed: 20, Model: model, Provider: provider})
+}
+
+func fallbackRequest() TaskRequest {
+	return TaskRequest{Prompt: "Summarize the quarterly report", TaskType: "summarization", QualityRequired: QualityStandard, MaxTokens: 500}
+}
+
+func TestRoute_FallsBackWhenProviderFails(t *testing.T) {
+	// Find the preferred provider, then take it down
+	preferred := NewRouter(&failingService{}).RankModels("summarization")[0].Provider
+	service := &failingService{failures: map[string]error{
+		preferred: errs.Newf(errs.ProviderUnavailable, "%s is overloaded", preferred),
+	}}
+	config := DefaultRouterConfig()
+	config.MaxFallbacks = len(staticModels())
+	router := NewRouter(service, config)
+
+	before := router.RankModels("summarization")[0]
+	result, err := router.Route(context.Background(), fallbackRequest())
+	if err != nil {
+		t.Fatalf("Expected routing to fall back to another provider: %v", err)
+	}
+	if result.SelectedModel.Provider == preferred {
+		t.Fatalf("Expected a provider other than %s, got %s", preferred, result.SelectedModel.Provider)
+	}
+	if len(result.Failures) == 0 || len(result.Failures) != len(result.FailedModels) {
+		t.Fatalf("Expected a failure reported for each failed model, got %+v", result.Failures)
+	}
+	for _, failure := range result.Failures {
+		if failure.Model.Provider != preferred || failure.Code != errs.ProviderUnavailable || failure.Reason == "" {
+			t.Errorf("Expected only %s reported as unavailable, got %+v", preferred, failure)
+		}
+	}
+	if len(service.calls) != len(result.Failures)+1 {
+		t.Errorf("Expected each failed model to be tried once, got calls %v", service.calls)
+	}
+
+	// The failures count against the models, so the flaky one ranks lower
+	stats := router.GetPerformanceStats()
+	perf := stats[performanceKey(before.Provider, before.Model, "summarization")]
+	if perf == nil || perf.SampleCount != 1 || perf.SuccessRate != 0 {
+		t.Fatalf("Expected the failure to be recorded, got %+v", perf)
+	}
+	for _, model := range router.RankModels("summarization") {
+		if model.Provider == before.Provider && model.Model == before.Model && model.OverallScore >= before.OverallScore {
+			t.Errorf("Expected %s to score lower after failing, got %.3f from %.3f", model.Model, model.OverallScore, before.OverallScore)
+		}
+	}
+}
+
+func TestRoute_ReturnsFailuresThatAreNotTheProvidersFault(t *testing.T) {
+	service := &failingService{failures: map[string]error{
+		"anthropic": errs.New(errs.Validation, "prompt is too long"),
+		"openai":    errs.New(errs.Validation, "prompt is too long"),
+	}}
+	router := NewRouter(service)
+
+	_, err := router.Route(context.Background(), fallbackRequest())
+	if errs.CodeOf(err) != errs.Validation {
+		t.Fatalf("Expected the rejected request to be returned, got %v", err)
+	}
+	if len(service.calls) != 1 {
+		t.Errorf("Expected no fallback for a rejected request, got calls %v", service.calls)
+	}
+	if len(router.GetPerformanceStats()) != 0 {
+		t.Error("Expected a rejected request not to count against the model")
+	}
+}
+
+func TestRoute_BudgetFailureOnlyFallsBackToCheaperModels(t *testing.T) {
+	exhausted := errs.New(errs.BudgetExceeded, "daily budget limit of $1.00 exceeded")
+	service := &failingService{failures: map[string]error{"anthropic": exhausted, "openai": exhausted, "local": exhausted}}
+	config := DefaultRouterConfig()
+	config.MaxFallbacks = len(staticModels())
+	router := NewRouter(service, config)
+
+	_, err := router.Route(context.Background(), fallbackRequest())
+	if errs.CodeOf(err) != errs.BudgetExceeded {
+		t.Fatalf("Expected the budget failure to be returned, got %v", err)
+	}
+
+	// Every model tried after the first was cheaper than the one before it
+	var tried []ModelRecommendation
+	for _, call := range service.calls {
+		for _, model := range router.RankModels("summarization") {
+			if model.Provider+"/"+model.Model == call {
+				tried = append(tried, model)
+			}
+		}
+	}
+	if len(tried) != len(service.calls) {
+		t.Fatalf("Expected every call to be a ranked model, got %v", service.calls)
+	}
+	for i := 1; i < len(tried); i++ {
+		if tried[i].EstimatedCost >= tried[i-1].EstimatedCost {
+			t.Errorf("Expected %s to cost less than %s", tried[i].Model, tried[i-1].Model)
+		}
+	}
+	if len(router.GetPerformanceStats()) != 0 {
+		t.Error("Expected a budget failure not to count against the model")
+	}
+}
+
+func TestRoute_BudgetFailureExcludesPricierModels(t *testing.T) {
+	req := fallbackRequest()
+	ranked := NewRouter(&failingService{}).RankModels(req.TaskType)
+	top := ranked[0]
+	service := &failingService{failures: map[string]error{
+		top.Provider: errs.New(errs.BudgetExceeded, "daily budget limit of $1.00 exceeded"),
+	}}
+	config := DefaultRouterConfig()
+	config.MaxFallbacks = len(staticModels())
+	router := NewRouter(service, config)
+
+	result, err := router.Route(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Expected a cheaper model to take over: %v", err)
+	}
+	if result.SelectedModel.EstimatedCost >= top.EstimatedCost {
+		t.Errorf("Expected a model cheaper than %s, got %s", top.Model, result.SelectedModel.Model)
+	}
+	if len(result.Failures) == 0 || result.Failures[0].Code != errs.BudgetExceeded {
+		t.Errorf("Expected the budget failure to be reported, got %+v", result.Failures)
+	}
+	for _, exclusion := range result.ExcludedModels {
+		if exclusion.Reason != ExclusionOverBudget {
+			t.Errorf("Expected only budget exclusions, got %+v", exclusion)
+		}
+		if exclusion.Model.EstimatedCost < top.EstimatedCost {
+			t.Errorf("Expected %s, cheaper than %s, to be tried", exclusion.Model.Model, top.Model)
+		}
+	}
+}
+
+// timeoutService times out every completion request, keeping the parameters
+// it was given. It cannot list its models.
+type timeoutService struct {
+	params []mcp.ServiceParams
+}
+
+func (s *timeoutService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	if params["operation"] != "complete" {
+		return mcp.ErrorResult(errors.New("unsupported operation"))
+	}
+	s.params = append(s.params, params)
+	provider, _ := params["provider"].(string)
+	model, _ := params["model"].(string)
+	return mcp.ErrorResult(&mcp.TimeoutError{Provider: provider, Model: model, Timeout: 10 * time.Second, Elapsed: 10 * time.Second})
+}
+
+func TestRoute_TimeoutStopsTheRoute(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+	service := &timeoutService{}
+	config := DefaultRouterConfig()
+	config.MaxFallbacks = len(staticModels())
+	router := NewRouter(service, config)
+	budget := budgetWithRemaining(t, clock, 0.5)
+	router.SetBudgetManager(budget)
+
+	req := fallbackRequest()
+	req.Timeout = 10 * time.Second
+	req.HardMaxTokens = 200
+	req.RetryOnTimeout = true
+	_, err := router.Route(context.Background(), req)
+	if !errors.Is(err, mcp.ErrTimeout) || errs.CodeOf(err) != errs.Timeout {
+		t.Fatalf("Expected the timeout to be returned, got %v", err)
+	}
+	if len(service.params) != 1 {
+		t.Fatalf("Expected no fallback after a timeout, got %d calls", len(service.params))
+	}
+
+	// The request's limits reach the service
+	params := service.params[0]
+	if params["timeout_seconds"] != 10.0 || params["hard_max_tokens"] != 200 || params["retry_on_timeout"] != true {
+		t.Errorf("Expected the request's deadline and token cap passed through, got %v", params)
+	}
+
+	// The attempt is recorded for its time, marked as a timeout
+	transactions := budget.GetTransactions()
+	last := transactions[len(transactions)-1]
+	if last.Success || last.Cost != 0 || last.Tags["outcome"] != "timeout" || last.Provider == "" {
+		t.Errorf("Expected the timed-out attempt recorded, got %+v", last)
+	}
+}
+
+// retriedService answers every completion request after two failed attempts,
+// the first of which the provider billed for.
+type retriedService struct{}
+
+func (retriedService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	provider, _ := params["provider"].(string)
+	model, _ := params["model"].(string)
+	result := mcp.SuccessResult(&mcp.CompletionResponse{Text: "Done.", TokensUsed: 20, Cost: 0.002, Model: model, Provider: provider})
+	result.Metadata["failed_attempts"] = []mcp.FailedAttempt{
+		{Attempt: 1, Provider: provider, Model: model, TokensUsed: 1200, Cost: 0.01, LatencyMs: 300, Error: "overloaded"},
+		{Attempt: 2, Provider: provider, Model: model, LatencyMs: 100, Error: "connection reset"},
+	}
+	return result
+}
+
+func TestRoute_RecordsFailedAttempts(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+	router := NewRouter(retriedService{})
+	budget := budgetWithRemaining(t, clock, 0.5)
+	router.SetBudgetManager(budget)
+	before := len(budget.GetTransactions())
+
+	result, err := router.Route(context.Background(), fallbackRequest())
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+
+	// The billed attempt is recorded as wasted spend, before the completion
+	transactions := budget.GetTransactions()[before:]
+	if len(transactions) != 2 {
+		t.Fatalf("Expected the billed attempt and the completion recorded, got %+v", transactions)
+	}
+	failed := transactions[0]
+	if failed.Success || failed.Cost != 0.01 || failed.TokensUsed != 1200 || failed.Tags["attempt"] != "1" || failed.Model != result.SelectedModel.Model {
+		t.Errorf("Expected the failed attempt recorded with its usage, got %+v", failed)
+	}
+	if !transactions[1].Success || transactions[1].Cost != 0.002 {
+		t.Errorf("Expected the completion recorded, got %+v", transactions[1])
+	}
+
+	// Both retried attempts count against the model
+	perf := router.GetPerformanceStats()[performanceKey(result.SelectedModel.Provider, result.SelectedModel.Model, "summarization")]
+	if perf == nil || perf.SampleCount < 2 || perf.SuccessRate >= 0.5 {
+		t.Errorf("Expected the failed attempts in the model's success rate, got %+v", perf)
+	}
+}
@@ -2,12 +2,21 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/metrics"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 // LLMServiceInterface defines the interface needed by the router.
@@ -15,6 +24,10 @@ type LLMServiceInterface interface {
 	Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult
 }
 
+// driftLearnedWeight is how much learned quality counts in a drifting model's
+// score, against 0.5 normally.
+const driftLearnedWeight = 0.25
+
 // TaskComplexity represents the complexity level of a task.
 type TaskComplexity int
 
@@ -44,9 +57,35 @@ type TaskRequest struct {
 	// Prompt is the text to be processed
 	Prompt string
 
+	// Messages carries a conversation, oldest turn first, ending with the
+	// turn to answer. When set it is sent instead of Prompt
+	Messages []mcp.Message
+
 	// MaxTokens is the maximum number of tokens to generate
 	MaxTokens int
 
+	// HardMaxTokens caps generation below MaxTokens and any model default
+	// (0: no cap)
+	HardMaxTokens int
+
+	// Timeout bounds each model attempt; a timed-out attempt fails the
+	// route with an error matching mcp.ErrTimeout rather than falling back
+	// (0: the service's client timeout only). A deadline on the route's
+	// context applies as well.
+	Timeout time.Duration
+
+	// RetryOnTimeout lets the service retry a timed-out attempt
+	RetryOnTimeout bool
+
+	// NoCache sends the request to the provider even when the service has
+	// the same request cached
+	NoCache bool
+
+	// ResponseSchema asks for a JSON reply matching the schema; the
+	// service checks the reply and asks once for a repair if it does not
+	// (nil: free text)
+	ResponseSchema *mcp.ResponseSchema
+
 	// Temperature controls randomness in generation
 	Temperature float64
 
@@ -62,10 +101,59 @@ type TaskRequest struct {
 	// PreferredProvider can override automatic selection
 	PreferredProvider string
 
-	// Metadata contains additional context about the task
+	// Sensitive marks a request whose refusals are respected as they are:
+	// a refused sensitive request is never rephrased or sent elsewhere
+	Sensitive bool
+
+	// Policy routes this request with a preset in place of the router's
+	// policy (PolicyDefault: the router's)
+	Policy RoutingPolicy
+
+	// AllowDecomposition lets a prompt too long for any model be processed
+	// in overlapping chunks whose answers are then combined; see
+	// RoutingResult.Decomposed. Conversations are never split
+	AllowDecomposition bool
+
+	// Metadata contains additional context about the task. The
+	// MetadataGoalID and MetadataObjectiveID entries attribute the usage the
+	// router records, and MetadataPlanID lets the request spend into the
+	// budget's grace.
 	Metadata map[string]interface{}
 }
 
+// Metadata keys of TaskRequest that the router reads.
+const (
+	// MetadataGoalID is the ID of the goal a request works towards
+	MetadataGoalID = "goal_id"
+	// MetadataObjectiveID is the ID of the objective a request works on
+	MetadataObjectiveID = "objective_id"
+	// MetadataPlanID is the ID of the running execution plan a request is
+	// part of, which soft budget enforcement lets spend past the limits
+	MetadataPlanID = "plan_id"
+	// MetadataComponent names the component that made a request, such as
+	// "ethics", for the service's audit log
+	MetadataComponent = "component"
+)
+
+// metadataString returns a string metadata entry, or "" when unset.
+func (req TaskRequest) metadataString(key string) string {
+	value, _ := req.Metadata[key].(string)
+	return value
+}
+
+// text returns the prompt, or the conversation's turns one after another,
+// for the checks that read the request as a whole.
+func (req TaskRequest) text() string {
+	if len(req.Messages) == 0 {
+		return req.Prompt
+	}
+	contents := make([]string, len(req.Messages))
+	for i, message := range req.Messages {
+		contents[i] = message.Content
+	}
+	return strings.Join(contents, "\n\n")
+}
+
 // TaskAssessment contains the router's assessment of a task.
 type TaskAssessment struct {
 	// Complexity is the estimated complexity level
@@ -101,11 +189,32 @@ type ModelRecommendation struct {
 	// SpeedScore is the expected speed (0-1, higher is faster)
 	SpeedScore float64
 
+	// CostScore is the expected cost efficiency (0-1, higher is cheaper)
+	CostScore float64
+
 	// OverallScore is the weighted combination of factors
 	OverallScore float64
 
+	// Components breaks OverallScore down into its parts
+	Components ScoreComponents
+
 	// Reasoning explains why this model was recommended
 	Reasoning string
+
+	// TokenEstimate is the prompt token count the context and cost checks
+	// used, and which tokenizer produced it
+	TokenEstimate TokenEstimate
+}
+
+// ScoreComponents are the parts of a model's overall score: each factor
+// score multiplied by its weight in the routing policy (by default, in
+// RouterConfig), less the penalty for past refusals and failures.
+// Quality + Cost + Speed - Penalty is the overall score.
+type ScoreComponents struct {
+	Quality float64 `json:"quality"`
+	Cost    float64 `json:"cost"`
+	Speed   float64 `json:"speed"`
+	Penalty float64 `json:"penalty,omitempty"`
 }
 
 // ModelPerformance tracks how well models perform on different task types.
@@ -119,14 +228,71 @@ type ModelPerformance struct {
 	AverageLatency time.Duration
 	SampleCount   int
 	LastUpdated   time.Time
+
+	// EffectiveSamples is the weight of the samples behind the averages as
+	// of LastUpdated. Each sample counts for half as much every
+	// RouterConfig.PerformanceHalfLife, so recent results outweigh old ones;
+	// without decay it equals SampleCount
+	EffectiveSamples float64
+
+	// Drifting is set while recent results have degraded from the model's
+	// baseline; scoring then trusts the learned metrics less
+	Drifting      bool
+	DriftDetectedAt time.Time
+
+	// Completions counts the completions routing received from the model,
+	// and Refusals those it classified as content-policy refusals
+	Completions int
+	Refusals    int
+
+	// latencySamples counts the samples behind AverageLatency, which records
+	// from older versions lack
+	latencySamples int
+}
+
+// RefusalRate returns the fraction (0-1) of the model's completions that
+// were refusals.
+func (p *ModelPerformance) RefusalRate() float64 {
+	if p.Completions == 0 {
+		return 0
+	}
+	return float64(p.Refusals) / float64(p.Completions)
+}
+
+// EffectiveSamplesAt returns the weight of the record's samples at now, when
+// each sample loses half its weight every halfLife (zero: never).
+func (p *ModelPerformance) EffectiveSamplesAt(now time.Time, halfLife time.Duration) float64 {
+	return p.EffectiveSamples * decayFactor(now.Sub(p.LastUpdated), halfLife)
+}
+
+// decayFactor returns how much of a sample's weight is left after elapsed,
+// when it halves every halfLife (zero: never).
+func decayFactor(elapsed, halfLife time.Duration) float64 {
+	if halfLife <= 0 || elapsed <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(elapsed)/float64(halfLife))
 }
 
 // Router provides intelligent LLM routing based on task requirements and learning.
 type Router struct {
 	llmService  LLMServiceInterface
 	performance map[string]*ModelPerformance // key: provider_model_tasktype
+	dirty       map[string]bool              // Records changed since the last SavePerformance
 	mu          sync.RWMutex
 	config      RouterConfig
+	policy      RoutingPolicy // Guarded by mu; set with SetPolicy
+	exchanges   *ExchangeLogger
+	budget      *BudgetManager
+
+	modelsMu      sync.Mutex
+	models        []ModelInfo // Cached listing from the LLM service
+	modelsFetched time.Time
+
+	// routings remembers recent routings for SubmitFeedback, which
+	// feedbackMu serializes
+	routings   *routingMemory
+	feedbackMu sync.Mutex
 }
 
 // RouterConfig contains configuration for the router.
@@ -149,8 +315,76 @@ type RouterConfig struct {
 	// ConservativeBias starts with higher quality models until learning occurs
 	ConservativeBias float64
 
-	// MinSampleSize before trusting performance metrics
+	// MinSampleSize before trusting performance metrics, counted in
+	// effective samples: decayed by PerformanceHalfLife, to the nearest
+	// whole sample
 	MinSampleSize int
+
+	// PerformanceHalfLife is how long it takes a recorded sample to count
+	// for half as much in a model's averages (zero: samples never decay)
+	PerformanceHalfLife time.Duration
+
+	// ExplorationRate is the fraction (0-1) of basic-quality requests routed
+	// first to a model with too few effective samples to be trusted, so
+	// early winners do not lock out models that were barely tried.
+	// Premium-quality requests and ethical analysis are never explored
+	// (default: 0, no exploration)
+	ExplorationRate float64
+
+	// Random is the exploration source, returning values in [0, 1)
+	// (defaults to math/rand)
+	Random func() float64
+
+	// MaxFallbacks is how many alternative models are tried when the selected
+	// model fails with a provider failure
+	MaxFallbacks int
+
+	// Clock times executions for latency tracking (default: the system clock)
+	Clock utils.Clock
+
+	// TokenEstimator counts prompt tokens per candidate model (default: the
+	// heuristic for every model)
+	TokenEstimator *TokenEstimator
+
+	// Credentials tracks which providers reject their credentials; routing
+	// skips them (default: a monitor that raises no alerts)
+	Credentials *CredentialMonitor
+
+	// RephraseRefusals retries a refused request once with a rephrased
+	// framing before falling back to another provider
+	RephraseRefusals bool
+
+	// RefusalPenalty is how much a model's refusal rate lowers its overall
+	// score (0-1)
+	RefusalPenalty float64
+
+	// FailurePenalty is how much a model's failure rate lowers its overall
+	// score (0-1)
+	FailurePenalty float64
+
+	// PerformanceStore persists performance records through LoadPerformance
+	// and SavePerformance (default: none, records are kept in memory only)
+	PerformanceStore PerformanceStore
+
+	// ModelCacheTTL is how long the models listed by the LLM service are
+	// reused before being listed again (zero lists them on every request)
+	ModelCacheTTL time.Duration
+
+	// Policy is the preset requests are routed with until SetPolicy
+	// changes it (PolicyDefault: the weights above)
+	Policy RoutingPolicy
+
+	// RecentRoutings is how many routings are remembered in memory for
+	// SubmitFeedback (default: DefaultRecentRoutings)
+	RecentRoutings int
+
+	// RoutingStore persists remembered routings, so they can be rated after
+	// a restart (default: none, routings are remembered in memory only)
+	RoutingStore RoutingStore
+
+	// DecompositionOverlap is how many tokens consecutive chunks share when
+	// a request is decomposed (default: DefaultDecompositionOverlap)
+	DecompositionOverlap int
 }
 
 // DefaultRouterConfig returns sensible defaults for router configuration.
@@ -163,6 +397,14 @@ func DefaultRouterConfig() RouterConfig {
 		SpeedWeight:       0.2,  // 20% weight for speed
 		ConservativeBias:  0.2,  // Start conservative, prefer quality over cost
 		MinSampleSize:     5,    // Need 5 samples before trusting metrics
+		PerformanceHalfLife: 14 * 24 * time.Hour,
+		MaxFallbacks:      2,    // Try up to two alternatives before giving up
+		RephraseRefusals:  true,
+		RefusalPenalty:    0.3,
+		FailurePenalty:    0.3,
+		ModelCacheTTL:     30 * time.Second,
+		RecentRoutings:    DefaultRecentRoutings,
+		DecompositionOverlap: DefaultDecompositionOverlap,
 	}
 }
 
@@ -172,16 +414,51 @@ func NewRouter(llmService LLMServiceInterface, config ...RouterConfig) *Router {
 	if len(config) > 0 {
 		cfg = config[0]
 	}
+	cfg.Clock = utils.ClockOrReal(cfg.Clock)
+	if cfg.TokenEstimator == nil {
+		cfg.TokenEstimator = NewTokenEstimator(TokenizerConfig{})
+	}
+	if cfg.Credentials == nil {
+		cfg.Credentials = NewCredentialMonitor(CredentialMonitorConfig{Clock: cfg.Clock})
+	}
+	if cfg.RecentRoutings <= 0 {
+		cfg.RecentRoutings = DefaultRecentRoutings
+	}
+	if cfg.Random == nil {
+		cfg.Random = rand.Float64
+	}
+	if cfg.DecompositionOverlap <= 0 {
+		cfg.DecompositionOverlap = DefaultDecompositionOverlap
+	}
 
 	return &Router{
 		llmService:  llmService,
 		performance: make(map[string]*ModelPerformance),
+		dirty:       make(map[string]bool),
 		config:      cfg,
+		policy:      cfg.Policy,
+		routings:    newRoutingMemory(cfg.RecentRoutings),
 	}
 }
 
-// Route selects the best model for a task and executes it.
+// Route selects the best model for a task and executes it. When every model
+// fails, the error wraps the last failure, so errors.Is tells its kind:
+// ErrBudgetExceeded or mcp.ErrBudgetExceeded when nothing could be afforded,
+// mcp.ErrRateLimited, mcp.ErrProviderUnavailable, mcp.ErrAuthFailed or
+// mcp.ErrContextTooLarge for what the last provider tried reported,
+// mcp.ErrProviderUnhealthy when every provider is out of service, and
+// mcp.ErrTimeout when an attempt ran past the request's Timeout or the
+// context's deadline. Providers the service's health monitor took out of
+// service are skipped. The result's ID names the routing for SubmitFeedback.
+// A service without any provider fails at once with ErrNoProvidersConfigured.
 func (r *Router) Route(ctx context.Context, req TaskRequest) (*RoutingResult, error) {
+	routed := false
+	defer func() { r.recordRoutingOutcome(req, routed) }()
+	if err := r.Ready(); err != nil {
+		return nil, err
+	}
+	routingID := newRoutingID()
+
 	// Step 1: Assess the task
 	assessment := r.assessTask(req)
 
@@ -192,50 +469,595 @@ func (r *Router) Route(ctx context.Context, req TaskRequest) (*RoutingResult, er
 	recommendations := r.scoreModels(models, assessment, req)
 
 	if len(recommendations) == 0 {
-		return nil, fmt.Errorf("no suitable models available for this task")
+		// A prompt too long for every model can still be taken in parts
+		if req.AllowDecomposition && len(req.Messages) == 0 && r.fitsNoModel(models, req) {
+			result, err := r.routeDecomposed(ctx, req, assessment, models)
+			routed = err == nil
+			return result, err
+		}
+		return nil, errs.New(errs.ProviderUnavailable, "no suitable models available for this task")
 	}
+	explored := r.explore(req, assessment, recommendations)
+
+	// Step 4: Execute with the best model the budget manager, when set, can
+	// afford, falling back to the next best on a provider failure, which is
+	// recorded against the model. Providers that
+	// rejected their credentials are skipped rather than retried; other
+	// failures only rule out the model that failed. A budget failure only
+	// falls back to cheaper models, and any other failure is returned as it
+	// is. A refusal is retried once rephrased, then rules out the whole
+	// provider.
+	var failedModels []ModelRecommendation
+	var failures []ModelFailure
+	var excludedModels []ModelExclusion
+	var refusals []ModelRefusal
+	skippedProviders := r.unhealthyProviders(ctx)
+	costCeiling := math.Inf(1)
+	var unaffordable *BudgetExceededError
+	rephrased := false
+	var lastErr error
+	var tried *ModelRecommendation
+	attempts := 0
+	for i, candidate := range recommendations {
+		if attempts > r.config.MaxFallbacks {
+			break
+		}
+		reason, overBudget, err := r.exclusion(candidate, req.metadataString(MetadataPlanID), skippedProviders, costCeiling)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			excludedModels = append(excludedModels, ModelExclusion{Model: candidate, Reason: reason})
+			if overBudget != nil && unaffordable == nil {
+				unaffordable = overBudget
+			}
+			continue
+		}
+		attempts++
+		if tried != nil {
+			metrics.RoutingFallbacks.Inc(tried.Provider, tried.Model)
+		}
+		tried = &recommendations[i]
+
+		started := r.config.Clock.Now()
+		result, refusal, err := r.attempt(ctx, req, candidate, false, routingID)
+		if err != nil {
+			lastErr = err
+			failedModels = append(failedModels, candidate)
+			failures = append(failures, ModelFailure{Model: candidate, Code: errs.CodeOf(err), Reason: err.Error()})
+			if ctx.Err() != nil {
+				break
+			}
+			if errs.CodeOf(err) == errs.BudgetExceeded {
+				costCeiling = candidate.EstimatedCost
+				continue
+			}
+			if !isProviderFailure(err) {
+				break
+			}
+			// Rejected credentials say nothing about the model itself
+			if errors.Is(err, mcp.ErrAuthFailed) {
+				r.config.Credentials.RecordAuthFailure(candidate.Provider, err)
+			} else {
+				r.RecordPerformance(candidate.Provider, candidate.Model, req.TaskType, 0, 0, r.config.Clock.Since(started), false)
+			}
+			continue
+		}
+		r.config.Credentials.RecordSuccess(candidate.Provider)
+
+		if refusal != "" {
+			refusals = append(refusals, ModelRefusal{Model: candidate, Reason: refusal})
+			lastErr = fmt.Errorf("%w: %s/%s %s", ErrProviderRefused, candidate.Provider, candidate.Model, refusal)
+
+			// Sensitive requests keep their refusal: no rephrasing, no other provider
+			if blocked := refusalRetryBlocked(req); blocked != "" {
+				lastErr = fmt.Errorf("%w (not retried: %s)", lastErr, blocked)
+				break
+			}
 
-	// Step 4: Select the best model
-	selectedModel := recommendations[0] // Already sorted by score
+			if !r.config.RephraseRefusals || rephrased {
+				skippedProviders[candidate.Provider] = ExclusionRefused
+				continue
+			}
+			rephrased = true
+			started = r.config.Clock.Now()
+			result, refusal, err = r.attempt(ctx, rephraseRequest(req), candidate, true, routingID)
+			if err != nil || refusal != "" {
+				if refusal != "" {
+					refusals = append(refusals, ModelRefusal{Model: candidate, Reason: refusal, Rephrased: true})
+				}
+				if err != nil && ctx.Err() != nil {
+					lastErr = err
+					break
+				}
+				skippedProviders[candidate.Provider] = ExclusionRefused
+				continue
+			}
+		}
 
-	// Step 5: Execute the task
-	result, err := r.executeTask(ctx, req, selectedModel)
-	if err != nil {
-		return nil, fmt.Errorf("task execution failed: %w", err)
+		r.rememberRouting(ctx, &RoutingRecord{
+			ID:       routingID,
+			Provider: candidate.Provider,
+			Model:    candidate.Model,
+			TaskType: req.TaskType,
+			Cost:     result.Cost,
+			Latency:  r.config.Clock.Since(started),
+			RoutedAt: r.config.Clock.Now(),
+		})
+		routed = true
+		recordEstimateAccuracy(candidate, result)
+
+		return &RoutingResult{
+			ID:                routingID,
+			Assessment:        assessment,
+			SelectedModel:     candidate,
+			AlternativeModels: recommendations[i+1:],
+			FailedModels:      failedModels,
+			Failures:          failures,
+			ExcludedModels:    excludedModels,
+			Refusals:          refusals,
+			Rephrased:         rephrased,
+			Exploratory:       explored != nil && candidate.Provider == explored.Provider && candidate.Model == explored.Model,
+			ExecutionResult:   result,
+			ExecutionTime:     r.config.Clock.Now(),
+		}, nil
 	}
 
-	return &RoutingResult{
-		Assessment:        assessment,
-		SelectedModel:     selectedModel,
-		AlternativeModels: recommendations[1:],
-		ExecutionResult:   result,
-		ExecutionTime:     time.Now(),
-	}, nil
+	if lastErr == nil {
+		return nil, noCandidateError(excludedModels, unaffordable)
+	}
+	if len(failures) > 1 {
+		tried := make([]string, len(failures))
+		for i, failure := range failures {
+			tried[i] = failure.Model.Provider + "/" + failure.Model.Model
+		}
+		return nil, fmt.Errorf("task execution failed after trying %s: %w", strings.Join(tried, ", "), lastErr)
+	}
+	return nil, fmt.Errorf("task execution failed: %w", lastErr)
+}
+
+// recordRoutingOutcome counts a request routed, or failed to route, under
+// the name of the policy it was routed with.
+func (r *Router) recordRoutingOutcome(req TaskRequest, routed bool) {
+	if !metrics.Enabled() {
+		return
+	}
+	policy := req.Policy
+	if policy == PolicyDefault {
+		policy = r.Policy()
+	}
+	name := string(policy)
+	if policy == PolicyDefault {
+		name = "default"
+	}
+	outcome := "failed"
+	if routed {
+		outcome = "routed"
+	}
+	metrics.RoutingDecisions.Inc(name, outcome)
+}
+
+// recordEstimateAccuracy records how the cost of a completion compared with
+// the cost estimated when its model was chosen. Cached completions cost
+// nothing, whatever the estimate, and are left out.
+func recordEstimateAccuracy(model ModelRecommendation, result *mcp.CompletionResponse) {
+	if hit, _ := result.Metadata["cache_hit"].(bool); hit || model.EstimatedCost <= 0 {
+		return
+	}
+	metrics.CostEstimateRatio.Observe(result.Cost/model.EstimatedCost, model.Provider)
+}
+
+// RoutePlan makes the routing decision Route would make for req without
+// executing it: the assessment, the scored models, the model that would be
+// tried first and the exact service request it would be sent, in
+// PlannedParams. ExecutionResult is nil and nothing is spent or recorded.
+// Models Route would skip before trying them are listed in ExcludedModels;
+// if every model would be skipped, RoutePlan fails as Route would.
+func (r *Router) RoutePlan(ctx context.Context, req TaskRequest) (*RoutingResult, error) {
+	assessment := r.assessTask(req)
+	recommendations := r.scoreModels(r.getAvailableModels(), assessment, req)
+	if len(recommendations) == 0 {
+		return nil, errs.New(errs.ProviderUnavailable, "no suitable models available for this task")
+	}
+
+	skippedProviders := r.unhealthyProviders(ctx)
+	var excludedModels []ModelExclusion
+	var unaffordable *BudgetExceededError
+	for i, candidate := range recommendations {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		reason, overBudget, err := r.exclusion(candidate, req.metadataString(MetadataPlanID), skippedProviders, math.Inf(1))
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			excludedModels = append(excludedModels, ModelExclusion{Model: candidate, Reason: reason})
+			if overBudget != nil && unaffordable == nil {
+				unaffordable = overBudget
+			}
+			continue
+		}
+
+		return &RoutingResult{
+			Assessment:        assessment,
+			SelectedModel:     candidate,
+			AlternativeModels: recommendations[i+1:],
+			ExcludedModels:    excludedModels,
+			PlannedParams:     serviceParams(req, candidate),
+		}, nil
+	}
+
+	return nil, noCandidateError(excludedModels, unaffordable)
+}
+
+// exclusion reports why a candidate is skipped without being tried, if it
+// is: its provider rejected its credentials, is out of service or refused
+// the task, as skippedProviders records, it costs costCeiling or more, or
+// the budget manager cannot afford it, with the grace of the running plan
+// planID the request is part of, if any. A model the budget manager cannot
+// afford also comes with the error to report should nothing else be
+// affordable.
+func (r *Router) exclusion(candidate ModelRecommendation, planID string, skippedProviders map[string]ExclusionReason, costCeiling float64) (ExclusionReason, *BudgetExceededError, error) {
+	if r.config.Credentials.Excluded(candidate.Provider) {
+		return ExclusionAuthFailed, nil, nil
+	}
+	if reason, skipped := skippedProviders[candidate.Provider]; skipped {
+		return reason, nil, nil
+	}
+	if candidate.EstimatedCost >= costCeiling {
+		return ExclusionOverBudget, nil, nil
+	}
+	if r.budget != nil {
+		check, err := r.budget.CanAffordInPlan(candidate.EstimatedCost, planID)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to check budget: %w", err)
+		}
+		if !check.Affordable {
+			return ExclusionOverBudget, &BudgetExceededError{Model: candidate, Affordability: check}, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// unhealthyProviders returns the providers the LLM service's health monitor
+// took out of service, as list_providers reports them. A service that
+// cannot say is taken to have none.
+func (r *Router) unhealthyProviders(ctx context.Context) map[string]ExclusionReason {
+	unhealthy := make(map[string]ExclusionReason)
+	if r.llmService == nil {
+		return unhealthy
+	}
+	result := r.llmService.Execute(ctx, mcp.ServiceParams{"operation": "list_providers"})
+	data, ok := result.Data.(map[string]interface{})
+	if !result.Success || !ok {
+		return unhealthy
+	}
+	providers, _ := data["providers"].([]map[string]interface{})
+	for _, provider := range providers {
+		name, _ := provider["name"].(string)
+		if healthy, ok := provider["healthy"].(bool); ok && !healthy {
+			unhealthy[name] = ExclusionUnhealthy
+		}
+	}
+	return unhealthy
+}
+
+// noCandidateError is the error when every model was excluded before any
+// was tried: the budget's, if a model could not be afforded, otherwise the
+// provider health's or credentials'.
+func noCandidateError(excluded []ModelExclusion, unaffordable *BudgetExceededError) error {
+	if unaffordable != nil {
+		return unaffordable
+	}
+	for _, exclusion := range excluded {
+		if exclusion.Reason == ExclusionUnhealthy {
+			return fmt.Errorf("every suitable provider is out of service: %w", mcp.ErrProviderUnhealthy)
+		}
+	}
+	return fmt.Errorf("every suitable provider rejected its credentials: %w", mcp.ErrAuthFailed)
+}
+
+// isProviderFailure reports whether an execution error says the provider
+// could not serve the request, so another model may: it is down, rate
+// limited, rejected its credentials or failed unexpectedly. Rejected
+// requests, policy blocks and exhausted budgets are not provider failures.
+func isProviderFailure(err error) bool {
+	switch errs.CodeOf(err) {
+	case errs.ProviderUnavailable, errs.QuotaExceeded, errs.ProviderAuth, errs.Internal:
+		return true
+	default:
+		return false
+	}
+}
+
+// Credentials returns the monitor tracking which providers reject their credentials.
+func (r *Router) Credentials() *CredentialMonitor {
+	return r.config.Credentials
+}
+
+// ErrNoProvidersConfigured is matched when the LLM service has no provider
+// to execute tasks with. It is mcp.ErrNoProvidersConfigured, so either matches.
+var ErrNoProvidersConfigured = mcp.ErrNoProvidersConfigured
+
+// Ready returns nil when the service has a provider to execute tasks with,
+// and otherwise an error matching ErrNoProvidersConfigured that says how to
+// configure one. Without a provider the router still assesses tasks and
+// estimates their cost from the catalog's prices, but Route fails.
+func (r *Router) Ready() error {
+	if ready, ok := r.llmService.(interface{ Ready() error }); ok {
+		return ready.Ready()
+	}
+	if !r.HasProviders() {
+		return fmt.Errorf("%w: %s", ErrNoProvidersConfigured, mcp.NoProvidersRemediation)
+	}
+	return nil
+}
+
+// Diagnose asks the service which providers it set up and why it skipped
+// the others.
+func (r *Router) Diagnose(ctx context.Context) (*mcp.ProviderDiagnosis, error) {
+	if r.llmService == nil {
+		return nil, r.Ready()
+	}
+	result := r.llmService.Execute(ctx, mcp.ServiceParams{"operation": "diagnose"})
+	if !result.Success {
+		return nil, result.Error
+	}
+	diagnosis, ok := result.Data.(*mcp.ProviderDiagnosis)
+	if !ok {
+		return nil, fmt.Errorf("LLM service cannot diagnose its providers")
+	}
+	return diagnosis, nil
+}
+
+// HasProviders reports whether the service has any provider configured.
+// A service that cannot say is taken to have one.
+func (r *Router) HasProviders() bool {
+	if counter, ok := r.llmService.(interface{ GetProviderCount() int }); ok {
+		return counter.GetProviderCount() > 0
+	}
+	return r.llmService != nil
+}
+
+// ProbeProvider checks the provider's credentials with a minimal request to
+// its cheapest model and records the outcome: an accepted probe restores a
+// provider excluded for rejected credentials. Failures other than rejected
+// credentials leave the recorded state alone and are returned as they are.
+func (r *Router) ProbeProvider(ctx context.Context, provider string) error {
+	var cheapest *ModelInfo
+	models := r.getAvailableModels()
+	for i := range models {
+		model := &models[i]
+		if model.Provider != provider {
+			continue
+		}
+		if cheapest == nil || model.InputCost+model.OutputCost < cheapest.InputCost+cheapest.OutputCost {
+			cheapest = model
+		}
+	}
+	if cheapest == nil {
+		return errs.Newf(errs.NotFound, "unknown provider: %s", provider)
+	}
+
+	req := TaskRequest{Prompt: "ping", MaxTokens: 1, TaskType: "probe"}
+	_, err := r.executeTask(ctx, req, ModelRecommendation{Provider: provider, Model: cheapest.Model})
+	switch {
+	case err == nil:
+		r.config.Credentials.RecordSuccess(provider)
+	case errors.Is(err, mcp.ErrAuthFailed):
+		r.config.Credentials.RecordAuthFailure(provider, err)
+	}
+	return err
+}
+
+// attempt executes the task on one model, logs the exchange and records
+// what the completion cost, under the routing's ID when it succeeded. A
+// completion classified as a refusal is returned with its reason and
+// counted against the model.
+func (r *Router) attempt(ctx context.Context, req TaskRequest, model ModelRecommendation, rephrased bool, routingID string) (*mcp.CompletionResponse, string, error) {
+	started := r.config.Clock.Now()
+	result, err := r.executeTask(ctx, req, model)
+	latency := r.config.Clock.Since(started)
+	var refusal string
+	if err == nil {
+		refusal = DetectRefusal(result)
+		r.recordCompletion(model.Provider, model.Model, req.TaskType, refusal != "")
+		transactionID := ""
+		if refusal == "" {
+			transactionID = routingID
+		}
+		r.recordUsage(ctx, req, model, result, latency, transactionID)
+	} else if errors.Is(err, mcp.ErrTimeout) {
+		// A timed-out attempt is recorded for the time it took
+		r.recordUsage(ctx, req, model, nil, latency, "")
+	}
+	r.logExchange(req, model, result, latency, err, refusal, rephrased)
+	return result, refusal, err
+}
+
+// SetExchangeLogger makes the router record its LLM exchanges.
+func (r *Router) SetExchangeLogger(logger *ExchangeLogger) {
+	r.exchanges = logger
+}
+
+// SetBudgetManager makes the router check each model's estimated cost
+// against the budget before executing it and record what completions cost.
+// Callers that record their own usage should leave it unset.
+func (r *Router) SetBudgetManager(budget *BudgetManager) {
+	r.budget = budget
+}
+
+// recordUsage records a completion's cost with the budget manager, when set.
+// A successful completion is recorded under transactionID, the ID of its
+// routing, so feedback can rate it; others pass "". A completion served from
+// the service's cache is recorded at no cost, tagged as a cache hit. A nil
+// result records an attempt that timed out, tagged as such, with its latency
+// and no cost.
+func (r *Router) recordUsage(ctx context.Context, req TaskRequest, model ModelRecommendation, result *mcp.CompletionResponse, latency time.Duration, transactionID string) {
+	if r.budget == nil {
+		return
+	}
+
+	transaction := Transaction{
+		ID:       transactionID,
+		TaskType: req.TaskType,
+		Success:  transactionID != "",
+		Latency:  latency.Milliseconds(),
+
+		GoalID:      req.metadataString(MetadataGoalID),
+		ObjectiveID: req.metadataString(MetadataObjectiveID),
+	}
+	if result != nil {
+		transaction.Provider = result.Provider
+		transaction.Model = result.Model
+		transaction.TokensUsed = result.TokensUsed
+		transaction.Cost = result.Cost
+		if hit, _ := result.Metadata["cache_hit"].(bool); hit {
+			transaction.Tags = map[string]string{"outcome": "cache_hit"}
+		}
+	} else {
+		transaction.Tags = map[string]string{"outcome": "timeout"}
+	}
+	if transaction.Provider == "" {
+		transaction.Provider = model.Provider
+	}
+	if transaction.Model == "" {
+		transaction.Model = model.Model
+	}
+	if err := r.budget.RecordUsage(ctx, transaction); err != nil {
+		log.Printf("Warning: failed to record LLM usage: %v", err)
+	}
+}
+
+// logExchange records an executed exchange when an exchange logger is set.
+func (r *Router) logExchange(req TaskRequest, model ModelRecommendation, result *mcp.CompletionResponse, latency time.Duration, err error, refusal string, rephrased bool) {
+	if r.exchanges == nil {
+		return
+	}
+
+	exchange := Exchange{
+		Provider: model.Provider,
+		Model:    model.Model,
+		TaskType: req.TaskType,
+		Prompt:   req.text(),
+		Latency:  latency,
+		Err:      err,
+
+		Refusal:   refusal,
+		Rephrased: rephrased,
+	}
+	if result != nil {
+		exchange.Response = result.Text
+		exchange.TokensUsed = result.TokensUsed
+		exchange.Cost = result.Cost
+	}
+	if _, logErr := r.exchanges.Log(exchange); logErr != nil {
+		log.Printf("Warning: failed to log LLM exchange: %v", logErr)
+	}
+}
+
+// ExclusionReason explains why routing skipped a recommended model without trying it.
+type ExclusionReason string
+
+const (
+	// ExclusionAuthFailed models belong to a provider that rejected its credentials
+	ExclusionAuthFailed ExclusionReason = "auth_failed"
+
+	// ExclusionRefused models belong to a provider that already refused the request
+	ExclusionRefused ExclusionReason = "refused"
+
+	// ExclusionUnhealthy models belong to a provider the LLM service's
+	// health monitor took out of service
+	ExclusionUnhealthy ExclusionReason = "unhealthy"
+
+	// ExclusionOverBudget models cost more than the budget manager can
+	// afford, or no less than a model that already failed for exceeding the
+	// budget
+	ExclusionOverBudget ExclusionReason = "over_budget"
+)
+
+// ErrBudgetExceeded is matched by errors.Is when routing found no model the
+// budget can afford.
+var ErrBudgetExceeded = errs.New(errs.BudgetExceeded, "no suitable model fits the remaining budget")
+
+// BudgetExceededError is returned by Route when the budget manager could not
+// afford any suitable model. Model is the best of them and Affordability the
+// budget check that rejected it.
+type BudgetExceededError struct {
+	Model         ModelRecommendation
+	Affordability *AffordabilityCheck
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s: %s/%s would cost $%.4f (%s)", ErrBudgetExceeded.Error(),
+		e.Model.Provider, e.Model.Model, e.Model.EstimatedCost, strings.Join(e.Affordability.Warnings, "; "))
+}
+
+// Is matches ErrBudgetExceeded and mcp.ErrBudgetExceeded, so either finds
+// a spent budget whether routing or the service found it.
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded || target == mcp.ErrBudgetExceeded
+}
+
+// ErrorCode reports errs.BudgetExceeded.
+func (e *BudgetExceededError) ErrorCode() errs.Code {
+	return errs.BudgetExceeded
+}
+
+// ModelExclusion is a recommended model that routing skipped.
+type ModelExclusion struct {
+	Model  ModelRecommendation
+	Reason ExclusionReason
+}
+
+// ModelFailure is a model that was tried and failed, with the error code and
+// message of its failure.
+type ModelFailure struct {
+	Model  ModelRecommendation
+	Code   errs.Code
+	Reason string
 }
 
 // RoutingResult contains the complete result of routing and execution.
 type RoutingResult struct {
+	ID                string // Names the routing for SubmitFeedback
 	Assessment        TaskAssessment
 	SelectedModel     ModelRecommendation
 	AlternativeModels []ModelRecommendation
+	FailedModels      []ModelRecommendation // Models tried before SelectedModel that failed
+	Failures          []ModelFailure        // Why each of FailedModels failed, in the same order
+	ExcludedModels    []ModelExclusion      // Models skipped without being tried
+	Refusals          []ModelRefusal        // Completions classified as content-policy refusals
+	Rephrased         bool                  // The prompt was rephrased after a refusal
+	Exploratory       bool                  // SelectedModel was tried first to learn about it; see RouterConfig.ExplorationRate
+	Decomposed        bool                  // The prompt was too long for any model and was processed in Chunks
+	Chunks            []ChunkResult         // The map calls of a decomposed routing; the reduce call is SelectedModel
 	ExecutionResult   *mcp.CompletionResponse
 	ExecutionTime     time.Time
-	UserRating        float64 // Set later via feedback
+	UserRating        float64 // Set later via feedback; see SubmitFeedback
+
+	// PlannedParams is the service request RoutePlan would send to
+	// SelectedModel; Route leaves it nil. It is not part of the wire format
+	PlannedParams mcp.ServiceParams
 }
 
 // assessTask analyzes a task to determine its complexity and requirements.
 func (r *Router) assessTask(req TaskRequest) TaskAssessment {
 	// Estimate token usage
-	estimatedTokens := r.estimateTokenUsage(req.Prompt, req.MaxTokens)
+	estimatedTokens := r.estimateTokenUsage(req.text(), req.MaxTokens)
 
 	// Assess complexity based on prompt characteristics
-	complexity := r.assessComplexity(req.Prompt, req.TaskType)
+	complexity := r.assessComplexity(req.text(), req.TaskType)
 
 	// Determine quality needed (use provided or infer from task type)
 	qualityNeeded := req.QualityRequired
 	if qualityNeeded == QualityBasic && r.inferQualityFromTaskType(req.TaskType) > QualityBasic {
 		qualityNeeded = r.inferQualityFromTaskType(req.TaskType)
 	}
+	if minimum := r.policySettings(req).MinQuality; qualityNeeded < minimum {
+		qualityNeeded = minimum
+	}
 
 	// Generate reasoning for the assessment
 	reasoning := r.generateAssessmentReasoning(complexity, estimatedTokens, qualityNeeded, req.TaskType)
@@ -248,22 +1070,48 @@ func (r *Router) assessTask(req TaskRequest) TaskAssessment {
 	}
 }
 
-// estimateTokenUsage provides a rough estimate of token usage.
+// estimateTokenUsage provides a model-independent estimate of token usage;
+// scoreModels refines it per model.
 func (r *Router) estimateTokenUsage(prompt string, maxTokens int) int {
-	// More accurate estimation: 1 token ≈ 3.5 characters for English text
-	// Add word count factor for better accuracy
-	words := len(strings.Fields(prompt))
-	promptTokens := max(len(prompt)/3, words) // Take the larger of char-based or word-based estimate
+	promptTokens := r.CountTokens("", prompt)
+	return promptTokens + estimateOutputTokens(promptTokens, maxTokens)
+}
+
+// CountTokens returns the number of tokens text takes for model, exactly
+// where the router's token estimator has the model's vocabulary and with the
+// heuristic for its family otherwise. An empty model gets the heuristic for
+// no family in particular.
+func (r *Router) CountTokens(model, text string) int {
+	return r.config.TokenEstimator.CountTokens(model, text)
+}
 
-	// If maxTokens is set, use it; otherwise estimate output length
-	outputTokens := maxTokens
-	if outputTokens == 0 {
-		// Estimate output based on input length with minimum reasonable response
-		baseOutput := max(12, int(float64(promptTokens)*2.5)) // Minimum 12 tokens for any response
-		outputTokens = baseOutput
+// estimatePromptTokens counts the tokens a request sends to a model: its
+// prompt, or the sum over the messages of its conversation.
+func (r *Router) estimatePromptTokens(model string, req TaskRequest) TokenEstimate {
+	if len(req.Messages) == 0 {
+		return r.config.TokenEstimator.Estimate(model, req.Prompt)
 	}
 
-	return promptTokens + outputTokens
+	var total TokenEstimate
+	for i, message := range req.Messages {
+		estimate := r.config.TokenEstimator.Estimate(model, message.Content)
+		total.Tokens += estimate.Tokens
+		if i == 0 {
+			total.Tokenizer, total.Exact = estimate.Tokenizer, estimate.Exact
+		}
+		total.Exact = total.Exact && estimate.Exact
+	}
+	return total
+}
+
+// estimateOutputTokens estimates the length of the response to a prompt.
+func estimateOutputTokens(promptTokens, maxTokens int) int {
+	// If maxTokens is set, use it; otherwise estimate output based on input
+	// length with a minimum reasonable response
+	if maxTokens > 0 {
+		return maxTokens
+	}
+	return max(12, int(float64(promptTokens)*2.5))
 }
 
 // max returns the larger of two integers
@@ -398,93 +1246,179 @@ func (r *Router) generateAssessmentReasoning(complexity TaskComplexity, tokens i
 	return strings.Join(parts, ", ")
 }
 
-// getAvailableModels returns the models available from the LLM service.
+// getAvailableModels returns the chat models the LLM service offers, as
+// reported by its list_models operation. The listing is reused for
+// ModelCacheTTL. A service that cannot list its models, or lists none, is
+// taken to offer the built-in set from staticModels.
 func (r *Router) getAvailableModels() []ModelInfo {
-	// This would need to interface with the LLM service to get available providers and models
-	// For now, we'll return a hardcoded set based on the MCP LLM service implementation
-
-	models := []ModelInfo{
-		{
-			Provider:     "anthropic",
-			Model:        "claude-3-sonnet",
-			InputCost:    3.0,
-			OutputCost:   15.0,
-			MaxTokens:    4096,
-			ContextSize:  200000,
-			QualityTier:  QualityPremium,
-			SpeedTier:    2, // 1=fastest, 3=slowest
-		},
-		{
-			Provider:     "anthropic",
-			Model:        "claude-3-haiku",
-			InputCost:    0.25,
-			OutputCost:   1.25,
-			MaxTokens:    4096,
-			ContextSize:  200000,
-			QualityTier:  QualityStandard,
-			SpeedTier:    1, // Fastest
-		},
-		{
-			Provider:     "openai",
-			Model:        "gpt-4",
-			InputCost:    30.0,
-			OutputCost:   60.0,
-			MaxTokens:    4096,
-			ContextSize:  8192,
-			QualityTier:  QualityPremium,
-			SpeedTier:    3, // Slowest
-		},
-		{
-			Provider:     "openai",
-			Model:        "gpt-3.5-turbo",
-			InputCost:    0.5,
-			OutputCost:   1.5,
-			MaxTokens:    4096,
-			ContextSize:  16385,
-			QualityTier:  QualityStandard,
-			SpeedTier:    1, // Fastest
-		},
-		{
-			Provider:     "local",
-			Model:        "local-llama",
-			InputCost:    0.0,
-			OutputCost:   0.0,
-			MaxTokens:    4096,
-			ContextSize:  4096,
-			QualityTier:  QualityBasic,
-			SpeedTier:    2, // Medium
-		},
+	now := r.config.Clock.Now()
+
+	r.modelsMu.Lock()
+	defer r.modelsMu.Unlock()
+	if r.models == nil || now.Sub(r.modelsFetched) >= r.config.ModelCacheTTL {
+		models := r.listServiceModels(context.Background())
+		if len(models) == 0 {
+			models = staticModels()
+		}
+		r.models, r.modelsFetched = models, now
 	}
+	return append([]ModelInfo(nil), r.models...)
+}
 
+// listServiceModels asks the LLM service for its models and converts the chat
+// models to ModelInfo. It returns nil if the service cannot list them.
+func (r *Router) listServiceModels(ctx context.Context) []ModelInfo {
+	if r.llmService == nil {
+		return nil
+	}
+	result := r.llmService.Execute(ctx, mcp.ServiceParams{"operation": "list_models"})
+	if !result.Success {
+		return nil
+	}
+	listings, ok := result.Data.([]mcp.ModelListing)
+	if !ok {
+		return nil
+	}
+
+	models := make([]ModelInfo, 0, len(listings))
+	for _, listing := range listings {
+		if listing.SupportsChat {
+			models = append(models, modelInfoFromListing(listing))
+		}
+	}
 	return models
 }
 
+// modelInfoFromListing converts a listed model to ModelInfo. Tiers the
+// listing leaves out are taken from the built-in entry for the same model,
+// or default to standard quality and medium speed.
+func modelInfoFromListing(listing mcp.ModelListing) ModelInfo {
+	info := listedModelInfo(listing)
+	for _, known := range staticModels() {
+		if known.Provider == listing.Provider && known.Model == listing.Model {
+			if listing.QualityTier == "" {
+				info.QualityTier = known.QualityTier
+			}
+			if listing.SpeedTier <= 0 {
+				info.SpeedTier = known.SpeedTier
+			}
+			break
+		}
+	}
+	return info
+}
+
+// listedModelInfo converts a listed model to ModelInfo, with standard
+// quality and medium speed for tiers the listing leaves out.
+func listedModelInfo(listing mcp.ModelListing) ModelInfo {
+	info := ModelInfo{
+		Provider:    listing.Provider,
+		Model:       listing.Model,
+		InputCost:   listing.InputCost,
+		OutputCost:  listing.OutputCost,
+		MaxTokens:   listing.MaxTokens,
+		ContextSize: listing.ContextSize,
+		QualityTier: QualityStandard,
+		SpeedTier:   2,
+	}
+	switch listing.QualityTier {
+	case "basic":
+		info.QualityTier = QualityBasic
+	case "standard":
+		info.QualityTier = QualityStandard
+	case "premium":
+		info.QualityTier = QualityPremium
+	}
+	if listing.SpeedTier > 0 {
+		info.SpeedTier = listing.SpeedTier
+	}
+	return info
+}
+
+// staticModels returns the chat models of the MCP LLM service's built-in
+// catalog, by provider and model, and its local placeholder model. They are
+// used when the service cannot list its own.
+func staticModels() []ModelInfo {
+	definitions := mcp.DefaultModelDefinitions()
+	var models []ModelInfo
+	for _, provider := range []string{"anthropic", "openai"} {
+		keys := make([]string, 0, len(definitions[provider]))
+		for key := range definitions[provider] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			config := definitions[provider][key]
+			if !config.SupportsChat {
+				continue
+			}
+			models = append(models, listedModelInfo(mcp.ModelListing{
+				Provider:    provider,
+				Model:       key,
+				InputCost:   config.InputCost,
+				OutputCost:  config.OutputCost,
+				MaxTokens:   config.MaxTokens,
+				ContextSize: config.ContextSize,
+				QualityTier: config.QualityTier,
+				SpeedTier:   config.SpeedTier,
+			}))
+		}
+	}
+
+	return append(models, ModelInfo{
+		Provider:    "local",
+		Model:       "local-llama",
+		InputCost:   0.0,
+		OutputCost:  0.0,
+		MaxTokens:   4096,
+		ContextSize: 4096,
+		QualityTier: QualityBasic,
+		SpeedTier:   2, // Medium
+	})
+}
+
 // ModelInfo represents information about an available model.
 type ModelInfo struct {
 	Provider     string
 	Model        string
-	InputCost    float64 // Cost per 1K tokens
-	OutputCost   float64 // Cost per 1K tokens
+	InputCost    float64 // Cost per 1M tokens
+	OutputCost   float64 // Cost per 1M tokens
 	MaxTokens    int
 	ContextSize  int
 	QualityTier  QualityRequirement
 	SpeedTier    int // 1=fastest, 3=slowest
 }
 
+// cost returns the cost of a request to the model, priced the way the LLM
+// service prices it.
+func (m ModelInfo) cost(inputTokens, outputTokens int) float64 {
+	return mcp.ModelConfig{InputCost: m.InputCost, OutputCost: m.OutputCost}.Cost(inputTokens, outputTokens)
+}
+
 // scoreModels scores each available model for a given task.
 func (r *Router) scoreModels(models []ModelInfo, assessment TaskAssessment, req TaskRequest) []ModelRecommendation {
 	var recommendations []ModelRecommendation
+	settings := r.policySettings(req)
 
 	for _, model := range models {
+		// Skip models priced above the policy's ceiling
+		if settings.MaxInputCost > 0 && model.InputCost > settings.MaxInputCost {
+			continue
+		}
+
+		// Count the prompt with the model's own vocabulary when one is available
+		tokenEstimate := r.estimatePromptTokens(model.Model, req)
+		inputTokens := tokenEstimate.Tokens
+		outputTokens := estimateOutputTokens(inputTokens, req.MaxTokens)
+
 		// Skip models that can't handle the token requirements
-		if assessment.EstimatedTokens > model.ContextSize {
+		if inputTokens+outputTokens > model.ContextSize {
 			continue
 		}
 
 		// Calculate estimated cost
-		inputTokens := len(req.Prompt) / 4 // Rough estimate
-		outputTokens := assessment.EstimatedTokens - inputTokens
-		estimatedCost := (float64(inputTokens)*model.InputCost + float64(outputTokens)*model.OutputCost) / 1000.0
+		estimatedCost := model.cost(inputTokens, outputTokens)
 
 		// Skip models that exceed budget constraint
 		if req.BudgetConstraint != nil && estimatedCost > *req.BudgetConstraint {
@@ -501,13 +1435,18 @@ func (r *Router) scoreModels(models []ModelInfo, assessment TaskAssessment, req
 		perf := r.getPerformance(model.Provider, model.Model, req.TaskType)
 
 		// Apply learning from historical performance
-		if perf != nil && perf.SampleCount >= r.config.MinSampleSize {
-			// Use learned performance metrics
-			qualityScore = (qualityScore + perf.AverageRating/10.0) / 2.0
-		} else {
+		if perf != nil && r.trusted(perf) {
+			// Use learned performance metrics. A drifting model's history may
+			// no longer describe it, so its score leans back on the tier prior
+			learnedWeight := 0.5
+			if perf.Drifting {
+				learnedWeight = driftLearnedWeight
+			}
+			qualityScore = qualityScore*(1-learnedWeight) + perf.AverageRating/10.0*learnedWeight
+		} else if perf == nil || !perf.Drifting {
 			// Apply conservative bias for unknown models
 			if model.QualityTier > assessment.QualityNeeded {
-				qualityScore += r.config.ConservativeBias
+				qualityScore += settings.ConservativeBias
 				if qualityScore > 1.0 {
 					qualityScore = 1.0
 				}
@@ -515,15 +1454,35 @@ func (r *Router) scoreModels(models []ModelInfo, assessment TaskAssessment, req
 		}
 
 		// Calculate cost score (0-1, higher is cheaper)
-		costScore := r.calculateCostScore(estimatedCost, req.BudgetConstraint)
+		costScore := r.calculateCostScore(estimatedCost, req.BudgetConstraint, settings.CostReference)
 
 		// Calculate overall score using weighted combination
-		overallScore := (qualityScore * r.config.QualityWeight) +
-			(costScore * r.config.CostWeight) +
-			(speedScore * r.config.SpeedWeight)
+		components := ScoreComponents{
+			Quality: qualityScore * settings.QualityWeight,
+			Cost:    costScore * settings.CostWeight,
+			Speed:   speedScore * settings.SpeedWeight,
+		}
+
+		// Refusals and failures make a model less reliable for the task type
+		if perf != nil && perf.Refusals > 0 {
+			components.Penalty += perf.RefusalRate() * r.config.RefusalPenalty
+		}
+		if perf != nil && perf.SampleCount > 0 {
+			components.Penalty += (1 - perf.SuccessRate) * r.config.FailurePenalty
+		}
+		overallScore := components.Quality + components.Cost + components.Speed - components.Penalty
 
 		// Generate reasoning
 		reasoning := r.generateRecommendationReasoning(model, qualityScore, costScore, speedScore, estimatedCost)
+		if perf != nil && perf.Drifting {
+			reasoning += ", recent results drifting from baseline"
+		}
+		if perf != nil && perf.Refusals > 0 {
+			reasoning += fmt.Sprintf(", refused %.0f%% of requests", perf.RefusalRate()*100)
+		}
+		if perf != nil && perf.SampleCount > 0 && perf.SuccessRate < 1 {
+			reasoning += fmt.Sprintf(", failed %.0f%% of requests", (1-perf.SuccessRate)*100)
+		}
 
 		recommendation := ModelRecommendation{
 			Provider:      model.Provider,
@@ -531,8 +1490,11 @@ func (r *Router) scoreModels(models []ModelInfo, assessment TaskAssessment, req
 			EstimatedCost: estimatedCost,
 			QualityScore:  qualityScore,
 			SpeedScore:    speedScore,
+			CostScore:     costScore,
 			OverallScore:  overallScore,
+			Components:    components,
 			Reasoning:     reasoning,
+			TokenEstimate: tokenEstimate,
 		}
 
 		recommendations = append(recommendations, recommendation)
@@ -550,6 +1512,56 @@ func (r *Router) scoreModels(models []ModelInfo, assessment TaskAssessment, req
 	return recommendations
 }
 
+// ethicalAnalysisTaskType is the task type of the ethical framework's
+// analyses, which are never explored.
+const ethicalAnalysisTaskType = "ethical_analysis"
+
+// trusted reports whether a record has enough effective samples, counted to
+// the nearest whole sample, for scoring to rely on its metrics.
+func (r *Router) trusted(perf *ModelPerformance) bool {
+	samples := perf.EffectiveSamplesAt(r.config.Clock.Now(), r.config.PerformanceHalfLife)
+	return math.Round(samples) >= float64(r.config.MinSampleSize)
+}
+
+// explore decides whether a request is explored and, if it is, moves the
+// least-sampled model routing does not yet trust to the front of
+// recommendations and returns it. Only ExplorationRate of the basic-quality
+// requests are explored; premium-quality requests and ethical analysis never
+// are. The other models keep their order as fallbacks.
+func (r *Router) explore(req TaskRequest, assessment TaskAssessment, recommendations []ModelRecommendation) *ModelRecommendation {
+	if r.config.ExplorationRate <= 0 || assessment.QualityNeeded != QualityBasic || req.QualityRequired == QualityPremium {
+		return nil
+	}
+	if strings.EqualFold(req.TaskType, ethicalAnalysisTaskType) || r.config.Random() >= r.config.ExplorationRate {
+		return nil
+	}
+
+	now := r.config.Clock.Now()
+	chosen, fewest := -1, 0.0
+	for i := 1; i < len(recommendations); i++ {
+		perf := r.getPerformance(recommendations[i].Provider, recommendations[i].Model, req.TaskType)
+		samples := 0.0
+		if perf != nil {
+			if r.trusted(perf) {
+				continue
+			}
+			samples = perf.EffectiveSamplesAt(now, r.config.PerformanceHalfLife)
+		}
+		if chosen < 0 || samples < fewest {
+			chosen, fewest = i, samples
+		}
+	}
+	if chosen < 0 {
+		return nil
+	}
+
+	explored := recommendations[chosen]
+	explored.Reasoning += fmt.Sprintf(", explored with %.1f effective samples", fewest)
+	copy(recommendations[1:chosen+1], recommendations[:chosen])
+	recommendations[0] = explored
+	return &explored
+}
+
 // calculateQualityScore calculates how well a model matches quality requirements.
 func (r *Router) calculateQualityScore(model ModelInfo, required QualityRequirement) float64 {
 	qualityDiff := int(model.QualityTier) - int(required)
@@ -572,9 +1584,11 @@ func (r *Router) calculateQualityScore(model ModelInfo, required QualityRequirem
 	}
 }
 
-// calculateCostScore calculates cost efficiency score.
-func (r *Router) calculateCostScore(estimatedCost float64, budgetConstraint *float64) float64 {
-	maxBudget := r.config.MaxCostPerRequest
+// calculateCostScore calculates cost efficiency score against the budget
+// constraint, or else the reference cost, which policySettings defaults to
+// MaxCostPerRequest.
+func (r *Router) calculateCostScore(estimatedCost float64, budgetConstraint *float64, reference float64) float64 {
+	maxBudget := reference
 	if budgetConstraint != nil {
 		maxBudget = *budgetConstraint
 	}
@@ -624,32 +1638,103 @@ func (r *Router) generateRecommendationReasoning(model ModelInfo, qualityScore,
 
 // executeTask executes the task using the selected model.
 func (r *Router) executeTask(ctx context.Context, req TaskRequest, model ModelRecommendation) (*mcp.CompletionResponse, error) {
-	// Prepare parameters for the LLM service
+	// Execute using the LLM service
+	result := r.llmService.Execute(ctx, serviceParams(req, model))
+	failed, _ := result.Metadata["failed_attempts"].([]mcp.FailedAttempt)
+	r.recordFailedAttempts(ctx, req, failed, result.Error != nil)
+	if result.Error != nil {
+		return nil, fmt.Errorf("LLM service execution failed: %w", result.Error)
+	}
+
+	// Extract completion response
+	completion, ok := result.Data.(*mcp.CompletionResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type from LLM service")
+	}
+
+	return completion, nil
+}
+
+// recordFailedAttempts records the attempts the LLM service made at a task
+// that failed. Usage a provider billed for a failed attempt is recorded with
+// the budget manager as an unsuccessful transaction tagged with the attempt
+// number, and each attempt the service retried counts against its model's
+// success rate. When the service gave up, its last attempt is the task's own
+// failure, which the caller records.
+func (r *Router) recordFailedAttempts(ctx context.Context, req TaskRequest, failed []mcp.FailedAttempt, gaveUp bool) {
+	for i, attempt := range failed {
+		if r.budget != nil && (attempt.TokensUsed > 0 || attempt.Cost > 0) {
+			transaction := Transaction{
+				Provider:   attempt.Provider,
+				Model:      attempt.Model,
+				TaskType:   req.TaskType,
+				TokensUsed: attempt.TokensUsed,
+				Cost:       attempt.Cost,
+				Success:    false,
+				Latency:    attempt.LatencyMs,
+
+				GoalID:      req.metadataString(MetadataGoalID),
+				ObjectiveID: req.metadataString(MetadataObjectiveID),
+				Tags:        map[string]string{"attempt": strconv.Itoa(attempt.Attempt)},
+			}
+			if err := r.budget.RecordUsage(ctx, transaction); err != nil {
+				log.Printf("Warning: failed to record failed LLM attempt: %v", err)
+			}
+		}
+		if gaveUp && i == len(failed)-1 {
+			continue
+		}
+		latency := time.Duration(attempt.LatencyMs) * time.Millisecond
+		r.RecordPerformance(attempt.Provider, attempt.Model, req.TaskType, attempt.Cost, 0, latency, false)
+	}
+}
+
+// serviceParams returns the parameters of the LLM service request that runs
+// the task on model.
+func serviceParams(req TaskRequest, model ModelRecommendation) mcp.ServiceParams {
 	params := mcp.ServiceParams{
 		"operation":  "complete",
-		"prompt":     req.Prompt,
 		"provider":   model.Provider,
 		"model":      model.Model,
 		"max_tokens": req.MaxTokens,
 	}
+	if len(req.Messages) > 0 {
+		params["messages"] = req.Messages
+	} else {
+		params["prompt"] = req.Prompt
+	}
 
 	if req.Temperature > 0 {
 		params["temperature"] = req.Temperature
 	}
-
-	// Execute using the LLM service
-	result := r.llmService.Execute(ctx, params)
-	if result.Error != nil {
-		return nil, fmt.Errorf("LLM service execution failed: %w", result.Error)
+	if req.HardMaxTokens > 0 {
+		params["hard_max_tokens"] = req.HardMaxTokens
 	}
-
-	// Extract completion response
-	completion, ok := result.Data.(*mcp.CompletionResponse)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type from LLM service")
+	if req.Timeout > 0 {
+		params["timeout_seconds"] = req.Timeout.Seconds()
+	}
+	if req.RetryOnTimeout {
+		params["retry_on_timeout"] = true
+	}
+	if req.NoCache {
+		params["no_cache"] = true
+	}
+	if req.ResponseSchema != nil {
+		params["response_schema"] = req.ResponseSchema
 	}
 
-	return completion, nil
+	// The service's audit log attributes the request by these, and its
+	// budget check gives running plans their grace
+	metadata := make(map[string]interface{})
+	for _, key := range []string{MetadataGoalID, MetadataObjectiveID, MetadataPlanID, MetadataComponent} {
+		if value := req.metadataString(key); value != "" {
+			metadata[key] = value
+		}
+	}
+	if len(metadata) > 0 {
+		params["metadata"] = metadata
+	}
+	return params
 }
 
 // getPerformance retrieves historical performance data for a model/task combination.
@@ -657,8 +1742,7 @@ func (r *Router) getPerformance(provider, model, taskType string) *ModelPerforma
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	key := fmt.Sprintf("%s_%s_%s", provider, model, taskType)
-	return r.performance[key]
+	return r.performance[performanceKey(provider, model, taskType)]
 }
 
 // RecordPerformance records the performance of a model on a task for learning.
@@ -666,7 +1750,8 @@ func (r *Router) RecordPerformance(provider, model, taskType string, cost float6
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	key := fmt.Sprintf("%s_%s_%s", provider, model, taskType)
+	key := performanceKey(provider, model, taskType)
+	r.dirty[key] = true
 
 	perf, exists := r.performance[key]
 	if !exists {
@@ -680,41 +1765,103 @@ func (r *Router) RecordPerformance(provider, model, taskType string, cost float6
 		r.performance[key] = perf
 	}
 
-	// Update metrics using incremental formulas
+	// Update metrics using incremental formulas, weighting the earlier
+	// samples by what is left of them after decay
+	now := r.config.Clock.Now()
+	weight := perf.EffectiveSamplesAt(now, r.config.PerformanceHalfLife)
+	average := func(current, sample, weight float64) float64 {
+		return (current*weight + sample) / (weight + 1)
+	}
 	perf.SampleCount++
+	perf.EffectiveSamples = weight + 1
 
 	// Update success rate
 	if successful {
-		perf.SuccessRate = (perf.SuccessRate*float64(perf.SampleCount-1) + 1.0) / float64(perf.SampleCount)
+		perf.SuccessRate = average(perf.SuccessRate, 1.0, weight)
 	} else {
-		perf.SuccessRate = (perf.SuccessRate*float64(perf.SampleCount-1) + 0.0) / float64(perf.SampleCount)
+		perf.SuccessRate = average(perf.SuccessRate, 0.0, weight)
 	}
 
 	// Update average rating (only if rating is provided and valid)
 	if rating >= 1.0 && rating <= 10.0 {
-		if perf.SampleCount == 1 {
-			perf.AverageRating = rating
-		} else {
-			perf.AverageRating = (perf.AverageRating*float64(perf.SampleCount-1) + rating) / float64(perf.SampleCount)
-		}
+		perf.AverageRating = average(perf.AverageRating, rating, weight)
 	}
 
 	// Update average cost
-	if perf.SampleCount == 1 {
-		perf.AverageCost = cost
-	} else {
-		perf.AverageCost = (perf.AverageCost*float64(perf.SampleCount-1) + cost) / float64(perf.SampleCount)
+	perf.AverageCost = average(perf.AverageCost, cost, weight)
+
+	// Update average latency; a record without latency samples takes the
+	// first one as it is
+	latencyWeight := weight
+	if perf.latencySamples == 0 {
+		latencyWeight = 0
 	}
+	perf.latencySamples++
+	perf.AverageLatency = time.Duration(average(float64(perf.AverageLatency), float64(latency), latencyWeight))
 
-	// Update average latency
-	if perf.SampleCount == 1 {
-		perf.AverageLatency = latency
-	} else {
-		totalLatency := perf.AverageLatency*time.Duration(perf.SampleCount-1) + latency
-		perf.AverageLatency = totalLatency / time.Duration(perf.SampleCount)
+	perf.LastUpdated = now
+}
+
+// recordCompletion counts a completion received from a model, and whether
+// it was a refusal, for the refusal rate.
+func (r *Router) recordCompletion(provider, model, taskType string, refused bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := performanceKey(provider, model, taskType)
+	r.dirty[key] = true
+
+	perf, exists := r.performance[key]
+	if !exists {
+		perf = &ModelPerformance{
+			Provider: provider,
+			Model:    model,
+			TaskType: taskType,
+		}
+		r.performance[key] = perf
 	}
 
-	perf.LastUpdated = time.Now()
+	perf.Completions++
+	if refused {
+		perf.Refusals++
+	}
+}
+
+// SetDrift flags or clears quality drift for a model on a task type. While
+// flagged, the model's learned metrics count for less when scoring and it
+// gets no conservative bias, widening the uncertainty around it rather than
+// ruling it out.
+func (r *Router) SetDrift(provider, model, taskType string, drifting bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := performanceKey(provider, model, taskType)
+
+	perf, exists := r.performance[key]
+	if !exists {
+		if !drifting {
+			return
+		}
+		perf = &ModelPerformance{
+			Provider: provider,
+			Model:    model,
+			TaskType: taskType,
+		}
+		r.performance[key] = perf
+	}
+
+	r.dirty[key] = true
+
+	if drifting && !perf.Drifting {
+		perf.DriftDetectedAt = r.config.Clock.Now()
+	}
+	perf.Drifting = drifting
+}
+
+// RankModels scores the available models for a task type, best first.
+func (r *Router) RankModels(taskType string) []ModelRecommendation {
+	req := TaskRequest{TaskType: taskType}
+	return r.scoreModels(r.getAvailableModels(), r.assessTask(req), req)
 }
 
 // GetPerformanceStats returns performance statistics for learning analysis.
@@ -735,6 +1882,11 @@ func (r *Router) GetPerformanceStats() map[string]*ModelPerformance {
 			AverageLatency: perf.AverageLatency,
 			SampleCount:   perf.SampleCount,
 			LastUpdated:   perf.LastUpdated,
+			EffectiveSamples: perf.EffectiveSamples,
+			Drifting:      perf.Drifting,
+			DriftDetectedAt: perf.DriftDetectedAt,
+			Completions:   perf.Completions,
+			Refusals:      perf.Refusals,
 		}
 	}
 
@@ -748,7 +1900,7 @@ func (r *Router) EstimateCost(req TaskRequest) (*CostEstimate, error) {
 	recommendations := r.scoreModels(models, assessment, req)
 
 	if len(recommendations) == 0 {
-		return nil, fmt.Errorf("no suitable models available for cost estimation")
+		return nil, errs.New(errs.ProviderUnavailable, "no suitable models available for cost estimation")
 	}
 
 	// Get cost estimates for top 3 recommendations
@@ -816,4 +1968,14 @@ func (qr QualityRequirement) String() string {
 	default:
 		return "unknown"
 	}
+}
+
+// ParseQualityRequirement returns the quality requirement with the given name.
+func ParseQualityRequirement(name string) (QualityRequirement, error) {
+	for _, qr := range []QualityRequirement{QualityBasic, QualityStandard, QualityPremium} {
+		if qr.String() == name {
+			return qr, nil
+		}
+	}
+	return QualityStandard, errs.Newf(errs.Validation, "unknown quality %q (basic, standard or premium)", name)
 }
\ No newline at end of file
@@ -2,6 +2,9 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -339,6 +342,28 @@ func TestCostEstimation(t *testing.T) {
 	}
 }
 
+func TestCostEstimationPricesInputAndOutputSeparately(t *testing.T) {
+	provider := &mcp.AnthropicProvider{Models: map[string]mcp.ModelConfig{
+		"claude-3-sonnet": {Name: "claude-3-sonnet-20240229", InputCost: 3.0, OutputCost: 15.0, MaxTokens: 4096, ContextSize: 200000, SupportsChat: true},
+	}}
+	router := NewRouter(mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{"anthropic": provider}))
+
+	// A short prompt asking for a long answer is mostly output
+	estimate, err := router.EstimateCost(TaskRequest{Prompt: "Write the launch announcement", TaskType: "writing", MaxTokens: 4000})
+	if err != nil {
+		t.Fatalf("Cost estimation failed: %v", err)
+	}
+	rec := router.RankModels("writing")[0]
+	inputTokens := router.config.TokenEstimator.Estimate(rec.Model, "Write the launch announcement").Tokens
+	want := provider.CalculateCost("claude-3-sonnet", inputTokens, 4000)
+	if estimate.Options[0].EstimatedCost != want {
+		t.Errorf("Expected the estimate to match what the provider charges, $%.6f, got $%.6f", want, estimate.Options[0].EstimatedCost)
+	}
+	if averaged := float64(inputTokens+4000) * 9.0 / 1000000; want <= averaged {
+		t.Errorf("Expected output-heavy pricing above the averaged $%.6f, got $%.6f", averaged, want)
+	}
+}
+
 func TestPerformanceRecording(t *testing.T) {
 	router := NewRouter(NewMockLLMService())
 
@@ -386,11 +411,9 @@ func TestRouterIntegration(t *testing.T) {
 	}
 
 	// Set response for all possible models that could be selected
-	mockService.SetResponse("complete", "anthropic", "claude-3-haiku", mockResponse)
-	mockService.SetResponse("complete", "anthropic", "claude-3-sonnet", mockResponse)
-	mockService.SetResponse("complete", "openai", "gpt-4", mockResponse)
-	mockService.SetResponse("complete", "openai", "gpt-3.5-turbo", mockResponse)
-	mockService.SetResponse("complete", "local", "local-llama", mockResponse)
+	for _, model := range staticModels() {
+		mockService.SetResponse("complete", model.Provider, model.Model, mockResponse)
+	}
 
 	router := NewRouter(mockService)
 
@@ -486,6 +509,121 @@ func TestRouterWithLearning(t *testing.T) {
 	}
 }
 
+func TestSetDrift(t *testing.T) {
+	router := NewRouter(NewMockLLMService())
+
+	// Clearing a model the router has never seen leaves no record behind
+	router.SetDrift("openai", "gpt-4", "analysis", false)
+	if len(router.GetPerformanceStats()) != 0 {
+		t.Error("Expected no performance record for a cleared unknown model")
+	}
+
+	// A mediocre history drags the haiku below its perfect tier match
+	for i := 0; i < 5; i++ {
+		router.RecordPerformance("anthropic", "claude-3-haiku", "analysis", 0.01, 6, time.Second, true)
+	}
+	score := func() ModelRecommendation {
+		for _, rec := range router.RankModels("analysis") {
+			if rec.Model == "claude-3-haiku" {
+				return rec
+			}
+		}
+		t.Fatal("Expected claude-3-haiku to be ranked")
+		return ModelRecommendation{}
+	}
+	trusted := score()
+
+	router.SetDrift("anthropic", "claude-3-haiku", "analysis", true)
+	perf := router.GetPerformanceStats()["anthropic_claude-3-haiku_analysis"]
+	if !perf.Drifting || perf.DriftDetectedAt.IsZero() || perf.SampleCount != 5 {
+		t.Errorf("Expected the existing record to be flagged, got %+v", perf)
+	}
+	drifting := score()
+	if drifting.QualityScore <= trusted.QualityScore || drifting.QualityScore >= 1.0 {
+		t.Errorf("Expected drift to lean the score back toward the tier prior, got %.3f vs %.3f", drifting.QualityScore, trusted.QualityScore)
+	}
+	if !strings.Contains(drifting.Reasoning, "drifting") {
+		t.Errorf("Expected the reasoning to mention drift, got %q", drifting.Reasoning)
+	}
+
+	router.SetDrift("anthropic", "claude-3-haiku", "analysis", false)
+	if cleared := score(); cleared.QualityScore != trusted.QualityScore {
+		t.Errorf("Expected clearing drift to restore the score, got %.3f vs %.3f", cleared.QualityScore, trusted.QualityScore)
+	}
+}
+
+func TestRouterFallback(t *testing.T) {
+	mockService := NewMockLLMService()
+	router := NewRouter(mockService)
+
+	req := TaskRequest{
+		Prompt:          "Summarize the quarterly report",
+		TaskType:        "summarization",
+		QualityRequired: QualityStandard,
+		MaxTokens:       500,
+	}
+
+	ctx := context.Background()
+
+	// Find out which model is preferred for this request
+	first, err := router.Route(ctx, req)
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	primary := first.SelectedModel
+	if len(first.FailedModels) != 0 {
+		t.Errorf("Expected no failed models, got %d", len(first.FailedModels))
+	}
+
+	// Make the preferred model fail and expect the router to fall back
+	mockService.SetError("complete", primary.Provider, primary.Model, fmt.Errorf("model unavailable"))
+
+	result, err := router.Route(ctx, req)
+	if err != nil {
+		t.Fatalf("Routing should fall back to an alternative model: %v", err)
+	}
+	if result.SelectedModel.Provider == primary.Provider && result.SelectedModel.Model == primary.Model {
+		t.Error("Should not select the failing model")
+	}
+	if len(result.FailedModels) != 1 || result.FailedModels[0].Model != primary.Model {
+		t.Errorf("Expected failing model %s to be recorded, got %v", primary.Model, result.FailedModels)
+	}
+
+	// Without fallbacks the failure is returned directly
+	config := DefaultRouterConfig()
+	config.MaxFallbacks = 0
+	strictRouter := NewRouter(mockService, config)
+	if _, err := strictRouter.Route(ctx, req); err == nil {
+		t.Error("Expected error when fallbacks are disabled")
+	}
+}
+
+func TestRouterWithoutProviders(t *testing.T) {
+	for _, name := range []string{"ANTHROPIC_API_KEY", "OPENAI_API_KEY", "LOCAL_LLM_URL"} {
+		t.Setenv(name, "")
+	}
+	router := NewRouter(mcp.NewLLMServiceWithCredentials(nil, map[string]string{}))
+
+	if err := router.Ready(); !errors.Is(err, ErrNoProvidersConfigured) {
+		t.Fatalf("Expected the router not ready, got %v", err)
+	}
+	req := TaskRequest{Prompt: "Summarize the meeting notes", TaskType: "generation"}
+	if _, err := router.Route(context.Background(), req); !errors.Is(err, ErrNoProvidersConfigured) {
+		t.Errorf("Expected routing to fail for the missing providers, got %v", err)
+	}
+
+	// Estimates still work from the catalog's prices
+	estimate, err := router.EstimateCost(req)
+	if err != nil || len(estimate.Options) == 0 || estimate.Options[0].EstimatedCost <= 0 {
+		t.Errorf("Expected a cost estimate without providers, got %+v, %v", estimate, err)
+	}
+
+	diagnosis, err := router.Diagnose(context.Background())
+	if err != nil || diagnosis.Ready || len(diagnosis.Providers) != 3 {
+		t.Errorf("Expected every provider reported as skipped, got %+v, %v", diagnosis, err)
+	}
+}
+
 func TestDefaultRouterConfig(t *testing.T) {
 	config := DefaultRouterConfig()
 
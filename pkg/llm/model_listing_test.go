@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// catalogProvider is a provider that can describe its models but is never
+// asked to run them here.
+type catalogProvider struct {
+	models map[string]mcp.ModelConfig
+}
+
+func (p *catalogProvider) Name() string { return "Catalog" }
+
+func (p *catalogProvider) Complete(ctx context.Context, request mcp.CompletionRequest) (*mcp.CompletionResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *catalogProvider) Embed(ctx context.Context, request mcp.EmbeddingRequest) (*mcp.EmbeddingResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *catalogProvider) CalculateCost(model string, inputTokens, outputTokens int) float64 {
+	return 0
+}
+
+func (p *catalogProvider) HealthCheck(ctx context.Context) error { return nil }
+
+func (p *catalogProvider) ListModels() map[string]mcp.ModelConfig { return p.models }
+
+func findModel(models []ModelInfo, provider, model string) *ModelInfo {
+	for i := range models {
+		if models[i].Provider == provider && models[i].Model == model {
+			return &models[i]
+		}
+	}
+	return nil
+}
+
+func TestRouterUsesModelsListedByService(t *testing.T) {
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+		"anthropic": &mcp.AnthropicProvider{Models: map[string]mcp.ModelConfig{
+			// Priced differently from the built-in set, and without tiers
+			"claude-3-haiku": {Name: "claude-3-haiku-20240307", InputCost: 1.0, OutputCost: 5.0, MaxTokens: 4096, ContextSize: 200000, SupportsChat: true},
+		}},
+	})
+	clock := utils.NewFakeClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	config := DefaultRouterConfig()
+	config.Clock = clock
+	router := NewRouter(service, config)
+
+	models := router.getAvailableModels()
+	if len(models) != 1 {
+		t.Fatalf("Expected only the listed model, got %+v", models)
+	}
+	haiku := models[0]
+	if haiku.InputCost != 1.0 || haiku.OutputCost != 5.0 {
+		t.Errorf("Expected the configured costs, got %.2f/%.2f", haiku.InputCost, haiku.OutputCost)
+	}
+	if haiku.QualityTier != QualityStandard || haiku.SpeedTier != 1 {
+		t.Errorf("Expected the built-in tiers for a listing without them, got %v/%d", haiku.QualityTier, haiku.SpeedTier)
+	}
+
+	// A provider added later appears once the cached listing expires
+	service.SetProvider("mistral", &catalogProvider{models: map[string]mcp.ModelConfig{
+		"mistral-small": {Name: "mistral-small-latest", MaxTokens: 4096, ContextSize: 32000, SupportsChat: true, QualityTier: "premium", SpeedTier: 1},
+	}})
+	if findModel(router.getAvailableModels(), "mistral", "mistral-small") != nil {
+		t.Error("Expected the cached listing to be reused")
+	}
+	clock.Advance(config.ModelCacheTTL)
+
+	ranked := router.RankModels("analysis")
+	if len(ranked) != 2 || ranked[0].Provider != "mistral" || ranked[0].Model != "mistral-small" {
+		t.Fatalf("Expected the free premium model to rank first, got %+v", ranked)
+	}
+	estimate, err := router.EstimateCost(TaskRequest{Prompt: "Analyze this proposal", TaskType: "analysis"})
+	if err != nil {
+		t.Fatalf("Cost estimation failed: %v", err)
+	}
+	if estimate.Options[0].Provider != "mistral" || estimate.Options[0].EstimatedCost != 0 {
+		t.Errorf("Expected the new model estimated at its configured cost, got %+v", estimate.Options[0])
+	}
+}
+
+func TestRouterFallsBackToBuiltInModels(t *testing.T) {
+	// The mock service cannot list its models
+	router := NewRouter(NewMockLLMService())
+	models := router.getAvailableModels()
+	if len(models) != len(staticModels()) {
+		t.Fatalf("Expected the %d built-in models, got %d", len(staticModels()), len(models))
+	}
+
+	// Nor can a service without providers that describe them
+	router = NewRouter(mcp.NewLLMServiceWithProviders(nil, nil))
+	if findModel(router.getAvailableModels(), "openai", "gpt-4") == nil {
+		t.Error("Expected the built-in models for a service listing none")
+	}
+}
+
+func TestRouterSkipsModelsThatCannotChat(t *testing.T) {
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+		"openai": &mcp.OpenAIProvider{Models: map[string]mcp.ModelConfig{
+			"gpt-3.5-turbo":          {InputCost: 0.5, OutputCost: 1.5, MaxTokens: 4096, ContextSize: 16385, SupportsChat: true},
+			"text-embedding-ada-002": {InputCost: 0.1, ContextSize: 8191, SupportsEmbed: true},
+		}},
+	})
+	models := NewRouter(service).getAvailableModels()
+	if len(models) != 1 || models[0].Model != "gpt-3.5-turbo" {
+		t.Errorf("Expected only the chat model, got %+v", models)
+	}
+}
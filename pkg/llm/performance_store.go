@@ -0,0 +1,268 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// modelPerformanceNodeType is the storage node type of persisted performance records.
+const modelPerformanceNodeType = "model_performance"
+
+// PerformanceStore persists the router's model performance records, so
+// what routing learned survives restarts.
+type PerformanceStore interface {
+	// LoadPerformance returns every persisted record.
+	LoadPerformance(ctx context.Context) ([]*ModelPerformance, error)
+
+	// SavePerformance persists records, replacing earlier versions of the
+	// same provider, model and task type.
+	SavePerformance(ctx context.Context, records []*ModelPerformance) error
+}
+
+// StoragePerformanceStore keeps performance records as "model_performance"
+// nodes, one per provider, model and task type.
+type StoragePerformanceStore struct {
+	store *storage.Store
+}
+
+// NewStoragePerformanceStore creates a performance store backed by store.
+func NewStoragePerformanceStore(store *storage.Store) *StoragePerformanceStore {
+	return &StoragePerformanceStore{store: store}
+}
+
+// LoadPerformance returns every persisted record. Records written before a
+// field existed load with that field unset.
+func (s *StoragePerformanceStore) LoadPerformance(ctx context.Context) ([]*ModelPerformance, error) {
+	nodes, err := s.store.GetNodesByType(ctx, modelPerformanceNodeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model performance: %w", err)
+	}
+
+	records := make([]*ModelPerformance, 0, len(nodes))
+	for _, node := range nodes {
+		if perf := nodeToPerformance(node); perf != nil {
+			records = append(records, perf)
+		}
+	}
+	return records, nil
+}
+
+// SavePerformance persists records as new versions of their nodes. Nothing
+// is written to a read-only store such as a replica.
+func (s *StoragePerformanceStore) SavePerformance(ctx context.Context, records []*ModelPerformance) error {
+	if s.store.IsReadOnly() {
+		return nil
+	}
+	for _, perf := range records {
+		key := performanceKey(perf.Provider, perf.Model, perf.TaskType)
+		node := storage.NewNodeWithID(performanceNodeID(key), modelPerformanceNodeType, performanceToNodeData(perf))
+		if err := s.store.AddNode(ctx, node); err != nil {
+			return fmt.Errorf("failed to save model performance %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// LoadPerformance hydrates the router's performance records from its
+// PerformanceStore. Records the router already holds for the same model and
+// task type are combined with the stored ones, weighted by effective samples.
+// Without a PerformanceStore it does nothing.
+func (r *Router) LoadPerformance(ctx context.Context) error {
+	if r.config.PerformanceStore == nil {
+		return nil
+	}
+	records, err := r.config.PerformanceStore.LoadPerformance(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, stored := range records {
+		key := performanceKey(stored.Provider, stored.Model, stored.TaskType)
+		if live, exists := r.performance[key]; exists {
+			r.performance[key] = mergePerformance(stored, live, r.config.PerformanceHalfLife)
+			r.dirty[key] = true
+		} else {
+			r.performance[key] = stored
+		}
+	}
+	return nil
+}
+
+// SavePerformance persists the records changed since the last save to the
+// router's PerformanceStore. Records are copied under the lock and written
+// without it, so routing is not held up; a record changed during the save is
+// saved again next time. Without a PerformanceStore it does nothing.
+func (r *Router) SavePerformance(ctx context.Context) error {
+	if r.config.PerformanceStore == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	pending := make([]*ModelPerformance, 0, len(r.dirty))
+	for key := range r.dirty {
+		if perf, exists := r.performance[key]; exists {
+			pending = append(pending, clonePerformance(perf))
+		}
+	}
+	r.dirty = make(map[string]bool)
+	r.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return performanceKey(pending[i].Provider, pending[i].Model, pending[i].TaskType) <
+			performanceKey(pending[j].Provider, pending[j].Model, pending[j].TaskType)
+	})
+
+	if err := r.config.PerformanceStore.SavePerformance(ctx, pending); err != nil {
+		// Saving again writes the latest version, so every record is retried
+		r.mu.Lock()
+		for _, perf := range pending {
+			r.dirty[performanceKey(perf.Provider, perf.Model, perf.TaskType)] = true
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// performanceKey returns the key of a model's record for a task type.
+func performanceKey(provider, model, taskType string) string {
+	return fmt.Sprintf("%s_%s_%s", provider, model, taskType)
+}
+
+// performanceNodeID returns a filesystem-safe node ID for a performance key.
+func performanceNodeID(key string) string {
+	return modelPerformanceNodeType + "_" + strings.NewReplacer("/", "_", ":", "_", " ", "_").Replace(key)
+}
+
+// clonePerformance returns a copy of a performance record.
+func clonePerformance(perf *ModelPerformance) *ModelPerformance {
+	clone := *perf
+	return &clone
+}
+
+// mergePerformance combines two records of the same model and task type,
+// weighting each average by the effective samples behind it when the later
+// of the two was updated.
+func mergePerformance(a, b *ModelPerformance, halfLife time.Duration) *ModelPerformance {
+	merged := clonePerformance(b)
+	merged.SampleCount = a.SampleCount + b.SampleCount
+	merged.latencySamples = a.latencySamples + b.latencySamples
+	merged.Completions = a.Completions + b.Completions
+	merged.Refusals = a.Refusals + b.Refusals
+	if a.LastUpdated.After(merged.LastUpdated) {
+		merged.LastUpdated = a.LastUpdated
+	}
+
+	weighted := func(x, y, xn, yn float64) float64 {
+		if xn+yn == 0 {
+			return y
+		}
+		return (x*xn + y*yn) / (xn + yn)
+	}
+	aWeight := a.EffectiveSamplesAt(merged.LastUpdated, halfLife)
+	bWeight := b.EffectiveSamplesAt(merged.LastUpdated, halfLife)
+	merged.EffectiveSamples = aWeight + bWeight
+	merged.SuccessRate = weighted(a.SuccessRate, b.SuccessRate, aWeight, bWeight)
+	merged.AverageRating = weighted(a.AverageRating, b.AverageRating, aWeight, bWeight)
+	merged.AverageCost = weighted(a.AverageCost, b.AverageCost, aWeight, bWeight)
+	merged.AverageLatency = time.Duration(weighted(float64(a.AverageLatency), float64(b.AverageLatency), float64(a.latencySamples), float64(b.latencySamples)))
+	return merged
+}
+
+// performanceToNodeData converts a performance record to storage node data.
+func performanceToNodeData(perf *ModelPerformance) map[string]interface{} {
+	data := map[string]interface{}{
+		"provider":           perf.Provider,
+		"model":              perf.Model,
+		"task_type":          perf.TaskType,
+		"success_rate":       perf.SuccessRate,
+		"average_rating":     perf.AverageRating,
+		"average_cost":       perf.AverageCost,
+		"average_latency_ms": float64(perf.AverageLatency) / float64(time.Millisecond),
+		"latency_samples":    perf.latencySamples,
+		"sample_count":       perf.SampleCount,
+		"effective_samples":  perf.EffectiveSamples,
+		"last_updated":       perf.LastUpdated.Format(time.RFC3339Nano),
+		"drifting":           perf.Drifting,
+		"completions":        perf.Completions,
+		"refusals":           perf.Refusals,
+	}
+	if !perf.DriftDetectedAt.IsZero() {
+		data["drift_detected_at"] = perf.DriftDetectedAt.Format(time.RFC3339Nano)
+	}
+	return data
+}
+
+// nodeToPerformance converts a persisted node back into a performance record,
+// or returns nil if the node does not identify a model and task type.
+//
+// Older records lack the latency and refusal fields. Their latency average is
+// treated as having no samples, so the next recorded latency replaces it
+// instead of being averaged with zero. Records from before samples decayed
+// count every sample at full weight.
+func nodeToPerformance(node *storage.Node) *ModelPerformance {
+	provider, _ := node.Data["provider"].(string)
+	model, _ := node.Data["model"].(string)
+	taskType, _ := node.Data["task_type"].(string)
+	if provider == "" || model == "" {
+		return nil
+	}
+
+	perf := &ModelPerformance{
+		Provider:      provider,
+		Model:         model,
+		TaskType:      taskType,
+		SuccessRate:   performanceNumber(node.Data, "success_rate"),
+		AverageRating: performanceNumber(node.Data, "average_rating"),
+		AverageCost:   performanceNumber(node.Data, "average_cost"),
+		SampleCount:   int(performanceNumber(node.Data, "sample_count")),
+		Completions:   int(performanceNumber(node.Data, "completions")),
+		Refusals:      int(performanceNumber(node.Data, "refusals")),
+	}
+	perf.Drifting, _ = node.Data["drifting"].(bool)
+
+	perf.EffectiveSamples = float64(perf.SampleCount)
+	if _, ok := node.Data["effective_samples"]; ok {
+		perf.EffectiveSamples = performanceNumber(node.Data, "effective_samples")
+	}
+
+	if _, ok := node.Data["average_latency_ms"]; ok {
+		perf.AverageLatency = time.Duration(performanceNumber(node.Data, "average_latency_ms") * float64(time.Millisecond))
+		perf.latencySamples = perf.SampleCount
+		if _, ok := node.Data["latency_samples"]; ok {
+			perf.latencySamples = int(performanceNumber(node.Data, "latency_samples"))
+		}
+	}
+
+	if updated, ok := node.Data["last_updated"].(string); ok {
+		perf.LastUpdated, _ = time.Parse(time.RFC3339Nano, updated)
+	}
+	if detected, ok := node.Data["drift_detected_at"].(string); ok {
+		perf.DriftDetectedAt, _ = time.Parse(time.RFC3339Nano, detected)
+	}
+	return perf
+}
+
+// performanceNumber reads a numeric field, which is a float64 once the node
+// has been read back from disk and a Go integer or float before.
+func performanceNumber(data map[string]interface{}, key string) float64 {
+	switch v := data[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
@@ -0,0 +1,233 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// newPersistentRouter opens the store in dir and creates a router persisting
+// to it, as the CLI does on startup.
+func newPersistentRouter(t *testing.T, dir string) (*Router, *storage.Store) {
+	t.Helper()
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	config := DefaultRouterConfig()
+	config.PerformanceStore = NewStoragePerformanceStore(store)
+	router := NewRouter(NewMockLLMService(), config)
+	if err := router.LoadPerformance(context.Background()); err != nil {
+		t.Fatalf("Failed to load performance: %v", err)
+	}
+	return router, store
+}
+
+func TestPerformancePersistsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	router, store := newPersistentRouter(t, dir)
+	router.RecordPerformance("anthropic", "claude-3-haiku", "analysis", 0.01, 8, 200*time.Millisecond, true)
+	router.RecordPerformance("anthropic", "claude-3-haiku", "analysis", 0.03, 6, 400*time.Millisecond, false)
+	router.RecordPerformance("openai", "gpt-4", "writing", 0.10, 9, time.Second, true)
+	router.recordCompletion("openai", "gpt-4", "writing", true)
+	router.SetDrift("openai", "gpt-4", "writing", true)
+	if err := router.SavePerformance(ctx); err != nil {
+		t.Fatalf("Failed to save performance: %v", err)
+	}
+	want := router.GetPerformanceStats()
+	store.Close()
+
+	restarted, store := newPersistentRouter(t, dir)
+	defer store.Close()
+	got := restarted.GetPerformanceStats()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d records after restart, got %d", len(want), len(got))
+	}
+	for key, expected := range want {
+		actual, exists := got[key]
+		if !exists {
+			t.Errorf("Record %s was lost", key)
+			continue
+		}
+		if !actual.LastUpdated.Equal(expected.LastUpdated) || !actual.DriftDetectedAt.Equal(expected.DriftDetectedAt) {
+			t.Errorf("Record %s times changed: got %v/%v, want %v/%v", key, actual.LastUpdated, actual.DriftDetectedAt, expected.LastUpdated, expected.DriftDetectedAt)
+		}
+		actual.LastUpdated, expected.LastUpdated = time.Time{}, time.Time{}
+		actual.DriftDetectedAt, expected.DriftDetectedAt = time.Time{}, time.Time{}
+		if *actual != *expected {
+			t.Errorf("Record %s changed across restart:\n got %+v\nwant %+v", key, *actual, *expected)
+		}
+	}
+
+	// Nothing changed since the load, so nothing is written
+	sequence := store.Sequence()
+	if err := restarted.SavePerformance(ctx); err != nil {
+		t.Fatalf("Failed to save performance: %v", err)
+	}
+	if store.Sequence() != sequence {
+		t.Error("Expected a save without changes to write nothing")
+	}
+}
+
+func TestPerformanceMigratesOlderRecords(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	// Written before latency and refusals were tracked
+	old := storage.NewNodeWithID(performanceNodeID("anthropic_claude-3-haiku_analysis"), modelPerformanceNodeType, map[string]interface{}{
+		"provider":       "anthropic",
+		"model":          "claude-3-haiku",
+		"task_type":      "analysis",
+		"success_rate":   0.5,
+		"average_rating": 7.0,
+		"average_cost":   0.02,
+		"sample_count":   10,
+		"last_updated":   time.Now().Format(time.RFC3339),
+	})
+	if err := store.AddNode(context.Background(), old); err != nil {
+		t.Fatalf("Failed to add old record: %v", err)
+	}
+	store.Close()
+
+	router, store := newPersistentRouter(t, dir)
+	defer store.Close()
+	router.RecordPerformance("anthropic", "claude-3-haiku", "analysis", 0.02, 7, 300*time.Millisecond, true)
+
+	perf := router.GetPerformanceStats()["anthropic_claude-3-haiku_analysis"]
+	if perf == nil {
+		t.Fatal("Expected the old record to load")
+	}
+	if perf.SampleCount != 11 || perf.SuccessRate <= 0.5 {
+		t.Errorf("Expected the old samples to count, got %d samples at %.2f success", perf.SampleCount, perf.SuccessRate)
+	}
+	if perf.AverageLatency != 300*time.Millisecond {
+		t.Errorf("Expected the first recorded latency to stand alone, got %v", perf.AverageLatency)
+	}
+}
+
+func TestPerformanceSaveDuringRecording(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	router, store := newPersistentRouter(t, dir)
+
+	const workers, samples = 4, 50
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < samples; i++ {
+				router.RecordPerformance("anthropic", "claude-3-haiku", fmt.Sprintf("task-%d", w), 0.01, 8, time.Duration(i)*time.Millisecond, i%2 == 0)
+			}
+		}(w)
+	}
+	saveErrs := make(chan error, 1)
+	go func() {
+		defer close(saveErrs)
+		for i := 0; i < 20; i++ {
+			if err := router.SavePerformance(ctx); err != nil {
+				saveErrs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	if err := <-saveErrs; err != nil {
+		t.Fatalf("Save during recording failed: %v", err)
+	}
+
+	// Records changed after a save are saved by the next one
+	if err := router.SavePerformance(ctx); err != nil {
+		t.Fatalf("Failed to save performance: %v", err)
+	}
+	store.Close()
+
+	restarted, store := newPersistentRouter(t, dir)
+	defer store.Close()
+	stats := restarted.GetPerformanceStats()
+	for w := 0; w < workers; w++ {
+		perf := stats[fmt.Sprintf("anthropic_claude-3-haiku_task-%d", w)]
+		if perf == nil || perf.SampleCount != samples {
+			t.Errorf("Expected %d saved samples for worker %d, got %+v", samples, w, perf)
+		}
+	}
+}
+
+// failingPerformanceStore fails its saves until told not to.
+type failingPerformanceStore struct {
+	fail  bool
+	saved []*ModelPerformance
+}
+
+func (s *failingPerformanceStore) LoadPerformance(ctx context.Context) ([]*ModelPerformance, error) {
+	return nil, nil
+}
+
+func (s *failingPerformanceStore) SavePerformance(ctx context.Context, records []*ModelPerformance) error {
+	if s.fail {
+		return errors.New("disk full")
+	}
+	s.saved = append(s.saved, records...)
+	return nil
+}
+
+func TestPerformanceSaveRetriesAfterFailure(t *testing.T) {
+	perfStore := &failingPerformanceStore{fail: true}
+	config := DefaultRouterConfig()
+	config.Clock = utils.NewFakeClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	config.PerformanceStore = perfStore
+	router := NewRouter(NewMockLLMService(), config)
+
+	router.RecordPerformance("openai", "gpt-4", "writing", 0.1, 9, time.Second, true)
+	if err := router.SavePerformance(context.Background()); err == nil {
+		t.Fatal("Expected the failed save to be reported")
+	}
+
+	perfStore.fail = false
+	if err := router.SavePerformance(context.Background()); err != nil {
+		t.Fatalf("Failed to save performance: %v", err)
+	}
+	if len(perfStore.saved) != 1 || perfStore.saved[0].SampleCount != 1 {
+		t.Errorf("Expected the unsaved record on the next save, got %+v", perfStore.saved)
+	}
+
+	// Records loaded after some were recorded are combined with them
+	perfStore.saved[0].SampleCount = 3
+	perfStore.saved[0].EffectiveSamples = 3
+	perfStore.saved[0].SuccessRate = 0
+	loader := &loadingPerformanceStore{records: perfStore.saved}
+	config.PerformanceStore = loader
+	merged := NewRouter(NewMockLLMService(), config)
+	merged.RecordPerformance("openai", "gpt-4", "writing", 0.1, 9, time.Second, true)
+	if err := merged.LoadPerformance(context.Background()); err != nil {
+		t.Fatalf("Failed to load performance: %v", err)
+	}
+	perf := merged.GetPerformanceStats()["openai_gpt-4_writing"]
+	if perf.SampleCount != 4 || perf.SuccessRate != 0.25 {
+		t.Errorf("Expected 4 samples at 0.25 success, got %d at %.2f", perf.SampleCount, perf.SuccessRate)
+	}
+}
+
+// loadingPerformanceStore returns fixed records.
+type loadingPerformanceStore struct {
+	records []*ModelPerformance
+}
+
+func (s *loadingPerformanceStore) LoadPerformance(ctx context.Context) ([]*ModelPerformance, error) {
+	return s.records, nil
+}
+
+func (s *loadingPerformanceStore) SavePerformance(ctx context.Context, records []*ModelPerformance) error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// ErrProviderRefused is returned when every provider tried declined the
+// request on content-policy grounds.
+var ErrProviderRefused = errs.New(errs.PolicyBlocked, "provider refused the request")
+
+// refusalFinishReasons are finish reasons providers report when a completion
+// was withheld by their content policy.
+var refusalFinishReasons = map[string]bool{
+	"refusal":        true,
+	"content_filter": true,
+}
+
+// refusalTextLimit is the longest response the text patterns are checked
+// against. A refusal is short; a long answer that happens to open with
+// "I can't" is an answer.
+const refusalTextLimit = 400
+
+// refusalPatterns match the opening of a completion that declines the
+// request. They are anchored to the start and kept narrow, so that answers
+// such as "I don't know" or "I can't find that in the file" do not match.
+var refusalPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(i'm sorry|i am sorry|sorry|unfortunately)?[,.]?\s*(but\s+)?i(( can't| cannot| can not| won't| will not)|('m| am) (unable|not able) to) (help|assist) (you )?with (that|this|your request|this request)\b`),
+	regexp.MustCompile(`^(i'm sorry|i am sorry|sorry)?[,.]?\s*(but\s+)?i(( can't| cannot| won't)|('m| am) unable to) (comply|fulfill|fulfil) (with )?(that|this|your)\b`),
+	regexp.MustCompile(`^(i'm sorry|i am sorry|sorry)?[,.]?\s*(but\s+)?i (must|have to) decline\b`),
+	regexp.MustCompile(`^(i'm sorry|i am sorry|sorry)?[,.]?\s*(but\s+)?(this|that|your) request (violates|goes against|is against) (my|our|the) (content |usage )?(policy|policies|guidelines)\b`),
+}
+
+// DetectRefusal reports whether a completion is a content-policy refusal,
+// and why. The provider's finish reason is trusted when it names one; the
+// text patterns only apply to short completions. Returns "" for anything
+// else, including empty completions and uncertain answers.
+func DetectRefusal(response *mcp.CompletionResponse) string {
+	if response == nil {
+		return ""
+	}
+	if reason, ok := response.Metadata["finish_reason"].(string); ok && refusalFinishReasons[reason] {
+		return "finish reason " + reason
+	}
+
+	text := strings.TrimSpace(response.Text)
+	if text == "" || len(text) > refusalTextLimit {
+		return ""
+	}
+	text = strings.ToLower(strings.NewReplacer("’", "'", "‘", "'").Replace(text))
+	for _, pattern := range refusalPatterns {
+		if pattern.MatchString(text) {
+			return "declined: " + firstSentence(text)
+		}
+	}
+	return ""
+}
+
+// firstSentence returns the text up to the end of its first sentence.
+func firstSentence(text string) string {
+	if end := strings.IndexAny(text, ".!\n"); end >= 0 {
+		return text[:end]
+	}
+	return text
+}
+
+// sensitivePromptPatterns mark prompts whose refusals are respected as
+// they are: they are never rephrased or sent to another provider.
+var sensitivePromptPatterns = regexp.MustCompile(`\b(weapons?|explosives?|bombs?|firearms?|ammunition|malware|ransomware|keyloggers?|exploits?|zero-day|phishing|poisons?|toxins?|nerve agents?|bioweapons?|self-harm|suicide|overdose|narcotics|meth|synthesi[sz]e drugs?|bypass (security|authentication)|steal(ing)? (credentials|passwords)|credit card numbers|social security numbers)\b`)
+
+// refusalRetryBlocked reports why a refused request must not be retried,
+// or "" if a retry is allowed.
+func refusalRetryBlocked(req TaskRequest) string {
+	if req.Sensitive {
+		return "request marked sensitive"
+	}
+	if match := sensitivePromptPatterns.FindString(strings.ToLower(req.text())); match != "" {
+		return fmt.Sprintf("prompt matches the sensitive pattern %q", match)
+	}
+	return ""
+}
+
+// rephraseTemplate frames a refused request with the context it was made
+// in. It states the legitimate purpose the request already had; it does not
+// ask the model to set its policy aside.
+const rephraseTemplate = `The following is a routine request made by a personal work assistant on behalf of its user, as part of their ordinary work (task type: %s). It has a legitimate purpose; if any part of it is genuinely out of bounds, help with the rest and say briefly what was left out.
+
+%s`
+
+// rephraseRequest returns the single retry of a refused request: its prompt,
+// or the last turn of its conversation, framed by rephraseTemplate.
+func rephraseRequest(req TaskRequest) TaskRequest {
+	taskType := req.TaskType
+	if taskType == "" {
+		taskType = "general"
+	}
+	if len(req.Messages) == 0 {
+		req.Prompt = fmt.Sprintf(rephraseTemplate, taskType, req.Prompt)
+		return req
+	}
+
+	messages := append([]mcp.Message(nil), req.Messages...)
+	last := &messages[len(messages)-1]
+	last.Content = fmt.Sprintf(rephraseTemplate, taskType, last.Content)
+	req.Messages = messages
+	return req
+}
+
+// ModelRefusal is a completion routing received and classified as a refusal.
+type ModelRefusal struct {
+	Model  ModelRecommendation
+	Reason string
+
+	// Rephrased is set when the refused prompt was the rephrased retry
+	Rephrased bool
+}
@@ -0,0 +1,251 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// refusingService refuses a set number of completion requests per provider,
+// then answers. It cannot list its models.
+type refusingService struct {
+	refusals map[string]int
+	calls    []refusalCall
+}
+
+type refusalCall struct {
+	provider  string
+	model     string
+	rephrased bool
+}
+
+func (s *refusingService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	if params["operation"] != "complete" {
+		return mcp.ErrorResult(errors.New("unsupported operation"))
+	}
+	provider, _ := params["provider"].(string)
+	model, _ := params["model"].(string)
+	prompt, _ := params["prompt"].(string)
+	s.calls = append(s.calls, refusalCall{provider, model, strings.HasPrefix(prompt, "The following is a routine request")})
+
+	text := "Here is the summary you asked for."
+	if s.refusals[provider] > 0 {
+		s.refusals[provider]--
+		text = "I'm sorry, but I can't help with that."
+	}
+	return mcp.SuccessResult(&mcp.CompletionResponse{Text: text, TokensUsed: 20, Model: model, Provider: provider})
+}
+
+func (s *refusingService) rephrasedCalls() int {
+	count := 0
+	for _, call := range s.calls {
+		if call.rephrased {
+			count++
+		}
+	}
+	return count
+}
+
+func refusalRequest() TaskRequest {
+	return TaskRequest{
+		Prompt:          "Summarize the quarterly report",
+		TaskType:        "summarization",
+		QualityRequired: QualityStandard,
+		MaxTokens:       500,
+	}
+}
+
+// topModel returns the model routing tries first for req.
+func topModel(r *Router, req TaskRequest) ModelRecommendation {
+	return r.scoreModels(r.getAvailableModels(), r.assessTask(req), req)[0]
+}
+
+func TestDetectRefusal(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *mcp.CompletionResponse
+		refusal  bool
+	}{
+		{"apology", &mcp.CompletionResponse{Text: "I'm sorry, but I can't help with that."}, true},
+		{"plain", &mcp.CompletionResponse{Text: "I cannot assist with this request."}, true},
+		{"curly apostrophe", &mcp.CompletionResponse{Text: "Sorry, I won’t help with that."}, true},
+		{"decline", &mcp.CompletionResponse{Text: "I must decline to write that email."}, true},
+		{"policy", &mcp.CompletionResponse{Text: "This request violates my content policy."}, true},
+		{"comply", &mcp.CompletionResponse{Text: "I'm unable to comply with that request."}, true},
+		{"content filter", &mcp.CompletionResponse{Metadata: map[string]interface{}{"finish_reason": "content_filter"}}, true},
+		{"refusal stop reason", &mcp.CompletionResponse{Text: "No.", Metadata: map[string]interface{}{"finish_reason": "refusal"}}, true},
+
+		{"unknown answer", &mcp.CompletionResponse{Text: "I don't know."}, false},
+		{"unsure", &mcp.CompletionResponse{Text: "I'm not sure, but the report suggests revenue fell."}, false},
+		{"missing input", &mcp.CompletionResponse{Text: "I can't find that function in the file you attached."}, false},
+		{"quoted refusal", &mcp.CompletionResponse{Text: "The customer wrote: I can't help with that."}, false},
+		{"unfortunately", &mcp.CompletionResponse{Text: "Unfortunately the build failed on the second step."}, false},
+		{"long answer", &mcp.CompletionResponse{Text: "I can't help with that directly, but " + strings.Repeat("here is a detailed plan. ", 30)}, false},
+		{"normal stop", &mcp.CompletionResponse{Text: "Done.", Metadata: map[string]interface{}{"finish_reason": "end_turn"}}, false},
+		{"empty", &mcp.CompletionResponse{}, false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := DetectRefusal(tt.response)
+			if (reason != "") != tt.refusal {
+				t.Errorf("DetectRefusal = %q, want refusal %v", reason, tt.refusal)
+			}
+		})
+	}
+}
+
+func TestRouteRephrasesRefusalOnce(t *testing.T) {
+	service := &refusingService{refusals: map[string]int{}}
+	router := NewRouter(service)
+	req := refusalRequest()
+	primary := topModel(router, req)
+	scoreBefore := primary.OverallScore
+	service.refusals[primary.Provider] = 1
+
+	result, err := router.Route(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if result.SelectedModel.Model != primary.Model || !result.Rephrased {
+		t.Errorf("Expected the rephrased retry on %s to answer, got %s (rephrased %v)", primary.Model, result.SelectedModel.Model, result.Rephrased)
+	}
+	if len(result.Refusals) != 1 || result.Refusals[0].Rephrased {
+		t.Errorf("Expected one refusal of the original prompt, got %+v", result.Refusals)
+	}
+	if len(service.calls) != 2 || service.rephrasedCalls() != 1 {
+		t.Errorf("Expected the original and one rephrased call, got %+v", service.calls)
+	}
+
+	stats := router.GetPerformanceStats()[primary.Provider+"_"+primary.Model+"_summarization"]
+	if stats == nil || stats.Completions != 2 || stats.Refusals != 1 || stats.RefusalRate() != 0.5 {
+		t.Fatalf("Expected a 50%% refusal rate over 2 completions, got %+v", stats)
+	}
+
+	var after ModelRecommendation
+	for _, rec := range router.scoreModels(router.getAvailableModels(), router.assessTask(req), req) {
+		if rec.Model == primary.Model {
+			after = rec
+		}
+	}
+	if after.OverallScore >= scoreBefore || !strings.Contains(after.Reasoning, "refused 50%") {
+		t.Errorf("Expected the refusal rate to lower the score, got %.3f (was %.3f): %s", after.OverallScore, scoreBefore, after.Reasoning)
+	}
+}
+
+func TestRouteFallsBackToAnotherProviderAfterRefusals(t *testing.T) {
+	service := &refusingService{refusals: map[string]int{}}
+	router := NewRouter(service)
+	logger := newTestExchangeLogger(t, ExchangeLogConfig{SampleRate: 0})
+	router.SetExchangeLogger(logger)
+	req := refusalRequest()
+	primary := topModel(router, req)
+	service.refusals[primary.Provider] = 10
+
+	result, err := router.Route(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Route should fall back to another provider: %v", err)
+	}
+	if result.SelectedModel.Provider == primary.Provider {
+		t.Errorf("Expected a provider other than %s, got %s", primary.Provider, result.SelectedModel.Provider)
+	}
+	if len(result.Refusals) != 2 || !result.Refusals[1].Rephrased {
+		t.Errorf("Expected the original and rephrased refusals, got %+v", result.Refusals)
+	}
+	for _, exclusion := range result.ExcludedModels {
+		if exclusion.Model.Provider == primary.Provider && exclusion.Reason != ExclusionRefused {
+			t.Errorf("Expected %s to be skipped as refused, got %s", exclusion.Model.Model, exclusion.Reason)
+		}
+	}
+	for _, call := range service.calls[2:] {
+		if call.provider == primary.Provider || call.rephrased {
+			t.Errorf("Fallback should send the original prompt to another provider, got %+v", call)
+		}
+	}
+
+	// Both refusals are logged whatever the sample rate
+	records, err := logger.Tail(10)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	var refused, rephrased int
+	for _, record := range records {
+		if record.LoggedBecause == "refusal" {
+			refused++
+		}
+		if record.Rephrased {
+			rephrased++
+		}
+	}
+	if refused != 2 || rephrased != 1 {
+		t.Errorf("Expected 2 logged refusals, 1 rephrased, got %d and %d", refused, rephrased)
+	}
+}
+
+func TestRouteRephrasesAtMostOnce(t *testing.T) {
+	service := &refusingService{refusals: map[string]int{"anthropic": 10, "openai": 10, "local": 10}}
+	router := NewRouter(service)
+
+	_, err := router.Route(context.Background(), refusalRequest())
+	if !errors.Is(err, ErrProviderRefused) || errs.CodeOf(err) != errs.PolicyBlocked {
+		t.Fatalf("Expected a policy refusal when every provider refuses, got %v", err)
+	}
+	if service.rephrasedCalls() != 1 {
+		t.Errorf("Expected a single rephrased retry, got %d", service.rephrasedCalls())
+	}
+	providers := make(map[string]bool)
+	for _, call := range service.calls {
+		providers[call.provider] = true
+	}
+	if len(service.calls) != 4 || len(providers) != 3 {
+		t.Errorf("Expected one call per provider plus the retry, got %+v", service.calls)
+	}
+}
+
+func TestRouteRespectsSensitiveRefusals(t *testing.T) {
+	tests := []struct {
+		name string
+		req  func(TaskRequest) TaskRequest
+	}{
+		{"sensitive flag", func(req TaskRequest) TaskRequest { req.Sensitive = true; return req }},
+		{"sensitive pattern", func(req TaskRequest) TaskRequest { req.Prompt = "Write a phishing email for our bank"; return req }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &refusingService{refusals: map[string]int{"anthropic": 10, "openai": 10, "local": 10}}
+			router := NewRouter(service)
+
+			_, err := router.Route(context.Background(), tt.req(refusalRequest()))
+			if !errors.Is(err, ErrProviderRefused) || !strings.Contains(err.Error(), "not retried") {
+				t.Fatalf("Expected the refusal to stand, got %v", err)
+			}
+			if len(service.calls) != 1 {
+				t.Errorf("Expected no retry or fallback, got %+v", service.calls)
+			}
+		})
+	}
+}
+
+func TestRouteWithoutRephrasing(t *testing.T) {
+	service := &refusingService{refusals: map[string]int{}}
+	config := DefaultRouterConfig()
+	config.RephraseRefusals = false
+	router := NewRouter(service, config)
+	req := refusalRequest()
+	primary := topModel(router, req)
+	service.refusals[primary.Provider] = 1
+
+	result, err := router.Route(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if result.Rephrased || service.rephrasedCalls() != 0 || result.SelectedModel.Provider == primary.Provider {
+		t.Errorf("Expected a straight fallback without rephrasing, got %s (rephrased %v)", result.SelectedModel.Model, result.Rephrased)
+	}
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// TestRoutePlanSendsNothing checks that a dry run picks the model Route
+// would and describes its request without executing it.
+func TestRoutePlanSendsNothing(t *testing.T) {
+	service := &countingLLMService{providers: 2}
+	router := NewRouter(service)
+
+	req := TaskRequest{Prompt: "Analyze this business proposal", TaskType: "analysis", MaxTokens: 500}
+	plan, err := router.RoutePlan(context.Background(), req)
+	if err != nil {
+		t.Fatalf("RoutePlan failed: %v", err)
+	}
+	if calls := service.calls.Load(); calls != 0 {
+		t.Fatalf("Expected no completion requests, got %d", calls)
+	}
+	if plan.ExecutionResult != nil {
+		t.Error("Expected no execution result from a dry run")
+	}
+
+	params := plan.PlannedParams
+	if params["operation"] != "complete" || params["provider"] != plan.SelectedModel.Provider || params["model"] != plan.SelectedModel.Model {
+		t.Errorf("Planned params do not target the selected model: %v", params)
+	}
+	if params["prompt"] != req.Prompt {
+		t.Errorf("Expected the prompt in the planned params, got %v", params["prompt"])
+	}
+
+	ranked := append([]ModelRecommendation{plan.SelectedModel}, plan.AlternativeModels...)
+	for _, model := range ranked {
+		parts := model.Components
+		if sum := parts.Quality + parts.Cost + parts.Speed - parts.Penalty; math.Abs(sum-model.OverallScore) > 1e-9 {
+			t.Errorf("%s components sum to %f, overall score is %f", model.Model, sum, model.OverallScore)
+		}
+	}
+}
+
+// TestRoutePlanHonorsBudget checks that a dry run skips the models Route
+// would skip and fails as Route would when nothing is affordable.
+func TestRoutePlanHonorsBudget(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+	service := &anthropicOnlyService{}
+	router := NewRouter(service)
+	router.SetBudgetManager(budgetWithRemaining(t, clock, 0.02))
+
+	plan, err := router.RoutePlan(context.Background(), budgetRequest())
+	if err != nil {
+		t.Fatalf("RoutePlan failed: %v", err)
+	}
+	if plan.SelectedModel.Model != "claude-3-haiku" {
+		t.Errorf("Expected haiku to fit the remaining budget, got %s", plan.SelectedModel.Model)
+	}
+	if len(plan.ExcludedModels) != 1 || plan.ExcludedModels[0].Model.Model != "claude-3-sonnet" || plan.ExcludedModels[0].Reason != ExclusionOverBudget {
+		t.Errorf("Expected sonnet excluded as over budget, got %+v", plan.ExcludedModels)
+	}
+
+	router.SetBudgetManager(budgetWithRemaining(t, clock, 0.001))
+	_, err = router.RoutePlan(context.Background(), budgetRequest())
+	var exceeded *BudgetExceededError
+	if !errors.As(err, &exceeded) || exceeded.Model.Model != "claude-3-sonnet" {
+		t.Fatalf("Expected a BudgetExceededError for sonnet, got %v", err)
+	}
+	if len(service.calls) != 0 {
+		t.Errorf("Expected nothing to be executed, got calls %v", service.calls)
+	}
+}
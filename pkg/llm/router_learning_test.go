@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// listedModelService lists the given basic models, priced alike, and
+// completes any request with them.
+type listedModelService struct {
+	*MockLLMService
+	speedTiers map[string]int
+}
+
+func newListedModelService(speedTiers map[string]int) *listedModelService {
+	return &listedModelService{MockLLMService: NewMockLLMService(), speedTiers: speedTiers}
+}
+
+func (s *listedModelService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	if params["operation"] != "list_models" {
+		return s.MockLLMService.Execute(ctx, params)
+	}
+	var listings []mcp.ModelListing
+	for model, speed := range s.speedTiers {
+		listings = append(listings, mcp.ModelListing{
+			Provider: "local", Model: model, InputCost: 0.001, OutputCost: 0.002,
+			MaxTokens: 4096, ContextSize: 8192, SupportsChat: true, QualityTier: "basic", SpeedTier: speed,
+		})
+	}
+	return mcp.SuccessResult(listings)
+}
+
+// simulateQualityShift records a month of daily ratings in which alpha
+// outperforms beta, then ten days in which beta has become the better
+// model, and returns the model ranked first afterwards.
+func simulateQualityShift(t *testing.T, halfLife time.Duration) (string, *Router) {
+	t.Helper()
+	clock := utils.NewFakeClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	config := DefaultRouterConfig()
+	config.Clock = clock
+	config.PerformanceHalfLife = halfLife
+	router := NewRouter(newListedModelService(map[string]int{"alpha": 2, "beta": 2}), config)
+
+	day := func(alpha, beta float64) {
+		router.RecordPerformance("local", "alpha", "simple_qa", 0.001, alpha, time.Second, true)
+		router.RecordPerformance("local", "beta", "simple_qa", 0.001, beta, time.Second, true)
+		clock.Advance(24 * time.Hour)
+	}
+	for i := 0; i < 30; i++ {
+		day(9, 5)
+	}
+	if best := router.RankModels("simple_qa")[0].Model; best != "alpha" {
+		t.Fatalf("Expected alpha ranked first while it performs better, got %s", best)
+	}
+	for i := 0; i < 10; i++ {
+		day(4, 8)
+	}
+	return router.RankModels("simple_qa")[0].Model, router
+}
+
+func TestRouterAdaptsToQualityShift(t *testing.T) {
+	best, router := simulateQualityShift(t, 7*24*time.Hour)
+	if best != "beta" {
+		t.Errorf("Expected decayed history to let beta overtake alpha, got %s", best)
+	}
+	perf := router.GetPerformanceStats()["local_alpha_simple_qa"]
+	if perf.SampleCount != 40 || perf.EffectiveSamples >= 15 || perf.AverageRating >= 6.5 {
+		t.Errorf("Expected old samples to count for less, got %d samples weighing %.1f at %.1f", perf.SampleCount, perf.EffectiveSamples, perf.AverageRating)
+	}
+
+	// Without decay the month of good results still outweighs the shift
+	if best, _ := simulateQualityShift(t, 0); best != "alpha" {
+		t.Errorf("Expected undecayed history to keep alpha first, got %s", best)
+	}
+}
+
+func TestRouterTrustDecays(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	config := DefaultRouterConfig()
+	config.Clock = clock
+	router := NewRouter(NewMockLLMService(), config)
+
+	for i := 0; i < config.MinSampleSize; i++ {
+		router.RecordPerformance("anthropic", "claude-3-haiku", "analysis", 0.01, 6, time.Second, true)
+	}
+	perf := router.getPerformance("anthropic", "claude-3-haiku", "analysis")
+	if !router.trusted(perf) {
+		t.Fatal("Expected fresh samples to be trusted")
+	}
+
+	// Two half-lives later the samples weigh as one and a quarter
+	clock.Advance(2 * config.PerformanceHalfLife)
+	if samples := perf.EffectiveSamplesAt(clock.Now(), config.PerformanceHalfLife); samples < 1.24 || samples > 1.26 {
+		t.Errorf("Expected 1.25 effective samples, got %.3f", samples)
+	}
+	if router.trusted(perf) {
+		t.Error("Expected decayed samples to no longer be trusted")
+	}
+}
+
+func TestRouterExploration(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultRouterConfig()
+	config.ExplorationRate = 0.1
+	draw := 0.0
+	config.Random = func() float64 { return draw }
+
+	// alpha is fastest, gamma is trusted and beta has never been tried
+	router := NewRouter(newListedModelService(map[string]int{"alpha": 1, "gamma": 2, "beta": 3}), config)
+	for i := 0; i < config.MinSampleSize; i++ {
+		router.RecordPerformance("local", "gamma", "simple_qa", 0.001, 10, time.Second, true)
+	}
+	basic := TaskRequest{Prompt: "What is the capital of France?", TaskType: "simple_qa", QualityRequired: QualityBasic}
+
+	draw = 0.5
+	result, err := router.Route(ctx, basic)
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	if result.Exploratory || result.SelectedModel.Model != "alpha" {
+		t.Errorf("Expected alpha without exploration, got %s (exploratory %v)", result.SelectedModel.Model, result.Exploratory)
+	}
+
+	draw = 0.05
+	result, err = router.Route(ctx, basic)
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	if !result.Exploratory || result.SelectedModel.Model != "beta" {
+		t.Fatalf("Expected the untried beta explored, got %s (exploratory %v)", result.SelectedModel.Model, result.Exploratory)
+	}
+	if !strings.Contains(result.SelectedModel.Reasoning, "explored") {
+		t.Errorf("Expected the reasoning to mention exploration, got %q", result.SelectedModel.Reasoning)
+	}
+	if len(result.AlternativeModels) != 2 || result.AlternativeModels[0].Model != "alpha" || result.AlternativeModels[1].Model != "gamma" {
+		t.Errorf("Expected the other models kept in order as fallbacks, got %+v", result.AlternativeModels)
+	}
+
+	// Premium quality and ethical analysis are never explored
+	draw = 0
+	for _, req := range []TaskRequest{
+		{Prompt: "Draft the keynote", TaskType: "simple_qa", QualityRequired: QualityPremium},
+		{Prompt: "Is this fair to the user?", TaskType: "ethical_analysis", QualityRequired: QualityBasic},
+	} {
+		result, err := router.Route(ctx, req)
+		if err != nil {
+			t.Fatalf("Routing failed: %v", err)
+		}
+		if result.Exploratory || result.SelectedModel.Model == "beta" {
+			t.Errorf("Expected %s at %v not explored, got %s", req.TaskType, req.QualityRequired, result.SelectedModel.Model)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package llm
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// DefaultRecentRoutings is how many routings are remembered for feedback.
+const DefaultRecentRoutings = 500
+
+// RoutingIDPrefix starts every routing ID.
+const RoutingIDPrefix = "rt-"
+
+// routingRecordNodeType is the storage node type of persisted routings.
+const routingRecordNodeType = "routing_record"
+
+// ErrRoutingExpired is returned for feedback on a routing that is no longer
+// remembered.
+var ErrRoutingExpired = errs.New(errs.NotFound, "routing has expired")
+
+// RoutingRecord is what the router remembers about a routing, so that it can
+// be rated after the fact.
+type RoutingRecord struct {
+	ID       string
+	Provider string
+	Model    string
+	TaskType string
+	Cost     float64
+	Latency  time.Duration
+	RoutedAt time.Time
+
+	// TransactionID is the budget transaction the completion was recorded
+	// under, or "" when the router had no budget manager
+	TransactionID string
+
+	// Rating (1-10) and Comment are the feedback on the routing; Rating is
+	// 0 until it is rated
+	Rating  float64
+	Comment string
+	RatedAt time.Time
+}
+
+// RoutingStore persists the routings the router remembers.
+type RoutingStore interface {
+	// SaveRouting persists a record, replacing an earlier version with the
+	// same ID.
+	SaveRouting(ctx context.Context, record *RoutingRecord) error
+
+	// LoadRouting returns a persisted record. It fails with errs.NotFound
+	// for unknown IDs, wrapping ErrRoutingExpired for evicted ones.
+	LoadRouting(ctx context.Context, id string) (*RoutingRecord, error)
+}
+
+// newRoutingID returns a routing ID short enough to type.
+func newRoutingID() string {
+	return RoutingIDPrefix + strings.ReplaceAll(uuid.New().String(), "-", "")[:8]
+}
+
+// routingMemory is a least recently used cache of routings.
+type routingMemory struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Most recently used first
+	byID     map[string]*list.Element
+}
+
+func newRoutingMemory(capacity int) *routingMemory {
+	return &routingMemory{capacity: capacity, order: list.New(), byID: make(map[string]*list.Element)}
+}
+
+// put remembers a copy of record as the most recently used, evicting the
+// least recently used beyond capacity.
+func (m *routingMemory) put(record *RoutingRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *record
+	if element, exists := m.byID[record.ID]; exists {
+		element.Value = &stored
+		m.order.MoveToFront(element)
+		return
+	}
+	m.byID[record.ID] = m.order.PushFront(&stored)
+	for m.order.Len() > m.capacity {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.byID, oldest.Value.(*RoutingRecord).ID)
+	}
+}
+
+// get returns a copy of the remembered record, marking it used.
+func (m *routingMemory) get(id string) (*RoutingRecord, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, exists := m.byID[id]
+	if !exists {
+		return nil, false
+	}
+	m.order.MoveToFront(element)
+	record := *element.Value.(*RoutingRecord)
+	return &record, true
+}
+
+// rememberRouting remembers a routing for feedback, persisting it when the
+// router has a RoutingStore. A routing that fails to persist can still be
+// rated until this router forgets it.
+func (r *Router) rememberRouting(ctx context.Context, record *RoutingRecord) {
+	if r.budget != nil {
+		record.TransactionID = record.ID
+	}
+	r.routings.put(record)
+	if r.config.RoutingStore == nil {
+		return
+	}
+	if err := r.config.RoutingStore.SaveRouting(ctx, record); err != nil {
+		log.Printf("Warning: failed to save routing %s: %v", record.ID, err)
+	}
+}
+
+// SubmitFeedback rates a routing (1-10) with an optional comment. The rating
+// is recorded against the routed model with RecordPerformance, so routing
+// learns from it, and, when the router has a budget manager and the routing
+// a transaction, as the transaction's quality. Each routing can be rated
+// once. Routings the router no longer remembers, in memory or in its
+// RoutingStore, cannot be rated: unknown IDs fail with errs.NotFound, and
+// IDs the store evicted with ErrRoutingExpired.
+func (r *Router) SubmitFeedback(ctx context.Context, routingID string, rating float64, comment string) (*RoutingRecord, error) {
+	if rating < 1 || rating > 10 {
+		return nil, errs.Newf(errs.Validation, "rating must be between 1 and 10, got %g", rating)
+	}
+
+	// Lookups and saves of one routing must not interleave, or it could be
+	// rated twice
+	r.feedbackMu.Lock()
+	defer r.feedbackMu.Unlock()
+
+	record, err := r.lookupRouting(ctx, routingID)
+	if err != nil {
+		return nil, err
+	}
+	if record.Rating > 0 {
+		return nil, errs.Newf(errs.Conflict, "routing %s was already rated %g", routingID, record.Rating).
+			With("routing_id", routingID)
+	}
+
+	record.Rating = rating
+	record.Comment = comment
+	record.RatedAt = r.config.Clock.Now()
+	if r.config.RoutingStore != nil {
+		if err := r.config.RoutingStore.SaveRouting(ctx, record); err != nil {
+			return nil, fmt.Errorf("failed to save feedback on routing %s: %w", routingID, err)
+		}
+	}
+	r.routings.put(record)
+
+	r.RecordPerformance(record.Provider, record.Model, record.TaskType, record.Cost, rating, record.Latency, true)
+
+	if r.budget != nil && record.TransactionID != "" {
+		if err := r.budget.RecordQuality(ctx, record.TransactionID, rating); err != nil {
+			log.Printf("Warning: failed to record the quality of routing %s: %v", routingID, err)
+		}
+	}
+	return record, nil
+}
+
+// lookupRouting returns a remembered routing. The store, which other
+// processes rate through too, is asked first; memory covers routings that
+// failed to persist.
+func (r *Router) lookupRouting(ctx context.Context, routingID string) (*RoutingRecord, error) {
+	var err error
+	if r.config.RoutingStore != nil {
+		var record *RoutingRecord
+		if record, err = r.config.RoutingStore.LoadRouting(ctx, routingID); err == nil {
+			return record, nil
+		}
+		if errors.Is(err, ErrRoutingExpired) {
+			return nil, err
+		}
+	}
+	if record, exists := r.routings.get(routingID); exists {
+		return record, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, errs.Newf(errs.NotFound, "unknown routing ID %q; only the last %d routings can be rated",
+		routingID, r.config.RecentRoutings).With("routing_id", routingID)
+}
+
+// StorageRoutingStore keeps routings as "routing_record" nodes, one per
+// routing. It keeps the capacity most recently used ones live and archives
+// the rest in batches, after which they count as expired.
+type StorageRoutingStore struct {
+	store    *storage.Store
+	capacity int
+}
+
+// NewStorageRoutingStore creates a routing store backed by store that keeps
+// capacity routings (default: DefaultRecentRoutings).
+func NewStorageRoutingStore(store *storage.Store, capacity int) *StorageRoutingStore {
+	if capacity <= 0 {
+		capacity = DefaultRecentRoutings
+	}
+	return &StorageRoutingStore{store: store, capacity: capacity}
+}
+
+// SaveRouting persists a record as a new version of its node, then archives
+// the least recently used records once a tenth more than capacity are live.
+// Nothing is written to a read-only store such as a replica.
+func (s *StorageRoutingStore) SaveRouting(ctx context.Context, record *RoutingRecord) error {
+	if s.store.IsReadOnly() {
+		return nil
+	}
+	node := storage.NewNodeWithID(record.ID, routingRecordNodeType, routingToNodeData(record))
+	if err := s.store.AddNode(ctx, node); err != nil {
+		return fmt.Errorf("failed to save routing %s: %w", record.ID, err)
+	}
+	return s.evict(ctx)
+}
+
+// evict archives the least recently used records beyond capacity. Records
+// are archived in batches, as every archiving writes a new segment.
+func (s *StorageRoutingStore) evict(ctx context.Context) error {
+	nodes, err := s.store.GetNodesByType(ctx, routingRecordNodeType)
+	if err != nil {
+		return fmt.Errorf("failed to list routings: %w", err)
+	}
+	if len(nodes) <= s.capacity+s.capacity/10 {
+		return nil
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ValidFrom.After(nodes[j].ValidFrom) })
+	expired := make([]string, 0, len(nodes)-s.capacity)
+	for _, node := range nodes[s.capacity:] {
+		expired = append(expired, node.ID)
+	}
+	if _, err := s.store.ArchiveNodes(ctx, expired); err != nil {
+		return fmt.Errorf("failed to archive expired routings: %w", err)
+	}
+	return nil
+}
+
+// LoadRouting returns a persisted record.
+func (s *StorageRoutingStore) LoadRouting(ctx context.Context, id string) (*RoutingRecord, error) {
+	node, err := s.store.GetNode(ctx, id)
+	if err != nil || node.Type != routingRecordNodeType {
+		return nil, errs.Newf(errs.NotFound, "unknown routing ID %q", id).With("routing_id", id)
+	}
+	if s.store.IsArchived(ctx, id) {
+		return nil, fmt.Errorf("%w: %s can no longer be rated; only the last %d routings are kept",
+			ErrRoutingExpired, id, s.capacity)
+	}
+	return nodeToRouting(node), nil
+}
+
+// routingToNodeData converts a routing record to storage node data.
+func routingToNodeData(record *RoutingRecord) map[string]interface{} {
+	data := map[string]interface{}{
+		"provider":   record.Provider,
+		"model":      record.Model,
+		"task_type":  record.TaskType,
+		"cost":       record.Cost,
+		"latency_ms": float64(record.Latency) / float64(time.Millisecond),
+		"routed_at":  record.RoutedAt.Format(time.RFC3339Nano),
+	}
+	if record.TransactionID != "" {
+		data["transaction_id"] = record.TransactionID
+	}
+	if record.Rating > 0 {
+		data["rating"] = record.Rating
+		data["comment"] = record.Comment
+		data["rated_at"] = record.RatedAt.Format(time.RFC3339Nano)
+	}
+	return data
+}
+
+// nodeToRouting converts a persisted node back into a routing record.
+func nodeToRouting(node *storage.Node) *RoutingRecord {
+	record := &RoutingRecord{
+		ID:      node.ID,
+		Cost:    performanceNumber(node.Data, "cost"),
+		Latency: time.Duration(performanceNumber(node.Data, "latency_ms") * float64(time.Millisecond)),
+		Rating:  performanceNumber(node.Data, "rating"),
+	}
+	record.Provider, _ = node.Data["provider"].(string)
+	record.Model, _ = node.Data["model"].(string)
+	record.TaskType, _ = node.Data["task_type"].(string)
+	record.TransactionID, _ = node.Data["transaction_id"].(string)
+	record.Comment, _ = node.Data["comment"].(string)
+	if routed, ok := node.Data["routed_at"].(string); ok {
+		record.RoutedAt, _ = time.Parse(time.RFC3339Nano, routed)
+	}
+	if rated, ok := node.Data["rated_at"].(string); ok {
+		record.RatedAt, _ = time.Parse(time.RFC3339Nano, rated)
+	}
+	return record
+}
@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+func feedbackRequest() TaskRequest {
+	return TaskRequest{
+		Prompt:          "Summarize the meeting notes",
+		TaskType:        "summarization",
+		QualityRequired: QualityStandard,
+		MaxTokens:       500,
+	}
+}
+
+func TestSubmitFeedback_RecordsPerformance(t *testing.T) {
+	ctx := context.Background()
+	router := NewRouter(NewMockLLMService())
+	budget, err := NewBudgetManager(t.TempDir(), journalConfig(), testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	router.SetBudgetManager(budget)
+
+	result, err := router.Route(ctx, feedbackRequest())
+	if err != nil {
+		t.Fatalf("Routing failed: %v", err)
+	}
+	if !strings.HasPrefix(result.ID, RoutingIDPrefix) {
+		t.Fatalf("Expected a routing ID, got %q", result.ID)
+	}
+
+	if _, err := router.SubmitFeedback(ctx, result.ID, 11, ""); errs.CodeOf(err) != errs.Validation {
+		t.Errorf("Expected a rating above 10 to be refused, got %v", err)
+	}
+	record, err := router.SubmitFeedback(ctx, result.ID, 8, "concise")
+	if err != nil {
+		t.Fatalf("SubmitFeedback failed: %v", err)
+	}
+	if record.Provider != result.SelectedModel.Provider || record.Model != result.SelectedModel.Model || record.Comment != "concise" {
+		t.Errorf("Expected the routed model with the comment, got %+v", record)
+	}
+
+	// The rating is learned from...
+	key := performanceKey(record.Provider, record.Model, "summarization")
+	if perf := router.GetPerformanceStats()[key]; perf == nil || perf.AverageRating != 8 {
+		t.Errorf("Expected the rating in the model's performance, got %+v", perf)
+	}
+
+	// ...and becomes the quality of the completion's transaction
+	transactions := budget.GetTransactions()
+	if len(transactions) != 1 || transactions[0].ID != result.ID || transactions[0].Quality != 8 {
+		t.Errorf("Expected the transaction rated 8, got %+v", transactions)
+	}
+	if roi := budget.usage.ProviderROI[record.Provider]; roi == nil || roi.AverageQuality != 8 {
+		t.Errorf("Expected the rating in the provider's ROI, got %+v", roi)
+	}
+
+	if _, err := router.SubmitFeedback(ctx, result.ID, 3, ""); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected a second rating to be refused, got %v", err)
+	}
+	if _, err := router.SubmitFeedback(ctx, "rt-00000000", 5, ""); errs.CodeOf(err) != errs.NotFound {
+		t.Errorf("Expected an unknown routing to be refused, got %v", err)
+	}
+}
+
+func TestSubmitFeedback_AcrossRouters(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := DefaultRouterConfig()
+	config.RoutingStore = NewStorageRoutingStore(store, 10)
+	router := NewRouter(NewMockLLMService(), config)
+
+	var ids []string
+	for i := 0; i < 12; i++ {
+		result, err := router.Route(ctx, feedbackRequest())
+		if err != nil {
+			t.Fatalf("Routing failed: %v", err)
+		}
+		ids = append(ids, result.ID)
+	}
+
+	// Another process, such as a later CLI command, rates a recent routing
+	other := NewRouter(NewMockLLMService(), config)
+	if _, err := other.SubmitFeedback(ctx, ids[11], 9, ""); err != nil {
+		t.Fatalf("SubmitFeedback failed: %v", err)
+	}
+	if _, err := other.SubmitFeedback(ctx, ids[11], 9, ""); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected a second rating to be refused, got %v", err)
+	}
+
+	// The oldest routings were evicted past a tenth over capacity
+	if _, err := other.SubmitFeedback(ctx, ids[0], 4, ""); !errors.Is(err, ErrRoutingExpired) {
+		t.Errorf("Expected the oldest routing to have expired, got %v", err)
+	}
+	if _, err := other.SubmitFeedback(ctx, ids[2], 4, ""); err != nil {
+		t.Errorf("Expected a routing within capacity to be rated, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// RoutingPolicy names a preset of the weights and limits routing scores
+// models with, for choosing a mode of work rather than tuning weights.
+type RoutingPolicy string
+
+const (
+	// PolicyDefault scores models with the router's configured weights
+	PolicyDefault RoutingPolicy = ""
+
+	// PolicyCostSaver favours the cheapest models, local ones first, and
+	// never picks a model priced above costSaverMaxInputCost
+	PolicyCostSaver RoutingPolicy = "cost-saver"
+
+	// PolicyBalanced weighs quality, cost and speed as the default router
+	// configuration does
+	PolicyBalanced RoutingPolicy = "balanced"
+
+	// PolicyQualityFirst picks the best model available regardless of cost
+	PolicyQualityFirst RoutingPolicy = "quality-first"
+)
+
+// RoutingPolicies lists the named policies, cheapest first.
+var RoutingPolicies = []RoutingPolicy{PolicyCostSaver, PolicyBalanced, PolicyQualityFirst}
+
+// costSaverMaxInputCost is the highest input price, per 1M tokens, of the
+// models PolicyCostSaver routes to.
+const costSaverMaxInputCost = 1.0
+
+// costSaverCostReference is the request cost PolicyCostSaver scores as no
+// cheaper than any other, so that even small costs count against a model.
+const costSaverCostReference = 0.001
+
+// PolicySettings are the scoring weights and limits a policy routes with.
+type PolicySettings struct {
+	QualityWeight    float64
+	CostWeight       float64
+	SpeedWeight      float64
+	ConservativeBias float64
+
+	// CostReference is the request cost whose cost score is zero (0: the
+	// router's MaxCostPerRequest)
+	CostReference float64
+
+	// MaxInputCost rules out models whose input costs more per 1M tokens
+	// (0: no ceiling)
+	MaxInputCost float64
+
+	// MinQuality raises the quality a request is assessed as needing
+	MinQuality QualityRequirement
+}
+
+// ParseRoutingPolicy returns the policy with the given name; "" is
+// PolicyDefault.
+func ParseRoutingPolicy(name string) (RoutingPolicy, error) {
+	policy := RoutingPolicy(strings.TrimSpace(name))
+	if policy == PolicyDefault {
+		return policy, nil
+	}
+	for _, known := range RoutingPolicies {
+		if policy == known {
+			return policy, nil
+		}
+	}
+	return PolicyDefault, errs.Newf(errs.Validation, "unknown routing policy %q (cost-saver, balanced or quality-first)", name).
+		With("policy", name)
+}
+
+// Settings returns the policy's weights and limits. PolicyDefault, and any
+// unknown policy, takes them from config.
+func (p RoutingPolicy) Settings(config RouterConfig) PolicySettings {
+	switch p {
+	case PolicyCostSaver:
+		return PolicySettings{
+			QualityWeight: 0.1,
+			CostWeight:    0.9,
+			CostReference: costSaverCostReference,
+			MaxInputCost:  costSaverMaxInputCost,
+		}
+	case PolicyBalanced:
+		defaults := DefaultRouterConfig()
+		return PolicySettings{
+			QualityWeight:    defaults.QualityWeight,
+			CostWeight:       defaults.CostWeight,
+			SpeedWeight:      defaults.SpeedWeight,
+			ConservativeBias: defaults.ConservativeBias,
+		}
+	case PolicyQualityFirst:
+		return PolicySettings{
+			QualityWeight:    0.9,
+			SpeedWeight:      0.1,
+			ConservativeBias: 0.3,
+			MinQuality:       QualityPremium,
+		}
+	default:
+		return PolicySettings{
+			QualityWeight:    config.QualityWeight,
+			CostWeight:       config.CostWeight,
+			SpeedWeight:      config.SpeedWeight,
+			ConservativeBias: config.ConservativeBias,
+		}
+	}
+}
+
+// String returns the policy's name, "default" for PolicyDefault.
+func (p RoutingPolicy) String() string {
+	if p == PolicyDefault {
+		return "default"
+	}
+	return string(p)
+}
+
+// SetPolicy sets the policy requests without a Policy of their own are
+// routed with.
+func (r *Router) SetPolicy(policy RoutingPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy = policy
+}
+
+// Policy returns the policy requests without a Policy of their own are
+// routed with.
+func (r *Router) Policy() RoutingPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.policy
+}
+
+// ApplyConfig updates the settings routing can change while it runs: the
+// policy, the weights PolicyDefault scores with and MaxCostPerRequest.
+// Weights cannot be negative and must not all be zero, and the cost per
+// request must be positive. The other fields of config are fixed when the
+// router is created and are ignored.
+func (r *Router) ApplyConfig(config RouterConfig) error {
+	weights := map[string]float64{
+		"quality": config.QualityWeight,
+		"cost":    config.CostWeight,
+		"speed":   config.SpeedWeight,
+	}
+	total := 0.0
+	for name, weight := range weights {
+		if weight < 0 {
+			return errs.Newf(errs.Validation, "%s weight cannot be negative, got %g", name, weight).With("weight", name)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errs.New(errs.Validation, "quality, cost and speed weights cannot all be zero")
+	}
+	if config.ConservativeBias < 0 || config.ConservativeBias > 1 {
+		return errs.Newf(errs.Validation, "conservative bias must be between 0 and 1, got %g", config.ConservativeBias)
+	}
+	if config.MaxCostPerRequest <= 0 {
+		return errs.Newf(errs.Validation, "max cost per request must be positive, got %g", config.MaxCostPerRequest)
+	}
+	if _, err := ParseRoutingPolicy(string(config.Policy)); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy = config.Policy
+	r.config.QualityWeight = config.QualityWeight
+	r.config.CostWeight = config.CostWeight
+	r.config.SpeedWeight = config.SpeedWeight
+	r.config.ConservativeBias = config.ConservativeBias
+	r.config.MaxCostPerRequest = config.MaxCostPerRequest
+	return nil
+}
+
+// policySettings returns the settings the request is routed with: those of
+// its own policy, or else the router's. A policy without a cost reference
+// of its own scores costs against MaxCostPerRequest.
+func (r *Router) policySettings(req TaskRequest) PolicySettings {
+	r.mu.RLock()
+	policy, config := r.policy, r.config
+	r.mu.RUnlock()
+
+	if req.Policy != PolicyDefault {
+		policy = req.Policy
+	}
+	settings := policy.Settings(config)
+	if settings.CostReference == 0 {
+		settings.CostReference = config.MaxCostPerRequest
+	}
+	return settings
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// TestRoutingPolicies checks that the same request routes to a different
+// model under each policy.
+func TestRoutingPolicies(t *testing.T) {
+	router := NewRouter(NewMockLLMService())
+	req := TaskRequest{Prompt: "Summarize the meeting notes for the team", MaxTokens: 200}
+
+	tests := []struct {
+		policy RoutingPolicy
+		model  string
+	}{
+		{PolicyCostSaver, "local-llama"},
+		{PolicyBalanced, "gpt-4o-mini"},
+		{PolicyQualityFirst, "claude-3-sonnet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			req := req
+			req.Policy = tt.policy
+			plan, err := router.RoutePlan(context.Background(), req)
+			if err != nil {
+				t.Fatalf("RoutePlan failed: %v", err)
+			}
+			if plan.SelectedModel.Model != tt.model {
+				t.Errorf("Expected %s to route to %s, got %s", tt.policy, tt.model, plan.SelectedModel.Model)
+			}
+		})
+	}
+
+	// The router's policy applies to requests without one of their own
+	router.SetPolicy(PolicyQualityFirst)
+	plan, err := router.RoutePlan(context.Background(), req)
+	if err != nil {
+		t.Fatalf("RoutePlan failed: %v", err)
+	}
+	if plan.SelectedModel.Model != "claude-3-sonnet" || plan.Assessment.QualityNeeded != QualityPremium {
+		t.Errorf("Expected the router's quality-first policy to pick claude-3-sonnet, got %s", plan.SelectedModel.Model)
+	}
+	req.Policy = PolicyCostSaver
+	if plan, err := router.RoutePlan(context.Background(), req); err != nil || plan.SelectedModel.Model != "local-llama" {
+		t.Errorf("Expected the request's own policy to take precedence, got %v", err)
+	}
+}
+
+// TestRoutingPolicies_CostSaverCeiling checks that cost-saver never ranks a
+// model priced above its ceiling, even for premium work.
+func TestRoutingPolicies_CostSaverCeiling(t *testing.T) {
+	router := NewRouter(NewMockLLMService(), RouterConfig{Policy: PolicyCostSaver, MaxCostPerRequest: 0.10, ModelCacheTTL: 0})
+	req := TaskRequest{Prompt: "Write the final quarterly report", QualityRequired: QualityPremium}
+
+	ranked := router.scoreModels(router.getAvailableModels(), router.assessTask(req), req)
+	if len(ranked) == 0 {
+		t.Fatal("Expected some models within the ceiling")
+	}
+	for _, model := range ranked {
+		if model.Model == "claude-3-sonnet" || model.Model == "gpt-4" {
+			t.Errorf("Expected %s to be ruled out by the cost-saver ceiling", model.Model)
+		}
+	}
+}
+
+func TestParseRoutingPolicy(t *testing.T) {
+	for _, policy := range append(RoutingPolicies, PolicyDefault) {
+		if parsed, err := ParseRoutingPolicy(string(policy)); err != nil || parsed != policy {
+			t.Errorf("ParseRoutingPolicy(%q) = %q, %v", policy, parsed, err)
+		}
+	}
+	if _, err := ParseRoutingPolicy("cheapest"); !errors.Is(err, errs.Validation) {
+		t.Errorf("Expected an unknown policy to be a validation error, got %v", err)
+	}
+}
+
+func TestRouterApplyConfig(t *testing.T) {
+	router := NewRouter(NewMockLLMService())
+	req := TaskRequest{Prompt: "Summarize the meeting notes for the team", MaxTokens: 200}
+
+	config := DefaultRouterConfig()
+	config.Policy = PolicyQualityFirst
+	if err := router.ApplyConfig(config); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	plan, err := router.RoutePlan(context.Background(), req)
+	if err != nil {
+		t.Fatalf("RoutePlan failed: %v", err)
+	}
+	if plan.SelectedModel.Model != "claude-3-sonnet" {
+		t.Errorf("Expected the applied quality-first policy to pick claude-3-sonnet, got %s", plan.SelectedModel.Model)
+	}
+
+	invalid := []func(c *RouterConfig){
+		func(c *RouterConfig) { c.CostWeight = -0.1 },
+		func(c *RouterConfig) { c.QualityWeight, c.CostWeight, c.SpeedWeight = 0, 0, 0 },
+		func(c *RouterConfig) { c.ConservativeBias = 1.5 },
+		func(c *RouterConfig) { c.MaxCostPerRequest = 0 },
+		func(c *RouterConfig) { c.Policy = "cheapest" },
+	}
+	for i, change := range invalid {
+		config := DefaultRouterConfig()
+		change(&config)
+		if err := router.ApplyConfig(config); !errors.Is(err, errs.Validation) {
+			t.Errorf("Expected config %d to be rejected, got %v", i, err)
+		}
+	}
+	if router.Policy() != PolicyQualityFirst {
+		t.Errorf("Expected rejected configs to keep the policy, got %s", router.Policy())
+	}
+}
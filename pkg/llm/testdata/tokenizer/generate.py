@@ -0,0 +1,269 @@
+#!/usr/bin/env python3
+"""Generates the tokenizer fixtures used by tokenizer_test.go.
+
+    python3 generate.py train CORPUS...   # train fixture.tiktoken on CORPUS files
+    python3 generate.py counts            # recompute counts.json and counts_o200k.json
+
+The vocabulary is a small byte-level BPE in tiktoken format (base64 token,
+rank), trained with the cl100k_base pre-tokenizer split. counts.json holds
+reference token counts for the sample texts, computed by the encoder below,
+which is written independently of the Go implementation and follows
+tiktoken's rank-ordered byte pair merging. counts_o200k.json holds the counts
+of the same vocabulary with the o200k_base pre-tokenizer split.
+"""
+import base64
+import collections
+import json
+import os
+import sys
+import unicodedata
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+VOCAB = os.path.join(HERE, "fixture.tiktoken")
+SAMPLES = ["prose.txt", "code.txt", "cjk.txt"]
+MERGES = 4000
+
+
+def is_letter(c):
+    return unicodedata.category(c).startswith("L")
+
+
+def is_number(c):
+    return unicodedata.category(c).startswith("N")
+
+
+def is_space(c):
+    return c.isspace()
+
+
+def split_cl100k(text):
+    """Splits text like the cl100k_base regular expression:
+    (?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\\r\\n\\p{L}\\p{N}]?\\p{L}+|\\p{N}{1,3}|
+     ?[^\\s\\p{L}\\p{N}]+[\\r\\n]*|\\s*[\\r\\n]+|\\s+(?!\\S)|\\s+
+    """
+    pieces = []
+    i, n = 0, len(text)
+    while i < n:
+        c = text[i]
+        rest = text[i:i + 3].lower()
+        if c == "'" and (rest[:3] in ("'re", "'ve", "'ll")):
+            pieces.append(text[i:i + 3]); i += 3; continue
+        if c == "'" and rest[:2] in ("'s", "'t", "'m", "'d"):
+            pieces.append(text[i:i + 2]); i += 2; continue
+        j = i
+        if not is_letter(c) and not is_number(c) and c not in "\r\n" and i + 1 < n and is_letter(text[i + 1]):
+            j = i + 1
+        if j < n and is_letter(text[j]):
+            while j < n and is_letter(text[j]):
+                j += 1
+            pieces.append(text[i:j]); i = j; continue
+        if is_number(c):
+            j = i
+            while j < n and j - i < 3 and is_number(text[j]):
+                j += 1
+            pieces.append(text[i:j]); i = j; continue
+        j = i + 1 if c == " " else i
+        if j < n and not is_space(text[j]) and not is_letter(text[j]) and not is_number(text[j]):
+            while j < n and not is_space(text[j]) and not is_letter(text[j]) and not is_number(text[j]):
+                j += 1
+            while j < n and text[j] in "\r\n":
+                j += 1
+            pieces.append(text[i:j]); i = j; continue
+        # Whitespace run
+        j = i
+        while j < n and is_space(text[j]):
+            j += 1
+        last_newline = -1
+        for k in range(i, j):
+            if text[k] in "\r\n":
+                last_newline = k
+        if last_newline >= 0:
+            pieces.append(text[i:last_newline + 1]); i = last_newline + 1; continue
+        if j < n and j - 1 > i:
+            pieces.append(text[i:j - 1]); i = j - 1; continue
+        pieces.append(text[i:j]); i = j
+    return pieces
+
+
+def is_upper_cased(c):
+    cat = unicodedata.category(c)
+    return cat in ("Lu", "Lt", "Lm", "Lo") or cat.startswith("M")
+
+
+def is_lower_cased(c):
+    cat = unicodedata.category(c)
+    return cat in ("Ll", "Lm", "Lo") or cat.startswith("M")
+
+
+def contraction_end(text, j):
+    low = text[j:j + 3].lower()
+    for suffix in ("'re", "'ve", "'ll", "'s", "'t", "'m", "'d"):
+        if low.startswith(suffix):
+            return j + len(suffix)
+    return j
+
+
+def match_cased_word(text, i, upper_first):
+    """Matches [^\\r\\n\\p{L}\\p{N}]?, then upper-case letters then lower-case
+    ones (at least one lower-case, or one upper-case when upper_first), then an
+    optional contraction, backtracking like the regular expression engine."""
+    n = len(text)
+    c = text[i]
+    starts = [i]
+    if not is_letter(c) and not is_number(c) and c not in "\r\n":
+        starts = [i + 1, i]
+    for start in starts:
+        j = start
+        while j < n and is_upper_cased(text[j]):
+            j += 1
+        if upper_first:
+            if j == start:
+                continue
+            while j < n and is_lower_cased(text[j]):
+                j += 1
+            return contraction_end(text, j)
+        for k in range(j, start - 1, -1):
+            end = k
+            while end < n and is_lower_cased(text[end]):
+                end += 1
+            if end > k:
+                return contraction_end(text, end)
+    return -1
+
+
+def split_o200k(text):
+    """Splits text like the o200k_base regular expression, whose word
+    alternatives split at case changes and keep contractions:
+    [^\\r\\n\\p{L}\\p{N}]?[\\p{Lu}\\p{Lt}\\p{Lm}\\p{Lo}\\p{M}]*[\\p{Ll}\\p{Lm}\\p{Lo}\\p{M}]+(?i:'s|...)?|
+    [^\\r\\n\\p{L}\\p{N}]?[\\p{Lu}\\p{Lt}\\p{Lm}\\p{Lo}\\p{M}]+[\\p{Ll}\\p{Lm}\\p{Lo}\\p{M}]*(?i:'s|...)?|
+    \\p{N}{1,3}| ?[^\\s\\p{L}\\p{N}]+[\\r\\n/]*|\\s*[\\r\\n]+|\\s+(?!\\S)|\\s+
+    """
+    pieces = []
+    i, n = 0, len(text)
+    while i < n:
+        c = text[i]
+        j = match_cased_word(text, i, False)
+        if j < 0:
+            j = match_cased_word(text, i, True)
+        if j >= 0:
+            pieces.append(text[i:j]); i = j; continue
+        if is_number(c):
+            j = i
+            while j < n and j - i < 3 and is_number(text[j]):
+                j += 1
+            pieces.append(text[i:j]); i = j; continue
+        j = i + 1 if c == " " else i
+        if j < n and not is_space(text[j]) and not is_letter(text[j]) and not is_number(text[j]):
+            while j < n and not is_space(text[j]) and not is_letter(text[j]) and not is_number(text[j]):
+                j += 1
+            while j < n and text[j] in "\r\n/":
+                j += 1
+            pieces.append(text[i:j]); i = j; continue
+        # Whitespace run
+        j = i
+        while j < n and is_space(text[j]):
+            j += 1
+        last_newline = -1
+        for k in range(i, j):
+            if text[k] in "\r\n":
+                last_newline = k
+        if last_newline >= 0:
+            pieces.append(text[i:last_newline + 1]); i = last_newline + 1; continue
+        if j < n and j - 1 > i:
+            pieces.append(text[i:j - 1]); i = j - 1; continue
+        pieces.append(text[i:j]); i = j
+    return pieces
+
+
+def load_ranks():
+    ranks = {}
+    with open(VOCAB, "rb") as f:
+        for line in f:
+            token, rank = line.split()
+            ranks[base64.b64decode(token)] = int(rank)
+    return ranks
+
+
+def count_piece(piece, ranks):
+    if piece in ranks:
+        return 1
+    parts = [bytes([b]) for b in piece]
+    while len(parts) > 1:
+        best, best_rank = -1, None
+        for k in range(len(parts) - 1):
+            rank = ranks.get(parts[k] + parts[k + 1])
+            if rank is not None and (best_rank is None or rank < best_rank):
+                best, best_rank = k, rank
+        if best < 0:
+            break
+        parts[best:best + 2] = [parts[best] + parts[best + 1]]
+    return len(parts)
+
+
+def count(text, ranks, split=split_cl100k):
+    return sum(count_piece(p.encode("utf-8"), ranks) for p in split(text))
+
+
+def train(paths):
+    words = collections.Counter()
+    for path in paths:
+        with open(path, encoding="utf-8", errors="ignore") as f:
+            for piece in split_cl100k(f.read()):
+                words[tuple(bytes([b]) for b in piece.encode("utf-8"))] += 1
+    words = [[list(w), c] for w, c in words.items()]
+
+    pairs = collections.Counter()
+    where = collections.defaultdict(set)
+    for idx, (w, c) in enumerate(words):
+        for a, b in zip(w, w[1:]):
+            pairs[(a, b)] += c
+            where[(a, b)].add(idx)
+
+    vocab = [bytes([b]) for b in range(256)]
+    for _ in range(MERGES):
+        if not pairs:
+            break
+        (a, b), freq = max(pairs.items(), key=lambda kv: (kv[1], kv[0]))
+        if freq < 2:
+            break
+        merged = a + b
+        vocab.append(merged)
+        for idx in list(where[(a, b)]):
+            w, c = words[idx]
+            for x, y in zip(w, w[1:]):
+                pairs[(x, y)] -= c
+                if pairs[(x, y)] <= 0:
+                    del pairs[(x, y)]
+                where[(x, y)].discard(idx)
+            k, out = 0, []
+            while k < len(w):
+                if k + 1 < len(w) and w[k] == a and w[k + 1] == b:
+                    out.append(merged); k += 2
+                else:
+                    out.append(w[k]); k += 1
+            words[idx][0] = out
+            for x, y in zip(out, out[1:]):
+                pairs[(x, y)] += c
+                where[(x, y)].add(idx)
+
+    with open(VOCAB, "wb") as f:
+        for rank, token in enumerate(vocab):
+            f.write(base64.b64encode(token) + b" " + str(rank).encode() + b"\n")
+
+
+def counts():
+    ranks = load_ranks()
+    for output, split in (("counts.json", split_cl100k), ("counts_o200k.json", split_o200k)):
+        result = {}
+        for name in SAMPLES:
+            with open(os.path.join(HERE, name), encoding="utf-8") as f:
+                result[name] = count(f.read(), ranks, split)
+        with open(os.path.join(HERE, output), "w") as f:
+            json.dump(result, f, indent=2, sort_keys=True)
+            f.write("\n")
+
+
+if __name__ == "__main__":
+    if sys.argv[1:2] == ["train"]:
+        train(sys.argv[2:])
+    counts()
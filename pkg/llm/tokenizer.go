@@ -0,0 +1,668 @@
+package llm
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// HeuristicTokenizerName names the character heuristic in token estimates.
+const HeuristicTokenizerName = "heuristic"
+
+// Defaults for TokenizerConfig.
+const (
+	DefaultMaxLoadedVocabularies = 2
+	DefaultMaxVocabularyBytes    = 16 << 20
+)
+
+// ErrNoVocabulary is returned when no vocabulary is available for a model.
+var ErrNoVocabulary = errs.New(errs.NotFound, "no vocabulary for model")
+
+// Tokenizer counts the tokens a model sees in a text.
+type Tokenizer interface {
+	// CountTokens returns the number of tokens in text
+	CountTokens(text string) int
+
+	// Name identifies the tokenizer, e.g. a vocabulary name
+	Name() string
+}
+
+// HeuristicTokenizer returns the tokenizer used when no vocabulary is
+// available: the larger of one token per three bytes and one per word.
+// Against BPE vocabularies it is typically within 50% for English prose and
+// source code; CJK text, where a character is often a token of its own, is
+// undercounted by up to half.
+func HeuristicTokenizer() Tokenizer {
+	return heuristicTokenizer{}
+}
+
+type heuristicTokenizer struct{}
+
+func (heuristicTokenizer) Name() string { return HeuristicTokenizerName }
+
+func (heuristicTokenizer) CountTokens(text string) int {
+	return max(len(text)/3, len(strings.Fields(text)))
+}
+
+// familyHeuristic estimates tokens with ratios typical of a model family's
+// vocabulary: how many bytes of ASCII text make a token, and how many tokens
+// a CJK character and any other non-ASCII character take.
+type familyHeuristic struct {
+	bytesPerToken float64
+	perCJK        float64
+	perOther      float64
+}
+
+func (familyHeuristic) Name() string { return HeuristicTokenizerName }
+
+func (h familyHeuristic) CountTokens(text string) int {
+	var ascii, cjk, other int
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		default:
+			other++
+		}
+	}
+	estimate := float64(ascii)/h.bytesPerToken + float64(cjk)*h.perCJK + float64(other)*h.perOther
+	return max(int(math.Ceil(estimate)), len(strings.Fields(text)))
+}
+
+// tokenFamilies calibrates the heuristic for model families by model name
+// prefix, the first match winning. The ratios are typical of each family's
+// vocabulary on mixed prose and code; they estimate, they do not count.
+var tokenFamilies = []struct {
+	prefixes  []string
+	heuristic familyHeuristic
+}{
+	// o200k_base
+	{[]string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4"}, familyHeuristic{4.2, 0.8, 0.35}},
+	// cl100k_base, and the Llama 3 vocabulary built on it
+	{[]string{"gpt-4", "gpt-3.5", "text-embedding", "llama3", "llama-3"}, familyHeuristic{3.9, 1.1, 0.45}},
+	{[]string{"claude"}, familyHeuristic{3.5, 1.2, 0.5}},
+	// 32k SentencePiece vocabularies, which spell CJK out in bytes
+	{[]string{"llama", "codellama", "mistral", "mixtral"}, familyHeuristic{3.3, 1.6, 0.6}},
+	{[]string{"qwen"}, familyHeuristic{4.0, 0.75, 0.4}},
+	{[]string{"gemma", "gemini"}, familyHeuristic{4.1, 0.8, 0.4}},
+}
+
+// HeuristicTokenizerFor returns the heuristic calibrated for the family of
+// model, such as GPT-4o, Claude or Llama, or HeuristicTokenizer for models of
+// no known family. A path before the model name, as in
+// "meta-llama/Llama-2-7b", is ignored.
+func HeuristicTokenizerFor(model string) Tokenizer {
+	name := strings.ToLower(model)
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = name[slash+1:]
+	}
+	for _, family := range tokenFamilies {
+		for _, prefix := range family.prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return family.heuristic
+			}
+		}
+	}
+	return HeuristicTokenizer()
+}
+
+// CountTokens estimates the tokens text takes for model without
+// vocabularies, using the heuristic for its family. TokenEstimator.CountTokens
+// counts exactly where vocabularies are installed.
+func CountTokens(model, text string) int {
+	return HeuristicTokenizerFor(model).CountTokens(text)
+}
+
+// BPETokenizer counts tokens exactly with a byte pair encoding vocabulary,
+// the scheme used by OpenAI models.
+type BPETokenizer struct {
+	name  string
+	ranks map[string]int
+	split func(string) []string
+}
+
+// LoadBPETokenizer reads a vocabulary in tiktoken format: one base64 token
+// and its rank per line, lower ranks merging first. The name selects the
+// pre-tokenizer split: the GPT-2 rules for r50k_base, p50k_base and gpt2, the
+// o200k_base rules for o200k_base, the cl100k_base rules otherwise. Files
+// larger than maxBytes are refused.
+func LoadBPETokenizer(path, name string, maxBytes int64) (*BPETokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err != nil {
+		return nil, err
+	} else if maxBytes > 0 && info.Size() > maxBytes {
+		return nil, fmt.Errorf("vocabulary %s is %d bytes, over the %d byte limit", name, info.Size(), maxBytes)
+	}
+
+	tokenizer := &BPETokenizer{name: name, ranks: make(map[string]int), split: splitCL100K}
+	switch name {
+	case "r50k_base", "p50k_base", "p50k_edit", "gpt2":
+		tokenizer.split = splitGPT2
+	case "o200k_base":
+		tokenizer.split = splitO200K
+	}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("vocabulary %s line %d: expected a token and a rank", name, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("vocabulary %s line %d: %w", name, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("vocabulary %s line %d: %w", name, line, err)
+		}
+		tokenizer.ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary %s: %w", name, err)
+	}
+	if len(tokenizer.ranks) == 0 {
+		return nil, fmt.Errorf("vocabulary %s is empty", name)
+	}
+	return tokenizer, nil
+}
+
+// Name returns the vocabulary name.
+func (t *BPETokenizer) Name() string {
+	return t.name
+}
+
+// CountTokens returns the number of tokens text encodes to.
+func (t *BPETokenizer) CountTokens(text string) int {
+	count := 0
+	for _, piece := range t.split(text) {
+		count += t.countPiece(piece)
+	}
+	return count
+}
+
+// countPiece encodes one pre-tokenized piece by repeatedly merging the
+// adjacent pair with the lowest rank, as tiktoken does.
+func (t *BPETokenizer) countPiece(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+
+	// bounds[i] is where part i starts; the last entry is the end of the piece
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, 0
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := t.ranks[piece[bounds[i]:bounds[i+2]]]; ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// splitCL100K splits text into pieces like the cl100k_base pattern:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// Go's regexp has no lookahead, so the alternatives are matched by hand, in
+// order, at each position.
+func splitCL100K(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		end := matchContraction(text, i, true)
+		if end < 0 {
+			end = matchLead(text, i, isLetterLead, unicode.IsLetter)
+		}
+		if end < 0 {
+			end = matchRun(text, i, unicode.IsNumber, 3)
+		}
+		if end < 0 {
+			end = matchPunctuation(text, i, "\r\n")
+		}
+		if end < 0 {
+			end = matchSpace(text, i, true)
+		}
+		pieces = append(pieces, text[i:end])
+		i = end
+	}
+	return pieces
+}
+
+// splitGPT2 splits text like the GPT-2 pattern used by r50k_base and p50k_base:
+//
+//	's|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+
+func splitGPT2(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		end := matchContraction(text, i, false)
+		if end < 0 {
+			end = matchLead(text, i, isSpaceChar, unicode.IsLetter)
+		}
+		if end < 0 {
+			end = matchLead(text, i, isSpaceChar, unicode.IsNumber)
+		}
+		if end < 0 {
+			end = matchPunctuation(text, i, "")
+		}
+		if end < 0 {
+			end = matchSpace(text, i, false)
+		}
+		pieces = append(pieces, text[i:end])
+		i = end
+	}
+	return pieces
+}
+
+// splitO200K splits text into pieces like the o200k_base pattern:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// Unlike cl100k_base it splits words where their case changes, as in
+// "retryWithBackoff", and keeps contractions with their word.
+func splitO200K(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		end := matchCasedWord(text, i, false)
+		if end < 0 {
+			end = matchCasedWord(text, i, true)
+		}
+		if end < 0 {
+			end = matchRun(text, i, unicode.IsNumber, 3)
+		}
+		if end < 0 {
+			end = matchPunctuation(text, i, "\r\n/")
+		}
+		if end < 0 {
+			end = matchSpace(text, i, true)
+		}
+		pieces = append(pieces, text[i:end])
+		i = end
+	}
+	return pieces
+}
+
+// matchCasedWord matches the word alternatives of o200k_base at i: an
+// optional lead in [^\r\n\p{L}\p{N}], upper-case runes then lower-case ones,
+// and an optional contraction. The word needs a lower-case rune, or an
+// upper-case one when upperFirst is set. As the regular expression does, it
+// retries without the lead, and gives back upper-case runes that are also
+// lower-case until the lower-case ones match.
+func matchCasedWord(text string, i int, upperFirst bool) int {
+	starts := []int{i}
+	if r, width := runeAt(text, i); isLetterLead(r) {
+		starts = []int{i + width, i}
+	}
+	for _, start := range starts {
+		if upperFirst {
+			end := matchRun(text, start, isUpperCased, 0)
+			if end < 0 {
+				continue
+			}
+			if lower := matchRun(text, end, isLowerCased, 0); lower >= 0 {
+				end = lower
+			}
+			return withContraction(text, end)
+		}
+
+		// upper[k] is where the lower-case runes start after k upper-case ones
+		upper := []int{start}
+		for end := start; ; {
+			r, width := runeAt(text, end)
+			if width == 0 || !isUpperCased(r) {
+				break
+			}
+			end += width
+			upper = append(upper, end)
+		}
+		for k := len(upper) - 1; k >= 0; k-- {
+			if end := matchRun(text, upper[k], isLowerCased, 0); end >= 0 {
+				return withContraction(text, end)
+			}
+		}
+	}
+	return -1
+}
+
+// isUpperCased reports whether r is in [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}].
+func isUpperCased(r rune) bool {
+	return unicode.IsUpper(r) || unicode.IsTitle(r) || unicode.In(r, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerCased reports whether r is in [\p{Ll}\p{Lm}\p{Lo}\p{M}].
+func isLowerCased(r rune) bool {
+	return unicode.IsLower(r) || unicode.In(r, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// withContraction extends a word ending at end over a contraction after it.
+func withContraction(text string, end int) int {
+	if end < len(text) {
+		if contraction := matchContraction(text, end, true); contraction >= 0 {
+			return contraction
+		}
+	}
+	return end
+}
+
+// runeAt decodes the rune at i, returning it and its width.
+func runeAt(text string, i int) (rune, int) {
+	if i >= len(text) {
+		return utf8.RuneError, 0
+	}
+	return utf8.DecodeRuneInString(text[i:])
+}
+
+// matchContraction matches 's, 't, 're, 've, 'm, 'll or 'd at i, returning
+// the end of the match or -1.
+func matchContraction(text string, i int, ignoreCase bool) int {
+	if text[i] != '\'' {
+		return -1
+	}
+	rest := text[i+1:]
+	for _, suffix := range []string{"re", "ve", "ll", "s", "t", "m", "d"} {
+		if len(rest) < len(suffix) {
+			continue
+		}
+		head := rest[:len(suffix)]
+		if head == suffix || (ignoreCase && strings.EqualFold(head, suffix)) {
+			return i + 1 + len(suffix)
+		}
+	}
+	return -1
+}
+
+// matchLead matches an optional leading rune accepted by lead followed by one
+// or more runes accepted by accept. The lead is never itself accepted.
+func matchLead(text string, i int, lead, accept func(rune) bool) int {
+	r, width := runeAt(text, i)
+	start := i
+	if lead(r) {
+		start = i + width
+	}
+	return matchRun(text, start, accept, 0)
+}
+
+// isLetterLead reports whether r is in [^\r\n\p{L}\p{N}], the optional lead
+// of cl100k_base words.
+func isLetterLead(r rune) bool {
+	return r != '\r' && r != '\n' && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// isSpaceChar reports whether r is a plain space, the optional lead of GPT-2 pieces.
+func isSpaceChar(r rune) bool {
+	return r == ' '
+}
+
+// matchRun matches one or more runes accepted by accept, at most limit of
+// them when limit is positive.
+func matchRun(text string, i int, accept func(rune) bool, limit int) int {
+	end := i
+	for n := 0; limit <= 0 || n < limit; n++ {
+		r, width := runeAt(text, end)
+		if width == 0 || !accept(r) {
+			break
+		}
+		end += width
+	}
+	if end == i {
+		return -1
+	}
+	return end
+}
+
+// isPunctuation reports whether r is in [^\s\p{L}\p{N}].
+func isPunctuation(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// matchPunctuation matches " ?[^\s\p{L}\p{N}]+" followed by any run of the
+// bytes in trailing, such as "\r\n".
+func matchPunctuation(text string, i int, trailing string) int {
+	end := matchLead(text, i, isSpaceChar, isPunctuation)
+	if end < 0 {
+		return -1
+	}
+	for end < len(text) && strings.IndexByte(trailing, text[end]) >= 0 {
+		end++
+	}
+	return end
+}
+
+// matchSpace matches a whitespace run at i: up to its last line break when
+// newlines is set and it has one (\s*[\r\n]+), otherwise all of it but the
+// last space before a non-space (\s+(?!\S)), otherwise all of it (\s+).
+// It always advances, so splitting cannot stall on unexpected input.
+func matchSpace(text string, i int, newlines bool) int {
+	end := matchRun(text, i, unicode.IsSpace, 0)
+	if end < 0 {
+		_, width := runeAt(text, i)
+		return i + max(width, 1)
+	}
+	if newlines {
+		if last := strings.LastIndexAny(text[i:end], "\r\n"); last >= 0 {
+			return i + last + 1
+		}
+	}
+	if end < len(text) {
+		_, width := utf8.DecodeLastRuneInString(text[i:end])
+		if end-width > i {
+			return end - width
+		}
+	}
+	return end
+}
+
+// TokenizerConfig configures exact token counting. Vocabularies are read
+// from local files only; nothing is fetched over the network.
+type TokenizerConfig struct {
+	// VocabularyDir holds vocabulary files named <vocabulary>.tiktoken.
+	// Empty disables exact counting.
+	VocabularyDir string
+
+	// Models maps model names to vocabulary names, adding to and overriding
+	// DefaultModelVocabularies. A name ending in "*" matches by prefix.
+	// Vocabularies mapped to models they were not made for, such as an
+	// OpenAI vocabulary standing in for a Claude model, give approximate counts.
+	Models map[string]string
+
+	// MaxLoaded bounds how many vocabularies are held in memory at once;
+	// the least recently used is dropped first
+	MaxLoaded int
+
+	// MaxVocabularyBytes refuses vocabulary files larger than this
+	MaxVocabularyBytes int64
+}
+
+// DefaultModelVocabularies maps OpenAI models to the vocabularies they use.
+func DefaultModelVocabularies() map[string]string {
+	return map[string]string{
+		"gpt-4o":           "o200k_base",
+		"gpt-4o-*":         "o200k_base",
+		"gpt-4.1*":         "o200k_base",
+		"gpt-5*":           "o200k_base",
+		"o1*":              "o200k_base",
+		"o3*":              "o200k_base",
+		"o4-*":             "o200k_base",
+		"gpt-4":            "cl100k_base",
+		"gpt-4-*":          "cl100k_base",
+		"gpt-3.5-turbo":    "cl100k_base",
+		"gpt-3.5-turbo-*":  "cl100k_base",
+		"text-davinci-003": "p50k_base",
+		"text-davinci-002": "p50k_base",
+		"davinci":          "r50k_base",
+	}
+}
+
+// TokenEstimate is a token count along with how it was made.
+type TokenEstimate struct {
+	Tokens int `json:"tokens"`
+
+	// Tokenizer is the vocabulary used, or HeuristicTokenizerName
+	Tokenizer string `json:"tokenizer"`
+
+	// Exact is set when the count comes from the model's own vocabulary
+	Exact bool `json:"exact,omitempty"`
+}
+
+// TokenEstimator counts tokens for a model with its vocabulary when one is
+// available, and with the heuristic otherwise. Vocabularies are loaded on
+// first use and cached.
+type TokenEstimator struct {
+	config TokenizerConfig
+	models map[string]string
+
+	mu     sync.Mutex
+	loaded map[string]*list.Element // vocabulary name -> element holding a *BPETokenizer
+	lru    *list.List               // most recently used first
+	failed map[string]error         // vocabularies that could not be loaded
+}
+
+// NewTokenEstimator creates a token estimator.
+func NewTokenEstimator(config TokenizerConfig) *TokenEstimator {
+	if config.MaxLoaded <= 0 {
+		config.MaxLoaded = DefaultMaxLoadedVocabularies
+	}
+	if config.MaxVocabularyBytes <= 0 {
+		config.MaxVocabularyBytes = DefaultMaxVocabularyBytes
+	}
+
+	models := DefaultModelVocabularies()
+	for model, vocabulary := range config.Models {
+		models[model] = vocabulary
+	}
+
+	return &TokenEstimator{
+		config: config,
+		models: models,
+		loaded: make(map[string]*list.Element),
+		lru:    list.New(),
+		failed: make(map[string]error),
+	}
+}
+
+// Estimate counts the tokens of text for model. It never fails: without a
+// usable vocabulary the heuristic for the model's family is used.
+func (te *TokenEstimator) Estimate(model, text string) TokenEstimate {
+	tokenizer, exact, err := te.Tokenizer(model)
+	if err != nil {
+		tokenizer, exact = HeuristicTokenizerFor(model), false
+	}
+	return TokenEstimate{
+		Tokens:    tokenizer.CountTokens(text),
+		Tokenizer: tokenizer.Name(),
+		Exact:     exact,
+	}
+}
+
+// CountTokens returns the number of tokens text takes for model; see Estimate.
+func (te *TokenEstimator) CountTokens(model, text string) int {
+	return te.Estimate(model, text).Tokens
+}
+
+// Tokenizer returns the BPE tokenizer for model, loading its vocabulary if
+// needed, and whether it is the model's own vocabulary rather than a
+// configured stand-in. It returns ErrNoVocabulary when no vocabulary is
+// mapped to the model or no vocabulary directory is configured.
+func (te *TokenEstimator) Tokenizer(model string) (Tokenizer, bool, error) {
+	vocabulary, native := te.vocabularyFor(model)
+	if vocabulary == "" || te.config.VocabularyDir == "" {
+		return nil, false, fmt.Errorf("%w %s", ErrNoVocabulary, model)
+	}
+
+	te.mu.Lock()
+	defer te.mu.Unlock()
+
+	if element, ok := te.loaded[vocabulary]; ok {
+		te.lru.MoveToFront(element)
+		return element.Value.(*BPETokenizer), native, nil
+	}
+	if err := te.failed[vocabulary]; err != nil {
+		return nil, false, err
+	}
+
+	path := filepath.Join(te.config.VocabularyDir, vocabulary+".tiktoken")
+	tokenizer, err := LoadBPETokenizer(path, vocabulary, te.config.MaxVocabularyBytes)
+	if err != nil {
+		err = fmt.Errorf("failed to load vocabulary %s for %s: %w", vocabulary, model, err)
+		te.failed[vocabulary] = err
+		return nil, false, err
+	}
+
+	te.loaded[vocabulary] = te.lru.PushFront(tokenizer)
+	for te.lru.Len() > te.config.MaxLoaded {
+		oldest := te.lru.Back()
+		te.lru.Remove(oldest)
+		delete(te.loaded, oldest.Value.(*BPETokenizer).Name())
+	}
+	return tokenizer, native, nil
+}
+
+// Loaded returns the names of the vocabularies held in memory, most recently used first.
+func (te *TokenEstimator) Loaded() []string {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+
+	var names []string
+	for element := te.lru.Front(); element != nil; element = element.Next() {
+		names = append(names, element.Value.(*BPETokenizer).Name())
+	}
+	return names
+}
+
+// vocabularyFor finds the vocabulary mapped to model, preferring exact names
+// over the longest matching prefix, and reports whether it is the model's own.
+func (te *TokenEstimator) vocabularyFor(model string) (string, bool) {
+	vocabulary, ok := te.models[model]
+	if !ok {
+		longest := 0
+		for pattern, name := range te.models {
+			prefix, isPrefix := strings.CutSuffix(pattern, "*")
+			if isPrefix && strings.HasPrefix(model, prefix) && len(prefix) >= longest {
+				vocabulary, longest = name, len(prefix)
+			}
+		}
+	}
+	if vocabulary == "" {
+		return "", false
+	}
+
+	defaults := DefaultModelVocabularies()
+	native := defaults[model] == vocabulary
+	for pattern, name := range defaults {
+		if prefix, isPrefix := strings.CutSuffix(pattern, "*"); isPrefix && strings.HasPrefix(model, prefix) && name == vocabulary {
+			native = true
+		}
+	}
+	return vocabulary, native
+}
@@ -0,0 +1,312 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The fixture vocabulary and reference counts are produced by
+// testdata/tokenizer/generate.py, an encoder written independently of this one.
+const tokenizerTestdata = "testdata/tokenizer"
+
+// cl100kReferences are published cl100k_base counts of short texts.
+var cl100kReferences = map[string]int{
+	"hello world":                  2,
+	"tiktoken is great!":           6,
+	"antidisestablishmentarianism": 6,
+	"2 + 2 = 4":                    7,
+	"お誕生日おめでとう":                    9,
+}
+
+// loadReferenceCounts reads the expected token counts of the sample texts.
+func loadReferenceCounts(t *testing.T) map[string]int {
+	t.Helper()
+	return loadCounts(t, "counts.json")
+}
+
+// loadCounts reads a file of expected token counts by sample text.
+func loadCounts(t *testing.T, name string) map[string]int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(tokenizerTestdata, name))
+	if err != nil {
+		t.Fatalf("Failed to read reference counts: %v", err)
+	}
+	var counts map[string]int
+	if err := json.Unmarshal(data, &counts); err != nil {
+		t.Fatalf("Failed to decode reference counts: %v", err)
+	}
+	return counts
+}
+
+// readSample reads a sample text.
+func readSample(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(tokenizerTestdata, name))
+	if err != nil {
+		t.Fatalf("Failed to read sample %s: %v", name, err)
+	}
+	return string(data)
+}
+
+// vocabularyDir returns a directory holding the fixture vocabulary under the given names.
+func vocabularyDir(t *testing.T, names ...string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(tokenizerTestdata, "fixture.tiktoken"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture vocabulary: %v", err)
+	}
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name+".tiktoken"), data, 0644); err != nil {
+			t.Fatalf("Failed to write vocabulary %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestBPETokenizer_ReferenceCounts(t *testing.T) {
+	tokenizer, err := LoadBPETokenizer(filepath.Join(tokenizerTestdata, "fixture.tiktoken"), "fixture", 0)
+	if err != nil {
+		t.Fatalf("Failed to load fixture vocabulary: %v", err)
+	}
+
+	for name, want := range loadReferenceCounts(t) {
+		if got := tokenizer.CountTokens(readSample(t, name)); got != want {
+			t.Errorf("%s: expected %d tokens, got %d", name, want, got)
+		}
+	}
+
+	if got := tokenizer.CountTokens(""); got != 0 {
+		t.Errorf("Expected no tokens for empty text, got %d", got)
+	}
+}
+
+func TestBPETokenizer_O200KSplit(t *testing.T) {
+	// Words split where their case changes and keep their contractions
+	text := "I'VE don't HTMLParser retryWithBackoff ÉCOLE naïve 12345 a/b//\n"
+	want := []string{"I'VE", " don't", " HTMLParser", " retry", "With", "Backoff", " ÉCOLE", " naïve", " ", "123", "45", " a", "/b", "//\n"}
+	if got := splitO200K(text); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected pieces %q, got %q", want, got)
+	}
+
+	tokenizer, err := LoadBPETokenizer(filepath.Join(tokenizerTestdata, "fixture.tiktoken"), "o200k_base", 0)
+	if err != nil {
+		t.Fatalf("Failed to load fixture vocabulary: %v", err)
+	}
+	for name, want := range loadCounts(t, "counts_o200k.json") {
+		if got := tokenizer.CountTokens(readSample(t, name)); got != want {
+			t.Errorf("%s: expected %d tokens, got %d", name, want, got)
+		}
+	}
+}
+
+func TestHeuristicTokenizerFor_Families(t *testing.T) {
+	// Calibrated estimates stay within two tokens of published counts
+	for text, want := range cl100kReferences {
+		if got := CountTokens("gpt-4", text); got < want-2 || got > want+2 {
+			t.Errorf("%q: expected about %d tokens, got %d", text, want, got)
+		}
+	}
+
+	// Each family counts CJK text its own way; unknown models get the plain heuristic
+	text := readSample(t, "cjk.txt")
+	counts := make(map[int]bool)
+	for _, model := range []string{"gpt-4o-mini", "gpt-4", "claude-3-haiku", "meta-llama/Llama-2-7b-chat", "qwen2.5:7b"} {
+		counts[CountTokens(model, text)] = true
+	}
+	if len(counts) != 5 {
+		t.Errorf("Expected a different CJK count per family, got %v", counts)
+	}
+	if CountTokens("llama3.1:8b", text) != CountTokens("gpt-4", text) {
+		t.Error("Expected Llama 3 counted like cl100k_base, whose vocabulary it extends")
+	}
+	if got, want := CountTokens("unknown-model", text), HeuristicTokenizer().CountTokens(text); got != want {
+		t.Errorf("Expected the plain heuristic's %d for an unknown model, got %d", want, got)
+	}
+}
+
+func TestHeuristicTokenizer_Tolerance(t *testing.T) {
+	// Documented tolerance against BPE counts: within 50% for prose and
+	// code, and no less than half the true count for CJK
+	const lower, upper = 0.5, 1.5
+
+	heuristic := HeuristicTokenizer()
+	if heuristic.Name() != HeuristicTokenizerName {
+		t.Errorf("Expected heuristic tokenizer name %q, got %q", HeuristicTokenizerName, heuristic.Name())
+	}
+	for name, want := range loadReferenceCounts(t) {
+		got := heuristic.CountTokens(readSample(t, name))
+		ratio := float64(got) / float64(want)
+		if ratio < lower || ratio > upper {
+			t.Errorf("%s: heuristic count %d is %.2f of the reference %d, outside [%.2f, %.2f]",
+				name, got, ratio, want, lower, upper)
+		}
+	}
+}
+
+func TestLoadBPETokenizer_Limits(t *testing.T) {
+	path := filepath.Join(tokenizerTestdata, "fixture.tiktoken")
+	if _, err := LoadBPETokenizer(path, "fixture", 1024); err == nil {
+		t.Error("Expected a vocabulary over the size limit to be refused")
+	}
+
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "bad.tiktoken")
+	if err := os.WriteFile(malformed, []byte("aGVsbG8=\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBPETokenizer(malformed, "bad", 0); err == nil {
+		t.Error("Expected a line without a rank to be refused")
+	}
+}
+
+func TestTokenEstimator_Fallback(t *testing.T) {
+	text := readSample(t, "prose.txt")
+	heuristic := HeuristicTokenizerFor("gpt-4").CountTokens(text)
+
+	// No vocabulary directory configured
+	estimator := NewTokenEstimator(TokenizerConfig{})
+	estimate := estimator.Estimate("gpt-4", text)
+	if estimate.Tokenizer != HeuristicTokenizerName || estimate.Exact || estimate.Tokens != heuristic {
+		t.Errorf("Expected the model family's heuristic without a vocabulary directory, got %+v", estimate)
+	}
+	if _, _, err := estimator.Tokenizer("gpt-4"); !errors.Is(err, ErrNoVocabulary) {
+		t.Errorf("Expected ErrNoVocabulary, got %v", err)
+	}
+
+	// Directory configured, but the vocabulary file is missing
+	estimator = NewTokenEstimator(TokenizerConfig{VocabularyDir: t.TempDir()})
+	if estimate := estimator.Estimate("gpt-4", text); estimate.Tokenizer != HeuristicTokenizerName {
+		t.Errorf("Expected the heuristic for a missing vocabulary, got %+v", estimate)
+	}
+	if _, _, err := estimator.Tokenizer("gpt-4"); err == nil || errors.Is(err, ErrNoVocabulary) {
+		t.Errorf("Expected the load failure to be reported, got %v", err)
+	}
+
+	// No vocabulary mapped to the model
+	estimator = NewTokenEstimator(TokenizerConfig{VocabularyDir: vocabularyDir(t, "cl100k_base")})
+	if estimate := estimator.Estimate("claude-3-haiku", text); estimate.Tokenizer != HeuristicTokenizerName {
+		t.Errorf("Expected the heuristic for an unmapped model, got %+v", estimate)
+	}
+	if loaded := estimator.Loaded(); len(loaded) != 0 {
+		t.Errorf("Expected no vocabulary to be loaded, got %v", loaded)
+	}
+}
+
+func TestTokenEstimator_ModelMapping(t *testing.T) {
+	counts := loadReferenceCounts(t)
+	text := readSample(t, "code.txt")
+
+	estimator := NewTokenEstimator(TokenizerConfig{
+		VocabularyDir: vocabularyDir(t, "cl100k_base", "fixture"),
+		Models:        map[string]string{"claude-*": "fixture"},
+	})
+
+	// Models using the vocabulary they were made for are exact, prefixes included
+	for _, model := range []string{"gpt-4", "gpt-4-turbo", "gpt-3.5-turbo-16k"} {
+		estimate := estimator.Estimate(model, text)
+		if estimate.Tokens != counts["code.txt"] || estimate.Tokenizer != "cl100k_base" || !estimate.Exact {
+			t.Errorf("%s: expected an exact cl100k_base count of %d, got %+v", model, counts["code.txt"], estimate)
+		}
+	}
+
+	// Configured stand-ins count with the vocabulary but are approximate
+	estimate := estimator.Estimate("claude-3-sonnet", text)
+	if estimate.Tokens != counts["code.txt"] || estimate.Tokenizer != "fixture" || estimate.Exact {
+		t.Errorf("Expected an approximate fixture count of %d, got %+v", counts["code.txt"], estimate)
+	}
+}
+
+func TestTokenEstimator_LazyBoundedCache(t *testing.T) {
+	estimator := NewTokenEstimator(TokenizerConfig{
+		VocabularyDir: vocabularyDir(t, "one", "two", "three"),
+		Models:        map[string]string{"model-one": "one", "model-two": "two", "model-three": "three"},
+		MaxLoaded:     2,
+	})
+
+	if loaded := estimator.Loaded(); len(loaded) != 0 {
+		t.Fatalf("Expected vocabularies to load on first use, got %v", loaded)
+	}
+
+	estimator.Estimate("model-one", "hello")
+	estimator.Estimate("model-two", "hello")
+	estimator.Estimate("model-one", "hello") // Now the most recently used
+	estimator.Estimate("model-three", "hello")
+
+	if loaded := strings.Join(estimator.Loaded(), ","); loaded != "three,one" {
+		t.Errorf("Expected the least recently used vocabulary to be dropped, got %s", loaded)
+	}
+
+	// A dropped vocabulary is reloaded when needed again
+	if estimate := estimator.Estimate("model-two", "hello"); estimate.Tokenizer != "two" {
+		t.Errorf("Expected the dropped vocabulary to reload, got %+v", estimate)
+	}
+	if loaded := strings.Join(estimator.Loaded(), ","); loaded != "two,three" {
+		t.Errorf("Expected the reloaded vocabulary to be most recent, got %s", loaded)
+	}
+}
+
+func TestRouter_RecordsTokenEstimate(t *testing.T) {
+	config := DefaultRouterConfig()
+	config.TokenEstimator = NewTokenEstimator(TokenizerConfig{VocabularyDir: vocabularyDir(t, "cl100k_base")})
+	router := NewRouter(NewMockLLMService(), config)
+
+	prompt := readSample(t, "prose.txt")
+	req := TaskRequest{Prompt: prompt, TaskType: "analysis", QualityRequired: QualityStandard, MaxTokens: 100}
+	assessment := router.assessTask(req)
+
+	recommendations := router.scoreModels(router.getAvailableModels(), assessment, req)
+	if len(recommendations) == 0 {
+		t.Fatal("Should have at least one recommendation")
+	}
+	sawExact, sawHeuristic := false, false
+	for _, rec := range recommendations {
+		switch {
+		case rec.Model == "gpt-4":
+			sawExact = true
+			if !rec.TokenEstimate.Exact || rec.TokenEstimate.Tokens != loadReferenceCounts(t)["prose.txt"] {
+				t.Errorf("Expected an exact prompt count for gpt-4, got %+v", rec.TokenEstimate)
+			}
+		case rec.Provider == "anthropic":
+			sawHeuristic = true
+			if rec.TokenEstimate.Tokenizer != HeuristicTokenizerName || rec.TokenEstimate.Exact {
+				t.Errorf("Expected a heuristic prompt count for %s, got %+v", rec.Model, rec.TokenEstimate)
+			}
+		}
+	}
+	if !sawExact || !sawHeuristic {
+		t.Errorf("Expected both gpt-4 and an Anthropic model to be scored, got %d recommendations", len(recommendations))
+	}
+}
+
+func TestBPETokenizer_InstalledVocabulary(t *testing.T) {
+	// Checks against published cl100k_base counts when the real vocabulary is installed
+	dir := os.Getenv("AI_WORK_STUDIO_VOCABULARY_DIR")
+	path := filepath.Join(dir, "cl100k_base.tiktoken")
+	if _, err := os.Stat(path); dir == "" || err != nil {
+		t.Skip("cl100k_base.tiktoken not installed in AI_WORK_STUDIO_VOCABULARY_DIR")
+	}
+	tokenizer, err := LoadBPETokenizer(path, "cl100k_base", 0)
+	if err != nil {
+		t.Fatalf("Failed to load cl100k_base: %v", err)
+	}
+
+	for text, want := range cl100kReferences {
+		if got := tokenizer.CountTokens(text); got != want {
+			t.Errorf("%q: expected %d tokens, got %d", text, want, got)
+		}
+	}
+
+	// The calibrated heuristic stays within a quarter of the real counts
+	for _, name := range []string{"prose.txt", "code.txt", "cjk.txt"} {
+		text := readSample(t, name)
+		want, got := tokenizer.CountTokens(text), CountTokens("gpt-4", text)
+		if ratio := float64(got) / float64(want); ratio < 0.75 || ratio > 1.25 {
+			t.Errorf("%s: heuristic count %d is %.2f of the real %d", name, got, ratio, want)
+		}
+	}
+}
@@ -0,0 +1,711 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// WireFormatVersion is the version of the JSON encoding of TaskRequest and
+// RoutingResult. Bump it, and keep decoding the older versions, whenever the
+// encoding changes in a way older readers would misread.
+const WireFormatVersion = 1
+
+// Metadata value kinds in the wire format. Every metadata value is encoded as
+// a small envelope naming its kind, so that types JSON cannot tell apart,
+// such as times and strings, survive a round trip.
+const (
+	wireKindNull     = "null"
+	wireKindString   = "string"
+	wireKindBool     = "bool"
+	wireKindInt      = "int"
+	wireKindFloat    = "float"
+	wireKindTime     = "time"
+	wireKindDuration = "duration"
+	wireKindList     = "list"
+	wireKindMap      = "map"
+	wireKindJSON     = "json"
+)
+
+// wireValue is the envelope for one metadata value.
+type wireValue struct {
+	Kind  string          `json:"kind"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// taskRequestWire is the wire form of a TaskRequest.
+type taskRequestWire struct {
+	Version            int                  `json:"version"`
+	Prompt             string               `json:"prompt"`
+	Messages           []mcp.Message        `json:"messages,omitempty"`
+	MaxTokens          int                  `json:"max_tokens"`
+	Temperature        float64              `json:"temperature"`
+	TaskType           string               `json:"task_type,omitempty"`
+	QualityRequired    QualityRequirement   `json:"quality_required"`
+	BudgetConstraint   *float64             `json:"budget_constraint,omitempty"`
+	PreferredProvider  string               `json:"preferred_provider,omitempty"`
+	Sensitive          bool                 `json:"sensitive,omitempty"`
+	Metadata           map[string]wireValue `json:"metadata,omitempty"`
+	AllowDecomposition bool                 `json:"allow_decomposition,omitempty"`
+}
+
+// routingResultWire is the wire form of a RoutingResult.
+type routingResultWire struct {
+	Version           int                       `json:"version"`
+	Assessment        taskAssessmentWire        `json:"assessment"`
+	SelectedModel     modelRecommendationWire   `json:"selected_model"`
+	AlternativeModels []modelRecommendationWire `json:"alternative_models,omitempty"`
+	FailedModels      []modelRecommendationWire `json:"failed_models,omitempty"`
+	Failures          []modelFailureWire        `json:"failures,omitempty"`
+	ExcludedModels    []modelExclusionWire      `json:"excluded_models,omitempty"`
+	Refusals          []modelRefusalWire        `json:"refusals,omitempty"`
+	Rephrased         bool                      `json:"rephrased,omitempty"`
+	ExecutionResult   *completionResponseWire   `json:"execution_result,omitempty"`
+	ExecutionTime     time.Time                 `json:"execution_time"`
+	UserRating        float64                   `json:"user_rating"`
+	ID                string                    `json:"id,omitempty"`
+	Decomposed        bool                      `json:"decomposed,omitempty"`
+	Chunks            []chunkResultWire         `json:"chunks,omitempty"`
+}
+
+type taskAssessmentWire struct {
+	Complexity        TaskComplexity            `json:"complexity"`
+	EstimatedTokens   int                       `json:"estimated_tokens"`
+	QualityNeeded     QualityRequirement        `json:"quality_needed"`
+	RecommendedModels []modelRecommendationWire `json:"recommended_models,omitempty"`
+	Reasoning         string                    `json:"reasoning,omitempty"`
+}
+
+type modelRecommendationWire struct {
+	Provider      string          `json:"provider"`
+	Model         string          `json:"model"`
+	EstimatedCost float64         `json:"estimated_cost"`
+	QualityScore  float64         `json:"quality_score"`
+	SpeedScore    float64         `json:"speed_score"`
+	CostScore     float64         `json:"cost_score,omitempty"`
+	OverallScore  float64         `json:"overall_score"`
+	Components    ScoreComponents `json:"components,omitzero"`
+	Reasoning     string          `json:"reasoning,omitempty"`
+	TokenEstimate TokenEstimate   `json:"token_estimate,omitzero"`
+}
+
+type modelExclusionWire struct {
+	Model  modelRecommendationWire `json:"model"`
+	Reason ExclusionReason         `json:"reason"`
+}
+
+type modelFailureWire struct {
+	Model  modelRecommendationWire `json:"model"`
+	Code   errs.Code               `json:"code"`
+	Reason string                  `json:"reason"`
+}
+
+type modelRefusalWire struct {
+	Model     modelRecommendationWire `json:"model"`
+	Reason    string                  `json:"reason"`
+	Rephrased bool                    `json:"rephrased,omitempty"`
+}
+
+type chunkResultWire struct {
+	Index        int     `json:"index"`
+	Start        int     `json:"start"`
+	End          int     `json:"end"`
+	PromptTokens int     `json:"prompt_tokens"`
+	RoutingID    string  `json:"routing_id,omitempty"`
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	TokensUsed   int     `json:"tokens_used"`
+	Cost         float64 `json:"cost"`
+}
+
+type completionResponseWire struct {
+	Text       string               `json:"text"`
+	TokensUsed int                  `json:"tokens_used"`
+	Model      string               `json:"model"`
+	Provider   string               `json:"provider"`
+	Cost       float64              `json:"cost"`
+	Metadata   map[string]wireValue `json:"metadata,omitempty"`
+}
+
+// legacyTaskRequest and legacyRoutingResult decode records written before the
+// wire format existed, by plain json.Marshal: Go field names, enums as
+// integers and metadata as bare JSON values.
+type legacyTaskRequest TaskRequest
+type legacyRoutingResult RoutingResult
+
+// MarshalJSON encodes the request in the versioned wire format.
+func (req TaskRequest) MarshalJSON() ([]byte, error) {
+	metadata, err := encodeWireMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(taskRequestWire{
+		Version:            WireFormatVersion,
+		Prompt:             req.Prompt,
+		Messages:           req.Messages,
+		MaxTokens:          req.MaxTokens,
+		Temperature:        req.Temperature,
+		TaskType:           req.TaskType,
+		QualityRequired:    req.QualityRequired,
+		BudgetConstraint:   req.BudgetConstraint,
+		PreferredProvider:  req.PreferredProvider,
+		Sensitive:          req.Sensitive,
+		Metadata:           metadata,
+		AllowDecomposition: req.AllowDecomposition,
+	})
+}
+
+// UnmarshalJSON decodes a request in the wire format, or converts one written
+// in the older unversioned encoding. Unknown fields are ignored so that
+// payloads from newer minor revisions still decode.
+func (req *TaskRequest) UnmarshalJSON(data []byte) error {
+	version, err := wireVersion(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode task request: %w", err)
+	}
+	if version == 0 {
+		var legacy legacyTaskRequest
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("failed to decode legacy task request: %w", err)
+		}
+		*req = TaskRequest(legacy)
+		req.Metadata = normalizeLegacyMetadata(req.Metadata)
+		return nil
+	}
+
+	var wire taskRequestWire
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return fmt.Errorf("failed to decode task request: %w", err)
+	}
+	metadata, err := decodeWireMetadata(wire.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to decode task request metadata: %w", err)
+	}
+
+	*req = TaskRequest{
+		Prompt:             wire.Prompt,
+		Messages:           wire.Messages,
+		MaxTokens:          wire.MaxTokens,
+		Temperature:        wire.Temperature,
+		TaskType:           wire.TaskType,
+		QualityRequired:    wire.QualityRequired,
+		BudgetConstraint:   wire.BudgetConstraint,
+		PreferredProvider:  wire.PreferredProvider,
+		Sensitive:          wire.Sensitive,
+		Metadata:           metadata,
+		AllowDecomposition: wire.AllowDecomposition,
+	}
+	return nil
+}
+
+// MarshalJSON encodes the routing result in the versioned wire format.
+func (result RoutingResult) MarshalJSON() ([]byte, error) {
+	wire := routingResultWire{
+		Version: WireFormatVersion,
+		Assessment: taskAssessmentWire{
+			Complexity:        result.Assessment.Complexity,
+			EstimatedTokens:   result.Assessment.EstimatedTokens,
+			QualityNeeded:     result.Assessment.QualityNeeded,
+			RecommendedModels: recommendationsToWire(result.Assessment.RecommendedModels),
+			Reasoning:         result.Assessment.Reasoning,
+		},
+		SelectedModel:     modelRecommendationWire(result.SelectedModel),
+		AlternativeModels: recommendationsToWire(result.AlternativeModels),
+		FailedModels:      recommendationsToWire(result.FailedModels),
+		Failures:          failuresToWire(result.Failures),
+		ExcludedModels:    exclusionsToWire(result.ExcludedModels),
+		Refusals:          refusalsToWire(result.Refusals),
+		Rephrased:         result.Rephrased,
+		ExecutionTime:     result.ExecutionTime,
+		UserRating:        result.UserRating,
+		ID:                result.ID,
+		Decomposed:        result.Decomposed,
+		Chunks:            chunksToWire(result.Chunks),
+	}
+
+	if response := result.ExecutionResult; response != nil {
+		metadata, err := encodeWireMetadata(response.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		wire.ExecutionResult = &completionResponseWire{
+			Text:       response.Text,
+			TokensUsed: response.TokensUsed,
+			Model:      response.Model,
+			Provider:   response.Provider,
+			Cost:       response.Cost,
+			Metadata:   metadata,
+		}
+	}
+
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON decodes a routing result in the wire format, or converts one
+// written in the older unversioned encoding.
+func (result *RoutingResult) UnmarshalJSON(data []byte) error {
+	version, err := wireVersion(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode routing result: %w", err)
+	}
+	if version == 0 {
+		var legacy legacyRoutingResult
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("failed to decode legacy routing result: %w", err)
+		}
+		*result = RoutingResult(legacy)
+		if result.ExecutionResult != nil {
+			result.ExecutionResult.Metadata = normalizeLegacyMetadata(result.ExecutionResult.Metadata)
+		}
+		return nil
+	}
+
+	var wire routingResultWire
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return fmt.Errorf("failed to decode routing result: %w", err)
+	}
+
+	*result = RoutingResult{
+		Assessment: TaskAssessment{
+			Complexity:        wire.Assessment.Complexity,
+			EstimatedTokens:   wire.Assessment.EstimatedTokens,
+			QualityNeeded:     wire.Assessment.QualityNeeded,
+			RecommendedModels: recommendationsFromWire(wire.Assessment.RecommendedModels),
+			Reasoning:         wire.Assessment.Reasoning,
+		},
+		SelectedModel:     ModelRecommendation(wire.SelectedModel),
+		AlternativeModels: recommendationsFromWire(wire.AlternativeModels),
+		FailedModels:      recommendationsFromWire(wire.FailedModels),
+		Failures:          failuresFromWire(wire.Failures),
+		ExcludedModels:    exclusionsFromWire(wire.ExcludedModels),
+		Refusals:          refusalsFromWire(wire.Refusals),
+		Rephrased:         wire.Rephrased,
+		ExecutionTime:     wire.ExecutionTime,
+		UserRating:        wire.UserRating,
+		ID:                wire.ID,
+		Decomposed:        wire.Decomposed,
+		Chunks:            chunksFromWire(wire.Chunks),
+	}
+
+	if response := wire.ExecutionResult; response != nil {
+		metadata, err := decodeWireMetadata(response.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to decode execution result metadata: %w", err)
+		}
+		result.ExecutionResult = &mcp.CompletionResponse{
+			Text:       response.Text,
+			TokensUsed: response.TokensUsed,
+			Model:      response.Model,
+			Provider:   response.Provider,
+			Cost:       response.Cost,
+			Metadata:   metadata,
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the quality requirement by name, so that reordering
+// the constants cannot change what stored records mean.
+func (qr QualityRequirement) MarshalJSON() ([]byte, error) {
+	name, err := qualityName(qr)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(name)
+}
+
+// UnmarshalJSON decodes a quality requirement from its name, or from the
+// integer used by the older encoding.
+func (qr *QualityRequirement) UnmarshalJSON(data []byte) error {
+	value, err := decodeEnum(data, []string{"basic", "standard", "premium"})
+	if err != nil {
+		return fmt.Errorf("invalid quality requirement: %w", err)
+	}
+	*qr = QualityRequirement(value)
+	return nil
+}
+
+// MarshalJSON encodes the task complexity by name.
+func (tc TaskComplexity) MarshalJSON() ([]byte, error) {
+	if tc < TaskComplexitySimple || tc > TaskComplexityComplex {
+		return nil, fmt.Errorf("invalid task complexity %d", int(tc))
+	}
+	return json.Marshal(tc.String())
+}
+
+// UnmarshalJSON decodes a task complexity from its name, or from the integer
+// used by the older encoding.
+func (tc *TaskComplexity) UnmarshalJSON(data []byte) error {
+	value, err := decodeEnum(data, []string{"simple", "moderate", "complex"})
+	if err != nil {
+		return fmt.Errorf("invalid task complexity: %w", err)
+	}
+	*tc = TaskComplexity(value)
+	return nil
+}
+
+// qualityName returns the wire name of a quality requirement.
+func qualityName(qr QualityRequirement) (string, error) {
+	if qr < QualityBasic || qr > QualityPremium {
+		return "", fmt.Errorf("invalid quality requirement %d", int(qr))
+	}
+	return qr.String(), nil
+}
+
+// decodeEnum returns the position of an enum name in names, also accepting
+// the position itself as a legacy integer.
+func decodeEnum(data []byte, names []string) (int, error) {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		for i, candidate := range names {
+			if candidate == name {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+
+	var value int
+	if err := json.Unmarshal(data, &value); err != nil {
+		return 0, fmt.Errorf("expected a name, got %s", data)
+	}
+	if value < 0 || value >= len(names) {
+		return 0, fmt.Errorf("value %d out of range", value)
+	}
+	return value, nil
+}
+
+// wireVersion reads the format version of a payload; 0 means the payload
+// predates the wire format.
+func wireVersion(data []byte) (int, error) {
+	var header struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	if header.Version == nil {
+		return 0, nil
+	}
+	if *header.Version < 1 || *header.Version > WireFormatVersion {
+		return 0, fmt.Errorf("unsupported wire format version %d", *header.Version)
+	}
+	return *header.Version, nil
+}
+
+func recommendationsToWire(models []ModelRecommendation) []modelRecommendationWire {
+	if models == nil {
+		return nil
+	}
+	wire := make([]modelRecommendationWire, len(models))
+	for i, model := range models {
+		wire[i] = modelRecommendationWire(model)
+	}
+	return wire
+}
+
+func recommendationsFromWire(wire []modelRecommendationWire) []ModelRecommendation {
+	if wire == nil {
+		return nil
+	}
+	models := make([]ModelRecommendation, len(wire))
+	for i, model := range wire {
+		models[i] = ModelRecommendation(model)
+	}
+	return models
+}
+
+func exclusionsToWire(exclusions []ModelExclusion) []modelExclusionWire {
+	if exclusions == nil {
+		return nil
+	}
+	wire := make([]modelExclusionWire, len(exclusions))
+	for i, exclusion := range exclusions {
+		wire[i] = modelExclusionWire{Model: modelRecommendationWire(exclusion.Model), Reason: exclusion.Reason}
+	}
+	return wire
+}
+
+func exclusionsFromWire(wire []modelExclusionWire) []ModelExclusion {
+	if wire == nil {
+		return nil
+	}
+	exclusions := make([]ModelExclusion, len(wire))
+	for i, exclusion := range wire {
+		exclusions[i] = ModelExclusion{Model: ModelRecommendation(exclusion.Model), Reason: exclusion.Reason}
+	}
+	return exclusions
+}
+
+func failuresToWire(failures []ModelFailure) []modelFailureWire {
+	if failures == nil {
+		return nil
+	}
+	wire := make([]modelFailureWire, len(failures))
+	for i, failure := range failures {
+		wire[i] = modelFailureWire{Model: modelRecommendationWire(failure.Model), Code: failure.Code, Reason: failure.Reason}
+	}
+	return wire
+}
+
+func failuresFromWire(wire []modelFailureWire) []ModelFailure {
+	if wire == nil {
+		return nil
+	}
+	failures := make([]ModelFailure, len(wire))
+	for i, failure := range wire {
+		failures[i] = ModelFailure{Model: ModelRecommendation(failure.Model), Code: failure.Code, Reason: failure.Reason}
+	}
+	return failures
+}
+
+func refusalsToWire(refusals []ModelRefusal) []modelRefusalWire {
+	if refusals == nil {
+		return nil
+	}
+	wire := make([]modelRefusalWire, len(refusals))
+	for i, refusal := range refusals {
+		wire[i] = modelRefusalWire{Model: modelRecommendationWire(refusal.Model), Reason: refusal.Reason, Rephrased: refusal.Rephrased}
+	}
+	return wire
+}
+
+func refusalsFromWire(wire []modelRefusalWire) []ModelRefusal {
+	if wire == nil {
+		return nil
+	}
+	refusals := make([]ModelRefusal, len(wire))
+	for i, refusal := range wire {
+		refusals[i] = ModelRefusal{Model: ModelRecommendation(refusal.Model), Reason: refusal.Reason, Rephrased: refusal.Rephrased}
+	}
+	return refusals
+}
+
+func chunksToWire(chunks []ChunkResult) []chunkResultWire {
+	if chunks == nil {
+		return nil
+	}
+	wire := make([]chunkResultWire, len(chunks))
+	for i, chunk := range chunks {
+		wire[i] = chunkResultWire(chunk)
+	}
+	return wire
+}
+
+func chunksFromWire(wire []chunkResultWire) []ChunkResult {
+	if wire == nil {
+		return nil
+	}
+	chunks := make([]ChunkResult, len(wire))
+	for i, chunk := range wire {
+		chunks[i] = ChunkResult(chunk)
+	}
+	return chunks
+}
+
+// encodeWireMetadata wraps each metadata value in a typed envelope.
+func encodeWireMetadata(metadata map[string]interface{}) (map[string]wireValue, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	wire := make(map[string]wireValue, len(metadata))
+	for key, value := range metadata {
+		encoded, err := encodeWireValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %q: %w", key, err)
+		}
+		wire[key] = encoded
+	}
+	return wire, nil
+}
+
+// decodeWireMetadata unwraps metadata envelopes into normalized Go values.
+func decodeWireMetadata(wire map[string]wireValue) (map[string]interface{}, error) {
+	if wire == nil {
+		return nil, nil
+	}
+	metadata := make(map[string]interface{}, len(wire))
+	for key, encoded := range wire {
+		value, err := decodeWireValue(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %q: %w", key, err)
+		}
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
+// encodeWireValue wraps one value. Values decode to normalized types: all
+// integers to int, all floats to float64, slices to []interface{} and maps
+// with string keys to map[string]interface{}. Other types are kept as their
+// plain JSON encoding.
+func encodeWireValue(value interface{}) (wireValue, error) {
+	switch v := value.(type) {
+	case nil:
+		return wireValue{Kind: wireKindNull}, nil
+	case time.Time:
+		return newWireValue(wireKindTime, v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return newWireValue(wireKindDuration, v.String())
+	case json.RawMessage:
+		return wireValue{Kind: wireKindJSON, Value: v}, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return newWireValue(wireKindString, rv.String())
+	case reflect.Bool:
+		return newWireValue(wireKindBool, rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return newWireValue(wireKindInt, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return wireValue{}, fmt.Errorf("integer %d is out of range", rv.Uint())
+		}
+		return newWireValue(wireKindInt, int64(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(rv.Float()) || math.IsInf(rv.Float(), 0) {
+			return wireValue{}, fmt.Errorf("float %v cannot be encoded", rv.Float())
+		}
+		return newWireValue(wireKindFloat, rv.Float())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return wireValue{Kind: wireKindNull}, nil
+		}
+		items := make([]wireValue, rv.Len())
+		for i := range items {
+			item, err := encodeWireValue(rv.Index(i).Interface())
+			if err != nil {
+				return wireValue{}, err
+			}
+			items[i] = item
+		}
+		return newWireValue(wireKindList, items)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if rv.IsNil() {
+			return wireValue{Kind: wireKindNull}, nil
+		}
+		entries := make(map[string]wireValue, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			entry, err := encodeWireValue(iter.Value().Interface())
+			if err != nil {
+				return wireValue{}, err
+			}
+			entries[iter.Key().String()] = entry
+		}
+		return newWireValue(wireKindMap, entries)
+	}
+
+	return newWireValue(wireKindJSON, value)
+}
+
+func newWireValue(kind string, value interface{}) (wireValue, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return wireValue{}, fmt.Errorf("failed to encode %s value: %w", kind, err)
+	}
+	return wireValue{Kind: kind, Value: encoded}, nil
+}
+
+// decodeWireValue unwraps one value.
+func decodeWireValue(wire wireValue) (interface{}, error) {
+	switch wire.Kind {
+	case wireKindNull:
+		return nil, nil
+	case wireKindString:
+		var s string
+		err := json.Unmarshal(wire.Value, &s)
+		return s, err
+	case wireKindBool:
+		var b bool
+		err := json.Unmarshal(wire.Value, &b)
+		return b, err
+	case wireKindInt:
+		var i int
+		err := json.Unmarshal(wire.Value, &i)
+		return i, err
+	case wireKindFloat:
+		var f float64
+		err := json.Unmarshal(wire.Value, &f)
+		return f, err
+	case wireKindTime:
+		var s string
+		if err := json.Unmarshal(wire.Value, &s); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case wireKindDuration:
+		var s string
+		if err := json.Unmarshal(wire.Value, &s); err != nil {
+			return nil, err
+		}
+		return time.ParseDuration(s)
+	case wireKindList:
+		var items []wireValue
+		if err := json.Unmarshal(wire.Value, &items); err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := decodeWireValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		return list, nil
+	case wireKindMap:
+		var entries map[string]wireValue
+		if err := json.Unmarshal(wire.Value, &entries); err != nil {
+			return nil, err
+		}
+		return decodeWireMetadata(entries)
+	case wireKindJSON:
+		var value interface{}
+		err := json.Unmarshal(wire.Value, &value)
+		return value, err
+	default:
+		return nil, fmt.Errorf("unknown value kind %q", wire.Kind)
+	}
+}
+
+// normalizeLegacyMetadata converts metadata decoded from the older encoding
+// to the types the wire format produces: whole numbers become int and
+// RFC 3339 timestamps become time.Time.
+func normalizeLegacyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	for key, value := range metadata {
+		metadata[key] = normalizeLegacyValue(value)
+	}
+	return metadata
+}
+
+func normalizeLegacyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int(v)
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeLegacyValue(item)
+		}
+	case map[string]interface{}:
+		return normalizeLegacyMetadata(v)
+	}
+	return value
+}
@@ -0,0 +1,253 @@
+package llm
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+var updateWireGolden = flag.Bool("update-wire", false, "rewrite the wire format golden file")
+
+// wireFixture returns a request and result that exercise every field.
+func wireFixture() (TaskRequest, RoutingResult) {
+	budget := 0.25
+	requestedAt := time.Date(2026, 3, 14, 9, 26, 53, 589793000, time.UTC)
+
+	req := TaskRequest{
+		Prompt: "Summarize the quarterly report",
+		Messages: []mcp.Message{
+			{Role: mcp.RoleUser, Content: "Here is the quarterly report."},
+			{Role: mcp.RoleAssistant, Content: "Got it."},
+		},
+		MaxTokens:          500,
+		Temperature:        0.3,
+		TaskType:           "summarization",
+		QualityRequired:    QualityPremium,
+		BudgetConstraint:   &budget,
+		PreferredProvider:  "anthropic",
+		AllowDecomposition: true,
+		Metadata: map[string]interface{}{
+			"objective_id": "obj-42",
+			"attempt":      2,
+			"score":        0.75,
+			"urgent":       true,
+			"requested_at": requestedAt,
+			"timeout":      90 * time.Second,
+			"tags":         []interface{}{"report", 3},
+			"source":       map[string]interface{}{"kind": "file", "size": 1024},
+			"none":         nil,
+		},
+	}
+
+	haiku := ModelRecommendation{Provider: "anthropic", Model: "claude-3-haiku", EstimatedCost: 0.001, QualityScore: 0.7, SpeedScore: 0.9, OverallScore: 0.8, Reasoning: "fast",
+		TokenEstimate: TokenEstimate{Tokens: 12, Tokenizer: HeuristicTokenizerName}}
+	sonnet := ModelRecommendation{Provider: "anthropic", Model: "claude-3-sonnet", EstimatedCost: 0.01, QualityScore: 0.9, SpeedScore: 0.6, OverallScore: 0.85}
+	gpt := ModelRecommendation{Provider: "openai", Model: "gpt-4", EstimatedCost: 0.03, QualityScore: 0.95, SpeedScore: 0.4, OverallScore: 0.7,
+		TokenEstimate: TokenEstimate{Tokens: 8, Tokenizer: "cl100k_base", Exact: true}}
+
+	result := RoutingResult{
+		Assessment: TaskAssessment{
+			Complexity:        TaskComplexityModerate,
+			EstimatedTokens:   620,
+			QualityNeeded:     QualityPremium,
+			RecommendedModels: []ModelRecommendation{sonnet, haiku, gpt},
+			Reasoning:         "moderate summarization",
+		},
+		SelectedModel:     haiku,
+		AlternativeModels: []ModelRecommendation{gpt},
+		FailedModels:      []ModelRecommendation{sonnet},
+		Failures:          []ModelFailure{{Model: sonnet, Code: errs.QuotaExceeded, Reason: "rate limited"}},
+		ExcludedModels:    []ModelExclusion{{Model: gpt, Reason: ExclusionAuthFailed}},
+		Decomposed:        true,
+		Chunks: []ChunkResult{
+			{Index: 0, Start: 0, End: 18, PromptTokens: 40, RoutingID: "rt-1", Provider: "anthropic", Model: "claude-3-haiku", TokensUsed: 90, Cost: 0.0002},
+			{Index: 1, Start: 12, End: 30, PromptTokens: 42, RoutingID: "rt-2", Provider: "anthropic", Model: "claude-3-haiku", TokensUsed: 95, Cost: 0.0002},
+		},
+		ExecutionResult: &mcp.CompletionResponse{
+			Text:       "Revenue grew 12%.",
+			TokensUsed: 480,
+			Model:      "claude-3-haiku",
+			Provider:   "anthropic",
+			Cost:       0.0009,
+			Metadata:   map[string]interface{}{"finished_at": requestedAt.Add(time.Second), "stop_reason": "end_turn"},
+		},
+		ExecutionTime: requestedAt.Add(2 * time.Second),
+		UserRating:    8.5,
+	}
+	return req, result
+}
+
+func TestWireFormat_RoundTrip(t *testing.T) {
+	req, result := wireFixture()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+	var decodedReq TaskRequest
+	if err := json.Unmarshal(data, &decodedReq); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+	if !reflect.DeepEqual(req, decodedReq) {
+		t.Errorf("Request did not round-trip:\nwant %#v\n got %#v", req, decodedReq)
+	}
+
+	data, err = json.Marshal(&result)
+	if err != nil {
+		t.Fatalf("Failed to encode result: %v", err)
+	}
+	var decodedResult RoutingResult
+	if err := json.Unmarshal(data, &decodedResult); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if !reflect.DeepEqual(result, decodedResult) {
+		t.Errorf("Result did not round-trip:\nwant %#v\n got %#v", result, decodedResult)
+	}
+}
+
+func TestWireFormat_PointerPresenceAndEnums(t *testing.T) {
+	zero := 0.0
+	for _, budget := range []*float64{nil, &zero} {
+		for _, quality := range []QualityRequirement{QualityBasic, QualityStandard, QualityPremium} {
+			req := TaskRequest{Prompt: "p", QualityRequired: quality, BudgetConstraint: budget}
+			data, err := json.Marshal(req)
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			if !strings.Contains(string(data), `"quality_required":"`+quality.String()+`"`) {
+				t.Errorf("Expected the quality to be encoded by name, got %s", data)
+			}
+
+			var decoded TaskRequest
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Failed to decode: %v", err)
+			}
+			if decoded.QualityRequired != quality {
+				t.Errorf("Expected quality %s, got %s", quality, decoded.QualityRequired)
+			}
+			if (budget == nil) != (decoded.BudgetConstraint == nil) {
+				t.Errorf("Expected budget presence %v to survive, got %v", budget != nil, decoded.BudgetConstraint)
+			}
+		}
+	}
+
+	for _, complexity := range []TaskComplexity{TaskComplexitySimple, TaskComplexityModerate, TaskComplexityComplex} {
+		data, _ := json.Marshal(RoutingResult{Assessment: TaskAssessment{Complexity: complexity}})
+		var decoded RoutingResult
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded.Assessment.Complexity != complexity {
+			t.Errorf("Expected complexity %s to round-trip, got %s, %v", complexity, decoded.Assessment.Complexity, err)
+		}
+	}
+
+	if _, err := json.Marshal(TaskRequest{QualityRequired: QualityRequirement(7)}); err == nil {
+		t.Error("Expected an out-of-range quality to be refused")
+	}
+	var decoded TaskRequest
+	if err := json.Unmarshal([]byte(`{"version":1,"quality_required":"ultra"}`), &decoded); err == nil {
+		t.Error("Expected an unknown quality name to be refused")
+	}
+}
+
+func TestWireFormat_ForwardsCompatibility(t *testing.T) {
+	payload := `{
+		"version": 1,
+		"prompt": "hello",
+		"quality_required": "standard",
+		"priority_lane": "fast",
+		"metadata": {"note": {"kind": "string", "value": "x", "checksum": "abc"}}
+	}`
+	var req TaskRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		t.Fatalf("Expected unknown fields to be ignored, got %v", err)
+	}
+	if req.Prompt != "hello" || req.QualityRequired != QualityStandard || req.Metadata["note"] != "x" {
+		t.Errorf("Expected known fields to decode, got %+v", req)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":2,"prompt":"hello"}`), &req); err == nil {
+		t.Error("Expected a newer format version to be refused")
+	}
+	var result RoutingResult
+	if err := json.Unmarshal([]byte(`{"version":1,"execution_result":{"text":"t","metadata":{"x":{"kind":"vector"}}}}`), &result); err == nil {
+		t.Error("Expected an unknown metadata kind to be refused")
+	}
+}
+
+func TestWireFormat_LegacyConversion(t *testing.T) {
+	// Written by plain json.Marshal before the wire format existed
+	legacyRequest := `{"Prompt":"hello","MaxTokens":100,"Temperature":0.5,"TaskType":"qa","QualityRequired":2,
+		"BudgetConstraint":0,"PreferredProvider":"","Metadata":{"attempt":3,"started":"2026-03-14T09:26:53Z","ratio":0.5}}`
+	var req TaskRequest
+	if err := json.Unmarshal([]byte(legacyRequest), &req); err != nil {
+		t.Fatalf("Failed to convert legacy request: %v", err)
+	}
+	started := time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+	if req.QualityRequired != QualityPremium || req.BudgetConstraint == nil || *req.BudgetConstraint != 0 ||
+		req.Metadata["attempt"] != 3 || req.Metadata["ratio"] != 0.5 || !req.Metadata["started"].(time.Time).Equal(started) {
+		t.Errorf("Expected the legacy request to be converted, got %+v", req)
+	}
+
+	legacyResult := `{"Assessment":{"Complexity":1,"EstimatedTokens":10,"QualityNeeded":0},
+		"SelectedModel":{"Provider":"openai","Model":"gpt-4"},
+		"ExecutionResult":{"text":"hi","tokens_used":5,"model":"gpt-4","provider":"openai","cost":0.01,"metadata":{"n":1}},
+		"ExecutionTime":"2026-03-14T09:26:53Z","UserRating":0}`
+	var result RoutingResult
+	if err := json.Unmarshal([]byte(legacyResult), &result); err != nil {
+		t.Fatalf("Failed to convert legacy result: %v", err)
+	}
+	if result.Assessment.Complexity != TaskComplexityModerate || result.SelectedModel.Model != "gpt-4" ||
+		result.ExecutionResult.Metadata["n"] != 1 {
+		t.Errorf("Expected the legacy result to be converted, got %+v", result)
+	}
+
+	// Converted records are written back in the current format
+	data, _ := json.Marshal(req)
+	if !strings.Contains(string(data), `"version":1`) || !strings.Contains(string(data), `"kind":"time"`) {
+		t.Errorf("Expected the converted request to re-encode in the wire format, got %s", data)
+	}
+}
+
+func TestWireFormat_Golden(t *testing.T) {
+	req, result := wireFixture()
+	got, err := json.MarshalIndent(map[string]interface{}{"request": req, "result": result}, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to encode fixture: %v", err)
+	}
+	got = append(got, '\n')
+
+	goldenPath := filepath.Join("testdata", "wire_v1.golden.json")
+	if *updateWireGolden {
+		if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Wire format does not match %s; if the change is intended, bump WireFormatVersion "+
+			"and run with -update-wire\n--- got ---\n%s", goldenPath, got)
+	}
+
+	// The frozen payload must keep decoding to the fixture
+	var golden struct {
+		Request TaskRequest   `json:"request"`
+		Result  RoutingResult `json:"result"`
+	}
+	if err := json.Unmarshal(want, &golden); err != nil {
+		t.Fatalf("Failed to decode golden file: %v", err)
+	}
+	if !reflect.DeepEqual(golden.Request, req) || !reflect.DeepEqual(golden.Result, result) {
+		t.Error("Expected the golden file to decode to the fixture")
+	}
+}
@@ -0,0 +1,431 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/jsonlog"
+)
+
+// AuditConfig configures the audit log of LLM requests.
+type AuditConfig struct {
+	// Path is the file entries are appended to; rotated files are kept
+	// beside it
+	Path string
+
+	// MaxSizeMB is the size in megabytes at which the file is rotated (0: 100)
+	MaxSizeMB int
+
+	// MaxBackups is how many rotated files are kept (0: all of them)
+	MaxBackups int
+
+	// MaxAgeDays removes rotated files older than this (0: kept regardless
+	// of age)
+	MaxAgeDays int
+
+	// Redact replaces prompts and responses with their SHA-256 hashes,
+	// keeping their lengths and the rest of each entry
+	Redact bool
+
+	// RedactComponents overrides Redact for requests from the named
+	// components, such as {"ethics": true}
+	RedactComponents map[string]bool
+}
+
+// AuditEntry is one LLM request as the audit log records it.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Operation string    `json:"operation"`
+
+	// Parameters are the request's parameters other than its prompt
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// Prompt and Response are empty when redacted; the lengths, in bytes,
+	// are always kept
+	Prompt        string `json:"prompt,omitempty"`
+	Response      string `json:"response,omitempty"`
+	PromptBytes   int    `json:"prompt_bytes"`
+	ResponseBytes int    `json:"response_bytes"`
+
+	// Redacted entries keep the SHA-256 hashes of the prompt and response,
+	// so identical requests can still be matched
+	Redacted     bool   `json:"redacted,omitempty"`
+	PromptHash   string `json:"prompt_hash,omitempty"`
+	ResponseHash string `json:"response_hash,omitempty"`
+
+	TokensUsed int     `json:"tokens_used"`
+	Cost       float64 `json:"cost"`
+	LatencyMs  int64   `json:"latency_ms"`
+
+	// ObjectiveID and Component say where the request came from, from its
+	// metadata or the log context of the caller
+	ObjectiveID string `json:"objective_id,omitempty"`
+	Component   string `json:"component,omitempty"`
+
+	// Error is set for requests that failed
+	Error string `json:"error,omitempty"`
+}
+
+// AuditFilter selects audit entries. Zero fields match every entry.
+type AuditFilter struct {
+	// From and To bound the entries' timestamps; To is exclusive
+	From time.Time
+	To   time.Time
+
+	Provider    string
+	ObjectiveID string
+
+	// Limit keeps only the most recent entries that match (0: all of them)
+	Limit int
+}
+
+// matches reports whether the entry is selected by the filter.
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	if !f.From.IsZero() && entry.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !entry.Timestamp.Before(f.To) {
+		return false
+	}
+	if f.Provider != "" && entry.Provider != f.Provider {
+		return false
+	}
+	return f.ObjectiveID == "" || entry.ObjectiveID == f.ObjectiveID
+}
+
+// AuditLog records LLM requests as JSON lines in a file rotated by size,
+// like the application log.
+type AuditLog struct {
+	config AuditConfig
+	writer *lumberjack.Logger
+	mu     sync.Mutex
+}
+
+// NewAuditLog opens the audit log at config.Path, creating its directory.
+func NewAuditLog(config AuditConfig) (*AuditLog, error) {
+	if config.Path == "" {
+		return nil, errs.New(errs.Validation, "audit log path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	components := make(map[string]bool, len(config.RedactComponents))
+	for component, redact := range config.RedactComponents {
+		components[component] = redact
+	}
+	config.RedactComponents = components
+
+	return &AuditLog{
+		config: config,
+		writer: &lumberjack.Logger{
+			Filename:   config.Path,
+			MaxSize:    config.MaxSizeMB,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAgeDays,
+			Compress:   true,
+		},
+	}, nil
+}
+
+// SetRedaction sets whether requests from the component are redacted; ""
+// sets the default for components without a setting of their own.
+func (a *AuditLog) SetRedaction(component string, redact bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if component == "" {
+		a.config.Redact = redact
+		return
+	}
+	a.config.RedactComponents[component] = redact
+}
+
+// redacts reports whether requests from the component are redacted.
+// Callers hold a.mu.
+func (a *AuditLog) redacts(component string) bool {
+	if redact, ok := a.config.RedactComponents[component]; ok {
+		return redact
+	}
+	return a.config.Redact
+}
+
+// Record appends the entry, redacting it if its component is redacted.
+func (a *AuditLog) Record(entry AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry.PromptBytes = len(entry.Prompt)
+	entry.ResponseBytes = len(entry.Response)
+	if a.redacts(entry.Component) {
+		entry.Redacted = true
+		entry.PromptHash = auditHash(entry.Prompt)
+		entry.ResponseHash = auditHash(entry.Response)
+		entry.Prompt, entry.Response = "", ""
+	}
+
+	if err := jsonlog.Append(a.writer, entry); err != nil {
+		return fmt.Errorf("failed to log audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditHash returns the hex SHA-256 hash of text, or "" for no text.
+func auditHash(text string) string {
+	if text == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Query returns the entries the filter selects, oldest first, from the
+// current file and the rotated ones still kept.
+func (a *AuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	files, err := a.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	for _, path := range files {
+		if err := readAuditFile(path, func(entry AuditEntry) {
+			if filter.matches(entry) {
+				entries = append(entries, entry)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Entries are stamped when their request started but written when it
+	// ended, so overlapping requests are written out of order
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+// files returns the log's rotated files, oldest first, then its current one.
+// Rotated files are named after the log with the time of rotation added,
+// which sorts them in order.
+func (a *AuditLog) files() ([]string, error) {
+	ext := filepath.Ext(a.config.Path)
+	prefix := strings.TrimSuffix(filepath.Base(a.config.Path), ext) + "-"
+
+	dirEntries, err := os.ReadDir(filepath.Dir(a.config.Path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit files: %w", err)
+	}
+	// A file being compressed is listed once, by its uncompressed name
+	rotated := make(map[string]bool)
+	for _, dirEntry := range dirEntries {
+		name := strings.TrimSuffix(dirEntry.Name(), ".gz")
+		if !dirEntry.IsDir() && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ext) {
+			rotated[filepath.Join(filepath.Dir(a.config.Path), name)] = true
+		}
+	}
+	files := make([]string, 0, len(rotated)+1)
+	for path := range rotated {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	if _, err := os.Stat(a.config.Path); err == nil {
+		files = append(files, a.config.Path)
+	}
+	return files, nil
+}
+
+// readAuditFile calls fn with each entry in the file, or in its compressed
+// copy once rotation compressed it. Lines that are not entries, such as one
+// cut short by a crash, are skipped.
+func readAuditFile(path string, fn func(AuditEntry)) error {
+	err := jsonlog.ReadFile(path, fn)
+	if errors.Is(err, os.ErrNotExist) {
+		err = jsonlog.ReadFile(path+".gz", fn)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		// Removed by rotation since it was listed
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read audit file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the current file.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.writer.Close()
+}
+
+// SetAuditLog records every completion and embedding, successful or not, in
+// log; nil stops auditing.
+func (llm *LLMService) SetAuditLog(log *AuditLog) {
+	llm.auditMu.Lock()
+	defer llm.auditMu.Unlock()
+	llm.auditLog = log
+}
+
+// auditLogger returns the audit log, or nil if requests are not audited.
+func (llm *LLMService) auditLogger() *AuditLog {
+	llm.auditMu.RLock()
+	defer llm.auditMu.RUnlock()
+	return llm.auditLog
+}
+
+// auditParamsExcluded are the parameters an audit entry records apart from
+// its Parameters, or not at all.
+var auditParamsExcluded = map[string]bool{
+	"operation": true,
+	"prompt":    true,
+	"messages":  true,
+	"text":      true,
+	"metadata":  true,
+}
+
+// audited runs an operation that calls a provider and records it in the
+// audit log, when there is one.
+func (llm *LLMService) audited(ctx context.Context, params ServiceParams, operation func(context.Context, ServiceParams) ServiceResult) ServiceResult {
+	auditLog := llm.auditLogger()
+	if auditLog == nil {
+		return operation(ctx, params)
+	}
+
+	started := time.Now()
+	result := operation(ctx, params)
+
+	entry := AuditEntry{
+		Timestamp:  started,
+		Operation:  params["operation"].(string),
+		Parameters: make(map[string]interface{}),
+		LatencyMs:  time.Since(started).Milliseconds(),
+	}
+	entry.Provider, _ = params["provider"].(string)
+	entry.Model, _ = params["model"].(string)
+	for key, value := range params {
+		if !auditParamsExcluded[key] {
+			entry.Parameters[key] = value
+		}
+	}
+
+	if entry.Operation == "embed" {
+		entry.Prompt, _ = params["text"].(string)
+	} else {
+		request := CompletionRequest{}
+		request.Prompt, _ = params["prompt"].(string)
+		request.Messages, _ = messagesParam(params)
+		entry.Prompt = request.PromptText()
+	}
+
+	metadata, _ := params["metadata"].(map[string]interface{})
+	logContext := utils.LogContextFrom(ctx)
+	entry.ObjectiveID, _ = metadata["objective_id"].(string)
+	if entry.ObjectiveID == "" {
+		entry.ObjectiveID = logContext.ObjectiveID
+	}
+	entry.Component, _ = metadata["component"].(string)
+	if entry.Component == "" {
+		entry.Component = logContext.Component
+	}
+
+	switch data := result.Data.(type) {
+	case *CompletionResponse:
+		entry.Provider, entry.Model = data.Provider, data.Model
+		entry.Response = data.Text
+		entry.TokensUsed, entry.Cost = data.TokensUsed, data.Cost
+	case *EmbeddingResponse:
+		entry.Provider, entry.Model = data.Provider, data.Model
+		entry.TokensUsed, entry.Cost = data.TokensUsed, data.Cost
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+		entry.TokensUsed, _ = result.Metadata["tokens_used"].(int)
+		entry.Cost, _ = result.Metadata["cost"].(float64)
+	}
+
+	if err := auditLog.Record(entry); err != nil {
+		llm.logger.Printf("Warning: %v", err)
+	}
+	return result
+}
+
+// getAudit returns the audit entries selected by the optional "from" and
+// "to" (RFC 3339 times), "provider", "objective_id" and "limit" parameters.
+func (llm *LLMService) getAudit(ctx context.Context, params ServiceParams) ServiceResult {
+	auditLog := llm.auditLogger()
+	if auditLog == nil {
+		return ErrorResult(errs.New(errs.NotFound, "audit logging is not enabled"))
+	}
+	filter, err := auditFilterParams(params)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	entries, err := auditLog.Query(filter)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	return SuccessResult(entries)
+}
+
+// auditFilterParams reads the filter of a get_audit request.
+func auditFilterParams(params ServiceParams) (AuditFilter, error) {
+	var filter AuditFilter
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value, exists := params[bound.name]
+		if !exists {
+			continue
+		}
+		switch value := value.(type) {
+		case time.Time:
+			*bound.target = value
+		case string:
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, NewValidationError(bound.name, bound.name+" must be an RFC 3339 time")
+			}
+			*bound.target = parsed
+		default:
+			return filter, NewValidationError(bound.name, bound.name+" must be an RFC 3339 time")
+		}
+	}
+	filter.Provider, _ = params["provider"].(string)
+	filter.ObjectiveID, _ = params["objective_id"].(string)
+	if limit, exists := params["limit"]; exists {
+		switch value := limit.(type) {
+		case int:
+			filter.Limit = value
+		case float64:
+			filter.Limit = int(value)
+		default:
+			return filter, NewValidationError("limit", "limit must be a number")
+		}
+	}
+	return filter, nil
+}
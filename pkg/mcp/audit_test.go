@@ -0,0 +1,182 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// echoProvider replies to every completion with its prompt reversed, at one
+// token per word.
+type echoProvider struct{}
+
+func (echoProvider) Name() string { return "Echo" }
+
+func (echoProvider) Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error) {
+	runes := []rune(request.PromptText())
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	tokens := len(strings.Fields(request.PromptText()))
+	return &CompletionResponse{Text: string(runes), TokensUsed: tokens, Model: request.Model, Provider: "echo", Cost: float64(tokens) * 0.001}, nil
+}
+
+func (echoProvider) Embed(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	return &EmbeddingResponse{Embedding: []float64{1}, TokensUsed: 1, Model: request.Model, Provider: "echo"}, nil
+}
+
+func (echoProvider) CalculateCost(model string, inputTokens, outputTokens int) float64 { return 0 }
+
+func (echoProvider) HealthCheck(ctx context.Context) error { return nil }
+
+func newAuditedService(t *testing.T, config AuditConfig) (*LLMService, *AuditLog) {
+	t.Helper()
+	if config.Path == "" {
+		config.Path = filepath.Join(t.TempDir(), "llm-audit.jsonl")
+	}
+	auditLog, err := NewAuditLog(config)
+	if err != nil {
+		t.Fatalf("NewAuditLog failed: %v", err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+
+	service := NewLLMServiceWithProviders(log.New(io.Discard, "", 0), map[string]LLMProvider{"echo": echoProvider{}})
+	service.SetAuditLog(auditLog)
+	return service, auditLog
+}
+
+func TestAuditLog_RecordsRequests(t *testing.T) {
+	service, _ := newAuditedService(t, AuditConfig{})
+	ctx := context.Background()
+	started := time.Now()
+
+	result := service.Execute(ctx, ServiceParams{
+		"operation": "complete", "prompt": "plan the launch", "provider": "echo", "model": "echo-1",
+		"max_tokens": 100, "metadata": map[string]interface{}{"objective_id": "objective_1"},
+	})
+	if !result.Success {
+		t.Fatalf("complete failed: %v", result.Error)
+	}
+	ethicsCtx := utils.WithLogContext(ctx, utils.LogContext{ObjectiveID: "objective_2", Component: "ethics"})
+	service.Execute(ethicsCtx, ServiceParams{"operation": "complete", "prompt": "is this fair", "provider": "echo"})
+	service.Execute(ctx, ServiceParams{"operation": "embed", "text": "launch notes", "provider": "echo"})
+
+	result = service.Execute(ctx, ServiceParams{"operation": "get_audit"})
+	if !result.Success {
+		t.Fatalf("get_audit failed: %v", result.Error)
+	}
+	entries := result.Data.([]AuditEntry)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %d", len(entries))
+	}
+
+	first := entries[0]
+	if first.Prompt != "plan the launch" || first.Response != "hcnual eht nalp" || first.Provider != "echo" || first.Model != "echo-1" {
+		t.Errorf("Expected the full prompt and response, got %+v", first)
+	}
+	if first.Operation != "complete" || first.ObjectiveID != "objective_1" || first.TokensUsed != 3 || first.Cost != 0.003 {
+		t.Errorf("Expected the request's usage and objective, got %+v", first)
+	}
+	if first.Parameters["max_tokens"] != 100.0 || first.Parameters["prompt"] != nil || first.Timestamp.Before(started) {
+		t.Errorf("Expected the parameters without the prompt, got %v", first.Parameters)
+	}
+	if entries[1].ObjectiveID != "objective_2" || entries[1].Component != "ethics" {
+		t.Errorf("Expected the caller's log context, got %+v", entries[1])
+	}
+	if entries[2].Operation != "embed" || entries[2].Prompt != "launch notes" {
+		t.Errorf("Expected the embedding's text, got %+v", entries[2])
+	}
+
+	// Filters
+	result = service.Execute(ctx, ServiceParams{"operation": "get_audit", "objective_id": "objective_2"})
+	if entries := result.Data.([]AuditEntry); len(entries) != 1 || entries[0].Prompt != "is this fair" {
+		t.Errorf("Expected the objective's one entry, got %v", result.Data)
+	}
+	result = service.Execute(ctx, ServiceParams{"operation": "get_audit", "from": time.Now().Add(time.Hour).Format(time.RFC3339)})
+	if entries := result.Data.([]AuditEntry); len(entries) != 0 {
+		t.Errorf("Expected no entries from the future, got %d", len(entries))
+	}
+	result = service.Execute(ctx, ServiceParams{"operation": "get_audit", "provider": "echo", "limit": 1})
+	if entries := result.Data.([]AuditEntry); len(entries) != 1 || entries[0].Operation != "embed" {
+		t.Errorf("Expected only the latest entry, got %v", result.Data)
+	}
+	if err := service.ValidateParams(ServiceParams{"operation": "get_audit", "to": "yesterday"}); err == nil {
+		t.Error("Expected an invalid time to fail validation")
+	}
+}
+
+func TestAuditLog_RedactionByComponent(t *testing.T) {
+	service, auditLog := newAuditedService(t, AuditConfig{RedactComponents: map[string]bool{"ethics": true}})
+	ctx := context.Background()
+
+	complete := func(component string) {
+		params := ServiceParams{"operation": "complete", "prompt": "private notes", "provider": "echo"}
+		if component != "" {
+			params["metadata"] = map[string]interface{}{"component": component}
+		}
+		if result := service.Execute(ctx, params); !result.Success {
+			t.Fatalf("complete failed: %v", result.Error)
+		}
+	}
+	complete("ethics")
+	complete("planner")
+	auditLog.SetRedaction("", true)
+	auditLog.SetRedaction("planner", false)
+	complete("")
+	complete("planner")
+
+	entries, err := auditLog.Query(AuditFilter{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	redacted := []bool{true, false, true, false}
+	for i, entry := range entries {
+		if entry.Redacted != redacted[i] {
+			t.Errorf("Entry %d from %q: redacted %v, want %v", i, entry.Component, entry.Redacted, redacted[i])
+		}
+		if entry.PromptBytes != len("private notes") || entry.ResponseBytes != len("private notes") {
+			t.Errorf("Entry %d: expected the lengths kept, got %d and %d", i, entry.PromptBytes, entry.ResponseBytes)
+		}
+		if entry.Redacted && (entry.Prompt != "" || entry.Response != "" || entry.PromptHash != auditHash("private notes")) {
+			t.Errorf("Entry %d: expected only hashes, got %+v", i, entry)
+		}
+	}
+}
+
+func TestAuditLog_QueriesRotatedFiles(t *testing.T) {
+	service, auditLog := newAuditedService(t, AuditConfig{MaxSizeMB: 1})
+	ctx := context.Background()
+
+	// Four 400 KB prompts fill more than one file
+	prompt := strings.Repeat("x", 400*1024)
+	for i := 0; i < 4; i++ {
+		if result := service.Execute(ctx, ServiceParams{"operation": "complete", "prompt": prompt, "provider": "echo"}); !result.Success {
+			t.Fatalf("complete failed: %v", result.Error)
+		}
+	}
+
+	files, err := auditLog.files()
+	if err != nil || len(files) < 2 {
+		t.Fatalf("Expected the log to rotate, got files %v, %v", files, err)
+	}
+	entries, err := auditLog.Query(AuditFilter{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("Expected all 4 entries across the rotated files, got %d", len(entries))
+	}
+}
+
+func TestAuditLog_NotEnabled(t *testing.T) {
+	service := NewLLMServiceWithProviders(log.New(io.Discard, "", 0), map[string]LLMProvider{"echo": echoProvider{}})
+	if result := service.Execute(context.Background(), ServiceParams{"operation": "get_audit"}); result.Success {
+		t.Error("Expected get_audit to fail without an audit log")
+	}
+}
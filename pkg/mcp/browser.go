@@ -10,6 +10,8 @@ import (
 
 	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/chromedp"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
 )
 
 // BrowserService provides web browser automation capabilities through MCP.
@@ -153,6 +155,11 @@ func (bs *BrowserService) navigate(ctx context.Context, url string) ServiceResul
 		url = "https://" + url
 	}
 
+	// Only the page itself is checked; what it loads in turn is up to the browser
+	if err := netaudit.Default().CheckURL("browser", url); err != nil {
+		return ErrorResult(err)
+	}
+
 	var currentURL string
 	err := chromedp.Run(ctx,
 		chromedp.Navigate(url),
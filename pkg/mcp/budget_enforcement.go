@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// BudgetEnforcement is how spending past a budget limit is treated.
+type BudgetEnforcement string
+
+const (
+	// BudgetEnforcementHard refuses requests once a limit is reached
+	BudgetEnforcementHard BudgetEnforcement = "hard"
+
+	// BudgetEnforcementSoftWithGrace lets the requests of an execution plan
+	// already running spend past a limit, up to limit × (1 + GracePercent/100),
+	// so that an almost finished plan is not cut short. Other requests are
+	// refused at the limit.
+	BudgetEnforcementSoftWithGrace BudgetEnforcement = "soft"
+
+	// BudgetEnforcementWarnOnly never refuses requests; spending past a limit
+	// is only warned about
+	BudgetEnforcementWarnOnly BudgetEnforcement = "warn"
+)
+
+// DefaultGracePercent is how far past a limit, as a percentage of it,
+// BudgetEnforcementSoftWithGrace lets running plans spend by default.
+const DefaultGracePercent = 10.0
+
+// BudgetEnforcements are the enforcement modes, strictest first.
+var BudgetEnforcements = []BudgetEnforcement{
+	BudgetEnforcementHard,
+	BudgetEnforcementSoftWithGrace,
+	BudgetEnforcementWarnOnly,
+}
+
+// ParseBudgetEnforcement returns the enforcement mode with the given name;
+// "" is BudgetEnforcementHard.
+func ParseBudgetEnforcement(name string) (BudgetEnforcement, error) {
+	if name == "" {
+		return BudgetEnforcementHard, nil
+	}
+	for _, enforcement := range BudgetEnforcements {
+		if string(enforcement) == name {
+			return enforcement, nil
+		}
+	}
+	return "", errs.Newf(errs.Validation, "unknown budget enforcement %q, must be one of: %v", name, BudgetEnforcements).
+		With("enforcement", name)
+}
+
+// SetBudgetEnforcement sets how spending past the daily limit is treated,
+// and for BudgetEnforcementSoftWithGrace how far past it, as a percentage of
+// the limit, running plans may spend (0 or less: DefaultGracePercent).
+// Requests belong to a running plan when their metadata has a "plan_id".
+func (llm *LLMService) SetBudgetEnforcement(enforcement BudgetEnforcement, gracePercent float64) {
+	if gracePercent <= 0 {
+		gracePercent = DefaultGracePercent
+	}
+	llm.budgetMu.Lock()
+	defer llm.budgetMu.Unlock()
+	llm.budgetTracker.Enforcement = enforcement
+	llm.budgetTracker.GracePercent = gracePercent
+}
+
+// requestPlanID returns the execution plan a request's metadata says it is
+// part of, or "".
+func requestPlanID(params ServiceParams) string {
+	metadata, _ := params["metadata"].(map[string]interface{})
+	planID, _ := metadata["plan_id"].(string)
+	return planID
+}
@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 )
 
 // CommandService provides command execution capabilities as an MCP service.
@@ -135,22 +137,8 @@ func (cs *CommandService) validateOptionalParams(params ServiceParams) error {
 	}
 
 	// Validate args if provided
-	if args, exists := params["args"]; exists {
-		if args != nil {
-			switch v := args.(type) {
-			case []interface{}:
-				// Convert []interface{} to []string and validate
-				for i, arg := range v {
-					if _, ok := arg.(string); !ok {
-						return NewValidationError("args", fmt.Sprintf("argument at index %d must be a string", i))
-					}
-				}
-			case []string:
-				// Already valid
-			default:
-				return NewValidationError("args", "args must be an array of strings")
-			}
-		}
+	if err := validateStringListParam(params, "args"); err != nil {
+		return err
 	}
 
 	// Validate environment variables if provided
@@ -189,7 +177,8 @@ func (cs *CommandService) validateSecurity(params ServiceParams) error {
 	// Check if command is dangerous first (higher priority)
 	for _, dangerous := range cs.dangerousCommands {
 		if command == dangerous {
-			return NewValidationError("command", fmt.Sprintf("command '%s' is potentially dangerous and requires explicit approval", command))
+			return errs.Wrap(NewValidationError("command", fmt.Sprintf("command '%s' is potentially dangerous and requires explicit approval", command)),
+				errs.ApprovalRequired, map[string]interface{}{"command": command})
 		}
 	}
 
@@ -231,13 +220,7 @@ func (cs *CommandService) validateWorkingDirectory(workingDir string) error {
 
 	// Check if directory is within any allowed directory
 	for _, allowedDir := range cs.allowedDirs {
-		relPath, err := filepath.Rel(allowedDir, cleanDir)
-		if err != nil {
-			continue
-		}
-
-		// Directory is valid if it doesn't contain ".." (trying to escape)
-		if !strings.Contains(relPath, "..") {
+		if withinDir(allowedDir, cleanDir) {
 			return nil
 		}
 	}
@@ -251,18 +234,7 @@ func (cs *CommandService) Execute(ctx context.Context, params ServiceParams) Ser
 	commandStr := params["command"].(string)
 
 	// Extract and convert args
-	var args []string
-	if argsParam, exists := params["args"]; exists && argsParam != nil {
-		switch v := argsParam.(type) {
-		case []interface{}:
-			args = make([]string, len(v))
-			for i, arg := range v {
-				args[i] = arg.(string)
-			}
-		case []string:
-			args = v
-		}
-	}
+	args := stringListParam(params, "args")
 
 	// Extract working directory
 	workingDir := ""
@@ -364,4 +336,37 @@ func (cs *CommandService) executeCommand(ctx context.Context, command string, ar
 	}
 
 	return SuccessResult(result)
-}
\ No newline at end of file
+}
+
+// validateStringListParam validates that an optional parameter is an array
+// of strings.
+func validateStringListParam(params ServiceParams, name string) error {
+	switch v := params[name].(type) {
+	case nil, []string:
+		return nil
+	case []interface{}:
+		for i, item := range v {
+			if _, ok := item.(string); !ok {
+				return NewValidationError(name, fmt.Sprintf("argument at index %d must be a string", i))
+			}
+		}
+		return nil
+	default:
+		return NewValidationError(name, fmt.Sprintf("%s must be an array of strings", name))
+	}
+}
+
+// stringListParam returns a parameter validated by validateStringListParam.
+func stringListParam(params ServiceParams, name string) []string {
+	switch v := params[name].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			list[i] = item.(string)
+		}
+		return list
+	}
+	return nil
+}
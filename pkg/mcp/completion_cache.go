@@ -0,0 +1,269 @@
+package mcp
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+const (
+	// DefaultCacheEntries is how many completions a cache keeps by default
+	DefaultCacheEntries = 500
+
+	// DefaultCacheMaxTemperature is the highest temperature whose
+	// completions are cached by default
+	DefaultCacheMaxTemperature = 0.5
+)
+
+// CompletionCacheConfig configures the cache of completions.
+type CompletionCacheConfig struct {
+	// Path is the file the cache is kept in across restarts ("": memory only)
+	Path string
+
+	// MaxEntries is how many completions are kept; the least recently used
+	// is evicted first (0: DefaultCacheEntries)
+	MaxEntries int
+
+	// MaxTemperature is the highest temperature whose completions are
+	// cached, since higher ones are asked for to vary
+	// (0: DefaultCacheMaxTemperature)
+	MaxTemperature float64
+}
+
+// CacheStats reports how well a completion cache is doing.
+type CacheStats struct {
+	Entries int `json:"entries"`
+	Hits    int `json:"hits"`
+	Misses  int `json:"misses"`
+
+	// HitRate is the share of cacheable requests served from the cache
+	HitRate float64 `json:"hit_rate"`
+
+	// TokensSaved and CostSaved are what the hits would have cost
+	TokensSaved int     `json:"tokens_saved"`
+	CostSaved   float64 `json:"cost_saved"`
+}
+
+// cacheEntry is one cached completion.
+type cacheEntry struct {
+	Key      string             `json:"key"`
+	Response CompletionResponse `json:"response"`
+	StoredAt time.Time          `json:"stored_at"`
+}
+
+// cacheFile is the cache as persisted: its entries, least recently used
+// first, and its counters.
+type cacheFile struct {
+	Entries []cacheEntry `json:"entries"`
+	Stats   CacheStats   `json:"stats"`
+}
+
+// CompletionCache serves identical completion requests without calling the
+// provider again. Requests are identical when they go to the same provider
+// and model with the same messages, temperature, token limit, stop words and
+// response schema. The cache is bounded, evicting the least recently used
+// completion, and written to its file on every change.
+type CompletionCache struct {
+	config CompletionCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Of *cacheEntry, by key
+	order   *list.List               // Most recently used at the front
+	stats   CacheStats
+}
+
+// NewCompletionCache creates a completion cache, loading what its file holds.
+func NewCompletionCache(config CompletionCacheConfig) (*CompletionCache, error) {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultCacheEntries
+	}
+	if config.MaxTemperature <= 0 {
+		config.MaxTemperature = DefaultCacheMaxTemperature
+	}
+	cache := &CompletionCache{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	if config.Path == "" {
+		return cache, nil
+	}
+
+	data, err := os.ReadFile(config.Path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read completion cache: %w", err)
+	}
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse completion cache %s: %w", config.Path, err)
+	}
+	cache.stats = file.Stats
+	for i := range file.Entries {
+		cache.entries[file.Entries[i].Key] = cache.order.PushFront(&file.Entries[i])
+	}
+	cache.evict()
+	return cache, nil
+}
+
+// Cacheable reports whether completions at the temperature are cached.
+func (c *CompletionCache) Cacheable(temperature float64) bool {
+	return temperature <= c.config.MaxTemperature
+}
+
+// Get returns the cached completion for the key, counting a hit or a miss.
+func (c *CompletionCache) Get(key string) (*CompletionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*cacheEntry)
+	c.stats.Hits++
+	c.stats.TokensSaved += entry.Response.TokensUsed
+	c.stats.CostSaved += entry.Response.Cost
+	c.save()
+	response := entry.Response
+	return &response, true
+}
+
+// Put caches a completion under the key.
+func (c *CompletionCache) Put(key string, response *CompletionResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &cacheEntry{Key: key, Response: *response, StoredAt: time.Now()}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[key] = c.order.PushFront(entry)
+		c.evict()
+	}
+	c.save()
+}
+
+// Stats returns the cache's counters.
+func (c *CompletionCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// evict removes the least recently used completions over the limit. Callers
+// hold mu.
+func (c *CompletionCache) evict() {
+	for c.order.Len() > c.config.MaxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).Key)
+	}
+}
+
+// save writes the cache to its file, replacing it whole. A cache that cannot
+// be written keeps working from memory. Callers hold mu.
+func (c *CompletionCache) save() {
+	if c.config.Path == "" {
+		return
+	}
+	file := cacheFile{Stats: c.stats}
+	for element := c.order.Back(); element != nil; element = element.Prev() {
+		file.Entries = append(file.Entries, *element.Value.(*cacheEntry))
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.config.Path), 0755); err != nil {
+		return
+	}
+	tmp := c.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, c.config.Path); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// completionCacheKey identifies a completion request to a provider.
+func completionCacheKey(provider string, request CompletionRequest) string {
+	data, _ := json.Marshal(struct {
+		Provider       string          `json:"provider"`
+		Model          string          `json:"model"`
+		Messages       []Message       `json:"messages"`
+		Temperature    float64         `json:"temperature"`
+		MaxTokens      int             `json:"max_tokens"`
+		StopWords      []string        `json:"stop_words,omitempty"`
+		ResponseSchema *ResponseSchema `json:"response_schema,omitempty"`
+	}{provider, request.Model, request.Conversation(), request.Temperature, request.MaxTokens, request.StopWords, request.ResponseSchema})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SetCompletionCache serves completions from cache when a request matches
+// one it holds; nil stops caching. Requests above the cache's temperature,
+// and those with the "no_cache" parameter set, always go to the provider.
+func (llm *LLMService) SetCompletionCache(cache *CompletionCache) {
+	llm.cacheMu.Lock()
+	defer llm.cacheMu.Unlock()
+	llm.completionCache = cache
+}
+
+// cacheFor returns the cache that may serve a completion request and the
+// request's key in it, or nil when the request is not to be cached.
+func (llm *LLMService) cacheFor(params ServiceParams, provider string, request CompletionRequest) (*CompletionCache, string) {
+	llm.cacheMu.RLock()
+	cache := llm.completionCache
+	llm.cacheMu.RUnlock()
+	if noCache, _ := params["no_cache"].(bool); cache == nil || noCache || !cache.Cacheable(request.Temperature) {
+		return nil, ""
+	}
+	return cache, completionCacheKey(provider, request)
+}
+
+// cachedCompletion returns a cached completion as served: free, with what it
+// cost when it was made in its metadata.
+func cachedCompletion(cached *CompletionResponse) *CompletionResponse {
+	metadata := make(map[string]interface{}, len(cached.Metadata)+3)
+	for key, value := range cached.Metadata {
+		metadata[key] = value
+	}
+	metadata["cache_hit"] = true
+	metadata["cached_tokens"] = cached.TokensUsed
+	metadata["saved_cost"] = cached.Cost
+
+	served := *cached
+	served.TokensUsed, served.InputTokens, served.OutputTokens, served.Cost = 0, 0, 0, 0
+	served.Metadata = metadata
+	return &served
+}
+
+// cacheStats returns the completion cache's hit rate and savings.
+func (llm *LLMService) cacheStats(ctx context.Context, params ServiceParams) ServiceResult {
+	llm.cacheMu.RLock()
+	cache := llm.completionCache
+	llm.cacheMu.RUnlock()
+	if cache == nil {
+		return ErrorResult(errs.New(errs.NotFound, "completion caching is not enabled"))
+	}
+	return SuccessResult(cache.Stats())
+}
@@ -0,0 +1,124 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+)
+
+// countingProvider is an echoProvider that counts its completions.
+type countingProvider struct {
+	echoProvider
+	calls int
+}
+
+func (p *countingProvider) Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error) {
+	p.calls++
+	return p.echoProvider.Complete(ctx, request)
+}
+
+func newCachedService(t *testing.T, config CompletionCacheConfig) (*LLMService, *countingProvider, *CompletionCache) {
+	t.Helper()
+	cache, err := NewCompletionCache(config)
+	if err != nil {
+		t.Fatalf("NewCompletionCache failed: %v", err)
+	}
+	provider := &countingProvider{}
+	service := NewLLMServiceWithProviders(log.New(io.Discard, "", 0), map[string]LLMProvider{"echo": provider})
+	service.SetCompletionCache(cache)
+	return service, provider, cache
+}
+
+func completeParams(prompt string) ServiceParams {
+	return ServiceParams{"operation": "complete", "prompt": prompt, "provider": "echo", "model": "echo-1", "max_tokens": 100}
+}
+
+func TestCompletionCache_ServesRepeatedRequests(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache", "completions.json")
+	service, provider, cache := newCachedService(t, CompletionCacheConfig{Path: path})
+
+	first := service.Execute(ctx, completeParams("analyze the method steps"))
+	second := service.Execute(ctx, completeParams("analyze the method steps"))
+	if !first.Success || !second.Success {
+		t.Fatalf("complete failed: %v, %v", first.Error, second.Error)
+	}
+	if provider.calls != 1 {
+		t.Fatalf("Expected one provider call, got %d", provider.calls)
+	}
+
+	// The hit is free, but says what it saved
+	original, served := first.Data.(*CompletionResponse), second.Data.(*CompletionResponse)
+	if served.Text != original.Text || served.Cost != 0 || served.TokensUsed != 0 {
+		t.Errorf("Expected the cached text at no cost, got %+v", served)
+	}
+	if served.Metadata["cache_hit"] != true || served.Metadata["saved_cost"] != original.Cost {
+		t.Errorf("Expected the hit's savings in its metadata, got %v", served.Metadata)
+	}
+	budget := service.Execute(ctx, ServiceParams{"operation": "get_budget"}).Data.(*BudgetTracker)
+	if hits := budget.ByOperation["cache_hit"]; hits.Calls != 1 || hits.Cost != 0 {
+		t.Errorf("Expected a zero-cost cache hit recorded, got %+v", hits)
+	}
+
+	// Different parameters, a high temperature and no_cache all reach the provider
+	differentTokens := completeParams("analyze the method steps")
+	differentTokens["max_tokens"] = 200
+	varied := completeParams("analyze the method steps")
+	varied["temperature"] = 0.9
+	bypass := completeParams("analyze the method steps")
+	bypass["no_cache"] = true
+	for _, params := range []ServiceParams{differentTokens, varied, varied, bypass} {
+		if result := service.Execute(ctx, params); !result.Success {
+			t.Fatalf("complete failed: %v", result.Error)
+		}
+	}
+	if provider.calls != 5 {
+		t.Errorf("Expected every uncached request to reach the provider, got %d calls", provider.calls)
+	}
+
+	result := service.Execute(ctx, ServiceParams{"operation": "cache_stats"})
+	if !result.Success {
+		t.Fatalf("cache_stats failed: %v", result.Error)
+	}
+	stats := result.Data.(CacheStats)
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 2 || stats.CostSaved != original.Cost {
+		t.Errorf("Expected 1 hit, 2 misses and 2 entries, got %+v", stats)
+	}
+
+	// A restart keeps the cache and its counters
+	reopened, err := NewCompletionCache(CompletionCacheConfig{Path: path})
+	if err != nil {
+		t.Fatalf("Failed to reopen the cache: %v", err)
+	}
+	if reopened.Stats() != cache.Stats() {
+		t.Errorf("Expected %+v after a restart, got %+v", cache.Stats(), reopened.Stats())
+	}
+}
+
+func TestCompletionCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	service, provider, cache := newCachedService(t, CompletionCacheConfig{MaxEntries: 2})
+
+	for _, prompt := range []string{"first", "second", "first", "third", "first", "second"} {
+		if result := service.Execute(ctx, completeParams(prompt)); !result.Success {
+			t.Fatalf("complete failed: %v", result.Error)
+		}
+	}
+
+	// "second" was the least recently used when "third" came in
+	if provider.calls != 4 {
+		t.Errorf("Expected the evicted completion requested again, got %d calls", provider.calls)
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Hits != 2 {
+		t.Errorf("Expected 2 entries and 2 hits, got %+v", stats)
+	}
+}
+
+func TestCompletionCache_StatsWithoutCache(t *testing.T) {
+	service := NewLLMServiceWithProviders(log.New(io.Discard, "", 0), map[string]LLMProvider{"echo": echoProvider{}})
+	if result := service.Execute(context.Background(), ServiceParams{"operation": "cache_stats"}); result.Success {
+		t.Error("Expected cache_stats to fail without a cache")
+	}
+}
@@ -0,0 +1,139 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// KeyringService is the service name API keys are stored under in the OS
+// keyring, with the provider name as the account.
+const KeyringService = "ai-work-studio"
+
+// CredentialSourceKeyring is the Source of a provider whose key came from
+// the OS keyring.
+const CredentialSourceKeyring = "keyring"
+
+// ErrKeyringNotFound is matched by errors.Is when the keyring has no key for
+// a provider.
+var ErrKeyringNotFound = errs.New(errs.NotFound, "no API key in the OS keyring")
+
+// ErrKeyringUnavailable is matched by errors.Is when there is no OS keyring
+// to use: the platform has none the service supports, or its tool is not
+// installed.
+var ErrKeyringUnavailable = errs.New(errs.ProviderUnavailable, "no OS keyring is available")
+
+// ErrKeyringFailed is matched by errors.Is when the OS keyring is there but
+// refused or failed a request, such as when it is locked or access is denied.
+var ErrKeyringFailed = errs.New(errs.Internal, "the OS keyring failed")
+
+// Keyring stores secrets by service and user, as the OS keyring does.
+type Keyring interface {
+	// Get returns the secret, or an error matching ErrKeyringNotFound
+	Get(service, user string) (string, error)
+
+	// Set stores the secret, replacing any stored before
+	Set(service, user, secret string) error
+
+	// Delete removes the secret, or fails with ErrKeyringNotFound
+	Delete(service, user string) error
+}
+
+// SystemKeyring returns the OS keyring: the login keychain on macOS,
+// through the security tool, the Credential Manager on Windows, and the
+// Secret Service (GNOME Keyring, KWallet) elsewhere, through secret-tool.
+// Where none is available every call fails with ErrKeyringUnavailable.
+func SystemKeyring() Keyring {
+	return systemKeyring{}
+}
+
+// Credential is a provider's API key and where it was found.
+type Credential struct {
+	// Key is the API key; empty when none was found
+	Key string
+
+	// Source is where the key was found: CredentialSourceKeyring, a
+	// configuration entry such as "credentials.anthropic", or an
+	// environment variable
+	Source string
+
+	// Checked are the places looked at, in order
+	Checked []string
+
+	// Warning is a problem met looking, such as a keyring that could not
+	// be read, that did not stop a key being found elsewhere
+	Warning string
+}
+
+// CredentialsProvider resolves the API keys of the providers that use one.
+// Resolve is called again whenever the service reloads its credentials.
+type CredentialsProvider interface {
+	Resolve(provider string) Credential
+}
+
+// Credentials resolves API keys from the OS keyring, then the configuration
+// file, then the environment, taking the first key found.
+type Credentials struct {
+	keyring Keyring
+	config  map[string]string
+}
+
+// NewCredentials creates a credentials provider over keyring and the keys
+// of the configuration file's credentials section, by provider name. Either
+// may be nil to skip it.
+func NewCredentials(keyring Keyring, configKeys map[string]string) *Credentials {
+	return &Credentials{keyring: keyring, config: configKeys}
+}
+
+// Resolve returns the provider's key from the first place that has one.
+func (c *Credentials) Resolve(provider string) Credential {
+	var credential Credential
+	if c.keyring != nil {
+		credential.Checked = append(credential.Checked, CredentialSourceKeyring)
+		key, err := c.keyring.Get(KeyringService, provider)
+		switch {
+		case err == nil && key != "":
+			credential.Key, credential.Source = key, CredentialSourceKeyring
+			return credential
+		case err != nil && !errors.Is(err, ErrKeyringNotFound) && !errors.Is(err, ErrKeyringUnavailable):
+			credential.Warning = fmt.Sprintf("could not read the keyring: %v", err)
+		}
+	}
+
+	if c.config != nil {
+		entry := ConfigCredentialEntry(provider)
+		credential.Checked = append(credential.Checked, entry)
+		if key := strings.TrimSpace(c.config[provider]); key != "" {
+			credential.Key, credential.Source = key, entry
+			return credential
+		}
+	}
+
+	variable := strings.ToUpper(provider) + "_API_KEY"
+	credential.Checked = append(credential.Checked, variable)
+	if key := os.Getenv(variable); key != "" {
+		credential.Key, credential.Source = key, variable
+	}
+	return credential
+}
+
+// ConfigCredentialEntry names the configuration file entry holding a
+// provider's key.
+func ConfigCredentialEntry(provider string) string {
+	return "credentials." + provider
+}
+
+// staticCredentials gives one provider a fixed key, for SetAPIKey.
+type staticCredentials struct {
+	provider, key string
+}
+
+func (s staticCredentials) Resolve(provider string) Credential {
+	if provider != s.provider {
+		return Credential{}
+	}
+	return Credential{Key: s.key, Source: ConfigCredentialEntry(provider), Checked: []string{ConfigCredentialEntry(provider)}}
+}
@@ -0,0 +1,156 @@
+//go:build !windows
+
+package mcp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// systemKeyring is the OS keyring through its command-line tool: security on
+// macOS and secret-tool elsewhere.
+type systemKeyring struct{}
+
+func (systemKeyring) Get(service, user string) (string, error) {
+	tool, err := newKeyringTool(
+		[]string{"find-generic-password", "-s", service, "-a", user, "-w"},
+		[]string{"lookup", "service", service, "username", user})
+	if err != nil {
+		return "", err
+	}
+	out, err := tool.run("")
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimRight(out, "\r\n")
+	if secret == "" {
+		return "", ErrKeyringNotFound
+	}
+	return secret, nil
+}
+
+func (systemKeyring) Set(service, user, secret string) error {
+	// Commands read from standard input keep the secret off the command line
+	tool, err := newKeyringTool(
+		[]string{"-i"},
+		[]string{"store", "--label", "AI Work Studio " + user + " API key", "service", service, "username", user})
+	if err != nil {
+		return err
+	}
+	input := secret
+	if runtime.GOOS == "darwin" {
+		input = fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", shellQuote(service), shellQuote(user), shellQuote(secret))
+	}
+	_, err = tool.run(input)
+	return err
+}
+
+func (k systemKeyring) Delete(service, user string) error {
+	// secret-tool clears a secret that is not there without complaint
+	if runtime.GOOS != "darwin" {
+		if _, err := k.Get(service, user); err != nil {
+			return err
+		}
+	}
+	tool, err := newKeyringTool(
+		[]string{"delete-generic-password", "-s", service, "-a", user},
+		[]string{"clear", "service", service, "username", user})
+	if err != nil {
+		return err
+	}
+	_, err = tool.run("")
+	return err
+}
+
+// keyringTool is a keyring's command-line tool, run once.
+type keyringTool struct {
+	cmd *exec.Cmd
+
+	// missing reports whether the tool exited with status, having printed
+	// stderr, because it has no such secret rather than because it failed
+	missing func(status int, stderr string) bool
+}
+
+// securityItemNotFound is the status the security tool exits with when the
+// keychain has no such item (errSecItemNotFound).
+const securityItemNotFound = 44
+
+// securityMissing reports a missing secret by the security tool's status.
+func securityMissing(status int, stderr string) bool {
+	return status == securityItemNotFound
+}
+
+// secretToolMissing reports a missing secret as secret-tool does: status 1
+// without a message. Secret Service errors exit 1 with one.
+func secretToolMissing(status int, stderr string) bool {
+	return status == 1 && strings.TrimSpace(stderr) == ""
+}
+
+// newKeyringTool returns the security tool with securityArgs on macOS, or
+// secret-tool with secretToolArgs elsewhere. Without a D-Bus session to
+// reach the Secret Service over there is no keyring.
+func newKeyringTool(securityArgs, secretToolArgs []string) (*keyringTool, error) {
+	if runtime.GOOS == "darwin" {
+		return &keyringTool{cmd: exec.Command("/usr/bin/security", securityArgs...), missing: securityMissing}, nil
+	}
+	if !sessionBus() {
+		return nil, fmt.Errorf("%w: there is no D-Bus session for the Secret Service", ErrKeyringUnavailable)
+	}
+	return &keyringTool{cmd: exec.Command("secret-tool", secretToolArgs...), missing: secretToolMissing}, nil
+}
+
+// sessionBus reports whether there is a D-Bus session bus: named by the
+// environment, at the runtime directory's socket, or started for the X
+// display.
+func sessionBus() bool {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" || os.Getenv("DISPLAY") != "" {
+		return true
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(runtimeDir, "bus"))
+	return err == nil
+}
+
+// run runs the tool with input and returns what it printed. A tool that is
+// not installed means there is no keyring. One that exits with an error
+// fails with ErrKeyringNotFound if it has no such secret, and otherwise with
+// ErrKeyringFailed and its message, unless it was given input, which may be
+// the secret it repeats.
+func (tool *keyringTool) run(input string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	tool.cmd.Stdin = strings.NewReader(input)
+	tool.cmd.Stdout = &stdout
+	tool.cmd.Stderr = &stderr
+	err := tool.cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return stdout.String(), nil
+	case errors.As(err, &exitErr):
+		status := exitErr.ExitCode()
+		if tool.missing(status, stderr.String()) {
+			return "", ErrKeyringNotFound
+		}
+		name := filepath.Base(tool.cmd.Path)
+		if message := strings.TrimSpace(stderr.String()); message != "" && input == "" {
+			return "", fmt.Errorf("%w: %s exited with status %d: %s", ErrKeyringFailed, name, status, message)
+		}
+		return "", fmt.Errorf("%w: %s exited with status %d", ErrKeyringFailed, name, status)
+	default:
+		return "", fmt.Errorf("%w: %v", ErrKeyringUnavailable, err)
+	}
+}
+
+// shellQuote quotes a word for the security tool's interactive mode.
+func shellQuote(word string) string {
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
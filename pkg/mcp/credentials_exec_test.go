@@ -0,0 +1,51 @@
+//go:build !windows
+
+package mcp
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestKeyringTool_TellsMissingFromFailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		missing func(status int, stderr string) bool
+		want    error
+	}{
+		{"secret-tool found", "printf 'secret\\n'", secretToolMissing, nil},
+		{"secret-tool missing", "exit 1", secretToolMissing, ErrKeyringNotFound},
+		{"secret-tool locked", "echo 'Cannot unlock the collection' >&2; exit 1", secretToolMissing, ErrKeyringFailed},
+		{"security missing", "exit 44", securityMissing, ErrKeyringNotFound},
+		{"security denied", "echo 'User interaction is not allowed.' >&2; exit 36", securityMissing, ErrKeyringFailed},
+	}
+	for _, tt := range tests {
+		tool := &keyringTool{cmd: exec.Command("sh", "-c", tt.script), missing: tt.missing}
+		out, err := tool.run("")
+		switch {
+		case tt.want == nil && (err != nil || out != "secret\n"):
+			t.Errorf("%s: expected the secret, got %q, %v", tt.name, out, err)
+		case tt.want != nil && !errors.Is(err, tt.want):
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+		// A failure says why, so a locked keyring is not mistaken for an empty one
+		if errors.Is(tt.want, ErrKeyringFailed) && !strings.Contains(err.Error(), "exited with status") {
+			t.Errorf("%s: expected the tool's status reported, got %v", tt.name, err)
+		}
+	}
+
+	// What a tool given the secret prints is left out, as it may repeat it
+	tool := &keyringTool{cmd: exec.Command("sh", "-c", "cat >&2; exit 1"), missing: secretToolMissing}
+	if _, err := tool.run("sk-secret"); !errors.Is(err, ErrKeyringFailed) || strings.Contains(err.Error(), "sk-secret") {
+		t.Errorf("Expected the failure without the secret, got %v", err)
+	}
+
+	// A tool that is not installed means there is no keyring
+	tool = &keyringTool{cmd: exec.Command("ai-work-studio-no-such-keyring-tool"), missing: secretToolMissing}
+	if _, err := tool.run(""); !errors.Is(err, ErrKeyringUnavailable) {
+		t.Errorf("Expected no keyring without its tool, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memoryKeyring is a Keyring held in memory, keyed by service and user.
+type memoryKeyring struct {
+	secrets map[string]string
+	err     error
+}
+
+func newMemoryKeyring() *memoryKeyring {
+	return &memoryKeyring{secrets: make(map[string]string)}
+}
+
+func (k *memoryKeyring) Get(service, user string) (string, error) {
+	if k.err != nil {
+		return "", k.err
+	}
+	secret, ok := k.secrets[service+"/"+user]
+	if !ok {
+		return "", ErrKeyringNotFound
+	}
+	return secret, nil
+}
+
+func (k *memoryKeyring) Set(service, user, secret string) error {
+	k.secrets[service+"/"+user] = secret
+	return nil
+}
+
+func (k *memoryKeyring) Delete(service, user string) error {
+	if _, ok := k.secrets[service+"/"+user]; !ok {
+		return ErrKeyringNotFound
+	}
+	delete(k.secrets, service+"/"+user)
+	return nil
+}
+
+func TestCredentials_ResolvePriority(t *testing.T) {
+	clearProviderEnv(t)
+	t.Setenv("ANTHROPIC_API_KEY", "env-key")
+	keyring := newMemoryKeyring()
+	config := map[string]string{"anthropic": "config-key"}
+	credentials := NewCredentials(keyring, config)
+
+	// The keyring comes first
+	keyring.Set(KeyringService, "anthropic", "keyring-key")
+	if c := credentials.Resolve("anthropic"); c.Key != "keyring-key" || c.Source != CredentialSourceKeyring {
+		t.Errorf("Expected the keyring's key, got %+v", c)
+	}
+
+	// Then the configuration file
+	keyring.Delete(KeyringService, "anthropic")
+	if c := credentials.Resolve("anthropic"); c.Key != "config-key" || c.Source != "credentials.anthropic" {
+		t.Errorf("Expected the configuration's key, got %+v", c)
+	}
+
+	// Then the environment
+	delete(config, "anthropic")
+	c := credentials.Resolve("anthropic")
+	if c.Key != "env-key" || c.Source != "ANTHROPIC_API_KEY" {
+		t.Errorf("Expected the environment's key, got %+v", c)
+	}
+	if got := strings.Join(c.Checked, ","); got != "keyring,credentials.anthropic,ANTHROPIC_API_KEY" {
+		t.Errorf("Expected every source checked in order, got %s", got)
+	}
+
+	// A keyring that cannot be read is noted, not fatal
+	keyring.err = errors.New("locked")
+	if c := credentials.Resolve("anthropic"); c.Key != "env-key" || !strings.Contains(c.Warning, "locked") {
+		t.Errorf("Expected the keyring's error noted, got %+v", c)
+	}
+	// One that does not exist is not
+	keyring.err = ErrKeyringUnavailable
+	if c := credentials.Resolve("anthropic"); c.Warning != "" {
+		t.Errorf("Expected no warning without a keyring, got %q", c.Warning)
+	}
+}
+
+func TestLLMService_ReloadCredentials(t *testing.T) {
+	clearProviderEnv(t)
+	keyring := newMemoryKeyring()
+	keyring.Set(KeyringService, "anthropic", "first-key")
+	service := NewLLMServiceWithCredentialsProvider(nil, NewCredentials(keyring, nil))
+
+	before := service.providerSet()
+	inFlight, ok := before["anthropic"].(*AnthropicProvider)
+	if !ok || inFlight.APIKey != "first-key" {
+		t.Fatalf("Expected the keyring's Anthropic key, got %+v", before["anthropic"])
+	}
+	if _, ok := before["openai"]; ok {
+		t.Fatal("Expected no OpenAI provider without a key")
+	}
+
+	keyring.Set(KeyringService, "anthropic", "second-key")
+	keyring.Set(KeyringService, "openai", "openai-key")
+	result := service.Execute(context.Background(), ServiceParams{"operation": "reload_credentials"})
+	if !result.Success {
+		t.Fatalf("reload_credentials failed: %v", result.Error)
+	}
+	diagnosis := result.Data.(*ProviderDiagnosis)
+	for _, status := range diagnosis.Providers {
+		if status.Provider != "local" && (!status.Available || status.Source != CredentialSourceKeyring) {
+			t.Errorf("Expected %s available from the keyring, got %+v", status.Provider, status)
+		}
+	}
+
+	after := service.providerSet()
+	if anthropic := after["anthropic"].(*AnthropicProvider); anthropic == inFlight || anthropic.APIKey != "second-key" {
+		t.Errorf("Expected a new Anthropic provider with the new key, got %+v", anthropic)
+	}
+	if openai, ok := after["openai"].(*OpenAIProvider); !ok || openai.APIKey != "openai-key" {
+		t.Errorf("Expected an OpenAI provider added, got %+v", after["openai"])
+	}
+	// A request holding the old provider finishes with the key it started with
+	if inFlight.APIKey != "first-key" || before["anthropic"] != inFlight {
+		t.Error("Expected the provider in use left unchanged")
+	}
+
+	// An unchanged key keeps its provider; a removed one drops it
+	keyring.Delete(KeyringService, "openai")
+	service.ReloadCredentials()
+	final := service.providerSet()
+	if final["anthropic"] != after["anthropic"] {
+		t.Error("Expected the Anthropic provider kept when its key did not change")
+	}
+	if _, ok := final["openai"]; ok {
+		t.Error("Expected the OpenAI provider removed with its key")
+	}
+
+	// Keys are resolved before the providers are locked, so a slow keyring
+	// does not hold up requests
+	keyed := NewCredentials(keyring, nil)
+	service.credentials = resolveFunc(func(provider string) Credential {
+		if !service.providersMu.TryRLock() {
+			t.Errorf("Expected the %s key resolved outside the provider lock", provider)
+		} else {
+			service.providersMu.RUnlock()
+		}
+		return keyed.Resolve(provider)
+	})
+	service.ReloadCredentials()
+}
+
+// resolveFunc adapts a function to a CredentialsProvider.
+type resolveFunc func(provider string) Credential
+
+func (f resolveFunc) Resolve(provider string) Credential { return f(provider) }
+
+func TestLLMService_RedactsKeysFromErrors(t *testing.T) {
+	clearProviderEnv(t)
+	service := NewLLMServiceWithCredentials(nil, map[string]string{"openai": "sk-sentinel-0123456789"})
+
+	failing := func(ctx context.Context, params ServiceParams) ServiceResult {
+		return ErrorResult(errors.Join(ErrProviderUnavailable, errors.New("invalid key sk-sentinel-0123456789")))
+	}
+	result := service.withoutKeys(failing)(context.Background(), ServiceParams{"operation": "complete"})
+	if strings.Contains(result.Error.Error(), "sk-sentinel") || !strings.Contains(result.Error.Error(), "[REDACTED]") {
+		t.Errorf("Expected the key redacted, got %q", result.Error)
+	}
+	if !errors.Is(result.Error, ErrProviderUnavailable) {
+		t.Errorf("Expected the redacted error to keep its cause, got %v", result.Error)
+	}
+}
@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32    = windows.NewLazySystemDLL("advapi32.dll")
+	credReadW   = advapi32.NewProc("CredReadW")
+	credWriteW  = advapi32.NewProc("CredWriteW")
+	credDeleteW = advapi32.NewProc("CredDeleteW")
+	credFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1 // CRED_TYPE_GENERIC
+	credPersistLocalMachine = 2 // CRED_PERSIST_LOCAL_MACHINE
+)
+
+// credential is the Credential Manager's CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// systemKeyring is the Windows Credential Manager, holding each secret as a
+// generic credential named "<service>:<user>".
+type systemKeyring struct{}
+
+func (systemKeyring) Get(service, user string) (string, error) {
+	target, err := windows.UTF16PtrFromString(service + ":" + user)
+	if err != nil {
+		return "", err
+	}
+	var stored *credential
+	if err := callCredential(credReadW, uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&stored))); err != nil {
+		return "", err
+	}
+	defer credFree.Call(uintptr(unsafe.Pointer(stored)))
+	if stored.CredentialBlobSize == 0 {
+		return "", ErrKeyringNotFound
+	}
+	return string(unsafe.Slice(stored.CredentialBlob, stored.CredentialBlobSize)), nil
+}
+
+func (systemKeyring) Set(service, user, secret string) error {
+	target, err := windows.UTF16PtrFromString(service + ":" + user)
+	if err != nil {
+		return err
+	}
+	userName, err := windows.UTF16PtrFromString(user)
+	if err != nil {
+		return err
+	}
+	blob := []byte(secret)
+	stored := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		UserName:           userName,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+	}
+	if len(blob) > 0 {
+		stored.CredentialBlob = &blob[0]
+	}
+	return callCredential(credWriteW, uintptr(unsafe.Pointer(&stored)), 0)
+}
+
+func (systemKeyring) Delete(service, user string) error {
+	target, err := windows.UTF16PtrFromString(service + ":" + user)
+	if err != nil {
+		return err
+	}
+	return callCredential(credDeleteW, uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+}
+
+// callCredential calls a Credential Manager function. One that finds no
+// credential fails with ErrKeyringNotFound; one without a logon session to
+// keep credentials in, with ErrKeyringUnavailable; any other failure, with
+// ErrKeyringFailed.
+func callCredential(proc *windows.LazyProc, args ...uintptr) error {
+	if err := proc.Find(); err != nil {
+		return fmt.Errorf("%w: %v", ErrKeyringUnavailable, err)
+	}
+	if ok, _, err := proc.Call(args...); ok == 0 {
+		switch {
+		case errors.Is(err, windows.ERROR_NOT_FOUND):
+			return ErrKeyringNotFound
+		case errors.Is(err, windows.ERROR_NO_SUCH_LOGON_SESSION):
+			return fmt.Errorf("%w: %v", ErrKeyringUnavailable, err)
+		default:
+			return fmt.Errorf("%w: %v", ErrKeyringFailed, err)
+		}
+	}
+	return nil
+}
@@ -30,6 +30,10 @@
 //	params := mcp.ServiceParams{"input": "hello"}
 //	result := registry.CallService(ctx, "my-service", params)
 //
+//	// Wrap every call, first registered outermost
+//	registry.RegisterMiddleware(mcp.RecoveryMiddleware(nil))
+//	registry.RegisterMiddleware(mcp.TimingMiddleware())
+//
 // Service Implementation:
 //
 //	type MyService struct {
@@ -56,6 +60,8 @@
 //   - Service discovery and metadata
 //   - Execution timing and monitoring
 //   - Thread-safe service registry operations
+//   - Middleware around registry calls, with timing, logging and panic
+//     recovery built in
 //
 // This enables the RTC to execute methods that require external tool capabilities
 // while maintaining the system's principles of simplicity and reliability.
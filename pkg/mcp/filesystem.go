@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -9,6 +10,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 )
 
 // FileSystemService provides file system operations as an MCP service.
@@ -17,6 +20,7 @@ import (
 type FileSystemService struct {
 	*BaseService
 	basePaths   []string // Allowed base directories for security
+	realPaths   []string // basePaths with their symlinks resolved
 	maxFileSize int64    // Maximum file size in bytes for read/write operations
 	chunkSize   int      // Chunk size for large file operations
 }
@@ -46,9 +50,39 @@ type DirectoryListing struct {
 	Count   int        `json:"count"`
 }
 
+// SearchMatch is a file found by a search. Line is the 1-based line the
+// content was found on, and 0 when only the file name was searched.
+type SearchMatch struct {
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// SearchResults represents the files under a directory matching a search.
+type SearchResults struct {
+	Path      string        `json:"path"`
+	Pattern   string        `json:"pattern,omitempty"`
+	Content   string        `json:"content,omitempty"`
+	Matches   []SearchMatch `json:"matches"`
+	Count     int           `json:"count"`
+	Truncated bool          `json:"truncated"`
+}
+
+// defaultSearchResults is how many matches a search returns by default.
+const defaultSearchResults = 100
+
+// fileOperationAliases maps the short operation names to the operations
+// they stand for.
+var fileOperationAliases = map[string]string{
+	"read":  "read_file",
+	"write": "write_file",
+	"list":  "list_directory",
+}
+
 // NewFileSystemService creates a new file system MCP service.
 // basePaths defines the allowed directories for operations (security restriction).
 // If basePaths is empty, operations are restricted to the current working directory.
+// The first base path is the root that relative paths are resolved against.
 func NewFileSystemService(basePaths []string, logger *log.Logger) *FileSystemService {
 	base := NewBaseService(
 		"filesystem",
@@ -67,17 +101,20 @@ func NewFileSystemService(basePaths []string, logger *log.Logger) *FileSystemSer
 
 	// Clean and normalize all base paths
 	cleanPaths := make([]string, len(basePaths))
+	realPaths := make([]string, len(basePaths))
 	for i, path := range basePaths {
 		if absPath, err := filepath.Abs(path); err == nil {
 			cleanPaths[i] = filepath.Clean(absPath)
 		} else {
 			cleanPaths[i] = filepath.Clean(path)
 		}
+		realPaths[i] = resolveSymlinks(cleanPaths[i])
 	}
 
 	return &FileSystemService{
 		BaseService: base,
 		basePaths:   cleanPaths,
+		realPaths:   realPaths,
 		maxFileSize: 100 * 1024 * 1024, // 100MB default
 		chunkSize:   64 * 1024,          // 64KB chunks
 	}
@@ -98,6 +135,9 @@ func (fs *FileSystemService) ValidateParams(params ServiceParams) error {
 	if !ok {
 		return NewValidationError("operation", "operation must be a string")
 	}
+	if aliased, ok := fileOperationAliases[operationStr]; ok {
+		operationStr = aliased
+	}
 
 	// Validate operation-specific parameters
 	switch operationStr {
@@ -113,14 +153,23 @@ func (fs *FileSystemService) ValidateParams(params ServiceParams) error {
 		return fs.validateCreateDirectoryParams(params)
 	case "delete_file":
 		return fs.validateDeleteFileParams(params)
+	case "stat":
+		return ValidateStringParam(params, "path", true)
+	case "search":
+		return fs.validateSearchParams(params)
 	default:
 		return NewValidationError("operation", fmt.Sprintf("unsupported operation: %s", operationStr))
 	}
 }
 
-// Execute performs the requested file system operation.
+// Execute performs the requested file system operation. The operations are
+// read (read_file), write (write_file), list (list_directory), stat, search,
+// exists, create_directory and delete_file.
 func (fs *FileSystemService) Execute(ctx context.Context, params ServiceParams) ServiceResult {
 	operation := params["operation"].(string)
+	if aliased, ok := fileOperationAliases[operation]; ok {
+		operation = aliased
+	}
 
 	switch operation {
 	case "read_file":
@@ -135,35 +184,60 @@ func (fs *FileSystemService) Execute(ctx context.Context, params ServiceParams)
 		return fs.createDirectory(ctx, params)
 	case "delete_file":
 		return fs.deleteFile(ctx, params)
+	case "stat":
+		return fs.stat(ctx, params)
+	case "search":
+		return fs.search(ctx, params)
 	default:
 		return ErrorResult(fmt.Errorf("unsupported operation: %s", operation))
 	}
 }
 
-// validatePath checks if the given path is within allowed base directories.
-func (fs *FileSystemService) validatePath(path string) error {
-	// Clean and get absolute path
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return fmt.Errorf("invalid path: %w", err)
+// resolvePath returns the absolute path a requested path refers to,
+// resolving relative paths against the root. The path must lie within an
+// allowed base directory both as written and once its symlinks are
+// resolved, so neither ".." nor a link can lead outside.
+func (fs *FileSystemService) resolvePath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(fs.basePaths[0], path)
 	}
+	cleanPath := filepath.Clean(path)
+	realPath := resolveSymlinks(cleanPath)
 
-	cleanPath := filepath.Clean(absPath)
-
-	// Check if path is within any allowed base directory
-	for _, basePath := range fs.basePaths {
-		relPath, err := filepath.Rel(basePath, cleanPath)
-		if err != nil {
-			continue
+	for i, basePath := range fs.basePaths {
+		if withinDir(basePath, cleanPath) && withinDir(fs.realPaths[i], realPath) {
+			return cleanPath, nil
 		}
+	}
 
-		// Path is valid if it doesn't contain ".." (trying to escape)
-		if !strings.Contains(relPath, "..") {
-			return nil
-		}
+	return "", errs.Newf(errs.PolicyBlocked, "path '%s' is outside allowed directories", path)
+}
+
+// withinDir reports whether path is dir or lies beneath it. Both are clean
+// absolute paths.
+func withinDir(dir, path string) bool {
+	relPath, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
 	}
+	return relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
 
-	return fmt.Errorf("path '%s' is outside allowed directories", path)
+// resolveSymlinks resolves the symlinks in a clean absolute path. The part
+// of the path that does not exist yet is kept as written.
+func resolveSymlinks(path string) string {
+	existing, rest := path, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(existing); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
 }
 
 // validateReadFileParams validates parameters for read_file operation.
@@ -234,12 +308,33 @@ func (fs *FileSystemService) validateDeleteFileParams(params ServiceParams) erro
 	return ValidateStringParam(params, "path", true)
 }
 
+// validateSearchParams validates parameters for search operation.
+func (fs *FileSystemService) validateSearchParams(params ServiceParams) error {
+	for _, name := range []string{"path", "pattern", "content"} {
+		if err := ValidateStringParam(params, name, false); err != nil {
+			return err
+		}
+	}
+
+	pattern, _ := params["pattern"].(string)
+	content, _ := params["content"].(string)
+	if pattern == "" && content == "" {
+		return NewValidationError("pattern", "search needs a name pattern, content to find, or both")
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return NewValidationError("pattern", fmt.Sprintf("invalid name pattern: %v", err))
+	}
+
+	minResults := 1
+	maxResults := 10000
+	return ValidateIntParam(params, "max_results", false, &minResults, &maxResults)
+}
+
 // readFile reads a file and returns its content.
 func (fs *FileSystemService) readFile(ctx context.Context, params ServiceParams) ServiceResult {
-	path := params["path"].(string)
-
 	// Validate path
-	if err := fs.validatePath(path); err != nil {
+	path, err := fs.resolvePath(params["path"].(string))
+	if err != nil {
 		return ErrorResult(fmt.Errorf("path validation failed: %w", err))
 	}
 
@@ -322,11 +417,11 @@ func (fs *FileSystemService) readFile(ctx context.Context, params ServiceParams)
 
 // writeFile writes content to a file.
 func (fs *FileSystemService) writeFile(ctx context.Context, params ServiceParams) ServiceResult {
-	path := params["path"].(string)
 	content := params["content"]
 
 	// Validate path
-	if err := fs.validatePath(path); err != nil {
+	path, err := fs.resolvePath(params["path"].(string))
+	if err != nil {
 		return ErrorResult(fmt.Errorf("path validation failed: %w", err))
 	}
 
@@ -359,7 +454,6 @@ func (fs *FileSystemService) writeFile(ctx context.Context, params ServiceParams
 
 	// Write file
 	var file *os.File
-	var err error
 
 	if encoding == "binary" {
 		file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
@@ -418,10 +512,9 @@ func (fs *FileSystemService) writeFile(ctx context.Context, params ServiceParams
 
 // listDirectory lists the contents of a directory.
 func (fs *FileSystemService) listDirectory(ctx context.Context, params ServiceParams) ServiceResult {
-	path := params["path"].(string)
-
 	// Validate path
-	if err := fs.validatePath(path); err != nil {
+	path, err := fs.resolvePath(params["path"].(string))
+	if err != nil {
 		return ErrorResult(fmt.Errorf("path validation failed: %w", err))
 	}
 
@@ -483,10 +576,9 @@ func (fs *FileSystemService) listDirectory(ctx context.Context, params ServicePa
 
 // checkExists checks if a file or directory exists.
 func (fs *FileSystemService) checkExists(ctx context.Context, params ServiceParams) ServiceResult {
-	path := params["path"].(string)
-
 	// Validate path
-	if err := fs.validatePath(path); err != nil {
+	path, err := fs.resolvePath(params["path"].(string))
+	if err != nil {
 		return ErrorResult(fmt.Errorf("path validation failed: %w", err))
 	}
 
@@ -507,12 +599,140 @@ func (fs *FileSystemService) checkExists(ctx context.Context, params ServicePara
 	return SuccessResult(result)
 }
 
+// stat returns the metadata of a file or directory.
+func (fs *FileSystemService) stat(ctx context.Context, params ServiceParams) ServiceResult {
+	// Validate path
+	path, err := fs.resolvePath(params["path"].(string))
+	if err != nil {
+		return ErrorResult(fmt.Errorf("path validation failed: %w", err))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrorResult(errs.Newf(errs.NotFound, "file not found: %s", path))
+		}
+		return ErrorResult(fmt.Errorf("error accessing file: %w", err))
+	}
+
+	return SuccessResult(FileInfo{
+		Name:    info.Name(),
+		Path:    path,
+		IsDir:   info.IsDir(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode().String(),
+	})
+}
+
+// search finds the files under a directory (the root by default) whose names
+// match a glob pattern, whose lines contain some text, or both. Symlinks are
+// not followed, and binary and oversized files are not searched for content.
+func (fs *FileSystemService) search(ctx context.Context, params ServiceParams) ServiceResult {
+	requested, _ := params["path"].(string)
+	if requested == "" {
+		requested = fs.basePaths[0]
+	}
+	root, err := fs.resolvePath(requested)
+	if err != nil {
+		return ErrorResult(fmt.Errorf("path validation failed: %w", err))
+	}
+
+	pattern, _ := params["pattern"].(string)
+	content, _ := params["content"].(string)
+	limit := defaultSearchResults
+	switch v := params["max_results"].(type) {
+	case int:
+		limit = v
+	case float64:
+		limit = int(v)
+	}
+
+	results := SearchResults{Path: root, Pattern: pattern, Content: content, Matches: []SearchMatch{}}
+	errLimit := fmt.Errorf("search limit reached")
+	err = filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // Skip entries we can't access
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() {
+			return nil
+		}
+		if pattern != "" {
+			if matched, _ := filepath.Match(pattern, entry.Name()); !matched {
+				return nil
+			}
+		}
+
+		if content == "" {
+			results.Matches = append(results.Matches, SearchMatch{Path: path})
+		} else {
+			found, err := fs.searchFile(path, content, limit-len(results.Matches))
+			if err != nil {
+				return nil // Skip files we can't read
+			}
+			results.Matches = append(results.Matches, found...)
+		}
+		if len(results.Matches) >= limit {
+			return errLimit
+		}
+		return nil
+	})
+	switch {
+	case err == errLimit:
+		results.Truncated = true
+	case os.IsNotExist(err):
+		return ErrorResult(errs.Newf(errs.NotFound, "directory not found: %s", root))
+	case err != nil && ctx.Err() != nil:
+		return ErrorResult(fmt.Errorf("operation cancelled: %w", ctx.Err()))
+	case err != nil:
+		return ErrorResult(fmt.Errorf("error searching directory: %w", err))
+	}
+
+	results.Count = len(results.Matches)
+	return SuccessResult(results)
+}
+
+// searchFile returns up to limit lines of a file containing content.
+func (fs *FileSystemService) searchFile(path, content string, limit int) ([]SearchMatch, error) {
+	if fs.isBinaryFile(path) {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > fs.maxFileSize {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var matches []SearchMatch
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, fs.chunkSize), 1024*1024)
+	for line := 1; scanner.Scan() && len(matches) < limit; line++ {
+		if text := scanner.Text(); strings.Contains(text, content) {
+			matches = append(matches, SearchMatch{Path: path, Line: line, Text: text})
+		}
+	}
+	return matches, nil
+}
+
 // createDirectory creates a directory and any necessary parent directories.
 func (fs *FileSystemService) createDirectory(ctx context.Context, params ServiceParams) ServiceResult {
-	path := params["path"].(string)
-
 	// Validate path
-	if err := fs.validatePath(path); err != nil {
+	path, err := fs.resolvePath(params["path"].(string))
+	if err != nil {
 		return ErrorResult(fmt.Errorf("path validation failed: %w", err))
 	}
 
@@ -539,10 +759,9 @@ func (fs *FileSystemService) createDirectory(ctx context.Context, params Service
 
 // deleteFile deletes a file or empty directory.
 func (fs *FileSystemService) deleteFile(ctx context.Context, params ServiceParams) ServiceResult {
-	path := params["path"].(string)
-
 	// Validate path
-	if err := fs.validatePath(path); err != nil {
+	path, err := fs.resolvePath(params["path"].(string))
+	if err != nil {
 		return ErrorResult(fmt.Errorf("path validation failed: %w", err))
 	}
 
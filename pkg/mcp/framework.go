@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 )
 
 // ServiceResult represents the result of an MCP service call.
@@ -175,6 +177,11 @@ func (ve ValidationError) Error() string {
 	return fmt.Sprintf("validation error for parameter '%s': %s", ve.Parameter, ve.Message)
 }
 
+// ErrorCode returns errs.Validation.
+func (ve ValidationError) ErrorCode() errs.Code {
+	return errs.Validation
+}
+
 // NewValidationError creates a new validation error for a specific parameter.
 func NewValidationError(parameter, message string) ValidationError {
 	return ValidationError{
@@ -0,0 +1,247 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// HealthConfig configures the provider health monitor.
+type HealthConfig struct {
+	// Interval is the time between rounds of health checks
+	Interval time.Duration
+
+	// FailureThreshold is the number of consecutive failed requests or
+	// health checks after which a provider is taken out of service
+	FailureThreshold int
+
+	// Timeout bounds each health check
+	Timeout time.Duration
+}
+
+// DefaultHealthConfig returns the default health monitor configuration.
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		Interval:         30 * time.Second,
+		FailureThreshold: 3,
+		Timeout:          5 * time.Second,
+	}
+}
+
+// ProviderHealth is a provider's health as the monitor last saw it.
+type ProviderHealth struct {
+	Healthy bool
+
+	// ConsecutiveFailures counts failed requests and health checks since
+	// the last success
+	ConsecutiveFailures int
+
+	// LastChecked is when the provider was last health checked (zero if never)
+	LastChecked time.Time
+
+	// LastError is the most recent failure, cleared by a success
+	LastError string
+}
+
+// StartHealthMonitor starts tracking provider health and checks every
+// provider each interval until ctx is done. A provider is taken out of
+// service after FailureThreshold consecutive failed requests or checks:
+// automatic selection skips it and requests naming it fail at once with an
+// error matching ErrProviderUnhealthy. It is re-admitted once a health check
+// succeeds. Zero config values fall back to the defaults.
+func (llm *LLMService) StartHealthMonitor(ctx context.Context, config HealthConfig) {
+	llm.enableHealth(config)
+
+	go func() {
+		ticker := time.NewTicker(llm.healthConfig().Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				llm.CheckHealth(ctx)
+			}
+		}
+	}()
+}
+
+// CheckHealth health checks every provider now and returns their health by
+// provider name. It starts health tracking with the default configuration
+// if the monitor is not running.
+func (llm *LLMService) CheckHealth(ctx context.Context) map[string]ProviderHealth {
+	llm.enableHealth(HealthConfig{})
+	timeout := llm.healthConfig().Timeout
+
+	providers := llm.providerSet()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := providers[name].HealthCheck(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			break
+		}
+		llm.recordHealth(name, err, true)
+	}
+	return llm.providerHealth()
+}
+
+// enableHealth starts tracking provider health, if it is not tracked yet.
+func (llm *LLMService) enableHealth(config HealthConfig) {
+	defaults := DefaultHealthConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	llm.healthMu.Lock()
+	defer llm.healthMu.Unlock()
+	if llm.health == nil {
+		llm.health = make(map[string]*ProviderHealth)
+		llm.healthCfg = config
+	}
+}
+
+// healthConfig returns the health monitor configuration.
+func (llm *LLMService) healthConfig() HealthConfig {
+	llm.healthMu.Lock()
+	defer llm.healthMu.Unlock()
+	return llm.healthCfg
+}
+
+// providerHealth returns a copy of every provider's health, by name. Until
+// health is tracked every provider is healthy.
+func (llm *LLMService) providerHealth() map[string]ProviderHealth {
+	llm.healthMu.Lock()
+	defer llm.healthMu.Unlock()
+	providers := llm.providerSet()
+	report := make(map[string]ProviderHealth, len(providers))
+	for name := range providers {
+		if state, ok := llm.health[name]; ok {
+			report[name] = *state
+		} else {
+			report[name] = ProviderHealth{Healthy: true}
+		}
+	}
+	return report
+}
+
+// providerHealthy reports whether requests may go to the provider.
+func (llm *LLMService) providerHealthy(provider string) bool {
+	llm.healthMu.Lock()
+	defer llm.healthMu.Unlock()
+	state, ok := llm.health[provider]
+	return !ok || state.Healthy
+}
+
+// unhealthyError is returned for a request to a provider out of service.
+func (llm *LLMService) unhealthyError(provider string) error {
+	err := errs.Newf(errs.ProviderUnavailable, "provider '%s' is out of service after failing its health checks", provider)
+	err.Err = ErrProviderUnhealthy
+	return err
+}
+
+// recordOutcome counts a request's outcome toward the provider's health:
+// a success resets its failures, and a failure to reach the provider or a
+// server error adds one. Other failures say nothing about its health.
+func (llm *LLMService) recordOutcome(ctx context.Context, provider string, err error) {
+	if err != nil && (ctx.Err() != nil || !isAvailabilityFailure(err)) {
+		return
+	}
+	llm.recordHealth(provider, err, false)
+}
+
+// recordHealth records a request's or health check's outcome, taking the
+// provider out of service once its failures reach the threshold and
+// re-admitting it when a health check succeeds.
+func (llm *LLMService) recordHealth(provider string, err error, check bool) {
+	llm.healthMu.Lock()
+	defer llm.healthMu.Unlock()
+	if llm.health == nil {
+		return
+	}
+	state, ok := llm.health[provider]
+	if !ok {
+		state = &ProviderHealth{Healthy: true}
+		llm.health[provider] = state
+	}
+	if check {
+		state.LastChecked = llm.clock.Now()
+	}
+
+	if err == nil {
+		// Only a health check re-admits a provider out of service
+		if state.Healthy || check {
+			if !state.Healthy {
+				llm.logger.Printf("Provider %s passed its health check and is back in service", provider)
+			}
+			state.Healthy = true
+			state.ConsecutiveFailures = 0
+			state.LastError = ""
+		}
+		return
+	}
+
+	state.ConsecutiveFailures++
+	state.LastError = err.Error()
+	if state.Healthy && state.ConsecutiveFailures >= llm.healthCfg.FailureThreshold {
+		state.Healthy = false
+		llm.logger.Printf("Provider %s taken out of service after %d consecutive failures: %v",
+			provider, state.ConsecutiveFailures, err)
+	}
+}
+
+// isAvailabilityFailure reports whether an error shows the provider could
+// not serve the request: a server error, a timeout or a failed connection.
+func isAvailabilityFailure(err error) bool {
+	if errors.Is(err, ErrProviderUnavailable) || errors.Is(err, ErrTimeout) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// probeHealth sends a GET to url and fails if the server cannot be reached
+// or answers with a server error. Rejected credentials and other client
+// errors still show the server is up, and are left to the credential checks.
+func probeHealth(ctx context.Context, client *http.Client, url string, auth AuthScheme, provider string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health check: %w", err)
+	}
+	auth.Apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s health check failed: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 500 {
+		return newAPIError(resp, provider, "", nil)
+	}
+	return nil
+}
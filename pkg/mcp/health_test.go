@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProviderHealth_RequestFailures(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	var generated atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/generate" {
+			generated.Add(1)
+			w.WriteHeader(int(status.Load()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	service := NewLLMServiceWithProviders(log.New(io.Discard, "", 0), map[string]LLMProvider{
+		"local": &LocalProvider{ServerURL: server.URL, HTTPClient: server.Client(), Models: map[string]ModelConfig{"local-llama": {Name: "llama"}}},
+	})
+	service.SetRetryConfig(RetryConfig{MaxRetries: 0, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffRate: 1})
+	complete := func() ServiceResult {
+		return service.Execute(context.Background(), ServiceParams{"operation": "complete", "prompt": "Hi", "provider": "local"})
+	}
+
+	// Without the monitor, failures are not tracked
+	complete()
+	complete()
+	if !service.providerHealthy("local") {
+		t.Fatal("Expected no health tracking before the monitor starts")
+	}
+
+	service.enableHealth(HealthConfig{FailureThreshold: 2})
+
+	// Rejected requests say nothing about the provider's health
+	status.Store(http.StatusBadRequest)
+	complete()
+	complete()
+	if !service.providerHealthy("local") {
+		t.Fatal("Expected client errors not to count against the provider")
+	}
+
+	// Server errors do, up to the threshold
+	status.Store(http.StatusServiceUnavailable)
+	complete()
+	if !service.providerHealthy("local") {
+		t.Fatal("Expected one failure to leave the provider in service")
+	}
+	complete()
+	if service.providerHealthy("local") {
+		t.Fatal("Expected two consecutive failures to take the provider out of service")
+	}
+
+	sent := generated.Load()
+	if result := complete(); !errors.Is(result.Error, ErrProviderUnhealthy) {
+		t.Errorf("Expected ErrProviderUnhealthy, got %v", result.Error)
+	}
+	if generated.Load() != sent {
+		t.Error("Expected the request not to reach the provider")
+	}
+
+	// A successful request does not re-admit it, a health check does
+	health := service.CheckHealth(context.Background())
+	if state := health["local"]; !state.Healthy || state.ConsecutiveFailures != 0 || state.LastChecked.IsZero() {
+		t.Errorf("Expected a passing check to re-admit the provider, got %+v", state)
+	}
+}
+
+func TestProviderHealth_Checks(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	service := NewLLMServiceWithProviders(log.New(io.Discard, "", 0), map[string]LLMProvider{
+		"openai": &OpenAIProvider{APIKey: "test-key", BaseURL: server.URL, HTTPClient: server.Client()},
+	})
+	service.enableHealth(HealthConfig{FailureThreshold: 2})
+	ctx := context.Background()
+
+	// A rejected key still shows the server is up
+	status.Store(http.StatusUnauthorized)
+	if state := service.CheckHealth(ctx)["openai"]; !state.Healthy || state.ConsecutiveFailures != 0 {
+		t.Errorf("Expected a client error to pass the check, got %+v", state)
+	}
+
+	status.Store(http.StatusBadGateway)
+	service.CheckHealth(ctx)
+	state := service.CheckHealth(ctx)["openai"]
+	if state.Healthy || state.ConsecutiveFailures != 2 || state.LastError == "" {
+		t.Errorf("Expected two failed checks to take the provider out of service, got %+v", state)
+	}
+	if _, _, err := service.selectProvider(ServiceParams{}, "complete"); err == nil {
+		t.Error("Expected automatic selection to skip the provider")
+	}
+
+	// A closed server fails the check as well
+	server.Close()
+	if state := service.CheckHealth(ctx)["openai"]; state.Healthy || state.ConsecutiveFailures != 3 {
+		t.Errorf("Expected an unreachable server to fail the check, got %+v", state)
+	}
+}
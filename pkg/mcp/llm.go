@@ -3,22 +3,313 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/metrics"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/retry"
 )
 
 // LLMService provides language model access as an MCP service.
 // It supports multiple providers, budget tracking, and error handling with retries.
 type LLMService struct {
 	*BaseService
-	providers    map[string]LLMProvider
+	providers    map[string]LLMProvider // Replaced, never changed in place, under providersMu
+	providersMu  sync.RWMutex
+	credentials  CredentialsProvider // Resolves the keys of the Anthropic and OpenAI providers
 	budgetTracker *BudgetTracker
-	httpClient   *http.Client
+	httpTimeout  time.Duration
 	retryConfig  RetryConfig
+
+	clock          utils.Clock    // Decides which day spending falls in
+	budgetLocation *time.Location // Time zone whose midnight starts a new budget day
+	budgetMu       sync.Mutex     // Guards budgetTracker across concurrent requests
+
+	limiters map[string]*providerLimiter // Per-provider limits, by provider name
+	limitsMu sync.RWMutex
+
+	health    map[string]*ProviderHealth // Provider health, by name; nil until tracked
+	healthCfg HealthConfig
+	healthMu  sync.Mutex
+
+	auditLog *AuditLog // Records every request; nil when not auditing
+	auditMu  sync.RWMutex
+
+	completionCache *CompletionCache // Serves repeated completions; nil when not caching
+	cacheMu         sync.RWMutex
+
+	models      ModelDefinitions // Models the Anthropic and OpenAI providers offer, under providersMu
+	uncataloged sync.Map         // Provider/model pairs warned about as missing from the catalog
+
+	availability []ProviderStatus // What was checked for each provider at construction
+}
+
+// Errors matched by errors.Is against the errors the service returns, so
+// callers can tell a spent budget from a provider that is down without
+// reading messages. Provider failures are *APIError values carrying the
+// provider, model and HTTP status.
+var (
+	// ErrAuthFailed is matched when a provider rejects the configured
+	// credentials, such as an expired or revoked API key
+	ErrAuthFailed = errs.New(errs.ProviderAuth, "provider rejected the credentials")
+
+	// ErrRateLimited is matched when a provider answers 429 Too Many Requests
+	ErrRateLimited = errs.New(errs.QuotaExceeded, "provider rate-limited the request")
+
+	// ErrProviderUnavailable is matched when a provider fails with a server error
+	ErrProviderUnavailable = errs.New(errs.ProviderUnavailable, "provider is unavailable")
+
+	// ErrContextTooLarge is matched when a provider rejects a request for
+	// not fitting the model's context window
+	ErrContextTooLarge = errs.New(errs.Validation, "request exceeds the model's context window")
+
+	// ErrBudgetExceeded is matched when the service's daily budget is spent
+	ErrBudgetExceeded = errs.New(errs.BudgetExceeded, "daily budget exceeded")
+
+	// ErrTimeout is matched when a completion runs past its deadline, set
+	// by its timeout_seconds or by the caller's context
+	ErrTimeout = errs.New(errs.Timeout, "request timed out")
+
+	// ErrProviderUnhealthy is matched when a request goes to a provider the
+	// health monitor took out of service; it fails at once, without retries
+	ErrProviderUnhealthy = errs.New(errs.ProviderUnavailable, "provider is out of service")
+
+	// ErrInvalidResponse is matched when a completion asked for JSON and the
+	// reply still did not match its response schema after a repair attempt
+	ErrInvalidResponse = errs.New(errs.Validation, "reply does not match the response schema")
+
+	// ErrNoProvidersConfigured is matched when the service has no provider
+	// at all, because no API key or local server was configured
+	ErrNoProvidersConfigured = errs.New(errs.ProviderUnavailable, "no LLM providers are configured")
+)
+
+// contextLengthErrorTypes are the error types and codes providers report for
+// requests too large for the model.
+var contextLengthErrorTypes = map[string]bool{
+	"context_length_exceeded": true, // OpenAI
+	"request_too_large":       true, // Anthropic
+}
+
+// APIError is an error response from a provider's HTTP API.
+type APIError struct {
+	Provider   string // Name the provider is configured under, such as "anthropic"
+	Model      string // Model the request was for
+	StatusCode int
+	Message    string
+
+	// Type is the provider's own error type or code, such as
+	// "overloaded_error" or "context_length_exceeded", if it sent one
+	Type string
+
+	// RetryAfter is how long the provider asked callers to wait, from its
+	// Retry-After header (0 if none was sent)
+	RetryAfter time.Duration
+
+	// InputTokens and OutputTokens are the usage the provider reported for
+	// the failed request, and Cost its price, when it reported any
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+}
+
+func (e *APIError) Error() string {
+	source := e.Provider
+	if e.Model != "" {
+		source += "/" + e.Model
+	}
+	if source != "" {
+		source += " "
+	}
+	return fmt.Sprintf("%sAPI error (status %d): %s", source, e.StatusCode, e.Message)
+}
+
+// sentinel returns the error e matches: ErrAuthFailed for 401 and 403,
+// ErrRateLimited for 429, ErrContextTooLarge for 413 or a context length
+// error type, ErrProviderUnavailable for server errors, and nil for any
+// other rejected request.
+func (e *APIError) sentinel() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrAuthFailed
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusRequestEntityTooLarge || contextLengthErrorTypes[e.Type]:
+		return ErrContextTooLarge
+	case e.StatusCode >= 500:
+		return ErrProviderUnavailable
+	default:
+		return nil
+	}
+}
+
+// Is matches the sentinel error for the response status.
+func (e *APIError) Is(target error) bool {
+	sentinel := e.sentinel()
+	return sentinel != nil && target == sentinel
+}
+
+// ErrorCode reports the code of the matched sentinel error, or
+// errs.Validation for other rejected requests.
+func (e *APIError) ErrorCode() errs.Code {
+	if sentinel := e.sentinel(); sentinel != nil {
+		return errs.CodeOf(sentinel)
+	}
+	return errs.Validation
+}
+
+// RetryClass classifies the error for retry policies: "auth", "rate_limit",
+// "server" or "client".
+func (e *APIError) RetryClass() string {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return "auth"
+	case e.StatusCode == http.StatusTooManyRequests:
+		return "rate_limit"
+	case e.StatusCode >= 500:
+		return "server"
+	default:
+		return "client"
+	}
+}
+
+// RetryHint returns the wait the provider asked for in its Retry-After header.
+func (e *APIError) RetryHint() time.Duration {
+	return e.RetryAfter
+}
+
+// newAPIError builds the error for a failed provider response from its
+// decoded body, which may be nil. Providers send {"error": {"message": ...,
+// "type": ..., "code": ...}} or {"error": "message"}; a code is preferred to
+// a type as the more specific of the two. Usage sent alongside the error,
+// under Anthropic's or OpenAI's names, is kept for the caller to price.
+func newAPIError(resp *http.Response, provider, model string, body map[string]interface{}) *APIError {
+	apiErr := &APIError{
+		Provider:   provider,
+		Model:      model,
+		StatusCode: resp.StatusCode,
+		Message:    "unknown error",
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	if resp.Status != "" {
+		apiErr.Message = resp.Status
+	}
+	switch detail := body["error"].(type) {
+	case string:
+		apiErr.Message = detail
+	case map[string]interface{}:
+		if message, ok := detail["message"].(string); ok {
+			apiErr.Message = message
+		}
+		if code, ok := detail["code"].(string); ok && code != "" {
+			apiErr.Type = code
+		} else if errType, ok := detail["type"].(string); ok {
+			apiErr.Type = errType
+		}
+	}
+	if usage, ok := body["usage"].(map[string]interface{}); ok {
+		for _, key := range []string{"input_tokens", "prompt_tokens"} {
+			if tokens, ok := usage[key].(float64); ok {
+				apiErr.InputTokens = int(tokens)
+			}
+		}
+		for _, key := range []string{"output_tokens", "completion_tokens"} {
+			if tokens, ok := usage[key].(float64); ok {
+				apiErr.OutputTokens = int(tokens)
+			}
+		}
+	}
+	return apiErr
+}
+
+// TokensUsed returns the tokens the provider reported for the failed request.
+func (e *APIError) TokensUsed() int {
+	return e.InputTokens + e.OutputTokens
+}
+
+// TimeoutError is a completion that ran past its deadline before the
+// provider answered. Timeouts are only retried when the request opts in.
+type TimeoutError struct {
+	Provider string
+	Model    string
+
+	// Timeout is the request's own timeout, or 0 when the deadline was the
+	// caller's
+	Timeout time.Duration
+
+	// Elapsed is how long the request ran before it was abandoned
+	Elapsed time.Duration
+
+	Err error
+}
+
+// Error describes the timeout, e.g. "anthropic/claude-3-haiku request timed out after 10s".
+func (e *TimeoutError) Error() string {
+	message := fmt.Sprintf("%s/%s request timed out after %s", e.Provider, e.Model, e.Elapsed.Round(time.Millisecond))
+	if e.Timeout > 0 {
+		message += fmt.Sprintf(" (timeout %s)", e.Timeout)
+	}
+	return message
+}
+
+// Unwrap returns the error the request failed with.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrTimeout.
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// ErrorCode reports the Timeout code.
+func (e *TimeoutError) ErrorCode() errs.Code {
+	return errs.Timeout
+}
+
+// checkTimeout returns err as a *TimeoutError when ctx's deadline has
+// passed, and as it is otherwise.
+func checkTimeout(ctx context.Context, err error, provider string, request CompletionRequest, started time.Time) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &TimeoutError{
+		Provider: provider,
+		Model:    request.Model,
+		Timeout:  request.Timeout,
+		Elapsed:  time.Since(started),
+		Err:      err,
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
 }
 
 // LLMProvider defines the interface for different LLM providers.
@@ -26,23 +317,104 @@ type LLMProvider interface {
 	Name() string
 	Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error)
 	Embed(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error)
-	CalculateCost(tokens int, operation string) float64
+	// CalculateCost returns the cost of a request to model, pricing input
+	// and output tokens separately
+	CalculateCost(model string, inputTokens, outputTokens int) float64
+	// HealthCheck cheaply checks that the provider can take requests
+	HealthCheck(ctx context.Context) error
 }
 
-// CompletionRequest represents a text completion request.
+// Roles of the messages in a conversation.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// CompletionRequest represents a text completion request. Messages, when
+// set, carries a conversation, oldest turn first, and is sent instead of
+// Prompt.
 type CompletionRequest struct {
 	Model       string            `json:"model"`
 	Prompt      string            `json:"prompt"`
+	Messages    []Message         `json:"messages,omitempty"`
 	MaxTokens   int               `json:"max_tokens,omitempty"`
 	Temperature float64           `json:"temperature,omitempty"`
 	StopWords   []string          `json:"stop_words,omitempty"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
+
+	// Timeout bounds each attempt at the request on top of the HTTP
+	// client's own timeout (0: the client's timeout only)
+	Timeout time.Duration `json:"-"`
+
+	// ResponseSchema asks for a JSON reply, which the service checks
+	// before returning it (nil: free text)
+	ResponseSchema *ResponseSchema `json:"response_schema,omitempty"`
+}
+
+// withTimeout returns ctx bounded by the request's timeout, if it has one.
+func (r CompletionRequest) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.Timeout)
+}
+
+// estimatedTokens is the request's size for rate limits before the provider
+// reports it: the prompt at about four characters a token, plus the most it
+// may generate.
+func (r CompletionRequest) estimatedTokens() int {
+	return len(r.PromptText())/4 + r.MaxTokens
+}
+
+// Conversation returns the request's messages, or its prompt as the single
+// user message.
+func (r CompletionRequest) Conversation() []Message {
+	if len(r.Messages) > 0 {
+		return r.Messages
+	}
+	return []Message{{Role: RoleUser, Content: r.Prompt}}
+}
+
+// PromptText returns the request as one prompt, for providers without chat
+// support: each message on its own line after its role, ending with an
+// assistant line for the reply.
+func (r CompletionRequest) PromptText() string {
+	if len(r.Messages) == 0 {
+		return r.Prompt
+	}
+	var b strings.Builder
+	for _, message := range r.Messages {
+		fmt.Fprintf(&b, "%s: %s\n\n", roleLabel(message.Role), message.Content)
+	}
+	b.WriteString(roleLabel(RoleAssistant) + ":")
+	return b.String()
+}
+
+// roleLabel returns the prefix a role is written with in a plain prompt.
+func roleLabel(role string) string {
+	switch role {
+	case RoleSystem:
+		return "System"
+	case RoleAssistant:
+		return "Assistant"
+	default:
+		return "User"
+	}
 }
 
 // CompletionResponse represents a text completion response.
 type CompletionResponse struct {
 	Text         string                 `json:"text"`
 	TokensUsed   int                    `json:"tokens_used"`
+	InputTokens  int                    `json:"input_tokens"`
+	OutputTokens int                    `json:"output_tokens"`
 	Model        string                 `json:"model"`
 	Provider     string                 `json:"provider"`
 	Cost         float64                `json:"cost"`
@@ -67,6 +439,10 @@ type EmbeddingResponse struct {
 }
 
 // BudgetTracker tracks token usage and costs across providers.
+// The counters cover the current day, which began at StartTime; when a new
+// calendar day begins they are archived to History and start again from zero.
+// Failed attempts the provider still billed for count in the totals like any
+// other usage; FailedTokens and FailedCost break out that wasted share.
 type BudgetTracker struct {
 	TotalTokens int                        `json:"total_tokens"`
 	TotalCost   float64                    `json:"total_cost"`
@@ -74,6 +450,93 @@ type BudgetTracker struct {
 	ByOperation map[string]OperationUsage  `json:"by_operation"`
 	DailyLimit  float64                    `json:"daily_limit"`
 	StartTime   time.Time                  `json:"start_time"`
+
+	FailedTokens int     `json:"failed_tokens"` // Share of TotalTokens billed for failed attempts
+	FailedCost   float64 `json:"failed_cost"`   // Share of TotalCost billed for failed attempts
+
+	Yesterday   *BudgetDay  `json:"yesterday,omitempty"` // Totals of the previous calendar day, once one has passed
+	History     []BudgetDay `json:"history,omitempty"`   // Archived days, oldest first, up to budgetHistoryDays
+	DaysTracked int         `json:"days_tracked"`        // Calendar days since tracking began, including today
+
+	Enforcement  BudgetEnforcement `json:"enforcement,omitempty"`   // How spending past DailyLimit is treated ("": hard)
+	GracePercent float64           `json:"grace_percent,omitempty"` // Grace past DailyLimit for running plans, as a percentage of it
+}
+
+// BudgetDay holds the totals of one archived day of budget tracking.
+type BudgetDay struct {
+	Date        string                    `json:"date"` // YYYY-MM-DD in the budget's time zone
+	TotalTokens int                       `json:"total_tokens"`
+	TotalCost   float64                   `json:"total_cost"`
+	ByProvider  map[string]ProviderUsage  `json:"by_provider"`
+	ByOperation map[string]OperationUsage `json:"by_operation"`
+
+	FailedTokens int     `json:"failed_tokens"`
+	FailedCost   float64 `json:"failed_cost"`
+}
+
+// FailedAttempt is one attempt at a request that failed, whether the request
+// was retried after it or not, with the usage the provider billed for it.
+// Results of provider operations list their failed attempts under the
+// "failed_attempts" metadata key.
+type FailedAttempt struct {
+	Attempt    int     `json:"attempt"` // 1 for the request's first attempt
+	Provider   string  `json:"provider"`
+	Model      string  `json:"model"`
+	TokensUsed int     `json:"tokens_used"`
+	Cost       float64 `json:"cost"`
+	LatencyMs  int64   `json:"latency_ms"`
+	Error      string  `json:"error"`
+}
+
+// budgetHistoryDays is how many archived days a BudgetTracker keeps.
+const budgetHistoryDays = 30
+
+// clone returns a deep copy of the tracker, safe to hand out while requests
+// keep updating the original. Callers hold budgetMu.
+func (b *BudgetTracker) clone() *BudgetTracker {
+	c := *b
+	c.ByProvider = copyProviderUsage(b.ByProvider)
+	c.ByOperation = copyOperationUsage(b.ByOperation)
+	if b.Yesterday != nil {
+		yesterday := b.Yesterday.clone()
+		c.Yesterday = &yesterday
+	}
+	if b.History != nil {
+		c.History = make([]BudgetDay, len(b.History))
+		for i, day := range b.History {
+			c.History[i] = day.clone()
+		}
+	}
+	return &c
+}
+
+// clone returns a copy of the day that shares none of its maps.
+func (d BudgetDay) clone() BudgetDay {
+	d.ByProvider = copyProviderUsage(d.ByProvider)
+	d.ByOperation = copyOperationUsage(d.ByOperation)
+	return d
+}
+
+func copyProviderUsage(usage map[string]ProviderUsage) map[string]ProviderUsage {
+	if usage == nil {
+		return nil
+	}
+	copied := make(map[string]ProviderUsage, len(usage))
+	for name, u := range usage {
+		copied[name] = u
+	}
+	return copied
+}
+
+func copyOperationUsage(usage map[string]OperationUsage) map[string]OperationUsage {
+	if usage == nil {
+		return nil
+	}
+	copied := make(map[string]OperationUsage, len(usage))
+	for name, u := range usage {
+		copied[name] = u
+	}
+	return copied
 }
 
 // ProviderUsage tracks usage for a specific provider.
@@ -90,12 +553,36 @@ type OperationUsage struct {
 	Calls  int     `json:"calls"`
 }
 
-// RetryConfig defines retry behavior for failed requests.
+// RetryConfig defines retry behavior for failed requests. It is translated
+// into a retry.Policy; see Policy.
 type RetryConfig struct {
 	MaxRetries  int           `json:"max_retries"`
 	BaseDelay   time.Duration `json:"base_delay"`
 	MaxDelay    time.Duration `json:"max_delay"`
 	BackoffRate float64       `json:"backoff_rate"`
+
+	// Jitter randomizes each wait between zero and the backed-off delay
+	Jitter bool `json:"jitter"`
+
+	// RespectRetryAfter waits as long as a provider's Retry-After header asks,
+	// up to MaxDelay
+	RespectRetryAfter bool `json:"respect_retry_after"`
+
+	// OnRetry is called before each retry wait, for instrumentation
+	OnRetry func(retry.Event) `json:"-"`
+}
+
+// Policy returns the shared retry policy these settings describe.
+func (c RetryConfig) Policy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts:       c.MaxRetries + 1,
+		BaseDelay:         c.BaseDelay,
+		MaxDelay:          c.MaxDelay,
+		Multiplier:        c.BackoffRate,
+		Jitter:            c.Jitter,
+		RespectRetryAfter: c.RespectRetryAfter,
+		OnRetry:           c.OnRetry,
+	}
 }
 
 // AnthropicProvider implements the Anthropic Claude API.
@@ -104,6 +591,14 @@ type AnthropicProvider struct {
 	BaseURL    string
 	HTTPClient *http.Client
 	Models     map[string]ModelConfig
+
+	// EmbeddingsURL is the base URL of a Voyage-compatible embeddings API,
+	// e.g. https://api.voyageai.com; Anthropic has no embedding models of
+	// its own, so without one the provider cannot embed
+	EmbeddingsURL string
+
+	// EmbeddingsKey is the bearer token EmbeddingsURL takes, if any
+	EmbeddingsKey string
 }
 
 // OpenAIProvider implements the OpenAI API.
@@ -112,6 +607,10 @@ type OpenAIProvider struct {
 	BaseURL    string
 	HTTPClient *http.Client
 	Models     map[string]ModelConfig
+
+	// EmbeddingsURL is the base URL embeddings are requested from
+	// (default: BaseURL)
+	EmbeddingsURL string
 }
 
 // LocalProvider implements local HuggingFace model access.
@@ -119,21 +618,159 @@ type LocalProvider struct {
 	ServerURL  string
 	HTTPClient *http.Client
 	Models     map[string]ModelConfig
+
+	// APIFormat is the API the server speaks: LocalFormatTGWUI or
+	// LocalFormatOpenAI. Discover detects it when unset; a provider never
+	// discovered uses LocalFormatTGWUI.
+	APIFormat LocalAPIFormat
+
+	// EmbeddingsURL is the base URL of the server's OpenAI-compatible
+	// embeddings route (default: ServerURL)
+	EmbeddingsURL string
+
+	// CountTokens counts the tokens of the requests and replies the server
+	// reports no usage for (default: about four characters a token)
+	CountTokens TokenCounter
+}
+
+// TokenCounter counts the tokens text takes for a model.
+type TokenCounter func(model, text string) int
+
+// anthropicAPIVersion is the Anthropic API version requests are written for.
+const anthropicAPIVersion = "2023-06-01"
+
+// AuthScheme adds a provider's credentials to its API requests. Each HTTP
+// provider declares its scheme through an AuthScheme method.
+type AuthScheme interface {
+	Apply(req *http.Request)
+}
+
+// HeaderAuth sends an API key in a header of its own, as Anthropic's x-api-key.
+type HeaderAuth struct {
+	Header string
+	Key    string
+}
+
+// Apply sets the key header.
+func (a HeaderAuth) Apply(req *http.Request) {
+	req.Header.Set(a.Header, a.Key)
+}
+
+// BearerAuth sends an API key as a bearer token, as OpenAI expects.
+type BearerAuth struct {
+	Token string
 }
 
+// Apply sets the Authorization header.
+func (a BearerAuth) Apply(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+}
+
+// NoAuth sends no credentials, for servers that need none.
+type NoAuth struct{}
+
+// Apply leaves the request unchanged.
+func (NoAuth) Apply(req *http.Request) {}
+
 // ModelConfig contains configuration for a specific model.
 type ModelConfig struct {
 	Name         string  `json:"name"`
-	InputCost    float64 `json:"input_cost"`    // Cost per 1K tokens
-	OutputCost   float64 `json:"output_cost"`   // Cost per 1K tokens
+	InputCost    float64 `json:"input_cost"`    // Cost per 1M tokens
+	OutputCost   float64 `json:"output_cost"`   // Cost per 1M tokens
 	MaxTokens    int     `json:"max_tokens"`
 	ContextSize  int     `json:"context_size"`
 	SupportsChat bool    `json:"supports_chat"`
 	SupportsEmbed bool   `json:"supports_embed"`
+	Dimensions   int     `json:"dimensions,omitempty"`   // Embedding length; 0 if unknown
+	QualityTier  string  `json:"quality_tier,omitempty"` // "basic", "standard" or "premium"
+	SpeedTier    int     `json:"speed_tier,omitempty"`   // 1=fastest, 3=slowest
+}
+
+// Cost returns the cost of a request to the model: input and output tokens
+// each at their own price per 1M tokens.
+func (mc ModelConfig) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*mc.InputCost + float64(outputTokens)*mc.OutputCost) / 1000000.0
+}
+
+// findModelConfig returns the configuration of model, given either the key
+// used in requests or the provider's own model name.
+func findModelConfig(models map[string]ModelConfig, model string) (ModelConfig, bool) {
+	if config, exists := models[model]; exists {
+		return config, true
+	}
+	for _, config := range models {
+		if config.Name == model {
+			return config, true
+		}
+	}
+	return ModelConfig{}, false
+}
+
+// ModelCatalog is implemented by providers that can describe their models.
+// The list_models operation reports the models of every provider implementing it.
+type ModelCatalog interface {
+	ListModels() map[string]ModelConfig
+}
+
+// ModelListing describes one model as reported by the list_models operation.
+type ModelListing struct {
+	Provider      string  `json:"provider"`
+	Model         string  `json:"model"` // Key used to request the model
+	InputCost     float64 `json:"input_cost"`
+	OutputCost    float64 `json:"output_cost"`
+	MaxTokens     int     `json:"max_tokens"`
+	ContextSize   int     `json:"context_size"`
+	SupportsChat  bool    `json:"supports_chat"`
+	SupportsEmbed bool    `json:"supports_embed"`
+	Dimensions    int     `json:"dimensions,omitempty"`
+	QualityTier   string  `json:"quality_tier,omitempty"`
+	SpeedTier     int     `json:"speed_tier,omitempty"`
 }
 
-// NewLLMService creates a new LLM MCP service.
+// NewLLMService creates a new LLM MCP service, with the API keys set in
+// the environment.
 func NewLLMService(logger *log.Logger) *LLMService {
+	return NewLLMServiceWithCredentialsProvider(logger, NewCredentials(nil, nil))
+}
+
+// NewLLMServiceWithCredentials creates an LLM service that also uses the given
+// API keys, keyed by provider name ("anthropic", "openai"). They take
+// precedence over keys in the environment, as the configuration file does.
+func NewLLMServiceWithCredentials(logger *log.Logger, apiKeys map[string]string) *LLMService {
+	if apiKeys == nil {
+		apiKeys = map[string]string{}
+	}
+	return NewLLMServiceWithCredentialsProvider(logger, NewCredentials(nil, apiKeys))
+}
+
+// NewLLMServiceWithCredentialsProvider creates an LLM service whose API keys
+// come from credentials, which is asked again whenever the service reloads
+// its credentials.
+func NewLLMServiceWithCredentialsProvider(logger *log.Logger, credentials CredentialsProvider) *LLMService {
+	service := newLLMService(logger)
+	service.credentials = credentials
+	service.initializeProviders()
+	return service
+}
+
+// NewLLMServiceWithProviders creates an LLM service that uses only the given
+// providers, keyed by the provider name used in requests. Credentials in the
+// environment are ignored, so no real provider can be reached.
+func NewLLMServiceWithProviders(logger *log.Logger, providers map[string]LLMProvider) *LLMService {
+	service := newLLMService(logger)
+	for name, provider := range providers {
+		service.providers[name] = provider
+		service.availability = append(service.availability, ProviderStatus{
+			Provider:  name,
+			Available: true,
+			Source:    "given to the service",
+		})
+	}
+	return service
+}
+
+// newLLMService creates an LLM service with default settings and no providers.
+func newLLMService(logger *log.Logger) *LLMService {
 	base := NewBaseService(
 		"llm",
 		"Language model access with multiple providers, budget tracking, and error handling",
@@ -148,100 +785,47 @@ func NewLLMService(logger *log.Logger) *LLMService {
 			ByOperation: make(map[string]OperationUsage),
 			DailyLimit:  100.0, // $100 daily limit by default
 			StartTime:   time.Now(),
+			DaysTracked: 1,
 		},
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		httpTimeout: 30 * time.Second,
 		retryConfig: RetryConfig{
-			MaxRetries:  3,
-			BaseDelay:   1 * time.Second,
-			MaxDelay:    10 * time.Second,
-			BackoffRate: 2.0,
+			MaxRetries:        3,
+			BaseDelay:         1 * time.Second,
+			MaxDelay:          10 * time.Second,
+			BackoffRate:       2.0,
+			Jitter:            true,
+			RespectRetryAfter: true,
 		},
+		clock:          utils.RealClock(),
+		budgetLocation: time.Local,
+		limiters:       make(map[string]*providerLimiter),
+		models:         DefaultModelDefinitions(),
 	}
 
-	// Initialize providers based on available credentials
-	service.initializeProviders()
-
 	return service
 }
 
-// initializeProviders sets up available LLM providers based on environment variables.
+// initializeProviders sets up the providers with API keys the service's
+// credentials resolve, and the local provider when LOCAL_LLM_URL is set.
 func (llm *LLMService) initializeProviders() {
-	// Anthropic Claude API
-	if apiKey := os.Getenv("ANTHROPIC_API_KEY"); apiKey != "" {
-		anthropic := &AnthropicProvider{
-			APIKey:     apiKey,
-			BaseURL:    "https://api.anthropic.com",
-			HTTPClient: llm.httpClient,
-			Models: map[string]ModelConfig{
-				"claude-3-sonnet": {
-					Name:         "claude-3-sonnet-20240229",
-					InputCost:    3.0,   // $3 per 1M tokens
-					OutputCost:   15.0,  // $15 per 1M tokens
-					MaxTokens:    4096,
-					ContextSize:  200000,
-					SupportsChat: true,
-					SupportsEmbed: false,
-				},
-				"claude-3-haiku": {
-					Name:         "claude-3-haiku-20240307",
-					InputCost:    0.25,  // $0.25 per 1M tokens
-					OutputCost:   1.25,  // $1.25 per 1M tokens
-					MaxTokens:    4096,
-					ContextSize:  200000,
-					SupportsChat: true,
-					SupportsEmbed: false,
-				},
-			},
+	providers := make(map[string]LLMProvider)
+	var statuses []ProviderStatus
+	definitions := llm.modelDefinitions()
+	for _, name := range keyedProviders {
+		credential := llm.credentials.Resolve(name)
+		if credential.Key != "" {
+			providers[name] = llm.newKeyedProvider(name, credential.Key, definitions)
 		}
-		llm.providers["anthropic"] = anthropic
-	}
-
-	// OpenAI API
-	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
-		openai := &OpenAIProvider{
-			APIKey:     apiKey,
-			BaseURL:    "https://api.openai.com",
-			HTTPClient: llm.httpClient,
-			Models: map[string]ModelConfig{
-				"gpt-4": {
-					Name:         "gpt-4",
-					InputCost:    30.0,  // $30 per 1M tokens
-					OutputCost:   60.0,  // $60 per 1M tokens
-					MaxTokens:    4096,
-					ContextSize:  8192,
-					SupportsChat: true,
-					SupportsEmbed: false,
-				},
-				"gpt-3.5-turbo": {
-					Name:         "gpt-3.5-turbo",
-					InputCost:    0.5,   // $0.5 per 1M tokens
-					OutputCost:   1.5,   // $1.5 per 1M tokens
-					MaxTokens:    4096,
-					ContextSize:  16385,
-					SupportsChat: true,
-					SupportsEmbed: false,
-				},
-				"text-embedding-ada-002": {
-					Name:         "text-embedding-ada-002",
-					InputCost:    0.1,   // $0.1 per 1M tokens
-					OutputCost:   0.0,   // No output cost for embeddings
-					MaxTokens:    0,     // Not applicable
-					ContextSize:  8191,
-					SupportsChat: false,
-					SupportsEmbed: true,
-				},
-			},
-		}
-		llm.providers["openai"] = openai
+		statuses = append(statuses, credentialStatus(name, credential))
 	}
 
 	// Local HuggingFace models
+	localStatus := ProviderStatus{Provider: "local", Checked: []string{"LOCAL_LLM_URL"}, Reason: "LOCAL_LLM_URL is not set"}
 	if serverURL := os.Getenv("LOCAL_LLM_URL"); serverURL != "" {
 		local := &LocalProvider{
 			ServerURL:  serverURL,
-			HTTPClient: llm.httpClient,
+			HTTPClient: netaudit.NewClient("provider:local", llm.httpTimeout),
+			APIFormat:  LocalAPIFormat(os.Getenv("LOCAL_LLM_FORMAT")),
 			Models: map[string]ModelConfig{
 				"local-llama": {
 					Name:         "llama-2-7b-chat",
@@ -251,13 +835,292 @@ func (llm *LLMService) initializeProviders() {
 					ContextSize:  4096,
 					SupportsChat: true,
 					SupportsEmbed: false,
+					QualityTier:  "basic",
+					SpeedTier:    2,
 				},
 			},
 		}
-		llm.providers["local"] = local
+		// The server's embedding model, named as the server knows it
+		if embedModel := os.Getenv("LOCAL_EMBED_MODEL"); embedModel != "" {
+			local.EmbeddingsURL = os.Getenv("LOCAL_EMBEDDINGS_URL")
+			local.Models["local-embed"] = ModelConfig{
+				Name:          embedModel,
+				ContextSize:   4096,
+				SupportsEmbed: true,
+			}
+		}
+		// Learn the server's API and models; a server that is down keeps
+		// the configured format, or the text-generation-webui one
+		ctx, cancel := context.WithTimeout(context.Background(), localDiscoveryTimeout)
+		localStatus = ProviderStatus{Provider: "local", Available: true, Source: "LOCAL_LLM_URL", Checked: localStatus.Checked}
+		if err := local.Discover(ctx); err != nil {
+			llm.logger.Printf("Local LLM server at %s did not list its models: %v", serverURL, err)
+			localStatus.Reason = fmt.Sprintf("server at %s is unreachable or did not list its models (%v); requests will still be sent to it", serverURL, err)
+		}
+		cancel()
+		providers["local"] = local
+	}
+	statuses = append(statuses, localStatus)
+
+	llm.providersMu.Lock()
+	defer llm.providersMu.Unlock()
+	llm.providers = providers
+	for _, status := range statuses {
+		llm.setAvailability(status)
+	}
+}
+
+// keyedProviders are the providers that authenticate with an API key.
+var keyedProviders = []string{"anthropic", "openai"}
+
+// newKeyedProvider creates the Anthropic or OpenAI provider with apiKey,
+// offering the models of definitions.
+func (llm *LLMService) newKeyedProvider(name, apiKey string, definitions ModelDefinitions) LLMProvider {
+	if name == "openai" {
+		return &OpenAIProvider{
+			APIKey:        apiKey,
+			BaseURL:       "https://api.openai.com",
+			HTTPClient:    netaudit.NewClient("provider:openai", llm.httpTimeout),
+			Models:        definitions.Models("openai"),
+			EmbeddingsURL: os.Getenv("OPENAI_EMBEDDINGS_URL"),
+		}
+	}
+
+	anthropic := &AnthropicProvider{
+		APIKey:     apiKey,
+		BaseURL:    "https://api.anthropic.com",
+		HTTPClient: netaudit.NewClient("provider:anthropic", llm.httpTimeout),
+	}
+	// Embeddings come from a Voyage-compatible endpoint, when one is set
+	if embeddingsURL := os.Getenv("ANTHROPIC_EMBEDDINGS_URL"); embeddingsURL != "" {
+		anthropic.EmbeddingsURL = embeddingsURL
+		anthropic.EmbeddingsKey = os.Getenv("VOYAGE_API_KEY")
+	}
+	anthropic.Models = anthropicModels(definitions, anthropic.EmbeddingsURL != "")
+	return anthropic
+}
+
+// ReloadCredentials resolves the API keys again and replaces the Anthropic
+// and OpenAI providers whose key changed, adding those that now have one and
+// removing those that no longer do. Requests already sent finish with the
+// provider they started with. Providers given to the service or set up
+// without a key are left as they are. It returns the providers' statuses.
+func (llm *LLMService) ReloadCredentials() *ProviderDiagnosis {
+	if llm.credentials != nil {
+		// A keyring can be slow to answer, so the keys are resolved before
+		// requests are held up on the provider lock
+		credentials := make(map[string]Credential, len(keyedProviders))
+		for _, name := range keyedProviders {
+			credentials[name] = llm.credentials.Resolve(name)
+		}
+		llm.updateProviders(func(providers map[string]LLMProvider) {
+			for _, name := range keyedProviders {
+				credential := credentials[name]
+				switch {
+				case credential.Key == "":
+					delete(providers, name)
+				case providerAPIKey(providers[name]) != credential.Key:
+					providers[name] = llm.newKeyedProvider(name, credential.Key, llm.models)
+				}
+				llm.setAvailability(credentialStatus(name, credential))
+			}
+		})
+	}
+	return llm.Diagnose()
+}
+
+// withoutKeys wraps an operation so that no API key the service holds
+// appears in the errors it returns, such as a provider's error echoing the
+// key it was sent. Errors are checked before they are audited or logged.
+func (llm *LLMService) withoutKeys(operation func(context.Context, ServiceParams) ServiceResult) func(context.Context, ServiceParams) ServiceResult {
+	return func(ctx context.Context, params ServiceParams) ServiceResult {
+		// Keys replaced by a reload during the call are still redacted
+		before := llm.providerSet()
+		result := operation(ctx, params)
+		if result.Error != nil {
+			result.Error = redactKeys(result.Error, before, llm.providerSet())
+		}
+		return result
+	}
+}
+
+// redactKeys returns err with the API keys of every provider in the sets
+// replaced in its message; errors.Is and errors.As still see the original.
+func redactKeys(err error, sets ...map[string]LLMProvider) error {
+	message := err.Error()
+	redacted := message
+	for _, providers := range sets {
+		for _, provider := range providers {
+			for _, key := range providerSecrets(provider) {
+				if len(key) >= minRedactedKeyLength {
+					redacted = strings.ReplaceAll(redacted, key, "[REDACTED]")
+				}
+			}
+		}
+	}
+	if redacted == message {
+		return err
+	}
+	return &redactedError{message: redacted, err: err}
+}
+
+// minRedactedKeyLength keeps redaction from rewriting common words when a
+// test or misconfiguration sets a key a few characters long.
+const minRedactedKeyLength = 8
+
+// providerSecrets returns the keys a provider authenticates with.
+func providerSecrets(provider LLMProvider) []string {
+	switch p := provider.(type) {
+	case *AnthropicProvider:
+		return []string{p.APIKey, p.EmbeddingsKey}
+	case *OpenAIProvider:
+		return []string{p.APIKey}
+	default:
+		return nil
 	}
 }
 
+// redactedError is an error whose message had API keys removed.
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string { return e.message }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// providerAPIKey returns the API key a provider authenticates with, if any.
+func providerAPIKey(provider LLMProvider) string {
+	switch p := provider.(type) {
+	case *AnthropicProvider:
+		return p.APIKey
+	case *OpenAIProvider:
+		return p.APIKey
+	default:
+		return ""
+	}
+}
+
+// providerSet returns the service's providers by name. The map is never
+// changed once returned; replacing a provider replaces the map.
+func (llm *LLMService) providerSet() map[string]LLMProvider {
+	llm.providersMu.RLock()
+	defer llm.providersMu.RUnlock()
+	return llm.providers
+}
+
+// setProvider adds or replaces a provider, and records its status if given.
+func (llm *LLMService) setProvider(name string, provider LLMProvider, status *ProviderStatus) {
+	llm.updateProviders(func(providers map[string]LLMProvider) {
+		providers[name] = provider
+		if status != nil {
+			llm.setAvailability(*status)
+		}
+	})
+}
+
+// updateProviders replaces the service's providers with a copy changed by
+// update, under providersMu. Providers are never changed in place: update
+// replaces one with a changed copy, so requests holding it are unaffected.
+func (llm *LLMService) updateProviders(update func(providers map[string]LLMProvider)) {
+	llm.providersMu.Lock()
+	defer llm.providersMu.Unlock()
+	providers := make(map[string]LLMProvider, len(llm.providers)+1)
+	for name, provider := range llm.providers {
+		providers[name] = provider
+	}
+	update(providers)
+	llm.providers = providers
+}
+
+// voyageEmbedModel is the embedding model the Anthropic provider offers
+// when it has a Voyage-compatible embeddings endpoint.
+var voyageEmbedModel = ModelConfig{
+	Name:          "voyage-3",
+	InputCost:     0.06, // $0.06 per 1M tokens
+	ContextSize:   32000,
+	SupportsEmbed: true,
+	Dimensions:    1024,
+}
+
+// anthropicModels returns the Anthropic models of definitions, with the
+// Voyage embedding model when the provider can embed.
+func anthropicModels(definitions ModelDefinitions, embeddings bool) map[string]ModelConfig {
+	models := definitions.Models("anthropic")
+	if _, exists := models["voyage-3"]; embeddings && !exists {
+		models["voyage-3"] = voyageEmbedModel
+	}
+	return models
+}
+
+// SetModelDefinitions replaces the models the Anthropic and OpenAI providers
+// offer, those added later by SetAPIKey included. The providers are replaced
+// with copies offering them; requests already sent finish with the old ones.
+func (llm *LLMService) SetModelDefinitions(definitions ModelDefinitions) {
+	llm.updateProviders(func(providers map[string]LLMProvider) {
+		llm.models = definitions
+		if anthropic, ok := providers["anthropic"].(*AnthropicProvider); ok {
+			updated := *anthropic
+			updated.Models = anthropicModels(definitions, anthropic.EmbeddingsURL != "")
+			providers["anthropic"] = &updated
+		}
+		if openai, ok := providers["openai"].(*OpenAIProvider); ok {
+			updated := *openai
+			updated.Models = definitions.Models("openai")
+			providers["openai"] = &updated
+		}
+	})
+}
+
+// modelDefinitions returns the models the Anthropic and OpenAI providers
+// are set up to offer.
+func (llm *LLMService) modelDefinitions() ModelDefinitions {
+	llm.providersMu.RLock()
+	defer llm.providersMu.RUnlock()
+	return llm.models
+}
+
+// LoadModelCatalog offers the built-in models with those of the catalog
+// file at path over them; see LoadModelDefinitions. A file that cannot be
+// loaded leaves the models as they were.
+func (llm *LLMService) LoadModelCatalog(path string) error {
+	definitions, err := LoadModelDefinitions(path)
+	if err != nil {
+		return err
+	}
+	llm.SetModelDefinitions(definitions)
+	return nil
+}
+
+// SetAPIKey replaces the API key of the Anthropic or OpenAI provider, adding
+// the provider if it had no key, so a renewed key takes effect without a
+// restart. Requests already sent finish with the key they started with.
+func (llm *LLMService) SetAPIKey(provider, apiKey string) error {
+	var err error
+	llm.updateProviders(func(providers map[string]LLMProvider) {
+		switch existing := providers[provider].(type) {
+		case *AnthropicProvider:
+			updated := *existing
+			updated.APIKey = apiKey
+			providers[provider] = &updated
+		case *OpenAIProvider:
+			updated := *existing
+			updated.APIKey = apiKey
+			providers[provider] = &updated
+		case nil:
+			if provider != "anthropic" && provider != "openai" {
+				err = fmt.Errorf("provider '%s' does not use an API key", provider)
+				return
+			}
+			providers[provider] = llm.newKeyedProvider(provider, apiKey, llm.models)
+			llm.setAvailability(credentialStatus(provider, staticCredentials{provider, apiKey}.Resolve(provider)))
+		default:
+			err = fmt.Errorf("provider '%s' does not use an API key", provider)
+		}
+	})
+	return err
+}
+
 // ValidateParams validates parameters for LLM operations.
 func (llm *LLMService) ValidateParams(params ServiceParams) error {
 	if err := llm.BaseService.ValidateParams(params); err != nil {
@@ -282,10 +1145,25 @@ func (llm *LLMService) ValidateParams(params ServiceParams) error {
 		return llm.validateEmbedParams(params)
 	case "list_providers":
 		return nil // No additional parameters needed
+	case "list_models":
+		return nil // No additional parameters needed
+	case "diagnose":
+		return nil // No additional parameters needed
+	case "reload_credentials":
+		return nil // No additional parameters needed
+	case "refresh_models":
+		return llm.validateRefreshParams(params)
 	case "get_budget":
 		return nil // No additional parameters needed
 	case "reset_budget":
 		return nil // No additional parameters needed
+	case "get_limits":
+		return nil // No additional parameters needed
+	case "get_audit":
+		_, err := auditFilterParams(params)
+		return err
+	case "cache_stats":
+		return nil // No additional parameters needed
 	default:
 		return NewValidationError("operation", fmt.Sprintf("unsupported operation: %s", operationStr))
 	}
@@ -293,8 +1171,19 @@ func (llm *LLMService) ValidateParams(params ServiceParams) error {
 
 // validateCompleteParams validates parameters for complete operation.
 func (llm *LLMService) validateCompleteParams(params ServiceParams) error {
-	if err := ValidateStringParam(params, "prompt", true); err != nil {
-		return err
+	_, hasPrompt := params["prompt"]
+	_, hasMessages := params["messages"]
+	switch {
+	case hasPrompt && hasMessages:
+		return NewValidationError("messages", "give either prompt or messages, not both")
+	case hasMessages:
+		if _, err := messagesParam(params); err != nil {
+			return err
+		}
+	default:
+		if err := ValidateStringParam(params, "prompt", true); err != nil {
+			return err
+		}
 	}
 
 	if err := ValidateStringParam(params, "provider", false); err != nil {
@@ -308,7 +1197,7 @@ func (llm *LLMService) validateCompleteParams(params ServiceParams) error {
 	// Validate provider exists if specified
 	if providerName, exists := params["provider"]; exists {
 		providerStr := providerName.(string)
-		if _, exists := llm.providers[providerStr]; !exists {
+		if _, exists := llm.providerSet()[providerStr]; !exists {
 			return NewValidationError("provider", "specified provider '"+providerStr+"' is not available")
 		}
 	}
@@ -319,6 +1208,35 @@ func (llm *LLMService) validateCompleteParams(params ServiceParams) error {
 	if err := ValidateIntParam(params, "max_tokens", false, &minTokens, &maxTokens); err != nil {
 		return err
 	}
+	if err := ValidateIntParam(params, "hard_max_tokens", false, &minTokens, &maxTokens); err != nil {
+		return err
+	}
+
+	// Per-request deadline, in seconds
+	if _, exists := params["timeout_seconds"]; exists {
+		timeout, ok := timeoutParam(params)
+		if !ok {
+			return NewValidationError("timeout_seconds", "timeout_seconds must be a number")
+		}
+		if timeout <= 0 || timeout > maxRequestTimeout {
+			return NewValidationError("timeout_seconds", fmt.Sprintf("timeout_seconds must be greater than 0 and at most %.0f", maxRequestTimeout.Seconds()))
+		}
+	}
+	for _, name := range []string{"retry_on_timeout", "no_cache"} {
+		if value, exists := params[name]; exists {
+			if _, ok := value.(bool); !ok {
+				return NewValidationError(name, name+" must be a boolean")
+			}
+		}
+	}
+	if _, err := responseSchemaParam(params); err != nil {
+		return err
+	}
+	if metadata, exists := params["metadata"]; exists {
+		if _, ok := metadata.(map[string]interface{}); !ok {
+			return NewValidationError("metadata", "metadata must be an object")
+		}
+	}
 
 	// Temperature validation (0.0 to 2.0)
 	if temp, exists := params["temperature"]; exists {
@@ -351,7 +1269,7 @@ func (llm *LLMService) validateEmbedParams(params ServiceParams) error {
 	// Validate provider exists if specified
 	if providerName, exists := params["provider"]; exists {
 		providerStr := providerName.(string)
-		if _, exists := llm.providers[providerStr]; !exists {
+		if _, exists := llm.providerSet()[providerStr]; !exists {
 			return NewValidationError("provider", "specified provider '"+providerStr+"' is not available")
 		}
 	}
@@ -359,29 +1277,131 @@ func (llm *LLMService) validateEmbedParams(params ServiceParams) error {
 	return nil
 }
 
+// validateRefreshParams validates parameters for refresh_models operation.
+func (llm *LLMService) validateRefreshParams(params ServiceParams) error {
+	if err := ValidateStringParam(params, "provider", false); err != nil {
+		return err
+	}
+	if providerName, exists := params["provider"]; exists {
+		providerStr := providerName.(string)
+		if _, ok := llm.providerSet()[providerStr].(ModelRefresher); !ok {
+			return NewValidationError("provider", "provider '"+providerStr+"' cannot refresh its models")
+		}
+	}
+	return nil
+}
+
 // Execute performs the requested LLM operation.
 func (llm *LLMService) Execute(ctx context.Context, params ServiceParams) ServiceResult {
 	operation := params["operation"].(string)
 
 	switch operation {
 	case "complete":
-		return llm.complete(ctx, params)
+		return llm.audited(ctx, params, llm.withoutKeys(llm.complete))
 	case "embed":
-		return llm.embed(ctx, params)
+		return llm.audited(ctx, params, llm.withoutKeys(llm.embed))
 	case "list_providers":
 		return llm.listProviders(ctx, params)
+	case "list_models":
+		return llm.listModels(ctx, params)
+	case "diagnose":
+		return SuccessResult(llm.Diagnose())
+	case "reload_credentials":
+		return SuccessResult(llm.ReloadCredentials())
+	case "refresh_models":
+		return llm.refreshModels(ctx, params)
 	case "get_budget":
 		return llm.getBudget(ctx, params)
 	case "reset_budget":
 		return llm.resetBudget(ctx, params)
+	case "get_limits":
+		return llm.getLimits(ctx, params)
+	case "get_audit":
+		return llm.getAudit(ctx, params)
+	case "cache_stats":
+		return llm.cacheStats(ctx, params)
+	default:
+		return ErrorResult(errs.Newf(errs.Validation, "unsupported operation: %s", operation))
+	}
+}
+
+// maxRequestTimeout is the longest timeout_seconds a completion may ask for.
+const maxRequestTimeout = 10 * time.Minute
+
+// timeoutParam reads the "timeout_seconds" parameter, given as an integer
+// or a fraction of seconds.
+func timeoutParam(params ServiceParams) (time.Duration, bool) {
+	switch value := params["timeout_seconds"].(type) {
+	case int:
+		return time.Duration(value) * time.Second, true
+	case float64:
+		return time.Duration(value * float64(time.Second)), true
 	default:
-		return ErrorResult(fmt.Errorf("unsupported operation: %s", operation))
+		return 0, false
 	}
 }
 
+// messagesParam reads the "messages" parameter: a non-empty list of
+// messages, as []Message or as decoded JSON objects with a role and content.
+func messagesParam(params ServiceParams) ([]Message, error) {
+	var messages []Message
+	switch value := params["messages"].(type) {
+	case []Message:
+		messages = value
+	case []map[string]interface{}:
+		for _, entry := range value {
+			messages = append(messages, messageFromMap(entry))
+		}
+	case []interface{}:
+		for _, item := range value {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, NewValidationError("messages", "each message must be an object with a role and content")
+			}
+			messages = append(messages, messageFromMap(entry))
+		}
+	default:
+		return nil, NewValidationError("messages", "messages must be a list of messages")
+	}
+
+	if len(messages) == 0 {
+		return nil, NewValidationError("messages", "messages cannot be empty")
+	}
+	for i, message := range messages {
+		switch message.Role {
+		case RoleSystem, RoleUser, RoleAssistant:
+		default:
+			return nil, NewValidationError("messages", fmt.Sprintf("message %d has unknown role %q", i, message.Role))
+		}
+		if strings.TrimSpace(message.Content) == "" {
+			return nil, NewValidationError("messages", fmt.Sprintf("message %d has no content", i))
+		}
+	}
+	return messages, nil
+}
+
+func messageFromMap(entry map[string]interface{}) Message {
+	role, _ := entry["role"].(string)
+	content, _ := entry["content"].(string)
+	return Message{Role: role, Content: content}
+}
+
 // complete performs text completion with automatic provider selection.
 func (llm *LLMService) complete(ctx context.Context, params ServiceParams) ServiceResult {
-	prompt := params["prompt"].(string)
+	var request CompletionRequest
+	if _, exists := params["messages"]; exists {
+		messages, err := messagesParam(params)
+		if err != nil {
+			return ErrorResult(err)
+		}
+		request.Messages = messages
+	} else {
+		prompt, ok := params["prompt"].(string)
+		if !ok {
+			return ErrorResult(NewValidationError("prompt", "required parameter is missing"))
+		}
+		request.Prompt = prompt
+	}
 
 	// Select provider and model
 	providerName, modelName, err := llm.selectProvider(params, "complete")
@@ -389,22 +1409,36 @@ func (llm *LLMService) complete(ctx context.Context, params ServiceParams) Servi
 		return ErrorResult(fmt.Errorf("provider selection failed: %w", err))
 	}
 
-	provider, exists := llm.providers[providerName]
+	provider, exists := llm.providerSet()[providerName]
 	if !exists {
-		return ErrorResult(fmt.Errorf("provider '%s' not available", providerName))
+		return ErrorResult(errs.Newf(errs.ProviderUnavailable, "provider '%s' not available", providerName))
 	}
 
-	// Build completion request
-	request := CompletionRequest{
-		Model:  modelName,
-		Prompt: prompt,
-	}
+	request.Model = modelName
+	uncataloged := llm.warnUncataloged(providerName, modelName)
 
 	// Set optional parameters
 	if maxTokens, exists := params["max_tokens"]; exists {
 		request.MaxTokens = maxTokens.(int)
 	}
 
+	// A hard cap holds whatever else asked for more tokens
+	if hardMax, exists := params["hard_max_tokens"]; exists {
+		var limit int
+		switch value := hardMax.(type) {
+		case int:
+			limit = value
+		case float64:
+			limit = int(value)
+		}
+		if request.MaxTokens == 0 || request.MaxTokens > limit {
+			request.MaxTokens = limit
+		}
+	}
+	request.Timeout, _ = timeoutParam(params)
+	retryTimeouts, _ := params["retry_on_timeout"].(bool)
+	request.ResponseSchema, _ = responseSchemaParam(params)
+
 	if temperature, exists := params["temperature"]; exists {
 		request.Temperature = temperature.(float64)
 	}
@@ -418,26 +1452,134 @@ func (llm *LLMService) complete(ctx context.Context, params ServiceParams) Servi
 		}
 	}
 
+	// A request made before is answered from the cache, free of charge
+	cache, cacheKey := llm.cacheFor(params, providerName, request)
+	if cache != nil {
+		if cached, ok := cache.Get(cacheKey); ok {
+			llm.updateBudget(providerName, "cache_hit", 0, 0)
+			return SuccessResult(cachedCompletion(cached))
+		}
+	}
+
 	// Check budget before making request
-	if err := llm.checkBudget(); err != nil {
+	planID := requestPlanID(params)
+	if err := llm.checkBudget(planID); err != nil {
 		return ErrorResult(fmt.Errorf("budget check failed: %w", err))
 	}
 
-	// Execute with retries
-	response, err := llm.executeWithRetry(ctx, func() (interface{}, error) {
-		return provider.Complete(ctx, request)
+	// Execute with retries, each attempt within the provider's limits
+	started := time.Now()
+	response, failed, err := llm.executeWithRetry(ctx, providerName, modelName, "complete", retryTimeouts, func() (interface{}, error) {
+		return llm.throttled(ctx, providerName, request.estimatedTokens(), func() (interface{}, error) {
+			return provider.Complete(ctx, request)
+		})
 	})
 
+	latency := time.Since(started)
 	if err != nil {
-		return ErrorResult(fmt.Errorf("completion failed: %w", err))
+		metrics.LLMRequests.Inc(providerName, modelName, string(errs.CodeOf(err)))
+		metrics.LLMLatency.Observe(latency.Seconds(), providerName, modelName)
+		result := withFailedAttempts(ErrorResult(fmt.Errorf("completion failed: %w", err)), failed)
+		// A timed-out request still took time, which callers record
+		if errors.Is(err, ErrTimeout) {
+			result.Metadata["latency_ms"] = latency.Milliseconds()
+		}
+		return result
 	}
 
 	completionResp := response.(*CompletionResponse)
+	if uncataloged {
+		if completionResp.Metadata == nil {
+			completionResp.Metadata = make(map[string]interface{})
+		}
+		completionResp.Metadata["uncataloged_model"] = true
+	}
 
 	// Update budget tracking
 	llm.updateBudget(providerName, "complete", completionResp.TokensUsed, completionResp.Cost)
+	recordCompletionMetrics(providerName, modelName, completionResp, latency)
+
+	result := SuccessResult(completionResp)
+	if request.ResponseSchema != nil {
+		result = llm.checkStructured(ctx, provider, providerName, request, retryTimeouts, planID, completionResp)
+	}
+	if cache != nil && result.Error == nil {
+		cache.Put(cacheKey, result.Data.(*CompletionResponse))
+	}
+	return withFailedAttempts(result, failed)
+}
 
-	return SuccessResult(completionResp)
+// recordCompletionMetrics counts a successful completion's tokens and cost
+// and its latency, retries included.
+func recordCompletionMetrics(provider, model string, response *CompletionResponse, latency time.Duration) {
+	metrics.LLMRequests.Inc(provider, model, "success")
+	metrics.LLMTokens.Add(float64(response.InputTokens), provider, model, "input")
+	metrics.LLMTokens.Add(float64(response.OutputTokens), provider, model, "output")
+	metrics.LLMCost.Add(response.Cost, provider, model)
+	metrics.LLMLatency.Observe(latency.Seconds(), provider, model)
+}
+
+// withFailedAttempts adds the failed attempts to the result's metadata,
+// ahead of any it already lists.
+func withFailedAttempts(result ServiceResult, failed []FailedAttempt) ServiceResult {
+	if len(failed) == 0 {
+		return result
+	}
+	if listed, ok := result.Metadata["failed_attempts"].([]FailedAttempt); ok {
+		failed = append(append([]FailedAttempt{}, failed...), listed...)
+	}
+	result.Metadata["failed_attempts"] = failed
+	return result
+}
+
+// checkStructured returns a reply that asked for JSON once it matches the
+// request's schema. A reply that does not is sent back once with the
+// problem; the result then covers the usage of both attempts.
+func (llm *LLMService) checkStructured(ctx context.Context, provider LLMProvider, providerName string, request CompletionRequest, retryTimeouts bool, planID string, first *CompletionResponse) ServiceResult {
+	text, _, err := request.ResponseSchema.Parse(first.Text)
+	if err == nil {
+		first.Text = text
+		return SuccessResult(first)
+	}
+
+	repair := request
+	repair.Prompt = ""
+	repair.Messages = append(append([]Message{}, request.Conversation()...),
+		Message{Role: RoleAssistant, Content: first.Text},
+		Message{Role: RoleUser, Content: request.ResponseSchema.repairPrompt(err)})
+
+	if err := llm.checkBudget(planID); err != nil {
+		return ErrorResult(fmt.Errorf("budget check failed: %w", err))
+	}
+	response, failed, err := llm.executeWithRetry(ctx, providerName, repair.Model, "complete", retryTimeouts, func() (interface{}, error) {
+		return llm.throttled(ctx, providerName, repair.estimatedTokens(), func() (interface{}, error) {
+			return provider.Complete(ctx, repair)
+		})
+	})
+	if err != nil {
+		return withFailedAttempts(ErrorResult(fmt.Errorf("completion repair failed: %w", err)), failed)
+	}
+	second := response.(*CompletionResponse)
+	llm.updateBudget(providerName, "complete", second.TokensUsed, second.Cost)
+
+	second.TokensUsed += first.TokensUsed
+	second.InputTokens += first.InputTokens
+	second.OutputTokens += first.OutputTokens
+	second.Cost += first.Cost
+	if second.Metadata == nil {
+		second.Metadata = make(map[string]interface{})
+	}
+	second.Metadata["repaired"] = true
+
+	text, _, err = request.ResponseSchema.Parse(second.Text)
+	if err != nil {
+		result := ErrorResult(fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+		result.Metadata["tokens_used"] = second.TokensUsed
+		result.Metadata["cost"] = second.Cost
+		return withFailedAttempts(result, failed)
+	}
+	second.Text = text
+	return withFailedAttempts(SuccessResult(second), failed)
 }
 
 // embed performs text embedding.
@@ -450,9 +1592,9 @@ func (llm *LLMService) embed(ctx context.Context, params ServiceParams) ServiceR
 		return ErrorResult(fmt.Errorf("provider selection failed: %w", err))
 	}
 
-	provider, exists := llm.providers[providerName]
+	provider, exists := llm.providerSet()[providerName]
 	if !exists {
-		return ErrorResult(fmt.Errorf("provider '%s' not available", providerName))
+		return ErrorResult(errs.Newf(errs.ProviderUnavailable, "provider '%s' not available", providerName))
 	}
 
 	// Build embedding request
@@ -460,63 +1602,202 @@ func (llm *LLMService) embed(ctx context.Context, params ServiceParams) ServiceR
 		Model: modelName,
 		Text:  text,
 	}
+	llm.warnUncataloged(providerName, modelName)
 
 	// Check budget before making request
-	if err := llm.checkBudget(); err != nil {
+	if err := llm.checkBudget(requestPlanID(params)); err != nil {
 		return ErrorResult(fmt.Errorf("budget check failed: %w", err))
 	}
 
 	// Execute with retries
-	response, err := llm.executeWithRetry(ctx, func() (interface{}, error) {
-		return provider.Embed(ctx, request)
+	response, failed, err := llm.executeWithRetry(ctx, providerName, modelName, "embed", false, func() (interface{}, error) {
+		return llm.throttled(ctx, providerName, len(text)/4, func() (interface{}, error) {
+			return provider.Embed(ctx, request)
+		})
 	})
 
 	if err != nil {
-		return ErrorResult(fmt.Errorf("embedding failed: %w", err))
+		return withFailedAttempts(ErrorResult(fmt.Errorf("embedding failed: %w", err)), failed)
+	}
+
+	embeddingResp := response.(*EmbeddingResponse)
+
+	// Update budget tracking
+	llm.updateBudget(providerName, "embed", embeddingResp.TokensUsed, embeddingResp.Cost)
+
+	return withFailedAttempts(SuccessResult(embeddingResp), failed)
+}
+
+// listProviders returns information about available providers and their
+// health, ordered by name.
+func (llm *LLMService) listProviders(ctx context.Context, params ServiceParams) ServiceResult {
+	providers := llm.providerSet()
+	result := map[string]interface{}{
+		"providers": make([]map[string]interface{}, 0, len(providers)),
+	}
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	health := llm.providerHealth()
+	for _, name := range names {
+		status := health[name]
+		providerInfo := map[string]interface{}{
+			"name": name,
+			"provider_name": providers[name].Name(),
+			"healthy":              status.Healthy,
+			"consecutive_failures": status.ConsecutiveFailures,
+			"last_checked":         "",
+		}
+		if !status.LastChecked.IsZero() {
+			providerInfo["last_checked"] = status.LastChecked.Format(time.RFC3339)
+		}
+		if status.LastError != "" {
+			providerInfo["last_error"] = status.LastError
+		}
+		result["providers"] = append(result["providers"].([]map[string]interface{}), providerInfo)
 	}
 
-	embeddingResp := response.(*EmbeddingResponse)
+	return SuccessResult(result)
+}
 
-	// Update budget tracking
-	llm.updateBudget(providerName, "embed", embeddingResp.TokensUsed, embeddingResp.Cost)
+// listModels returns the models of every provider that can describe them,
+// ordered by provider and model.
+func (llm *LLMService) listModels(ctx context.Context, params ServiceParams) ServiceResult {
+	providers := llm.providerSet()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	models := make([]ModelListing, 0)
+	for _, name := range names {
+		catalog, ok := providers[name].(ModelCatalog)
+		if !ok {
+			continue
+		}
+		configs := catalog.ListModels()
+		keys := make([]string, 0, len(configs))
+		for key := range configs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			config := configs[key]
+			models = append(models, ModelListing{
+				Provider:      name,
+				Model:         key,
+				InputCost:     config.InputCost,
+				OutputCost:    config.OutputCost,
+				MaxTokens:     config.MaxTokens,
+				ContextSize:   config.ContextSize,
+				SupportsChat:  config.SupportsChat,
+				SupportsEmbed: config.SupportsEmbed,
+				Dimensions:    config.Dimensions,
+				QualityTier:   config.QualityTier,
+				SpeedTier:     config.SpeedTier,
+			})
+		}
+	}
 
-	return SuccessResult(embeddingResp)
+	return SuccessResult(models)
 }
 
-// listProviders returns information about available providers.
-func (llm *LLMService) listProviders(ctx context.Context, params ServiceParams) ServiceResult {
-	result := map[string]interface{}{
-		"providers": make([]map[string]interface{}, 0, len(llm.providers)),
+// refreshModels asks each provider that can, or the one named by the
+// "provider" parameter, which of its catalog's models its API serves, and
+// offers only those. It returns a ModelRefresh per provider, by name.
+func (llm *LLMService) refreshModels(ctx context.Context, params ServiceParams) ServiceResult {
+	providers := llm.providerSet()
+	var names []string
+	if providerName, exists := params["provider"]; exists {
+		names = []string{providerName.(string)}
+	} else {
+		for name, provider := range providers {
+			if _, ok := provider.(ModelRefresher); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
 	}
 
-	for name, provider := range llm.providers {
-		providerInfo := map[string]interface{}{
-			"name": name,
-			"provider_name": provider.Name(),
+	refreshes := make([]*ModelRefresh, 0, len(names))
+	for _, name := range names {
+		refresher, ok := providers[name].(ModelRefresher)
+		if !ok {
+			return ErrorResult(errs.Newf(errs.ProviderUnavailable, "provider '%s' not available", name))
 		}
-		result["providers"] = append(result["providers"].([]map[string]interface{}), providerInfo)
+		refreshed, refresh, err := refresher.RefreshModels(ctx, llm.modelDefinitions().Models(name))
+		if err != nil {
+			return ErrorResult(fmt.Errorf("failed to refresh %s models: %w", name, err))
+		}
+		// A provider replaced while its API was asked, by a new key or
+		// catalog, is kept rather than overwritten with the stale copy
+		llm.updateProviders(func(current map[string]LLMProvider) {
+			if current[name] == providers[name] {
+				current[name] = refreshed
+			}
+		})
+		refreshes = append(refreshes, refresh)
 	}
+	return SuccessResult(refreshes)
+}
 
-	return SuccessResult(result)
+// warnUncataloged logs, once per provider and model, that a requested model
+// has no catalog entry: it is sent to the provider as named and priced at
+// nothing. It reports whether the model is uncataloged.
+func (llm *LLMService) warnUncataloged(providerName, model string) bool {
+	catalog, ok := llm.providerSet()[providerName].(ModelCatalog)
+	if !ok || model == "" {
+		return false
+	}
+	// Local models are free whatever they are called
+	if _, local := catalog.(*LocalProvider); local {
+		return false
+	}
+	if _, exists := findModelConfig(catalog.ListModels(), model); exists {
+		return false
+	}
+	if _, warned := llm.uncataloged.LoadOrStore(providerName+"/"+model, true); !warned {
+		llm.logger.Printf("Warning: model '%s' is not in the %s catalog; sending it as named, with its cost estimated at $0 (add it to %s to price it)",
+			model, providerName, ModelCatalogFile)
+	}
+	return true
 }
 
-// getBudget returns current budget tracking information.
+// getBudget returns a snapshot of the current budget tracking information.
 func (llm *LLMService) getBudget(ctx context.Context, params ServiceParams) ServiceResult {
-	return SuccessResult(llm.budgetTracker)
+	llm.budgetMu.Lock()
+	defer llm.budgetMu.Unlock()
+	llm.rollOverBudget()
+	return SuccessResult(llm.budgetTracker.clone())
 }
 
-// resetBudget resets the budget tracking counters.
+// resetBudget resets the budget tracking counters. Archived days are kept.
 func (llm *LLMService) resetBudget(ctx context.Context, params ServiceParams) ServiceResult {
+	llm.budgetMu.Lock()
+	defer llm.budgetMu.Unlock()
+	now := llm.clock.Now()
 	llm.budgetTracker = &BudgetTracker{
 		ByProvider:  make(map[string]ProviderUsage),
 		ByOperation: make(map[string]OperationUsage),
 		DailyLimit:  llm.budgetTracker.DailyLimit,
-		StartTime:   time.Now(),
+		StartTime:   now,
+		Yesterday:   llm.budgetTracker.Yesterday,
+		History:     llm.budgetTracker.History,
+		DaysTracked: llm.budgetTracker.DaysTracked,
+
+		Enforcement:  llm.budgetTracker.Enforcement,
+		GracePercent: llm.budgetTracker.GracePercent,
 	}
 
 	result := map[string]interface{}{
 		"message": "Budget tracking reset successfully",
-		"reset_time": time.Now().Format(time.RFC3339),
+		"reset_time": now.Format(time.RFC3339),
 	}
 
 	return SuccessResult(result)
@@ -527,8 +1808,11 @@ func (llm *LLMService) selectProvider(params ServiceParams, operation string) (s
 	// If provider explicitly specified, use it
 	if providerName, exists := params["provider"]; exists {
 		providerStr := providerName.(string)
-		if _, exists := llm.providers[providerStr]; !exists {
-			return "", "", fmt.Errorf("specified provider '%s' not available", providerStr)
+		if _, exists := llm.providerSet()[providerStr]; !exists {
+			return "", "", errs.Newf(errs.ProviderUnavailable, "specified provider '%s' not available", providerStr)
+		}
+		if !llm.providerHealthy(providerStr) {
+			return "", "", llm.unhealthyError(providerStr)
 		}
 
 		// Get model for this provider
@@ -536,27 +1820,42 @@ func (llm *LLMService) selectProvider(params ServiceParams, operation string) (s
 		return providerStr, modelName, nil
 	}
 
-	// Auto-select based on operation and cost
+	// Auto-select based on operation and cost, skipping providers out of service
 	switch operation {
 	case "complete":
 		// Prefer local, then anthropic (haiku), then openai
-		if _, exists := llm.providers["local"]; exists {
+		if llm.selectable("local") {
 			return "local", llm.getModelForProvider("local", operation, params), nil
 		}
-		if _, exists := llm.providers["anthropic"]; exists {
+		if llm.selectable("anthropic") {
 			return "anthropic", "claude-3-haiku", nil
 		}
-		if _, exists := llm.providers["openai"]; exists {
+		if llm.selectable("openai") {
 			return "openai", "gpt-3.5-turbo", nil
 		}
 	case "embed":
-		// For embeddings, prefer OpenAI as it has dedicated embedding models
-		if _, exists := llm.providers["openai"]; exists {
-			return "openai", "text-embedding-ada-002", nil
+		// Prefer local embeddings, which are free, then OpenAI's, then
+		// Anthropic's when it has an embeddings endpoint
+		for _, name := range []string{"local", "openai", "anthropic"} {
+			if !llm.selectable(name) {
+				continue
+			}
+			if model := llm.embedModel(name); model != "" {
+				return name, model, nil
+			}
 		}
 	}
 
-	return "", "", fmt.Errorf("no suitable provider available for operation '%s'", operation)
+	if len(llm.providerSet()) == 0 {
+		return "", "", llm.Ready()
+	}
+	return "", "", errs.Newf(errs.ProviderUnavailable, "no suitable provider available for operation '%s'", operation)
+}
+
+// selectable reports whether a provider is configured and in service.
+func (llm *LLMService) selectable(name string) bool {
+	_, exists := llm.providerSet()[name]
+	return exists && llm.providerHealthy(name)
 }
 
 // getModelForProvider returns the appropriate model for a provider and operation.
@@ -566,6 +1865,12 @@ func (llm *LLMService) getModelForProvider(providerName, operation string, param
 		return modelName.(string)
 	}
 
+	if operation == "embed" {
+		if model := llm.embedModel(providerName); model != "" {
+			return model
+		}
+	}
+
 	// Return default models based on provider and operation
 	switch providerName {
 	case "anthropic":
@@ -580,23 +1885,152 @@ func (llm *LLMService) getModelForProvider(providerName, operation string, param
 			return "text-embedding-ada-002"
 		}
 	case "local":
-		return "local-llama"
+		if local, ok := llm.providerSet()["local"].(*LocalProvider); ok {
+			return local.DefaultModel()
+		}
+		return localPlaceholderModel
 	}
 
 	return ""
 }
 
-// checkBudget verifies that the daily budget limit hasn't been exceeded.
-func (llm *LLMService) checkBudget() error {
-	if llm.budgetTracker.TotalCost >= llm.budgetTracker.DailyLimit {
-		return fmt.Errorf("daily budget limit of $%.2f exceeded (current: $%.2f)",
-			llm.budgetTracker.DailyLimit, llm.budgetTracker.TotalCost)
+// embedModel returns the first of the provider's models, by key, flagged
+// SupportsEmbed, or "" if it has none or cannot list its models.
+func (llm *LLMService) embedModel(providerName string) string {
+	catalog, ok := llm.providerSet()[providerName].(ModelCatalog)
+	if !ok {
+		return ""
+	}
+	var keys []string
+	for key, config := range catalog.ListModels() {
+		if config.SupportsEmbed {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
+
+// checkBudget verifies that the daily budget limit hasn't been exceeded, or,
+// under BudgetEnforcementSoftWithGrace, that the grace past it hasn't when
+// planID names the running plan a request is part of. Under
+// BudgetEnforcementWarnOnly it only warns.
+func (llm *LLMService) checkBudget(planID string) error {
+	llm.budgetMu.Lock()
+	defer llm.budgetMu.Unlock()
+	llm.rollOverBudget()
+	tracker := llm.budgetTracker
+	if tracker.TotalCost < tracker.DailyLimit {
+		return nil
+	}
+
+	switch tracker.Enforcement {
+	case BudgetEnforcementWarnOnly:
+		llm.logger.Printf("Warning: daily budget limit of $%.2f exceeded (current: $%.2f)", tracker.DailyLimit, tracker.TotalCost)
+		return nil
+	case BudgetEnforcementSoftWithGrace:
+		if graceLimit := tracker.DailyLimit * (1 + tracker.GracePercent/100); planID != "" && tracker.TotalCost < graceLimit {
+			llm.logger.Printf("Warning: daily budget limit of $%.2f exceeded (current: $%.2f); plan %s continues within the grace of $%.2f",
+				tracker.DailyLimit, tracker.TotalCost, planID, graceLimit-tracker.DailyLimit)
+			return nil
+		}
 	}
-	return nil
+
+	err := errs.Newf(errs.BudgetExceeded, "daily budget limit of $%.2f exceeded (current: $%.2f)",
+		tracker.DailyLimit, tracker.TotalCost).
+		With("limit", tracker.DailyLimit).
+		With("spent", tracker.TotalCost)
+	err.Err = ErrBudgetExceeded
+	return err
+}
+
+// rollOverBudget starts a new budget day once the clock has passed the
+// calendar day the current one started on, in the budget's time zone. The
+// finished day's totals are archived and the counters start again from zero.
+func (llm *LLMService) rollOverBudget() {
+	tracker := llm.budgetTracker
+	started := budgetDayStart(tracker.StartTime, llm.budgetLocation)
+	today := budgetDayStart(llm.clock.Now(), llm.budgetLocation)
+	if !today.After(started) {
+		return
+	}
+
+	finished := BudgetDay{
+		Date:        started.Format("2006-01-02"),
+		TotalTokens: tracker.TotalTokens,
+		TotalCost:   tracker.TotalCost,
+		ByProvider:  tracker.ByProvider,
+		ByOperation: tracker.ByOperation,
+
+		FailedTokens: tracker.FailedTokens,
+		FailedCost:   tracker.FailedCost,
+	}
+	tracker.History = append(tracker.History, finished)
+	if len(tracker.History) > budgetHistoryDays {
+		tracker.History = tracker.History[len(tracker.History)-budgetHistoryDays:]
+	}
+
+	// A day without any requests was never open, so it archived nothing
+	yesterday := today.AddDate(0, 0, -1).Format("2006-01-02")
+	if finished.Date == yesterday {
+		tracker.Yesterday = &finished
+	} else {
+		tracker.Yesterday = &BudgetDay{
+			Date:        yesterday,
+			ByProvider:  make(map[string]ProviderUsage),
+			ByOperation: make(map[string]OperationUsage),
+		}
+	}
+	tracker.DaysTracked += budgetDaysBetween(started, today)
+
+	tracker.TotalTokens = 0
+	tracker.TotalCost = 0
+	tracker.FailedTokens = 0
+	tracker.FailedCost = 0
+	tracker.ByProvider = make(map[string]ProviderUsage)
+	tracker.ByOperation = make(map[string]OperationUsage)
+	tracker.StartTime = today
+}
+
+// budgetDayStart returns midnight of t's calendar day in loc.
+func budgetDayStart(t time.Time, loc *time.Location) time.Time {
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// budgetDaysBetween counts the calendar days from one midnight to a later
+// one, unaffected by daylight saving changes in between.
+func budgetDaysBetween(from, to time.Time) int {
+	fromYear, fromMonth, fromDay := from.Date()
+	toYear, toMonth, toDay := to.Date()
+	days := time.Date(toYear, toMonth, toDay, 0, 0, 0, 0, time.UTC).Sub(time.Date(fromYear, fromMonth, fromDay, 0, 0, 0, 0, time.UTC))
+	return int(days / (24 * time.Hour))
 }
 
 // updateBudget updates budget tracking with usage information.
 func (llm *LLMService) updateBudget(provider, operation string, tokens int, cost float64) {
+	llm.budgetMu.Lock()
+	defer llm.budgetMu.Unlock()
+	llm.addUsage(provider, operation, tokens, cost)
+}
+
+// updateFailedBudget updates budget tracking with the usage of a failed
+// attempt, which counts like any other and as wasted spend besides.
+func (llm *LLMService) updateFailedBudget(provider, operation string, tokens int, cost float64) {
+	llm.budgetMu.Lock()
+	defer llm.budgetMu.Unlock()
+	llm.addUsage(provider, operation, tokens, cost)
+	llm.budgetTracker.FailedTokens += tokens
+	llm.budgetTracker.FailedCost += cost
+}
+
+// addUsage adds usage to the current budget day. Callers hold budgetMu.
+func (llm *LLMService) addUsage(provider, operation string, tokens int, cost float64) {
+	llm.rollOverBudget()
+
 	// Update totals
 	llm.budgetTracker.TotalTokens += tokens
 	llm.budgetTracker.TotalCost += cost
@@ -616,69 +2050,149 @@ func (llm *LLMService) updateBudget(provider, operation string, tokens int, cost
 	llm.budgetTracker.ByOperation[operation] = operationUsage
 }
 
-// executeWithRetry executes a function with exponential backoff retry logic.
-func (llm *LLMService) executeWithRetry(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
-	var lastErr error
-	delay := llm.retryConfig.BaseDelay
-
-	for attempt := 0; attempt <= llm.retryConfig.MaxRetries; attempt++ {
-		result, err := fn()
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-
-		// Check if this is a retryable error
-		if !llm.isRetryableError(err) {
-			break
+// executeWithRetry executes a function under the service's retry policy.
+// Timed-out attempts are only retried when retryTimeouts is set. Each failed
+// attempt, at the operation on the provider's model, is returned, and any
+// usage the provider reported for it is counted in the budget.
+func (llm *LLMService) executeWithRetry(ctx context.Context, provider, model, operation string, retryTimeouts bool, fn func() (interface{}, error)) (interface{}, []FailedAttempt, error) {
+	policy := llm.retryConfig.Policy()
+	policy.Retryable = func(err error) bool {
+		if errors.Is(err, ErrTimeout) {
+			return retryTimeouts
 		}
-
-		// Don't retry on the last attempt
-		if attempt == llm.retryConfig.MaxRetries {
-			break
+		return llm.isRetryableError(err)
+	}
+
+	var result interface{}
+	var failed []FailedAttempt
+	stats, err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		started := time.Now()
+		var err error
+		result, err = fn()
+		if err != nil {
+			attempt := FailedAttempt{
+				Attempt:   len(failed) + 1,
+				Provider:  provider,
+				Model:     model,
+				LatencyMs: time.Since(started).Milliseconds(),
+				Error:     err.Error(),
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && (apiErr.TokensUsed() > 0 || apiErr.Cost > 0) {
+				attempt.TokensUsed = apiErr.TokensUsed()
+				attempt.Cost = apiErr.Cost
+				llm.updateFailedBudget(provider, operation, attempt.TokensUsed, attempt.Cost)
+			}
+			failed = append(failed, attempt)
 		}
+		return err
+	})
+	if err == nil {
+		return result, failed, nil
+	}
+	if stats.Cancelled {
+		return nil, failed, fmt.Errorf("context cancelled during retry: %w", err)
+	}
+	return nil, failed, fmt.Errorf("operation failed after %d retries: %w", llm.retryConfig.MaxRetries, err)
+}
 
-		// Wait before retrying
-		select {
-		case <-time.After(delay):
-			// Continue to retry
-		case <-ctx.Done():
-			return nil, fmt.Errorf("context cancelled during retry: %w", ctx.Err())
-		}
+// isRetryableError determines if an error should trigger a retry: rate
+// limits, server errors and failed connections pass, while rejected
+// credentials, oversized requests and other rejected requests fail the same
+// way every time.
+func (llm *LLMService) isRetryableError(err error) bool {
+	switch {
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrProviderUnavailable):
+		return true
+	case errors.Is(err, ErrAuthFailed), errors.Is(err, ErrContextTooLarge), errors.Is(err, ErrBudgetExceeded):
+		return false
+	}
 
-		// Exponential backoff
-		delay = time.Duration(float64(delay) * llm.retryConfig.BackoffRate)
-		if delay > llm.retryConfig.MaxDelay {
-			delay = llm.retryConfig.MaxDelay
-		}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return false
 	}
 
-	return nil, fmt.Errorf("operation failed after %d retries: %w", llm.retryConfig.MaxRetries, lastErr)
+	// Timeouts and refused or dropped connections
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
 
-// isRetryableError determines if an error should trigger a retry.
-func (llm *LLMService) isRetryableError(err error) bool {
-	errStr := strings.ToLower(err.Error())
+// requestEmbedding embeds the request's text with the OpenAI-compatible
+// /v1/embeddings route at baseURL, which OpenAI, Voyage and local servers
+// share. The model is sent under its configured name and priced by its
+// input tokens. An embedding of another length than the model's configured
+// Dimensions is an error, as it could not be compared with the model's
+// other embeddings.
+func requestEmbedding(ctx context.Context, client *http.Client, baseURL string, auth AuthScheme, provider string, models map[string]ModelConfig, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	config, exists := findModelConfig(models, request.Model)
+	model := request.Model
+	if exists && config.Name != "" {
+		model = config.Name
+	}
+
+	// Build the embedding request
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": request.Text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
 
-	// Rate limiting errors
-	if strings.Contains(errStr, "rate limit") ||
-	   strings.Contains(errStr, "too many requests") ||
-	   strings.Contains(errStr, "429") {
-		return true
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(baseURL, "/")+"/v1/embeddings", strings.NewReader(string(requestBody)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
+	auth.Apply(req)
 
-	// Temporary network errors
-	if strings.Contains(errStr, "timeout") ||
-	   strings.Contains(errStr, "connection") ||
-	   strings.Contains(errStr, "500") ||
-	   strings.Contains(errStr, "502") ||
-	   strings.Contains(errStr, "503") ||
-	   strings.Contains(errStr, "504") {
-		return true
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s embeddings request failed: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	// Parse response; a failed request reports its status even when the
+	// body is not JSON
+	var body struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	var raw map[string]interface{}
+	data, decodeErr := io.ReadAll(resp.Body)
+	if decodeErr == nil {
+		decodeErr = json.Unmarshal(data, &raw)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError(resp, provider, request.Model, raw)
+	}
+	if decodeErr == nil {
+		decodeErr = json.Unmarshal(data, &body)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+
+	if len(body.Data) == 0 || len(body.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("%s/%s returned no embedding", provider, request.Model)
+	}
+	embedding := body.Data[0].Embedding
+	if config.Dimensions > 0 && len(embedding) != config.Dimensions {
+		return nil, fmt.Errorf("%s/%s returned an embedding of %d dimensions, expected %d", provider, request.Model, len(embedding), config.Dimensions)
 	}
 
-	return false
+	return &EmbeddingResponse{
+		Embedding:  embedding,
+		TokensUsed: body.Usage.TotalTokens,
+		Model:      request.Model,
+		Provider:   provider,
+		Cost:       config.Cost(body.Usage.TotalTokens, 0),
+	}, nil
 }
 
 // Provider implementations
@@ -688,18 +2202,41 @@ func (ap *AnthropicProvider) Name() string {
 	return "Anthropic Claude API"
 }
 
+// ListModels returns the configured models, keyed by the name used in requests.
+func (ap *AnthropicProvider) ListModels() map[string]ModelConfig {
+	return ap.Models
+}
+
+// AuthScheme returns the x-api-key header the Anthropic API requires.
+func (ap *AnthropicProvider) AuthScheme() AuthScheme {
+	return HeaderAuth{Header: "x-api-key", Key: ap.APIKey}
+}
+
 // Complete performs text completion using the Anthropic Claude API.
 func (ap *AnthropicProvider) Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error) {
+	// Bound the request by its own timeout as well as the client's
+	started := time.Now()
+	ctx, cancel := request.withTimeout(ctx)
+	defer cancel()
+
 	// Build Anthropic API request
+	// The API takes system instructions apart from the conversation
+	var system []string
+	var messages []Message
+	for _, message := range request.Conversation() {
+		if message.Role == RoleSystem {
+			system = append(system, message.Content)
+			continue
+		}
+		messages = append(messages, message)
+	}
 	anthropicRequest := map[string]interface{}{
-		"model":      request.Model,
+		"model":      apiModelName(ap.Models, request.Model),
 		"max_tokens": request.MaxTokens,
-		"messages": []map[string]interface{}{
-			{
-				"role":    "user",
-				"content": request.Prompt,
-			},
-		},
+		"messages":   messages,
+	}
+	if len(system) > 0 {
+		anthropicRequest["system"] = strings.Join(system, "\n\n")
 	}
 
 	if request.Temperature > 0 {
@@ -710,6 +2247,16 @@ func (ap *AnthropicProvider) Complete(ctx context.Context, request CompletionReq
 		anthropicRequest["stop_sequences"] = request.StopWords
 	}
 
+	// JSON replies come back as the input of a tool the model must call
+	if schema := request.ResponseSchema; schema != nil {
+		anthropicRequest["tools"] = []map[string]interface{}{{
+			"name":         schema.name(),
+			"description":  "Record the reply as structured data.",
+			"input_schema": schema.objectSchema(),
+		}}
+		anthropicRequest["tool_choice"] = map[string]interface{}{"type": "tool", "name": schema.name()}
+	}
+
 	// Marshal request
 	requestBody, err := json.Marshal(anthropicRequest)
 	if err != nil {
@@ -723,39 +2270,34 @@ func (ap *AnthropicProvider) Complete(ctx context.Context, request CompletionReq
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+ap.APIKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("anthropic-version", anthropicAPIVersion)
+	ap.AuthScheme().Apply(req)
 
 	// Execute request
 	resp, err := ap.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("API request failed: %w", err)
+		return nil, checkTimeout(ctx, fmt.Errorf("API request failed: %w", err), "anthropic", request, started)
 	}
 	defer resp.Body.Close()
 
-	// Parse response
+	// Parse response; a failed request reports its status even when the
+	// body is not JSON
 	var anthropicResp map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	// Handle API errors
+	decodeErr := json.NewDecoder(resp.Body).Decode(&anthropicResp)
 	if resp.StatusCode >= 400 {
-		errMsg := "unknown error"
-		if errData, exists := anthropicResp["error"]; exists {
-			if errMap, ok := errData.(map[string]interface{}); ok {
-				if msg, ok := errMap["message"].(string); ok {
-					errMsg = msg
-				}
-			}
-		}
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, errMsg)
+		apiErr := newAPIError(resp, "anthropic", request.Model, anthropicResp)
+		apiErr.Cost = ap.CalculateCost(request.Model, apiErr.InputTokens, apiErr.OutputTokens)
+		return nil, apiErr
+	}
+	if decodeErr != nil {
+		return nil, checkTimeout(ctx, fmt.Errorf("failed to decode response: %w", decodeErr), "anthropic", request, started)
 	}
 
 	// Extract content and usage
 	var text string
-	var tokensUsed int
+	var inputTokens, outputTokens int
 
+	stopReason, _ := anthropicResp["stop_reason"].(string)
 	if content, exists := anthropicResp["content"]; exists {
 		if contentArray, ok := content.([]interface{}); ok && len(contentArray) > 0 {
 			if firstContent, ok := contentArray[0].(map[string]interface{}); ok {
@@ -763,51 +2305,81 @@ func (ap *AnthropicProvider) Complete(ctx context.Context, request CompletionReq
 					text = textContent
 				}
 			}
+			// A forced tool call carries the JSON reply as its input
+			for _, block := range contentArray {
+				if blockMap, ok := block.(map[string]interface{}); ok && blockMap["type"] == "tool_use" {
+					if input, err := json.Marshal(blockMap["input"]); err == nil {
+						text = string(input)
+					}
+					break
+				}
+			}
 		}
 	}
 
 	if usage, exists := anthropicResp["usage"]; exists {
 		if usageMap, ok := usage.(map[string]interface{}); ok {
-			if inputTokens, ok := usageMap["input_tokens"].(float64); ok {
-				tokensUsed += int(inputTokens)
+			if tokens, ok := usageMap["input_tokens"].(float64); ok {
+				inputTokens = int(tokens)
 			}
-			if outputTokens, ok := usageMap["output_tokens"].(float64); ok {
-				tokensUsed += int(outputTokens)
+			if tokens, ok := usageMap["output_tokens"].(float64); ok {
+				outputTokens = int(tokens)
 			}
 		}
 	}
 
 	// Calculate cost
-	cost := ap.CalculateCost(tokensUsed, "complete")
+	cost := ap.CalculateCost(request.Model, inputTokens, outputTokens)
 
 	return &CompletionResponse{
-		Text:       text,
-		TokensUsed: tokensUsed,
-		Model:      request.Model,
-		Provider:   "anthropic",
-		Cost:       cost,
+		Text:         text,
+		TokensUsed:   inputTokens + outputTokens,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Model:        request.Model,
+		Provider:     "anthropic",
+		Cost:         cost,
 		Metadata: map[string]interface{}{
-			"api_version": "2023-06-01",
+			"api_version":   anthropicAPIVersion,
+			"finish_reason": stopReason,
 		},
 	}, nil
 }
 
-// Embed returns an error as Anthropic doesn't provide embedding models.
+// Embed performs text embedding using the Voyage-compatible API at
+// EmbeddingsURL, as Anthropic doesn't provide embedding models.
 func (ap *AnthropicProvider) Embed(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
-	return nil, fmt.Errorf("Anthropic provider does not support embeddings")
+	if ap.EmbeddingsURL == "" {
+		return nil, fmt.Errorf("Anthropic provider does not support embeddings without an embeddings URL")
+	}
+
+	var auth AuthScheme = NoAuth{}
+	if ap.EmbeddingsKey != "" {
+		auth = BearerAuth{Token: ap.EmbeddingsKey}
+	}
+	response, err := requestEmbedding(ctx, ap.HTTPClient, ap.EmbeddingsURL, auth, "anthropic", ap.Models, request)
+	if err != nil {
+		return nil, err
+	}
+	response.Metadata = map[string]interface{}{
+		"embeddings_url": ap.EmbeddingsURL,
+	}
+	return response, nil
 }
 
-// CalculateCost calculates the cost for Anthropic API usage.
-func (ap *AnthropicProvider) CalculateCost(tokens int, operation string) float64 {
-	// Cost is typically split between input and output tokens
-	// For simplicity, we'll use average cost (this could be refined with actual input/output split)
-	modelConfig, exists := ap.Models["claude-3-haiku"] // Default model for cost calculation
+// CalculateCost calculates the cost for Anthropic API usage from the
+// model's input and output prices. Unknown models cost nothing.
+func (ap *AnthropicProvider) CalculateCost(model string, inputTokens, outputTokens int) float64 {
+	modelConfig, exists := findModelConfig(ap.Models, model)
 	if !exists {
 		return 0.0
 	}
+	return modelConfig.Cost(inputTokens, outputTokens)
+}
 
-	avgCost := (modelConfig.InputCost + modelConfig.OutputCost) / 2.0
-	return float64(tokens) * avgCost / 1000000.0 // Convert to cost per token
+// HealthCheck lists the API's models, which costs nothing.
+func (ap *AnthropicProvider) HealthCheck(ctx context.Context) error {
+	return probeHealth(ctx, ap.HTTPClient, ap.BaseURL+"/v1/models", ap.AuthScheme(), "anthropic")
 }
 
 // Name returns the provider name for OpenAIProvider.
@@ -815,17 +2387,27 @@ func (op *OpenAIProvider) Name() string {
 	return "OpenAI API"
 }
 
+// ListModels returns the configured models, keyed by the name used in requests.
+func (op *OpenAIProvider) ListModels() map[string]ModelConfig {
+	return op.Models
+}
+
+// AuthScheme returns the bearer token the OpenAI API requires.
+func (op *OpenAIProvider) AuthScheme() AuthScheme {
+	return BearerAuth{Token: op.APIKey}
+}
+
 // Complete performs text completion using the OpenAI API.
 func (op *OpenAIProvider) Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error) {
+	// Bound the request by its own timeout as well as the client's
+	started := time.Now()
+	ctx, cancel := request.withTimeout(ctx)
+	defer cancel()
+
 	// Build OpenAI API request
 	openaiRequest := map[string]interface{}{
-		"model": request.Model,
-		"messages": []map[string]interface{}{
-			{
-				"role":    "user",
-				"content": request.Prompt,
-			},
-		},
+		"model":    apiModelName(op.Models, request.Model),
+		"messages": request.Conversation(),
 	}
 
 	if request.MaxTokens > 0 {
@@ -840,6 +2422,22 @@ func (op *OpenAIProvider) Complete(ctx context.Context, request CompletionReques
 		openaiRequest["stop"] = request.StopWords
 	}
 
+	if schema := request.ResponseSchema; schema != nil {
+		if schema.Schema == nil {
+			// JSON mode needs the conversation itself to ask for JSON
+			openaiRequest["messages"] = schema.withInstruction(request).Conversation()
+			openaiRequest["response_format"] = map[string]interface{}{"type": "json_object"}
+		} else {
+			openaiRequest["response_format"] = map[string]interface{}{
+				"type": "json_schema",
+				"json_schema": map[string]interface{}{
+					"name":   schema.name(),
+					"schema": schema.Schema,
+				},
+			}
+		}
+	}
+
 	// Marshal request
 	requestBody, err := json.Marshal(openaiRequest)
 	if err != nil {
@@ -853,41 +2451,57 @@ func (op *OpenAIProvider) Complete(ctx context.Context, request CompletionReques
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+op.APIKey)
+	op.AuthScheme().Apply(req)
 
 	// Execute request
 	resp, err := op.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("API request failed: %w", err)
+		return nil, checkTimeout(ctx, fmt.Errorf("API request failed: %w", err), "openai", request, started)
 	}
 	defer resp.Body.Close()
 
-	// Parse response
+	// Parse response; a failed request reports its status even when the
+	// body is not JSON
 	var openaiResp map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	// Handle API errors
+	decodeErr := json.NewDecoder(resp.Body).Decode(&openaiResp)
 	if resp.StatusCode >= 400 {
-		errMsg := "unknown error"
-		if errData, exists := openaiResp["error"]; exists {
-			if errMap, ok := errData.(map[string]interface{}); ok {
-				if msg, ok := errMap["message"].(string); ok {
-					errMsg = msg
-				}
-			}
-		}
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, errMsg)
+		apiErr := newAPIError(resp, "openai", request.Model, openaiResp)
+		apiErr.Cost = op.CalculateCost(request.Model, apiErr.InputTokens, apiErr.OutputTokens)
+		return nil, apiErr
+	}
+	if decodeErr != nil {
+		return nil, checkTimeout(ctx, fmt.Errorf("failed to decode response: %w", decodeErr), "openai", request, started)
 	}
 
 	// Extract content and usage
-	var text string
-	var tokensUsed int
+	text, finishReason, inputTokens, outputTokens := parseChatCompletion(openaiResp)
+
+	// Calculate cost
+	cost := op.CalculateCost(request.Model, inputTokens, outputTokens)
+
+	return &CompletionResponse{
+		Text:         text,
+		TokensUsed:   inputTokens + outputTokens,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Model:        request.Model,
+		Provider:     "openai",
+		Cost:         cost,
+		Metadata: map[string]interface{}{
+			"api_version":   "v1",
+			"finish_reason": finishReason,
+		},
+	}, nil
+}
 
-	if choices, exists := openaiResp["choices"]; exists {
+// parseChatCompletion extracts the reply text, finish reason and token usage
+// of a decoded chat completions response, as OpenAI and compatible servers
+// return it.
+func parseChatCompletion(response map[string]interface{}) (text, finishReason string, inputTokens, outputTokens int) {
+	if choices, exists := response["choices"]; exists {
 		if choicesArray, ok := choices.([]interface{}); ok && len(choicesArray) > 0 {
 			if firstChoice, ok := choicesArray[0].(map[string]interface{}); ok {
+				finishReason, _ = firstChoice["finish_reason"].(string)
 				if message, ok := firstChoice["message"].(map[string]interface{}); ok {
 					if content, ok := message["content"].(string); ok {
 						text = content
@@ -897,137 +2511,53 @@ func (op *OpenAIProvider) Complete(ctx context.Context, request CompletionReques
 		}
 	}
 
-	if usage, exists := openaiResp["usage"]; exists {
+	if usage, exists := response["usage"]; exists {
 		if usageMap, ok := usage.(map[string]interface{}); ok {
-			if totalTokens, ok := usageMap["total_tokens"].(float64); ok {
-				tokensUsed = int(totalTokens)
+			if tokens, ok := usageMap["prompt_tokens"].(float64); ok {
+				inputTokens = int(tokens)
+			}
+			if tokens, ok := usageMap["completion_tokens"].(float64); ok {
+				outputTokens = int(tokens)
+			}
+			// Without the split, the whole request is priced as input
+			if tokens, ok := usageMap["total_tokens"].(float64); ok && inputTokens+outputTokens == 0 {
+				inputTokens = int(tokens)
 			}
 		}
 	}
-
-	// Calculate cost
-	cost := op.CalculateCost(tokensUsed, "complete")
-
-	return &CompletionResponse{
-		Text:       text,
-		TokensUsed: tokensUsed,
-		Model:      request.Model,
-		Provider:   "openai",
-		Cost:       cost,
-		Metadata: map[string]interface{}{
-			"api_version": "v1",
-		},
-	}, nil
+	return text, finishReason, inputTokens, outputTokens
 }
 
 // Embed performs text embedding using the OpenAI API.
 func (op *OpenAIProvider) Embed(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
-	// Build OpenAI embedding request
-	openaiRequest := map[string]interface{}{
-		"model": request.Model,
-		"input": request.Text,
-	}
-
-	// Marshal request
-	requestBody, err := json.Marshal(openaiRequest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", op.BaseURL+"/v1/embeddings", strings.NewReader(string(requestBody)))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	baseURL := op.EmbeddingsURL
+	if baseURL == "" {
+		baseURL = op.BaseURL
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+op.APIKey)
-
-	// Execute request
-	resp, err := op.HTTPClient.Do(req)
+	response, err := requestEmbedding(ctx, op.HTTPClient, baseURL, op.AuthScheme(), "openai", op.Models, request)
 	if err != nil {
-		return nil, fmt.Errorf("API request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Parse response
-	var openaiResp map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	// Handle API errors
-	if resp.StatusCode >= 400 {
-		errMsg := "unknown error"
-		if errData, exists := openaiResp["error"]; exists {
-			if errMap, ok := errData.(map[string]interface{}); ok {
-				if msg, ok := errMap["message"].(string); ok {
-					errMsg = msg
-				}
-			}
-		}
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, errMsg)
-	}
-
-	// Extract embedding and usage
-	var embedding []float64
-	var tokensUsed int
-
-	if data, exists := openaiResp["data"]; exists {
-		if dataArray, ok := data.([]interface{}); ok && len(dataArray) > 0 {
-			if firstData, ok := dataArray[0].(map[string]interface{}); ok {
-				if embeddingData, ok := firstData["embedding"].([]interface{}); ok {
-					embedding = make([]float64, len(embeddingData))
-					for i, val := range embeddingData {
-						if floatVal, ok := val.(float64); ok {
-							embedding[i] = floatVal
-						}
-					}
-				}
-			}
-		}
+		return nil, err
 	}
-
-	if usage, exists := openaiResp["usage"]; exists {
-		if usageMap, ok := usage.(map[string]interface{}); ok {
-			if totalTokens, ok := usageMap["total_tokens"].(float64); ok {
-				tokensUsed = int(totalTokens)
-			}
-		}
+	response.Metadata = map[string]interface{}{
+		"api_version": "v1",
 	}
-
-	// Calculate cost
-	cost := op.CalculateCost(tokensUsed, "embed")
-
-	return &EmbeddingResponse{
-		Embedding:  embedding,
-		TokensUsed: tokensUsed,
-		Model:      request.Model,
-		Provider:   "openai",
-		Cost:       cost,
-		Metadata: map[string]interface{}{
-			"api_version": "v1",
-		},
-	}, nil
+	return response, nil
 }
 
-// CalculateCost calculates the cost for OpenAI API usage.
-func (op *OpenAIProvider) CalculateCost(tokens int, operation string) float64 {
-	var cost float64
-
-	// Find appropriate model cost
-	for _, modelConfig := range op.Models {
-		if operation == "complete" && modelConfig.SupportsChat {
-			avgCost := (modelConfig.InputCost + modelConfig.OutputCost) / 2.0
-			cost = float64(tokens) * avgCost / 1000000.0
-			break
-		} else if operation == "embed" && modelConfig.SupportsEmbed {
-			cost = float64(tokens) * modelConfig.InputCost / 1000000.0
-			break
-		}
+// CalculateCost calculates the cost for OpenAI API usage from the model's
+// input and output prices. Embeddings have only input tokens. Unknown models
+// cost nothing.
+func (op *OpenAIProvider) CalculateCost(model string, inputTokens, outputTokens int) float64 {
+	modelConfig, exists := findModelConfig(op.Models, model)
+	if !exists {
+		return 0.0
 	}
+	return modelConfig.Cost(inputTokens, outputTokens)
+}
 
-	return cost
+// HealthCheck lists the API's models, which costs nothing.
+func (op *OpenAIProvider) HealthCheck(ctx context.Context) error {
+	return probeHealth(ctx, op.HTTPClient, op.BaseURL+"/v1/models", op.AuthScheme(), "openai")
 }
 
 // Name returns the provider name for LocalProvider.
@@ -1035,11 +2565,36 @@ func (lp *LocalProvider) Name() string {
 	return "Local HuggingFace Models"
 }
 
-// Complete performs text completion using local models.
+// ListModels returns the configured models, keyed by the name used in requests.
+func (lp *LocalProvider) ListModels() map[string]ModelConfig {
+	return lp.Models
+}
+
+// AuthScheme returns NoAuth: the local server needs no credentials.
+func (lp *LocalProvider) AuthScheme() AuthScheme {
+	return NoAuth{}
+}
+
+// Complete performs text completion using local models, in the API format
+// the server speaks.
 func (lp *LocalProvider) Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error) {
+	// Bound the request by its own timeout as well as the client's
+	started := time.Now()
+	ctx, cancel := request.withTimeout(ctx)
+	defer cancel()
+
+	// Local servers are only asked for JSON in the prompt
+	if request.ResponseSchema != nil {
+		request = request.ResponseSchema.withInstruction(request)
+	}
+
+	if lp.APIFormat == LocalFormatOpenAI {
+		return lp.completeChat(ctx, request, started)
+	}
+
 	// Build local API request (compatible with text-generation-webui format)
 	localRequest := map[string]interface{}{
-		"prompt":      request.Prompt,
+		"prompt":      request.PromptText(),
 		"max_tokens":  request.MaxTokens,
 		"temperature": request.Temperature,
 	}
@@ -1061,23 +2616,24 @@ func (lp *LocalProvider) Complete(ctx context.Context, request CompletionRequest
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	lp.AuthScheme().Apply(req)
 
 	// Execute request
 	resp, err := lp.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("local API request failed: %w", err)
+		return nil, checkTimeout(ctx, fmt.Errorf("local API request failed: %w", err), "local", request, started)
 	}
 	defer resp.Body.Close()
 
-	// Parse response
+	// Parse response; a failed request reports its status even when the
+	// body is not JSON
 	var localResp map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&localResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	// Handle API errors
+	decodeErr := json.NewDecoder(resp.Body).Decode(&localResp)
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("local API error (status %d): %v", resp.StatusCode, localResp)
+		return nil, newAPIError(resp, "local", request.Model, localResp)
+	}
+	if decodeErr != nil {
+		return nil, checkTimeout(ctx, fmt.Errorf("failed to decode response: %w", decodeErr), "local", request, started)
 	}
 
 	// Extract generated text
@@ -1092,36 +2648,87 @@ func (lp *LocalProvider) Complete(ctx context.Context, request CompletionRequest
 		}
 	}
 
-	// Estimate tokens (rough approximation: 1 token ≈ 4 characters)
-	tokensUsed := len(request.Prompt+text) / 4
+	// The generate API reports no usage
+	inputTokens := lp.estimateTokens(request.Model, request.PromptText())
+	outputTokens := lp.estimateTokens(request.Model, text)
 
 	return &CompletionResponse{
-		Text:       text,
-		TokensUsed: tokensUsed,
-		Model:      request.Model,
-		Provider:   "local",
-		Cost:       0.0, // Local models are free
+		Text:         text,
+		TokensUsed:   inputTokens + outputTokens,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Model:        request.Model,
+		Provider:     "local",
+		Cost:         0.0, // Local models are free
 		Metadata: map[string]interface{}{
 			"server_url": lp.ServerURL,
 		},
 	}, nil
 }
 
-// Embed returns an error as local embeddings would need a separate implementation.
+// Embed performs text embedding using the OpenAI-compatible embeddings
+// route of the local server, as llama.cpp and text-generation-webui serve
+// it. Only models flagged SupportsEmbed are asked for embeddings.
 func (lp *LocalProvider) Embed(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
-	return nil, fmt.Errorf("local provider does not currently support embeddings")
+	config, exists := findModelConfig(lp.Models, request.Model)
+	if !exists || !config.SupportsEmbed {
+		return nil, fmt.Errorf("local model '%s' does not support embeddings", request.Model)
+	}
+
+	baseURL := lp.EmbeddingsURL
+	if baseURL == "" {
+		baseURL = lp.ServerURL
+	}
+	response, err := requestEmbedding(ctx, lp.HTTPClient, baseURL, lp.AuthScheme(), "local", lp.Models, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers that report no usage get the same estimate as completions
+	if response.TokensUsed == 0 {
+		response.TokensUsed = lp.estimateTokens(request.Model, request.Text)
+	}
+	response.Cost = 0.0 // Local models are free
+	response.Metadata = map[string]interface{}{
+		"server_url": baseURL,
+	}
+	return response, nil
 }
 
 // CalculateCost returns 0.0 for local providers since they're free to use.
-func (lp *LocalProvider) CalculateCost(tokens int, operation string) float64 {
+func (lp *LocalProvider) CalculateCost(model string, inputTokens, outputTokens int) float64 {
 	return 0.0 // Local models are free
 }
 
+// HealthCheck asks the server which model it has loaded, or which models
+// it serves.
+func (lp *LocalProvider) HealthCheck(ctx context.Context) error {
+	if lp.APIFormat == LocalFormatOpenAI {
+		return probeHealth(ctx, lp.HTTPClient, lp.ServerURL+"/v1/models", lp.AuthScheme(), "local")
+	}
+	return probeHealth(ctx, lp.HTTPClient, lp.ServerURL+"/api/v1/model", lp.AuthScheme(), "local")
+}
+
+// SetBudgetLocation sets the time zone whose midnight starts a new budget
+// day (default: the local time zone).
+func (llm *LLMService) SetBudgetLocation(loc *time.Location) {
+	llm.budgetLocation = loc
+}
+
 // Testing helper methods
 
+// SetClock sets the clock budget days are measured by, for testing. Tracking
+// restarts from the clock's current time.
+func (llm *LLMService) SetClock(clock utils.Clock) {
+	llm.budgetMu.Lock()
+	defer llm.budgetMu.Unlock()
+	llm.clock = utils.ClockOrReal(clock)
+	llm.budgetTracker.StartTime = llm.clock.Now()
+}
+
 // SetProvider manually sets a provider for testing purposes.
 func (llm *LLMService) SetProvider(name string, provider LLMProvider) {
-	llm.providers[name] = provider
+	llm.setProvider(name, provider, nil)
 }
 
 // SetRetryConfig sets the retry configuration for testing.
@@ -1131,6 +2738,8 @@ func (llm *LLMService) SetRetryConfig(config RetryConfig) {
 
 // SetBudgetLimit sets the daily budget limit for testing.
 func (llm *LLMService) SetBudgetLimit(limit float64) {
+	llm.budgetMu.Lock()
+	defer llm.budgetMu.Unlock()
 	llm.budgetTracker.DailyLimit = limit
 }
 
@@ -1141,5 +2750,5 @@ func (llm *LLMService) UpdateBudgetForTest(provider, operation string, tokens in
 
 // GetProviderCount returns the number of registered providers.
 func (llm *LLMService) GetProviderCount() int {
-	return len(llm.providers)
+	return len(llm.providerSet())
 }
\ No newline at end of file
@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NoProvidersRemediation tells the user how to configure a provider when
+// there is none.
+const NoProvidersRemediation = "set ANTHROPIC_API_KEY or OPENAI_API_KEY, save a key with 'config set-credential <provider>', " +
+	"or set LOCAL_LLM_URL to a local model server"
+
+// ProviderStatus reports whether a provider was set up when the service was
+// created, and why not.
+type ProviderStatus struct {
+	Provider  string `json:"provider"`
+	Available bool   `json:"available"`
+
+	// Source is where the provider's settings came from, such as the
+	// environment variable holding its key
+	Source string `json:"source,omitempty"`
+
+	// Checked are the environment variables and configuration entries
+	// looked at for the provider's settings
+	Checked []string `json:"checked,omitempty"`
+
+	// Reason is why an unavailable provider was skipped, or a problem an
+	// available one has
+	Reason string `json:"reason,omitempty"`
+}
+
+// ProviderDiagnosis is the report of the diagnose operation.
+type ProviderDiagnosis struct {
+	// Ready is set when at least one provider can execute requests
+	Ready     bool             `json:"ready"`
+	Providers []ProviderStatus `json:"providers"`
+
+	// Remediation says how to configure a provider when none is ready
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Ready returns nil when the service has a provider to execute requests
+// with, and otherwise an error matching ErrNoProvidersConfigured that says
+// how to configure one. See Diagnose for why each provider was skipped.
+func (llm *LLMService) Ready() error {
+	if len(llm.providerSet()) > 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNoProvidersConfigured, NoProvidersRemediation)
+}
+
+// Diagnose reports, for every provider the service knows of, whether it
+// was set up, from what, and why not.
+func (llm *LLMService) Diagnose() *ProviderDiagnosis {
+	llm.providersMu.RLock()
+	statuses := append([]ProviderStatus(nil), llm.availability...)
+	llm.providersMu.RUnlock()
+
+	diagnosis := &ProviderDiagnosis{
+		Ready:     llm.Ready() == nil,
+		Providers: statuses,
+	}
+	sort.Slice(diagnosis.Providers, func(i, j int) bool {
+		return diagnosis.Providers[i].Provider < diagnosis.Providers[j].Provider
+	})
+	if !diagnosis.Ready {
+		diagnosis.Remediation = NoProvidersRemediation
+	}
+	return diagnosis
+}
+
+// setAvailability records the status of a provider, replacing an earlier
+// one. The caller must hold providersMu.
+func (llm *LLMService) setAvailability(status ProviderStatus) {
+	for i := range llm.availability {
+		if llm.availability[i].Provider == status.Provider {
+			llm.availability[i] = status
+			return
+		}
+	}
+	llm.availability = append(llm.availability, status)
+}
+
+// credentialStatus reports where the provider's API key was found, or
+// where it was looked for.
+func credentialStatus(provider string, credential Credential) ProviderStatus {
+	status := ProviderStatus{Provider: provider, Checked: credential.Checked, Reason: credential.Warning}
+	if credential.Key != "" {
+		status.Available, status.Source = true, credential.Source
+		return status
+	}
+	status.Reason = "no API key: " + strings.Join(credential.Checked, ", ") + " checked"
+	if credential.Warning != "" {
+		status.Reason += "; " + credential.Warning
+	}
+	return status
+}
@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// clearProviderEnv unsets the variables that configure providers.
+func clearProviderEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"ANTHROPIC_API_KEY", "OPENAI_API_KEY", "LOCAL_LLM_URL"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLLMService_NoProvidersConfigured(t *testing.T) {
+	clearProviderEnv(t)
+	service := NewLLMServiceWithCredentials(nil, map[string]string{})
+
+	err := service.Ready()
+	if !errors.Is(err, ErrNoProvidersConfigured) || !strings.Contains(err.Error(), "LOCAL_LLM_URL") {
+		t.Fatalf("Expected the missing providers reported with a remedy, got %v", err)
+	}
+	result := service.Execute(context.Background(), ServiceParams{"operation": "complete", "prompt": "Hello"})
+	if !errors.Is(result.Error, ErrNoProvidersConfigured) {
+		t.Errorf("Expected a completion to fail for the missing providers, got %v", result.Error)
+	}
+
+	result = service.Execute(context.Background(), ServiceParams{"operation": "diagnose"})
+	if !result.Success {
+		t.Fatalf("Diagnose failed: %v", result.Error)
+	}
+	diagnosis := result.Data.(*ProviderDiagnosis)
+	if diagnosis.Ready || diagnosis.Remediation == "" || len(diagnosis.Providers) != 3 {
+		t.Fatalf("Expected three skipped providers and a remedy, got %+v", diagnosis)
+	}
+	anthropic := diagnosis.Providers[0]
+	if anthropic.Provider != "anthropic" || anthropic.Available ||
+		strings.Join(anthropic.Checked, ",") != "credentials.anthropic,ANTHROPIC_API_KEY" || !strings.Contains(anthropic.Reason, "no API key") {
+		t.Errorf("Expected the Anthropic key's sources reported, got %+v", anthropic)
+	}
+	if local := diagnosis.Providers[1]; local.Provider != "local" || local.Reason != "LOCAL_LLM_URL is not set" {
+		t.Errorf("Expected the local server's variable reported, got %+v", local)
+	}
+
+	// A key saved later makes the service ready
+	if err := service.SetAPIKey("openai", "key"); err != nil {
+		t.Fatalf("SetAPIKey failed: %v", err)
+	}
+	diagnosis = service.Diagnose()
+	if service.Ready() != nil || !diagnosis.Ready || diagnosis.Remediation != "" {
+		t.Errorf("Expected the service ready with a key, got %+v", diagnosis)
+	}
+	if openai := diagnosis.Providers[2]; !openai.Available || openai.Source != "credentials.openai" {
+		t.Errorf("Expected the OpenAI key's source reported, got %+v", openai)
+	}
+}
+
+func TestLLMService_DiagnoseProviderSources(t *testing.T) {
+	clearProviderEnv(t)
+	t.Setenv("ANTHROPIC_API_KEY", "env-key")
+	t.Setenv("LOCAL_LLM_URL", "http://127.0.0.1:1")
+
+	diagnosis := NewLLMService(nil).Diagnose()
+	if !diagnosis.Ready {
+		t.Fatalf("Expected the service ready, got %+v", diagnosis)
+	}
+	byName := make(map[string]ProviderStatus)
+	for _, status := range diagnosis.Providers {
+		byName[status.Provider] = status
+	}
+	if anthropic := byName["anthropic"]; anthropic.Source != "ANTHROPIC_API_KEY" || len(anthropic.Checked) != 1 {
+		t.Errorf("Expected only the environment checked without a configuration, got %+v", anthropic)
+	}
+	if local := byName["local"]; !local.Available || !strings.Contains(local.Reason, "unreachable") {
+		t.Errorf("Expected the unreachable local server kept with a note, got %+v", local)
+	}
+}
@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LocalAPIFormat is the API a local model server speaks.
+type LocalAPIFormat string
+
+const (
+	// LocalFormatTGWUI is text-generation-webui's /api/v1/generate API
+	LocalFormatTGWUI LocalAPIFormat = "tgwui"
+
+	// LocalFormatOpenAI is the OpenAI-compatible /v1/chat/completions API
+	// that Ollama, the llama.cpp server, vLLM and LM Studio serve
+	LocalFormatOpenAI LocalAPIFormat = "openai-compatible"
+)
+
+// localDiscoveryTimeout bounds how long startup waits for the local server
+// to list its models.
+const localDiscoveryTimeout = 3 * time.Second
+
+// localPlaceholderModel is the model the local provider is configured with
+// until the server names the models it serves.
+const localPlaceholderModel = "local-llama"
+
+// Discover asks the server for the models it serves on the OpenAI-compatible
+// /v1/models route. A server that lists them speaks the OpenAI-compatible
+// API, unless APIFormat says otherwise, and its models are added to Models
+// under their IDs in place of the placeholder model. A server that does not
+// is taken for text-generation-webui when APIFormat is unset.
+func (lp *LocalProvider) Discover(ctx context.Context) error {
+	ids, err := lp.listServerModels(ctx)
+	if err != nil {
+		if lp.APIFormat == "" {
+			lp.APIFormat = LocalFormatTGWUI
+		}
+		return err
+	}
+	if lp.APIFormat == "" {
+		lp.APIFormat = LocalFormatOpenAI
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if lp.Models == nil {
+		lp.Models = make(map[string]ModelConfig)
+	}
+	delete(lp.Models, localPlaceholderModel)
+	for _, id := range ids {
+		// Models already configured, like the embedding model, keep their settings
+		if _, exists := findModelConfig(lp.Models, id); exists {
+			continue
+		}
+		lp.Models[id] = ModelConfig{
+			Name:         id,
+			InputCost:    0.0, // Free for local models
+			OutputCost:   0.0,
+			MaxTokens:    4096,
+			ContextSize:  4096,
+			SupportsChat: true,
+			QualityTier:  "basic",
+			SpeedTier:    2,
+		}
+	}
+	return nil
+}
+
+// listServerModels returns the IDs of the models the server lists on its
+// /v1/models route.
+func (lp *LocalProvider) listServerModels(ctx context.Context) ([]string, error) {
+	return listAPIModels(ctx, lp.HTTPClient, lp.ServerURL, lp.AuthScheme(), "local")
+}
+
+// DefaultModel returns the model completions use when none is requested:
+// the placeholder model while configured, otherwise the first chat model
+// by name.
+func (lp *LocalProvider) DefaultModel() string {
+	if _, exists := lp.Models[localPlaceholderModel]; exists {
+		return localPlaceholderModel
+	}
+	names := make([]string, 0, len(lp.Models))
+	for name, config := range lp.Models {
+		if config.SupportsChat {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return localPlaceholderModel
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// estimateTokens estimates the tokens text takes for a model, by the name
+// the server knows it by, for usage the server does not report.
+func (lp *LocalProvider) estimateTokens(model, text string) int {
+	if config, exists := findModelConfig(lp.Models, model); exists && config.Name != "" {
+		model = config.Name
+	}
+	if lp.CountTokens == nil {
+		return len(text) / 4
+	}
+	return lp.CountTokens(model, text)
+}
+
+// SetTokenCounter counts the tokens of the usage local servers do not report
+// with count, such as a token estimator's CountTokens.
+func (llm *LLMService) SetTokenCounter(count TokenCounter) {
+	llm.updateProviders(func(providers map[string]LLMProvider) {
+		for name, provider := range providers {
+			if local, ok := provider.(*LocalProvider); ok {
+				updated := *local
+				updated.CountTokens = count
+				providers[name] = &updated
+			}
+		}
+	})
+}
+
+// completeChat performs a completion on the server's OpenAI-compatible chat
+// completions route, with the usage the server reports.
+func (lp *LocalProvider) completeChat(ctx context.Context, request CompletionRequest, started time.Time) (*CompletionResponse, error) {
+	// The server knows the model by its own name
+	model := request.Model
+	if config, exists := findModelConfig(lp.Models, request.Model); exists && config.Name != "" {
+		model = config.Name
+	}
+
+	chatRequest := map[string]interface{}{
+		"model":    model,
+		"messages": request.Conversation(),
+		"stream":   false,
+	}
+	if request.MaxTokens > 0 {
+		chatRequest["max_tokens"] = request.MaxTokens
+	}
+	if request.Temperature > 0 {
+		chatRequest["temperature"] = request.Temperature
+	}
+	if len(request.StopWords) > 0 {
+		chatRequest["stop"] = request.StopWords
+	}
+
+	requestBody, err := json.Marshal(chatRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", lp.ServerURL+"/v1/chat/completions", strings.NewReader(string(requestBody)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	lp.AuthScheme().Apply(req)
+
+	resp, err := lp.HTTPClient.Do(req)
+	if err != nil {
+		return nil, checkTimeout(ctx, fmt.Errorf("local API request failed: %w", err), "local", request, started)
+	}
+	defer resp.Body.Close()
+
+	// A failed request reports its status even when the body is not JSON
+	var chatResp map[string]interface{}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&chatResp)
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError(resp, "local", request.Model, chatResp)
+	}
+	if decodeErr != nil {
+		return nil, checkTimeout(ctx, fmt.Errorf("failed to decode response: %w", decodeErr), "local", request, started)
+	}
+
+	text, finishReason, inputTokens, outputTokens := parseChatCompletion(chatResp)
+
+	// Servers that report no usage get the estimate of the generate API
+	if inputTokens+outputTokens == 0 {
+		inputTokens = lp.estimateTokens(request.Model, request.PromptText())
+		outputTokens = lp.estimateTokens(request.Model, text)
+	}
+
+	return &CompletionResponse{
+		Text:         text,
+		TokensUsed:   inputTokens + outputTokens,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Model:        request.Model,
+		Provider:     "local",
+		Cost:         0.0, // Local models are free
+		Metadata: map[string]interface{}{
+			"server_url":    lp.ServerURL,
+			"api_format":    string(LocalFormatOpenAI),
+			"finish_reason": finishReason,
+		},
+	}, nil
+}
@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newLocalService returns a service with only the local provider.
+func newLocalService(local *LocalProvider) *LLMService {
+	return NewLLMServiceWithProviders(log.New(io.Discard, "", 0), map[string]LLMProvider{"local": local})
+}
+
+func TestLocalProvider_OpenAICompatible(t *testing.T) {
+	var chatRequest map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"object": "list", "data": [{"id": "qwen2.5:7b"}, {"id": "llama3.1:8b"}, {"id": "nomic-embed-text"}]}`))
+		case "/v1/chat/completions":
+			json.NewDecoder(r.Body).Decode(&chatRequest)
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Hello there"}, "finish_reason": "stop"}],
+				"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	local := &LocalProvider{
+		ServerURL:  server.URL,
+		HTTPClient: server.Client(),
+		Models: map[string]ModelConfig{
+			"local-llama": {Name: "llama-2-7b-chat", SupportsChat: true},
+			"local-embed": {Name: "nomic-embed-text", SupportsEmbed: true},
+		},
+	}
+	if err := local.Discover(context.Background()); err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	// The format is detected, and the listed models replace the placeholder
+	if local.APIFormat != LocalFormatOpenAI {
+		t.Errorf("Expected the OpenAI-compatible format, got %q", local.APIFormat)
+	}
+	if _, exists := local.Models["local-llama"]; exists || len(local.Models) != 3 {
+		t.Errorf("Expected the listed models in place of the placeholder, got %v", local.Models)
+	}
+	if embed := local.Models["local-embed"]; !embed.SupportsEmbed || embed.SupportsChat {
+		t.Errorf("Expected the configured embedding model kept, got %+v", embed)
+	}
+	if model := local.DefaultModel(); model != "llama3.1:8b" {
+		t.Errorf("Expected the first chat model by default, got %q", model)
+	}
+
+	result := newLocalService(local).Execute(context.Background(), ServiceParams{"operation": "complete", "prompt": "Hi", "provider": "local"})
+	if !result.Success {
+		t.Fatalf("Completion failed: %v", result.Error)
+	}
+	response := result.Data.(*CompletionResponse)
+	if response.Text != "Hello there" || response.InputTokens != 12 || response.OutputTokens != 3 || response.Cost != 0 {
+		t.Errorf("Expected the reply with the server's usage, got %+v", response)
+	}
+	if chatRequest["model"] != "llama3.1:8b" {
+		t.Errorf("Expected the server's model name, got %v", chatRequest["model"])
+	}
+	messages, _ := chatRequest["messages"].([]interface{})
+	if len(messages) != 1 || messages[0].(map[string]interface{})["content"] != "Hi" {
+		t.Errorf("Expected the prompt as a chat message, got %v", chatRequest["messages"])
+	}
+}
+
+func TestLocalProvider_TextGenerationWebUI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/generate" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"results": [{"text": "Hello there, friend"}]}`))
+	}))
+	defer server.Close()
+
+	local := &LocalProvider{
+		ServerURL:  server.URL,
+		HTTPClient: server.Client(),
+		Models:     map[string]ModelConfig{"local-llama": {Name: "llama-2-7b-chat", SupportsChat: true}},
+	}
+	if err := local.Discover(context.Background()); err == nil {
+		t.Error("Expected the missing model listing to be reported")
+	}
+	if local.APIFormat != LocalFormatTGWUI || local.DefaultModel() != "local-llama" {
+		t.Errorf("Expected the generate API with the placeholder model, got %q, %q", local.APIFormat, local.DefaultModel())
+	}
+
+	result := newLocalService(local).Execute(context.Background(), ServiceParams{"operation": "complete", "prompt": "Hi there, model", "provider": "local"})
+	if !result.Success {
+		t.Fatalf("Completion failed: %v", result.Error)
+	}
+	response := result.Data.(*CompletionResponse)
+	if response.Text != "Hello there, friend" || response.InputTokens != 3 || response.OutputTokens != 4 {
+		t.Errorf("Expected the reply with estimated usage, got %+v", response)
+	}
+
+	// A token counter is asked by the name the server knows the model by
+	service := newLocalService(local)
+	var models []string
+	service.SetTokenCounter(func(model, text string) int {
+		models = append(models, model)
+		return len(strings.Fields(text))
+	})
+	result = service.Execute(context.Background(), ServiceParams{"operation": "complete", "prompt": "Hi there, model", "provider": "local"})
+	if !result.Success {
+		t.Fatalf("Completion failed: %v", result.Error)
+	}
+	if response := result.Data.(*CompletionResponse); response.InputTokens != 3 || response.OutputTokens != 3 {
+		t.Errorf("Expected the counter's usage, got %+v", response)
+	}
+	if len(models) != 2 || models[0] != "llama-2-7b-chat" {
+		t.Errorf("Expected the prompt and reply counted for llama-2-7b-chat, got %v", models)
+	}
+	// The counter is set on a copy, leaving the provider in use unchanged
+	if local.CountTokens != nil || service.providerSet()["local"] == local {
+		t.Error("Expected the local provider replaced, not changed in place")
+	}
+}
+
+func TestLocalProvider_ConfiguredFormatWins(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"data": [{"id": "mistral"}]}`))
+		case "/api/v1/generate":
+			w.Write([]byte(`{"results": [{"text": "ok"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	local := &LocalProvider{ServerURL: server.URL, HTTPClient: server.Client(), APIFormat: LocalFormatTGWUI}
+	if err := local.Discover(context.Background()); err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if local.APIFormat != LocalFormatTGWUI || local.DefaultModel() != "mistral" {
+		t.Errorf("Expected the configured format with the listed model, got %q, %q", local.APIFormat, local.DefaultModel())
+	}
+
+	// A chat-only route would 404 here
+	result := newLocalService(local).Execute(context.Background(), ServiceParams{"operation": "complete", "prompt": "Hi", "provider": "local"})
+	if !result.Success || result.Data.(*CompletionResponse).Text != "ok" {
+		t.Errorf("Expected the generate API to be used, got %+v", result)
+	}
+}
@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// ServiceHandler executes a call to the named service. The registry's own
+// handler finds the service and executes it; middleware wraps it.
+type ServiceHandler func(ctx context.Context, serviceName string, params ServiceParams) ServiceResult
+
+// Middleware wraps a ServiceHandler to add behaviour to every call through
+// a registry, such as timing, logging or checks that may refuse the call
+// without passing it on to next.
+type Middleware func(next ServiceHandler) ServiceHandler
+
+// TimingMiddleware records how long each call took, middleware inside it
+// included, as a time.Duration under "call_duration" in the result's
+// metadata.
+func TimingMiddleware() Middleware {
+	return func(next ServiceHandler) ServiceHandler {
+		return func(ctx context.Context, serviceName string, params ServiceParams) ServiceResult {
+			start := time.Now()
+			result := next(ctx, serviceName, params)
+			if result.Metadata == nil {
+				result.Metadata = make(map[string]interface{})
+			}
+			result.Metadata["call_duration"] = time.Since(start)
+			return result
+		}
+	}
+}
+
+// LoggingMiddleware logs each call to logger once it returns: failures as
+// warnings with their error code, successes at debug level. Entries carry
+// the LogContext of the call's context, with any goal_id, objective_id,
+// plan_id, method_id, user_id or session_id string parameters over it.
+// Other parameters are not logged, as they may hold content or secrets.
+func LoggingMiddleware(logger utils.Logger) Middleware {
+	return func(next ServiceHandler) ServiceHandler {
+		return func(ctx context.Context, serviceName string, params ServiceParams) ServiceResult {
+			start := time.Now()
+			result := next(ctx, serviceName, params)
+
+			lc := ParamsLogContext(ctx, params)
+			lc.Component = "mcp." + serviceName
+			fields := map[string]interface{}{
+				"service":     serviceName,
+				"duration_ms": time.Since(start).Milliseconds(),
+			}
+			if operation, ok := params["operation"].(string); ok {
+				fields["operation"] = operation
+			}
+			if result.Success {
+				logger.Debug(lc, "Service call succeeded", fields)
+			} else {
+				fields["error_code"] = string(errs.CodeOf(result.Error))
+				logger.Warning(lc, fmt.Sprintf("Service call failed: %v", result.Error), fields)
+			}
+			return result
+		}
+	}
+}
+
+// ParamsLogContext returns the LogContext carried by ctx, with the
+// correlation IDs set in params over it.
+func ParamsLogContext(ctx context.Context, params ServiceParams) utils.LogContext {
+	lc := utils.LogContextFrom(ctx)
+	for key, field := range map[string]*string{
+		"goal_id":      &lc.GoalID,
+		"objective_id": &lc.ObjectiveID,
+		"plan_id":      &lc.PlanID,
+		"method_id":    &lc.MethodID,
+		"user_id":      &lc.UserID,
+		"session_id":   &lc.SessionID,
+	} {
+		if value, ok := params[key].(string); ok && value != "" {
+			*field = value
+		}
+	}
+	return lc
+}
+
+// RecoveryMiddleware turns a panic in the handlers it wraps into a failed
+// result coded errs.Internal, so one broken service cannot take down its
+// caller. The panic and its stack are logged to logger (nil: log.Default)
+// and the panic value is kept under "panic" in the result's metadata.
+func RecoveryMiddleware(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next ServiceHandler) ServiceHandler {
+		return func(ctx context.Context, serviceName string, params ServiceParams) (result ServiceResult) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					logger.Printf("MCP Service panic: %s | %v\n%s", serviceName, recovered, debug.Stack())
+					result = ErrorResult(errs.Newf(errs.Internal, "service %s panicked: %v", serviceName, recovered).
+						With("service", serviceName))
+					result.Metadata["panic"] = fmt.Sprint(recovered)
+				}
+			}()
+			return next(ctx, serviceName, params)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// panicService panics on every call.
+type panicService struct {
+	*BaseService
+}
+
+func (s *panicService) Execute(ctx context.Context, params ServiceParams) ServiceResult {
+	panic("index out of range")
+}
+
+// logEntry is one entry a recordingLogger received.
+type logEntry struct {
+	level   utils.LogLevel
+	context utils.LogContext
+	message string
+	fields  map[string]interface{}
+}
+
+// recordingLogger keeps the entries logged to it.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) record(level utils.LogLevel, ctx utils.LogContext, message string, fields []map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := logEntry{level: level, context: ctx, message: message}
+	if len(fields) > 0 {
+		entry.fields = fields[0]
+	}
+	l.entries = append(l.entries, entry)
+}
+
+func (l *recordingLogger) Debug(ctx utils.LogContext, message string, fields ...map[string]interface{}) {
+	l.record(utils.LogLevelDebug, ctx, message, fields)
+}
+func (l *recordingLogger) Info(ctx utils.LogContext, message string, fields ...map[string]interface{}) {
+	l.record(utils.LogLevelInfo, ctx, message, fields)
+}
+func (l *recordingLogger) Warning(ctx utils.LogContext, message string, fields ...map[string]interface{}) {
+	l.record(utils.LogLevelWarning, ctx, message, fields)
+}
+func (l *recordingLogger) Error(ctx utils.LogContext, message string, fields ...map[string]interface{}) {
+	l.record(utils.LogLevelError, ctx, message, fields)
+}
+func (l *recordingLogger) WithContext(utils.LogContext) utils.Logger { return l }
+func (l *recordingLogger) Close() error                              { return nil }
+
+// tracingMiddleware appends to trace as each call enters and leaves it.
+func tracingMiddleware(name string, trace *[]string) Middleware {
+	return func(next ServiceHandler) ServiceHandler {
+		return func(ctx context.Context, serviceName string, params ServiceParams) ServiceResult {
+			*trace = append(*trace, name+">")
+			result := next(ctx, serviceName, params)
+			*trace = append(*trace, "<"+name)
+			return result
+		}
+	}
+}
+
+func TestServiceRegistry_MiddlewareOrder(t *testing.T) {
+	registry := NewServiceRegistry(log.New(io.Discard, "", 0))
+	registry.RegisterService(NewMockService("echo", "Echo service", false))
+
+	var trace []string
+	for _, name := range []string{"outer", "inner"} {
+		if err := registry.RegisterMiddleware(tracingMiddleware(name, &trace)); err != nil {
+			t.Fatalf("Failed to register middleware: %v", err)
+		}
+	}
+	if err := registry.RegisterMiddleware(nil); err == nil {
+		t.Error("Expected nil middleware to be refused")
+	}
+
+	result := registry.CallService(context.Background(), "echo", ServiceParams{})
+	if !result.Success || result.Metadata["called_via_registry"] != "echo" {
+		t.Fatalf("Expected the call to reach the service, got %v", result.Error)
+	}
+	if got := strings.Join(trace, " "); got != "outer> inner> <inner <outer" {
+		t.Errorf("Expected middleware applied in registration order, got %s", got)
+	}
+
+	// A refusal short-circuits everything inside it
+	registry.RegisterMiddleware(func(next ServiceHandler) ServiceHandler {
+		return func(ctx context.Context, serviceName string, params ServiceParams) ServiceResult {
+			return ErrorResult(errs.New(errs.PolicyBlocked, "not today"))
+		}
+	})
+	trace = nil
+	result = registry.CallService(context.Background(), "echo", ServiceParams{})
+	if errs.CodeOf(result.Error) != errs.PolicyBlocked || result.Metadata["called_via_registry"] != nil {
+		t.Errorf("Expected the call refused before the service, got %+v", result)
+	}
+	if got := strings.Join(trace, " "); got != "outer> inner> <inner <outer" {
+		t.Errorf("Expected the earlier middleware still to run, got %s", got)
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	registry := NewServiceRegistry(log.New(io.Discard, "", 0))
+	registry.RegisterService(&panicService{NewBaseService("broken", "Panics on every call", nil)})
+	registry.RegisterMiddleware(RecoveryMiddleware(log.New(io.Discard, "", 0)))
+
+	result := registry.CallService(context.Background(), "broken", ServiceParams{})
+	if result.Success || errs.CodeOf(result.Error) != errs.Internal {
+		t.Fatalf("Expected the panic reported as an internal error, got %v", result.Error)
+	}
+	if !strings.Contains(result.Error.Error(), "index out of range") || result.Metadata["panic"] != "index out of range" {
+		t.Errorf("Expected the panic value kept, got %v, %v", result.Error, result.Metadata)
+	}
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	registry := NewServiceRegistry(log.New(io.Discard, "", 0))
+	registry.RegisterService(NewMockService("echo", "Echo service", false))
+	registry.RegisterMiddleware(TimingMiddleware())
+	registry.RegisterMiddleware(func(next ServiceHandler) ServiceHandler {
+		return func(ctx context.Context, serviceName string, params ServiceParams) ServiceResult {
+			time.Sleep(5 * time.Millisecond)
+			return next(ctx, serviceName, params)
+		}
+	})
+
+	result := registry.CallService(context.Background(), "echo", ServiceParams{})
+	if duration, ok := result.Metadata["call_duration"].(time.Duration); !ok || duration < 5*time.Millisecond {
+		t.Errorf("Expected the call's duration, middleware included, got %v", result.Metadata["call_duration"])
+	}
+
+	// Calls that fail before reaching a service are timed too
+	result = registry.CallService(context.Background(), "missing", ServiceParams{})
+	if _, ok := result.Metadata["call_duration"].(time.Duration); result.Success || !ok {
+		t.Errorf("Expected the failed call timed, got %v", result.Metadata)
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	logger := &recordingLogger{}
+	registry := NewServiceRegistry(log.New(io.Discard, "", 0))
+	registry.RegisterService(NewMockService("echo", "Echo service", false))
+	registry.RegisterMiddleware(LoggingMiddleware(logger))
+
+	ctx := utils.WithLogContext(context.Background(), utils.LogContext{GoalID: "goal_1", PlanID: "plan_1"})
+	registry.CallService(ctx, "echo", ServiceParams{"operation": "read", "plan_id": "plan_2", "api_key": "secret"})
+	registry.CallService(ctx, "missing", ServiceParams{})
+
+	if len(logger.entries) != 2 {
+		t.Fatalf("Expected an entry per call, got %d", len(logger.entries))
+	}
+	ok := logger.entries[0]
+	want := utils.LogContext{GoalID: "goal_1", PlanID: "plan_2", Component: "mcp.echo"}
+	if ok.level != utils.LogLevelDebug || ok.context != want || ok.fields["operation"] != "read" {
+		t.Errorf("Expected a debug entry with the params' plan over the context's, got %+v", ok)
+	}
+	if _, leaked := ok.fields["api_key"]; leaked || len(ok.fields) != 3 {
+		t.Errorf("Expected only the service, operation and duration logged, got %v", ok.fields)
+	}
+	failed := logger.entries[1]
+	if failed.level != utils.LogLevelWarning || failed.fields["error_code"] != string(errs.Internal) || !strings.Contains(failed.message, "not found") {
+		t.Errorf("Expected a warning for the missing service, got %+v", failed)
+	}
+}
+
+func TestServiceRegistry_MiddlewareConcurrentRegistration(t *testing.T) {
+	registry := NewServiceRegistry(log.New(io.Discard, "", 0))
+	registry.RegisterService(NewMockService("echo", "Echo service", false))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if result := registry.CallService(context.Background(), "echo", ServiceParams{}); !result.Success {
+					t.Errorf("Call failed during registration: %v", result.Error)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		registry.RegisterMiddleware(TimingMiddleware())
+	}
+	wg.Wait()
+
+	if len(registry.middleware) != 20 {
+		t.Errorf("Expected all middleware registered, got %d", len(registry.middleware))
+	}
+}
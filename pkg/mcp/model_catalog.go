@@ -0,0 +1,326 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// ModelCatalogFile is the name of the optional file, in the configuration
+// directory, whose model definitions override and extend the built-in ones.
+const ModelCatalogFile = "models.json"
+
+// ModelDefinitions holds the models providers offer, by provider name and
+// then by the key the model is requested by. A key is an alias for the
+// model's Name, the name the provider's API knows it by, so requests can
+// ask for "claude-sonnet-4" without naming a release.
+type ModelDefinitions map[string]map[string]ModelConfig
+
+// DefaultModelDefinitions returns the built-in models of the Anthropic and
+// OpenAI providers, with their prices per 1M tokens.
+func DefaultModelDefinitions() ModelDefinitions {
+	return ModelDefinitions{
+		"anthropic": {
+			"claude-opus-4": {
+				Name:         "claude-opus-4-20250514",
+				InputCost:    15.0, // $15 per 1M tokens
+				OutputCost:   75.0, // $75 per 1M tokens
+				MaxTokens:    32000,
+				ContextSize:  200000,
+				SupportsChat: true,
+				QualityTier:  "premium",
+				SpeedTier:    3,
+			},
+			"claude-sonnet-4": {
+				Name:         "claude-sonnet-4-20250514",
+				InputCost:    3.0,  // $3 per 1M tokens
+				OutputCost:   15.0, // $15 per 1M tokens
+				MaxTokens:    64000,
+				ContextSize:  200000,
+				SupportsChat: true,
+				QualityTier:  "premium",
+				SpeedTier:    2,
+			},
+			"claude-3-5-haiku": {
+				Name:         "claude-3-5-haiku-20241022",
+				InputCost:    0.8, // $0.80 per 1M tokens
+				OutputCost:   4.0, // $4 per 1M tokens
+				MaxTokens:    8192,
+				ContextSize:  200000,
+				SupportsChat: true,
+				QualityTier:  "standard",
+				SpeedTier:    1,
+			},
+			"claude-3-sonnet": {
+				Name:         "claude-3-sonnet-20240229",
+				InputCost:    3.0,  // $3 per 1M tokens
+				OutputCost:   15.0, // $15 per 1M tokens
+				MaxTokens:    4096,
+				ContextSize:  200000,
+				SupportsChat: true,
+				QualityTier:  "premium",
+				SpeedTier:    2,
+			},
+			"claude-3-haiku": {
+				Name:         "claude-3-haiku-20240307",
+				InputCost:    0.25, // $0.25 per 1M tokens
+				OutputCost:   1.25, // $1.25 per 1M tokens
+				MaxTokens:    4096,
+				ContextSize:  200000,
+				SupportsChat: true,
+				QualityTier:  "standard",
+				SpeedTier:    1,
+			},
+		},
+		"openai": {
+			"gpt-4.1": {
+				Name:         "gpt-4.1",
+				InputCost:    2.0, // $2 per 1M tokens
+				OutputCost:   8.0, // $8 per 1M tokens
+				MaxTokens:    32768,
+				ContextSize:  1047576,
+				SupportsChat: true,
+				QualityTier:  "premium",
+				SpeedTier:    2,
+			},
+			"gpt-4o": {
+				Name:         "gpt-4o",
+				InputCost:    2.5,  // $2.50 per 1M tokens
+				OutputCost:   10.0, // $10 per 1M tokens
+				MaxTokens:    16384,
+				ContextSize:  128000,
+				SupportsChat: true,
+				QualityTier:  "premium",
+				SpeedTier:    2,
+			},
+			"gpt-4o-mini": {
+				Name:         "gpt-4o-mini",
+				InputCost:    0.15, // $0.15 per 1M tokens
+				OutputCost:   0.6,  // $0.60 per 1M tokens
+				MaxTokens:    16384,
+				ContextSize:  128000,
+				SupportsChat: true,
+				QualityTier:  "standard",
+				SpeedTier:    1,
+			},
+			"gpt-4": {
+				Name:         "gpt-4",
+				InputCost:    30.0, // $30 per 1M tokens
+				OutputCost:   60.0, // $60 per 1M tokens
+				MaxTokens:    4096,
+				ContextSize:  8192,
+				SupportsChat: true,
+				QualityTier:  "premium",
+				SpeedTier:    3,
+			},
+			"gpt-3.5-turbo": {
+				Name:         "gpt-3.5-turbo",
+				InputCost:    0.5, // $0.5 per 1M tokens
+				OutputCost:   1.5, // $1.5 per 1M tokens
+				MaxTokens:    4096,
+				ContextSize:  16385,
+				SupportsChat: true,
+				QualityTier:  "standard",
+				SpeedTier:    1,
+			},
+			// Newer embedding models have other dimensions, so they are
+			// only used once added to models.json
+			"text-embedding-ada-002": {
+				Name:          "text-embedding-ada-002",
+				InputCost:     0.1, // $0.1 per 1M tokens
+				ContextSize:   8191,
+				SupportsEmbed: true,
+				Dimensions:    1536,
+			},
+		},
+	}
+}
+
+// LoadModelDefinitions returns the built-in model definitions with those in
+// the JSON file at path over them. The file holds models by provider and
+// key, as ModelDefinitions does:
+//
+//	{"anthropic": {"claude-sonnet-4": {"input_cost": 3, "output_cost": 15}}}
+//
+// An entry for a built-in model changes only the fields it sets; an entry
+// for a new key adds the model, named by its key unless it sets a name. A
+// missing file leaves the built-in definitions as they are.
+func LoadModelDefinitions(path string) (ModelDefinitions, error) {
+	definitions := DefaultModelDefinitions()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return definitions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model catalog: %w", err)
+	}
+
+	var file map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errs.Wrap(fmt.Errorf("failed to parse model catalog %s: %w", path, err), errs.Validation, nil)
+	}
+	for provider, entries := range file {
+		if definitions[provider] == nil {
+			definitions[provider] = make(map[string]ModelConfig)
+		}
+		for key, raw := range entries {
+			// Fields the entry leaves out keep their built-in values
+			config := definitions[provider][key]
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&config); err != nil {
+				return nil, errs.Newf(errs.Validation, "model catalog %s: %s/%s: %v", path, provider, key, err)
+			}
+			if config.Name == "" {
+				config.Name = key
+			}
+			if err := config.validate(); err != nil {
+				return nil, errs.Newf(errs.Validation, "model catalog %s: %s/%s: %v", path, provider, key, err)
+			}
+			definitions[provider][key] = config
+		}
+	}
+	return definitions, nil
+}
+
+// validate checks that the model's prices, sizes and tiers make sense.
+func (mc ModelConfig) validate() error {
+	switch {
+	case mc.InputCost < 0 || mc.OutputCost < 0:
+		return fmt.Errorf("costs cannot be negative")
+	case mc.MaxTokens < 0 || mc.ContextSize < 0 || mc.Dimensions < 0:
+		return fmt.Errorf("max_tokens, context_size and dimensions cannot be negative")
+	case mc.SpeedTier < 0 || mc.SpeedTier > 3:
+		return fmt.Errorf("speed_tier must be between 1 and 3")
+	}
+	switch mc.QualityTier {
+	case "", "basic", "standard", "premium":
+		return nil
+	default:
+		return fmt.Errorf("unknown quality_tier %q", mc.QualityTier)
+	}
+}
+
+// Models returns a copy of the provider's models, which the caller may
+// change.
+func (d ModelDefinitions) Models(provider string) map[string]ModelConfig {
+	models := make(map[string]ModelConfig, len(d[provider]))
+	for key, config := range d[provider] {
+		models[key] = config
+	}
+	return models
+}
+
+// apiModelName returns the name the provider's API knows model by: the
+// Name of its configuration, or model itself when it has none, so models
+// missing from the catalog are sent as requested.
+func apiModelName(models map[string]ModelConfig, model string) string {
+	if config, exists := findModelConfig(models, model); exists && config.Name != "" {
+		return config.Name
+	}
+	return model
+}
+
+// ModelRefresher is implemented by providers that can ask their API which
+// models it serves. The refresh_models operation refreshes every provider
+// implementing it.
+type ModelRefresher interface {
+	// RefreshModels returns a copy of the provider offering the models of
+	// catalog the API serves, and reports which it does and does not
+	RefreshModels(ctx context.Context, catalog map[string]ModelConfig) (LLMProvider, *ModelRefresh, error)
+}
+
+// ModelRefresh reports what a provider's API serves of its catalog.
+type ModelRefresh struct {
+	Provider    string   `json:"provider"`
+	Available   []string `json:"available"`   // Catalog keys the API serves, now offered
+	Unavailable []string `json:"unavailable"` // Catalog keys the API does not serve, no longer offered
+
+	// Uncataloged are the models the API serves that the catalog has no
+	// prices for. They are not offered, but may still be requested by name.
+	Uncataloged []string `json:"uncataloged"`
+}
+
+// RefreshModels lists the models the OpenAI API serves to the account and
+// returns a copy of the provider offering those of catalog it lists in place
+// of its models. Prices still come from the catalog.
+func (op *OpenAIProvider) RefreshModels(ctx context.Context, catalog map[string]ModelConfig) (LLMProvider, *ModelRefresh, error) {
+	ids, err := listAPIModels(ctx, op.HTTPClient, op.BaseURL, op.AuthScheme(), "openai")
+	if err != nil {
+		return nil, nil, err
+	}
+	served := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		served[id] = true
+	}
+
+	refresh := &ModelRefresh{Provider: "openai", Available: []string{}, Unavailable: []string{}, Uncataloged: []string{}}
+	models := make(map[string]ModelConfig)
+	cataloged := make(map[string]bool)
+	for key, config := range catalog {
+		name := apiModelName(catalog, key)
+		cataloged[name] = true
+		if served[name] {
+			models[key] = config
+			refresh.Available = append(refresh.Available, key)
+		} else {
+			refresh.Unavailable = append(refresh.Unavailable, key)
+		}
+	}
+	for _, id := range ids {
+		if !cataloged[id] {
+			refresh.Uncataloged = append(refresh.Uncataloged, id)
+		}
+	}
+	sort.Strings(refresh.Available)
+	sort.Strings(refresh.Unavailable)
+	sort.Strings(refresh.Uncataloged)
+
+	refreshed := *op
+	refreshed.Models = models
+	return &refreshed, refresh, nil
+}
+
+// listAPIModels returns the IDs of the models listed on the /v1/models
+// route at baseURL, which OpenAI and compatible servers share.
+func listAPIModels(ctx context.Context, client *http.Client, baseURL string, auth AuthScheme, provider string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/v1/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	auth.Apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s model listing failed: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	var listing struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&listing)
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError(resp, provider, "", nil)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode model listing: %w", decodeErr)
+	}
+
+	ids := make([]string, 0, len(listing.Data))
+	for _, model := range listing.Data {
+		if model.ID != "" {
+			ids = append(ids, model.ID)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,194 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+func writeModelCatalog(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ModelCatalogFile)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write model catalog: %v", err)
+	}
+	return path
+}
+
+func TestLoadModelDefinitions(t *testing.T) {
+	defaults := DefaultModelDefinitions()
+	definitions, err := LoadModelDefinitions(filepath.Join(t.TempDir(), ModelCatalogFile))
+	if err != nil || !reflect.DeepEqual(definitions, defaults) {
+		t.Fatalf("Expected the built-in models without a catalog file, got %v", err)
+	}
+
+	path := writeModelCatalog(t, `{
+		"anthropic": {
+			"claude-3-haiku": {"input_cost": 0.3},
+			"claude-sonnet-4-5": {"name": "claude-sonnet-4-5-20250929", "input_cost": 3, "output_cost": 15, "context_size": 200000, "supports_chat": true, "quality_tier": "premium"}
+		},
+		"openai": {"o3-mini": {"input_cost": 1.1, "output_cost": 4.4, "supports_chat": true}}
+	}`)
+	definitions, err = LoadModelDefinitions(path)
+	if err != nil {
+		t.Fatalf("Failed to load catalog: %v", err)
+	}
+	haiku := definitions["anthropic"]["claude-3-haiku"]
+	want := defaults["anthropic"]["claude-3-haiku"]
+	want.InputCost = 0.3
+	if haiku != want {
+		t.Errorf("Expected only the price of the built-in model changed, got %+v", haiku)
+	}
+	if sonnet := definitions["anthropic"]["claude-sonnet-4-5"]; sonnet.Name != "claude-sonnet-4-5-20250929" || sonnet.OutputCost != 15 {
+		t.Errorf("Expected the new model added, got %+v", sonnet)
+	}
+	if o3 := definitions["openai"]["o3-mini"]; o3.Name != "o3-mini" {
+		t.Errorf("Expected a model without a name to be named by its key, got %+v", o3)
+	}
+	if len(definitions["openai"]) != len(defaults["openai"])+1 {
+		t.Errorf("Expected the built-in OpenAI models kept, got %d", len(definitions["openai"]))
+	}
+
+	for _, content := range []string{
+		`{"openai": {"gpt-4o": {"input_cots": 2}}}`,
+		`{"openai": {"gpt-4o": {"input_cost": -1}}}`,
+		`{"openai": {"gpt-4o": {"quality_tier": "best"}}}`,
+		`{"openai": ["gpt-4o"]}`,
+	} {
+		if _, err := LoadModelDefinitions(writeModelCatalog(t, content)); !errors.Is(err, errs.Validation) {
+			t.Errorf("Expected %s to be rejected, got %v", content, err)
+		}
+	}
+}
+
+// modelServer serves /v1/models with ids and answers chat completions,
+// recording the model each asked for.
+func modelServer(t *testing.T, ids []string, requested *[]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			data := make([]map[string]string, len(ids))
+			for i, id := range ids {
+				data[i] = map[string]string{"id": id}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		*requested = append(*requested, body["model"].(string))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "Hi"}, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 1000000, "completion_tokens": 1000000},
+		})
+	}))
+}
+
+func TestLLMService_ModelCatalog(t *testing.T) {
+	var requested []string
+	server := modelServer(t, nil, &requested)
+	defer server.Close()
+
+	var logged bytes.Buffer
+	inUse := &OpenAIProvider{APIKey: "key", BaseURL: server.URL, HTTPClient: server.Client()}
+	service := NewLLMServiceWithProviders(log.New(&logged, "", 0), map[string]LLMProvider{"openai": inUse})
+	service.SetRetryConfig(RetryConfig{})
+	path := writeModelCatalog(t, `{"openai": {"gpt-latest": {"name": "gpt-4o-2024-11-20", "input_cost": 2.5, "output_cost": 10, "supports_chat": true}}}`)
+	if err := service.LoadModelCatalog(path); err != nil {
+		t.Fatalf("Failed to load catalog: %v", err)
+	}
+	// The catalog is offered by a copy, leaving the provider in use unchanged
+	if inUse.Models != nil || service.providerSet()["openai"] == inUse {
+		t.Error("Expected the OpenAI provider replaced, not changed in place")
+	}
+
+	// An alias is sent by the name the API knows and priced from the catalog
+	result := service.Execute(context.Background(), ServiceParams{"operation": "complete", "provider": "openai", "model": "gpt-latest", "prompt": "Hello"})
+	if !result.Success {
+		t.Fatalf("Completion failed: %v", result.Error)
+	}
+	response := result.Data.(*CompletionResponse)
+	if requested[0] != "gpt-4o-2024-11-20" || response.Model != "gpt-latest" || response.Cost != 12.5 {
+		t.Errorf("Expected the alias resolved and priced, got %s, %+v", requested[0], response)
+	}
+
+	// A model the catalog lacks is passed through, unpriced, with a warning
+	for i := 0; i < 2; i++ {
+		result = service.Execute(context.Background(), ServiceParams{"operation": "complete", "provider": "openai", "model": "gpt-next", "prompt": "Hello"})
+		if !result.Success {
+			t.Fatalf("Expected an uncataloged model to be sent, got %v", result.Error)
+		}
+	}
+	response = result.Data.(*CompletionResponse)
+	if requested[2] != "gpt-next" || response.Cost != 0 || response.Metadata["uncataloged_model"] != true {
+		t.Errorf("Expected the model sent as named at no cost, got %s, %+v", requested[2], response)
+	}
+	if warnings := strings.Count(logged.String(), "'gpt-next' is not in the openai catalog"); warnings != 1 {
+		t.Errorf("Expected one warning for the uncataloged model, got %d: %s", warnings, logged.String())
+	}
+
+	// A catalog that cannot be loaded keeps the models offered
+	if err := service.LoadModelCatalog(writeModelCatalog(t, `{"openai": {"gpt-4o": {"input_cost": -1}}}`)); err == nil {
+		t.Error("Expected an invalid catalog to be rejected")
+	}
+	if _, exists := service.providers["openai"].(*OpenAIProvider).Models["gpt-latest"]; !exists {
+		t.Error("Expected the rejected catalog to leave the models as they were")
+	}
+}
+
+func TestLLMService_RefreshModels(t *testing.T) {
+	var requested []string
+	server := modelServer(t, []string{"gpt-4o-mini", "gpt-4o", "o3-pro", "dall-e-3"}, &requested)
+	defer server.Close()
+
+	service := NewLLMServiceWithProviders(nil, map[string]LLMProvider{
+		"openai": &OpenAIProvider{APIKey: "key", BaseURL: server.URL, HTTPClient: server.Client()},
+		"local":  &LocalProvider{ServerURL: server.URL, HTTPClient: server.Client()},
+	})
+	service.SetModelDefinitions(DefaultModelDefinitions())
+
+	if err := service.ValidateParams(ServiceParams{"operation": "refresh_models", "provider": "local"}); err == nil {
+		t.Error("Expected a provider that cannot refresh to be refused")
+	}
+
+	result := service.Execute(context.Background(), ServiceParams{"operation": "refresh_models"})
+	if !result.Success {
+		t.Fatalf("Refresh failed: %v", result.Error)
+	}
+	refreshes := result.Data.([]*ModelRefresh)
+	if len(refreshes) != 1 || refreshes[0].Provider != "openai" {
+		t.Fatalf("Expected only OpenAI refreshed, got %+v", refreshes)
+	}
+	refresh := refreshes[0]
+	if !reflect.DeepEqual(refresh.Available, []string{"gpt-4o", "gpt-4o-mini"}) ||
+		!reflect.DeepEqual(refresh.Uncataloged, []string{"dall-e-3", "o3-pro"}) ||
+		len(refresh.Unavailable) != len(DefaultModelDefinitions()["openai"])-2 {
+		t.Errorf("Unexpected refresh: %+v", refresh)
+	}
+
+	// Only the served models are offered, at the catalog's prices
+	listing := service.Execute(context.Background(), ServiceParams{"operation": "list_models", "provider": "openai"}).Data.([]ModelListing)
+	var offered []string
+	for _, model := range listing {
+		if model.Provider == "openai" {
+			offered = append(offered, model.Model)
+			if model.InputCost == 0 {
+				t.Errorf("Expected %s priced from the catalog", model.Model)
+			}
+		}
+	}
+	if !reflect.DeepEqual(offered, []string{"gpt-4o", "gpt-4o-mini"}) {
+		t.Errorf("Expected the served models offered, got %v", offered)
+	}
+}
@@ -0,0 +1,297 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// rateWindow is the period per-minute limits are counted over.
+const rateWindow = time.Minute
+
+// ProviderLimits bounds the traffic the service sends one provider. Requests
+// over a limit wait for room rather than fail. Zero fields are unlimited.
+type ProviderLimits struct {
+	// RequestsPerMinute caps requests started in any minute
+	RequestsPerMinute int `json:"requests_per_minute"`
+
+	// TokensPerMinute caps the tokens of requests started in any minute.
+	// A request is counted at its estimate (prompt plus max_tokens) until
+	// the provider reports what it used.
+	TokensPerMinute int `json:"tokens_per_minute"`
+
+	// MaxConcurrent caps requests in flight at once
+	MaxConcurrent int `json:"max_concurrent"`
+}
+
+// ProviderUtilization reports a provider's traffic against its limits, as
+// the get_limits operation returns it.
+type ProviderUtilization struct {
+	Provider           string         `json:"provider"`
+	Limits             ProviderLimits `json:"limits"`
+	RequestsLastMinute int            `json:"requests_last_minute"`
+	TokensLastMinute   int            `json:"tokens_last_minute"`
+	InFlight           int            `json:"in_flight"`
+	Waiting            int            `json:"waiting"`
+
+	// PausedUntil is when requests resume after the provider answered 429
+	// with a Retry-After header (zero if not paused)
+	PausedUntil time.Time `json:"paused_until,omitempty"`
+}
+
+// rateEntry is one request counted against the per-minute limits.
+type rateEntry struct {
+	id     uint64
+	at     time.Time
+	tokens int
+}
+
+// providerLimiter holds one provider's requests to its limits.
+type providerLimiter struct {
+	mu          sync.Mutex
+	limits      ProviderLimits
+	slots       chan struct{} // Semaphore of MaxConcurrent slots; nil if unlimited
+	window      []rateEntry   // Requests of the last minute, oldest first
+	nextID      uint64
+	inFlight    int
+	waiting     int
+	pausedUntil time.Time
+}
+
+// newProviderLimiter creates a limiter enforcing limits.
+func newProviderLimiter(limits ProviderLimits) *providerLimiter {
+	limiter := &providerLimiter{limits: limits}
+	if limits.MaxConcurrent > 0 {
+		limiter.slots = make(chan struct{}, limits.MaxConcurrent)
+	}
+	return limiter
+}
+
+// acquire waits until a request estimated at tokens fits the limits. The
+// returned release must be called once the request is over, with the tokens
+// it actually used. A wait that would outlast ctx's deadline fails at once
+// with an error matching ErrTimeout.
+func (l *providerLimiter) acquire(ctx context.Context, provider string, tokens int) (func(used int), error) {
+	l.mu.Lock()
+	l.waiting++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for a %s request slot: %w", provider, ctx.Err())
+		}
+	}
+	releaseSlot := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	for {
+		l.mu.Lock()
+		id, wait := l.reserve(time.Now(), tokens)
+		l.mu.Unlock()
+
+		if wait == 0 {
+			return func(used int) {
+				l.mu.Lock()
+				l.inFlight--
+				for i := range l.window {
+					if l.window[i].id == id {
+						l.window[i].tokens = used
+						break
+					}
+				}
+				l.mu.Unlock()
+				releaseSlot()
+			}, nil
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			releaseSlot()
+			err := errs.Newf(errs.Timeout, "%s rate limits leave no room before the request's deadline (next in %s)", provider, wait.Round(time.Millisecond))
+			err.Err = ErrTimeout
+			return nil, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			releaseSlot()
+			return nil, fmt.Errorf("waiting for %s rate limits: %w", provider, ctx.Err())
+		}
+	}
+}
+
+// reserve counts a request estimated at tokens if it fits the limits at
+// now, returning its ID, or returns how long to wait before trying again.
+// The caller holds l.mu.
+func (l *providerLimiter) reserve(now time.Time, tokens int) (uint64, time.Duration) {
+	l.prune(now)
+
+	if now.Before(l.pausedUntil) {
+		return 0, l.pausedUntil.Sub(now)
+	}
+	if limit := l.limits.RequestsPerMinute; limit > 0 && len(l.window) >= limit {
+		return 0, l.window[len(l.window)-limit].at.Add(rateWindow).Sub(now)
+	}
+	if limit := l.limits.TokensPerMinute; limit > 0 {
+		used := l.tokensUsed()
+		// A request larger than the whole limit goes once the window is empty
+		if used > 0 && used+tokens > limit {
+			for _, entry := range l.window {
+				used -= entry.tokens
+				if used+tokens <= limit || used <= 0 {
+					return 0, entry.at.Add(rateWindow).Sub(now)
+				}
+			}
+		}
+	}
+
+	l.nextID++
+	l.window = append(l.window, rateEntry{id: l.nextID, at: now, tokens: tokens})
+	l.inFlight++
+	return l.nextID, 0
+}
+
+// prune drops requests that left the window. The caller holds l.mu.
+func (l *providerLimiter) prune(now time.Time) {
+	cutoff := now.Add(-rateWindow)
+	kept := 0
+	for kept < len(l.window) && !l.window[kept].at.After(cutoff) {
+		kept++
+	}
+	l.window = l.window[kept:]
+}
+
+// tokensUsed totals the tokens in the window. The caller holds l.mu.
+func (l *providerLimiter) tokensUsed() int {
+	total := 0
+	for _, entry := range l.window {
+		total += entry.tokens
+	}
+	return total
+}
+
+// pause holds back requests until the provider's Retry-After has passed.
+func (l *providerLimiter) pause(wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(wait); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// utilization reports the limiter's current traffic.
+func (l *providerLimiter) utilization(provider string) ProviderUtilization {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+	report := ProviderUtilization{
+		Provider:           provider,
+		Limits:             l.limits,
+		RequestsLastMinute: len(l.window),
+		TokensLastMinute:   l.tokensUsed(),
+		InFlight:           l.inFlight,
+		Waiting:            l.waiting,
+	}
+	if now.Before(l.pausedUntil) {
+		report.PausedUntil = l.pausedUntil
+	}
+	return report
+}
+
+// SetProviderLimits sets the limits requests to a provider are held to,
+// replacing any earlier ones. Zero limits remove them.
+func (llm *LLMService) SetProviderLimits(provider string, limits ProviderLimits) {
+	llm.limitsMu.Lock()
+	defer llm.limitsMu.Unlock()
+	if limits == (ProviderLimits{}) {
+		delete(llm.limiters, provider)
+		return
+	}
+	llm.limiters[provider] = newProviderLimiter(limits)
+}
+
+// limiter returns the provider's limiter, or nil if it is unlimited.
+func (llm *LLMService) limiter(provider string) *providerLimiter {
+	llm.limitsMu.RLock()
+	defer llm.limitsMu.RUnlock()
+	return llm.limiters[provider]
+}
+
+// throttled runs one request to a provider within its limits. The request
+// is counted at estimate tokens until its response reports the real usage.
+// A 429 with a Retry-After header pauses every request to the provider. The
+// outcome counts toward the provider's health, and a provider out of service
+// is not sent the request at all.
+func (llm *LLMService) throttled(ctx context.Context, provider string, estimate int, fn func() (interface{}, error)) (interface{}, error) {
+	if !llm.providerHealthy(provider) {
+		return nil, llm.unhealthyError(provider)
+	}
+
+	limiter := llm.limiter(provider)
+	if limiter == nil {
+		result, err := fn()
+		llm.recordOutcome(ctx, provider, err)
+		return result, err
+	}
+
+	release, err := limiter.acquire(ctx, provider, estimate)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := fn()
+	llm.recordOutcome(ctx, provider, err)
+	used := 0
+	switch response := result.(type) {
+	case *CompletionResponse:
+		used = response.TokensUsed
+	case *EmbeddingResponse:
+		used = response.TokensUsed
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		limiter.pause(apiErr.RetryAfter)
+	}
+	release(used)
+	return result, err
+}
+
+// getLimits reports every provider's traffic against its limits, by
+// provider name.
+func (llm *LLMService) getLimits(ctx context.Context, params ServiceParams) ServiceResult {
+	providers := llm.providerSet()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := make([]ProviderUtilization, 0, len(names))
+	for _, name := range names {
+		if limiter := llm.limiter(name); limiter != nil {
+			report = append(report, limiter.utilization(name))
+		} else {
+			report = append(report, ProviderUtilization{Provider: name})
+		}
+	}
+	return SuccessResult(report)
+}
@@ -0,0 +1,91 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProviderLimiter_RequestsPerMinute(t *testing.T) {
+	limiter := newProviderLimiter(ProviderLimits{RequestsPerMinute: 2})
+	start := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if _, wait := limiter.reserve(start.Add(time.Duration(i)*time.Second), 0); wait != 0 {
+			t.Fatalf("Expected request %d to fit, got a wait of %s", i+1, wait)
+		}
+	}
+	// The third request waits until the first leaves the window
+	if _, wait := limiter.reserve(start.Add(10*time.Second), 0); wait != 50*time.Second {
+		t.Errorf("Expected a 50s wait, got %s", wait)
+	}
+	if _, wait := limiter.reserve(start.Add(rateWindow+time.Millisecond), 0); wait != 0 {
+		t.Errorf("Expected room once the first request left the window, got a wait of %s", wait)
+	}
+}
+
+func TestProviderLimiter_TokensPerMinute(t *testing.T) {
+	limiter := newProviderLimiter(ProviderLimits{TokensPerMinute: 1000})
+	start := time.Now()
+
+	limiter.reserve(start, 600)
+	limiter.reserve(start.Add(5*time.Second), 300)
+	if _, wait := limiter.reserve(start.Add(10*time.Second), 200); wait != 50*time.Second {
+		t.Errorf("Expected to wait for the first request's tokens, got %s", wait)
+	}
+	if _, wait := limiter.reserve(start.Add(10*time.Second), 800); wait != 55*time.Second {
+		t.Errorf("Expected to wait for both requests' tokens, got %s", wait)
+	}
+
+	// A request larger than the limit still goes once the window is empty
+	if _, wait := limiter.reserve(start.Add(2*rateWindow), 5000); wait != 0 {
+		t.Errorf("Expected an oversized request to go alone, got a wait of %s", wait)
+	}
+}
+
+func TestProviderLimiter_ReleaseRecordsUsage(t *testing.T) {
+	limiter := newProviderLimiter(ProviderLimits{TokensPerMinute: 1000, MaxConcurrent: 1})
+	ctx := context.Background()
+
+	release, err := limiter.acquire(ctx, "test", 900)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	if report := limiter.utilization("test"); report.InFlight != 1 || report.TokensLastMinute != 900 {
+		t.Errorf("Expected the estimate counted while in flight, got %+v", report)
+	}
+	release(120)
+	if report := limiter.utilization("test"); report.InFlight != 0 || report.TokensLastMinute != 120 || report.RequestsLastMinute != 1 {
+		t.Errorf("Expected the reported usage to replace the estimate, got %+v", report)
+	}
+
+	// The slot was returned, so the next request needs no wait
+	release, err = limiter.acquire(ctx, "test", 500)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	release(500)
+}
+
+func TestProviderLimiter_PauseAndDeadline(t *testing.T) {
+	limiter := newProviderLimiter(ProviderLimits{RequestsPerMinute: 100})
+	limiter.pause(time.Hour)
+	if report := limiter.utilization("test"); report.PausedUntil.IsZero() {
+		t.Error("Expected the pause to be reported")
+	}
+
+	// A wait that would outlast the deadline fails at once
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	started := time.Now()
+	if _, err := limiter.acquire(ctx, "test", 0); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the request to fail without waiting, took %s", elapsed)
+	}
+	if report := limiter.utilization("test"); report.Waiting != 0 || report.InFlight != 0 {
+		t.Errorf("Expected nothing left waiting or in flight, got %+v", report)
+	}
+}
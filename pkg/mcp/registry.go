@@ -13,9 +13,11 @@ import (
 // ServiceRegistry manages a collection of MCP services and provides
 // service discovery and execution capabilities.
 type ServiceRegistry struct {
-	services map[string]Service
-	mutex    sync.RWMutex
-	logger   *log.Logger
+	services   map[string]Service
+	middleware []Middleware
+	handler    ServiceHandler // callService wrapped in middleware
+	mutex      sync.RWMutex
+	logger     *log.Logger
 }
 
 // ServiceInfo contains metadata about a registered service.
@@ -31,10 +33,12 @@ func NewServiceRegistry(logger *log.Logger) *ServiceRegistry {
 		logger = log.Default()
 	}
 
-	return &ServiceRegistry{
+	sr := &ServiceRegistry{
 		services: make(map[string]Service),
 		logger:   logger,
 	}
+	sr.handler = sr.callService
+	return sr
 }
 
 // RegisterService adds a service to the registry.
@@ -123,9 +127,41 @@ func (sr *ServiceRegistry) ServiceExists(name string) bool {
 	return exists
 }
 
+// RegisterMiddleware wraps every later call through the registry in
+// middleware. Middleware registered first is outermost, so it sees each
+// call first and its result last. Calls already in progress finish with
+// the middleware they started with.
+func (sr *ServiceRegistry) RegisterMiddleware(middleware Middleware) error {
+	if middleware == nil {
+		return fmt.Errorf("middleware cannot be nil")
+	}
+
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	sr.middleware = append(sr.middleware, middleware)
+	handler := ServiceHandler(sr.callService)
+	for i := len(sr.middleware) - 1; i >= 0; i-- {
+		handler = sr.middleware[i](handler)
+	}
+	sr.handler = handler
+
+	return nil
+}
+
 // CallService executes a service by name with the given parameters.
 // This is the primary interface for executing MCP services through the registry.
 func (sr *ServiceRegistry) CallService(ctx context.Context, serviceName string, params ServiceParams) ServiceResult {
+	sr.mutex.RLock()
+	handler := sr.handler
+	sr.mutex.RUnlock()
+
+	return handler(ctx, serviceName, params)
+}
+
+// callService is the handler at the centre of the middleware, which finds
+// the service and executes it.
+func (sr *ServiceRegistry) callService(ctx context.Context, serviceName string, params ServiceParams) ServiceResult {
 	// Get the service
 	service, exists := sr.GetService(serviceName)
 	if !exists {
@@ -0,0 +1,225 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ResponseSchema asks a completion for a JSON reply. Without a Schema any
+// JSON object will do; with one the reply must match it. Schemas use the
+// type, properties, required, items, enum, minimum and maximum keywords of
+// JSON Schema; other keywords are passed to providers but not checked.
+type ResponseSchema struct {
+	// Name identifies the schema to providers that ask for one
+	Name string `json:"name,omitempty"`
+
+	// Schema is the JSON schema of the reply (nil: any JSON object)
+	Schema map[string]interface{} `json:"schema,omitempty"`
+}
+
+// JSONMode returns a ResponseSchema accepting any JSON object.
+func JSONMode() *ResponseSchema {
+	return &ResponseSchema{Name: "json"}
+}
+
+// name returns the schema's name, or "response".
+func (s *ResponseSchema) name() string {
+	if s.Name == "" {
+		return "response"
+	}
+	return s.Name
+}
+
+// objectSchema returns the schema, or one for any object in JSON mode.
+func (s *ResponseSchema) objectSchema() map[string]interface{} {
+	if s.Schema == nil {
+		return map[string]interface{}{"type": "object"}
+	}
+	return s.Schema
+}
+
+// Instruction returns the text added to prompts for providers without a
+// structured output mode of their own.
+func (s *ResponseSchema) Instruction() string {
+	if s.Schema == nil {
+		return "Reply with a single JSON object and nothing else."
+	}
+	schema, _ := json.Marshal(s.Schema)
+	return "Reply with a single JSON value matching this JSON schema and nothing else:\n" + string(schema)
+}
+
+// Parse decodes a reply, ignoring surrounding whitespace and a Markdown
+// code fence, and checks it against the schema. It returns the JSON text
+// that was checked.
+func (s *ResponseSchema) Parse(text string) (string, interface{}, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") && strings.HasSuffix(text, "```") && len(text) > 6 {
+		text = strings.TrimSuffix(text, "```")
+		if newline := strings.Index(text, "\n"); newline >= 0 {
+			text = text[newline+1:]
+		} else {
+			text = strings.TrimPrefix(text, "```")
+		}
+		text = strings.TrimSpace(text)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return text, nil, fmt.Errorf("reply is not valid JSON: %w", err)
+	}
+	if err := validateSchema(value, s.objectSchema(), "$"); err != nil {
+		return text, nil, err
+	}
+	return text, value, nil
+}
+
+// withInstruction returns request with the schema's instruction added, for
+// providers that can only be asked for JSON in the prompt: after the prompt,
+// or as a leading system message in a conversation.
+func (s *ResponseSchema) withInstruction(request CompletionRequest) CompletionRequest {
+	if len(request.Messages) == 0 {
+		request.Prompt += "\n\n" + s.Instruction()
+		return request
+	}
+	messages := make([]Message, 0, len(request.Messages)+1)
+	messages = append(messages, Message{Role: RoleSystem, Content: s.Instruction()})
+	request.Messages = append(messages, request.Messages...)
+	return request
+}
+
+// repairPrompt asks the model to correct a reply that failed Parse.
+func (s *ResponseSchema) repairPrompt(cause error) string {
+	return fmt.Sprintf("Your reply could not be used: %v.\n%s", cause, s.Instruction())
+}
+
+// validateSchema checks value against schema, naming the offending part of
+// the value by its path.
+func validateSchema(value interface{}, schema map[string]interface{}, path string) error {
+	if allowed, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range allowed {
+			if fmt.Sprint(option) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s must be one of %v", path, allowed)
+		}
+	}
+
+	switch schemaType, _ := schema["type"].(string); schemaType {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, key := range schemaStrings(schema["required"]) {
+			if _, exists := object[key]; !exists {
+				return fmt.Errorf("%s is missing %q", path, key)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(properties))
+		for key := range properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, _ := properties[key].(map[string]interface{})
+			if field, exists := object[key]; exists && property != nil {
+				if err := validateSchema(field, property, path+"."+key); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				if err := validateSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	case "number", "integer":
+		number, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+		if schemaType == "integer" && number != float64(int64(number)) {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+		if minimum, ok := schemaNumber(schema["minimum"]); ok && number < minimum {
+			return fmt.Errorf("%s must be at least %v", path, minimum)
+		}
+		if maximum, ok := schemaNumber(schema["maximum"]); ok && number > maximum {
+			return fmt.Errorf("%s must be at most %v", path, maximum)
+		}
+	}
+	return nil
+}
+
+// schemaNumber reads a number from a schema keyword.
+func schemaNumber(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int:
+		return float64(number), true
+	}
+	return 0, false
+}
+
+// schemaStrings reads a list of strings from a schema keyword.
+func schemaStrings(value interface{}) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		strs := make([]string, 0, len(list))
+		for _, item := range list {
+			if str, ok := item.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// responseSchemaParam reads the response_schema parameter: a
+// *ResponseSchema, a JSON schema as a map, or "json" for JSON mode. It
+// returns nil when the parameter is absent.
+func responseSchemaParam(params ServiceParams) (*ResponseSchema, error) {
+	value, exists := params["response_schema"]
+	if !exists || value == nil {
+		return nil, nil
+	}
+	switch schema := value.(type) {
+	case *ResponseSchema:
+		return schema, nil
+	case ResponseSchema:
+		return &schema, nil
+	case map[string]interface{}:
+		return &ResponseSchema{Schema: schema}, nil
+	case string:
+		if schema == "json" {
+			return JSONMode(), nil
+		}
+	}
+	return nil, NewValidationError("response_schema", `response_schema must be a JSON schema or "json"`)
+}
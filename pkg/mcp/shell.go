@@ -0,0 +1,378 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+const (
+	// DefaultShellTimeout bounds how long a command runs by default
+	DefaultShellTimeout = 30 * time.Second
+
+	// DefaultShellOutputBytes is how much of each of a command's stdout and
+	// stderr is kept by default
+	DefaultShellOutputBytes = 64 * 1024
+)
+
+// DefaultShellCommands are the programs a shell service runs by default.
+var DefaultShellCommands = []string{
+	"ls", "pwd", "echo", "cat", "head", "tail", "grep", "find", "wc", "sort", "diff",
+	"git", "go", "mkdir", "touch", "cp", "mv",
+}
+
+// DefaultRiskyPatterns match the command lines a shell service has reviewed
+// before running them by default: ones that delete, overwrite, publish,
+// reach the network or change permissions.
+var DefaultRiskyPatterns = []string{
+	`^(rm|rmdir|shred|dd|truncate)\b`,
+	`^find\b.*\s-(delete|exec|execdir)\b`,
+	`^git\s+(push|reset\s+--hard|clean|rebase|checkout\s+--)\b`,
+	`^(curl|wget|ssh|scp|rsync)\b`,
+	`^(npm|pip|pip3|go)\s+(install|publish|get)\b`,
+	`^(chmod|chown|kill|pkill|killall)\b`,
+	`\s--force\b`,
+}
+
+// ShellConfig configures a shell service.
+type ShellConfig struct {
+	// Root is the directory commands run in; working directories must lie
+	// beneath it ("": the current directory)
+	Root string
+
+	// AllowedCommands are the programs that may be run, by name
+	// (nil: DefaultShellCommands)
+	AllowedCommands []string
+
+	// Timeouts bounds how long each program may run, by name
+	Timeouts map[string]time.Duration
+
+	// DefaultTimeout bounds programs without a timeout of their own
+	// (0: DefaultShellTimeout)
+	DefaultTimeout time.Duration
+
+	// MaxOutputBytes is how much of each of stdout and stderr is kept; the
+	// rest is discarded (0: DefaultShellOutputBytes)
+	MaxOutputBytes int
+
+	// RiskyPatterns are regular expressions over the command line; commands
+	// matching one are reviewed before they run (nil: DefaultRiskyPatterns)
+	RiskyPatterns []string
+}
+
+// CommandReviewer decides whether a risky command line may run, returning
+// an error when it may not. risk is the pattern the command matched.
+type CommandReviewer func(ctx context.Context, commandLine, risk string) error
+
+// ShellResult represents a command run, or one that would be run.
+type ShellResult struct {
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	WorkingDir string   `json:"working_dir"`
+	Timeout    string   `json:"timeout"`
+
+	// Risk is the risky pattern the command matched, if any, and Reviewed
+	// whether it was cleared to run
+	Risk     string `json:"risk,omitempty"`
+	Reviewed bool   `json:"reviewed"`
+
+	// DryRun is set when the command was only checked, not run
+	DryRun bool `json:"dry_run"`
+
+	ExitCode        int    `json:"exit_code"`
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	StdoutTruncated bool   `json:"stdout_truncated"`
+	StderrTruncated bool   `json:"stderr_truncated"`
+	TimedOut        bool   `json:"timed_out"`
+	Duration        string `json:"duration"`
+}
+
+// ShellService runs allowed programs under a root directory as an MCP
+// service, with the "run" operation. Programs are run directly, not through
+// a shell, so their arguments are never expanded or piped. Each run is bounded
+// by its program's timeout and keeps only so much output, and command lines
+// matching a risky pattern run only once the reviewer clears them.
+type ShellService struct {
+	*BaseService
+	root           string
+	allowed        map[string]bool
+	timeouts       map[string]time.Duration
+	defaultTimeout time.Duration
+	maxOutputBytes int
+	risky          []*regexp.Regexp
+	reviewer       CommandReviewer
+}
+
+// NewShellService creates a shell MCP service.
+func NewShellService(config ShellConfig, logger *log.Logger) (*ShellService, error) {
+	base := NewBaseService(
+		"shell",
+		"Runs allowed commands under a root directory with timeouts, capped output and review of risky commands",
+		logger,
+	)
+
+	root := config.Root
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shell root: %w", err)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, errs.Newf(errs.Validation, "shell root %s is not a directory", root)
+	}
+
+	commands := config.AllowedCommands
+	if commands == nil {
+		commands = DefaultShellCommands
+	}
+	allowed := make(map[string]bool, len(commands))
+	for _, command := range commands {
+		allowed[command] = true
+	}
+
+	patterns := config.RiskyPatterns
+	if patterns == nil {
+		patterns = DefaultRiskyPatterns
+	}
+	risky := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		if risky[i], err = regexp.Compile(pattern); err != nil {
+			return nil, errs.Newf(errs.Validation, "invalid risky pattern %q: %v", pattern, err)
+		}
+	}
+
+	service := &ShellService{
+		BaseService:    base,
+		root:           filepath.Clean(root),
+		allowed:        allowed,
+		timeouts:       config.Timeouts,
+		defaultTimeout: config.DefaultTimeout,
+		maxOutputBytes: config.MaxOutputBytes,
+		risky:          risky,
+	}
+	if service.defaultTimeout <= 0 {
+		service.defaultTimeout = DefaultShellTimeout
+	}
+	if service.maxOutputBytes <= 0 {
+		service.maxOutputBytes = DefaultShellOutputBytes
+	}
+	return service, nil
+}
+
+// SetReviewer sets what clears risky commands to run; nil refuses them all
+// with errs.ApprovalRequired.
+func (ss *ShellService) SetReviewer(reviewer CommandReviewer) {
+	ss.reviewer = reviewer
+}
+
+// ValidateParams validates parameters for the run operation: command, and
+// optionally args, working_dir, timeout (seconds, at most the program's own)
+// and dry_run.
+func (ss *ShellService) ValidateParams(params ServiceParams) error {
+	if err := ss.BaseService.ValidateParams(params); err != nil {
+		return err
+	}
+
+	if err := ValidateStringParam(params, "operation", true); err != nil {
+		return err
+	}
+	if operation := params["operation"].(string); operation != "run" {
+		return NewValidationError("operation", fmt.Sprintf("unsupported operation: %s", operation))
+	}
+
+	if err := ValidateStringParam(params, "command", true); err != nil {
+		return err
+	}
+	command := params["command"].(string)
+	if strings.ContainsRune(command, '/') || strings.ContainsRune(command, filepath.Separator) {
+		return NewValidationError("command", "command must be a program name, not a path")
+	}
+	if !ss.allowed[command] {
+		return NewValidationError("command", fmt.Sprintf("command '%s' is not in the allowed commands list", command))
+	}
+
+	if err := validateStringListParam(params, "args"); err != nil {
+		return err
+	}
+	if err := ValidateStringParam(params, "working_dir", false); err != nil {
+		return err
+	}
+	if _, err := ss.workingDir(params); err != nil {
+		return NewValidationError("working_dir", err.Error())
+	}
+
+	minTimeout := 1
+	maxTimeout := int(ss.timeoutFor(command) / time.Second)
+	if err := ValidateIntParam(params, "timeout", false, &minTimeout, &maxTimeout); err != nil {
+		return err
+	}
+
+	if dryRun, exists := params["dry_run"]; exists {
+		if _, ok := dryRun.(bool); !ok {
+			return NewValidationError("dry_run", "dry_run must be a boolean")
+		}
+	}
+	return nil
+}
+
+// Execute runs the command, or with dry_run set, reports what would be run
+// without running or reviewing it. A command that exits non-zero succeeds
+// with its exit code; one that runs past its timeout fails with errs.Timeout
+// and the output it produced.
+func (ss *ShellService) Execute(ctx context.Context, params ServiceParams) ServiceResult {
+	command := params["command"].(string)
+	args := stringListParam(params, "args")
+	workingDir, err := ss.workingDir(params)
+	if err != nil {
+		return ErrorResult(err)
+	}
+
+	timeout := ss.timeoutFor(command)
+	switch v := params["timeout"].(type) {
+	case int:
+		timeout = time.Duration(v) * time.Second
+	case float64:
+		timeout = time.Duration(v) * time.Second
+	}
+
+	commandLine := strings.Join(append([]string{command}, args...), " ")
+	result := &ShellResult{
+		Command:    command,
+		Args:       args,
+		WorkingDir: workingDir,
+		Timeout:    timeout.String(),
+		Risk:       ss.riskOf(commandLine),
+	}
+	if dryRun, _ := params["dry_run"].(bool); dryRun {
+		result.DryRun = true
+		return SuccessResult(result)
+	}
+
+	if result.Risk != "" {
+		if ss.reviewer == nil {
+			return ErrorResult(errs.Newf(errs.ApprovalRequired, "command '%s' is risky and needs review before it runs", commandLine).
+				With("command", commandLine).With("risk", result.Risk))
+		}
+		if err := ss.reviewer(ctx, commandLine, result.Risk); err != nil {
+			return ErrorResult(fmt.Errorf("command '%s' was not cleared to run: %w", commandLine, err))
+		}
+		result.Reviewed = true
+	}
+
+	return ss.run(ctx, result, timeout)
+}
+
+// run runs a command and records its outcome in result.
+func (ss *ShellService) run(ctx context.Context, result *ShellResult, timeout time.Duration) ServiceResult {
+	start := time.Now()
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &cappedBuffer{limit: ss.maxOutputBytes}
+	stderr := &cappedBuffer{limit: ss.maxOutputBytes}
+	cmd := exec.CommandContext(runCtx, result.Command, result.Args...)
+	cmd.Dir = result.WorkingDir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second // Don't wait on children still holding the output open
+
+	err := cmd.Run()
+	result.Duration = time.Since(start).String()
+	result.Stdout, result.StdoutTruncated = stdout.String(), stdout.truncated
+	result.Stderr, result.StderrTruncated = stderr.String(), stderr.truncated
+
+	if runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		result.TimedOut = true
+		result.ExitCode = -1
+		return ServiceResult{
+			Success:  false,
+			Data:     result,
+			Error:    errs.Newf(errs.Timeout, "command '%s' ran past its %s timeout", result.Command, timeout).With("command", result.Command),
+			Metadata: make(map[string]interface{}),
+		}
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && ctx.Err() == nil:
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return ErrorResult(fmt.Errorf("command execution failed: %w", err))
+	}
+	return SuccessResult(result)
+}
+
+// workingDir returns the directory a command is to run in: the root, or the
+// working_dir parameter resolved against it, which must lie beneath it.
+func (ss *ShellService) workingDir(params ServiceParams) (string, error) {
+	requested, _ := params["working_dir"].(string)
+	if requested == "" {
+		return ss.root, nil
+	}
+	dir := requested
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(ss.root, dir)
+	}
+	dir = filepath.Clean(dir)
+	if !withinDir(ss.root, dir) || !withinDir(resolveSymlinks(ss.root), resolveSymlinks(dir)) {
+		return "", errs.Newf(errs.PolicyBlocked, "working directory '%s' is outside the shell root", requested)
+	}
+	return dir, nil
+}
+
+// timeoutFor returns how long a program may run.
+func (ss *ShellService) timeoutFor(command string) time.Duration {
+	if timeout, ok := ss.timeouts[command]; ok && timeout > 0 {
+		return timeout
+	}
+	return ss.defaultTimeout
+}
+
+// riskOf returns the first risky pattern a command line matches, or "".
+func (ss *ShellService) riskOf(commandLine string) string {
+	for _, pattern := range ss.risky {
+		if pattern.MatchString(commandLine) {
+			return pattern.String()
+		}
+	}
+	return ""
+}
+
+// cappedBuffer keeps the first limit bytes written to it, discarding the
+// rest so a chatty command cannot exhaust memory. The buffer is not embedded,
+// so io.Copy cannot bypass Write through its ReadFrom.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write keeps what fits under the limit, reporting every byte as written so
+// the command is not stopped by a short write.
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns what was kept.
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,196 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+func newTestShell(t *testing.T, config ShellConfig) *ShellService {
+	t.Helper()
+	if config.Root == "" {
+		config.Root = t.TempDir()
+	}
+	service, err := NewShellService(config, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("NewShellService failed: %v", err)
+	}
+	return service
+}
+
+func runParams(command string, args ...string) ServiceParams {
+	return ServiceParams{"operation": "run", "command": command, "args": args}
+}
+
+func TestShellService_Run(t *testing.T) {
+	ctx := context.Background()
+	service := newTestShell(t, ShellConfig{AllowedCommands: []string{"echo", "ls", "pwd", "sh"}})
+	registry := NewServiceRegistry(log.New(io.Discard, "", 0))
+	if err := registry.RegisterService(service); err != nil {
+		t.Fatalf("Failed to register shell service: %v", err)
+	}
+
+	result := registry.CallService(ctx, "shell", runParams("echo", "hello", "$HOME"))
+	if !result.Success {
+		t.Fatalf("run failed: %v", result.Error)
+	}
+	run := result.Data.(*ShellResult)
+	if run.Stdout != "hello $HOME\n" || run.ExitCode != 0 || run.WorkingDir != service.root {
+		t.Errorf("Expected the arguments echoed unexpanded in the root, got %+v", run)
+	}
+
+	// A failing command still succeeds, with its exit code and stderr
+	result = registry.CallService(ctx, "shell", runParams("ls", "missing-file"))
+	if !result.Success {
+		t.Fatalf("run failed: %v", result.Error)
+	}
+	if run := result.Data.(*ShellResult); run.ExitCode == 0 || run.Stderr == "" {
+		t.Errorf("Expected a non-zero exit code and stderr, got %+v", run)
+	}
+
+	// Working directories resolve under the root
+	if err := os.Mkdir(filepath.Join(service.root, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	params := runParams("pwd")
+	params["working_dir"] = "sub"
+	result = registry.CallService(ctx, "shell", params)
+	if !result.Success || result.Data.(*ShellResult).WorkingDir != filepath.Join(service.root, "sub") {
+		t.Errorf("Expected pwd to run in sub, got %v, %v", result.Data, result.Error)
+	}
+}
+
+func TestShellService_RefusesInvalidCommands(t *testing.T) {
+	service := newTestShell(t, ShellConfig{AllowedCommands: []string{"echo"}})
+	outside := t.TempDir()
+	link := filepath.Join(service.root, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		params ServiceParams
+	}{
+		{"missing operation", ServiceParams{"command": "echo"}},
+		{"unknown operation", ServiceParams{"operation": "spawn", "command": "echo"}},
+		{"not allowed", runParams("cat")},
+		{"path to a program", runParams("/bin/echo")},
+		{"args not strings", ServiceParams{"operation": "run", "command": "echo", "args": []interface{}{1}}},
+		{"working dir outside root", ServiceParams{"operation": "run", "command": "echo", "working_dir": ".."}},
+		{"working dir through a link", ServiceParams{"operation": "run", "command": "echo", "working_dir": link}},
+		{"timeout above the command's", ServiceParams{"operation": "run", "command": "echo", "timeout": 31}},
+		{"dry_run not a boolean", ServiceParams{"operation": "run", "command": "echo", "dry_run": "yes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := service.ValidateParams(tt.params); errs.CodeOf(err) != errs.Validation {
+				t.Errorf("Expected a validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestShellService_CapsOutputAndTime(t *testing.T) {
+	ctx := context.Background()
+	service := newTestShell(t, ShellConfig{
+		AllowedCommands: []string{"sh"},
+		Timeouts:        map[string]time.Duration{"sh": 200 * time.Millisecond},
+		MaxOutputBytes:  10,
+	})
+
+	result := CallService(ctx, service, runParams("sh", "-c", "echo 0123456789abcdef; echo oops >&2"))
+	if !result.Success {
+		t.Fatalf("run failed: %v", result.Error)
+	}
+	run := result.Data.(*ShellResult)
+	if run.Stdout != "0123456789" || !run.StdoutTruncated || run.Stderr != "oops\n" || run.StderrTruncated {
+		t.Errorf("Expected stdout cut at 10 bytes and stderr whole, got %+v", run)
+	}
+
+	// The command's own timeout stops it, keeping what it wrote
+	start := time.Now()
+	result = CallService(ctx, service, runParams("sh", "-c", "echo started; sleep 5"))
+	if result.Success || errs.CodeOf(result.Error) != errs.Timeout {
+		t.Fatalf("Expected a timeout, got %v", result.Error)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected the command stopped at its timeout, took %s", elapsed)
+	}
+	if run := result.Data.(*ShellResult); !run.TimedOut || run.Stdout != "started\n" || run.Timeout != "200ms" {
+		t.Errorf("Expected the timed out run's output, got %+v", run)
+	}
+}
+
+func TestShellService_ReviewsRiskyCommands(t *testing.T) {
+	ctx := context.Background()
+	service := newTestShell(t, ShellConfig{AllowedCommands: []string{"rm", "git", "echo"}})
+	doomed := filepath.Join(service.root, "doomed.txt")
+	if err := os.WriteFile(doomed, []byte("bye"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Without a reviewer risky commands are refused
+	result := CallService(ctx, service, runParams("rm", "doomed.txt"))
+	if result.Success || errs.CodeOf(result.Error) != errs.ApprovalRequired {
+		t.Fatalf("Expected approval required, got %v", result.Error)
+	}
+
+	// A dry run says what would happen without asking or running
+	var reviewed []string
+	refusal := errors.New("not today")
+	service.SetReviewer(func(ctx context.Context, commandLine, risk string) error {
+		reviewed = append(reviewed, commandLine)
+		if strings.HasPrefix(commandLine, "git") {
+			return refusal
+		}
+		return nil
+	})
+	params := runParams("rm", "doomed.txt")
+	params["dry_run"] = true
+	result = CallService(ctx, service, params)
+	if run, _ := result.Data.(*ShellResult); !result.Success || !run.DryRun || run.Risk == "" || len(reviewed) != 0 {
+		t.Fatalf("Expected a dry run flagging the risk unreviewed, got %+v, %v", result.Data, result.Error)
+	}
+	if _, err := os.Stat(doomed); err != nil {
+		t.Fatalf("Expected the dry run to leave the file, got %v", err)
+	}
+
+	// Cleared commands run; refused ones do not
+	result = CallService(ctx, service, runParams("rm", "doomed.txt"))
+	if !result.Success || !result.Data.(*ShellResult).Reviewed {
+		t.Fatalf("Expected the reviewed command to run, got %v", result.Error)
+	}
+	if _, err := os.Stat(doomed); !os.IsNotExist(err) {
+		t.Errorf("Expected the file removed, got %v", err)
+	}
+	result = CallService(ctx, service, runParams("git", "push", "origin"))
+	if result.Success || !errors.Is(result.Error, refusal) {
+		t.Errorf("Expected the reviewer's refusal, got %v", result.Error)
+	}
+
+	// Safe commands skip review
+	if result := CallService(ctx, service, runParams("echo", "hi")); !result.Success {
+		t.Errorf("run failed: %v", result.Error)
+	}
+	if len(reviewed) != 2 || reviewed[1] != "git push origin" {
+		t.Errorf("Expected the two risky commands reviewed, got %v", reviewed)
+	}
+}
+
+func TestNewShellService_RejectsBadConfig(t *testing.T) {
+	if _, err := NewShellService(ShellConfig{Root: filepath.Join(t.TempDir(), "missing")}, nil); err == nil {
+		t.Error("Expected a missing root to be rejected")
+	}
+	if _, err := NewShellService(ShellConfig{Root: t.TempDir(), RiskyPatterns: []string{"("}}, nil); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+}
@@ -0,0 +1,704 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
+)
+
+const (
+	// DefaultWebUserAgent identifies the studio to the sites it fetches;
+	// robots.txt rules are matched against its product name
+	DefaultWebUserAgent = "AIWorkStudio/1.0 (+https://github.com/Solifugus/ai-work-studio)"
+
+	// DefaultWebMaxBytes is how much of a page is read by default
+	DefaultWebMaxBytes = 2 * 1024 * 1024
+
+	// DefaultWebTimeout bounds a fetch or search by default
+	DefaultWebTimeout = 30 * time.Second
+
+	// DefaultWebRequestsPerMinute is how many requests a host is sent per
+	// minute by default
+	DefaultWebRequestsPerMinute = 30
+
+	// robotsCacheTTL is how long a host's robots.txt is kept
+	robotsCacheTTL = time.Hour
+
+	// robotsMaxBytes is how much of a robots.txt is read
+	robotsMaxBytes = 512 * 1024
+)
+
+// WebConfig configures a web service.
+type WebConfig struct {
+	// UserAgent is sent with every request (DefaultWebUserAgent if empty)
+	UserAgent string
+
+	// MaxBytes is the most of a page that is read; fetches may ask for less
+	// (0: DefaultWebMaxBytes)
+	MaxBytes int64
+
+	// Timeout bounds each request (0: DefaultWebTimeout)
+	Timeout time.Duration
+
+	// RequestsPerMinute caps the requests sent one host, which wait for room
+	// rather than fail (0: DefaultWebRequestsPerMinute, negative: unlimited)
+	RequestsPerMinute int
+
+	// Search is the backend of the search operation; without one, searches
+	// fail with errs.ProviderUnavailable
+	Search SearchBackend
+}
+
+// WebPage represents a fetched page, reduced to its text.
+type WebPage struct {
+	URL         string `json:"url"`
+	FinalURL    string `json:"final_url"` // After redirects
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Title       string `json:"title"`
+	Text        string `json:"text"`
+
+	// Bytes is how much of the page was read, and Truncated whether the
+	// rest was left unread for the size cap
+	Bytes     int  `json:"bytes"`
+	Truncated bool `json:"truncated"`
+
+	// ContentHash is the SHA-256 of the text, so a page fetched again can
+	// be recognized as unchanged
+	ContentHash string    `json:"content_hash"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// WebSearchResult is one page a search found.
+type WebSearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// WebSearchResults represents the outcome of a search.
+type WebSearchResults struct {
+	Query   string            `json:"query"`
+	Backend string            `json:"backend"`
+	Results []WebSearchResult `json:"results"`
+	Count   int               `json:"count"`
+}
+
+// SearchBackend finds pages on the web for the search operation.
+type SearchBackend interface {
+	// Name identifies the backend, such as "searxng"
+	Name() string
+
+	// Search returns up to limit results for query, sending its requests
+	// with client
+	Search(ctx context.Context, client *http.Client, query string, limit int) ([]WebSearchResult, error)
+}
+
+// WebService retrieves information from the web as an MCP service, with the
+// "fetch" and "search" operations. Fetches honor robots.txt and a size cap
+// and return the page's text with its markup stripped; failures carry the
+// errs code of their cause, such as errs.NotFound for a 404 and errs.Timeout
+// for a request that ran too long. Requests to each host are rate limited,
+// and all of them go through the network auditor.
+type WebService struct {
+	*BaseService
+	config WebConfig
+	agent  string // The user agent's product name, for robots.txt
+	client *http.Client
+
+	mu       sync.Mutex
+	limiters map[string]*providerLimiter // By host
+	robots   map[string]*robotsRules     // By scheme and host
+}
+
+// NewWebService creates a web MCP service.
+func NewWebService(config WebConfig, logger *log.Logger) *WebService {
+	base := NewBaseService(
+		"web",
+		"Fetches web pages as text and searches the web, honoring robots.txt and per-host rate limits",
+		logger,
+	)
+
+	if config.UserAgent == "" {
+		config.UserAgent = DefaultWebUserAgent
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultWebMaxBytes
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultWebTimeout
+	}
+	if config.RequestsPerMinute == 0 {
+		config.RequestsPerMinute = DefaultWebRequestsPerMinute
+	}
+
+	service := &WebService{
+		BaseService: base,
+		config:      config,
+		agent:       strings.ToLower(strings.SplitN(config.UserAgent, "/", 2)[0]),
+		client:      netaudit.NewClient("web", config.Timeout),
+		limiters:    make(map[string]*providerLimiter),
+		robots:      make(map[string]*robotsRules),
+	}
+	// Redirects may lead to other hosts, whose robots.txt applies too
+	service.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errs.New(errs.Validation, "stopped after 10 redirects")
+		}
+		return service.checkRobots(req.Context(), req.URL)
+	}
+	return service
+}
+
+// ValidateParams validates parameters for the fetch operation (url, and
+// optionally max_bytes) and the search operation (query, and optionally
+// limit).
+func (ws *WebService) ValidateParams(params ServiceParams) error {
+	if err := ws.BaseService.ValidateParams(params); err != nil {
+		return err
+	}
+	if err := ValidateStringParam(params, "operation", true); err != nil {
+		return err
+	}
+
+	switch operation := params["operation"].(string); operation {
+	case "fetch":
+		if err := ValidateStringParam(params, "url", true); err != nil {
+			return err
+		}
+		if _, err := parseWebURL(params["url"].(string)); err != nil {
+			return NewValidationError("url", err.Error())
+		}
+		minBytes := 1
+		maxBytes := int(ws.config.MaxBytes)
+		return ValidateIntParam(params, "max_bytes", false, &minBytes, &maxBytes)
+
+	case "search":
+		if err := ValidateStringParam(params, "query", true); err != nil {
+			return err
+		}
+		minLimit := 1
+		maxLimit := 50
+		return ValidateIntParam(params, "limit", false, &minLimit, &maxLimit)
+
+	default:
+		return NewValidationError("operation", fmt.Sprintf("unsupported operation: %s", operation))
+	}
+}
+
+// Execute performs the requested web operation.
+func (ws *WebService) Execute(ctx context.Context, params ServiceParams) ServiceResult {
+	switch operation := params["operation"].(string); operation {
+	case "fetch":
+		return ws.fetch(ctx, params)
+	case "search":
+		return ws.search(ctx, params)
+	default:
+		return ErrorResult(fmt.Errorf("unsupported operation: %s", operation))
+	}
+}
+
+// fetch GETs a page and returns its text.
+func (ws *WebService) fetch(ctx context.Context, params ServiceParams) ServiceResult {
+	rawURL := params["url"].(string)
+	target, err := parseWebURL(rawURL)
+	if err != nil {
+		return ErrorResult(errs.Wrap(err, errs.Validation, map[string]interface{}{"url": rawURL}))
+	}
+	maxBytes := ws.config.MaxBytes
+	switch v := params["max_bytes"].(type) {
+	case int:
+		maxBytes = int64(v)
+	case float64:
+		maxBytes = int64(v)
+	}
+
+	if err := ws.checkRobots(ctx, target); err != nil {
+		return ErrorResult(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return ErrorResult(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("User-Agent", ws.config.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5")
+
+	resp, err := ws.throttled(ctx, target.Host, req)
+	if err != nil {
+		return ErrorResult(webRequestError(rawURL, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return ErrorResult(webStatusError(rawURL, resp.StatusCode))
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType != "" && !textContentType(contentType) {
+		return ErrorResult(errs.Newf(errs.Validation, "%s is %s, not text", rawURL, contentType).With("url", rawURL))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return ErrorResult(webRequestError(rawURL, err))
+	}
+	page := &WebPage{
+		URL:         rawURL,
+		FinalURL:    resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: contentType,
+		Truncated:   int64(len(body)) > maxBytes,
+		FetchedAt:   time.Now(),
+	}
+	if page.Truncated {
+		body = body[:maxBytes]
+	}
+	page.Bytes = len(body)
+
+	if contentType == "" || strings.Contains(contentType, "html") {
+		page.Title, page.Text = htmlToText(string(body))
+	} else {
+		page.Text = strings.TrimSpace(string(body))
+	}
+	sum := sha256.Sum256([]byte(page.Text))
+	page.ContentHash = hex.EncodeToString(sum[:])
+	return SuccessResult(page)
+}
+
+// search asks the search backend for pages matching a query.
+func (ws *WebService) search(ctx context.Context, params ServiceParams) ServiceResult {
+	backend := ws.config.Search
+	if backend == nil {
+		return ErrorResult(errs.New(errs.ProviderUnavailable, "no search backend is configured; set SEARXNG_URL or BRAVE_SEARCH_API_KEY"))
+	}
+	query := params["query"].(string)
+	limit := 10
+	switch v := params["limit"].(type) {
+	case int:
+		limit = v
+	case float64:
+		limit = int(v)
+	}
+
+	release, err := ws.limiter(backend.Name()).acquire(ctx, backend.Name(), 0)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	results, err := backend.Search(ctx, ws.client, query, limit)
+	release(0)
+	if err != nil {
+		return ErrorResult(fmt.Errorf("%s search failed: %w", backend.Name(), err))
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return SuccessResult(&WebSearchResults{
+		Query:   query,
+		Backend: backend.Name(),
+		Results: results,
+		Count:   len(results),
+	})
+}
+
+// throttled sends a request once the host's rate limit has room.
+func (ws *WebService) throttled(ctx context.Context, host string, req *http.Request) (*http.Response, error) {
+	release, err := ws.limiter(host).acquire(ctx, host, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer release(0)
+	return ws.client.Do(req)
+}
+
+// limiter returns the limiter of a host, or of a search backend.
+func (ws *WebService) limiter(host string) *providerLimiter {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	limiter, ok := ws.limiters[host]
+	if !ok {
+		limits := ProviderLimits{}
+		if ws.config.RequestsPerMinute > 0 {
+			limits.RequestsPerMinute = ws.config.RequestsPerMinute
+		}
+		limiter = newProviderLimiter(limits)
+		ws.limiters[host] = limiter
+	}
+	return limiter
+}
+
+// checkRobots fails with errs.PolicyBlocked when the site's robots.txt
+// disallows the URL to the service's user agent.
+func (ws *WebService) checkRobots(ctx context.Context, target *url.URL) error {
+	site := target.Scheme + "://" + target.Host
+	ws.mu.Lock()
+	rules, ok := ws.robots[site]
+	ws.mu.Unlock()
+	if !ok || time.Since(rules.fetched) > robotsCacheTTL {
+		rules = ws.fetchRobots(ctx, site)
+		ws.mu.Lock()
+		ws.robots[site] = rules
+		ws.mu.Unlock()
+	}
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	if !rules.allows(path) {
+		return errs.Newf(errs.PolicyBlocked, "robots.txt of %s disallows %s", target.Host, path).With("url", target.String())
+	}
+	return nil
+}
+
+// fetchRobots reads a site's robots.txt. A site without one, or that cannot
+// be reached, allows everything; one whose robots.txt fails with a server
+// error allows nothing.
+func (ws *WebService) fetchRobots(ctx context.Context, site string) *robotsRules {
+	rules := &robotsRules{fetched: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return rules
+	}
+	req.Header.Set("User-Agent", ws.config.UserAgent)
+
+	client := *ws.client
+	client.CheckRedirect = nil
+	resp, err := client.Do(req)
+	if err != nil {
+		return rules
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		rules.disallowAll = true
+	case resp.StatusCode < 300:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, robotsMaxBytes))
+		rules.rules = parseRobots(string(data), ws.agent)
+	}
+	return rules
+}
+
+// robotsRules are the robots.txt rules that apply to the service.
+type robotsRules struct {
+	rules       []robotsRule
+	disallowAll bool
+	fetched     time.Time
+}
+
+// robotsRule is one Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	length  int // Of the path pattern, for precedence
+	pattern *regexp.Regexp
+}
+
+// allows reports whether a path may be fetched: the longest matching rule
+// decides, an Allow winning a tie.
+func (r *robotsRules) allows(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || (rule.length == longest && rule.allow) {
+			allowed, longest = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
+
+// parseRobots returns the rules of a robots.txt for agent: those of the
+// groups naming it, or else those of the "*" groups.
+func parseRobots(data, agent string) []robotsRule {
+	var named, everyone []robotsRule
+	var agents []string
+	inRules := false
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // An empty Disallow allows everything
+			}
+			rule := robotsRule{allow: key == "allow", length: len(value), pattern: robotsPattern(value)}
+			for _, name := range agents {
+				switch {
+				case name == "*":
+					everyone = append(everyone, rule)
+				case strings.Contains(agent, name):
+					named = append(named, rule)
+				}
+			}
+		}
+	}
+	if named != nil {
+		return named
+	}
+	return everyone
+}
+
+// robotsPattern compiles a robots.txt path, in which "*" matches anything
+// and a trailing "$" anchors the end.
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+	pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(path), `\*`, ".*")
+	if anchored {
+		pattern += "$"
+	}
+	return regexp.MustCompile(pattern)
+}
+
+// parseWebURL parses an absolute http or https URL.
+func parseWebURL(rawURL string) (*url.URL, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("URL must be http or https, got %q", rawURL)
+	}
+	if target.Host == "" {
+		return nil, fmt.Errorf("URL has no host: %q", rawURL)
+	}
+	return target, nil
+}
+
+// webStatusError returns the error for a failed response: errs.NotFound for
+// 404 and 410, errs.ProviderAuth for 401 and 403, errs.QuotaExceeded for
+// 429, errs.ProviderUnavailable for server errors and errs.Validation for
+// other rejected requests.
+func webStatusError(rawURL string, status int) error {
+	code := errs.Validation
+	switch {
+	case status == http.StatusNotFound || status == http.StatusGone:
+		code = errs.NotFound
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		code = errs.ProviderAuth
+	case status == http.StatusTooManyRequests:
+		code = errs.QuotaExceeded
+	case status >= 500:
+		code = errs.ProviderUnavailable
+	}
+	return errs.Newf(code, "%s returned %d %s", rawURL, status, http.StatusText(status)).
+		With("url", rawURL).With("status", status)
+}
+
+// webRequestError returns the error for a request that got no response:
+// errors that already have a code, such as the auditor blocking the request
+// or the rate limit leaving no room before its deadline, as they are,
+// errs.Timeout for one that ran too long, and errs.ProviderUnavailable
+// otherwise.
+func webRequestError(rawURL string, err error) error {
+	if errs.CodeOf(err) != errs.Internal {
+		return err
+	}
+	var netErr net.Error
+	code := errs.ProviderUnavailable
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		code = errs.Timeout
+	}
+	return errs.Wrap(fmt.Errorf("failed to fetch %s: %w", rawURL, err), code, map[string]interface{}{"url": rawURL})
+}
+
+// textContentType reports whether a media type can be reduced to text.
+func textContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || strings.HasSuffix(contentType, "json") ||
+		strings.HasSuffix(contentType, "xml")
+}
+
+var (
+	htmlTitlePattern   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title\s*>`)
+	htmlCommentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlBlockPattern   = regexp.MustCompile(`(?i)</?(p|div|br|hr|li|ul|ol|dl|dt|dd|h[1-6]|tr|table|section|article|header|footer|nav|main|aside|pre|blockquote|form)\b[^>]*>`)
+	htmlCellPattern    = regexp.MustCompile(`(?i)</?(td|th)\b[^>]*>`)
+	htmlTagPattern     = regexp.MustCompile(`(?s)<[^>]*>`)
+
+	// htmlDropPatterns match the elements whose content is not text
+	htmlDropPatterns = func() []*regexp.Regexp {
+		var patterns []*regexp.Regexp
+		for _, tag := range []string{"head", "title", "script", "style", "noscript", "template", "svg"} {
+			patterns = append(patterns, regexp.MustCompile(`(?is)<`+tag+`\b[^>]*>.*?</`+tag+`\s*>`))
+		}
+		return patterns
+	}()
+)
+
+// htmlToText returns the title and the readable text of an HTML page: its
+// markup stripped, entities decoded and whitespace collapsed, with blocks
+// such as paragraphs and list items on lines of their own.
+func htmlToText(page string) (title, text string) {
+	if match := htmlTitlePattern.FindStringSubmatch(page); match != nil {
+		title = strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(match[1], ""))), " ")
+	}
+
+	page = htmlCommentPattern.ReplaceAllString(page, "")
+	for _, pattern := range htmlDropPatterns {
+		page = pattern.ReplaceAllString(page, "")
+	}
+	page = htmlBlockPattern.ReplaceAllString(page, "\n")
+	page = htmlCellPattern.ReplaceAllString(page, " ")
+	page = html.UnescapeString(htmlTagPattern.ReplaceAllString(page, ""))
+
+	var lines []string
+	for _, line := range strings.Split(page, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return title, strings.Join(lines, "\n")
+}
+
+// SearxNGBackend searches through a SearxNG instance's JSON API, which the
+// instance must have enabled.
+type SearxNGBackend struct {
+	// BaseURL is the instance's address, such as "http://localhost:8888"
+	BaseURL string
+}
+
+// Name implements SearchBackend.
+func (b *SearxNGBackend) Name() string {
+	return "searxng"
+}
+
+// Search implements SearchBackend.
+func (b *SearxNGBackend) Search(ctx context.Context, client *http.Client, query string, limit int) ([]WebSearchResult, error) {
+	endpoint := strings.TrimSuffix(b.BaseURL, "/") + "/search?" + url.Values{"q": {query}, "format": {"json"}}.Encode()
+	var reply struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getSearchJSON(ctx, client, endpoint, nil, &reply); err != nil {
+		return nil, err
+	}
+
+	results := make([]WebSearchResult, 0, len(reply.Results))
+	for _, result := range reply.Results {
+		results = append(results, WebSearchResult{Title: result.Title, URL: result.URL, Snippet: result.Content})
+	}
+	return results, nil
+}
+
+// BraveSearchBackend searches through the Brave Search API.
+type BraveSearchBackend struct {
+	APIKey string
+
+	// Endpoint is the API's web search address (the public API if empty)
+	Endpoint string
+}
+
+// Name implements SearchBackend.
+func (b *BraveSearchBackend) Name() string {
+	return "brave"
+}
+
+// Search implements SearchBackend.
+func (b *BraveSearchBackend) Search(ctx context.Context, client *http.Client, query string, limit int) ([]WebSearchResult, error) {
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.search.brave.com/res/v1/web/search"
+	}
+	endpoint += "?" + url.Values{"q": {query}, "count": {fmt.Sprint(limit)}}.Encode()
+	var reply struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := getSearchJSON(ctx, client, endpoint, map[string]string{"X-Subscription-Token": b.APIKey}, &reply); err != nil {
+		return nil, err
+	}
+
+	results := make([]WebSearchResult, 0, len(reply.Web.Results))
+	for _, result := range reply.Web.Results {
+		// Brave marks the query's terms in its descriptions
+		_, snippet := htmlToText(result.Description)
+		results = append(results, WebSearchResult{Title: result.Title, URL: result.URL, Snippet: snippet})
+	}
+	return results, nil
+}
+
+// getSearchJSON GETs a search API endpoint and decodes its JSON reply.
+func getSearchJSON(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, reply interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return webRequestError(endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return webStatusError(endpoint, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("failed to decode search results: %w", err)
+	}
+	return nil
+}
+
+// SearchBackendFromEnv returns the search backend the environment
+// configures: a SearxNG instance at SEARXNG_URL, or else the Brave Search
+// API with the key in BRAVE_SEARCH_API_KEY. It returns nil when neither is
+// set.
+func SearchBackendFromEnv() SearchBackend {
+	if baseURL := os.Getenv("SEARXNG_URL"); baseURL != "" {
+		return &SearxNGBackend{BaseURL: baseURL}
+	}
+	if apiKey := os.Getenv("BRAVE_SEARCH_API_KEY"); apiKey != "" {
+		return &BraveSearchBackend{APIKey: apiKey}
+	}
+	return nil
+}
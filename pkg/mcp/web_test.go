@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// newTestSite serves a small site with a robots.txt that keeps the studio
+// out of /private.
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /\n\nUser-agent: AIWorkStudio\nDisallow: /private\nAllow: /private/ok$\n")
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != DefaultWebUserAgent {
+			http.Error(w, "who are you", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `<html><head><title>Dark &amp; light</title><style>p {}</style></head>
+<body><!-- nav --><script>track()</script><h1>Themes</h1><p>Version 2 adds <b>dark</b> mode.</p>
+<ul><li>One</li><li>Two</li></ul><table><tr><td>a</td><td>b</td></tr></table></body></html>`)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusFound)
+	})
+	mux.HandleFunc("/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/private/ok", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<p>fine</p>")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fetchParams(url string) ServiceParams {
+	return ServiceParams{"operation": "fetch", "url": url}
+}
+
+func TestWebService_Fetch(t *testing.T) {
+	ctx := context.Background()
+	site := newTestSite(t)
+	service := NewWebService(WebConfig{}, log.New(io.Discard, "", 0))
+
+	result := CallService(ctx, service, fetchParams(site.URL+"/moved"))
+	if !result.Success {
+		t.Fatalf("fetch failed: %v", result.Error)
+	}
+	page := result.Data.(*WebPage)
+	if page.Title != "Dark & light" || page.FinalURL != site.URL+"/article" {
+		t.Errorf("Expected the redirected article's title, got %q from %s", page.Title, page.FinalURL)
+	}
+	if want := "Themes\nVersion 2 adds dark mode.\nOne\nTwo\na b"; page.Text != want {
+		t.Errorf("Expected the page's text\n%q, got\n%q", want, page.Text)
+	}
+
+	// The same text hashes the same, wherever it came from
+	again := CallService(ctx, service, fetchParams(site.URL+"/article"))
+	if !again.Success || again.Data.(*WebPage).ContentHash != page.ContentHash || len(page.ContentHash) != 64 {
+		t.Errorf("Expected the same content hash for the same page, got %v", again.Error)
+	}
+
+	params := fetchParams(site.URL + "/notes.txt")
+	params["max_bytes"] = 10
+	result = CallService(ctx, service, params)
+	if !result.Success {
+		t.Fatalf("fetch failed: %v", result.Error)
+	}
+	if page := result.Data.(*WebPage); page.Text != "xxxxxxxxxx" || !page.Truncated || page.Bytes != 10 {
+		t.Errorf("Expected the text cut at 10 bytes, got %+v", page)
+	}
+}
+
+func TestWebService_FetchFailures(t *testing.T) {
+	ctx := context.Background()
+	site := newTestSite(t)
+	service := NewWebService(WebConfig{Timeout: 200 * time.Millisecond}, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		path string
+		code errs.Code
+	}{
+		{"/missing", errs.NotFound},
+		{"/private/notes", errs.PolicyBlocked},
+		{"/image.png", errs.Validation},
+		{"/slow", errs.Timeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result := CallService(ctx, service, fetchParams(site.URL+tt.path))
+			if result.Success || errs.CodeOf(result.Error) != tt.code {
+				t.Errorf("Expected %s, got %v", tt.code, result.Error)
+			}
+		})
+	}
+
+	// The studio's own robots.txt group allows what it names
+	if result := CallService(ctx, service, fetchParams(site.URL+"/private/ok")); !result.Success {
+		t.Errorf("Expected the allowed page fetched, got %v", result.Error)
+	}
+
+	for _, url := range []string{"ftp://example.com/file", "/relative", "http://"} {
+		if err := service.ValidateParams(fetchParams(url)); errs.CodeOf(err) != errs.Validation {
+			t.Errorf("Expected %q rejected, got %v", url, err)
+		}
+	}
+}
+
+func TestWebService_RateLimitsPerHost(t *testing.T) {
+	site := newTestSite(t)
+	service := NewWebService(WebConfig{RequestsPerMinute: 1}, log.New(io.Discard, "", 0))
+
+	if result := CallService(context.Background(), service, fetchParams(site.URL+"/article")); !result.Success {
+		t.Fatalf("fetch failed: %v", result.Error)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	result := CallService(ctx, service, fetchParams(site.URL+"/notes.txt"))
+	if result.Success || errs.CodeOf(result.Error) != errs.Timeout {
+		t.Errorf("Expected the second request to the host to wait past its deadline, got %v", result.Error)
+	}
+}
+
+func TestWebService_Search(t *testing.T) {
+	ctx := context.Background()
+	searx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"results": [{"title": "About %s", "url": "https://example.com/1", "content": "first"},
+			{"title": "More", "url": "https://example.com/2", "content": "second"}]}`, r.URL.Query().Get("q"))
+	}))
+	defer searx.Close()
+	brave := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subscription-Token") != "secret" {
+			http.Error(w, "bad key", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"web": {"results": [{"title": "Brave", "url": "https://example.com/b", "description": "a <strong>dark</strong> mode"}]}}`)
+	}))
+	defer brave.Close()
+
+	service := NewWebService(WebConfig{Search: &SearxNGBackend{BaseURL: searx.URL + "/"}}, log.New(io.Discard, "", 0))
+	result := CallService(ctx, service, ServiceParams{"operation": "search", "query": "dark mode", "limit": 1})
+	if !result.Success {
+		t.Fatalf("search failed: %v", result.Error)
+	}
+	found := result.Data.(*WebSearchResults)
+	if found.Backend != "searxng" || found.Count != 1 || found.Results[0].Title != "About dark mode" {
+		t.Errorf("Expected the first SearxNG result, got %+v", found)
+	}
+
+	service = NewWebService(WebConfig{Search: &BraveSearchBackend{APIKey: "secret", Endpoint: brave.URL}}, log.New(io.Discard, "", 0))
+	result = CallService(ctx, service, ServiceParams{"operation": "search", "query": "dark mode"})
+	if !result.Success || result.Data.(*WebSearchResults).Results[0].Snippet != "a dark mode" {
+		t.Errorf("Expected the Brave result with its markup stripped, got %+v, %v", result.Data, result.Error)
+	}
+	service = NewWebService(WebConfig{Search: &BraveSearchBackend{APIKey: "wrong", Endpoint: brave.URL}}, log.New(io.Discard, "", 0))
+	if result := CallService(ctx, service, ServiceParams{"operation": "search", "query": "x"}); errs.CodeOf(result.Error) != errs.ProviderAuth {
+		t.Errorf("Expected a rejected key reported, got %v", result.Error)
+	}
+
+	service = NewWebService(WebConfig{}, log.New(io.Discard, "", 0))
+	if result := CallService(ctx, service, ServiceParams{"operation": "search", "query": "x"}); errs.CodeOf(result.Error) != errs.ProviderUnavailable {
+		t.Errorf("Expected search without a backend to fail, got %v", result.Error)
+	}
+}
+
+func TestSearchBackendFromEnv(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "")
+	t.Setenv("BRAVE_SEARCH_API_KEY", "")
+	if backend := SearchBackendFromEnv(); backend != nil {
+		t.Errorf("Expected no backend, got %s", backend.Name())
+	}
+	t.Setenv("BRAVE_SEARCH_API_KEY", "key")
+	if backend := SearchBackendFromEnv(); backend == nil || backend.Name() != "brave" {
+		t.Errorf("Expected the Brave backend, got %v", backend)
+	}
+	t.Setenv("SEARXNG_URL", "http://localhost:8888")
+	if backend := SearchBackendFromEnv(); backend == nil || backend.Name() != "searxng" {
+		t.Errorf("Expected SearxNG preferred, got %v", backend)
+	}
+}
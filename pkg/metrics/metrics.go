@@ -0,0 +1,308 @@
+// Package metrics keeps counters, gauges and histograms of the studio's LLM
+// requests, routing, spending and task execution, and serves them in the
+// Prometheus text format for graphing in tools such as Grafana.
+//
+// Recording is off until a registry is enabled, which the agent does when
+// it serves the endpoint; until then every Add, Set and Observe returns
+// after a single atomic load, so instrumented code pays nothing for it.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// kind is the Prometheus type of a metric.
+type kind string
+
+const (
+	kindCounter   kind = "counter"
+	kindGauge     kind = "gauge"
+	kindHistogram kind = "histogram"
+)
+
+// Registry holds metrics and writes them out for scraping.
+type Registry struct {
+	enabled atomic.Bool
+
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry creates a registry with recording disabled.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Enable turns recording on or off. Values recorded before it was turned
+// off are kept.
+func (r *Registry) Enable(enabled bool) {
+	r.enabled.Store(enabled)
+}
+
+// Enabled reports whether the registry records values.
+func (r *Registry) Enabled() bool {
+	return r.enabled.Load()
+}
+
+// Reset forgets every recorded value, keeping the metrics registered.
+func (r *Registry) Reset() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.families {
+		f.mu.Lock()
+		f.series = make(map[string]*series)
+		f.mu.Unlock()
+	}
+}
+
+// family is a metric and its series, one for each set of label values.
+type family struct {
+	registry *Registry
+	name     string
+	help     string
+	kind     kind
+	labels   []string
+	buckets  []float64 // Upper bounds, ascending; histograms only
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a metric for one set of label values.
+type series struct {
+	labels []string
+	value  float64  // Counters and gauges
+	counts []uint64 // Histograms: observations in each bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// register adds a metric, panicking on a name in use or an invalid name,
+// since both are mistakes in the code declaring it.
+func (r *Registry) register(name, help string, k kind, buckets []float64, labels []string) *family {
+	if !validName(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	for _, label := range labels {
+		if !validName(label) || label == "le" {
+			panic(fmt.Sprintf("metrics: invalid label name %q for %s", label, name))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.families[name]; exists {
+		panic(fmt.Sprintf("metrics: %s is already registered", name))
+	}
+	f := &family{
+		registry: r,
+		name:     name,
+		help:     help,
+		kind:     k,
+		labels:   labels,
+		buckets:  buckets,
+		series:   make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// update applies fn to the series for values under the family's lock.
+func (f *family) update(values []string, fn func(*series)) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes labels %v, got %d values", f.name, f.labels, len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, exists := f.series[key]
+	if !exists {
+		s = &series{labels: append([]string(nil), values...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// Counter is a value that only goes up, such as a number of requests.
+type Counter struct {
+	f *family
+}
+
+// NewCounter registers a counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{f: r.register(name, help, kindCounter, nil, labels)}
+}
+
+// Add adds v, which must not be negative, to the series for the label
+// values, given in the order the labels were registered.
+func (c *Counter) Add(v float64, labels ...string) {
+	if !c.f.registry.enabled.Load() || v < 0 {
+		return
+	}
+	c.f.update(labels, func(s *series) { s.value += v })
+}
+
+// Inc adds one to the series for the label values.
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Gauge is a value that goes up and down, such as the spending so far in a
+// budget period.
+type Gauge struct {
+	f *family
+}
+
+// NewGauge registers a gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{f: r.register(name, help, kindGauge, nil, labels)}
+}
+
+// Set sets the series for the label values to v.
+func (g *Gauge) Set(v float64, labels ...string) {
+	if !g.f.registry.enabled.Load() {
+		return
+	}
+	g.f.update(labels, func(s *series) { s.value = v })
+}
+
+// Histogram counts observations, such as latencies, into buckets.
+type Histogram struct {
+	f *family
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// which are sorted; observations above the last fall in the +Inf bucket.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &Histogram{f: r.register(name, help, kindHistogram, sorted, labels)}
+}
+
+// Observe records v in the series for the label values.
+func (h *Histogram) Observe(v float64, labels ...string) {
+	if !h.f.registry.enabled.Load() || math.IsNaN(v) {
+		return
+	}
+	h.f.update(labels, func(s *series) {
+		if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+			s.counts[i]++
+		}
+		s.sum += v
+		s.count++
+	})
+}
+
+// WriteTo writes every metric with a recorded value in the Prometheus text
+// exposition format, metrics sorted by name and series by label values.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.RUnlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// write appends the family's series to b; a family without any is left out.
+func (f *family) write(b *strings.Builder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.series) == 0 {
+		return
+	}
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].labels, "\xff") < strings.Join(all[j].labels, "\xff")
+	})
+
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.kind)
+	for _, s := range all {
+		if f.kind != kindHistogram {
+			fmt.Fprintf(b, "%s%s %s\n", f.name, f.labelSet(s.labels, ""), formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, f.labelSet(s.labels, formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, f.labelSet(s.labels, "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", f.name, f.labelSet(s.labels, ""), formatValue(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", f.name, f.labelSet(s.labels, ""), s.count)
+	}
+}
+
+// labelSet formats the label values as {name="value",...}, followed by the
+// le label of a histogram bucket when le is set.
+func (f *family) labelSet(values []string, le string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, value := range values {
+		pairs = append(pairs, f.labels[i]+`="`+escapeLabel(value)+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabel escapes backslashes, quotes and newlines in a label value.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// escapeHelp escapes backslashes and newlines in help text.
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+// formatValue formats a sample value as the text format expects.
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// validName reports whether name is a valid metric or label name.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		letter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !letter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
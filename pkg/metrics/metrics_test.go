@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	return b.String()
+}
+
+func TestRegistry_DisabledRecordsNothing(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests.", "provider")
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{1}, "provider")
+
+	requests.Inc("openai")
+	latency.Observe(0.5, "openai")
+	if out := scrape(t, r); out != "" {
+		t.Errorf("Expected nothing recorded while disabled, got:\n%s", out)
+	}
+
+	// Label values are not checked until recording, so a disabled registry
+	// does no work at all
+	requests.Inc()
+}
+
+func TestRegistry_TextFormat(t *testing.T) {
+	r := NewRegistry()
+	r.Enable(true)
+	requests := r.NewCounter("requests_total", "Requests, by outcome.", "provider", "status")
+	spend := r.NewGauge("spend_dollars", "Spending.", "period")
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.5}, "provider")
+	r.NewCounter("unused_total", "Never recorded.")
+
+	requests.Inc("openai", "success")
+	requests.Add(2, "anthropic", "success")
+	requests.Add(-1, "anthropic", "success")
+	requests.Inc("local", `bad "value"`+"\n")
+	spend.Set(3, "daily")
+	spend.Set(1.25, "daily")
+	latency.Observe(0.2, "openai")
+	latency.Observe(0.5, "openai")
+	latency.Observe(3, "openai")
+
+	want := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{provider="openai",le="0.5"} 2
+latency_seconds_bucket{provider="openai",le="1"} 2
+latency_seconds_bucket{provider="openai",le="+Inf"} 3
+latency_seconds_sum{provider="openai"} 3.7
+latency_seconds_count{provider="openai"} 3
+# HELP requests_total Requests, by outcome.
+# TYPE requests_total counter
+requests_total{provider="anthropic",status="success"} 2
+requests_total{provider="local",status="bad \"value\"\n"} 1
+requests_total{provider="openai",status="success"} 1
+# HELP spend_dollars Spending.
+# TYPE spend_dollars gauge
+spend_dollars{period="daily"} 1.25
+`
+	if out := scrape(t, r); out != want {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", out, want)
+	}
+
+	r.Reset()
+	if out := scrape(t, r); out != "" {
+		t.Errorf("Expected nothing after a reset, got:\n%s", out)
+	}
+}
+
+func TestRegistry_RejectsMistakes(t *testing.T) {
+	r := NewRegistry()
+	r.Enable(true)
+	requests := r.NewCounter("requests_total", "Requests.", "provider")
+
+	for name, fn := range map[string]func(){
+		"duplicate name":    func() { r.NewGauge("requests_total", "Again.") },
+		"invalid name":      func() { r.NewCounter("requests-total", "Dashed.") },
+		"reserved label":    func() { r.NewHistogram("sizes", "Sizes.", nil, "le") },
+		"wrong label count": func() { requests.Inc("openai", "gpt-4o") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for a %s", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestServer_ServesMetrics(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests.")
+	server := NewServer(r, "127.0.0.1:0")
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Close()
+
+	if !r.Enabled() {
+		t.Fatal("Expected serving to enable recording")
+	}
+	requests.Inc()
+
+	resp, err := http.Get("http://" + server.Addr() + Path)
+	if err != nil {
+		t.Fatalf("Scrape failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") ||
+		!strings.Contains(string(body), "requests_total 1\n") {
+		t.Errorf("Unexpected scrape: %s\n%s", resp.Header.Get("Content-Type"), body)
+	}
+
+	resp, err = http.Post("http://"+server.Addr()+Path, "text/plain", nil)
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected a post refused, got %d", resp.StatusCode)
+	}
+
+	server.Close()
+	if r.Enabled() {
+		t.Error("Expected closing to disable recording")
+	}
+}
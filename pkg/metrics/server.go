@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Path is where the endpoint serves metrics.
+const Path = "/metrics"
+
+// contentType is the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler serves the registry's metrics to GET requests.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		r.WriteTo(w)
+	})
+}
+
+// Server serves a registry's metrics over HTTP at Path.
+type Server struct {
+	registry *Registry
+	addr     string
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewServer creates a server for registry on addr. Call Start to listen.
+func NewServer(registry *Registry, addr string) *Server {
+	return &Server{registry: registry, addr: addr}
+}
+
+// Start listens for scrapes and enables recording to the registry.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics scrapes: %w", err)
+	}
+	s.listener = listener
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, s.registry.Handler())
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	s.registry.Enable(true)
+	go s.server.Serve(listener)
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.addr
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops listening and disables recording to the registry.
+func (s *Server) Close() error {
+	s.registry.Enable(false)
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Close()
+}
@@ -0,0 +1,63 @@
+package metrics
+
+// Default is the registry the studio's instrumentation records to and the
+// endpoint serves. It is disabled until a Server starts.
+var Default = NewRegistry()
+
+// LatencyBuckets are the upper bounds, in seconds, of request latencies.
+var LatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// TaskDurationBuckets are the upper bounds, in seconds, of task durations.
+var TaskDurationBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600}
+
+// EstimateRatioBuckets are the upper bounds of actual cost divided by
+// estimated cost: 1 is an exact estimate, below it an overestimate.
+var EstimateRatioBuckets = []float64{0.25, 0.5, 0.75, 0.9, 1.1, 1.25, 1.5, 2, 4}
+
+// LLM service, per provider and model. Status is "success" or the error
+// code of the failure.
+var (
+	LLMRequests = Default.NewCounter("studio_llm_requests_total",
+		"LLM completion requests, by outcome.", "provider", "model", "status")
+	LLMTokens = Default.NewCounter("studio_llm_tokens_total",
+		"Tokens used by LLM completions, by direction (input or output).", "provider", "model", "direction")
+	LLMCost = Default.NewCounter("studio_llm_cost_dollars_total",
+		"Cost of LLM completions in dollars.", "provider", "model")
+	LLMLatency = Default.NewHistogram("studio_llm_request_duration_seconds",
+		"Time taken by LLM completion requests, retries included.", LatencyBuckets, "provider", "model")
+)
+
+// Router. Policy is the routing policy's name, "default" for the router's
+// own weights; outcome is "routed" or "failed".
+var (
+	RoutingDecisions = Default.NewCounter("studio_router_decisions_total",
+		"Requests routed, by routing policy and outcome.", "policy", "outcome")
+	RoutingFallbacks = Default.NewCounter("studio_router_fallbacks_total",
+		"Models that failed or refused a request that was then tried with another.", "provider", "model")
+	CostEstimateRatio = Default.NewHistogram("studio_router_cost_estimate_ratio",
+		"Actual cost of routed requests divided by the cost estimated for them.", EstimateRatioBuckets, "provider")
+)
+
+// Budget manager. Period is "daily", "weekly" or "monthly".
+var (
+	BudgetSpend = Default.NewGauge("studio_budget_spend_dollars",
+		"Spending so far in the current budget period.", "period")
+	BudgetAlerts = Default.NewCounter("studio_budget_alerts_total",
+		"Budget alerts fired, by period and threshold percentage.", "period", "threshold")
+)
+
+// Real-time cursor. Status is the task's final status.
+var (
+	Tasks = Default.NewCounter("studio_tasks_total",
+		"Tasks executed, by final status.", "status")
+	TaskRetries = Default.NewCounter("studio_task_retries_total",
+		"Task attempts beyond the first.")
+	TaskDuration = Default.NewHistogram("studio_task_duration_seconds",
+		"Time taken by tasks, retries included.", TaskDurationBuckets, "status")
+)
+
+// Enabled reports whether the default registry records values, for
+// instrumentation that must do work to compute them.
+func Enabled() bool {
+	return Default.Enabled()
+}
@@ -0,0 +1,861 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// Archive segments hold the histories of nodes that have been moved out of the
+// live store, together with the edges they are the source of. Only the segment
+// index is read when the store opens; a segment file is read the first time one
+// of its nodes or edges is requested, or when a query includes archived data.
+// The index lives in data/archive/index.json, each segment in
+// data/archive/{segment}.json.
+const (
+	archiveDirName   = "archive"
+	archiveIndexName = "index.json"
+
+	// archiveSegmentNodes bounds the nodes per segment, so reading one archived
+	// node on demand only reads a small part of the archive
+	archiveSegmentNodes = 2000
+)
+
+// ArchiveResult describes what a call to ArchiveNodes moved out of the live store.
+type ArchiveResult struct {
+	Segments []string // Segments written; empty if nothing was archived
+	Nodes    int
+	Edges    int
+}
+
+// archiveSegment lists the contents of one segment file.
+type archiveSegment struct {
+	ID        string            `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Nodes     map[string]string `json:"nodes"` // map[nodeID]type
+	Edges     []string          `json:"edges"`
+	Versions  uint64            `json:"versions"` // Stored versions, for sequence numbering
+}
+
+// archiveIndex is the persisted list of segments.
+type archiveIndex struct {
+	NextSegment int               `json:"next_segment"`
+	Segments    []*archiveSegment `json:"segments"`
+	Compacted   uint64            `json:"compacted,omitempty"` // Versions removed by Compact, for sequence numbering
+}
+
+// archiveSegmentData is the content of a segment file.
+type archiveSegmentData struct {
+	Nodes []NodeHistory `json:"nodes"`
+	Edges []EdgeHistory `json:"edges"`
+}
+
+// archive tracks the archive segments of a store and caches those that have been read.
+// Its own mutex guards lazy loading, so readers holding the store's read lock can use it.
+type archive struct {
+	dir string
+	mu  sync.Mutex
+
+	index        archiveIndex
+	nodeSegments map[string]*archiveSegment // map[nodeID]segment
+	edgeSegments map[string]*archiveSegment // map[edgeID]segment
+
+	// Histories of segments that have been read
+	loaded      map[string]bool
+	recent      []*archiveSegment // Loaded segments, least recently read first
+	maxCached   int               // Segments kept loaded; 0 means all of them
+	nodes       map[string]NodeHistory
+	nodesByType map[string]map[string]NodeHistory
+	edges       map[string]EdgeHistory
+
+	// Set for read-only stores: the index is reconciled in memory only
+	readOnly bool
+}
+
+// newArchive creates the archive state for the given archive directory.
+func newArchive(dir string) *archive {
+	return &archive{
+		dir:          dir,
+		nodeSegments: make(map[string]*archiveSegment),
+		edgeSegments: make(map[string]*archiveSegment),
+		loaded:       make(map[string]bool),
+		nodes:        make(map[string]NodeHistory),
+		nodesByType:  make(map[string]map[string]NodeHistory),
+		edges:        make(map[string]EdgeHistory),
+	}
+}
+
+// loadIndex reads the segment index, if there is one.
+func (a *archive) loadIndex() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := decodeJSONFile(filepath.Join(a.dir, archiveIndexName), &a.index); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read archive index: %w", err)
+	}
+
+	for _, segment := range a.index.Segments {
+		for nodeID := range segment.Nodes {
+			a.nodeSegments[nodeID] = segment
+		}
+		for _, edgeID := range segment.Edges {
+			a.edgeSegments[edgeID] = segment
+		}
+	}
+	return nil
+}
+
+// versions returns the number of stored versions held in the archive, and
+// of those removed by Compact.
+func (a *archive) versions() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	total := a.index.Compacted
+	for _, segment := range a.index.Segments {
+		total += segment.Versions
+	}
+	return total
+}
+
+// hasNode reports whether the node is archived.
+func (a *archive) hasNode(nodeID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.nodeSegments[nodeID] != nil
+}
+
+// hasEdge reports whether the edge is archived.
+func (a *archive) hasEdge(edgeID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.edgeSegments[edgeID] != nil
+}
+
+// nodeTypes returns the types of all archived nodes.
+func (a *archive) nodeTypes() map[string]bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	types := make(map[string]bool)
+	for _, segment := range a.index.Segments {
+		for _, nodeType := range segment.Nodes {
+			types[nodeType] = true
+		}
+	}
+	return types
+}
+
+// node returns the history of an archived node, reading its segment if needed.
+func (a *archive) node(nodeID string) (NodeHistory, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	segment := a.nodeSegments[nodeID]
+	if segment == nil {
+		return nil, false, nil
+	}
+	if err := a.loadSegment(segment); err != nil {
+		return nil, false, err
+	}
+	history, exists := a.nodes[nodeID]
+	return history, exists, nil
+}
+
+// edge returns the history of an archived edge, reading its segment if needed.
+func (a *archive) edge(edgeID string) (EdgeHistory, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	segment := a.edgeSegments[edgeID]
+	if segment == nil {
+		return nil, false, nil
+	}
+	if err := a.loadSegment(segment); err != nil {
+		return nil, false, err
+	}
+	history, exists := a.edges[edgeID]
+	return history, exists, nil
+}
+
+// forEachNode reads every segment and calls fn for each archived node history.
+// If nodeType is not empty, only nodes of that type are visited. It stops
+// with ctx.Err() once ctx is done.
+func (a *archive) forEachNode(ctx context.Context, nodeType string, fn func(NodeHistory)) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	guard := &scanGuard{ctx: ctx}
+	if a.maxCached > 0 {
+		// Visit one segment at a time rather than loading the whole archive
+		for _, segment := range a.index.Segments {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := a.loadSegment(segment); err != nil {
+				return err
+			}
+			for nodeID, segmentType := range segment.Nodes {
+				if guard.stopped() {
+					return guard.err
+				}
+				if history, exists := a.nodes[nodeID]; exists && (nodeType == "" || segmentType == nodeType) {
+					fn(history)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := a.loadAll(ctx); err != nil {
+		return err
+	}
+
+	if nodeType != "" {
+		for _, history := range a.nodesByType[nodeType] {
+			if guard.stopped() {
+				return guard.err
+			}
+			fn(history)
+		}
+		return nil
+	}
+	for _, history := range a.nodes {
+		if guard.stopped() {
+			return guard.err
+		}
+		fn(history)
+	}
+	return nil
+}
+
+// forEachEdge reads every segment and calls fn for each archived edge history.
+// It stops with ctx.Err() once ctx is done.
+func (a *archive) forEachEdge(ctx context.Context, fn func(EdgeHistory)) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	guard := &scanGuard{ctx: ctx}
+	if a.maxCached > 0 {
+		for _, segment := range a.index.Segments {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := a.loadSegment(segment); err != nil {
+				return err
+			}
+			for _, edgeID := range segment.Edges {
+				if guard.stopped() {
+					return guard.err
+				}
+				if history, exists := a.edges[edgeID]; exists {
+					fn(history)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := a.loadAll(ctx); err != nil {
+		return err
+	}
+	for _, history := range a.edges {
+		if guard.stopped() {
+			return guard.err
+		}
+		fn(history)
+	}
+	return nil
+}
+
+// loadAll reads every segment that has not been read yet, stopping once ctx
+// is done. Callers hold a.mu.
+func (a *archive) loadAll(ctx context.Context) error {
+	for _, segment := range a.index.Segments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.loadSegment(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSegment reads a segment file into the cache. Callers hold a.mu.
+// Histories that have since been restored to the live store are skipped.
+// With maxCached set, the least recently read segments are evicted first.
+func (a *archive) loadSegment(segment *archiveSegment) error {
+	if a.loaded[segment.ID] {
+		a.touch(segment)
+		return nil
+	}
+	for a.maxCached > 0 && len(a.recent) >= a.maxCached {
+		a.evict(a.recent[0])
+	}
+
+	var data archiveSegmentData
+	if err := decodeJSONFile(a.segmentPath(segment.ID), &data); err != nil {
+		return fmt.Errorf("failed to read archive segment %s: %w", segment.ID, err)
+	}
+
+	for _, history := range data.Nodes {
+		current := history.GetCurrentVersion()
+		if current == nil || a.nodeSegments[current.ID] != segment {
+			continue
+		}
+		a.nodes[current.ID] = history
+		if a.nodesByType[current.Type] == nil {
+			a.nodesByType[current.Type] = make(map[string]NodeHistory)
+		}
+		a.nodesByType[current.Type][current.ID] = history
+	}
+	for _, history := range data.Edges {
+		if len(history) == 0 || a.edgeSegments[history[0].ID] != segment {
+			continue
+		}
+		a.edges[history[0].ID] = history
+	}
+
+	a.loaded[segment.ID] = true
+	a.recent = append(a.recent, segment)
+	return nil
+}
+
+// touch marks a loaded segment as the most recently read. Callers hold a.mu.
+func (a *archive) touch(segment *archiveSegment) {
+	for i, s := range a.recent {
+		if s == segment {
+			a.recent = append(append(a.recent[:i:i], a.recent[i+1:]...), segment)
+			return
+		}
+	}
+}
+
+// evict drops a loaded segment's histories from the cache. Callers hold a.mu.
+func (a *archive) evict(segment *archiveSegment) {
+	for nodeID := range segment.Nodes {
+		if history, exists := a.nodes[nodeID]; exists {
+			if current := history.GetCurrentVersion(); current != nil {
+				delete(a.nodesByType[current.Type], nodeID)
+			}
+			delete(a.nodes, nodeID)
+		}
+	}
+	for _, edgeID := range segment.Edges {
+		delete(a.edges, edgeID)
+	}
+	for i, s := range a.recent {
+		if s == segment {
+			a.recent = append(a.recent[:i:i], a.recent[i+1:]...)
+			break
+		}
+	}
+	delete(a.loaded, segment.ID)
+}
+
+// flush evicts every loaded segment and returns how many there were.
+func (a *archive) flush() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	dropped := len(a.recent)
+	for len(a.recent) > 0 {
+		a.evict(a.recent[0])
+	}
+	return dropped
+}
+
+// add writes new segments holding the given histories and records them in the index.
+func (a *archive) add(contents []archiveSegmentData) ([]*archiveSegment, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	var segments []*archiveSegment
+	removeWritten := func() {
+		for _, segment := range segments {
+			os.Remove(a.segmentPath(segment.ID))
+		}
+	}
+
+	nextSegment := a.index.NextSegment
+	for _, content := range contents {
+		nextSegment++
+		segment := &archiveSegment{
+			ID:        fmt.Sprintf("segment-%06d", nextSegment),
+			CreatedAt: time.Now(),
+			Nodes:     make(map[string]string, len(content.Nodes)),
+			Edges:     make([]string, 0, len(content.Edges)),
+		}
+		for _, history := range content.Nodes {
+			current := history.GetCurrentVersion()
+			segment.Nodes[current.ID] = current.Type
+			segment.Versions += uint64(len(history))
+		}
+		for _, history := range content.Edges {
+			segment.Edges = append(segment.Edges, history[0].ID)
+			segment.Versions += uint64(len(history))
+		}
+
+		data, err := json.Marshal(content)
+		if err != nil {
+			removeWritten()
+			return nil, fmt.Errorf("failed to serialize archive segment: %w", err)
+		}
+		if err := writeFileAtomic(a.segmentPath(segment.ID), data); err != nil {
+			removeWritten()
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+
+	previous := a.index
+	a.index.NextSegment = nextSegment
+	a.index.Segments = append(append([]*archiveSegment{}, previous.Segments...), segments...)
+	if err := a.saveIndex(); err != nil {
+		a.index = previous
+		removeWritten()
+		return nil, err
+	}
+
+	for _, segment := range segments {
+		for nodeID := range segment.Nodes {
+			a.nodeSegments[nodeID] = segment
+		}
+		for _, edgeID := range segment.Edges {
+			a.edgeSegments[edgeID] = segment
+		}
+	}
+	return segments, nil
+}
+
+// takeNode removes a node from the archive and returns its history, so it can
+// return to the live store.
+func (a *archive) takeNode(nodeID string) (NodeHistory, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	segment := a.nodeSegments[nodeID]
+	if segment == nil {
+		return nil, fmt.Errorf("node %s is not archived", nodeID)
+	}
+	if err := a.loadSegment(segment); err != nil {
+		return nil, err
+	}
+
+	history := a.nodes[nodeID]
+	a.forgetNode(segment, nodeID, len(history))
+	if err := a.saveIndex(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// takeEdge removes an edge from the archive and returns its history, so it can
+// return to the live store.
+func (a *archive) takeEdge(edgeID string) (EdgeHistory, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	segment := a.edgeSegments[edgeID]
+	if segment == nil {
+		return nil, fmt.Errorf("edge %s is not archived", edgeID)
+	}
+	if err := a.loadSegment(segment); err != nil {
+		return nil, err
+	}
+
+	history := a.edges[edgeID]
+	a.forgetEdge(segment, edgeID, len(history))
+	if err := a.saveIndex(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// dropLiveDuplicates forgets archived entries that also exist as live files,
+// which happens when archiving was interrupted before the live files were removed.
+// The live files are authoritative. Versions of the dropped entries are given by
+// the live histories, since archived histories never change.
+func (a *archive) dropLiveDuplicates(nodes map[string]NodeHistory, edges map[string]EdgeHistory) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changed := false
+	for nodeID, segment := range a.nodeSegments {
+		if history, live := nodes[nodeID]; live {
+			a.forgetNode(segment, nodeID, len(history))
+			changed = true
+		}
+	}
+
+	// Edges are dropped per segment, since removing them one at a time is quadratic
+	droppedEdges := make(map[*archiveSegment]map[string]bool)
+	for edgeID, segment := range a.edgeSegments {
+		if history, live := edges[edgeID]; live {
+			if droppedEdges[segment] == nil {
+				droppedEdges[segment] = make(map[string]bool)
+			}
+			droppedEdges[segment][edgeID] = true
+			delete(a.edgeSegments, edgeID)
+			segment.Versions -= uint64(len(history))
+		}
+	}
+	for segment, dropped := range droppedEdges {
+		kept := segment.Edges[:0]
+		for _, edgeID := range segment.Edges {
+			if !dropped[edgeID] {
+				kept = append(kept, edgeID)
+			}
+		}
+		segment.Edges = kept
+		a.dropIfEmpty(segment)
+		changed = true
+	}
+
+	if !changed || a.readOnly {
+		return nil
+	}
+	return a.saveIndex()
+}
+
+// forgetNode removes a node from the archive state. Callers hold a.mu.
+func (a *archive) forgetNode(segment *archiveSegment, nodeID string, versions int) {
+	if history, exists := a.nodes[nodeID]; exists {
+		if current := history.GetCurrentVersion(); current != nil {
+			delete(a.nodesByType[current.Type], nodeID)
+		}
+		delete(a.nodes, nodeID)
+	}
+	delete(a.nodeSegments, nodeID)
+	delete(segment.Nodes, nodeID)
+	segment.Versions -= uint64(versions)
+	a.dropIfEmpty(segment)
+}
+
+// forgetEdge removes an edge from the archive state. Callers hold a.mu.
+func (a *archive) forgetEdge(segment *archiveSegment, edgeID string, versions int) {
+	delete(a.edges, edgeID)
+	delete(a.edgeSegments, edgeID)
+	for i, id := range segment.Edges {
+		if id == edgeID {
+			segment.Edges = append(segment.Edges[:i], segment.Edges[i+1:]...)
+			break
+		}
+	}
+	segment.Versions -= uint64(versions)
+	a.dropIfEmpty(segment)
+}
+
+// addCompacted counts versions removed by Compact in the persisted index.
+func (a *archive) addCompacted(versions uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	a.index.Compacted += versions
+	if err := a.saveIndex(); err != nil {
+		a.index.Compacted -= versions
+		return err
+	}
+	return nil
+}
+
+// dropIfEmpty removes a segment that no longer holds anything. Callers hold a.mu.
+// The file is removed after the index has been saved without it.
+func (a *archive) dropIfEmpty(segment *archiveSegment) {
+	if len(segment.Nodes) > 0 || len(segment.Edges) > 0 {
+		return
+	}
+	for i, s := range a.index.Segments {
+		if s == segment {
+			a.index.Segments = append(a.index.Segments[:i], a.index.Segments[i+1:]...)
+			break
+		}
+	}
+	a.evict(segment)
+}
+
+// saveIndex persists the segment index and removes files of dropped segments.
+// Callers hold a.mu.
+func (a *archive) saveIndex() error {
+	data, err := json.Marshal(a.index)
+	if err != nil {
+		return fmt.Errorf("failed to serialize archive index: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(a.dir, archiveIndexName), data); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(a.index.Segments))
+	for _, segment := range a.index.Segments {
+		keep[a.segmentPath(segment.ID)] = true
+	}
+	files, _ := filepath.Glob(filepath.Join(a.dir, "segment-*.json"))
+	for _, file := range files {
+		if !keep[file] {
+			os.Remove(file)
+		}
+	}
+	return nil
+}
+
+// segmentPath returns the file path of a segment.
+func (a *archive) segmentPath(segmentID string) string {
+	return filepath.Join(a.dir, segmentID+".json")
+}
+
+// ArchiveNodes moves the given nodes out of the live store into new archive
+// segments, along with every edge whose source node is archived. Archived data
+// is unchanged and remains available: GetNode, GetEdge and their AsOf variants
+// still find it, and queries and searches include it on request. Listings such
+// as GetNodesByType, GetEdgesByType and GetNeighbors cover live data only.
+// Updating an archived node or edge returns it to the live store.
+// IDs that are unknown or already archived are ignored.
+func (s *Store) ArchiveNodes(ctx context.Context, nodeIDs []string) (*ArchiveResult, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	archived := make(map[string]bool, len(nodeIDs))
+	var nodes []NodeHistory
+	for _, nodeID := range nodeIDs {
+		history, exists := s.nodes[nodeID]
+		if !exists || archived[nodeID] || history.GetCurrentVersion() == nil {
+			continue
+		}
+		archived[nodeID] = true
+		nodes = append(nodes, history)
+	}
+
+	// Sort for reproducible segment files
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i][0].ID < nodes[j][0].ID })
+
+	// Split nodes into segments; edges go to the segment of their source node,
+	// or the first one if their source was archived earlier
+	var contents []archiveSegmentData
+	segmentOf := make(map[string]int, len(nodes))
+	for i, history := range nodes {
+		if i%archiveSegmentNodes == 0 {
+			contents = append(contents, archiveSegmentData{})
+		}
+		last := len(contents) - 1
+		contents[last].Nodes = append(contents[last].Nodes, history)
+		segmentOf[history[0].ID] = last
+	}
+
+	var edges []EdgeHistory
+	for _, history := range s.edges {
+		current := history.GetCurrentVersion()
+		if current == nil {
+			continue
+		}
+		index, inBatch := segmentOf[current.SourceID]
+		if !inBatch && !s.archive.hasNode(current.SourceID) {
+			continue
+		}
+		if len(contents) == 0 {
+			contents = append(contents, archiveSegmentData{})
+		}
+		contents[index].Edges = append(contents[index].Edges, history)
+		edges = append(edges, history)
+	}
+
+	if len(contents) == 0 {
+		return &ArchiveResult{}, nil
+	}
+	for _, content := range contents {
+		sort.Slice(content.Edges, func(i, j int) bool { return content.Edges[i][0].ID < content.Edges[j][0].ID })
+	}
+
+	// A cancelled archive stops before it writes anything; once segments are
+	// written, the live files are removed regardless.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Segments and index are written before live files are removed; if that
+	// is interrupted, loading prefers the live files.
+	segments, err := s.archive.add(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	archivedEdges := make(map[string]bool, len(edges))
+	s.checkDirs("edges")
+	for _, history := range edges {
+		edgeID := history[0].ID
+		archivedEdges[edgeID] = true
+		delete(s.edges, edgeID)
+		if err := os.Remove(filepath.Join(s.dataDir, "edges", edgeID+".json")); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove archived edge file: %w", err)
+		}
+	}
+	s.stampDirs("edges")
+	for edgeType, typeEdges := range s.edgesByType {
+		kept := typeEdges[:0]
+		for _, edge := range typeEdges {
+			if !archivedEdges[edge.ID] {
+				kept = append(kept, edge)
+			}
+		}
+		s.edgesByType[edgeType] = kept
+	}
+
+	var nodeDirs []string
+	seenDirs := make(map[string]bool)
+	for _, history := range nodes {
+		if dir := filepath.Join("nodes", history.GetCurrentVersion().Type); !seenDirs[dir] {
+			seenDirs[dir] = true
+			nodeDirs = append(nodeDirs, dir)
+		}
+	}
+	s.checkDirs(nodeDirs...)
+	for _, history := range nodes {
+		current := history.GetCurrentVersion()
+		delete(s.nodes, current.ID)
+		delete(s.nodesByType[current.Type], current.ID)
+		s.search.remove(current)
+		s.fields.remove(current)
+		if err := os.Remove(filepath.Join(s.dataDir, "nodes", current.Type, current.ID+".json")); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove archived node file: %w", err)
+		}
+	}
+	s.stampDirs(nodeDirs...)
+
+	now := s.now()
+	for _, history := range nodes {
+		current := history.GetCurrentVersion()
+		recordChange(ctx, &ChangeEvent{Kind: ChangeNodeArchived, Node: current, PreviousNode: current, Timestamp: now})
+	}
+	for _, history := range edges {
+		if current := history.GetCurrentVersion(); current != nil {
+			recordChange(ctx, &ChangeEvent{Kind: ChangeEdgeArchived, Edge: current, PreviousEdge: current, Timestamp: now})
+		}
+	}
+
+	result := &ArchiveResult{Nodes: len(nodes), Edges: len(edges)}
+	for _, segment := range segments {
+		result.Segments = append(result.Segments, segment.ID)
+	}
+	return result, nil
+}
+
+// IsArchived reports whether the node has been moved to an archive segment.
+func (s *Store) IsArchived(ctx context.Context, nodeID string) bool {
+	return s.archive.hasNode(nodeID)
+}
+
+// lookupNode returns the history of a live or archived node.
+// Callers hold at least the read lock.
+func (s *Store) lookupNode(nodeID string) (NodeHistory, error) {
+	if history, exists := s.nodes[nodeID]; exists {
+		return history, nil
+	}
+	history, exists, err := s.archive.node(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errs.Newf(errs.NotFound, "node %s not found", nodeID).With("node_id", nodeID)
+	}
+	return history, nil
+}
+
+// lookupEdge returns the history of a live or archived edge.
+// Callers hold at least the read lock.
+func (s *Store) lookupEdge(edgeID string) (EdgeHistory, error) {
+	if history, exists := s.edges[edgeID]; exists {
+		return history, nil
+	}
+	history, exists, err := s.archive.edge(edgeID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errs.Newf(errs.NotFound, "edge %s not found", edgeID).With("edge_id", edgeID)
+	}
+	return history, nil
+}
+
+// nodeExists reports whether a node is live or archived. Callers hold at least the read lock.
+func (s *Store) nodeExists(nodeID string) bool {
+	if _, exists := s.nodes[nodeID]; exists {
+		return true
+	}
+	return s.archive.hasNode(nodeID)
+}
+
+// restoreNode returns an archived node to the live store, if it is archived.
+// Callers hold the write lock.
+func (s *Store) restoreNode(ctx context.Context, nodeID string) error {
+	if _, live := s.nodes[nodeID]; live || !s.archive.hasNode(nodeID) {
+		return nil
+	}
+
+	history, err := s.archive.takeNode(nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to restore archived node: %w", err)
+	}
+	current := history.GetCurrentVersion()
+
+	s.nodes[nodeID] = history
+	if s.nodesByType[current.Type] == nil {
+		s.nodesByType[current.Type] = make(map[string]NodeHistory)
+	}
+	s.nodesByType[current.Type][nodeID] = history
+	s.search.add(current)
+	s.fields.add(current)
+	if err := s.saveNodeFile(nodeID); err != nil {
+		return err
+	}
+	recordChange(ctx, &ChangeEvent{Kind: ChangeNodeRestored, Node: current, PreviousNode: current, Timestamp: s.now()})
+	return nil
+}
+
+// restoreEdge returns an archived edge to the live store, if it is archived.
+// Callers hold the write lock.
+func (s *Store) restoreEdge(ctx context.Context, edgeID string) error {
+	if _, live := s.edges[edgeID]; live || !s.archive.hasEdge(edgeID) {
+		return nil
+	}
+
+	history, err := s.archive.takeEdge(edgeID)
+	if err != nil {
+		return fmt.Errorf("failed to restore archived edge: %w", err)
+	}
+
+	s.edges[edgeID] = history
+	current := history.GetCurrentVersion()
+	if current != nil {
+		s.updateEdgeTypeIndex(current)
+	}
+	if err := s.saveEdgeFile(edgeID); err != nil {
+		return err
+	}
+	if current != nil {
+		recordChange(ctx, &ChangeEvent{Kind: ChangeEdgeRestored, Edge: current, PreviousEdge: current, Timestamp: s.now()})
+	}
+	return nil
+}
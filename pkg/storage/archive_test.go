@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupArchiveStore creates a store with a goal that has two objectives and
+// one note, linked by "serves" edges and a "note_for" edge from the note.
+func setupArchiveStore(t *testing.T) (*Store, map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ids := make(map[string]string)
+	add := func(key, nodeType, title string) {
+		node := NewNode(nodeType, map[string]interface{}{"title": title})
+		if err := store.AddNode(ctx, node); err != nil {
+			t.Fatalf("Failed to add %s: %v", key, err)
+		}
+		ids[key] = node.ID
+	}
+	add("goal", "goal", "Write the annual report")
+	add("done", "objective", "Collect revenue figures")
+	add("open", "objective", "Draft summary section")
+	add("note", "note", "Figures live in the finance share")
+
+	link := func(key, sourceKey, targetKey, edgeType string) {
+		edge := NewEdge(ids[sourceKey], ids[targetKey], edgeType, nil)
+		if err := store.AddEdge(ctx, edge); err != nil {
+			t.Fatalf("Failed to add edge %s: %v", key, err)
+		}
+		ids[key] = edge.ID
+	}
+	link("done_serves", "done", "goal", "serves")
+	link("open_serves", "open", "goal", "serves")
+	link("note_for", "note", "done", "note_for")
+
+	return store, ids
+}
+
+func TestArchiveNodes(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+	sequence := store.Sequence()
+
+	result, err := store.ArchiveNodes(ctx, []string{ids["done"], "missing"})
+	if err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+	if result.Nodes != 1 || result.Edges != 1 || len(result.Segments) != 1 {
+		t.Errorf("Expected 1 node and 1 edge in 1 segment, got %+v", result)
+	}
+	if store.Sequence() != sequence {
+		t.Errorf("Expected archiving to leave the sequence at %d, got %d", sequence, store.Sequence())
+	}
+
+	if !store.IsArchived(ctx, ids["done"]) || store.IsArchived(ctx, ids["open"]) {
+		t.Error("Expected only the archived objective to be reported as archived")
+	}
+	if _, err := os.Stat(filepath.Join(store.DataDir(), "nodes", "objective", ids["done"]+".json")); !os.IsNotExist(err) {
+		t.Error("Expected the live node file to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(store.DataDir(), "edges", ids["done_serves"]+".json")); !os.IsNotExist(err) {
+		t.Error("Expected the live file of the outgoing edge to be removed")
+	}
+
+	// Archived data stays reachable by ID
+	node, err := store.GetNode(ctx, ids["done"])
+	if err != nil || node.Data["title"] != "Collect revenue figures" {
+		t.Errorf("Expected archived node by ID, got %v, %v", node, err)
+	}
+	if _, err := store.GetEdge(ctx, ids["done_serves"]); err != nil {
+		t.Errorf("Expected archived edge by ID: %v", err)
+	}
+
+	// Live listings exclude it, edges into it from live nodes stay live
+	objectives, _ := store.GetNodesByType(ctx, "objective")
+	if len(objectives) != 1 || objectives[0].ID != ids["open"] {
+		t.Errorf("Expected only the open objective to be listed, got %d", len(objectives))
+	}
+	if _, err := store.GetEdge(ctx, ids["note_for"]); err != nil || store.IsArchived(ctx, ids["note"]) {
+		t.Error("Expected the incoming edge and its source to stay live")
+	}
+	serves, _ := store.GetEdgesByType(ctx, "serves")
+	if len(serves) != 1 || serves[0].ID != ids["open_serves"] {
+		t.Errorf("Expected only the live serves edge to be listed, got %d", len(serves))
+	}
+
+	// Archiving again is a no-op
+	result, err = store.ArchiveNodes(ctx, []string{ids["done"]})
+	if err != nil || result.Nodes != 0 || len(result.Segments) != 0 {
+		t.Errorf("Expected archiving an archived node to do nothing, got %+v, %v", result, err)
+	}
+}
+
+func TestArchiveQueries(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+
+	if _, err := store.ArchiveNodes(ctx, []string{ids["done"]}); err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+
+	live, _ := store.Nodes().OfType("objective").All()
+	if len(live) != 1 {
+		t.Errorf("Expected 1 live objective, got %d", len(live))
+	}
+	all, _ := store.Nodes().OfType("objective").IncludeArchived().All()
+	if len(all) != 2 {
+		t.Errorf("Expected 2 objectives including archived, got %d", len(all))
+	}
+	asOf, _ := store.Nodes().OfType("objective").IncludeArchived().AsOf(time.Now()).All()
+	if len(asOf) != 2 {
+		t.Errorf("Expected 2 objectives as of now including archived, got %d", len(asOf))
+	}
+
+	edges, _ := store.Edges().OfType("serves").All()
+	if len(edges) != 1 {
+		t.Errorf("Expected 1 live serves edge, got %d", len(edges))
+	}
+	edges, _ = store.Edges().OfType("serves").IncludeArchived().All()
+	if len(edges) != 2 {
+		t.Errorf("Expected 2 serves edges including archived, got %d", len(edges))
+	}
+
+	types, _ := store.GetNodeTypes(ctx)
+	if len(types) != 3 {
+		t.Errorf("Expected archived types to be listed, got %v", types)
+	}
+}
+
+func TestArchiveRestoresOnUpdate(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+
+	if _, err := store.ArchiveNodes(ctx, []string{ids["done"]}); err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+
+	if err := store.UpdateNode(ctx, ids["done"], map[string]interface{}{"title": "Collect revenue figures again"}); err != nil {
+		t.Fatalf("UpdateNode failed: %v", err)
+	}
+	if store.IsArchived(ctx, ids["done"]) {
+		t.Error("Expected the updated node to be live again")
+	}
+	objectives, _ := store.GetNodesByType(ctx, "objective")
+	if len(objectives) != 2 {
+		t.Errorf("Expected both objectives to be listed, got %d", len(objectives))
+	}
+	history, _ := store.lookupNode(ids["done"])
+	if len(history) != 2 {
+		t.Errorf("Expected the restored node to keep its history, got %d versions", len(history))
+	}
+
+	// The archived edge can be restored separately
+	if err := store.UpdateEdge(ctx, ids["done_serves"], map[string]interface{}{"weight": 1.0}); err != nil {
+		t.Fatalf("UpdateEdge failed: %v", err)
+	}
+	serves, _ := store.GetEdgesByType(ctx, "serves")
+	if len(serves) != 2 {
+		t.Errorf("Expected both serves edges to be listed, got %d", len(serves))
+	}
+	if _, err := os.Stat(filepath.Join(store.DataDir(), "archive")); err != nil {
+		t.Errorf("Expected the archive directory to remain: %v", err)
+	}
+	segments, _ := filepath.Glob(filepath.Join(store.DataDir(), "archive", "segment-*.json"))
+	if len(segments) != 0 {
+		t.Errorf("Expected the emptied segment to be removed, got %v", segments)
+	}
+}
+
+func TestArchiveSurvivesReload(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+
+	if _, err := store.ArchiveNodes(ctx, []string{ids["done"], ids["goal"]}); err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+	sequence := store.Sequence()
+
+	reloaded, err := NewStore(store.DataDir())
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if reloaded.Sequence() != sequence {
+		t.Errorf("Expected sequence %d after reload, got %d", sequence, reloaded.Sequence())
+	}
+	if !reloaded.IsArchived(ctx, ids["done"]) || !reloaded.IsArchived(ctx, ids["goal"]) {
+		t.Error("Expected archived nodes to stay archived after reload")
+	}
+	if node, err := reloaded.GetNode(ctx, ids["goal"]); err != nil || node.Data["title"] != "Write the annual report" {
+		t.Errorf("Expected archived goal after reload, got %v, %v", node, err)
+	}
+
+	// Edges to archived nodes can still be added
+	if err := reloaded.AddEdge(ctx, NewEdge(ids["note"], ids["goal"], "note_for", nil)); err != nil {
+		t.Errorf("Expected an edge to an archived node to be accepted: %v", err)
+	}
+}
+
+func TestArchivePrefersLiveFilesAfterInterruption(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+
+	// Keep a copy of the live file, as if removal had not happened before a crash
+	nodePath := filepath.Join(store.DataDir(), "nodes", "objective", ids["done"]+".json")
+	data, err := os.ReadFile(nodePath)
+	if err != nil {
+		t.Fatalf("Failed to read node file: %v", err)
+	}
+	if _, err := store.ArchiveNodes(ctx, []string{ids["done"]}); err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+	if err := os.WriteFile(nodePath, data, 0644); err != nil {
+		t.Fatalf("Failed to restore node file: %v", err)
+	}
+
+	reloaded, err := NewStore(store.DataDir())
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if reloaded.IsArchived(ctx, ids["done"]) {
+		t.Error("Expected the live copy to win over the archived one")
+	}
+	objectives, _ := reloaded.Nodes().OfType("objective").IncludeArchived().All()
+	if len(objectives) != 2 {
+		t.Errorf("Expected no duplicate objectives, got %d", len(objectives))
+	}
+}
+
+func TestArchiveLowMemoryKeepsOneSegment(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+
+	// Archive in two passes so the histories land in separate segments
+	if _, err := store.ArchiveNodes(ctx, []string{ids["done"]}); err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+	if _, err := store.ArchiveNodes(ctx, []string{ids["goal"]}); err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+
+	lean, err := NewStore(store.DataDir(), LowMemory())
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if lean.search != nil {
+		t.Error("Expected a LowMemory store to keep no search index")
+	}
+
+	archived, err := lean.Search(ctx, "annual report", SearchOptions{IncludeArchived: true})
+	if err != nil || len(archived) != 1 || archived[0].ID != ids["goal"] {
+		t.Errorf("Expected the archived goal from a scan, got %v, %v", archived, err)
+	}
+	live, err := lean.Search(ctx, "finance", SearchOptions{})
+	if err != nil || len(live) != 1 || live[0].ID != ids["note"] {
+		t.Errorf("Expected the live note from a scan, got %v, %v", live, err)
+	}
+
+	objectives, err := lean.Nodes().OfType("objective").IncludeArchived().All()
+	if err != nil || len(objectives) != 2 {
+		t.Errorf("Expected both objectives across segments, got %d, %v", len(objectives), err)
+	}
+	if len(lean.archive.recent) != 1 {
+		t.Errorf("Expected one cached segment, got %d", len(lean.archive.recent))
+	}
+
+	if dropped := lean.DropCaches(); dropped != 1 {
+		t.Errorf("Expected one segment dropped, got %d", dropped)
+	}
+	if len(lean.archive.nodes) != 0 || len(lean.archive.edges) != 0 {
+		t.Error("Expected DropCaches to empty the archive cache")
+	}
+	if node, err := lean.GetNode(ctx, ids["done"]); err != nil || node.Data["title"] != "Collect revenue figures" {
+		t.Errorf("Expected the archived objective to be read again, got %v, %v", node, err)
+	}
+}
@@ -69,6 +69,12 @@ func (bm *BackupManager) CreateBackup(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("failed to backup edges: %w", err)
 	}
 
+	if err := bm.copyDirectory(ctx, filepath.Join(bm.dataDir, archiveDirName), filepath.Join(backupPath, archiveDirName)); err != nil {
+		// Clean up partial backup on failure
+		os.RemoveAll(backupPath)
+		return "", fmt.Errorf("failed to backup archive: %w", err)
+	}
+
 	// Write backup metadata
 	if err := bm.writeBackupMetadata(backupPath, timestamp); err != nil {
 		// Clean up partial backup on failure
@@ -288,6 +294,11 @@ func (bm *BackupManager) RestoreFromBackup(ctx context.Context, backupPath strin
 		return fmt.Errorf("failed to restore edges: %w", err)
 	}
 
+	if err := bm.copyDirectory(ctx, filepath.Join(backupPath, archiveDirName), filepath.Join(tempDir, archiveDirName)); err != nil {
+		os.RemoveAll(tempDir)
+		return fmt.Errorf("failed to restore archive: %w", err)
+	}
+
 	// Backup current data (in case restoration fails)
 	currentBackupPath := bm.dataDir + "_pre_restore_backup"
 	if err := os.RemoveAll(currentBackupPath); err != nil {
@@ -318,6 +329,14 @@ func (bm *BackupManager) RestoreFromBackup(ctx context.Context, backupPath strin
 		}
 	}
 
+	if _, err := os.Stat(filepath.Join(bm.dataDir, archiveDirName)); err == nil {
+		if err := os.Rename(filepath.Join(bm.dataDir, archiveDirName), filepath.Join(currentBackupPath, archiveDirName)); err != nil {
+			bm.restoreFromCurrentBackup(currentBackupPath)
+			os.RemoveAll(tempDir)
+			return fmt.Errorf("failed to backup current archive: %w", err)
+		}
+	}
+
 	// Move restored data into place
 	if err := os.Rename(filepath.Join(tempDir, "nodes"), filepath.Join(bm.dataDir, "nodes")); err != nil {
 		// Try to restore from current backup
@@ -333,6 +352,15 @@ func (bm *BackupManager) RestoreFromBackup(ctx context.Context, backupPath strin
 		return fmt.Errorf("failed to move restored edges: %w", err)
 	}
 
+	// Backups taken before anything was archived have no archive directory
+	if _, err := os.Stat(filepath.Join(tempDir, archiveDirName)); err == nil {
+		if err := os.Rename(filepath.Join(tempDir, archiveDirName), filepath.Join(bm.dataDir, archiveDirName)); err != nil {
+			bm.restoreFromCurrentBackup(currentBackupPath)
+			os.RemoveAll(tempDir)
+			return fmt.Errorf("failed to move restored archive: %w", err)
+		}
+	}
+
 	// Clean up temporary directories
 	os.RemoveAll(tempDir)
 	os.RemoveAll(currentBackupPath)
@@ -348,6 +376,10 @@ func (bm *BackupManager) restoreFromCurrentBackup(currentBackupPath string) {
 	if _, err := os.Stat(filepath.Join(currentBackupPath, "edges")); err == nil {
 		os.Rename(filepath.Join(currentBackupPath, "edges"), filepath.Join(bm.dataDir, "edges"))
 	}
+	if _, err := os.Stat(filepath.Join(currentBackupPath, archiveDirName)); err == nil {
+		os.RemoveAll(filepath.Join(bm.dataDir, archiveDirName))
+		os.Rename(filepath.Join(currentBackupPath, archiveDirName), filepath.Join(bm.dataDir, archiveDirName))
+	}
 }
 
 // RestoreFromLatestBackup restores from the most recent backup.
@@ -0,0 +1,435 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+const (
+	// batchesDirName is the directory, under the data directory, holding the
+	// manifests of committed batches whose files are being renamed into place
+	batchesDirName = "batches"
+
+	// batchTempSuffix ends the names of the files a batch writes before they
+	// are renamed into place
+	batchTempSuffix = ".batch.tmp"
+)
+
+// batchFailpoint, when set by tests, is called as a batch's flush reaches
+// each stage: "written", "committed" and "renamed". An error stops the batch
+// there, as if the process had stopped, leaving its files as they are.
+var batchFailpoint func(stage string) error
+
+// batchOp is one write staged in a Tx.
+type batchOp struct {
+	node   *Node                  // AddNode
+	edge   *Edge                  // AddEdge
+	nodeID string                 // UpdateNode
+	data   map[string]interface{} // UpdateNode
+}
+
+// Tx stages the writes of a batch; see Store.Batch. Its methods only record
+// the writes, which are checked and made when the batch function returns.
+type Tx struct {
+	ops  []batchOp
+	done bool
+}
+
+// AddNode stages adding a node, or a new version of it when it exists.
+func (tx *Tx) AddNode(node *Node) error {
+	if node == nil {
+		return fmt.Errorf("node cannot be nil")
+	}
+	return tx.stage(batchOp{node: node})
+}
+
+// UpdateNode stages a new version of a node with the given data. The node
+// may be one added earlier in the batch.
+func (tx *Tx) UpdateNode(nodeID string, data map[string]interface{}) error {
+	return tx.stage(batchOp{nodeID: nodeID, data: data})
+}
+
+// AddEdge stages adding an edge, or a new version of it when it exists. Its
+// source and target may be nodes added earlier in the batch.
+func (tx *Tx) AddEdge(edge *Edge) error {
+	if edge == nil {
+		return fmt.Errorf("edge cannot be nil")
+	}
+	return tx.stage(batchOp{edge: edge})
+}
+
+// stage records a write, unless the batch has finished.
+func (tx *Tx) stage(op batchOp) error {
+	if tx.done {
+		return errs.New(errs.Conflict, "batch has already finished")
+	}
+	tx.ops = append(tx.ops, op)
+	return nil
+}
+
+// batchChange is a staged write resolved against the store: the version it
+// adds and the kind of change it is.
+type batchChange struct {
+	kind ChangeKind
+	node *Node
+	edge *Edge
+}
+
+// batchPlan is a batch ready to be written: its changes in order, and the
+// histories of the nodes and edges they touch as they will be afterwards.
+type batchPlan struct {
+	at      time.Time
+	changes []batchChange
+	nodes   map[string]NodeHistory
+	edges   map[string]EdgeHistory
+}
+
+// batchManifest lists the files of a committed batch, relative to the data
+// directory. It is written once every file is staged next to its final path,
+// and removed once they have all been renamed into place.
+type batchManifest struct {
+	ID    string   `json:"id"`
+	Files []string `json:"files"`
+}
+
+// Batch makes the writes fn stages on its Tx as one change: either all of
+// them are stored or, when fn or any write fails, none are. Several writes to
+// the same node or edge are written to its file once.
+//
+// The new versions of every file are written next to it first, then a
+// manifest listing them, then they are renamed into place. A batch is
+// committed when its manifest is written: if the process stops before that,
+// the staged files are dropped when the store is next opened, and after it
+// the renames are finished. A store opened ReadOnly does neither, and may see
+// a batch that is being written in part.
+//
+// fn runs without the store lock, so it may read the store, but the writes it
+// stages are only checked, e.g. that an edge's nodes exist, when it returns.
+// Subscribers are notified of each write, in order, after the batch is
+// stored. Archived nodes and edges that are written are restored first, and
+// stay restored when the batch fails.
+func (s *Store) Batch(ctx context.Context, fn func(tx *Tx) error) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	tx := &Tx{}
+	err := fn(tx)
+	tx.done = true
+	if err != nil {
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	var events []*ChangeEvent
+	s.mu.Lock()
+	defer func() {
+		s.mu.Unlock()
+		for _, event := range events {
+			s.publish(event)
+			recordChange(ctx, event)
+		}
+	}()
+
+	// A cancelled caller stops before anything changes
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	plan, err := s.planBatch(ctx, tx.ops)
+	if err != nil {
+		return err
+	}
+	if err := s.flushBatch(plan); err != nil {
+		return err
+	}
+	events = s.applyBatch(ctx, plan)
+	return nil
+}
+
+// planBatch resolves staged writes against the store, without changing it
+// beyond restoring archived entries. Callers hold the write lock.
+func (s *Store) planBatch(ctx context.Context, ops []batchOp) (*batchPlan, error) {
+	plan := &batchPlan{
+		at:    s.now(),
+		nodes: make(map[string]NodeHistory),
+		edges: make(map[string]EdgeHistory),
+	}
+	for _, op := range ops {
+		switch {
+		case op.node != nil:
+			if err := s.restoreNode(ctx, op.node.ID); err != nil {
+				return nil, err
+			}
+			if err := s.checkSchema(op.node); err != nil {
+				return nil, err
+			}
+			s.stampNode(op.node)
+			plan.addNode(ChangeNodeAdded, s.nodes[op.node.ID], op.node)
+
+		case op.edge != nil:
+			// The edge's nodes may be stored or staged before it
+			for _, end := range [][2]string{{"source", op.edge.SourceID}, {"target", op.edge.TargetID}} {
+				if _, staged := plan.nodes[end[1]]; !staged && !s.nodeExists(end[1]) {
+					return nil, errs.Newf(errs.NotFound, "%s node %s not found", end[0], end[1]).With("node_id", end[1])
+				}
+			}
+			if err := s.restoreEdge(ctx, op.edge.ID); err != nil {
+				return nil, err
+			}
+			s.stampEdge(op.edge)
+			plan.addEdge(s.edges[op.edge.ID], op.edge)
+
+		default:
+			if err := s.restoreNode(ctx, op.nodeID); err != nil {
+				return nil, err
+			}
+			history, staged := plan.nodes[op.nodeID]
+			if !staged {
+				history = s.nodes[op.nodeID]
+			}
+			if history == nil {
+				return nil, errs.Newf(errs.NotFound, "node %s not found", op.nodeID).With("node_id", op.nodeID)
+			}
+			current := history.GetCurrentVersion()
+			if current == nil {
+				return nil, fmt.Errorf("no current version found for node %s", op.nodeID)
+			}
+			newVersion := NewNodeWithID(op.nodeID, current.Type, op.data)
+			if err := s.checkSchema(newVersion); err != nil {
+				return nil, err
+			}
+			s.stampNode(newVersion)
+			plan.addNode(ChangeNodeUpdated, s.nodes[op.nodeID], newVersion)
+		}
+	}
+	return plan, nil
+}
+
+// addNode adds a node version to the plan. The stored history is only read:
+// the version it supersedes is copied.
+func (p *batchPlan) addNode(kind ChangeKind, stored NodeHistory, node *Node) {
+	history, staged := p.nodes[node.ID]
+	if !staged {
+		history = stored
+	}
+	next := make(NodeHistory, len(history), len(history)+1)
+	for i, version := range history {
+		if version.IsCurrent() {
+			superseded := *version
+			superseded.Supersede(p.at)
+			version = &superseded
+		}
+		next[i] = version
+	}
+	p.nodes[node.ID] = append(next, node)
+	p.changes = append(p.changes, batchChange{kind: kind, node: node})
+}
+
+// addEdge is addNode for edges.
+func (p *batchPlan) addEdge(stored EdgeHistory, edge *Edge) {
+	history, staged := p.edges[edge.ID]
+	if !staged {
+		history = stored
+	}
+	next := make(EdgeHistory, len(history), len(history)+1)
+	for i, version := range history {
+		if version.IsCurrent() {
+			superseded := *version
+			superseded.Supersede(p.at)
+			version = &superseded
+		}
+		next[i] = version
+	}
+	p.edges[edge.ID] = append(next, edge)
+	p.changes = append(p.changes, batchChange{kind: ChangeEdgeAdded, edge: edge})
+}
+
+// files returns the contents of the files the plan writes, by path relative
+// to the data directory.
+func (p *batchPlan) files() (map[string][]byte, error) {
+	files := make(map[string][]byte, len(p.nodes)+len(p.edges))
+	for nodeID, history := range p.nodes {
+		data, err := json.MarshalIndent(history, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize node history: %w", err)
+		}
+		files[filepath.Join("nodes", history.GetCurrentVersion().Type, nodeID+".json")] = data
+	}
+	for edgeID, history := range p.edges {
+		data, err := json.MarshalIndent(history, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize edge history: %w", err)
+		}
+		files[filepath.Join("edges", edgeID+".json")] = data
+	}
+	return files, nil
+}
+
+// flushBatch writes the files of a plan: staged beside their final paths,
+// committed by the manifest, then renamed into place. A failure before the
+// commit removes the staged files. Callers hold the write lock.
+func (s *Store) flushBatch(plan *batchPlan) error {
+	files, err := plan.files()
+	if err != nil {
+		return err
+	}
+	manifest := batchManifest{ID: strconv.FormatInt(time.Now().UnixNano(), 10)}
+	for path := range files {
+		manifest.Files = append(manifest.Files, filepath.ToSlash(path))
+	}
+	sort.Strings(manifest.Files)
+
+	// A batch may add a node type's directory as well as files
+	dirs := []string{"nodes"}
+	seenDirs := map[string]bool{"nodes": true}
+	for _, path := range manifest.Files {
+		if dir := filepath.Dir(filepath.FromSlash(path)); !seenDirs[dir] {
+			seenDirs[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	s.checkDirs(dirs...)
+
+	var written []string
+	discard := func(err error) error {
+		for _, temp := range written {
+			os.Remove(temp)
+		}
+		return err
+	}
+	for _, path := range manifest.Files {
+		final := filepath.Join(s.dataDir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(final), 0755); err != nil {
+			return discard(fmt.Errorf("failed to create directory: %w", err))
+		}
+		temp := batchTempPath(final, manifest.ID)
+		if err := os.WriteFile(temp, files[filepath.FromSlash(path)], 0644); err != nil {
+			return discard(fmt.Errorf("failed to write temp file: %w", err))
+		}
+		written = append(written, temp)
+	}
+	if err := batchStage("written"); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return discard(fmt.Errorf("failed to serialize batch manifest: %w", err))
+	}
+	manifestPath := filepath.Join(s.dataDir, batchesDirName, manifest.ID+".json")
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+		return discard(fmt.Errorf("failed to create batches directory: %w", err))
+	}
+	if err := writeFileAtomic(manifestPath, data); err != nil {
+		return discard(fmt.Errorf("failed to commit batch: %w", err))
+	}
+	if err := batchStage("committed"); err != nil {
+		return err
+	}
+
+	// The batch is committed: a file that cannot be renamed now is renamed
+	// when the store is next opened, which the manifest is kept for
+	renamed := true
+	for i, path := range manifest.Files {
+		final := filepath.Join(s.dataDir, filepath.FromSlash(path))
+		if err := os.Rename(batchTempPath(final, manifest.ID), final); err != nil {
+			renamed = false
+			continue
+		}
+		if i == 0 {
+			if err := batchStage("renamed"); err != nil {
+				return err
+			}
+		}
+	}
+	if renamed {
+		os.Remove(manifestPath)
+	}
+	s.stampDirs(dirs...)
+	return nil
+}
+
+// applyBatch makes a flushed plan's changes in memory and returns their
+// events. Callers hold the write lock.
+func (s *Store) applyBatch(ctx context.Context, plan *batchPlan) []*ChangeEvent {
+	events := make([]*ChangeEvent, 0, len(plan.changes))
+	for _, change := range plan.changes {
+		event := &ChangeEvent{Kind: change.kind, Node: change.node, Edge: change.edge}
+
+		// Planning restored whatever was archived, so committing cannot fail
+		if change.node != nil {
+			event.PreviousNode, _ = s.commitNode(ctx, change.node, plan.at)
+		} else {
+			event.PreviousEdge, _ = s.commitEdge(ctx, change.edge, plan.at)
+		}
+		event.Sequence = s.nextSequence()
+		event.Timestamp = s.now()
+		events = append(events, event)
+	}
+	return events
+}
+
+// recoverBatches finishes the batches that were committed but not renamed
+// into place when the process stopped, and drops the files of those that
+// were not committed. It runs before the store's files are loaded.
+func (s *Store) recoverBatches() error {
+	manifests, err := filepath.Glob(filepath.Join(s.dataDir, batchesDirName, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, manifestPath := range manifests {
+		var manifest batchManifest
+		if err := decodeJSONFile(manifestPath, &manifest); err != nil {
+			return fmt.Errorf("failed to read batch manifest %s: %w", manifestPath, err)
+		}
+		for _, path := range manifest.Files {
+			final := filepath.Join(s.dataDir, filepath.FromSlash(path))
+			if err := os.Rename(batchTempPath(final, manifest.ID), final); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to finish batch %s: %w", manifest.ID, err)
+			}
+		}
+		if err := os.Remove(manifestPath); err != nil {
+			return fmt.Errorf("failed to remove batch manifest: %w", err)
+		}
+	}
+
+	for _, dir := range []string{"nodes", "edges"} {
+		err := filepath.WalkDir(filepath.Join(s.dataDir, dir), func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasSuffix(path, batchTempSuffix) {
+				return os.Remove(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to drop uncommitted batch files: %w", err)
+		}
+	}
+	return nil
+}
+
+// batchTempPath returns where a batch stages the new contents of a file.
+func batchTempPath(path, batchID string) string {
+	return path + "." + batchID + batchTempSuffix
+}
+
+// batchStage calls batchFailpoint, when set, for the stage a flush reached.
+func batchStage(stage string) error {
+	if batchFailpoint == nil {
+		return nil
+	}
+	return batchFailpoint(stage)
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// storeFiles lists the files under a store's data directory.
+func storeFiles(t *testing.T, dataDir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dataDir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to list store files: %v", err)
+	}
+	return files
+}
+
+// assertNoBatchLeftovers fails when staged files or manifests remain.
+func assertNoBatchLeftovers(t *testing.T, dataDir string) {
+	t.Helper()
+	for _, path := range storeFiles(t, dataDir) {
+		if strings.HasSuffix(path, ".tmp") || strings.Contains(path, batchesDirName+string(filepath.Separator)) {
+			t.Errorf("Expected no batch files left behind, found %s", path)
+		}
+	}
+}
+
+func TestBatch_StoresWritesTogether(t *testing.T) {
+	tempDir := createTempDir(t)
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	var events []ChangeEvent
+	store.Subscribe(func(event ChangeEvent) {
+		events = append(events, event)
+	})
+
+	goal := NewNode("goal", map[string]interface{}{"title": "Ship"})
+	objective := NewNode("objective", map[string]interface{}{"status": "pending"})
+	serves := NewEdge(objective.ID, goal.ID, "serves", nil)
+	err = store.Batch(ctx, func(tx *Tx) error {
+		if err := tx.AddNode(goal); err != nil {
+			return err
+		}
+		if err := tx.AddNode(objective); err != nil {
+			return err
+		}
+		if err := tx.AddEdge(serves); err != nil {
+			return err
+		}
+		return tx.UpdateNode(objective.ID, map[string]interface{}{"status": "in_progress"})
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+
+	// Subscribers see each write, in order
+	kinds := []ChangeKind{ChangeNodeAdded, ChangeNodeAdded, ChangeEdgeAdded, ChangeNodeUpdated}
+	if len(events) != len(kinds) {
+		t.Fatalf("Expected %d events, got %d", len(kinds), len(events))
+	}
+	for i, event := range events {
+		if event.Kind != kinds[i] || (i > 0 && event.Sequence != events[i-1].Sequence+1) {
+			t.Errorf("Expected event %d to be %s in sequence, got %s #%d", i, kinds[i], event.Kind, event.Sequence)
+		}
+	}
+	if events[3].PreviousNode != objective {
+		t.Error("Expected the update to supersede the objective added in the batch")
+	}
+
+	// The objective's two versions went to its file in one write
+	assertNoBatchLeftovers(t, tempDir)
+	if files := storeFiles(t, tempDir); len(files) != 3 {
+		t.Errorf("Expected a file per node and edge, got %v", files)
+	}
+	reopened := reopenStore(t, store)
+	if reopened.packStamps == nil {
+		t.Error("Expected the batch to leave the live pack usable")
+	}
+	for _, s := range []*Store{store, reopened} {
+		current, err := s.GetNode(ctx, objective.ID)
+		if err != nil || current.Data["status"] != "in_progress" || len(s.nodes[objective.ID]) != 2 {
+			t.Errorf("Expected the updated objective with two versions, got %v, %v", current, err)
+		}
+		if _, err := s.GetEdge(ctx, serves.ID); err != nil {
+			t.Errorf("Expected the edge stored, got %v", err)
+		}
+	}
+	if reopened.sequence != store.sequence {
+		t.Errorf("Expected sequence %d after reopening, got %d", store.sequence, reopened.sequence)
+	}
+}
+
+func TestBatch_RollsBackOnError(t *testing.T) {
+	tempDir := createTempDir(t)
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	goal := NewNode("goal", map[string]interface{}{"title": "Ship"})
+	if err := store.AddNode(ctx, goal); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	var events []ChangeEvent
+	store.Subscribe(func(event ChangeEvent) {
+		events = append(events, event)
+	})
+
+	// An edge to a missing node fails the writes staged before it
+	objective := NewNode("objective", nil)
+	err = store.Batch(ctx, func(tx *Tx) error {
+		tx.UpdateNode(goal.ID, map[string]interface{}{"title": "Ship it"})
+		tx.AddNode(objective)
+		return tx.AddEdge(NewEdge(objective.ID, "missing", "uses", nil))
+	})
+	if errs.CodeOf(err) != errs.NotFound {
+		t.Fatalf("Expected the missing node reported, got %v", err)
+	}
+
+	// So does the batch function failing
+	failure := errors.New("changed my mind")
+	var staged *Tx
+	err = store.Batch(ctx, func(tx *Tx) error {
+		staged = tx
+		tx.AddNode(objective)
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the function's error, got %v", err)
+	}
+	if err := staged.AddNode(objective); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected staging after the batch to be refused, got %v", err)
+	}
+
+	if _, err := store.GetNode(ctx, objective.ID); errs.CodeOf(err) != errs.NotFound {
+		t.Errorf("Expected no objective stored, got %v", err)
+	}
+	if current, _ := store.GetNode(ctx, goal.ID); current.Data["title"] != "Ship" || len(store.nodes[goal.ID]) != 1 {
+		t.Errorf("Expected the goal unchanged, got %v", current.Data)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events, got %d", len(events))
+	}
+	assertNoBatchLeftovers(t, tempDir)
+
+	readOnly, err := NewStore(tempDir, ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open read-only store: %v", err)
+	}
+	if err := readOnly.Batch(ctx, func(tx *Tx) error { return tx.AddNode(NewNode("goal", nil)) }); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestBatch_SurvivesStopping(t *testing.T) {
+	// Stopping before the manifest is written loses the batch; after, the
+	// next open finishes it
+	stages := map[string]bool{"written": false, "committed": true, "renamed": true}
+	for stage, stored := range stages {
+		t.Run(stage, func(t *testing.T) {
+			tempDir := createTempDir(t)
+			store, err := NewStore(tempDir)
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			ctx := context.Background()
+			goal := NewNode("goal", map[string]interface{}{"title": "Ship"})
+			if err := store.AddNode(ctx, goal); err != nil {
+				t.Fatalf("Failed to add node: %v", err)
+			}
+
+			stopped := errors.New("process stopped")
+			batchFailpoint = func(reached string) error {
+				if reached == stage {
+					return stopped
+				}
+				return nil
+			}
+			defer func() { batchFailpoint = nil }()
+
+			objective := NewNode("objective", nil)
+			serves := NewEdge(objective.ID, goal.ID, "serves", nil)
+			err = store.Batch(ctx, func(tx *Tx) error {
+				tx.AddNode(objective)
+				tx.AddEdge(serves)
+				return tx.UpdateNode(goal.ID, map[string]interface{}{"title": "Ship it"})
+			})
+			if !errors.Is(err, stopped) {
+				t.Fatalf("Expected the batch stopped at %s, got %v", stage, err)
+			}
+			batchFailpoint = nil
+
+			reopened, err := NewStore(tempDir)
+			if err != nil {
+				t.Fatalf("Failed to reopen store: %v", err)
+			}
+			_, objectiveErr := reopened.GetNode(ctx, objective.ID)
+			_, edgeErr := reopened.GetEdge(ctx, serves.ID)
+			current, _ := reopened.GetNode(ctx, goal.ID)
+			if stored {
+				if objectiveErr != nil || edgeErr != nil || current.Data["title"] != "Ship it" {
+					t.Errorf("Expected the whole batch stored, got %v, %v, %v", objectiveErr, edgeErr, current.Data)
+				}
+			} else if objectiveErr == nil || edgeErr == nil || current.Data["title"] != "Ship" {
+				t.Errorf("Expected none of the batch stored, got %v, %v, %v", objectiveErr, edgeErr, current.Data)
+			}
+			assertNoBatchLeftovers(t, tempDir)
+		})
+	}
+}
+
+func BenchmarkBatch_NodeWithEdges(b *testing.B) {
+	store, err := NewStore(b.TempDir())
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+	goal := NewNode("goal", nil)
+	if err := store.AddNode(ctx, goal); err != nil {
+		b.Fatalf("Failed to add node: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		objective := NewNode("objective", map[string]interface{}{"status": "pending"})
+		err := store.Batch(ctx, func(tx *Tx) error {
+			tx.AddNode(objective)
+			tx.AddEdge(NewEdge(objective.ID, goal.ID, "serves", nil))
+			return tx.UpdateNode(objective.ID, map[string]interface{}{"status": "in_progress"})
+		})
+		if err != nil {
+			b.Fatalf("Batch failed: %v", err)
+		}
+	}
+}
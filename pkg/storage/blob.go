@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// blobDirName is the directory under the data directory blobs are kept in.
+const blobDirName = "blobs"
+
+// BlobScheme prefixes references to blobs, as in "blob://<hash>".
+const BlobScheme = "blob://"
+
+// DefaultBlobGCMinAge is how old an unreferenced blob must be before
+// CollectBlobs removes it unless told otherwise, so that blobs written for
+// an execution still recording its results are not collected.
+const DefaultBlobGCMinAge = time.Hour
+
+// BlobRef returns the reference to the blob with the given hash.
+func BlobRef(hash string) string {
+	return BlobScheme + hash
+}
+
+// ParseBlobRef returns the hash a blob reference names, and whether ref is
+// a blob reference at all.
+func ParseBlobRef(ref string) (string, bool) {
+	hash, ok := strings.CutPrefix(ref, BlobScheme)
+	return hash, ok && validBlobHash(hash)
+}
+
+// PutBlob stores data as a content-addressed file under the data directory
+// and returns its hash, the hex SHA-256 of data. Storing data already stored
+// keeps the one copy. Blobs are local to this store: they are not sent to
+// replicas.
+func (s *Store) PutBlob(ctx context.Context, data []byte) (string, error) {
+	if err := s.checkWritable(); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := s.blobPath(hash)
+
+	s.blobMu.Lock()
+	defer s.blobMu.Unlock()
+
+	// A blob stored again counts as new, so CollectBlobs leaves it for its
+	// new reference to be recorded
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			return "", fmt.Errorf("failed to touch blob %s: %w", hash, err)
+		}
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", fmt.Errorf("failed to write blob %s: %w", hash, err)
+	}
+	return hash, nil
+}
+
+// GetBlob returns the data of the blob with the given hash.
+func (s *Store) GetBlob(ctx context.Context, hash string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !validBlobHash(hash) {
+		return nil, errs.Newf(errs.Validation, "invalid blob hash %q", hash).With("hash", hash)
+	}
+
+	data, err := os.ReadFile(s.blobPath(hash))
+	if os.IsNotExist(err) {
+		return nil, errs.Newf(errs.NotFound, "blob %s not found", hash).With("hash", hash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	return data, nil
+}
+
+// BlobGCOptions selects the blobs CollectBlobs removes.
+type BlobGCOptions struct {
+	// Referenced holds the hashes of the blobs still in use, which are kept
+	Referenced map[string]bool
+
+	// MinAge is how long ago an unreferenced blob must have been stored to
+	// be removed (default DefaultBlobGCMinAge)
+	MinAge time.Duration
+
+	// DryRun reports what would be removed without removing anything
+	DryRun bool
+}
+
+// BlobGCResult describes what a call to CollectBlobs removed, or would remove.
+type BlobGCResult struct {
+	Removed    int   // Blobs removed
+	BytesFreed int64 // Their total size
+	Kept       int   // Blobs left in place
+	DryRun     bool
+}
+
+// String summarizes the result for display.
+func (r *BlobGCResult) String() string {
+	if r.Removed == 0 {
+		return fmt.Sprintf("No unreferenced blobs to remove, keeping %d", r.Kept)
+	}
+	verb := "Removed"
+	if r.DryRun {
+		verb = "Would remove"
+	}
+	return fmt.Sprintf("%s %d unreferenced blobs (%d bytes), keeping %d", verb, r.Removed, r.BytesFreed, r.Kept)
+}
+
+// CollectBlobs removes the blobs that are neither referenced nor newer than
+// the minimum age. Like Compact, it is local to this store.
+func (s *Store) CollectBlobs(ctx context.Context, opts BlobGCOptions) (*BlobGCResult, error) {
+	if opts.MinAge <= 0 {
+		opts.MinAge = DefaultBlobGCMinAge
+	}
+	if !opts.DryRun {
+		if err := s.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.blobMu.Lock()
+	defer s.blobMu.Unlock()
+
+	result := &BlobGCResult{DryRun: opts.DryRun}
+	cutoff := time.Now().Add(-opts.MinAge)
+	guard := &scanGuard{ctx: ctx}
+	err := filepath.WalkDir(filepath.Join(s.dataDir, blobDirName), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if guard.stopped() {
+			return guard.err
+		}
+		if entry.IsDir() || !validBlobHash(entry.Name()) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if opts.Referenced[entry.Name()] || info.ModTime().After(cutoff) {
+			result.Kept++
+			return nil
+		}
+		if !opts.DryRun {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove blob %s: %w", entry.Name(), err)
+			}
+		}
+		result.Removed++
+		result.BytesFreed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// blobPath returns the file a blob is kept in, fanned out by the first two
+// characters of its hash.
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.dataDir, blobDirName, hash[:2], hash)
+}
+
+// validBlobHash reports whether hash is a hex SHA-256, which also keeps
+// blob paths inside the blob directory.
+func validBlobHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil && strings.ToLower(hash) == hash
+}
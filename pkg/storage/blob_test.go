@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+func TestBlobs_PutAndGet(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	large := bytes.Repeat([]byte("0123456789abcdef"), 256*1024) // 4 MB
+	hash, err := store.PutBlob(ctx, large)
+	if err != nil {
+		t.Fatalf("PutBlob failed: %v", err)
+	}
+	data, err := store.GetBlob(ctx, hash)
+	if err != nil {
+		t.Fatalf("GetBlob failed: %v", err)
+	}
+	if !bytes.Equal(data, large) {
+		t.Fatalf("GetBlob returned %d bytes, want the %d stored", len(data), len(large))
+	}
+
+	// The same content is stored once, under the same hash
+	again, err := store.PutBlob(ctx, large)
+	if err != nil || again != hash {
+		t.Errorf("Storing the blob again gave %q, %v; want %q", again, err, hash)
+	}
+	if parsed, ok := ParseBlobRef(BlobRef(hash)); !ok || parsed != hash {
+		t.Errorf("ParseBlobRef(BlobRef(hash)) = %q, %v", parsed, ok)
+	}
+
+	// Blobs survive reopening the store
+	reopened, err := NewStore(store.DataDir())
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if data, err := reopened.GetBlob(ctx, hash); err != nil || len(data) != len(large) {
+		t.Errorf("Reopened store returned %d bytes, %v", len(data), err)
+	}
+}
+
+func TestBlobs_Errors(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	missing := "0000000000000000000000000000000000000000000000000000000000000000"
+	if _, err := store.GetBlob(ctx, missing); !errors.Is(err, errs.NotFound) {
+		t.Errorf("GetBlob of a missing blob returned %v, want NotFound", err)
+	}
+	for _, hash := range []string{"../../nodes/goal/x.json", "abc", missing[:62] + "ZZ"} {
+		if _, err := store.GetBlob(ctx, hash); !errors.Is(err, errs.Validation) {
+			t.Errorf("GetBlob(%q) returned %v, want Validation", hash, err)
+		}
+		if _, ok := ParseBlobRef(BlobRef(hash)); ok {
+			t.Errorf("ParseBlobRef accepted %q", hash)
+		}
+	}
+
+	readOnly, err := NewStore(store.DataDir(), ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open read-only store: %v", err)
+	}
+	if _, err := readOnly.PutBlob(ctx, []byte("data")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("PutBlob on a read-only store returned %v, want ErrReadOnly", err)
+	}
+}
+
+func TestBlobs_Collect(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	put := func(content string, age time.Duration) string {
+		hash, err := store.PutBlob(ctx, []byte(content))
+		if err != nil {
+			t.Fatalf("PutBlob failed: %v", err)
+		}
+		old := time.Now().Add(-age)
+		if err := os.Chtimes(store.blobPath(hash), old, old); err != nil {
+			t.Fatalf("Failed to age blob: %v", err)
+		}
+		return hash
+	}
+	kept := put("referenced", 2*time.Hour)
+	stale := put("stale output", 2*time.Hour)
+	recent := put("recent output", time.Minute)
+
+	opts := BlobGCOptions{Referenced: map[string]bool{kept: true}, DryRun: true}
+	result, err := store.CollectBlobs(ctx, opts)
+	if err != nil {
+		t.Fatalf("CollectBlobs dry run failed: %v", err)
+	}
+	if result.Removed != 1 || result.Kept != 2 || result.BytesFreed != int64(len("stale output")) {
+		t.Errorf("Dry run = %+v, want 1 removed and 2 kept", result)
+	}
+	if _, err := store.GetBlob(ctx, stale); err != nil {
+		t.Errorf("Dry run removed a blob: %v", err)
+	}
+
+	opts.DryRun = false
+	if _, err := store.CollectBlobs(ctx, opts); err != nil {
+		t.Fatalf("CollectBlobs failed: %v", err)
+	}
+	if _, err := store.GetBlob(ctx, stale); !errors.Is(err, errs.NotFound) {
+		t.Errorf("Unreferenced blob was kept: %v", err)
+	}
+	for _, hash := range []string{kept, recent} {
+		if _, err := store.GetBlob(ctx, hash); err != nil {
+			t.Errorf("Blob %s was removed: %v", hash, err)
+		}
+	}
+
+	// Storing an old blob again protects it until its new reference is recorded
+	put("refreshed", 2*time.Hour)
+	refreshed, _ := store.PutBlob(ctx, []byte("refreshed"))
+	if result, err := store.CollectBlobs(ctx, BlobGCOptions{}); err != nil || result.Removed != 1 {
+		t.Fatalf("CollectBlobs = %+v, %v; want only the old unreferenced blob removed", result, err)
+	}
+	if _, err := store.GetBlob(ctx, refreshed); err != nil {
+		t.Errorf("Re-stored blob was removed: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// DefaultCompactRetention is how far back Compact keeps every version unless
+// told otherwise.
+const DefaultCompactRetention = 30 * 24 * time.Hour
+
+// CompactOptions selects the node versions Compact removes.
+type CompactOptions struct {
+	// Retention is how far back every version is kept (default
+	// DefaultCompactRetention). Versions superseded before then are thinned
+	// to the first and last version of each UTC day.
+	Retention time.Duration
+
+	// Types limits compaction to nodes of these types; empty means all types
+	Types []string
+
+	// ExportPath, if set, receives the removed versions as JSON before
+	// anything is removed. An existing file is not overwritten.
+	ExportPath string
+
+	// DryRun reports what would be removed without changing anything
+	DryRun bool
+}
+
+// CompactResult describes what a call to Compact removed, or would remove.
+type CompactResult struct {
+	Nodes           int       // Nodes that lost versions
+	VersionsRemoved int       // Versions removed
+	VersionsKept    int       // Versions left in the compacted nodes' histories
+	Cutoff          time.Time // Versions superseded before this were thinned
+	ExportPath      string    // Where the removed versions were written, if anywhere
+	DryRun          bool
+}
+
+// String summarizes the result for display.
+func (r *CompactResult) String() string {
+	verb := "Removed"
+	if r.DryRun {
+		verb = "Would remove"
+	}
+	if r.VersionsRemoved == 0 {
+		return fmt.Sprintf("No versions superseded before %s to remove", r.Cutoff.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("%s %d versions from %d nodes superseded before %s, keeping %d",
+		verb, r.VersionsRemoved, r.Nodes, r.Cutoff.Format("2006-01-02"), r.VersionsKept)
+}
+
+// compactExport is the content of an export file.
+type compactExport struct {
+	CompactedAt time.Time     `json:"compacted_at"`
+	Cutoff      time.Time     `json:"cutoff"`
+	Nodes       []NodeHistory `json:"nodes"` // Removed versions, grouped by node
+}
+
+// Compact thins the history of live nodes: of the versions superseded before
+// the retention window, only the first and last of each UTC day are kept.
+// Current versions and every version still valid within the window are
+// never removed, so GetNodeAtTime answers as before for any time inside it.
+// Older times that fall between two kept versions of the same day no longer
+// have a version. Edges and archived nodes are not compacted.
+//
+// Compaction is local to this store: it is not sent to replicas, and no
+// change event is published.
+func (s *Store) Compact(ctx context.Context, opts CompactOptions) (*CompactResult, error) {
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultCompactRetention
+	}
+	if !opts.DryRun {
+		if err := s.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	result := &CompactResult{Cutoff: now.Add(-opts.Retention), DryRun: opts.DryRun}
+
+	types := make(map[string]bool, len(opts.Types))
+	for _, nodeType := range opts.Types {
+		types[nodeType] = true
+	}
+
+	// Work out every node's kept and removed versions before changing any
+	kept := make(map[string]NodeHistory)
+	var removed []NodeHistory
+	guard := &scanGuard{ctx: ctx}
+	for nodeID, history := range s.nodes {
+		if guard.stopped() {
+			return nil, guard.err
+		}
+		current := history.GetCurrentVersion()
+		if current == nil || (len(types) > 0 && !types[current.Type]) {
+			continue
+		}
+		keep, drop := thinHistory(history, result.Cutoff)
+		if len(drop) == 0 {
+			continue
+		}
+		kept[nodeID] = keep
+		removed = append(removed, drop)
+		result.Nodes++
+		result.VersionsRemoved += len(drop)
+		result.VersionsKept += len(keep)
+	}
+	if opts.DryRun || len(removed) == 0 {
+		return result, nil
+	}
+
+	// Sort for a reproducible export file
+	sort.Slice(removed, func(i, j int) bool { return removed[i][0].ID < removed[j][0].ID })
+	if opts.ExportPath != "" {
+		if err := writeCompactExport(opts.ExportPath, compactExport{CompactedAt: now, Cutoff: result.Cutoff, Nodes: removed}); err != nil {
+			return nil, err
+		}
+		result.ExportPath = opts.ExportPath
+	}
+
+	// Record the removed versions first, so the sequence a restarted store
+	// derives from its stored versions never goes backwards
+	if err := s.archive.addCompacted(uint64(result.VersionsRemoved)); err != nil {
+		return nil, err
+	}
+
+	for nodeID, history := range kept {
+		s.nodes[nodeID] = history
+		s.nodesByType[history.GetCurrentVersion().Type][nodeID] = history
+		if err := s.saveNodeFile(nodeID); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// thinHistory splits a node history into the versions Compact keeps and those
+// it removes: of the versions superseded before cutoff, all but the first and
+// last of each UTC day, by ValidFrom.
+func thinHistory(history NodeHistory, cutoff time.Time) (keep, drop NodeHistory) {
+	versions := history.GetAllVersions()
+
+	// Index of the first and last old version of each day
+	first := make(map[string]int)
+	last := make(map[string]int)
+	for i, version := range versions {
+		if version.IsCurrent() || !version.ValidUntil.Before(cutoff) {
+			continue
+		}
+		day := version.ValidFrom.UTC().Format("2006-01-02")
+		if _, seen := first[day]; !seen {
+			first[day] = i
+		}
+		last[day] = i
+	}
+
+	for i, version := range versions {
+		if version.IsCurrent() || !version.ValidUntil.Before(cutoff) {
+			keep = append(keep, version)
+			continue
+		}
+		day := version.ValidFrom.UTC().Format("2006-01-02")
+		if i == first[day] || i == last[day] {
+			keep = append(keep, version)
+		} else {
+			drop = append(drop, version)
+		}
+	}
+	return keep, drop
+}
+
+// writeCompactExport writes the removed versions to a new file.
+func writeCompactExport(path string, export compactExport) error {
+	if _, err := os.Stat(path); err == nil {
+		return errs.Newf(errs.Conflict, "export file %s already exists", path)
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize compacted versions: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write compaction export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// setupCompactStore creates an objective that changed three times on its
+// first day, twice on the next, and once more two months later, and a note
+// that changed three times on the first day.
+func setupCompactStore(t *testing.T) (*Store, *utils.FakeClock, string, string) {
+	t.Helper()
+	ctx := context.Background()
+	clock := utils.NewFakeClock(time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC))
+	store, err := NewStore(t.TempDir(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	objective := NewNode("objective", map[string]interface{}{"status": "pending"})
+	note := NewNode("note", map[string]interface{}{"text": "draft"})
+	for _, node := range []*Node{objective, note} {
+		if err := store.AddNode(ctx, node); err != nil {
+			t.Fatalf("Failed to add node: %v", err)
+		}
+	}
+
+	update := func(nodeID, key, value string, after time.Duration) {
+		clock.Advance(after)
+		if err := store.UpdateNode(ctx, nodeID, map[string]interface{}{key: value}); err != nil {
+			t.Fatalf("UpdateNode failed: %v", err)
+		}
+	}
+	update(objective.ID, "status", "active", time.Hour)         // Jan 5 09:00
+	update(objective.ID, "status", "blocked", time.Hour)        // Jan 5 10:00
+	update(objective.ID, "status", "active", time.Hour)         // Jan 5 11:00
+	update(note.ID, "text", "edited", time.Minute)              // Jan 5 11:01
+	update(note.ID, "text", "reviewed", time.Minute)            // Jan 5 11:02
+	update(note.ID, "text", "final", time.Minute)               // Jan 5 11:03
+	update(objective.ID, "status", "review", 21*time.Hour)      // Jan 6 08:03
+	update(objective.ID, "status", "done", 4*time.Hour)         // Jan 6 12:03
+	update(objective.ID, "status", "reopened", 60*24*time.Hour) // Mar 7 12:03
+
+	return store, clock, objective.ID, note.ID
+}
+
+func TestCompact_ThinsOldVersionsToFirstAndLastPerDay(t *testing.T) {
+	ctx := context.Background()
+	store, _, objectiveID, noteID := setupCompactStore(t)
+	sequence := store.Sequence()
+
+	preview, err := store.Compact(ctx, CompactOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	// Jan 5 09:00 and 10:00 of the objective, Jan 5 11:01 of the note
+	if preview.VersionsRemoved != 3 || preview.Nodes != 2 || !preview.DryRun {
+		t.Fatalf("Expected 3 versions of 2 nodes to be removable, got %+v", preview)
+	}
+	if history := store.nodes[objectiveID]; len(history) != 7 {
+		t.Fatalf("Expected a dry run to change nothing, got %d versions", len(history))
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "compacted.json")
+	result, err := store.Compact(ctx, CompactOptions{ExportPath: exportPath})
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if result.VersionsRemoved != 3 || result.VersionsKept != 8 || result.ExportPath != exportPath {
+		t.Errorf("Expected 3 versions removed and 8 kept, got %+v", result)
+	}
+
+	at := func(nodeID string, timestamp time.Time) string {
+		node, err := store.GetNodeAtTime(ctx, nodeID, timestamp)
+		if err != nil {
+			return ""
+		}
+		for _, value := range node.Data {
+			return value.(string)
+		}
+		return ""
+	}
+	day := func(d, hour, minute int) time.Time { return time.Date(2026, 1, d, hour, minute, 30, 0, time.UTC) }
+	checks := []struct {
+		name      string
+		nodeID    string
+		timestamp time.Time
+		want      string
+	}{
+		{"first of the day", objectiveID, day(5, 8, 0), "pending"},
+		{"removed version", objectiveID, day(5, 9, 0), ""},
+		{"last of the day", objectiveID, day(5, 11, 0), "active"},
+		{"next day", objectiveID, day(6, 9, 0), "review"},
+		{"inside the retention window", objectiveID, time.Date(2026, 2, 20, 0, 0, 0, 0, time.UTC), "done"},
+		{"current version", objectiveID, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), "reopened"},
+		{"removed note version", noteID, day(5, 11, 1), ""},
+		{"current note version", noteID, day(5, 11, 3), "final"},
+	}
+	for _, check := range checks {
+		if got := at(check.nodeID, check.timestamp); got != check.want {
+			t.Errorf("%s: expected %q, got %q", check.name, check.want, got)
+		}
+	}
+
+	// The removed versions were exported first
+	var export compactExport
+	if err := decodeJSONFile(exportPath, &export); err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	exported := 0
+	for _, history := range export.Nodes {
+		exported += len(history)
+	}
+	if exported != 3 || !export.Cutoff.Equal(result.Cutoff) {
+		t.Errorf("Expected the 3 removed versions exported, got %d", exported)
+	}
+
+	// Reopening keeps the compacted history and a sequence that has not gone back
+	reopened, err := NewStore(store.DataDir())
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if history := reopened.nodes[objectiveID]; len(history) != 5 {
+		t.Errorf("Expected 5 objective versions on disk, got %d", len(history))
+	}
+	if reopened.Sequence() != sequence {
+		t.Errorf("Expected the sequence to stay at %d, got %d", sequence, reopened.Sequence())
+	}
+}
+
+func TestCompact_Options(t *testing.T) {
+	ctx := context.Background()
+	store, _, objectiveID, noteID := setupCompactStore(t)
+
+	// Only the chosen types are compacted
+	result, err := store.Compact(ctx, CompactOptions{Types: []string{"note"}})
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if result.VersionsRemoved != 1 || len(store.nodes[noteID]) != 3 || len(store.nodes[objectiveID]) != 7 {
+		t.Errorf("Expected only the note compacted, got %+v", result)
+	}
+
+	// A retention window reaching back past every version removes nothing
+	result, err = store.Compact(ctx, CompactOptions{Retention: 90 * 24 * time.Hour})
+	if err != nil || result.VersionsRemoved != 0 {
+		t.Errorf("Expected nothing to compact within 90 days, got %+v, %v", result, err)
+	}
+
+	// An export never overwrites a file
+	exportPath := filepath.Join(t.TempDir(), "compacted.json")
+	if err := os.WriteFile(exportPath, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := store.Compact(ctx, CompactOptions{ExportPath: exportPath}); errs.CodeOf(err) != errs.Conflict {
+		t.Errorf("Expected a conflict for an existing export file, got %v", err)
+	}
+	if len(store.nodes[objectiveID]) != 7 {
+		t.Error("Expected a failed export to leave the history alone")
+	}
+
+	// Read-only stores can preview but not compact
+	readOnly, err := NewStore(store.DataDir(), ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open read-only store: %v", err)
+	}
+	if _, err := readOnly.Compact(ctx, CompactOptions{DryRun: true}); err != nil {
+		t.Errorf("Expected a dry run on a read-only store, got %v", err)
+	}
+	if _, err := readOnly.Compact(ctx, CompactOptions{}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+}
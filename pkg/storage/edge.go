@@ -35,6 +35,10 @@ type Edge struct {
 	// ValidUntil is when this version was superseded.
 	// Zero time (time.Time{}) indicates the current active version.
 	ValidUntil time.Time `json:"valid_until"`
+
+	// stampedAt is the constructor's timestamp; a store with its own clock
+	// restamps the version unless the caller changed ValidFrom
+	stampedAt time.Time
 }
 
 // NewEdge creates a new edge between the given source and target nodes.
@@ -50,6 +54,7 @@ func NewEdge(sourceID, targetID, edgeType string, data map[string]interface{}) *
 		CreatedAt:  now,
 		ValidFrom:  now,
 		ValidUntil: time.Time{}, // Zero time means current version
+		stampedAt:  now,
 	}
 }
 
@@ -66,6 +71,7 @@ func NewEdgeWithID(id, sourceID, targetID, edgeType string, data map[string]inte
 		CreatedAt:  now,
 		ValidFrom:  now,
 		ValidUntil: time.Time{},
+		stampedAt:  now,
 	}
 }
 
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ChangeKind identifies the kind of mutation that produced a ChangeEvent.
+type ChangeKind string
+
+const (
+	// ChangeNodeAdded is emitted when AddNode stores a node
+	ChangeNodeAdded ChangeKind = "node_added"
+	// ChangeNodeUpdated is emitted when UpdateNode creates a new node version
+	ChangeNodeUpdated ChangeKind = "node_updated"
+	// ChangeEdgeAdded is emitted when AddEdge stores an edge
+	ChangeEdgeAdded ChangeKind = "edge_added"
+	// ChangeEdgeUpdated is emitted when UpdateEdge creates a new edge version
+	ChangeEdgeUpdated ChangeKind = "edge_updated"
+	// ChangeStoreReloaded is emitted when a replica replaces its contents with
+	// a snapshot of the primary, and when Import replaces or adds to the
+	// store's contents; subscribers must recompute from the store
+	ChangeStoreReloaded ChangeKind = "store_reloaded"
+
+	// ChangeNodeArchived, ChangeNodeRestored, ChangeEdgeArchived and
+	// ChangeEdgeRestored are only seen by a ChangeRecorder: archiving moves
+	// versions between files without changing them, so subscribers are not
+	// notified. Node and PreviousNode (or Edge and PreviousEdge) are both the
+	// current version.
+	ChangeNodeArchived ChangeKind = "node_archived"
+	ChangeNodeRestored ChangeKind = "node_restored"
+	ChangeEdgeArchived ChangeKind = "edge_archived"
+	ChangeEdgeRestored ChangeKind = "edge_restored"
+)
+
+// ChangeEvent describes a single committed mutation of the store.
+// Events are numbered with a store-wide sequence so subscribers can detect
+// missed or reordered deliveries.
+type ChangeEvent struct {
+	// Sequence is the store sequence number assigned to this mutation
+	Sequence uint64
+
+	// Kind identifies what kind of mutation occurred
+	Kind ChangeKind
+
+	// Node is the new node version (node events only)
+	Node *Node
+
+	// PreviousNode is the version that was current before the change.
+	// Nil when the node did not exist before.
+	PreviousNode *Node
+
+	// Edge is the new edge version (edge events only)
+	Edge *Edge
+
+	// PreviousEdge is the edge version that was current before the change.
+	// Nil when the edge did not exist before.
+	PreviousEdge *Edge
+
+	// Timestamp is when the change was committed
+	Timestamp time.Time
+}
+
+// ChangeHandler receives change events from the store.
+// Handlers are called synchronously after the store lock has been released,
+// so they may read from or write to the store, but they should return quickly.
+type ChangeHandler func(event ChangeEvent)
+
+// Subscribe registers a handler that is notified of every committed mutation.
+// It returns a function that removes the subscription.
+//
+// Delivery is best-effort: a mutation whose persistence fails still consumes a
+// sequence number but is not delivered, and concurrent writers may deliver
+// events out of order. Subscribers that need exact results should treat any
+// break in the sequence as a signal to recompute from the store.
+func (s *Store) Subscribe(handler ChangeHandler) func() {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	s.nextSubscriberID++
+	id := s.nextSubscriberID
+	s.subscribers[id] = handler
+
+	return func() {
+		s.subMu.Lock()
+		defer s.subMu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// Sequence returns the sequence number of the most recent mutation.
+// On startup the sequence is initialized to the number of stored versions,
+// counting those removed by Compact, so it only ever increases for a given
+// data directory.
+func (s *Store) Sequence() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sequence
+}
+
+// nextSequence advances the store sequence. Callers must hold s.mu.
+func (s *Store) nextSequence() uint64 {
+	s.sequence++
+	return s.sequence
+}
+
+// publish delivers an event to all subscribers in subscription order.
+// A nil event is ignored so callers can publish unconditionally.
+func (s *Store) publish(event *ChangeEvent) {
+	if event == nil {
+		return
+	}
+
+	s.subMu.RLock()
+	ids := make([]int, 0, len(s.subscribers))
+	for id := range s.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	handlers := make([]ChangeHandler, 0, len(ids))
+	for _, id := range ids {
+		handlers = append(handlers, s.subscribers[id])
+	}
+	s.subMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(*event)
+	}
+}
+
+// ChangeRecorder collects the changes committed through the contexts it is
+// attached to. Unlike a subscription it only sees its own writes, so a
+// composite operation can tell exactly what it changed while other writers
+// use the store. It also sees nodes and edges being archived and restored.
+type ChangeRecorder struct {
+	mu     sync.Mutex
+	events []ChangeEvent
+}
+
+type changeRecorderKey struct{}
+
+// WithChangeRecorder returns a context whose node and edge writes are
+// collected by recorder.
+func WithChangeRecorder(ctx context.Context, recorder *ChangeRecorder) context.Context {
+	return context.WithValue(ctx, changeRecorderKey{}, recorder)
+}
+
+// Events returns the recorded changes in the order they were committed.
+func (r *ChangeRecorder) Events() []ChangeEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ChangeEvent(nil), r.events...)
+}
+
+// recordChange hands an event to the recorder attached to ctx, if any.
+// A nil event is ignored, as by publish.
+func recordChange(ctx context.Context, event *ChangeEvent) {
+	if event == nil || ctx == nil {
+		return
+	}
+	recorder, ok := ctx.Value(changeRecorderKey{}).(*ChangeRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.events = append(recorder.events, *event)
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubscribeReceivesChanges(t *testing.T) {
+	tempDir := createTempDir(t)
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	var events []ChangeEvent
+	unsubscribe := store.Subscribe(func(event ChangeEvent) {
+		events = append(events, event)
+	})
+
+	node := NewNode("goal", map[string]interface{}{"status": "active"})
+	if err := store.AddNode(ctx, node); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := store.UpdateNode(ctx, node.ID, map[string]interface{}{"status": "completed"}); err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	other := NewNode("goal", map[string]interface{}{"status": "active"})
+	if err := store.AddNode(ctx, other); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	edge := NewEdge(node.ID, other.ID, "related", nil)
+	if err := store.AddEdge(ctx, edge); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(events))
+	}
+
+	expectedKinds := []ChangeKind{ChangeNodeAdded, ChangeNodeUpdated, ChangeNodeAdded, ChangeEdgeAdded}
+	for i, event := range events {
+		if event.Kind != expectedKinds[i] {
+			t.Errorf("Event %d: expected kind %s, got %s", i, expectedKinds[i], event.Kind)
+		}
+		if event.Sequence != uint64(i+1) {
+			t.Errorf("Event %d: expected sequence %d, got %d", i, i+1, event.Sequence)
+		}
+	}
+
+	if events[0].PreviousNode != nil {
+		t.Errorf("Expected no previous node for a new node")
+	}
+	if events[1].PreviousNode == nil || events[1].PreviousNode.Data["status"] != "active" {
+		t.Errorf("Expected previous node with status active, got %v", events[1].PreviousNode)
+	}
+	if events[1].Node.Data["status"] != "completed" {
+		t.Errorf("Expected updated node with status completed, got %v", events[1].Node.Data["status"])
+	}
+	if events[3].Edge == nil || events[3].Edge.ID != edge.ID {
+		t.Errorf("Expected edge event for %s", edge.ID)
+	}
+
+	if store.Sequence() != 4 {
+		t.Errorf("Expected store sequence 4, got %d", store.Sequence())
+	}
+
+	// No more events after unsubscribing
+	unsubscribe()
+	if err := store.UpdateNode(ctx, other.ID, map[string]interface{}{"status": "paused"}); err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+	if len(events) != 4 {
+		t.Errorf("Expected no events after unsubscribe, got %d", len(events))
+	}
+	if store.Sequence() != 5 {
+		t.Errorf("Expected store sequence 5, got %d", store.Sequence())
+	}
+}
+
+func TestSequenceSurvivesReopen(t *testing.T) {
+	tempDir := createTempDir(t)
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ctx := context.Background()
+
+	node := NewNode("goal", map[string]interface{}{"status": "active"})
+	if err := store.AddNode(ctx, node); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := store.UpdateNode(ctx, node.ID, map[string]interface{}{"status": "paused"}); err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+	before := store.Sequence()
+	store.Close()
+
+	reopened, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Sequence() != before {
+		t.Errorf("Expected sequence %d after reopen, got %d", before, reopened.Sequence())
+	}
+
+	types, err := reopened.GetNodeTypes(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get node types: %v", err)
+	}
+	if len(types) != 1 || types[0] != "goal" {
+		t.Errorf("Expected node types [goal], got %v", types)
+	}
+}
+
+func TestChangeRecorderSeesOnlyItsOwnWrites(t *testing.T) {
+	tempDir := createTempDir(t)
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	var published int
+	store.Subscribe(func(event ChangeEvent) { published++ })
+
+	recorder := &ChangeRecorder{}
+	ctx := WithChangeRecorder(context.Background(), recorder)
+
+	node := NewNode("goal", map[string]interface{}{"status": "active"})
+	if err := store.AddNode(ctx, node); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	other := NewNode("goal", map[string]interface{}{"status": "active"})
+	if err := store.AddNode(context.Background(), other); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := store.AddEdge(ctx, NewEdge(node.ID, other.ID, "related", nil)); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+	if _, err := store.ArchiveNodes(ctx, []string{node.ID}); err != nil {
+		t.Fatalf("Failed to archive node: %v", err)
+	}
+	if err := store.UpdateNode(ctx, node.ID, map[string]interface{}{"status": "completed"}); err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	want := []ChangeKind{ChangeNodeAdded, ChangeEdgeAdded, ChangeNodeArchived, ChangeEdgeArchived, ChangeNodeRestored, ChangeNodeUpdated}
+	events := recorder.Events()
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d recorded changes, got %d: %+v", len(want), len(events), events)
+	}
+	for i, kind := range want {
+		if events[i].Kind != kind {
+			t.Errorf("Expected change %d to be %s, got %s", i, kind, events[i].Kind)
+		}
+	}
+
+	// Archiving and restoring are not published to subscribers
+	if published != 4 {
+		t.Errorf("Expected 4 published changes, got %d", published)
+	}
+}
@@ -0,0 +1,704 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// ExportSchemaVersion is the layout version of the archives Export writes.
+// Import refuses archives of a later version than it knows.
+const ExportSchemaVersion = 1
+
+// exportFormat identifies store exports in their manifest.
+const exportFormat = "ai-work-studio-store"
+
+// exportManifestName is the first entry of an export archive.
+const exportManifestName = "manifest.json"
+
+// exportDirs are the directories under the data directory an export holds:
+// the store's own, and its blobs.
+var exportDirs = append(append([]string{}, storeDirs...), blobDirName)
+
+// ExportManifest describes the contents of an export archive. It is the
+// archive's first entry, followed by the files it lists.
+type ExportManifest struct {
+	Format        string    `json:"format"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Sequence      uint64    `json:"sequence"` // Store sequence the export was taken at
+
+	// Nodes and edges count the live and archived ones, with their versions
+	Nodes        int `json:"nodes"`
+	Edges        int `json:"edges"`
+	NodeVersions int `json:"node_versions"`
+	EdgeVersions int `json:"edge_versions"`
+	Blobs        int `json:"blobs"`
+
+	Files []ExportFile `json:"files"`
+}
+
+// ExportFile is a file of an export archive, by its path under the data
+// directory.
+type ExportFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// String summarizes the manifest for display.
+func (m *ExportManifest) String() string {
+	return fmt.Sprintf("%d nodes (%d versions), %d edges (%d versions) and %d blobs as of %s",
+		m.Nodes, m.NodeVersions, m.Edges, m.EdgeVersions, m.Blobs, m.CreatedAt.Format("2006-01-02 15:04:05"))
+}
+
+// ImportMode selects how Import combines an archive with the store.
+type ImportMode string
+
+const (
+	// ImportMerge adds the nodes, edges and blobs the store does not have,
+	// and reports those it has with a different history as conflicts
+	ImportMerge ImportMode = "merge"
+
+	// ImportReplace replaces everything in the store with the archive
+	ImportReplace ImportMode = "replace"
+)
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// Mode is how the archive is combined with the store (default ImportMerge)
+	Mode ImportMode
+}
+
+// ImportConflict is a node or edge an archive and the store both have, with
+// different histories. Merging keeps the store's.
+type ImportConflict struct {
+	Kind string // "node" or "edge"
+	ID   string
+	Type string
+}
+
+// ImportResult describes what a call to Import changed.
+type ImportResult struct {
+	Mode     ImportMode
+	Manifest *ExportManifest
+
+	// Nodes, edges, versions and blobs added; with ImportReplace, all of
+	// the archive's
+	Nodes    int
+	Edges    int
+	Versions int
+	Blobs    int
+
+	Unchanged int              // Nodes and edges the store already has, with the same history
+	Conflicts []ImportConflict // Nodes and edges the store has with a different history
+}
+
+// String summarizes the result for display.
+func (r *ImportResult) String() string {
+	if r.Mode == ImportReplace {
+		return fmt.Sprintf("Replaced the store with %d nodes, %d edges and %d blobs", r.Nodes, r.Edges, r.Blobs)
+	}
+	summary := fmt.Sprintf("Added %d nodes, %d edges (%d versions) and %d blobs", r.Nodes, r.Edges, r.Versions, r.Blobs)
+	if r.Unchanged > 0 {
+		summary += fmt.Sprintf(", %d already present", r.Unchanged)
+	}
+	if len(r.Conflicts) > 0 {
+		summary += fmt.Sprintf(", %d conflicts kept as they were", len(r.Conflicts))
+	}
+	return summary
+}
+
+// Export writes the store's files to w as a gzipped tar archive for backup
+// or moving to another machine: the live and archived node and edge
+// histories, and the blobs. The archive starts with a manifest of counts
+// and checksums. Writes wait while the export is taken, so it is consistent
+// even while the app runs.
+func (s *Store) Export(ctx context.Context, w io.Writer) (*ExportManifest, error) {
+	s.blobMu.Lock()
+	defer s.blobMu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	manifest := &ExportManifest{
+		Format:        exportFormat,
+		SchemaVersion: ExportSchemaVersion,
+		CreatedAt:     s.now(),
+		Sequence:      s.sequence,
+	}
+	if err := s.countContents(ctx, manifest); err != nil {
+		return nil, err
+	}
+	files, err := listExportFiles(ctx, s.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Files = files
+	for _, file := range files {
+		if strings.HasPrefix(file.Path, blobDirName+"/") {
+			manifest.Blobs++
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize export manifest: %w", err)
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeExportEntry(tw, exportManifestName, int64(len(data)), manifest.CreatedAt, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := copyExportFile(tw, s.dataDir, file, manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write export: %w", err)
+	}
+	return manifest, nil
+}
+
+// Import reads an archive written by Export into the store. The whole
+// archive is checked against its manifest, and loaded, before the store is
+// changed: an archive of an unknown schema version, with a file missing,
+// extra or failing its checksum, or with contents that do not load or do
+// not match its counts is refused, leaving the store as it was.
+//
+// ImportMerge adds what the store does not have. Archived nodes and edges
+// it adds become live, with every version kept.
+func (s *Store) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = ImportMerge
+	}
+	if opts.Mode != ImportMerge && opts.Mode != ImportReplace {
+		return nil, errs.Newf(errs.Validation, "unknown import mode %q, must be merge or replace", opts.Mode).With("mode", string(opts.Mode))
+	}
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Staged under the data directory, so it can be renamed into place
+	staging, err := os.MkdirTemp(s.dataDir, ".import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := readExport(ctx, r, staging)
+	if err != nil {
+		return nil, err
+	}
+	incoming, err := NewStore(staging, ReadOnly())
+	if err != nil {
+		return nil, errs.Wrap(err, errs.Validation, nil)
+	}
+	if err := incoming.checkImport(ctx, manifest); err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Mode: opts.Mode, Manifest: manifest}
+	if opts.Mode == ImportReplace {
+		if err := s.replaceFrom(staging); err != nil {
+			return nil, err
+		}
+		result.Nodes, result.Edges = manifest.Nodes, manifest.Edges
+		result.Versions = manifest.NodeVersions + manifest.EdgeVersions
+		result.Blobs = manifest.Blobs
+		return result, nil
+	}
+	if err := s.mergeFrom(ctx, incoming, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// countContents sets the node and edge counts of m from the store's live
+// and archived contents. Callers hold at least the read lock.
+func (s *Store) countContents(ctx context.Context, m *ExportManifest) error {
+	for _, history := range s.nodes {
+		m.Nodes++
+		m.NodeVersions += len(history)
+	}
+	for _, history := range s.edges {
+		m.Edges++
+		m.EdgeVersions += len(history)
+	}
+	if err := s.archive.forEachNode(ctx, "", func(history NodeHistory) {
+		m.Nodes++
+		m.NodeVersions += len(history)
+	}); err != nil {
+		return err
+	}
+	return s.archive.forEachEdge(ctx, func(history EdgeHistory) {
+		m.Edges++
+		m.EdgeVersions += len(history)
+	})
+}
+
+// checkImport checks that a store staged from an archive holds what its
+// manifest says, and that every ID and type can name a file.
+func (s *Store) checkImport(ctx context.Context, manifest *ExportManifest) error {
+	counted := &ExportManifest{}
+	if err := s.countContents(ctx, counted); err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if counted.Nodes != manifest.Nodes || counted.NodeVersions != manifest.NodeVersions ||
+		counted.Edges != manifest.Edges || counted.EdgeVersions != manifest.EdgeVersions {
+		return errs.Newf(errs.Validation, "export holds %d nodes (%d versions) and %d edges (%d versions), but its manifest lists %d (%d) and %d (%d)",
+			counted.Nodes, counted.NodeVersions, counted.Edges, counted.EdgeVersions,
+			manifest.Nodes, manifest.NodeVersions, manifest.Edges, manifest.EdgeVersions)
+	}
+
+	var bad string
+	check := func(names ...string) {
+		for _, name := range names {
+			if bad == "" && !validFileName(name) {
+				bad = name
+			}
+		}
+	}
+	err := s.forEachHistory(ctx, func(history NodeHistory) {
+		for _, node := range history {
+			check(node.ID, node.Type)
+		}
+	}, func(history EdgeHistory) {
+		check(history[0].ID)
+	})
+	if err != nil {
+		return errs.Wrap(err, errs.Validation, nil)
+	}
+	if bad != "" {
+		return errs.Newf(errs.Validation, "export holds an ID or type that cannot name a file: %q", bad)
+	}
+	return nil
+}
+
+// forEachHistory calls nodeFn for each live and archived node history and
+// edgeFn for each edge history. Callers hold at least the read lock.
+func (s *Store) forEachHistory(ctx context.Context, nodeFn func(NodeHistory), edgeFn func(EdgeHistory)) error {
+	for _, history := range s.nodes {
+		nodeFn(history)
+	}
+	for _, history := range s.edges {
+		edgeFn(history)
+	}
+	if err := s.archive.forEachNode(ctx, "", nodeFn); err != nil {
+		return err
+	}
+	return s.archive.forEachEdge(ctx, edgeFn)
+}
+
+// validFileName reports whether name can be used as a file name under a
+// store directory without leaving it.
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// replaceFrom replaces the store's files with those staged in dir, and
+// reloads. If the files cannot all be moved, those already moved are put
+// back.
+func (s *Store) replaceFrom(dir string) error {
+	var event *ChangeEvent
+	s.blobMu.Lock()
+	defer s.blobMu.Unlock()
+	s.mu.Lock()
+	defer func() {
+		s.mu.Unlock()
+		s.publish(event)
+	}()
+
+	previous := filepath.Join(dir, ".previous")
+	if err := os.Mkdir(previous, 0755); err != nil {
+		return fmt.Errorf("failed to set aside the store: %w", err)
+	}
+	var moved []string
+	restore := func() {
+		for _, name := range moved {
+			target := filepath.Join(s.dataDir, name)
+			os.RemoveAll(target)
+			os.Rename(filepath.Join(previous, name), target)
+		}
+	}
+	for _, name := range exportDirs {
+		target := filepath.Join(s.dataDir, name)
+		if err := os.Rename(target, filepath.Join(previous, name)); err != nil && !os.IsNotExist(err) {
+			restore()
+			return fmt.Errorf("failed to set aside %s: %w", name, err)
+		}
+		moved = append(moved, name)
+		if err := os.Rename(filepath.Join(dir, name), target); err != nil && !os.IsNotExist(err) {
+			restore()
+			return fmt.Errorf("failed to install %s: %w", name, err)
+		}
+	}
+	for _, name := range []string{"nodes", "edges"} {
+		if err := os.MkdirAll(filepath.Join(s.dataDir, name), 0755); err != nil {
+			return fmt.Errorf("failed to create %s directory: %w", name, err)
+		}
+	}
+
+	if err := s.reload(); err != nil {
+		restore()
+		if reloadErr := s.reload(); reloadErr != nil {
+			return fmt.Errorf("failed to load import: %w; and to reload the store: %v", err, reloadErr)
+		}
+		return fmt.Errorf("failed to load import: %w", err)
+	}
+
+	// The sequence never goes backwards, here or once the store restarts
+	s.sequence = max(s.sequence+1, s.versions())
+	event = &ChangeEvent{Sequence: s.sequence, Kind: ChangeStoreReloaded, Timestamp: s.now()}
+	return nil
+}
+
+// mergeFrom adds the histories and blobs of incoming that the store does
+// not have, recording what it did in result.
+func (s *Store) mergeFrom(ctx context.Context, incoming *Store, result *ImportResult) error {
+	var event *ChangeEvent
+	s.blobMu.Lock()
+	defer s.blobMu.Unlock()
+	s.mu.Lock()
+	defer func() {
+		s.mu.Unlock()
+		s.publish(event)
+	}()
+
+	var nodes []NodeHistory
+	var edges []EdgeHistory
+	if err := incoming.forEachHistory(ctx,
+		func(history NodeHistory) { nodes = append(nodes, history) },
+		func(history EdgeHistory) { edges = append(edges, history) },
+	); err != nil {
+		return err
+	}
+	// Sorted for a reproducible conflict report
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i][0].ID < nodes[j][0].ID })
+	sort.Slice(edges, func(i, j int) bool { return edges[i][0].ID < edges[j][0].ID })
+
+	versions := 0
+	for _, history := range nodes {
+		nodeID := history[0].ID
+		existing, err := s.lookupNode(nodeID)
+		if err == nil {
+			if sameHistory(existing, history) {
+				result.Unchanged++
+			} else {
+				result.Conflicts = append(result.Conflicts, ImportConflict{Kind: "node", ID: nodeID, Type: history[len(history)-1].Type})
+			}
+			continue
+		}
+		if !errors.Is(err, errs.NotFound) {
+			return err
+		}
+
+		s.nodes[nodeID] = history
+		if current := history.GetCurrentVersion(); current != nil {
+			if s.nodesByType[current.Type] == nil {
+				s.nodesByType[current.Type] = make(map[string]NodeHistory)
+			}
+			s.nodesByType[current.Type][nodeID] = history
+			s.search.add(current)
+			s.fields.add(current)
+		}
+		if err := s.saveNodeFile(nodeID); err != nil {
+			return err
+		}
+		result.Nodes++
+		versions += len(history)
+	}
+	for _, history := range edges {
+		edgeID := history[0].ID
+		existing, err := s.lookupEdge(edgeID)
+		if err == nil {
+			if sameHistory(existing, history) {
+				result.Unchanged++
+			} else {
+				result.Conflicts = append(result.Conflicts, ImportConflict{Kind: "edge", ID: edgeID, Type: history[len(history)-1].Type})
+			}
+			continue
+		}
+		if !errors.Is(err, errs.NotFound) {
+			return err
+		}
+
+		s.edges[edgeID] = history
+		if current := history.GetCurrentVersion(); current != nil {
+			s.updateEdgeTypeIndex(current)
+		}
+		if err := s.saveEdgeFile(edgeID); err != nil {
+			return err
+		}
+		result.Edges++
+		versions += len(history)
+	}
+
+	// Blobs are named by their content, so one the store has is the same
+	err := filepath.WalkDir(filepath.Join(incoming.dataDir, blobDirName), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		target := s.blobPath(entry.Name())
+		if _, err := os.Stat(target); err == nil {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create blob directory: %w", err)
+		}
+		if err := os.Rename(path, target); err != nil {
+			return fmt.Errorf("failed to add blob %s: %w", entry.Name(), err)
+		}
+		result.Blobs++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	result.Versions = versions
+	if versions > 0 {
+		s.sequence += uint64(versions)
+		event = &ChangeEvent{Sequence: s.sequence, Kind: ChangeStoreReloaded, Timestamp: s.now()}
+	}
+	return nil
+}
+
+// sameHistory reports whether two histories serialize alike.
+func sameHistory(a, b interface{}) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	return err == nil && bytes.Equal(left, right)
+}
+
+// listExportFiles lists the files under the export directories of dataDir
+// with their checksums, in path order.
+func listExportFiles(ctx context.Context, dataDir string) ([]ExportFile, error) {
+	var files []ExportFile
+	for _, dir := range exportDirs {
+		root := filepath.Join(dataDir, dir)
+		err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && filePath == root {
+					return nil
+				}
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if entry.IsDir() || strings.HasSuffix(filePath, ".tmp") {
+				return nil
+			}
+
+			file, err := os.Open(filePath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			hash := sha256.New()
+			size, err := io.Copy(hash, file)
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(dataDir, filePath)
+			if err != nil {
+				return err
+			}
+			files = append(files, ExportFile{Path: filepath.ToSlash(relPath), Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read store files: %w", err)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// copyExportFile writes a store file to an export archive, checking it has
+// not changed since it was listed.
+func copyExportFile(tw *tar.Writer, dataDir string, file ExportFile, modTime time.Time) error {
+	f, err := os.Open(filepath.Join(dataDir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file.Path, err)
+	}
+	defer f.Close()
+	return writeExportEntry(tw, file.Path, file.Size, modTime, f)
+}
+
+// writeExportEntry writes one file of size bytes from r to an export archive.
+func writeExportEntry(tw *tar.Writer, name string, size int64, modTime time.Time, r io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if _, err := io.CopyN(tw, r, size); err != nil {
+		return fmt.Errorf("failed to write %s to export: %w", name, err)
+	}
+	return nil
+}
+
+// readExport extracts an export archive into dir, checking every file
+// against the manifest, and returns the manifest.
+func readExport(ctx context.Context, r io.Reader, dir string) (*ExportManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errs.Newf(errs.Validation, "not a store export: %v", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != exportManifestName {
+		return nil, errs.New(errs.Validation, "not a store export: the archive does not start with a manifest")
+	}
+	var manifest ExportManifest
+	if err := json.NewDecoder(io.LimitReader(tr, header.Size)).Decode(&manifest); err != nil {
+		return nil, errs.Newf(errs.Validation, "invalid export manifest: %v", err)
+	}
+	if manifest.Format != exportFormat {
+		return nil, errs.Newf(errs.Validation, "not a store export: format %q", manifest.Format)
+	}
+	if manifest.SchemaVersion < 1 || manifest.SchemaVersion > ExportSchemaVersion {
+		return nil, errs.Newf(errs.Validation, "unsupported export schema version %d, this version reads up to %d",
+			manifest.SchemaVersion, ExportSchemaVersion).With("schema_version", manifest.SchemaVersion)
+	}
+
+	expected := make(map[string]ExportFile, len(manifest.Files))
+	blobs := 0
+	for _, file := range manifest.Files {
+		if !validExportPath(file.Path) {
+			return nil, errs.Newf(errs.Validation, "export manifest lists an invalid path %q", file.Path)
+		}
+		if _, duplicate := expected[file.Path]; duplicate {
+			return nil, errs.Newf(errs.Validation, "export manifest lists %s twice", file.Path)
+		}
+		expected[file.Path] = file
+		if strings.HasPrefix(file.Path, blobDirName+"/") {
+			blobs++
+		}
+	}
+	if blobs != manifest.Blobs {
+		return nil, errs.Newf(errs.Validation, "export manifest lists %d blob files but counts %d", blobs, manifest.Blobs)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errs.Newf(errs.Validation, "corrupt export: %v", err)
+		}
+		file, listed := expected[header.Name]
+		if !listed || header.Typeflag != tar.TypeReg {
+			return nil, errs.Newf(errs.Validation, "export holds %s, which its manifest does not list", header.Name)
+		}
+		delete(expected, header.Name)
+		if err := extractExportFile(tr, dir, file); err != nil {
+			return nil, err
+		}
+	}
+	if len(expected) > 0 {
+		missing := make([]string, 0, len(expected))
+		for filePath := range expected {
+			missing = append(missing, filePath)
+		}
+		sort.Strings(missing)
+		return nil, errs.Newf(errs.Validation, "export is missing %d files its manifest lists, such as %s", len(missing), missing[0])
+	}
+	return &manifest, nil
+}
+
+// validExportPath reports whether a manifest path names a file under one of
+// the export directories.
+func validExportPath(filePath string) bool {
+	if filePath == "" || path.Clean(filePath) != filePath || path.IsAbs(filePath) || strings.Contains(filePath, `\`) {
+		return false
+	}
+	parts := strings.Split(filePath, "/")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if !validFileName(part) {
+			return false
+		}
+	}
+	for _, dir := range exportDirs {
+		if parts[0] == dir {
+			return parts[0] != blobDirName || validBlobHash(parts[len(parts)-1])
+		}
+	}
+	return false
+}
+
+// extractExportFile writes the current archive entry to dir, checking its
+// size and checksum against the manifest. Blobs must also hash to their
+// names.
+func extractExportFile(r io.Reader, dir string, file ExportFile) error {
+	target := filepath.Join(dir, filepath.FromSlash(file.Path))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", file.Path, err)
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", file.Path, err)
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), io.LimitReader(r, file.Size+1))
+	if err != nil {
+		return errs.Newf(errs.Validation, "corrupt export: failed to read %s: %v", file.Path, err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if size != file.Size || sum != file.SHA256 {
+		return errs.Newf(errs.Validation, "export file %s fails its checksum", file.Path).With("path", file.Path)
+	}
+	if strings.HasPrefix(file.Path, blobDirName+"/") && path.Base(file.Path) != sum {
+		return errs.Newf(errs.Validation, "export blob %s does not match its content", file.Path).With("path", file.Path)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", file.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// setupExportStore creates a store with several versions of nodes and
+// edges, an archived node and a blob, stamped by a clock that keeps
+// nanoseconds and a time zone.
+func setupExportStore(t *testing.T) (*Store, map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	zone := time.FixedZone("UTC+5:30", 5*3600+1800)
+	clock := utils.NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 123456789, zone))
+	store, err := NewStore(t.TempDir(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ids := make(map[string]string)
+	add := func(key, nodeType, title string) {
+		clock.Advance(1500 * time.Millisecond)
+		node := NewNode(nodeType, map[string]interface{}{"title": title})
+		if err := store.AddNode(ctx, node); err != nil {
+			t.Fatalf("Failed to add %s: %v", key, err)
+		}
+		ids[key] = node.ID
+	}
+	add("goal", "goal", "Move to the new laptop")
+	add("objective", "objective", "Copy the data directory")
+	add("old", "objective", "Try rsync")
+	for _, status := range []string{"in_progress", "blocked", "completed"} {
+		clock.Advance(time.Minute + 7*time.Nanosecond)
+		if err := store.UpdateNode(ctx, ids["objective"], map[string]interface{}{"title": "Copy the data directory", "status": status}); err != nil {
+			t.Fatalf("Failed to update objective: %v", err)
+		}
+	}
+
+	clock.Advance(time.Second)
+	edge := NewEdge(ids["objective"], ids["goal"], "serves", map[string]interface{}{"weight": 1.0})
+	if err := store.AddEdge(ctx, edge); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+	ids["serves"] = edge.ID
+	clock.Advance(time.Second)
+	if err := store.UpdateEdge(ctx, edge.ID, map[string]interface{}{"weight": 0.5}); err != nil {
+		t.Fatalf("Failed to update edge: %v", err)
+	}
+	clock.Advance(time.Second)
+	oldEdge := NewEdge(ids["old"], ids["goal"], "serves", nil)
+	if err := store.AddEdge(ctx, oldEdge); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+	ids["old_serves"] = oldEdge.ID
+
+	if _, err := store.ArchiveNodes(ctx, []string{ids["old"]}); err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+	hash, err := store.PutBlob(ctx, []byte("rsync -a ~/.ai-work-studio new-laptop:"))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	ids["blob"] = hash
+	return store, ids
+}
+
+// storeHistories returns every live and archived history in the store,
+// serialized, by ID.
+func storeHistories(t *testing.T, store *Store) map[string]string {
+	t.Helper()
+	histories := make(map[string]string)
+	record := func(id string, history interface{}) {
+		data, err := json.Marshal(history)
+		if err != nil {
+			t.Fatalf("Failed to serialize %s: %v", id, err)
+		}
+		histories[id] = string(data)
+	}
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	err := store.forEachHistory(context.Background(),
+		func(history NodeHistory) { record(history[0].ID, history) },
+		func(history EdgeHistory) { record(history[0].ID, history) })
+	if err != nil {
+		t.Fatalf("Failed to read histories: %v", err)
+	}
+	return histories
+}
+
+// checkSameHistories fails unless two stores hold the same histories, with
+// every version and timestamp.
+func checkSameHistories(t *testing.T, want, got *Store) {
+	t.Helper()
+	expected, actual := storeHistories(t, want), storeHistories(t, got)
+	if len(actual) != len(expected) {
+		t.Errorf("Expected %d histories, got %d", len(expected), len(actual))
+	}
+	for id, history := range expected {
+		if actual[id] != history {
+			t.Errorf("History of %s differs:\nwant %s\ngot  %s", id, history, actual[id])
+		}
+	}
+}
+
+func exportStore(t *testing.T, store *Store) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := store.Export(context.Background(), &buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source, ids := setupExportStore(t)
+
+	var buf bytes.Buffer
+	manifest, err := source.Export(ctx, &buf)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if manifest.SchemaVersion != ExportSchemaVersion || manifest.Nodes != 3 || manifest.NodeVersions != 6 ||
+		manifest.Edges != 2 || manifest.EdgeVersions != 3 || manifest.Blobs != 1 || manifest.Sequence != source.Sequence() {
+		t.Errorf("Unexpected manifest %s at sequence %d", manifest, manifest.Sequence)
+	}
+	for _, file := range manifest.Files {
+		if len(file.SHA256) != 64 || file.Size == 0 {
+			t.Errorf("Expected a checksum and size for %s, got %+v", file.Path, file)
+		}
+	}
+	archive := buf.Bytes()
+
+	for _, mode := range []ImportMode{ImportReplace, ImportMerge} {
+		t.Run(string(mode), func(t *testing.T) {
+			target, err := NewStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			var events []ChangeEvent
+			target.Subscribe(func(event ChangeEvent) { events = append(events, event) })
+
+			result, err := target.Import(ctx, bytes.NewReader(archive), ImportOptions{Mode: mode})
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if result.Nodes != 3 || result.Edges != 2 || result.Versions != 9 || result.Blobs != 1 || len(result.Conflicts) != 0 {
+				t.Errorf("Unexpected result: %s", result)
+			}
+			if len(events) != 1 || events[0].Kind != ChangeStoreReloaded || target.Sequence() < 9 {
+				t.Errorf("Expected one reload event and the sequence to count the versions, got %+v at %d", events, target.Sequence())
+			}
+			checkSameHistories(t, source, target)
+
+			// Temporal queries answer as they did on the source
+			asOf := source.nodes[ids["objective"]][2].ValidFrom.Add(time.Nanosecond)
+			node, err := target.GetNodeAtTime(ctx, ids["objective"], asOf)
+			if err != nil || node.Data["status"] != "blocked" {
+				t.Errorf("Expected the blocked version as of %s, got %v, %v", asOf, node, err)
+			}
+			if data, err := target.GetBlob(ctx, ids["blob"]); err != nil || !strings.HasPrefix(string(data), "rsync") {
+				t.Errorf("Expected the blob imported, got %q, %v", data, err)
+			}
+			if mode == ImportReplace && !target.IsArchived(ctx, ids["old"]) {
+				t.Error("Expected replacing to keep the archived node archived")
+			}
+
+			// And the files written survive reopening
+			reopened, err := NewStore(target.DataDir())
+			if err != nil {
+				t.Fatalf("Failed to reopen store: %v", err)
+			}
+			checkSameHistories(t, source, reopened)
+
+			// An export of the import is the same export
+			again := &bytes.Buffer{}
+			remanifest, err := reopened.Export(ctx, again)
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			if remanifest.Nodes != manifest.Nodes || remanifest.NodeVersions != manifest.NodeVersions || remanifest.Blobs != manifest.Blobs {
+				t.Errorf("Expected the re-export to match, got %s", remanifest)
+			}
+		})
+	}
+}
+
+func TestImportReplaceDropsLocalContents(t *testing.T) {
+	ctx := context.Background()
+	source, _ := setupExportStore(t)
+	target, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	local := NewNode("note", map[string]interface{}{"title": "Only on the new laptop"})
+	if err := target.AddNode(ctx, local); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	sequence := target.Sequence()
+
+	if _, err := target.Import(ctx, bytes.NewReader(exportStore(t, source)), ImportOptions{Mode: ImportReplace}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if _, err := target.GetNode(ctx, local.ID); !errors.Is(err, errs.NotFound) {
+		t.Errorf("Expected the local node replaced, got %v", err)
+	}
+	if target.Sequence() <= sequence {
+		t.Errorf("Expected the sequence to move forward from %d, got %d", sequence, target.Sequence())
+	}
+	checkSameHistories(t, source, target)
+}
+
+func TestImportMergeReportsConflicts(t *testing.T) {
+	ctx := context.Background()
+	source, ids := setupExportStore(t)
+	archive := exportStore(t, source)
+
+	target, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err := target.Import(ctx, bytes.NewReader(archive), ImportOptions{}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if err := target.UpdateNode(ctx, ids["goal"], map[string]interface{}{"title": "Move to the desktop instead"}); err != nil {
+		t.Fatalf("Failed to update goal: %v", err)
+	}
+	sequence := target.Sequence()
+
+	result, err := target.Import(ctx, bytes.NewReader(archive), ImportOptions{Mode: ImportMerge})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Nodes != 0 || result.Edges != 0 || result.Blobs != 0 || result.Unchanged != 4 {
+		t.Errorf("Expected nothing added and four unchanged, got %s", result)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0] != (ImportConflict{Kind: "node", ID: ids["goal"], Type: "goal"}) {
+		t.Errorf("Expected the edited goal reported, got %+v", result.Conflicts)
+	}
+	if goal, err := target.GetNode(ctx, ids["goal"]); err != nil || goal.Data["title"] != "Move to the desktop instead" {
+		t.Errorf("Expected the store's goal kept, got %v, %v", goal, err)
+	}
+	if target.Sequence() != sequence {
+		t.Errorf("Expected no change to be published, sequence went from %d to %d", sequence, target.Sequence())
+	}
+}
+
+// rewriteExport returns archive with each entry passed through edit, which
+// returns the new content and whether to keep the entry.
+func rewriteExport(t *testing.T, archive []byte, edit func(name string, data []byte) ([]byte, bool)) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var out bytes.Buffer
+	gzw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gzw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read export: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Failed to read export: %v", err)
+		}
+		data, keep := edit(header.Name, data)
+		if !keep {
+			continue
+		}
+		header.Size = int64(len(data))
+		tw.WriteHeader(header)
+		tw.Write(data)
+	}
+	tw.Close()
+	gzw.Close()
+	return out.Bytes()
+}
+
+func TestImportRejectsInvalidArchives(t *testing.T) {
+	ctx := context.Background()
+	source, ids := setupExportStore(t)
+	archive := exportStore(t, source)
+
+	editManifest := func(edit func(m map[string]interface{})) func(string, []byte) ([]byte, bool) {
+		return func(name string, data []byte) ([]byte, bool) {
+			if name != exportManifestName {
+				return data, true
+			}
+			var m map[string]interface{}
+			json.Unmarshal(data, &m)
+			edit(m)
+			data, _ = json.Marshal(m)
+			return data, true
+		}
+	}
+	tests := []struct {
+		name    string
+		archive []byte
+		want    string
+	}{
+		{"not an archive", []byte("hello"), "not a store export"},
+		{"newer schema", rewriteExport(t, archive, editManifest(func(m map[string]interface{}) { m["schema_version"] = 99 })), "schema version 99"},
+		{"tampered file", rewriteExport(t, archive, func(name string, data []byte) ([]byte, bool) {
+			return bytes.Replace(data, []byte("Move to the new laptop"), []byte("Move to the old laptop"), 1), true
+		}), "fails its checksum"},
+		{"missing file", rewriteExport(t, archive, func(name string, data []byte) ([]byte, bool) {
+			return data, !strings.HasPrefix(name, "edges/")
+		}), "missing 1 files"},
+		{"wrong counts", rewriteExport(t, archive, editManifest(func(m map[string]interface{}) { m["nodes"] = 4 })), "manifest lists 4"},
+		{"escaping path", rewriteExport(t, archive, editManifest(func(m map[string]interface{}) {
+			m["files"].([]interface{})[0].(map[string]interface{})["path"] = "nodes/../../config.toml"
+		})), "invalid path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := NewStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			local := NewNode("note", map[string]interface{}{"title": "Keep me"})
+			if err := target.AddNode(ctx, local); err != nil {
+				t.Fatalf("Failed to add node: %v", err)
+			}
+
+			for _, mode := range []ImportMode{ImportMerge, ImportReplace} {
+				_, err := target.Import(ctx, bytes.NewReader(tt.archive), ImportOptions{Mode: mode})
+				if !errors.Is(err, errs.Validation) || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Expected %s import refused with %q, got %v", mode, tt.want, err)
+				}
+			}
+			if _, err := target.GetNode(ctx, local.ID); err != nil {
+				t.Errorf("Expected the store left as it was, got %v", err)
+			}
+			if _, err := target.GetNode(ctx, ids["goal"]); !errors.Is(err, errs.NotFound) {
+				t.Errorf("Expected nothing imported, got %v", err)
+			}
+		})
+	}
+
+	replica, err := NewStore(t.TempDir(), ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err := replica.Import(ctx, bytes.NewReader(archive), ImportOptions{}); !errors.Is(err, errs.ReadOnly) {
+		t.Errorf("Expected a read-only store to refuse imports, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+// DefaultIndexedFields returns the node data fields a store indexes unless
+// IndexFields says otherwise: the ones objectives and methods are most often
+// looked up by.
+func DefaultIndexedFields() []string {
+	return []string{"goal_id", "status", "user_id", "method_id"}
+}
+
+// IndexFields sets the node data fields indexed for WithData queries,
+// replacing DefaultIndexedFields. With no fields, WithData always scans.
+func IndexFields(fields ...string) StoreOption {
+	return func(s *Store) {
+		s.fields = newFieldIndex(fields)
+	}
+}
+
+// fieldIndex maps the values of selected data fields of live nodes to the IDs
+// of the nodes holding them. Only current versions are indexed, and only
+// values that can be map keys; a node holding any other value for an indexed
+// field can never equal a value that is looked up.
+type fieldIndex struct {
+	values map[string]map[interface{}]map[string]struct{} // map[field]map[value]set of node IDs
+}
+
+// dataPredicate is a WithData condition the field index may answer.
+type dataPredicate struct {
+	key   string
+	value interface{}
+}
+
+// newFieldIndex creates an empty index over fields, or nil for no fields.
+func newFieldIndex(fields []string) *fieldIndex {
+	if len(fields) == 0 {
+		return nil
+	}
+	fi := &fieldIndex{values: make(map[string]map[interface{}]map[string]struct{})}
+	for _, field := range fields {
+		fi.values[field] = make(map[interface{}]map[string]struct{})
+	}
+	return fi
+}
+
+// reset returns an empty index over the same fields.
+func (fi *fieldIndex) reset() *fieldIndex {
+	if fi == nil {
+		return nil
+	}
+	fields := make([]string, 0, len(fi.values))
+	for field := range fi.values {
+		fields = append(fields, field)
+	}
+	return newFieldIndex(fields)
+}
+
+// add indexes the fields of a node version.
+func (fi *fieldIndex) add(node *Node) {
+	if fi == nil {
+		return
+	}
+	for field, byValue := range fi.values {
+		value, ok := node.Data[field]
+		if !ok || !indexable(value) {
+			continue
+		}
+		ids := byValue[value]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			byValue[value] = ids
+		}
+		ids[node.ID] = struct{}{}
+	}
+}
+
+// remove drops the fields of a node version from the index.
+func (fi *fieldIndex) remove(node *Node) {
+	if fi == nil {
+		return
+	}
+	for field, byValue := range fi.values {
+		value, ok := node.Data[field]
+		if !ok || !indexable(value) {
+			continue
+		}
+		if ids := byValue[value]; ids != nil {
+			delete(ids, node.ID)
+			if len(ids) == 0 {
+				delete(byValue, value)
+			}
+		}
+	}
+}
+
+// lookup returns the IDs of live nodes whose current version holds value in
+// field, and whether the index can answer: the field is indexed and the
+// value can be a map key.
+func (fi *fieldIndex) lookup(field string, value interface{}) (map[string]struct{}, bool) {
+	if fi == nil || !indexable(value) {
+		return nil, false
+	}
+	byValue, ok := fi.values[field]
+	if !ok {
+		return nil, false
+	}
+	return byValue[value], true
+}
+
+// indexable reports whether value can be a map key. JSON data decodes to
+// these types, and WithData compares with ==, so equal keys mean equal values.
+func indexable(value interface{}) bool {
+	switch value.(type) {
+	case string, bool, float64, float32, int, int64, int32, uint, uint64, uint32:
+		return true
+	default:
+		return false
+	}
+}
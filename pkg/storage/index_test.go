@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// objectiveIDs returns the sorted IDs of the live objectives with the given
+// goal_id and status, as found by a query.
+func objectiveIDs(t *testing.T, store *Store, goalID, status string) []string {
+	t.Helper()
+	nodes, err := store.Nodes().OfType("objective").WithData("goal_id", goalID).WithData("status", status).AllContext(context.Background())
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestFieldIndex_FollowsNewVersions(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	objective := NewNode("objective", map[string]interface{}{"goal_id": "g1", "status": "pending"})
+	other := NewNode("objective", map[string]interface{}{"goal_id": "g2", "status": "pending"})
+	for _, node := range []*Node{objective, other} {
+		if err := store.AddNode(ctx, node); err != nil {
+			t.Fatalf("Failed to add node: %v", err)
+		}
+	}
+	if ids := objectiveIDs(t, store, "g1", "pending"); len(ids) != 1 || ids[0] != objective.ID {
+		t.Fatalf("Expected the pending objective of g1, got %v", ids)
+	}
+
+	// The new version moves the node to another value; the old one no longer matches
+	if err := store.UpdateNode(ctx, objective.ID, map[string]interface{}{"goal_id": "g1", "status": "completed"}); err != nil {
+		t.Fatalf("UpdateNode failed: %v", err)
+	}
+	if ids := objectiveIDs(t, store, "g1", "pending"); len(ids) != 0 {
+		t.Errorf("Expected no pending objectives of g1 after the update, got %v", ids)
+	}
+	if ids := objectiveIDs(t, store, "g1", "completed"); len(ids) != 1 || ids[0] != objective.ID {
+		t.Errorf("Expected the completed objective of g1, got %v", ids)
+	}
+
+	// Dropping the field removes the node from the index
+	if err := store.UpdateNode(ctx, objective.ID, map[string]interface{}{"status": "completed"}); err != nil {
+		t.Fatalf("UpdateNode failed: %v", err)
+	}
+	if ids := objectiveIDs(t, store, "g1", "completed"); len(ids) != 0 {
+		t.Errorf("Expected the objective without a goal to leave the index, got %v", ids)
+	}
+
+	// Earlier versions are still found by temporal queries, which scan
+	past, err := store.Nodes().WithData("status", "pending").AsOf(other.ValidFrom).AllContext(ctx)
+	if err != nil {
+		t.Fatalf("AsOf query failed: %v", err)
+	}
+	if len(past) != 2 {
+		t.Errorf("Expected both objectives pending at the start, got %d", len(past))
+	}
+
+	// A store opened from disk rebuilds the same index
+	reopened, err := NewStore(store.DataDir())
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if ids := objectiveIDs(t, reopened, "g2", "pending"); len(ids) != 1 || ids[0] != other.ID {
+		t.Errorf("Expected the index rebuilt on load, got %v", ids)
+	}
+	if ids := objectiveIDs(t, reopened, "g1", "pending"); len(ids) != 0 {
+		t.Errorf("Expected superseded versions to stay out of the rebuilt index, got %v", ids)
+	}
+}
+
+func TestFieldIndex_MatchesScan(t *testing.T) {
+	ctx := context.Background()
+	indexed, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	scanned, err := NewStore(t.TempDir(), IndexFields())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	for i := 0; i < 40; i++ {
+		data := map[string]interface{}{"goal_id": fmt.Sprintf("g%d", i%4), "status": []string{"pending", "active"}[i%2]}
+		if i%5 == 0 {
+			data["status"] = []interface{}{"not", "comparable"}
+		}
+		for _, store := range []*Store{indexed, scanned} {
+			node := NewNodeWithID(fmt.Sprintf("n%d", i), "objective", data)
+			if err := store.AddNode(ctx, node); err != nil {
+				t.Fatalf("Failed to add node: %v", err)
+			}
+		}
+	}
+	// Re-adding a node as another type takes it out of its old partition
+	for _, store := range []*Store{indexed, scanned} {
+		if err := store.AddNode(ctx, NewNodeWithID("n1", "note", map[string]interface{}{"goal_id": "g1", "status": "active"})); err != nil {
+			t.Fatalf("Failed to re-add node: %v", err)
+		}
+	}
+
+	for _, goalID := range []string{"g0", "g1", "g2", "g3", "g4"} {
+		for _, status := range []string{"pending", "active"} {
+			want, got := objectiveIDs(t, scanned, goalID, status), objectiveIDs(t, indexed, goalID, status)
+			if fmt.Sprint(want) != fmt.Sprint(got) {
+				t.Errorf("%s/%s: index found %v, scan found %v", goalID, status, got, want)
+			}
+		}
+	}
+
+	objectives, err := indexed.GetNodesByType(ctx, "objective")
+	if err != nil {
+		t.Fatalf("GetNodesByType failed: %v", err)
+	}
+	for _, node := range objectives {
+		if node.Type != "objective" {
+			t.Errorf("Expected only objectives, got %s of type %s", node.ID, node.Type)
+		}
+	}
+	if len(objectives) != 39 {
+		t.Errorf("Expected 39 objectives, got %d", len(objectives))
+	}
+}
+
+// populateBenchmarkStore fills a store with 10,000 nodes: objectives spread
+// over 50 goals, and as many execution results.
+func populateBenchmarkStore(b *testing.B, opts ...StoreOption) *Store {
+	b.Helper()
+	ctx := context.Background()
+	store, err := NewStore(b.TempDir(), opts...)
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 0; i < 10000; i++ {
+		nodeType := "objective"
+		if i%2 == 1 {
+			nodeType = "execution_result"
+		}
+		node := NewNode(nodeType, map[string]interface{}{
+			"goal_id": fmt.Sprintf("goal-%d", i%100),
+			"status":  []string{"pending", "active", "completed", "failed"}[i%4],
+		})
+		if err := store.AddNode(ctx, node); err != nil {
+			b.Fatalf("Failed to add node: %v", err)
+		}
+	}
+	return store
+}
+
+func benchmarkObjectivesOfGoal(b *testing.B, opts ...StoreOption) {
+	store := populateBenchmarkStore(b, opts...)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nodes, err := store.Nodes().OfType("objective").WithData("goal_id", "goal-42").AllContext(ctx)
+		if err != nil || len(nodes) != 100 {
+			b.Fatalf("Expected 100 objectives, got %d (%v)", len(nodes), err)
+		}
+	}
+}
+
+func BenchmarkObjectivesOfGoal_Indexed(b *testing.B) {
+	benchmarkObjectivesOfGoal(b)
+}
+
+func BenchmarkObjectivesOfGoal_Scan(b *testing.B) {
+	benchmarkObjectivesOfGoal(b, IndexFields())
+}
+
+func BenchmarkGetNodesByType(b *testing.B) {
+	store := populateBenchmarkStore(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nodes, err := store.GetNodesByType(ctx, "objective")
+		if err != nil || len(nodes) != 5000 {
+			b.Fatalf("Expected 5000 objectives, got %d (%v)", len(nodes), err)
+		}
+	}
+}
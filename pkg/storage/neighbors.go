@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Direction selects which of a node's edges a traversal follows.
+type Direction int
+
+const (
+	// DirectionBoth follows edges from and to the node
+	DirectionBoth Direction = iota
+
+	// DirectionOutgoing follows edges whose source is the node
+	DirectionOutgoing
+
+	// DirectionIncoming follows edges whose target is the node
+	DirectionIncoming
+)
+
+// NeighborOptions restricts the traversal GetNeighbors makes. The zero value
+// follows every current edge one hop in either direction.
+type NeighborOptions struct {
+	// Direction is the direction edges are followed in, from each node
+	// reached
+	Direction Direction
+
+	// EdgeTypes limits the edges followed to these types (empty: all)
+	EdgeTypes []string
+
+	// NodeTypes limits the nodes returned to these types (empty: all).
+	// Nodes of other types are not traversed through either.
+	NodeTypes []string
+
+	// MaxDepth is the number of hops to traverse (0: 1, direct neighbors)
+	MaxDepth int
+
+	// Limit stops the traversal once this many nodes are found (0: no limit)
+	Limit int
+
+	// AsOf follows the edges and returns the node versions current at this
+	// time (zero: now)
+	AsOf time.Time
+
+	// IncludeArchived returns archived nodes that live edges lead to.
+	// Archived edges are never followed.
+	IncludeArchived bool
+}
+
+// NeighborResult holds the nodes a traversal reached, nearest first, and
+// the edges it followed: Edges[i] is the edge Nodes[i] was first reached by.
+type NeighborResult struct {
+	Nodes []*Node
+	Edges []*Edge
+}
+
+// GetNeighbors returns the live nodes, and archived ones on request,
+// connected to the given node through live edges, as restricted by opts. With a MaxDepth over 1 it traverses
+// breadth first, reaching each node once however many paths lead to it.
+// Nodes found at the same depth are ordered by the IDs of the edges that led
+// to them.
+func (s *Store) GetNeighbors(ctx context.Context, nodeID string, opts NeighborOptions) (*NeighborResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	depth := opts.MaxDepth
+	if depth < 1 {
+		depth = 1
+	}
+	edgeTypes := stringSet(opts.EdgeTypes)
+	nodeTypes := stringSet(opts.NodeTypes)
+
+	result := &NeighborResult{}
+	visited := map[string]bool{nodeID: true}
+	frontier := map[string]bool{nodeID: true}
+	guard := &scanGuard{ctx: ctx}
+
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		// One pass over the edges finds every edge leaving the frontier
+		var followed []*Edge
+		for _, history := range s.candidateEdges(opts.EdgeTypes, opts.AsOf) {
+			if guard.stopped() {
+				return nil, guard.err
+			}
+			edge := s.edgeVersion(history, opts.AsOf)
+			if edge == nil || (edgeTypes != nil && !edgeTypes[edge.Type]) {
+				continue
+			}
+			if _, ok := neighborVia(edge, frontier, opts.Direction); ok {
+				followed = append(followed, edge)
+			}
+		}
+		sort.Slice(followed, func(i, j int) bool { return followed[i].ID < followed[j].ID })
+
+		next := make(map[string]bool)
+		for _, edge := range followed {
+			neighborID, _ := neighborVia(edge, frontier, opts.Direction)
+			if visited[neighborID] {
+				continue
+			}
+			visited[neighborID] = true
+
+			neighbor := s.neighborVersion(neighborID, opts)
+			if neighbor == nil || (nodeTypes != nil && !nodeTypes[neighbor.Type]) {
+				continue
+			}
+			result.Nodes = append(result.Nodes, neighbor)
+			result.Edges = append(result.Edges, edge)
+			if opts.Limit > 0 && len(result.Nodes) >= opts.Limit {
+				return result, nil
+			}
+			next[neighborID] = true
+		}
+		frontier = next
+	}
+
+	return result, nil
+}
+
+// candidateEdges returns the histories of the edges a traversal may follow.
+// A traversal as of now that follows only some types of edge looks them up
+// in the type index, which holds current versions only. Callers hold the
+// read lock.
+func (s *Store) candidateEdges(edgeTypes []string, asOf time.Time) []EdgeHistory {
+	if len(edgeTypes) > 0 && asOf.IsZero() {
+		typed := 0
+		for _, edgeType := range edgeTypes {
+			typed += len(s.edgesByType[edgeType])
+		}
+		if typed < len(s.edges) {
+			histories := make([]EdgeHistory, 0, typed)
+			seen := make(map[string]bool, typed)
+			for _, edgeType := range edgeTypes {
+				for _, edge := range s.edgesByType[edgeType] {
+					if !seen[edge.ID] {
+						seen[edge.ID] = true
+						histories = append(histories, s.edges[edge.ID])
+					}
+				}
+			}
+			return histories
+		}
+	}
+	histories := make([]EdgeHistory, 0, len(s.edges))
+	for _, history := range s.edges {
+		histories = append(histories, history)
+	}
+	return histories
+}
+
+// edgeVersion returns the version of an edge current at asOf, or now when
+// asOf is zero.
+func (s *Store) edgeVersion(history EdgeHistory, asOf time.Time) *Edge {
+	if asOf.IsZero() {
+		return history.GetCurrentVersion()
+	}
+	return history.GetVersionAt(asOf)
+}
+
+// neighborVersion returns the version of a node a traversal reached that is
+// current at opts.AsOf, or nil if it has none or is archived and opts does
+// not include archived nodes. Callers hold the read lock.
+func (s *Store) neighborVersion(nodeID string, opts NeighborOptions) *Node {
+	history, exists := s.nodes[nodeID]
+	if !exists && opts.IncludeArchived {
+		history, exists, _ = s.archive.node(nodeID)
+	}
+	if !exists {
+		return nil
+	}
+	if opts.AsOf.IsZero() {
+		return history.GetCurrentVersion()
+	}
+	return history.GetVersionAt(opts.AsOf)
+}
+
+// neighborVia returns the node edge leads to from a node in frontier,
+// following it only in the given direction.
+func neighborVia(edge *Edge, frontier map[string]bool, direction Direction) (string, bool) {
+	if direction != DirectionIncoming && frontier[edge.SourceID] {
+		return edge.TargetID, true
+	}
+	if direction != DirectionOutgoing && frontier[edge.TargetID] {
+		return edge.SourceID, true
+	}
+	return "", false
+}
+
+// stringSet returns the values as a set, or nil for none.
+func stringSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
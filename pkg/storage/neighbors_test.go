@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// neighborGraph is a goal with two sub-goals, one of which has a sub-goal of
+// its own, an objective using a method, and an edge back from the goal to
+// the deepest sub-goal that closes a cycle.
+type neighborGraph struct {
+	store                                 *Store
+	clock                                 *utils.FakeClock
+	goal, sub1, sub2, subSub, obj, method *Node
+}
+
+func setupNeighborGraph(t *testing.T) *neighborGraph {
+	t.Helper()
+	ctx := context.Background()
+	clock := utils.NewFakeClock(time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC))
+	store, err := NewStore(t.TempDir(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	g := &neighborGraph{
+		store:  store,
+		clock:  clock,
+		goal:   NewNode("goal", map[string]interface{}{"title": "goal"}),
+		sub1:   NewNode("goal", map[string]interface{}{"title": "sub1"}),
+		sub2:   NewNode("goal", map[string]interface{}{"title": "sub2"}),
+		subSub: NewNode("goal", map[string]interface{}{"title": "subSub"}),
+		obj:    NewNode("objective", map[string]interface{}{"title": "obj"}),
+		method: NewNode("method", map[string]interface{}{"name": "method"}),
+	}
+	for _, node := range []*Node{g.goal, g.sub1, g.sub2, g.subSub, g.obj, g.method} {
+		if err := store.AddNode(ctx, node); err != nil {
+			t.Fatalf("Failed to add node: %v", err)
+		}
+	}
+	for _, edge := range []*Edge{
+		NewEdge(g.sub1.ID, g.goal.ID, "serves", nil),
+		NewEdge(g.sub2.ID, g.goal.ID, "serves", nil),
+		NewEdge(g.subSub.ID, g.sub1.ID, "serves", nil),
+		NewEdge(g.obj.ID, g.goal.ID, "serves", nil),
+		NewEdge(g.obj.ID, g.method.ID, "uses", nil),
+		NewEdge(g.goal.ID, g.subSub.ID, "relates", nil),
+	} {
+		if err := store.AddEdge(ctx, edge); err != nil {
+			t.Fatalf("Failed to add edge: %v", err)
+		}
+	}
+	return g
+}
+
+// titles maps the nodes' titles, or names for methods, to their positions.
+func titles(nodes []*Node) map[string]int {
+	found := make(map[string]int)
+	for i, node := range nodes {
+		title, _ := node.Data["title"].(string)
+		if title == "" {
+			title, _ = node.Data["name"].(string)
+		}
+		found[title] = i
+	}
+	return found
+}
+
+func TestGetNeighbors_Filters(t *testing.T) {
+	ctx := context.Background()
+	g := setupNeighborGraph(t)
+
+	// Sub-goals only: incoming "serves" edges from goals
+	result, err := g.store.GetNeighbors(ctx, g.goal.ID, NeighborOptions{
+		Direction: DirectionIncoming, EdgeTypes: []string{"serves"}, NodeTypes: []string{"goal"},
+	})
+	if err != nil {
+		t.Fatalf("GetNeighbors failed: %v", err)
+	}
+	found := titles(result.Nodes)
+	if len(found) != 2 || !hasKeys(found, "sub1", "sub2") {
+		t.Errorf("Expected sub1 and sub2, got %v", found)
+	}
+	for i, edge := range result.Edges {
+		if edge.Type != "serves" || edge.SourceID != result.Nodes[i].ID || edge.TargetID != g.goal.ID {
+			t.Errorf("Expected node %d to be reached by its serves edge, got %+v", i, edge)
+		}
+	}
+
+	// Parents: outgoing edges only
+	result, err = g.store.GetNeighbors(ctx, g.sub1.ID, NeighborOptions{Direction: DirectionOutgoing})
+	if err != nil || len(result.Nodes) != 1 || result.Nodes[0].ID != g.goal.ID {
+		t.Errorf("Expected sub1's one parent, got %v, %v", titles(result.Nodes), err)
+	}
+
+	// Every edge type, both directions
+	result, err = g.store.GetNeighbors(ctx, g.goal.ID, NeighborOptions{})
+	if err != nil || !hasKeys(titles(result.Nodes), "sub1", "sub2", "obj", "subSub") || len(result.Nodes) != 4 {
+		t.Errorf("Expected all four direct neighbors, got %v, %v", titles(result.Nodes), err)
+	}
+}
+
+func TestGetNeighbors_Depth(t *testing.T) {
+	ctx := context.Background()
+	g := setupNeighborGraph(t)
+
+	// The whole sub-goal tree, nearest first
+	result, err := g.store.GetNeighbors(ctx, g.goal.ID, NeighborOptions{
+		Direction: DirectionIncoming, EdgeTypes: []string{"serves"}, NodeTypes: []string{"goal"}, MaxDepth: 3,
+	})
+	if err != nil {
+		t.Fatalf("GetNeighbors failed: %v", err)
+	}
+	found := titles(result.Nodes)
+	if len(found) != 3 || found["subSub"] != 2 {
+		t.Errorf("Expected sub1 and sub2 then subSub, got %v", found)
+	}
+	if edge := result.Edges[found["subSub"]]; edge.SourceID != g.subSub.ID || edge.TargetID != g.sub1.ID {
+		t.Errorf("Expected subSub reached through sub1, got %+v", edge)
+	}
+
+	// The cycle through the relates edge reaches each node once
+	result, err = g.store.GetNeighbors(ctx, g.goal.ID, NeighborOptions{MaxDepth: 10})
+	if err != nil || len(result.Nodes) != 5 || len(titles(result.Nodes)) != 5 {
+		t.Errorf("Expected the 5 other nodes once each, got %v, %v", titles(result.Nodes), err)
+	}
+	if _, ok := titles(result.Nodes)["goal"]; ok {
+		t.Error("Expected the starting node not to be returned")
+	}
+
+	result, err = g.store.GetNeighbors(ctx, g.goal.ID, NeighborOptions{MaxDepth: 10, Limit: 2})
+	if err != nil || len(result.Nodes) != 2 || len(result.Edges) != 2 {
+		t.Errorf("Expected the limit to stop at 2 nodes, got %d, %v", len(result.Nodes), err)
+	}
+}
+
+func TestGetNeighbors_AsOfAndArchived(t *testing.T) {
+	ctx := context.Background()
+	g := setupNeighborGraph(t)
+	before := g.clock.Now()
+
+	g.clock.Advance(time.Hour)
+	late := NewNode("goal", map[string]interface{}{"title": "late"})
+	if err := g.store.AddNode(ctx, late); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := g.store.AddEdge(ctx, NewEdge(late.ID, g.goal.ID, "serves", nil)); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+
+	opts := NeighborOptions{Direction: DirectionIncoming, EdgeTypes: []string{"serves"}, NodeTypes: []string{"goal"}}
+	if result, err := g.store.GetNeighbors(ctx, g.goal.ID, opts); err != nil || len(result.Nodes) != 3 {
+		t.Errorf("Expected 3 sub-goals now, got %v, %v", titles(result.Nodes), err)
+	}
+	opts.AsOf = before
+	if result, err := g.store.GetNeighbors(ctx, g.goal.ID, opts); err != nil || len(result.Nodes) != 2 || hasKeys(titles(result.Nodes), "late") {
+		t.Errorf("Expected the 2 sub-goals of before, got %v, %v", titles(result.Nodes), err)
+	}
+
+	// An archived parent is reached only on request
+	if _, err := g.store.ArchiveNodes(ctx, []string{g.goal.ID}); err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+	opts = NeighborOptions{Direction: DirectionOutgoing, EdgeTypes: []string{"serves"}}
+	if result, err := g.store.GetNeighbors(ctx, g.sub1.ID, opts); err != nil || len(result.Nodes) != 0 {
+		t.Errorf("Expected no live parent, got %v, %v", titles(result.Nodes), err)
+	}
+	opts.IncludeArchived = true
+	if result, err := g.store.GetNeighbors(ctx, g.sub1.ID, opts); err != nil || len(result.Nodes) != 1 || result.Nodes[0].ID != g.goal.ID {
+		t.Errorf("Expected the archived parent, got %v, %v", titles(result.Nodes), err)
+	}
+}
+
+// hasKeys reports whether found has every key.
+func hasKeys(found map[string]int, keys ...string) bool {
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
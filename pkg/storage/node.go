@@ -29,6 +29,10 @@ type Node struct {
 	// ValidUntil is when this version was superseded.
 	// Zero time (time.Time{}) indicates the current active version.
 	ValidUntil time.Time `json:"valid_until"`
+
+	// stampedAt is the constructor's timestamp; a store with its own clock
+	// restamps the version unless the caller changed ValidFrom
+	stampedAt time.Time
 }
 
 // NewNode creates a new node with the given type and data.
@@ -42,6 +46,7 @@ func NewNode(nodeType string, data map[string]interface{}) *Node {
 		CreatedAt:  now,
 		ValidFrom:  now,
 		ValidUntil: time.Time{}, // Zero time means current version
+		stampedAt:  now,
 	}
 }
 
@@ -56,6 +61,7 @@ func NewNodeWithID(id, nodeType string, data map[string]interface{}) *Node {
 		CreatedAt:  now,
 		ValidFrom:  now,
 		ValidUntil: time.Time{},
+		stampedAt:  now,
 	}
 }
 
@@ -0,0 +1,543 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The live pack holds the histories of all live nodes and edges in one file,
+// data/live.pack, so opening a large store reads one file instead of one per
+// node and edge. The history files stay authoritative: the pack records the
+// modification times of the node and edge directories it was written from,
+// and is only used while they are unchanged.
+//
+// A writable store sets those times itself, to the nanosecond, when it loads
+// and after each of its own writes, so any later change to a directory gives
+// it a different time even on file systems that keep coarse ones. Close
+// writes the pack only if the directories still carry the times the store
+// set, that is, if no one else changed the files since; otherwise the next
+// open reads the history files. History files edited in place, which leaves
+// the directory times alone, go unnoticed: remove the pack after doing so.
+const (
+	livePackName  = "live.pack"
+	livePackMagic = "LIVEPACK1\n"
+)
+
+// Tags of the data values in a live pack.
+const (
+	packNull byte = iota
+	packFalse
+	packTrue
+	packNumber
+	packString
+	packArray
+	packObject
+)
+
+// errLivePack reports a live pack that cannot be read; the store then reads
+// the history files instead.
+var errLivePack = errors.New("malformed live pack")
+
+// dirStamps maps the node and edge directories, relative to the data
+// directory, to their modification times in nanoseconds.
+type dirStamps map[string]int64
+
+// equal reports whether both stamps name the same directories and times.
+func (d dirStamps) equal(other dirStamps) bool {
+	if d == nil || other == nil || len(d) != len(other) {
+		return false
+	}
+	for dir, stamp := range d {
+		if other[dir] != stamp {
+			return false
+		}
+	}
+	return true
+}
+
+// readDirStamps stamps the nodes directory, each node type directory and the
+// edges directory. With touch, it first sets their modification times to now.
+func (s *Store) readDirStamps(touch bool) (dirStamps, error) {
+	stamps := make(dirStamps)
+	dirs := []string{"nodes", "edges"}
+	entries, err := os.ReadDir(filepath.Join(s.dataDir, "nodes"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join("nodes", entry.Name()))
+		}
+	}
+	for _, dir := range dirs {
+		if err := stamps.read(s.dataDir, dir, touch); err != nil {
+			return nil, err
+		}
+	}
+	return stamps, nil
+}
+
+// read records the modification time of dir under dataDir, after setting it
+// to now with touch; a missing directory is recorded as zero.
+func (d dirStamps) read(dataDir, dir string, touch bool) error {
+	path := filepath.Join(dataDir, dir)
+	if touch {
+		if err := os.Chtimes(path, time.Time{}, time.Now()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		d[filepath.ToSlash(dir)] = 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	d[filepath.ToSlash(dir)] = info.ModTime().UnixNano()
+	return nil
+}
+
+// checkDirs is called before the store changes files in dirs; if someone
+// else changed them since the store stamped them, its memory may no longer
+// match the files, and it stops writing a pack. Callers hold the write lock.
+func (s *Store) checkDirs(dirs ...string) {
+	if s.dirStamps == nil {
+		return
+	}
+	current := make(dirStamps)
+	for _, dir := range dirs {
+		key := filepath.ToSlash(dir)
+		if err := current.read(s.dataDir, dir, false); err != nil || current[key] != s.dirStamps[key] {
+			s.dirStamps = nil
+			return
+		}
+	}
+}
+
+// stampDirs restamps dirs after the store changed files in them. Callers
+// hold the write lock.
+func (s *Store) stampDirs(dirs ...string) {
+	if s.dirStamps == nil {
+		return
+	}
+	for _, dir := range dirs {
+		if err := s.dirStamps.read(s.dataDir, dir, true); err != nil {
+			s.dirStamps = nil
+			return
+		}
+	}
+}
+
+// loadLivePack loads the live nodes and edges from the live pack, if there is
+// one written from the directories as they are now. It reports whether it
+// did; if not, nothing was loaded.
+func (s *Store) loadLivePack(stamps dirStamps) bool {
+	data, err := os.ReadFile(filepath.Join(s.dataDir, livePackName))
+	if err != nil {
+		return false
+	}
+
+	r := &packReader{data: data}
+	nodes, edges, packed, err := r.read()
+	if err != nil || !packed.equal(stamps) {
+		return false
+	}
+
+	if len(s.nodes) == 0 {
+		s.nodes = make(map[string]NodeHistory, len(nodes))
+	}
+	if len(s.edges) == 0 {
+		s.edges = make(map[string]EdgeHistory, len(edges))
+	}
+	for _, history := range nodes {
+		s.addLoadedNode(history)
+	}
+	for _, history := range edges {
+		s.addLoadedEdge(history)
+	}
+	s.packStamps = packed
+	return true
+}
+
+// writeLivePack packs the live nodes and edges, if the store's memory is known
+// to match the history files and the pack on disk does not already hold them.
+// Callers hold the write lock.
+func (s *Store) writeLivePack() error {
+	if s.readOnly || s.dirStamps == nil {
+		return nil
+	}
+	stamps, err := s.readDirStamps(false)
+	if err != nil {
+		return fmt.Errorf("failed to stamp data directories: %w", err)
+	}
+	if !stamps.equal(s.dirStamps) || stamps.equal(s.packStamps) {
+		return nil
+	}
+
+	var w packWriter
+	w.write(s.nodes, s.edges)
+	if w.err != nil {
+		return fmt.Errorf("failed to write live pack: %w", w.err)
+	}
+
+	path := filepath.Join(s.dataDir, livePackName)
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to write live pack: %w", err)
+	}
+	out := bufio.NewWriter(file)
+	w.header(out, stamps)
+	_, err = w.body.WriteTo(out)
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write live pack: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write live pack: %w", err)
+	}
+	s.packStamps = stamps
+	return nil
+}
+
+// packWriter encodes a live pack: a header with the directory stamps, then
+// a table of the distinct strings, then the node and edge histories, which
+// refer to strings by their index in the table. err keeps the first data
+// value that could not be encoded.
+type packWriter struct {
+	body    bytes.Buffer
+	strings map[string]uint64
+	table   []string
+	buf     [binary.MaxVarintLen64]byte
+	err     error
+}
+
+// write encodes every node and edge history into the body.
+func (p *packWriter) write(nodes map[string]NodeHistory, edges map[string]EdgeHistory) {
+	p.strings = make(map[string]uint64)
+
+	p.uvarint(uint64(len(nodes)))
+	for _, history := range nodes {
+		p.uvarint(uint64(len(history)))
+		for _, node := range history {
+			p.string(node.ID)
+			p.string(node.Type)
+			p.value(node.Data)
+			p.time(node.CreatedAt)
+			p.time(node.ValidFrom)
+			p.time(node.ValidUntil)
+		}
+	}
+
+	p.uvarint(uint64(len(edges)))
+	for _, history := range edges {
+		p.uvarint(uint64(len(history)))
+		for _, edge := range history {
+			p.string(edge.ID)
+			p.string(edge.SourceID)
+			p.string(edge.TargetID)
+			p.string(edge.Type)
+			p.value(edge.Data)
+			p.time(edge.CreatedAt)
+			p.time(edge.ValidFrom)
+			p.time(edge.ValidUntil)
+		}
+	}
+}
+
+// header writes the magic, the directory stamps and the string table to out.
+func (p *packWriter) header(out *bufio.Writer, stamps dirStamps) {
+	put := func(v uint64) {
+		out.Write(p.buf[:binary.PutUvarint(p.buf[:], v)])
+	}
+	out.WriteString(livePackMagic)
+	put(uint64(len(stamps)))
+	for dir, stamp := range stamps {
+		put(uint64(len(dir)))
+		out.WriteString(dir)
+		out.Write(p.buf[:binary.PutVarint(p.buf[:], stamp)])
+	}
+	put(uint64(len(p.table)))
+	for _, str := range p.table {
+		put(uint64(len(str)))
+		out.WriteString(str)
+	}
+}
+
+func (p *packWriter) uvarint(v uint64) {
+	p.body.Write(p.buf[:binary.PutUvarint(p.buf[:], v)])
+}
+
+func (p *packWriter) varint(v int64) {
+	p.body.Write(p.buf[:binary.PutVarint(p.buf[:], v)])
+}
+
+// string writes the index of s in the string table, adding it if needed.
+func (p *packWriter) string(s string) {
+	index, ok := p.strings[s]
+	if !ok {
+		index = uint64(len(p.table))
+		p.strings[s] = index
+		p.table = append(p.table, s)
+	}
+	p.uvarint(index)
+}
+
+// time encodes a time as the history files do: to the nanosecond, with its
+// zone offset.
+func (p *packWriter) time(t time.Time) {
+	_, offset := t.Zone()
+	p.varint(t.Unix())
+	p.uvarint(uint64(t.Nanosecond()))
+	p.varint(int64(offset))
+}
+
+// value encodes a JSON value. Values of other Go types, which only data
+// that has not been read back from disk holds, are encoded as reading back
+// their JSON would decode them.
+func (p *packWriter) value(v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		p.body.WriteByte(packNull)
+	case bool:
+		if v {
+			p.body.WriteByte(packTrue)
+		} else {
+			p.body.WriteByte(packFalse)
+		}
+	case float64:
+		p.body.WriteByte(packNumber)
+		p.body.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+	case string:
+		p.body.WriteByte(packString)
+		p.string(v)
+	case []interface{}:
+		if v == nil {
+			p.body.WriteByte(packNull)
+			return
+		}
+		p.body.WriteByte(packArray)
+		p.uvarint(uint64(len(v)))
+		for _, item := range v {
+			p.value(item)
+		}
+	case map[string]interface{}:
+		if v == nil {
+			p.body.WriteByte(packNull)
+			return
+		}
+		p.body.WriteByte(packObject)
+		p.uvarint(uint64(len(v)))
+		for key, item := range v {
+			p.string(key)
+			p.value(item)
+		}
+	default:
+		var decoded interface{}
+		encoded, err := json.Marshal(v)
+		if err == nil {
+			err = json.Unmarshal(encoded, &decoded)
+		}
+		if err != nil {
+			if p.err == nil {
+				p.err = err
+			}
+			return
+		}
+		p.value(decoded)
+	}
+}
+
+// packReader decodes a live pack held in memory.
+type packReader struct {
+	data  []byte
+	table []string
+	boxed []interface{} // Table strings as data values, boxed once each
+}
+
+// read decodes the directory stamps and the node and edge histories.
+func (r *packReader) read() (nodes []NodeHistory, edges []EdgeHistory, stamps dirStamps, err error) {
+	defer func() {
+		// Reads past the end of a truncated pack panic
+		if recover() != nil {
+			nodes, edges, stamps, err = nil, nil, nil, errLivePack
+		}
+	}()
+
+	if !bytes.HasPrefix(r.data, []byte(livePackMagic)) {
+		return nil, nil, nil, errLivePack
+	}
+	r.data = r.data[len(livePackMagic):]
+
+	stamps = make(dirStamps)
+	for i := r.count(); i > 0; i-- {
+		dir := string(r.bytes())
+		stamps[dir] = r.varint()
+	}
+	r.table = make([]string, r.count())
+	for i := range r.table {
+		r.table[i] = string(r.bytes())
+	}
+	r.boxed = make([]interface{}, len(r.table))
+
+	nodes = make([]NodeHistory, r.count())
+	for i := range nodes {
+		history := make(NodeHistory, r.count())
+		versions := make([]Node, len(history))
+		for j := range history {
+			node := &versions[j]
+			node.ID = r.string()
+			node.Type = r.string()
+			node.Data, _ = r.value().(map[string]interface{})
+			node.CreatedAt = r.time()
+			node.ValidFrom = r.time()
+			node.ValidUntil = r.time()
+			history[j] = node
+		}
+		if len(history) == 0 {
+			return nil, nil, nil, errLivePack
+		}
+		nodes[i] = history
+	}
+
+	edges = make([]EdgeHistory, r.count())
+	for i := range edges {
+		history := make(EdgeHistory, r.count())
+		versions := make([]Edge, len(history))
+		for j := range history {
+			edge := &versions[j]
+			edge.ID = r.string()
+			edge.SourceID = r.string()
+			edge.TargetID = r.string()
+			edge.Type = r.string()
+			edge.Data, _ = r.value().(map[string]interface{})
+			edge.CreatedAt = r.time()
+			edge.ValidFrom = r.time()
+			edge.ValidUntil = r.time()
+			history[j] = edge
+		}
+		if len(history) == 0 {
+			return nil, nil, nil, errLivePack
+		}
+		edges[i] = history
+	}
+
+	if len(r.data) != 0 {
+		return nil, nil, nil, errLivePack
+	}
+	return nodes, edges, stamps, nil
+}
+
+func (r *packReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		panic(errLivePack)
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *packReader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		panic(errLivePack)
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *packReader) byte() byte {
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+// count reads a length, which cannot exceed the bytes left in a valid pack.
+func (r *packReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		panic(errLivePack)
+	}
+	return int(n)
+}
+
+func (r *packReader) bytes() []byte {
+	n := r.count()
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *packReader) string() string {
+	return r.table[r.uvarint()]
+}
+
+// time decodes a time the way decoding its JSON would: UTC for a zero
+// offset, local time if the offset is the local zone's, otherwise a fixed
+// zone.
+func (r *packReader) time() time.Time {
+	sec := r.varint()
+	nsec := int64(r.uvarint())
+	offset := int(r.varint())
+	if offset == 0 {
+		return time.Unix(sec, nsec).UTC()
+	}
+	t := time.Unix(sec, nsec)
+	if _, local := t.Zone(); local != offset {
+		t = t.In(time.FixedZone("", offset))
+	}
+	return t
+}
+
+func (r *packReader) value() interface{} {
+	switch r.byte() {
+	case packNull:
+		return nil
+	case packFalse:
+		return false
+	case packTrue:
+		return true
+	case packNumber:
+		bits := binary.LittleEndian.Uint64(r.data[:8])
+		r.data = r.data[8:]
+		return math.Float64frombits(bits)
+	case packString:
+		index := r.uvarint()
+		if r.boxed[index] == nil {
+			r.boxed[index] = r.table[index]
+		}
+		return r.boxed[index]
+	case packArray:
+		array := make([]interface{}, r.count())
+		for i := range array {
+			array[i] = r.value()
+		}
+		return array
+	case packObject:
+		n := r.count()
+		object := make(map[string]interface{}, n)
+		for ; n > 0; n-- {
+			key := r.string()
+			object[key] = r.value()
+		}
+		return object
+	default:
+		panic(errLivePack)
+	}
+}
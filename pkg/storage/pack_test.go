@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// reopenStore closes store and opens its data directory again.
+func reopenStore(t *testing.T, store *Store) *Store {
+	t.Helper()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	reopened, err := NewStore(store.dataDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	return reopened
+}
+
+func TestLivePackRoundTrip(t *testing.T) {
+	tempDir := createTempDir(t)
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	goal := NewNode("goal", map[string]interface{}{
+		"title":    "Ship the release",
+		"priority": 3,
+		"done":     false,
+		"tags":     []string{"release", "q3"},
+		"owner":    map[string]interface{}{"name": "sam", "teams": []interface{}{"core", nil}},
+	})
+	if err := store.AddNode(ctx, goal); err != nil {
+		t.Fatalf("Failed to add goal: %v", err)
+	}
+	if err := store.UpdateNode(ctx, goal.ID, map[string]interface{}{"title": "Ship the release", "done": true}); err != nil {
+		t.Fatalf("Failed to update goal: %v", err)
+	}
+	method := NewNode("method", nil)
+	if err := store.AddNode(ctx, method); err != nil {
+		t.Fatalf("Failed to add method: %v", err)
+	}
+	edge := NewEdge(goal.ID, method.ID, "uses", map[string]interface{}{"weight": 0.5})
+	if err := store.AddEdge(ctx, edge); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+
+	packed := reopenStore(t, store)
+	defer packed.Close()
+	if _, err := os.Stat(filepath.Join(tempDir, livePackName)); err != nil {
+		t.Fatalf("Expected Close to write the live pack: %v", err)
+	}
+	if packed.packStamps == nil {
+		t.Fatal("Expected the reopened store to load the live pack")
+	}
+
+	// The pack must load exactly what reading the history files does
+	if err := os.Remove(filepath.Join(tempDir, livePackName)); err != nil {
+		t.Fatalf("Failed to remove live pack: %v", err)
+	}
+	scanned, err := NewStore(tempDir, ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if scanned.packStamps != nil {
+		t.Fatal("Expected the store to read the history files")
+	}
+	if !reflect.DeepEqual(packed.nodes, scanned.nodes) {
+		t.Errorf("Nodes from the live pack differ from the history files")
+	}
+	if !reflect.DeepEqual(packed.edges, scanned.edges) {
+		t.Errorf("Edges from the live pack differ from the history files")
+	}
+
+	results, err := packed.Search(ctx, "release", SearchOptions{})
+	if err != nil || len(results) != 1 || results[0].ID != goal.ID {
+		t.Errorf("Expected search to find the goal after loading the pack, got %v, %v", results, err)
+	}
+}
+
+func TestLivePackSkippedAfterOtherWrites(t *testing.T) {
+	tempDir := createTempDir(t)
+	ctx := context.Background()
+
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.AddNode(ctx, NewNode("goal", map[string]interface{}{"title": "first"})); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// Another store writes after the pack was written
+	other, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer other.Close()
+	second := NewNode("goal", map[string]interface{}{"title": "second"})
+	if err := other.AddNode(ctx, second); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	reopened, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.packStamps != nil {
+		t.Error("Expected a pack older than the files to be skipped")
+	}
+	if _, err := reopened.GetNode(ctx, second.ID); err != nil {
+		t.Errorf("Expected the node written after the pack: %v", err)
+	}
+}
+
+func TestLivePackNotWrittenOverOtherWrites(t *testing.T) {
+	tempDir := createTempDir(t)
+	ctx := context.Background()
+
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.AddNode(ctx, NewNode("goal", map[string]interface{}{"title": "first"})); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// Two stores open from the pack; the one that writes last has not seen
+	// what the other wrote, so it must not pack its memory
+	stale, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	other, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	second := NewNode("goal", map[string]interface{}{"title": "second"})
+	if err := other.AddNode(ctx, second); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := other.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	third := NewNode("goal", map[string]interface{}{"title": "third"})
+	if err := stale.AddNode(ctx, third); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	final := reopenStore(t, stale)
+	defer final.Close()
+	for _, node := range []*Node{second, third} {
+		if _, err := final.GetNode(ctx, node.ID); err != nil {
+			t.Errorf("Expected node %s after both stores closed: %v", node.Data["title"], err)
+		}
+	}
+}
+
+func TestLivePackMalformed(t *testing.T) {
+	tempDir := createTempDir(t)
+	ctx := context.Background()
+
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	node := NewNode("goal", map[string]interface{}{"title": "kept"})
+	if err := store.AddNode(ctx, node); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// A truncated pack is ignored in favor of the history files
+	path := filepath.Join(tempDir, livePackName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read live pack: %v", err)
+	}
+	if err := os.WriteFile(path, data[:len(data)-3], 0644); err != nil {
+		t.Fatalf("Failed to truncate live pack: %v", err)
+	}
+
+	reopened, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.packStamps != nil {
+		t.Error("Expected the truncated pack to be skipped")
+	}
+	if _, err := reopened.GetNode(ctx, node.ID); err != nil {
+		t.Errorf("Expected the node from its history file: %v", err)
+	}
+}
@@ -7,18 +7,29 @@ import (
 
 // NodeQuery provides a fluent interface for querying nodes.
 // It uses the builder pattern to construct filters and executes them lazily.
+// Queries cover live nodes unless IncludeArchived is used.
 type NodeQuery struct {
 	store     *Store
 	filters   []NodeFilter
 	timeQuery *TimeQuery
+	scope     queryScope
 }
 
 // EdgeQuery provides a fluent interface for querying edges.
 // It uses the builder pattern to construct filters and executes them lazily.
+// Queries cover live edges unless IncludeArchived is used.
 type EdgeQuery struct {
 	store     *Store
 	filters   []EdgeFilter
 	timeQuery *TimeQuery
+	scope     queryScope
+}
+
+// queryScope narrows the candidates a query scans before filters are applied.
+type queryScope struct {
+	typeName        string          // Only scan the type partition of this node or edge type
+	includeArchived bool            // Also scan archive segments
+	data            []dataPredicate // WithData conditions the field index may answer
 }
 
 // NodeFilter is a function that filters nodes based on criteria.
@@ -27,6 +38,30 @@ type NodeFilter func(*Node) bool
 // EdgeFilter is a function that filters edges based on criteria.
 type EdgeFilter func(*Edge) bool
 
+// scanCheckInterval is how many items a scan visits between checks of its
+// context, so a cancelled scan stops promptly without a check per item.
+const scanCheckInterval = 256
+
+// scanGuard stops a long scan once its context is done.
+type scanGuard struct {
+	ctx   context.Context
+	count int
+	err   error
+}
+
+// stopped counts one visited item and reports whether the scan should stop.
+// The context is checked every scanCheckInterval items.
+func (g *scanGuard) stopped() bool {
+	if g.err != nil {
+		return true
+	}
+	g.count++
+	if g.count%scanCheckInterval == 0 {
+		g.err = g.ctx.Err()
+	}
+	return g.err != nil
+}
+
 // TimeQuery holds temporal query parameters.
 type TimeQuery struct {
 	asOf      *time.Time
@@ -59,10 +94,32 @@ func (nq *NodeQuery) OfType(nodeType string) *NodeQuery {
 		return n.Type == nodeType
 	})
 
+	newScope := nq.scope
+	newScope.typeName = nodeType
+
 	return &NodeQuery{
 		store:     nq.store,
 		filters:   newFilters,
 		timeQuery: nq.timeQuery, // Shallow copy is OK for timeQuery
+		scope:     newScope,
+	}
+}
+
+// IncludeArchived makes the query also cover archived nodes.
+// The first such query reads every archive segment into memory, except in
+// LowMemory stores, which read them one at a time.
+func (nq *NodeQuery) IncludeArchived() *NodeQuery {
+	newFilters := make([]NodeFilter, len(nq.filters))
+	copy(newFilters, nq.filters)
+
+	newScope := nq.scope
+	newScope.includeArchived = true
+
+	return &NodeQuery{
+		store:     nq.store,
+		filters:   newFilters,
+		timeQuery: nq.timeQuery,
+		scope:     newScope,
 	}
 }
 
@@ -83,10 +140,14 @@ func (nq *NodeQuery) WithData(dataKey string, expectedValue interface{}) *NodeQu
 		return value == expectedValue
 	})
 
+	newScope := nq.scope
+	newScope.data = append(append([]dataPredicate(nil), nq.scope.data...), dataPredicate{key: dataKey, value: expectedValue})
+
 	return &NodeQuery{
 		store:     nq.store,
 		filters:   newFilters,
 		timeQuery: nq.timeQuery,
+		scope:     newScope,
 	}
 }
 
@@ -103,6 +164,7 @@ func (nq *NodeQuery) WithID(nodeID string) *NodeQuery {
 		store:     nq.store,
 		filters:   newFilters,
 		timeQuery: nq.timeQuery,
+		scope:     nq.scope,
 	}
 }
 
@@ -121,6 +183,7 @@ func (nq *NodeQuery) AsOf(timestamp time.Time) *NodeQuery {
 		store:     nq.store,
 		filters:   newFilters,
 		timeQuery: newTimeQuery,
+		scope:     nq.scope,
 	}
 }
 
@@ -140,6 +203,7 @@ func (nq *NodeQuery) Between(start, end time.Time) *NodeQuery {
 		store:     nq.store,
 		filters:   newFilters,
 		timeQuery: newTimeQuery,
+		scope:     nq.scope,
 	}
 }
 
@@ -166,11 +230,24 @@ func (nq *NodeQuery) Neighbors(edgeType string) *NodeQuery {
 		store:     nq.store,
 		filters:   newFilters,
 		timeQuery: nq.timeQuery,
+		scope:     nq.scope,
 	}
 }
 
 // All executes the query and returns all matching nodes.
+//
+// Deprecated: use AllContext, which stops when its context is cancelled.
 func (nq *NodeQuery) All() ([]*Node, error) {
+	return nq.AllContext(context.Background())
+}
+
+// AllContext executes the query and returns all matching nodes. A long scan
+// stops with ctx.Err() once ctx is done.
+func (nq *NodeQuery) AllContext(ctx context.Context) ([]*Node, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	nq.store.mu.RLock()
 	defer nq.store.mu.RUnlock()
 
@@ -178,31 +255,41 @@ func (nq *NodeQuery) All() ([]*Node, error) {
 
 	// Check if this is a neighbor traversal query (special case)
 	if nq.hasNeighborTraversal() {
-		return nq.executeNeighborTraversal()
+		return nq.executeNeighborTraversal(ctx)
 	}
 
 	// Handle temporal queries
 	if nq.timeQuery != nil && nq.timeQuery.asOf != nil {
-		return nq.executeAsOfQuery()
+		return nq.executeAsOfQuery(ctx)
 	}
 	if nq.timeQuery != nil && nq.timeQuery.rangeFrom != nil && nq.timeQuery.rangeTo != nil {
-		return nq.executeBetweenQuery()
+		return nq.executeBetweenQuery(ctx)
 	}
 
-	// Regular query - iterate through all nodes
-	for _, history := range nq.store.nodes {
+	// Regular query - iterate through the candidate nodes
+	err := nq.forEachCandidate(ctx, true, func(history NodeHistory) {
 		node := history.GetCurrentVersion()
 		if node != nil && nq.matchesAllFilters(node) {
 			results = append(results, node)
 		}
-	}
+	})
 
+	if err != nil {
+		return nil, err
+	}
 	return results, nil
 }
 
 // First executes the query and returns the first matching node, or nil if none found.
+//
+// Deprecated: use FirstContext.
 func (nq *NodeQuery) First() (*Node, error) {
-	results, err := nq.All()
+	return nq.FirstContext(context.Background())
+}
+
+// FirstContext executes the query and returns the first matching node, or nil if none found.
+func (nq *NodeQuery) FirstContext(ctx context.Context) (*Node, error) {
+	results, err := nq.AllContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -213,8 +300,15 @@ func (nq *NodeQuery) First() (*Node, error) {
 }
 
 // Count executes the query and returns the number of matching nodes.
+//
+// Deprecated: use CountContext.
 func (nq *NodeQuery) Count() (int, error) {
-	results, err := nq.All()
+	return nq.CountContext(context.Background())
+}
+
+// CountContext executes the query and returns the number of matching nodes.
+func (nq *NodeQuery) CountContext(ctx context.Context) (int, error) {
+	results, err := nq.AllContext(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -230,10 +324,31 @@ func (eq *EdgeQuery) OfType(edgeType string) *EdgeQuery {
 		return e.Type == edgeType
 	})
 
+	newScope := eq.scope
+	newScope.typeName = edgeType
+
+	return &EdgeQuery{
+		store:     eq.store,
+		filters:   newFilters,
+		timeQuery: eq.timeQuery,
+		scope:     newScope,
+	}
+}
+
+// IncludeArchived makes the query also cover archived edges.
+// The first such query reads every archive segment into memory.
+func (eq *EdgeQuery) IncludeArchived() *EdgeQuery {
+	newFilters := make([]EdgeFilter, len(eq.filters))
+	copy(newFilters, eq.filters)
+
+	newScope := eq.scope
+	newScope.includeArchived = true
+
 	return &EdgeQuery{
 		store:     eq.store,
 		filters:   newFilters,
 		timeQuery: eq.timeQuery,
+		scope:     newScope,
 	}
 }
 
@@ -250,6 +365,7 @@ func (eq *EdgeQuery) ConnectingNode(nodeID string) *EdgeQuery {
 		store:     eq.store,
 		filters:   newFilters,
 		timeQuery: eq.timeQuery,
+		scope:     eq.scope,
 	}
 }
 
@@ -266,6 +382,7 @@ func (eq *EdgeQuery) FromNode(nodeID string) *EdgeQuery {
 		store:     eq.store,
 		filters:   newFilters,
 		timeQuery: eq.timeQuery,
+		scope:     eq.scope,
 	}
 }
 
@@ -282,6 +399,7 @@ func (eq *EdgeQuery) ToNode(nodeID string) *EdgeQuery {
 		store:     eq.store,
 		filters:   newFilters,
 		timeQuery: eq.timeQuery,
+		scope:     eq.scope,
 	}
 }
 
@@ -305,6 +423,26 @@ func (eq *EdgeQuery) WithData(dataKey string, expectedValue interface{}) *EdgeQu
 		store:     eq.store,
 		filters:   newFilters,
 		timeQuery: eq.timeQuery,
+		scope:     eq.scope,
+	}
+}
+
+// BetweenNodes filters edges from sourceID to targetID. Like the other
+// filters it applies to the version a temporal query reads, so an edge that
+// was retargeted matches as of the times it pointed at targetID.
+func (eq *EdgeQuery) BetweenNodes(sourceID, targetID string) *EdgeQuery {
+	// Create a new query to avoid modifying the original
+	newFilters := make([]EdgeFilter, len(eq.filters), len(eq.filters)+1)
+	copy(newFilters, eq.filters)
+	newFilters = append(newFilters, func(e *Edge) bool {
+		return e.SourceID == sourceID && e.TargetID == targetID
+	})
+
+	return &EdgeQuery{
+		store:     eq.store,
+		filters:   newFilters,
+		timeQuery: eq.timeQuery,
+		scope:     eq.scope,
 	}
 }
 
@@ -322,6 +460,7 @@ func (eq *EdgeQuery) AsOf(timestamp time.Time) *EdgeQuery {
 		store:     eq.store,
 		filters:   newFilters,
 		timeQuery: newTimeQuery,
+		scope:     eq.scope,
 	}
 }
 
@@ -340,11 +479,24 @@ func (eq *EdgeQuery) Between(start, end time.Time) *EdgeQuery {
 		store:     eq.store,
 		filters:   newFilters,
 		timeQuery: newTimeQuery,
+		scope:     eq.scope,
 	}
 }
 
 // All executes the query and returns all matching edges.
+//
+// Deprecated: use AllContext, which stops when its context is cancelled.
 func (eq *EdgeQuery) All() ([]*Edge, error) {
+	return eq.AllContext(context.Background())
+}
+
+// AllContext executes the query and returns all matching edges. A long scan
+// stops with ctx.Err() once ctx is done.
+func (eq *EdgeQuery) AllContext(ctx context.Context) ([]*Edge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	eq.store.mu.RLock()
 	defer eq.store.mu.RUnlock()
 
@@ -352,17 +504,44 @@ func (eq *EdgeQuery) All() ([]*Edge, error) {
 
 	// Handle temporal queries
 	if eq.timeQuery != nil && eq.timeQuery.asOf != nil {
-		return eq.executeAsOfQuery()
+		return eq.executeAsOfQuery(ctx)
 	}
 	if eq.timeQuery != nil && eq.timeQuery.rangeFrom != nil && eq.timeQuery.rangeTo != nil {
-		return eq.executeBetweenQuery()
+		return eq.executeBetweenQuery(ctx)
 	}
 
-	// Regular query - iterate through all edges
-	for _, history := range eq.store.edges {
-		edge := history.GetCurrentVersion()
-		if edge != nil && eq.matchesAllFilters(edge) {
-			results = append(results, edge)
+	// Regular query - the type index already holds current versions
+	guard := &scanGuard{ctx: ctx}
+	if eq.scope.typeName != "" {
+		for _, edge := range eq.store.edgesByType[eq.scope.typeName] {
+			if guard.stopped() {
+				return nil, guard.err
+			}
+			if eq.matchesAllFilters(edge) {
+				results = append(results, edge)
+			}
+		}
+	} else {
+		for _, history := range eq.store.edges {
+			if guard.stopped() {
+				return nil, guard.err
+			}
+			edge := history.GetCurrentVersion()
+			if edge != nil && eq.matchesAllFilters(edge) {
+				results = append(results, edge)
+			}
+		}
+	}
+
+	if eq.scope.includeArchived {
+		err := eq.store.archive.forEachEdge(ctx, func(history EdgeHistory) {
+			edge := history.GetCurrentVersion()
+			if edge != nil && eq.matchesAllFilters(edge) {
+				results = append(results, edge)
+			}
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -370,8 +549,15 @@ func (eq *EdgeQuery) All() ([]*Edge, error) {
 }
 
 // First executes the query and returns the first matching edge, or nil if none found.
+//
+// Deprecated: use FirstContext.
 func (eq *EdgeQuery) First() (*Edge, error) {
-	results, err := eq.All()
+	return eq.FirstContext(context.Background())
+}
+
+// FirstContext executes the query and returns the first matching edge, or nil if none found.
+func (eq *EdgeQuery) FirstContext(ctx context.Context) (*Edge, error) {
+	results, err := eq.AllContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -382,8 +568,15 @@ func (eq *EdgeQuery) First() (*Edge, error) {
 }
 
 // Count executes the query and returns the number of matching edges.
+//
+// Deprecated: use CountContext.
 func (eq *EdgeQuery) Count() (int, error) {
-	results, err := eq.All()
+	return eq.CountContext(context.Background())
+}
+
+// CountContext executes the query and returns the number of matching edges.
+func (eq *EdgeQuery) CountContext(ctx context.Context) (int, error) {
+	results, err := eq.AllContext(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -428,12 +621,13 @@ func (nq *NodeQuery) isNeighborTraversalFilter(filter NodeFilter) bool {
 }
 
 // executeNeighborTraversal performs relationship traversal.
-func (nq *NodeQuery) executeNeighborTraversal() ([]*Node, error) {
+func (nq *NodeQuery) executeNeighborTraversal(ctx context.Context) ([]*Node, error) {
 	// First, execute the query without neighbor traversal to get base nodes
 	baseQuery := &NodeQuery{
 		store:     nq.store,
 		filters:   make([]NodeFilter, 0),
 		timeQuery: nq.timeQuery,
+		scope:     nq.scope,
 	}
 
 	// Add all non-neighbor-traversal filters
@@ -443,7 +637,7 @@ func (nq *NodeQuery) executeNeighborTraversal() ([]*Node, error) {
 		}
 	}
 
-	baseNodes, err := baseQuery.All()
+	baseNodes, err := baseQuery.AllContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -468,17 +662,15 @@ func (nq *NodeQuery) executeNeighborTraversal() ([]*Node, error) {
 	// Find all neighbors of the base nodes through edges of the specified type
 	neighborMap := make(map[string]*Node) // Use map to avoid duplicates
 	for _, baseNode := range baseNodes {
-		neighbors, err := nq.store.GetNeighbors(context.Background(), baseNode.ID)
+		neighbors, err := nq.store.GetNeighbors(ctx, baseNode.ID, NeighborOptions{EdgeTypes: []string{edgeType}})
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			continue
 		}
-
-		// Filter neighbors by edge type
-		for _, neighbor := range neighbors {
-			// Check if there's an edge of the specified type between baseNode and neighbor
-			if nq.hasEdgeOfType(baseNode.ID, neighbor.ID, edgeType) {
-				neighborMap[neighbor.ID] = neighbor
-			}
+		for _, neighbor := range neighbors.Nodes {
+			neighborMap[neighbor.ID] = neighbor
 		}
 	}
 
@@ -491,39 +683,28 @@ func (nq *NodeQuery) executeNeighborTraversal() ([]*Node, error) {
 	return result, nil
 }
 
-// hasEdgeOfType checks if there's an edge of the given type between two nodes.
-func (nq *NodeQuery) hasEdgeOfType(nodeID1, nodeID2, edgeType string) bool {
-	for _, history := range nq.store.edges {
-		edge := history.GetCurrentVersion()
-		if edge != nil && edge.Type == edgeType && edge.ConnectsNodes(nodeID1, nodeID2) {
-			return true
-		}
-	}
-	return false
-}
-
 // executeAsOfQuery executes a temporal query for a specific timestamp.
-func (nq *NodeQuery) executeAsOfQuery() ([]*Node, error) {
+func (nq *NodeQuery) executeAsOfQuery(ctx context.Context) ([]*Node, error) {
 	var results []*Node
 	timestamp := *nq.timeQuery.asOf
 
-	for _, history := range nq.store.nodes {
+	err := nq.forEachCandidate(ctx, false, func(history NodeHistory) {
 		node := history.GetVersionAt(timestamp)
 		if node != nil && nq.matchesAllFilters(node) {
 			results = append(results, node)
 		}
-	}
+	})
 
-	return results, nil
+	return results, err
 }
 
 // executeBetweenQuery executes a temporal query for a time range.
-func (nq *NodeQuery) executeBetweenQuery() ([]*Node, error) {
+func (nq *NodeQuery) executeBetweenQuery(ctx context.Context) ([]*Node, error) {
 	var results []*Node
 	start := *nq.timeQuery.rangeFrom
 	end := *nq.timeQuery.rangeTo
 
-	for _, history := range nq.store.nodes {
+	err := nq.forEachCandidate(ctx, false, func(history NodeHistory) {
 		// Check if any version of this node was active during the range
 		found := false
 		for _, version := range history {
@@ -534,9 +715,73 @@ func (nq *NodeQuery) executeBetweenQuery() ([]*Node, error) {
 			}
 		}
 		_ = found // Silence unused variable warning
+	})
+
+	return results, err
+}
+
+// forEachCandidate calls fn for every node history the query's scope covers:
+// the live nodes the field index finds for a WithData condition, when only
+// current versions are read and that is fewer than the type partition; else
+// the type partition if the query is restricted to one type, all live nodes
+// otherwise; and archived nodes if requested. It stops with ctx.Err() once
+// ctx is done.
+func (nq *NodeQuery) forEachCandidate(ctx context.Context, current bool, fn func(NodeHistory)) error {
+	guard := &scanGuard{ctx: ctx}
+	if ids, ok := nq.indexedCandidates(current); ok {
+		for nodeID := range ids {
+			if guard.stopped() {
+				return guard.err
+			}
+			if history, exists := nq.store.nodes[nodeID]; exists {
+				fn(history)
+			}
+		}
+	} else if nq.scope.typeName != "" {
+		for _, history := range nq.store.nodesByType[nq.scope.typeName] {
+			if guard.stopped() {
+				return guard.err
+			}
+			fn(history)
+		}
+	} else {
+		for _, history := range nq.store.nodes {
+			if guard.stopped() {
+				return guard.err
+			}
+			fn(history)
+		}
 	}
 
-	return results, nil
+	if nq.scope.includeArchived {
+		return nq.store.archive.forEachNode(ctx, nq.scope.typeName, fn)
+	}
+	return nil
+}
+
+// indexedCandidates returns the smallest set of live node IDs the field index
+// finds for the query's WithData conditions, and whether it is worth using
+// over a scan. The index only covers current versions.
+func (nq *NodeQuery) indexedCandidates(current bool) (map[string]struct{}, bool) {
+	if !current {
+		return nil, false
+	}
+
+	var best map[string]struct{}
+	found := false
+	for _, predicate := range nq.scope.data {
+		ids, ok := nq.store.fields.lookup(predicate.key, predicate.value)
+		if ok && (!found || len(ids) < len(best)) {
+			best, found = ids, true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	if nq.scope.typeName != "" && len(nq.store.nodesByType[nq.scope.typeName]) <= len(best) {
+		return nil, false
+	}
+	return best, true
 }
 
 // isActiveInRange checks if a node version was active during any part of the time range.
@@ -545,7 +790,7 @@ func (nq *NodeQuery) isActiveInRange(node *Node, start, end time.Time) bool {
 	nodeStart := node.ValidFrom
 	nodeEnd := node.ValidUntil
 	if nodeEnd.IsZero() {
-		nodeEnd = time.Now() // Current version is active until now
+		nodeEnd = nq.store.now() // Current version is active until now
 	}
 
 	// Check for overlap: nodeStart < end AND start < nodeEnd
@@ -565,27 +810,27 @@ func (eq *EdgeQuery) matchesAllFilters(edge *Edge) bool {
 }
 
 // executeAsOfQuery executes a temporal query for a specific timestamp.
-func (eq *EdgeQuery) executeAsOfQuery() ([]*Edge, error) {
+func (eq *EdgeQuery) executeAsOfQuery(ctx context.Context) ([]*Edge, error) {
 	var results []*Edge
 	timestamp := *eq.timeQuery.asOf
 
-	for _, history := range eq.store.edges {
+	err := eq.forEachHistory(ctx, func(history EdgeHistory) {
 		edge := history.GetVersionAt(timestamp)
 		if edge != nil && eq.matchesAllFilters(edge) {
 			results = append(results, edge)
 		}
-	}
+	})
 
-	return results, nil
+	return results, err
 }
 
 // executeBetweenQuery executes a temporal query for a time range.
-func (eq *EdgeQuery) executeBetweenQuery() ([]*Edge, error) {
+func (eq *EdgeQuery) executeBetweenQuery(ctx context.Context) ([]*Edge, error) {
 	var results []*Edge
 	start := *eq.timeQuery.rangeFrom
 	end := *eq.timeQuery.rangeTo
 
-	for _, history := range eq.store.edges {
+	err := eq.forEachHistory(ctx, func(history EdgeHistory) {
 		// Check if any version of this edge was active during the range
 		found := false
 		for _, version := range history {
@@ -596,9 +841,26 @@ func (eq *EdgeQuery) executeBetweenQuery() ([]*Edge, error) {
 			}
 		}
 		_ = found // Silence unused variable warning
+	})
+
+	return results, err
+}
+
+// forEachHistory calls fn for every live edge history, and archived ones if requested.
+// The type index only holds current versions, so temporal queries scan all histories.
+func (eq *EdgeQuery) forEachHistory(ctx context.Context, fn func(EdgeHistory)) error {
+	guard := &scanGuard{ctx: ctx}
+	for _, history := range eq.store.edges {
+		if guard.stopped() {
+			return guard.err
+		}
+		fn(history)
 	}
 
-	return results, nil
+	if eq.scope.includeArchived {
+		return eq.store.archive.forEachEdge(ctx, fn)
+	}
+	return nil
 }
 
 // isActiveInRange checks if an edge version was active during any part of the time range.
@@ -607,7 +869,7 @@ func (eq *EdgeQuery) isActiveInRange(edge *Edge, start, end time.Time) bool {
 	edgeStart := edge.ValidFrom
 	edgeEnd := edge.ValidUntil
 	if edgeEnd.IsZero() {
-		edgeEnd = time.Now() // Current version is active until now
+		edgeEnd = eq.store.now() // Current version is active until now
 	}
 
 	// Check for overlap: edgeStart < end AND start < edgeEnd
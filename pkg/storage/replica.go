@@ -0,0 +1,459 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
+)
+
+// ReplicaScheme is the scheme of replication connection strings.
+const ReplicaScheme = "studio"
+
+// replicaStateFile keeps what a replica needs to resume after a restart.
+const replicaStateFile = "replica.json"
+
+// replicaIncomingDir receives snapshot files until they are complete.
+const replicaIncomingDir = "incoming"
+
+// ParsePrimary parses a replication connection string,
+// "studio://TOKEN@HOST:PORT", into the primary's address and token. The
+// token may be left out for a primary on this machine.
+func ParsePrimary(connection string) (addr, token string, err error) {
+	parsed, err := url.Parse(connection)
+	if err != nil || parsed.Scheme != ReplicaScheme || parsed.Host == "" {
+		return "", "", fmt.Errorf("invalid replication connection string %q, expected %s://TOKEN@HOST:PORT", redactConnection(connection), ReplicaScheme)
+	}
+	addr = parsed.Host
+	if parsed.Port() == "" {
+		_, port, _ := net.SplitHostPort(DefaultReplicationAddr)
+		addr = net.JoinHostPort(parsed.Hostname(), port)
+	}
+	if parsed.User != nil {
+		token = parsed.User.Username()
+	}
+	if token == "" && !IsLoopbackAddr(addr) {
+		return "", "", fmt.Errorf("replication connection string for %s needs a token", addr)
+	}
+	return addr, token, nil
+}
+
+// redactConnection drops the token from a connection string for messages.
+func redactConnection(connection string) string {
+	parsed, err := url.Parse(connection)
+	if err != nil || parsed.User == nil {
+		return connection
+	}
+	parsed.User = url.User("TOKEN")
+	return parsed.String()
+}
+
+// ReplicaConfig configures a ReplicaStore.
+type ReplicaConfig struct {
+	// Primary is the connection string of the primary, see ParsePrimary
+	Primary string
+
+	// RetryInterval is how long to wait before reconnecting after the
+	// stream breaks (default 2 seconds)
+	RetryInterval time.Duration
+
+	// IdleTimeout drops a connection that has sent nothing, not even a
+	// heartbeat, for this long (default 15 seconds)
+	IdleTimeout time.Duration
+
+	// StoreOptions apply to the local copy, such as LowMemory
+	StoreOptions []StoreOption
+
+	// Client makes the requests (default: an audited client, so the
+	// replication endpoint appears in the network audit log)
+	Client *http.Client
+}
+
+// ReplicaStatus describes how closely a replica follows its primary.
+type ReplicaStatus struct {
+	Primary   string
+	Connected bool
+
+	// Sequence is the last mutation applied; PrimarySequence the last the
+	// primary reported
+	Sequence        uint64
+	PrimarySequence uint64
+
+	// CaughtUpAt is when the replica last had every reported mutation
+	CaughtUpAt  time.Time
+	LastContact time.Time
+
+	// Snapshots and Reconnects count since the replica was opened
+	Snapshots  int
+	Reconnects int
+	LastError  string
+}
+
+// Behind returns how many reported mutations are not applied yet.
+func (s ReplicaStatus) Behind() uint64 {
+	if s.PrimarySequence <= s.Sequence {
+		return 0
+	}
+	return s.PrimarySequence - s.Sequence
+}
+
+// Lag returns how long the replica has been behind the primary.
+func (s ReplicaStatus) Lag(now time.Time) time.Duration {
+	if s.Behind() == 0 || s.CaughtUpAt.IsZero() {
+		return 0
+	}
+	return now.Sub(s.CaughtUpAt)
+}
+
+// Summary describes the replica's health on one line.
+func (s ReplicaStatus) Summary(now time.Time) string {
+	if !s.Connected {
+		summary := fmt.Sprintf("replica of %s, disconnected at sequence %d", s.Primary, s.Sequence)
+		if !s.LastContact.IsZero() {
+			summary += fmt.Sprintf(", last contact %s ago", now.Sub(s.LastContact).Round(time.Second))
+		}
+		if s.LastError != "" {
+			summary += ": " + s.LastError
+		}
+		return summary
+	}
+	if behind := s.Behind(); behind > 0 {
+		return fmt.Sprintf("replica of %s at sequence %d, %d behind (lag %s)", s.Primary, s.Sequence, behind, s.Lag(now).Round(time.Millisecond))
+	}
+	return fmt.Sprintf("replica of %s at sequence %d, in sync", s.Primary, s.Sequence)
+}
+
+// replicaState is persisted so a restarted replica can resume.
+type replicaState struct {
+	// Epoch is the primary run the local copy follows
+	Epoch string `json:"epoch"`
+
+	// Offset is the primary's sequence less the number of stored versions.
+	// Each applied mutation adds one of each, so it holds until the next
+	// snapshot.
+	Offset uint64 `json:"offset"`
+}
+
+// ReplicaStore is a read-only copy of a primary's store, kept current over
+// the network. The embedded Store serves every read as the primary would;
+// mutations fail with ErrReadOnly, naming the primary.
+type ReplicaStore struct {
+	*Store
+
+	dir     string
+	addr    string
+	token   string
+	client  *http.Client
+	retry   time.Duration
+	idle    time.Duration
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	mu           sync.Mutex
+	epoch        string // Primary run the local copy follows
+	primaryEpoch string // Primary run of the current connection
+	status       ReplicaStatus
+	synced       bool          // The local copy follows the connected primary
+	changed      chan struct{} // Closed and replaced when the status changes
+}
+
+// OpenReplica opens the local copy kept in dir and starts following the
+// primary in the background. Reads are served from the local copy, which
+// may be stale until WaitForSync returns.
+func OpenReplica(dir string, config ReplicaConfig) (*ReplicaStore, error) {
+	addr, token, err := ParsePrimary(config.Primary)
+	if err != nil {
+		return nil, err
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = 2 * time.Second
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 15 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = netaudit.NewClient("replication", 0)
+	}
+
+	options := append(append([]StoreOption(nil), config.StoreOptions...), func(s *Store) {
+		s.replicaOf = addr
+	})
+	store, err := NewStore(dir, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replica: %w", err)
+	}
+
+	replica := &ReplicaStore{
+		Store:   store,
+		dir:     dir,
+		addr:    addr,
+		token:   token,
+		client:  config.Client,
+		retry:   config.RetryInterval,
+		idle:    config.IdleTimeout,
+		stopped: make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+
+	var state replicaState
+	if err := decodeJSONFile(filepath.Join(dir, replicaStateFile), &state); err == nil {
+		replica.epoch = state.Epoch
+		store.sequence += state.Offset
+	}
+	replica.status = ReplicaStatus{Primary: addr, Sequence: store.Sequence()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	replica.cancel = cancel
+	go replica.run(ctx)
+	return replica, nil
+}
+
+// Status returns the replica's connection and lag.
+func (r *ReplicaStore) Status() ReplicaStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// WaitForSync waits until the replica is connected and has applied every
+// mutation the primary reported, or ctx is done.
+func (r *ReplicaStore) WaitForSync(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		status, synced, changed := r.status, r.synced, r.changed
+		r.mu.Unlock()
+
+		if status.Connected && synced && status.Behind() == 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			if status.LastError != "" {
+				return fmt.Errorf("replica of %s is not in sync: %s", r.addr, status.LastError)
+			}
+			return fmt.Errorf("replica of %s is not in sync: %w", r.addr, ctx.Err())
+		}
+	}
+}
+
+// Close stops following the primary and closes the local copy.
+func (r *ReplicaStore) Close() error {
+	r.cancel()
+	<-r.stopped
+	return r.Store.Close()
+}
+
+// update changes the status and wakes WaitForSync.
+func (r *ReplicaStore) update(fn func(status *ReplicaStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.status)
+	r.status.Sequence = r.Store.Sequence()
+	if r.status.Sequence >= r.status.PrimarySequence {
+		r.status.CaughtUpAt = time.Now()
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// run follows the primary until ctx is done, reconnecting when the stream
+// breaks.
+func (r *ReplicaStore) run(ctx context.Context) {
+	defer close(r.stopped)
+	for {
+		err := r.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		r.mu.Lock()
+		r.synced = false
+		r.mu.Unlock()
+		r.update(func(status *ReplicaStatus) {
+			status.Connected = false
+			status.Reconnects++
+			if err != nil {
+				status.LastError = err.Error()
+			}
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.retry):
+		}
+	}
+}
+
+// follow connects once and applies the stream until it breaks.
+func (r *ReplicaStore) follow(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r.mu.Lock()
+	epoch := r.epoch
+	r.mu.Unlock()
+
+	query := url.Values{}
+	query.Set("after", strconv.FormatUint(r.Store.Sequence(), 10))
+	query.Set("epoch", epoch)
+	endpoint := url.URL{Scheme: "http", Host: r.addr, Path: replicationPath, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to primary: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return fmt.Errorf("the primary rejected the replication token")
+	default:
+		return fmt.Errorf("the primary refused replication: %s", resp.Status)
+	}
+
+	// A connection that goes quiet, without even heartbeats, is dropped
+	idle := time.AfterFunc(r.idle, cancel)
+	defer idle.Stop()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var frame replicationFrame
+		if err := decoder.Decode(&frame); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return fmt.Errorf("the primary closed the replication stream")
+			}
+			return fmt.Errorf("failed to read replication stream: %w", err)
+		}
+		idle.Reset(r.idle)
+
+		if err := r.handleFrame(decoder, &frame); err != nil {
+			return err
+		}
+	}
+}
+
+// handleFrame applies one frame; a snapshot reads its file frames too.
+func (r *ReplicaStore) handleFrame(decoder *json.Decoder, frame *replicationFrame) error {
+	now := time.Now()
+	switch frame.Type {
+	case frameHello:
+		// A new epoch is only adopted with the snapshot that follows it, as
+		// is a local copy ahead of the primary
+		r.mu.Lock()
+		r.synced = r.epoch == frame.Epoch && r.Store.Sequence() <= frame.Sequence
+		r.primaryEpoch = frame.Epoch
+		r.mu.Unlock()
+		r.update(func(status *ReplicaStatus) {
+			status.Connected = true
+			status.LastError = ""
+			status.PrimarySequence = frame.Sequence
+			status.LastContact = now
+		})
+		return nil
+
+	case frameSnapshot:
+		if err := r.receiveSnapshot(decoder, frame); err != nil {
+			return err
+		}
+		r.update(func(status *ReplicaStatus) {
+			status.Snapshots++
+			status.PrimarySequence = max(status.PrimarySequence, frame.Sequence)
+			status.LastContact = now
+		})
+		return nil
+
+	case frameRecord:
+		if err := r.Store.applyRecord(frame); err != nil {
+			return err
+		}
+		r.update(func(status *ReplicaStatus) {
+			status.PrimarySequence = max(status.PrimarySequence, frame.Sequence)
+			status.LastContact = now
+		})
+		return nil
+
+	case frameHeartbeat:
+		r.update(func(status *ReplicaStatus) {
+			status.PrimarySequence = frame.Sequence
+			status.LastContact = now
+		})
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected replication frame %q", frame.Type)
+	}
+}
+
+// receiveSnapshot writes the snapshot's files to the incoming directory and
+// installs them once all have arrived.
+func (r *ReplicaStore) receiveSnapshot(decoder *json.Decoder, header *replicationFrame) error {
+	incoming := filepath.Join(r.dir, replicaIncomingDir)
+	if err := os.RemoveAll(incoming); err != nil {
+		return fmt.Errorf("failed to clear incoming snapshot: %w", err)
+	}
+	defer os.RemoveAll(incoming)
+
+	for i := 0; i < header.Files; i++ {
+		var file replicationFrame
+		if err := decoder.Decode(&file); err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if file.Type != frameFile {
+			return fmt.Errorf("unexpected replication frame %q in snapshot", file.Type)
+		}
+
+		path := filepath.Join(incoming, filepath.FromSlash(file.Path))
+		if rel, err := filepath.Rel(incoming, path); err != nil || !filepath.IsLocal(rel) {
+			return fmt.Errorf("snapshot file %q is outside the store", file.Path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+		if err := os.WriteFile(path, file.Data, 0644); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+	}
+
+	// Until the new state is written, a restart starts over with a snapshot
+	statePath := filepath.Join(r.dir, replicaStateFile)
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to reset replica state: %w", err)
+	}
+	if err := r.Store.installSnapshot(incoming, header.Sequence); err != nil {
+		return err
+	}
+
+	r.Store.mu.RLock()
+	state := replicaState{Offset: header.Sequence - min(header.Sequence, r.Store.versions())}
+	r.Store.mu.RUnlock()
+	r.mu.Lock()
+	r.epoch = r.primaryEpoch
+	r.synced = true
+	state.Epoch = r.epoch
+	r.mu.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(statePath, data)
+}
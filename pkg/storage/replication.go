@@ -0,0 +1,761 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Replication keeps read-only copies of a store on other machines, so a
+// desktop can browse the data of an always-on server without syncing files.
+//
+// The primary runs a ReplicationServer next to its store. A replica opened
+// with OpenReplica connects to it over HTTP and receives one JSON frame per
+// line: a hello with the primary's epoch and sequence, then either a
+// snapshot of the store files or the mutations it missed, then every new
+// mutation as it is committed, with heartbeats in between. A reconnecting
+// replica resumes after the last sequence it applied when the primary still
+// has the mutations that follow in its backlog and is the same run it last
+// saw (the epoch); otherwise it takes a new snapshot.
+
+// DefaultReplicationAddr is where a primary listens for replicas by default.
+const DefaultReplicationAddr = "127.0.0.1:7420"
+
+// replicationPath is the HTTP path of the replication stream.
+const replicationPath = "/replication/stream"
+
+// storeDirs are the directories under the data directory that make up a
+// store, and so a snapshot.
+var storeDirs = []string{"nodes", "edges", archiveDirName}
+
+// Frame types of the replication stream.
+const (
+	frameHello     = "hello"
+	frameSnapshot  = "snapshot"
+	frameFile      = "file"
+	frameRecord    = "record"
+	frameHeartbeat = "heartbeat"
+)
+
+// errReplicationGap reports a mutation that does not follow the replica's
+// sequence, or does not fit its contents; the replica reconnects.
+var errReplicationGap = errors.New("replication stream does not follow the replica")
+
+// replicationFrame is one line of the replication stream.
+type replicationFrame struct {
+	Type string `json:"type"`
+
+	// Epoch identifies the primary's run (hello)
+	Epoch string `json:"epoch,omitempty"`
+
+	// Sequence is the primary's sequence (hello, heartbeat), the sequence the
+	// snapshot was taken at (snapshot), or the mutation's sequence (record)
+	Sequence uint64 `json:"sequence,omitempty"`
+
+	// Time is when the frame was sent, or the mutation was committed
+	Time time.Time `json:"time"`
+
+	// Files is the number of file frames following a snapshot
+	Files int `json:"files,omitempty"`
+
+	// Path and Data are a store file, relative to the data directory
+	Path string `json:"path,omitempty"`
+	Data []byte `json:"data,omitempty"`
+
+	// Kind and the new version make up a record; SupersededAt is when the
+	// previous version was superseded, if there was one
+	Kind         ChangeKind `json:"kind,omitempty"`
+	Node         *Node      `json:"node,omitempty"`
+	Edge         *Edge      `json:"edge,omitempty"`
+	SupersededAt *time.Time `json:"superseded_at,omitempty"`
+}
+
+// ReplicationConfig configures a ReplicationServer.
+type ReplicationConfig struct {
+	// Addr is the address to listen on (default DefaultReplicationAddr)
+	Addr string
+
+	// Token authenticates replicas. It is required unless Addr is a
+	// loopback address.
+	Token string
+
+	// Backlog is how many recent mutations are kept, so a replica that
+	// reconnects can resume instead of taking a new snapshot (default 10000)
+	Backlog int
+
+	// SnapshotInterval is how often connected replicas are sent a fresh
+	// snapshot. Snapshots carry the changes the stream does not, such as
+	// archiving (default 1 hour).
+	SnapshotInterval time.Duration
+
+	// HeartbeatInterval is how often the primary reports its sequence, from
+	// which replicas measure their lag (default 2 seconds)
+	HeartbeatInterval time.Duration
+
+	// StatusFile, if set, is where the server keeps its status for other
+	// processes, such as the status command
+	StatusFile string
+}
+
+// DefaultReplicationConfig returns a configuration listening on localhost.
+func DefaultReplicationConfig() ReplicationConfig {
+	return ReplicationConfig{
+		Addr:              DefaultReplicationAddr,
+		Backlog:           10000,
+		SnapshotInterval:  time.Hour,
+		HeartbeatInterval: 2 * time.Second,
+	}
+}
+
+// IsLoopbackAddr reports whether a listen address only accepts connections
+// from this machine.
+func IsLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ReplicaConnection describes a connected replica as the primary sees it.
+type ReplicaConnection struct {
+	Remote      string    `json:"remote"`
+	ConnectedAt time.Time `json:"connected_at"`
+
+	// Sent is the sequence of the last mutation streamed to the replica
+	Sent     uint64    `json:"sent"`
+	LastSent time.Time `json:"last_sent"`
+
+	Snapshots int `json:"snapshots"`
+}
+
+// ReplicationServerStatus describes a primary's replication listener.
+type ReplicationServerStatus struct {
+	Addr      string              `json:"addr"`
+	Epoch     string              `json:"epoch"`
+	Sequence  uint64              `json:"sequence"`
+	Replicas  []ReplicaConnection `json:"replicas"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// Summary describes the listener and its replicas on one line.
+func (s ReplicationServerStatus) Summary() string {
+	if len(s.Replicas) == 0 {
+		return fmt.Sprintf("serving on %s at sequence %d, no replicas connected", s.Addr, s.Sequence)
+	}
+	parts := make([]string, 0, len(s.Replicas))
+	for _, replica := range s.Replicas {
+		parts = append(parts, fmt.Sprintf("%s %d behind", replica.Remote, s.Sequence-min(replica.Sent, s.Sequence)))
+	}
+	return fmt.Sprintf("serving on %s at sequence %d to %s", s.Addr, s.Sequence, strings.Join(parts, ", "))
+}
+
+// ReadReplicationStatus reads the status a ReplicationServer keeps in its
+// StatusFile. It returns nil if no server is running.
+func ReadReplicationStatus(path string) (*ReplicationServerStatus, error) {
+	var status ReplicationServerStatus
+	if err := decodeJSONFile(path, &status); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read replication status: %w", err)
+	}
+	return &status, nil
+}
+
+// backlogRecord is an encoded record frame kept for streaming.
+type backlogRecord struct {
+	sequence uint64
+	line     []byte
+}
+
+// ReplicationServer streams a store's mutations to replicas.
+type ReplicationServer struct {
+	store  *Store
+	config ReplicationConfig
+	epoch  string
+
+	listener    net.Listener
+	server      *http.Server
+	unsubscribe func()
+	closed      chan struct{}
+	closeOnce   sync.Once
+
+	mu          sync.Mutex
+	backlog     []backlogRecord // Sorted by sequence
+	floor       uint64          // Mutations up to here are not in the backlog
+	changed     chan struct{}   // Closed and replaced when the backlog grows
+	replicas    map[int]*ReplicaConnection
+	nextReplica int
+}
+
+// NewReplicationServer creates a server for store. Call Start to listen.
+func NewReplicationServer(store *Store, config ReplicationConfig) (*ReplicationServer, error) {
+	defaults := DefaultReplicationConfig()
+	if config.Addr == "" {
+		config.Addr = defaults.Addr
+	}
+	if config.Backlog <= 0 {
+		config.Backlog = defaults.Backlog
+	}
+	if config.SnapshotInterval <= 0 {
+		config.SnapshotInterval = defaults.SnapshotInterval
+	}
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = defaults.HeartbeatInterval
+	}
+	if config.Token == "" && !IsLoopbackAddr(config.Addr) {
+		return nil, fmt.Errorf("a replication token is required to listen on %s, which is not a loopback address", config.Addr)
+	}
+
+	epoch := make([]byte, 8)
+	if _, err := rand.Read(epoch); err != nil {
+		return nil, fmt.Errorf("failed to create replication epoch: %w", err)
+	}
+
+	return &ReplicationServer{
+		store:    store,
+		config:   config,
+		epoch:    hex.EncodeToString(epoch),
+		closed:   make(chan struct{}),
+		changed:  make(chan struct{}),
+		replicas: make(map[int]*ReplicaConnection),
+	}, nil
+}
+
+// Start listens for replicas and begins recording mutations for them.
+func (rs *ReplicationServer) Start() error {
+	listener, err := net.Listen("tcp", rs.config.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for replicas: %w", err)
+	}
+	rs.listener = listener
+
+	// Subscribe before serving, so no mutation after a snapshot is missed
+	rs.mu.Lock()
+	rs.unsubscribe = rs.store.Subscribe(rs.handleChange)
+	rs.floor = rs.store.Sequence()
+	rs.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(replicationPath, rs.serveStream)
+	rs.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go rs.server.Serve(listener)
+
+	if rs.config.StatusFile != "" {
+		go rs.writeStatusLoop()
+	}
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (rs *ReplicationServer) Addr() string {
+	if rs.listener == nil {
+		return rs.config.Addr
+	}
+	return rs.listener.Addr().String()
+}
+
+// Status returns the listener's sequence and connected replicas.
+func (rs *ReplicationServer) Status() ReplicationServerStatus {
+	status := ReplicationServerStatus{
+		Addr:      rs.Addr(),
+		Epoch:     rs.epoch,
+		Sequence:  rs.store.Sequence(),
+		UpdatedAt: time.Now(),
+	}
+
+	rs.mu.Lock()
+	ids := make([]int, 0, len(rs.replicas))
+	for id := range rs.replicas {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		status.Replicas = append(status.Replicas, *rs.replicas[id])
+	}
+	rs.mu.Unlock()
+	return status
+}
+
+// Close disconnects every replica and stops listening.
+func (rs *ReplicationServer) Close() error {
+	var err error
+	rs.closeOnce.Do(func() {
+		close(rs.closed)
+		if rs.unsubscribe != nil {
+			rs.unsubscribe()
+		}
+		if rs.server != nil {
+			err = rs.server.Close()
+		}
+		if rs.config.StatusFile != "" {
+			os.Remove(rs.config.StatusFile)
+		}
+	})
+	return err
+}
+
+// handleChange encodes a committed mutation into the backlog. Encoding
+// here captures the version as committed: the new version is current, even
+// if a later mutation has superseded it by the time it is streamed.
+func (rs *ReplicationServer) handleChange(event ChangeEvent) {
+	frame := replicationFrame{
+		Type:     frameRecord,
+		Sequence: event.Sequence,
+		Time:     event.Timestamp,
+		Kind:     event.Kind,
+	}
+	switch {
+	case event.Node != nil:
+		node := *event.Node
+		node.ValidUntil = time.Time{}
+		frame.Node = &node
+		if event.PreviousNode != nil {
+			at := event.PreviousNode.ValidUntil
+			frame.SupersededAt = &at
+		}
+	case event.Edge != nil:
+		edge := *event.Edge
+		edge.ValidUntil = time.Time{}
+		frame.Edge = &edge
+		if event.PreviousEdge != nil {
+			at := event.PreviousEdge.ValidUntil
+			frame.SupersededAt = &at
+		}
+	default:
+		return
+	}
+
+	line, err := json.Marshal(frame)
+	if err != nil {
+		// Replicas find the gap and take a snapshot
+		return
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	// Concurrent writers may deliver out of order
+	record := backlogRecord{sequence: event.Sequence, line: line}
+	i := sort.Search(len(rs.backlog), func(i int) bool { return rs.backlog[i].sequence >= event.Sequence })
+	rs.backlog = append(rs.backlog, backlogRecord{})
+	copy(rs.backlog[i+1:], rs.backlog[i:])
+	rs.backlog[i] = record
+
+	if excess := len(rs.backlog) - rs.config.Backlog; excess > 0 {
+		rs.floor = max(rs.floor, rs.backlog[excess-1].sequence)
+		rs.backlog = append(rs.backlog[:0:0], rs.backlog[excess:]...)
+	}
+
+	close(rs.changed)
+	rs.changed = make(chan struct{})
+}
+
+// recordsFrom returns the backlog records that follow on from next without
+// a gap, whether a committed mutation after them is missing from the
+// backlog, and a channel closed when the backlog grows. Nothing is returned
+// when next is below the backlog, since those mutations are gone.
+func (rs *ReplicationServer) recordsFrom(next uint64) (records []backlogRecord, missing, gone bool, changed <-chan struct{}) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if next <= rs.floor {
+		return nil, true, true, rs.changed
+	}
+	i := sort.Search(len(rs.backlog), func(i int) bool { return rs.backlog[i].sequence >= next })
+	for ; i < len(rs.backlog) && rs.backlog[i].sequence == next; i++ {
+		records = append(records, rs.backlog[i])
+		next++
+	}
+	missing = next <= rs.store.Sequence()
+	return records, missing, false, rs.changed
+}
+
+// authorized checks a replica's token in constant time.
+func (rs *ReplicationServer) authorized(r *http.Request) bool {
+	if rs.config.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(rs.config.Token)) == 1
+}
+
+// serveStream streams the store to one replica until it disconnects.
+func (rs *ReplicationServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	if !rs.authorized(r) {
+		http.Error(w, "invalid replication token", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	after, err := strconv.ParseUint(query.Get("after"), 10, 64)
+	if err != nil && query.Get("after") != "" {
+		http.Error(w, "invalid sequence", http.StatusBadRequest)
+		return
+	}
+
+	rs.mu.Lock()
+	rs.nextReplica++
+	id := rs.nextReplica
+	connection := &ReplicaConnection{Remote: r.RemoteAddr, ConnectedAt: time.Now(), Sent: after}
+	rs.replicas[id] = connection
+	rs.mu.Unlock()
+	defer func() {
+		rs.mu.Lock()
+		delete(rs.replicas, id)
+		rs.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	stream := &replicationStream{w: w, flusher: flusher}
+	rs.stream(r.Context(), stream, connection, after, query.Get("epoch"))
+}
+
+// replicationStream writes frames to a replica.
+type replicationStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s *replicationStream) send(frame replicationFrame) error {
+	line, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	return s.write(line)
+}
+
+func (s *replicationStream) write(line []byte) error {
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// stream sends the hello, a snapshot unless the replica can resume after
+// the given sequence, and then every mutation until the replica goes away.
+func (rs *ReplicationServer) stream(ctx context.Context, stream *replicationStream, connection *ReplicaConnection, after uint64, epoch string) {
+	sequence := rs.store.Sequence()
+	if err := stream.send(replicationFrame{Type: frameHello, Epoch: rs.epoch, Sequence: sequence, Time: time.Now()}); err != nil {
+		return
+	}
+	stream.flusher.Flush()
+
+	next := after + 1
+	if epoch != rs.epoch || after > sequence {
+		var err error
+		if next, err = rs.sendSnapshot(stream, connection); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(rs.config.HeartbeatInterval)
+	defer heartbeat.Stop()
+	snapshot := time.NewTicker(rs.config.SnapshotInterval)
+	defer snapshot.Stop()
+
+	// A mutation missing from the backlog is either still being delivered
+	// or lost; after a heartbeat interval it is taken to be lost
+	var missingSince time.Time
+	for {
+		records, missing, gone, changed := rs.recordsFrom(next)
+		for _, record := range records {
+			if err := stream.write(record.line); err != nil {
+				return
+			}
+			next = record.sequence + 1
+		}
+		if len(records) > 0 {
+			stream.flusher.Flush()
+			rs.mu.Lock()
+			connection.Sent = next - 1
+			connection.LastSent = time.Now()
+			rs.mu.Unlock()
+			missingSince = time.Time{}
+			continue
+		}
+
+		var retry <-chan time.Time
+		if missing {
+			if missingSince.IsZero() {
+				missingSince = time.Now()
+			}
+			wait := rs.config.HeartbeatInterval - time.Since(missingSince)
+			if gone || wait <= 0 {
+				var err error
+				if next, err = rs.sendSnapshot(stream, connection); err != nil {
+					return
+				}
+				missingSince = time.Time{}
+				continue
+			}
+			retry = time.After(wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-rs.closed:
+			return
+		case <-changed:
+		case <-retry:
+		case <-heartbeat.C:
+			if err := stream.send(replicationFrame{Type: frameHeartbeat, Sequence: rs.store.Sequence(), Time: time.Now()}); err != nil {
+				return
+			}
+			stream.flusher.Flush()
+		case <-snapshot.C:
+			var err error
+			if next, err = rs.sendSnapshot(stream, connection); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sendSnapshot sends the store files as of a consistent sequence and
+// returns the sequence of the first mutation to stream after them.
+func (rs *ReplicationServer) sendSnapshot(stream *replicationStream, connection *ReplicaConnection) (uint64, error) {
+	var sequence uint64
+	var files []replicationFrame
+	err := rs.store.WithFileSnapshot(func(snapshotSequence uint64) error {
+		sequence = snapshotSequence
+		var err error
+		files, err = readStoreFiles(rs.store.DataDir())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	if err := stream.send(replicationFrame{Type: frameSnapshot, Sequence: sequence, Files: len(files), Time: now}); err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		file.Time = now
+		if err := stream.send(file); err != nil {
+			return 0, err
+		}
+	}
+	stream.flusher.Flush()
+
+	rs.mu.Lock()
+	connection.Sent = sequence
+	connection.LastSent = now
+	connection.Snapshots++
+	rs.mu.Unlock()
+	return sequence + 1, nil
+}
+
+// writeStatusLoop keeps the status file current until the server closes.
+func (rs *ReplicationServer) writeStatusLoop() {
+	ticker := time.NewTicker(rs.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		rs.writeStatus()
+		select {
+		case <-rs.closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeStatus writes the status file; failures only delay the next update.
+func (rs *ReplicationServer) writeStatus() {
+	data, err := json.MarshalIndent(rs.Status(), "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(rs.config.StatusFile), 0755); err != nil {
+		return
+	}
+	writeFileAtomic(rs.config.StatusFile, data)
+}
+
+// readStoreFiles reads the files that make up the store under dataDir as
+// file frames.
+func readStoreFiles(dataDir string) ([]replicationFrame, error) {
+	var files []replicationFrame
+	for _, dir := range storeDirs {
+		root := filepath.Join(dataDir, dir)
+		err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return nil
+				}
+				return err
+			}
+			if entry.IsDir() || strings.HasSuffix(path, ".tmp") {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(dataDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, replicationFrame{Type: frameFile, Path: filepath.ToSlash(relPath), Data: data})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read store files: %w", err)
+		}
+	}
+	return files, nil
+}
+
+// applyRecord applies a replicated mutation to a replica, persisting it
+// like the primary did. Records already covered by a snapshot are skipped.
+// It takes no context: a record that was received is applied whole, so the
+// replica's sequence never has a gap.
+func (s *Store) applyRecord(frame *replicationFrame) error {
+	var event *ChangeEvent
+	s.mu.Lock()
+	defer func() {
+		s.mu.Unlock()
+		s.publish(event)
+	}()
+
+	if frame.Sequence <= s.sequence {
+		return nil
+	}
+	if frame.Sequence != s.sequence+1 {
+		return fmt.Errorf("%w: expected sequence %d, got %d", errReplicationGap, s.sequence+1, frame.Sequence)
+	}
+
+	var supersededAt time.Time
+	if frame.SupersededAt != nil {
+		supersededAt = *frame.SupersededAt
+	}
+
+	event = &ChangeEvent{Sequence: frame.Sequence, Kind: frame.Kind, Timestamp: frame.Time}
+	switch frame.Kind {
+	case ChangeNodeAdded, ChangeNodeUpdated:
+		if frame.Node == nil || s.nodeExists(frame.Node.ID) != (frame.SupersededAt != nil) {
+			event = nil
+			return fmt.Errorf("%w: node record %d does not fit the replica", errReplicationGap, frame.Sequence)
+		}
+		previous, err := s.commitNode(context.Background(), frame.Node, supersededAt)
+		if err != nil {
+			event = nil
+			return err
+		}
+		s.sequence = frame.Sequence
+		if err := s.saveNodeFile(frame.Node.ID); err != nil {
+			event = nil
+			return err
+		}
+		event.Node, event.PreviousNode = frame.Node, previous
+
+	case ChangeEdgeAdded, ChangeEdgeUpdated:
+		if frame.Edge == nil || s.edgeExists(frame.Edge.ID) != (frame.SupersededAt != nil) {
+			event = nil
+			return fmt.Errorf("%w: edge record %d does not fit the replica", errReplicationGap, frame.Sequence)
+		}
+		previous, err := s.commitEdge(context.Background(), frame.Edge, supersededAt)
+		if err != nil {
+			event = nil
+			return err
+		}
+		s.sequence = frame.Sequence
+		if err := s.saveEdgeFile(frame.Edge.ID); err != nil {
+			event = nil
+			return err
+		}
+		event.Edge, event.PreviousEdge = frame.Edge, previous
+
+	default:
+		event = nil
+		return fmt.Errorf("%w: unknown record kind %q", errReplicationGap, frame.Kind)
+	}
+	return nil
+}
+
+// edgeExists reports whether an edge is live or archived. Callers hold at least the read lock.
+func (s *Store) edgeExists(edgeID string) bool {
+	if _, exists := s.edges[edgeID]; exists {
+		return true
+	}
+	return s.archive.hasEdge(edgeID)
+}
+
+// installSnapshot replaces a replica's contents with the snapshot files in
+// dir, taken at the given sequence. Subscribers are sent ChangeStoreReloaded.
+func (s *Store) installSnapshot(dir string, sequence uint64) error {
+	var event *ChangeEvent
+	s.mu.Lock()
+	defer func() {
+		s.mu.Unlock()
+		s.publish(event)
+	}()
+
+	for _, name := range storeDirs {
+		target := filepath.Join(s.dataDir, name)
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", target, err)
+		}
+		if err := os.Rename(filepath.Join(dir, name), target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to install snapshot: %w", err)
+		}
+	}
+	for _, name := range []string{"nodes", "edges"} {
+		if err := os.MkdirAll(filepath.Join(s.dataDir, name), 0755); err != nil {
+			return fmt.Errorf("failed to create %s directory: %w", name, err)
+		}
+	}
+
+	if err := s.reload(); err != nil {
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	s.sequence = sequence
+	event = &ChangeEvent{Sequence: sequence, Kind: ChangeStoreReloaded, Timestamp: s.now()}
+	return nil
+}
+
+// reload drops what the store holds in memory and loads the files under the
+// data directory again. Callers hold the write lock.
+func (s *Store) reload() error {
+	archive := newArchive(filepath.Join(s.dataDir, archiveDirName))
+	archive.maxCached = s.archive.maxCached
+	archive.readOnly = s.readOnly
+	s.archive = archive
+	s.nodes = make(map[string]NodeHistory)
+	s.edges = make(map[string]EdgeHistory)
+	s.nodesByType = make(map[string]map[string]NodeHistory)
+	s.edgesByType = make(map[string][]*Edge)
+	if s.search != nil {
+		s.search = newSearchIndex()
+	}
+	s.fields = s.fields.reset()
+	return s.loadAll()
+}
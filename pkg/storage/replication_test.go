@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestReplication serves store on a free loopback port with short intervals.
+func startTestReplication(t *testing.T, store *Store, config ReplicationConfig) *ReplicationServer {
+	t.Helper()
+	if config.Addr == "" {
+		config.Addr = "127.0.0.1:0"
+	}
+	config.HeartbeatInterval = 20 * time.Millisecond
+	server, err := NewReplicationServer(store, config)
+	if err != nil {
+		t.Fatalf("Failed to create replication server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start replication server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// openTestReplica opens a replica that retries quickly.
+func openTestReplica(t *testing.T, dir, primary string) *ReplicaStore {
+	t.Helper()
+	replica, err := OpenReplica(dir, ReplicaConfig{Primary: primary, RetryInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to open replica: %v", err)
+	}
+	return replica
+}
+
+func waitForReplica(t *testing.T, replica *ReplicaStore) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := replica.WaitForSync(ctx); err != nil {
+		t.Fatalf("Replica did not sync: %v", err)
+	}
+}
+
+// waitForSequence waits until the replica has applied the primary's sequence.
+func waitForSequence(t *testing.T, replica *ReplicaStore, sequence uint64) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for replica.Sequence() != sequence {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected replica sequence %d, got %d", sequence, replica.Sequence())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitForReplica(t, replica)
+}
+
+// assertSameFiles checks that two stores hold byte-for-byte identical files.
+func assertSameFiles(t *testing.T, primary, replica *Store) {
+	t.Helper()
+	want, err := readStoreFiles(primary.DataDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readStoreFiles(replica.DataDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d store files on the replica, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Path != want[i].Path || !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Fatalf("Replica file %s differs from primary file %s", got[i].Path, want[i].Path)
+		}
+	}
+}
+
+func TestReplicaFollowsPrimary(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewStore(createTempDir(t))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Data from before the replica connects arrives in the snapshot,
+	// including archived nodes
+	goal := NewNode("goal", map[string]interface{}{"title": "Replicate"})
+	old := NewNode("goal", map[string]interface{}{"title": "Old goal"})
+	for _, node := range []*Node{goal, old} {
+		if err := primary.AddNode(ctx, node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := primary.ArchiveNodes(ctx, []string{old.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := startTestReplication(t, primary, ReplicationConfig{})
+	replica := openTestReplica(t, createTempDir(t), "studio://"+server.Addr())
+	defer replica.Close()
+	waitForReplica(t, replica)
+
+	if !replica.IsArchived(ctx, old.ID) {
+		t.Error("Expected the archived node to be archived on the replica")
+	}
+	if got, err := replica.GetNode(ctx, goal.ID); err != nil || got.Data["title"] != "Replicate" {
+		t.Errorf("Expected the replica to serve the goal, got %v, %v", got, err)
+	}
+
+	// Later mutations arrive on the stream: new versions, edges, and a
+	// node brought back from the archive
+	objective := NewNode("objective", map[string]interface{}{"title": "Stream"})
+	if err := primary.AddNode(ctx, objective); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.UpdateNode(ctx, goal.ID, map[string]interface{}{"title": "Replicated"}); err != nil {
+		t.Fatal(err)
+	}
+	edge := NewEdge(goal.ID, objective.ID, "contains", nil)
+	if err := primary.AddEdge(ctx, edge); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.UpdateEdge(ctx, edge.ID, map[string]interface{}{"order": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.UpdateNode(ctx, old.ID, map[string]interface{}{"title": "Revived"}); err != nil {
+		t.Fatal(err)
+	}
+	waitForSequence(t, replica, primary.Sequence())
+
+	if replica.IsArchived(ctx, old.ID) {
+		t.Error("Expected the revived node to be live on the replica")
+	}
+	edges, _ := replica.GetEdgesByType(ctx, "contains")
+	if len(edges) != 1 || fmt.Sprint(edges[0].Data["order"]) != "1" {
+		t.Errorf("Expected the updated edge on the replica, got %v", edges)
+	}
+	assertSameFiles(t, primary, replica.Store)
+
+	status := replica.Status()
+	if !status.Connected || status.Behind() != 0 || status.Snapshots != 1 {
+		t.Errorf("Expected a connected replica in sync after one snapshot, got %+v", status)
+	}
+	if !strings.Contains(status.Summary(time.Now()), "in sync") {
+		t.Errorf("Expected the summary to report the replica in sync, got %q", status.Summary(time.Now()))
+	}
+	if serverStatus := server.Status(); len(serverStatus.Replicas) != 1 || serverStatus.Replicas[0].Sent != primary.Sequence() {
+		t.Errorf("Expected the primary to report one replica in sync, got %+v", serverStatus)
+	}
+}
+
+func TestReplicaRejectsWrites(t *testing.T) {
+	primary, err := NewStore(createTempDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startTestReplication(t, primary, ReplicationConfig{})
+	replica := openTestReplica(t, createTempDir(t), "studio://"+server.Addr())
+	defer replica.Close()
+
+	err = replica.AddNode(context.Background(), NewNode("goal", nil))
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got %v", err)
+	}
+	if !strings.Contains(err.Error(), server.Addr()) {
+		t.Errorf("Expected the error to name the primary, got %q", err)
+	}
+	if !replica.IsReadOnly() || primary.IsReadOnly() {
+		t.Error("Expected only the replica to be read-only")
+	}
+}
+
+func TestReplicationToken(t *testing.T) {
+	if _, err := NewReplicationServer(nil, ReplicationConfig{Addr: "0.0.0.0:0"}); err == nil {
+		t.Error("Expected a token to be required off localhost")
+	}
+	if _, _, err := ParsePrimary("studio://pi.local:7420"); err == nil {
+		t.Error("Expected a token to be required for a remote primary")
+	}
+	if addr, token, err := ParsePrimary("studio://secret@pi.local"); err != nil || addr != "pi.local:7420" || token != "secret" {
+		t.Errorf("Expected the default port and the token, got %q, %q, %v", addr, token, err)
+	}
+	if _, _, err := ParsePrimary("http://secret@pi.local:7420"); err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected a redacted error for the wrong scheme, got %v", err)
+	}
+
+	primary, err := NewStore(createTempDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startTestReplication(t, primary, ReplicationConfig{Token: "secret"})
+
+	wrong := openTestReplica(t, createTempDir(t), "studio://guess@"+server.Addr())
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := wrong.WaitForSync(ctx); err == nil || !strings.Contains(err.Error(), "token") {
+		t.Errorf("Expected the wrong token to be rejected, got %v", err)
+	}
+	wrong.Close()
+
+	right := openTestReplica(t, createTempDir(t), "studio://secret@"+server.Addr())
+	defer right.Close()
+	waitForReplica(t, right)
+}
+
+func TestReplicaResumesOrTakesSnapshot(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewStore(createTempDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addNodes := func(count int) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			if err := primary.AddNode(ctx, NewNode("task", map[string]interface{}{"n": i})); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	server := startTestReplication(t, primary, ReplicationConfig{Backlog: 20})
+	dir := createTempDir(t)
+	connection := "studio://" + server.Addr()
+
+	replica := openTestReplica(t, dir, connection)
+	waitForReplica(t, replica)
+	replica.Close()
+
+	// Within the backlog, a reopened replica resumes from its sequence
+	addNodes(10)
+	replica = openTestReplica(t, dir, connection)
+	waitForReplica(t, replica)
+	if status := replica.Status(); status.Snapshots != 0 || replica.Sequence() != primary.Sequence() {
+		t.Errorf("Expected the replica to resume without a snapshot, got %+v", status)
+	}
+	replica.Close()
+
+	// Past the backlog, the gap cannot be resumed and a snapshot is taken
+	addNodes(50)
+	replica = openTestReplica(t, dir, connection)
+	defer replica.Close()
+	waitForReplica(t, replica)
+	if status := replica.Status(); status.Snapshots != 1 {
+		t.Errorf("Expected a new snapshot after the backlog was exceeded, got %+v", status)
+	}
+	assertSameFiles(t, primary, replica.Store)
+
+	// A new primary run means a new epoch, and so a snapshot
+	server.Close()
+	restarted := startTestReplication(t, primary, ReplicationConfig{Addr: server.Addr()})
+	addNodes(1)
+	waitForSequence(t, replica, primary.Sequence())
+	if status := replica.Status(); status.Snapshots != 2 || status.Reconnects == 0 {
+		t.Errorf("Expected a reconnect and a snapshot after the primary restarted, got %+v", status)
+	}
+	assertSameFiles(t, primary, replica.Store)
+	if summary := restarted.Status().Summary(); !strings.Contains(summary, fmt.Sprint(primary.Sequence())) {
+		t.Errorf("Expected the server summary to give the sequence, got %q", summary)
+	}
+}
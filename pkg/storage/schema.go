@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// ErrSchemaViolation matches every SchemaError.
+var ErrSchemaViolation = errs.New(errs.Validation, "node data does not match its schema")
+
+// FieldType is the kind of value a schema field holds. Values are checked as
+// they are held in memory and as they come back from JSON, so an integer may
+// be any Go integer or a float64 without a fraction.
+type FieldType string
+
+const (
+	// FieldAny accepts any value
+	FieldAny FieldType = "any"
+
+	// FieldString is a string
+	FieldString FieldType = "string"
+
+	// FieldNumber is any integer or floating-point number
+	FieldNumber FieldType = "number"
+
+	// FieldInteger is a whole number, including a float64 without a fraction
+	FieldInteger FieldType = "integer"
+
+	// FieldBool is a boolean
+	FieldBool FieldType = "bool"
+
+	// FieldTime is a time in RFC 3339 format, or a time.Time
+	FieldTime FieldType = "time"
+
+	// FieldList is a slice of any element type
+	FieldList FieldType = "list"
+
+	// FieldMap is a map with string keys
+	FieldMap FieldType = "map"
+)
+
+// Field declares one data field of a node type.
+type Field struct {
+	Type FieldType
+
+	// Required fields must be present and not null; others may be missing
+	// or null, but are checked when set
+	Required bool
+
+	// Check further validates a value of the right type (nil: none)
+	Check func(value interface{}) error
+}
+
+// Schema declares the data fields a node type must have. Fields it does not
+// declare are not checked.
+type Schema struct {
+	Fields map[string]Field
+}
+
+// FieldViolation is one field that does not match its schema.
+type FieldViolation struct {
+	Field string
+	Issue string
+}
+
+func (v FieldViolation) String() string {
+	return v.Field + ": " + v.Issue
+}
+
+// SchemaError reports the fields of a node that do not match the schema of
+// its type. It matches ErrSchemaViolation.
+type SchemaError struct {
+	NodeID     string
+	NodeType   string
+	Violations []FieldViolation
+}
+
+func (e *SchemaError) Error() string {
+	issues := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		issues[i] = violation.String()
+	}
+	return fmt.Sprintf("invalid %s node %s: %s", e.NodeType, e.NodeID, strings.Join(issues, "; "))
+}
+
+// ErrorCode returns errs.Validation.
+func (e *SchemaError) ErrorCode() errs.Code {
+	return errs.Validation
+}
+
+// Is reports whether target is ErrSchemaViolation.
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrSchemaViolation
+}
+
+// schemas holds the registered schemas by node type.
+var schemas = struct {
+	sync.RWMutex
+	byType map[string]Schema
+}{byType: make(map[string]Schema)}
+
+// RegisterNodeSchema sets the schema that nodes of nodeType are checked
+// against when written, replacing any registered before. Packages that own
+// a node type register its schema when they are initialized.
+func RegisterNodeSchema(nodeType string, schema Schema) {
+	schemas.Lock()
+	defer schemas.Unlock()
+	schemas.byType[nodeType] = schema
+}
+
+// NodeSchema returns the schema registered for nodeType.
+func NodeSchema(nodeType string) (Schema, bool) {
+	schemas.RLock()
+	defer schemas.RUnlock()
+	schema, ok := schemas.byType[nodeType]
+	return schema, ok
+}
+
+// Validate returns the fields of data that do not match the schema, by
+// field name.
+func (s Schema) Validate(data map[string]interface{}) []FieldViolation {
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []FieldViolation
+	for _, name := range names {
+		field := s.Fields[name]
+		value, present := data[name]
+		value = derefValue(value)
+		if !present || value == nil {
+			if field.Required {
+				violations = append(violations, FieldViolation{Field: name, Issue: "required"})
+			}
+			continue
+		}
+		if issue := checkFieldType(field.Type, value); issue != "" {
+			violations = append(violations, FieldViolation{Field: name, Issue: issue})
+			continue
+		}
+		if field.Check != nil {
+			if err := field.Check(value); err != nil {
+				violations = append(violations, FieldViolation{Field: name, Issue: err.Error()})
+			}
+		}
+	}
+	return violations
+}
+
+// derefValue returns what a pointer points to, as managers store optional
+// times as *string; a nil pointer is nil.
+func derefValue(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// checkFieldType describes how value is not of type t, or returns "".
+func checkFieldType(t FieldType, value interface{}) string {
+	ok := false
+	switch t {
+	case FieldAny:
+		ok = true
+	case FieldString:
+		_, ok = value.(string)
+	case FieldBool:
+		_, ok = value.(bool)
+	case FieldNumber:
+		_, ok = numberValue(value)
+	case FieldInteger:
+		number, isNumber := numberValue(value)
+		if isNumber && number != math.Trunc(number) {
+			return fmt.Sprintf("expected integer, got %v", value)
+		}
+		ok = isNumber
+	case FieldTime:
+		switch v := value.(type) {
+		case time.Time:
+			ok = true
+		case string:
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return fmt.Sprintf("expected an RFC 3339 time, got %q", v)
+			}
+			ok = true
+		}
+	case FieldList:
+		kind := reflect.TypeOf(value).Kind()
+		ok = kind == reflect.Slice || kind == reflect.Array
+	case FieldMap:
+		valueType := reflect.TypeOf(value)
+		ok = valueType.Kind() == reflect.Map && valueType.Key().Kind() == reflect.String
+	default:
+		return fmt.Sprintf("unknown field type %q", t)
+	}
+	if !ok {
+		return fmt.Sprintf("expected %s, got %T", t, value)
+	}
+	return ""
+}
+
+// numberValue returns value as a float64 if it is a number of any Go type.
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// OneOf returns a Check accepting only the given strings, as for statuses.
+func OneOf(values ...string) func(interface{}) error {
+	return func(value interface{}) error {
+		s, _ := value.(string)
+		for _, allowed := range values {
+			if s == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", s, strings.Join(values, ", "))
+	}
+}
+
+// ValidateNode checks a node's data against the schema of its type. Types
+// without a schema are not checked.
+func ValidateNode(node *Node) error {
+	schema, ok := NodeSchema(node.Type)
+	if !ok {
+		return nil
+	}
+	if violations := schema.Validate(node.Data); len(violations) > 0 {
+		return &SchemaError{NodeID: node.ID, NodeType: node.Type, Violations: violations}
+	}
+	return nil
+}
+
+// checkSchema validates a node about to be written, unless the store was
+// opened with SkipSchemaValidation.
+func (s *Store) checkSchema(node *Node) error {
+	if s.skipSchemas {
+		return nil
+	}
+	return ValidateNode(node)
+}
+
+// ValidateAll checks the current version of every live node against the
+// schema of its type, whether or not writes are checked, and returns the
+// nodes that do not match by type and ID. Archived nodes are not read.
+func (s *Store) ValidateAll(ctx context.Context) ([]*SchemaError, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var invalid []*SchemaError
+	guard := &scanGuard{ctx: ctx}
+	for nodeType, histories := range s.nodesByType {
+		if _, ok := NodeSchema(nodeType); !ok {
+			continue
+		}
+		for _, history := range histories {
+			if guard.stopped() {
+				return nil, guard.err
+			}
+			current := history.GetCurrentVersion()
+			if current == nil {
+				continue
+			}
+			if err := ValidateNode(current); err != nil {
+				invalid = append(invalid, err.(*SchemaError))
+			}
+		}
+	}
+	sort.Slice(invalid, func(i, j int) bool {
+		if invalid[i].NodeType != invalid[j].NodeType {
+			return invalid[i].NodeType < invalid[j].NodeType
+		}
+		return invalid[i].NodeID < invalid[j].NodeID
+	})
+	return invalid, nil
+}
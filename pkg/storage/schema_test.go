@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// schemaTestType is registered by these tests only, so no other test's
+// nodes are checked against it.
+const schemaTestType = "schema_test_item"
+
+func registerTestSchema() {
+	RegisterNodeSchema(schemaTestType, Schema{Fields: map[string]Field{
+		"title":    {Type: FieldString, Required: true},
+		"priority": {Type: FieldInteger, Required: true},
+		"status":   {Type: FieldString, Check: OneOf("open", "done")},
+		"weight":   {Type: FieldNumber},
+		"due":      {Type: FieldTime},
+		"tags":     {Type: FieldList},
+		"context":  {Type: FieldMap},
+		"urgent":   {Type: FieldBool},
+	}})
+}
+
+// violationFields returns the fields of an error's violations.
+func violationFields(err error) []string {
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		return nil
+	}
+	fields := make([]string, len(schemaErr.Violations))
+	for i, violation := range schemaErr.Violations {
+		fields[i] = violation.Field
+	}
+	return fields
+}
+
+func TestSchema_TypeCoercion(t *testing.T) {
+	registerTestSchema()
+	schema, _ := NodeSchema(schemaTestType)
+	dueAt := "2026-05-01T09:00:00Z"
+
+	tests := []struct {
+		name  string
+		data  map[string]interface{}
+		wrong []string
+	}{
+		{"int priority", map[string]interface{}{"title": "a", "priority": 3}, nil},
+		{"int64 priority", map[string]interface{}{"title": "a", "priority": int64(3)}, nil},
+		{"whole float64 priority, as read from JSON", map[string]interface{}{"title": "a", "priority": 3.0}, nil},
+		{"json.Number priority", map[string]interface{}{"title": "a", "priority": json.Number("3")}, nil},
+		{"fractional priority", map[string]interface{}{"title": "a", "priority": 3.5}, []string{"priority"}},
+		{"string priority", map[string]interface{}{"title": "a", "priority": "3"}, []string{"priority"}},
+		{"missing required", map[string]interface{}{"priority": 3}, []string{"title"}},
+		{"null required", map[string]interface{}{"title": nil, "priority": 3}, []string{"title"}},
+		{"null optional", map[string]interface{}{"title": "a", "priority": 3, "due": nil, "status": nil}, nil},
+		{"int weight", map[string]interface{}{"title": "a", "priority": 3, "weight": 2}, nil},
+		{"time values", map[string]interface{}{"title": "a", "priority": 3, "due": dueAt}, nil},
+		{"time pointer", map[string]interface{}{"title": "a", "priority": 3, "due": &dueAt}, nil},
+		{"nil time pointer", map[string]interface{}{"title": "a", "priority": 3, "due": (*string)(nil)}, nil},
+		{"time.Time", map[string]interface{}{"title": "a", "priority": 3, "due": time.Now()}, nil},
+		{"bad time", map[string]interface{}{"title": "a", "priority": 3, "due": "next Tuesday"}, []string{"due"}},
+		{"typed list and map", map[string]interface{}{"title": "a", "priority": 3, "tags": []string{"x"}, "context": map[string]string{"k": "v"}}, nil},
+		{"JSON list and map", map[string]interface{}{"title": "a", "priority": 3, "tags": []interface{}{"x"}, "context": map[string]interface{}{"k": "v"}}, nil},
+		{"wrong list and map", map[string]interface{}{"title": "a", "priority": 3, "tags": "x", "context": []string{"k"}}, []string{"context", "tags"}},
+		{"check", map[string]interface{}{"title": "a", "priority": 3, "status": "later"}, []string{"status"}},
+		{"bool", map[string]interface{}{"title": "a", "priority": 3, "urgent": "yes"}, []string{"urgent"}},
+		{"undeclared fields", map[string]interface{}{"title": "a", "priority": 3, "anything": []int{1}}, nil},
+	}
+	for _, tt := range tests {
+		violations := schema.Validate(tt.data)
+		var fields []string
+		for _, violation := range violations {
+			fields = append(fields, violation.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(tt.wrong, ",") {
+			t.Errorf("%s: expected violations of %v, got %v", tt.name, tt.wrong, violations)
+		}
+	}
+
+	// The same data passes before and after a trip through JSON
+	data := map[string]interface{}{"title": "a", "priority": 7, "weight": 1, "tags": []string{"x"}, "due": &dueAt}
+	encoded, _ := json.Marshal(data)
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if violations := schema.Validate(decoded); len(violations) != 0 {
+		t.Errorf("Expected data read back from JSON to pass, got %v", violations)
+	}
+}
+
+func TestSchema_EnforcedOnWrites(t *testing.T) {
+	registerTestSchema()
+	ctx := context.Background()
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	bad := NewNode(schemaTestType, map[string]interface{}{"priority": "high"})
+	err = store.AddNode(ctx, bad)
+	if !errors.Is(err, ErrSchemaViolation) || errs.CodeOf(err) != errs.Validation {
+		t.Fatalf("Expected a schema violation, got %v", err)
+	}
+	if fields := violationFields(err); strings.Join(fields, ",") != "priority,title" {
+		t.Errorf("Expected field-level violations, got %v", fields)
+	}
+	if _, err := store.GetNode(ctx, bad.ID); err == nil {
+		t.Error("Expected the invalid node not to be stored")
+	}
+
+	good := NewNode(schemaTestType, map[string]interface{}{"title": "Item", "priority": 2})
+	if err := store.AddNode(ctx, good); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	if err := store.UpdateNode(ctx, good.ID, map[string]interface{}{"title": "Item", "priority": "2"}); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Expected UpdateNode to reject a string priority, got %v", err)
+	}
+	err = store.Batch(ctx, func(tx *Tx) error {
+		return tx.UpdateNode(good.ID, map[string]interface{}{"priority": 3})
+	})
+	if !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Expected a batch to reject a missing title, got %v", err)
+	}
+	current, _ := store.GetNode(ctx, good.ID)
+	if current.Data["priority"] != 2 {
+		t.Errorf("Expected rejected updates to leave the node unchanged, got %v", current.Data)
+	}
+
+	// Types without a schema are not checked
+	if err := store.AddNode(ctx, NewNode("schema_test_unregistered", map[string]interface{}{"priority": "high"})); err != nil {
+		t.Errorf("Expected an unregistered type to be stored, got %v", err)
+	}
+}
+
+func TestSchema_SkipAndValidateAll(t *testing.T) {
+	registerTestSchema()
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewStore(dir, SkipSchemaValidation())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	valid := NewNode(schemaTestType, map[string]interface{}{"title": "Fine", "priority": 1})
+	invalid := NewNode(schemaTestType, map[string]interface{}{"title": "Broken", "priority": "1"})
+	fixed := NewNode(schemaTestType, map[string]interface{}{"priority": 1})
+	for _, node := range []*Node{valid, invalid, fixed} {
+		if err := store.AddNode(ctx, node); err != nil {
+			t.Fatalf("Expected writes to skip validation, got %v", err)
+		}
+	}
+	if err := store.UpdateNode(ctx, fixed.ID, map[string]interface{}{"title": "Fixed", "priority": 1}); err != nil {
+		t.Fatalf("UpdateNode failed: %v", err)
+	}
+
+	// Reopened, with numbers read back from JSON, only the current
+	// version of each node is audited
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	report, err := reopened.ValidateAll(ctx)
+	if err != nil {
+		t.Fatalf("ValidateAll failed: %v", err)
+	}
+	if len(report) != 1 || report[0].NodeID != invalid.ID || strings.Join(violationFields(report[0]), ",") != "priority" {
+		t.Errorf("Expected only the string priority reported, got %v", report)
+	}
+}
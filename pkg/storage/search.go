@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minSearchWordLength is the shortest word that is indexed and searched for.
+const minSearchWordLength = 2
+
+// SearchOptions narrows a search.
+type SearchOptions struct {
+	// Types limits results to nodes of these types. Empty means all types.
+	Types []string
+
+	// IncludeArchived also scans archived nodes, reading every archive segment.
+	IncludeArchived bool
+
+	// Limit caps the number of results. Zero means no limit.
+	Limit int
+}
+
+// searchIndex maps the words in live node data to the IDs of the nodes that contain them.
+// Words are runs of letters in top-level string values, so IDs and timestamps stay out.
+type searchIndex struct {
+	words map[string]map[string]struct{} // map[word]set of node IDs
+}
+
+// newSearchIndex creates an empty search index.
+func newSearchIndex() *searchIndex {
+	return &searchIndex{words: make(map[string]map[string]struct{})}
+}
+
+// add indexes the words of a node version.
+func (si *searchIndex) add(node *Node) {
+	if si == nil {
+		return
+	}
+	for word := range nodeWords(node) {
+		ids := si.words[word]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			si.words[word] = ids
+		}
+		ids[node.ID] = struct{}{}
+	}
+}
+
+// remove drops the words of a node version from the index.
+func (si *searchIndex) remove(node *Node) {
+	if si == nil {
+		return
+	}
+	for word := range nodeWords(node) {
+		if ids := si.words[word]; ids != nil {
+			delete(ids, node.ID)
+			if len(ids) == 0 {
+				delete(si.words, word)
+			}
+		}
+	}
+}
+
+// lookup returns the IDs of nodes containing every word.
+func (si *searchIndex) lookup(words []string) []string {
+	// Start from the rarest word so the intersection stays small
+	sets := make([]map[string]struct{}, 0, len(words))
+	for _, word := range words {
+		ids := si.words[word]
+		if len(ids) == 0 {
+			return nil
+		}
+		sets = append(sets, ids)
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	var matches []string
+	for id := range sets[0] {
+		inAll := true
+		for _, set := range sets[1:] {
+			if _, ok := set[id]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			matches = append(matches, id)
+		}
+	}
+	return matches
+}
+
+// Search returns current nodes whose data contains every word of text,
+// ignoring case. Results are ordered by most recently changed first.
+// Live nodes are served from an in-memory index, or scanned in LowMemory
+// stores; archived nodes are only scanned when IncludeArchived is set.
+func (s *Store) Search(ctx context.Context, text string, opts SearchOptions) ([]*Node, error) {
+	words := searchWords(text)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("search text must contain at least one word")
+	}
+
+	types := make(map[string]bool, len(opts.Types))
+	for _, nodeType := range opts.Types {
+		types[nodeType] = true
+	}
+	matchesType := func(node *Node) bool {
+		return len(types) == 0 || types[node.Type]
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var results []*Node
+	if s.search != nil {
+		for _, nodeID := range s.search.lookup(words) {
+			if current := s.nodes[nodeID].GetCurrentVersion(); current != nil && matchesType(current) {
+				results = append(results, current)
+			}
+		}
+	} else {
+		// Without an index, scan the live nodes
+		guard := &scanGuard{ctx: ctx}
+		for _, history := range s.nodes {
+			if guard.stopped() {
+				return nil, guard.err
+			}
+			if current := history.GetCurrentVersion(); current != nil && matchesType(current) && containsWords(current, words) {
+				results = append(results, current)
+			}
+		}
+	}
+
+	if opts.IncludeArchived {
+		err := s.archive.forEachNode(ctx, "", func(history NodeHistory) {
+			current := history.GetCurrentVersion()
+			if current != nil && matchesType(current) && containsWords(current, words) {
+				results = append(results, current)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].ValidFrom.Equal(results[j].ValidFrom) {
+			return results[i].ValidFrom.After(results[j].ValidFrom)
+		}
+		return results[i].ID < results[j].ID
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	return results, nil
+}
+
+// containsWords reports whether a node's data contains every word.
+func containsWords(node *Node, words []string) bool {
+	found := nodeWords(node)
+	for _, word := range words {
+		if !found[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeWords returns the set of searchable words in a node's data.
+func nodeWords(node *Node) map[string]bool {
+	words := make(map[string]bool)
+	for _, value := range node.Data {
+		switch v := value.(type) {
+		case string:
+			for _, word := range searchWords(v) {
+				words[word] = true
+			}
+		case *string:
+			if v != nil {
+				for _, word := range searchWords(*v) {
+					words[word] = true
+				}
+			}
+		case []string:
+			for _, item := range v {
+				for _, word := range searchWords(item) {
+					words[word] = true
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				if str, ok := item.(string); ok {
+					for _, word := range searchWords(str) {
+						words[word] = true
+					}
+				}
+			}
+		}
+	}
+	return words
+}
+
+// searchWords splits text into lower-cased words of letters.
+func searchWords(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	words := fields[:0]
+	for _, field := range fields {
+		if len(field) >= minSearchWordLength {
+			words = append(words, field)
+		}
+	}
+	return words
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+
+	results, err := store.Search(ctx, "Revenue", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != ids["done"] {
+		t.Errorf("Expected the revenue objective, got %d results", len(results))
+	}
+
+	// Every word must match
+	results, _ = store.Search(ctx, "figures finance", SearchOptions{})
+	if len(results) != 1 || results[0].ID != ids["note"] {
+		t.Errorf("Expected only the note to match both words, got %d results", len(results))
+	}
+	results, _ = store.Search(ctx, "figures", SearchOptions{Types: []string{"objective"}})
+	if len(results) != 1 || results[0].ID != ids["done"] {
+		t.Errorf("Expected the type filter to leave the objective, got %d results", len(results))
+	}
+	results, _ = store.Search(ctx, "figures", SearchOptions{Limit: 1})
+	if len(results) != 1 {
+		t.Errorf("Expected the limit to apply, got %d results", len(results))
+	}
+
+	if _, err := store.Search(ctx, "  1 ", SearchOptions{}); err == nil {
+		t.Error("Expected an error for text without words")
+	}
+}
+
+func TestSearchFollowsUpdates(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+
+	if err := store.UpdateNode(ctx, ids["open"], map[string]interface{}{"title": "Draft conclusion section"}); err != nil {
+		t.Fatalf("UpdateNode failed: %v", err)
+	}
+	if results, _ := store.Search(ctx, "summary", SearchOptions{}); len(results) != 0 {
+		t.Errorf("Expected old words to be dropped, got %d results", len(results))
+	}
+	if results, _ := store.Search(ctx, "conclusion", SearchOptions{}); len(results) != 1 {
+		t.Errorf("Expected new words to be found, got %d results", len(results))
+	}
+
+	// The index is rebuilt on load
+	reloaded, err := NewStore(store.DataDir())
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if results, _ := reloaded.Search(ctx, "conclusion", SearchOptions{}); len(results) != 1 {
+		t.Errorf("Expected search to work after reload, got %d results", len(results))
+	}
+}
+
+func TestSearchArchived(t *testing.T) {
+	ctx := context.Background()
+	store, ids := setupArchiveStore(t)
+
+	if _, err := store.ArchiveNodes(ctx, []string{ids["done"]}); err != nil {
+		t.Fatalf("ArchiveNodes failed: %v", err)
+	}
+
+	if results, _ := store.Search(ctx, "revenue", SearchOptions{}); len(results) != 0 {
+		t.Errorf("Expected archived nodes to be left out, got %d results", len(results))
+	}
+	results, err := store.Search(ctx, "revenue", SearchOptions{IncludeArchived: true})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != ids["done"] {
+		t.Errorf("Expected the archived objective, got %d results", len(results))
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 // Store provides file-based temporal storage for nodes and edges.
@@ -15,6 +19,10 @@ import (
 // The storage layout is:
 //   data/nodes/{type}/{id}.json - Node history files
 //   data/edges/{id}.json - Edge history files
+//   data/archive/ - Archive segments, see ArchiveNodes
+//   data/blobs/{hash[:2]}/{hash} - Content-addressed blobs, see PutBlob
+//   data/batches/{id}.json - Manifests of batches being written, see Batch
+//   data/live.pack - Live nodes and edges packed for a fast open, see Close
 type Store struct {
 	// Base directory for all data files
 	dataDir string
@@ -31,28 +39,125 @@ type Store struct {
 
 	// Edge type index for faster queries (only current versions)
 	edgesByType map[string][]*Edge // map[type]current_edges
+
+	// Word index over live node data, for Search; nil in LowMemory stores,
+	// which scan instead
+	search *searchIndex
+
+	// Value index over selected data fields of live nodes, for WithData;
+	// nil when no fields are indexed
+	fields *fieldIndex
+
+	// Archived nodes and edges, read from disk on demand
+	archive *archive
+
+	// Serializes storing blobs with collecting them
+	blobMu sync.Mutex
+
+	// Modification times of the node and edge directories as the store's own
+	// writes left them, nil once it cannot tell; and those the live pack on
+	// disk was written from
+	dirStamps  dirStamps
+	packStamps dirStamps
+
+	// Change notification: sequence numbers every committed mutation
+	sequence         uint64
+	subscribers      map[int]ChangeHandler
+	nextSubscriberID int
+	subMu            sync.RWMutex
+
+	// Source of version timestamps; nil means the system clock
+	clock utils.Clock
+
+	// Set by ReadOnly: mutations fail and nothing is written to disk
+	readOnly bool
+
+	// Set on replicas to the primary's address: mutations fail, and only
+	// the replication stream changes the store
+	replicaOf string
+
+	// Set by SkipSchemaValidation: writes are not checked against node schemas
+	skipSchemas bool
 }
 
-// NewStore creates a new file-based storage instance.
-// It creates the necessary directory structure if it doesn't exist.
-func NewStore(dataDir string) (*Store, error) {
-	// Ensure directory structure exists
-	nodesDir := filepath.Join(dataDir, "nodes")
-	edgesDir := filepath.Join(dataDir, "edges")
+// ErrReadOnly is returned by mutations on a store opened with ReadOnly, and
+// on replicas, where the error names the primary to make changes on.
+var ErrReadOnly = errs.New(errs.ReadOnly, "store is read-only")
+
+// StoreOption configures optional behavior of a Store.
+type StoreOption func(*Store)
 
-	if err := os.MkdirAll(nodesDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create nodes directory: %w", err)
+// WithClock makes the store stamp versions using clock instead of the
+// system time, so temporal queries can be tested without sleeping.
+func WithClock(clock utils.Clock) StoreOption {
+	return func(s *Store) {
+		s.clock = clock
 	}
-	if err := os.MkdirAll(edgesDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create edges directory: %w", err)
+}
+
+// ReadOnly opens the store for reading only. Nothing is created or written on
+// disk, a missing data directory reads as empty, and mutations return
+// ErrReadOnly. Short-lived readers such as shell completion use it so they
+// never race a running writer.
+func ReadOnly() StoreOption {
+	return func(s *Store) {
+		s.readOnly = true
 	}
+}
+
+// SkipSchemaValidation stores node data without checking it against the
+// schemas registered for node types, for migrations that repair data the
+// schemas reject. ValidateAll still reports what does not match.
+func SkipSchemaValidation() StoreOption {
+	return func(s *Store) {
+		s.skipSchemas = true
+	}
+}
 
+// LowMemory trades speed for a smaller footprint, for always-on hosts such
+// as a Raspberry Pi: Search and WithData scan the live nodes instead of
+// keeping indexes, and only the most recently read archive segment stays
+// cached.
+func LowMemory() StoreOption {
+	return func(s *Store) {
+		s.search = nil
+		s.fields = nil
+		s.archive.maxCached = 1
+	}
+}
+
+// NewStore creates a new file-based storage instance.
+// It creates the necessary directory structure if it doesn't exist.
+func NewStore(dataDir string, opts ...StoreOption) (*Store, error) {
 	store := &Store{
 		dataDir:     dataDir,
 		nodes:       make(map[string]NodeHistory),
 		edges:       make(map[string]EdgeHistory),
 		nodesByType: make(map[string]map[string]NodeHistory),
 		edgesByType: make(map[string][]*Edge),
+		search:      newSearchIndex(),
+		fields:      newFieldIndex(DefaultIndexedFields()),
+		archive:     newArchive(filepath.Join(dataDir, archiveDirName)),
+		subscribers: make(map[int]ChangeHandler),
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	store.archive.readOnly = store.readOnly
+
+	// Ensure directory structure exists
+	if !store.readOnly {
+		if err := os.MkdirAll(filepath.Join(dataDir, "nodes"), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create nodes directory: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Join(dataDir, "edges"), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create edges directory: %w", err)
+		}
+
+		// Finish or drop the batches a stopped process left behind
+		if err := store.recoverBatches(); err != nil {
+			return nil, fmt.Errorf("failed to recover batches: %w", err)
+		}
 	}
 
 	// Load all existing data into memory
@@ -60,26 +165,101 @@ func NewStore(dataDir string) (*Store, error) {
 		return nil, fmt.Errorf("failed to load existing data: %w", err)
 	}
 
+	// Every stored version corresponds to one past mutation
+	store.sequence = store.versions()
+
 	return store, nil
 }
 
-// AddNode adds a new node to the store.
-// If a node with this ID already exists, creates a new version.
-func (s *Store) AddNode(ctx context.Context, node *Node) error {
-	if node == nil {
-		return fmt.Errorf("node cannot be nil")
+// versions counts the stored versions, live and archived. Callers hold at
+// least the read lock, or own the store exclusively.
+func (s *Store) versions() uint64 {
+	var count uint64
+	for _, history := range s.nodes {
+		count += uint64(len(history))
+	}
+	for _, history := range s.edges {
+		count += uint64(len(history))
 	}
+	return count + s.archive.versions()
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// now returns the current time on the store clock.
+func (s *Store) now() time.Time {
+	return utils.ClockOrReal(s.clock).Now()
+}
+
+// stampNode moves a constructor-stamped node onto the store clock, when one
+// was given. Nodes whose ValidFrom was set by the caller, e.g. backdated
+// imports, keep it.
+func (s *Store) stampNode(node *Node) {
+	if s.clock == nil || node.stampedAt.IsZero() || !node.ValidFrom.Equal(node.stampedAt) {
+		return
+	}
+	now := s.now()
+	if node.CreatedAt.Equal(node.stampedAt) {
+		node.CreatedAt = now
+	}
+	node.ValidFrom = now
+	node.stampedAt = time.Time{}
+}
+
+// stampEdge is stampNode for edges.
+func (s *Store) stampEdge(edge *Edge) {
+	if s.clock == nil || edge.stampedAt.IsZero() || !edge.ValidFrom.Equal(edge.stampedAt) {
+		return
+	}
+	now := s.now()
+	if edge.CreatedAt.Equal(edge.stampedAt) {
+		edge.CreatedAt = now
+	}
+	edge.ValidFrom = now
+	edge.stampedAt = time.Time{}
+}
+
+// checkWritable returns the error for a mutation on a store that may not be
+// changed directly: ErrReadOnly, naming the primary on replicas.
+func (s *Store) checkWritable() error {
+	if s.replicaOf != "" {
+		return fmt.Errorf("%w: this is a replica of %s, make changes on the primary", ErrReadOnly, s.replicaOf)
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// IsReadOnly reports whether mutations are refused, because the store was
+// opened with ReadOnly or is a replica.
+func (s *Store) IsReadOnly() bool {
+	return s.readOnly || s.replicaOf != ""
+}
+
+// commitNode makes node the current version of its node in memory,
+// superseding the previous version at the given time, which it returns.
+// Callers hold the write lock and persist the node afterwards.
+func (s *Store) commitNode(ctx context.Context, node *Node, at time.Time) (*Node, error) {
+	// A new version of an archived node brings it back to the live store
+	if err := s.restoreNode(ctx, node.ID); err != nil {
+		return nil, err
+	}
 
 	// Check if node ID already exists
+	var previous *Node
 	if history, exists := s.nodes[node.ID]; exists {
 		// Supersede the current version
 		currentVersion := history.GetCurrentVersion()
 		if currentVersion != nil {
-			currentVersion.Supersede(time.Now())
+			currentVersion.Supersede(at)
+			s.search.remove(currentVersion)
+			s.fields.remove(currentVersion)
+
+			// A new version may change the type; the node leaves its old partition
+			if currentVersion.Type != node.Type {
+				delete(s.nodesByType[currentVersion.Type], node.ID)
+			}
 		}
+		previous = currentVersion
 
 		// Add new version
 		s.nodes[node.ID] = append(history, node)
@@ -93,19 +273,114 @@ func (s *Store) AddNode(ctx context.Context, node *Node) error {
 		s.nodesByType[node.Type] = make(map[string]NodeHistory)
 	}
 	s.nodesByType[node.Type][node.ID] = s.nodes[node.ID]
+	s.search.add(node)
+	s.fields.add(node)
+	return previous, nil
+}
+
+// commitEdge is commitNode for edges. The superseded version leaves the type
+// index, which only holds current versions.
+func (s *Store) commitEdge(ctx context.Context, edge *Edge, at time.Time) (*Edge, error) {
+	// A new version of an archived edge brings it back to the live store
+	if err := s.restoreEdge(ctx, edge.ID); err != nil {
+		return nil, err
+	}
+
+	// Check if edge ID already exists
+	var previous *Edge
+	if history, exists := s.edges[edge.ID]; exists {
+		// Supersede the current version
+		currentVersion := history.GetCurrentVersion()
+		if currentVersion != nil {
+			currentVersion.Supersede(at)
+			s.removeFromEdgeTypeIndex(currentVersion)
+		}
+		previous = currentVersion
+
+		// Add new version
+		s.edges[edge.ID] = append(history, edge)
+	} else {
+		// Create new edge history
+		s.edges[edge.ID] = EdgeHistory{edge}
+	}
+
+	// Update type index (only store current version)
+	s.updateEdgeTypeIndex(edge)
+	return previous, nil
+}
+
+// AddNode adds a new node to the store.
+// If a node with this ID already exists, creates a new version.
+func (s *Store) AddNode(ctx context.Context, node *Node) error {
+	if node == nil {
+		return fmt.Errorf("node cannot be nil")
+	}
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkSchema(node); err != nil {
+		return err
+	}
+
+	var event *ChangeEvent
+	s.mu.Lock()
+	defer func() {
+		s.mu.Unlock()
+		s.publish(event)
+		recordChange(ctx, event)
+	}()
+
+	// A cancelled caller stops before anything changes
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.stampNode(node)
+	previous, err := s.commitNode(ctx, node, s.now())
+	if err != nil {
+		return err
+	}
 
 	// Persist to disk
-	return s.saveNodeFile(node.ID)
+	seq := s.nextSequence()
+	if err := s.saveNodeFile(node.ID); err != nil {
+		return err
+	}
+
+	event = &ChangeEvent{
+		Sequence:     seq,
+		Kind:         ChangeNodeAdded,
+		Node:         node,
+		PreviousNode: previous,
+		Timestamp:    s.now(),
+	}
+	return nil
 }
 
 // UpdateNode creates a new version of an existing node.
 func (s *Store) UpdateNode(ctx context.Context, nodeID string, data map[string]interface{}) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	var event *ChangeEvent
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer func() {
+		s.mu.Unlock()
+		s.publish(event)
+		recordChange(ctx, event)
+	}()
+
+	// A cancelled caller stops before anything changes
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.restoreNode(ctx, nodeID); err != nil {
+		return err
+	}
 
 	history, exists := s.nodes[nodeID]
 	if !exists {
-		return fmt.Errorf("node %s not found", nodeID)
+		return errs.Newf(errs.NotFound, "node %s not found", nodeID).With("node_id", nodeID)
 	}
 
 	currentVersion := history.GetCurrentVersion()
@@ -115,26 +390,50 @@ func (s *Store) UpdateNode(ctx context.Context, nodeID string, data map[string]i
 
 	// Create new version with updated data
 	newVersion := NewNodeWithID(nodeID, currentVersion.Type, data)
+	if err := s.checkSchema(newVersion); err != nil {
+		return err
+	}
+	s.stampNode(newVersion)
 
 	// Supersede current version
-	currentVersion.Supersede(time.Now())
+	currentVersion.Supersede(s.now())
 
 	// Add new version
 	s.nodes[nodeID] = append(history, newVersion)
 	s.nodesByType[newVersion.Type][nodeID] = s.nodes[nodeID]
+	s.search.remove(currentVersion)
+	s.search.add(newVersion)
+	s.fields.remove(currentVersion)
+	s.fields.add(newVersion)
 
 	// Persist to disk
-	return s.saveNodeFile(nodeID)
+	seq := s.nextSequence()
+	if err := s.saveNodeFile(nodeID); err != nil {
+		return err
+	}
+
+	event = &ChangeEvent{
+		Sequence:     seq,
+		Kind:         ChangeNodeUpdated,
+		Node:         newVersion,
+		PreviousNode: currentVersion,
+		Timestamp:    s.now(),
+	}
+	return nil
 }
 
 // GetNode returns the current version of a node by ID.
+// Archived nodes are found too; their segment is read on first access.
 func (s *Store) GetNode(ctx context.Context, nodeID string) (*Node, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	history, exists := s.nodes[nodeID]
-	if !exists {
-		return nil, fmt.Errorf("node %s not found", nodeID)
+	history, err := s.lookupNode(nodeID)
+	if err != nil {
+		return nil, err
 	}
 
 	current := history.GetCurrentVersion()
@@ -147,12 +446,15 @@ func (s *Store) GetNode(ctx context.Context, nodeID string) (*Node, error) {
 
 // GetNodeAtTime returns the version of a node that was active at the given time.
 func (s *Store) GetNodeAtTime(ctx context.Context, nodeID string, timestamp time.Time) (*Node, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	history, exists := s.nodes[nodeID]
-	if !exists {
-		return nil, fmt.Errorf("node %s not found", nodeID)
+	history, err := s.lookupNode(nodeID)
+	if err != nil {
+		return nil, err
 	}
 
 	version := history.GetVersionAt(timestamp)
@@ -169,48 +471,77 @@ func (s *Store) AddEdge(ctx context.Context, edge *Edge) error {
 	if edge == nil {
 		return fmt.Errorf("edge cannot be nil")
 	}
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
 
+	var event *ChangeEvent
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer func() {
+		s.mu.Unlock()
+		s.publish(event)
+		recordChange(ctx, event)
+	}()
 
 	// Verify that source and target nodes exist
-	if _, exists := s.nodes[edge.SourceID]; !exists {
-		return fmt.Errorf("source node %s not found", edge.SourceID)
+	if !s.nodeExists(edge.SourceID) {
+		return errs.Newf(errs.NotFound, "source node %s not found", edge.SourceID).With("node_id", edge.SourceID)
 	}
-	if _, exists := s.nodes[edge.TargetID]; !exists {
-		return fmt.Errorf("target node %s not found", edge.TargetID)
+	if !s.nodeExists(edge.TargetID) {
+		return errs.Newf(errs.NotFound, "target node %s not found", edge.TargetID).With("node_id", edge.TargetID)
 	}
 
-	// Check if edge ID already exists
-	if history, exists := s.edges[edge.ID]; exists {
-		// Supersede the current version
-		currentVersion := history.GetCurrentVersion()
-		if currentVersion != nil {
-			currentVersion.Supersede(time.Now())
-		}
-
-		// Add new version
-		s.edges[edge.ID] = append(history, edge)
-	} else {
-		// Create new edge history
-		s.edges[edge.ID] = EdgeHistory{edge}
+	// A cancelled caller stops before anything changes
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// Update type index (only store current version)
-	s.updateEdgeTypeIndex(edge)
+	s.stampEdge(edge)
+	previous, err := s.commitEdge(ctx, edge, s.now())
+	if err != nil {
+		return err
+	}
 
 	// Persist to disk
-	return s.saveEdgeFile(edge.ID)
+	seq := s.nextSequence()
+	if err := s.saveEdgeFile(edge.ID); err != nil {
+		return err
+	}
+
+	event = &ChangeEvent{
+		Sequence:     seq,
+		Kind:         ChangeEdgeAdded,
+		Edge:         edge,
+		PreviousEdge: previous,
+		Timestamp:    s.now(),
+	}
+	return nil
 }
 
 // UpdateEdge creates a new version of an existing edge.
 func (s *Store) UpdateEdge(ctx context.Context, edgeID string, data map[string]interface{}) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	var event *ChangeEvent
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer func() {
+		s.mu.Unlock()
+		s.publish(event)
+		recordChange(ctx, event)
+	}()
+
+	// A cancelled caller stops before anything changes
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.restoreEdge(ctx, edgeID); err != nil {
+		return err
+	}
 
 	history, exists := s.edges[edgeID]
 	if !exists {
-		return fmt.Errorf("edge %s not found", edgeID)
+		return errs.Newf(errs.NotFound, "edge %s not found", edgeID).With("edge_id", edgeID)
 	}
 
 	currentVersion := history.GetCurrentVersion()
@@ -220,9 +551,10 @@ func (s *Store) UpdateEdge(ctx context.Context, edgeID string, data map[string]i
 
 	// Create new version with updated data
 	newVersion := NewEdgeWithID(edgeID, currentVersion.SourceID, currentVersion.TargetID, currentVersion.Type, data)
+	s.stampEdge(newVersion)
 
 	// Supersede current version
-	currentVersion.Supersede(time.Now())
+	currentVersion.Supersede(s.now())
 
 	// Add new version
 	s.edges[edgeID] = append(history, newVersion)
@@ -232,17 +564,32 @@ func (s *Store) UpdateEdge(ctx context.Context, edgeID string, data map[string]i
 	s.updateEdgeTypeIndex(newVersion)
 
 	// Persist to disk
-	return s.saveEdgeFile(edgeID)
+	seq := s.nextSequence()
+	if err := s.saveEdgeFile(edgeID); err != nil {
+		return err
+	}
+
+	event = &ChangeEvent{
+		Sequence:     seq,
+		Kind:         ChangeEdgeUpdated,
+		Edge:         newVersion,
+		PreviousEdge: currentVersion,
+		Timestamp:    s.now(),
+	}
+	return nil
 }
 
 // GetEdge returns the current version of an edge by ID.
 func (s *Store) GetEdge(ctx context.Context, edgeID string) (*Edge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	history, exists := s.edges[edgeID]
-	if !exists {
-		return nil, fmt.Errorf("edge %s not found", edgeID)
+	history, err := s.lookupEdge(edgeID)
+	if err != nil {
+		return nil, err
 	}
 
 	current := history.GetCurrentVersion()
@@ -255,12 +602,15 @@ func (s *Store) GetEdge(ctx context.Context, edgeID string) (*Edge, error) {
 
 // GetEdgeAtTime returns the version of an edge that was active at the given time.
 func (s *Store) GetEdgeAtTime(ctx context.Context, edgeID string, timestamp time.Time) (*Edge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	history, exists := s.edges[edgeID]
-	if !exists {
-		return nil, fmt.Errorf("edge %s not found", edgeID)
+	history, err := s.lookupEdge(edgeID)
+	if err != nil {
+		return nil, err
 	}
 
 	version := history.GetVersionAt(timestamp)
@@ -271,45 +621,11 @@ func (s *Store) GetEdgeAtTime(ctx context.Context, edgeID string, timestamp time
 	return version, nil
 }
 
-// GetNeighbors returns all nodes connected to the given node ID through current edges.
-func (s *Store) GetNeighbors(ctx context.Context, nodeID string) ([]*Node, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var neighbors []*Node
-	neighborIDs := make(map[string]bool) // To avoid duplicates
-
-	// Find all edges connected to this node
-	for _, history := range s.edges {
-		current := history.GetCurrentVersion()
-		if current != nil && current.ConnectsNode(nodeID) {
-			var neighborID string
-			if current.SourceID == nodeID {
-				neighborID = current.TargetID
-			} else {
-				neighborID = current.SourceID
-			}
-
-			// Avoid duplicates
-			if neighborIDs[neighborID] {
-				continue
-			}
-			neighborIDs[neighborID] = true
-
-			// Get the neighbor node
-			if neighborHistory, exists := s.nodes[neighborID]; exists {
-				if neighbor := neighborHistory.GetCurrentVersion(); neighbor != nil {
-					neighbors = append(neighbors, neighbor)
-				}
-			}
-		}
-	}
-
-	return neighbors, nil
-}
-
-// GetEdgesByType returns all current edges of the given type.
+// GetEdgesByType returns all current live edges of the given type.
 func (s *Store) GetEdgesByType(ctx context.Context, edgeType string) ([]*Edge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -319,14 +635,22 @@ func (s *Store) GetEdgesByType(ctx context.Context, edgeType string) ([]*Edge, e
 	return edges, nil
 }
 
-// GetNodesByType returns all current nodes of the given type.
+// GetNodesByType returns all current live nodes of the given type.
+// Use Nodes().OfType(nodeType).IncludeArchived() to include archived nodes.
 func (s *Store) GetNodesByType(ctx context.Context, nodeType string) ([]*Node, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var nodes []*Node
 	if typeMap, exists := s.nodesByType[nodeType]; exists {
+		guard := &scanGuard{ctx: ctx}
 		for _, history := range typeMap {
+			if guard.stopped() {
+				return nil, guard.err
+			}
 			if current := history.GetCurrentVersion(); current != nil {
 				nodes = append(nodes, current)
 			}
@@ -336,6 +660,31 @@ func (s *Store) GetNodesByType(ctx context.Context, nodeType string) ([]*Node, e
 	return nodes, nil
 }
 
+// GetNodeTypes returns the sorted list of node types that have stored nodes,
+// live or archived.
+func (s *Store) GetNodeTypes(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	typeSet := s.archive.nodeTypes()
+	for nodeType, typeMap := range s.nodesByType {
+		if len(typeMap) > 0 {
+			typeSet[nodeType] = true
+		}
+	}
+
+	types := make([]string, 0, len(typeSet))
+	for nodeType := range typeSet {
+		types = append(types, nodeType)
+	}
+	sort.Strings(types)
+
+	return types, nil
+}
+
 // saveNodeFile persists a node's history to disk using atomic writes.
 func (s *Store) saveNodeFile(nodeID string) error {
 	history, exists := s.nodes[nodeID]
@@ -351,6 +700,7 @@ func (s *Store) saveNodeFile(nodeID string) error {
 
 	// Ensure type directory exists
 	typeDir := filepath.Join(s.dataDir, "nodes", current.Type)
+	s.checkDirs("nodes", filepath.Join("nodes", current.Type))
 	if err := os.MkdirAll(typeDir, 0755); err != nil {
 		return fmt.Errorf("failed to create type directory: %w", err)
 	}
@@ -375,6 +725,7 @@ func (s *Store) saveNodeFile(nodeID string) error {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
+	s.stampDirs("nodes", filepath.Join("nodes", current.Type))
 	return nil
 }
 
@@ -387,6 +738,7 @@ func (s *Store) saveEdgeFile(edgeID string) error {
 
 	// File path
 	filePath := filepath.Join(s.dataDir, "edges", edgeID+".json")
+	s.checkDirs("edges")
 	tempPath := filePath + ".tmp"
 
 	// Serialize all versions
@@ -405,19 +757,41 @@ func (s *Store) saveEdgeFile(edgeID string) error {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
+	s.stampDirs("edges")
 	return nil
 }
 
 // loadAll loads all existing nodes and edges from disk into memory.
 func (s *Store) loadAll() error {
-	// Load nodes
-	if err := s.loadNodes(); err != nil {
-		return fmt.Errorf("failed to load nodes: %w", err)
+	// Read the live pack if it was written from the files as they are now
+	stamps, _ := s.readDirStamps(false)
+	if !s.loadLivePack(stamps) {
+		// Writable stores stamp the directories first, so that files written
+		// while they are read show
+		if !s.readOnly {
+			stamps, _ = s.readDirStamps(true)
+		}
+
+		if err := s.loadNodes(); err != nil {
+			return fmt.Errorf("failed to load nodes: %w", err)
+		}
+		if err := s.loadEdges(); err != nil {
+			return fmt.Errorf("failed to load edges: %w", err)
+		}
+
+		// Files written while they were read may have been missed
+		if after, err := s.readDirStamps(false); err != nil || !after.equal(stamps) {
+			stamps = nil
+		}
 	}
+	s.dirStamps = stamps
 
-	// Load edges
-	if err := s.loadEdges(); err != nil {
-		return fmt.Errorf("failed to load edges: %w", err)
+	// Only the archive index is read here; segments are read on demand
+	if err := s.archive.loadIndex(); err != nil {
+		return err
+	}
+	if err := s.archive.dropLiveDuplicates(s.nodes, s.edges); err != nil {
+		return fmt.Errorf("failed to reconcile archive index: %w", err)
 	}
 
 	return nil
@@ -426,92 +800,126 @@ func (s *Store) loadAll() error {
 // loadNodes loads all node files from disk.
 func (s *Store) loadNodes() error {
 	nodesDir := filepath.Join(s.dataDir, "nodes")
+	if _, err := os.Stat(nodesDir); os.IsNotExist(err) && s.readOnly {
+		return nil
+	}
 
 	// Walk through type directories
-	return filepath.Walk(nodesDir, func(path string, info os.FileInfo, err error) error {
+	return filepath.WalkDir(nodesDir, func(path string, entry os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip directories and non-JSON files
-		if info.IsDir() || filepath.Ext(path) != ".json" {
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
 			return nil
 		}
 
-		// Read and parse node history file
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read node file %s: %w", path, err)
-		}
-
+		// Stream-decode the node history file
 		var history NodeHistory
-		if err := json.Unmarshal(data, &history); err != nil {
-			return fmt.Errorf("failed to unmarshal node file %s: %w", path, err)
+		if err := decodeJSONFile(path, &history); err != nil {
+			return fmt.Errorf("failed to load node file %s: %w", path, err)
 		}
 
 		if len(history) == 0 {
 			return nil // Skip empty history
 		}
 
-		// Store in memory
-		nodeID := history[0].ID
-		s.nodes[nodeID] = history
+		s.addLoadedNode(history)
+		return nil
+	})
+}
 
-		// Update type index
-		if current := history.GetCurrentVersion(); current != nil {
-			if s.nodesByType[current.Type] == nil {
-				s.nodesByType[current.Type] = make(map[string]NodeHistory)
-			}
-			s.nodesByType[current.Type][nodeID] = history
+// addLoadedNode adds a node history read from disk to memory and the indexes.
+func (s *Store) addLoadedNode(history NodeHistory) {
+	nodeID := history[0].ID
+	s.nodes[nodeID] = history
+
+	// Update type index
+	if current := history.GetCurrentVersion(); current != nil {
+		if s.nodesByType[current.Type] == nil {
+			s.nodesByType[current.Type] = make(map[string]NodeHistory)
 		}
+		s.nodesByType[current.Type][nodeID] = history
+		s.search.add(current)
+		s.fields.add(current)
+	}
+}
 
-		return nil
-	})
+// ReadNodesOfType reads the current version of each node of nodeType from
+// the files under dataDir, without opening a store, so it sees what another
+// process wrote after a store on the same directory was opened. Nodes
+// without a current version are left out.
+func ReadNodesOfType(dataDir, nodeType string) ([]*Node, error) {
+	entries, err := os.ReadDir(filepath.Join(dataDir, "nodes", nodeType))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s nodes: %w", nodeType, err)
+	}
+
+	var nodes []*Node
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		var history NodeHistory
+		path := filepath.Join(dataDir, "nodes", nodeType, entry.Name())
+		if err := decodeJSONFile(path, &history); err != nil {
+			return nil, fmt.Errorf("failed to load node file %s: %w", path, err)
+		}
+		if current := history.GetCurrentVersion(); current != nil && current.Type == nodeType {
+			nodes = append(nodes, current)
+		}
+	}
+	return nodes, nil
 }
 
 // loadEdges loads all edge files from disk.
 func (s *Store) loadEdges() error {
 	edgesDir := filepath.Join(s.dataDir, "edges")
+	if _, err := os.Stat(edgesDir); os.IsNotExist(err) && s.readOnly {
+		return nil
+	}
 
 	// Walk through edge files
-	return filepath.Walk(edgesDir, func(path string, info os.FileInfo, err error) error {
+	return filepath.WalkDir(edgesDir, func(path string, entry os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip directories and non-JSON files
-		if info.IsDir() || filepath.Ext(path) != ".json" {
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
 			return nil
 		}
 
-		// Read and parse edge history file
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read edge file %s: %w", path, err)
-		}
-
+		// Stream-decode the edge history file
 		var history EdgeHistory
-		if err := json.Unmarshal(data, &history); err != nil {
-			return fmt.Errorf("failed to unmarshal edge file %s: %w", path, err)
+		if err := decodeJSONFile(path, &history); err != nil {
+			return fmt.Errorf("failed to load edge file %s: %w", path, err)
 		}
 
 		if len(history) == 0 {
 			return nil // Skip empty history
 		}
 
-		// Store in memory
-		edgeID := history[0].ID
-		s.edges[edgeID] = history
-
-		// Update type index (only add current version)
-		if current := history.GetCurrentVersion(); current != nil {
-			s.updateEdgeTypeIndex(current)
-		}
-
+		s.addLoadedEdge(history)
 		return nil
 	})
 }
 
+// addLoadedEdge adds an edge history read from disk to memory and the type index.
+func (s *Store) addLoadedEdge(history EdgeHistory) {
+	edgeID := history[0].ID
+	s.edges[edgeID] = history
+
+	// Update type index (only add current version)
+	if current := history.GetCurrentVersion(); current != nil {
+		s.updateEdgeTypeIndex(current)
+	}
+}
+
 // updateEdgeTypeIndex adds an edge to the type index.
 func (s *Store) updateEdgeTypeIndex(edge *Edge) {
 	s.edgesByType[edge.Type] = append(s.edgesByType[edge.Type], edge)
@@ -529,12 +937,57 @@ func (s *Store) removeFromEdgeTypeIndex(edge *Edge) {
 	}
 }
 
-// Close safely shuts down the store.
-// Currently a no-op, but provides future hook for cleanup.
+// decodeJSONFile decodes a JSON document from a file as it is read,
+// instead of reading the whole file into memory first.
+func decodeJSONFile(path string, v interface{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(v)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place.
+func writeFileAtomic(path string, data []byte) error {
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath) // Clean up on failure
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
+
+// DataDir returns the directory the store persists its files to.
+func (s *Store) DataDir() string {
+	return s.dataDir
+}
+
+// WithFileSnapshot runs fn while writes to the store are blocked, so the files
+// under DataDir form a consistent snapshot at the given sequence number.
+// fn must only read files; calling Store methods from fn may deadlock.
+func (s *Store) WithFileSnapshot(fn func(sequence uint64) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(s.sequence)
+}
+
+// DropCaches releases the archive segments cached by earlier reads and
+// returns how many were dropped. They are read from disk again when needed.
+func (s *Store) DropCaches() int {
+	return s.archive.flush()
+}
+
+// Close safely shuts down the store. It packs the live nodes and edges into
+// data/live.pack, so the next open reads one file instead of every history
+// file; the pack is skipped when another process changed the files since.
 func (s *Store) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Future: Could sync any pending writes, close file handles, etc.
-	return nil
+	return s.writeLivePack()
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -404,10 +405,11 @@ func TestGraphTraversal(t *testing.T) {
 
 	t.Run("GetNeighbors", func(t *testing.T) {
 		// Get neighbors of goal node
-		neighbors, err := store.GetNeighbors(ctx, goalNode.ID)
+		result, err := store.GetNeighbors(ctx, goalNode.ID, NeighborOptions{})
 		if err != nil {
 			t.Fatalf("Failed to get neighbors: %v", err)
 		}
+		neighbors := result.Nodes
 
 		if len(neighbors) != 2 {
 			t.Errorf("Expected 2 neighbors for goal node, got %d", len(neighbors))
@@ -426,13 +428,13 @@ func TestGraphTraversal(t *testing.T) {
 		}
 
 		// Get neighbors of method1 node
-		method1Neighbors, err := store.GetNeighbors(ctx, method1Node.ID)
+		method1Neighbors, err := store.GetNeighbors(ctx, method1Node.ID, NeighborOptions{})
 		if err != nil {
 			t.Fatalf("Failed to get method1 neighbors: %v", err)
 		}
 
-		if len(method1Neighbors) != 2 {
-			t.Errorf("Expected 2 neighbors for method1 node, got %d", len(method1Neighbors))
+		if len(method1Neighbors.Nodes) != 2 {
+			t.Errorf("Expected 2 neighbors for method1 node, got %d", len(method1Neighbors.Nodes))
 		}
 	})
 }
@@ -488,6 +490,82 @@ func TestPersistenceAndLoading(t *testing.T) {
 	}
 }
 
+func TestReadOnlyStore(t *testing.T) {
+	ctx := context.Background()
+
+	// A missing data directory reads as empty and is not created
+	missing := filepath.Join(createTempDir(t), "absent")
+	empty, err := NewStore(missing, ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open missing directory read-only: %v", err)
+	}
+	if nodes, _ := empty.GetNodesByType(ctx, "goal"); len(nodes) != 0 {
+		t.Errorf("Expected no nodes, got %d", len(nodes))
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("Expected read-only open not to create %s", missing)
+	}
+
+	tempDir := createTempDir(t)
+	writer, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	goal := NewNode("goal", map[string]interface{}{"title": "read me"})
+	old := NewNode("goal", map[string]interface{}{"title": "archived"})
+	if err := writer.AddNode(ctx, goal); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if err := writer.AddNode(ctx, old); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if _, err := writer.ArchiveNodes(ctx, []string{old.ID}); err != nil {
+		t.Fatalf("Failed to archive node: %v", err)
+	}
+
+	// Simulate an interrupted archive: the live file reappears
+	liveFile := filepath.Join(tempDir, "nodes", "goal", old.ID+".json")
+	data, err := json.Marshal(NodeHistory{old})
+	if err != nil {
+		t.Fatalf("Failed to encode node: %v", err)
+	}
+	if err := os.WriteFile(liveFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write live file: %v", err)
+	}
+	indexPath := filepath.Join(tempDir, archiveDirName, archiveIndexName)
+	indexBefore, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("Failed to read archive index: %v", err)
+	}
+
+	reader, err := NewStore(tempDir, ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open store read-only: %v", err)
+	}
+	if node, err := reader.GetNode(ctx, goal.ID); err != nil || node.Data["title"] != "read me" {
+		t.Errorf("Expected to read the goal, got %v, %v", node, err)
+	}
+	if reader.IsArchived(ctx, old.ID) {
+		t.Error("Expected the live file to win over the archive")
+	}
+	if indexAfter, _ := os.ReadFile(indexPath); string(indexAfter) != string(indexBefore) {
+		t.Error("Expected the archive index to be left untouched")
+	}
+
+	if err := reader.AddNode(ctx, NewNode("goal", nil)); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected AddNode to fail with ErrReadOnly, got %v", err)
+	}
+	if err := reader.UpdateNode(ctx, goal.ID, map[string]interface{}{"title": "changed"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected UpdateNode to fail with ErrReadOnly, got %v", err)
+	}
+	if err := reader.AddEdge(ctx, NewEdge(goal.ID, old.ID, "related", nil)); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected AddEdge to fail with ErrReadOnly, got %v", err)
+	}
+	if _, err := reader.ArchiveNodes(ctx, []string{goal.ID}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ArchiveNodes to fail with ErrReadOnly, got %v", err)
+	}
+}
+
 func TestConcurrentAccess(t *testing.T) {
 	tempDir := createTempDir(t)
 	store, err := NewStore(tempDir)
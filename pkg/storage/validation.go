@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 )
 
 // ValidationError represents a specific validation failure.
@@ -25,6 +27,11 @@ func (ve ValidationError) Error() string {
 	return fmt.Sprintf("validation failed for %s in %s: %s", ve.Type, ve.FilePath, ve.Issue)
 }
 
+// ErrorCode returns errs.Validation.
+func (ve ValidationError) ErrorCode() errs.Code {
+	return errs.Validation
+}
+
 // ValidationResult contains the results of a validation check.
 type ValidationResult struct {
 	FilePath   string            // File that was validated
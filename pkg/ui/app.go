@@ -2,17 +2,28 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 
 	"github.com/Solifugus/ai-work-studio/internal/config"
 	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
 	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
 )
 
+// replicaSyncTimeout bounds how long startup waits for a replica to catch up.
+const replicaSyncTimeout = 5 * time.Second
+
 // App represents the main AI Work Studio application.
 type App struct {
 	fyneApp    fyne.App
@@ -22,10 +33,34 @@ type App struct {
 
 	// Core components
 	store            *storage.Store
+	replica          *storage.ReplicaStore
 	goalManager      *core.GoalManager
 	objectiveManager *core.ObjectiveManager
 	methodManager    *core.MethodManager
 	contextManager   *core.UserContextManager
+	rollupManager    *core.RollupManager
+	ruleManager      *core.ApprovalRuleManager
+	replanAdvisor    *core.ReplanAdvisor
+	llmService       *mcp.LLMService
+	llmRouter        *llm.Router
+
+	// watcher applies edits to the configuration file while the app runs
+	watcher *config.Watcher
+
+	// Budget is opened on first use by budgetManager and shared, so alerts
+	// for spending recorded anywhere in the app reach every subscriber
+	budgetOnce sync.Once
+	budget     *llm.BudgetManager
+	budgetErr  error
+
+	// Objectives run in the background through one cursor, so an execution
+	// view opened at any time can attach to a run in progress. Views are
+	// only touched on the UI goroutine.
+	cursor         *core.RealTimeCursor
+	runsMu         sync.Mutex
+	runs           map[string]context.CancelFunc
+	runsWG         sync.WaitGroup
+	executionViews map[string]*ExecutionView
 
 	// Application state
 	ctx    context.Context
@@ -41,33 +76,257 @@ func NewApp(cfg *config.Config, configPath string) (*App, error) {
 	// Create Fyne application
 	fyneApp := app.NewWithID("ai.work.studio")
 
-	// Initialize storage
-	store, err := storage.NewStore(cfg.DataDir)
+	// Record outbound requests and apply the network allowlist. Failing to
+	// set this up is fatal, so an enforced allowlist is never silently dropped.
+	auditor, err := netaudit.NewAuditor(cfg.Network.AuditorConfig(cfg.DataDir))
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		return nil, fmt.Errorf("failed to initialize network auditing: %w", err)
+	}
+	netaudit.SetDefault(auditor)
+
+	// Initialize storage: the local store, or a read-only replica of the
+	// primary's store when one is configured
+	var store *storage.Store
+	var replica *storage.ReplicaStore
+	if cfg.Replication.IsReplica() {
+		replica, err = storage.OpenReplica(config.ReplicaDir(cfg.DataDir), cfg.Replication.ReplicaConfig(cfg.Profile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		syncCtx, cancelSync := context.WithTimeout(context.Background(), replicaSyncTimeout)
+		if err := replica.WaitForSync(syncCtx); err != nil {
+			log.Printf("Warning: %v; showing data as of sequence %d", err, replica.Sequence())
+		}
+		cancelSync()
+		store = replica.Store
+	} else {
+		store, err = storage.NewStore(cfg.DataDir, cfg.Profile.StoreOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		}
 	}
 
 	// Initialize core managers
 	goalManager := core.NewGoalManager(store)
 	objectiveManager := core.NewObjectiveManager(store)
+	objectiveManager.SetWIPLimits(core.WIPLimits{
+		MaxInProgress:        cfg.Preferences.MaxInProgressObjectives,
+		MaxInProgressPerGoal: cfg.Preferences.MaxInProgressPerGoal,
+		Goals:                cfg.Preferences.GoalWIPLimits,
+	})
+	objectiveManager.SetTimeBoxes(core.TimeBoxes{
+		High:   time.Duration(cfg.Preferences.TimeBoxHighMinutes) * time.Minute,
+		Normal: time.Duration(cfg.Preferences.TimeBoxNormalMinutes) * time.Minute,
+		Low:    time.Duration(cfg.Preferences.TimeBoxLowMinutes) * time.Minute,
+	})
+	if weights, err := core.DefaultSuggestionWeights().WithOverrides(cfg.Preferences.SuggestionWeights); err != nil {
+		log.Printf("Warning: ignoring suggestion weights: %v", err)
+	} else {
+		objectiveManager.SetSuggestionWeights(weights)
+	}
 	methodManager := core.NewMethodManager(store)
 	contextManager := core.NewUserContextManager(store)
 
+	// Initialize dashboard rollups; reads fall back to scanning if this fails
+	rollupManager := core.NewRollupManager(store)
+	if err := rollupManager.Initialize(context.Background()); err != nil {
+		log.Printf("Warning: Failed to initialize rollups: %v", err)
+	}
+
+	// Initialize LLM routing with the providers whose keys are in the OS
+	// keyring, the configuration or the environment. A provider that starts
+	// rejecting its key is skipped, and the user is notified to replace it.
+	if warning := config.CredentialsFileWarning(configPath, cfg); warning != "" {
+		log.Printf("Warning: %s", warning)
+	}
+	llmService := mcp.NewLLMServiceWithCredentialsProvider(log.Default(), mcp.NewCredentials(mcp.SystemKeyring(), cfg.CredentialKeys()))
+	if configPath != "" {
+		if err := llmService.LoadModelCatalog(filepath.Join(filepath.Dir(configPath), mcp.ModelCatalogFile)); err != nil {
+			log.Printf("Warning: Ignoring the model catalog: %v", err)
+		}
+	}
+	for provider, limits := range cfg.API.Limits {
+		llmService.SetProviderLimits(provider, mcp.ProviderLimits(limits))
+	}
+	if enforcement, err := cfg.BudgetLimits.EnforcementMode(); err == nil {
+		llmService.SetBudgetEnforcement(enforcement, cfg.BudgetLimits.GracePercent)
+	}
+	if cfg.Audit.Enabled {
+		if auditLog, err := mcp.NewAuditLog(cfg.Audit.AuditLogConfig(cfg.DataDir)); err != nil {
+			log.Printf("Warning: Failed to open the LLM audit log: %v", err)
+		} else {
+			llmService.SetAuditLog(auditLog)
+		}
+	}
+	if cfg.CompletionCache.Enabled {
+		if cache, err := mcp.NewCompletionCache(cfg.CompletionCache.CacheConfig(cfg.DataDir)); err != nil {
+			log.Printf("Warning: Failed to open the completion cache: %v", err)
+		} else {
+			llmService.SetCompletionCache(cache)
+		}
+	}
+	routerConfig := llm.DefaultRouterConfig()
+	routerConfig.TokenEstimator = llm.NewTokenEstimator(llm.TokenizerConfig{
+		VocabularyDir: cfg.API.Tokenizer.VocabularyDir,
+		Models:        cfg.API.Tokenizer.Models,
+	})
+	llmService.SetTokenCounter(routerConfig.TokenEstimator.CountTokens)
+	routerConfig.Credentials = llm.NewCredentialMonitor(llm.CredentialMonitorConfig{
+		Sources: llm.DiagnosedCredentialSources(llmService.Diagnose()),
+		OnAlert: func(alert llm.CredentialAlert) {
+			fyne.Do(func() {
+				fyneApp.SendNotification(fyne.NewNotification(alert.Title(), alert.Message()))
+			})
+		},
+	})
+	routerConfig.PerformanceStore = llm.NewStoragePerformanceStore(store)
+	routerConfig.RoutingStore = llm.NewStorageRoutingStore(store, 0)
+	routerConfig.Policy = llm.RoutingPolicy(cfg.Routing.Policy)
+	llmRouter := llm.NewRouter(llmService, routerConfig)
+	if err := llmRouter.LoadPerformance(context.Background()); err != nil {
+		log.Printf("Warning: Failed to load model performance: %v", err)
+	}
+
 	// Create cancellable context for the application
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &App{
+	// Check provider health in the background so a provider that is down is
+	// skipped instead of failing every request
+	llmService.StartHealthMonitor(ctx, mcp.DefaultHealthConfig())
+
+	a := &App{
 		fyneApp:          fyneApp,
 		config:           cfg,
 		configPath:       configPath,
 		store:            store,
+		replica:          replica,
 		goalManager:      goalManager,
 		objectiveManager: objectiveManager,
 		methodManager:    methodManager,
 		contextManager:   contextManager,
+		rollupManager:    rollupManager,
+		ruleManager:      core.NewApprovalRuleManager(store),
+		replanAdvisor:    core.NewReplanAdvisor(store, nil),
+		llmService:       llmService,
+		llmRouter:        llmRouter,
+		runs:             make(map[string]context.CancelFunc),
+		executionViews:   make(map[string]*ExecutionView),
 		ctx:              ctx,
 		cancel:           cancel,
-	}, nil
+	}
+	a.cursor = a.newExecutionCursor()
+
+	// Apply edits to the configuration file without a restart; a file that
+	// does not validate is logged and the running settings are kept
+	if configPath != "" {
+		if watcher, err := config.Watch(configPath, func(next *config.Config) {
+			fyne.Do(func() { a.applyConfig(next) })
+		}); err != nil {
+			log.Printf("Warning: configuration changes will need a restart: %v", err)
+		} else {
+			a.watcher = watcher
+		}
+	}
+	return a, nil
+}
+
+// applyConfig switches the running components to a configuration the
+// watcher reloaded. Settings that components only read when they are
+// created, such as the data directory, take effect on the next start.
+func (a *App) applyConfig(next *config.Config) {
+	routerConfig := llm.DefaultRouterConfig()
+	routerConfig.Policy = llm.RoutingPolicy(next.Routing.Policy)
+	if err := a.llmRouter.ApplyConfig(routerConfig); err != nil {
+		log.Printf("Warning: keeping the routing settings: %v", err)
+	}
+
+	if enforcement, err := next.BudgetLimits.EnforcementMode(); err == nil {
+		a.llmService.SetBudgetEnforcement(enforcement, next.BudgetLimits.GracePercent)
+	}
+	if budgetConfig, err := next.BudgetLimits.ManagerConfig(); err != nil {
+		log.Printf("Warning: keeping the budget limits: %v", err)
+	} else if budget, err := budgetManager(a); err == nil {
+		if err := budget.ApplyConfig(budgetConfig); err != nil {
+			log.Printf("Warning: keeping the budget limits: %v", err)
+		}
+	}
+
+	a.objectiveManager.SetWIPLimits(core.WIPLimits{
+		MaxInProgress:        next.Preferences.MaxInProgressObjectives,
+		MaxInProgressPerGoal: next.Preferences.MaxInProgressPerGoal,
+		Goals:                next.Preferences.GoalWIPLimits,
+	})
+	a.objectiveManager.SetTimeBoxes(core.TimeBoxes{
+		High:   time.Duration(next.Preferences.TimeBoxHighMinutes) * time.Minute,
+		Normal: time.Duration(next.Preferences.TimeBoxNormalMinutes) * time.Minute,
+		Low:    time.Duration(next.Preferences.TimeBoxLowMinutes) * time.Minute,
+	})
+	if weights, err := core.DefaultSuggestionWeights().WithOverrides(next.Preferences.SuggestionWeights); err == nil {
+		a.objectiveManager.SetSuggestionWeights(weights)
+	}
+
+	*a.config = *next
+	a.config.SyncConvenienceFields()
+}
+
+// UpdateAPIKey saves a replacement API key for the provider to the OS
+// keyring, or to the configuration file where there is no keyring, reloads
+// the running service's credentials and probes the provider, which clears an
+// exclusion for rejected credentials once the key is accepted.
+func (a *App) UpdateAPIKey(provider, apiKey string) error {
+	apiKey = strings.TrimSpace(apiKey)
+	var updates config.APIKeyUpdates
+	switch provider {
+	case "anthropic":
+		updates.Anthropic = &apiKey
+	case "openai":
+		updates.OpenAI = &apiKey
+	default:
+		return fmt.Errorf("unknown provider: %s", provider)
+	}
+	if apiKey == "" {
+		return fmt.Errorf("the %s API key cannot be empty", provider)
+	}
+
+	source := llm.CredentialSourceKeyring
+	err := mcp.SystemKeyring().Set(mcp.KeyringService, provider, apiKey)
+	if errors.Is(err, mcp.ErrKeyringUnavailable) {
+		source = llm.CredentialSourceConfig
+		err = a.config.UpdateAPIKeys(a.configPath, updates)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save key: %w", err)
+	}
+
+	// Requests already running finish with the key they started with
+	a.llmService.ReloadCredentials()
+	a.llmRouter.Credentials().SetSource(provider, source)
+	return a.llmRouter.ProbeProvider(a.ctx, provider)
+}
+
+// UpdateSuggestionWeights saves the suggestion weights and applies them to
+// the running objective manager.
+func (a *App) UpdateSuggestionWeights(weights core.SuggestionWeights) error {
+	if err := weights.Validate(); err != nil {
+		return err
+	}
+	updates := config.PreferenceUpdates{SuggestionWeights: weights.Map()}
+	if err := a.config.UpdatePreferences(a.configPath, updates); err != nil {
+		return fmt.Errorf("failed to save suggestion weights: %w", err)
+	}
+	a.objectiveManager.SetSuggestionWeights(weights)
+	return nil
+}
+
+// UpdateRoutingPolicy saves the routing policy and applies it to the running
+// router.
+func (a *App) UpdateRoutingPolicy(policy llm.RoutingPolicy) error {
+	name := string(policy)
+	if err := a.config.UpdateRouting(a.configPath, config.RoutingUpdates{Policy: &name}); err != nil {
+		return fmt.Errorf("failed to save routing policy: %w", err)
+	}
+	a.llmRouter.SetPolicy(policy)
+	return nil
 }
 
 // Run starts the application and blocks until it exits.
@@ -93,6 +352,12 @@ func (a *App) Run() error {
 	// Show the main window
 	a.mainWindow.Show()
 
+	// Say now, rather than when the first task fails, that nothing can run
+	if err := a.llmService.Ready(); err != nil {
+		a.ShowError("No LLM Providers Configured",
+			"Goals, plans and cost estimates work, but no task can run until a provider is set up.\n\nTo add one, "+mcp.NoProvidersRemediation+".")
+	}
+
 	// Run the application (blocks until window is closed)
 	a.fyneApp.Run()
 
@@ -101,16 +366,40 @@ func (a *App) Run() error {
 
 // Stop gracefully shuts down the application.
 func (a *App) Stop() {
+	// Stop watching before the app's own save changes the file
+	if a.watcher != nil {
+		a.watcher.Close()
+	}
+
 	// Save current window preferences before closing
 	if err := a.saveWindowPreferences(); err != nil {
 		log.Printf("Warning: Failed to save window preferences: %v", err)
 	}
 
-	// Cancel context to stop any background operations
+	// Cancel context to stop any background operations, and wait for
+	// objective runs to stop before their storage is closed
 	a.cancel()
+	for _, view := range a.executionViews {
+		view.Close()
+	}
+	a.stopObjectiveRuns()
+
+	// Persist rollups before closing storage
+	if a.rollupManager != nil {
+		if err := a.rollupManager.Close(context.Background()); err != nil {
+			log.Printf("Warning: Failed to persist rollups: %v", err)
+		}
+	}
+
+	// Persist what routing learned before closing storage
+	if err := a.llmRouter.SavePerformance(context.Background()); err != nil {
+		log.Printf("Warning: Failed to save model performance: %v", err)
+	}
 
 	// Close storage
-	if a.store != nil {
+	if a.replica != nil {
+		a.replica.Close()
+	} else if a.store != nil {
 		a.store.Close()
 	}
 
@@ -153,6 +442,11 @@ func (a *App) GetUserContextManager() *core.UserContextManager {
 	return a.contextManager
 }
 
+// GetRollupManager returns the rollup manager used for dashboard aggregates.
+func (a *App) GetRollupManager() *core.RollupManager {
+	return a.rollupManager
+}
+
 // applyWindowPreferences applies saved window preferences to the main window.
 func (a *App) applyWindowPreferences() {
 	if a.mainWindow == nil {
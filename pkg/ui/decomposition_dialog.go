@@ -0,0 +1,191 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+)
+
+// DecompositionDialog shows the objectives proposed for a goal as a checklist
+// and creates the ones the user keeps.
+type DecompositionDialog struct {
+	app      *App
+	parent   fyne.Window
+	proposal *core.DecompositionProposal
+
+	checks map[string]*widget.Check // Keyed by proposal key
+
+	// Callback
+	OnObjectivesCreated func(accepted *core.AcceptedDecomposition)
+}
+
+// ProposeObjectives asks the LLM for objectives for a goal in the background
+// and shows them in a DecompositionDialog. onCreated runs after any are created.
+func ProposeObjectives(app *App, parent fyne.Window, goalID string, onCreated func(*core.AcceptedDecomposition)) {
+	progress := dialog.NewCustomWithoutButtons("Propose Objectives",
+		container.NewVBox(widget.NewLabel("Asking for objectives..."), widget.NewProgressBarInfinite()), parent)
+	progress.Show()
+
+	go func() {
+		proposal, err := app.GetGoalManager().ProposeObjectives(app.GetContext(), goalID, decompositionOptions(app))
+		fyne.Do(func() {
+			progress.Hide()
+			if err != nil {
+				showCodedError(fmt.Errorf("failed to propose objectives: %w", err), parent)
+				return
+			}
+
+			dd := NewDecompositionDialog(app, parent, proposal)
+			dd.OnObjectivesCreated = onCreated
+			dd.Show()
+		})
+	}()
+}
+
+// decompositionOptions sets up decomposition with the app's router and budget.
+func decompositionOptions(app *App) core.DecompositionOptions {
+	opts := core.DefaultDecompositionOptions()
+	opts.Router = app.llmRouter
+	opts.ContextManager = app.GetUserContextManager()
+
+	opts.UserID = app.GetConfig().Session.UserID
+	budget, err := budgetManager(app)
+	if err != nil {
+		log.Printf("Warning: planning cost will not be recorded: %v", err)
+	} else {
+		opts.Budget = budget
+	}
+	return opts
+}
+
+// budgetManager returns the budget that LLM spending is recorded against,
+// opening it on first use.
+func budgetManager(app *App) (*llm.BudgetManager, error) {
+	app.budgetOnce.Do(func() {
+		cfg := app.GetConfig()
+		config, err := cfg.BudgetLimits.ManagerConfig()
+		if err != nil {
+			app.budgetErr = err
+			return
+		}
+		app.budget, app.budgetErr = llm.NewBudgetManager(filepath.Join(cfg.DataDir, "budget"), config, log.Default())
+	})
+	return app.budget, app.budgetErr
+}
+
+// NewDecompositionDialog creates a checklist dialog for a proposal.
+func NewDecompositionDialog(app *App, parent fyne.Window, proposal *core.DecompositionProposal) *DecompositionDialog {
+	return &DecompositionDialog{
+		app:      app,
+		parent:   parent,
+		proposal: proposal,
+		checks:   make(map[string]*widget.Check),
+	}
+}
+
+// Show displays the checklist, or a notice when nothing usable was proposed.
+func (dd *DecompositionDialog) Show() {
+	if len(dd.proposal.Items) == 0 {
+		message := "No usable objectives were proposed."
+		if len(dd.proposal.Issues) > 0 {
+			message += "\n\n" + strings.Join(dd.proposal.Issues, "\n")
+		}
+		dialog.ShowInformation("Propose Objectives", message, dd.parent)
+		return
+	}
+
+	d := dialog.NewCustomConfirm("Propose Objectives", "Create Selected", "Cancel", dd.buildContent(), func(confirmed bool) {
+		if confirmed {
+			dd.createSelected()
+		}
+	}, dd.parent)
+	d.Resize(fyne.NewSize(640, 480))
+	d.Show()
+}
+
+// buildContent lists each proposal with a checkbox, followed by any issues.
+func (dd *DecompositionDialog) buildContent() fyne.CanvasObject {
+	list := container.NewVBox()
+	for _, item := range dd.proposal.Items {
+		method := item.MethodName
+		if item.NewMethodNeeded {
+			method = core.NewMethodNeeded
+		}
+
+		check := widget.NewCheck(fmt.Sprintf("%s. %s", item.Key, item.Title), nil)
+		check.SetChecked(true)
+		dd.checks[item.Key] = check
+
+		details := fmt.Sprintf("Priority %d, method: %s", item.Priority, method)
+		if len(item.DependsOn) > 0 {
+			details += ", after " + strings.Join(item.DependsOn, ", ")
+		}
+		if item.Description != "" {
+			details = item.Description + "\n" + details
+		}
+		detailsLabel := widget.NewLabel(details)
+		detailsLabel.Wrapping = fyne.TextWrapWord
+
+		list.Add(check)
+		list.Add(container.NewPadded(detailsLabel))
+	}
+
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("Tick the objectives to create (cost $%.4f).", dd.proposal.Cost)),
+		list,
+	)
+	if len(dd.proposal.Issues) > 0 {
+		issuesLabel := widget.NewLabel(strings.Join(dd.proposal.Issues, "\n"))
+		issuesLabel.Wrapping = fyne.TextWrapWord
+		content.Add(widget.NewCard("Issues with the response", "", issuesLabel))
+	}
+	return container.NewVScroll(content)
+}
+
+// selectedKeys returns the keys of the ticked proposals, in proposal order.
+func (dd *DecompositionDialog) selectedKeys() []string {
+	var keys []string
+	for _, item := range dd.proposal.Items {
+		if check := dd.checks[item.Key]; check != nil && check.Checked {
+			keys = append(keys, item.Key)
+		}
+	}
+	return keys
+}
+
+// createSelected creates the ticked objectives.
+func (dd *DecompositionDialog) createSelected() {
+	keys := dd.selectedKeys()
+	if len(keys) == 0 {
+		return
+	}
+
+	accepted, err := dd.app.GetGoalManager().AcceptProposals(dd.app.GetContext(), dd.proposal, keys)
+	if err != nil {
+		showCodedError(fmt.Errorf("failed to create objectives: %w", err), dd.parent)
+		return
+	}
+
+	if len(accepted.MethodSuggestions) > 0 {
+		names := make([]string, len(accepted.MethodSuggestions))
+		for i, method := range accepted.MethodSuggestions {
+			names[i] = "- " + method.Name
+		}
+		dialog.ShowInformation("Draft Methods Created",
+			"These objectives needed a new method, so draft methods were created.\n"+
+				"Refine them in the Methods view before running:\n\n"+strings.Join(names, "\n"), dd.parent)
+	}
+
+	if dd.OnObjectivesCreated != nil {
+		dd.OnObjectivesCreated(accepted)
+	}
+}
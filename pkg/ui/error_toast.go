@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// ToastCategory groups error codes by what the user can do about them, which
+// decides how an error is presented.
+type ToastCategory string
+
+const (
+	// ToastNotice is for requests that need another step first, such as
+	// finishing prerequisites or resolving an ambiguous choice
+	ToastNotice ToastCategory = "notice"
+
+	// ToastLimit is for work stopped by a budget, quota, policy, WIP limit or deadline
+	ToastLimit ToastCategory = "limit"
+
+	// ToastSetup is for provider problems fixed in the settings
+	ToastSetup ToastCategory = "setup"
+
+	// ToastError is for invalid input and unexpected failures
+	ToastError ToastCategory = "error"
+)
+
+// ToastCategoryFor returns the category an error code is shown in.
+func ToastCategoryFor(code errs.Code) ToastCategory {
+	switch code {
+	case errs.NotFound, errs.Conflict, errs.DependenciesNotMet, errs.ApprovalRequired:
+		return ToastNotice
+	case errs.BudgetExceeded, errs.QuotaExceeded, errs.PolicyBlocked, errs.WIPLimit, errs.ReadOnly, errs.Timeout:
+		return ToastLimit
+	case errs.ProviderAuth, errs.ProviderUnavailable:
+		return ToastSetup
+	default:
+		return ToastError
+	}
+}
+
+// Title returns the dialog title for the category.
+func (c ToastCategory) Title() string {
+	switch c {
+	case ToastNotice:
+		return "Not Yet Possible"
+	case ToastLimit:
+		return "Limit Reached"
+	case ToastSetup:
+		return "Check Provider Settings"
+	default:
+		return "Error"
+	}
+}
+
+// showCodedError presents err according to the category of its error code:
+// limits and notices are explained rather than reported as failures.
+func showCodedError(err error, parent fyne.Window) {
+	category := ToastCategoryFor(errs.CodeOf(err))
+	if category == ToastError {
+		dialog.ShowError(err, parent)
+		return
+	}
+	dialog.ShowInformation(category.Title(), err.Error(), parent)
+}
@@ -0,0 +1,312 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+)
+
+// ExecutionView is a window following an objective's execution as it runs:
+// the status of each task, the tokens used so far, the time elapsed and the
+// tools the current task calls, with controls to pause, resume and cancel
+// it. It attaches to the execution in progress when opened, and closing it
+// leaves the execution running.
+type ExecutionView struct {
+	app       *App
+	objective *core.Objective
+	window    fyne.Window
+
+	// UI Components
+	statusLabel  *widget.Label
+	tokensLabel  *widget.Label
+	elapsedLabel *widget.Label
+	toolsLabel   *widget.Label
+	tasksList    *widget.List
+	pauseButton  *widget.Button
+	resumeButton *widget.Button
+	cancelButton *widget.Button
+
+	// Latest progress, only touched on the UI goroutine
+	progress core.ExecutionProgress
+	attached bool
+	closed   bool
+
+	// Progress handed from the executing goroutine to follow; only the
+	// latest event is kept, as each one carries the whole state
+	events      chan core.ExecutionProgress
+	stop        chan struct{}
+	unsubscribe func()
+}
+
+// ShowExecution opens the execution view for the objective, or brings
+// forward the one already open.
+func (a *App) ShowExecution(objective *core.Objective) {
+	if view, open := a.executionViews[objective.ID]; open {
+		view.window.RequestFocus()
+		return
+	}
+	view := NewExecutionView(a, objective)
+	a.executionViews[objective.ID] = view
+	view.window.Show()
+}
+
+// NewExecutionView creates the execution view for an objective and attaches
+// it to the objective's execution in progress, if there is one.
+func NewExecutionView(app *App, objective *core.Objective) *ExecutionView {
+	ev := &ExecutionView{
+		app:       app,
+		objective: objective,
+		window:    app.fyneApp.NewWindow(fmt.Sprintf("Execution: %s", objective.Title)),
+		events:    make(chan core.ExecutionProgress, 1),
+		stop:      make(chan struct{}),
+	}
+	ev.buildUI()
+	ev.window.Resize(fyne.NewSize(640, 480))
+	ev.window.SetOnClosed(ev.Close)
+
+	// Subscribe before looking up the execution in progress, so no event
+	// falls between the two
+	cursor := app.ExecutionCursor()
+	ev.unsubscribe = cursor.Subscribe(ev.receive)
+	if progress, ok := cursor.Progress(objective.ID); ok {
+		ev.show(progress)
+	} else {
+		ev.showIdle()
+	}
+	go ev.follow()
+	return ev
+}
+
+// buildUI creates the view's widgets.
+func (ev *ExecutionView) buildUI() {
+	ev.statusLabel = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	ev.tokensLabel = widget.NewLabel("0")
+	ev.elapsedLabel = widget.NewLabel("0s")
+	ev.toolsLabel = widget.NewLabel("None")
+	ev.toolsLabel.Wrapping = fyne.TextWrapWord
+
+	summary := container.NewGridWithColumns(2,
+		widget.NewLabel("Status:"), ev.statusLabel,
+		widget.NewLabel("Tokens Used:"), ev.tokensLabel,
+		widget.NewLabel("Elapsed:"), ev.elapsedLabel,
+		widget.NewLabel("Current Task Tools:"), ev.toolsLabel,
+	)
+
+	ev.tasksList = widget.NewList(
+		func() int { return len(ev.progress.Tasks) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil,
+				widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+				widget.NewLabel(""),
+				widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			task := ev.progress.Tasks[id]
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(task.Description)
+			row.Objects[1].(*widget.Label).SetText(string(task.Status))
+			details := ""
+			if task.Status != core.TaskStatusPending && task.Status != core.TaskStatusRunning {
+				details = fmt.Sprintf("%d tokens, %s", task.TokensUsed, task.Duration.Round(time.Millisecond))
+			}
+			row.Objects[2].(*widget.Label).SetText(details)
+		},
+	)
+
+	ev.pauseButton = widget.NewButtonWithIcon("Pause", theme.MediaPauseIcon(), ev.pause)
+	ev.resumeButton = widget.NewButtonWithIcon("Resume", theme.MediaPlayIcon(), ev.resume)
+	ev.cancelButton = widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), ev.confirmCancel)
+
+	ev.window.SetContent(container.NewBorder(
+		widget.NewCard("Execution", ev.objective.Title, summary),            // top
+		container.NewHBox(ev.pauseButton, ev.resumeButton, ev.cancelButton), // bottom
+		nil, // left
+		nil, // right
+		widget.NewCard("Tasks", "", ev.tasksList), // center
+	))
+}
+
+// receive is the cursor's progress handler. It runs on the executing
+// goroutine, so it only hands the event on, replacing one not yet shown.
+func (ev *ExecutionView) receive(progress core.ExecutionProgress) {
+	if progress.ObjectiveID != ev.objective.ID {
+		return
+	}
+	select {
+	case <-ev.events:
+	default:
+	}
+	select {
+	case ev.events <- progress:
+	default:
+	}
+}
+
+// follow shows progress events as they arrive, and keeps the elapsed time
+// current between them, until the view is closed.
+func (ev *ExecutionView) follow() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case progress := <-ev.events:
+			fyne.Do(func() {
+				if !ev.closed {
+					ev.show(progress)
+				}
+			})
+		case <-ticker.C:
+			fyne.Do(ev.tick)
+		case <-ev.stop:
+			return
+		}
+	}
+}
+
+// show displays a snapshot of the execution.
+func (ev *ExecutionView) show(progress core.ExecutionProgress) {
+	ev.progress = progress
+	ev.attached = progress.Kind != core.ProgressExecutionFinished
+
+	switch {
+	case !ev.attached:
+		ev.statusLabel.SetText(fmt.Sprintf("Finished: %s", progress.Status))
+	case progress.Paused:
+		ev.statusLabel.SetText("Paused")
+	default:
+		ev.statusLabel.SetText(string(progress.Status))
+	}
+	ev.tokensLabel.SetText(strconv.Itoa(progress.TokensUsed))
+	ev.elapsedLabel.SetText(progress.Elapsed.Round(time.Second).String())
+
+	tools := "None"
+	if task, ok := progress.CurrentTask(); ok && len(task.ToolsUsed) > 0 {
+		tools = strings.Join(task.ToolsUsed, ", ")
+	}
+	ev.toolsLabel.SetText(tools)
+	ev.tasksList.Refresh()
+	ev.updateControls()
+}
+
+// showIdle displays that no execution of the objective is in progress.
+func (ev *ExecutionView) showIdle() {
+	if ev.app.IsObjectiveRunning(ev.objective.ID) {
+		ev.statusLabel.SetText("Planning")
+	} else {
+		ev.statusLabel.SetText("Not running")
+	}
+	ev.updateControls()
+}
+
+// tick advances the elapsed time of a running execution, and otherwise
+// keeps the controls in step with the objective's run.
+func (ev *ExecutionView) tick() {
+	switch {
+	case ev.closed:
+	case ev.attached && !ev.progress.Paused:
+		ev.elapsedLabel.SetText(time.Since(ev.progress.StartTime).Round(time.Second).String())
+	case !ev.attached && ev.progress.PlanID == "":
+		ev.showIdle()
+	case !ev.attached:
+		ev.updateControls()
+	}
+}
+
+// updateControls enables the controls that apply to the objective as it is
+// now. Pausing takes effect between tasks, so a pause requested during a
+// task is shown until the execution holds.
+func (ev *ExecutionView) updateControls() {
+	objective, err := ev.app.GetObjectiveManager().GetObjective(ev.app.GetContext(), ev.objective.ID)
+	if err != nil {
+		return
+	}
+	running := ev.app.IsObjectiveRunning(ev.objective.ID)
+	paused := objective.Status == core.ObjectiveStatusPaused && objective.TimeBoxStop() == nil
+
+	setEnabled(ev.pauseButton, objective.Status == core.ObjectiveStatusInProgress)
+	setEnabled(ev.resumeButton, paused)
+	setEnabled(ev.cancelButton, running)
+	if ev.attached && paused && !ev.progress.Paused {
+		ev.statusLabel.SetText("Pausing after the current task")
+	}
+}
+
+// pause pauses the objective; its execution holds before the next task.
+func (ev *ExecutionView) pause() {
+	if _, err := ev.app.GetObjectiveManager().PauseObjective(ev.app.GetContext(), ev.objective.ID); err != nil {
+		showCodedError(err, ev.window)
+		return
+	}
+	ev.updateControls()
+}
+
+// resume resumes the paused objective, so its execution goes on.
+func (ev *ExecutionView) resume() {
+	ctx := ev.app.GetContext()
+	manager := ev.app.GetObjectiveManager()
+
+	_, err := manager.ResumeObjective(ctx, ev.objective.ID)
+	if errors.Is(err, core.ErrWIPLimitReached) {
+		message := err.Error() + "\n\nResume it anyway? The exception will be recorded."
+		dialog.ShowConfirm("Work-in-Progress Limit Reached", message, func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			_, err := manager.ResumeObjective(ctx, ev.objective.ID, core.StartOptions{OverrideWIPLimit: true, Reason: "resumed from the execution view"})
+			if err != nil {
+				showCodedError(err, ev.window)
+			}
+			ev.updateControls()
+		}, ev.window)
+		return
+	}
+	if err != nil {
+		showCodedError(err, ev.window)
+		return
+	}
+	ev.updateControls()
+}
+
+// confirmCancel cancels the objective's run once the user confirms.
+func (ev *ExecutionView) confirmCancel() {
+	message := "Stop running this objective? The task in progress is abandoned and the objective stays in progress."
+	dialog.ShowConfirm("Cancel Execution", message, func(confirmed bool) {
+		if confirmed {
+			ev.app.CancelObjectiveRun(ev.objective.ID)
+		}
+	}, ev.window)
+}
+
+// Close detaches the view from the execution and closes its window. The
+// execution goes on.
+func (ev *ExecutionView) Close() {
+	// Closing the window calls Close again
+	if ev.closed {
+		return
+	}
+	ev.closed = true
+	ev.unsubscribe()
+	close(ev.stop)
+	delete(ev.app.executionViews, ev.objective.ID)
+	ev.window.Close()
+}
+
+// setEnabled enables or disables a button.
+func setEnabled(button *widget.Button, enabled bool) {
+	if enabled {
+		button.Enable()
+	} else {
+		button.Disable()
+	}
+}
@@ -35,12 +35,14 @@ type GoalsView struct {
 	goals     []*core.Goal
 	goalNodes map[string]*GoalTreeNode // Maps goal IDs to tree nodes
 	rootGoals []string                 // IDs of top-level goals (no parents)
+	rollups   map[string]*core.GoalTreeNode // Progress rolled up from sub-goals, by goal ID
 
 	// State
 	searchFilter   string
 	statusFilter   core.GoalStatus
 	sortMode      string
 	selectedGoalID string
+	expanded       map[string]bool // Open branches, restored after each refresh
 }
 
 // GoalTreeNode represents a goal in the tree structure.
@@ -56,6 +58,8 @@ func NewGoalsView(app *App, parent fyne.Window) *GoalsView {
 		app:       app,
 		parent:    parent,
 		goalNodes: make(map[string]*GoalTreeNode),
+		rollups:   make(map[string]*core.GoalTreeNode),
+		expanded:  make(map[string]bool),
 		sortMode:  "priority", // Default sort by priority
 	}
 
@@ -101,6 +105,7 @@ func (gv *GoalsView) buildToolbar() {
 	// Status filter
 	statusOptions := []string{"All", "Active", "Paused", "Completed", "Archived"}
 	gv.filterSelect = widget.NewSelect(statusOptions, func(selected string) {
+		showedArchived := gv.statusFilter == core.GoalStatusArchived
 		switch selected {
 		case "Active":
 			gv.statusFilter = core.GoalStatusActive
@@ -113,6 +118,11 @@ func (gv *GoalsView) buildToolbar() {
 		default:
 			gv.statusFilter = "" // "All" - no filter
 		}
+		// Archived goals are only loaded while they are asked for
+		if showedArchived != (gv.statusFilter == core.GoalStatusArchived) {
+			gv.refreshData()
+			return
+		}
 		gv.applyFiltersAndSort()
 	})
 	gv.filterSelect.SetSelected("All")
@@ -142,6 +152,12 @@ func (gv *GoalsView) buildToolbar() {
 		}
 	})
 
+	proposeButton := widget.NewButtonWithIcon("Propose Objectives", theme.ListIcon(), func() {
+		if gv.selectedGoalID != "" {
+			gv.proposeObjectives(gv.selectedGoalID)
+		}
+	})
+
 	refreshButton := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), func() {
 		gv.refreshData()
 	})
@@ -166,6 +182,7 @@ func (gv *GoalsView) buildToolbar() {
 		newButton,
 		editButton,
 		deleteButton,
+		proposeButton,
 		widget.NewSeparator(),
 		refreshButton,
 	)
@@ -217,6 +234,14 @@ func (gv *GoalsView) buildGoalsTree() {
 		},
 	)
 
+	// Remember open branches so a refresh does not collapse them
+	gv.goalsTree.OnBranchOpened = func(uid widget.TreeNodeID) {
+		gv.expanded[uid] = true
+	}
+	gv.goalsTree.OnBranchClosed = func(uid widget.TreeNodeID) {
+		delete(gv.expanded, uid)
+	}
+
 	// Handle selection changes
 	gv.goalsTree.OnSelected = func(uid widget.TreeNodeID) {
 		gv.selectedGoalID = uid
@@ -233,8 +258,10 @@ func (gv *GoalsView) createGoalNodeWidget() fyne.CanvasObject {
 	statusIcon := widget.NewIcon(theme.InfoIcon())
 	priorityLabel := widget.NewLabel("P5")
 	titleLabel := widget.NewLabel("Goal Title")
+	progressLabel := widget.NewLabel("")
 
 	priorityLabel.TextStyle = fyne.TextStyle{Bold: true}
+	progressLabel.Importance = widget.LowImportance
 
 	// Set minimum size for consistent layout
 	statusIcon.Resize(fyne.NewSize(16, 16))
@@ -244,6 +271,7 @@ func (gv *GoalsView) createGoalNodeWidget() fyne.CanvasObject {
 		statusIcon,
 		priorityLabel,
 		titleLabel,
+		progressLabel,
 	)
 }
 
@@ -290,6 +318,30 @@ func (gv *GoalsView) updateGoalNodeWidget(uid widget.TreeNodeID, nodeWidget fyne
 			titleLabel.TextStyle = fyne.TextStyle{}
 		}
 	}
+
+	if len(containerObj.Objects) >= 4 {
+		progressLabel := containerObj.Objects[3].(*widget.Label)
+		progressLabel.SetText(gv.progressSummary(uid))
+	}
+}
+
+// progressSummary describes a goal's progress including its sub-goals,
+// e.g. "40% · 2/5 objectives (1 failed) · $0.50", weighted by priority.
+func (gv *GoalsView) progressSummary(goalID string) string {
+	node, exists := gv.rollups[goalID]
+	if !exists || node.Rollup.Objectives == 0 {
+		return ""
+	}
+
+	rollup := node.Rollup
+	summary := fmt.Sprintf("%.0f%% · %d/%d objectives", rollup.WeightedPercent(), rollup.Completed(), rollup.Objectives)
+	if failed := rollup.Failed(); failed > 0 {
+		summary += fmt.Sprintf(" (%d failed)", failed)
+	}
+	if rollup.Spend > 0 {
+		summary += fmt.Sprintf(" · $%.2f", rollup.Spend)
+	}
+	return summary
 }
 
 // getStatusIcon returns the appropriate icon for a goal status.
@@ -314,7 +366,7 @@ func (gv *GoalsView) refreshData() {
 
 	// Load all goals
 	ctx := gv.app.GetContext()
-	goals, err := gv.app.GetGoalManager().ListGoals(ctx, core.GoalFilter{})
+	goals, err := gv.app.GetGoalManager().ListGoals(ctx, core.GoalFilter{IncludeArchived: gv.statusFilter == core.GoalStatusArchived})
 	if err != nil {
 		log.Printf("Failed to load goals: %v", err)
 		gv.updateStatusBar("Error loading goals")
@@ -322,13 +374,47 @@ func (gv *GoalsView) refreshData() {
 	}
 
 	gv.goals = goals
+	gv.loadRollups()
 	gv.rebuildTreeStructure()
 	gv.applyFiltersAndSort()
+	gv.restoreExpandedBranches()
 
 	count := len(gv.goals)
 	gv.updateStatusBar(fmt.Sprintf("Loaded %d goal(s)", count))
 }
 
+// loadRollups loads each goal's progress rolled up from its sub-goals.
+// The tree still shows goals without numbers if the rollups cannot be loaded.
+func (gv *GoalsView) loadRollups() {
+	gv.rollups = make(map[string]*core.GoalTreeNode)
+
+	tree, err := gv.app.GetGoalManager().GetGoalTreeWithRollups(gv.app.GetContext(), "", core.GoalTreeOptions{})
+	if err != nil {
+		log.Printf("Failed to load goal rollups: %v", err)
+		return
+	}
+
+	var index func(nodes []*core.GoalTreeNode)
+	index = func(nodes []*core.GoalTreeNode) {
+		for _, node := range nodes {
+			if _, exists := gv.rollups[node.Goal.ID]; !exists {
+				gv.rollups[node.Goal.ID] = node
+			}
+			index(node.Children)
+		}
+	}
+	index(tree.Roots)
+}
+
+// restoreExpandedBranches reopens the branches that were open before a refresh.
+func (gv *GoalsView) restoreExpandedBranches() {
+	for goalID := range gv.expanded {
+		if node, exists := gv.goalNodes[goalID]; exists && len(node.Children) > 0 {
+			gv.goalsTree.OpenBranch(goalID)
+		}
+	}
+}
+
 // rebuildTreeStructure analyzes goal relationships and builds the tree structure.
 func (gv *GoalsView) rebuildTreeStructure() {
 	gv.goalNodes = make(map[string]*GoalTreeNode)
@@ -494,6 +580,12 @@ func (gv *GoalsView) updateStatusBar(message ...string) {
 			goal := node.Goal
 			status := fmt.Sprintf("Selected: %s | Status: %s | Priority: %d | Created: %s",
 				goal.Title, goal.Status, goal.Priority, goal.CreatedAt.Format("2006-01-02"))
+			if progress := gv.progressSummary(goal.ID); progress != "" {
+				status += " | " + progress
+			}
+			if rollup, exists := gv.rollups[goal.ID]; exists && !rollup.Rollup.LastActivity.IsZero() {
+				status += " | Last activity: " + rollup.Rollup.LastActivity.Local().Format("2006-01-02 15:04")
+			}
 			gv.statusLabel.SetText(status)
 		}
 	} else {
@@ -528,17 +620,12 @@ func (gv *GoalsView) showEditGoalDialog(goalID string) {
 	dialog.Show()
 }
 
-// archiveGoal archives the specified goal.
+// archiveGoal archives the specified goal, cancelling its pending and
+// paused objectives.
 func (gv *GoalsView) archiveGoal(goalID string) {
 	ctx := gv.app.GetContext()
 
-	// Update goal status to archived
-	archiveStatus := core.GoalStatusArchived
-	updates := core.GoalUpdates{
-		Status: &archiveStatus,
-	}
-
-	_, err := gv.app.GetGoalManager().UpdateGoal(ctx, goalID, updates)
+	_, err := gv.app.GetGoalManager().ArchiveGoal(ctx, goalID, core.ArchiveOptions{})
 	if err != nil {
 		log.Printf("Failed to archive goal: %v", err)
 		gv.updateStatusBar("Error archiving goal")
@@ -549,6 +636,16 @@ func (gv *GoalsView) archiveGoal(goalID string) {
 	gv.updateStatusBar("Goal archived")
 }
 
+// proposeObjectives asks for objectives for the goal and lets the user
+// choose which to create.
+func (gv *GoalsView) proposeObjectives(goalID string) {
+	gv.updateStatusBar("Proposing objectives...")
+	ProposeObjectives(gv.app, gv.parent, goalID, func(accepted *core.AcceptedDecomposition) {
+		gv.refreshData()
+		gv.updateStatusBar(fmt.Sprintf("Created %d objectives", len(accepted.Objectives)))
+	})
+}
+
 // GetContainer returns the main container widget for this view.
 func (gv *GoalsView) GetContainer() *fyne.Container {
 	return gv.container
@@ -2,11 +2,17 @@ package ui
 
 import (
 	"fmt"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/Solifugus/ai-work-studio/internal/config"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
 )
 
 // MainWindow represents the main application window with tab navigation.
@@ -199,11 +205,248 @@ func (mw *MainWindow) createSettingsTab() fyne.CanvasObject {
 		autoApproveCheck,
 		verboseCheck,
 		widget.NewSeparator(),
+		widget.NewLabel("Approval Rules"),
+		mw.createApprovalRulesSection(),
+		widget.NewSeparator(),
+		widget.NewLabel("Model Routing"),
+		mw.createRoutingSection(),
+		widget.NewSeparator(),
+		widget.NewLabel("Provider Keys"),
+		mw.createProviderKeysSection(),
+		widget.NewSeparator(),
+		widget.NewLabel("Network Allowlist"),
+		mw.createNetworkSection(),
+		widget.NewSeparator(),
 		widget.NewLabel("More settings will be available in future versions."),
 	)
 	return container.NewScroll(content)
 }
 
+// createApprovalRulesSection lists the standing approval rules with their
+// usage, and lets the user revoke them or resume suspended ones.
+func (mw *MainWindow) createApprovalRulesSection() fyne.CanvasObject {
+	section := container.NewVBox()
+
+	var refresh func()
+	refresh = func() {
+		section.Objects = nil
+
+		rules, err := mw.app.ruleManager.ListRules(mw.app.ctx)
+		if err != nil {
+			section.Add(widget.NewLabel(fmt.Sprintf("Failed to load rules: %v", err)))
+			section.Refresh()
+			return
+		}
+		if len(rules) == 0 {
+			section.Add(widget.NewLabel("No rules yet. Approve a decision with \"always allow similar\" to create one."))
+			section.Refresh()
+			return
+		}
+
+		now := time.Now()
+		for _, rule := range rules {
+			rule := rule
+			text := fmt.Sprintf("%s (%s, used %d times)", rule, rule.State(now), rule.UsageCount)
+			if rule.Status == core.ApprovalRuleSuspended {
+				text += fmt.Sprintf("\nSuspended: %s", rule.SuspendedReason)
+			}
+			label := widget.NewLabel(text)
+			label.Wrapping = fyne.TextWrapWord
+
+			var buttons []fyne.CanvasObject
+			if rule.Status == core.ApprovalRuleSuspended {
+				buttons = append(buttons, widget.NewButton("Resume", func() {
+					if err := mw.app.ruleManager.ResumeRule(mw.app.ctx, rule.ID); err != nil {
+						showCodedError(err, mw.window)
+						return
+					}
+					refresh()
+				}))
+			}
+			if rule.Status != core.ApprovalRuleRevoked {
+				buttons = append(buttons, widget.NewButton("Revoke", func() {
+					dialog.ShowConfirm("Revoke Rule", fmt.Sprintf("Revoke \"%s\"?", rule), func(confirmed bool) {
+						if !confirmed {
+							return
+						}
+						if err := mw.app.ruleManager.RevokeRule(mw.app.ctx, rule.ID); err != nil {
+							showCodedError(err, mw.window)
+							return
+						}
+						refresh()
+					}, mw.window)
+				}))
+			}
+
+			section.Add(container.NewBorder(nil, nil, nil, container.NewHBox(buttons...), label))
+		}
+		section.Refresh()
+	}
+
+	refresh()
+	return section
+}
+
+// createProviderKeysSection shows whether each provider accepts its API key,
+// and lets the user replace keys stored in the configuration.
+func (mw *MainWindow) createProviderKeysSection() fyne.CanvasObject {
+	section := container.NewVBox()
+
+	var refresh func()
+	refresh = func() {
+		section.Objects = nil
+
+		for _, provider := range llm.CredentialProviders {
+			provider := provider
+			status := mw.app.llmRouter.Credentials().Status(provider)
+			text := fmt.Sprintf("%s: %s", provider, credentialStateText(status.State))
+			if status.State == llm.CredentialAuthFailed {
+				text += fmt.Sprintf(" since %s", status.FailedAt.Format("Jan 2, 15:04"))
+			}
+			label := widget.NewLabel(text)
+			label.Wrapping = fyne.TextWrapWord
+
+			// A key saved here overrides one set in the environment
+			if status.Source == llm.CredentialSourceEnv {
+				label.SetText(text + fmt.Sprintf("\nSet by %s", llm.ProviderEnvVar(provider)))
+			}
+
+			replace := widget.NewButton("Replace Key", func() {
+				entry := widget.NewPasswordEntry()
+				dialog.ShowForm(fmt.Sprintf("Replace %s API Key", provider), "Save", "Cancel",
+					[]*widget.FormItem{widget.NewFormItem("API key", entry)},
+					func(confirmed bool) {
+						if !confirmed {
+							return
+						}
+						go func() {
+							err := mw.app.UpdateAPIKey(provider, entry.Text)
+							fyne.Do(func() {
+								if err != nil {
+									showCodedError(fmt.Errorf("the %s key was not accepted: %w", provider, err), mw.window)
+								}
+								refresh()
+							})
+						}()
+					}, mw.window)
+			})
+			section.Add(container.NewBorder(nil, nil, nil, replace, label))
+		}
+		section.Refresh()
+	}
+
+	refresh()
+	return section
+}
+
+// createRoutingSection lets the user choose the routing policy models are
+// picked by.
+func (mw *MainWindow) createRoutingSection() fyne.CanvasObject {
+	options := make([]string, len(llm.RoutingPolicies))
+	for i, policy := range llm.RoutingPolicies {
+		options[i] = string(policy)
+	}
+
+	policySelect := widget.NewSelect(options, nil)
+	if current := mw.app.llmRouter.Policy(); current != llm.PolicyDefault {
+		policySelect.SetSelected(string(current))
+	}
+	policySelect.OnChanged = func(selected string) {
+		if err := mw.app.UpdateRoutingPolicy(llm.RoutingPolicy(selected)); err != nil {
+			showCodedError(err, mw.window)
+		}
+	}
+
+	help := widget.NewLabel("cost-saver prefers the cheapest and local models, quality-first the best available regardless of cost.")
+	help.Wrapping = fyne.TextWrapWord
+	return container.NewVBox(container.NewBorder(nil, nil, widget.NewLabel("Policy"), nil, policySelect), help)
+}
+
+// createNetworkSection shows the hosts outbound requests may reach, lets the
+// user add and remove them, and switches between auditing and enforcing.
+func (mw *MainWindow) createNetworkSection() fyne.CanvasObject {
+	auditor := netaudit.Default()
+	section := container.NewVBox()
+	list := container.NewVBox()
+
+	var refresh func()
+	refresh = func() {
+		list.Objects = nil
+		allowlist := auditor.Allowlist()
+		if len(allowlist) == 0 {
+			list.Add(widget.NewLabel("The allowlist is empty."))
+		}
+		for _, pattern := range allowlist {
+			pattern := pattern
+			remove := widget.NewButton("Remove", func() {
+				err := mw.app.config.UpdateNetwork(mw.app.configPath, config.NetworkUpdates{Disallow: []string{pattern}})
+				if err != nil {
+					showCodedError(err, mw.window)
+					return
+				}
+				auditor.Disallow(pattern)
+				refresh()
+			})
+			list.Add(container.NewBorder(nil, nil, nil, remove, widget.NewLabel(pattern)))
+		}
+		list.Refresh()
+	}
+
+	enforce := widget.NewCheck("Block hosts that are not on the allowlist", func(checked bool) {
+		mode := netaudit.ModeAudit
+		if checked {
+			mode = netaudit.ModeEnforce
+		}
+		modeName := string(mode)
+		if err := mw.app.config.UpdateNetwork(mw.app.configPath, config.NetworkUpdates{Mode: &modeName}); err != nil {
+			showCodedError(err, mw.window)
+			return
+		}
+		auditor.SetMode(mode)
+	})
+	enforce.SetChecked(auditor.Mode() == netaudit.ModeEnforce)
+
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("api.example.com, *.example.com or localhost:8080")
+	add := widget.NewButton("Add", func() {
+		pattern, err := netaudit.NormalizePattern(entry.Text)
+		if err != nil {
+			showCodedError(err, mw.window)
+			return
+		}
+		if err := mw.app.config.UpdateNetwork(mw.app.configPath, config.NetworkUpdates{Allow: []string{pattern}}); err != nil {
+			showCodedError(err, mw.window)
+			return
+		}
+		auditor.Allow(pattern)
+		entry.SetText("")
+		refresh()
+	})
+
+	note := widget.NewLabel("Every outbound request is recorded; run 'network audit' in the CLI for a summary.")
+	note.Wrapping = fyne.TextWrapWord
+
+	section.Add(enforce)
+	section.Add(list)
+	section.Add(container.NewBorder(nil, nil, nil, add, entry))
+	section.Add(note)
+
+	refresh()
+	return section
+}
+
+// credentialStateText describes a provider's credential state.
+func credentialStateText(state llm.CredentialState) string {
+	switch state {
+	case llm.CredentialHealthy:
+		return "key accepted"
+	case llm.CredentialAuthFailed:
+		return "key rejected, provider skipped"
+	default:
+		return "not checked yet"
+	}
+}
+
 // Dialog methods
 func (mw *MainWindow) showNewGoalDialog() {
 	titleEntry := widget.NewEntry()
@@ -122,6 +122,10 @@ func (mv *MethodsView) createDetailsPanel() {
 	// Metrics Tab
 	metricsTab := container.NewTabItem("Metrics", mv.createMetricsPanel())
 	mv.detailsView.Append(metricsTab)
+
+	// Playbook Tab
+	playbookTab := container.NewTabItem("Playbook", mv.createPlaybookPanel())
+	mv.detailsView.Append(playbookTab)
 }
 
 // createOverviewPanel creates the overview details panel
@@ -156,6 +160,14 @@ func (mv *MethodsView) createMetricsPanel() fyne.CanvasObject {
 	return container.NewScroll(content)
 }
 
+// createPlaybookPanel creates the playbook panel
+func (mv *MethodsView) createPlaybookPanel() fyne.CanvasObject {
+	content := container.NewVBox(
+		widget.NewLabel("Select a method to view its playbook"),
+	)
+	return container.NewScroll(content)
+}
+
 // methodsListLength returns the number of filtered methods
 func (mv *MethodsView) methodsListLength() int {
 	return len(mv.filteredMethods)
@@ -364,6 +376,9 @@ func (mv *MethodsView) updateDetailsView() {
 
 	// Update Metrics Tab
 	mv.updateMetricsTab()
+
+	// Update Playbook Tab
+	mv.updatePlaybookTab()
 }
 
 // updateOverviewTab updates the overview tab with method details
@@ -406,6 +421,12 @@ func (mv *MethodsView) updateOverviewTab() {
 			widget.NewLabel("Last Used:"), widget.NewLabel(mv.formatLastUsed(method.Metrics.LastUsed)),
 		),
 	)
+	if method.Metrics.ComparisonRuns > 0 {
+		content.Add(container.NewHBox(
+			widget.NewLabel("Comparisons Won:"),
+			widget.NewLabel(fmt.Sprintf("%d of %d", method.Metrics.ComparisonWins, method.Metrics.ComparisonRuns)),
+		))
+	}
 
 	scrollContent := container.NewScroll(content)
 	mv.detailsView.Items[0].Content = scrollContent
@@ -514,6 +535,26 @@ func (mv *MethodsView) updateMetricsTab() {
 	mv.detailsView.Refresh()
 }
 
+// updatePlaybookTab renders the generated playbook for the selected method.
+// The GUI shows the mechanical playbook only, so it never makes LLM calls.
+func (mv *MethodsView) updatePlaybookTab() {
+	method := mv.selectedMethod
+
+	var content fyne.CanvasObject
+	playbook, err := mv.app.GetMethodManager().GeneratePlaybook(mv.app.GetContext(), method.ID, core.DefaultPlaybookOptions())
+	if err != nil {
+		log.Printf("Failed to generate playbook for method %s: %v", method.ID, err)
+		content = widget.NewLabel("Failed to generate playbook: " + err.Error())
+	} else {
+		richText := widget.NewRichTextFromMarkdown(playbook.Markdown)
+		richText.Wrapping = fyne.TextWrapWord
+		content = richText
+	}
+
+	mv.detailsView.Items[4].Content = container.NewScroll(content)
+	mv.detailsView.Refresh()
+}
+
 // Helper methods
 
 // formatLastUsed formats the last used time
@@ -257,11 +257,11 @@ func TestMethodsViewCreateUI(t *testing.T) {
 	}
 
 	// Test details view tabs
-	if len(methodsView.detailsView.Items) != 4 {
-		t.Errorf("Details view tabs count = %d, want 4", len(methodsView.detailsView.Items))
+	if len(methodsView.detailsView.Items) != 5 {
+		t.Errorf("Details view tabs count = %d, want 5", len(methodsView.detailsView.Items))
 	}
 
-	expectedTabs := []string{"Overview", "Approach", "History", "Metrics"}
+	expectedTabs := []string{"Overview", "Approach", "History", "Metrics", "Playbook"}
 	for i, expected := range expectedTabs {
 		if i >= len(methodsView.detailsView.Items) {
 			break
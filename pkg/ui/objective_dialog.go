@@ -13,6 +13,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
 )
 
 // ObjectiveDialogMode represents the mode of the objective dialog.
@@ -44,10 +45,18 @@ type ObjectiveDialog struct {
 	prioritySlider   *widget.Slider
 	statusSelect     *widget.Select
 	contextEntry     *widget.Entry
+	timeBoxEntry     *widget.Entry
+	taskTypeSelect   *widget.Select
+	qualitySelect    *widget.Select
 
 	// Labels
 	priorityLabel *widget.Label
 	errorLabel    *widget.Label
+	costLabel     *widget.Label
+
+	// Cost estimate, nil when no provider is configured
+	costCard      *widget.Card
+	costPreviewer *llm.CostPreviewer
 
 	// Data
 	availableGoals   []*core.Goal
@@ -124,6 +133,11 @@ func (od *ObjectiveDialog) buildDialog() {
 	)
 
 	od.dialog.Resize(fyne.NewSize(550, 700))
+	od.dialog.SetOnClosed(func() {
+		if od.costPreviewer != nil {
+			od.costPreviewer.Close()
+		}
+	})
 
 	// If editing, populate fields
 	if od.mode == ObjectiveDialogModeEdit {
@@ -214,9 +228,94 @@ func (od *ObjectiveDialog) buildFormFields() {
 		return nil
 	}
 
+	// Time box entry
+	od.timeBoxEntry = widget.NewEntry()
+	od.timeBoxEntry.SetPlaceHolder("e.g. 2h or 45m (blank for the priority default)")
+	od.timeBoxEntry.Validator = func(s string) error {
+		_, err := parseTimeBox(s)
+		return err
+	}
+
 	// Error label
 	od.errorLabel = widget.NewLabel("")
 	od.errorLabel.Hide()
+
+	od.buildCostPreview()
+}
+
+// buildCostPreview sets up the cost estimate shown while the title and
+// description are written. It estimates only; nothing is sent to a provider.
+func (od *ObjectiveDialog) buildCostPreview() {
+	od.costLabel = widget.NewLabel("")
+	od.costLabel.Hide()
+	od.taskTypeSelect = widget.NewSelect([]string{"analysis", "generation", "reasoning", "qa"}, nil)
+	od.taskTypeSelect.SetSelected("analysis")
+	od.qualitySelect = widget.NewSelect([]string{
+		llm.QualityBasic.String(),
+		llm.QualityStandard.String(),
+		llm.QualityPremium.String(),
+	}, nil)
+	od.qualitySelect.SetSelected(llm.QualityStandard.String())
+	od.costCard = widget.NewCard("Estimated Cost", "", container.NewVBox(
+		container.NewGridWithColumns(2, od.taskTypeSelect, od.qualitySelect),
+		od.costLabel,
+	))
+
+	router := od.app.llmRouter
+	if router == nil || !router.HasProviders() {
+		od.costCard.Hide()
+		return
+	}
+
+	budget, err := budgetManager(od.app)
+	if err != nil {
+		log.Printf("Warning: cost estimates will not be checked against the budget: %v", err)
+	}
+	perRequest := od.app.GetConfig().BudgetLimits.PerRequestLimit
+	od.costPreviewer = llm.NewCostPreviewer(router, llm.CostPreviewConfig{
+		Limits: func() llm.CostLimits { return llm.BudgetCostLimits(budget, perRequest) },
+	}, func(seq uint64, preview *llm.CostPreview) {
+		fyne.Do(func() { od.showCostPreview(preview) })
+	})
+
+	update := func(string) { od.updateCostPreview() }
+	od.titleEntry.OnChanged = update
+	od.descriptionEntry.OnChanged = update
+	od.taskTypeSelect.OnChanged = update
+	od.qualitySelect.OnChanged = update
+}
+
+// updateCostPreview queues an estimate of the objective as currently written.
+func (od *ObjectiveDialog) updateCostPreview() {
+	if od.costPreviewer == nil {
+		return
+	}
+	quality, err := llm.ParseQualityRequirement(od.qualitySelect.Selected)
+	if err != nil {
+		quality = llm.QualityStandard
+	}
+	od.costPreviewer.Update(llm.TaskRequest{
+		Prompt:          strings.TrimSpace(od.titleEntry.Text + "\n\n" + od.descriptionEntry.Text),
+		TaskType:        od.taskTypeSelect.Selected,
+		QualityRequired: quality,
+	})
+}
+
+// showCostPreview displays an estimate, in the warning colour when it is
+// over a budget limit, or hides the label when there is nothing to show.
+func (od *ObjectiveDialog) showCostPreview(preview *llm.CostPreview) {
+	if preview == nil {
+		od.costLabel.Hide()
+		return
+	}
+	text := preview.String()
+	od.costLabel.Importance = widget.MediumImportance
+	if preview.OverBudget() {
+		text += " — " + preview.Warning
+		od.costLabel.Importance = widget.WarningImportance
+	}
+	od.costLabel.SetText(text)
+	od.costLabel.Show()
 }
 
 // createForm creates the form layout with all fields.
@@ -247,6 +346,12 @@ func (od *ObjectiveDialog) createForm() *fyne.Container {
 			widget.NewLabel("1=Low, 5=Medium, 10=High. Inherited from goal by default."),
 		)),
 
+		// Time box
+		widget.NewCard("Time Box", "", container.NewVBox(
+			od.timeBoxEntry,
+			widget.NewLabel("Optional. Active execution time before the objective is paused for review."),
+		)),
+
 		// Status
 		widget.NewCard("Status", "", container.NewVBox(
 			od.statusSelect,
@@ -264,6 +369,9 @@ func (od *ObjectiveDialog) createForm() *fyne.Container {
 			container.NewScroll(od.contextEntry),
 			widget.NewLabel("Optional JSON data providing context for execution."),
 		)),
+
+		// Cost estimate
+		od.costCard,
 	)
 
 	return form
@@ -350,6 +458,11 @@ func (od *ObjectiveDialog) populateFields() {
 	od.prioritySlider.SetValue(float64(obj.Priority))
 	od.priorityLabel.SetText(fmt.Sprintf("Priority: %d", obj.Priority))
 
+	// Set time box
+	if obj.MaxExecutionTime > 0 {
+		od.timeBoxEntry.SetText(obj.MaxExecutionTime.String())
+	}
+
 	// Set status
 	od.statusSelect.SetSelected(string(obj.Status))
 
@@ -403,6 +516,11 @@ func (od *ObjectiveDialog) validateForm() error {
 		return fmt.Errorf("context: %v", err)
 	}
 
+	// Validate time box
+	if err := od.timeBoxEntry.Validator(od.timeBoxEntry.Text); err != nil {
+		return fmt.Errorf("time box: %v", err)
+	}
+
 	return nil
 }
 
@@ -445,19 +563,31 @@ func (od *ObjectiveDialog) createObjective() {
 		return
 	}
 
+	// Set time box if one was given
+	if timeBox, _ := parseTimeBox(od.timeBoxEntry.Text); timeBox > 0 {
+		if updated, err := manager.UpdateObjective(ctx, objective.ID, core.ObjectiveUpdates{MaxExecutionTime: &timeBox}); err != nil {
+			log.Printf("Warning: Failed to set objective time box: %v", err)
+		} else {
+			objective = updated
+		}
+	}
+
 	// Set status if different from default
 	selectedStatus := core.ObjectiveStatus(od.statusSelect.Selected)
 	if selectedStatus != core.ObjectiveStatusPending {
+		// Only a real start counts against the work-in-progress limits; the
+		// other statuses pass through in_progress just to record the transition
+		passThrough := core.StartOptions{OverrideWIPLimit: true, Reason: "status set when creating the objective"}
 		switch selectedStatus {
 		case core.ObjectiveStatusInProgress:
 			_, err = manager.StartObjective(ctx, objective.ID)
 		case core.ObjectiveStatusPaused:
-			_, err = manager.StartObjective(ctx, objective.ID)
+			_, err = manager.StartObjective(ctx, objective.ID, passThrough)
 			if err == nil {
 				_, err = manager.PauseObjective(ctx, objective.ID)
 			}
 		case core.ObjectiveStatusCompleted:
-			_, err = manager.StartObjective(ctx, objective.ID)
+			_, err = manager.StartObjective(ctx, objective.ID, passThrough)
 			if err == nil {
 				_, err = manager.CompleteObjective(ctx, objective.ID, core.ObjectiveResult{
 					Success:     true,
@@ -466,7 +596,7 @@ func (od *ObjectiveDialog) createObjective() {
 				})
 			}
 		case core.ObjectiveStatusFailed:
-			_, err = manager.StartObjective(ctx, objective.ID)
+			_, err = manager.StartObjective(ctx, objective.ID, passThrough)
 			if err == nil {
 				_, err = manager.FailObjective(ctx, objective.ID, "Manually marked as failed", 0)
 			}
@@ -516,6 +646,9 @@ func (od *ObjectiveDialog) updateObjective() {
 		}
 	}
 
+	// Blank clears the time box back to the priority default
+	timeBox, _ := parseTimeBox(od.timeBoxEntry.Text)
+
 	// Update objective
 	updates := &core.ObjectiveUpdates{
 		GoalID:      &selectedGoal.ID,
@@ -524,6 +657,8 @@ func (od *ObjectiveDialog) updateObjective() {
 		Description: &od.descriptionEntry.Text,
 		Priority:    func() *int { p := int(od.prioritySlider.Value); return &p }(),
 		Context:     context,
+
+		MaxExecutionTime: &timeBox,
 	}
 
 	objective, err := manager.UpdateObjective(ctx, od.objective.ID, *updates)
@@ -549,4 +684,17 @@ func (od *ObjectiveDialog) handleCancel() {
 func (od *ObjectiveDialog) showError(message string) {
 	od.errorLabel.SetText(fmt.Sprintf("Error: %s", message))
 	od.errorLabel.Show()
-}
\ No newline at end of file
+}
+
+// parseTimeBox parses a time box entry; blank means no time box of its own.
+func parseTimeBox(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("must be a positive duration such as 2h or 45m")
+	}
+	return d, nil
+}
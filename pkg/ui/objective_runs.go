@@ -0,0 +1,230 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// newExecutionCursor returns the real-time cursor the app runs objectives
+// through. Tasks with side effects are put to the user for approval where
+// the ethical framework asks for it, and plans may use the web service.
+// Plans are checked against the executor's tools and their method first.
+func (a *App) newExecutionCursor() *core.RealTimeCursor {
+	ethics := core.NewEthicalFramework(a.store, a.llmRouter, a.contextManager)
+	executor := core.NewRouterTaskExecutor(a.llmRouter)
+	executor.SetEthicalReview(ethics, a.config.Session.UserID, a.promptApproval)
+	tools := mcp.NewServiceRegistry(log.New(io.Discard, "", 0))
+	tools.RegisterMiddleware(mcp.RecoveryMiddleware(nil))
+	if err := tools.RegisterService(mcp.NewWebService(mcp.WebConfig{Search: mcp.SearchBackendFromEnv()}, nil)); err != nil {
+		log.Printf("Warning: web service unavailable: %v", err)
+	}
+	executor.SetTools(tools)
+	cursor := core.NewRealTimeCursor(a.store, executor, core.NewStoreContextLoader(a.store))
+	cursor.SetPlanValidator(core.NewPlanValidator(a.store, executor))
+	return cursor
+}
+
+// ExecutionCursor returns the cursor objectives run through, whose progress
+// execution views subscribe to.
+func (a *App) ExecutionCursor() *core.RealTimeCursor {
+	return a.cursor
+}
+
+// RunObjective plans and executes an objective through the learning loop in
+// the background, and completes it with the outcome. A pending objective is
+// started first, with opts. The run belongs to the app rather than to any
+// window, so it goes on when the window following it is closed.
+func (a *App) RunObjective(objectiveID string, opts ...core.StartOptions) error {
+	a.runsMu.Lock()
+	defer a.runsMu.Unlock()
+	if _, running := a.runs[objectiveID]; running {
+		return errs.New(errs.Conflict, "objective is already running").With("objective_id", objectiveID)
+	}
+
+	objective, err := a.objectiveManager.GetObjective(a.ctx, objectiveID)
+	if err != nil {
+		return err
+	}
+	switch objective.Status {
+	case core.ObjectiveStatusCompleted, core.ObjectiveStatusFailed, core.ObjectiveStatusCancelled:
+		return errs.Newf(errs.Conflict, "objective is already %s", objective.Status).With("objective_id", objectiveID)
+	case core.ObjectiveStatusPaused:
+		return errs.New(errs.Conflict, "objective is paused; resume it first").With("objective_id", objectiveID)
+	case core.ObjectiveStatusPending:
+		// The learning loop itself continues an objective stopped by its time box
+		if objective.TimeBoxStop() == nil {
+			if _, err := a.objectiveManager.StartObjective(a.ctx, objectiveID, opts...); err != nil {
+				return err
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.runs[objectiveID] = cancel
+	a.runsWG.Add(1)
+	go func() {
+		defer a.runsWG.Done()
+		err := a.executeObjective(ctx, objectiveID)
+
+		a.runsMu.Lock()
+		delete(a.runs, objectiveID)
+		a.runsMu.Unlock()
+		cancel()
+
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Warning: objective %s: %v", objectiveID, err)
+			fyne.Do(func() { a.showRunError(fmt.Errorf("running %q: %w", objective.Title, err)) })
+		}
+	}()
+	return nil
+}
+
+// IsObjectiveRunning reports whether the objective is being run by the app.
+func (a *App) IsObjectiveRunning(objectiveID string) bool {
+	a.runsMu.Lock()
+	defer a.runsMu.Unlock()
+	_, running := a.runs[objectiveID]
+	return running
+}
+
+// CancelObjectiveRun cancels the objective's run, if one is in progress. The
+// objective stays in progress, to be run again or paused.
+func (a *App) CancelObjectiveRun(objectiveID string) bool {
+	a.runsMu.Lock()
+	defer a.runsMu.Unlock()
+	cancel, running := a.runs[objectiveID]
+	if running {
+		cancel()
+	}
+	return running
+}
+
+// stopObjectiveRuns cancels every run and waits for them to stop.
+func (a *App) stopObjectiveRuns() {
+	a.runsMu.Lock()
+	for _, cancel := range a.runs {
+		cancel()
+	}
+	a.runsMu.Unlock()
+	a.runsWG.Wait()
+}
+
+// executeObjective runs the learning loop for the objective and records its
+// outcome, as the CLI's run-objective does.
+func (a *App) executeObjective(ctx context.Context, objectiveID string) error {
+	cc := core.NewContemplativeCursor(a.store, core.NewRouterReasoner(a.llmRouter))
+	cc.SetContextLoader(core.NewStoreContextLoader(a.store))
+	loop := core.NewLearningLoop(a.store, cc, a.cursor, core.NewRouterLearningAgent(a.llmRouter))
+	loop.SetWIPLimits(a.objectiveManager.WIPLimits())
+	loop.SetTimeBoxes(a.objectiveManager.TimeBoxes())
+
+	result, err := loop.ExecuteObjective(ctx, objectiveID)
+	if err != nil {
+		return err
+	}
+	switch result.FinalOutcome {
+	case core.OutcomeDeferred, core.OutcomeTimeBoxed:
+		// The objective records why it stopped; the user picks it up from there
+		return nil
+	}
+
+	message := fmt.Sprintf("%s after %d attempt(s)", result.FinalOutcome, len(result.ExecutionAttempts))
+	if result.ErrorMessage != "" {
+		message += ": " + result.ErrorMessage
+	}
+	outputs := map[string]interface{}{}
+	if len(result.ExecutionAttempts) > 0 {
+		last := result.ExecutionAttempts[len(result.ExecutionAttempts)-1]
+		for taskID, taskResult := range last.ExecutionResult.TaskResults {
+			if taskResult.Status == core.TaskStatusCompleted {
+				outputs[taskID] = taskResult.Output
+			}
+		}
+	}
+	// An objective resumed from its time box is still pending, and one paused
+	// during its last task is resumed to be completed
+	objective, err := a.objectiveManager.GetObjective(ctx, objectiveID)
+	if err == nil {
+		switch objective.Status {
+		case core.ObjectiveStatusPending:
+			_, err = a.objectiveManager.StartObjective(ctx, objectiveID)
+		case core.ObjectiveStatusPaused:
+			_, err = a.objectiveManager.ResumeObjective(ctx, objectiveID, core.StartOptions{OverrideWIPLimit: true, Reason: "completing its run"})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record the objective's result: %w", err)
+	}
+	objective, err = a.objectiveManager.CompleteObjective(ctx, objectiveID, core.ObjectiveResult{
+		Success: result.WasSuccessful,
+		Message: message,
+		Data: map[string]interface{}{
+			"outcome":  string(result.FinalOutcome),
+			"attempts": len(result.ExecutionAttempts),
+			"outputs":  outputs,
+		},
+		TokensUsed: result.GetTotalTokensUsed(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record the objective's result: %w", err)
+	}
+
+	title := "Objective completed"
+	if !result.WasSuccessful {
+		title = "Objective failed"
+	}
+	fyne.Do(func() {
+		a.fyneApp.SendNotification(fyne.NewNotification(title, objective.Title+": "+message))
+	})
+	return nil
+}
+
+// promptApproval asks the user to approve a task the ethical framework put
+// to them, and waits for the answer or for the run to be cancelled.
+func (a *App) promptApproval(ctx context.Context, decision *core.EthicalDecision) (bool, string, error) {
+	message := fmt.Sprintf("%s\n\nAction: %s\nUrgency: %s\nImpact scores: Freedom=%.1f, Well-being=%.1f, Sustainability=%.1f",
+		decision.DecisionContext, decision.ProposedAction, decision.Urgency,
+		decision.Impact.FreedomImpact, decision.Impact.WellBeingImpact, decision.Impact.SustainabilityImpact)
+	if decision.Impact.Reasoning != "" {
+		message += "\nReasoning: " + decision.Impact.Reasoning
+	}
+
+	answer := make(chan bool, 1)
+	fyne.Do(func() {
+		if a.mainWindow == nil {
+			answer <- false
+			return
+		}
+		dialog.ShowConfirm("Approval Needed", message, func(approved bool) {
+			answer <- approved
+		}, a.mainWindow.window)
+	})
+
+	select {
+	case approved := <-answer:
+		if approved {
+			return true, "Approved via GUI", nil
+		}
+		return false, "Rejected via GUI", nil
+	case <-ctx.Done():
+		return false, "", ctx.Err()
+	}
+}
+
+// showRunError shows an error from a background run over the main window.
+func (a *App) showRunError(err error) {
+	if a.mainWindow == nil {
+		return
+	}
+	showCodedError(err, a.mainWindow.window)
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sort"
@@ -35,6 +36,12 @@ type ObjectivesView struct {
 	statusLabel   *widget.Label
 	refreshButton *widget.Button
 
+	// Suggested next panel
+	suggestionsCard *widget.Card
+	suggestionsBox  *fyne.Container
+	timeSelect      *widget.Select
+	energySelect    *widget.Select
+
 	// Data
 	objectives     []*core.Objective
 	filteredObjectives []*core.Objective
@@ -87,11 +94,12 @@ func (ov *ObjectivesView) GetContainer() *fyne.Container {
 func (ov *ObjectivesView) buildUI() {
 	ov.buildStatusBar()
 	ov.buildToolbar()
+	ov.buildSuggestionsPanel()
 	ov.buildObjectivesList()
 
 	// Main layout using border container
 	ov.container = container.NewBorder(
-		ov.toolbar,    // top
+		container.NewVBox(ov.suggestionsCard, ov.toolbar), // top
 		ov.statusLabel, // bottom
 		nil,           // left
 		nil,           // right
@@ -316,6 +324,9 @@ func (ov *ObjectivesView) updateStatusIconAndLabel(icon *widget.Icon, label *wid
 	case core.ObjectiveStatusPaused:
 		icon.SetResource(theme.MediaPauseIcon())
 		label.SetText("Paused")
+	case core.ObjectiveStatusCancelled:
+		icon.SetResource(theme.ContentClearIcon())
+		label.SetText("Cancelled")
 	default:
 		icon.SetResource(theme.InfoIcon())
 		label.SetText("Unknown")
@@ -426,6 +437,7 @@ func (ov *ObjectivesView) loadObjectives() {
 
 	ov.objectives = objectives
 	ov.applyFiltersAndSort()
+	ov.refreshSuggestions()
 }
 
 // applyFiltersAndSort filters and sorts objectives based on current settings.
@@ -545,6 +557,7 @@ func (ov *ObjectivesView) buildObjectiveDetailsContent(objective *core.Objective
 		widget.NewLabel("Method ID:"), widget.NewLabel(objective.MethodID),
 		widget.NewLabel("Created:"), widget.NewLabel(objective.CreatedAt.Format("2006-01-02 15:04:05")),
 	)
+	ov.addTimeBoxInfo(infoGrid, objective)
 
 	// Description
 	descEntry := widget.NewMultiLineEntry()
@@ -596,17 +609,21 @@ func (ov *ObjectivesView) buildObjectiveDetailsContent(objective *core.Objective
 		startButton := widget.NewButtonWithIcon("Start", theme.MediaPlayIcon(), func() {
 			ov.startObjective(objective)
 		})
-		actionButtons = container.NewHBox(startButton)
+		actionButtons = container.NewHBox(startButton, ov.buildRunButton(objective))
 	case core.ObjectiveStatusInProgress:
 		pauseButton := widget.NewButtonWithIcon("Pause", theme.MediaPauseIcon(), func() {
 			ov.pauseObjective(objective)
 		})
-		actionButtons = container.NewHBox(pauseButton)
+		actionButtons = container.NewHBox(pauseButton, ov.buildRunButton(objective), ov.buildExecutionButton(objective))
 	case core.ObjectiveStatusPaused:
+		if objective.TimeBoxStop() != nil {
+			actionButtons = ov.buildTimeBoxActions(objective)
+			break
+		}
 		resumeButton := widget.NewButtonWithIcon("Resume", theme.MediaPlayIcon(), func() {
 			ov.resumeObjective(objective)
 		})
-		actionButtons = container.NewHBox(resumeButton)
+		actionButtons = container.NewHBox(resumeButton, ov.buildExecutionButton(objective))
 	default:
 		editButton := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), func() {
 			ov.showEditObjectiveDialog(objective)
@@ -620,6 +637,7 @@ func (ov *ObjectivesView) buildObjectiveDetailsContent(objective *core.Objective
 		container.NewTabItem("Description", descEntry),
 		container.NewTabItem("Context", contextEntry),
 		container.NewTabItem("Results", resultContainer),
+		container.NewTabItem("Re-plan", ov.buildReplanPreview(objective)),
 	)
 
 	return container.NewBorder(
@@ -631,6 +649,51 @@ func (ov *ObjectivesView) buildObjectiveDetailsContent(objective *core.Objective
 	)
 }
 
+// buildReplanPreview shows how the objective's context changed since its last
+// execution and how it would be re-planned if run now.
+func (ov *ObjectivesView) buildReplanPreview(objective *core.Objective) fyne.CanvasObject {
+	decision, err := ov.app.replanAdvisor.Preview(ov.app.GetContext(), objective.ID)
+	if err != nil {
+		return widget.NewLabel(fmt.Sprintf("Could not compare the context: %v", err))
+	}
+
+	strategyGrid := container.NewGridWithColumns(2,
+		widget.NewLabel("Strategy:"), widget.NewLabelWithStyle(decision.Strategy.Description(), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Previous plan:"), widget.NewLabel(valueOrNone(decision.PreviousPlanID)),
+	)
+	reasonLabel := widget.NewLabel(decision.Reason)
+	reasonLabel.Wrapping = fyne.TextWrapWord
+
+	diffText := "Nothing to compare against"
+	if decision.Diff != nil {
+		diffText = decision.Diff.String()
+		if lines := decision.Diff.Lines(); len(lines) > 0 {
+			diffText += "\n\n" + strings.Join(lines, "\n")
+		}
+	}
+	diffEntry := widget.NewMultiLineEntry()
+	diffEntry.SetText(diffText)
+	diffEntry.Disable()
+
+	content := container.NewVBox(
+		strategyGrid,
+		reasonLabel,
+		widget.NewCard("Context changes", "", diffEntry),
+	)
+	if len(decision.AffectedTasks) > 0 {
+		content.Add(widget.NewCard("Tasks to re-plan", "", widget.NewLabel(strings.Join(decision.AffectedTasks, ", "))))
+	}
+	return content
+}
+
+// valueOrNone returns the value, or "None" when it is empty.
+func valueOrNone(value string) string {
+	if value == "" {
+		return "None"
+	}
+	return value
+}
+
 // showNewObjectiveDialog displays the dialog for creating a new objective.
 func (ov *ObjectivesView) showNewObjectiveDialog() {
 	dialog := NewObjectiveDialog(ov.app, ov.parent, nil) // nil for new objective
@@ -655,8 +718,15 @@ func (ov *ObjectivesView) startObjective(objective *core.Objective) {
 	manager := ov.app.GetObjectiveManager()
 
 	_, err := manager.StartObjective(ctx, objective.ID)
+	if errors.Is(err, core.ErrWIPLimitReached) {
+		ov.confirmWIPOverride(err, func() error {
+			_, err := manager.StartObjective(ctx, objective.ID, core.StartOptions{OverrideWIPLimit: true, Reason: "started from the objectives view"})
+			return err
+		})
+		return
+	}
 	if err != nil {
-		dialog.ShowError(err, ov.parent)
+		showCodedError(err, ov.parent)
 		return
 	}
 
@@ -670,7 +740,7 @@ func (ov *ObjectivesView) pauseObjective(objective *core.Objective) {
 
 	_, err := manager.PauseObjective(ctx, objective.ID)
 	if err != nil {
-		dialog.ShowError(err, ov.parent)
+		showCodedError(err, ov.parent)
 		return
 	}
 
@@ -683,14 +753,153 @@ func (ov *ObjectivesView) resumeObjective(objective *core.Objective) {
 	manager := ov.app.GetObjectiveManager()
 
 	_, err := manager.ResumeObjective(ctx, objective.ID)
+	if errors.Is(err, core.ErrWIPLimitReached) {
+		ov.confirmWIPOverride(err, func() error {
+			_, err := manager.ResumeObjective(ctx, objective.ID, core.StartOptions{OverrideWIPLimit: true, Reason: "resumed from the objectives view"})
+			return err
+		})
+		return
+	}
 	if err != nil {
-		dialog.ShowError(err, ov.parent)
+		showCodedError(err, ov.parent)
 		return
 	}
 
 	ov.loadObjectives()
 }
 
+// buildRunButton offers to run the objective in the background, unless it is
+// running already.
+func (ov *ObjectivesView) buildRunButton(objective *core.Objective) *widget.Button {
+	runButton := widget.NewButtonWithIcon("Run", theme.MediaFastForwardIcon(), func() {
+		ov.runObjective(objective)
+	})
+	if ov.app.IsObjectiveRunning(objective.ID) {
+		runButton.Disable()
+	}
+	return runButton
+}
+
+// buildExecutionButton opens the live view of the objective's execution.
+func (ov *ObjectivesView) buildExecutionButton(objective *core.Objective) *widget.Button {
+	return widget.NewButtonWithIcon("Execution", theme.VisibilityIcon(), func() {
+		ov.app.ShowExecution(objective)
+	})
+}
+
+// runObjective starts running the objective through the learning loop and
+// opens the view that follows its execution.
+func (ov *ObjectivesView) runObjective(objective *core.Objective) {
+	err := ov.app.RunObjective(objective.ID)
+	if errors.Is(err, core.ErrWIPLimitReached) {
+		ov.confirmWIPOverride(err, func() error {
+			if err := ov.app.RunObjective(objective.ID, core.StartOptions{OverrideWIPLimit: true, Reason: "run from the objectives view"}); err != nil {
+				return err
+			}
+			ov.app.ShowExecution(objective)
+			return nil
+		})
+		return
+	}
+	if err != nil {
+		showCodedError(err, ov.parent)
+		return
+	}
+
+	ov.app.ShowExecution(objective)
+	ov.loadObjectives()
+}
+
+// addTimeBoxInfo adds the objective's time box and the time it has used to
+// the details grid.
+func (ov *ObjectivesView) addTimeBoxInfo(infoGrid *fyne.Container, objective *core.Objective) {
+	timeBox := "None"
+	if limit := ov.app.GetObjectiveManager().TimeBoxes().For(objective); limit > 0 {
+		timeBox = limit.String()
+		if objective.MaxExecutionTime == 0 {
+			timeBox += " (priority default)"
+		}
+	}
+	infoGrid.Add(widget.NewLabel("Time Box:"))
+	infoGrid.Add(widget.NewLabel(timeBox))
+
+	if objective.ActiveExecutionTime > 0 || objective.ApprovalWaitTime > 0 {
+		infoGrid.Add(widget.NewLabel("Active Time:"))
+		infoGrid.Add(widget.NewLabel(objective.ActiveExecutionTime.Round(time.Second).String()))
+		infoGrid.Add(widget.NewLabel("Approval Wait:"))
+		infoGrid.Add(widget.NewLabel(objective.ApprovalWaitTime.Round(time.Second).String()))
+	}
+
+	if stop := objective.TimeBoxStop(); stop != nil {
+		stopped := fmt.Sprintf("%s of %s at %s", stop.Elapsed.Round(time.Second), stop.Limit, stop.At.Format("2006-01-02 15:04:05"))
+		if stop.TaskID != "" {
+			stopped += fmt.Sprintf(" (task %s)", stop.TaskID)
+		}
+		infoGrid.Add(widget.NewLabel("Time Box Exceeded:"))
+		infoGrid.Add(widget.NewLabel(stopped))
+	}
+}
+
+// buildTimeBoxActions offers the choices for an objective paused by its
+// time box: extend it, resume with a fresh one, or abandon the objective.
+func (ov *ObjectivesView) buildTimeBoxActions(objective *core.Objective) *fyne.Container {
+	extendButton := widget.NewButtonWithIcon("Extend", theme.ContentAddIcon(), func() {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("e.g. 30m")
+		dialog.ShowForm("Extend Time Box", "Extend", "Cancel",
+			[]*widget.FormItem{widget.NewFormItem("Extra time", entry)},
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				extension, err := time.ParseDuration(strings.TrimSpace(entry.Text))
+				if err != nil {
+					showCodedError(fmt.Errorf("invalid extension %q: %w", entry.Text, err), ov.parent)
+					return
+				}
+				ov.resolveTimeBox(objective, core.TimeBoxExtend, extension)
+			}, ov.parent)
+	})
+	resumeButton := widget.NewButtonWithIcon("Resume", theme.MediaPlayIcon(), func() {
+		ov.resolveTimeBox(objective, core.TimeBoxResume, 0)
+	})
+	abandonButton := widget.NewButtonWithIcon("Abandon", theme.CancelIcon(), func() {
+		dialog.ShowConfirm("Abandon Objective", "Mark this objective as failed?", func(confirmed bool) {
+			if confirmed {
+				ov.resolveTimeBox(objective, core.TimeBoxAbandon, 0)
+			}
+		}, ov.parent)
+	})
+	return container.NewHBox(extendButton, resumeButton, abandonButton)
+}
+
+// resolveTimeBox applies the user's answer to an objective paused by its time box.
+func (ov *ObjectivesView) resolveTimeBox(objective *core.Objective, resolution core.TimeBoxResolution, extension time.Duration) {
+	_, err := ov.app.GetObjectiveManager().ResolveTimeBox(ov.app.GetContext(), objective.ID, resolution, extension)
+	if err != nil {
+		showCodedError(err, ov.parent)
+		return
+	}
+
+	ov.loadObjectives()
+}
+
+// confirmWIPOverride explains a reached work-in-progress limit and runs
+// override if the user chooses to go over it anyway.
+func (ov *ObjectivesView) confirmWIPOverride(limitErr error, override func() error) {
+	message := limitErr.Error() + "\n\nStart it anyway? The exception will be recorded."
+	dialog.ShowConfirm("Work-in-Progress Limit Reached", message, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if err := override(); err != nil {
+			showCodedError(err, ov.parent)
+			return
+		}
+		ov.loadObjectives()
+	}, ov.parent)
+}
+
 // startAutoRefresh begins the automatic refresh timer for real-time updates.
 func (ov *ObjectivesView) startAutoRefresh() {
 	go func() {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -11,6 +12,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
 )
 
 // StatusView provides a dashboard showing system status and activity
@@ -28,6 +30,7 @@ type StatusView struct {
 	activityCard       *widget.Card
 	budgetCard         *widget.Card
 	dataStatsCard      *widget.Card
+	calibrationCard    *widget.Card
 	quickActionsCard   *widget.Card
 	recentEventsCard   *widget.Card
 
@@ -47,6 +50,13 @@ func NewStatusView(app *App, window fyne.Window) *StatusView {
 	sv.createUI()
 	sv.loadStatus()
 
+	// Show budget alerts as soon as spending anywhere in the app crosses a threshold
+	if budget, err := budgetManager(app); err == nil {
+		budget.OnAlert(func(llm.AlertInfo) {
+			fyne.Do(sv.loadBudgetStatus)
+		})
+	}
+
 	return sv
 }
 
@@ -55,7 +65,8 @@ func (sv *StatusView) createUI() {
 	// Create header controls
 	sv.refreshBtn = widget.NewButton("Refresh", sv.onRefresh)
 
-	sv.autoRefreshCheck = widget.NewCheck("Auto-refresh (30s)", sv.onAutoRefreshToggle)
+	refreshLabel := fmt.Sprintf("Auto-refresh (%s)", sv.app.GetConfig().Profile.RefreshInterval())
+	sv.autoRefreshCheck = widget.NewCheck(refreshLabel, sv.onAutoRefreshToggle)
 	sv.autoRefreshCheck.SetChecked(false)
 
 	headerContainer := container.NewHBox(
@@ -77,6 +88,7 @@ func (sv *StatusView) createUI() {
 
 	middleRow := container.NewHBox(
 		sv.dataStatsCard,
+		sv.calibrationCard,
 		sv.quickActionsCard,
 	)
 
@@ -102,6 +114,7 @@ func (sv *StatusView) createDashboardCards() {
 	sv.activityCard = sv.createActivityCard()
 	sv.budgetCard = sv.createBudgetCard()
 	sv.dataStatsCard = sv.createDataStatsCard()
+	sv.calibrationCard = sv.createCalibrationCard()
 	sv.quickActionsCard = sv.createQuickActionsCard()
 	sv.recentEventsCard = sv.createRecentEventsCard()
 }
@@ -126,10 +139,8 @@ func (sv *StatusView) createActivityCard() *widget.Card {
 
 // createBudgetCard creates the budget usage card
 func (sv *StatusView) createBudgetCard() *widget.Card {
-	// Note: This shows placeholder data until BudgetManager is integrated into App
 	content := container.NewVBox(
-		widget.NewLabel("Budget tracking not yet integrated"),
-		widget.NewLabel("Will show LLM usage costs when available"),
+		widget.NewLabel("Loading budget status..."),
 	)
 
 	return widget.NewCard("Budget Usage", "", content)
@@ -144,6 +155,15 @@ func (sv *StatusView) createDataStatsCard() *widget.Card {
 	return widget.NewCard("Data Statistics", "", content)
 }
 
+// createCalibrationCard creates the estimate calibration card
+func (sv *StatusView) createCalibrationCard() *widget.Card {
+	content := container.NewVBox(
+		widget.NewLabel("Loading estimate calibration..."),
+	)
+
+	return widget.NewCard("Estimate Calibration", "", content)
+}
+
 // createQuickActionsCard creates the quick actions card
 func (sv *StatusView) createQuickActionsCard() *widget.Card {
 	// Create action buttons
@@ -177,6 +197,7 @@ func (sv *StatusView) loadStatus() {
 	sv.loadActivity()
 	sv.loadBudgetStatus()
 	sv.loadDataStats()
+	sv.loadCalibration()
 	sv.loadRecentEvents()
 }
 
@@ -204,14 +225,35 @@ func (sv *StatusView) loadSystemHealth() {
 
 	uptimeStr := sv.getUptimeString()
 
+	// Ethical evaluation health comes from today's recorded evaluation failures
+	ethicsStatus := "✅ OK"
+	var ethicsWarning string
+	failures, err := sv.app.GetRollupManager().EvaluationFailuresOnDay(sv.app.GetContext(), time.Now())
+	if err == nil {
+		total := 0
+		for _, count := range failures {
+			total += count
+		}
+		if total > 0 {
+			ethicsStatus = fmt.Sprintf("⚠️ %d failures today", total)
+		}
+		ethicsWarning = core.EvaluationFailureWarning(failures)
+	}
+
 	content := container.NewVBox(
 		container.NewHBox(widget.NewLabel("Data Directory:"), widget.NewLabel(dataDirStatus)),
 		container.NewHBox(widget.NewLabel("Storage Engine:"), widget.NewLabel(storageStatus)),
+		container.NewHBox(widget.NewLabel("Ethical Evaluation:"), widget.NewLabel(ethicsStatus)),
 		container.NewHBox(widget.NewLabel("Uptime:"), widget.NewLabel(uptimeStr)),
-		widget.NewSeparator(),
-		widget.NewLabel(fmt.Sprintf("Data Path: %s", dataDir)),
-		widget.NewLabel(fmt.Sprintf("Config Path: %s", sv.app.GetConfigPath())),
 	)
+	if ethicsWarning != "" {
+		warning := widget.NewLabel("⚠️ " + ethicsWarning)
+		warning.Wrapping = fyne.TextWrapWord
+		content.Add(warning)
+	}
+	content.Add(widget.NewSeparator())
+	content.Add(widget.NewLabel(fmt.Sprintf("Data Path: %s", dataDir)))
+	content.Add(widget.NewLabel(fmt.Sprintf("Config Path: %s", sv.app.GetConfigPath())))
 
 	sv.systemHealthCard.SetContent(content)
 }
@@ -220,39 +262,30 @@ func (sv *StatusView) loadSystemHealth() {
 func (sv *StatusView) loadActivity() {
 	ctx := sv.app.GetContext()
 
-	// Get methods count
-	methodManager := sv.app.GetMethodManager()
-	methods, err := methodManager.ListMethods(ctx, core.MethodFilter{})
-	methodCount := 0
-	if err == nil {
-		methodCount = len(methods)
+	// Counts come from maintained rollups rather than listing every node
+	rollupManager := sv.app.GetRollupManager()
+	nodeCounts, err := rollupManager.NodeCounts(ctx)
+	if err != nil {
+		nodeCounts = map[string]int{}
 	}
+	methodCount := nodeCounts["method"]
+	objectiveCount := nodeCounts["objective"]
 
-	// Get goals count
-	goalManager := sv.app.GetGoalManager()
-	goals, err := goalManager.ListGoals(ctx, core.GoalFilter{})
-	goalCount := 0
-	if err == nil {
-		goalCount = len(goals)
+	goalCounts, err := rollupManager.GoalStatusCounts(ctx)
+	if err != nil {
+		goalCounts = map[core.GoalStatus]int{}
 	}
-
-	// Get objectives count
-	objectiveManager := sv.app.GetObjectiveManager()
-	objectives, err := objectiveManager.ListObjectives(ctx, core.ObjectiveFilter{})
-	objectiveCount := 0
-	if err == nil {
-		objectiveCount = len(objectives)
+	goalCount := 0
+	for _, count := range goalCounts {
+		goalCount += count
 	}
 
 	// Calculate activity status
-	activeGoalsCount := 0
-	completedGoalsCount := 0
-	for _, goal := range goals {
-		if goal.Status == core.GoalStatusActive {
-			activeGoalsCount++
-		} else if goal.Status == core.GoalStatusCompleted {
-			completedGoalsCount++
-		}
+	activeGoalsCount := goalCounts[core.GoalStatusActive]
+	completedGoalsCount := goalCounts[core.GoalStatusCompleted]
+	completionRate := 0.0
+	if goalCount > 0 {
+		completionRate = (float64(completedGoalsCount) / float64(goalCount)) * 100
 	}
 
 	content := container.NewVBox(
@@ -260,54 +293,77 @@ func (sv *StatusView) loadActivity() {
 		container.NewHBox(widget.NewLabel("Total Goals:"), widget.NewLabel(fmt.Sprintf("%d", goalCount))),
 		container.NewHBox(widget.NewLabel("Objectives:"), widget.NewLabel(fmt.Sprintf("%d", objectiveCount))),
 		container.NewHBox(widget.NewLabel("Methods:"), widget.NewLabel(fmt.Sprintf("%d", methodCount))),
-		widget.NewSeparator(),
-		NewProgressBar("Goal Completion", sv.calculateGoalCompletionRate(goals)).Card,
 	)
 
-	sv.activityCard.SetContent(content)
-}
-
-// loadBudgetStatus loads budget and usage information
-func (sv *StatusView) loadBudgetStatus() {
-	// TODO: Integrate BudgetManager from pkg/llm when added to App
-	// For now, show placeholder information
+	// Work in progress against the limit, with a warning near the cap
+	if wip, err := sv.app.GetObjectiveManager().WIPStatus(ctx); err == nil && wip.Total.Limit > 0 {
+		content.Add(container.NewHBox(widget.NewLabel("In Progress:"), widget.NewLabel(formatWIPUsage(wip.Total))))
+		if wip.Total.NearLimit() {
+			content.Add(widget.NewLabel("⚠️ Near the work-in-progress limit; finish or pause work before starting more"))
+		}
+	}
 
-	dailyUsageData := map[string]float64{
-		"OpenAI":    2.50,
-		"Anthropic": 1.75,
-		"Local":     0.00,
+	// The unattended work loop runs in a process of its own
+	if agent, err := core.LatestWorkLoopStatus(sv.app.GetConfig().DataDir); err == nil && agent != nil {
+		summary := widget.NewLabel("Agent: " + agent.Summary(time.Now()))
+		summary.Wrapping = fyne.TextWrapWord
+		content.Add(summary)
 	}
 
-	// Create simple spending chart
-	spendingChart := NewSimplePieChart("Daily Spending", dailyUsageData)
+	content.Add(widget.NewSeparator())
+	content.Add(NewProgressBar("Goal Completion", completionRate).Card)
 
-	// Create usage metrics
-	totalSpent := 0.0
-	for _, amount := range dailyUsageData {
-		totalSpent += amount
-	}
+	sv.activityCard.SetContent(content)
+}
 
-	dailyLimit := 5.0
-	usagePercentage := (totalSpent / dailyLimit) * 100
+// formatWIPUsage formats work in progress as "n / limit", marking a reached limit.
+func formatWIPUsage(usage core.WIPUsage) string {
+	text := fmt.Sprintf("%d / %d", usage.InProgress, usage.Limit)
+	if usage.AtLimit() {
+		text += " (at limit)"
+	}
+	return text
+}
 
-	usageBar := NewProgressBar("Daily Budget", usagePercentage)
+// loadBudgetStatus loads spending against each budget limit and the latest
+// budget alert
+func (sv *StatusView) loadBudgetStatus() {
+	budget, err := budgetManager(sv.app)
+	if err != nil {
+		sv.budgetCard.SetContent(widget.NewLabel(fmt.Sprintf("Budget unavailable: %v", err)))
+		return
+	}
+	status := budget.GetBudgetStatus()
 
-	content := container.NewVBox(
-		container.NewHBox(
-			widget.NewLabel("Today's Spending:"),
-			widget.NewLabel(fmt.Sprintf("$%.2f", totalSpent)),
-		),
-		container.NewHBox(
-			widget.NewLabel("Daily Limit:"),
-			widget.NewLabel(fmt.Sprintf("$%.2f", dailyLimit)),
-		),
-		usageBar.Card,
-		spendingChart.Card,
-	)
+	content := container.NewVBox()
+	for _, name := range []string{"daily", "weekly", "monthly"} {
+		period := status.Periods[name]
+		if period == nil {
+			continue
+		}
+		label := strings.ToUpper(name[:1]) + name[1:]
+		content.Add(container.NewHBox(
+			widget.NewLabel(label+" Spending:"),
+			widget.NewLabel(fmt.Sprintf("$%.2f of $%.2f", period.Usage, period.Limit)),
+		))
+		content.Add(NewProgressBar(label+" Budget", period.Percentage).Card)
+	}
+	if len(status.Periods) == 0 {
+		content.Add(widget.NewLabel("No budget limits configured"))
+	}
+	content.Add(widget.NewLabel(formatBudgetAlert(status.LatestAlert)))
 
 	sv.budgetCard.SetContent(content)
 }
 
+// formatBudgetAlert describes the latest budget alert for the status tab.
+func formatBudgetAlert(alert *llm.AlertInfo) string {
+	if alert == nil {
+		return "No budget alerts this period"
+	}
+	return fmt.Sprintf("Latest alert (%s): %s", alert.Timestamp.Format("Jan 2 15:04"), alert.Message)
+}
+
 // loadDataStats loads data storage statistics
 func (sv *StatusView) loadDataStats() {
 	config := sv.app.GetConfig()
@@ -345,6 +401,56 @@ func (sv *StatusView) loadDataStats() {
 	sv.dataStatsCard.SetContent(content)
 }
 
+// loadCalibration summarizes how far each method's estimates are adjusted
+// by its execution history
+func (sv *StatusView) loadCalibration() {
+	ctx := sv.app.GetContext()
+	methodManager := sv.app.GetMethodManager()
+
+	summary, err := methodManager.GetCalibrationSummary(ctx)
+	if err != nil {
+		sv.calibrationCard.SetContent(widget.NewLabel(fmt.Sprintf("Error loading calibration: %v", err)))
+		return
+	}
+	if len(summary) == 0 {
+		sv.calibrationCard.SetContent(widget.NewLabel("No executions recorded yet"))
+		return
+	}
+
+	calibrated := 0
+	for _, accuracy := range summary {
+		if accuracy.Calibration.Applied {
+			calibrated++
+		}
+	}
+
+	content := container.NewVBox(
+		container.NewHBox(widget.NewLabel("Methods Tracked:"), widget.NewLabel(fmt.Sprintf("%d", len(summary)))),
+		container.NewHBox(widget.NewLabel("Calibrated:"), widget.NewLabel(fmt.Sprintf("%d", calibrated))),
+		widget.NewSeparator(),
+	)
+
+	// Show the methods with the most history
+	for i, accuracy := range summary {
+		if i == 5 {
+			break
+		}
+		name := accuracy.MethodID
+		if method, err := methodManager.GetMethod(ctx, accuracy.MethodID); err == nil {
+			name = method.Name
+		}
+
+		adjustment := "not adjusted"
+		if accuracy.Calibration.Applied {
+			adjustment = fmt.Sprintf("%+.0f%%", (accuracy.Calibration.Factor-1)*100)
+		}
+		content.Add(widget.NewLabel(fmt.Sprintf("%s: %s, %d runs, %.0f%% error",
+			name, adjustment, accuracy.Samples, accuracy.MeanAbsolutePercentError)))
+	}
+
+	sv.calibrationCard.SetContent(content)
+}
+
 // loadRecentEvents loads recent system events
 func (sv *StatusView) loadRecentEvents() {
 	// Create sample recent events
@@ -494,7 +600,8 @@ func (sv *StatusView) onClearCache() {
 func (sv *StatusView) startAutoRefresh() {
 	sv.stopAutoRefresh() // Stop any existing timer
 
-	sv.refreshTimer = time.NewTicker(30 * time.Second)
+	// The operating profile sets how often rollups are re-read
+	sv.refreshTimer = time.NewTicker(sv.app.GetConfig().Profile.RefreshInterval())
 
 	go func() {
 		ticker := sv.refreshTimer
@@ -0,0 +1,192 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+)
+
+// panelSuggestions is how many suggestions the panel shows.
+const panelSuggestions = 3
+
+// availableTimeChoices are the choices of the panel's time selector.
+var availableTimeChoices = []string{"any", "15m", "30m", "1h", "2h", "4h"}
+
+// buildSuggestionsPanel creates the "Suggested next" panel shown above the
+// objectives list.
+func (ov *ObjectivesView) buildSuggestionsPanel() {
+	ov.suggestionsBox = container.NewVBox()
+
+	ov.timeSelect = widget.NewSelect(availableTimeChoices, func(string) {
+		ov.refreshSuggestions()
+	})
+	ov.energySelect = widget.NewSelect([]string{
+		string(core.EnergyLow),
+		string(core.EnergyNormal),
+		string(core.EnergyHigh),
+	}, func(string) {
+		ov.refreshSuggestions()
+	})
+	weightsButton := widget.NewButtonWithIcon("Weights", theme.SettingsIcon(), func() {
+		ov.showSuggestionWeightsDialog()
+	})
+
+	controls := container.NewHBox(
+		widget.NewLabel("Time:"), ov.timeSelect,
+		widget.NewLabel("Energy:"), ov.energySelect,
+		weightsButton,
+	)
+	ov.suggestionsCard = widget.NewCard("Suggested next", "", container.NewVBox(controls, ov.suggestionsBox))
+
+	ov.timeSelect.SetSelected("any")
+	ov.energySelect.SetSelected(string(core.EnergyNormal))
+}
+
+// suggestOptions returns the suggestion options for the panel's selections.
+func (ov *ObjectivesView) suggestOptions() core.SuggestOptions {
+	opts := core.DefaultSuggestOptions()
+	opts.MaxSuggestions = panelSuggestions
+	if available, err := time.ParseDuration(ov.timeSelect.Selected); err == nil {
+		opts.AvailableTime = available
+	}
+	if level, err := core.ParseEnergyLevel(ov.energySelect.Selected); err == nil {
+		opts.EnergyLevel = level
+	}
+	return opts
+}
+
+// refreshSuggestions recomputes the suggestions for the panel's selections.
+func (ov *ObjectivesView) refreshSuggestions() {
+	if ov.suggestionsBox == nil || ov.timeSelect == nil || ov.energySelect == nil {
+		return
+	}
+
+	ov.suggestionsBox.RemoveAll()
+	report, err := ov.app.GetObjectiveManager().SuggestNext(ov.app.GetContext(), ov.suggestOptions())
+	if err != nil {
+		ov.suggestionsBox.Add(widget.NewLabel(fmt.Sprintf("Could not suggest objectives: %v", err)))
+		return
+	}
+	if len(report.Suggestions) == 0 {
+		ov.suggestionsBox.Add(widget.NewLabel("Nothing can be started right now"))
+	}
+	for i, suggestion := range report.Suggestions {
+		ov.suggestionsBox.Add(ov.buildSuggestionRow(i+1, suggestion))
+	}
+	if report.Blocked > 0 || report.Deferred > 0 {
+		ov.suggestionsBox.Add(widget.NewLabelWithStyle(
+			fmt.Sprintf("Not shown: %d blocked, %d held back by work-in-progress limits", report.Blocked, report.Deferred),
+			fyne.TextAlignLeading, fyne.TextStyle{Italic: true}))
+	}
+}
+
+// buildSuggestionRow shows one suggestion with its main reasons.
+func (ov *ObjectivesView) buildSuggestionRow(rank int, suggestion *core.Suggestion) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle(fmt.Sprintf("%d. %s", rank, suggestion.Objective.Title), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	reason := widget.NewLabel(fmt.Sprintf("%.2f · %s", suggestion.Score, suggestion.Explain()))
+	reason.Truncation = fyne.TextTruncateEllipsis
+
+	whyButton := widget.NewButtonWithIcon("", theme.InfoIcon(), func() {
+		ov.showSuggestionBreakdown(suggestion)
+	})
+	openButton := widget.NewButton("Open", func() {
+		ov.showObjectiveDetails(suggestion.Objective)
+	})
+	return container.NewBorder(nil, nil, title, container.NewHBox(whyButton, openButton), reason)
+}
+
+// showSuggestionBreakdown shows how each factor contributed to a suggestion's score.
+func (ov *ObjectivesView) showSuggestionBreakdown(suggestion *core.Suggestion) {
+	grid := container.NewGridWithColumns(3,
+		widget.NewLabelWithStyle("Factor", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Weight × value", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Why", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+	)
+	for _, component := range suggestion.Components {
+		detail := component.Detail
+		if !component.Known {
+			detail += " (no data)"
+		}
+		grid.Add(widget.NewLabel(component.Factor))
+		grid.Add(widget.NewLabel(fmt.Sprintf("%.2f × %.2f = %.3f", component.Weight, component.Value, component.Contribution)))
+		grid.Add(widget.NewLabel(detail))
+	}
+
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("Score %.2f: the weighted average of the factors below.", suggestion.Score)),
+		grid,
+	)
+	d := dialog.NewCustom(fmt.Sprintf("Why: %s", suggestion.Objective.Title), "Close", content, ov.parent)
+	d.Resize(fyne.NewSize(700, 360))
+	d.Show()
+}
+
+// showSuggestionWeightsDialog lets the user tune the factor weights, previewing
+// the resulting suggestions as the sliders move, and saves them on confirm.
+func (ov *ObjectivesView) showSuggestionWeightsDialog() {
+	manager := ov.app.GetObjectiveManager()
+	weights := manager.SuggestionWeights()
+	preview := container.NewVBox()
+
+	updatePreview := func() {
+		preview.RemoveAll()
+		opts := ov.suggestOptions()
+		opts.Weights = &weights
+		report, err := manager.SuggestNext(ov.app.GetContext(), opts)
+		if err != nil {
+			preview.Add(widget.NewLabel(err.Error()))
+			return
+		}
+		for i, suggestion := range report.Suggestions {
+			preview.Add(widget.NewLabel(fmt.Sprintf("%d. %s (%.2f)", i+1, suggestion.Objective.Title, suggestion.Score)))
+		}
+		if len(report.Suggestions) == 0 {
+			preview.Add(widget.NewLabel("Nothing can be started right now"))
+		}
+	}
+
+	form := container.NewGridWithColumns(3)
+	for _, factor := range core.SuggestionFactors {
+		factor := factor
+		value, _ := weights.Get(factor)
+		valueLabel := widget.NewLabel(fmt.Sprintf("%.2f", value))
+		slider := widget.NewSlider(0, 1)
+		slider.Step = 0.05
+		slider.SetValue(value)
+		slider.OnChanged = func(value float64) {
+			valueLabel.SetText(fmt.Sprintf("%.2f", value))
+			weights, _ = weights.With(factor, value)
+			updatePreview()
+		}
+		form.Add(widget.NewLabel(factor))
+		form.Add(slider)
+		form.Add(valueLabel)
+	}
+	updatePreview()
+
+	content := container.NewVBox(
+		form,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Preview", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		preview,
+	)
+	d := dialog.NewCustomConfirm("Suggestion Weights", "Save", "Cancel", content, func(save bool) {
+		if !save {
+			return
+		}
+		if err := ov.app.UpdateSuggestionWeights(weights); err != nil {
+			dialog.ShowError(err, ov.parent)
+			return
+		}
+		ov.refreshSuggestions()
+	}, ov.parent)
+	d.Resize(fyne.NewSize(560, 480))
+	d.Show()
+}
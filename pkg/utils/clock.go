@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a source of time. Components with time-dependent behavior take a
+// Clock instead of calling time.Now directly, so that tests can control time
+// with a FakeClock rather than sleeping.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// Since returns the time elapsed since t
+	Since(t time.Time) time.Duration
+
+	// NewTimer creates a timer that fires once after d
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker creates a ticker that fires every d
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the Clock counterpart of time.Timer.
+type Timer interface {
+	// C returns the channel the timer fires on
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing; it reports whether it was active
+	Stop() bool
+
+	// Reset makes the timer fire after d; it reports whether it was active
+	Reset(d time.Duration) bool
+}
+
+// Ticker is the Clock counterpart of time.Ticker.
+type Ticker interface {
+	// C returns the channel the ticks are delivered on
+	C() <-chan time.Time
+
+	// Stop turns the ticker off
+	Stop()
+
+	// Reset changes the ticker's period to d
+	Reset(d time.Duration)
+}
+
+// RealClock returns the clock backed by the system time.
+func RealClock() Clock {
+	return realClock{}
+}
+
+// ClockOrReal returns clock, or the real clock when clock is nil. Constructors
+// use it so that leaving a Clock option unset means system time.
+func ClockOrReal(clock Clock) Clock {
+	if clock == nil {
+		return RealClock()
+	}
+	return clock
+}
+
+// realClock implements Clock with the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                  { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration { return time.Since(t) }
+func (realClock) NewTimer(d time.Duration) Timer  { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct{ timer *time.Timer }
+
+func (t realTimer) C() <-chan time.Time        { return t.timer.C }
+func (t realTimer) Stop() bool                 { return t.timer.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.timer.Reset(d) }
+
+type realTicker struct{ ticker *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time   { return t.ticker.C }
+func (t realTicker) Stop()                 { t.ticker.Stop() }
+func (t realTicker) Reset(d time.Duration) { t.ticker.Reset(d) }
+
+// FakeClock is a Clock whose time only moves when told to. Timers and tickers
+// created from it fire as Advance or Set moves time past their deadlines,
+// which makes midnight boundaries, DST changes and expiry deterministic to test.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFakeClock creates a fake clock set to start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the fake current time.
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+// Since returns the fake time elapsed since t.
+func (fc *FakeClock) Since(t time.Time) time.Duration {
+	return fc.Now().Sub(t)
+}
+
+// NewTimer creates a timer that fires once the fake time reaches now+d.
+// A timer with d <= 0 fires immediately.
+func (fc *FakeClock) NewTimer(d time.Duration) Timer {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	w := &fakeWaiter{clock: fc, c: make(chan time.Time, 1)}
+	fc.schedule(w, d)
+	return w
+}
+
+// NewTicker creates a ticker that fires each time the fake time passes
+// another multiple of d. Like time.NewTicker, it panics if d <= 0.
+func (fc *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("utils: non-positive interval for FakeClock.NewTicker")
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	w := &fakeWaiter{clock: fc, c: make(chan time.Time, 1), period: d}
+	fc.schedule(w, d)
+	return fakeTicker{w}
+}
+
+// Advance moves the fake time forward by d, firing due timers and tickers.
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	target := fc.now.Add(d)
+	fc.mu.Unlock()
+	fc.Set(target)
+}
+
+// Set moves the fake time to t, firing timers and tickers that fall due in
+// deadline order. Moving time backwards fires nothing.
+func (fc *FakeClock) Set(t time.Time) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	for {
+		due := fc.nextDue(t)
+		if due == nil {
+			break
+		}
+		fc.now = due.when
+		due.fire()
+	}
+	fc.now = t
+}
+
+// PendingTimers returns how many timers and tickers are waiting to fire.
+// Tests use it to wait until a component has set up its timers.
+func (fc *FakeClock) PendingTimers() int {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return len(fc.waiters)
+}
+
+// nextDue returns the earliest waiter due at or before t. Callers must hold fc.mu.
+func (fc *FakeClock) nextDue(t time.Time) *fakeWaiter {
+	sort.SliceStable(fc.waiters, func(i, j int) bool { return fc.waiters[i].when.Before(fc.waiters[j].when) })
+	if len(fc.waiters) == 0 || fc.waiters[0].when.After(t) {
+		return nil
+	}
+	return fc.waiters[0]
+}
+
+// schedule (re)arms a waiter to fire after d. Callers must hold fc.mu.
+func (fc *FakeClock) schedule(w *fakeWaiter, d time.Duration) bool {
+	wasActive := fc.unschedule(w)
+	w.when = fc.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		w.send(fc.now)
+		return wasActive
+	}
+	fc.waiters = append(fc.waiters, w)
+	return wasActive
+}
+
+// unschedule removes a waiter, reporting whether it was waiting. Callers must hold fc.mu.
+func (fc *FakeClock) unschedule(w *fakeWaiter) bool {
+	for i, waiter := range fc.waiters {
+		if waiter == w {
+			fc.waiters = append(fc.waiters[:i], fc.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeWaiter is a timer, or a ticker when period is set.
+type fakeWaiter struct {
+	clock  *FakeClock
+	c      chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+// fire delivers a tick and re-arms tickers. Callers must hold clock.mu.
+func (w *fakeWaiter) fire() {
+	w.send(w.when)
+	if w.period > 0 {
+		w.when = w.when.Add(w.period)
+	} else {
+		w.clock.unschedule(w)
+	}
+}
+
+// send delivers t without blocking; like a real ticker, ticks are dropped
+// while the previous one has not been received.
+func (w *fakeWaiter) send(t time.Time) {
+	select {
+	case w.c <- t:
+	default:
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.unschedule(w)
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.schedule(w, d)
+}
+
+// fakeTicker adapts a periodic fakeWaiter to the Ticker interface.
+type fakeTicker struct{ w *fakeWaiter }
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.c }
+func (t fakeTicker) Stop()               { t.w.Stop() }
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("utils: non-positive interval for Ticker.Reset")
+	}
+	t.w.clock.mu.Lock()
+	defer t.w.clock.mu.Unlock()
+	t.w.period = d
+	t.w.clock.schedule(t.w, d)
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock_NowAndSince(t *testing.T) {
+	start := time.Date(2026, 3, 8, 1, 30, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	if !clock.Now().Equal(start) {
+		t.Errorf("Expected %v, got %v", start, clock.Now())
+	}
+
+	clock.Advance(90 * time.Minute)
+	if got := clock.Since(start); got != 90*time.Minute {
+		t.Errorf("Expected 90m since start, got %v", got)
+	}
+
+	later := start.Add(48 * time.Hour)
+	clock.Set(later)
+	if !clock.Now().Equal(later) {
+		t.Errorf("Expected %v after Set, got %v", later, clock.Now())
+	}
+}
+
+func TestFakeClock_Timer(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	timer := clock.NewTimer(time.Minute)
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("Timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case fired := <-timer.C():
+		if !fired.Equal(start.Add(time.Minute)) {
+			t.Errorf("Expected the timer to fire at its deadline, got %v", fired)
+		}
+	default:
+		t.Fatal("Timer did not fire")
+	}
+	if clock.PendingTimers() != 0 {
+		t.Errorf("Expected a fired timer to be removed, %d pending", clock.PendingTimers())
+	}
+
+	// A reset timer fires again; a stopped one does not
+	if timer.Reset(time.Second) {
+		t.Error("Expected Reset of a fired timer to report it inactive")
+	}
+	if !timer.Stop() {
+		t.Error("Expected Stop of a pending timer to report it active")
+	}
+	clock.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("Stopped timer fired")
+	default:
+	}
+}
+
+func TestFakeClock_Ticker(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	ticker := clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	var ticks []time.Time
+	for i := 0; i < 3; i++ {
+		clock.Advance(10 * time.Second)
+		select {
+		case tick := <-ticker.C():
+			ticks = append(ticks, tick)
+		default:
+			t.Fatalf("Ticker did not fire on tick %d", i+1)
+		}
+	}
+	for i, tick := range ticks {
+		if want := start.Add(time.Duration(i+1) * 10 * time.Second); !tick.Equal(want) {
+			t.Errorf("Tick %d: expected %v, got %v", i+1, want, tick)
+		}
+	}
+
+	// Ticks that are not received are dropped rather than queued
+	clock.Advance(time.Minute)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Error("Expected missed ticks to be dropped")
+	default:
+	}
+
+	ticker.Reset(time.Hour)
+	clock.Advance(59 * time.Minute)
+	select {
+	case <-ticker.C():
+		t.Error("Expected the reset ticker to use its new period")
+	default:
+	}
+}
+
+func TestFakeClock_SetFiresAtDeadlines(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	late := clock.NewTimer(2 * time.Hour)
+	early := clock.NewTimer(time.Hour)
+
+	clock.Set(clock.Now().Add(3 * time.Hour))
+	earlyAt, lateAt := <-early.C(), <-late.C()
+	if !earlyAt.Before(lateAt) {
+		t.Errorf("Expected each timer to fire at its deadline, got %v and %v", earlyAt, lateAt)
+	}
+
+	// Moving backwards fires nothing
+	timer := clock.NewTimer(time.Minute)
+	clock.Set(clock.Now().Add(-time.Hour))
+	select {
+	case <-timer.C():
+		t.Error("Timer fired when time moved backwards")
+	default:
+	}
+}
+
+func TestClockOrReal(t *testing.T) {
+	if _, ok := ClockOrReal(nil).(realClock); !ok {
+		t.Error("Expected the real clock for nil")
+	}
+	fake := NewFakeClock(time.Now())
+	if ClockOrReal(fake) != Clock(fake) {
+		t.Error("Expected the given clock to be kept")
+	}
+}
@@ -15,7 +15,7 @@
 //   - JSON formatted output for structured parsing
 //   - Configurable destinations: console, file, or both
 //   - Automatic log file rotation with size and age limits
-//   - Contextual logging with goal, objective, and method IDs
+//   - Contextual logging with goal, objective, plan, and method IDs
 //   - Component-based logger management
 //
 // Example usage:
@@ -39,6 +39,12 @@
 //	    "details":    "Connection failed",
 //	})
 //
+// A LogContext can also travel with a context.Context, so code deep in a call
+// chain logs with the objective and plan IDs attached by its callers:
+//
+//	ctx = utils.WithLogContext(ctx, utils.LogContext{ObjectiveID: "obj-456", PlanID: "plan-789"})
+//	logger.Info(utils.LogContextFrom(ctx), "Task started")
+//
 // # Logger Manager
 //
 // For managing multiple component loggers, use LoggerManager:
@@ -50,6 +56,15 @@
 //	mcpLogger, err := manager.GetLogger("mcp_services")
 //	dbLogger, err := manager.GetLogger("amorphdb")
 //
+// # Clock
+//
+// Components with time-dependent behavior (budget periods, version stamps,
+// scheduling) take a Clock so tests can use a FakeClock instead of sleeping:
+//
+//	clock := utils.NewFakeClock(time.Date(2026, 3, 8, 1, 30, 0, 0, loc))
+//	budget, err := llm.NewBudgetManager(dir, llm.BudgetConfig{Clock: clock}, logger)
+//	clock.Advance(time.Hour) // fires any timers and tickers that fall due
+//
 // # Design Philosophy
 //
 // The utilities in this package follow the AI Work Studio design principles:
@@ -0,0 +1,148 @@
+// Package jsonlog reads and writes logs kept as JSON lines, one record per
+// line, such as the network audit, exchange and LLM audit logs.
+//
+// A Daily log keeps one file per day, named for the date, so old days are
+// pruned by removing their files:
+//
+//	files := jsonlog.Daily{Dir: dir, Prefix: "network-", Suffix: ".jsonl"}
+//	file, err := files.Open(jsonlog.Day(time.Now()))
+//	...
+//	days, err := files.Days()
+//	err = jsonlog.ReadFile(files.Path(days[0]), func(record Record) {
+//	    records = append(records, record)
+//	})
+package jsonlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DayLayout is the date format of a Daily log's file names.
+const DayLayout = "2006-01-02"
+
+// MaxLineBytes is the longest line ReadFile reads.
+const MaxLineBytes = 64 * 1024 * 1024
+
+// Day returns the day t falls on, as a Daily log names it.
+func Day(t time.Time) string {
+	return t.Format(DayLayout)
+}
+
+// Daily names a log's files by day: Prefix, the day, then Suffix, in Dir.
+type Daily struct {
+	Dir    string
+	Prefix string
+	Suffix string
+}
+
+// Path returns the file of the day.
+func (d Daily) Path(day string) string {
+	return filepath.Join(d.Dir, d.Prefix+day+d.Suffix)
+}
+
+// Days lists the days that have a file, oldest first.
+func (d Daily) Days() ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list logs in %s: %w", d.Dir, err)
+	}
+
+	var days []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, d.Prefix) || !strings.HasSuffix(name, d.Suffix) {
+			continue
+		}
+		days = append(days, strings.TrimSuffix(strings.TrimPrefix(name, d.Prefix), d.Suffix))
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// Open opens the day's file for appending, creating it readable only by
+// its owner.
+func (d Daily) Open(day string) (*os.File, error) {
+	file, err := os.OpenFile(d.Path(day), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log for %s: %w", day, err)
+	}
+	return file, nil
+}
+
+// Prune removes the files of the days more than retentionDays before now,
+// and returns how many it removed.
+func (d Daily) Prune(now time.Time, retentionDays int) (int, error) {
+	days, err := d.Days()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := Day(now.AddDate(0, 0, -retentionDays))
+	removed := 0
+	for _, day := range days {
+		if day >= cutoff {
+			continue
+		}
+		if err := os.Remove(d.Path(day)); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove log for %s: %w", day, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Append writes record to w as one line.
+func Append(w io.Writer, record interface{}) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// ReadFile calls fn with each record in the file, oldest first,
+// decompressing a file named ".gz". Lines that do not decode, such as one
+// cut short by a crash, are skipped. A file that does not exist fails with
+// an error matching os.ErrNotExist.
+func ReadFile[T any](path string, fn func(T)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", filepath.Base(path), err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), MaxLineBytes)
+	for scanner.Scan() {
+		var record T
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		fn(record)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
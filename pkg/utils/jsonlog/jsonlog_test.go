@@ -0,0 +1,81 @@
+package jsonlog
+
+import (
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type entry struct {
+	N int `json:"n"`
+}
+
+func TestDaily_AppendReadAndPrune(t *testing.T) {
+	files := Daily{Dir: t.TempDir(), Prefix: "test-", Suffix: ".jsonl"}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, day := range []time.Time{now.AddDate(0, 0, -9), now.AddDate(0, 0, -1), now, now} {
+		file, err := files.Open(Day(day))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if err := Append(file, entry{N: i}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		file.Close()
+	}
+	// Files of other logs, and lines cut short, are skipped
+	os.WriteFile(filepath.Join(files.Dir, "other-2026-03-10.jsonl"), []byte(`{"n": 9}`+"\n"), 0600)
+	file, _ := files.Open(Day(now))
+	file.WriteString(`{"n": 4`)
+	file.Close()
+
+	days, err := files.Days()
+	if err != nil {
+		t.Fatalf("Days failed: %v", err)
+	}
+	if !reflect.DeepEqual(days, []string{"2026-03-01", "2026-03-09", "2026-03-10"}) {
+		t.Errorf("Expected the days oldest first, got %v", days)
+	}
+
+	var read []int
+	if err := ReadFile(files.Path("2026-03-10"), func(e entry) { read = append(read, e.N) }); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !reflect.DeepEqual(read, []int{2, 3}) {
+		t.Errorf("Expected the day's whole records in order, got %v", read)
+	}
+
+	removed, err := files.Prune(now, 7)
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected one day pruned, got %d (%v)", removed, err)
+	}
+	if days, _ := files.Days(); days[0] != "2026-03-09" {
+		t.Errorf("Expected the oldest day removed, got %v", days)
+	}
+}
+
+func TestReadFile_CompressedAndMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotated.jsonl.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	gz := gzip.NewWriter(file)
+	Append(gz, entry{N: 1})
+	gz.Close()
+	file.Close()
+
+	var read []int
+	if err := ReadFile(path, func(e entry) { read = append(read, e.N) }); err != nil || !reflect.DeepEqual(read, []int{1}) {
+		t.Errorf("Expected the compressed record, got %v (%v)", read, err)
+	}
+
+	err = ReadFile(filepath.Join(t.TempDir(), "missing.jsonl"), func(entry) {})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file reported as such, got %v", err)
+	}
+}
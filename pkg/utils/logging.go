@@ -2,6 +2,7 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -61,12 +62,55 @@ type LogConfig struct {
 type LogContext struct {
 	GoalID      string `json:"goal_id,omitempty"`
 	ObjectiveID string `json:"objective_id,omitempty"`
+	PlanID      string `json:"plan_id,omitempty"`
 	MethodID    string `json:"method_id,omitempty"`
 	UserID      string `json:"user_id,omitempty"`
 	SessionID   string `json:"session_id,omitempty"`
 	Component   string `json:"component,omitempty"`
 }
 
+// logContextKey is the context key of a LogContext carried by a context.Context.
+type logContextKey struct{}
+
+// WithLogContext returns a copy of ctx carrying lc, merged over any LogContext
+// ctx already carries, so IDs attached further up the call chain are kept.
+func WithLogContext(ctx context.Context, lc LogContext) context.Context {
+	return context.WithValue(ctx, logContextKey{}, mergeContext(LogContextFrom(ctx), lc))
+}
+
+// LogContextFrom returns the LogContext carried by ctx, or an empty one.
+func LogContextFrom(ctx context.Context) LogContext {
+	lc, _ := ctx.Value(logContextKey{}).(LogContext)
+	return lc
+}
+
+// Fields returns the correlation IDs that are set, keyed like the logger's
+// fields, for tagging log entries and metrics alike.
+func (lc LogContext) Fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+
+	if lc.GoalID != "" {
+		fields["goal_id"] = lc.GoalID
+	}
+	if lc.ObjectiveID != "" {
+		fields["objective_id"] = lc.ObjectiveID
+	}
+	if lc.PlanID != "" {
+		fields["plan_id"] = lc.PlanID
+	}
+	if lc.MethodID != "" {
+		fields["method_id"] = lc.MethodID
+	}
+	if lc.UserID != "" {
+		fields["user_id"] = lc.UserID
+	}
+	if lc.SessionID != "" {
+		fields["session_id"] = lc.SessionID
+	}
+
+	return fields
+}
+
 // Logger provides structured logging interface.
 type Logger interface {
 	// Debug logs debug-level messages
@@ -191,25 +235,37 @@ func (l *ConcreteLogger) mergedContext(ctx LogContext) LogContext {
 
 // contextToFields converts LogContext to logrus fields.
 func (l *ConcreteLogger) contextToFields(ctx LogContext) logrus.Fields {
-	fields := logrus.Fields{}
+	return logrus.Fields(ctx.Fields())
+}
 
-	if ctx.GoalID != "" {
-		fields["goal_id"] = ctx.GoalID
-	}
-	if ctx.ObjectiveID != "" {
-		fields["objective_id"] = ctx.ObjectiveID
-	}
-	if ctx.MethodID != "" {
-		fields["method_id"] = ctx.MethodID
+// ApplyConfig updates the logger's level while it runs. The other fields of
+// config, such as where entries are written, are fixed when the logger is
+// created and are ignored. Loggers made by WithContext share the level.
+func (l *ConcreteLogger) ApplyConfig(config LogConfig) error {
+	level, err := parseLogLevel(config.Level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %s: %w", config.Level, err)
 	}
-	if ctx.UserID != "" {
-		fields["user_id"] = ctx.UserID
+	l.logger.SetLevel(level)
+	return nil
+}
+
+// ParseLogLevel returns the level with the given name, in any case; "WARN"
+// is accepted for LogLevelWarning.
+func ParseLogLevel(name string) (LogLevel, error) {
+	level, err := parseLogLevel(LogLevel(name))
+	if err != nil {
+		return "", err
 	}
-	if ctx.SessionID != "" {
-		fields["session_id"] = ctx.SessionID
+	switch level {
+	case logrus.DebugLevel:
+		return LogLevelDebug, nil
+	case logrus.WarnLevel:
+		return LogLevelWarning, nil
+	case logrus.ErrorLevel:
+		return LogLevelError, nil
 	}
-
-	return fields
+	return LogLevelInfo, nil
 }
 
 // parseLogLevel converts LogLevel string to logrus Level.
@@ -292,6 +348,9 @@ func mergeContext(base, override LogContext) LogContext {
 	if override.ObjectiveID != "" {
 		result.ObjectiveID = override.ObjectiveID
 	}
+	if override.PlanID != "" {
+		result.PlanID = override.PlanID
+	}
 	if override.MethodID != "" {
 		result.MethodID = override.MethodID
 	}
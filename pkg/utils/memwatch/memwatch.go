@@ -0,0 +1,256 @@
+// Package memwatch keeps a long-running process under a memory ceiling by
+// shedding load before the operating system has to kill it.
+//
+// A Watchdog samples the Go runtime's memory use. When it crosses a share of
+// the ceiling, every registered Shedder runs in order, such as pausing
+// background jobs and dropping caches, and the watchdog reports what they
+// shed. Once use falls back below a lower mark, paused work is resumed:
+//
+//	watchdog := memwatch.New(memwatch.Config{
+//	    Ceiling: 256 << 20,
+//	    OnShed:  func(event memwatch.Event) { log.Print(event) },
+//	})
+//	watchdog.Add(memwatch.Shedder{Name: "scheduler", Shed: scheduler.Pause, Resume: scheduler.Resume})
+//	go watchdog.Start(ctx)
+package memwatch
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// Config controls when a Watchdog sheds load.
+type Config struct {
+	// Ceiling is the memory budget in bytes (0 disables the watchdog)
+	Ceiling uint64
+
+	// ShedAt is the share of the ceiling at which load is shed (default 0.85)
+	ShedAt float64
+
+	// ResumeAt is the share of the ceiling below which shed work resumes (default 0.6)
+	ResumeAt float64
+
+	// Interval is how often memory is sampled (default 5s)
+	Interval time.Duration
+
+	// SetLimit also hands the ceiling to the runtime as its soft memory
+	// limit, unless one was already set through GOMEMLIMIT
+	SetLimit bool
+
+	// Sample reports the memory in use (default: memory obtained from the
+	// OS by the runtime and not yet returned, from runtime.MemStats)
+	Sample func() uint64
+
+	// OnShed and OnResume report what the watchdog did, for logging
+	OnShed   func(Event)
+	OnResume func(Event)
+
+	// Clock drives the sampling loop (default: the system clock)
+	Clock utils.Clock
+}
+
+// Shedder is one way of giving memory back under pressure.
+type Shedder struct {
+	// Name identifies the shedder in events
+	Name string
+
+	// Shed releases load and describes what was released ("" if nothing)
+	Shed func() string
+
+	// Resume restores what Shed paused (nil if nothing needs restoring)
+	Resume func()
+}
+
+// Event records one crossing of the shed or resume mark.
+type Event struct {
+	At      time.Time
+	Used    uint64
+	Ceiling uint64
+	Actions []Action // What each shedder did; empty for a resume
+}
+
+// Action is what a single shedder reported.
+type Action struct {
+	Shedder string
+	Detail  string
+}
+
+// String describes the event for a log line.
+func (e Event) String() string {
+	usage := fmt.Sprintf("%s of %s", formatMB(e.Used), formatMB(e.Ceiling))
+	if len(e.Actions) == 0 {
+		return fmt.Sprintf("memory at %s, resumed shed work", usage)
+	}
+	parts := make([]string, 0, len(e.Actions))
+	for _, action := range e.Actions {
+		parts = append(parts, fmt.Sprintf("%s: %s", action.Shedder, action.Detail))
+	}
+	return fmt.Sprintf("memory at %s, shed %s", usage, strings.Join(parts, "; "))
+}
+
+// Watchdog samples memory use and sheds load near the ceiling.
+type Watchdog struct {
+	config   Config
+	mu       sync.Mutex
+	shedders []Shedder
+	shedding bool
+	peak     uint64
+	sheds    int
+}
+
+// New creates a watchdog. Call Add for each shedder, then Start.
+func New(config Config) *Watchdog {
+	if config.ShedAt <= 0 || config.ShedAt > 1 {
+		config.ShedAt = 0.85
+	}
+	if config.ResumeAt <= 0 || config.ResumeAt >= config.ShedAt {
+		config.ResumeAt = config.ShedAt * 0.7
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	if config.Sample == nil {
+		config.Sample = RuntimeMemory
+	}
+	config.Clock = utils.ClockOrReal(config.Clock)
+	return &Watchdog{config: config}
+}
+
+// RuntimeMemory returns the memory the Go runtime holds from the operating
+// system, the figure GOMEMLIMIT applies to.
+func RuntimeMemory() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// FreeOSMemory is a shedder that returns freed memory to the operating system.
+func FreeOSMemory() Shedder {
+	return Shedder{
+		Name: "runtime",
+		Shed: func() string {
+			debug.FreeOSMemory()
+			return "returned free memory to the OS"
+		},
+	}
+}
+
+// Add registers a shedder. Shedders run in the order they were added, so
+// the cheapest to undo should come first.
+func (w *Watchdog) Add(shedder Shedder) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.shedders = append(w.shedders, shedder)
+}
+
+// Enabled reports whether a ceiling is configured.
+func (w *Watchdog) Enabled() bool {
+	return w.config.Ceiling > 0
+}
+
+// Start samples memory every interval until ctx is done.
+// It returns immediately when no ceiling is configured.
+func (w *Watchdog) Start(ctx context.Context) {
+	if !w.Enabled() {
+		return
+	}
+	if w.config.SetLimit && debug.SetMemoryLimit(-1) == maxMemoryLimit {
+		debug.SetMemoryLimit(int64(w.config.Ceiling))
+	}
+
+	ticker := w.config.Clock.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			w.Check()
+		}
+	}
+}
+
+// maxMemoryLimit is the runtime's memory limit when none has been set.
+const maxMemoryLimit = int64(^uint64(0) >> 1)
+
+// Check takes one sample, shedding or resuming as needed. Shedders run on
+// every sample above the shed mark, since caches refill; OnShed is only
+// called when use first crosses it.
+func (w *Watchdog) Check() {
+	if !w.Enabled() {
+		return
+	}
+	used := w.config.Sample()
+
+	w.mu.Lock()
+	if used > w.peak {
+		w.peak = used
+	}
+	shedders := append([]Shedder(nil), w.shedders...)
+	wasShedding := w.shedding
+
+	event := Event{At: w.config.Clock.Now(), Used: used, Ceiling: w.config.Ceiling}
+	switch {
+	case float64(used) >= w.config.ShedAt*float64(w.config.Ceiling):
+		w.shedding = true
+		w.sheds++
+	case wasShedding && float64(used) < w.config.ResumeAt*float64(w.config.Ceiling):
+		w.shedding = false
+	default:
+		w.mu.Unlock()
+		return
+	}
+	shedding := w.shedding
+	w.mu.Unlock()
+
+	if !shedding {
+		for _, shedder := range shedders {
+			if shedder.Resume != nil {
+				shedder.Resume()
+			}
+		}
+		if w.config.OnResume != nil {
+			w.config.OnResume(event)
+		}
+		return
+	}
+
+	for _, shedder := range shedders {
+		if shedder.Shed == nil {
+			continue
+		}
+		if detail := shedder.Shed(); detail != "" {
+			event.Actions = append(event.Actions, Action{Shedder: shedder.Name, Detail: detail})
+		}
+	}
+	if !wasShedding && w.config.OnShed != nil {
+		w.config.OnShed(event)
+	}
+}
+
+// Shedding reports whether load is currently shed.
+func (w *Watchdog) Shedding() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.shedding
+}
+
+// Stats reports the highest sample seen and how many samples shed load.
+func (w *Watchdog) Stats() (peak uint64, sheds int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.peak, w.sheds
+}
+
+// formatMB renders a byte count in megabytes.
+func formatMB(bytes uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
@@ -0,0 +1,96 @@
+package memwatch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWatchdogShedsAndResumes(t *testing.T) {
+	var used uint64
+	var shedEvents, resumeEvents []Event
+	paused := false
+	flushes := 0
+
+	watchdog := New(Config{
+		Ceiling:  1000,
+		Sample:   func() uint64 { return used },
+		OnShed:   func(event Event) { shedEvents = append(shedEvents, event) },
+		OnResume: func(event Event) { resumeEvents = append(resumeEvents, event) },
+	})
+	watchdog.Add(Shedder{
+		Name: "jobs",
+		Shed: func() string {
+			if paused {
+				return ""
+			}
+			paused = true
+			return "paused background jobs"
+		},
+		Resume: func() { paused = false },
+	})
+	watchdog.Add(Shedder{
+		Name: "cache",
+		Shed: func() string {
+			flushes++
+			return "dropped 3 segments"
+		},
+	})
+
+	used = 500
+	watchdog.Check()
+	if watchdog.Shedding() || paused || flushes != 0 {
+		t.Fatal("Expected nothing shed below the shed mark")
+	}
+
+	used = 900
+	watchdog.Check()
+	if !watchdog.Shedding() || !paused || flushes != 1 {
+		t.Fatalf("Expected load shed above the shed mark, paused=%v flushes=%d", paused, flushes)
+	}
+	if len(shedEvents) != 1 || len(shedEvents[0].Actions) != 2 {
+		t.Fatalf("Expected one shed event naming both shedders, got %+v", shedEvents)
+	}
+	if got := shedEvents[0].String(); !strings.Contains(got, "jobs: paused background jobs") || !strings.Contains(got, "cache: dropped 3 segments") {
+		t.Errorf("Unexpected event description %q", got)
+	}
+
+	// Caches are flushed again while memory stays high, without another event
+	used = 950
+	watchdog.Check()
+	if flushes != 2 || len(shedEvents) != 1 {
+		t.Errorf("Expected a second flush and no new event, got %d flushes and %d events", flushes, len(shedEvents))
+	}
+
+	// Between the marks, shed work stays paused
+	used = 700
+	watchdog.Check()
+	if !watchdog.Shedding() || !paused {
+		t.Error("Expected shed work to stay paused above the resume mark")
+	}
+
+	used = 400
+	watchdog.Check()
+	if watchdog.Shedding() || paused || len(resumeEvents) != 1 {
+		t.Errorf("Expected shed work resumed below the resume mark, paused=%v events=%d", paused, len(resumeEvents))
+	}
+
+	peak, sheds := watchdog.Stats()
+	if peak != 950 || sheds != 2 {
+		t.Errorf("Expected a peak of 950 over 2 shedding samples, got %d and %d", peak, sheds)
+	}
+}
+
+func TestWatchdogDisabledWithoutCeiling(t *testing.T) {
+	sampled := false
+	watchdog := New(Config{Sample: func() uint64 { sampled = true; return 1 << 40 }})
+	watchdog.Check()
+	if watchdog.Enabled() || sampled || watchdog.Shedding() {
+		t.Error("Expected a watchdog without a ceiling to do nothing")
+	}
+}
+
+func TestRuntimeMemory(t *testing.T) {
+	if RuntimeMemory() == 0 {
+		t.Error("Expected the runtime to report memory in use")
+	}
+}
@@ -0,0 +1,83 @@
+package netaudit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// rawClientPattern matches the ways code can reach the network without
+// going through NewClient.
+var rawClientPattern = regexp.MustCompile(`http\.(Client|Transport)\s*\{|new\(http\.(Client|Transport)\)|http\.(DefaultClient|DefaultTransport|Get|Head|Post|PostForm)\b`)
+
+// TestNoRawHTTPClients fails if code outside this package builds its own
+// outbound client, which would bypass the audit log and the allowlist.
+// Tests are exempt since they talk to local test servers.
+func TestNoRawHTTPClients(t *testing.T) {
+	root := moduleRoot(t)
+	self, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var offenders []string
+	err = filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			name := entry.Name()
+			if path == self || name == "vendor" || name == "testdata" || (strings.HasPrefix(name, ".") && path != root) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "//") {
+				continue
+			}
+			if rawClientPattern.MatchString(line) {
+				rel, _ := filepath.Rel(root, path)
+				offenders = append(offenders, fmt.Sprintf("%s:%d: %s", rel, i+1, strings.TrimSpace(line)))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to scan the source tree: %v", err)
+	}
+
+	if len(offenders) > 0 {
+		t.Errorf("outbound HTTP clients must come from netaudit.NewClient:\n%s", strings.Join(offenders, "\n"))
+	}
+}
+
+// moduleRoot finds the directory holding go.mod.
+func moduleRoot(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			t.Fatal("go.mod not found")
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,126 @@
+package netaudit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils/jsonlog"
+)
+
+// auditLog keeps records in one JSON-lines file per day, or in a bounded
+// ring in memory when it has no directory.
+type auditLog struct {
+	dir           string
+	files         jsonlog.Daily
+	retentionDays int
+
+	mu       sync.Mutex
+	ring     []Record
+	next     int
+	full     bool
+	prunedOn string
+	err      error
+}
+
+func newAuditLog(dir string, retentionDays, memoryRecords int) (*auditLog, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create network audit directory: %w", err)
+		}
+	}
+	return &auditLog{
+		dir:           dir,
+		files:         jsonlog.Daily{Dir: dir, Prefix: "network-", Suffix: ".jsonl"},
+		retentionDays: retentionDays,
+		ring:          make([]Record, memoryRecords),
+	}, nil
+}
+
+func (l *auditLog) append(record Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.dir == "" {
+		l.ring[l.next] = record
+		l.next = (l.next + 1) % len(l.ring)
+		l.full = l.full || l.next == 0
+		return
+	}
+	l.err = l.write(record)
+}
+
+// write appends the record to its day's file, pruning old days once a day.
+// Callers hold the lock.
+func (l *auditLog) write(record Record) error {
+	day := jsonlog.Day(record.Timestamp)
+	if l.prunedOn != day {
+		if _, err := l.files.Prune(record.Timestamp, l.retentionDays); err != nil {
+			return fmt.Errorf("failed to prune network audit logs: %w", err)
+		}
+		l.prunedOn = day
+	}
+
+	file, err := l.files.Open(day)
+	if err != nil {
+		return fmt.Errorf("failed to open network audit log: %w", err)
+	}
+	defer file.Close()
+
+	if err := jsonlog.Append(file, record); err != nil {
+		return fmt.Errorf("failed to log network record: %w", err)
+	}
+	return nil
+}
+
+func (l *auditLog) lastError() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// since returns the records at or after the given time, oldest first.
+func (l *auditLog) since(since time.Time) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.dir == "" {
+		var records []Record
+		start, count := 0, l.next
+		if l.full {
+			start, count = l.next, len(l.ring)
+		}
+		for i := 0; i < count; i++ {
+			record := l.ring[(start+i)%len(l.ring)]
+			if !record.Timestamp.Before(since) {
+				records = append(records, record)
+			}
+		}
+		return records, nil
+	}
+
+	days, err := l.files.Days()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network audit logs: %w", err)
+	}
+	var records []Record
+	firstDay := jsonlog.Day(since)
+	for _, day := range days {
+		if !since.IsZero() && day < firstDay {
+			continue
+		}
+		if err := jsonlog.ReadFile(l.files.Path(day), func(record Record) {
+			if !record.Timestamp.Before(since) {
+				records = append(records, record)
+			}
+		}); err != nil {
+			return nil, fmt.Errorf("failed to read network audit log for %s: %w", day, err)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
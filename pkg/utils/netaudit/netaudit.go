@@ -0,0 +1,489 @@
+// Package netaudit records every outbound network destination the studio
+// contacts and can restrict them to an allowlist.
+//
+// All outbound HTTP clients come from NewClient, which tags each request with
+// the component making it ("provider:anthropic", "browser", ...). The shared
+// transport records the destination host and port, the bytes sent and
+// received and whether TLS was used. In audit mode nothing is blocked; in
+// enforce mode a host that is not on the allowlist, or a request from a
+// component that did not identify itself, fails with a *BlockedError:
+//
+//	auditor, err := netaudit.NewAuditor(netaudit.Config{
+//	    Mode:      netaudit.ModeEnforce,
+//	    Allowlist: []string{"api.anthropic.com", "*.openai.com", "localhost:8080"},
+//	    Dir:       filepath.Join(dataDir, "network"),
+//	})
+//	netaudit.SetDefault(auditor)
+//
+//	client := netaudit.NewClient("provider:anthropic", 30*time.Second)
+package netaudit
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// Mode decides what happens to requests for hosts outside the allowlist.
+type Mode string
+
+const (
+	// ModeAudit records every request and blocks none
+	ModeAudit Mode = "audit"
+
+	// ModeEnforce blocks requests for hosts outside the allowlist and
+	// requests from components that did not identify themselves
+	ModeEnforce Mode = "enforce"
+)
+
+// ParseMode parses a configured mode; an empty string is audit mode.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(strings.ToLower(strings.TrimSpace(s))) {
+	case "", ModeAudit:
+		return ModeAudit, nil
+	case ModeEnforce:
+		return ModeEnforce, nil
+	default:
+		return "", fmt.Errorf("unknown network mode %q, must be %q or %q", s, ModeAudit, ModeEnforce)
+	}
+}
+
+// ErrBlocked is matched by the *BlockedError returned for a request the
+// allowlist does not permit.
+var ErrBlocked = errs.New(errs.PolicyBlocked, "outbound request blocked")
+
+// BlockedError names the destination that was refused and the component
+// that tried to reach it.
+type BlockedError struct {
+	Host      string
+	Port      int
+	Component string
+}
+
+func (e *BlockedError) Error() string {
+	destination := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	if e.Component == "" {
+		return fmt.Sprintf("outbound request to %s blocked: the requesting component is unknown", destination)
+	}
+	return fmt.Sprintf("outbound request to %s by %s blocked: %s is not on the network allowlist", destination, e.Component, e.Host)
+}
+
+// Is reports whether target is ErrBlocked.
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// ErrorCode returns errs.PolicyBlocked.
+func (e *BlockedError) ErrorCode() errs.Code {
+	return errs.PolicyBlocked
+}
+
+// Config configures an Auditor.
+type Config struct {
+	// Mode is audit (the default) or enforce
+	Mode Mode
+
+	// Allowlist holds the permitted destinations: a host ("api.openai.com"),
+	// a wildcard for its subdomains ("*.openai.com") or a host and port
+	// ("localhost:8080"). A pattern without a port matches any port.
+	Allowlist []string
+
+	// Dir is where the daily audit logs are written; if empty, only the most
+	// recent MemoryRecords requests are kept in memory
+	Dir string
+
+	// RetentionDays is how many days of logs are kept (default 30)
+	RetentionDays int
+
+	// MemoryRecords bounds the in-memory log used without Dir (default 1000)
+	MemoryRecords int
+
+	// Clock stamps records (default: the system clock)
+	Clock utils.Clock
+}
+
+// Auditor applies the allowlist and keeps the audit log. It is safe for
+// concurrent use.
+type Auditor struct {
+	mu        sync.RWMutex
+	mode      Mode
+	allowlist []string
+	clock     utils.Clock
+	log       *auditLog
+}
+
+// NewAuditor creates an auditor, creating Dir if it is set.
+func NewAuditor(config Config) (*Auditor, error) {
+	mode, err := ParseMode(string(config.Mode))
+	if err != nil {
+		return nil, err
+	}
+	if config.RetentionDays <= 0 {
+		config.RetentionDays = 30
+	}
+	if config.MemoryRecords <= 0 {
+		config.MemoryRecords = 1000
+	}
+
+	auditLog, err := newAuditLog(config.Dir, config.RetentionDays, config.MemoryRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	auditor := &Auditor{
+		mode:  mode,
+		clock: utils.ClockOrReal(config.Clock),
+		log:   auditLog,
+	}
+	for _, pattern := range config.Allowlist {
+		if err := auditor.Allow(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return auditor, nil
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultAuditor = mustAuditor(Config{})
+)
+
+func mustAuditor(config Config) *Auditor {
+	auditor, err := NewAuditor(config)
+	if err != nil {
+		panic(err)
+	}
+	return auditor
+}
+
+// Default returns the auditor used by clients from NewClient. Until
+// SetDefault is called it audits in memory and blocks nothing.
+func Default() *Auditor {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultAuditor
+}
+
+// SetDefault replaces the auditor used by clients from NewClient, including
+// clients created before the call.
+func SetDefault(auditor *Auditor) {
+	if auditor == nil {
+		return
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultAuditor = auditor
+}
+
+// Mode returns the current mode.
+func (a *Auditor) Mode() Mode {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.mode
+}
+
+// SetMode switches between audit and enforce mode.
+func (a *Auditor) SetMode(mode Mode) error {
+	parsed, err := ParseMode(string(mode))
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mode = parsed
+	return nil
+}
+
+// Allowlist returns the allowlist patterns in order.
+func (a *Auditor) Allowlist() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]string(nil), a.allowlist...)
+}
+
+// Allow adds a pattern to the allowlist. URLs are reduced to their host and
+// explicit port, so a configured base URL can be passed as it is.
+func (a *Auditor) Allow(pattern string) error {
+	normalized, err := NormalizePattern(pattern)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, existing := range a.allowlist {
+		if existing == normalized {
+			return nil
+		}
+	}
+	a.allowlist = append(a.allowlist, normalized)
+	sort.Strings(a.allowlist)
+	return nil
+}
+
+// Disallow removes a pattern from the allowlist and reports whether it was there.
+func (a *Auditor) Disallow(pattern string) bool {
+	normalized, err := NormalizePattern(pattern)
+	if err != nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, existing := range a.allowlist {
+		if existing == normalized {
+			a.allowlist = append(a.allowlist[:i], a.allowlist[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether the allowlist permits host and port, whatever the mode.
+func (a *Auditor) Allowed(host string, port int) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, pattern := range a.allowlist {
+		if patternMatches(pattern, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a *BlockedError if the auditor is enforcing and the request
+// may not be made: the host is not allowlisted or the component is unknown.
+func (a *Auditor) Check(component, host string, port int) error {
+	if a.Mode() != ModeEnforce {
+		return nil
+	}
+	if component == "" || !a.Allowed(host, port) {
+		return &BlockedError{Host: host, Port: port, Component: component}
+	}
+	return nil
+}
+
+// CheckURL checks and records a destination reached without the shared
+// transport, such as a page the headless browser is sent to. Only the
+// destination is recorded, since the bytes exchanged are not visible here.
+func (a *Auditor) CheckURL(component, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid URL %q", rawURL)
+	}
+	host, port := ParseDestination(parsed)
+	record := Record{
+		Component:   component,
+		Host:        host,
+		Port:        port,
+		TLS:         strings.EqualFold(parsed.Scheme, "https"),
+		Allowlisted: a.Allowed(host, port),
+	}
+	err = a.Check(component, host, port)
+	if err != nil {
+		record.Blocked = true
+		record.Error = err.Error()
+	}
+	a.Record(record)
+	return err
+}
+
+// NormalizePattern validates an allowlist pattern and returns its canonical
+// lower-case form.
+func NormalizePattern(pattern string) (string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if strings.Contains(pattern, "://") {
+		parsed, err := url.Parse(pattern)
+		if err != nil || parsed.Host == "" {
+			return "", fmt.Errorf("invalid allowlist entry %q", pattern)
+		}
+		pattern = parsed.Host
+	}
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	host, port := pattern, ""
+	if h, p, err := net.SplitHostPort(pattern); err == nil {
+		host, port = h, p
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid port in allowlist entry %q", pattern)
+		}
+	}
+	host = strings.Trim(host, "[]")
+
+	wildcard := strings.HasPrefix(host, "*.")
+	name := strings.TrimPrefix(host, "*.")
+	if name == "" || strings.ContainsAny(name, "*/ ") {
+		return "", fmt.Errorf("invalid allowlist entry %q", pattern)
+	}
+	if wildcard {
+		host = "*." + name
+	}
+
+	if port == "" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]", nil
+		}
+		return host, nil
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// patternMatches reports whether a normalized pattern permits host and port.
+func patternMatches(pattern, host string, port int) bool {
+	patternHost, patternPort := strings.Trim(pattern, "[]"), ""
+	if h, p, err := net.SplitHostPort(pattern); err == nil {
+		patternHost, patternPort = h, p
+	}
+	if patternPort != "" && patternPort != strconv.Itoa(port) {
+		return false
+	}
+	if suffix, wildcard := strings.CutPrefix(patternHost, "*"); wildcard {
+		return strings.HasSuffix(host, suffix)
+	}
+	return host == patternHost
+}
+
+// Record is one outbound request in the audit log. Paths and query strings
+// are not recorded, since they can carry credentials.
+type Record struct {
+	Timestamp time.Time `json:"timestamp"`
+	Component string    `json:"component"`
+	Method    string    `json:"method,omitempty"`
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+
+	// BytesSent and BytesReceived count request and response bodies
+	BytesSent     int64 `json:"bytes_sent"`
+	BytesReceived int64 `json:"bytes_received"`
+
+	TLS        bool   `json:"tls"`
+	TLSVersion string `json:"tls_version,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+
+	// Allowlisted records whether the allowlist permits the destination,
+	// so audit mode shows what enforcing would block
+	Allowlisted bool `json:"allowlisted"`
+
+	Blocked bool   `json:"blocked,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Destination summarizes the requests to one host and port.
+type Destination struct {
+	Host       string
+	Port       int
+	Components []string
+
+	Requests int
+	Blocked  int
+	Failed   int
+
+	BytesSent     int64
+	BytesReceived int64
+
+	// TLS is set when every request that connected used TLS
+	TLS         bool
+	Allowlisted bool
+
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Record appends a request to the audit log, stamping it if needed. Errors
+// writing the log are not returned to the request; they are kept for
+// LastError, so auditing never breaks the request it observes.
+func (a *Auditor) Record(record Record) {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = a.clock.Now()
+	}
+	a.log.append(record)
+}
+
+// LastError returns the most recent failure to write the audit log, if any.
+func (a *Auditor) LastError() error {
+	return a.log.lastError()
+}
+
+// Records returns the logged requests made at or after since, oldest first.
+func (a *Auditor) Records(since time.Time) ([]Record, error) {
+	return a.log.since(since)
+}
+
+// Summary groups the requests made at or after since by destination, most
+// requested first.
+func (a *Auditor) Summary(since time.Time) ([]Destination, error) {
+	records, err := a.Records(since)
+	if err != nil {
+		return nil, err
+	}
+	return Summarize(records), nil
+}
+
+// Summarize groups records by destination, most requested first.
+func Summarize(records []Record) []Destination {
+	byDestination := make(map[string]*Destination)
+	components := make(map[string]map[string]bool)
+	plaintext := make(map[string]bool)
+	for _, record := range records {
+		key := net.JoinHostPort(record.Host, strconv.Itoa(record.Port))
+		destination, exists := byDestination[key]
+		if !exists {
+			destination = &Destination{
+				Host:      record.Host,
+				Port:      record.Port,
+				FirstSeen: record.Timestamp,
+			}
+			byDestination[key] = destination
+			components[key] = make(map[string]bool)
+		}
+
+		destination.Requests++
+		switch {
+		case record.Blocked:
+			destination.Blocked++
+		case record.Error != "":
+			destination.Failed++
+		}
+		if record.StatusCode != 0 {
+			plaintext[key] = plaintext[key] || !record.TLS
+			destination.TLS = !plaintext[key]
+		}
+		destination.BytesSent += record.BytesSent
+		destination.BytesReceived += record.BytesReceived
+		destination.Allowlisted = record.Allowlisted
+		if record.Timestamp.Before(destination.FirstSeen) {
+			destination.FirstSeen = record.Timestamp
+		}
+		if record.Timestamp.After(destination.LastSeen) {
+			destination.LastSeen = record.Timestamp
+		}
+		if !components[key][record.Component] {
+			components[key][record.Component] = true
+			destination.Components = append(destination.Components, record.Component)
+		}
+	}
+
+	destinations := make([]Destination, 0, len(byDestination))
+	for _, destination := range byDestination {
+		sort.Strings(destination.Components)
+		destinations = append(destinations, *destination)
+	}
+	sort.Slice(destinations, func(i, j int) bool {
+		if destinations[i].Requests != destinations[j].Requests {
+			return destinations[i].Requests > destinations[j].Requests
+		}
+		if destinations[i].Host != destinations[j].Host {
+			return destinations[i].Host < destinations[j].Host
+		}
+		return destinations[i].Port < destinations[j].Port
+	})
+	return destinations
+}
@@ -0,0 +1,254 @@
+package netaudit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// newTestServer answers every request with a fixed body.
+func newTestServer(t *testing.T) (*httptest.Server, string, int) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("hello"))
+	}))
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	host, port := ParseDestination(u)
+	return server, host, port
+}
+
+func post(t *testing.T, client *http.Client, target string) error {
+	t.Helper()
+	resp, err := client.Post(target, "text/plain", strings.NewReader("ping"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	return err
+}
+
+func TestAuditModeRecordsWithoutBlocking(t *testing.T) {
+	server, host, port := newTestServer(t)
+	clock := utils.NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	auditor, err := NewAuditor(Config{Clock: clock})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+	if auditor.Mode() != ModeAudit {
+		t.Fatalf("expected audit mode by default, got %q", auditor.Mode())
+	}
+
+	if err := post(t, auditor.NewClient("provider:test", time.Second), server.URL); err != nil {
+		t.Fatalf("request in audit mode failed: %v", err)
+	}
+
+	records, err := auditor.Records(time.Time{})
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	record := records[0]
+	if record.Host != host || record.Port != port || record.Component != "provider:test" {
+		t.Errorf("unexpected destination %s:%d from %q", record.Host, record.Port, record.Component)
+	}
+	if record.BytesSent != 4 || record.BytesReceived != 5 {
+		t.Errorf("expected 4 bytes sent and 5 received, got %d and %d", record.BytesSent, record.BytesReceived)
+	}
+	if record.TLS || record.Allowlisted || record.Blocked {
+		t.Errorf("expected a plaintext, unlisted, unblocked record: %+v", record)
+	}
+	if record.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", record.StatusCode)
+	}
+}
+
+func TestEnforceModeAllowsListedHosts(t *testing.T) {
+	server, host, port := newTestServer(t)
+	auditor, err := NewAuditor(Config{Mode: ModeEnforce, Allowlist: []string{server.URL}})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+	if !auditor.Allowed(host, port) {
+		t.Fatalf("expected %s:%d to be allowed by %v", host, port, auditor.Allowlist())
+	}
+
+	if err := post(t, auditor.NewClient("fetch", time.Second), server.URL); err != nil {
+		t.Fatalf("allowlisted request failed: %v", err)
+	}
+	records, _ := auditor.Records(time.Time{})
+	if len(records) != 1 || !records[0].Allowlisted || records[0].Blocked {
+		t.Fatalf("expected one allowed record, got %+v", records)
+	}
+}
+
+func TestEnforceModeBlocksUnlistedHosts(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	auditor, err := NewAuditor(Config{Mode: ModeEnforce, Allowlist: []string{"api.example.com"}})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+
+	err = post(t, auditor.NewClient("notifier:webhook", time.Second), server.URL)
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("expected a BlockedError, got %v", err)
+	}
+	if !errors.Is(err, ErrBlocked) {
+		t.Error("expected the error to match ErrBlocked")
+	}
+	if blocked.Host != "127.0.0.1" || blocked.Component != "notifier:webhook" {
+		t.Errorf("expected the host and component to be named, got %+v", blocked)
+	}
+	if !strings.Contains(err.Error(), "notifier:webhook") || !strings.Contains(err.Error(), "127.0.0.1") {
+		t.Errorf("expected the message to name host and component: %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("expected the server not to be contacted, got %d requests", requests)
+	}
+
+	records, _ := auditor.Records(time.Time{})
+	if len(records) != 1 || !records[0].Blocked {
+		t.Fatalf("expected the blocked attempt to be recorded, got %+v", records)
+	}
+}
+
+func TestEnforceModeBlocksUnknownComponents(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	auditor, err := NewAuditor(Config{Mode: ModeEnforce, Allowlist: []string{server.URL}})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+
+	err = post(t, auditor.NewClient("", time.Second), server.URL)
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected a request without a component to be blocked, got %v", err)
+	}
+}
+
+func TestDefaultAuditorIsResolvedPerRequest(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	previous := Default()
+	defer SetDefault(previous)
+
+	client := NewClient("provider:test", time.Second)
+	enforcing, err := NewAuditor(Config{Mode: ModeEnforce})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+	SetDefault(enforcing)
+
+	if err := post(t, client, server.URL); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected a client created earlier to use the new default, got %v", err)
+	}
+}
+
+func TestAllowlistPatterns(t *testing.T) {
+	auditor, err := NewAuditor(Config{Allowlist: []string{
+		"API.Anthropic.com",
+		"*.openai.com",
+		"localhost:8080",
+		"https://api.openai.com/v1",
+	}})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+
+	cases := []struct {
+		host    string
+		port    int
+		allowed bool
+	}{
+		{"api.anthropic.com", 443, true},
+		{"api.anthropic.com", 8443, true},
+		{"evil-anthropic.com", 443, false},
+		{"api.openai.com", 443, true},
+		{"files.openai.com", 443, true},
+		{"openai.com", 443, false},
+		{"localhost", 8080, true},
+		{"localhost", 8081, false},
+	}
+	for _, c := range cases {
+		if got := auditor.Allowed(c.host, c.port); got != c.allowed {
+			t.Errorf("Allowed(%s, %d) = %v, want %v", c.host, c.port, got, c.allowed)
+		}
+	}
+
+	if !auditor.Disallow("localhost:8080") || auditor.Allowed("localhost", 8080) {
+		t.Error("expected localhost:8080 to be removed")
+	}
+	if _, err := NormalizePattern("exa mple.com"); err == nil {
+		t.Error("expected an invalid pattern to be rejected")
+	}
+	if _, err := ParseMode("block"); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+}
+
+func TestAuditLogPersistsAndSummarizes(t *testing.T) {
+	dir := t.TempDir()
+	clock := utils.NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	auditor, err := NewAuditor(Config{Dir: dir, RetentionDays: 2, Clock: clock})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+
+	auditor.Record(Record{Component: "provider:openai", Host: "api.openai.com", Port: 443, BytesSent: 10, BytesReceived: 20, TLS: true, StatusCode: 200})
+	clock.Advance(72 * time.Hour)
+	auditor.Record(Record{Component: "provider:anthropic", Host: "api.anthropic.com", Port: 443, BytesSent: 1, BytesReceived: 2, TLS: true, StatusCode: 200})
+	auditor.Record(Record{Component: "browser", Host: "api.anthropic.com", Port: 443, BytesSent: 3, TLS: true, StatusCode: 200})
+	if err := auditor.LastError(); err != nil {
+		t.Fatalf("writing the log failed: %v", err)
+	}
+
+	reopened, err := NewAuditor(Config{Dir: dir, Clock: clock})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+	summary, err := reopened.Summary(time.Time{})
+	if err != nil {
+		t.Fatalf("Summary failed: %v", err)
+	}
+	if len(summary) != 1 {
+		t.Fatalf("expected the day outside retention to be pruned, got %+v", summary)
+	}
+	destination := summary[0]
+	if destination.Host != "api.anthropic.com" || destination.Requests != 2 || destination.BytesSent != 4 || !destination.TLS {
+		t.Errorf("unexpected summary %+v", destination)
+	}
+	if strings.Join(destination.Components, ",") != "browser,provider:anthropic" {
+		t.Errorf("expected both components, got %v", destination.Components)
+	}
+}
+
+func TestMemoryLogIsBounded(t *testing.T) {
+	auditor, err := NewAuditor(Config{MemoryRecords: 3})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+	for port := 1; port <= 5; port++ {
+		auditor.Record(Record{Host: "localhost", Port: port})
+	}
+
+	records, _ := auditor.Records(time.Time{})
+	if len(records) != 3 || records[0].Port != 3 || records[2].Port != 5 {
+		t.Fatalf("expected the 3 most recent records, got %+v", records)
+	}
+}
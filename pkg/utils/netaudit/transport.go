@@ -0,0 +1,173 @@
+package netaudit
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewClient returns an HTTP client whose requests are checked and recorded
+// by the default auditor under the given component name, such as
+// "provider:openai". It is the only way the studio constructs outbound
+// clients; a request from a client without a component is blocked when the
+// allowlist is enforced.
+func NewClient(component string, timeout time.Duration) *http.Client {
+	return newClient(nil, component, timeout)
+}
+
+// NewClient returns an HTTP client checked and recorded by this auditor
+// rather than the default one.
+func (a *Auditor) NewClient(component string, timeout time.Duration) *http.Client {
+	return newClient(a, component, timeout)
+}
+
+func newClient(auditor *Auditor, component string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &transport{
+			auditor:   auditor,
+			component: component,
+			base:      http.DefaultTransport,
+		},
+	}
+}
+
+// transport checks each request against the allowlist and records it. A
+// redirect reaches it as a new request, so redirects are checked too.
+type transport struct {
+	auditor   *Auditor // nil: the default auditor at request time
+	component string
+	base      http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	auditor := t.auditor
+	if auditor == nil {
+		auditor = Default()
+	}
+
+	host, port := ParseDestination(req.URL)
+	record := Record{
+		Timestamp:   auditor.clock.Now(),
+		Component:   t.component,
+		Method:      req.Method,
+		Host:        host,
+		Port:        port,
+		Allowlisted: auditor.Allowed(host, port),
+	}
+
+	if err := auditor.Check(t.component, host, port); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		record.Blocked = true
+		record.Error = err.Error()
+		auditor.Record(record)
+		return nil, err
+	}
+
+	var sent *countingReader
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		sent = &countingReader{ReadCloser: req.Body}
+		req.Body = sent
+	}
+
+	started := auditor.clock.Now()
+	resp, err := t.base.RoundTrip(req)
+	if sent != nil {
+		record.BytesSent = sent.count()
+	}
+	if err != nil {
+		record.DurationMs = auditor.clock.Since(started).Milliseconds()
+		record.Error = err.Error()
+		auditor.Record(record)
+		return nil, err
+	}
+
+	record.StatusCode = resp.StatusCode
+	if resp.TLS != nil {
+		record.TLS = true
+		record.TLSVersion = tls.VersionName(resp.TLS.Version)
+	}
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		finish: func(received int64, readErr error) {
+			if sent != nil {
+				record.BytesSent = sent.count()
+			}
+			record.BytesReceived = received
+			record.DurationMs = auditor.clock.Since(started).Milliseconds()
+			if readErr != nil {
+				record.Error = readErr.Error()
+			}
+			auditor.Record(record)
+		},
+	}
+	return resp, nil
+}
+
+// ParseDestination returns the host and port a URL points at, filling in the
+// scheme's default port.
+func ParseDestination(u *url.URL) (string, int) {
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		return host, port
+	}
+	if strings.EqualFold(u.Scheme, "http") || strings.EqualFold(u.Scheme, "ws") {
+		return host, 80
+	}
+	return host, 443
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+func (r *countingReader) count() int64 {
+	return r.n.Load()
+}
+
+// recordingBody counts a response body and records the request once, when
+// the body is read to the end or closed.
+type recordingBody struct {
+	io.ReadCloser
+	received atomic.Int64
+	once     sync.Once
+	finish   func(received int64, err error)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.received.Add(int64(n))
+	if err == io.EOF {
+		b.done(nil)
+	} else if err != nil {
+		b.done(err)
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done(nil)
+	return err
+}
+
+func (b *recordingBody) done(err error) {
+	b.once.Do(func() { b.finish(b.received.Load(), err) })
+}
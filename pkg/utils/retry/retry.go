@@ -0,0 +1,264 @@
+// Package retry provides the retry and backoff policy shared by the LLM
+// service, the real-time cursor and anything else that retries failed work.
+//
+// A Policy says how many attempts an operation gets, how long to wait between
+// them and which errors are worth retrying. Do runs an operation under a
+// policy; callers that manage their own loop ask Policy.Next instead:
+//
+//	policy := retry.Policy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Jitter: true}
+//	stats, err := retry.Do(ctx, policy, func(ctx context.Context) error {
+//	    return client.Send(ctx, request)
+//	})
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// Policy describes how an operation is retried. The zero value makes a
+// single attempt.
+type Policy struct {
+	// MaxAttempts is the most times the operation runs, including the first
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first backed-off retry
+	BaseDelay time.Duration
+
+	// MaxDelay caps each wait (0: no cap)
+	MaxDelay time.Duration
+
+	// Multiplier grows the wait after each retry (values below 1 keep it constant)
+	Multiplier float64
+
+	// ImmediateFirstRetry retries once without waiting before backing off
+	ImmediateFirstRetry bool
+
+	// Jitter waits a random duration between zero and the computed delay
+	// ("full jitter"), so that many callers failing together spread out
+	Jitter bool
+
+	// RespectRetryAfter waits as long as a Hinted error asks, such as an HTTP
+	// Retry-After header, instead of the computed delay (still capped by MaxDelay)
+	RespectRetryAfter bool
+
+	// Retryable decides which errors are retried (default: all except
+	// context cancellation and deadlines)
+	Retryable func(err error) bool
+
+	// Classes override the policy for errors whose Classified class matches
+	Classes map[string]Class
+
+	// Clock times the waits (default: the system clock)
+	Clock utils.Clock
+
+	// OnRetry is called before each wait, for instrumentation
+	OnRetry func(Event)
+}
+
+// Class overrides a Policy for one class of errors, such as "rate_limit".
+type Class struct {
+	// MaxAttempts replaces the policy's limit (0 keeps it; 1 never retries)
+	MaxAttempts int
+
+	// BaseDelay replaces the policy's base delay (0 keeps it)
+	BaseDelay time.Duration
+}
+
+// Classified is implemented by errors that know their retry class.
+type Classified interface {
+	RetryClass() string
+}
+
+// Hinted is implemented by errors that carry how long the server asked the
+// caller to wait before retrying. A zero hint means none was given.
+type Hinted interface {
+	RetryHint() time.Duration
+}
+
+// Event describes one retry, reported to Policy.OnRetry before its wait.
+type Event struct {
+	// Attempt is how many attempts have run so far
+	Attempt int
+
+	// Delay is how long the retry waits
+	Delay time.Duration
+
+	// Err is the error that caused the retry
+	Err error
+
+	// Class is the error's retry class, if it has one
+	Class string
+}
+
+// Stats summarizes the attempts Do made.
+type Stats struct {
+	// Attempts is how many times the operation ran
+	Attempts int
+
+	// TotalWait is the time spent waiting between attempts
+	TotalWait time.Duration
+
+	// Cancelled is set when the context ended during a wait
+	Cancelled bool
+}
+
+// stopError marks an error that must not be retried.
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string { return e.err.Error() }
+func (e *stopError) Unwrap() error { return e.err }
+
+// Stop wraps err so that Do returns it at once instead of retrying.
+// Do returns err itself, without the wrapper.
+func Stop(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &stopError{err: err}
+}
+
+// ClassOf returns the retry class of err, or "" if it has none.
+func ClassOf(err error) string {
+	var classified Classified
+	if errors.As(err, &classified) {
+		return classified.RetryClass()
+	}
+	return ""
+}
+
+// Next reports whether to retry after attempts runs ending in err, and how
+// long to wait first. Attempts counts every run so far, starting at 1.
+func (p Policy) Next(attempts int, err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	var stop *stopError
+	if errors.As(err, &stop) {
+		return 0, false
+	}
+
+	maxAttempts := p.MaxAttempts
+	baseDelay := p.BaseDelay
+	if class, exists := p.Classes[ClassOf(err)]; exists {
+		if class.MaxAttempts > 0 {
+			maxAttempts = class.MaxAttempts
+		}
+		if class.BaseDelay > 0 {
+			baseDelay = class.BaseDelay
+		}
+	}
+	if attempts >= maxAttempts || !p.retryable(err) {
+		return 0, false
+	}
+
+	if p.RespectRetryAfter {
+		var hinted Hinted
+		if errors.As(err, &hinted) && hinted.RetryHint() > 0 {
+			return p.capDelay(hinted.RetryHint()), true
+		}
+	}
+
+	delay := p.backoff(attempts, baseDelay)
+	if p.Jitter && delay > 0 {
+		delay = time.Duration(rand.Int63n(int64(delay) + 1))
+	}
+	return delay, true
+}
+
+// retryable applies the policy's error filter.
+func (p Policy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// backoff returns the delay before the retry that follows attempts runs.
+func (p Policy) backoff(attempts int, baseDelay time.Duration) time.Duration {
+	exponent := attempts - 1
+	if p.ImmediateFirstRetry {
+		if attempts <= 1 {
+			return 0
+		}
+		exponent = attempts - 2
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(baseDelay) * math.Pow(multiplier, float64(exponent))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// capDelay limits a delay to MaxDelay.
+func (p Policy) capDelay(delay time.Duration) time.Duration {
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// Do runs fn until it succeeds, the policy gives up or ctx ends during a
+// wait. It returns fn's last error, or ctx's error if ctx ended while waiting.
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) (Stats, error) {
+	clock := utils.ClockOrReal(policy.Clock)
+	var stats Stats
+
+	for {
+		stats.Attempts++
+		err := fn(ctx)
+		if err == nil {
+			return stats, nil
+		}
+
+		delay, retry := policy.Next(stats.Attempts, err)
+		if !retry {
+			var stop *stopError
+			if errors.As(err, &stop) {
+				err = stop.err
+			}
+			return stats, err
+		}
+
+		if policy.OnRetry != nil {
+			policy.OnRetry(Event{Attempt: stats.Attempts, Delay: delay, Err: err, Class: ClassOf(err)})
+		}
+		if !wait(ctx, clock, delay) {
+			stats.Cancelled = true
+			return stats, ctx.Err()
+		}
+		stats.TotalWait += delay
+	}
+}
+
+// wait sleeps for delay, reporting false if ctx ends first.
+func wait(ctx context.Context, clock utils.Clock, delay time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if delay <= 0 {
+		return true
+	}
+
+	timer := clock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
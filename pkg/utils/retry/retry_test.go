@@ -0,0 +1,210 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
+)
+
+// classifiedError is an error with a retry class and an optional server hint.
+type classifiedError struct {
+	class string
+	hint  time.Duration
+}
+
+func (e *classifiedError) Error() string            { return "failed: " + e.class }
+func (e *classifiedError) RetryClass() string       { return e.class }
+func (e *classifiedError) RetryHint() time.Duration { return e.hint }
+
+var errTransient = errors.New("transient failure")
+
+func TestPolicy_NextSchedule(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		delays []time.Duration
+	}{
+		{
+			name:   "exponential with cap",
+			policy: Policy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2},
+			delays: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second},
+		},
+		{
+			name:   "immediate first retry",
+			policy: Policy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Multiplier: 3, ImmediateFirstRetry: true},
+			delays: []time.Duration{0, time.Second, 3 * time.Second},
+		},
+		{
+			name:   "constant without multiplier",
+			policy: Policy{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond},
+			delays: []time.Duration{500 * time.Millisecond, 500 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, expected := range tt.delays {
+				delay, ok := tt.policy.Next(i+1, errTransient)
+				if !ok || delay != expected {
+					t.Errorf("After attempt %d: expected retry after %v, got %v (retry %v)", i+1, expected, delay, ok)
+				}
+			}
+			if _, ok := tt.policy.Next(tt.policy.MaxAttempts, errTransient); ok {
+				t.Error("Expected no retry once MaxAttempts is reached")
+			}
+		})
+	}
+}
+
+func TestPolicy_NextFilters(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Second}
+
+	if _, ok := policy.Next(1, nil); ok {
+		t.Error("Expected no retry without an error")
+	}
+	if _, ok := policy.Next(1, context.Canceled); ok {
+		t.Error("Expected cancellation not to be retried by default")
+	}
+	if _, ok := policy.Next(1, Stop(errTransient)); ok {
+		t.Error("Expected a stopped error not to be retried")
+	}
+
+	policy.Retryable = func(err error) bool { return err != errTransient }
+	if _, ok := policy.Next(1, errTransient); ok {
+		t.Error("Expected the Retryable filter to be applied")
+	}
+}
+
+func TestPolicy_Jitter(t *testing.T) {
+	policy := Policy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: true}
+	varied := false
+	for i := 0; i < 50; i++ {
+		delay, ok := policy.Next(3, errTransient)
+		if !ok || delay < 0 || delay > 400*time.Millisecond {
+			t.Fatalf("Expected a jittered delay within [0, 400ms], got %v", delay)
+		}
+		if delay != 400*time.Millisecond {
+			varied = true
+		}
+	}
+	if !varied {
+		t.Error("Expected jitter to vary the delay")
+	}
+}
+
+func TestPolicy_ClassesAndRetryAfter(t *testing.T) {
+	policy := Policy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+		Multiplier:  2,
+		Classes: map[string]Class{
+			"auth":       {MaxAttempts: 1},
+			"rate_limit": {BaseDelay: 10 * time.Second},
+		},
+	}
+
+	if _, ok := policy.Next(1, &classifiedError{class: "auth"}); ok {
+		t.Error("Expected the auth class never to be retried")
+	}
+	if delay, _ := policy.Next(2, &classifiedError{class: "rate_limit"}); delay != 20*time.Second {
+		t.Errorf("Expected the rate limit class to back off from its own base, got %v", delay)
+	}
+
+	hinted := &classifiedError{class: "rate_limit", hint: 45 * time.Second}
+	if delay, _ := policy.Next(1, hinted); delay != 10*time.Second {
+		t.Errorf("Expected the hint to be ignored unless respected, got %v", delay)
+	}
+	policy.RespectRetryAfter = true
+	if delay, _ := policy.Next(1, hinted); delay != 45*time.Second {
+		t.Errorf("Expected the server's hint, got %v", delay)
+	}
+	hinted.hint = 2 * time.Hour
+	if delay, _ := policy.Next(1, hinted); delay != time.Minute {
+		t.Errorf("Expected the hint to be capped at MaxDelay, got %v", delay)
+	}
+}
+
+func TestDo_StatsAndEvents(t *testing.T) {
+	var events []Event
+	policy := Policy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Millisecond,
+		OnRetry:     func(event Event) { events = append(events, event) },
+	}
+
+	calls := 0
+	stats, err := Do(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return &classifiedError{class: "server"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success on the third attempt: %v", err)
+	}
+	if stats.Attempts != 3 || stats.TotalWait != 2*time.Millisecond || stats.Cancelled {
+		t.Errorf("Expected 3 attempts and 2ms of waiting, got %+v", stats)
+	}
+	if len(events) != 2 || events[1].Attempt != 2 || events[1].Class != "server" {
+		t.Errorf("Expected an event per retry, got %+v", events)
+	}
+
+	// A stopped error is returned unwrapped without retrying
+	stats, err = Do(context.Background(), policy, func(ctx context.Context) error {
+		return Stop(errTransient)
+	})
+	if err != errTransient || stats.Attempts != 1 {
+		t.Errorf("Expected one attempt returning the stopped error, got %v after %d", err, stats.Attempts)
+	}
+}
+
+func TestDo_CancelledDuringWait(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Hour, Clock: clock}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	var stats Stats
+	var err error
+	go func() {
+		stats, err = Do(ctx, policy, func(ctx context.Context) error { return errTransient })
+		close(done)
+	}()
+
+	for clock.PendingTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if !errors.Is(err, context.Canceled) || !stats.Cancelled || stats.Attempts != 1 {
+		t.Errorf("Expected the wait to be cancelled after one attempt, got %v with %+v", err, stats)
+	}
+}
+
+func TestDo_WaitsOnClock(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	policy := Policy{MaxAttempts: 2, BaseDelay: time.Minute, Clock: clock}
+
+	done := make(chan struct{})
+	var stats Stats
+	go func() {
+		stats, _ = Do(context.Background(), policy, func(ctx context.Context) error { return errTransient })
+		close(done)
+	}()
+
+	for clock.PendingTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+	<-done
+
+	if stats.Attempts != 2 || stats.TotalWait != time.Minute {
+		t.Errorf("Expected a retry after the fake minute, got %+v", stats)
+	}
+}
@@ -282,12 +282,14 @@ func BenchmarkStorageQueryByType(b *testing.B) {
 	})
 }
 
-func BenchmarkStorageGraphTraversal(b *testing.B) {
+// newChainStore returns a store holding a chain of 100 nodes, each with a
+// "connects" edge to the next, and the nodes' IDs in chain order.
+func newChainStore(b *testing.B) (*storage.Store, []string) {
 	tmpDir, err := os.MkdirTemp("", "bench-storage-graph-traversal-")
 	if err != nil {
 		b.Fatalf("Failed to create temp dir: %v", err)
 	}
-	defer os.RemoveAll(tmpDir)
+	b.Cleanup(func() { os.RemoveAll(tmpDir) })
 
 	store, err := storage.NewStore(tmpDir)
 	if err != nil {
@@ -331,16 +333,100 @@ func BenchmarkStorageGraphTraversal(b *testing.B) {
 			}
 		}
 	}
+	return store, nodeIDs
+}
+
+func BenchmarkStorageGraphTraversal(b *testing.B) {
+	store, nodeIDs := newChainStore(b)
 
 	recordBenchmark(b, "Storage_Graph_Traversal", func() {
 		sourceID := nodeIDs[rand.Intn(len(nodeIDs))]
-		_, err := store.GetNeighbors(context.Background(), sourceID)
+		_, err := store.GetNeighbors(context.Background(), sourceID, storage.NeighborOptions{})
 		if err != nil {
 			b.Fatalf("Failed to get neighbors: %v", err)
 		}
 	})
 }
 
+// postFilteredSuccessors finds the nodes up to depth "connects" edges ahead
+// of sourceID the way callers did before GetNeighbors took options: every
+// neighbor of each node reached, kept if an outgoing edge leads to it.
+func postFilteredSuccessors(b *testing.B, store *storage.Store, sourceID string, depth int) {
+	ctx := context.Background()
+	frontier := []string{sourceID}
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		var next []string
+		for _, nodeID := range frontier {
+			neighbors, err := store.GetNeighbors(ctx, nodeID, storage.NeighborOptions{})
+			if err != nil {
+				b.Fatalf("Failed to get neighbors: %v", err)
+			}
+			edges, err := store.Edges().OfType("connects").FromNode(nodeID).AllContext(ctx)
+			if err != nil {
+				b.Fatalf("Failed to get edges: %v", err)
+			}
+			for _, neighbor := range neighbors.Nodes {
+				for _, edge := range edges {
+					if edge.TargetID == neighbor.ID {
+						next = append(next, neighbor.ID)
+						break
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+}
+
+// BenchmarkStorageGraphTraversalFiltered compares finding the nodes a few
+// "connects" edges ahead on the chain by post-filtering every neighbor, as
+// callers did before, with asking GetNeighbors for them.
+func BenchmarkStorageGraphTraversalFiltered(b *testing.B) {
+	store, nodeIDs := newChainStore(b)
+	opts := storage.NeighborOptions{Direction: storage.DirectionOutgoing, EdgeTypes: []string{"connects"}}
+
+	for _, depth := range []int{1, 5} {
+		b.Run(fmt.Sprintf("PostFiltered_Depth%d", depth), func(b *testing.B) {
+			recordBenchmark(b, fmt.Sprintf("Storage_Graph_Traversal_PostFiltered_Depth%d", depth), func() {
+				postFilteredSuccessors(b, store, nodeIDs[rand.Intn(len(nodeIDs))], depth)
+			})
+		})
+		b.Run(fmt.Sprintf("Options_Depth%d", depth), func(b *testing.B) {
+			opts := opts
+			opts.MaxDepth = depth
+			recordBenchmark(b, fmt.Sprintf("Storage_Graph_Traversal_Options_Depth%d", depth), func() {
+				if _, err := store.GetNeighbors(context.Background(), nodeIDs[rand.Intn(len(nodeIDs))], opts); err != nil {
+					b.Fatalf("Failed to get neighbors: %v", err)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkStorageSearch(b *testing.B) {
+	// 5% of the scale test workspace, with the same shape
+	ws := NewScaleWorkspace(b, DefaultScaleSpec().Scaled(0.05))
+
+	recordBenchmark(b, "Storage_Search", func() {
+		_, err := ws.Store.Search(context.Background(), ws.SearchTerm, storage.SearchOptions{})
+		if err != nil {
+			b.Fatalf("Failed to search: %v", err)
+		}
+	})
+}
+
+func BenchmarkStorageListObjectivesByGoal(b *testing.B) {
+	ws := NewScaleWorkspace(b, DefaultScaleSpec().Scaled(0.05))
+
+	recordBenchmark(b, "Storage_List_Objectives_By_Goal", func() {
+		goalID := ws.GoalIDs[rand.Intn(len(ws.GoalIDs))]
+		_, err := ws.ObjectiveManager.ListObjectives(context.Background(), core.ObjectiveFilter{GoalID: &goalID})
+		if err != nil {
+			b.Fatalf("Failed to list objectives: %v", err)
+		}
+	})
+}
+
 // ====== MANAGER LAYER BENCHMARKS ======
 
 func BenchmarkGoalManagerCreate(b *testing.B) {
@@ -807,11 +893,42 @@ func BenchmarkMethodCacheCreate(b *testing.B) {
 }
 
 func BenchmarkMethodCacheFind(b *testing.B) {
+	benchmarkMethodCacheFind(b, "MethodCache_Find", nil)
+}
+
+// BenchmarkMethodCacheFindSemantic finds methods by embedding similarity
+// rather than shared words.
+func BenchmarkMethodCacheFindSemantic(b *testing.B) {
+	benchmarkMethodCacheFind(b, "MethodCache_FindSemantic", benchEmbeddings{})
+}
+
+// benchEmbeddings embeds text by hashing its words into a fixed number of
+// dimensions, as fast as a cached embedding would be.
+type benchEmbeddings struct{}
+
+func (benchEmbeddings) Embed(ctx context.Context, text string) ([]float64, error) {
+	embedding := make([]float64, 384)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		hash := 0
+		for _, char := range word {
+			hash = 31*hash + int(char)
+		}
+		if hash < 0 {
+			hash = -hash
+		}
+		embedding[hash%len(embedding)]++
+	}
+	return embedding, nil
+}
+
+// benchmarkMethodCacheFind records finding methods for objectives in a cache
+// of 100 methods, matched by embeddings when given.
+func benchmarkMethodCacheFind(b *testing.B, name string, embeddings core.EmbeddingProvider) {
 	fixtures := NewBenchFixtures(b)
 	ctx := context.Background()
 
 	// Create and populate method cache
-	cache := core.NewMethodCache(fixtures.Store, nil, core.DefaultCacheConfig())
+	cache := core.NewMethodCache(fixtures.Store, embeddings, core.DefaultCacheConfig())
 
 	// Pre-populate cache with methods
 	for i := 0; i < 100; i++ {
@@ -863,7 +980,7 @@ func BenchmarkMethodCacheFind(b *testing.B) {
 		"plan project timeline",
 	}
 
-	recordBenchmark(b, "MethodCache_Find", func() {
+	recordBenchmark(b, name, func() {
 		query := queries[rand.Intn(len(queries))]
 
 		// Use the cache query interface
@@ -20,12 +20,13 @@ type MockLLMServiceCLI struct{}
 // Execute implements the LLMServiceInterface for testing.
 func (m *MockLLMServiceCLI) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
 	// Return structured ethical reasoning response format
-	ethicalResponse := `Freedom Impact: 0.8
-Well-Being Impact: 0.7
-Sustainability Impact: 0.6
-Confidence: 0.9
-Reasoning: This is a mock ethical reasoning response for testing purposes.
-The decision appears to have positive impact across all dimensions.`
+	ethicalResponse := `{
+  "freedom_impact": 0.8,
+  "well_being_impact": 0.7,
+  "sustainability_impact": 0.6,
+  "confidence": 0.9,
+  "reasoning": "This is a mock ethical reasoning response for testing purposes. The decision appears to have positive impact across all dimensions."
+}`
 
 	return mcp.ServiceResult{
 		Success: true,
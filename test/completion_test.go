@@ -0,0 +1,334 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/internal/completion"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// completionFixture holds the entities the completion tests complete.
+type completionFixture struct {
+	dataDir         string
+	goals           []*core.Goal
+	objective       *core.Objective
+	method          *core.Method
+	pendingDecision *core.EthicalDecision
+	settledDecision *core.EthicalDecision
+	rule            *core.ApprovalRule
+}
+
+// newCompletionFixture stores two goals, an objective, a method, a pending and
+// a settled decision, and an approval rule, then closes the store.
+func newCompletionFixture(t *testing.T) *completionFixture {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	store, err := storage.NewStore(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	fixture := &completionFixture{dataDir: dataDir}
+	goalManager := core.NewGoalManager(store)
+	for _, title := range []string{"Learn Go", "Write a book"} {
+		goal, err := goalManager.CreateGoal(ctx, title, "", 5, nil)
+		if err != nil {
+			t.Fatalf("Failed to create goal: %v", err)
+		}
+		fixture.goals = append(fixture.goals, goal)
+	}
+
+	fixture.method, err = core.NewMethodManager(store).CreateMethod(ctx, "Spaced reading", "Read a chapter a day",
+		[]core.ApproachStep{{Description: "Read", Tools: []string{}}}, core.MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	fixture.objective, err = core.NewObjectiveManager(store).CreateObjective(ctx, fixture.goals[0].ID, fixture.method.ID,
+		"Finish the tour", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+
+	ethical := core.NewEthicalFramework(store, llm.NewRouter(NewMockLLMService()), core.NewUserContextManager(store))
+	fixture.pendingDecision, err = ethical.EvaluateDecision(ctx, fixture.objective.ID, "Trim the settings page",
+		"Hide advanced settings to restrict choices", nil, "completion-user")
+	if err != nil || !fixture.pendingDecision.IsPendingApproval() {
+		t.Fatalf("Failed to create a pending decision: %v", err)
+	}
+	fixture.settledDecision, err = ethical.EvaluateDecision(ctx, fixture.objective.ID, "Add a shortcut",
+		"Add a keyboard shortcut for search", nil, "completion-user")
+	if err != nil || fixture.settledDecision.IsPendingApproval() {
+		t.Fatalf("Failed to create a settled decision: %v", err)
+	}
+
+	fixture.rule, err = ethical.Rules().CreateRule(ctx, &core.ApprovalRule{
+		Verb:      "write",
+		Service:   "filesystem",
+		ScopeKind: core.ApprovalScopePathPrefix,
+		Scope:     "/home/me/notes",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	return fixture
+}
+
+// cliCompletionSpec mirrors the shape of the CLI's ID-taking commands, whose
+// table lives in package main.
+func cliCompletionSpec() completion.Spec {
+	return completion.Spec{
+		Globals: []completion.Flag{{Name: "-config", TakesValue: true}, {Name: "-data", TakesValue: true}, {Name: "-verbose"}},
+		Commands: []completion.Command{
+			{Name: "create-goal", Description: "Create a new goal"},
+			{Name: "create-objective", Description: "Create a new objective for a goal", Args: []completion.Arg{{Kind: completion.ArgGoal}}},
+			{
+				Name:  "decompose",
+				Args:  []completion.Arg{{Kind: completion.ArgGoal}},
+				Flags: []completion.Flag{{Name: "--max", TakesValue: true}, {Name: "--no-methods"}},
+			},
+			{Name: "preview", Args: []completion.Arg{{Kind: completion.ArgObjective}}},
+			{
+				Name: "methods",
+				Args: []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list", "playbook"}}, {Kind: completion.ArgMethod}},
+			},
+			{
+				Name:  "feedback",
+				Args:  []completion.Arg{{Kind: completion.ArgDecision}, {Kind: completion.ArgChoice, Words: []string{"approve", "reject"}}},
+				Flags: []completion.Flag{{Name: "--always"}, {Name: "--expires-days", TakesValue: true}},
+			},
+			{
+				Name: "rules",
+				Args: []completion.Arg{{Kind: completion.ArgChoice, Words: []string{"list", "revoke", "resume"}}, {Kind: completion.ArgRule}},
+			},
+			{Name: "help", Args: []completion.Arg{{Kind: completion.ArgCommand}}},
+		},
+	}
+}
+
+// candidateIDs returns the sorted IDs of candidates.
+func candidateIDs(candidates []completion.Candidate) []string {
+	ids := []string{}
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedIDs(ids ...string) []string {
+	sort.Strings(ids)
+	return ids
+}
+
+func TestCompletionArgumentPositions(t *testing.T) {
+	fixture := newCompletionFixture(t)
+	store, err := storage.NewStore(fixture.dataDir, storage.ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open store read-only: %v", err)
+	}
+	defer store.Close()
+	source := completion.NewStoreSource(store)
+	spec := cliCompletionSpec()
+
+	goalIDs := sortedIDs(fixture.goals[0].ID, fixture.goals[1].ID)
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"command names", []string{"cr"}, []string{"create-goal", "create-objective"}},
+		{"global flags", []string{"-d"}, []string{"-data"}},
+		{"goal for create-objective", []string{"create-objective", ""}, goalIDs},
+		{"goal by prefix", []string{"create-objective", fixture.goals[1].ID[:8]}, []string{fixture.goals[1].ID}},
+		{"free text after the goal", []string{"create-objective", fixture.goals[0].ID, ""}, []string{}},
+		{"goal for decompose", []string{"decompose", ""}, goalIDs},
+		{"goal after a flag value", []string{"decompose", "--max", "3", ""}, goalIDs},
+		{"flag value is not completed", []string{"decompose", "--max", ""}, []string{}},
+		{"command flags", []string{"decompose", "--"}, []string{"--max", "--no-methods"}},
+		{"objective for preview", []string{"preview", ""}, []string{fixture.objective.ID}},
+		{"subcommand", []string{"methods", "p"}, []string{"playbook"}},
+		{"method for playbook", []string{"methods", "playbook", ""}, []string{fixture.method.ID}},
+		{"only pending decisions", []string{"feedback", ""}, []string{fixture.pendingDecision.ID}},
+		{"feedback action", []string{"feedback", fixture.pendingDecision.ID, ""}, []string{"approve", "reject"}},
+		{"rule for revoke", []string{"rules", "revoke", ""}, []string{fixture.rule.ID}},
+		{"command for help", []string{"help", "pre"}, []string{"preview"}},
+		{"unknown command", []string{"frobnicate", ""}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := spec.Complete(context.Background(), source, tt.words)
+			if err != nil {
+				t.Fatalf("Complete failed: %v", err)
+			}
+			if got := candidateIDs(candidates); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// Entity candidates carry their title for shells that show descriptions
+	candidates, err := spec.Complete(context.Background(), source, []string{"preview", ""})
+	if err != nil || len(candidates) != 1 {
+		t.Fatalf("Expected one objective, got %v, %v", candidates, err)
+	}
+	if line := candidates[0].String(); line != fixture.objective.ID+"\tFinish the tour" {
+		t.Errorf("Unexpected candidate line %q", line)
+	}
+}
+
+func TestCompletionIsReadOnly(t *testing.T) {
+	fixture := newCompletionFixture(t)
+	before := snapshotFiles(t, fixture.dataDir)
+
+	store, err := storage.NewStore(fixture.dataDir, storage.ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open store read-only: %v", err)
+	}
+	for _, words := range [][]string{{"create-objective", ""}, {"feedback", ""}, {"rules", "resume", ""}} {
+		if _, err := cliCompletionSpec().Complete(context.Background(), completion.NewStoreSource(store), words); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+	store.Close()
+
+	if after := snapshotFiles(t, fixture.dataDir); strings.Join(after, "\n") != strings.Join(before, "\n") {
+		t.Errorf("Completion changed the data directory:\nbefore %v\nafter  %v", before, after)
+	}
+
+	// Before anything has been stored there is nothing to complete, and nothing is created
+	missing := filepath.Join(t.TempDir(), "not-yet")
+	empty, err := storage.NewStore(missing, storage.ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open missing data directory: %v", err)
+	}
+	candidates, err := cliCompletionSpec().Complete(context.Background(), completion.NewStoreSource(empty), []string{"preview", ""})
+	if err != nil || len(candidates) != 0 {
+		t.Errorf("Expected no candidates, got %v, %v", candidates, err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("Expected completion not to create the data directory")
+	}
+}
+
+// snapshotFiles lists every file under dir with its size and modification time.
+func snapshotFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, fmt.Sprintf("%s %d %s", path, info.Size(), info.ModTime().Format(time.RFC3339Nano)))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", dir, err)
+	}
+	return files
+}
+
+func TestResolveIDPrefix(t *testing.T) {
+	candidates := []completion.Candidate{
+		{ID: "3f2a9c10-aaaa", Title: "Learn Go"},
+		{ID: "3f2b0000-bbbb", Title: "Write a book"},
+		{ID: "3f2", Title: "Short ID"},
+		{ID: "9c01ffff-cccc", Title: "Plan a trip"},
+	}
+
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"full ID", "9c01ffff-cccc", "9c01ffff-cccc"},
+		{"unique prefix", "9c", "9c01ffff-cccc"},
+		{"longer unique prefix", "3f2a", "3f2a9c10-aaaa"},
+		{"exact match beats longer IDs", "3f2", "3f2"},
+		{"no match is left for the caller", "ffff", "ffff"},
+		{"empty argument", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := completion.Resolve(candidates, tt.arg)
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	_, err := completion.Resolve(candidates, "3f")
+	var ambiguous *completion.AmbiguousError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("Expected an ambiguity error, got %v", err)
+	}
+	if len(ambiguous.Matches) != 3 {
+		t.Errorf("Expected 3 candidates, got %v", ambiguous.Matches)
+	}
+	for _, want := range []string{`"3f"`, "3f2a9c10-aaaa  Learn Go", "3f2b0000-bbbb  Write a book", "3f2  Short ID"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to list %q, got:\n%s", want, err)
+		}
+	}
+}
+
+func TestResolveIDPrefixFromStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Seventeen goals: at least two share the first hex digit of their ID
+	goalManager := core.NewGoalManager(store)
+	byFirstDigit := make(map[byte][]string)
+	for i := 0; i < 17; i++ {
+		goal, err := goalManager.CreateGoal(ctx, "Goal", "", 5, nil)
+		if err != nil {
+			t.Fatalf("Failed to create goal: %v", err)
+		}
+		byFirstDigit[goal.ID[0]] = append(byFirstDigit[goal.ID[0]], goal.ID)
+	}
+
+	candidates, err := completion.NewStoreSource(store).Candidates(ctx, completion.ArgGoal)
+	if err != nil {
+		t.Fatalf("Failed to list goals: %v", err)
+	}
+	for digit, ids := range byFirstDigit {
+		got, err := completion.Resolve(candidates, string(digit))
+		if len(ids) == 1 {
+			if err != nil || got != ids[0] {
+				t.Errorf("Expected %q to resolve to %s, got %q, %v", digit, ids[0], got, err)
+			}
+			continue
+		}
+		var ambiguous *completion.AmbiguousError
+		if !errors.As(err, &ambiguous) || len(ambiguous.Matches) != len(ids) {
+			t.Errorf("Expected %q to be ambiguous between %v, got %q, %v", digit, ids, got, err)
+		}
+		for _, id := range ids {
+			if got, err := completion.Resolve(candidates, id[:8]); err != nil || got != id {
+				t.Errorf("Expected %s to resolve to %s, got %q, %v", id[:8], id, got, err)
+			}
+		}
+	}
+}
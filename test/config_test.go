@@ -3,7 +3,9 @@ package test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Solifugus/ai-work-studio/internal/config"
 )
@@ -122,13 +124,10 @@ func TestConfigManager(t *testing.T) {
 			t.Fatalf("Failed to load config with env overrides: %v", err)
 		}
 
-		// Verify environment overrides
-		if cfg.API.Anthropic.APIKey != "test-key-anthropic" {
-			t.Errorf("Expected Anthropic API key 'test-key-anthropic', got %q", cfg.API.Anthropic.APIKey)
-		}
-
-		if cfg.API.OpenAI.APIKey != "test-key-openai" {
-			t.Errorf("Expected OpenAI API key 'test-key-openai', got %q", cfg.API.OpenAI.APIKey)
+		// Verify environment overrides; API keys are never copied in, so a
+		// save cannot write them to disk
+		if keys := cfg.CredentialKeys(); len(keys) != 0 {
+			t.Errorf("Expected the environment's API keys left out of the config, got %d", len(keys))
 		}
 
 		if cfg.API.DefaultProvider != "local" {
@@ -248,6 +247,18 @@ func TestConfigValidation(t *testing.T) {
 		if err := cfg.Validate(); err == nil {
 			t.Error("Expected validation error when daily*30 > monthly")
 		}
+
+		// Test negative weekly limit and unknown time zone
+		cfg = config.DefaultConfig()
+		cfg.Budget.WeeklyLimit = -1.0
+		if err := cfg.Validate(); err == nil {
+			t.Error("Expected validation error for negative weekly limit")
+		}
+		cfg = config.DefaultConfig()
+		cfg.Budget.Timezone = "Mars/Olympus_Mons"
+		if err := cfg.Validate(); err == nil {
+			t.Error("Expected validation error for unknown budget timezone")
+		}
 	})
 
 	t.Run("InvalidPriority", func(t *testing.T) {
@@ -285,6 +296,41 @@ func TestConfigValidation(t *testing.T) {
 		}
 	})
 
+	t.Run("InvalidBackupSettings", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		cfg.Storage.BackupKeepDaily = -1
+		if err := cfg.Validate(); err == nil {
+			t.Error("Expected validation error for negative daily backups")
+		}
+
+		cfg = config.DefaultConfig()
+		cfg.Preferences.QuietHoursStart = "25:00"
+		cfg.Preferences.QuietHoursEnd = "06:00"
+		if err := cfg.Validate(); err == nil {
+			t.Error("Expected validation error for invalid quiet hours")
+		}
+
+		cfg.Preferences.QuietHoursStart = "22:00"
+		cfg.Preferences.QuietHoursEnd = ""
+		if err := cfg.Validate(); err == nil {
+			t.Error("Expected validation error for quiet hours without an end")
+		}
+	})
+
+	t.Run("InvalidWIPLimits", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		cfg.Preferences.MaxInProgressObjectives = -1
+		if err := cfg.Validate(); err == nil {
+			t.Error("Expected validation error for a negative work-in-progress limit")
+		}
+
+		cfg = config.DefaultConfig()
+		cfg.Preferences.GoalWIPLimits = map[string]int{"goal-1": -2}
+		if err := cfg.Validate(); err == nil {
+			t.Error("Expected validation error for a negative goal work-in-progress limit")
+		}
+	})
+
 	t.Run("EmptyRequiredFields", func(t *testing.T) {
 		cfg := config.DefaultConfig()
 		cfg.Storage.DataDir = ""
@@ -300,6 +346,264 @@ func TestConfigValidation(t *testing.T) {
 	})
 }
 
+// TestQuietHours tests the quiet-hours window, including one spanning midnight.
+func TestQuietHours(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatalf("Bad clock %q: %v", clock, err)
+		}
+		return parsed
+	}
+
+	var prefs config.PreferenceConfig
+	if prefs.HasQuietHours() || prefs.InQuietHours(at("03:00")) {
+		t.Error("Expected no quiet hours by default")
+	}
+
+	prefs.QuietHoursStart = "22:00"
+	prefs.QuietHoursEnd = "06:00"
+	for clock, want := range map[string]bool{"21:59": false, "22:00": true, "23:30": true, "03:00": true, "06:00": false, "12:00": false} {
+		if got := prefs.InQuietHours(at(clock)); got != want {
+			t.Errorf("InQuietHours(%s) = %v, want %v", clock, got, want)
+		}
+	}
+
+	prefs.QuietHoursStart = "01:00"
+	prefs.QuietHoursEnd = "05:00"
+	if !prefs.InQuietHours(at("02:00")) || prefs.InQuietHours(at("23:00")) {
+		t.Error("Unexpected result for a window within one day")
+	}
+}
+
+func TestConfigAPIKeys(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+	if keys := cfg.API.Keys(); len(keys) != 0 {
+		t.Errorf("Expected no keys by default, got %v", keys)
+	}
+
+	key := " sk-renewed\n"
+	if err := cfg.UpdateAPIKeys(configPath, config.APIKeyUpdates{OpenAI: &key}); err != nil {
+		t.Fatalf("Failed to update API keys: %v", err)
+	}
+	empty := "  "
+	if err := cfg.UpdateAPIKeys(configPath, config.APIKeyUpdates{Anthropic: &empty}); err == nil {
+		t.Error("Expected an empty key to be refused")
+	}
+
+	reloaded, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	keys := reloaded.API.Keys()
+	if len(keys) != 1 || keys["openai"] != "sk-renewed" {
+		t.Errorf("Expected only the trimmed OpenAI key, got %v", keys)
+	}
+}
+
+func TestConfigCredentials(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+	key := "sk-legacy"
+	if err := cfg.UpdateAPIKeys(configPath, config.APIKeyUpdates{OpenAI: &key}); err != nil {
+		t.Fatalf("Failed to update API keys: %v", err)
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatalf("Failed to stat config: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("Expected a config holding keys saved as 0600, got %o", perm)
+	}
+	if warning := config.CredentialsFileWarning(configPath, cfg); warning != "" {
+		t.Errorf("Expected no warning for a private file, got %q", warning)
+	}
+
+	// The credentials section overrides the older api.<provider>.api_key
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	content = append(content, []byte("\n[credentials]\n  openai = \"sk-section\"\n  anthropic = \"sk-anthropic\"\n")...)
+	if err := os.WriteFile(configPath, content, 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.Chmod(configPath, 0o644); err != nil {
+		t.Fatalf("Failed to chmod config: %v", err)
+	}
+	reloaded, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	keys := reloaded.CredentialKeys()
+	if len(keys) != 2 || keys["openai"] != "sk-section" || keys["anthropic"] != "sk-anthropic" {
+		t.Errorf("Expected the credentials section's keys, got %d keys", len(keys))
+	}
+	warning := config.CredentialsFileWarning(configPath, reloaded)
+	if !strings.Contains(warning, "chmod 600") || strings.Contains(warning, "sk-") {
+		t.Errorf("Expected a world-readable file warned about without its keys, got %q", warning)
+	}
+}
+
+func TestConfigNetworkAllowlist(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+	if cfg.Network.Mode != "audit" {
+		t.Errorf("Expected audit mode by default, got %q", cfg.Network.Mode)
+	}
+	seeded := strings.Join(cfg.Network.Allowlist, ",")
+	if seeded != "api.anthropic.com,api.openai.com" {
+		t.Errorf("Expected the provider hosts to be seeded, got %q", seeded)
+	}
+
+	enforce := "enforce"
+	updates := config.NetworkUpdates{Mode: &enforce, Allow: []string{"https://hooks.example.com/notify"}, Disallow: []string{"api.openai.com"}}
+	if err := cfg.UpdateNetwork(configPath, updates); err != nil {
+		t.Fatalf("Failed to update network settings: %v", err)
+	}
+	invalid := "block"
+	if err := cfg.UpdateNetwork(configPath, config.NetworkUpdates{Mode: &invalid}); err == nil {
+		t.Error("Expected an unknown mode to be refused")
+	}
+
+	reloaded, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if reloaded.Network.Mode != "enforce" {
+		t.Errorf("Expected enforce mode to be saved, got %q", reloaded.Network.Mode)
+	}
+	if got := strings.Join(reloaded.Network.Allowlist, ","); got != "api.anthropic.com,hooks.example.com" {
+		t.Errorf("Expected the edited allowlist to be saved without reseeding, got %q", got)
+	}
+}
+
+func TestConfigLowMemoryProfile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+
+	// Files written before profiles existed load as standard
+	if err := config.DefaultConfig().Save(configPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	legacy := strings.Split(string(data), "[profile]")[0]
+	if err := os.WriteFile(configPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Profile.Name != config.ProfileStandard || cfg.Profile.StoreOptions() != nil || cfg.Profile.MemoryCeiling() != 0 {
+		t.Errorf("Expected the standard profile without a memory budget, got %+v", cfg.Profile)
+	}
+	for _, feature := range cfg.Profile.Features() {
+		if feature.Degraded != "" {
+			t.Errorf("Expected nothing degraded in the standard profile, got %s: %s", feature.Name, feature.Degraded)
+		}
+	}
+
+	t.Setenv("AI_WORK_STUDIO_PROFILE", config.ProfileLowMemory)
+	cfg, err = config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	profile := cfg.Profile
+	if !profile.LowMemory() || len(profile.StoreOptions()) != 1 || profile.WorkerLimit(3) != 1 {
+		t.Errorf("Expected the environment to select the low-memory profile, got %+v", profile)
+	}
+	if profile.MemoryCeiling() != 256<<20 || profile.RefreshInterval() != 5*time.Minute {
+		t.Errorf("Expected a 256 MB budget and 5 minute refresh, got %d and %s", profile.MemoryCeiling(), profile.RefreshInterval())
+	}
+	degraded := 0
+	for _, feature := range profile.Features() {
+		if feature.Degraded != "" {
+			degraded++
+		}
+	}
+	if degraded != 5 {
+		t.Errorf("Expected every feature to describe its degradation, got %d", degraded)
+	}
+
+	profile.MemoryLimitMB = 128
+	if profile.MemoryCeiling() != 128<<20 {
+		t.Errorf("Expected a configured budget to win, got %d", profile.MemoryCeiling())
+	}
+
+	cfg.Profile.Name = "tiny"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an unknown profile to be refused")
+	}
+}
+
+func TestConfigReplication(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+	if cfg.Replication.IsReplica() || cfg.Replication.Listen != "" {
+		t.Errorf("Expected replication to be off by default, got %+v", cfg.Replication)
+	}
+
+	// A token is required off localhost, on either side
+	cfg.Replication = config.ReplicationConfig{Listen: "0.0.0.0:7420"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected listening on all interfaces without a token to be refused")
+	}
+	cfg.Replication.Token = "secret"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected listening with a token to be accepted: %v", err)
+	}
+	cfg.Replication = config.ReplicationConfig{Primary: "studio://pi.local:7420"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a remote primary without a token to be refused")
+	}
+	cfg.Replication = config.ReplicationConfig{Listen: "127.0.0.1:7420", Primary: "studio://127.0.0.1:7421"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected serving and following at once to be refused")
+	}
+
+	// The primary is reachable in enforce mode once configured by hand
+	cfg.Replication = config.ReplicationConfig{Primary: "studio://secret@pi.local"}
+	if err := cfg.Save(configPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	reloaded, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if !reloaded.Replication.IsReplica() {
+		t.Fatalf("Expected the primary to be saved, got %+v", reloaded.Replication)
+	}
+	if !strings.Contains(strings.Join(reloaded.Network.Allowlist, ","), "pi.local:7420") {
+		t.Errorf("Expected the primary to be allowlisted, got %v", reloaded.Network.Allowlist)
+	}
+	if dir := config.ReplicaDir(reloaded.DataDir); filepath.Dir(dir) != reloaded.DataDir {
+		t.Errorf("Expected the replica to be kept under the data directory, got %s", dir)
+	}
+}
+
 // TestConfigPath tests configuration path detection.
 func TestConfigPath(t *testing.T) {
 	t.Run("DefaultPath", func(t *testing.T) {
@@ -340,6 +644,155 @@ func TestConfigPath(t *testing.T) {
 }
 
 // Helper functions for pointer conversions
+// writeConfigEdit saves cfg to configPath with replace applied to its
+// text, the way a user editing the file by hand would change it.
+func writeConfigEdit(t *testing.T, configPath string, replace ...string) {
+	t.Helper()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	edited := strings.NewReplacer(replace...).Replace(string(data))
+	if edited == string(data) {
+		t.Fatalf("Edit %q changed nothing", replace)
+	}
+	if err := os.WriteFile(configPath, []byte(edited), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+func TestConfigRejectsInvalidFiles(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+	if err := cfg.UpdatePreferences(configPath, config.PreferenceUpdates{}); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	saved, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		replace []string
+		want    string
+	}{
+		{"unknown key", []string{"daily_limit = 5.0", "daily_limmit = 5.0"}, "budget.daily_limmit"},
+		{"wrong type", []string{"daily_limit = 5.0", `daily_limit = "five"`}, "daily_limit"},
+		{"negative budget", []string{"daily_limit = 5.0", "daily_limit = -1.0"}, "cannot be negative"},
+		{"log level", []string{`log_level = ""`, `log_level = "loud"`}, "invalid log level"},
+		{"routing policy", []string{`policy = "balanced"`, `policy = "thrifty"`}, "thrifty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfigEdit(t, configPath, tt.replace...)
+			defer os.WriteFile(configPath, saved, 0600)
+
+			_, err := config.Load(configPath)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// Log levels are accepted in any case
+	writeConfigEdit(t, configPath, `log_level = ""`, `log_level = "Warning"`)
+	reloaded, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if level := reloaded.Preferences.Verbosity(); level != "WARNING" {
+		t.Errorf("Expected the warning level, got %s", level)
+	}
+}
+
+func TestConfigWatch(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+	if err := cfg.UpdatePreferences(configPath, config.PreferenceUpdates{}); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	watcher, err := config.NewWatcher(configPath)
+	if err != nil {
+		t.Fatalf("Failed to watch config: %v", err)
+	}
+	var applied []*config.Config
+	var rejected []error
+	watcher.OnChange(func(next *config.Config) { applied = append(applied, next) })
+	watcher.OnReject(func(err error) { rejected = append(rejected, err) })
+
+	if changed, err := watcher.Check(); changed || err != nil {
+		t.Fatalf("Expected no change before the file is edited, got %v, %v", changed, err)
+	}
+
+	writeConfigEdit(t, configPath, "daily_limit = 5.0", "daily_limit = 4.0", `policy = "balanced"`, `policy = "cost-saver"`)
+	if changed, err := watcher.Check(); !changed || err != nil {
+		t.Fatalf("Expected the edit to be applied, got %v, %v", changed, err)
+	}
+	if len(applied) != 1 || applied[0].BudgetLimits.DailyLimit != 4 || applied[0].Routing.Policy != "cost-saver" {
+		t.Fatalf("Expected the new limit and policy delivered, got %+v", applied)
+	}
+
+	// A file that does not validate is rejected once and the last good
+	// configuration stays in effect
+	writeConfigEdit(t, configPath, "daily_limit = 4.0", "daily_limit = -3.0")
+	changed, err := watcher.Check()
+	if changed || err == nil || !strings.Contains(err.Error(), "keeping the previous configuration") {
+		t.Fatalf("Expected the negative limit rejected, got %v, %v", changed, err)
+	}
+	if changed, err := watcher.Check(); changed || err != nil {
+		t.Errorf("Expected the rejected file reported once, got %v, %v", changed, err)
+	}
+	if len(applied) != 1 || len(rejected) != 1 || watcher.Config().BudgetLimits.DailyLimit != 4 {
+		t.Errorf("Expected the last good configuration kept, got %d applied, %d rejected, daily limit %.2f",
+			len(applied), len(rejected), watcher.Config().BudgetLimits.DailyLimit)
+	}
+
+	writeConfigEdit(t, configPath, "daily_limit = -3.0", "daily_limit = 3.0")
+	if changed, err := watcher.Check(); !changed || err != nil || watcher.Config().BudgetLimits.DailyLimit != 3 {
+		t.Errorf("Expected the fixed file applied, got %v, %v", changed, err)
+	}
+}
+
+func TestConfigUpdateRejectedKeepsConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+
+	loud := "loud"
+	if err := cfg.UpdatePreferences(configPath, config.PreferenceUpdates{LogLevel: &loud}); err == nil {
+		t.Fatal("Expected an unknown log level to be refused")
+	}
+	negative := -2.0
+	if err := cfg.UpdateBudgetLimits(configPath, config.BudgetUpdates{DailyLimit: &negative}); err == nil {
+		t.Fatal("Expected a negative limit to be refused")
+	}
+	if cfg.Preferences.LogLevel != "" || cfg.BudgetLimits.DailyLimit != 5 || cfg.Budget.DailyLimit != 5 {
+		t.Errorf("Expected refused updates to leave the configuration as it was, got log level %q and daily limit %.2f",
+			cfg.Preferences.LogLevel, cfg.BudgetLimits.DailyLimit)
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing saved, got %v", err)
+	}
+
+	debug := "DEBUG"
+	if err := cfg.UpdatePreferences(configPath, config.PreferenceUpdates{LogLevel: &debug}); err != nil {
+		t.Fatalf("Failed to update log level: %v", err)
+	}
+	if cfg.Preferences.Verbosity() != "DEBUG" {
+		t.Errorf("Expected the debug level, got %s", cfg.Preferences.Verbosity())
+	}
+}
+
 func floatToPtr(f float64) *float64 {
 	return &f
 }
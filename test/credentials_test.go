@@ -0,0 +1,117 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// TestCredentials_KeysNeverSerialized sends a sentinel API key to a provider
+// that rejects it and echoes it back, then checks it appears in no log,
+// audit record, error or budget report.
+func TestCredentials_KeysNeverSerialized(t *testing.T) {
+	const sentinel = "sk-ant-REDACTED"
+	ctx := context.Background()
+	tempDir := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key: %s"}}`, r.Header.Get("x-api-key"))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	service := mcp.NewLLMServiceWithProviders(logger, map[string]mcp.LLMProvider{
+		"anthropic": &mcp.AnthropicProvider{
+			APIKey:     sentinel,
+			BaseURL:    server.URL,
+			HTTPClient: server.Client(),
+			Models: map[string]mcp.ModelConfig{
+				"claude-3-haiku": {Name: "claude-3-haiku-20240307", InputCost: 0.25, OutputCost: 1.25, MaxTokens: 4096, ContextSize: 200000, SupportsChat: true, QualityTier: "standard", SpeedTier: 1},
+			},
+		},
+	})
+	service.SetRetryConfig(mcp.RetryConfig{})
+	auditDir := filepath.Join(tempDir, "audit")
+	auditLog, err := mcp.NewAuditLog(mcp.AuditConfig{Path: filepath.Join(auditDir, "llm-audit.jsonl")})
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
+	service.SetAuditLog(auditLog)
+
+	budget, err := llm.NewBudgetManager(filepath.Join(tempDir, "budget"), llm.BudgetConfig{DailyLimit: 1, TrackingEnabled: true}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	router := llm.NewRouter(service)
+	router.SetBudgetManager(budget)
+
+	var serialized []string
+	_, routeErr := router.Route(ctx, llm.TaskRequest{Prompt: "Summarize the meeting notes", TaskType: "generation"})
+	if routeErr == nil {
+		t.Fatal("Expected the rejected key to fail the request")
+	}
+	serialized = append(serialized, routeErr.Error())
+
+	result := service.Execute(ctx, mcp.ServiceParams{"operation": "complete", "provider": "anthropic", "model": "claude-3-haiku", "prompt": "Hello"})
+	if result.Error == nil {
+		t.Fatal("Expected the rejected key to fail the completion")
+	}
+	if !strings.Contains(result.Error.Error(), "[REDACTED]") {
+		t.Errorf("Expected the echoed key redacted, got %q", result.Error)
+	}
+	serialized = append(serialized, result.Error.Error())
+
+	for _, operation := range []string{"diagnose", "reload_credentials"} {
+		data, err := json.Marshal(service.Execute(ctx, mcp.ServiceParams{"operation": operation}).Data)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s: %v", operation, err)
+		}
+		serialized = append(serialized, string(data))
+	}
+
+	for _, format := range []llm.ReportFormat{llm.ReportFormatCSV, llm.ReportFormatJSON} {
+		report, err := budget.ExportReport(ctx, llm.ReportOptions{Format: format})
+		if err != nil {
+			t.Fatalf("ExportReport failed: %v", err)
+		}
+		serialized = append(serialized, report.Content)
+	}
+
+	entries, err := auditLog.Query(mcp.AuditFilter{})
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("Expected the failed calls audited, got %d entries (%v)", len(entries), err)
+	}
+	files, err := os.ReadDir(auditDir)
+	if err != nil {
+		t.Fatalf("Failed to list audit log: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(auditDir, file.Name()))
+		if err != nil {
+			t.Fatalf("Failed to read audit log: %v", err)
+		}
+		serialized = append(serialized, string(data))
+	}
+	serialized = append(serialized, logs.String())
+
+	for i, output := range serialized {
+		if strings.Contains(output, sentinel) {
+			t.Errorf("Output %d contains the API key: %s", i, output)
+		}
+	}
+}
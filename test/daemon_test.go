@@ -39,11 +39,13 @@ func (m *MockLLMServiceDaemon) Execute(ctx context.Context, params mcp.ServicePa
 	if operation, exists := params["operation"]; exists {
 		switch operation {
 		case "ethical_evaluation", "complete":
-			response := `Freedom Impact: 0.8
-Well-Being Impact: 0.7
-Sustainability Impact: 0.6
-Confidence: 0.9
-Reasoning: Automated execution appears to have positive impact. The objective can be safely executed in the background without user intervention.`
+			response := `{
+  "freedom_impact": 0.8,
+  "well_being_impact": 0.7,
+  "sustainability_impact": 0.6,
+  "confidence": 0.9,
+  "reasoning": "Automated execution appears to have positive impact. The objective can be safely executed in the background without user intervention."
+}`
 
 			return mcp.ServiceResult{
 				Success: true,
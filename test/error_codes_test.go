@@ -0,0 +1,218 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/internal/completion"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/netaudit"
+)
+
+// catalogedErrors holds an example of every exported error value, error type
+// and error constructor, with the code it must carry. A new exported error
+// fails TestExportedErrorsCarryCodes until it is added here with a code.
+var catalogedErrors = map[string]struct {
+	err  error
+	code errs.Code
+}{
+	"completion.AmbiguousError":         {&completion.AmbiguousError{Prefix: "a1"}, errs.Conflict},
+	"core.ErrComparisonNotInitiated":    {core.ErrComparisonNotInitiated, errs.PolicyBlocked},
+	"core.ErrComparisonOverBudget":      {core.ErrComparisonOverBudget, errs.BudgetExceeded},
+	"core.ErrComparisonSideEffects":     {core.ErrComparisonSideEffects, errs.PolicyBlocked},
+	"core.ErrInvalidDecisionTransition": {core.ErrInvalidDecisionTransition, errs.Conflict},
+	"core.ErrPlanInvalid":               {core.ErrPlanInvalid, errs.Validation},
+	"core.ErrTaskNotApproved":           {core.ErrTaskNotApproved, errs.PolicyBlocked},
+	"core.ErrTimeBoxExceeded":           {core.ErrTimeBoxExceeded, errs.BudgetExceeded},
+	"core.ErrWIPLimitReached":           {core.ErrWIPLimitReached, errs.WIPLimit},
+	"core.ErrWorkLoopLocked":            {core.ErrWorkLoopLocked, errs.Conflict},
+	"core.WIPLimitError":                {&core.WIPLimitError{Limit: 1}, errs.WIPLimit},
+	"llm.BudgetExceededError":           {&llm.BudgetExceededError{Affordability: &llm.AffordabilityCheck{}}, errs.BudgetExceeded},
+	"llm.ErrBudgetExceeded":             {llm.ErrBudgetExceeded, errs.BudgetExceeded},
+	"llm.ErrNoProvidersConfigured":      {llm.ErrNoProvidersConfigured, errs.ProviderUnavailable},
+	"llm.ErrNoVocabulary":               {llm.ErrNoVocabulary, errs.NotFound},
+	"llm.ErrProviderRefused":            {llm.ErrProviderRefused, errs.PolicyBlocked},
+	"llm.ErrRoutingExpired":             {llm.ErrRoutingExpired, errs.NotFound},
+	"mcp.APIError":                      {&mcp.APIError{StatusCode: 401}, errs.ProviderAuth},
+	"mcp.ErrAuthFailed":                 {mcp.ErrAuthFailed, errs.ProviderAuth},
+	"mcp.ErrBudgetExceeded":             {mcp.ErrBudgetExceeded, errs.BudgetExceeded},
+	"mcp.ErrContextTooLarge":            {mcp.ErrContextTooLarge, errs.Validation},
+	"mcp.ErrInvalidResponse":            {mcp.ErrInvalidResponse, errs.Validation},
+	"mcp.ErrKeyringFailed":              {mcp.ErrKeyringFailed, errs.Internal},
+	"mcp.ErrKeyringNotFound":            {mcp.ErrKeyringNotFound, errs.NotFound},
+	"mcp.ErrKeyringUnavailable":         {mcp.ErrKeyringUnavailable, errs.ProviderUnavailable},
+	"mcp.ErrNoProvidersConfigured":      {mcp.ErrNoProvidersConfigured, errs.ProviderUnavailable},
+	"mcp.ErrProviderUnavailable":        {mcp.ErrProviderUnavailable, errs.ProviderUnavailable},
+	"mcp.ErrProviderUnhealthy":          {mcp.ErrProviderUnhealthy, errs.ProviderUnavailable},
+	"mcp.ErrRateLimited":                {mcp.ErrRateLimited, errs.QuotaExceeded},
+	"mcp.ErrTimeout":                    {mcp.ErrTimeout, errs.Timeout},
+	"mcp.NewValidationError":            {mcp.NewValidationError("path", "required"), errs.Validation},
+	"mcp.TimeoutError":                  {&mcp.TimeoutError{Timeout: time.Second}, errs.Timeout},
+	"mcp.ValidationError":               {mcp.ValidationError{Parameter: "path"}, errs.Validation},
+	"netaudit.BlockedError":             {&netaudit.BlockedError{Host: "example.com", Port: 443}, errs.PolicyBlocked},
+	"netaudit.ErrBlocked":               {netaudit.ErrBlocked, errs.PolicyBlocked},
+	"storage.ErrReadOnly":               {storage.ErrReadOnly, errs.ReadOnly},
+	"storage.ErrSchemaViolation":        {storage.ErrSchemaViolation, errs.Validation},
+	"storage.SchemaError":               {&storage.SchemaError{NodeType: "goal"}, errs.Validation},
+	"storage.ValidationError":           {storage.ValidationError{Type: "node"}, errs.Validation},
+}
+
+// TestExportedErrorsCarryCodes finds every exported Err* variable, *Error
+// type and New*Error constructor in the library packages and checks that
+// each is catalogued with the code it carries, even when wrapped.
+func TestExportedErrorsCarryCodes(t *testing.T) {
+	exported := exportedErrors(t, "../pkg", "../internal")
+	if len(exported) == 0 {
+		t.Fatal("Expected to find exported errors")
+	}
+
+	for _, name := range exported {
+		if _, ok := catalogedErrors[name]; !ok {
+			t.Errorf("%s has no entry in catalogedErrors: give it an error code and add it", name)
+		}
+	}
+
+	for name, entry := range catalogedErrors {
+		if !entry.code.Known() {
+			t.Errorf("%s expects %q, which is not in the catalogue", name, entry.code)
+		}
+		wrapped := fmt.Errorf("while testing: %w", entry.err)
+		if code := errs.CodeOf(wrapped); code != entry.code {
+			t.Errorf("%s carries code %q, expected %q", name, code, entry.code)
+		}
+	}
+}
+
+// exportedErrors returns "package.Name" for the exported error declarations
+// under dirs, skipping tests and the errs package itself.
+func exportedErrors(t *testing.T, dirs ...string) []string {
+	t.Helper()
+	var names []string
+	fset := token.NewFileSet()
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") ||
+				filepath.Base(filepath.Dir(path)) == "errs" {
+				return nil
+			}
+			file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+			if err != nil {
+				return err
+			}
+			for _, decl := range file.Decls {
+				for _, name := range errorDeclNames(decl) {
+					names = append(names, file.Name.Name+"."+name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to scan %s: %v", dir, err)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errorDeclNames returns the exported error names a declaration introduces.
+func errorDeclNames(decl ast.Decl) []string {
+	var names []string
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv == nil && decl.Name.IsExported() &&
+			strings.HasPrefix(decl.Name.Name, "New") && strings.HasSuffix(decl.Name.Name, "Error") {
+			names = append(names, decl.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				if decl.Tok != token.VAR {
+					continue
+				}
+				for _, ident := range spec.Names {
+					if ident.IsExported() && strings.HasPrefix(ident.Name, "Err") {
+						names = append(names, ident.Name)
+					}
+				}
+			case *ast.TypeSpec:
+				if spec.Name.IsExported() && strings.HasSuffix(spec.Name.Name, "Error") {
+					names = append(names, spec.Name.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// TestCodedErrorsSurviveWrapping checks codes on the main manager paths:
+// lookups of missing records and out-of-order status changes.
+func TestCodedErrorsSurviveWrapping(t *testing.T) {
+	store, err := storage.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	goals := core.NewGoalManager(store)
+	_, err = goals.GetGoal(ctx, "missing-goal")
+	if code := errs.CodeOf(err); code != errs.NotFound {
+		t.Errorf("Expected NOT_FOUND for a missing goal, got %q (%v)", code, err)
+	}
+	if details := errs.DetailsOf(err); details["node_id"] != "missing-goal" {
+		t.Errorf("Expected the missing ID in the details, got %v", details)
+	}
+	if !errors.Is(err, errs.NotFound) {
+		t.Error("Expected errors.Is to match the code")
+	}
+
+	if _, err := goals.CreateGoal(ctx, "", "", 5, nil); errs.CodeOf(err) != errs.Validation {
+		t.Errorf("Expected VALIDATION for an empty title, got %q (%v)", errs.CodeOf(err), err)
+	}
+
+	goal, err := goals.CreateGoal(ctx, "Ship the release", "", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	methods := core.NewMethodManager(store)
+	method, err := methods.CreateMethod(ctx, "Checklist", "", nil, core.MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	objectives := core.NewObjectiveManager(store)
+	objective, err := objectives.CreateObjective(ctx, goal.ID, method.ID, "Tag the build", "", nil, 5)
+	if err != nil {
+		t.Fatalf("Failed to create objective: %v", err)
+	}
+	_, err = objectives.CompleteObjective(ctx, objective.ID, core.ObjectiveResult{Success: true})
+	if code := errs.CodeOf(err); code != errs.Conflict {
+		t.Errorf("Expected CONFLICT completing a pending objective, got %q (%v)", code, err)
+	}
+
+	readOnly, err := storage.NewStore(t.TempDir(), storage.ReadOnly())
+	if err != nil {
+		t.Fatalf("Failed to open read-only store: %v", err)
+	}
+	defer readOnly.Close()
+	if _, err := core.NewGoalManager(readOnly).CreateGoal(ctx, "Blocked", "", 5, nil); errs.CodeOf(err) != errs.ReadOnly {
+		t.Errorf("Expected READ_ONLY on a read-only store, got %q (%v)", errs.CodeOf(err), err)
+	}
+}
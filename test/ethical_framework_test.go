@@ -23,33 +23,39 @@ type MockLLMService struct {
 func NewMockLLMService() *MockLLMService {
 	responses := map[string]*mcp.CompletionResponse{
 		"ethical_analysis": {
-			Text: `Freedom Impact: 0.8
-Well-Being Impact: 0.6
-Sustainability Impact: 0.4
-Confidence: 0.9
-Reasoning: This action enhances user autonomy by providing more control options, improves productivity through better organization, and maintains system efficiency with minimal resource overhead.`,
+			Text: `{
+  "freedom_impact": 0.8,
+  "well_being_impact": 0.6,
+  "sustainability_impact": 0.4,
+  "confidence": 0.9,
+  "reasoning": "This action enhances user autonomy by providing more control options, improves productivity through better organization, and maintains system efficiency with minimal resource overhead."
+}`,
 			TokensUsed: 200,
 			Model:      "mock-model",
 			Provider:   "mock",
 			Cost:       0.001,
 		},
 		"negative_ethical_analysis": {
-			Text: `Freedom Impact: -0.7
-Well-Being Impact: -0.5
-Sustainability Impact: -0.2
-Confidence: 0.8
-Reasoning: This action significantly restricts user choice and autonomy, creates stress and reduces productivity, and introduces some technical debt that may harm long-term maintainability.`,
+			Text: `{
+  "freedom_impact": -0.7,
+  "well_being_impact": -0.5,
+  "sustainability_impact": -0.2,
+  "confidence": 0.8,
+  "reasoning": "This action significantly restricts user choice and autonomy, creates stress and reduces productivity, and introduces some technical debt that may harm long-term maintainability."
+}`,
 			TokensUsed: 200,
 			Model:      "mock-model",
 			Provider:   "mock",
 			Cost:       0.001,
 		},
 		"low_confidence_analysis": {
-			Text: `Freedom Impact: 0.3
-Well-Being Impact: 0.2
-Sustainability Impact: 0.1
-Confidence: 0.4
-Reasoning: The impact of this action is unclear due to insufficient information and complex dependencies that make prediction difficult.`,
+			Text: `{
+  "freedom_impact": 0.3,
+  "well_being_impact": 0.2,
+  "sustainability_impact": 0.1,
+  "confidence": 0.4,
+  "reasoning": "The impact of this action is unclear due to insufficient information and complex dependencies that make prediction difficult."
+}`,
 			TokensUsed: 200,
 			Model:      "mock-model",
 			Provider:   "mock",
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/internal/selftest"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// TestGoalDecomposition proposes objectives for a goal through the router
+// using the self-test fake provider, with both clean and messy responses.
+func TestGoalDecomposition(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+
+	store, err := storage.NewStore(filepath.Join(tempDir, "data"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	goalManager := core.NewGoalManager(store)
+	goal, err := goalManager.CreateGoal(ctx, "Tame email", "Keep the inbox under control", 6, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+
+	methodManager := core.NewMethodManager(store)
+	method, err := methodManager.CreateMethod(ctx, "Inbox triage", "Sort new email into actions.",
+		[]core.ApproachStep{{Description: "Read new messages", Tools: []string{"email_read"}}},
+		core.MethodDomainUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := methodManager.UpdateMethodMetrics(ctx, method.ID, true, 8.0); err != nil {
+			t.Fatalf("Failed to record method success: %v", err)
+		}
+	}
+
+	contextManager := core.NewUserContextManager(store)
+	if _, err := contextManager.LearnContext(ctx, core.ContextCategoryPreferences, "Prefers to handle email in the morning",
+		core.ContextSourceExplicit, []string{"email", "inbox"}, "user-1"); err != nil {
+		t.Fatalf("Failed to add user context: %v", err)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	provider := selftest.NewFakeProvider()
+	service := mcp.NewLLMServiceWithProviders(logger, map[string]mcp.LLMProvider{
+		"anthropic": provider,
+		"openai":    provider,
+		"local":     provider,
+	})
+	service.SetRetryConfig(mcp.RetryConfig{
+		MaxRetries:  2,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+		BackoffRate: 2.0,
+	})
+
+	budget, err := llm.NewBudgetManager(filepath.Join(tempDir, "budget"), llm.BudgetConfig{
+		DailyLimit:      1.0,
+		WeeklyLimit:     7.0,
+		MonthlyLimit:    30.0,
+		TrackingEnabled: true,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	opts := core.DefaultDecompositionOptions()
+	opts.Router = llm.NewRouter(service)
+	opts.Budget = budget
+	opts.ContextManager = contextManager
+	opts.UserID = "user-1"
+
+	objectiveManager := core.NewObjectiveManager(store)
+
+	t.Run("clean", func(t *testing.T) {
+		provider.ReplyNext(`[
+			{"title": "Clear the backlog", "description": "Archive mail older than a month", "method": "M1", "priority": 7, "dependencies": []},
+			{"title": "Automate filing", "description": "Write filters for newsletters", "method": "new method needed", "priority": 5, "dependencies": [1]}
+		]`)
+
+		proposal, err := goalManager.ProposeObjectives(ctx, goal.ID, opts)
+		if err != nil {
+			t.Fatalf("ProposeObjectives failed: %v", err)
+		}
+		if len(proposal.Issues) != 0 {
+			t.Errorf("Expected no issues, got %v", proposal.Issues)
+		}
+		if len(proposal.Methods) != 1 || proposal.Methods[0].ID != method.ID {
+			t.Errorf("Expected the proven method to be offered, got %+v", proposal.Methods)
+		}
+		if len(proposal.Items) != 2 || proposal.Items[0].MethodID != method.ID || !proposal.Items[1].NewMethodNeeded {
+			t.Fatalf("Unexpected proposals: %+v", proposal.Items)
+		}
+
+		spending := budget.GetSpendingAnalysis().TaskTypeBreakdown[core.PlanningTaskType]
+		if proposal.Cost <= 0 || spending != proposal.Cost {
+			t.Errorf("Expected %f attributed to %q, got %f", proposal.Cost, core.PlanningTaskType, spending)
+		}
+
+		// Nothing exists until the proposal is accepted
+		objectives, err := objectiveManager.GetObjectivesForGoal(ctx, goal.ID)
+		if err != nil || len(objectives) != 0 {
+			t.Fatalf("Expected no objectives before acceptance, got %d, %v", len(objectives), err)
+		}
+
+		accepted, err := goalManager.AcceptProposals(ctx, proposal, []string{"1", "2"})
+		if err != nil {
+			t.Fatalf("AcceptProposals failed: %v", err)
+		}
+		if len(accepted.Objectives) != 2 || len(accepted.MethodSuggestions) != 1 {
+			t.Fatalf("Expected two objectives and one method suggestion, got %+v", accepted)
+		}
+		if accepted.Objectives[0].MethodID != method.ID || accepted.Objectives[1].MethodID != accepted.MethodSuggestions[0].ID {
+			t.Errorf("Expected objectives linked to their methods, got %+v", accepted.Objectives)
+		}
+
+		objectives, err = objectiveManager.GetObjectivesForGoal(ctx, goal.ID)
+		if err != nil || len(objectives) != 2 {
+			t.Errorf("Expected two objectives for the goal, got %d, %v", len(objectives), err)
+		}
+	})
+
+	t.Run("messy", func(t *testing.T) {
+		provider.ReplyNext("Here you go:\n```json\n" + `{"objectives": [
+			{"title": "Unsubscribe from lists", "method": "Inbox Triage", "priority": "high"},
+			{"description": "missing title"},
+			{"title": "Review weekly", "method": "Calendar magic", "priority": 0, "dependencies": ["Unsubscribe from lists", 7]}
+		]}` + "\n```")
+
+		proposal, err := goalManager.ProposeObjectives(ctx, goal.ID, opts)
+		if err != nil {
+			t.Fatalf("ProposeObjectives failed: %v", err)
+		}
+		if len(proposal.Items) != 2 {
+			t.Fatalf("Expected the two valid proposals, got %+v", proposal.Items)
+		}
+		if proposal.Items[0].MethodID != method.ID || proposal.Items[0].Priority != 8 {
+			t.Errorf("Unexpected first proposal: %+v", proposal.Items[0])
+		}
+		if second := proposal.Items[1]; !second.NewMethodNeeded || second.Priority != 1 || len(second.DependsOn) != 1 {
+			t.Errorf("Unexpected second proposal: %+v", second)
+		}
+
+		report := strings.Join(proposal.Issues, "\n")
+		for _, want := range []string{"no title", `unknown method "Calendar magic"`, `dependency "7"`} {
+			if !strings.Contains(report, want) {
+				t.Errorf("Expected the issues to mention %q, got:\n%s", want, report)
+			}
+		}
+	})
+
+	t.Run("unreadable", func(t *testing.T) {
+		provider.ReplyNext("I would start by reading your email.")
+
+		proposal, err := goalManager.ProposeObjectives(ctx, goal.ID, opts)
+		if err != nil {
+			t.Fatalf("ProposeObjectives failed: %v", err)
+		}
+		if len(proposal.Items) != 0 || len(proposal.Issues) != 1 {
+			t.Errorf("Expected an empty proposal with one issue, got %+v", proposal)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/internal/lineinput"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+)
+
+// readAll reads every entry of input, as interactive mode would.
+func readAll(t *testing.T, input string, history *lineinput.History) ([]string, string) {
+	t.Helper()
+	var out strings.Builder
+	reader := lineinput.NewReader(bufio.NewReader(strings.NewReader(input)), &out, history)
+	var entries []string
+	for {
+		entry, err := reader.Read("> ")
+		if err == io.EOF {
+			return entries, out.String()
+		}
+		if err != nil {
+			entries = append(entries, "error: "+string(errs.CodeOf(err)))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func TestLineInput_ContinuedLines(t *testing.T) {
+	entries, out := readAll(t, "plan the trip \\\n  to Lisbon\\\nin May\n\n  status  \nlast line without newline", nil)
+
+	want := []string{"plan the trip \n  to Lisbon\nin May", "", "status", "last line without newline"}
+	if strings.Join(entries, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, entries)
+	}
+	if strings.Count(out, lineinput.ContinuationPrompt) != 2 {
+		t.Errorf("Expected the continuation marker for each continued line, got %q", out)
+	}
+}
+
+func TestLineInput_HistoryPersistsAndRecalls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cli_history")
+	history, err := lineinput.LoadHistory(path, 0)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+
+	entries, out := readAll(t, "/goals\n/goals\nfirst \\\nsecond\n!!\n!1\n!9\n!x\n", history)
+	want := []string{"/goals", "/goals", "first \nsecond", "first \nsecond", "/goals", "error: NOT_FOUND", "!x"}
+	if strings.Join(entries, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, entries)
+	}
+	if !strings.Contains(out, "first \nsecond\n") {
+		t.Errorf("Expected a recalled entry to be shown, got %q", out)
+	}
+
+	// Repeats are kept once; a new session loads what was saved
+	reloaded, err := lineinput.LoadHistory(path, 0)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	saved := []string{"/goals", "first \nsecond", "/goals", "!x"}
+	if got := reloaded.Entries(); strings.Join(got, "|") != strings.Join(saved, "|") {
+		t.Errorf("Expected %q saved, got %q", saved, got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the history file to be private, got %v, %v", info, err)
+	}
+}
+
+func TestLineInput_HistoryLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cli_history")
+	history, err := lineinput.LoadHistory(path, 3)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	for _, entry := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		if err := history.Add(entry); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if got := strings.Join(history.Entries(), ""); got != "efg" {
+		t.Errorf("Expected the last 3 entries kept, got %q", got)
+	}
+
+	reloaded, err := lineinput.LoadHistory(path, 3)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if got := strings.Join(reloaded.Entries(), ""); got != "efg" {
+		t.Errorf("Expected the last 3 entries loaded, got %q", got)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines > 6 {
+		t.Errorf("Expected the file to be compacted, got %d lines", lines)
+	}
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -358,6 +359,28 @@ func TestContextMerging(t *testing.T) {
 	logger.Info(utils.LogContext{GoalID: "different-goal"}, "Original logger message")
 }
 
+func TestLogContextCarriedByContext(t *testing.T) {
+	ctx := context.Background()
+	if lc := utils.LogContextFrom(ctx); lc != (utils.LogContext{}) {
+		t.Errorf("Expected an empty log context, got %+v", lc)
+	}
+
+	// Later values are merged over the ones attached further up
+	ctx = utils.WithLogContext(ctx, utils.LogContext{GoalID: "goal-1", ObjectiveID: "objective-1"})
+	ctx = utils.WithLogContext(ctx, utils.LogContext{ObjectiveID: "objective-2", PlanID: "plan-1"})
+
+	lc := utils.LogContextFrom(ctx)
+	want := utils.LogContext{GoalID: "goal-1", ObjectiveID: "objective-2", PlanID: "plan-1"}
+	if lc != want {
+		t.Errorf("Expected %+v, got %+v", want, lc)
+	}
+
+	fields := lc.Fields()
+	if len(fields) != 3 || fields["plan_id"] != "plan-1" || fields["objective_id"] != "objective-2" {
+		t.Errorf("Expected the set IDs as fields, got %v", fields)
+	}
+}
+
 func TestLogRotation(t *testing.T) {
 	// Create temporary directory for test logs
 	tempDir, err := os.MkdirTemp("", "rotation_test")
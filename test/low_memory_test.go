@@ -0,0 +1,163 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/internal/config"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+	"github.com/Solifugus/ai-work-studio/pkg/utils/memwatch"
+)
+
+const (
+	// lowMemoryObjectives is the size of the representative workload
+	lowMemoryObjectives = 1000
+
+	// lowMemoryBudgetMB is the memory budget the workload runs under,
+	// as GOMEMLIMIT and as the watchdog's ceiling
+	lowMemoryBudgetMB = 64
+)
+
+// TestLowMemoryProfile runs a representative workload in a store opened with
+// the low-memory profile, under a low runtime memory limit, and checks that it
+// completes without the runtime going over the budget.
+func TestLowMemoryProfile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping low-memory workload in short mode")
+	}
+
+	ctx := context.Background()
+	profile := config.ProfileConfig{Name: config.ProfileLowMemory, MemoryLimitMB: lowMemoryBudgetMB}
+
+	previousLimit := debug.SetMemoryLimit(int64(profile.MemoryCeiling()))
+	defer debug.SetMemoryLimit(previousLimit)
+
+	store, err := storage.NewStore(t.TempDir(), profile.StoreOptions()...)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	var shedEvents []memwatch.Event
+	watchdog := memwatch.New(memwatch.Config{
+		Ceiling: profile.MemoryCeiling(),
+		OnShed:  func(event memwatch.Event) { shedEvents = append(shedEvents, event) },
+	})
+	watchdog.Add(memwatch.Shedder{Name: "archive cache", Shed: func() string {
+		return fmt.Sprintf("dropped %d cached segments", store.DropCaches())
+	}})
+	watchdog.Add(memwatch.FreeOSMemory())
+
+	rollups := core.NewRollupManager(store)
+	if err := rollups.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize rollups: %v", err)
+	}
+	defer rollups.Close(ctx)
+
+	goalManager := core.NewGoalManager(store)
+	methodManager := core.NewMethodManager(store)
+	objectiveManager := core.NewObjectiveManager(store)
+
+	goal, err := goalManager.CreateGoal(ctx, "Keep the home server tidy", "Routine upkeep", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := methodManager.CreateMethod(ctx, "Routine upkeep", "Check, fix and record",
+		[]core.ApproachStep{
+			{Description: "Check the current state"},
+			{Description: "Fix what is out of place"},
+			{Description: "Record what changed"},
+		}, core.MethodDomainUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+
+	// Create, start and complete every objective, sampling as the agent would
+	objectiveIDs := make([]string, 0, lowMemoryObjectives)
+	for i := 0; i < lowMemoryObjectives; i++ {
+		description := fmt.Sprintf("Rotate the logs of service %d", i)
+		if i%100 == 0 {
+			description = fmt.Sprintf("Verify the backups of service %d", i)
+		}
+		objective, err := objectiveManager.CreateObjective(ctx, goal.ID, method.ID,
+			fmt.Sprintf("Upkeep task %d", i), description, nil, 5)
+		if err != nil {
+			t.Fatalf("Failed to create objective %d: %v", i, err)
+		}
+		if _, err := objectiveManager.StartObjective(ctx, objective.ID); err != nil {
+			t.Fatalf("Failed to start objective %d: %v", i, err)
+		}
+		result := core.ObjectiveResult{Success: true, Message: "logs rotated", TokensUsed: 200, CompletedAt: time.Now()}
+		if _, err := objectiveManager.CompleteObjective(ctx, objective.ID, result); err != nil {
+			t.Fatalf("Failed to complete objective %d: %v", i, err)
+		}
+		objectiveIDs = append(objectiveIDs, objective.ID)
+		if i%100 == 0 {
+			watchdog.Check()
+		}
+	}
+
+	// A work session executes a few objectives end to end through the cursors
+	reasoner := NewMockLLMReasoner()
+	cursor := core.NewRealTimeCursor(store, NewMockTaskExecutor(), NewMockContextLoader())
+	for i := 0; i < 5; i++ {
+		objective, err := objectiveManager.CreateObjective(ctx, goal.ID, method.ID,
+			fmt.Sprintf("Session task %d", i), "Review the disk usage report", nil, 7)
+		if err != nil {
+			t.Fatalf("Failed to create session objective: %v", err)
+		}
+		plan, err := reasoner.DecomposePlan(ctx, objective, method)
+		if err != nil {
+			t.Fatalf("Failed to plan session objective: %v", err)
+		}
+		plan.ID = fmt.Sprintf("session-plan-%d", i)
+		result, err := cursor.ExecutePlan(ctx, plan)
+		if err != nil {
+			t.Fatalf("Session execution failed: %v", err)
+		}
+		if result.Status != core.ExecutionStatusCompleted {
+			t.Errorf("Expected session objective %d to succeed", i)
+		}
+		watchdog.Check()
+	}
+
+	completions, err := rollups.CompletionsOnDay(ctx, time.Now())
+	if err != nil || completions != lowMemoryObjectives {
+		t.Errorf("Expected %d completions today, got %d, %v", lowMemoryObjectives, completions, err)
+	}
+
+	// Search works without the in-memory index
+	found, err := store.Search(ctx, "verify backups", storage.SearchOptions{})
+	if err != nil || len(found) != lowMemoryObjectives/100 {
+		t.Errorf("Expected %d backup objectives, got %d, %v", lowMemoryObjectives/100, len(found), err)
+	}
+
+	// Settle the work into several archive segments, then read across them
+	for start := 0; start < len(objectiveIDs); start += 250 {
+		if _, err := store.ArchiveNodes(ctx, objectiveIDs[start:start+250]); err != nil {
+			t.Fatalf("Failed to archive objectives: %v", err)
+		}
+		watchdog.Check()
+	}
+	archived, err := store.Search(ctx, "upkeep service", storage.SearchOptions{IncludeArchived: true})
+	if err != nil || len(archived) != lowMemoryObjectives {
+		t.Errorf("Expected %d archived objectives from search, got %d, %v", lowMemoryObjectives, len(archived), err)
+	}
+	if _, err := objectiveManager.GetObjective(ctx, objectiveIDs[0]); err != nil {
+		t.Errorf("Expected an archived objective to stay reachable: %v", err)
+	}
+	watchdog.Check()
+
+	peak, sheds := watchdog.Stats()
+	t.Logf("peak %.1f MB of %d MB, %d shedding samples", float64(peak)/(1<<20), lowMemoryBudgetMB, sheds)
+	for _, event := range shedEvents {
+		t.Logf("watchdog: %s", event)
+	}
+	if peak > profile.MemoryCeiling() {
+		t.Errorf("Expected the workload to stay under %d MB, peaked at %.1f MB", lowMemoryBudgetMB, float64(peak)/(1<<20))
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
 	"github.com/Solifugus/ai-work-studio/pkg/mcp"
 )
 
@@ -81,6 +82,10 @@ func TestFileSystemServiceIntegration(t *testing.T) {
 		testPathValidation(t, registry, testDir)
 	})
 
+	t.Run("StatAndSearch", func(t *testing.T) {
+		testStatAndSearch(t, registry, testDir)
+	})
+
 	t.Run("LargeFileHandling", func(t *testing.T) {
 		testLargeFileHandling(t, registry, testDir)
 	})
@@ -538,10 +543,8 @@ func testPathValidation(t *testing.T, registry *mcp.ServiceRegistry, testDir str
 			"path":      filepath.Join(symlinkPath, "passwd"),
 		})
 
-		// This should ideally be blocked, but depends on implementation
-		// For now, we just ensure it doesn't crash
 		if result.Success {
-			t.Log("Warning: symlink attack was not blocked")
+			t.Error("expected symlink attack to be blocked")
 		}
 
 		// Cleanup
@@ -549,6 +552,76 @@ func testPathValidation(t *testing.T, registry *mcp.ServiceRegistry, testDir str
 	}
 }
 
+// testStatAndSearch tests the short operation names, stat and search
+func testStatAndSearch(t *testing.T, registry *mcp.ServiceRegistry, testDir string) {
+	ctx := context.Background()
+	searchDir := filepath.Join(testDir, "search")
+
+	// Relative paths resolve against the root
+	for name, content := range map[string]string{"notes.md": "todo: ship\ndone: plan\n", "deep/todo.txt": "nothing\ntodo: test\n", "image.png": "todo: no"} {
+		result := registry.CallService(ctx, "filesystem", mcp.ServiceParams{
+			"operation": "write",
+			"path":      filepath.Join("search", name),
+			"content":   content,
+		})
+		if !result.Success {
+			t.Fatalf("write failed: %v", result.Error)
+		}
+	}
+
+	result := registry.CallService(ctx, "filesystem", mcp.ServiceParams{"operation": "read", "path": "search/notes.md"})
+	if !result.Success || result.Data.(mcp.FileContent).Path != filepath.Join(searchDir, "notes.md") {
+		t.Errorf("expected read of a path relative to the root, got %v", result.Error)
+	}
+
+	result = registry.CallService(ctx, "filesystem", mcp.ServiceParams{"operation": "stat", "path": filepath.Join(searchDir, "deep")})
+	if !result.Success {
+		t.Fatalf("stat failed: %v", result.Error)
+	}
+	if info := result.Data.(mcp.FileInfo); !info.IsDir || info.Name != "deep" {
+		t.Errorf("expected stat of the deep directory, got %+v", info)
+	}
+	result = registry.CallService(ctx, "filesystem", mcp.ServiceParams{"operation": "stat", "path": "search/missing"})
+	if result.Success || errs.CodeOf(result.Error) != errs.NotFound {
+		t.Errorf("expected stat of a missing file to fail as not found, got %v", result.Error)
+	}
+
+	// Content search skips binary files
+	result = registry.CallService(ctx, "filesystem", mcp.ServiceParams{"operation": "search", "path": "search", "content": "todo:"})
+	if !result.Success {
+		t.Fatalf("search failed: %v", result.Error)
+	}
+	found := result.Data.(mcp.SearchResults)
+	if found.Count != 2 || found.Truncated {
+		t.Fatalf("expected two matching lines, got %+v", found)
+	}
+	want := map[string]string{
+		fmt.Sprintf("%s:1", filepath.Join(searchDir, "notes.md")):      "todo: ship",
+		fmt.Sprintf("%s:2", filepath.Join(searchDir, "deep/todo.txt")): "todo: test",
+	}
+	for _, match := range found.Matches {
+		if text := want[fmt.Sprintf("%s:%d", match.Path, match.Line)]; text != match.Text {
+			t.Errorf("unexpected match %+v", match)
+		}
+	}
+
+	// Name search, limited
+	result = registry.CallService(ctx, "filesystem", mcp.ServiceParams{"operation": "search", "path": "search", "pattern": "*.*", "max_results": 2})
+	if found := result.Data.(mcp.SearchResults); !result.Success || found.Count != 2 || !found.Truncated {
+		t.Errorf("expected the name search cut at two results, got %+v, %v", result.Data, result.Error)
+	}
+
+	for _, params := range []mcp.ServiceParams{
+		{"operation": "search", "path": "search"},
+		{"operation": "search", "pattern": "["},
+		{"operation": "search", "path": "..", "content": "todo"},
+	} {
+		if result := registry.CallService(ctx, "filesystem", params); result.Success {
+			t.Errorf("expected search with %v to fail", params)
+		}
+	}
+}
+
 // testLargeFileHandling tests handling of larger files
 func testLargeFileHandling(t *testing.T, registry *mcp.ServiceRegistry, testDir string) {
 	// Create a larger content (but not too large for tests)
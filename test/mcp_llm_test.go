@@ -3,14 +3,24 @@ package test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Solifugus/ai-work-studio/internal/selftest"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
 	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/utils"
 )
 
 // TestLLMService tests the LLM MCP service implementation.
@@ -70,6 +80,43 @@ func TestLLMValidateParams(t *testing.T) {
 			},
 			hasError: true,
 		},
+		{
+			name: "complete operation - valid messages",
+			params: mcp.ServiceParams{
+				"operation": "complete",
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "My name is Ada."},
+					map[string]interface{}{"role": "assistant", "content": "Nice to meet you, Ada."},
+					map[string]interface{}{"role": "user", "content": "What is my name?"},
+				},
+			},
+			hasError: false,
+		},
+		{
+			name: "complete operation - prompt and messages",
+			params: mcp.ServiceParams{
+				"operation": "complete",
+				"prompt":    "Hello, world!",
+				"messages":  []mcp.Message{{Role: mcp.RoleUser, Content: "Hello, world!"}},
+			},
+			hasError: true,
+		},
+		{
+			name: "complete operation - empty messages",
+			params: mcp.ServiceParams{
+				"operation": "complete",
+				"messages":  []mcp.Message{},
+			},
+			hasError: true,
+		},
+		{
+			name: "complete operation - unknown message role",
+			params: mcp.ServiceParams{
+				"operation": "complete",
+				"messages":  []mcp.Message{{Role: "narrator", Content: "Once upon a time"}},
+			},
+			hasError: true,
+		},
 		{
 			name: "complete operation - invalid temperature",
 			params: mcp.ServiceParams{
@@ -101,6 +148,13 @@ func TestLLMValidateParams(t *testing.T) {
 			},
 			hasError: false,
 		},
+		{
+			name: "list_models - valid",
+			params: mcp.ServiceParams{
+				"operation": "list_models",
+			},
+			hasError: false,
+		},
 		{
 			name: "get_budget - valid",
 			params: mcp.ServiceParams{
@@ -165,6 +219,42 @@ func TestLLMListProviders(t *testing.T) {
 	}
 }
 
+// TestLLMListModels tests listing the models of each provider.
+func TestLLMListModels(t *testing.T) {
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+		"openai": &mcp.OpenAIProvider{Models: map[string]mcp.ModelConfig{
+			"gpt-4":                  {Name: "gpt-4", InputCost: 30.0, OutputCost: 60.0, MaxTokens: 4096, ContextSize: 8192, SupportsChat: true, QualityTier: "premium", SpeedTier: 3},
+			"text-embedding-ada-002": {Name: "text-embedding-ada-002", InputCost: 0.1, ContextSize: 8191, SupportsEmbed: true},
+		}},
+		"anthropic": &mcp.AnthropicProvider{Models: map[string]mcp.ModelConfig{
+			"claude-3-haiku": {Name: "claude-3-haiku-20240307", InputCost: 0.25, OutputCost: 1.25, MaxTokens: 4096, ContextSize: 200000, SupportsChat: true},
+		}},
+	})
+
+	result := service.Execute(context.Background(), mcp.ServiceParams{"operation": "list_models"})
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.Error)
+	}
+	models, ok := result.Data.([]mcp.ModelListing)
+	if !ok {
+		t.Fatalf("Expected a model listing, got %T", result.Data)
+	}
+
+	want := []mcp.ModelListing{
+		{Provider: "anthropic", Model: "claude-3-haiku", InputCost: 0.25, OutputCost: 1.25, MaxTokens: 4096, ContextSize: 200000, SupportsChat: true},
+		{Provider: "openai", Model: "gpt-4", InputCost: 30.0, OutputCost: 60.0, MaxTokens: 4096, ContextSize: 8192, SupportsChat: true, QualityTier: "premium", SpeedTier: 3},
+		{Provider: "openai", Model: "text-embedding-ada-002", InputCost: 0.1, ContextSize: 8191, SupportsEmbed: true},
+	}
+	if len(models) != len(want) {
+		t.Fatalf("Expected %d models, got %+v", len(want), models)
+	}
+	for i := range want {
+		if models[i] != want[i] {
+			t.Errorf("Model %d: expected %+v, got %+v", i, want[i], models[i])
+		}
+	}
+}
+
 // TestLLMBudgetTracking tests budget tracking functionality.
 func TestLLMBudgetTracking(t *testing.T) {
 	service := mcp.NewLLMService(nil)
@@ -211,8 +301,8 @@ func mockAnthropicServer(t *testing.T, response map[string]interface{}, statusCo
 			t.Errorf("Unexpected path: %s", r.URL.Path)
 		}
 
-		if r.Header.Get("Authorization") != "Bearer test-key" {
-			t.Errorf("Invalid authorization header")
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("Invalid x-api-key header")
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -245,6 +335,170 @@ func mockOpenAIServer(t *testing.T, endpoint string, response map[string]interfa
 	}))
 }
 
+// TestLLMProviderAuthHeaders tests that each provider sends the headers its
+// API requires, and no other provider's credentials.
+func TestLLMProviderAuthHeaders(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []map[string]interface{}{{"embedding": []float64{0.1}}},
+		})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	completion := mcp.CompletionRequest{Model: "test-model", Prompt: "Hello!"}
+	tests := []struct {
+		name string
+		call func() error
+		want map[string]string // An empty value means the header must be absent
+	}{
+		{
+			name: "anthropic",
+			call: func() error {
+				provider := &mcp.AnthropicProvider{APIKey: "anthropic-key", BaseURL: server.URL, HTTPClient: server.Client()}
+				_, err := provider.Complete(ctx, completion)
+				return err
+			},
+			want: map[string]string{"x-api-key": "anthropic-key", "anthropic-version": "2023-06-01", "Authorization": ""},
+		},
+		{
+			name: "openai completion",
+			call: func() error {
+				provider := &mcp.OpenAIProvider{APIKey: "openai-key", BaseURL: server.URL, HTTPClient: server.Client()}
+				_, err := provider.Complete(ctx, completion)
+				return err
+			},
+			want: map[string]string{"Authorization": "Bearer openai-key", "x-api-key": ""},
+		},
+		{
+			name: "openai embedding",
+			call: func() error {
+				provider := &mcp.OpenAIProvider{APIKey: "openai-key", BaseURL: server.URL, HTTPClient: server.Client()}
+				_, err := provider.Embed(ctx, mcp.EmbeddingRequest{Model: "test-embedding", Text: "Hello!"})
+				return err
+			},
+			want: map[string]string{"Authorization": "Bearer openai-key", "x-api-key": ""},
+		},
+		{
+			name: "anthropic embedding",
+			call: func() error {
+				provider := &mcp.AnthropicProvider{APIKey: "anthropic-key", EmbeddingsURL: server.URL, EmbeddingsKey: "voyage-key", HTTPClient: server.Client()}
+				_, err := provider.Embed(ctx, mcp.EmbeddingRequest{Model: "voyage-3", Text: "Hello!"})
+				return err
+			},
+			want: map[string]string{"Authorization": "Bearer voyage-key", "x-api-key": ""},
+		},
+		{
+			name: "local",
+			call: func() error {
+				provider := &mcp.LocalProvider{ServerURL: server.URL, HTTPClient: server.Client()}
+				_, err := provider.Complete(ctx, completion)
+				return err
+			},
+			want: map[string]string{"Authorization": "", "x-api-key": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers = nil
+			if err := tt.call(); err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			if headers == nil {
+				t.Fatal("Expected the provider to reach the server")
+			}
+			for header, want := range tt.want {
+				if got := headers.Get(header); got != want {
+					t.Errorf("Expected %s header %q, got %q", header, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLLMProvidersSendConversation(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	}))
+	defer server.Close()
+
+	conversation := []mcp.Message{
+		{Role: mcp.RoleSystem, Content: "Answer briefly."},
+		{Role: mcp.RoleUser, Content: "My name is Ada."},
+		{Role: mcp.RoleAssistant, Content: "Nice to meet you, Ada."},
+		{Role: mcp.RoleUser, Content: "What is my name?"},
+	}
+	roles := func(value interface{}) []string {
+		var got []string
+		messages, _ := value.([]interface{})
+		for _, message := range messages {
+			entry, _ := message.(map[string]interface{})
+			role, _ := entry["role"].(string)
+			got = append(got, role)
+		}
+		return got
+	}
+
+	ctx := context.Background()
+	request := mcp.CompletionRequest{Model: "test-model", Messages: conversation, MaxTokens: 100}
+
+	anthropic := &mcp.AnthropicProvider{APIKey: "key", BaseURL: server.URL, HTTPClient: server.Client()}
+	if _, err := anthropic.Complete(ctx, request); err != nil {
+		t.Fatalf("Anthropic request failed: %v", err)
+	}
+	if got := roles(body["messages"]); !reflect.DeepEqual(got, []string{"user", "assistant", "user"}) {
+		t.Errorf("Expected Anthropic to receive the conversation without the system turn, got %v", got)
+	}
+	if body["system"] != "Answer briefly." {
+		t.Errorf("Expected Anthropic to receive the system turn apart, got %v", body["system"])
+	}
+
+	openai := &mcp.OpenAIProvider{APIKey: "key", BaseURL: server.URL, HTTPClient: server.Client()}
+	if _, err := openai.Complete(ctx, request); err != nil {
+		t.Fatalf("OpenAI request failed: %v", err)
+	}
+	if got := roles(body["messages"]); !reflect.DeepEqual(got, []string{"system", "user", "assistant", "user"}) {
+		t.Errorf("Expected OpenAI to receive every turn, got %v", got)
+	}
+
+	local := &mcp.LocalProvider{ServerURL: server.URL, HTTPClient: server.Client()}
+	if _, err := local.Complete(ctx, request); err != nil {
+		t.Fatalf("Local request failed: %v", err)
+	}
+	want := "System: Answer briefly.\n\nUser: My name is Ada.\n\nAssistant: Nice to meet you, Ada.\n\nUser: What is my name?\n\nAssistant:"
+	if body["prompt"] != want {
+		t.Errorf("Expected the local prompt to prefix each turn with its role, got %q", body["prompt"])
+	}
+
+	// The service passes a messages parameter through to the provider
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{"openai": openai})
+	result := service.Execute(ctx, mcp.ServiceParams{
+		"operation": "complete",
+		"provider":  "openai",
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": "My name is Ada."},
+			map[string]interface{}{"role": "assistant", "content": "Nice to meet you, Ada."},
+			map[string]interface{}{"role": "user", "content": "What is my name?"},
+		},
+	})
+	if !result.Success {
+		t.Fatalf("Completion with messages failed: %v", result.Error)
+	}
+	if got := roles(body["messages"]); !reflect.DeepEqual(got, []string{"user", "assistant", "user"}) {
+		t.Errorf("Expected the service to send the conversation, got %v", got)
+	}
+}
+
 // TestLLMAnthropicProvider tests the Anthropic provider implementation.
 func TestLLMAnthropicProvider(t *testing.T) {
 	// Create mock server
@@ -306,7 +560,7 @@ func TestLLMAnthropicProvider(t *testing.T) {
 		t.Errorf("Expected provider 'anthropic', got %s", result.Provider)
 	}
 
-	// Test embedding (should fail)
+	// Test embedding (should fail without an embeddings URL)
 	embeddingRequest := mcp.EmbeddingRequest{
 		Model: "claude-3-haiku",
 		Text:  "Hello!",
@@ -447,6 +701,62 @@ func TestLLMOpenAIProvider(t *testing.T) {
 	})
 }
 
+// TestLLMCostSplitsInputAndOutput tests that input and output tokens are
+// each charged at their model's documented price per 1M tokens.
+func TestLLMCostSplitsInputAndOutput(t *testing.T) {
+	anthropic := &mcp.AnthropicProvider{Models: map[string]mcp.ModelConfig{
+		"claude-3-sonnet": {Name: "claude-3-sonnet-20240229", InputCost: 3.0, OutputCost: 15.0, SupportsChat: true},
+		"claude-3-haiku":  {Name: "claude-3-haiku-20240307", InputCost: 0.25, OutputCost: 1.25, SupportsChat: true},
+	}}
+	openai := &mcp.OpenAIProvider{Models: map[string]mcp.ModelConfig{
+		"gpt-4":                  {Name: "gpt-4", InputCost: 30.0, OutputCost: 60.0, SupportsChat: true},
+		"text-embedding-ada-002": {Name: "text-embedding-ada-002", InputCost: 0.1, SupportsEmbed: true},
+	}}
+
+	tests := []struct {
+		name         string
+		provider     mcp.LLMProvider
+		model        string
+		inputTokens  int
+		outputTokens int
+		want         float64
+	}{
+		{"sonnet input only", anthropic, "claude-3-sonnet", 1000000, 0, 3.0},
+		{"sonnet output only", anthropic, "claude-3-sonnet", 0, 1000000, 15.0},
+		{"sonnet generation heavy", anthropic, "claude-3-sonnet", 250000, 750000, 12.0},
+		{"haiku by provider name", anthropic, "claude-3-haiku-20240307", 2000000, 2000000, 3.0},
+		{"gpt-4", openai, "gpt-4", 500000, 500000, 45.0},
+		{"embedding", openai, "text-embedding-ada-002", 1000000, 0, 0.1},
+		{"unknown model", anthropic, "claude-unknown", 1000000, 1000000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.provider.CalculateCost(tt.model, tt.inputTokens, tt.outputTokens); got != tt.want {
+				t.Errorf("Expected $%.4f, got $%.4f", tt.want, got)
+			}
+		})
+	}
+
+	// A completion reports its split and is charged by it
+	server := mockAnthropicServer(t, map[string]interface{}{
+		"content": []map[string]interface{}{{"type": "text", "text": "A long answer"}},
+		"usage":   map[string]interface{}{"input_tokens": 2000.0, "output_tokens": 8000.0},
+	}, 200)
+	defer server.Close()
+	anthropic.APIKey, anthropic.BaseURL, anthropic.HTTPClient = "test-key", server.URL, server.Client()
+
+	result, err := anthropic.Complete(context.Background(), mcp.CompletionRequest{Model: "claude-3-sonnet", Prompt: "Hello!", MaxTokens: 8000})
+	if err != nil {
+		t.Fatalf("Completion failed: %v", err)
+	}
+	if result.InputTokens != 2000 || result.OutputTokens != 8000 || result.TokensUsed != 10000 {
+		t.Errorf("Expected 2000 input and 8000 output tokens, got %d/%d of %d", result.InputTokens, result.OutputTokens, result.TokensUsed)
+	}
+	if result.Cost != 0.126 {
+		t.Errorf("Expected $0.126, got $%.6f", result.Cost)
+	}
+}
+
 // TestLLMLocalProvider tests the local provider implementation.
 func TestLLMLocalProvider(t *testing.T) {
 	// Create mock local server
@@ -511,7 +821,7 @@ func TestLLMLocalProvider(t *testing.T) {
 		t.Errorf("Expected provider 'local', got %s", result.Provider)
 	}
 
-	// Test embedding (should fail)
+	// Test embedding (should fail for a model not flagged SupportsEmbed)
 	embeddingRequest := mcp.EmbeddingRequest{
 		Model: "local-llama",
 		Text:  "Hello!",
@@ -519,7 +829,99 @@ func TestLLMLocalProvider(t *testing.T) {
 
 	_, err = provider.Embed(ctx, embeddingRequest)
 	if err == nil {
-		t.Errorf("Expected embedding to fail for local provider")
+		t.Errorf("Expected embedding to fail for a chat model")
+	}
+}
+
+// TestLLMLocalEmbeddings tests embeddings from OpenAI-compatible local and
+// Voyage servers, and that the free local provider is preferred for them.
+func TestLLMLocalEmbeddings(t *testing.T) {
+	var requests []map[string]interface{}
+	embedding := []float64{0.1, 0.2, 0.3}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":  []map[string]interface{}{{"embedding": embedding}},
+			"usage": map[string]interface{}{"total_tokens": 4},
+		})
+	}))
+	defer server.Close()
+
+	local := &mcp.LocalProvider{
+		ServerURL:  "http://127.0.0.1:1", // Completions only; embeddings go to their own URL
+		HTTPClient: server.Client(),
+		Models: map[string]mcp.ModelConfig{
+			"local-llama": {Name: "llama-2-7b-chat", SupportsChat: true},
+			"local-embed": {Name: "nomic-embed-text", SupportsEmbed: true, Dimensions: 3},
+		},
+		EmbeddingsURL: server.URL,
+	}
+	ctx := context.Background()
+
+	result, err := local.Embed(ctx, mcp.EmbeddingRequest{Model: "local-embed", Text: "Hello, world!"})
+	if err != nil {
+		t.Fatalf("Embedding failed: %v", err)
+	}
+	if len(result.Embedding) != 3 || result.Embedding[2] != 0.3 || result.TokensUsed != 4 || result.Cost != 0 || result.Provider != "local" {
+		t.Errorf("Unexpected embedding response: %+v", result)
+	}
+	if len(requests) != 1 || requests[0]["model"] != "nomic-embed-text" || requests[0]["input"] != "Hello, world!" {
+		t.Errorf("Expected the model's server name and the text sent, got %v", requests)
+	}
+
+	// An embedding of the wrong length, or none, is rejected
+	embedding = []float64{0.1, 0.2}
+	if _, err := local.Embed(ctx, mcp.EmbeddingRequest{Model: "local-embed", Text: "Hello!"}); err == nil || !strings.Contains(err.Error(), "2 dimensions, expected 3") {
+		t.Errorf("Expected a dimension mismatch error, got %v", err)
+	}
+	embedding = []float64{}
+	if _, err := local.Embed(ctx, mcp.EmbeddingRequest{Model: "local-embed", Text: "Hello!"}); err == nil {
+		t.Error("Expected an empty embedding to be rejected")
+	}
+	embedding = []float64{0.1, 0.2, 0.3}
+
+	// Anthropic embeds through a Voyage-compatible endpoint, priced by input tokens
+	anthropic := &mcp.AnthropicProvider{
+		APIKey:     "anthropic-key",
+		HTTPClient: server.Client(),
+		Models: map[string]mcp.ModelConfig{
+			"claude-3-haiku": {Name: "claude-3-haiku-20240307", SupportsChat: true},
+			"voyage-3":       {Name: "voyage-3", InputCost: 0.06, SupportsEmbed: true},
+		},
+		EmbeddingsURL: server.URL,
+	}
+	result, err = anthropic.Embed(ctx, mcp.EmbeddingRequest{Model: "voyage-3", Text: "Hello!"})
+	if err != nil {
+		t.Fatalf("Voyage embedding failed: %v", err)
+	}
+	if result.Provider != "anthropic" || result.Cost != 4*0.06/1000000 {
+		t.Errorf("Unexpected Voyage embedding response: %+v", result)
+	}
+
+	// Local embeddings are free, so they are chosen over OpenAI's
+	openai := &mcp.OpenAIProvider{APIKey: "openai-key", BaseURL: "http://127.0.0.1:1", HTTPClient: server.Client(),
+		Models: map[string]mcp.ModelConfig{"text-embedding-ada-002": {Name: "text-embedding-ada-002", InputCost: 0.1, SupportsEmbed: true}}}
+	for _, tt := range []struct {
+		name      string
+		providers map[string]mcp.LLMProvider
+		want      string
+	}{
+		{"local over openai", map[string]mcp.LLMProvider{"local": local, "openai": openai, "anthropic": anthropic}, "local"},
+		{"anthropic with an embeddings URL", map[string]mcp.LLMProvider{"anthropic": anthropic}, "anthropic"},
+	} {
+		service := mcp.NewLLMServiceWithProviders(nil, tt.providers)
+		result := service.Execute(ctx, mcp.ServiceParams{"operation": "embed", "text": "Hello!"})
+		if !result.Success {
+			t.Fatalf("%s: embedding failed: %v", tt.name, result.Error)
+		}
+		if provider := result.Data.(*mcp.EmbeddingResponse).Provider; provider != tt.want {
+			t.Errorf("%s: expected %s to embed, got %s", tt.name, tt.want, provider)
+		}
 	}
 }
 
@@ -647,10 +1049,646 @@ func TestLLMErrorHandling(t *testing.T) {
 		if !strings.Contains(err.Error(), "API error (status 401)") {
 			t.Errorf("Expected authentication error, got: %v", err)
 		}
+		if !errors.Is(err, mcp.ErrAuthFailed) {
+			t.Errorf("Expected the error to match ErrAuthFailed, got: %v", err)
+		}
+	})
+
+	// Test replacing a rejected key without a restart
+	t.Run("renewed_key", func(t *testing.T) {
+		callCount := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			callCount++
+			if r.Header.Get("x-api-key") != "renewed-key" {
+				w.WriteHeader(401)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": map[string]interface{}{"message": "Invalid API key"},
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"content": []map[string]interface{}{{"type": "text", "text": "Hello!"}},
+				"usage":   map[string]interface{}{"input_tokens": 5.0, "output_tokens": 10.0},
+			})
+		}))
+		defer server.Close()
+
+		provider := &mcp.AnthropicProvider{
+			APIKey:     "expired-key",
+			BaseURL:    server.URL,
+			HTTPClient: &http.Client{Timeout: 5 * time.Second},
+			Models:     map[string]mcp.ModelConfig{"claude-3-haiku": {Name: "claude-3-haiku"}},
+		}
+		service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{"anthropic": provider})
+		params := mcp.ServiceParams{
+			"operation": "complete",
+			"prompt":    "Hello!",
+			"provider":  "anthropic",
+			"model":     "claude-3-haiku",
+		}
+
+		result := service.Execute(context.Background(), params)
+		if !errors.Is(result.Error, mcp.ErrAuthFailed) {
+			t.Fatalf("Expected the expired key to be rejected, got: %v", result.Error)
+		}
+		if callCount != 1 {
+			t.Errorf("Expected rejected credentials not to be retried, got %d calls", callCount)
+		}
+
+		if err := service.SetAPIKey("anthropic", "renewed-key"); err != nil {
+			t.Fatalf("Failed to set API key: %v", err)
+		}
+		if result := service.Execute(context.Background(), params); !result.Success {
+			t.Errorf("Expected the renewed key to be accepted, got: %v", result.Error)
+		}
+		if err := service.SetAPIKey("local", "key"); err == nil {
+			t.Error("Expected a provider without API keys to be refused")
+		}
 	})
 }
 
 // TestLLMBudgetLimits tests budget limit enforcement.
+// TestLLMRetrySchedule checks how long the service waits between retries and
+// how it reports giving up.
+// TestLLMProviderErrorTypes tests that providers map failed responses to the
+// typed errors, and that retries follow the type rather than the message.
+func TestLLMProviderErrorTypes(t *testing.T) {
+	request := mcp.CompletionRequest{Model: "test-model", Prompt: "Hello!"}
+	tests := []struct {
+		name     string
+		provider string
+		status   int
+		body     string
+		want     error
+		wantType string
+	}{
+		{name: "rate limited", provider: "anthropic", status: 429,
+			body: `{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`,
+			want: mcp.ErrRateLimited, wantType: "rate_limit_error"},
+		{name: "overloaded", provider: "anthropic", status: 529,
+			body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			want: mcp.ErrProviderUnavailable, wantType: "overloaded_error"},
+		{name: "request too large", provider: "anthropic", status: 413,
+			body: `{"type":"error","error":{"type":"request_too_large","message":"Request exceeds the maximum allowed number of bytes"}}`,
+			want: mcp.ErrContextTooLarge, wantType: "request_too_large"},
+		{name: "context length", provider: "openai", status: 400,
+			body: `{"error":{"message":"This model's maximum context length is 8192 tokens","type":"invalid_request_error","code":"context_length_exceeded"}}`,
+			want: mcp.ErrContextTooLarge, wantType: "context_length_exceeded"},
+		{name: "bad gateway page", provider: "openai", status: 502,
+			body: `<html><body>Bad Gateway</body></html>`,
+			want: mcp.ErrProviderUnavailable},
+		{name: "local server error", provider: "local", status: 503,
+			body: `{"error":"model is still loading"}`,
+			want: mcp.ErrProviderUnavailable},
+		{name: "rejected key", provider: "openai", status: 401,
+			body: `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`,
+			want: mcp.ErrAuthFailed, wantType: "invalid_api_key"},
+	}
+
+	sentinels := []error{mcp.ErrAuthFailed, mcp.ErrRateLimited, mcp.ErrProviderUnavailable, mcp.ErrContextTooLarge, mcp.ErrBudgetExceeded}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			var err error
+			switch tt.provider {
+			case "anthropic":
+				_, err = (&mcp.AnthropicProvider{APIKey: "test-key", BaseURL: server.URL, HTTPClient: server.Client()}).Complete(context.Background(), request)
+			case "openai":
+				_, err = (&mcp.OpenAIProvider{APIKey: "test-key", BaseURL: server.URL, HTTPClient: server.Client()}).Complete(context.Background(), request)
+			case "local":
+				_, err = (&mcp.LocalProvider{ServerURL: server.URL, HTTPClient: server.Client()}).Complete(context.Background(), request)
+			}
+
+			var apiErr *mcp.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected an API error, got %v", err)
+			}
+			if apiErr.Provider != tt.provider || apiErr.Model != "test-model" || apiErr.StatusCode != tt.status || apiErr.Type != tt.wantType {
+				t.Errorf("Expected %s/test-model status %d type %q, got %+v", tt.provider, tt.status, tt.wantType, apiErr)
+			}
+			for _, sentinel := range sentinels {
+				if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v", err, sentinel, got)
+				}
+			}
+		})
+	}
+
+	// A service retries outages but not requests that would fail again
+	retries := []struct {
+		status    int
+		body      string
+		wantCalls int
+	}{
+		{502, `<html><body>Bad Gateway</body></html>`, 3},
+		{400, `{"error":{"message":"Too long","code":"context_length_exceeded"}}`, 1},
+		{400, `{"error":{"message":"Rate limit mentioned in a rejected request"}}`, 1},
+	}
+	for _, tt := range retries {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+			"openai": &mcp.OpenAIProvider{APIKey: "test-key", BaseURL: server.URL, HTTPClient: server.Client(),
+				Models: map[string]mcp.ModelConfig{"test-model": {Name: "test-model", SupportsChat: true}}},
+		})
+		service.SetRetryConfig(mcp.RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffRate: 1})
+
+		result := service.Execute(context.Background(), mcp.ServiceParams{
+			"operation": "complete", "prompt": "Hello!", "provider": "openai", "model": "test-model",
+		})
+		server.Close()
+		if result.Success || calls != tt.wantCalls {
+			t.Errorf("Status %d %s: expected %d calls, got %d (%v)", tt.status, tt.body, tt.wantCalls, calls, result.Error)
+		}
+	}
+
+	// A spent budget is typed too, and never reaches a provider
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+		"openai": &mcp.OpenAIProvider{APIKey: "test-key", Models: map[string]mcp.ModelConfig{"test-model": {Name: "test-model"}}},
+	})
+	service.SetBudgetLimit(0.01)
+	service.UpdateBudgetForTest("openai", "complete", 1000, 0.02)
+	result := service.Execute(context.Background(), mcp.ServiceParams{
+		"operation": "complete", "prompt": "Hello!", "provider": "openai", "model": "test-model",
+	})
+	if !errors.Is(result.Error, mcp.ErrBudgetExceeded) || errors.Is(result.Error, mcp.ErrProviderUnavailable) {
+		t.Errorf("Expected the spent budget to match ErrBudgetExceeded only, got %v", result.Error)
+	}
+}
+
+func TestLLMRequestTimeouts(t *testing.T) {
+	calls := 0
+	var maxTokens []float64
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		maxTokens = append(maxTokens, body["max_tokens"].(float64))
+		if body["messages"].([]interface{})[0].(map[string]interface{})["content"] == "slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": []map[string]interface{}{{"type": "text", "text": "Hi"}},
+			"usage":   map[string]interface{}{"input_tokens": 5, "output_tokens": 2},
+		})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+		"anthropic": &mcp.AnthropicProvider{APIKey: "test-key", BaseURL: server.URL, HTTPClient: &http.Client{Timeout: 5 * time.Second},
+			Models: map[string]mcp.ModelConfig{"claude-3-haiku": {Name: "claude-3-haiku"}}},
+	})
+	service.SetRetryConfig(mcp.RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffRate: 1})
+	complete := func(prompt string, extra mcp.ServiceParams) mcp.ServiceResult {
+		params := mcp.ServiceParams{"operation": "complete", "prompt": prompt, "provider": "anthropic", "model": "claude-3-haiku", "max_tokens": 1000}
+		for key, value := range extra {
+			params[key] = value
+		}
+		if err := service.ValidateParams(params); err != nil {
+			return mcp.ErrorResult(err)
+		}
+		return service.Execute(context.Background(), params)
+	}
+
+	// The request's own deadline cuts it short long before the client's
+	started := time.Now()
+	result := complete("slow", mcp.ServiceParams{"timeout_seconds": 0.1})
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("Expected the request to stop at its timeout, took %s", elapsed)
+	}
+	var timeoutErr *mcp.TimeoutError
+	if !errors.As(result.Error, &timeoutErr) || !errors.Is(result.Error, mcp.ErrTimeout) || errs.CodeOf(result.Error) != errs.Timeout {
+		t.Fatalf("Expected a timeout error, got %v", result.Error)
+	}
+	if timeoutErr.Provider != "anthropic" || timeoutErr.Model != "claude-3-haiku" || timeoutErr.Timeout != 100*time.Millisecond || timeoutErr.Elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the timeout's details, got %+v", timeoutErr)
+	}
+	if latency, ok := result.Metadata["latency_ms"].(int64); !ok || latency < 100 {
+		t.Errorf("Expected the partial latency in the result metadata, got %v", result.Metadata)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a timeout not to be retried unless asked, got %d calls", calls)
+	}
+
+	// Callers can opt in to retrying timeouts
+	calls = 0
+	result = complete("slow", mcp.ServiceParams{"timeout_seconds": 0.05, "retry_on_timeout": true})
+	if !errors.Is(result.Error, mcp.ErrTimeout) || calls != 3 {
+		t.Errorf("Expected 3 timed-out attempts, got %d calls (%v)", calls, result.Error)
+	}
+
+	// A hard token cap overrides a larger max_tokens
+	maxTokens = nil
+	result = complete("quick", mcp.ServiceParams{"timeout_seconds": 5, "hard_max_tokens": 200})
+	if !result.Success || len(maxTokens) != 1 || maxTokens[0] != 200 {
+		t.Errorf("Expected the request capped at 200 tokens, got %v (%v)", maxTokens, result.Error)
+	}
+
+	for _, extra := range []mcp.ServiceParams{
+		{"timeout_seconds": 0},
+		{"timeout_seconds": -1.5},
+		{"timeout_seconds": 3600},
+		{"timeout_seconds": "10"},
+		{"hard_max_tokens": 0},
+		{"retry_on_timeout": "yes"},
+	} {
+		if result := complete("quick", extra); errs.CodeOf(result.Error) != errs.Validation {
+			t.Errorf("Expected %v to be rejected, got %v", extra, result.Error)
+		}
+	}
+}
+
+// TestLLMProviderLimits runs concurrent requests against a provider with
+// limits and checks the server never sees more than the cap at once.
+func TestLLMProviderLimits(t *testing.T) {
+	var mu sync.Mutex
+	current, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		current++
+		if current > peak {
+			peak = current
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]interface{}{"content": "Hi"}}},
+			"usage":   map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 5},
+		})
+	}))
+	defer server.Close()
+
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+		"openai": &mcp.OpenAIProvider{APIKey: "key", BaseURL: server.URL, HTTPClient: server.Client(),
+			Models: map[string]mcp.ModelConfig{"gpt-4o-mini": {Name: "gpt-4o-mini"}}},
+	})
+	service.SetProviderLimits("openai", mcp.ProviderLimits{MaxConcurrent: 3, RequestsPerMinute: 20})
+	complete := func(ctx context.Context) mcp.ServiceResult {
+		return service.Execute(ctx, mcp.ServiceParams{"operation": "complete", "prompt": "Hello", "provider": "openai", "model": "gpt-4o-mini", "max_tokens": 10})
+	}
+
+	var wg sync.WaitGroup
+	failures := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result := complete(context.Background()); !result.Success {
+				failures <- result.Error
+			}
+			getBudgetTracker(t, service) // Read the budget while others update it
+		}()
+	}
+	wg.Wait()
+	close(failures)
+	for err := range failures {
+		t.Errorf("Expected every request to wait for a slot and succeed, got %v", err)
+	}
+
+	// The budget is handed out as a snapshot that later spending leaves alone
+	snapshot := getBudgetTracker(t, service)
+	service.UpdateBudgetForTest("openai", "complete", 15, 0.01)
+	if calls := snapshot.ByProvider["openai"].Calls; calls != 20 {
+		t.Errorf("Expected the budget snapshot to keep 20 calls, got %d", calls)
+	}
+	if peak != 3 {
+		t.Errorf("Expected at most 3 requests in flight, and the cap reached, got a peak of %d", peak)
+	}
+
+	result := service.Execute(context.Background(), mcp.ServiceParams{"operation": "get_limits"})
+	if !result.Success {
+		t.Fatalf("get_limits failed: %v", result.Error)
+	}
+	report := result.Data.([]mcp.ProviderUtilization)
+	if len(report) != 1 || report[0].Provider != "openai" || report[0].RequestsLastMinute != 20 ||
+		report[0].TokensLastMinute != 300 || report[0].InFlight != 0 || report[0].Limits.MaxConcurrent != 3 {
+		t.Errorf("Expected 20 requests of 15 tokens in the last minute, got %+v", report)
+	}
+
+	// The per-minute limit is used up; a request that cannot wait out the
+	// minute within its deadline fails at once
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if result := complete(ctx); !errors.Is(result.Error, mcp.ErrTimeout) {
+		t.Errorf("Expected a timeout waiting for the request limit, got %v", result.Error)
+	}
+	if err := service.ValidateParams(mcp.ServiceParams{"operation": "get_limits"}); err != nil {
+		t.Errorf("Expected get_limits to validate, got %v", err)
+	}
+}
+
+// TestLLMStructuredOutput tests JSON replies: how each provider is asked for
+// them, and the service's single repair attempt on a malformed reply.
+func TestLLMStructuredOutput(t *testing.T) {
+	schema := &mcp.ResponseSchema{
+		Name: "verdict",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"score": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+				"notes": map[string]interface{}{"type": "string"},
+			},
+			"required": []interface{}{"score", "notes"},
+		},
+	}
+
+	// The OpenAI server replies from a script, one reply per request
+	var replies []string
+	var bodies []map[string]interface{}
+	openaiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		reply := replies[0]
+		replies = replies[1:]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]interface{}{"content": reply}}},
+			"usage":   map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 5},
+		})
+	}))
+	defer openaiServer.Close()
+
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+		"openai": &mcp.OpenAIProvider{APIKey: "key", BaseURL: openaiServer.URL, HTTPClient: openaiServer.Client(),
+			Models: map[string]mcp.ModelConfig{"gpt-4o-mini": {Name: "gpt-4o-mini", InputCost: 1, OutputCost: 1}}},
+	})
+	complete := func(responseSchema interface{}) mcp.ServiceResult {
+		params := mcp.ServiceParams{"operation": "complete", "prompt": "Judge this.", "provider": "openai", "model": "gpt-4o-mini",
+			"response_schema": responseSchema}
+		if err := service.ValidateParams(params); err != nil {
+			return mcp.ErrorResult(err)
+		}
+		return service.Execute(context.Background(), params)
+	}
+
+	// A malformed first reply is repaired with a second request
+	replies = []string{"Sure! Here is my verdict: {\"score\": 0.7", `{"score": 0.7, "notes": "Fine"}`}
+	result := complete(schema)
+	if !result.Success {
+		t.Fatalf("Expected the repaired reply to succeed, got %v", result.Error)
+	}
+	response := result.Data.(*mcp.CompletionResponse)
+	if response.Text != `{"score": 0.7, "notes": "Fine"}` || response.TokensUsed != 30 || response.Metadata["repaired"] != true {
+		t.Errorf("Expected the repaired reply with both attempts' usage, got %q, %d tokens, %v", response.Text, response.TokensUsed, response.Metadata)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(bodies))
+	}
+	format, _ := bodies[0]["response_format"].(map[string]interface{})
+	if format["type"] != "json_schema" || format["json_schema"].(map[string]interface{})["name"] != "verdict" {
+		t.Errorf("Expected OpenAI to be sent the schema as the response format, got %v", bodies[0]["response_format"])
+	}
+	repair, _ := bodies[1]["messages"].([]interface{})
+	if len(repair) != 3 || repair[1].(map[string]interface{})["role"] != "assistant" ||
+		!strings.Contains(repair[2].(map[string]interface{})["content"].(string), "not valid JSON") {
+		t.Errorf("Expected the repair request to return the bad reply with the problem, got %v", repair)
+	}
+
+	// A reply that breaks the schema twice fails
+	bodies = nil
+	replies = []string{`{"score": 3, "notes": "Great"}`, `{"score": 0.5}`}
+	result = complete(schema)
+	if result.Success || !errors.Is(result.Error, mcp.ErrInvalidResponse) || errs.CodeOf(result.Error) != errs.Validation {
+		t.Errorf("Expected an invalid response error, got %v", result.Error)
+	}
+	if len(bodies) != 2 || !strings.Contains(bodies[1]["messages"].([]interface{})[2].(map[string]interface{})["content"].(string), "at most 1") {
+		t.Errorf("Expected one repair naming the out-of-range score")
+	}
+
+	// JSON mode asks for any object, fenced or not
+	bodies = nil
+	replies = []string{"```json\n{\"ok\": true}\n```"}
+	result = complete("json")
+	if !result.Success || result.Data.(*mcp.CompletionResponse).Text != `{"ok": true}` {
+		t.Errorf("Expected the fenced object to be accepted, got %v", result.Error)
+	}
+	if format, _ := bodies[0]["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("Expected OpenAI's JSON mode, got %v", bodies[0]["response_format"])
+	}
+
+	if result := complete(42); errs.CodeOf(result.Error) != errs.Validation {
+		t.Errorf("Expected a bad response_schema to be rejected, got %v", result.Error)
+	}
+
+	// Anthropic is made to call a tool whose input is the reply
+	var anthropicBody map[string]interface{}
+	anthropicServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&anthropicBody)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": []map[string]interface{}{{"type": "tool_use", "name": "verdict", "input": map[string]interface{}{"score": 0.2, "notes": "Weak"}}},
+		})
+	}))
+	defer anthropicServer.Close()
+
+	anthropic := &mcp.AnthropicProvider{APIKey: "key", BaseURL: anthropicServer.URL, HTTPClient: anthropicServer.Client()}
+	reply, err := anthropic.Complete(context.Background(), mcp.CompletionRequest{Model: "claude-3-haiku", Prompt: "Judge this.", MaxTokens: 100, ResponseSchema: schema})
+	if err != nil {
+		t.Fatalf("Anthropic request failed: %v", err)
+	}
+	if reply.Text != `{"notes":"Weak","score":0.2}` {
+		t.Errorf("Expected the tool input as the reply, got %q", reply.Text)
+	}
+	if choice, _ := anthropicBody["tool_choice"].(map[string]interface{}); choice["name"] != "verdict" {
+		t.Errorf("Expected the verdict tool to be forced, got %v", anthropicBody["tool_choice"])
+	}
+}
+
+func TestLLMRetrySchedule(t *testing.T) {
+	newFailingService := func(attempts *[]time.Time) (*mcp.LLMService, func()) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*attempts = append(*attempts, time.Now())
+			w.WriteHeader(503)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"message": "overloaded"},
+			})
+		}))
+		service := mcp.NewLLMService(nil)
+		service.SetProvider("anthropic", &mcp.AnthropicProvider{
+			APIKey:     "test-key",
+			BaseURL:    server.URL,
+			HTTPClient: &http.Client{Timeout: 5 * time.Second},
+			Models: map[string]mcp.ModelConfig{
+				"claude-3-haiku": {Name: "claude-3-haiku", InputCost: 0.25, OutputCost: 1.25},
+			},
+		})
+		return service, server.Close
+	}
+	params := mcp.ServiceParams{
+		"operation": "complete",
+		"prompt":    "Hello!",
+		"provider":  "anthropic",
+		"model":     "claude-3-haiku",
+	}
+
+	t.Run("backoff", func(t *testing.T) {
+		var attempts []time.Time
+		service, closeServer := newFailingService(&attempts)
+		defer closeServer()
+
+		// Waits grow by BackoffRate from BaseDelay and stop growing at MaxDelay
+		service.SetRetryConfig(mcp.RetryConfig{
+			MaxRetries:  3,
+			BaseDelay:   20 * time.Millisecond,
+			MaxDelay:    30 * time.Millisecond,
+			BackoffRate: 4.0,
+		})
+
+		result := service.Execute(context.Background(), params)
+		if result.Success {
+			t.Fatal("Expected the request to fail")
+		}
+		if !strings.Contains(result.Error.Error(), "operation failed after 3 retries") {
+			t.Errorf("Expected the retries to be reported, got %v", result.Error)
+		}
+		if len(attempts) != 4 {
+			t.Fatalf("Expected 4 attempts, got %d", len(attempts))
+		}
+
+		minimums := []time.Duration{20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond}
+		for i, minimum := range minimums {
+			gap := attempts[i+1].Sub(attempts[i])
+			if gap < minimum || gap >= minimum+40*time.Millisecond {
+				t.Errorf("Expected retry %d after %v, got %v", i+1, minimum, gap)
+			}
+		}
+	})
+
+	t.Run("retry_after", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(429)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"message": "Rate limit exceeded"},
+			})
+		}))
+		defer server.Close()
+
+		provider := &mcp.AnthropicProvider{APIKey: "test-key", BaseURL: server.URL, HTTPClient: server.Client()}
+		_, err := provider.Complete(context.Background(), mcp.CompletionRequest{Model: "claude-3-haiku", Prompt: "Hello!"})
+		var apiErr *mcp.APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("Expected an API error, got %v", err)
+		}
+		if apiErr.RetryAfter != 7*time.Second || apiErr.RetryClass() != "rate_limit" {
+			t.Errorf("Expected a rate limit asking for 7s, got %s after %v", apiErr.RetryClass(), apiErr.RetryAfter)
+		}
+
+		// The hint replaces the backoff, capped by MaxDelay
+		config := mcp.RetryConfig{MaxRetries: 2, BaseDelay: time.Second, MaxDelay: 5 * time.Second, BackoffRate: 2, RespectRetryAfter: true}
+		if delay, ok := config.Policy().Next(1, err); !ok || delay != 5*time.Second {
+			t.Errorf("Expected the hinted wait capped at 5s, got %v", delay)
+		}
+	})
+
+	t.Run("cancelled_during_wait", func(t *testing.T) {
+		var attempts []time.Time
+		service, closeServer := newFailingService(&attempts)
+		defer closeServer()
+		service.SetRetryConfig(mcp.RetryConfig{
+			MaxRetries:  3,
+			BaseDelay:   time.Second,
+			MaxDelay:    time.Second,
+			BackoffRate: 2.0,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		result := service.Execute(ctx, params)
+		if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+			t.Errorf("Expected the wait to end with the context, took %v", elapsed)
+		}
+		if result.Success || !strings.Contains(result.Error.Error(), "context cancelled during retry") {
+			t.Errorf("Expected a cancellation during the wait, got %v", result.Error)
+		}
+		if len(attempts) != 1 {
+			t.Errorf("Expected a single attempt before the wait, got %d", len(attempts))
+		}
+	})
+}
+
+func TestLLMFailedAttemptsCountInBudget(t *testing.T) {
+	// The first attempt is rejected after the provider processed it, the
+	// second succeeds
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		usage := map[string]interface{}{"input_tokens": 1000, "output_tokens": 200}
+		if requests == 1 {
+			w.WriteHeader(529)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"type": "overloaded_error", "message": "Overloaded"},
+				"usage": usage,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": []interface{}{map[string]interface{}{"type": "text", "text": "Hello!"}},
+			"usage":   usage,
+		})
+	}))
+	defer server.Close()
+
+	service := mcp.NewLLMService(nil)
+	service.SetRetryConfig(mcp.RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffRate: 1})
+	service.SetProvider("anthropic", &mcp.AnthropicProvider{
+		APIKey:     "test-key",
+		BaseURL:    server.URL,
+		HTTPClient: server.Client(),
+		Models: map[string]mcp.ModelConfig{
+			"claude-3-haiku": {Name: "claude-3-haiku", InputCost: 0.25, OutputCost: 1.25},
+		},
+	})
+
+	result := service.Execute(context.Background(), mcp.ServiceParams{
+		"operation": "complete", "prompt": "Hello!", "provider": "anthropic", "model": "claude-3-haiku",
+	})
+	if !result.Success {
+		t.Fatalf("Expected the retry to succeed: %v", result.Error)
+	}
+	failed, _ := result.Metadata["failed_attempts"].([]mcp.FailedAttempt)
+	if len(failed) != 1 {
+		t.Fatalf("Expected the one failed attempt reported, got %v", result.Metadata["failed_attempts"])
+	}
+	attemptCost := failed[0].Cost
+	if attempt := failed[0]; attempt.Attempt != 1 || attempt.TokensUsed != 1200 || attemptCost == 0 || attempt.Model != "claude-3-haiku" {
+		t.Errorf("Expected the first attempt's usage, got %+v", attempt)
+	}
+
+	// Both attempts were billed; the first is wasted spend
+	budget := getBudgetTracker(t, service)
+	if budget.TotalTokens != 2400 || budget.TotalCost != 2*attemptCost {
+		t.Errorf("Expected both attempts in the totals, got %d tokens, $%f", budget.TotalTokens, budget.TotalCost)
+	}
+	if budget.FailedTokens != 1200 || budget.FailedCost != attemptCost {
+		t.Errorf("Expected the failed attempt broken out, got %d tokens, $%f", budget.FailedTokens, budget.FailedCost)
+	}
+	if calls := budget.ByProvider["anthropic"].Calls; calls != 2 {
+		t.Errorf("Expected both attempts counted as calls, got %d", calls)
+	}
+}
+
 func TestLLMBudgetLimits(t *testing.T) {
 	// Create service with environment to have at least one provider
 	os.Setenv("ANTHROPIC_API_KEY", "test-key")
@@ -682,6 +1720,99 @@ func TestLLMBudgetLimits(t *testing.T) {
 	}
 }
 
+// getBudgetTracker reads the service's budget through get_budget.
+func getBudgetTracker(t *testing.T, service *mcp.LLMService) *mcp.BudgetTracker {
+	t.Helper()
+	result := service.Execute(context.Background(), mcp.ServiceParams{"operation": "get_budget"})
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.Error)
+	}
+	tracker, ok := result.Data.(*mcp.BudgetTracker)
+	if !ok {
+		t.Fatalf("Expected a budget tracker, got %T", result.Data)
+	}
+	return tracker
+}
+
+// TestLLMBudgetRollsOverAtMidnight tests that a new day starts a new budget.
+func TestLLMBudgetRollsOverAtMidnight(t *testing.T) {
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	clock := utils.NewFakeClock(time.Date(2026, 3, 10, 23, 30, 0, 0, zone))
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{"anthropic": selftest.NewFakeProvider()})
+	service.SetBudgetLocation(zone)
+	service.SetClock(clock)
+	service.SetBudgetLimit(1.0)
+	service.UpdateBudgetForTest("anthropic", "complete", 1000, 1.5)
+
+	params := mcp.ServiceParams{
+		"operation": "complete",
+		"prompt":    "Hello!",
+		"provider":  "anthropic",
+		"model":     "claude-3-haiku",
+	}
+	if result := service.Execute(context.Background(), params); result.Success {
+		t.Fatal("Expected the spent budget to block the request")
+	}
+
+	// Past midnight the limit applies to the new day's spending only
+	clock.Advance(45 * time.Minute)
+	if result := service.Execute(context.Background(), params); !result.Success {
+		t.Fatalf("Expected the request on the new day to succeed, got %v", result.Error)
+	}
+
+	tracker := getBudgetTracker(t, service)
+	if tracker.TotalCost >= 1.0 || tracker.ByProvider["anthropic"].Calls != 1 {
+		t.Errorf("Expected only the new day's request counted, got $%.4f over %d calls", tracker.TotalCost, tracker.ByProvider["anthropic"].Calls)
+	}
+	if !tracker.StartTime.Equal(time.Date(2026, 3, 11, 0, 0, 0, 0, zone)) {
+		t.Errorf("Expected the day to start at midnight, got %v", tracker.StartTime)
+	}
+	if tracker.DaysTracked != 2 {
+		t.Errorf("Expected 2 days tracked, got %d", tracker.DaysTracked)
+	}
+	if tracker.Yesterday == nil || tracker.Yesterday.Date != "2026-03-10" || tracker.Yesterday.TotalCost != 1.5 || tracker.Yesterday.TotalTokens != 1000 {
+		t.Errorf("Expected yesterday's totals, got %+v", tracker.Yesterday)
+	}
+	if len(tracker.History) != 1 || tracker.History[0].Date != "2026-03-10" {
+		t.Errorf("Expected the previous day archived, got %+v", tracker.History)
+	}
+}
+
+// TestLLMBudgetRolloverTimeZoneAndIdleDays tests that days follow the budget's
+// time zone and that days without requests are counted.
+func TestLLMBudgetRolloverTimeZoneAndIdleDays(t *testing.T) {
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	clock := utils.NewFakeClock(time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC))
+	service := mcp.NewLLMServiceWithProviders(nil, nil)
+	service.SetBudgetLocation(zone)
+	service.SetClock(clock)
+	service.UpdateBudgetForTest("anthropic", "complete", 400, 0.2)
+
+	// Midnight in UTC is still evening in the budget's time zone
+	clock.Advance(time.Hour)
+	if tracker := getBudgetTracker(t, service); tracker.TotalCost != 0.2 || tracker.DaysTracked != 1 || tracker.Yesterday != nil {
+		t.Errorf("Expected the same budget day, got $%.2f over %d days", tracker.TotalCost, tracker.DaysTracked)
+	}
+
+	clock.Advance(72 * time.Hour)
+	tracker := getBudgetTracker(t, service)
+	if tracker.TotalCost != 0 || tracker.DaysTracked != 4 {
+		t.Errorf("Expected a fresh day 4, got $%.2f over %d days", tracker.TotalCost, tracker.DaysTracked)
+	}
+	if tracker.Yesterday == nil || tracker.Yesterday.Date != "2026-03-12" || tracker.Yesterday.TotalCost != 0 {
+		t.Errorf("Expected an idle yesterday, got %+v", tracker.Yesterday)
+	}
+	if len(tracker.History) != 1 || tracker.History[0].Date != "2026-03-10" || tracker.History[0].TotalCost != 0.2 {
+		t.Errorf("Expected only the day with spending archived, got %+v", tracker.History)
+	}
+
+	// A manual reset clears today's counters but keeps the archive
+	service.Execute(context.Background(), mcp.ServiceParams{"operation": "reset_budget"})
+	if tracker := getBudgetTracker(t, service); tracker.DaysTracked != 4 || len(tracker.History) != 1 {
+		t.Errorf("Expected the reset to keep history, got %d days and %d archived", tracker.DaysTracked, len(tracker.History))
+	}
+}
+
 // TestLLMProviderSelection tests automatic provider selection logic.
 func TestLLMProviderSelection(t *testing.T) {
 	// Set up multiple providers
@@ -766,6 +1897,127 @@ func TestLLMProviderSelection(t *testing.T) {
 	}
 }
 
+// TestLLMProviderHealth takes a local server down and up again and checks
+// that the health monitor moves routing off it and back.
+func TestLLMProviderHealth(t *testing.T) {
+	var up atomic.Bool
+	up.Store(true)
+	var generated atomic.Int32
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/model":
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": "llama-2-7b-chat"})
+		case "/api/v1/generate":
+			generated.Add(1)
+			json.NewEncoder(w).Encode(map[string]interface{}{"results": []map[string]interface{}{{"text": "Hello from local"}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer local.Close()
+
+	hosted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/models":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{}})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"choices": []map[string]interface{}{{"message": map[string]interface{}{"role": "assistant", "content": "Hello from OpenAI"}}},
+				"usage":   map[string]interface{}{"prompt_tokens": 5.0, "completion_tokens": 4.0, "total_tokens": 9.0},
+			})
+		}
+	}))
+	defer hosted.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	service := mcp.NewLLMServiceWithProviders(nil, map[string]mcp.LLMProvider{
+		"local": &mcp.LocalProvider{ServerURL: local.URL, HTTPClient: client, Models: map[string]mcp.ModelConfig{
+			"local-llama": {Name: "llama-2-7b-chat", MaxTokens: 4096, ContextSize: 4096, SupportsChat: true, QualityTier: "standard", SpeedTier: 1},
+		}},
+		"openai": &mcp.OpenAIProvider{APIKey: "test-key", BaseURL: hosted.URL, HTTPClient: client, Models: map[string]mcp.ModelConfig{
+			"gpt-3.5-turbo": {Name: "gpt-3.5-turbo", InputCost: 0.5, OutputCost: 1.5, MaxTokens: 4096, ContextSize: 16385, SupportsChat: true, QualityTier: "standard", SpeedTier: 1},
+		}},
+	})
+	service.SetRetryConfig(mcp.RetryConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffRate: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.StartHealthMonitor(ctx, mcp.HealthConfig{Interval: 10 * time.Millisecond, FailureThreshold: 2})
+	router := llm.NewRouter(service)
+
+	routedTo := func() string {
+		t.Helper()
+		result, err := router.Route(ctx, llm.TaskRequest{Prompt: "Say hello", TaskType: "qa", MaxTokens: 50})
+		if err != nil {
+			t.Fatalf("Routing failed: %v", err)
+		}
+		return result.SelectedModel.Provider
+	}
+
+	// The free local model is preferred while it is up
+	if provider := routedTo(); provider != "local" {
+		t.Fatalf("Expected the local provider while it is up, got %s", provider)
+	}
+
+	// Once its health checks fail it is skipped, and requests naming it fail
+	// without reaching it
+	up.Store(false)
+	status := waitForProviderHealth(t, service, "local", false)
+	if status["last_error"] == nil {
+		t.Errorf("Expected the failed check reported, got %v", status)
+	}
+	sent := generated.Load()
+	if provider := routedTo(); provider != "openai" {
+		t.Errorf("Expected routing to move to openai, got %s", provider)
+	}
+	result := service.Execute(ctx, mcp.ServiceParams{"operation": "complete", "prompt": "Hi", "provider": "local"})
+	if !errors.Is(result.Error, mcp.ErrProviderUnhealthy) {
+		t.Errorf("Expected ErrProviderUnhealthy for the local provider, got %v", result.Error)
+	}
+	result = service.Execute(ctx, mcp.ServiceParams{"operation": "complete", "prompt": "Hi"})
+	if !result.Success || result.Data.(*mcp.CompletionResponse).Provider != "openai" {
+		t.Errorf("Expected automatic selection to skip the local provider, got %+v", result)
+	}
+	if generated.Load() != sent {
+		t.Error("Expected no request to reach the local server while it is out of service")
+	}
+
+	// A passing health check re-admits it
+	up.Store(true)
+	waitForProviderHealth(t, service, "local", true)
+	if provider := routedTo(); provider != "local" {
+		t.Errorf("Expected routing back to the local provider, got %s", provider)
+	}
+}
+
+// waitForProviderHealth polls list_providers until the provider has been
+// checked and has the given health, and returns its entry.
+func waitForProviderHealth(t *testing.T, service *mcp.LLMService, provider string, healthy bool) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result := service.Execute(context.Background(), mcp.ServiceParams{"operation": "list_providers"})
+		if !result.Success {
+			t.Fatalf("list_providers failed: %v", result.Error)
+		}
+		for _, info := range result.Data.(map[string]interface{})["providers"].([]map[string]interface{}) {
+			if info["name"] == provider && info["healthy"] == healthy && info["last_checked"] != "" {
+				return info
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s to be healthy=%v", provider, healthy)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
 // TestLLMIntegration tests the LLM service with the MCP framework.
 func TestLLMIntegration(t *testing.T) {
 	// Create service registry
@@ -810,4 +2062,37 @@ func TestLLMIntegration(t *testing.T) {
 	if registryCall, exists := result.Metadata["registry_call"]; !exists || !registryCall.(bool) {
 		t.Errorf("Expected registry_call metadata")
 	}
-}
\ No newline at end of file
+}
+// TestLLMBudgetEnforcement tests that soft enforcement lets running plans
+// spend into the grace past the daily limit, and warn-only never blocks.
+func TestLLMBudgetEnforcement(t *testing.T) {
+	service := mcp.NewLLMServiceWithProviders(log.New(io.Discard, "", 0), map[string]mcp.LLMProvider{"anthropic": selftest.NewFakeProvider()})
+	service.SetBudgetLimit(1.0)
+	service.SetBudgetEnforcement(mcp.BudgetEnforcementSoftWithGrace, 10)
+	service.UpdateBudgetForTest("anthropic", "complete", 1000, 1.05)
+
+	request := func(planID string) mcp.ServiceResult {
+		params := mcp.ServiceParams{"operation": "complete", "prompt": "Hello!", "provider": "anthropic", "model": "claude-3-haiku"}
+		if planID != "" {
+			params["metadata"] = map[string]interface{}{"plan_id": planID}
+		}
+		return service.Execute(context.Background(), params)
+	}
+	if result := request(""); result.Success || errs.CodeOf(result.Error) != errs.BudgetExceeded {
+		t.Errorf("Expected a request outside a plan blocked at the limit, got %v", result.Error)
+	}
+	if result := request("plan_1"); !result.Success {
+		t.Errorf("Expected the running plan's request allowed within the grace, got %v", result.Error)
+	}
+
+	// Once the grace is spent the plan is blocked too
+	service.UpdateBudgetForTest("anthropic", "complete", 1000, 0.05)
+	if result := request("plan_1"); result.Success {
+		t.Error("Expected the plan blocked once the grace is spent")
+	}
+
+	service.SetBudgetEnforcement(mcp.BudgetEnforcementWarnOnly, 0)
+	if result := request(""); !result.Success {
+		t.Errorf("Expected warn-only enforcement not to block, got %v", result.Error)
+	}
+}
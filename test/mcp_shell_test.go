@@ -0,0 +1,82 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// TestShellServiceWithEthicalReview runs a risky command through the shell
+// service with the ethical framework as its reviewer, and checks the command
+// runs only once the user approves it.
+func TestShellServiceWithEthicalReview(t *testing.T) {
+	fixtures := NewTestFixtures(t)
+	ctx := context.Background()
+
+	root := t.TempDir()
+	service, err := mcp.NewShellService(mcp.ShellConfig{Root: root, AllowedCommands: []string{"rm", "ls"}}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell service: %v", err)
+	}
+	registry := mcp.NewServiceRegistry(nil)
+	if err := registry.RegisterService(service); err != nil {
+		t.Fatalf("Failed to register shell service: %v", err)
+	}
+
+	ethics := core.NewEthicalFramework(fixtures.Store, llm.NewRouter(&ScriptedLLMService{}), core.NewUserContextManager(fixtures.Store))
+	approve := false
+	var prompted []*core.EthicalDecision
+	service.SetReviewer(core.NewCommandReviewer(ethics, "test-user", func(ctx context.Context, decision *core.EthicalDecision) (bool, string, error) {
+		prompted = append(prompted, decision)
+		return approve, "", nil
+	}))
+
+	notes := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(notes, []byte("draft"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	remove := mcp.ServiceParams{"operation": "run", "command": "rm", "args": []string{"notes.txt"}}
+
+	// Declined: the file stays
+	result := registry.CallService(ctx, "shell", remove)
+	if result.Success || !errors.Is(result.Error, core.ErrTaskNotApproved) {
+		t.Fatalf("Expected the declined command refused, got %v", result.Error)
+	}
+	if _, err := os.Stat(notes); err != nil {
+		t.Fatalf("Expected the file kept, got %v", err)
+	}
+
+	// Approved: the command runs and its decision is implemented
+	approve = true
+	result = registry.CallService(ctx, "shell", remove)
+	if !result.Success {
+		t.Fatalf("Expected the approved command to run, got %v", result.Error)
+	}
+	if _, err := os.Stat(notes); !os.IsNotExist(err) {
+		t.Errorf("Expected the file removed, got %v", err)
+	}
+	if len(prompted) != 2 || prompted[1].ProposedAction != "Run rm notes.txt" {
+		t.Fatalf("Expected each run put up for approval, got %d prompts", len(prompted))
+	}
+	decision, err := ethics.GetDecision(ctx, prompted[1].ID)
+	if err != nil {
+		t.Fatalf("Failed to get decision: %v", err)
+	}
+	if decision.State() != core.DecisionStateImplemented {
+		t.Errorf("Expected the approved decision implemented, got %s", decision.State())
+	}
+
+	// Safe commands are not reviewed
+	if result := registry.CallService(ctx, "shell", mcp.ServiceParams{"operation": "run", "command": "ls"}); !result.Success {
+		t.Errorf("ls failed: %v", result.Error)
+	}
+	if len(prompted) != 2 {
+		t.Errorf("Expected ls to run without review, got %d prompts", len(prompted))
+	}
+}
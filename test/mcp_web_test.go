@@ -0,0 +1,47 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// TestWebServiceAsExecutorTool registers the web service with an executor
+// and checks plans are offered it, and that it fetches through the registry.
+func TestWebServiceAsExecutorTool(t *testing.T) {
+	ctx := context.Background()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><title>Release notes</title><p>Version 2 adds dark mode.</p></html>")
+	}))
+	defer site.Close()
+
+	tools := mcp.NewServiceRegistry(nil)
+	if err := tools.RegisterService(mcp.NewWebService(mcp.WebConfig{}, nil)); err != nil {
+		t.Fatalf("Failed to register web service: %v", err)
+	}
+	executor := core.NewRouterTaskExecutor(llm.NewRouter(&ScriptedLLMService{}))
+
+	available, err := executor.GetAvailableTools(ctx)
+	if err != nil || len(available) != 1 || available[0] != "llm" {
+		t.Fatalf("Expected only the LLM without tools, got %v, %v", available, err)
+	}
+	executor.SetTools(tools)
+	available, err = executor.GetAvailableTools(ctx)
+	if err != nil || len(available) != 2 || available[1] != "web" {
+		t.Fatalf("Expected the web service advertised, got %v, %v", available, err)
+	}
+
+	result := tools.CallService(ctx, "web", mcp.ServiceParams{"operation": "fetch", "url": site.URL})
+	if !result.Success {
+		t.Fatalf("fetch failed: %v", result.Error)
+	}
+	if page := result.Data.(*mcp.WebPage); page.Title != "Release notes" || page.Text != "Version 2 adds dark mode." {
+		t.Errorf("Expected the page's title and text, got %+v", page)
+	}
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/internal/selftest"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// TestMethodPlaybookNarrative generates a playbook narrative through the
+// router using the self-test fake provider, so no network access is needed.
+func TestMethodPlaybookNarrative(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+
+	store, err := storage.NewStore(filepath.Join(tempDir, "data"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	methodManager := core.NewMethodManager(store)
+	original, err := methodManager.CreateMethod(ctx, "Inbox triage", "Sort new email into actions.",
+		[]core.ApproachStep{{Description: "Read new messages", Tools: []string{"email_read"}}},
+		core.MethodDomainUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+
+	current := original
+	for i, reason := range []string{"Refined due to: newsletters cluttered the summary", "Refined due to: urgent mail was missed"} {
+		evolved := &core.Method{
+			Name:        current.Name,
+			Description: current.Description,
+			Approach: append(append([]core.ApproachStep{}, current.Approach...),
+				core.ApproachStep{Description: fmt.Sprintf("Extra filter %d", i+1)}),
+			Domain:    current.Domain,
+			Version:   fmt.Sprintf("1.%d.0", i+1),
+			Status:    core.MethodStatusActive,
+			CreatedAt: time.Now(),
+		}
+		if err := methodManager.CreateMethodEvolution(ctx, current.ID, evolved, reason); err != nil {
+			t.Fatalf("Failed to evolve method: %v", err)
+		}
+		current = evolved
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	provider := selftest.NewFakeProvider()
+	service := mcp.NewLLMServiceWithProviders(logger, map[string]mcp.LLMProvider{
+		"anthropic": provider,
+		"openai":    provider,
+		"local":     provider,
+	})
+	service.SetRetryConfig(mcp.RetryConfig{
+		MaxRetries:  2,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+		BackoffRate: 2.0,
+	})
+	router := llm.NewRouter(service)
+
+	budget, err := llm.NewBudgetManager(filepath.Join(tempDir, "budget"), llm.BudgetConfig{
+		DailyLimit:      1.0,
+		WeeklyLimit:     7.0,
+		MonthlyLimit:    30.0,
+		TrackingEnabled: true,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+
+	// Without a narrative the playbook makes no LLM calls
+	opts := core.DefaultPlaybookOptions()
+	opts.Router = router
+	opts.Budget = budget
+	if _, err := methodManager.GeneratePlaybook(ctx, current.ID, opts); err != nil {
+		t.Fatalf("Failed to generate playbook: %v", err)
+	}
+	if completions, _ := provider.Calls(); completions != 0 {
+		t.Errorf("Expected no LLM calls without a narrative, got %d", completions)
+	}
+
+	opts.UseLLMNarrative = true
+	playbook, err := methodManager.GeneratePlaybook(ctx, current.ID, opts)
+	if err != nil {
+		t.Fatalf("Failed to generate playbook with narrative: %v", err)
+	}
+	if playbook.NarrativeError != nil {
+		t.Fatalf("Narrative failed: %v", playbook.NarrativeError)
+	}
+	if completions, _ := provider.Calls(); completions != 1 {
+		t.Errorf("Expected exactly one LLM call for the narrative, got %d", completions)
+	}
+
+	if !strings.HasPrefix(playbook.Narrative, "Fake completion:") {
+		t.Errorf("Expected the fake provider's narrative, got %q", playbook.Narrative)
+	}
+	if !strings.Contains(playbook.Markdown, "## Narrative\n\n"+playbook.Narrative) {
+		t.Errorf("Expected narrative section in playbook:\n%s", playbook.Markdown)
+	}
+	if !strings.Contains(playbook.Markdown, "Refined due to: urgent mail was missed") {
+		t.Errorf("Expected lineage reasons in playbook:\n%s", playbook.Markdown)
+	}
+	if playbook.NarrativeCost <= 0 {
+		t.Errorf("Expected a narrative cost, got %f", playbook.NarrativeCost)
+	}
+
+	spending := budget.GetSpendingAnalysis().TaskTypeBreakdown[core.PlaybookTaskType]
+	if spending != playbook.NarrativeCost {
+		t.Errorf("Expected %f attributed to %q, got %f", playbook.NarrativeCost, core.PlaybookTaskType, spending)
+	}
+
+	// A failing provider leaves the mechanical playbook intact
+	for i := 0; i < 10; i++ {
+		provider.FailNext(fmt.Errorf("provider unavailable"))
+	}
+	playbook, err = methodManager.GeneratePlaybook(ctx, current.ID, opts)
+	if err != nil {
+		t.Fatalf("Narrative failure should not fail the playbook: %v", err)
+	}
+	if playbook.NarrativeError == nil {
+		t.Error("Expected a narrative error when the provider fails")
+	}
+	if strings.Contains(playbook.Markdown, "## Narrative") || !strings.Contains(playbook.Markdown, "## Steps") {
+		t.Errorf("Expected the mechanical playbook without a narrative:\n%s", playbook.Markdown)
+	}
+}
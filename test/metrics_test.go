@@ -0,0 +1,203 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/internal/selftest"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/errs"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+	"github.com/Solifugus/ai-work-studio/pkg/metrics"
+)
+
+// scrapeMetrics fetches the endpoint and returns the label sets of each
+// sample, keyed by sample name.
+func scrapeMetrics(t *testing.T, addr string) map[string][]map[string]string {
+	t.Helper()
+	resp, err := http.Get("http://" + addr + metrics.Path)
+	if err != nil {
+		t.Fatalf("Scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	samples := make(map[string][]map[string]string)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		series := line[:strings.LastIndex(line, " ")]
+		name, labels := series, map[string]string{}
+		if open := strings.Index(series, "{"); open >= 0 {
+			name = series[:open]
+			for _, pair := range strings.Split(strings.TrimSuffix(series[open+1:], "}"), ",") {
+				key, value, _ := strings.Cut(pair, "=")
+				labels[key] = strings.Trim(value, `"`)
+			}
+		}
+		samples[name] = append(samples[name], labels)
+	}
+	return samples
+}
+
+// labelNames returns the sorted label names of a label set.
+func labelNames(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// hasSample reports whether one of the samples carries every wanted label.
+func hasSample(samples []map[string]string, want map[string]string) bool {
+	for _, labels := range samples {
+		matched := true
+		for key, value := range want {
+			if labels[key] != value {
+				matched = false
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	metrics.Default.Reset()
+	server := metrics.NewServer(metrics.Default, "127.0.0.1:0")
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to serve metrics: %v", err)
+	}
+	defer func() {
+		server.Close()
+		metrics.Default.Reset()
+	}()
+
+	logger := log.New(io.Discard, "", 0)
+	provider := selftest.NewFakeProvider()
+	service := mcp.NewLLMServiceWithProviders(logger, map[string]mcp.LLMProvider{
+		"anthropic": provider,
+		"openai":    provider,
+	})
+	service.SetRetryConfig(mcp.RetryConfig{})
+	budget, err := llm.NewBudgetManager(filepath.Join(t.TempDir(), "budget"), llm.BudgetConfig{
+		DailyLimit:      1,
+		TrackingEnabled: true,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create budget manager: %v", err)
+	}
+	router := llm.NewRouter(service)
+	router.SetBudgetManager(budget)
+
+	// The first model fails, and the request falls back to the next
+	provider.FailNext(errors.New("connection reset"))
+	if _, err := router.Route(ctx, llm.TaskRequest{
+		Prompt:   "Summarize the meeting notes",
+		TaskType: "generation",
+		Policy:   llm.PolicyQualityFirst,
+	}); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+
+	// Spending past the daily limit fires its alerts
+	if err := budget.RecordUsage(ctx, llm.Transaction{Provider: "openai", Model: "gpt-4o", Cost: 1, Success: true}); err != nil {
+		t.Fatalf("RecordUsage failed: %v", err)
+	}
+
+	// One plan whose tasks succeed, and one whose tasks fail after a retry
+	fixtures := NewTestFixtures(t)
+	executor := NewMockTaskExecutor()
+	rtc := core.NewRealTimeCursor(fixtures.Store, executor, NewMockContextLoader())
+	rtc.SetRetryConfig(&core.RetryConfig{
+		MaxRetries:        1,
+		BaseDelay:         time.Millisecond,
+		MaxDelay:          time.Millisecond,
+		BackoffMultiplier: 1,
+		RetriableErrors:   []string{"mock execution failure"},
+	})
+	if _, err := rtc.ExecutePlan(ctx, fixtures.CreateTestExecutionPlan("metrics-obj", "metrics-method")); err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+	executor.ShouldFailExecution = true
+	rtc.ExecutePlan(ctx, fixtures.CreateTestExecutionPlan("metrics-failing-obj", "metrics-method"))
+
+	samples := scrapeMetrics(t, server.Addr())
+	tests := []struct {
+		name   string
+		labels string
+		want   []map[string]string
+	}{
+		{"studio_llm_requests_total", "model,provider,status", []map[string]string{
+			{"status": "success"}, {"status": string(errs.Internal)},
+		}},
+		{"studio_llm_tokens_total", "direction,model,provider", []map[string]string{
+			{"direction": "input"}, {"direction": "output"},
+		}},
+		{"studio_llm_cost_dollars_total", "model,provider", nil},
+		{"studio_llm_request_duration_seconds_bucket", "le,model,provider", []map[string]string{{"le": "+Inf"}}},
+		{"studio_llm_request_duration_seconds_count", "model,provider", nil},
+		{"studio_router_decisions_total", "outcome,policy", []map[string]string{
+			{"policy": "quality-first", "outcome": "routed"},
+		}},
+		{"studio_router_fallbacks_total", "model,provider", nil},
+		{"studio_router_cost_estimate_ratio_count", "provider", nil},
+		{"studio_budget_spend_dollars", "period", []map[string]string{
+			{"period": "daily"}, {"period": "weekly"}, {"period": "monthly"},
+		}},
+		{"studio_budget_alerts_total", "period,threshold", []map[string]string{
+			{"period": "daily", "threshold": "75"}, {"period": "daily", "threshold": "100"},
+		}},
+		{"studio_tasks_total", "status", []map[string]string{
+			{"status": string(core.TaskStatusCompleted)}, {"status": string(core.TaskStatusFailed)},
+		}},
+		{"studio_task_retries_total", "", nil},
+		{"studio_task_duration_seconds_sum", "status", nil},
+	}
+	for _, tt := range tests {
+		series := samples[tt.name]
+		if len(series) == 0 {
+			t.Errorf("Expected %s to be served", tt.name)
+			continue
+		}
+		for _, labels := range series {
+			if names := labelNames(labels); names != tt.labels {
+				t.Errorf("Expected %s labelled %q, got %q", tt.name, tt.labels, names)
+				break
+			}
+		}
+		for _, want := range tt.want {
+			if !hasSample(series, want) {
+				t.Errorf("Expected %s with %v, got %v", tt.name, want, series)
+			}
+		}
+	}
+
+	// Nothing is recorded once the endpoint is closed
+	server.Close()
+	metrics.Default.Reset()
+	provider.FailNext(errors.New("connection reset"))
+	router.Route(ctx, llm.TaskRequest{Prompt: "Summarize the meeting notes", TaskType: "generation"})
+	var out strings.Builder
+	metrics.Default.WriteTo(&out)
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing recorded with the endpoint closed, got:\n%s", out.String())
+	}
+}
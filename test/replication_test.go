@@ -0,0 +1,293 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/internal/config"
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+const (
+	// replicationMutations is how many changes the primary makes while the
+	// replica follows
+	replicationMutations = 10000
+
+	// replicationBacklog is how many records the primary keeps for resuming;
+	// the test's second outage is longer than this
+	replicationBacklog = 1000
+)
+
+// replicationWords fill node data so search has something to match.
+var replicationWords = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot"}
+
+// TestReadReplica runs a primary and a replica in-process, applies
+// replicationMutations changes to the primary while the replica is killed
+// and reopened twice, and checks that both then answer queries identically.
+// The first outage is resumed from the backlog; the second is longer than
+// the backlog and needs a new snapshot.
+func TestReadReplica(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping replication workload in short mode")
+	}
+
+	ctx := context.Background()
+	primaryDir := t.TempDir()
+	primary, err := storage.NewStore(primaryDir)
+	if err != nil {
+		t.Fatalf("Failed to create primary store: %v", err)
+	}
+	defer primary.Close()
+
+	rollups := core.NewRollupManager(primary)
+	if err := rollups.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize rollups: %v", err)
+	}
+
+	settings := config.ReplicationConfig{Listen: "127.0.0.1:0"}
+	serverConfig := settings.ServerConfig(primaryDir)
+	serverConfig.Backlog = replicationBacklog
+	serverConfig.HeartbeatInterval = 50 * time.Millisecond
+	server, err := storage.NewReplicationServer(primary, serverConfig)
+	if err != nil {
+		t.Fatalf("Failed to create replication server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start replication server: %v", err)
+	}
+	defer server.Close()
+
+	replicaDir := config.ReplicaDir(t.TempDir())
+	replicaSettings := config.ReplicationConfig{Primary: "studio://" + server.Addr()}
+	openReplica := func() *storage.ReplicaStore {
+		t.Helper()
+		replicaConfig := replicaSettings.ReplicaConfig(config.ProfileConfig{Name: config.ProfileStandard})
+		replicaConfig.RetryInterval = 20 * time.Millisecond
+		replica, err := storage.OpenReplica(replicaDir, replicaConfig)
+		if err != nil {
+			t.Fatalf("Failed to open replica: %v", err)
+		}
+		return replica
+	}
+
+	replica := openReplica()
+	defer func() { replica.Close() }()
+
+	var midpoint time.Time
+	mutate := newReplicationWorkload(t, primary)
+	for i := 0; i < replicationMutations; i++ {
+		switch i {
+		case 3000:
+			// Kill the replica once it has caught up, so the outage is
+			// shorter than the backlog
+			waitForReplicaSequence(t, replica, primary.Sequence())
+			replica.Close()
+		case 6000:
+			// Kill the replica mid-stream
+			replica.Close()
+		case 3300, 6000 + 2*replicationBacklog:
+			replica = openReplica()
+		case replicationMutations / 2:
+			midpoint = time.Now()
+		}
+		mutate(ctx, i)
+
+		if i == 3500 {
+			// The short outage was resumed from the backlog
+			waitForReplicaSequence(t, replica, primary.Sequence())
+			if status := replica.Status(); status.Snapshots != 0 {
+				t.Errorf("Expected the replica to resume without a snapshot, got %+v", status)
+			}
+		}
+	}
+	if err := rollups.Close(ctx); err != nil {
+		t.Fatalf("Failed to persist rollups: %v", err)
+	}
+	waitForReplicaSequence(t, replica, primary.Sequence())
+
+	// A replica that falls behind by more than the backlog while streaming,
+	// as a slow one can, is sent further snapshots
+	status := replica.Status()
+	if status.Snapshots == 0 {
+		t.Errorf("Expected a snapshot after the outage longer than the backlog, got %+v", status)
+	}
+	if status.Behind() != 0 || !status.Connected {
+		t.Errorf("Expected the replica to be connected and in sync, got %+v", status)
+	}
+
+	want := replicationFingerprint(t, primary, midpoint)
+	got := replicationFingerprint(t, replica.Store, midpoint)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Expected the replica to answer queries exactly as the primary does, first difference: %s", firstDifference(want, got))
+	}
+
+	// The replica rejects writes, and its rollups are computed locally
+	err = replica.AddNode(ctx, storage.NewNode("goal", nil))
+	if err == nil {
+		t.Fatal("Expected a write on the replica to fail")
+	}
+	replicaRollups := core.NewRollupManager(replica.Store)
+	if err := replicaRollups.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize rollups on the replica: %v", err)
+	}
+	defer replicaRollups.Close(ctx)
+	primaryCounts, _ := core.NewRollupManager(primary).NodeCounts(ctx)
+	replicaCounts, err := replicaRollups.NodeCounts(ctx)
+	if err != nil || fmt.Sprint(replicaCounts) != fmt.Sprint(primaryCounts) {
+		t.Errorf("Expected replica node counts %v, got %v (%v)", primaryCounts, replicaCounts, err)
+	}
+
+	// The primary reports the replica through its status file
+	serverStatus, err := storage.ReadReplicationStatus(config.ReplicationStatusFile(primaryDir))
+	if err != nil || serverStatus == nil {
+		t.Fatalf("Expected the primary's replication status, got %v, %v", serverStatus, err)
+	}
+}
+
+// newReplicationWorkload returns a function applying mutation i to store: a
+// deterministic mix of new goals and tasks, goal updates, new edges and edge
+// updates.
+func newReplicationWorkload(t *testing.T, store *storage.Store) func(ctx context.Context, i int) {
+	random := rand.New(rand.NewSource(42))
+	var goals []string
+	var edges []string
+
+	return func(ctx context.Context, i int) {
+		t.Helper()
+		word := replicationWords[random.Intn(len(replicationWords))]
+
+		var err error
+		switch {
+		case i%5 == 0 || len(goals) == 0:
+			goal := storage.NewNode("goal", map[string]interface{}{
+				"title":      fmt.Sprintf("Goal %d %s", i, word),
+				"status":     "active",
+				"priority":   random.Intn(10) + 1,
+				"created_at": time.Now().Format(time.RFC3339),
+			})
+			err = store.AddNode(ctx, goal)
+			goals = append(goals, goal.ID)
+		case i%5 == 1:
+			err = store.UpdateNode(ctx, goals[random.Intn(len(goals))], map[string]interface{}{
+				"title":      fmt.Sprintf("Goal %d revised %s", i, word),
+				"status":     []string{"active", "paused", "completed"}[random.Intn(3)],
+				"priority":   random.Intn(10) + 1,
+				"created_at": time.Now().Format(time.RFC3339),
+			})
+		case i%5 == 2:
+			task := storage.NewNode("task", map[string]interface{}{
+				"description": fmt.Sprintf("Task %d %s", i, word),
+				"tags":        []string{word, "replicated"},
+			})
+			if err = store.AddNode(ctx, task); err == nil {
+				edge := storage.NewEdge(goals[random.Intn(len(goals))], task.ID, "contains", map[string]interface{}{"order": i})
+				err = store.AddEdge(ctx, edge)
+				edges = append(edges, edge.ID)
+			}
+		case len(edges) > 0:
+			err = store.UpdateEdge(ctx, edges[random.Intn(len(edges))], map[string]interface{}{"order": i, "weight": random.Float64()})
+		default:
+			err = store.AddNode(ctx, storage.NewNode("note", map[string]interface{}{"text": word}))
+		}
+		if err != nil {
+			t.Fatalf("Mutation %d failed: %v", i, err)
+		}
+	}
+}
+
+// waitForReplicaSequence waits until the replica has applied sequence.
+func waitForReplicaSequence(t *testing.T, replica *storage.ReplicaStore, sequence uint64) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for replica.Sequence() != sequence {
+		if time.Now().After(deadline) {
+			t.Fatalf("Replica stuck at sequence %d of %d: %+v", replica.Sequence(), sequence, replica.Status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := replica.WaitForSync(ctx); err != nil {
+		t.Fatalf("Replica did not sync: %v", err)
+	}
+}
+
+// replicationFingerprint encodes the answers to a set of queries, in a
+// stable order, so two stores can be compared byte for byte.
+func replicationFingerprint(t *testing.T, store *storage.Store, asOf time.Time) []byte {
+	t.Helper()
+	ctx := context.Background()
+	results := make(map[string]interface{})
+
+	types, err := store.GetNodeTypes(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list node types: %v", err)
+	}
+	sort.Strings(types)
+	results["types"] = types
+	for _, nodeType := range types {
+		nodes, err := store.GetNodesByType(ctx, nodeType)
+		if err != nil {
+			t.Fatalf("Failed to list %s nodes: %v", nodeType, err)
+		}
+		results["nodes/"+nodeType] = sortedNodes(nodes)
+	}
+
+	edges, err := store.Edges().OfType("contains").All()
+	if err != nil {
+		t.Fatalf("Failed to query edges: %v", err)
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].ID < edges[j].ID })
+	results["edges"] = edges
+
+	paused, err := store.Nodes().OfType("goal").WithData("status", "paused").All()
+	if err != nil {
+		t.Fatalf("Failed to query paused goals: %v", err)
+	}
+	results["paused"] = sortedNodes(paused)
+
+	earlier, err := store.Nodes().OfType("goal").AsOf(asOf).All()
+	if err != nil {
+		t.Fatalf("Failed to query goals as of the midpoint: %v", err)
+	}
+	results["as_of"] = sortedNodes(earlier)
+
+	for _, word := range replicationWords[:2] {
+		found, err := store.Search(ctx, word, storage.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Failed to search for %s: %v", word, err)
+		}
+		results["search/"+word] = sortedNodes(found)
+	}
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to encode query results: %v", err)
+	}
+	return data
+}
+
+func sortedNodes(nodes []*storage.Node) []*storage.Node {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// firstDifference describes the first line where two fingerprints differ.
+func firstDifference(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) && i < len(gotLines); i++ {
+		if wantLines[i] != gotLines[i] {
+			return fmt.Sprintf("line %d: primary %q, replica %q", i+1, wantLines[i], gotLines[i])
+		}
+	}
+	return fmt.Sprintf("primary has %d lines, replica %d", len(wantLines), len(gotLines))
+}
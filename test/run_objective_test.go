@@ -0,0 +1,175 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/llm"
+	"github.com/Solifugus/ai-work-studio/pkg/mcp"
+)
+
+// ScriptedLLMService answers planning requests by the JSON schema they ask
+// for, and task requests with a line naming the task.
+type ScriptedLLMService struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+// scriptedReplies are the replies to requests for each schema.
+var scriptedReplies = map[string]string{
+	"objective_analysis": `{"complexity_level": 3, "required_capabilities": ["writing"], "key_challenges": ["tone"],
+		"success_criteria": ["release notes published"], "estimated_token_budget": 2000,
+		"recommended_approach": "draft then publish", "domain_context": "documentation"}`,
+	"method_design": "```json\n" + `{"name": "Draft and publish", "description": "Write a draft, then publish it", "domain": "general",
+		"steps": [{"description": "Draft the text", "tools": ["llm"]}, {"description": "Publish the text", "tools": ["filesystem"]}]}` + "\n```",
+	"execution_plan": `{"title": "Publish release notes", "tasks": [
+		{"id": "draft", "type": "generate", "description": "Draft the release notes", "method_step": 0, "estimated_tokens": 300},
+		{"id": "publish", "type": "write_file", "description": "Write the release notes to NOTES.md", "method_step": 1,
+		 "estimated_tokens": 200, "side_effects": true, "depends_on": ["draft"]}]}`,
+	"ethical_impact": `{"freedom_impact": 0.1, "well_being_impact": 0.2, "sustainability_impact": 0.1, "confidence": 0.8,
+		"reasoning": "Writes a file in the user's project"}`,
+	"method_refinement": `{"type": "none", "reasoning": "The method only failed where the user declined"}`,
+}
+
+// Execute implements llm.LLMServiceInterface.
+func (s *ScriptedLLMService) Execute(ctx context.Context, params mcp.ServiceParams) mcp.ServiceResult {
+	prompt, _ := params["prompt"].(string)
+	s.mu.Lock()
+	s.prompts = append(s.prompts, prompt)
+	s.mu.Unlock()
+
+	text := "done"
+	if schema, ok := params["response_schema"].(*mcp.ResponseSchema); ok && schema != nil {
+		text = scriptedReplies[schema.Name]
+	} else if strings.Contains(prompt, "TASK (generate)") {
+		text = "Version 2 adds dark mode."
+	}
+	return mcp.ServiceResult{
+		Success: true,
+		Data:    &mcp.CompletionResponse{Text: text, TokensUsed: 100, Model: "mock-model", Provider: "mock"},
+	}
+}
+
+// taskPrompt returns the prompt the task of the given type was sent with.
+func (s *ScriptedLLMService) taskPrompt(taskType string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, prompt := range s.prompts {
+		if strings.Contains(prompt, "TASK ("+taskType+")") {
+			return prompt
+		}
+	}
+	return ""
+}
+
+// TestRunObjectiveWithRouter executes an objective end to end with the
+// router-backed reasoner, executor, context loader and learning agent, and
+// checks that a side-effecting task waits for the user's approval.
+func TestRunObjectiveWithRouter(t *testing.T) {
+	fixtures := NewTestFixtures(t)
+	ctx := context.Background()
+
+	goal, err := fixtures.GoalManager.CreateGoal(ctx, "Ship version 2", "Release the second version", 5, nil)
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	method, err := fixtures.MethodManager.CreateMethod(ctx, "Draft and publish", "Write a draft, then publish it",
+		[]core.ApproachStep{{Description: "Draft the text"}, {Description: "Publish the text"}}, core.MethodDomainGeneral, nil)
+	if err != nil {
+		t.Fatalf("Failed to create method: %v", err)
+	}
+
+	run := func(t *testing.T, approve bool) (*core.LearningResult, *ScriptedLLMService, []*core.EthicalDecision) {
+		objective, err := fixtures.ObjectiveManager.CreateObjective(ctx, goal.ID, method.ID, "Publish release notes",
+			"Write and publish the notes for version 2", map[string]interface{}{"audience": "users"}, 5)
+		if err != nil {
+			t.Fatalf("Failed to create objective: %v", err)
+		}
+
+		service := &ScriptedLLMService{}
+		router := llm.NewRouter(service)
+		ethics := core.NewEthicalFramework(fixtures.Store, router, core.NewUserContextManager(fixtures.Store))
+
+		var prompted []*core.EthicalDecision
+		executor := core.NewRouterTaskExecutor(router)
+		executor.SetEthicalReview(ethics, "test-user", func(ctx context.Context, decision *core.EthicalDecision) (bool, string, error) {
+			prompted = append(prompted, decision)
+			return approve, "checked", nil
+		})
+		loader := core.NewStoreContextLoader(fixtures.Store)
+
+		cc := core.NewContemplativeCursor(fixtures.Store, core.NewRouterReasoner(router))
+		cc.SetContextLoader(loader)
+		rtc := core.NewRealTimeCursor(fixtures.Store, executor, loader)
+		loop := core.NewLearningLoop(fixtures.Store, cc, rtc, core.NewRouterLearningAgent(router))
+
+		result, err := loop.ExecuteObjective(ctx, objective.ID)
+		if err != nil {
+			t.Fatalf("ExecuteObjective failed: %v", err)
+		}
+		if len(result.ExecutionAttempts) == 0 {
+			t.Fatal("Expected an execution attempt")
+		}
+		return result, service, prompted
+	}
+
+	t.Run("Approved", func(t *testing.T) {
+		result, service, prompted := run(t, true)
+		if !result.WasSuccessful || result.FinalOutcome != core.OutcomeSuccess {
+			t.Fatalf("Expected success, got %s: %s", result.FinalOutcome, result.ErrorMessage)
+		}
+		if len(prompted) != 1 || prompted[0].ProposedAction != "Write the release notes to NOTES.md" {
+			t.Fatalf("Expected the publish task to be put up for approval once, got %d prompts", len(prompted))
+		}
+
+		decision, err := core.NewEthicalFramework(fixtures.Store, nil, nil).GetDecision(ctx, prompted[0].ID)
+		if err != nil {
+			t.Fatalf("Failed to get decision: %v", err)
+		}
+		if decision.State() != core.DecisionStateImplemented || decision.UserFeedback != "checked" {
+			t.Errorf("Expected the approved decision to be implemented, got %s", decision.State())
+		}
+
+		publish := service.taskPrompt("write_file")
+		for _, want := range []string{"OBJECTIVE: Publish release notes", "GOAL: Ship version 2", "audience: users", "[draft]\nVersion 2 adds dark mode."} {
+			if !strings.Contains(publish, want) {
+				t.Errorf("Expected the publish prompt to contain %q:\n%s", want, publish)
+			}
+		}
+
+		attempt := result.ExecutionAttempts[0]
+		if attempt.ExecutionResult.SuccessfulTasks != 2 || result.GetTotalTokensUsed() != 200 {
+			t.Errorf("Expected 2 tasks using 200 tokens, got %d tasks and %d tokens",
+				attempt.ExecutionResult.SuccessfulTasks, result.GetTotalTokensUsed())
+		}
+		if attempt.MethodRating <= 0 || attempt.ExecutionAnalysis == nil {
+			t.Errorf("Expected the attempt to rate its method, got %.1f", attempt.MethodRating)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		result, service, prompted := run(t, false)
+		if len(prompted) != 1 {
+			t.Fatalf("Expected one approval prompt, got %d", len(prompted))
+		}
+
+		publish := result.ExecutionAttempts[0].ExecutionResult.TaskResults["publish"]
+		if publish == nil || publish.Status != core.TaskStatusFailed || !strings.Contains(publish.ErrorMessage, core.ErrTaskNotApproved.Error()) {
+			t.Fatalf("Expected the publish task to fail as not approved, got %+v", publish)
+		}
+		if service.taskPrompt("write_file") != "" {
+			t.Error("Expected the rejected task not to be sent to the LLM")
+		}
+
+		decision, err := core.NewEthicalFramework(fixtures.Store, nil, nil).GetDecision(ctx, prompted[0].ID)
+		if err != nil {
+			t.Fatalf("Failed to get decision: %v", err)
+		}
+		if decision.State() != core.DecisionStateRejected {
+			t.Errorf("Expected the decision to be rejected, got %s", decision.State())
+		}
+	})
+}
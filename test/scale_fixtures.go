@@ -0,0 +1,284 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// ScaleSpec describes the size and shape of a synthetic workspace.
+// Counts are per node type; every objective also adds its "serves" and
+// "uses" edges, every execution result and ethical decision links back to
+// an objective, and RelatedEdges random "related_to" edges fill the rest.
+type ScaleSpec struct {
+	Goals            int
+	Methods          int
+	Objectives       int
+	ExecutionResults int
+	EthicalDecisions int
+	RelatedEdges     int
+
+	// ArchivedFraction of goals are archived, together with their objectives.
+	ArchivedFraction float64
+
+	// Span is how far back in time the workspace's activity reaches.
+	Span time.Duration
+
+	// Seed makes the generated workspace reproducible.
+	Seed int64
+}
+
+// DefaultScaleSpec returns a workspace of 100k nodes and 300k edges with
+// roughly a year of history, dominated by execution results and decisions.
+func DefaultScaleSpec() ScaleSpec {
+	return ScaleSpec{
+		Goals:            1000,
+		Methods:          2000,
+		Objectives:       27000,
+		ExecutionResults: 50000,
+		EthicalDecisions: 20000,
+		RelatedEdges:     176000,
+		ArchivedFraction: 0.6,
+		Span:             365 * 24 * time.Hour,
+		Seed:             42,
+	}
+}
+
+// Scaled returns a copy of the spec with every count multiplied by factor,
+// for benchmarks that need the same shape at a smaller size.
+func (s ScaleSpec) Scaled(factor float64) ScaleSpec {
+	scale := func(n int) int {
+		scaled := int(float64(n) * factor)
+		if scaled < 1 && n > 0 {
+			scaled = 1
+		}
+		return scaled
+	}
+	s.Goals = scale(s.Goals)
+	s.Methods = scale(s.Methods)
+	s.Objectives = scale(s.Objectives)
+	s.ExecutionResults = scale(s.ExecutionResults)
+	s.EthicalDecisions = scale(s.EthicalDecisions)
+	s.RelatedEdges = scale(s.RelatedEdges)
+	return s
+}
+
+// Nodes returns the number of nodes the spec generates.
+func (s ScaleSpec) Nodes() int {
+	return s.Goals + s.Methods + s.Objectives + s.ExecutionResults + s.EthicalDecisions
+}
+
+// Edges returns the number of edges the spec generates.
+func (s ScaleSpec) Edges() int {
+	return 2*s.Objectives + s.ExecutionResults + s.EthicalDecisions + s.RelatedEdges
+}
+
+// ScaleWorkspace is a generated workspace together with handles into it.
+type ScaleWorkspace struct {
+	*TestFixtures
+	Spec ScaleSpec
+
+	GoalIDs      []string
+	ObjectiveIDs []string
+
+	// SearchTerm appears in the title of exactly SearchMatches goals and objectives.
+	SearchTerm    string
+	SearchMatches int
+
+	// Archived describes the archiving pass run after generation.
+	Archived *core.ArchiveReport
+}
+
+// scaleTopics provides realistic title vocabulary for generated nodes.
+var scaleTopics = []string{
+	"quarterly report", "inbox triage", "research notes", "vendor contract",
+	"release checklist", "budget review", "travel booking", "team onboarding",
+	"blog draft", "security audit", "customer follow-up", "data cleanup",
+}
+
+// NewScaleWorkspace generates the workspace described by spec in a fresh
+// temporary directory. Goals, methods and objectives go through the core
+// managers so they look exactly like user data; the high-volume types are
+// written directly to the store. Like a long-running workspace, it ends with
+// settled work archived and rollups persisted.
+func NewScaleWorkspace(tb testing.TB, spec ScaleSpec) *ScaleWorkspace {
+	tb.Helper()
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(spec.Seed))
+	now := time.Now()
+
+	store, err := storage.NewStore(tb.TempDir())
+	if err != nil {
+		tb.Fatalf("Failed to create scale store: %v", err)
+	}
+
+	ws := &ScaleWorkspace{
+		TestFixtures: &TestFixtures{
+			TestDataDir:      store.DataDir(),
+			Store:            store,
+			GoalManager:      core.NewGoalManager(store),
+			MethodManager:    core.NewMethodManager(store),
+			ObjectiveManager: core.NewObjectiveManager(store),
+		},
+		Spec:       spec,
+		SearchTerm: "zephyr",
+	}
+	// Callers may drop the store to reopen the workspace, so don't hold on to it here
+	tb.Cleanup(func() {
+		if ws.Store != nil {
+			ws.Store.Close()
+		}
+	})
+
+	// ago returns a random point in the workspace's history, recent days weighted higher
+	ago := func() time.Time {
+		return now.Add(-time.Duration(rng.ExpFloat64()*float64(spec.Span)/4) % spec.Span)
+	}
+	title := func(kind string, i int) string {
+		if i%997 == 0 {
+			ws.SearchMatches++
+			return fmt.Sprintf("%s %d: %s %s", kind, i, ws.SearchTerm, scaleTopics[i%len(scaleTopics)])
+		}
+		return fmt.Sprintf("%s %d: %s", kind, i, scaleTopics[rng.Intn(len(scaleTopics))])
+	}
+
+	archivedGoals := make(map[string]bool)
+	for i := 0; i < spec.Goals; i++ {
+		goal, err := ws.GoalManager.CreateGoal(ctx, title("Goal", i), "Generated goal for scale testing", 1+rng.Intn(10), nil)
+		if err != nil {
+			tb.Fatalf("Failed to create goal %d: %v", i, err)
+		}
+		if rng.Float64() < spec.ArchivedFraction {
+			status := core.GoalStatusArchived
+			if _, err := ws.GoalManager.UpdateGoal(ctx, goal.ID, core.GoalUpdates{Status: &status}); err != nil {
+				tb.Fatalf("Failed to archive goal %d: %v", i, err)
+			}
+			archivedGoals[goal.ID] = true
+		}
+		ws.GoalIDs = append(ws.GoalIDs, goal.ID)
+	}
+
+	methodIDs := make([]string, 0, spec.Methods)
+	for i := 0; i < spec.Methods; i++ {
+		steps := []core.ApproachStep{
+			{Description: "Gather the inputs", Tools: []string{"filesystem"}},
+			{Description: "Draft and review the result", Tools: []string{"llm"}},
+		}
+		method, err := ws.MethodManager.CreateMethod(ctx, fmt.Sprintf("Method %d", i), "Generated method for scale testing",
+			steps, core.MethodDomainGeneral, nil)
+		if err != nil {
+			tb.Fatalf("Failed to create method %d: %v", i, err)
+		}
+		methodIDs = append(methodIDs, method.ID)
+	}
+
+	objectiveStatuses := []core.ObjectiveStatus{
+		core.ObjectiveStatusPending, core.ObjectiveStatusInProgress,
+		core.ObjectiveStatusFailed, core.ObjectiveStatusPaused,
+	}
+	for i := 0; i < spec.Objectives; i++ {
+		goalID := ws.GoalIDs[rng.Intn(len(ws.GoalIDs))]
+		methodID := methodIDs[rng.Intn(len(methodIDs))]
+		objective, err := ws.ObjectiveManager.CreateObjective(ctx, goalID, methodID, title("Objective", i),
+			"Generated objective for scale testing", map[string]interface{}{"source": "scale"}, 1+rng.Intn(10))
+		if err != nil {
+			tb.Fatalf("Failed to create objective %d: %v", i, err)
+		}
+
+		// Objectives of archived goals are finished; live ones are spread over all states
+		status := core.ObjectiveStatusCompleted
+		if !archivedGoals[goalID] && rng.Intn(3) > 0 {
+			status = objectiveStatuses[rng.Intn(len(objectiveStatuses))]
+		}
+		if status != core.ObjectiveStatusPending {
+			started := ago()
+			updates := core.ObjectiveUpdates{Status: &status, StartedAt: &started}
+			if status == core.ObjectiveStatusCompleted {
+				completed := started.Add(time.Duration(rng.Intn(120)) * time.Minute)
+				updates.CompletedAt = &completed
+			}
+			if _, err := ws.ObjectiveManager.UpdateObjective(ctx, objective.ID, updates); err != nil {
+				tb.Fatalf("Failed to update objective %d: %v", i, err)
+			}
+		}
+		ws.ObjectiveIDs = append(ws.ObjectiveIDs, objective.ID)
+	}
+
+	allIDs := append(append(append([]string{}, ws.GoalIDs...), methodIDs...), ws.ObjectiveIDs...)
+	addLinked := func(nodeType, edgeType string, count int, data func(objectiveID string) map[string]interface{}) {
+		for i := 0; i < count; i++ {
+			objectiveID := ws.ObjectiveIDs[rng.Intn(len(ws.ObjectiveIDs))]
+			node := storage.NewNode(nodeType, data(objectiveID))
+			node.CreatedAt = ago()
+			node.ValidFrom = node.CreatedAt
+			if err := store.AddNode(ctx, node); err != nil {
+				tb.Fatalf("Failed to add %s %d: %v", nodeType, i, err)
+			}
+			if err := store.AddEdge(ctx, storage.NewEdge(node.ID, objectiveID, edgeType, nil)); err != nil {
+				tb.Fatalf("Failed to link %s %d: %v", nodeType, i, err)
+			}
+			allIDs = append(allIDs, node.ID)
+		}
+	}
+
+	addLinked("execution_result", "result_of", spec.ExecutionResults, func(objectiveID string) map[string]interface{} {
+		end := ago()
+		return map[string]interface{}{
+			"objective_id":      objectiveID,
+			"method_id":         methodIDs[rng.Intn(len(methodIDs))],
+			"status":            "completed",
+			"total_tokens_used": 200 + rng.Intn(4000),
+			"total_cost":        rng.Float64() * 0.05,
+			"total_duration":    rng.Float64() * 300,
+			"start_time":        end.Add(-time.Minute).Format(time.RFC3339),
+			"end_time":          end.Format(time.RFC3339),
+			"successful_tasks":  1 + rng.Intn(5),
+			"failed_tasks":      rng.Intn(2),
+		}
+	})
+
+	addLinked("ethical_decision", "concerns", spec.EthicalDecisions, func(objectiveID string) map[string]interface{} {
+		approval := string(core.DecisionApprovalApproved)
+		if rng.Intn(50) == 0 {
+			approval = string(core.DecisionApprovalPending)
+		}
+		return map[string]interface{}{
+			"objective_id":     objectiveID,
+			"decision_context": "Generated decision for scale testing",
+			"proposed_action":  scaleTopics[rng.Intn(len(scaleTopics))],
+			"confidence_score": rng.Float64(),
+			"urgency":          "low",
+			"approval_status":  approval,
+			"created_at":       ago().Format(time.RFC3339),
+		}
+	})
+
+	for i := 0; i < spec.RelatedEdges; i++ {
+		source := allIDs[rng.Intn(len(allIDs))]
+		target := allIDs[rng.Intn(len(allIDs))]
+		if err := store.AddEdge(ctx, storage.NewEdge(source, target, "related_to", map[string]interface{}{"weight": rng.Float64()})); err != nil {
+			tb.Fatalf("Failed to add related edge %d: %v", i, err)
+		}
+	}
+
+	report, err := core.NewArchiveManager(store).ArchiveSettled(ctx, core.ArchivePolicy{}, false)
+	if err != nil {
+		tb.Fatalf("Failed to archive settled work: %v", err)
+	}
+	ws.Archived = report
+
+	rollups := core.NewRollupManager(store)
+	if err := rollups.Initialize(ctx); err != nil {
+		tb.Fatalf("Failed to build rollups: %v", err)
+	}
+	if err := rollups.Close(ctx); err != nil {
+		tb.Fatalf("Failed to persist rollups: %v", err)
+	}
+
+	return ws
+}
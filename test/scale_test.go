@@ -0,0 +1,215 @@
+//go:build scale
+
+package test
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Solifugus/ai-work-studio/pkg/core"
+	"github.com/Solifugus/ai-work-studio/pkg/storage"
+)
+
+// Budgets for a workspace of DefaultScaleSpec size. Run with:
+//
+//	go test -tags scale ./test -run TestScale -timeout 30m
+const (
+	scaleLoadBudget       = 5 * time.Second
+	scaleGetNodeBudget    = time.Millisecond
+	scaleListByGoalBudget = 50 * time.Millisecond
+	scaleSearchBudget     = 200 * time.Millisecond
+	scaleRollupBudget     = 10 * time.Millisecond
+
+	// scaleArchivedGetNodeBudget covers the first read of an archived node,
+	// which reads its whole segment from disk
+	scaleArchivedGetNodeBudget = 250 * time.Millisecond
+
+	// scaleHeapCeiling is the live heap allowed after loading the workspace
+	scaleHeapCeiling = 512 << 20
+)
+
+// timed runs fn and returns how long it took.
+func timed(fn func()) time.Duration {
+	start := time.Now()
+	fn()
+	return time.Since(start)
+}
+
+// checkBudget fails the test if elapsed exceeds budget, and logs it either way.
+func checkBudget(t *testing.T, name string, elapsed, budget time.Duration) {
+	t.Helper()
+	t.Logf("%-28s %12v (budget %v)", name, elapsed, budget)
+	if elapsed > budget {
+		t.Errorf("%s took %v, over its %v budget", name, elapsed, budget)
+	}
+}
+
+// TestScaleReadPaths generates a year-sized workspace, reopens it the way the
+// application does at startup, and holds the core read paths to their budgets.
+func TestScaleReadPaths(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping scale test in short mode")
+	}
+
+	ctx := context.Background()
+	spec := DefaultScaleSpec()
+
+	var ws *ScaleWorkspace
+	generated := timed(func() { ws = NewScaleWorkspace(t, spec) })
+	t.Logf("Generated %d nodes and %d edges in %v; %s", spec.Nodes(), spec.Edges(), generated, ws.Archived)
+	if ws.Archived.TotalNodes() == 0 {
+		t.Fatal("Expected the workspace to have archived settled work")
+	}
+	if err := ws.Store.Close(); err != nil {
+		t.Fatalf("Failed to close generated store: %v", err)
+	}
+	ws.Store = nil
+	ws.GoalManager, ws.MethodManager, ws.ObjectiveManager = nil, nil, nil
+	runtime.GC()
+
+	// Startup load and memory
+	var store *storage.Store
+	var err error
+	loaded := timed(func() { store, err = storage.NewStore(ws.TestDataDir) })
+	if err != nil {
+		t.Fatalf("Failed to load workspace: %v", err)
+	}
+	defer store.Close()
+	checkBudget(t, "startup load", loaded, scaleLoadBudget)
+
+	var mem runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&mem)
+	t.Logf("%-28s %9d MB (ceiling %d MB)", "heap after load", mem.HeapAlloc>>20, scaleHeapCeiling>>20)
+	if mem.HeapAlloc > scaleHeapCeiling {
+		t.Errorf("Heap after load is %d MB, over the %d MB ceiling", mem.HeapAlloc>>20, scaleHeapCeiling>>20)
+	}
+
+	// GetNode, live and archived
+	var liveID, archivedID string
+	for _, id := range ws.ObjectiveIDs {
+		if store.IsArchived(ctx, id) {
+			if archivedID == "" {
+				archivedID = id
+			}
+		} else if liveID == "" {
+			liveID = id
+		}
+	}
+	if liveID == "" || archivedID == "" {
+		t.Fatal("Expected both live and archived objectives")
+	}
+
+	var node *storage.Node
+	elapsed := timed(func() { node, err = store.GetNode(ctx, liveID) })
+	if err != nil || node.ID != liveID {
+		t.Fatalf("Failed to get live node: %v", err)
+	}
+	checkBudget(t, "GetNode (live)", elapsed, scaleGetNodeBudget)
+
+	elapsed = timed(func() { node, err = store.GetNode(ctx, archivedID) })
+	if err != nil || node.ID != archivedID {
+		t.Fatalf("Failed to get archived node: %v", err)
+	}
+	checkBudget(t, "GetNode (archived, cold)", elapsed, scaleArchivedGetNodeBudget)
+
+	elapsed = timed(func() { node, err = store.GetNode(ctx, archivedID) })
+	if err != nil || node.ID != archivedID {
+		t.Fatalf("Failed to get archived node again: %v", err)
+	}
+	checkBudget(t, "GetNode (archived, warm)", elapsed, scaleGetNodeBudget)
+
+	// ListObjectives filtered by goal, for the live goal with the most objectives
+	objectiveManager := core.NewObjectiveManager(store)
+	perGoal := make(map[string]int)
+	for _, id := range ws.ObjectiveIDs {
+		if objective, err := store.GetNode(ctx, id); err == nil && !store.IsArchived(ctx, id) {
+			if goalID, ok := objective.Data["goal_id"].(string); ok {
+				perGoal[goalID]++
+			}
+		}
+	}
+	var busiestGoal string
+	for goalID, count := range perGoal {
+		if count > perGoal[busiestGoal] || (count == perGoal[busiestGoal] && goalID < busiestGoal) {
+			busiestGoal = goalID
+		}
+	}
+
+	var objectives []*core.Objective
+	elapsed = timed(func() {
+		objectives, err = objectiveManager.ListObjectives(ctx, core.ObjectiveFilter{GoalID: &busiestGoal})
+	})
+	if err != nil {
+		t.Fatalf("Failed to list objectives: %v", err)
+	}
+	if len(objectives) != perGoal[busiestGoal] {
+		t.Errorf("Expected %d objectives for goal, got %d", perGoal[busiestGoal], len(objectives))
+	}
+	checkBudget(t, "ListObjectives by goal", elapsed, scaleListByGoalBudget)
+
+	// Search over live data; archived matches are only found on request
+	var results []*storage.Node
+	elapsed = timed(func() { results, err = store.Search(ctx, ws.SearchTerm, storage.SearchOptions{}) })
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	checkBudget(t, "Search (live)", elapsed, scaleSearchBudget)
+	for _, result := range results {
+		if !strings.Contains(result.Data["title"].(string), ws.SearchTerm) {
+			t.Errorf("Search returned non-matching node %s", result.ID)
+		}
+	}
+
+	elapsed = timed(func() {
+		results, err = store.Search(ctx, ws.SearchTerm, storage.SearchOptions{IncludeArchived: true})
+	})
+	if err != nil {
+		t.Fatalf("Failed to search including archived: %v", err)
+	}
+	if len(results) != ws.SearchMatches {
+		t.Errorf("Expected %d matches including archived, got %d", ws.SearchMatches, len(results))
+	}
+	t.Logf("%-28s %12v (unbudgeted)", "Search (with archived)", elapsed)
+
+	// Dashboard rollups, read from the persisted snapshot
+	rollups := core.NewRollupManager(store)
+	elapsed = timed(func() { err = rollups.Initialize(ctx) })
+	if err != nil {
+		t.Fatalf("Failed to initialize rollups: %v", err)
+	}
+	t.Logf("%-28s %12v", "rollup initialize", elapsed)
+	defer rollups.Close(ctx)
+
+	var statusCounts map[core.ObjectiveStatus]int
+	elapsed = timed(func() {
+		if statusCounts, err = rollups.ObjectiveStatusCounts(ctx); err != nil {
+			return
+		}
+		if _, err = rollups.GoalStatusCounts(ctx); err != nil {
+			return
+		}
+		if _, err = rollups.NodeCounts(ctx); err != nil {
+			return
+		}
+		if _, err = rollups.UsageOnDay(ctx, time.Now()); err != nil {
+			return
+		}
+		_, err = rollups.PendingDecisionCount(ctx)
+	})
+	if err != nil {
+		t.Fatalf("Failed to read rollups: %v", err)
+	}
+	checkBudget(t, "dashboard rollup read", elapsed, scaleRollupBudget)
+
+	total := 0
+	for _, count := range statusCounts {
+		total += count
+	}
+	if total != spec.Objectives {
+		t.Errorf("Expected rollups to count all %d objectives including archived, got %d", spec.Objectives, total)
+	}
+}
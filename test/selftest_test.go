@@ -0,0 +1,103 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Solifugus/ai-work-studio/internal/selftest"
+)
+
+// recordingTransport fails and records every outbound HTTP request.
+type recordingTransport struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.requests = append(rt.requests, req.URL.String())
+	return nil, fmt.Errorf("outbound HTTP is not allowed during the self-test: %s", req.URL)
+}
+
+// TestSelfTestScenario runs the same scenario as `studio selftest`.
+func TestSelfTestScenario(t *testing.T) {
+	// Real-looking credentials must be ignored entirely
+	t.Setenv("ANTHROPIC_API_KEY", "selftest-should-not-use-this")
+	t.Setenv("OPENAI_API_KEY", "selftest-should-not-use-this")
+	t.Setenv("LOCAL_LLM_URL", "http://127.0.0.1:1")
+
+	transport := &recordingTransport{}
+	originalTransport := http.DefaultTransport
+	http.DefaultTransport = transport
+	defer func() {
+		http.DefaultTransport = originalTransport
+	}()
+
+	report, err := selftest.Run(context.Background(), selftest.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Self-test could not run: %v", err)
+	}
+
+	for _, stage := range report.Stages {
+		if !stage.Passed {
+			t.Errorf("Stage %q failed: %s", stage.Name, stage.Detail)
+		}
+	}
+
+	expectedStages := []string{
+		"fake provider", "completion", "embedding", "json mode", "rate limit",
+		"storage", "plan execution", "ethical evaluation", "budget", "routing fallback",
+	}
+	if len(report.Stages) != len(expectedStages) {
+		t.Fatalf("Expected %d stages, got %d", len(expectedStages), len(report.Stages))
+	}
+	for i, name := range expectedStages {
+		if report.Stages[i].Name != name {
+			t.Errorf("Stage %d: expected %q, got %q", i, name, report.Stages[i].Name)
+		}
+	}
+
+	if !report.Passed() {
+		t.Errorf("Expected report to pass, %d stages failed", len(report.Failures()))
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.requests) != 0 {
+		t.Errorf("Expected no outbound HTTP requests, got %v", transport.requests)
+	}
+}
+
+// TestSelfTestReportPrint verifies the checklist output and failure reporting.
+func TestSelfTestReportPrint(t *testing.T) {
+	report := &selftest.Report{
+		Stages: []selftest.StageResult{
+			{Name: "completion", Passed: true, Detail: "ok"},
+			{Name: "budget", Passed: false, Detail: "budget exceeded"},
+		},
+	}
+
+	if report.Passed() {
+		t.Error("Report with a failed stage should not pass")
+	}
+	if failures := report.Failures(); len(failures) != 1 || failures[0].Name != "budget" {
+		t.Errorf("Expected budget to be the only failure, got %v", failures)
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	output := out.String()
+
+	if !strings.Contains(output, "✓ completion") || !strings.Contains(output, "✗ budget") {
+		t.Errorf("Checklist is missing stage marks:\n%s", output)
+	}
+	if !strings.Contains(output, "1/2 stages passed") {
+		t.Errorf("Checklist is missing the summary:\n%s", output)
+	}
+}